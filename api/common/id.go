@@ -123,6 +123,7 @@ func (e Error) Msg() string {
 
 // Node contains the node id and address.
 type Node struct {
+	Labels      map[string]string
 	NodeID      string
 	GrpcAddress string
 	HTTPAddress string
@@ -131,6 +132,8 @@ type Node struct {
 var (
 	// FlagNodeHost is the node id from flag.
 	FlagNodeHost string
+	// FlagNodeLabels is the node labels from flag.
+	FlagNodeLabels map[string]string
 	// FlagNodeHostProvider is the node id provider from flag.
 	FlagNodeHostProvider NodeHostProvider
 )
@@ -173,7 +176,7 @@ func GenerateNode(grpcPort, httpPort *uint32) (Node, error) {
 	if port == nil {
 		return Node{}, fmt.Errorf("no port found")
 	}
-	node := Node{}
+	node := Node{Labels: FlagNodeLabels}
 	var nodeHost string
 	switch FlagNodeHostProvider {
 	case NodeHostProviderHostname:
@@ -0,0 +1,68 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package data
+
+import (
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+)
+
+// HeartbeatKindVersion is the version tag of heartbeat kind.
+var HeartbeatKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "heartbeat",
+}
+
+// TopicHeartbeat is the heartbeat topic.
+var TopicHeartbeat = bus.BiTopic(HeartbeatKindVersion.String())
+
+// NodeDrainKindVersion is the version tag of node drain kind.
+var NodeDrainKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "node-drain",
+}
+
+// TopicNodeDrain is the node drain topic.
+var TopicNodeDrain = bus.BiTopic(NodeDrainKindVersion.String())
+
+// SegmentDropKindVersion is the version tag of segment drop kind.
+var SegmentDropKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "segment-drop",
+}
+
+// TopicSegmentDrop is the segment drop topic.
+var TopicSegmentDrop = bus.BiTopic(SegmentDropKindVersion.String())
+
+// SnapshotKindVersion is the version tag of snapshot kind.
+var SnapshotKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "snapshot",
+}
+
+// TopicSnapshot is the snapshot topic.
+var TopicSnapshot = bus.BiTopic(SnapshotKindVersion.String())
+
+// PartShipKindVersion is the version tag of part ship kind.
+var PartShipKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "part-ship",
+}
+
+// TopicPartShip is the part ship topic.
+var TopicPartShip = bus.BiTopic(PartShipKindVersion.String())
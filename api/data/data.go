@@ -21,6 +21,7 @@ package data
 import (
 	"google.golang.org/protobuf/proto"
 
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
 	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
 	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
 	"github.com/apache/skywalking-banyandb/pkg/bus"
@@ -28,11 +29,25 @@ import (
 
 // TopicMap is the map of topic name to topic.
 var TopicMap = map[string]bus.Topic{
-	TopicStreamWrite.String():  TopicStreamWrite,
-	TopicStreamQuery.String():  TopicStreamQuery,
-	TopicMeasureWrite.String(): TopicMeasureWrite,
-	TopicMeasureQuery.String(): TopicMeasureQuery,
-	TopicTopNQuery.String():    TopicTopNQuery,
+	TopicStreamWrite.String():        TopicStreamWrite,
+	TopicStreamQuery.String():        TopicStreamQuery,
+	TopicStreamPurge.String():        TopicStreamPurge,
+	TopicStreamDelete.String():       TopicStreamDelete,
+	TopicStreamTopNSeries.String():   TopicStreamTopNSeries,
+	TopicStreamRebuildIndex.String(): TopicStreamRebuildIndex,
+	TopicStreamVerifyIndex.String():  TopicStreamVerifyIndex,
+	TopicStreamSearchSeries.String(): TopicStreamSearchSeries,
+	TopicStreamTagValues.String():    TopicStreamTagValues,
+	TopicStreamChanges.String():      TopicStreamChanges,
+	TopicMeasureWrite.String():       TopicMeasureWrite,
+	TopicMeasureQuery.String():       TopicMeasureQuery,
+	TopicTopNQuery.String():          TopicTopNQuery,
+	TopicMeasureChanges.String():     TopicMeasureChanges,
+	TopicHeartbeat.String():          TopicHeartbeat,
+	TopicNodeDrain.String():          TopicNodeDrain,
+	TopicSegmentDrop.String():        TopicSegmentDrop,
+	TopicSnapshot.String():           TopicSnapshot,
+	TopicPartShip.String():           TopicPartShip,
 }
 
 // TopicRequestMap is the map of topic name to request message.
@@ -43,6 +58,30 @@ var TopicRequestMap = map[bus.Topic]func() proto.Message{
 	TopicStreamQuery: func() proto.Message {
 		return &streamv1.QueryRequest{}
 	},
+	TopicStreamPurge: func() proto.Message {
+		return &streamv1.PurgeRequest{}
+	},
+	TopicStreamDelete: func() proto.Message {
+		return &streamv1.DeleteRequest{}
+	},
+	TopicStreamTopNSeries: func() proto.Message {
+		return &streamv1.TopNSeriesRequest{}
+	},
+	TopicStreamRebuildIndex: func() proto.Message {
+		return &streamv1.RebuildIndexRequest{}
+	},
+	TopicStreamVerifyIndex: func() proto.Message {
+		return &streamv1.VerifyIndexRequest{}
+	},
+	TopicStreamSearchSeries: func() proto.Message {
+		return &streamv1.SearchSeriesRequest{}
+	},
+	TopicStreamTagValues: func() proto.Message {
+		return &streamv1.TagValuesRequest{}
+	},
+	TopicStreamChanges: func() proto.Message {
+		return &streamv1.ChangesRequest{}
+	},
 	TopicMeasureWrite: func() proto.Message {
 		return &measurev1.InternalWriteRequest{}
 	},
@@ -52,6 +91,24 @@ var TopicRequestMap = map[bus.Topic]func() proto.Message{
 	TopicTopNQuery: func() proto.Message {
 		return &measurev1.TopNRequest{}
 	},
+	TopicMeasureChanges: func() proto.Message {
+		return &measurev1.ChangesRequest{}
+	},
+	TopicHeartbeat: func() proto.Message {
+		return &clusterv1.HeartbeatRequest{}
+	},
+	TopicNodeDrain: func() proto.Message {
+		return &clusterv1.DrainRequest{}
+	},
+	TopicSegmentDrop: func() proto.Message {
+		return &clusterv1.DropSegmentsRequest{}
+	},
+	TopicSnapshot: func() proto.Message {
+		return &clusterv1.SnapshotRequest{}
+	},
+	TopicPartShip: func() proto.Message {
+		return &clusterv1.PartShipRequest{}
+	},
 }
 
 // TopicResponseMap is the map of topic name to response message.
@@ -59,10 +116,52 @@ var TopicResponseMap = map[bus.Topic]func() proto.Message{
 	TopicStreamQuery: func() proto.Message {
 		return &streamv1.QueryResponse{}
 	},
+	TopicStreamPurge: func() proto.Message {
+		return &streamv1.PurgeResponse{}
+	},
+	TopicStreamDelete: func() proto.Message {
+		return &streamv1.DeleteResponse{}
+	},
+	TopicStreamTopNSeries: func() proto.Message {
+		return &streamv1.TopNSeriesResponse{}
+	},
+	TopicStreamRebuildIndex: func() proto.Message {
+		return &streamv1.RebuildIndexResponse{}
+	},
+	TopicStreamVerifyIndex: func() proto.Message {
+		return &streamv1.VerifyIndexResponse{}
+	},
+	TopicStreamSearchSeries: func() proto.Message {
+		return &streamv1.SearchSeriesResponse{}
+	},
+	TopicStreamTagValues: func() proto.Message {
+		return &streamv1.TagValuesResponse{}
+	},
+	TopicStreamChanges: func() proto.Message {
+		return &streamv1.ChangesResponse{}
+	},
 	TopicMeasureQuery: func() proto.Message {
 		return &measurev1.QueryResponse{}
 	},
 	TopicTopNQuery: func() proto.Message {
 		return &measurev1.TopNResponse{}
 	},
+	TopicMeasureChanges: func() proto.Message {
+		return &measurev1.ChangesResponse{}
+	},
+	TopicHeartbeat: func() proto.Message {
+		return &clusterv1.HeartbeatResponse{}
+	},
+	TopicNodeDrain: func() proto.Message {
+		return &clusterv1.DrainResponse{}
+	},
+	TopicSegmentDrop: func() proto.Message {
+		return &clusterv1.DropSegmentsResponse{}
+	},
+	TopicSnapshot: func() proto.Message {
+		return &clusterv1.SnapshotResponse{}
+	},
+	TopicPartShip: func() proto.Message {
+		return &clusterv1.PartShipResponse{}
+	},
 }
@@ -48,3 +48,12 @@ var TopNQueryKindVersion = common.KindVersion{
 
 // TopicTopNQuery is the top-n query topic.
 var TopicTopNQuery = bus.BiTopic(TopNQueryKindVersion.String())
+
+// MeasureChangesKindVersion is the version tag of measure changes kind.
+var MeasureChangesKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "measure-changes",
+}
+
+// TopicMeasureChanges is the measure changes topic.
+var TopicMeasureChanges = bus.BiTopic(MeasureChangesKindVersion.String())
@@ -39,3 +39,85 @@ var StreamQueryKindVersion = common.KindVersion{
 
 // TopicStreamQuery is the stream query topic.
 var TopicStreamQuery = bus.BiTopic(StreamQueryKindVersion.String())
+
+// StreamExportKindVersion is the version tag of stream export kind.
+var StreamExportKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "stream-export",
+}
+
+// TopicStreamExport is the stream export topic.
+// It's only published inside a liaison or a standalone server, whose response may refer to the spilled elements.
+var TopicStreamExport = bus.BiTopic(StreamExportKindVersion.String())
+
+// StreamPurgeKindVersion is the version tag of stream purge kind.
+var StreamPurgeKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "stream-purge",
+}
+
+// TopicStreamPurge is the stream purge topic.
+var TopicStreamPurge = bus.BiTopic(StreamPurgeKindVersion.String())
+
+// StreamDeleteKindVersion is the version tag of stream delete kind.
+var StreamDeleteKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "stream-delete",
+}
+
+// TopicStreamDelete is the stream delete topic.
+var TopicStreamDelete = bus.BiTopic(StreamDeleteKindVersion.String())
+
+// StreamTopNSeriesKindVersion is the version tag of stream top-n series kind.
+var StreamTopNSeriesKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "stream-topn-series",
+}
+
+// TopicStreamTopNSeries is the stream top-n series topic.
+var TopicStreamTopNSeries = bus.BiTopic(StreamTopNSeriesKindVersion.String())
+
+// StreamRebuildIndexKindVersion is the version tag of stream index rebuild kind.
+var StreamRebuildIndexKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "stream-rebuild-index",
+}
+
+// TopicStreamRebuildIndex is the stream index rebuild topic.
+var TopicStreamRebuildIndex = bus.BiTopic(StreamRebuildIndexKindVersion.String())
+
+// StreamVerifyIndexKindVersion is the version tag of stream index verification kind.
+var StreamVerifyIndexKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "stream-verify-index",
+}
+
+// TopicStreamVerifyIndex is the stream index verification topic.
+var TopicStreamVerifyIndex = bus.BiTopic(StreamVerifyIndexKindVersion.String())
+
+// StreamSearchSeriesKindVersion is the version tag of stream series search kind.
+var StreamSearchSeriesKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "stream-search-series",
+}
+
+// TopicStreamSearchSeries is the stream series search topic.
+var TopicStreamSearchSeries = bus.BiTopic(StreamSearchSeriesKindVersion.String())
+
+// StreamTagValuesKindVersion is the version tag of stream tag values kind.
+var StreamTagValuesKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "stream-tag-values",
+}
+
+// TopicStreamTagValues is the stream tag values topic.
+var TopicStreamTagValues = bus.BiTopic(StreamTagValuesKindVersion.String())
+
+// StreamChangesKindVersion is the version tag of stream changes kind.
+var StreamChangesKindVersion = common.KindVersion{
+	Version: "v1",
+	Kind:    "stream-changes",
+}
+
+// TopicStreamChanges is the stream changes topic.
+var TopicStreamChanges = bus.BiTopic(StreamChangesKindVersion.String())
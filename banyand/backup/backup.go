@@ -0,0 +1,178 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package backup uploads the data of a data node to an object storage incrementally.
+//
+// A round of backup takes a snapshot of the groups, and uploads the parts of the snapshot which
+// haven't been uploaded. The parts are immutable, so a part is uploaded only once along with a
+// manifest, and shared by all backups referring to it. The rest of the snapshot, such as the series
+// and the part lists, is uploaded per backup, which is completed once the manifest of the backup is
+// uploaded. The restore command pulls a backup back into the snapshot root of a data node, which is
+// imported by the snapshot API.
+package backup
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/banyand/snapshot"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+const (
+	moduleName = "backup"
+	// namePrefix tells the snapshots taken by the backups from the ones taken by the users.
+	namePrefix = "backup-"
+	nameLayout = "20060102T150405Z"
+	// scheduleOptions accepts the standard cron expressions and the descriptors.
+	scheduleOptions = cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor
+)
+
+var (
+	_ run.PreRunner = (*service)(nil)
+	_ run.Config    = (*service)(nil)
+	_ run.Service   = (*service)(nil)
+)
+
+// Service uploads the snapshots of a data node to an object storage on the schedule.
+type Service interface {
+	run.PreRunner
+	run.Config
+	run.Service
+}
+
+type service struct {
+	snapshots snapshot.Service
+	uploader  *uploader
+	l         *logger.Logger
+	scheduler *timestamp.Scheduler
+	ctx       context.Context
+	cancel    context.CancelFunc
+	stopCh    chan struct{}
+	schedule  string
+	groups    []string
+	cfg       storeConfig
+}
+
+// NewService returns a service backing up the snapshots taken by the snapshot service.
+func NewService(snapshots snapshot.Service) Service {
+	return &service{
+		snapshots: snapshots,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+func (s *service) Name() string {
+	return moduleName
+}
+
+func (s *service) FlagSet() *run.FlagSet {
+	flagS := run.NewFlagSet(moduleName)
+	s.cfg.addFlags(flagS.FlagSet)
+	flagS.StringVar(&s.schedule, "backup-schedule", "@every 1h", "the schedule of the backups in the cron expression, such as \"@daily\" or \"0 */6 * * *\"")
+	flagS.StringSliceVar(&s.groups, "backup-groups", nil, "the groups to back up, all groups if it's empty")
+	return flagS
+}
+
+func (s *service) Validate() error {
+	if s.cfg.Dest == "" {
+		return nil
+	}
+	if s.cfg.bandwidth < 0 {
+		return errors.New("backup-bandwidth must not be negative")
+	}
+	_, err := cron.NewParser(scheduleOptions).Parse(s.schedule)
+	return errors.WithMessage(err, "invalid backup-schedule")
+}
+
+func (s *service) PreRun(ctx context.Context) error {
+	s.l = logger.GetLogger(moduleName)
+	if s.cfg.Dest == "" {
+		return nil
+	}
+	val := ctx.Value(common.ContextNodeKey)
+	if val == nil {
+		return errors.New("node id is empty")
+	}
+	store, err := s.cfg.open()
+	if err != nil {
+		return err
+	}
+	s.uploader = &uploader{
+		store:   store,
+		limiter: s.cfg.limiter(),
+		node:    val.(common.Node).NodeID,
+	}
+	return nil
+}
+
+func (s *service) Serve() run.StopNotify {
+	if s.uploader == nil {
+		return s.stopCh
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	clock, _ := timestamp.GetClock(s.ctx)
+	s.scheduler = timestamp.NewScheduler(s.l, clock)
+	if err := s.scheduler.Register(moduleName, scheduleOptions, s.schedule, s.run); err != nil {
+		s.l.Fatal().Err(err).Msg("failed to schedule the backups")
+	}
+	s.l.Info().Str("dest", s.cfg.Dest).Str("schedule", s.schedule).Msg("backups are scheduled")
+	return s.stopCh
+}
+
+func (s *service) GracefulStop() {
+	if s.cancel != nil {
+		// abort the transfers of the running round
+		s.cancel()
+	}
+	if s.scheduler != nil {
+		s.scheduler.Close()
+	}
+	close(s.stopCh)
+}
+
+func (s *service) run(now time.Time, l *logger.Logger) bool {
+	name := namePrefix + now.UTC().Format(nameLayout)
+	start := time.Now()
+	stats, err := s.backup(name, now)
+	if err != nil {
+		l.Error().Err(err).Str("name", name).Msg("failed to back up")
+		return true
+	}
+	l.Info().Str("name", name).Int("parts", stats.parts).Int("uploaded_parts", stats.uploadedParts).
+		Int64("uploaded_bytes", stats.uploadedBytes).Dur("took", time.Since(start)).Msg("backed up")
+	return true
+}
+
+// backup uploads a snapshot, which is removed once it's uploaded.
+func (s *service) backup(name string, now time.Time) (uploadStats, error) {
+	if _, err := s.snapshots.Create(name, s.groups); err != nil {
+		return uploadStats{}, errors.WithMessage(err, "cannot take the snapshot")
+	}
+	defer func() {
+		if err := s.snapshots.Delete(name); err != nil {
+			s.l.Warn().Err(err).Str("name", name).Msg("failed to remove the snapshot")
+		}
+	}()
+	return s.uploader.upload(s.ctx, s.snapshots.Path(name), name, now)
+}
@@ -0,0 +1,137 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/pkg/fs/remote"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+)
+
+const testNode = "data-0:17912"
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o700))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+	}
+}
+
+func readFiles(t *testing.T, root string) map[string]string {
+	files := make(map[string]string)
+	require.NoError(t, filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		files[filepath.ToSlash(rel)] = string(content)
+		return err
+	}))
+	return files
+}
+
+func Test_uploader_incremental(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	ctx := context.Background()
+	remoteRoot := filepath.Join(tmpPath, "remote")
+	store := remote.NewFileStore(remoteRoot)
+	u := &uploader{store: store, node: testNode, limiter: (&storeConfig{bandwidth: 1 << 20}).limiter()}
+
+	seg := "stream/default/shard-0/seg-20240101"
+	first := map[string]string{
+		"snapshot.json":                          `{"name":"backup-1"}`,
+		"stream/default/series.json":             "series",
+		seg + "/metadata":                        "1.1.0",
+		seg + "/0000000000000001.snp":            `["0000000000000001"]`,
+		seg + "/0000000000000001/primary.bin":    "p1",
+		seg + "/0000000000000001/timestamps.bin": "t1",
+		seg + "/idx/00000001.seg":                "idx",
+	}
+	src1 := filepath.Join(tmpPath, "backup-1")
+	writeFiles(t, src1, first)
+	stats, err := u.upload(ctx, src1, "backup-1", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, uploadStats{parts: 1, uploadedParts: 1, uploadedBytes: 57}, stats)
+
+	// the part is merged with a new one
+	second := map[string]string{
+		"snapshot.json":                       `{"name":"backup-2"}`,
+		"stream/default/series.json":          "series",
+		seg + "/metadata":                     "1.1.0",
+		seg + "/0000000000000002.snp":         `["0000000000000001","0000000000000002"]`,
+		seg + "/0000000000000002/primary.bin": "p2",
+	}
+	for k, v := range first {
+		if filepath.Base(filepath.Dir(k)) == "0000000000000001" {
+			second[k] = v
+		}
+	}
+	src2 := filepath.Join(tmpPath, "backup-2")
+	writeFiles(t, src2, second)
+	// the uploaded parts are listed from the store by a new uploader
+	u = &uploader{store: store, node: testNode}
+	stats, err = u.upload(ctx, src2, "backup-2", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.parts)
+	assert.Equal(t, 1, stats.uploadedParts, "the first part isn't uploaded again")
+
+	r := &restorer{store: store, node: testNode}
+	name, err := r.latest(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "backup-2", name)
+	for n, want := range map[string]map[string]string{"backup-1": first, "backup-2": second} {
+		dst := filepath.Join(tmpPath, "restored", n)
+		m, errRestore := r.restore(ctx, n, dst)
+		require.NoError(t, errRestore)
+		assert.Equal(t, n, m.Name)
+		assert.Equal(t, want, readFiles(t, dst))
+	}
+	_, err = r.restore(ctx, "backup-2", filepath.Join(tmpPath, "restored", "backup-2"))
+	assert.Error(t, err, "the restored directory exists")
+
+	// a corrupted part fails the restore
+	require.NoError(t, os.WriteFile(filepath.Join(remoteRoot, testNode, partsDir, seg, "0000000000000002", "primary.bin"), []byte("p3"), 0o600))
+	dst := filepath.Join(tmpPath, "restored", "corrupted")
+	_, err = r.restore(ctx, "backup-2", dst)
+	assert.ErrorContains(t, err, "corrupted")
+	_, err = os.Stat(dst)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = (&restorer{store: store, node: "data-1:17912"}).latest(ctx)
+	assert.ErrorIs(t, err, errNoBackup)
+}
+
+func Test_isPart(t *testing.T) {
+	assert.True(t, isPart("stream/default/shard-0/seg-20240101/00000000000000a1"))
+	assert.False(t, isPart("stream/default/shard-0/seg-20240101/idx"))
+	assert.False(t, isPart("stream/default/shard-0/00000000000000a1"))
+}
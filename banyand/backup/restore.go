@@ -0,0 +1,193 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/time/rate"
+
+	"github.com/apache/skywalking-banyandb/pkg/fs/remote"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/version"
+)
+
+var errNoBackup = errors.New("no backup is found")
+
+// restorer downloads a backup as a snapshot, which is imported into a node by the snapshot API.
+type restorer struct {
+	store   remote.ObjectStore
+	limiter *rate.Limiter
+	node    string
+}
+
+// latest returns the name of the last completed backup.
+func (r *restorer) latest(ctx context.Context) (string, error) {
+	prefix := backupKey(r.node, "") + "/"
+	keys, err := r.store.List(ctx, prefix)
+	if err != nil {
+		return "", err
+	}
+	var name string
+	for _, k := range keys {
+		if n, ok := strings.CutSuffix(strings.TrimPrefix(k, prefix), "/"+manifestFilename); ok && n > name {
+			name = n
+		}
+	}
+	if name == "" {
+		return "", errors.WithMessagef(errNoBackup, "node %s", r.node)
+	}
+	return name, nil
+}
+
+// restore downloads the backup into the dir, which is created by renaming a temporary directory once all files are verified.
+func (r *restorer) restore(ctx context.Context, name, dir string) (*backupManifest, error) {
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.Errorf("the directory %s exists", dir)
+	}
+	m := &backupManifest{}
+	if err := r.getJSON(ctx, path.Join(backupKey(r.node, name), manifestFilename), m); err != nil {
+		return nil, errors.WithMessagef(err, "cannot read the backup %s", name)
+	}
+	tmp := dir + ".restoring"
+	if err := os.RemoveAll(tmp); err != nil {
+		return nil, err
+	}
+	err := r.download(ctx, m, tmp)
+	if err == nil {
+		err = os.Rename(tmp, dir)
+	}
+	if err != nil {
+		_ = os.RemoveAll(tmp)
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *restorer) download(ctx context.Context, m *backupManifest, dir string) error {
+	for _, f := range m.Files {
+		if err := r.downloadFile(ctx, path.Join(backupKey(r.node, m.Name), f.Name), filepath.Join(dir, filepath.FromSlash(f.Name)), f); err != nil {
+			return err
+		}
+	}
+	for _, rel := range m.Parts {
+		pm := &partManifest{}
+		if err := r.getJSON(ctx, path.Join(partsKey(r.node, rel), manifestFilename), pm); err != nil {
+			return errors.WithMessagef(err, "cannot read the part %s", rel)
+		}
+		for _, f := range pm.Files {
+			if err := r.downloadFile(ctx, path.Join(partsKey(r.node, rel), f.Name), filepath.Join(dir, filepath.FromSlash(rel), f.Name), f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *restorer) downloadFile(ctx context.Context, key, p string, entry fileEntry) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+	body, err := r.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), limitReader(ctx, body, r.limiter))
+	if err == nil {
+		err = f.Sync()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "cannot download %s", key)
+	}
+	if n != entry.Size || hex.EncodeToString(h.Sum(nil)) != entry.SHA256 {
+		return errors.Errorf("%s is corrupted, the size is %d, and the checksum is %x", key, n, h.Sum(nil))
+	}
+	return nil
+}
+
+func (r *restorer) getJSON(ctx context.Context, key string, v any) error {
+	body, err := r.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(limitReader(ctx, body, r.limiter)).Decode(v)
+}
+
+// NewRestoreCommand returns the command downloading a backup into the snapshot root of a data node.
+// The restored snapshot is registered by importing it with the snapshot API once the node starts.
+func NewRestoreCommand() *cobra.Command {
+	var cfg storeConfig
+	var node, name, root string
+	cmd := &cobra.Command{
+		Use:     "restore",
+		Version: version.Build(),
+		Short:   "Restore a backup into the snapshot root of a data node",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if node == "" {
+				return errors.New("the node of the backup is required")
+			}
+			store, err := cfg.open()
+			if err != nil {
+				return err
+			}
+			r := &restorer{store: store, node: node, limiter: cfg.limiter()}
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			if name == "" {
+				if name, err = r.latest(ctx); err != nil {
+					return err
+				}
+			}
+			dir := filepath.Join(root, name)
+			m, err := r.restore(ctx, name, dir)
+			if err != nil {
+				return err
+			}
+			logger.GetLogger("backup").Info().Str("name", name).Str("dir", dir).Int("parts", len(m.Parts)).
+				Msg("restored the backup, import the snapshot once the data node starts")
+			return nil
+		},
+	}
+	cfg.addFlags(cmd.Flags())
+	cmd.Flags().StringVar(&node, "node", "", "the id of the node which uploaded the backup")
+	cmd.Flags().StringVar(&name, "backup", "", "the name of the backup, the latest one is restored if it's empty")
+	cmd.Flags().StringVar(&root, "snapshot-root-path", "/tmp/snapshots", "the snapshot root of the data node")
+	return cmd
+}
@@ -0,0 +1,88 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package backup
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"golang.org/x/time/rate"
+
+	"github.com/apache/skywalking-banyandb/pkg/fs/remote"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+// maxBurst caps the bytes a rate-limited reader reads at a time.
+const maxBurst = 1 << 20
+
+var errEmptyDest = errors.New("the destination of the backups is empty")
+
+// storeConfig is shared by the backup service and the restore command.
+type storeConfig struct {
+	remote.Config
+	bandwidth run.Bytes
+}
+
+func (c *storeConfig) addFlags(flagS *pflag.FlagSet) {
+	c.AddFlags(flagS, "backup", "the backups")
+	flagS.VarP(&c.bandwidth, "backup-bandwidth", "", "the max bytes per second to upload or download, no limit if it's zero")
+}
+
+func (c *storeConfig) open() (remote.ObjectStore, error) {
+	if c.Dest == "" {
+		return nil, errEmptyDest
+	}
+	return c.Open()
+}
+
+// limiter returns nil if the bandwidth is unlimited.
+func (c *storeConfig) limiter() *rate.Limiter {
+	if c.bandwidth <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(c.bandwidth), int(min(int64(c.bandwidth), maxBurst)))
+}
+
+// limitReader slows down r to the rate of the limiter, which is shared by all transfers of a round.
+func limitReader(ctx context.Context, r io.Reader, limiter *rate.Limiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, limiter: limiter}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > lr.limiter.Burst() {
+		p = p[:lr.limiter.Burst()]
+	}
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		if errWait := lr.limiter.WaitN(lr.ctx, n); errWait != nil {
+			return n, errWait
+		}
+	}
+	return n, err
+}
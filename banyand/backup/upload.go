@@ -0,0 +1,215 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/apache/skywalking-banyandb/pkg/fs/remote"
+)
+
+const (
+	manifestFilename = "manifest.json"
+	partsDir         = "parts"
+	backupsDir       = "backups"
+)
+
+// partPattern matches the directories of the parts, which are named by their IDs in hex under the segments.
+var partPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// fileEntry is a file uploaded, which is verified by its size and checksum once it's downloaded.
+type fileEntry struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// partManifest is uploaded once all files of a part are uploaded, so a part without it is uploaded again.
+type partManifest struct {
+	CreatedAt time.Time   `json:"created_at"`
+	Files     []fileEntry `json:"files"`
+}
+
+// backupManifest lists the files of a snapshot and the parts it shares with the other backups.
+type backupManifest struct {
+	CreatedAt time.Time   `json:"created_at"`
+	Name      string      `json:"name"`
+	Node      string      `json:"node"`
+	Files     []fileEntry `json:"files"`
+	// Parts are the paths of the parts relative to the snapshot, which are uploaded under the parts of the node.
+	Parts []string `json:"parts"`
+}
+
+type uploadStats struct {
+	parts         int
+	uploadedParts int
+	uploadedBytes int64
+}
+
+// uploader uploads the snapshots of a node. The parts are immutable, so a part is uploaded only once
+// and shared by all backups referring to it.
+type uploader struct {
+	store   remote.ObjectStore
+	limiter *rate.Limiter
+	// uploaded are the parts with the manifests, which are listed from the store at the first round.
+	uploaded map[string]bool
+	node     string
+}
+
+func partsKey(node, rel string) string {
+	return path.Join(node, partsDir, rel)
+}
+
+func backupKey(node, name string) string {
+	return path.Join(node, backupsDir, name)
+}
+
+// upload uploads the snapshot in the dir, the backup is completed once its manifest is uploaded.
+func (u *uploader) upload(ctx context.Context, dir, name string, now time.Time) (uploadStats, error) {
+	var stats uploadStats
+	if u.uploaded == nil {
+		uploaded, err := u.listParts(ctx)
+		if err != nil {
+			return stats, err
+		}
+		u.uploaded = uploaded
+	}
+	m := &backupManifest{Name: name, Node: u.node, CreatedAt: now}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !d.IsDir() {
+			entry, errUpload := u.uploadFile(ctx, p, path.Join(backupKey(u.node, name), rel))
+			if errUpload != nil {
+				return errUpload
+			}
+			entry.Name = rel
+			m.Files = append(m.Files, entry)
+			stats.uploadedBytes += entry.Size
+			return nil
+		}
+		if !isPart(p) {
+			return nil
+		}
+		m.Parts = append(m.Parts, rel)
+		stats.parts++
+		if u.uploaded[rel] {
+			return filepath.SkipDir
+		}
+		size, errUpload := u.uploadPart(ctx, p, rel, now)
+		if errUpload != nil {
+			return errors.WithMessagef(errUpload, "cannot upload the part %s", rel)
+		}
+		u.uploaded[rel] = true
+		stats.uploadedParts++
+		stats.uploadedBytes += size
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return stats, err
+	}
+	return stats, u.putJSON(ctx, path.Join(backupKey(u.node, name), manifestFilename), m)
+}
+
+func (u *uploader) listParts(ctx context.Context) (map[string]bool, error) {
+	prefix := partsKey(u.node, "") + "/"
+	keys, err := u.store.List(ctx, prefix)
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot list the uploaded parts")
+	}
+	uploaded := make(map[string]bool)
+	for _, k := range keys {
+		if rel, ok := strings.CutSuffix(strings.TrimPrefix(k, prefix), "/"+manifestFilename); ok {
+			uploaded[rel] = true
+		}
+	}
+	return uploaded, nil
+}
+
+func (u *uploader) uploadPart(ctx context.Context, dir, rel string, now time.Time) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	m := &partManifest{CreatedAt: now}
+	var size int64
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		entry, errUpload := u.uploadFile(ctx, filepath.Join(dir, e.Name()), path.Join(partsKey(u.node, rel), e.Name()))
+		if errUpload != nil {
+			return 0, errUpload
+		}
+		entry.Name = e.Name()
+		m.Files = append(m.Files, entry)
+		size += entry.Size
+	}
+	return size, u.putJSON(ctx, path.Join(partsKey(u.node, rel), manifestFilename), m)
+}
+
+func (u *uploader) uploadFile(ctx context.Context, p, key string) (fileEntry, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return fileEntry{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fileEntry{}, err
+	}
+	h := sha256.New()
+	body := io.TeeReader(limitReader(ctx, f, u.limiter), h)
+	if err = u.store.Put(ctx, key, body, info.Size()); err != nil {
+		return fileEntry{}, errors.WithMessagef(err, "cannot upload %s", p)
+	}
+	return fileEntry{Size: info.Size(), SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func (u *uploader) putJSON(ctx context.Context, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return u.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)))
+}
+
+func isPart(dir string) bool {
+	return partPattern.MatchString(filepath.Base(dir)) && strings.HasPrefix(filepath.Base(filepath.Dir(dir)), "seg-")
+}
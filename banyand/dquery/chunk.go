@@ -0,0 +1,280 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dquery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/query/executor"
+)
+
+var errChunkUnsupported = errors.New("the publisher doesn't support chunked responses")
+
+// BroadcastChunked opens a chunk stream to every node that serves the query.
+// For a replicated group, a shard is retried on another replica if its chosen one fails
+// before sending the first chunk. Failures after that are reported by the streams.
+// As Broadcast does, each part of the time range is read from its tier.
+func (dc *distributedContext) BroadcastChunked(topic bus.Topic, message bus.Message) ([]executor.NodeStream, error) {
+	if dc.chunked == nil {
+		return nil, errChunkUnsupported
+	}
+	tr, err := dc.tiers()
+	if err != nil {
+		return nil, err
+	}
+	if tr == nil {
+		return dc.broadcastChunkedHot(topic, message, nil)
+	}
+	var streams []executor.NodeStream
+	if tr.hot != nil {
+		if streams, err = dc.broadcastChunkedHot(topic, withTimeRange(message, tr.hot), tr); err != nil {
+			return nil, err
+		}
+	}
+	for i, timeRange := range tr.cold {
+		coldMessage, index := withTimeRange(message, timeRange), uint32(i)
+		streams = append(streams, dc.broadcastChunkedHedged(func(ctx context.Context, n string, ids []uint32) (*timedStream, error) {
+			return dc.open(ctx, topic, coldMessage, n, ids, index)
+		}, closeStream, tr.coldNodes)...)
+	}
+	return streams, nil
+}
+
+func closeStream(s *timedStream) {
+	_ = s.Close()
+}
+
+func (dc *distributedContext) broadcastChunkedHot(topic bus.Topic, message bus.Message, tr *tierRoute) ([]executor.NodeStream, error) {
+	p, err := dc.router.plan(dc.metadata, dc.readOptions, dc.timeRange, dc.locality)
+	if err != nil {
+		return nil, err
+	}
+	call := func(ctx context.Context, n string, ids []uint32) (*timedStream, error) {
+		return dc.open(ctx, topic, message, n, ids, 0)
+	}
+	// the nodes of a paged query are resumed from their own positions
+	if p == nil && (tr != nil || dc.nodes != nil && (dc.hedger != nil || paged(message.Data()))) {
+		return dc.broadcastChunkedHedged(call, closeStream, dc.hotNodes(tr)), nil
+	}
+	if p == nil {
+		ss, errBroadcast := dc.chunked.BroadcastChunked(dc.queryContext(), topic, dc.chunkSize, message)
+		if errBroadcast != nil {
+			return nil, errBroadcast
+		}
+		streams := make([]executor.NodeStream, 0, len(ss))
+		for _, s := range ss {
+			streams = append(streams, &timedStream{ChunkStream: s, timeout: dc.timeout})
+		}
+		return streams, nil
+	}
+	var streams []executor.NodeStream
+	closeAll := func() {
+		for _, s := range streams {
+			_ = s.Close()
+		}
+	}
+	failed := make(map[string]struct{})
+	pending := p.byNode(p.shardIDs())
+	type openResult struct {
+		err      error
+		node     string
+		streams  []*timedStream
+		shardIDs []uint32
+	}
+	for len(pending) > 0 {
+		results := make(chan openResult, len(pending))
+		for n, ids := range pending {
+			go func(n string, ids []uint32) {
+				ss, errOpen := hedge(dc, p, n, ids, call, closeStream)
+				results <- openResult{node: n, shardIDs: ids, streams: ss, err: errOpen}
+			}(n, ids)
+		}
+		var failures []routeResult
+		for range pending {
+			r := <-results
+			if r.err == nil {
+				replicaQueryCounter.Inc(1, p.group, r.node)
+				for _, s := range r.streams {
+					streams = append(streams, s)
+				}
+				continue
+			}
+			replicaFallbackCounter.Inc(1, p.group, fallbackFailure)
+			failed[r.node] = struct{}{}
+			failures = append(failures, routeResult{node: r.node, shardIDs: r.shardIDs, err: r.err})
+			err = multierr.Append(err, r.err)
+		}
+		retry, errReassign := dc.reassign(p, failures, failed)
+		if errReassign != nil {
+			closeAll()
+			return nil, multierr.Append(err, errReassign)
+		}
+		pending = p.byNode(retry)
+	}
+	return streams, nil
+}
+
+// broadcastChunkedHedged opens the streams to the nodes one by one to hedge the slow ones.
+// A node which fails to open is reported by its stream as the broadcaster does.
+func (dc *distributedContext) broadcastChunkedHedged(call callFunc[*timedStream], discard func(*timedStream), nodes []string) []executor.NodeStream {
+	streams := make([]executor.NodeStream, len(nodes))
+	var wg sync.WaitGroup
+	wg.Add(len(nodes))
+	for i, n := range nodes {
+		go func(i int, n string) {
+			defer wg.Done()
+			ss, err := hedge(dc, nil, n, nil, call, discard)
+			if err != nil {
+				streams[i] = &failedStream{node: n, err: err}
+				return
+			}
+			streams[i] = ss[0]
+		}(i, n)
+	}
+	wg.Wait()
+	return streams
+}
+
+// open sends the query of some shards to a node and waits for the first chunk.
+// The index counts the previous streams to the node for the same shards, e.g. for the time ranges of the cold tiers.
+func (dc *distributedContext) open(ctx context.Context, topic bus.Topic, message bus.Message, n string, shardIDs []uint32, index uint32) (*timedStream, error) {
+	s, err := dc.chunked.PublishChunked(ctx, topic, dc.chunkSize,
+		bus.NewMessageWithNode(message.ID(), n, withShardIDs(withNodeCursor(message.Data(), n, shardIDs, index), shardIDs)))
+	if err != nil {
+		return nil, err
+	}
+	ts := &timedStream{ChunkStream: s, timeout: dc.timeout, shardIDs: shardIDs}
+	first, err := ts.Recv()
+	if err != nil {
+		_ = ts.Close()
+		return nil, err
+	}
+	ts.first = &first
+	dc.serve(n, shardIDs)
+	return ts, nil
+}
+
+// ReportNodeFailure records the failure to annotate the response if partial results are allowed.
+func (dc *distributedContext) ReportNodeFailure(node string, err error) bool {
+	return dc.reportFailure(node, nil, err)
+}
+
+// reportFailure records the failure with the shards missing from the results if partial results are allowed.
+// The shards are the ones the node serves if shardIDs is nil.
+func (dc *distributedContext) reportFailure(node string, shardIDs []uint32, err error) bool {
+	if !dc.readOptions.GetAllowPartialResults() {
+		return false
+	}
+	if shardIDs == nil {
+		dc.failuresMu.Lock()
+		shardIDs = dc.served[node]
+		dc.failuresMu.Unlock()
+	}
+	if shardIDs == nil {
+		shardIDs = dc.router.owned(dc.metadata, node)
+	}
+	missingShardsCounter.Inc(float64(len(shardIDs)), dc.metadata.GetGroup())
+	dc.failuresMu.Lock()
+	defer dc.failuresMu.Unlock()
+	dc.failures = append(dc.failures, &modelv1.NodeFailure{Node: node, Error: err.Error(), ShardIds: shardIDs})
+	return true
+}
+
+// serve records the shards a node serves, which its failure is annotated with.
+func (dc *distributedContext) serve(node string, shardIDs []uint32) {
+	if shardIDs == nil {
+		return
+	}
+	dc.failuresMu.Lock()
+	defer dc.failuresMu.Unlock()
+	if dc.served == nil {
+		dc.served = make(map[string][]uint32)
+	}
+	dc.served[node] = append(dc.served[node], shardIDs...)
+}
+
+func (dc *distributedContext) nodeFailures() []*modelv1.NodeFailure {
+	dc.failuresMu.Lock()
+	defer dc.failuresMu.Unlock()
+	return dc.failures
+}
+
+// timedStream fails a chunk that doesn't arrive in time.
+type timedStream struct {
+	queue.ChunkStream
+	first    *bus.Message
+	shardIDs []uint32
+	timeout  time.Duration
+}
+
+func (s *timedStream) ShardIDs() []uint32 {
+	return s.shardIDs
+}
+
+func (s *timedStream) Recv() (bus.Message, error) {
+	if s.first != nil {
+		m := *s.first
+		s.first = nil
+		return m, nil
+	}
+	done := make(chan routeResult, 1)
+	go func() {
+		m, err := s.ChunkStream.Recv()
+		done <- routeResult{message: m, err: err}
+	}()
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.message, r.err
+	case <-timer.C:
+		// closing the stream unblocks the pending Recv
+		_ = s.ChunkStream.Close()
+		return bus.Message{}, errors.Errorf("receiving a chunk from node %s timed out after %s", s.Node(), s.timeout)
+	}
+}
+
+// failedStream reports the failure of opening a stream to a node.
+type failedStream struct {
+	err  error
+	node string
+}
+
+func (s *failedStream) Node() string {
+	return s.node
+}
+
+func (s *failedStream) ShardIDs() []uint32 {
+	return nil
+}
+
+func (s *failedStream) Recv() (bus.Message, error) {
+	return bus.Message{}, s.err
+}
+
+func (s *failedStream) Close() error {
+	return nil
+}
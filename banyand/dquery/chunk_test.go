@@ -0,0 +1,83 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dquery
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+)
+
+type blockingStream struct {
+	closed chan struct{}
+}
+
+func (s *blockingStream) Node() string {
+	return "slow"
+}
+
+func (s *blockingStream) Recv() (bus.Message, error) {
+	<-s.closed
+	return bus.Message{}, io.EOF
+}
+
+func (s *blockingStream) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func TestTimedStream(t *testing.T) {
+	s := &blockingStream{closed: make(chan struct{})}
+	ts := &timedStream{ChunkStream: s, timeout: 50 * time.Millisecond}
+	_, err := ts.Recv()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "node slow timed out")
+	select {
+	case <-s.closed:
+	default:
+		t.Fatal("the stream should be closed after timing out")
+	}
+}
+
+func TestReportNodeFailure(t *testing.T) {
+	dc := &distributedContext{}
+	assert.False(t, dc.ReportNodeFailure("data-node-0", io.ErrUnexpectedEOF))
+	assert.Empty(t, dc.nodeFailures())
+
+	dc = &distributedContext{readOptions: &modelv1.ReadOptions{AllowPartialResults: true}}
+	assert.True(t, dc.ReportNodeFailure("data-node-0", io.ErrUnexpectedEOF))
+	assert.Equal(t, []*modelv1.NodeFailure{{Node: "data-node-0", Error: io.ErrUnexpectedEOF.Error()}}, dc.nodeFailures())
+}
+
+func TestReportNodeFailureShards(t *testing.T) {
+	dc := &distributedContext{readOptions: &modelv1.ReadOptions{AllowPartialResults: true}}
+	dc.serve("data-node-0", []uint32{1})
+	dc.serve("data-node-0", []uint32{3})
+	assert.True(t, dc.ReportNodeFailure("data-node-0", io.ErrUnexpectedEOF))
+	assert.True(t, dc.reportFailure("data-node-1", []uint32{2}, io.ErrUnexpectedEOF))
+	assert.Equal(t, []*modelv1.NodeFailure{
+		{Node: "data-node-0", Error: io.ErrUnexpectedEOF.Error(), ShardIds: []uint32{1, 3}},
+		{Node: "data-node-1", Error: io.ErrUnexpectedEOF.Error(), ShardIds: []uint32{2}},
+	}, dc.nodeFailures())
+}
@@ -20,10 +20,18 @@ package dquery
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/multierr"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/apache/skywalking-banyandb/api/data"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
 	"github.com/apache/skywalking-banyandb/banyand/measure"
 	"github.com/apache/skywalking-banyandb/banyand/metadata"
@@ -31,7 +39,9 @@ import (
 	"github.com/apache/skywalking-banyandb/banyand/stream"
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/node"
 	"github.com/apache/skywalking-banyandb/pkg/query/executor"
+	"github.com/apache/skywalking-banyandb/pkg/query/spill"
 	"github.com/apache/skywalking-banyandb/pkg/run"
 )
 
@@ -39,30 +49,75 @@ const (
 	moduleName = "distributed-query"
 )
 
-var _ run.Service = (*queryService)(nil)
+var readPreferences = map[string]modelv1.ReadPreference{
+	"primary":     modelv1.ReadPreference_READ_PREFERENCE_PRIMARY,
+	"any-replica": modelv1.ReadPreference_READ_PREFERENCE_ANY_REPLICA,
+	"nearest":     modelv1.ReadPreference_READ_PREFERENCE_NEAREST,
+}
+
+var (
+	_ run.Service = (*queryService)(nil)
+	_ run.Config  = (*queryService)(nil)
+)
 
 type queryService struct {
-	log         *logger.Logger
-	metaService metadata.Repo
-	sqp         *streamQueryProcessor
-	mqp         *measureQueryProcessor
-	tqp         *topNQueryProcessor
-	closer      *run.Closer
-	pipeline    queue.Server
+	log            *logger.Logger
+	metaService    metadata.Repo
+	sqp            *streamQueryProcessor
+	sep            *streamExportProcessor
+	mqp            *measureQueryProcessor
+	tqp            *topNQueryProcessor
+	closer         *run.Closer
+	pipeline       queue.Server
+	broadcaster    queue.Client
+	chunked        queue.ChunkedPublisher
+	nodes          queue.NodePublisher
+	router         *router
+	hedger         *hedger
+	replicaTimeout time.Duration
+	maxStaleness   time.Duration
+	hedgeMinDelay  time.Duration
+	hedgeMaxQuery  int
+	hedgeMaxNode   int
+	chunkSize      uint32
+	spillRoot      string
+	readPreference string
+	spillThreshold run.Bytes
+	maxGroups      int
 }
 
 // NewService return a new query service.
-func NewService(metaService metadata.Repo, pipeline queue.Server, broadcaster bus.Broadcaster,
+// The placement routes the queries of the old data to the cold tier, it's nil if there is no tier.
+// The replicas the lag reports missing writes are read only if the others fail, it's nil if they aren't tracked.
+func NewService(metaService metadata.Repo, pipeline queue.Server, broadcaster queue.Client, selector node.Selector,
+	placement *node.Placement, lag queue.ReplicaLag,
 ) (run.Unit, error) {
 	svc := &queryService{
 		metaService: metaService,
 		closer:      run.NewCloser(1),
 		pipeline:    pipeline,
+		broadcaster: broadcaster,
+		router: &router{
+			metaService: metaService,
+			selector:    selector,
+			lag:         lag,
+			placement:   placement,
+		},
+	}
+	if status, ok := broadcaster.(queue.NodeStatus); ok {
+		svc.router.status = status
+	}
+	if chunked, ok := broadcaster.(queue.ChunkedPublisher); ok {
+		svc.chunked = chunked
+	}
+	if nodes, ok := broadcaster.(queue.NodePublisher); ok {
+		svc.nodes = nodes
 	}
 	svc.sqp = &streamQueryProcessor{
 		queryService: svc,
 		broadcaster:  broadcaster,
 	}
+	svc.sep = &streamExportProcessor{streamQueryProcessor: svc.sqp}
 	svc.mqp = &measureQueryProcessor{
 		queryService: svc,
 		broadcaster:  broadcaster,
@@ -78,12 +133,108 @@ func (q *queryService) Name() string {
 	return moduleName
 }
 
+func (q *queryService) FlagSet() *run.FlagSet {
+	fs := run.NewFlagSet(moduleName)
+	fs.DurationVar(&q.replicaTimeout, "dquery-replica-timeout", 10*time.Second,
+		"the timeout of querying a replica before retrying on another one")
+	fs.StringVar(&q.readPreference, "dquery-read-preference", "primary",
+		"the read preference of the queries not setting one: primary, any-replica or nearest")
+	fs.DurationVar(&q.maxStaleness, "dquery-max-staleness", 0,
+		"the max staleness of the secondary replicas read by the queries not setting one, 0 means the freshness isn't checked")
+	fs.Uint32Var(&q.chunkSize, "dquery-chunk-size", 1000,
+		"the max number of elements in a chunk of a data node's response")
+	fs.DurationVar(&q.hedgeMinDelay, "dquery-hedge-min-delay", 10*time.Millisecond,
+		"the min delay before sending a query again to hedge a slow data node")
+	fs.IntVar(&q.hedgeMaxQuery, "dquery-hedge-max-per-query", 2,
+		"the max number of hedged requests of a query, 0 disables hedging")
+	fs.IntVar(&q.hedgeMaxNode, "dquery-hedge-max-per-node", 8,
+		"the max number of outstanding hedged requests to a data node")
+	fs.StringVar(&q.spillRoot, "dquery-spill-root", "",
+		"the directory to spill the elements of the exported queries and the groups of the measure queries, spilling is disabled if it's empty")
+	q.spillThreshold = 64 << 20
+	fs.VarP(&q.spillThreshold, "dquery-spill-threshold", "",
+		"the max bytes of the elements an exported query holds in memory before spilling them")
+	fs.IntVar(&q.maxGroups, "dquery-max-groups", 100000,
+		"the max groups a measure query aggregates in memory before spilling the data points of the others")
+	return fs
+}
+
+func (q *queryService) Validate() error {
+	if q.replicaTimeout <= 0 {
+		return errors.New("dquery-replica-timeout must be positive")
+	}
+	if _, ok := readPreferences[q.readPreference]; !ok {
+		return fmt.Errorf("dquery-read-preference %q is invalid, it should be primary, any-replica or nearest", q.readPreference)
+	}
+	if q.maxStaleness < 0 {
+		return errors.New("dquery-max-staleness must not be negative")
+	}
+	if q.chunkSize == 0 {
+		return errors.New("dquery-chunk-size must be positive")
+	}
+	if q.hedgeMaxQuery < 0 || q.hedgeMaxNode < 0 {
+		return errors.New("dquery-hedge-max-per-query and dquery-hedge-max-per-node must not be negative")
+	}
+	if q.spillRoot != "" && q.spillThreshold <= 0 {
+		return errors.New("dquery-spill-threshold must be positive")
+	}
+	if q.spillRoot != "" && q.maxGroups <= 0 {
+		return errors.New("dquery-max-groups must be positive")
+	}
+	q.hedger = newHedger(q.hedgeMinDelay, q.hedgeMaxQuery, q.hedgeMaxNode)
+	return nil
+}
+
+func (q *queryService) newDistributedContext(ctx context.Context, metadata *commonv1.Metadata, readOptions *modelv1.ReadOptions,
+	timeRange *modelv1.TimeRange,
+) *distributedContext {
+	return &distributedContext{
+		ctx:         ctx,
+		Broadcaster: q.broadcaster,
+		publisher:   q.broadcaster,
+		chunked:     q.chunked,
+		nodes:       q.nodes,
+		hedger:      q.hedger,
+		router:      q.router,
+		metadata:    metadata,
+		readOptions: q.withDefaults(readOptions),
+		timeRange:   timeRange,
+		timeout:     q.replicaTimeout,
+		chunkSize:   q.chunkSize,
+	}
+}
+
+// withDefaults fills the read preference and the staleness bound the query doesn't set.
+func (q *queryService) withDefaults(readOptions *modelv1.ReadOptions) *modelv1.ReadOptions {
+	preference := readPreferences[q.readPreference]
+	if readOptions.GetPreference() != modelv1.ReadPreference_READ_PREFERENCE_UNSPECIFIED ||
+		preference == modelv1.ReadPreference_READ_PREFERENCE_PRIMARY {
+		return readOptions
+	}
+	result := &modelv1.ReadOptions{Preference: preference}
+	if readOptions != nil {
+		result = proto.Clone(readOptions).(*modelv1.ReadOptions)
+		result.Preference = preference
+	}
+	if result.MaxStaleness == nil && q.maxStaleness > 0 {
+		result.MaxStaleness = durationpb.New(q.maxStaleness)
+	}
+	return result
+}
+
 func (q *queryService) PreRun(_ context.Context) error {
 	q.log = logger.GetLogger(moduleName)
 	q.sqp.streamService = stream.NewPortableRepository(q.metaService, q.log)
 	q.mqp.measureService = measure.NewPortableRepository(q.metaService, q.log)
+	if q.spillRoot != "" {
+		// the spill files of the previous process are useless since their queries are gone
+		if err := spill.Clean(q.spillRoot); err != nil {
+			return err
+		}
+	}
 	return multierr.Combine(
 		q.pipeline.Subscribe(data.TopicStreamQuery, q.sqp),
+		q.pipeline.Subscribe(data.TopicStreamExport, q.sep),
 		q.pipeline.Subscribe(data.TopicMeasureQuery, q.mqp),
 		q.pipeline.Subscribe(data.TopicTopNQuery, q.tqp),
 	)
@@ -104,7 +255,30 @@ var _ executor.DistributedExecutionContext = (*distributedContext)(nil)
 
 type distributedContext struct {
 	bus.Broadcaster
-	timeRange *modelv1.TimeRange
+	// ctx is canceled once the client gives up the query, which aborts the calls to the data nodes.
+	ctx         context.Context
+	publisher   bus.Publisher
+	chunked     queue.ChunkedPublisher
+	nodes       queue.NodePublisher
+	router      *router
+	hedger      *hedger
+	metadata    *commonv1.Metadata
+	readOptions *modelv1.ReadOptions
+	timeRange   *modelv1.TimeRange
+	locality    locality
+	failures    []*modelv1.NodeFailure
+	served      map[string][]uint32
+	timeout     time.Duration
+	failuresMu  sync.Mutex
+	hedges      atomic.Int32
+	chunkSize   uint32
+}
+
+func (dc *distributedContext) queryContext() context.Context {
+	if dc.ctx == nil {
+		return context.Background()
+	}
+	return dc.ctx
 }
 
 func (dc *distributedContext) TimeRange() *modelv1.TimeRange {
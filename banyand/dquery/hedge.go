@@ -0,0 +1,291 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dquery
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+)
+
+const (
+	latencyGain   = 0.125
+	deviationGain = 0.25
+	// minLatencySamples is the number of responses from a node before its latency is trusted.
+	minLatencySamples = 10
+)
+
+var (
+	hedgeProvider   = observability.NewMeterProvider(observability.RootScope.SubScope("dquery").SubScope("hedge"))
+	hedgeCounter    = hedgeProvider.Counter("total", "node")
+	hedgeWonCounter = hedgeProvider.Counter("won_total", "node")
+	hedgeDelayGauge = hedgeProvider.Gauge("delay_seconds", "node")
+)
+
+// latency is the moving average and mean deviation of a node's response time.
+type latency struct {
+	mean      float64
+	deviation float64
+	samples   int
+}
+
+func (l *latency) observe(d time.Duration) {
+	v := d.Seconds()
+	l.samples++
+	if l.samples == 1 {
+		l.mean, l.deviation = v, v/2
+		return
+	}
+	l.deviation += deviationGain * (math.Abs(v-l.mean) - l.deviation)
+	l.mean += latencyGain * (v - l.mean)
+}
+
+// p95 approximates the 95th percentile by the mean plus twice the mean deviation.
+func (l *latency) p95() time.Duration {
+	return time.Duration((l.mean + 2*l.deviation) * float64(time.Second))
+}
+
+// hedger decides when a query to a slow node is sent again, and bounds the outstanding hedges.
+type hedger struct {
+	latencies   map[string]*latency
+	outstanding map[string]int
+	minDelay    time.Duration
+	maxPerQuery int
+	maxPerNode  int
+	mu          sync.Mutex
+}
+
+func newHedger(minDelay time.Duration, maxPerQuery, maxPerNode int) *hedger {
+	if maxPerQuery < 1 || maxPerNode < 1 {
+		return nil
+	}
+	return &hedger{
+		latencies:   make(map[string]*latency),
+		outstanding: make(map[string]int),
+		minDelay:    minDelay,
+		maxPerQuery: maxPerQuery,
+		maxPerNode:  maxPerNode,
+	}
+}
+
+func (h *hedger) observe(node string, d time.Duration) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	l, ok := h.latencies[node]
+	if !ok {
+		l = &latency{}
+		h.latencies[node] = l
+	}
+	l.observe(d)
+	delay := l.p95()
+	h.mu.Unlock()
+	hedgeDelayGauge.Set(delay.Seconds(), node)
+}
+
+// delay returns how long to wait for a node before hedging.
+// It's false if the node hasn't responded enough times to know its latency.
+func (h *hedger) delay(node string) (time.Duration, bool) {
+	if h == nil {
+		return 0, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l, ok := h.latencies[node]
+	if !ok || l.samples < minLatencySamples {
+		return 0, false
+	}
+	if d := l.p95(); d > h.minDelay {
+		return d, true
+	}
+	return h.minDelay, true
+}
+
+// acquire reserves a hedge on every target node, or none if any of them has too many outstanding hedges.
+func (h *hedger) acquire(nodes []string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, n := range nodes {
+		if h.outstanding[n] >= h.maxPerNode {
+			return false
+		}
+	}
+	for _, n := range nodes {
+		h.outstanding[n]++
+	}
+	return true
+}
+
+func (h *hedger) release(nodes []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, n := range nodes {
+		if h.outstanding[n]--; h.outstanding[n] <= 0 {
+			delete(h.outstanding, n)
+		}
+	}
+}
+
+// callFunc sends the query of some shards to a node. Canceling ctx aborts the call.
+type callFunc[T any] func(ctx context.Context, n string, shardIDs []uint32) (T, error)
+
+type attempt[T any] struct {
+	err     error
+	results []T
+}
+
+// hedge calls a node, and sends the query again if the node doesn't respond within its usual latency.
+// The hedge goes to another replica of every shard, or to the same node if a shard has no other replica.
+// The first complete attempt wins, the other one is canceled and its results are passed to discard.
+func hedge[T any](dc *distributedContext, p *routePlan, n string, shardIDs []uint32, call callFunc[T], discard func(T)) ([]T, error) {
+	h := dc.hedger
+	timed := func(ctx context.Context, n string, shardIDs []uint32) (T, error) {
+		defer dc.router.track(n)()
+		start := time.Now()
+		r, err := call(ctx, n, shardIDs)
+		if err == nil {
+			h.observe(n, time.Since(start))
+		}
+		return r, err
+	}
+	ctx, cancel := abortable(dc.queryContext())
+	primary := make(chan attempt[T], 1)
+	go func() {
+		r, err := timed(ctx, n, shardIDs)
+		primary <- attempt[T]{results: []T{r}, err: err}
+	}()
+	settle := func(a attempt[T]) ([]T, error) {
+		if a.err != nil {
+			cancel()
+			return nil, a.err
+		}
+		return a.results, nil
+	}
+	delay, ok := h.delay(n)
+	if !ok {
+		return settle(<-primary)
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case a := <-primary:
+		return settle(a)
+	case <-timer.C:
+	}
+	targets := hedgeTargets(p, n, shardIDs)
+	nodes := make([]string, 0, len(targets))
+	for t := range targets {
+		nodes = append(nodes, t)
+	}
+	if dc.hedges.Add(1) > int32(h.maxPerQuery) || !h.acquire(nodes) {
+		return settle(<-primary)
+	}
+	hedgeCounter.Inc(1, n)
+	hedgeCtx, hedgeCancel := abortable(dc.queryContext())
+	hedged := make(chan attempt[T], 1)
+	go func() {
+		defer h.release(nodes)
+		results := make(chan attempt[T], len(targets))
+		for t, ids := range targets {
+			go func(t string, ids []uint32) {
+				r, err := timed(hedgeCtx, t, ids)
+				results <- attempt[T]{results: []T{r}, err: err}
+			}(t, ids)
+		}
+		var all attempt[T]
+		for range targets {
+			r := <-results
+			if r.err != nil {
+				all.err = multierr.Append(all.err, r.err)
+				continue
+			}
+			all.results = append(all.results, r.results...)
+		}
+		if all.err != nil {
+			for _, r := range all.results {
+				discard(r)
+			}
+			all.results = nil
+		}
+		hedged <- all
+	}()
+	abandon := func(cancel context.CancelFunc, loser <-chan attempt[T]) {
+		cancel()
+		go func() {
+			for _, r := range (<-loser).results {
+				discard(r)
+			}
+		}()
+	}
+	var err error
+	for pending := 2; pending > 0; pending-- {
+		select {
+		case a := <-primary:
+			if a.err == nil {
+				abandon(hedgeCancel, hedged)
+				return a.results, nil
+			}
+			err = multierr.Append(err, a.err)
+			primary = nil
+		case a := <-hedged:
+			if a.err == nil {
+				hedgeWonCounter.Inc(1, n)
+				abandon(cancel, primary)
+				return a.results, nil
+			}
+			err = multierr.Append(err, a.err)
+			hedged = nil
+		}
+	}
+	cancel()
+	hedgeCancel()
+	return nil, err
+}
+
+// abortable returns the context of an attempt, which is canceled only if the attempt loses.
+// The winner's context is left alone since its results, like chunk streams, are bound to it.
+// Both are canceled along with the query.
+func abortable(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithCancel(parent)
+}
+
+// hedgeTargets groups the shards by the next replica to query.
+func hedgeTargets(p *routePlan, n string, shardIDs []uint32) map[string][]uint32 {
+	targets := make(map[string][]uint32)
+	if p == nil || shardIDs == nil {
+		targets[n] = shardIDs
+		return targets
+	}
+	for _, id := range shardIDs {
+		t := n
+		for _, c := range p.candidates[id] {
+			if c != n {
+				t = c
+				break
+			}
+		}
+		targets[t] = append(targets[t], id)
+	}
+	return targets
+}
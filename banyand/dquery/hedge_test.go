@@ -0,0 +1,130 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dquery
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func warmUp(h *hedger, node string, d time.Duration) {
+	for i := 0; i < minLatencySamples; i++ {
+		h.observe(node, d)
+	}
+}
+
+func TestHedgerDelay(t *testing.T) {
+	h := newHedger(time.Millisecond, 1, 1)
+	_, ok := h.delay("data-node-0")
+	assert.False(t, ok)
+	h.observe("data-node-0", 20*time.Millisecond)
+	_, ok = h.delay("data-node-0")
+	assert.False(t, ok, "a few samples aren't trusted")
+	warmUp(h, "data-node-0", 20*time.Millisecond)
+	d, ok := h.delay("data-node-0")
+	require.True(t, ok)
+	assert.GreaterOrEqual(t, d, 20*time.Millisecond)
+	assert.Less(t, d, 40*time.Millisecond)
+
+	warmUp(h, "data-node-1", time.Microsecond)
+	d, ok = h.delay("data-node-1")
+	require.True(t, ok)
+	assert.Equal(t, time.Millisecond, d, "the delay is bounded by the min delay")
+
+	assert.Nil(t, newHedger(time.Millisecond, 0, 1), "hedging is disabled")
+}
+
+// slowFirst blocks the first call until it's canceled, and answers the others at once.
+func slowFirst(calls *atomic.Int32, canceled chan<- struct{}) callFunc[string] {
+	return func(ctx context.Context, n string, _ []uint32) (string, error) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			close(canceled)
+			return "", ctx.Err()
+		}
+		return n, nil
+	}
+}
+
+func TestHedgeWins(t *testing.T) {
+	h := newHedger(time.Millisecond, 1, 1)
+	warmUp(h, "data-node-0", time.Millisecond)
+	dc := &distributedContext{hedger: h}
+	var calls atomic.Int32
+	canceled := make(chan struct{})
+	results, err := hedge(dc, nil, "data-node-0", nil, slowFirst(&calls, canceled), func(string) {})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data-node-0"}, results)
+	assert.Equal(t, int32(2), calls.Load())
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the slow call isn't canceled")
+	}
+	assert.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.outstanding["data-node-0"] == 0
+	}, 5*time.Second, 10*time.Millisecond, "the hedge is released")
+}
+
+func TestHedgeCapped(t *testing.T) {
+	h := newHedger(time.Millisecond, 1, 1)
+	warmUp(h, "data-node-0", time.Millisecond)
+	require.True(t, h.acquire([]string{"data-node-0"}))
+	dc := &distributedContext{hedger: h}
+	slow := func(_ context.Context, n string, _ []uint32) (string, error) {
+		time.Sleep(20 * time.Millisecond)
+		return n, nil
+	}
+	var calls atomic.Int32
+	counted := func(ctx context.Context, n string, ids []uint32) (string, error) {
+		calls.Add(1)
+		return slow(ctx, n, ids)
+	}
+	results, err := hedge(dc, nil, "data-node-0", nil, counted, func(string) {})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data-node-0"}, results)
+	assert.Equal(t, int32(1), calls.Load(), "the node has too many outstanding hedges")
+
+	h.release([]string{"data-node-0"})
+	dc.hedges.Store(int32(h.maxPerQuery))
+	calls.Store(0)
+	_, err = hedge(dc, nil, "data-node-0", nil, counted, func(string) {})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), calls.Load(), "the query has used up its hedges")
+}
+
+func TestHedgeTargets(t *testing.T) {
+	p := &routePlan{candidates: [][]string{
+		{"data-node-0", "data-node-1"},
+		{"data-node-0", "data-node-2"},
+		{"data-node-0"},
+	}}
+	assert.Equal(t, map[string][]uint32{
+		"data-node-1": {0},
+		"data-node-2": {1},
+		"data-node-0": {2},
+	}, hedgeTargets(p, "data-node-0", []uint32{0, 1, 2}))
+	assert.Equal(t, map[string][]uint32{"data-node-0": nil}, hedgeTargets(nil, "data-node-0", nil))
+}
@@ -18,7 +18,6 @@
 package dquery
 
 import (
-	"context"
 	"time"
 
 	"github.com/apache/skywalking-banyandb/api/common"
@@ -54,6 +53,19 @@ func (p *measureQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to get execution context for measure %s: %v", meta.GetName(), err))
 		return
 	}
+	rollupCriteria, err := logical_measure.RollupRequest(ec.GetSchema(), queryCriteria)
+	if err != nil {
+		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to pick the rollup for measure %s: %v", meta.GetName(), err))
+		return
+	}
+	if rollupCriteria != queryCriteria {
+		queryCriteria, meta = rollupCriteria, rollupCriteria.GetMetadata()
+		if ec, err = p.measureService.Measure(meta); err != nil {
+			resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to get execution context for the rollup %s of measure %s: %v",
+				meta.GetGroup(), meta.GetName(), err))
+			return
+		}
+	}
 
 	s, err := logical_measure.BuildSchema(ec.GetSchema(), ec.GetIndexRules())
 	if err != nil {
@@ -71,10 +83,15 @@ func (p *measureQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 		e.Str("plan", plan.String()).Msg("query plan")
 	}
 
-	mIterator, err := plan.(executor.MeasureExecutable).Execute(executor.WithDistributedExecutionContext(context.Background(), &distributedContext{
-		Broadcaster: p.broadcaster,
-		timeRange:   queryCriteria.TimeRange,
-	}))
+	dc := p.newDistributedContext(message.Context(), queryCriteria.GetMetadata(), queryCriteria.GetReadOptions(), queryCriteria.GetTimeRange())
+	dc.locality = locality{entity: ec.GetSchema().GetEntity().GetTagNames(), criteria: queryCriteria.GetCriteria()}
+	var span *executor.Span
+	if queryCriteria.GetTrace() {
+		span = executor.NewSpan("distributed measure query")
+	}
+	ctx := executor.WithSpan(executor.WithGroupSpill(executor.WithDistributedExecutionContext(dc.queryContext(), dc),
+		executor.GroupSpill{Dir: p.spillRoot, MaxGroups: p.maxGroups}), span)
+	mIterator, err := plan.(executor.MeasureExecutable).Execute(ctx)
 	if err != nil {
 		ml.Error().Err(err).RawJSON("req", logger.Proto(queryCriteria)).Msg("fail to close the query plan")
 		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to execute the query plan for measure %s: %v", meta.GetName(), err))
@@ -88,13 +105,16 @@ func (p *measureQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 	result := make([]*measurev1.DataPoint, 0)
 	for mIterator.Next() {
 		current := mIterator.Current()
-		if len(current) > 0 {
-			result = append(result, current[0])
+		if len(current) < 1 {
+			continue
 		}
+		result = append(result, current[0])
 	}
 	if e := ml.Debug(); e.Enabled() {
 		e.RawJSON("ret", logger.Proto(&measurev1.QueryResponse{DataPoints: result})).Msg("got a measure")
 	}
-	resp = bus.NewMessage(bus.MessageID(now), &measurev1.QueryResponse{DataPoints: result})
+	span.End()
+	resp = bus.NewMessage(bus.MessageID(now), &measurev1.QueryResponse{DataPoints: result, Trace: span.Proto(), NodeFailures: dc.nodeFailures(),
+		ContinuationToken: logical_measure.ContinuationToken(plan, result)})
 	return
 }
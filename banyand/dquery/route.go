@@ -0,0 +1,552 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dquery
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/node"
+	"github.com/apache/skywalking-banyandb/pkg/partition"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+const (
+	fallbackStale   = "stale"
+	fallbackMissing = "missing"
+	fallbackFailure = "failure"
+)
+
+var (
+	errNoReplica = errors.New("no replica is available")
+
+	replicaProvider        = observability.NewMeterProvider(observability.RootScope.SubScope("dquery").SubScope("replica"))
+	replicaQueryCounter    = replicaProvider.Counter("query_total", "group", "node")
+	replicaFallbackCounter = replicaProvider.Counter("fallback_total", "group", "reason")
+
+	routeProvider        = observability.NewMeterProvider(observability.RootScope.SubScope("dquery").SubScope("route"))
+	shardPinnedCounter   = routeProvider.Counter("pinned_total", "group")
+	missingShardsCounter = routeProvider.Counter("missing_shards_total", "group")
+)
+
+// router assigns every shard of a replicated group to exactly one replica for a query.
+type router struct {
+	metaService metadata.Repo
+	selector    node.Selector
+	status      queue.NodeStatus
+	lag         queue.ReplicaLag
+	placement   *node.Placement
+	// inflight counts the outstanding requests of every node, which are *atomic.Int64
+	inflight sync.Map
+	seq      atomic.Uint64
+}
+
+// routePlan records the candidate replicas of every target shard and the chosen one.
+type routePlan struct {
+	group      string
+	candidates [][]string
+	chosen     []string
+	targets    []uint32
+}
+
+// locality is what a query tells about the shards it hits.
+type locality struct {
+	criteria *modelv1.Criteria
+	entity   []string
+}
+
+// plan returns nil if the group isn't replicated and the criteria don't pin a shard,
+// and the query should be broadcast to all nodes.
+func (r *router) plan(metadata *commonv1.Metadata, opts *modelv1.ReadOptions, timeRange *modelv1.TimeRange, l locality) (*routePlan, error) {
+	if r == nil || r.selector == nil {
+		return nil, nil
+	}
+	g, err := r.metaService.GroupRegistry().GetGroup(context.Background(), metadata.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	replicas := g.GetResourceOpts().GetReplicas()
+	shardNum := g.GetResourceOpts().GetShardNum()
+	targets, err := pinnedShards(metadata, g.GetResourceOpts(), l)
+	if err != nil {
+		return nil, err
+	}
+	if replicas < 2 && targets == nil {
+		return nil, nil
+	}
+	if targets == nil {
+		targets = make([]uint32, shardNum)
+		for i := range targets {
+			targets[i] = uint32(i)
+		}
+	}
+	p := &routePlan{
+		group:      metadata.GetGroup(),
+		candidates: make([][]string, shardNum),
+		chosen:     make([]string, shardNum),
+		targets:    targets,
+	}
+	seq := r.seq.Add(1)
+	// planned counts the shards of the query assigned to every node, which spreads them with the outstanding requests
+	planned := make(map[string]int)
+	for _, i := range targets {
+		nodes, errPick := node.PickReplicas(r.selector, metadata.GetGroup(), metadata.GetName(), i, int(replicas))
+		if errPick != nil {
+			return nil, errPick
+		}
+		p.candidates[i] = r.order(p.group, i, nodes, opts, timeRange, seq, planned)
+		p.chosen[i] = p.candidates[i][0]
+		planned[p.chosen[i]]++
+	}
+	return p, nil
+}
+
+// order sorts the replicas of a shard by the read preference.
+// The primary is the only candidate of a primary read, whose failure fails the shard instead of falling over to the secondaries.
+// Secondary replicas beyond the staleness bound are dropped.
+// The replicas missing writes in the time range are moved to the end, which are only read if the others fail.
+func (r *router) order(group string, shardID uint32, nodes []string, opts *modelv1.ReadOptions, timeRange *modelv1.TimeRange,
+	seq uint64, planned map[string]int,
+) []string {
+	primary := nodes[0]
+	preference := opts.GetPreference()
+	// the unspecified preference is left by withDefaults only if the default one is primary
+	if preference == modelv1.ReadPreference_READ_PREFERENCE_UNSPECIFIED ||
+		preference == modelv1.ReadPreference_READ_PREFERENCE_PRIMARY {
+		return []string{primary}
+	}
+	end := timeRange.GetEnd().AsTime()
+	fresh := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		if n == primary || r.fresh(n, group, shardID, opts, end) {
+			fresh = append(fresh, n)
+		}
+	}
+	if len(fresh) < len(nodes) {
+		replicaFallbackCounter.Inc(1, group, fallbackStale)
+	}
+	switch preference {
+	case modelv1.ReadPreference_READ_PREFERENCE_ANY_REPLICA:
+		// rotate the candidates to spread queries across replicas, then prefer the least loaded ones
+		offset := int((seq + uint64(shardID)) % uint64(len(fresh)))
+		rotated := make([]string, 0, len(fresh))
+		fresh = append(append(rotated, fresh[offset:]...), fresh[:offset]...)
+		sort.SliceStable(fresh, func(i, j int) bool {
+			return r.load(fresh[i], planned) < r.load(fresh[j], planned)
+		})
+	case modelv1.ReadPreference_READ_PREFERENCE_NEAREST:
+		sort.SliceStable(fresh, func(i, j int) bool {
+			return r.latency(fresh[i]) < r.latency(fresh[j])
+		})
+	}
+	if r.lag == nil {
+		return fresh
+	}
+	begin := timeRange.GetBegin().AsTime()
+	complete := make([]string, 0, len(fresh))
+	var missing []string
+	for _, n := range fresh {
+		if r.lag.Missing(n, group, shardID, begin, end) {
+			missing = append(missing, n)
+			continue
+		}
+		complete = append(complete, n)
+	}
+	if len(missing) > 0 && len(complete) > 0 {
+		replicaFallbackCounter.Inc(1, group, fallbackMissing)
+	}
+	return append(complete, missing...)
+}
+
+// load returns the outstanding requests of a node and the shards of the query planned to it.
+func (r *router) load(n string, planned map[string]int) int64 {
+	return r.counter(n).Load() + int64(planned[n])
+}
+
+// track counts an outstanding request of a node until the returned function is called.
+func (r *router) track(n string) func() {
+	if r == nil {
+		return func() {}
+	}
+	c := r.counter(n)
+	c.Add(1)
+	return func() {
+		c.Add(-1)
+	}
+}
+
+func (r *router) counter(n string) *atomic.Int64 {
+	c, _ := r.inflight.LoadOrStore(n, new(atomic.Int64))
+	return c.(*atomic.Int64)
+}
+
+func (r *router) fresh(n, group string, shardID uint32, opts *modelv1.ReadOptions, end time.Time) bool {
+	maxStaleness := opts.GetMaxStaleness().AsDuration()
+	if maxStaleness <= 0 {
+		return true
+	}
+	if r.status == nil {
+		return false
+	}
+	applied, ok := r.status.Watermark(n, group, shardID)
+	return ok && !applied.Before(end.Add(-maxStaleness))
+}
+
+func (r *router) latency(n string) time.Duration {
+	if r.status != nil {
+		if l, ok := r.status.Latency(n); ok {
+			return l
+		}
+	}
+	return time.Duration(1<<63 - 1)
+}
+
+// owned returns the shards of the resource which the selector places on the node.
+func (r *router) owned(metadata *commonv1.Metadata, n string) []uint32 {
+	if r == nil || r.selector == nil {
+		return nil
+	}
+	g, err := r.metaService.GroupRegistry().GetGroup(context.Background(), metadata.GetGroup())
+	if err != nil {
+		return nil
+	}
+	var shardIDs []uint32
+	for i := uint32(0); i < g.GetResourceOpts().GetShardNum(); i++ {
+		if owner, errPick := r.selector.Pick(metadata.GetGroup(), metadata.GetName(), i); errPick == nil && owner == n {
+			shardIDs = append(shardIDs, i)
+		}
+	}
+	return shardIDs
+}
+
+// byNode groups the given shards by their chosen replicas.
+func (p *routePlan) byNode(shardIDs []uint32) map[string][]uint32 {
+	result := make(map[string][]uint32)
+	for _, id := range shardIDs {
+		result[p.chosen[id]] = append(result[p.chosen[id]], id)
+	}
+	return result
+}
+
+// reassign moves a shard to the next candidate which isn't failed.
+func (p *routePlan) reassign(shardID uint32, failed map[string]struct{}) error {
+	for _, n := range p.candidates[shardID] {
+		if _, ok := failed[n]; !ok {
+			p.chosen[shardID] = n
+			return nil
+		}
+	}
+	return errors.WithMessagef(errNoReplica, "shard %d of group %s", shardID, p.group)
+}
+
+func (p *routePlan) shardIDs() []uint32 {
+	return p.targets
+}
+
+// pinnedShards returns the only shard hit by the query if its criteria pin all the routing tags.
+// It returns nil if the query could hit any shard.
+func pinnedShards(metadata *commonv1.Metadata, opts *commonv1.ResourceOpts, l locality) ([]uint32, error) {
+	sharding, err := partition.NewSharding(opts.GetSharding())
+	if err != nil {
+		return nil, err
+	}
+	id, ok := partition.PinnedShard(metadata.GetName(), l.entity, l.criteria, sharding, opts.GetShardNum())
+	if !ok {
+		return nil, nil
+	}
+	shardPinnedCounter.Inc(1, metadata.GetGroup())
+	return []uint32{uint32(id)}, nil
+}
+
+type routeResult struct {
+	err      error
+	node     string
+	message  bus.Message
+	messages []bus.Message
+	shardIDs []uint32
+}
+
+// Broadcast sends the query to all nodes if the group isn't replicated and no shard is pinned.
+// Otherwise, it reads every target shard from only one of its replicas,
+// and retries the shards of a failed replica on other replicas.
+// If the group has segments on the cold tier, the time range is split to read each part from its tier.
+func (dc *distributedContext) Broadcast(topic bus.Topic, message bus.Message) ([]bus.Future, error) {
+	tr, err := dc.tiers()
+	if err != nil {
+		return nil, err
+	}
+	if tr == nil {
+		return dc.broadcastHot(topic, message, nil)
+	}
+	var futures []bus.Future
+	if tr.hot != nil {
+		if futures, err = dc.broadcastHot(topic, withTimeRange(message, tr.hot), tr); err != nil {
+			return nil, err
+		}
+	}
+	for _, timeRange := range tr.cold {
+		coldMessage := withTimeRange(message, timeRange)
+		futures = append(futures, dc.broadcastHedged(func(ctx context.Context, n string, ids []uint32) (bus.Message, error) {
+			return dc.query(ctx, topic, coldMessage, n, ids)
+		}, tr.coldNodes)...)
+	}
+	return futures, nil
+}
+
+func (dc *distributedContext) broadcastHot(topic bus.Topic, message bus.Message, tr *tierRoute) ([]bus.Future, error) {
+	p, err := dc.router.plan(dc.metadata, dc.readOptions, dc.timeRange, dc.locality)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil && tr == nil && (dc.hedger == nil || dc.nodes == nil) {
+		return dc.Broadcaster.Broadcast(topic, message)
+	}
+	call := func(ctx context.Context, n string, ids []uint32) (bus.Message, error) {
+		return dc.query(ctx, topic, message, n, ids)
+	}
+	if p == nil {
+		return dc.broadcastHedged(call, dc.hotNodes(tr)), nil
+	}
+	var futures []bus.Future
+	failed := make(map[string]struct{})
+	pending := p.byNode(p.shardIDs())
+	for len(pending) > 0 {
+		results := make(chan routeResult, len(pending))
+		for n, ids := range pending {
+			go func(n string, ids []uint32) {
+				mm, errQuery := hedge(dc, p, n, ids, call, func(bus.Message) {})
+				results <- routeResult{node: n, shardIDs: ids, messages: mm, err: errQuery}
+			}(n, ids)
+		}
+		var failures []routeResult
+		for range pending {
+			r := <-results
+			if r.err == nil {
+				replicaQueryCounter.Inc(1, p.group, r.node)
+				for _, m := range r.messages {
+					futures = append(futures, &resolvedFuture{message: m})
+				}
+				continue
+			}
+			replicaFallbackCounter.Inc(1, p.group, fallbackFailure)
+			failed[r.node] = struct{}{}
+			failures = append(failures, r)
+			err = multierr.Append(err, r.err)
+		}
+		retry, errReassign := dc.reassign(p, failures, failed)
+		if errReassign != nil {
+			return nil, multierr.Append(err, errReassign)
+		}
+		pending = p.byNode(retry)
+	}
+	return futures, nil
+}
+
+// reassign moves the shards of the failed nodes to their next replicas, and returns the shards to retry.
+// A shard whose replicas all fail is reported as missing if partial results are allowed, otherwise an error is returned.
+func (dc *distributedContext) reassign(p *routePlan, failures []routeResult, failed map[string]struct{}) ([]uint32, error) {
+	var retry []uint32
+	for _, f := range failures {
+		var missing []uint32
+		for _, id := range f.shardIDs {
+			err := p.reassign(id, failed)
+			if err == nil {
+				retry = append(retry, id)
+				continue
+			}
+			if !dc.readOptions.GetAllowPartialResults() {
+				return nil, err
+			}
+			missing = append(missing, id)
+		}
+		if len(missing) > 0 {
+			dc.reportFailure(f.node, missing, f.err)
+		}
+	}
+	return retry, nil
+}
+
+// broadcastHedged queries the nodes one by one to hedge the slow ones.
+// A failed node's error is returned by its future as the broadcaster does,
+// or it's left out if partial results are allowed.
+func (dc *distributedContext) broadcastHedged(call callFunc[bus.Message], nodes []string) []bus.Future {
+	futures := make([]bus.Future, len(nodes))
+	var wg sync.WaitGroup
+	wg.Add(len(nodes))
+	for i, n := range nodes {
+		go func(i int, n string) {
+			defer wg.Done()
+			mm, err := hedge(dc, nil, n, nil, call, func(bus.Message) {})
+			if err != nil {
+				if !dc.reportFailure(n, nil, err) {
+					futures[i] = &failedFuture{err: err}
+				}
+				return
+			}
+			futures[i] = &resolvedFuture{message: mm[0]}
+		}(i, n)
+	}
+	wg.Wait()
+	result := futures[:0]
+	for _, f := range futures {
+		if f != nil {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// query sends the message to the node and waits for its reply until the timeout.
+// A timed-out or hedged call is canceled, which aborts the stream and the reading the local queue does for it.
+// The reply carrying common.Error is a failure, so that the shards are retried on other replicas.
+func (dc *distributedContext) query(ctx context.Context, topic bus.Topic, message bus.Message, n string, shardIDs []uint32) (bus.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, dc.timeout)
+	defer cancel()
+	m := bus.NewMessageWithNode(message.ID(), n, withShardIDs(message.Data(), shardIDs)).WithContext(ctx)
+	var f bus.Future
+	var err error
+	if dc.nodes != nil {
+		f, err = dc.nodes.PublishContext(ctx, topic, m)
+	} else {
+		f, err = dc.publisher.Publish(topic, m)
+	}
+	if err != nil {
+		return bus.Message{}, err
+	}
+	done := make(chan routeResult, 1)
+	go func() {
+		m, errGet := f.Get()
+		done <- routeResult{message: m, err: errGet}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return bus.Message{}, r.err
+		}
+		if e, ok := r.message.Data().(common.Error); ok {
+			return bus.Message{}, errors.Errorf("node %s failed to query: %s", n, e.Msg())
+		}
+		return r.message, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return bus.Message{}, errors.Errorf("querying node %s timed out after %s", n, dc.timeout)
+		}
+		return bus.Message{}, ctx.Err()
+	}
+}
+
+func withShardIDs(data any, shardIDs []uint32) any {
+	if shardIDs == nil {
+		return data
+	}
+	switch d := data.(type) {
+	case *streamv1.QueryRequest:
+		q := proto.Clone(d).(*streamv1.QueryRequest)
+		q.ShardIds = shardIDs
+		return q
+	case *measurev1.QueryRequest:
+		q := proto.Clone(d).(*measurev1.QueryRequest)
+		q.ShardIds = shardIDs
+		return q
+	}
+	return data
+}
+
+// withNodeCursor replaces the continuation token of a distributed query with the node's own one,
+// which resumes the node after the rows of it returned by the previous pages.
+func withNodeCursor(data any, node string, shardIDs []uint32, index uint32) any {
+	switch d := data.(type) {
+	case *streamv1.QueryRequest:
+		if len(d.ContinuationToken) == 0 {
+			return data
+		}
+		q := proto.Clone(d).(*streamv1.QueryRequest)
+		q.ContinuationToken, q.Offset = nodeCursor(d.ContinuationToken, node, shardIDs, index)
+		return q
+	case *measurev1.QueryRequest:
+		if len(d.ContinuationToken) == 0 {
+			return data
+		}
+		q := proto.Clone(d).(*measurev1.QueryRequest)
+		q.ContinuationToken, q.Offset = nodeCursor(d.ContinuationToken, node, shardIDs, index)
+		return q
+	}
+	return data
+}
+
+// nodeCursor returns the token and the offset of the node, which starts from the beginning without a position.
+func nodeCursor(token []byte, node string, shardIDs []uint32, index uint32) ([]byte, uint32) {
+	// the token is validated by the analyzer
+	cc, err := pbv1.ParseNodeCursors(token)
+	if err != nil {
+		return nil, 0
+	}
+	c, _ := cc.Find(node, shardIDs, index)
+	return c.Token, c.Skip
+}
+
+// paged reports whether the query resumes a previous page.
+func paged(data any) bool {
+	switch d := data.(type) {
+	case *streamv1.QueryRequest:
+		return len(d.GetContinuationToken()) > 0
+	case *measurev1.QueryRequest:
+		return len(d.GetContinuationToken()) > 0
+	}
+	return false
+}
+
+type resolvedFuture struct {
+	message bus.Message
+}
+
+func (f *resolvedFuture) Get() (bus.Message, error) {
+	return f.message, nil
+}
+
+func (f *resolvedFuture) GetAll() ([]bus.Message, error) {
+	return []bus.Message{f.message}, nil
+}
+
+type failedFuture struct {
+	err error
+}
+
+func (f *failedFuture) Get() (bus.Message, error) {
+	return bus.Message{}, f.err
+}
+
+func (f *failedFuture) GetAll() ([]bus.Message, error) {
+	return nil, f.err
+}
@@ -0,0 +1,260 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dquery
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/api/data"
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+type fakeStatus struct {
+	watermarks map[string]time.Time
+	latencies  map[string]time.Duration
+}
+
+func (f *fakeStatus) Watermark(node, _ string, _ uint32) (time.Time, bool) {
+	ts, ok := f.watermarks[node]
+	return ts, ok
+}
+
+func (f *fakeStatus) Latency(node string) (time.Duration, bool) {
+	l, ok := f.latencies[node]
+	return l, ok
+}
+
+func (f *fakeStatus) Pressure(_ string) (*clusterv1.WritePressure, bool) {
+	return nil, false
+}
+
+func (f *fakeStatus) GroupSize(_ string) uint64 {
+	return 0
+}
+
+type fakeLag struct {
+	missing map[string]bool
+}
+
+func (f *fakeLag) Missing(node, _ string, _ uint32, _, _ time.Time) bool {
+	return f.missing[node]
+}
+
+func TestRouterOrder(t *testing.T) {
+	end := time.Now()
+	r := &router{status: &fakeStatus{
+		watermarks: map[string]time.Time{
+			"data-node-1": end.Add(-time.Second),
+			"data-node-2": end.Add(-time.Hour),
+		},
+		latencies: map[string]time.Duration{
+			"data-node-0": 5 * time.Millisecond,
+			"data-node-1": time.Millisecond,
+			"data-node-2": time.Microsecond,
+		},
+	}}
+	nodes := []string{"data-node-0", "data-node-1", "data-node-2"}
+	bounded := func(preference modelv1.ReadPreference) *modelv1.ReadOptions {
+		return &modelv1.ReadOptions{Preference: preference, MaxStaleness: durationpb.New(time.Minute)}
+	}
+	tests := []struct {
+		opts    *modelv1.ReadOptions
+		planned map[string]int
+		name    string
+		want    []string
+		seq     uint64
+	}{
+		{
+			name: "default",
+			want: []string{"data-node-0"},
+		},
+		{
+			name: "primary doesn't fall over to the secondaries",
+			opts: bounded(modelv1.ReadPreference_READ_PREFERENCE_PRIMARY),
+			want: []string{"data-node-0"},
+		},
+		{
+			name: "nearest skips the stale replica",
+			opts: bounded(modelv1.ReadPreference_READ_PREFERENCE_NEAREST),
+			want: []string{"data-node-1", "data-node-0"},
+		},
+		{
+			name: "nearest without a staleness bound",
+			opts: &modelv1.ReadOptions{Preference: modelv1.ReadPreference_READ_PREFERENCE_NEAREST},
+			want: []string{"data-node-2", "data-node-1", "data-node-0"},
+		},
+		{
+			name: "any replica rotates",
+			opts: bounded(modelv1.ReadPreference_READ_PREFERENCE_ANY_REPLICA),
+			seq:  1,
+			want: []string{"data-node-1", "data-node-0"},
+		},
+		{
+			name:    "any replica prefers the less loaded one",
+			opts:    bounded(modelv1.ReadPreference_READ_PREFERENCE_ANY_REPLICA),
+			planned: map[string]int{"data-node-0": 2, "data-node-1": 1},
+			want:    []string{"data-node-1", "data-node-0"},
+		},
+	}
+	timeRange := &modelv1.TimeRange{Begin: timestamppb.New(end.Add(-time.Hour)), End: timestamppb.New(end)}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, r.order("sw_record", 0, nodes, tt.opts, timeRange, tt.seq, tt.planned))
+		})
+	}
+}
+
+func TestRouterOrderMissingWrites(t *testing.T) {
+	nodes := []string{"data-node-0", "data-node-1", "data-node-2"}
+	r := &router{lag: &fakeLag{missing: map[string]bool{"data-node-0": true}}}
+	end := time.Now()
+	timeRange := &modelv1.TimeRange{Begin: timestamppb.New(end.Add(-time.Hour)), End: timestamppb.New(end)}
+	// the primary is read even if it misses writes
+	assert.Equal(t, []string{"data-node-0"}, r.order("sw_record", 0, nodes, nil, timeRange, 0, nil))
+	assert.Equal(t, []string{"data-node-1", "data-node-2", "data-node-0"},
+		r.order("sw_record", 0, nodes, &modelv1.ReadOptions{Preference: modelv1.ReadPreference_READ_PREFERENCE_NEAREST}, timeRange, 0, nil))
+
+	done := r.track("data-node-1")
+	assert.Equal(t, []string{"data-node-2", "data-node-1", "data-node-0"},
+		r.order("sw_record", 0, nodes, &modelv1.ReadOptions{Preference: modelv1.ReadPreference_READ_PREFERENCE_ANY_REPLICA}, timeRange, 1, nil))
+	done()
+	assert.Equal(t, []string{"data-node-1", "data-node-2", "data-node-0"},
+		r.order("sw_record", 0, nodes, &modelv1.ReadOptions{Preference: modelv1.ReadPreference_READ_PREFERENCE_ANY_REPLICA}, timeRange, 1, nil))
+}
+
+func TestReassignPartialResults(t *testing.T) {
+	newPlan := func() *routePlan {
+		return &routePlan{
+			group:      "sw_record",
+			candidates: [][]string{{"data-node-0", "data-node-1"}, {"data-node-0"}},
+			chosen:     []string{"data-node-0", "data-node-0"},
+			targets:    []uint32{0, 1},
+		}
+	}
+	failed := map[string]struct{}{"data-node-0": {}}
+	failures := []routeResult{{node: "data-node-0", shardIDs: []uint32{0, 1}, err: io.ErrUnexpectedEOF}}
+
+	dc := &distributedContext{}
+	_, err := dc.reassign(newPlan(), failures, failed)
+	assert.ErrorIs(t, err, errNoReplica)
+
+	dc = &distributedContext{readOptions: &modelv1.ReadOptions{AllowPartialResults: true}}
+	p := newPlan()
+	retry, err := dc.reassign(p, failures, failed)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{0}, retry)
+	assert.Equal(t, "data-node-1", p.chosen[0])
+	assert.Equal(t, []*modelv1.NodeFailure{{Node: "data-node-0", Error: io.ErrUnexpectedEOF.Error(), ShardIds: []uint32{1}}}, dc.nodeFailures())
+}
+
+// replyingNodes replies the queries sent to the nodes in replies, and blocks the others until they're canceled.
+type replyingNodes struct {
+	replies  map[string]any
+	canceled chan struct{}
+}
+
+func (r *replyingNodes) Nodes() []string {
+	return nil
+}
+
+func (r *replyingNodes) PublishContext(ctx context.Context, _ bus.Topic, messages ...bus.Message) (bus.Future, error) {
+	m := messages[0]
+	if reply, ok := r.replies[m.Node()]; ok {
+		return &resolvedFuture{message: bus.NewMessage(m.ID(), reply)}, nil
+	}
+	return &blockingFuture{ctx: ctx, canceled: r.canceled}, nil
+}
+
+type blockingFuture struct {
+	ctx      context.Context
+	canceled chan struct{}
+}
+
+func (f *blockingFuture) Get() (bus.Message, error) {
+	<-f.ctx.Done()
+	close(f.canceled)
+	return bus.Message{}, f.ctx.Err()
+}
+
+func (f *blockingFuture) GetAll() ([]bus.Message, error) {
+	m, err := f.Get()
+	return []bus.Message{m}, err
+}
+
+func TestQueryReplies(t *testing.T) {
+	nodes := &replyingNodes{
+		replies: map[string]any{
+			"data-node-0": &streamv1.QueryResponse{},
+			"data-node-1": common.NewError("stream %s is not found", "sw"),
+		},
+		canceled: make(chan struct{}),
+	}
+	dc := &distributedContext{nodes: nodes, timeout: 50 * time.Millisecond}
+	message := bus.NewMessage(1, &streamv1.QueryRequest{})
+
+	m, err := dc.query(context.Background(), data.TopicStreamQuery, message, "data-node-0", []uint32{0})
+	require.NoError(t, err)
+	assert.IsType(t, &streamv1.QueryResponse{}, m.Data())
+
+	_, err = dc.query(context.Background(), data.TopicStreamQuery, message, "data-node-1", []uint32{0})
+	assert.ErrorContains(t, err, "stream sw is not found", "the error replied is a failure")
+
+	_, err = dc.query(context.Background(), data.TopicStreamQuery, message, "data-node-2", []uint32{0})
+	assert.ErrorContains(t, err, "timed out")
+	select {
+	case <-nodes.canceled:
+	case <-time.After(time.Second):
+		assert.Fail(t, "the timed-out call isn't canceled")
+	}
+}
+
+func TestWithNodeCursor(t *testing.T) {
+	token := pbv1.NodeCursors{
+		{Node: "data-node-0", ShardIDs: []uint32{1}, Token: []byte("first"), Skip: 2},
+		{Node: "data-node-0", ShardIDs: []uint32{1}, Index: 1, Token: []byte("second")},
+	}.MarshalToken()
+	q := &streamv1.QueryRequest{ContinuationToken: token, Offset: 5}
+
+	got := withNodeCursor(q, "data-node-0", []uint32{1}, 0).(*streamv1.QueryRequest)
+	assert.Equal(t, []byte("first"), got.ContinuationToken)
+	assert.Equal(t, uint32(2), got.Offset)
+	got = withNodeCursor(q, "data-node-0", []uint32{1}, 1).(*streamv1.QueryRequest)
+	assert.Equal(t, []byte("second"), got.ContinuationToken)
+	assert.Equal(t, uint32(0), got.Offset)
+	// the node queried for other shards starts from the beginning
+	got = withNodeCursor(q, "data-node-0", []uint32{2}, 0).(*streamv1.QueryRequest)
+	assert.Nil(t, got.ContinuationToken)
+	assert.Equal(t, uint32(0), got.Offset)
+	assert.Equal(t, token, q.ContinuationToken, "the query of the liaison is kept")
+
+	first := &streamv1.QueryRequest{Offset: 5}
+	assert.Same(t, first, withNodeCursor(first, "data-node-0", nil, 0))
+}
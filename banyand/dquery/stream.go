@@ -18,16 +18,23 @@
 package dquery
 
 import (
-	"context"
 	"time"
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
 	"github.com/apache/skywalking-banyandb/banyand/stream"
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/query/executor"
 	logical_stream "github.com/apache/skywalking-banyandb/pkg/query/logical/stream"
+	"github.com/apache/skywalking-banyandb/pkg/query/spill"
+)
+
+var (
+	spillProvider     = observability.NewMeterProvider(observability.RootScope.SubScope("dquery").SubScope("spill"))
+	spillQueryCounter = spillProvider.Counter("query_total", "group")
+	spillBytesCounter = spillProvider.Counter("bytes_total", "group")
 )
 
 type streamQueryProcessor struct {
@@ -37,6 +44,20 @@ type streamQueryProcessor struct {
 }
 
 func (p *streamQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
+	return p.rev(message, false)
+}
+
+// streamExportProcessor executes a query as streamQueryProcessor does,
+// but it accumulates the elements in spill.Elements which spill to disk once they exceed the threshold.
+type streamExportProcessor struct {
+	*streamQueryProcessor
+}
+
+func (p *streamExportProcessor) Rev(message bus.Message) (resp bus.Message) {
+	return p.rev(message, true)
+}
+
+func (p *streamQueryProcessor) rev(message bus.Message, export bool) (resp bus.Message) {
 	now := time.Now().UnixNano()
 	queryCriteria, ok := message.Data().(*streamv1.QueryRequest)
 	if !ok {
@@ -68,17 +89,71 @@ func (p *streamQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 	if p.log.Debug().Enabled() {
 		p.log.Debug().Str("plan", plan.String()).Msg("query plan")
 	}
-	entities, err := plan.(executor.StreamExecutable).Execute(executor.WithDistributedExecutionContext(context.Background(), &distributedContext{
-		Broadcaster: p.broadcaster,
-		timeRange:   queryCriteria.TimeRange,
-	}))
+	if queryCriteria.GetExplain() {
+		// the access paths are chosen by the data nodes, the plan shows the distributed one only
+		resp = bus.NewMessage(bus.MessageID(now), &streamv1.QueryResponse{Plan: plan.String()})
+		return
+	}
+	dc := p.newDistributedContext(message.Context(), queryCriteria.GetMetadata(), queryCriteria.GetReadOptions(), queryCriteria.GetTimeRange())
+	dc.locality = locality{entity: ec.GetSchema().GetEntity().GetTagNames(), criteria: queryCriteria.GetCriteria()}
+	warnings := &executor.Warnings{}
+	var span *executor.Span
+	if queryCriteria.GetTrace() {
+		span = executor.NewSpan("distributed stream query")
+	}
+	ctx := executor.WithSpan(executor.WithWarnings(executor.WithDistributedExecutionContext(dc.queryContext(), dc), warnings), span)
+	var batches *executor.ColumnBatches
+	if queryCriteria.GetColumnar() {
+		// the column batches are returned as they are, they never spill
+		batches = &executor.ColumnBatches{}
+		ctx = executor.WithColumnBatches(ctx, batches)
+		export = false
+	}
+	var elements *spill.Elements
+	if export {
+		elements = spill.NewElements(p.spillRoot, int(p.spillThreshold))
+		ctx = executor.WithStreamElementSink(ctx, &offsetSink{sink: elements, offset: queryCriteria.GetOffset()})
+	}
+	entities, err := plan.(executor.StreamExecutable).Execute(ctx)
+	span.End()
+	if elements != nil && elements.SpilledBytes() > 0 {
+		spillQueryCounter.Inc(1, meta.GetGroup())
+		spillBytesCounter.Inc(float64(elements.SpilledBytes()), meta.GetGroup())
+	}
 	if err != nil {
+		if elements != nil {
+			_ = elements.Close()
+		}
 		p.log.Error().Err(err).RawJSON("req", logger.Proto(queryCriteria)).Msg("fail to execute the query plan")
 		resp = bus.NewMessage(bus.MessageID(now), common.NewError("execute the query plan for stream %s: %v", meta.GetName(), err))
 		return
 	}
 
-	resp = bus.NewMessage(bus.MessageID(now), &streamv1.QueryResponse{Elements: entities})
+	if export {
+		resp = bus.NewMessage(bus.MessageID(now), &spill.Export{Elements: elements, NodeFailures: dc.nodeFailures(), Warnings: warnings.List()})
+		return
+	}
+	if batches != nil {
+		resp = bus.NewMessage(bus.MessageID(now), &streamv1.QueryResponse{Batches: batches.List(), NodeFailures: dc.nodeFailures(), Warnings: warnings.List(),
+			Trace: span.Proto()})
+		return
+	}
+	resp = bus.NewMessage(bus.MessageID(now), &streamv1.QueryResponse{Elements: entities, NodeFailures: dc.nodeFailures(), Warnings: warnings.List(),
+		Trace: span.Proto(), ContinuationToken: logical_stream.ContinuationToken(plan, entities)})
 
 	return
 }
+
+// offsetSink skips the elements before the offset, which the offset plan drops from the result of a query.
+type offsetSink struct {
+	sink   executor.StreamElementSink
+	offset uint32
+}
+
+func (s *offsetSink) Append(e *streamv1.Element) error {
+	if s.offset > 0 {
+		s.offset--
+		return nil
+	}
+	return s.sink.Append(e)
+}
@@ -0,0 +1,128 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dquery
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/node"
+)
+
+var tierQueryCounter = routeProvider.Counter("tier_total", "group", "tier")
+
+// tierRoute is the parts of a query's time range read from each tier.
+type tierRoute struct {
+	// hot is nil if the hot tier isn't read
+	hot       *modelv1.TimeRange
+	coldNodes []string
+	cold      []*modelv1.TimeRange
+}
+
+// tiers returns nil if all the nodes are in the same tier, or the group is never relocated.
+func (r *router) tiers(metadata *commonv1.Metadata, timeRange *modelv1.TimeRange) (*tierRoute, error) {
+	if r == nil || r.placement == nil || timeRange == nil {
+		return nil, nil
+	}
+	coldNodes := r.placement.ColdNodes()
+	if len(coldNodes) == 0 {
+		return nil, nil
+	}
+	g, err := r.metaService.GroupRegistry().GetGroup(context.Background(), metadata.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	threshold, ok := node.ColdThreshold(g.GetResourceOpts(), now)
+	if !ok {
+		// the policy is removed, but the relocated segments are still on the cold tier
+		if !r.placement.Tracked(metadata.GetGroup()) {
+			return nil, nil
+		}
+		threshold = now
+	}
+	hot, cold := r.placement.Route(metadata.GetGroup(), node.TimeSpan{
+		Begin: timeRange.GetBegin().AsTime(),
+		End:   timeRange.GetEnd().AsTime(),
+	}, threshold)
+	tr := &tierRoute{coldNodes: coldNodes}
+	if hot != nil {
+		tr.hot = toTimeRange(*hot)
+		tierQueryCounter.Inc(1, metadata.GetGroup(), node.TierHot)
+	}
+	for _, s := range cold {
+		tr.cold = append(tr.cold, toTimeRange(s))
+	}
+	if len(cold) > 0 {
+		tierQueryCounter.Inc(1, metadata.GetGroup(), node.TierCold)
+	}
+	return tr, nil
+}
+
+func (dc *distributedContext) tiers() (*tierRoute, error) {
+	if dc.nodes == nil {
+		return nil, nil
+	}
+	return dc.router.tiers(dc.metadata, dc.timeRange)
+}
+
+// hotNodes returns the nodes to broadcast the query except the cold ones.
+func (dc *distributedContext) hotNodes(tr *tierRoute) []string {
+	nodes := dc.nodes.Nodes()
+	if tr == nil {
+		return nodes
+	}
+	cold := make(map[string]struct{}, len(tr.coldNodes))
+	for _, n := range tr.coldNodes {
+		cold[n] = struct{}{}
+	}
+	result := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		if _, ok := cold[n]; !ok {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func toTimeRange(s node.TimeSpan) *modelv1.TimeRange {
+	return &modelv1.TimeRange{Begin: timestamppb.New(s.Begin), End: timestamppb.New(s.End)}
+}
+
+// withTimeRange replaces the time range of a query.
+func withTimeRange(message bus.Message, timeRange *modelv1.TimeRange) bus.Message {
+	switch d := message.Data().(type) {
+	case *streamv1.QueryRequest:
+		q := proto.Clone(d).(*streamv1.QueryRequest)
+		q.TimeRange = timeRange
+		return bus.NewMessage(message.ID(), q)
+	case *measurev1.QueryRequest:
+		q := proto.Clone(d).(*measurev1.QueryRequest)
+		q.TimeRange = timeRange
+		return bus.NewMessage(message.ID(), q)
+	}
+	return message
+}
@@ -0,0 +1,138 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dquery
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/data"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/node"
+)
+
+type fakeRepo struct {
+	metadata.Repo
+	groups fakeGroups
+}
+
+func (r *fakeRepo) GroupRegistry() schema.Group {
+	return r.groups
+}
+
+type fakeGroups struct {
+	schema.Group
+	group *commonv1.Group
+}
+
+func (g fakeGroups) GetGroup(context.Context, string) (*commonv1.Group, error) {
+	return g.group, nil
+}
+
+// tieredNodes answers a stream query with the elements a node holds in the requested time range.
+type tieredNodes struct {
+	elements map[string][]time.Time
+	ranges   map[string][]*modelv1.TimeRange
+}
+
+func (t *tieredNodes) Nodes() []string {
+	nodes := make([]string, 0, len(t.elements))
+	for n := range t.elements {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+func (t *tieredNodes) PublishContext(_ context.Context, _ bus.Topic, messages ...bus.Message) (bus.Future, error) {
+	m := messages[0]
+	q := m.Data().(*streamv1.QueryRequest)
+	t.ranges[m.Node()] = append(t.ranges[m.Node()], q.TimeRange)
+	resp := &streamv1.QueryResponse{}
+	for _, ts := range t.elements[m.Node()] {
+		if !ts.Before(q.TimeRange.Begin.AsTime()) && ts.Before(q.TimeRange.End.AsTime()) {
+			resp.Elements = append(resp.Elements, &streamv1.Element{ElementId: m.Node(), Timestamp: timestamppb.New(ts)})
+		}
+	}
+	return &resolvedFuture{message: bus.NewMessage(m.ID(), resp)}, nil
+}
+
+func TestBroadcastAcrossTiers(t *testing.T) {
+	now := time.Now().Truncate(time.Hour)
+	day := 24 * time.Hour
+	placement, err := node.NewPlacement()
+	require.NoError(t, err)
+	placement.AddNode(&databasev1.Node{Metadata: &commonv1.Metadata{Name: "cold-0"}})
+	// the segments of the oldest two days are relocated, the next ones are still on the hot tier
+	placement.Mark("sw", now.Add(-6*day), now.Add(-4*day), node.SegmentCold)
+	nodes := &tieredNodes{
+		elements: map[string][]time.Time{
+			// the hot node keeps the relocated element until its segment is dropped
+			"hot-0":  {now.Add(-5 * day), now.Add(-3 * day), now.Add(-time.Hour)},
+			"cold-0": {now.Add(-5 * day)},
+		},
+		ranges: make(map[string][]*modelv1.TimeRange),
+	}
+	group := &commonv1.Group{
+		Metadata: &commonv1.Metadata{Name: "sw"},
+		ResourceOpts: &commonv1.ResourceOpts{
+			ShardNum:  1,
+			Ttl:       &commonv1.IntervalRule{Unit: commonv1.IntervalRule_UNIT_DAY, Num: 7},
+			Placement: &commonv1.Placement{ColdAfter: &commonv1.IntervalRule{Unit: commonv1.IntervalRule_UNIT_DAY, Num: 2}},
+		},
+	}
+	dc := &distributedContext{
+		nodes:     nodes,
+		router:    &router{metaService: &fakeRepo{groups: fakeGroups{group: group}}, placement: placement},
+		metadata:  &commonv1.Metadata{Name: "sw", Group: "sw"},
+		timeRange: toTimeRange(node.TimeSpan{Begin: now.Add(-7 * day), End: now}),
+		timeout:   time.Second,
+	}
+	futures, err := dc.Broadcast(data.TopicStreamQuery, bus.NewMessage(1, &streamv1.QueryRequest{
+		Metadata:  dc.metadata,
+		TimeRange: dc.timeRange,
+	}))
+	require.NoError(t, err)
+	var got []time.Time
+	for _, f := range futures {
+		m, errGet := f.Get()
+		require.NoError(t, errGet)
+		for _, e := range m.Data().(*streamv1.QueryResponse).Elements {
+			got = append(got, e.Timestamp.AsTime())
+		}
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Before(got[j]) })
+	assert.Equal(t, []time.Time{now.Add(-5 * day).UTC(), now.Add(-3 * day).UTC(), now.Add(-time.Hour).UTC()}, got)
+
+	require.Len(t, nodes.ranges["hot-0"], 1)
+	assert.Equal(t, now.Add(-4*day).UTC(), nodes.ranges["hot-0"][0].Begin.AsTime(), "the hot tier skips the relocated segments")
+	require.Len(t, nodes.ranges["cold-0"], 1)
+	assert.WithinDuration(t, time.Now().Add(-2*day), nodes.ranges["cold-0"][0].End.AsTime(), time.Minute, "the cold tier stops at the threshold")
+}
@@ -0,0 +1,243 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package drain implements draining and decommissioning a data node.
+//
+// A draining node rejects new writes and flushes its in-memory parts. Once
+// there is no in-memory part, it's reported as drained and can be stopped
+// safely. The merges in progress are abandoned on stopping, which leaves
+// the merged parts untouched.
+package drain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/api/data"
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+const checkInterval = time.Second
+
+var (
+	errNodeNotRegistered = errors.New("the node isn't registered")
+	errNotDecommissioned = errors.New("the node isn't decommissioning")
+	errNotFlushed        = errors.New("the in-memory parts aren't flushed")
+
+	_ run.PreRunner       = (*service)(nil)
+	_ run.Service         = (*service)(nil)
+	_ bus.MessageListener = (*service)(nil)
+)
+
+// MemPartCounter counts the in-memory parts which aren't flushed to the disk yet.
+type MemPartCounter interface {
+	MemPartCount() int
+}
+
+// Service manages the lifecycle state of a data node.
+type Service interface {
+	run.PreRunner
+	run.Service
+}
+
+type service struct {
+	metadata metadata.Repo
+	pipeline queue.Server
+	l        *logger.Logger
+	closer   *run.Closer
+	nodeID   string
+	counters []MemPartCounter
+	state    databasev1.NodeState
+	mu       sync.Mutex
+}
+
+// NewService returns a service draining the data node on the demand of liaisons.
+func NewService(metadata metadata.Repo, pipeline queue.Server, counters ...MemPartCounter) Service {
+	return &service{
+		metadata: metadata,
+		pipeline: pipeline,
+		counters: counters,
+		state:    databasev1.NodeState_NODE_STATE_ACTIVE,
+		closer:   run.NewCloser(1),
+	}
+}
+
+func (s *service) Name() string {
+	return "node-drain"
+}
+
+func (s *service) PreRun(ctx context.Context) error {
+	s.l = logger.GetLogger(s.Name())
+	val := ctx.Value(common.ContextNodeKey)
+	if val == nil {
+		return errors.New("node id is empty")
+	}
+	s.nodeID = val.(common.Node).NodeID
+	return s.pipeline.Subscribe(data.TopicNodeDrain, s)
+}
+
+func (s *service) Serve() run.StopNotify {
+	if !s.closer.AddRunning() {
+		return s.closer.CloseNotify()
+	}
+	go func() {
+		defer s.closer.Done()
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.closer.CloseNotify():
+				return
+			case <-ticker.C:
+				s.checkFlushed()
+			}
+		}
+	}()
+	return s.closer.CloseNotify()
+}
+
+func (s *service) GracefulStop() {
+	s.closer.Done()
+	s.closer.CloseThenWait()
+}
+
+func (s *service) Rev(message bus.Message) (resp bus.Message) {
+	req, ok := message.Data().(*clusterv1.DrainRequest)
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("invalid event data type"))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	switch req.GetAction() {
+	case clusterv1.DrainAction_DRAIN_ACTION_DRAIN:
+		// a decommissioning node is drained already
+		if s.state == databasev1.NodeState_NODE_STATE_ACTIVE {
+			err = s.transit(databasev1.NodeState_NODE_STATE_DRAINING)
+		}
+	case clusterv1.DrainAction_DRAIN_ACTION_DECOMMISSION:
+		if s.state != databasev1.NodeState_NODE_STATE_DECOMMISSIONING {
+			err = s.transit(databasev1.NodeState_NODE_STATE_DECOMMISSIONING)
+		}
+	case clusterv1.DrainAction_DRAIN_ACTION_ABORT:
+		err = s.transit(databasev1.NodeState_NODE_STATE_ACTIVE)
+	case clusterv1.DrainAction_DRAIN_ACTION_COMPLETE:
+		err = s.complete()
+	case clusterv1.DrainAction_DRAIN_ACTION_STATUS:
+	default:
+		err = errors.Errorf("unknown action %s", req.GetAction())
+	}
+	if err != nil {
+		s.l.Error().Err(err).Stringer("action", req.GetAction()).Msg("failed to change the node state")
+		return bus.NewMessage(message.ID(), common.NewError("failed to %s: %v", req.GetAction(), err))
+	}
+	return bus.NewMessage(message.ID(), &clusterv1.DrainResponse{
+		State:    s.state,
+		MemParts: uint32(s.memPartCount()),
+	})
+}
+
+func (s *service) checkFlushed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != databasev1.NodeState_NODE_STATE_DRAINING || s.memPartCount() > 0 {
+		return
+	}
+	if err := s.transit(databasev1.NodeState_NODE_STATE_DRAINED); err != nil {
+		s.l.Error().Err(err).Msg("failed to mark the node drained")
+		return
+	}
+	s.l.Info().Msg("the node is drained, it's safe to stop it")
+}
+
+// complete removes a decommissioning node from the registry after its data is copied to the others.
+func (s *service) complete() error {
+	if s.state == databasev1.NodeState_NODE_STATE_DECOMMISSIONED {
+		return nil
+	}
+	if s.state != databasev1.NodeState_NODE_STATE_DECOMMISSIONING {
+		return errors.WithMessagef(errNotDecommissioned, "the state is %s", s.state)
+	}
+	if n := s.memPartCount(); n > 0 {
+		return errors.WithMessagef(errNotFlushed, "%d in-memory parts left", n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.metadata.NodeRegistry().UnregisterNode(ctx, s.nodeID); err != nil {
+		return err
+	}
+	s.state = databasev1.NodeState_NODE_STATE_DECOMMISSIONED
+	s.l.Info().Msg("the node is decommissioned, it's safe to stop it")
+	return nil
+}
+
+// transit stops or resumes accepting writes, then publishes the state to liaisons through the registry.
+func (s *service) transit(state databasev1.NodeState) error {
+	if s.state == databasev1.NodeState_NODE_STATE_DECOMMISSIONED {
+		return errors.New("the node is decommissioned")
+	}
+	gate, ok := s.pipeline.(queue.WriteGate)
+	if ok {
+		gate.RejectWrites(state != databasev1.NodeState_NODE_STATE_ACTIVE)
+	}
+	if err := s.updateRegistration(state); err != nil {
+		if ok {
+			gate.RejectWrites(s.state != databasev1.NodeState_NODE_STATE_ACTIVE)
+		}
+		return err
+	}
+	s.l.Info().Stringer("from", s.state).Stringer("to", state).Msg("the node state is changed")
+	s.state = state
+	return nil
+}
+
+func (s *service) updateRegistration(state databasev1.NodeState) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	nodes, err := s.metadata.NodeRegistry().ListNode(ctx, databasev1.Role_ROLE_DATA)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if n.GetMetadata().GetName() != s.nodeID {
+			continue
+		}
+		updated := proto.Clone(n).(*databasev1.Node)
+		updated.State = state
+		return s.metadata.NodeRegistry().UpdateNode(ctx, updated)
+	}
+	return errors.WithMessagef(errNodeNotRegistered, "node %s", s.nodeID)
+}
+
+func (s *service) memPartCount() int {
+	var count int
+	for _, c := range s.counters {
+		count += c.MemPartCount()
+	}
+	return count
+}
@@ -0,0 +1,156 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package drain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+type fakeNodeRegistry struct {
+	schema.Node
+	nodes map[string]*databasev1.Node
+}
+
+func (f *fakeNodeRegistry) ListNode(_ context.Context, _ databasev1.Role) ([]*databasev1.Node, error) {
+	result := make([]*databasev1.Node, 0, len(f.nodes))
+	for _, n := range f.nodes {
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func (f *fakeNodeRegistry) UpdateNode(_ context.Context, node *databasev1.Node) error {
+	f.nodes[node.GetMetadata().GetName()] = node
+	return nil
+}
+
+func (f *fakeNodeRegistry) UnregisterNode(_ context.Context, name string) error {
+	delete(f.nodes, name)
+	return nil
+}
+
+type fakeRepo struct {
+	metadata.Repo
+	registry *fakeNodeRegistry
+}
+
+func (f *fakeRepo) NodeRegistry() schema.Node {
+	return f.registry
+}
+
+type fakeServer struct {
+	queue.Server
+	rejected bool
+}
+
+func (f *fakeServer) RejectWrites(reject bool) {
+	f.rejected = reject
+}
+
+type fakeCounter int
+
+func (f *fakeCounter) MemPartCount() int {
+	return int(*f)
+}
+
+func newTestService(t *testing.T) (*service, *fakeNodeRegistry, *fakeServer, *fakeCounter) {
+	require.NoError(t, logger.Init(logger.Logging{Env: "dev", Level: "warn"}))
+	registry := &fakeNodeRegistry{nodes: map[string]*databasev1.Node{
+		"data-1": {Metadata: &commonv1.Metadata{Name: "data-1"}, Roles: []databasev1.Role{databasev1.Role_ROLE_DATA}},
+	}}
+	server := &fakeServer{}
+	counter := new(fakeCounter)
+	*counter = 2
+	s := NewService(&fakeRepo{registry: registry}, server, counter).(*service)
+	s.l = logger.GetLogger("test")
+	s.nodeID = "data-1"
+	return s, registry, server, counter
+}
+
+func act(t *testing.T, s *service, action clusterv1.DrainAction) *clusterv1.DrainResponse {
+	m := s.Rev(bus.NewMessage(bus.MessageID(1), &clusterv1.DrainRequest{Action: action}))
+	resp, ok := m.Data().(*clusterv1.DrainResponse)
+	require.True(t, ok, "unexpected reply %v", m.Data())
+	return resp
+}
+
+func TestDrain(t *testing.T) {
+	s, registry, server, counter := newTestService(t)
+
+	resp := act(t, s, clusterv1.DrainAction_DRAIN_ACTION_DRAIN)
+	assert.Equal(t, databasev1.NodeState_NODE_STATE_DRAINING, resp.State)
+	assert.Equal(t, uint32(2), resp.MemParts)
+	assert.True(t, server.rejected)
+	assert.Equal(t, databasev1.NodeState_NODE_STATE_DRAINING, registry.nodes["data-1"].State)
+
+	s.checkFlushed()
+	assert.Equal(t, databasev1.NodeState_NODE_STATE_DRAINING, act(t, s, clusterv1.DrainAction_DRAIN_ACTION_STATUS).State)
+	*counter = 0
+	s.checkFlushed()
+	resp = act(t, s, clusterv1.DrainAction_DRAIN_ACTION_STATUS)
+	assert.Equal(t, databasev1.NodeState_NODE_STATE_DRAINED, resp.State)
+	assert.Equal(t, uint32(0), resp.MemParts)
+	assert.Equal(t, databasev1.NodeState_NODE_STATE_DRAINED, registry.nodes["data-1"].State)
+
+	resp = act(t, s, clusterv1.DrainAction_DRAIN_ACTION_ABORT)
+	assert.Equal(t, databasev1.NodeState_NODE_STATE_ACTIVE, resp.State)
+	assert.False(t, server.rejected)
+	assert.Equal(t, databasev1.NodeState_NODE_STATE_ACTIVE, registry.nodes["data-1"].State)
+}
+
+func TestDecommission(t *testing.T) {
+	s, registry, server, counter := newTestService(t)
+
+	m := s.Rev(bus.NewMessage(bus.MessageID(1), &clusterv1.DrainRequest{Action: clusterv1.DrainAction_DRAIN_ACTION_COMPLETE}))
+	_, ok := m.Data().(*clusterv1.DrainResponse)
+	assert.False(t, ok, "an active node can't be removed")
+
+	resp := act(t, s, clusterv1.DrainAction_DRAIN_ACTION_DECOMMISSION)
+	assert.Equal(t, databasev1.NodeState_NODE_STATE_DECOMMISSIONING, resp.State)
+	assert.True(t, server.rejected)
+	// the flushing doesn't end decommissioning
+	*counter = 0
+	s.checkFlushed()
+	assert.Equal(t, databasev1.NodeState_NODE_STATE_DECOMMISSIONING, act(t, s, clusterv1.DrainAction_DRAIN_ACTION_DRAIN).State)
+
+	resp = act(t, s, clusterv1.DrainAction_DRAIN_ACTION_COMPLETE)
+	assert.Equal(t, databasev1.NodeState_NODE_STATE_DECOMMISSIONED, resp.State)
+	assert.Empty(t, registry.nodes)
+}
+
+func TestDecommission_NotFlushed(t *testing.T) {
+	s, registry, _, _ := newTestService(t)
+	act(t, s, clusterv1.DrainAction_DRAIN_ACTION_DECOMMISSION)
+	m := s.Rev(bus.NewMessage(bus.MessageID(1), &clusterv1.DrainRequest{Action: clusterv1.DrainAction_DRAIN_ACTION_COMPLETE}))
+	_, ok := m.Data().(*clusterv1.DrainResponse)
+	assert.False(t, ok)
+	assert.Len(t, registry.nodes, 1)
+}
@@ -0,0 +1,290 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/pkg/index"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+// seriesFilename is the file of an archive listing the series documents, one JSON document per line.
+const seriesFilename = "series.json"
+
+const restoreBatchSize = 1024
+
+// Archive lays out the dir as the location of the database, so that the archived segments are opened as they are.
+func (d *database[T, O]) Archive(dir string, analyzerOf func(indexRuleID uint32) databasev1.IndexRule_Analyzer) (ArchiveStats, error) {
+	var stats ArchiveStats
+	lfs.MkdirIfNotExist(dir, dirPerm)
+	if err := d.index.archive(filepath.Join(dir, seriesFilename), analyzerOf); err != nil {
+		return stats, errors.WithMessage(err, "cannot archive the series")
+	}
+	d.RLock()
+	defer d.RUnlock()
+	for _, s := range d.sLst {
+		if err := s.segmentController.archive(filepath.Join(dir, fmt.Sprintf(shardTemplate, int(s.id))), nil, &stats); err != nil {
+			return stats, errors.WithMessagef(err, "cannot archive shard %d", s.id)
+		}
+	}
+	return stats, nil
+}
+
+// ArchiveShard lays out the dir like Archive, but only the segments of the shard overlapping the time range are archived.
+func (d *database[T, O]) ArchiveShard(dir string, shardID common.ShardID, timeRange timestamp.TimeRange,
+	analyzerOf func(indexRuleID uint32) databasev1.IndexRule_Analyzer,
+) (ArchiveStats, error) {
+	var stats ArchiveStats
+	lfs.MkdirIfNotExist(dir, dirPerm)
+	if err := d.index.archive(filepath.Join(dir, seriesFilename), analyzerOf); err != nil {
+		return stats, errors.WithMessage(err, "cannot archive the series")
+	}
+	d.RLock()
+	defer d.RUnlock()
+	if int(shardID) >= len(d.sLst) {
+		return stats, nil
+	}
+	err := d.sLst[shardID].segmentController.archive(filepath.Join(dir, fmt.Sprintf(shardTemplate, int(shardID))), &timeRange, &stats)
+	return stats, errors.WithMessagef(err, "cannot archive shard %d", shardID)
+}
+
+// Import links the archived segments into the database. A segment overlapping an existing one is skipped,
+// whose data are left to be copied by writing them again.
+func (d *database[T, O]) Import(dir string) (ArchiveStats, error) {
+	return d.importArchive(dir, false)
+}
+
+// Merge links the archived segments into the database like Import. The archived parts of a segment
+// the database has are imported into its table if the table is a PartImporter, otherwise the segment is skipped.
+func (d *database[T, O]) Merge(dir string) (ArchiveStats, error) {
+	return d.importArchive(dir, true)
+}
+
+func (d *database[T, O]) importArchive(dir string, merge bool) (ArchiveStats, error) {
+	var stats ArchiveStats
+	if err := d.index.restore(filepath.Join(dir, seriesFilename)); err != nil {
+		return stats, errors.WithMessage(err, "cannot import the series")
+	}
+	err := walkDir(dir, shardPathPrefix, func(suffix string) error {
+		shardID, err := strconv.Atoi(suffix)
+		if err != nil {
+			return err
+		}
+		if shardID >= int(d.opts.ShardNum) {
+			return errors.Errorf("the group has %d shards, but shard %d is archived", d.opts.ShardNum, shardID)
+		}
+		s, err := d.loadShard(common.ShardID(shardID))
+		if err != nil {
+			return err
+		}
+		return s.segmentController.importSegments(filepath.Join(dir, fmt.Sprintf(shardTemplate, shardID)), merge, &stats)
+	})
+	return stats, err
+}
+
+func (d *database[T, O]) loadShard(shardID common.ShardID) (*shard[T, O], error) {
+	d.Lock()
+	defer d.Unlock()
+	for i := len(d.sLst); i <= int(shardID); i++ {
+		d.logger.Info().Int("shard_id", i).Msg("creating a shard")
+		if err := d.registerShard(i); err != nil {
+			return nil, err
+		}
+	}
+	return d.sLst[shardID], nil
+}
+
+// archive archives the segments overlapping the time range, all segments if it's nil.
+func (sc *segmentController[T, O]) archive(dir string, timeRange *timestamp.TimeRange, stats *ArchiveStats) error {
+	var err error
+	for _, s := range sc.segments() {
+		if err == nil && (timeRange == nil || s.Overlapping(*timeRange)) {
+			err = s.archive(filepath.Join(dir, fmt.Sprintf(segTemplate, s.suffix)), timeRange, stats)
+		}
+		s.DecRef()
+	}
+	return err
+}
+
+// archive archives the table of the segment. Only the parts overlapping the time range are archived
+// if the table is a RangeArchiver and the time range isn't nil.
+func (s *segment[T]) archive(dir string, timeRange *timestamp.TimeRange, stats *ArchiveStats) error {
+	archiver, ok := any(s.tsTable).(Archiver)
+	if !ok {
+		return nil
+	}
+	lfs.MkdirIfNotExist(dir, dirPerm)
+	version, err := lfs.Read(filepath.Join(s.path, metadataFilename))
+	if err != nil {
+		return err
+	}
+	if _, err = lfs.Write(version, filepath.Join(dir, metadataFilename), filePermission); err != nil {
+		return err
+	}
+	var parts int
+	var size uint64
+	if ra, isRange := any(s.tsTable).(RangeArchiver); isRange && timeRange != nil {
+		parts, size, err = ra.ArchiveRange(dir, *timeRange)
+	} else {
+		parts, size, err = archiver.Archive(dir)
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "cannot archive segment %s", s.suffix)
+	}
+	stats.Segments++
+	stats.Parts += parts
+	stats.Size += size
+	return nil
+}
+
+func (sc *segmentController[T, O]) importSegments(dir string, merge bool, stats *ArchiveStats) error {
+	compatibleVersions, err := readCompatibleVersions()
+	if err != nil {
+		return err
+	}
+	return walkDir(dir, segPathPrefix, func(suffix string) error {
+		start, errParse := sc.Parse(suffix)
+		if errParse != nil {
+			return errors.WithMessage(errParse, "the segment interval of the archive doesn't match the group")
+		}
+		src := filepath.Join(dir, fmt.Sprintf(segTemplate, suffix))
+		version, errRead := lfs.Read(filepath.Join(src, metadataFilename))
+		if errRead != nil {
+			return errRead
+		}
+		if !slices.Contains(compatibleVersions[compatibleVersionsKey], string(version)) {
+			return errVersionIncompatible
+		}
+		imported, size, errImport := sc.importSegment(start, src, merge)
+		if errImport != nil {
+			return errImport
+		}
+		if !imported {
+			stats.SkippedSegments++
+			stats.SkippedSuffixes = append(stats.SkippedSuffixes, suffix)
+			return nil
+		}
+		stats.Segments++
+		stats.Size += size
+		return nil
+	})
+}
+
+func (sc *segmentController[T, O]) importSegment(start time.Time, src string, merge bool) (bool, uint64, error) {
+	sc.Lock()
+	defer sc.Unlock()
+	existing, end := sc.segmentEnd(start)
+	if existing != nil {
+		if importer, ok := any(existing.tsTable).(PartImporter); ok && merge {
+			parts, size, err := importer.ImportParts(src)
+			if err != nil {
+				return false, 0, errors.WithMessagef(err, "cannot merge the archived segment %s", src)
+			}
+			sc.l.Info().Stringer("segment", existing).Str("archive", src).Int("parts", parts).Msg("merged the archived parts into the segment")
+			return true, size, nil
+		}
+		sc.l.Info().Stringer("segment", existing).Str("archive", src).Msg("skip the archived segment since it exists")
+		return false, 0, nil
+	}
+	segPath := filepath.Join(sc.location, fmt.Sprintf(segTemplate, sc.Format(start)))
+	if _, err := os.Stat(segPath); !errors.Is(err, os.ErrNotExist) {
+		return false, 0, errors.Errorf("the directory %s of the segment exists", segPath)
+	}
+	size, err := lfs.CreateHardLink(src, segPath, nil)
+	if err == nil {
+		_, err = sc.load(start, end, sc.location)
+	}
+	if err != nil {
+		lfs.MustRMAll(segPath)
+		return false, 0, err
+	}
+	sc.l.Info().Str("path", segPath).Time("start", start).Time("end", end).Msg("imported the archived segment")
+	return true, size, nil
+}
+
+func (s *seriesIndex) archive(path string, analyzerOf func(indexRuleID uint32) databasev1.IndexRule_Analyzer) error {
+	if err := s.commit("archive"); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, filePermission)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	err = s.store.Documents(analyzerOf, func(doc index.Document) error {
+		return enc.Encode(doc)
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+func (s *seriesIndex) restore(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	docs := make(index.Documents, 0, restoreBatchSize)
+	for {
+		var doc index.Document
+		err = dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return errors.WithMessagef(err, "cannot decode %s", path)
+		}
+		if docs = append(docs, doc); len(docs) < restoreBatchSize {
+			continue
+		}
+		if err = s.apply(docs); err != nil {
+			return err
+		}
+		docs = docs[:0]
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	return s.apply(docs)
+}
@@ -18,8 +18,12 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"path"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
@@ -31,6 +35,7 @@ import (
 	"github.com/apache/skywalking-banyandb/pkg/index/posting"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/run"
 )
 
 func (d *database[T, O]) IndexDB() IndexDB {
@@ -41,27 +46,111 @@ func (d *database[T, O]) Lookup(ctx context.Context, series *pbv1.Series) (pbv1.
 	return d.index.searchPrimary(ctx, series)
 }
 
+// seriesIndex buffers the series written in memory, and commits them once the buffer is full
+// or every commit interval, which is offset from the flushes of the data to spread their IO.
+// The lookups search both the committed series and the buffered ones.
 type seriesIndex struct {
-	store index.SeriesStore
-	l     *logger.Logger
+	store  index.SeriesStore
+	l      *logger.Logger
+	closer *run.Closer
+	group  string
+	buffer seriesBuffer
+	// full wakes up the committer once the buffer is full
+	full     chan struct{}
+	opts     SeriesIndexOpts
+	commitMu sync.Mutex
 }
 
-func newSeriesIndex(ctx context.Context, root string, flushTimeoutSeconds int64) (*seriesIndex, error) {
+func newSeriesIndex(ctx context.Context, root string, opts SeriesIndexOpts) (*seriesIndex, error) {
 	si := &seriesIndex{
-		l: logger.Fetch(ctx, "series_index"),
+		l:      logger.Fetch(ctx, "series_index"),
+		group:  common.GetPosition(ctx).Database,
+		opts:   opts,
+		closer: run.NewCloser(0),
+	}
+	var batchWaitSec int64
+	if opts.AsyncFsync {
+		batchWaitSec = max(int64(opts.CommitInterval/time.Second), 1)
 	}
 	var err error
 	if si.store, err = inverted.NewStore(inverted.StoreOpts{
 		Path:         path.Join(root, "idx"),
 		Logger:       si.l,
-		BatchWaitSec: flushTimeoutSeconds,
+		BatchWaitSec: batchWaitSec,
 	}); err != nil {
 		return nil, err
 	}
+	if opts.MaxBufferedDocs > 0 && opts.CommitInterval > 0 {
+		si.full = make(chan struct{}, 1)
+		si.closer.AddRunning()
+		go si.runCommitter()
+	}
 	return si, nil
 }
 
+// runCommitter commits the buffer every commit interval or once it's full. The first commit happens half an interval later,
+// so that the commits fall between the flushes of the data started along with the index if their intervals are equal.
+func (s *seriesIndex) runCommitter() {
+	defer s.closer.Done()
+	timer := time.NewTimer(s.opts.CommitInterval / 2)
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.closer.CloseNotify():
+			return
+		case <-timer.C:
+			if err := s.commit("interval"); err != nil {
+				s.l.Error().Err(err).Msg("cannot commit the series index")
+			}
+			timer.Reset(s.opts.CommitInterval)
+		case <-s.full:
+			if err := s.commit("size"); err != nil {
+				s.l.Error().Err(err).Msg("cannot commit the series index")
+			}
+		}
+	}
+}
+
 func (s *seriesIndex) Write(docs index.Documents) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	if s.opts.MaxBufferedDocs <= 0 {
+		return s.apply(docs)
+	}
+	n := s.buffer.add(docs)
+	seriesIndexBufferedDocs.Set(float64(n), s.group)
+	if n < s.opts.MaxBufferedDocs {
+		return nil
+	}
+	if s.full == nil {
+		return s.commit("size")
+	}
+	// the writers don't wait for the commit
+	select {
+	case s.full <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// commit applies the buffered series to the store. The commits are serialized.
+func (s *seriesIndex) commit(reason string) error {
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+	docs := s.buffer.take()
+	if len(docs) == 0 {
+		return nil
+	}
+	start := time.Now()
+	err := s.apply(docs)
+	seriesIndexCommitLatency.Observe(time.Since(start).Seconds(), s.group)
+	seriesIndexCommitCount.Inc(1, s.group, reason)
+	seriesIndexBufferedDocs.Set(float64(s.buffer.committed()), s.group)
+	return err
+}
+
+func (s *seriesIndex) apply(docs index.Documents) error {
 	applied := make(chan struct{})
 	err := s.store.Batch(index.Batch{
 		Documents: docs,
@@ -74,6 +163,31 @@ func (s *seriesIndex) Write(docs index.Documents) error {
 	return nil
 }
 
+func (s *seriesIndex) Delete(seriesIDs []common.SeriesID) error {
+	if len(seriesIDs) == 0 {
+		return nil
+	}
+	// the buffered series are dropped rather than committed, and a commit in flight is waited for
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+	s.buffer.drop(seriesIDs)
+	seriesIndexBufferedDocs.Set(float64(s.buffer.len()), s.group)
+	deletions := make([]uint64, 0, len(seriesIDs))
+	for _, id := range seriesIDs {
+		deletions = append(deletions, uint64(id))
+	}
+	applied := make(chan struct{})
+	err := s.store.Batch(index.Batch{
+		Deletions: deletions,
+		Applied:   applied,
+	})
+	if err != nil {
+		return err
+	}
+	<-applied
+	return nil
+}
+
 var rangeOpts = index.RangeOpts{}
 
 func (s *seriesIndex) searchPrimary(_ context.Context, series *pbv1.Series) (pbv1.SeriesList, error) {
@@ -109,6 +223,9 @@ func (s *seriesIndex) searchPrimary(_ context.Context, series *pbv1.Series) (pbv
 			if err != nil {
 				return nil, err
 			}
+			ss = s.buffer.search(ss, func(entityValues []byte) bool {
+				return matchWildcard(series.Buffer, entityValues)
+			})
 			return convertIndexSeriesToSeriesList(ss)
 		}
 		series.EntityValues = series.EntityValues[:prefixIndex]
@@ -119,14 +236,20 @@ func (s *seriesIndex) searchPrimary(_ context.Context, series *pbv1.Series) (pbv
 		if err != nil {
 			return nil, err
 		}
+		ss = s.buffer.search(ss, func(entityValues []byte) bool {
+			return bytes.HasPrefix(entityValues, series.Buffer)
+		})
 		return convertIndexSeriesToSeriesList(ss)
 	}
 	if err = series.Marshal(); err != nil {
 		return nil, err
 	}
 	var seriesID common.SeriesID
-	seriesID, err = s.store.Search(series.Buffer)
-	if err != nil {
+	if ss := s.buffer.search(nil, func(entityValues []byte) bool {
+		return bytes.Equal(entityValues, series.Buffer)
+	}); len(ss) > 0 {
+		seriesID = ss[0].ID
+	} else if seriesID, err = s.store.Search(series.Buffer); err != nil {
 		return nil, err
 	}
 	if seriesID > 0 {
@@ -136,6 +259,34 @@ func (s *seriesIndex) searchPrimary(_ context.Context, series *pbv1.Series) (pbv
 	return nil, nil
 }
 
+func (s *seriesIndex) SearchPrefix(prefix, cursor []byte, limit int) (pbv1.SeriesList, bool, error) {
+	ss, err := s.store.SearchPrefix(prefix)
+	if err != nil {
+		return nil, false, err
+	}
+	ss = s.buffer.search(ss, func(entityValues []byte) bool {
+		return bytes.HasPrefix(entityValues, prefix)
+	})
+	ss = slices.DeleteFunc(ss, func(series index.Series) bool {
+		return bytes.Compare(series.EntityValues, cursor) <= 0
+	})
+	slices.SortFunc(ss, func(a, b index.Series) int {
+		return bytes.Compare(a.EntityValues, b.EntityValues)
+	})
+	var more bool
+	if limit > 0 && len(ss) > limit {
+		ss, more = ss[:limit], true
+	}
+	seriesList, err := convertIndexSeriesToSeriesList(ss)
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range seriesList {
+		seriesList[i].Buffer = ss[i].EntityValues
+	}
+	return seriesList, more, nil
+}
+
 func convertIndexSeriesToSeriesList(indexSeries []index.Series) (pbv1.SeriesList, error) {
 	seriesList := make(pbv1.SeriesList, 0, len(indexSeries))
 	for _, s := range indexSeries {
@@ -150,6 +301,12 @@ func convertIndexSeriesToSeriesList(indexSeries []index.Series) (pbv1.SeriesList
 }
 
 func (s *seriesIndex) Search(ctx context.Context, series *pbv1.Series, filter index.Filter, order *pbv1.OrderBy) (pbv1.SeriesList, error) {
+	// the filter and the order are evaluated by the store, which has to hold the buffered series
+	if filter != nil || order != nil && order.Index != nil {
+		if err := s.commit("query"); err != nil {
+			return nil, err
+		}
+	}
 	seriesList, err := s.searchPrimary(ctx, series)
 	if err != nil {
 		return nil, err
@@ -157,6 +314,9 @@ func (s *seriesIndex) Search(ctx context.Context, series *pbv1.Series, filter in
 
 	pl := seriesList.ToList()
 	if filter != nil {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
 		var plFilter posting.List
 		plFilter, err = filter.Execute(func(ruleType databasev1.IndexRule_Type) (index.Searcher, error) {
 			return s.store, nil
@@ -186,6 +346,9 @@ func (s *seriesIndex) Search(ctx context.Context, series *pbv1.Series, filter in
 
 	var sortedSeriesList pbv1.SeriesList
 	for iter.Next() {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
 		pv := iter.Val().Value
 		if err = pv.Intersect(pl); err != nil {
 			return nil, err
@@ -222,5 +385,6 @@ func appendSeriesList(dest, src pbv1.SeriesList, filter posting.List) pbv1.Serie
 }
 
 func (s *seriesIndex) Close() error {
-	return s.store.Close()
+	s.closer.CloseThenWait()
+	return multierr.Append(s.commit("close"), s.store.Close())
 }
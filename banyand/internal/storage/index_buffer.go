@@ -0,0 +1,163 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package storage
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/pkg/index"
+	"github.com/apache/skywalking-banyandb/pkg/meter"
+)
+
+const (
+	// DefaultSeriesIndexCommitInterval is the default interval of the commits of the series index.
+	DefaultSeriesIndexCommitInterval = 10 * time.Second
+	// DefaultSeriesIndexMaxBufferedDocs is the default number of the series buffered before a commit.
+	DefaultSeriesIndexMaxBufferedDocs = 1024
+)
+
+var (
+	seriesIndexProvider      = observability.NewMeterProvider(observability.RootScope.SubScope("series_index"))
+	seriesIndexBufferedDocs  = seriesIndexProvider.Gauge("buffered_docs", "group")
+	seriesIndexCommitCount   = seriesIndexProvider.Counter("commits_total", "group", "reason")
+	seriesIndexCommitLatency = seriesIndexProvider.Histogram("commit_latency", meter.DefBuckets, "group")
+)
+
+// SeriesIndexOpts tunes the commits of the series index, which are scheduled independently of the flushes of the data.
+type SeriesIndexOpts struct {
+	// CommitInterval is the max time the series written wait in memory before they are committed.
+	CommitInterval time.Duration
+	// MaxBufferedDocs is the max number of the series buffered in memory, the buffer is committed once it's full.
+	// Every write is committed at once if it's not positive.
+	MaxBufferedDocs int
+	// AsyncFsync persists the committed series in the background every CommitInterval,
+	// instead of syncing them to the disk on every commit.
+	AsyncFsync bool
+}
+
+// seriesBuffer holds the series written but not committed yet. A series being committed stays
+// in the buffer until the commit is applied, so that it's visible to the lookups all the time.
+type seriesBuffer struct {
+	active     map[common.SeriesID]index.Document
+	committing map[common.SeriesID]index.Document
+	mu         sync.RWMutex
+}
+
+func (b *seriesBuffer) add(docs index.Documents) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.active == nil {
+		b.active = make(map[common.SeriesID]index.Document)
+	}
+	for _, d := range docs {
+		d.EntityValues = bytes.Clone(d.EntityValues)
+		b.active[common.SeriesID(d.DocID)] = d
+	}
+	return len(b.active) + len(b.committing)
+}
+
+// take moves the active series to be committed, and returns them.
+func (b *seriesBuffer) take() index.Documents {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.active) == 0 {
+		return nil
+	}
+	docs := make(index.Documents, 0, len(b.active))
+	for _, d := range b.active {
+		docs = append(docs, d)
+	}
+	b.committing, b.active = b.active, nil
+	return docs
+}
+
+// committed drops the committed series, and returns the number of the series left.
+func (b *seriesBuffer) committed() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.committing = nil
+	return len(b.active)
+}
+
+func (b *seriesBuffer) drop(seriesIDs []common.SeriesID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, id := range seriesIDs {
+		delete(b.active, id)
+		delete(b.committing, id)
+	}
+}
+
+func (b *seriesBuffer) len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.active) + len(b.committing)
+}
+
+// search appends the buffered series whose entity values match to dst, unless dst contains them.
+func (b *seriesBuffer) search(dst []index.Series, match func(entityValues []byte) bool) []index.Series {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.active) == 0 && len(b.committing) == 0 {
+		return dst
+	}
+	found := make(map[common.SeriesID]struct{}, len(dst))
+	for _, s := range dst {
+		found[s.ID] = struct{}{}
+	}
+	for _, m := range []map[common.SeriesID]index.Document{b.active, b.committing} {
+		for id, d := range m {
+			if _, ok := found[id]; ok || !match(d.EntityValues) {
+				continue
+			}
+			found[id] = struct{}{}
+			dst = append(dst, index.Series{ID: id, EntityValues: d.EntityValues})
+		}
+	}
+	return dst
+}
+
+// matchWildcard reports whether the value matches the pattern,
+// in which '*' matches any sequence of bytes and '?' matches any single byte as the index does.
+func matchWildcard(pattern, value []byte) bool {
+	star, next := -1, 0
+	var p, v int
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, v
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case star >= 0:
+			next++
+			p, v = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
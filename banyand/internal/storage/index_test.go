@@ -20,11 +20,14 @@ package storage
 import (
 	"context"
 	"fmt"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/apache/skywalking-banyandb/api/common"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
 	"github.com/apache/skywalking-banyandb/pkg/index"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
@@ -38,7 +41,7 @@ var testSeriesPool pbv1.SeriesPool
 func TestSeriesIndex_Primary(t *testing.T) {
 	ctx := context.Background()
 	path, fn := setUp(require.New(t))
-	si, err := newSeriesIndex(ctx, path, 0)
+	si, err := newSeriesIndex(ctx, path, SeriesIndexOpts{})
 	require.NoError(t, err)
 	defer func() {
 		require.NoError(t, si.Close())
@@ -69,7 +72,7 @@ func TestSeriesIndex_Primary(t *testing.T) {
 	require.NoError(t, si.Write(docs))
 	// Restart the index
 	require.NoError(t, si.Close())
-	si, err = newSeriesIndex(ctx, path, 0)
+	si, err = newSeriesIndex(ctx, path, SeriesIndexOpts{})
 	require.NoError(t, err)
 	tests := []struct {
 		name         string
@@ -132,6 +135,256 @@ func TestSeriesIndex_Primary(t *testing.T) {
 	}
 }
 
+func TestSeriesIndex_Delete(t *testing.T) {
+	ctx := context.Background()
+	path, fn := setUp(require.New(t))
+	si, err := newSeriesIndex(ctx, path, SeriesIndexOpts{})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, si.Close())
+		fn()
+	}()
+	var docs index.Documents
+	var deleted []common.SeriesID
+	for _, subject := range []string{"sw", "sw_other"} {
+		for i := 0; i < 3; i++ {
+			series := testSeriesPool.Generate()
+			series.Subject = subject
+			series.EntityValues = []*modelv1.TagValue{{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: fmt.Sprintf("svc_%d", i)}}}}
+			require.NoError(t, series.Marshal())
+			docs = append(docs, index.Document{
+				DocID:        uint64(series.ID),
+				EntityValues: append([]byte(nil), series.Buffer...),
+			})
+			if subject == "sw" {
+				deleted = append(deleted, series.ID)
+			}
+			testSeriesPool.Release(series)
+		}
+	}
+	require.NoError(t, si.Write(docs))
+	lookup := func(subject string) pbv1.SeriesList {
+		seriesQuery := testSeriesPool.Generate()
+		defer testSeriesPool.Release(seriesQuery)
+		seriesQuery.Subject = subject
+		seriesQuery.EntityValues = []*modelv1.TagValue{pbv1.AnyTagValue}
+		sl, errSearch := si.searchPrimary(ctx, seriesQuery)
+		require.NoError(t, errSearch)
+		return sl
+	}
+	require.Len(t, lookup("sw"), 3)
+
+	require.NoError(t, si.Delete(deleted))
+	assert.Empty(t, lookup("sw"))
+	assert.Len(t, lookup("sw_other"), 3, "the series of the other subject are kept")
+
+	require.NoError(t, si.Write(docs[:1]))
+	assert.Len(t, lookup("sw"), 1, "a deleted series is found once it's written again")
+}
+
+func TestSeriesIndex_Buffer(t *testing.T) {
+	ctx := context.Background()
+	path, fn := setUp(require.New(t))
+	si, err := newSeriesIndex(ctx, path, SeriesIndexOpts{CommitInterval: time.Hour, MaxBufferedDocs: 100})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, si.Close())
+		fn()
+	}()
+	docs, ids := generateSeriesDocs(t, "sw", 3)
+	require.NoError(t, si.Write(docs))
+	lookup := func(entityValues ...*modelv1.TagValue) pbv1.SeriesList {
+		seriesQuery := testSeriesPool.Generate()
+		defer testSeriesPool.Release(seriesQuery)
+		seriesQuery.Subject = "sw"
+		seriesQuery.EntityValues = entityValues
+		sl, errSearch := si.searchPrimary(ctx, seriesQuery)
+		require.NoError(t, errSearch)
+		return sl
+	}
+	str := func(v string) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: v}}}
+	}
+	verify := func(stage string) {
+		sl := lookup(str("svc_1"), str("svc_1_instance_1"))
+		require.Len(t, sl, 1, stage)
+		assert.Equal(t, ids[1], sl[0].ID, stage)
+		assert.Len(t, lookup(str("svc_1"), pbv1.AnyTagValue), 1, stage)
+		assert.Len(t, lookup(pbv1.AnyTagValue, str("svc_2_instance_2")), 1, stage)
+		assert.Len(t, lookup(pbv1.AnyTagValue, pbv1.AnyTagValue), 3, stage)
+	}
+	assert.Equal(t, 3, si.buffer.len())
+	verify("buffered")
+
+	require.NoError(t, si.commit("test"))
+	assert.Equal(t, 0, si.buffer.len())
+	verify("committed")
+
+	// the series written again are in both the buffer and the store
+	require.NoError(t, si.Write(docs))
+	verify("rewritten")
+
+	require.NoError(t, si.Delete(ids[:1]))
+	assert.Equal(t, 2, si.buffer.len())
+	assert.Empty(t, lookup(str("svc_0"), str("svc_0_instance_0")))
+	assert.Len(t, lookup(pbv1.AnyTagValue, pbv1.AnyTagValue), 2)
+}
+
+func TestSeriesIndex_BufferFull(t *testing.T) {
+	ctx := context.Background()
+	path, fn := setUp(require.New(t))
+	si, err := newSeriesIndex(ctx, path, SeriesIndexOpts{CommitInterval: time.Hour, MaxBufferedDocs: 2})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, si.Close())
+		fn()
+	}()
+	docs, _ := generateSeriesDocs(t, "sw", 3)
+	require.NoError(t, si.Write(docs[:1]))
+	assert.Equal(t, 1, si.buffer.len())
+	require.NoError(t, si.Write(docs[1:]))
+	assert.Eventually(t, func() bool { return si.buffer.len() == 0 }, flags.EventuallyTimeout, 10*time.Millisecond,
+		"the committer commits the full buffer")
+
+	// the series buffered are committed on closing
+	docs, _ = generateSeriesDocs(t, "sw_other", 1)
+	require.NoError(t, si.Write(docs))
+	require.NoError(t, si.Close())
+	si, err = newSeriesIndex(ctx, path, SeriesIndexOpts{})
+	require.NoError(t, err)
+	seriesQuery := testSeriesPool.Generate()
+	defer testSeriesPool.Release(seriesQuery)
+	seriesQuery.Subject = "sw_other"
+	seriesQuery.EntityValues = []*modelv1.TagValue{pbv1.AnyTagValue, pbv1.AnyTagValue}
+	sl, err := si.searchPrimary(ctx, seriesQuery)
+	require.NoError(t, err)
+	assert.Len(t, sl, 1)
+}
+
+func TestSeriesIndex_SearchPrefix(t *testing.T) {
+	ctx := context.Background()
+	path, fn := setUp(require.New(t))
+	si, err := newSeriesIndex(ctx, path, SeriesIndexOpts{CommitInterval: time.Hour, MaxBufferedDocs: 100})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, si.Close())
+		fn()
+	}()
+	docs, _ := generateSeriesDocs(t, "sw", 12)
+	require.NoError(t, si.Write(docs[:6]))
+	require.NoError(t, si.commit("test"))
+	// the buffered series are searched along with the committed ones
+	require.NoError(t, si.Write(docs[6:]))
+	others, _ := generateSeriesDocs(t, "sw_other", 2)
+	require.NoError(t, si.Write(others))
+
+	prefix := &pbv1.Series{Subject: "sw"}
+	require.NoError(t, prefix.MarshalPrefix("svc_1"))
+	services := func(sl pbv1.SeriesList) []string {
+		var result []string
+		for _, s := range sl {
+			result = append(result, s.EntityValues[0].GetStr().GetValue())
+		}
+		return result
+	}
+	sl, more, err := si.SearchPrefix(prefix.Buffer, nil, 2)
+	require.NoError(t, err)
+	assert.True(t, more)
+	assert.Equal(t, []string{"svc_10", "svc_11"}, services(sl), "the series are in order of their marshaled entities")
+	sl, more, err = si.SearchPrefix(prefix.Buffer, sl[1].Buffer, 2)
+	require.NoError(t, err)
+	assert.False(t, more)
+	assert.Equal(t, []string{"svc_1"}, services(sl))
+
+	all := &pbv1.Series{Subject: "sw"}
+	require.NoError(t, all.MarshalPrefix(""))
+	sl, more, err = si.SearchPrefix(all.Buffer, nil, 0)
+	require.NoError(t, err)
+	assert.False(t, more)
+	assert.Len(t, sl, 12, "the series of sw_other don't match")
+}
+
+func Test_matchWildcard(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "abc", true},
+		{"a*", "abc", true},
+		{"*c", "abc", true},
+		{"a*c", "abbbc", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"abc", "abcd", false},
+		{"", "a", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchWildcard([]byte(tt.pattern), []byte(tt.value)), "%s ~ %s", tt.pattern, tt.value)
+	}
+}
+
+// BenchmarkSeriesIndex_Write reports the p99 latency of the writes when the series index
+// is committed on every write, and when it's buffered and committed in the background.
+func BenchmarkSeriesIndex_Write(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts SeriesIndexOpts
+	}{
+		{name: "sync", opts: SeriesIndexOpts{}},
+		{name: "buffered", opts: SeriesIndexOpts{CommitInterval: 100 * time.Millisecond, MaxBufferedDocs: DefaultSeriesIndexMaxBufferedDocs, AsyncFsync: true}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			path, fn := setUp(require.New(b))
+			defer fn()
+			si, err := newSeriesIndex(context.Background(), path, bc.opts)
+			require.NoError(b, err)
+			defer si.Close()
+			latencies := make([]time.Duration, 0, b.N)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				series := testSeriesPool.Generate()
+				series.Subject = "bench"
+				series.EntityValues = []*modelv1.TagValue{{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: fmt.Sprintf("svc_%d", i)}}}}
+				require.NoError(b, series.Marshal())
+				doc := index.Document{DocID: uint64(series.ID), EntityValues: append([]byte(nil), series.Buffer...)}
+				testSeriesPool.Release(series)
+				start := time.Now()
+				require.NoError(b, si.Write(index.Documents{doc}))
+				latencies = append(latencies, time.Since(start))
+			}
+			b.StopTimer()
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds()), "p99-us")
+		})
+	}
+}
+
+// generateSeriesDocs generates the documents of n series of the subject, whose entity values are svc_i and svc_i_instance_i.
+func generateSeriesDocs(t *testing.T, subject string, n int) (index.Documents, []common.SeriesID) {
+	var docs index.Documents
+	var ids []common.SeriesID
+	for i := 0; i < n; i++ {
+		series := testSeriesPool.Generate()
+		series.Subject = subject
+		series.EntityValues = []*modelv1.TagValue{
+			{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: fmt.Sprintf("svc_%d", i)}}},
+			{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: fmt.Sprintf("svc_%d_instance_%d", i, i)}}},
+		}
+		require.NoError(t, series.Marshal())
+		docs = append(docs, index.Document{
+			DocID:        uint64(series.ID),
+			EntityValues: append([]byte(nil), series.Buffer...),
+		})
+		ids = append(ids, series.ID)
+		testSeriesPool.Release(series)
+	}
+	return docs, ids
+}
+
 func setUp(t *require.Assertions) (tempDir string, deferFunc func()) {
 	t.NoError(logger.Init(logger.Logging{
 		Env:   "dev",
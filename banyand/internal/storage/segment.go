@@ -95,6 +95,9 @@ func (s *segment[T]) DecRef() {
 	}
 
 	if deletePath != "" {
+		if offloader, ok := any(s.tsTable).(Offloader); ok {
+			offloader.RemoveOffloaded()
+		}
 		lfs.MustRMAll(deletePath)
 	}
 }
@@ -283,21 +286,9 @@ func (sc *segmentController[T, O]) create(start time.Time) (*segment[T], error)
 	sc.Lock()
 	defer sc.Unlock()
 	start = sc.Standard(start)
-	var next *segment[T]
-	for _, s := range sc.lst {
-		if s.Contains(uint64(start.UnixNano())) {
-			return s, nil
-		}
-		if next == nil && s.Start.After(start) {
-			next = s
-		}
-	}
-	stdEnd := sc.segmentSize.nextTime(start)
-	var end time.Time
-	if next != nil && next.Start.Before(stdEnd) {
-		end = next.Start
-	} else {
-		end = stdEnd
+	existing, end := sc.segmentEnd(start)
+	if existing != nil {
+		return existing, nil
 	}
 	segPath := path.Join(sc.location, fmt.Sprintf(segTemplate, sc.Format(start)))
 	lfs.MkdirPanicIfExist(segPath, dirPerm)
@@ -317,6 +308,25 @@ func (sc *segmentController[T, O]) create(start time.Time) (*segment[T], error)
 	return sc.load(start, end, sc.location)
 }
 
+// segmentEnd returns the segment containing start if there is one.
+// Otherwise, it returns the end of a new segment beginning at start, which ends before the next segment.
+func (sc *segmentController[T, O]) segmentEnd(start time.Time) (*segment[T], time.Time) {
+	var next *segment[T]
+	for _, s := range sc.lst {
+		if s.Contains(uint64(start.UnixNano())) {
+			return s, time.Time{}
+		}
+		if next == nil && s.Start.After(start) {
+			next = s
+		}
+	}
+	stdEnd := sc.segmentSize.nextTime(start)
+	if next != nil && next.Start.Before(stdEnd) {
+		return nil, next.Start
+	}
+	return nil, stdEnd
+}
+
 func (sc *segmentController[T, O]) sortLst() {
 	sort.Slice(sc.lst, func(i, j int) bool {
 		return sc.lst[i].id < sc.lst[j].id
@@ -360,6 +370,23 @@ func (sc *segmentController[T, O]) remove(deadline time.Time) (err error) {
 	return err
 }
 
+// dropBefore differs from remove in keeping the segment which contains the deadline.
+// It's used to remove the segments whose data are copied elsewhere.
+func (sc *segmentController[T, O]) dropBefore(deadline time.Time) (dropped int) {
+	for _, s := range sc.segments() {
+		if !s.End.After(deadline) {
+			sc.l.Info().Stringer("segment", s).Time("deadline", deadline).Msg("drop the segment")
+			s.delete()
+			sc.Lock()
+			sc.removeSeg(s.id)
+			sc.Unlock()
+			dropped++
+		}
+		s.DecRef()
+	}
+	return dropped
+}
+
 func (sc *segmentController[T, O]) removeSeg(segID segmentID) {
 	for i, b := range sc.lst {
 		if b.id == segID {
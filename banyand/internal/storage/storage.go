@@ -32,6 +32,7 @@ import (
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
 	"github.com/apache/skywalking-banyandb/pkg/index"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
@@ -67,6 +68,12 @@ type SupplyTSDB[T TSTable] func() T
 type IndexDB interface {
 	Write(docs index.Documents) error
 	Search(ctx context.Context, series *pbv1.Series, filter index.Filter, order *pbv1.OrderBy) (pbv1.SeriesList, error)
+	// Delete removes the series from the index. Lookup no longer finds them until they are written again.
+	Delete(seriesIDs []common.SeriesID) error
+	// SearchPrefix returns the series whose marshaled entities start with prefix and sort after the cursor,
+	// in order of their marshaled entities, which are set to the Buffer of the series. At most limit series are returned,
+	// and more tells whether there are others.
+	SearchPrefix(prefix, cursor []byte, limit int) (seriesList pbv1.SeriesList, more bool, err error)
 }
 
 // TSDB allows listing and getting shard details.
@@ -74,8 +81,23 @@ type TSDB[T TSTable, O any] interface {
 	io.Closer
 	Lookup(ctx context.Context, series *pbv1.Series) (pbv1.SeriesList, error)
 	CreateTSTableIfNotExist(shardID common.ShardID, ts time.Time) (TSTableWrapper[T], error)
-	SelectTSTables(timeRange timestamp.TimeRange) []TSTableWrapper[T]
+	SelectTSTables(timeRange timestamp.TimeRange, shardIDs ...common.ShardID) []TSTableWrapper[T]
 	IndexDB() IndexDB
+	// DropSegments removes the segments ending at or before the deadline in all shards,
+	// and returns the number of removed segments.
+	DropSegments(deadline time.Time) int
+	// Archive writes the series and the flushed data of all segments into the dir.
+	// analyzerOf tells the analyzers of the index rules, which restore the indexed terms of the series.
+	Archive(dir string, analyzerOf func(indexRuleID uint32) databasev1.IndexRule_Analyzer) (ArchiveStats, error)
+	// Import writes the series archived in the dir, and registers the archived segments the database doesn't have.
+	Import(dir string) (ArchiveStats, error)
+	// ArchiveShard archives the series and the flushed data of the segments of a shard overlapping the time range like Archive.
+	// The tables which are RangeArchivers only archive the parts overlapping the time range.
+	ArchiveShard(dir string, shardID common.ShardID, timeRange timestamp.TimeRange,
+		analyzerOf func(indexRuleID uint32) databasev1.IndexRule_Analyzer) (ArchiveStats, error)
+	// Merge imports the archive in the dir like Import, and imports the archived parts of the segments the database has
+	// into their tables if the tables are PartImporters.
+	Merge(dir string) (ArchiveStats, error)
 }
 
 // TSTable is time series table.
@@ -83,6 +105,68 @@ type TSTable interface {
 	io.Closer
 }
 
+// Archiver is implemented by the tables whose data are archived by the snapshots.
+type Archiver interface {
+	// Archive links the flushed parts into the dir, where the table is opened later.
+	// It returns the number of the parts and their size in bytes.
+	Archive(dir string) (parts int, size uint64, err error)
+}
+
+// RangeArchiver is implemented by the Archivers archiving the parts overlapping a time range.
+type RangeArchiver interface {
+	// ArchiveRange links the flushed parts overlapping the time range into the dir like Archive.
+	ArchiveRange(dir string, timeRange timestamp.TimeRange) (parts int, size uint64, err error)
+}
+
+// PartImporter is implemented by the tables importing the parts archived by the same kind of tables on other nodes.
+type PartImporter interface {
+	// ImportParts links the parts archived in the dir into the table as new parts.
+	// It returns the number of the parts and their size in bytes.
+	ImportParts(dir string) (parts int, size uint64, err error)
+}
+
+// Offloader is implemented by the tables offloading their data out of the segment directory.
+type Offloader interface {
+	// RemoveOffloaded removes the offloaded data once the table is closed, before the segment directory is removed.
+	RemoveOffloaded()
+}
+
+// ArchiveStats sums up the segments a database archives or imports.
+type ArchiveStats struct {
+	// SkippedSuffixes are the suffixes of the segments skipped by an import
+	SkippedSuffixes []string
+	Segments        int
+	Parts           int
+	Size            uint64
+	SkippedSegments int
+}
+
+// ToArchive converts the stats of a group to the archive reported by the snapshots.
+func (as ArchiveStats) ToArchive(group string) *databasev1.SnapshotArchive {
+	return &databasev1.SnapshotArchive{
+		Group:                  group,
+		Segments:               uint32(as.Segments),
+		Parts:                  uint32(as.Parts),
+		SizeBytes:              as.Size,
+		SkippedSegments:        uint32(as.SkippedSegments),
+		SkippedSegmentSuffixes: as.SkippedSuffixes,
+	}
+}
+
+// SegmentSpan returns the time range of the segment named by the suffix in a group of the segment interval.
+func SegmentSpan(suffix string, segmentInterval *commonv1.IntervalRule) (timestamp.TimeRange, error) {
+	ir := MustToIntervalRule(segmentInterval)
+	format := hourFormat
+	if ir.Unit == DAY {
+		format = dayFormat
+	}
+	start, err := time.ParseInLocation(format, suffix, time.Local)
+	if err != nil {
+		return timestamp.TimeRange{}, errors.WithMessagef(err, "invalid segment suffix %s", suffix)
+	}
+	return timestamp.NewSectionTimeRange(start, ir.nextTime(start)), nil
+}
+
 // TSTableWrapper is a wrapper of TSTable.
 // It is used to manage the reference count of TSTable.
 type TSTableWrapper[T TSTable] interface {
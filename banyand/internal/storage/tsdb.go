@@ -20,6 +20,7 @@ package storage
 import (
 	"context"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -50,13 +51,13 @@ const (
 
 // TSDBOpts wraps options to create a tsdb.
 type TSDBOpts[T TSTable, O any] struct {
-	Option                         O
-	TSTableCreator                 TSTableCreator[T, O]
-	Location                       string
-	SegmentInterval                IntervalRule
-	TTL                            IntervalRule
-	ShardNum                       uint32
-	SeriesIndexFlushTimeoutSeconds int64
+	Option          O
+	TSTableCreator  TSTableCreator[T, O]
+	Location        string
+	SegmentInterval IntervalRule
+	TTL             IntervalRule
+	SeriesIndex     SeriesIndexOpts
+	ShardNum        uint32
 }
 
 type (
@@ -104,7 +105,7 @@ func OpenTSDB[T TSTable, O any](ctx context.Context, opts TSDBOpts[T, O]) (TSDB[
 	p := common.GetPosition(ctx)
 	location := filepath.Clean(opts.Location)
 	lfs.MkdirIfNotExist(location, dirPerm)
-	si, err := newSeriesIndex(ctx, location, opts.SeriesIndexFlushTimeoutSeconds)
+	si, err := newSeriesIndex(ctx, location, opts.SeriesIndex)
 	if err != nil {
 		return nil, errors.Wrap(errOpenDatabase, errors.WithMessage(err, "create series index failed").Error())
 	}
@@ -159,16 +160,29 @@ func (d *database[T, O]) createTSTTable(shardID common.ShardID, ts time.Time) (T
 	return d.sLst[shardID].segmentController.createTSTable(ts)
 }
 
-func (d *database[T, O]) SelectTSTables(timeRange timestamp.TimeRange) []TSTableWrapper[T] {
+func (d *database[T, O]) SelectTSTables(timeRange timestamp.TimeRange, shardIDs ...common.ShardID) []TSTableWrapper[T] {
 	var result []TSTableWrapper[T]
 	d.RLock()
 	for i := range d.sLst {
+		if len(shardIDs) > 0 && !slices.Contains(shardIDs, d.sLst[i].id) {
+			continue
+		}
 		result = append(result, d.sLst[i].segmentController.selectTSTables(timeRange)...)
 	}
 	d.RUnlock()
 	return result
 }
 
+func (d *database[T, O]) DropSegments(deadline time.Time) int {
+	var dropped int
+	d.RLock()
+	for i := range d.sLst {
+		dropped += d.sLst[i].segmentController.dropBefore(deadline)
+	}
+	d.RUnlock()
+	return dropped
+}
+
 func (d *database[T, O]) registerShard(id int) error {
 	ctx := context.WithValue(context.Background(), logger.ContextKey, d.logger)
 	ctx = common.SetPosition(ctx, func(p common.Position) common.Position {
@@ -0,0 +1,145 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package wal
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// NewReader returns a Reader reading the records after the offset.
+func (w *Log) NewReader(after uint64) (*Reader, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return nil, ErrClosed
+	}
+	if first := w.segments[0].first; after+1 < first {
+		return nil, errors.WithMessagef(ErrOffsetRetained, "offset %d, the first retained one is %d", after, first)
+	}
+	if last := w.segments[len(w.segments)-1].last; after > last {
+		return nil, errors.WithMessagef(ErrOffsetAhead, "offset %d, the last one is %d", after, last)
+	}
+	return &Reader{w: w, next: after + 1}, nil
+}
+
+// Replay calls fn with the records after the offset, up to the last one.
+func (w *Log) Replay(after uint64, fn func(offset uint64, payload []byte) error) error {
+	r, err := w.NewReader(after)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	last := w.LastOffset()
+	ctx := context.Background()
+	for r.next <= last {
+		offset, payload, errRead := r.Read(ctx)
+		if errRead != nil {
+			return errRead
+		}
+		if err = fn(offset, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reader tails the records of a log in order.
+type Reader struct {
+	w    *Log
+	file *os.File
+	// first is the first offset of the segment being read
+	first uint64
+	next  uint64
+	pos   int64
+}
+
+// Read returns the next record, and waits for it to be appended if it's not there yet.
+func (r *Reader) Read(ctx context.Context) (uint64, []byte, error) {
+	for {
+		r.w.mu.RLock()
+		if r.w.closed {
+			r.w.mu.RUnlock()
+			return 0, nil, ErrClosed
+		}
+		s := r.w.locate(r.next)
+		notify := r.w.notify
+		var size int64
+		if s != nil {
+			size = s.size
+		}
+		last := r.w.segments[len(r.w.segments)-1].last
+		r.w.mu.RUnlock()
+		if s == nil {
+			if r.next <= last {
+				return 0, nil, errors.WithMessagef(ErrOffsetRetained, "offset %d", r.next-1)
+			}
+			select {
+			case <-ctx.Done():
+				return 0, nil, ctx.Err()
+			case <-notify:
+			}
+			continue
+		}
+		if r.file == nil || r.first != s.first {
+			if err := r.open(s); err != nil {
+				return 0, nil, err
+			}
+		}
+		if r.pos >= size {
+			select {
+			case <-ctx.Done():
+				return 0, nil, ctx.Err()
+			case <-notify:
+			}
+			continue
+		}
+		offset, payload, n, err := readRecord(r.file, r.pos, size)
+		if err != nil {
+			return 0, nil, err
+		}
+		r.pos += n
+		if offset < r.next {
+			continue
+		}
+		r.next = offset + 1
+		return offset, payload, nil
+	}
+}
+
+func (r *Reader) open(s *segment) error {
+	r.Close()
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	r.file = f
+	r.first = s.first
+	r.pos = 0
+	return nil
+}
+
+// Close releases the segment being read.
+func (r *Reader) Close() {
+	if r.file != nil {
+		_ = r.file.Close()
+		r.file = nil
+	}
+}
@@ -0,0 +1,37 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package wal
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Recorder logs the writes of the groups before the storage applies them.
+// The writes not flushed by the storage are replayed after a restart.
+type Recorder interface {
+	// Enabled returns true if the writes are logged.
+	Enabled() bool
+	// Record logs the write of the group, and returns its offset in the log of the group.
+	// The offset should be released once the write is flushed, or once the storage rejects it.
+	// It returns 0 if the write isn't logged.
+	Record(ctx context.Context, topic, group string, write proto.Message) (uint64, error)
+	// Release marks the writes at the offsets of the group done, they aren't replayed any more.
+	Release(group string, offsets []uint64)
+}
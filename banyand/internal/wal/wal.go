@@ -0,0 +1,622 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package wal implements the write-ahead logs cut into segments of checksummed records.
+//
+// The records of a log are numbered by consecutive offsets, and a segment is named by the offset of its first record.
+// The concurrent appends are committed by a single write and sync as a group.
+package wal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+const (
+	segmentSuffix = ".wal"
+	// a record is framed by the checksum and the length of its payload, which are followed by its offset.
+	// The checksum covers the length, the offset and the payload.
+	headerSize = 16
+	// maxBatchRecords bounds the records committed by a single write.
+	maxBatchRecords = 1024
+	dirPermission   = 0o755
+	filePermission  = 0o600
+)
+
+var (
+	// ErrClosed is returned by the operations on a closed log.
+	ErrClosed = errors.New("the write-ahead log is closed")
+	// ErrOffsetMismatch is returned if the offset of a record doesn't follow the last one.
+	ErrOffsetMismatch = errors.New("the offset doesn't follow the last record")
+	// ErrOffsetRetained is returned if the records after the offset have been removed.
+	ErrOffsetRetained = errors.New("the records after the offset have been removed")
+	// ErrOffsetAhead is returned if the offset is ahead of the last record.
+	ErrOffsetAhead = errors.New("the offset is ahead of the last record")
+
+	errChecksum = errors.New("checksum mismatch")
+	errLength   = errors.New("the record exceeds the segment")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// SyncPolicy decides when a log is synced to the disk.
+type SyncPolicy int
+
+const (
+	// SyncInterval syncs the log periodically, a crash of the host loses the records written in the last interval.
+	SyncInterval SyncPolicy = iota
+	// SyncAlways syncs the log before acknowledging the records.
+	SyncAlways
+	// SyncNever leaves the syncing to the operating system, the log only survives the crashes of the process.
+	SyncNever
+)
+
+// ParseSyncPolicy parses the name of a SyncPolicy.
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch s {
+	case "interval":
+		return SyncInterval, nil
+	case "always":
+		return SyncAlways, nil
+	case "never":
+		return SyncNever, nil
+	}
+	return 0, fmt.Errorf("unknown wal sync policy %q, it should be always, interval or never", s)
+}
+
+// Options configures a Log.
+type Options struct {
+	// OnCommit is called with the number of the records committed by a write if it's set.
+	OnCommit func(records int)
+	// OnResize is called with the bytes appended to the log, or removed from it, if it's set.
+	OnResize func(delta int64)
+	// SegmentSize cuts a new segment once the active one reaches the size, the segments are only cut by Rotate if it's 0.
+	SegmentSize int64
+	// MaxSize bounds the size of the closed segments, the oldest ones are removed even if they aren't truncated.
+	// The size is unbounded if it's 0.
+	MaxSize int64
+	// SyncInterval is the interval the log is synced in by SyncInterval.
+	SyncInterval time.Duration
+	Sync         SyncPolicy
+}
+
+// segment is a file holding the records from the first offset.
+type segment struct {
+	// file is only opened for the active segment
+	file  *os.File
+	path  string
+	first uint64
+	// last is the offset of the last record, it's first-1 if the segment is empty
+	last uint64
+	// size only counts the complete records, readers don't read beyond it
+	size int64
+}
+
+type request struct {
+	done    chan error
+	payload []byte
+	// offset is assigned by the log if it's 0
+	offset uint64
+}
+
+// Log is a write-ahead log, whose last segment is active to append.
+type Log struct {
+	l *logger.Logger
+	// syncFile syncs the active segment, it's replaced by the tests failing the syncs
+	syncFile func(f *os.File) error
+	// failed refuses the appends once a failed write can't be truncated, which would leave the failed records behind
+	failed   error
+	requests chan *request
+	stopCh   chan struct{}
+	// notify is closed and replaced once records are committed, which wakes the readers up
+	notify   chan struct{}
+	dir      string
+	segments []*segment
+	opts     Options
+	wg       sync.WaitGroup
+	mu       sync.RWMutex
+	dirty    bool
+	closed   bool
+}
+
+// Open loads the segments of the log in the dir, and opens the last segment to append.
+// A torn record at the tail of the last segment is truncated since it's never acknowledged.
+func Open(dir string, opts Options, l *logger.Logger) (*Log, error) {
+	if err := os.MkdirAll(dir, dirPermission); err != nil {
+		return nil, err
+	}
+	w := &Log{
+		l:        l,
+		syncFile: (*os.File).Sync,
+		dir:      dir,
+		opts:     opts,
+		requests: make(chan *request),
+		stopCh:   make(chan struct{}),
+		notify:   make(chan struct{}),
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var firsts []uint64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), segmentSuffix) {
+			continue
+		}
+		first, errParse := strconv.ParseUint(strings.TrimSuffix(e.Name(), segmentSuffix), 16, 64)
+		if errParse != nil {
+			return nil, errors.WithMessagef(errParse, "invalid segment %s", e.Name())
+		}
+		firsts = append(firsts, first)
+	}
+	sort.Slice(firsts, func(i, j int) bool { return firsts[i] < firsts[j] })
+	var size int64
+	for i, first := range firsts {
+		s, errLoad := w.loadSegment(first, i == len(firsts)-1)
+		if errLoad != nil {
+			return nil, errLoad
+		}
+		if i > 0 && w.segments[i-1].last+1 != first {
+			return nil, errors.Errorf("segment %s doesn't follow the previous one", s.path)
+		}
+		w.segments = append(w.segments, s)
+		size += s.size
+	}
+	if len(w.segments) == 0 {
+		if err = w.createSegment(1); err != nil {
+			return nil, err
+		}
+	} else {
+		active := w.segments[len(w.segments)-1]
+		if active.file, err = os.OpenFile(active.path, os.O_WRONLY|os.O_APPEND, filePermission); err != nil {
+			return nil, err
+		}
+	}
+	w.resized(size)
+	w.wg.Add(1)
+	go w.commitLoop()
+	return w, nil
+}
+
+// loadSegment verifies the records of a segment.
+// A torn record at the tail of the active segment is truncated.
+func (w *Log) loadSegment(first uint64, active bool) (*segment, error) {
+	s := &segment{
+		path:  filepath.Join(w.dir, segmentName(first)),
+		first: first,
+		last:  first - 1,
+	}
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		offset, _, n, errRead := readRecord(f, s.size, info.Size())
+		if errors.Is(errRead, io.EOF) {
+			return s, nil
+		}
+		if errRead == nil && offset != s.last+1 {
+			errRead = errors.WithMessagef(ErrOffsetMismatch, "offset %d, expected %d", offset, s.last+1)
+		}
+		if errRead != nil {
+			if !active {
+				return nil, errors.WithMessagef(errRead, "segment %s is corrupted at %d", s.path, s.size)
+			}
+			w.l.Warn().Err(errRead).Str("segment", s.path).Int64("size", s.size).Msg("truncate the torn tail")
+			if errTruncate := os.Truncate(s.path, s.size); errTruncate != nil {
+				return nil, errTruncate
+			}
+			return s, nil
+		}
+		s.last = offset
+		s.size += n
+	}
+}
+
+func (w *Log) createSegment(first uint64) error {
+	s := &segment{
+		path:  filepath.Join(w.dir, segmentName(first)),
+		first: first,
+		last:  first - 1,
+	}
+	var err error
+	if s.file, err = os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePermission); err != nil {
+		return err
+	}
+	w.segments = append(w.segments, s)
+	return nil
+}
+
+// Append logs the payload at the offset following the last record, which is returned.
+// It returns once the payload is written along with the others appended concurrently,
+// and synced if the policy is SyncAlways.
+func (w *Log) Append(payload []byte) (uint64, error) {
+	req := &request{payload: payload}
+	if err := w.append(req); err != nil {
+		return 0, err
+	}
+	return req.offset, nil
+}
+
+// AppendAt logs the payload at the offset like Append, which should follow the last record.
+func (w *Log) AppendAt(offset uint64, payload []byte) error {
+	if offset == 0 {
+		return errors.WithMessage(ErrOffsetMismatch, "offset 0")
+	}
+	return w.append(&request{offset: offset, payload: payload})
+}
+
+func (w *Log) append(req *request) error {
+	req.done = make(chan error, 1)
+	select {
+	case w.requests <- req:
+	case <-w.stopCh:
+		return ErrClosed
+	}
+	return <-req.done
+}
+
+func (w *Log) commitLoop() {
+	defer w.wg.Done()
+	var tick <-chan time.Time
+	if w.opts.Sync == SyncInterval && w.opts.SyncInterval > 0 {
+		ticker := time.NewTicker(w.opts.SyncInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	var batch []*request
+	var buf []byte
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-tick:
+			w.syncDirty()
+		case req := <-w.requests:
+			batch = append(batch[:0], req)
+			// the appenders arriving during the last commit form the next group
+		drain:
+			for len(batch) < maxBatchRecords {
+				select {
+				case r := <-w.requests:
+					batch = append(batch, r)
+				default:
+					break drain
+				}
+			}
+			buf = w.commit(batch, buf)
+			for i := range batch {
+				batch[i] = nil
+			}
+		}
+	}
+}
+
+// commit writes the records of the batch, cutting a new segment if the active one is full.
+func (w *Log) commit(batch []*request, buf []byte) []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var pending []*request
+	var err error
+	write := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if err == nil {
+			err = w.write(buf, len(pending))
+		}
+		for _, r := range pending {
+			r.done <- err
+		}
+		pending = pending[:0]
+		buf = buf[:0]
+	}
+	buf = buf[:0]
+	for _, r := range batch {
+		if err != nil {
+			r.done <- err
+			continue
+		}
+		active := w.segments[len(w.segments)-1]
+		next := active.last + uint64(len(pending)) + 1
+		if r.offset == 0 {
+			r.offset = next
+		} else if r.offset != next {
+			r.done <- errors.WithMessagef(ErrOffsetMismatch, "offset %d, expected %d", r.offset, next)
+			continue
+		}
+		size := active.size + int64(len(buf))
+		if w.opts.SegmentSize > 0 && size > 0 && size+int64(headerSize+len(r.payload)) > w.opts.SegmentSize {
+			write()
+			if err == nil {
+				err = w.rotate()
+			}
+			if err != nil {
+				r.done <- err
+				continue
+			}
+		}
+		buf = appendRecord(buf, r.offset, r.payload)
+		pending = append(pending, r)
+	}
+	write()
+	return buf
+}
+
+// write appends the records in buf to the active segment.
+// A failed write or sync is truncated, so that no record failed to the appenders is left before the ones appended later.
+func (w *Log) write(buf []byte, records int) error {
+	if w.closed {
+		return ErrClosed
+	}
+	if w.failed != nil {
+		return w.failed
+	}
+	active := w.segments[len(w.segments)-1]
+	if _, err := active.file.Write(buf); err != nil {
+		return w.discard(active, errors.WithMessagef(err, "cannot write wal segment %s", active.path))
+	}
+	if w.opts.Sync == SyncAlways {
+		if err := w.syncFile(active.file); err != nil {
+			return w.discard(active, errors.WithMessagef(err, "cannot sync wal segment %s", active.path))
+		}
+	} else {
+		w.dirty = true
+	}
+	active.size += int64(len(buf))
+	active.last += uint64(records)
+	w.resized(int64(len(buf)))
+	if w.opts.OnCommit != nil {
+		w.opts.OnCommit(records)
+	}
+	close(w.notify)
+	w.notify = make(chan struct{})
+	return nil
+}
+
+// discard truncates the records failed by err from the active segment.
+// The log is failed if they can't be truncated, since their offsets would be reused by the next appends.
+func (w *Log) discard(active *segment, err error) error {
+	if errTruncate := active.file.Truncate(active.size); errTruncate != nil {
+		w.l.Error().Err(errTruncate).Str("segment", active.path).Msg("cannot truncate the failed write, the wal refuses the appends")
+		w.failed = errors.WithMessagef(err, "the wal is failed since segment %s can't be truncated", active.path)
+	}
+	return err
+}
+
+func (w *Log) syncDirty() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.dirty || w.closed {
+		return
+	}
+	active := w.segments[len(w.segments)-1]
+	if err := active.file.Sync(); err != nil {
+		w.l.Warn().Err(err).Str("segment", active.path).Msg("cannot sync the wal segment")
+		return
+	}
+	w.dirty = false
+}
+
+// Rotate closes the active segment if it isn't empty, and returns the offset of the last record of the closed segments.
+func (w *Log) Rotate() (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	active := w.segments[len(w.segments)-1]
+	if active.size == 0 {
+		return active.first - 1, nil
+	}
+	if err := w.rotate(); err != nil {
+		return 0, err
+	}
+	return active.last, nil
+}
+
+func (w *Log) rotate() error {
+	active := w.segments[len(w.segments)-1]
+	if w.opts.Sync != SyncNever {
+		if err := active.file.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := active.file.Close(); err != nil {
+		return err
+	}
+	active.file = nil
+	w.dirty = false
+	if err := w.createSegment(active.last + 1); err != nil {
+		return err
+	}
+	w.retain()
+	return nil
+}
+
+// Truncate removes the closed segments whose records are all at or before the offset.
+func (w *Log) Truncate(offset uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.segments) > 1 && w.segments[0].last <= offset {
+		if !w.removeOldest() {
+			return
+		}
+	}
+}
+
+// retain removes the oldest closed segments if they exceed the max size.
+func (w *Log) retain() {
+	if w.opts.MaxSize <= 0 {
+		return
+	}
+	var total int64
+	for _, s := range w.segments[:len(w.segments)-1] {
+		total += s.size
+	}
+	for len(w.segments) > 1 && total > w.opts.MaxSize {
+		s := w.segments[0]
+		w.l.Warn().Str("segment", s.path).Int64("max_size", w.opts.MaxSize).Msg("remove the segment not truncated yet since the wal is full")
+		if !w.removeOldest() {
+			return
+		}
+		total -= s.size
+	}
+}
+
+func (w *Log) removeOldest() bool {
+	s := w.segments[0]
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		w.l.Error().Err(err).Str("segment", s.path).Msg("failed to remove the wal segment")
+		return false
+	}
+	w.segments = w.segments[1:]
+	w.resized(-s.size)
+	return true
+}
+
+func (w *Log) resized(delta int64) {
+	if w.opts.OnResize != nil && delta != 0 {
+		w.opts.OnResize(delta)
+	}
+}
+
+// FirstOffset returns the offset of the first retained record, it's LastOffset()+1 if there is none.
+func (w *Log) FirstOffset() uint64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.segments[0].first
+}
+
+// LastOffset returns the offset of the last record, it's 0 if nothing is ever appended.
+func (w *Log) LastOffset() uint64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.segments[len(w.segments)-1].last
+}
+
+// Close stops the appends, and syncs the records written.
+func (w *Log) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	// the batch committed concurrently fails with ErrClosed
+	w.closed = true
+	w.mu.Unlock()
+	close(w.stopCh)
+	w.wg.Wait()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	close(w.notify)
+	var size int64
+	for _, s := range w.segments {
+		size += s.size
+	}
+	w.resized(-size)
+	active := w.segments[len(w.segments)-1]
+	if w.opts.Sync != SyncNever && w.dirty {
+		if err := active.file.Sync(); err != nil {
+			_ = active.file.Close()
+			return err
+		}
+	}
+	return active.file.Close()
+}
+
+// locate returns the segment holding the offset, or nil if the offset isn't written yet.
+func (w *Log) locate(offset uint64) *segment {
+	i := sort.Search(len(w.segments), func(i int) bool {
+		return w.segments[i].first > offset
+	})
+	if i == 0 {
+		return nil
+	}
+	s := w.segments[i-1]
+	if offset > s.last {
+		return nil
+	}
+	return s
+}
+
+func appendRecord(dst []byte, offset uint64, payload []byte) []byte {
+	start := len(dst)
+	dst = append(dst, make([]byte, headerSize)...)
+	binary.BigEndian.PutUint32(dst[start+4:], uint32(len(payload)))
+	binary.BigEndian.PutUint64(dst[start+8:], offset)
+	dst = append(dst, payload...)
+	binary.BigEndian.PutUint32(dst[start:], crc32.Checksum(dst[start+4:], crcTable))
+	return dst
+}
+
+// readRecord returns the offset and the payload of the record at pos, and the size of the record.
+// The record should end at or before end, it returns io.EOF if pos is end.
+func readRecord(f *os.File, pos, end int64) (uint64, []byte, int64, error) {
+	if pos == end {
+		return 0, nil, 0, io.EOF
+	}
+	if end-pos < headerSize {
+		return 0, nil, 0, io.ErrUnexpectedEOF
+	}
+	header := make([]byte, headerSize)
+	if _, err := f.ReadAt(header, pos); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, nil, 0, io.ErrUnexpectedEOF
+		}
+		return 0, nil, 0, err
+	}
+	// the length is checked before allocating the payload, since it isn't verified by the checksum yet
+	length := int64(binary.BigEndian.Uint32(header[4:]))
+	if length > end-pos-headerSize {
+		return 0, nil, 0, errors.WithMessagef(errLength, "length %d, %d bytes left", length, end-pos-headerSize)
+	}
+	record := make([]byte, headerSize+length)
+	copy(record, header)
+	if _, err := f.ReadAt(record[headerSize:], pos+headerSize); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, nil, 0, io.ErrUnexpectedEOF
+		}
+		return 0, nil, 0, err
+	}
+	if crc32.Checksum(record[4:], crcTable) != binary.BigEndian.Uint32(record) {
+		return 0, nil, 0, errChecksum
+	}
+	return binary.BigEndian.Uint64(record[8:]), record[headerSize:], int64(len(record)), nil
+}
+
+func segmentName(first uint64) string {
+	return fmt.Sprintf("%016x%s", first, segmentSuffix)
+}
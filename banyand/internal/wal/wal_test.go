@@ -0,0 +1,290 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package wal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+// every segment of segmentSize holds two records of 8 bytes.
+const segmentSize = 2 * (headerSize + 8)
+
+func record(i int) []byte {
+	return []byte(fmt.Sprintf("record%02d", i))
+}
+
+func replayAll(t *testing.T, w *Log, after uint64) map[uint64]string {
+	replayed := make(map[uint64]string)
+	require.NoError(t, w.Replay(after, func(offset uint64, payload []byte) error {
+		replayed[offset] = string(payload)
+		return nil
+	}))
+	return replayed
+}
+
+func TestLog(t *testing.T) {
+	dir := t.TempDir()
+	l := logger.GetLogger("test")
+	w, err := Open(dir, Options{SegmentSize: segmentSize}, l)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), w.LastOffset())
+	for i := 1; i <= 5; i++ {
+		offset, errAppend := w.Append(record(i))
+		require.NoError(t, errAppend)
+		assert.Equal(t, uint64(i), offset)
+	}
+	assert.Len(t, w.segments, 3)
+	assert.Equal(t, map[uint64]string{2: "record02", 3: "record03", 4: "record04", 5: "record05"}, replayAll(t, w, 1))
+
+	require.ErrorIs(t, w.AppendAt(7, record(7)), ErrOffsetMismatch)
+	require.NoError(t, w.AppendAt(6, record(6)))
+
+	// the segments truncated are removed
+	w.Truncate(3)
+	assert.Len(t, w.segments, 2)
+	assert.Equal(t, uint64(3), w.FirstOffset())
+	_, err = w.NewReader(1)
+	require.ErrorIs(t, err, ErrOffsetRetained)
+	_, err = w.NewReader(7)
+	require.ErrorIs(t, err, ErrOffsetAhead)
+	require.NoError(t, w.Close())
+	_, err = w.Append(record(7))
+	require.ErrorIs(t, err, ErrClosed)
+
+	w, err = Open(dir, Options{SegmentSize: segmentSize}, l)
+	require.NoError(t, err)
+	defer w.Close()
+	assert.Equal(t, uint64(6), w.LastOffset())
+	assert.Equal(t, map[uint64]string{5: "record05", 6: "record06"}, replayAll(t, w, 4))
+}
+
+func TestLogGroupCommit(t *testing.T) {
+	var batches, records atomic.Int64
+	var size atomic.Int64
+	w, err := Open(t.TempDir(), Options{
+		Sync:     SyncAlways,
+		OnCommit: func(n int) { batches.Add(1); records.Add(int64(n)) },
+		OnResize: func(delta int64) { size.Add(delta) },
+	}, logger.GetLogger("test"))
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errAppend := w.Append(record(i))
+			assert.NoError(t, errAppend)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int64(100), records.Load())
+	assert.LessOrEqual(t, batches.Load(), int64(100))
+	assert.Equal(t, int64(100*(headerSize+8)), size.Load())
+	replayed := replayAll(t, w, 0)
+	assert.Len(t, replayed, 100)
+
+	// the segments are only cut by rotating without the segment size
+	last, err := w.Rotate()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), last)
+	last, err = w.Rotate()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), last, "the empty active segment isn't closed")
+	_, err = w.Append(record(0))
+	require.NoError(t, err)
+	w.Truncate(last)
+	assert.Equal(t, int64(headerSize+8), size.Load())
+	require.NoError(t, w.Close())
+	assert.Equal(t, int64(0), size.Load())
+}
+
+func TestLogTail(t *testing.T) {
+	w, err := Open(t.TempDir(), Options{SegmentSize: segmentSize}, logger.GetLogger("test"))
+	require.NoError(t, err)
+	defer w.Close()
+	r, err := w.NewReader(0)
+	require.NoError(t, err)
+	defer r.Close()
+	received := make(chan uint64)
+	go func() {
+		for {
+			offset, _, errRead := r.Read(context.Background())
+			if errRead != nil {
+				close(received)
+				return
+			}
+			received <- offset
+		}
+	}()
+	// the reader follows the records across segments
+	for i := 1; i <= 5; i++ {
+		_, err = w.Append(record(i))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(i), <-received)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r2, err := w.NewReader(5)
+	require.NoError(t, err)
+	defer r2.Close()
+	_, _, err = r2.Read(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLogMaxSize(t *testing.T) {
+	w, err := Open(t.TempDir(), Options{SegmentSize: segmentSize, MaxSize: segmentSize}, logger.GetLogger("test"))
+	require.NoError(t, err)
+	defer w.Close()
+	for i := 1; i <= 6; i++ {
+		_, err = w.Append(record(i))
+		require.NoError(t, err)
+	}
+	// the size is checked on rotating, so the active segment isn't counted
+	assert.Len(t, w.segments, 2)
+	assert.Equal(t, uint64(3), w.FirstOffset())
+}
+
+func TestLogCorruption(t *testing.T) {
+	dir := t.TempDir()
+	l := logger.GetLogger("test")
+	w, err := Open(dir, Options{SegmentSize: segmentSize}, l)
+	require.NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		_, err = w.Append(record(i))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	// a crash tears the record being written, which is dropped from the tail of the active segment
+	active := filepath.Join(dir, segmentName(3))
+	f, err := os.OpenFile(active, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = f.Write(appendRecord(nil, 4, record(4))[:10])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	w, err = Open(dir, Options{SegmentSize: segmentSize}, l)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), w.LastOffset())
+	offset, err := w.Append(record(4))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), offset)
+	require.NoError(t, w.Close())
+
+	// a corrupted record in an inactive segment fails the opening
+	inactive := filepath.Join(dir, segmentName(1))
+	content, err := os.ReadFile(inactive)
+	require.NoError(t, err)
+	content[len(content)-1]++
+	require.NoError(t, os.WriteFile(inactive, content, 0o600))
+	_, err = Open(dir, Options{SegmentSize: segmentSize}, l)
+	require.ErrorIs(t, err, errChecksum)
+}
+
+func TestLogCorruptedLength(t *testing.T) {
+	dir := t.TempDir()
+	l := logger.GetLogger("test")
+	w, err := Open(dir, Options{}, l)
+	require.NoError(t, err)
+	for i := 1; i <= 2; i++ {
+		_, err = w.Append(record(i))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	// the length of the last record is corrupted, which is rejected before reading the payload
+	path := filepath.Join(dir, segmentName(1))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	last := content[headerSize+8:]
+	last[4] = 0xff
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	_, _, _, err = readRecord(f, headerSize+8, int64(len(content)))
+	require.ErrorIs(t, err, errLength)
+	require.NoError(t, f.Close())
+
+	// the shorter length is caught by the checksum
+	last[4] = 0
+	last[7] = 4
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+	f, err = os.Open(path)
+	require.NoError(t, err)
+	_, _, _, err = readRecord(f, headerSize+8, int64(len(content)))
+	require.ErrorIs(t, err, errChecksum)
+	require.NoError(t, f.Close())
+
+	// the corrupted tail of the active segment is truncated
+	w, err = Open(dir, Options{}, l)
+	require.NoError(t, err)
+	defer w.Close()
+	assert.Equal(t, uint64(1), w.LastOffset())
+	assert.Equal(t, map[uint64]string{1: "record01"}, replayAll(t, w, 0))
+}
+
+func TestLogSyncFailure(t *testing.T) {
+	dir := t.TempDir()
+	l := logger.GetLogger("test")
+	w, err := Open(dir, Options{Sync: SyncAlways}, l)
+	require.NoError(t, err)
+	_, err = w.Append(record(1))
+	require.NoError(t, err)
+	errSync := errors.New("sync failure")
+	w.mu.Lock()
+	w.syncFile = func(*os.File) error { return errSync }
+	w.mu.Unlock()
+	_, err = w.Append(record(2))
+	require.ErrorIs(t, err, errSync)
+
+	// the failed record is truncated, whose offset is taken by the next one
+	w.mu.Lock()
+	w.syncFile = (*os.File).Sync
+	w.mu.Unlock()
+	offset, err := w.Append(record(3))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), offset)
+	require.NoError(t, w.Close())
+	w, err = Open(dir, Options{}, l)
+	require.NoError(t, err)
+	defer w.Close()
+	assert.Equal(t, map[uint64]string{1: "record01", 2: "record03"}, replayAll(t, w, 0))
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	for name, want := range map[string]SyncPolicy{"always": SyncAlways, "interval": SyncInterval, "never": SyncNever} {
+		got, err := ParseSyncPolicy(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseSyncPolicy("sometimes")
+	require.Error(t, err)
+}
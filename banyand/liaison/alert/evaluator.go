@@ -0,0 +1,276 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package alert implements the evaluator of the alert rules, which queries the measures of the rules periodically
+// and emits the events once the series start or stop firing. The series firing are tracked in the memory,
+// so they are evaluated from scratch once the evaluator restarts.
+package alert
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
+	"github.com/apache/skywalking-banyandb/pkg/client"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+var (
+	_ run.Config    = (*evaluator)(nil)
+	_ run.PreRunner = (*evaluator)(nil)
+	_ run.Service   = (*evaluator)(nil)
+
+	errInvalidTimeout = errors.New("alert: the webhook timeout should be positive")
+)
+
+// NewEvaluator returns the evaluator of the alert rules, which is disabled unless the flag is set.
+func NewEvaluator(metadataRepo metadata.Repo) run.Unit {
+	return &evaluator{
+		metadata: metadataRepo,
+		rules:    make(map[string]*ruleRunner),
+		closer:   run.NewCloser(0),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+type evaluator struct {
+	clientCreds    credentials.TransportCredentials
+	metadata       metadata.Repo
+	l              *logger.Logger
+	c              *client.Client
+	httpClient     *http.Client
+	rules          map[string]*ruleRunner
+	closer         *run.Closer
+	stopCh         chan struct{}
+	grpcAddr       string
+	grpcCert       string
+	grpcToken      string
+	webhookTimeout time.Duration
+	connMux        sync.Mutex
+	mux            sync.Mutex
+	enabled        bool
+}
+
+func (e *evaluator) FlagSet() *run.FlagSet {
+	fs := run.NewFlagSet("alert")
+	fs.BoolVar(&e.enabled, "alert-enabled", false, "evaluate the alert rules, which is supposed to be enabled on a single liaison of a cluster "+
+		"since every evaluator emits the events on its own")
+	fs.DurationVar(&e.webhookTimeout, "alert-webhook-timeout", 5*time.Second, "the timeout of posting an event to the webhook")
+	fs.StringVar(&e.grpcAddr, "alert-banyand-addr", "localhost:17912", "the address of the gRPC server the alert rules are evaluated against")
+	fs.StringVar(&e.grpcCert, "alert-banyand-cert-file", "", "the TLS cert file of the gRPC server the alert rules are evaluated against if it enables TLS")
+	fs.StringVar(&e.grpcToken, "alert-banyand-token", "",
+		"the bearer token of the gRPC server the alert rules are evaluated against if it enables the authentication")
+	return fs
+}
+
+func (e *evaluator) Validate() error {
+	if !e.enabled {
+		return nil
+	}
+	if e.webhookTimeout <= 0 {
+		return errInvalidTimeout
+	}
+	if e.grpcCert != "" {
+		var err error
+		if e.clientCreds, err = credentials.NewClientTLSFromFile(e.grpcCert, ""); err != nil {
+			return errors.Wrap(err, "failed to load the grpc cert")
+		}
+	}
+	return nil
+}
+
+func (e *evaluator) Name() string {
+	return "liaison-alert"
+}
+
+func (e *evaluator) Role() databasev1.Role {
+	return databasev1.Role_ROLE_LIAISON
+}
+
+func (e *evaluator) PreRun(_ context.Context) error {
+	e.l = logger.GetLogger(e.Name())
+	if !e.enabled {
+		return nil
+	}
+	e.httpClient = &http.Client{Timeout: e.webhookTimeout}
+	e.metadata.RegisterHandler("alert", schema.KindAlertRule, e)
+	return nil
+}
+
+func (e *evaluator) Serve() run.StopNotify {
+	return e.stopCh
+}
+
+func (e *evaluator) GracefulStop() {
+	e.closer.CloseThenWait()
+	e.connMux.Lock()
+	if e.c != nil {
+		_ = e.c.Close()
+	}
+	e.connMux.Unlock()
+	close(e.stopCh)
+}
+
+// OnAddOrUpdate restarts the evaluation of the rule, which keeps the series firing.
+func (e *evaluator) OnAddOrUpdate(md schema.Metadata) {
+	rule, ok := md.Spec.(*databasev1.AlertRule)
+	if !ok {
+		return
+	}
+	key := ruleKey(rule.GetMetadata())
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	firing := make(map[string]series)
+	if prev, exist := e.rules[key]; exist {
+		if prev.rule.GetMetadata().GetModRevision() == rule.GetMetadata().GetModRevision() {
+			return
+		}
+		firing = prev.stop()
+	}
+	if !e.closer.AddRunning() {
+		return
+	}
+	ctx, cancel := context.WithCancel(e.closer.Ctx())
+	r := &ruleRunner{
+		e:      e,
+		rule:   rule,
+		firing: firing,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	e.rules[key] = r
+	go func() {
+		defer e.closer.Done()
+		r.run(ctx)
+	}()
+}
+
+// OnDelete stops the evaluation of the rule without resolving the series firing.
+func (e *evaluator) OnDelete(md schema.Metadata) {
+	if md.Kind != schema.KindAlertRule {
+		return
+	}
+	key := ruleKey(&commonv1.Metadata{Group: md.Group, Name: md.Name})
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if r, ok := e.rules[key]; ok {
+		r.stop()
+		delete(e.rules, key)
+	}
+}
+
+func (e *evaluator) client() (*client.Client, error) {
+	e.connMux.Lock()
+	defer e.connMux.Unlock()
+	if e.c != nil {
+		return e.c, nil
+	}
+	var opts []client.Option
+	if e.clientCreds != nil {
+		opts = append(opts, client.WithDialOptions(grpclib.WithTransportCredentials(e.clientCreds)))
+	}
+	if e.grpcToken != "" {
+		opts = append(opts, client.WithToken(e.grpcToken))
+	}
+	var err error
+	e.c, err = client.Connect(e.grpcAddr, opts...)
+	return e.c, err
+}
+
+func ruleKey(md *commonv1.Metadata) string {
+	return path.Join(md.GetGroup(), md.GetName())
+}
+
+type ruleRunner struct {
+	e      *evaluator
+	rule   *databasev1.AlertRule
+	firing map[string]series
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// stop stops the evaluation and returns the series firing.
+func (r *ruleRunner) stop() map[string]series {
+	r.cancel()
+	<-r.done
+	return r.firing
+}
+
+func (r *ruleRunner) run(ctx context.Context) {
+	defer close(r.done)
+	l := r.e.l.With().Str("group", r.rule.GetMetadata().GetGroup()).Str("rule", r.rule.GetMetadata().GetName()).Logger()
+	interval := time.Minute
+	for {
+		cr, err := r.evaluate(ctx, time.Now())
+		if cr != nil {
+			interval = cr.interval
+		}
+		if err != nil && ctx.Err() == nil {
+			l.Warn().Err(err).Msg("failed to evaluate the alert rule")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// evaluate evaluates the rule at now, then emits the events of the series which start or stop firing.
+// The series whose events fail to be emitted are evaluated again in the next round.
+// The compiled rule is returned once the rule is valid against its measure.
+func (r *ruleRunner) evaluate(ctx context.Context, now time.Time) (*compiledRule, error) {
+	measure, err := r.e.metadata.MeasureRegistry().GetMeasure(ctx, &commonv1.Metadata{
+		Group: r.rule.GetMetadata().GetGroup(),
+		Name:  r.rule.GetMeasure(),
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to get the measure %s", r.rule.GetMeasure())
+	}
+	cr, err := compile(r.rule, measure)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.e.client()
+	if err != nil {
+		return cr, err
+	}
+	dataPoints, err := c.QueryMeasure(ctx, cr.query(now))
+	if err != nil {
+		return cr, err
+	}
+	var errs error
+	for _, event := range cr.transit(r.firing, cr.values(dataPoints), now) {
+		if errEmit := r.e.emit(ctx, r.rule, event); errEmit != nil {
+			revert(r.firing, event)
+			errs = multierr.Append(errs, errEmit)
+		}
+	}
+	return cr, errs
+}
@@ -0,0 +1,279 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package alert
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/client"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+// pageSize is the number of the data points fetched at once while evaluating a rule.
+const pageSize = 1000
+
+// compiledRule is a rule resolved against the schema of its measure.
+type compiledRule struct {
+	rule *databasev1.AlertRule
+	// families are the tag families of the group_by_tag_names in order
+	families []string
+	interval time.Duration
+	window   time.Duration
+}
+
+func compile(rule *databasev1.AlertRule, measure *databasev1.Measure) (*compiledRule, error) {
+	interval, err := timestamp.ParseDuration(rule.GetInterval())
+	if err != nil || interval <= 0 {
+		return nil, errors.Errorf("invalid interval %q", rule.GetInterval())
+	}
+	window := interval
+	if rule.GetWindow() != "" {
+		if window, err = timestamp.ParseDuration(rule.GetWindow()); err != nil || window <= 0 {
+			return nil, errors.Errorf("invalid window %q", rule.GetWindow())
+		}
+	}
+	var field *databasev1.FieldSpec
+	for _, f := range measure.GetFields() {
+		if f.GetName() == rule.GetFieldName() {
+			field = f
+			break
+		}
+	}
+	if field == nil {
+		return nil, errors.Errorf("the field %s isn't found in the measure %s", rule.GetFieldName(), measure.GetMetadata().GetName())
+	}
+	if field.GetFieldType() != databasev1.FieldType_FIELD_TYPE_INT && field.GetFieldType() != databasev1.FieldType_FIELD_TYPE_FLOAT {
+		return nil, errors.Errorf("the field %s isn't numeric", rule.GetFieldName())
+	}
+	families := make([]string, 0, len(rule.GetGroupByTagNames()))
+	for _, tag := range rule.GetGroupByTagNames() {
+		family := tagFamily(measure, tag)
+		if family == "" {
+			return nil, errors.Errorf("the tag %s isn't found in the measure %s", tag, measure.GetMetadata().GetName())
+		}
+		families = append(families, family)
+	}
+	return &compiledRule{
+		rule:     rule,
+		families: families,
+		interval: interval,
+		window:   window,
+	}, nil
+}
+
+func tagFamily(measure *databasev1.Measure, tag string) string {
+	for _, tf := range measure.GetTagFamilies() {
+		for _, t := range tf.GetTags() {
+			if t.GetName() == tag {
+				return tf.GetName()
+			}
+		}
+	}
+	return ""
+}
+
+// query returns the query of the data points in the window ending at now.
+// The values of the series are aggregated by the server unless the rule takes the latest values,
+// in which case the data points are sorted from the latest.
+func (cr *compiledRule) query(now time.Time) *client.MeasureQuery {
+	rule := cr.rule
+	q := client.NewMeasureQuery(rule.GetMetadata().GetGroup(), rule.GetMeasure()).
+		TimeRange(now.Add(-cr.window), now).
+		Where(client.Expr(rule.GetCriteria())).
+		Fields(rule.GetFieldName()).
+		PageSize(pageSize)
+	for i, tag := range rule.GetGroupByTagNames() {
+		q.Project(cr.families[i], tag)
+	}
+	if rule.GetAggregation() == modelv1.AggregationFunction_AGGREGATION_FUNCTION_UNSPECIFIED {
+		return q.OrderBy("", modelv1.Sort_SORT_DESC)
+	}
+	for i, tag := range rule.GetGroupByTagNames() {
+		q.GroupBy(rule.GetFieldName(), cr.families[i], tag)
+	}
+	return q.Aggregate(rule.GetAggregation(), rule.GetFieldName())
+}
+
+// series is the value of a series evaluated.
+type series struct {
+	tags  []*modelv1.Tag
+	value float64
+}
+
+// values returns the value of every series in the data points, keyed by the values of the group_by_tag_names.
+// The first data point of a series is taken, which is the latest or the aggregated one.
+func (cr *compiledRule) values(dataPoints []*measurev1.DataPoint) map[string]series {
+	result := make(map[string]series)
+	for _, dp := range dataPoints {
+		v, ok := fieldValue(dp, cr.rule.GetFieldName())
+		if !ok {
+			continue
+		}
+		tags := make([]*modelv1.Tag, 0, len(cr.rule.GetGroupByTagNames()))
+		for _, name := range cr.rule.GetGroupByTagNames() {
+			tags = append(tags, &modelv1.Tag{Key: name, Value: tagValue(dp, name)})
+		}
+		key := seriesKey(tags)
+		if _, ok = result[key]; ok {
+			continue
+		}
+		result[key] = series{tags: tags, value: v}
+	}
+	return result
+}
+
+// transit compares the values with the threshold, and returns the events of the series which start or stop firing.
+// firing tracks the series firing, which is updated by the events.
+func (cr *compiledRule) transit(firing map[string]series, values map[string]series, now time.Time) []*databasev1.AlertEvent {
+	rule := cr.rule
+	var events []*databasev1.AlertEvent
+	for key, s := range values {
+		_, fired := firing[key]
+		if compare(rule.GetComparison(), s.value, rule.GetThreshold()) {
+			if !fired {
+				firing[key] = s
+				events = append(events, cr.event(databasev1.AlertEvent_STATE_FIRING, s, now))
+			}
+			continue
+		}
+		if fired {
+			delete(firing, key)
+			events = append(events, cr.event(databasev1.AlertEvent_STATE_RESOLVED, s, now))
+		}
+	}
+	// the series without any data point in the window are resolved, whose value is the last one firing
+	for key, s := range firing {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		delete(firing, key)
+		events = append(events, cr.event(databasev1.AlertEvent_STATE_RESOLVED, s, now))
+	}
+	return events
+}
+
+// revert reverts the transition of the event, so that the series transits again in the next round.
+func revert(firing map[string]series, event *databasev1.AlertEvent) {
+	key := seriesKey(event.GetTags())
+	if event.GetState() == databasev1.AlertEvent_STATE_FIRING {
+		delete(firing, key)
+		return
+	}
+	firing[key] = series{tags: event.GetTags(), value: event.GetValue()}
+}
+
+func (cr *compiledRule) event(state databasev1.AlertEvent_State, s series, now time.Time) *databasev1.AlertEvent {
+	return &databasev1.AlertEvent{
+		Rule: &commonv1.Metadata{
+			Group: cr.rule.GetMetadata().GetGroup(),
+			Name:  cr.rule.GetMetadata().GetName(),
+		},
+		State:     state,
+		Tags:      s.tags,
+		Value:     s.value,
+		Threshold: cr.rule.GetThreshold(),
+		Time:      timestamppb.New(now),
+	}
+}
+
+func compare(comparison databasev1.AlertRule_Comparison, value, threshold float64) bool {
+	switch comparison {
+	case databasev1.AlertRule_COMPARISON_GT:
+		return value > threshold
+	case databasev1.AlertRule_COMPARISON_GE:
+		return value >= threshold
+	case databasev1.AlertRule_COMPARISON_LT:
+		return value < threshold
+	case databasev1.AlertRule_COMPARISON_LE:
+		return value <= threshold
+	case databasev1.AlertRule_COMPARISON_EQ:
+		return value == threshold
+	case databasev1.AlertRule_COMPARISON_NE:
+		return value != threshold
+	default:
+		return false
+	}
+}
+
+func fieldValue(dp *measurev1.DataPoint, name string) (float64, bool) {
+	for _, f := range dp.GetFields() {
+		if f.GetName() != name {
+			continue
+		}
+		switch v := f.GetValue().GetValue().(type) {
+		case *modelv1.FieldValue_Int:
+			return float64(v.Int.GetValue()), true
+		case *modelv1.FieldValue_Float:
+			return v.Float.GetValue(), true
+		}
+		return 0, false
+	}
+	return 0, false
+}
+
+func tagValue(dp *measurev1.DataPoint, name string) *modelv1.TagValue {
+	for _, tf := range dp.GetTagFamilies() {
+		for _, t := range tf.GetTags() {
+			if t.GetKey() == name {
+				return t.GetValue()
+			}
+		}
+	}
+	return &modelv1.TagValue{Value: &modelv1.TagValue_Null{}}
+}
+
+// seriesKey joins the tags as "key=value" by commas.
+func seriesKey(tags []*modelv1.Tag) string {
+	var sb strings.Builder
+	for i, t := range tags {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(t.GetKey())
+		sb.WriteByte('=')
+		sb.WriteString(stringify(t.GetValue()))
+	}
+	return sb.String()
+}
+
+func stringify(v *modelv1.TagValue) string {
+	switch v.GetValue().(type) {
+	case *modelv1.TagValue_Str:
+		return v.GetStr().GetValue()
+	case *modelv1.TagValue_Int:
+		return strconv.FormatInt(v.GetInt().GetValue(), 10)
+	case *modelv1.TagValue_StrArray:
+		return strings.Join(v.GetStrArray().GetValue(), "|")
+	case *modelv1.TagValue_IntArray:
+		ss := make([]string, 0, len(v.GetIntArray().GetValue()))
+		for _, i := range v.GetIntArray().GetValue() {
+			ss = append(ss, strconv.FormatInt(i, 10))
+		}
+		return strings.Join(ss, "|")
+	}
+	return ""
+}
@@ -0,0 +1,190 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package alert
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+func testMeasure() *databasev1.Measure {
+	return &databasev1.Measure{
+		Metadata: &commonv1.Metadata{Group: "sw_metric", Name: "service_cpm_minute"},
+		TagFamilies: []*databasev1.TagFamilySpec{{
+			Name: "default",
+			Tags: []*databasev1.TagSpec{{Name: "id", Type: databasev1.TagType_TAG_TYPE_STRING}},
+		}},
+		Fields: []*databasev1.FieldSpec{
+			{Name: "total", FieldType: databasev1.FieldType_FIELD_TYPE_INT},
+			{Name: "name", FieldType: databasev1.FieldType_FIELD_TYPE_STRING},
+		},
+	}
+}
+
+func testRule() *databasev1.AlertRule {
+	return &databasev1.AlertRule{
+		Metadata:        &commonv1.Metadata{Group: "sw_metric", Name: "high_cpm"},
+		Measure:         "service_cpm_minute",
+		FieldName:       "total",
+		GroupByTagNames: []string{"id"},
+		Comparison:      databasev1.AlertRule_COMPARISON_GT,
+		Threshold:       100,
+		Interval:        "1m",
+	}
+}
+
+func dataPoint(id string, total int64) *measurev1.DataPoint {
+	return &measurev1.DataPoint{
+		TagFamilies: []*modelv1.TagFamily{{Name: "default", Tags: []*modelv1.Tag{{
+			Key:   "id",
+			Value: &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: id}}},
+		}}}},
+		Fields: []*measurev1.DataPoint_Field{{
+			Name:  "total",
+			Value: &modelv1.FieldValue{Value: &modelv1.FieldValue_Int{Int: &modelv1.Int{Value: total}}},
+		}},
+	}
+}
+
+func TestCompile(t *testing.T) {
+	cr, err := compile(testRule(), testMeasure())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default"}, cr.families)
+	assert.Equal(t, time.Minute, cr.window, "the window is the interval by default")
+
+	rule := testRule()
+	rule.Window = "5m"
+	cr, err = compile(rule, testMeasure())
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, cr.window)
+
+	rule = testRule()
+	rule.FieldName = "name"
+	_, err = compile(rule, testMeasure())
+	assert.Error(t, err, "the field isn't numeric")
+
+	rule = testRule()
+	rule.GroupByTagNames = []string{"unknown"}
+	_, err = compile(rule, testMeasure())
+	assert.Error(t, err)
+
+	rule = testRule()
+	rule.Interval = "0s"
+	_, err = compile(rule, testMeasure())
+	assert.Error(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		comparison databasev1.AlertRule_Comparison
+		value      float64
+		want       bool
+	}{
+		{databasev1.AlertRule_COMPARISON_GT, 101, true},
+		{databasev1.AlertRule_COMPARISON_GT, 100, false},
+		{databasev1.AlertRule_COMPARISON_GE, 100, true},
+		{databasev1.AlertRule_COMPARISON_LT, 99, true},
+		{databasev1.AlertRule_COMPARISON_LE, 101, false},
+		{databasev1.AlertRule_COMPARISON_EQ, 100, true},
+		{databasev1.AlertRule_COMPARISON_NE, 100, false},
+		{databasev1.AlertRule_COMPARISON_UNSPECIFIED, 101, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, compare(tt.comparison, tt.value, 100), "%s %v", tt.comparison, tt.value)
+	}
+}
+
+func TestValues(t *testing.T) {
+	cr, err := compile(testRule(), testMeasure())
+	require.NoError(t, err)
+	values := cr.values([]*measurev1.DataPoint{dataPoint("svc-a", 120), dataPoint("svc-b", 10), dataPoint("svc-a", 50)})
+	require.Len(t, values, 2)
+	assert.Equal(t, float64(120), values["id=svc-a"].value, "the first data point of a series is taken")
+	assert.Equal(t, float64(10), values["id=svc-b"].value)
+}
+
+func TestTransit(t *testing.T) {
+	cr, err := compile(testRule(), testMeasure())
+	require.NoError(t, err)
+	firing := make(map[string]series)
+	now := time.Now()
+
+	events := cr.transit(firing, cr.values([]*measurev1.DataPoint{dataPoint("svc-a", 120), dataPoint("svc-b", 10)}), now)
+	require.Len(t, events, 1)
+	assert.Equal(t, databasev1.AlertEvent_STATE_FIRING, events[0].GetState())
+	assert.Equal(t, "svc-a", events[0].GetTags()[0].GetValue().GetStr().GetValue())
+	assert.Equal(t, float64(120), events[0].GetValue())
+	assert.Contains(t, firing, "id=svc-a")
+
+	events = cr.transit(firing, cr.values([]*measurev1.DataPoint{dataPoint("svc-a", 130)}), now)
+	assert.Empty(t, events, "a series firing doesn't fire again")
+
+	events = cr.transit(firing, cr.values([]*measurev1.DataPoint{dataPoint("svc-a", 90)}), now)
+	require.Len(t, events, 1)
+	assert.Equal(t, databasev1.AlertEvent_STATE_RESOLVED, events[0].GetState())
+	assert.Empty(t, firing)
+
+	cr.transit(firing, cr.values([]*measurev1.DataPoint{dataPoint("svc-b", 200)}), now)
+	events = cr.transit(firing, nil, now)
+	require.Len(t, events, 1, "a series without data points is resolved")
+	assert.Equal(t, databasev1.AlertEvent_STATE_RESOLVED, events[0].GetState())
+	assert.Equal(t, float64(200), events[0].GetValue())
+
+	revert(firing, events[0])
+	assert.Contains(t, firing, "id=svc-b", "the series is resolved again in the next round")
+	revert(firing, cr.event(databasev1.AlertEvent_STATE_FIRING, firing["id=svc-b"], now))
+	assert.Empty(t, firing)
+}
+
+func TestPost(t *testing.T) {
+	var received *databasev1.AlertEvent
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := &databasev1.AlertEvent{}
+		body, _ := io.ReadAll(r.Body)
+		if err := protojson.Unmarshal(body, event); err == nil {
+			received = event
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	e := &evaluator{httpClient: srv.Client()}
+	cr, err := compile(testRule(), testMeasure())
+	require.NoError(t, err)
+	event := cr.event(databasev1.AlertEvent_STATE_FIRING, series{value: 120}, time.Now())
+	require.NoError(t, e.post(context.Background(), srv.URL, event))
+	require.NotNil(t, received)
+	assert.Equal(t, "high_cpm", received.GetRule().GetName())
+
+	status = http.StatusInternalServerError
+	assert.Error(t, e.post(context.Background(), srv.URL, event))
+}
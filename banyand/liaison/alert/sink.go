@@ -0,0 +1,92 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package alert
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/pkg/client"
+)
+
+// emit posts the event to the webhook and writes it to the stream of the rule.
+func (e *evaluator) emit(ctx context.Context, rule *databasev1.AlertRule, event *databasev1.AlertEvent) error {
+	var errs error
+	if rule.GetWebhook() != "" {
+		errs = multierr.Append(errs, e.post(ctx, rule.GetWebhook(), event))
+	}
+	if rule.GetStream() != nil {
+		errs = multierr.Append(errs, e.write(ctx, rule, event))
+	}
+	return errs
+}
+
+func (e *evaluator) post(ctx context.Context, webhook string, event *databasev1.AlertEvent) error {
+	body, err := protojson.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return errors.WithMessage(err, "failed to post the event to the webhook")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("the webhook responds with the status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// write writes the event to the stream as an element, whose ID is unique to the series and the time of the event.
+func (e *evaluator) write(ctx context.Context, rule *databasev1.AlertRule, event *databasev1.AlertEvent) error {
+	data, err := proto.Marshal(event)
+	if err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	group := rule.GetStream().GetGroup()
+	if group == "" {
+		group = rule.GetMetadata().GetGroup()
+	}
+	key := seriesKey(event.GetTags())
+	t := event.GetTime().AsTime()
+	element := client.NewElement(ruleKey(rule.GetMetadata())+"/"+key+"/"+strconv.FormatInt(t.UnixNano(), 10), t).
+		Tag("rule", rule.GetMetadata().GetName()).
+		Tag("state", event.GetState().String()).
+		Tag("series", key).
+		Tag("event", data)
+	return errors.WithMessage(c.WriteStream(ctx, group, rule.GetStream().GetName(), element), "failed to write the event to the stream")
+}
@@ -0,0 +1,193 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUnauthenticated is returned if a token is absent or invalid.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrPermissionDenied is returned if no role of a caller grants the operation.
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	Name  string
+	Roles []string
+}
+
+// Authenticator authenticates a bearer token.
+type Authenticator interface {
+	// Authenticate returns the identity of the token, or ErrUnauthenticated if it's invalid.
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+type rule struct {
+	groups     []string
+	operations map[Operation]struct{}
+}
+
+func (r rule) grants(op Operation, group string) bool {
+	_, ok := r.operations[op]
+	if _, admin := r.operations[OperationAdmin]; !ok && !admin {
+		return false
+	}
+	for _, pattern := range r.groups {
+		if group == "" {
+			if pattern == "*" {
+				return true
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern, group); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// staticTokens authenticates the static tokens of the users.
+type staticTokens map[[sha256.Size]byte]string
+
+func (st staticTokens) Authenticate(_ context.Context, token string) (*Identity, error) {
+	// the tokens are looked up by their digests, which takes the same time however they differ
+	name, ok := st[sha256.Sum256([]byte(token))]
+	if !ok {
+		return nil, errors.WithMessage(ErrUnauthenticated, "unknown token")
+	}
+	return &Identity{Name: name}, nil
+}
+
+// Service authenticates the tokens by the authenticators in order, i.e. the static tokens of the users and the JWTs,
+// and authorizes the operations of the identities by their roles.
+type Service struct {
+	userRoles      map[string][]string
+	roles          map[string][]rule
+	authenticators []Authenticator
+}
+
+// NewService returns a service enforcing the config.
+func NewService(c *Config) (*Service, error) {
+	s := &Service{
+		userRoles: make(map[string][]string, len(c.Users)),
+		roles:     make(map[string][]rule, len(c.Roles)),
+	}
+	for _, r := range c.Roles {
+		rules := make([]rule, 0, len(r.Rules))
+		for _, rc := range r.Rules {
+			ru := rule{groups: rc.Groups, operations: make(map[Operation]struct{}, len(rc.Operations))}
+			for _, o := range rc.Operations {
+				op, err := parseOperation(o)
+				if err != nil {
+					return nil, err
+				}
+				ru.operations[op] = struct{}{}
+			}
+			rules = append(rules, ru)
+		}
+		s.roles[r.Name] = rules
+	}
+	tokens := make(staticTokens)
+	for _, u := range c.Users {
+		s.userRoles[u.Name] = u.Roles
+		if u.Token != "" {
+			tokens[sha256.Sum256([]byte(u.Token))] = u.Name
+		}
+	}
+	if len(tokens) > 0 {
+		s.authenticators = append(s.authenticators, tokens)
+	}
+	if c.JWT != nil {
+		a, err := newJWTAuthenticator(c.JWT)
+		if err != nil {
+			return nil, err
+		}
+		s.authenticators = append(s.authenticators, a)
+	}
+	return s, nil
+}
+
+// Authenticate returns the identity of the bearer token from the first authenticator accepting it.
+// The identity has the roles bound to its user besides the ones the authenticator finds, e.g. the roles claim of a JWT.
+func (s *Service) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	if token == "" {
+		return nil, errors.WithMessage(ErrUnauthenticated, "no token")
+	}
+	err := errors.WithMessage(ErrUnauthenticated, "unknown token")
+	for _, a := range s.authenticators {
+		id, errAuth := a.Authenticate(ctx, token)
+		if errAuth == nil {
+			id.Roles = append(id.Roles, s.userRoles[id.Name]...)
+			return id, nil
+		}
+		if !errors.Is(errAuth, ErrUnauthenticated) {
+			// the authenticator fails to check the token, e.g. the keys of the JWTs aren't available
+			return nil, errAuth
+		}
+		err = errAuth
+	}
+	return nil, err
+}
+
+// Authorize checks whether the roles of the identity grant the operation on all the groups.
+// The requests without a group are granted by the rules covering all the groups.
+func (s *Service) Authorize(id *Identity, op Operation, groups []string) error {
+	if len(groups) == 0 {
+		groups = []string{""}
+	}
+	for _, g := range groups {
+		if !s.grants(id, op, g) {
+			if g == "" {
+				return errors.WithMessagef(ErrPermissionDenied, "%s can't %s all groups", id.Name, op)
+			}
+			return errors.WithMessagef(ErrPermissionDenied, "%s can't %s the group %s", id.Name, op, g)
+		}
+	}
+	return nil
+}
+
+func (s *Service) grants(id *Identity, op Operation, group string) bool {
+	for _, r := range id.Roles {
+		for _, ru := range s.roles[r] {
+			if ru.grants(op, group) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type identityKey struct{}
+
+// NewContext returns a context carrying the identity.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity carried by the context, or nil if it's absent.
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
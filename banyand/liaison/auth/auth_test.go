@@ -0,0 +1,227 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+users:
+- name: oap
+  token: oap-token
+  roles: [writer]
+- name: ops
+  token: ops-token
+  roles: [admin]
+- name: alice
+  roles: [reader]
+roles:
+- name: writer
+  rules:
+  - groups: ["sw_*"]
+    operations: [read, write]
+- name: reader
+  rules:
+  - groups: ["sw_metric"]
+    operations: [read]
+- name: admin
+  rules:
+  - groups: ["*"]
+    operations: [admin]
+`
+
+func writeConfig(t *testing.T, content string) string {
+	f := filepath.Join(t.TempDir(), "auth.yaml")
+	require.NoError(t, os.WriteFile(f, []byte(content), 0o600))
+	return f
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":     "users: []\nroles: []\nunknown: 1\n",
+		"no authentication": "roles:\n- name: r\n  rules:\n  - groups: [\"*\"]\n    operations: [read]\n",
+		"unknown role":      "users:\n- name: u\n  token: t\n  roles: [missing]\nroles: []\n",
+		"unknown operation": "users:\n- name: u\n  token: t\nroles:\n- name: r\n  rules:\n  - groups: [\"*\"]\n    operations: [delete]\n",
+		"invalid pattern":   "users:\n- name: u\n  token: t\nroles:\n- name: r\n  rules:\n  - groups: [\"[\"]\n    operations: [read]\n",
+		"shared token":      "users:\n- name: u\n  token: t\n- name: v\n  token: t\nroles: []\n",
+		"no issuer":         "jwt:\n  audience: banyandb\nroles: []\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, content))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestService_StaticTokens(t *testing.T) {
+	c, err := LoadConfig(writeConfig(t, testConfig))
+	require.NoError(t, err)
+	s, err := NewService(c)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = s.Authenticate(ctx, "")
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = s.Authenticate(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	oap, err := s.Authenticate(ctx, "oap-token")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Name: "oap", Roles: []string{"writer"}}, oap)
+	assert.NoError(t, s.Authorize(oap, OperationWrite, []string{"sw_metric", "sw_record"}))
+	assert.NoError(t, s.Authorize(oap, OperationRead, []string{"sw_metric"}))
+	assert.ErrorIs(t, s.Authorize(oap, OperationWrite, []string{"sw_metric", "other"}), ErrPermissionDenied)
+	assert.ErrorIs(t, s.Authorize(oap, OperationAdmin, []string{"sw_metric"}), ErrPermissionDenied)
+	// listing all groups needs a rule covering all of them
+	assert.ErrorIs(t, s.Authorize(oap, OperationRead, nil), ErrPermissionDenied)
+
+	ops, err := s.Authenticate(ctx, "ops-token")
+	require.NoError(t, err)
+	for _, op := range []Operation{OperationRead, OperationWrite, OperationAdmin} {
+		assert.NoError(t, s.Authorize(ops, op, nil))
+		assert.NoError(t, s.Authorize(ops, op, []string{"other"}))
+	}
+}
+
+func TestService_JWT(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "jwt.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+	c, err := LoadConfig(writeConfig(t, testConfig+`
+jwt:
+  issuer: https://accounts.example.com
+  audience: banyandb
+  key_file: `+keyFile+`
+  roles_claim: groups
+`))
+	require.NoError(t, err)
+	s, err := NewService(c)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	sign := func(claims jwt.MapClaims) string {
+		token, signErr := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(key)
+		require.NoError(t, signErr)
+		return token
+	}
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": "https://accounts.example.com",
+			"aud": "banyandb",
+			"sub": "alice",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	id, err := s.Authenticate(ctx, sign(valid()))
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Name: "alice", Roles: []string{"reader"}}, id)
+	assert.NoError(t, s.Authorize(id, OperationRead, []string{"sw_metric"}))
+	assert.ErrorIs(t, s.Authorize(id, OperationWrite, []string{"sw_metric"}), ErrPermissionDenied)
+
+	// the roles claim grants the roles besides the ones bound to the user
+	claims := valid()
+	claims["sub"] = "bob"
+	claims["groups"] = []string{"writer"}
+	id, err = s.Authenticate(ctx, sign(claims))
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Name: "bob", Roles: []string{"writer"}}, id)
+
+	invalid := map[string]func(jwt.MapClaims){
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"no expiration":  func(c jwt.MapClaims) { delete(c, "exp") },
+		"other issuer":   func(c jwt.MapClaims) { c["iss"] = "https://other.example.com" },
+		"other audience": func(c jwt.MapClaims) { c["aud"] = "other" },
+		"no subject":     func(c jwt.MapClaims) { delete(c, "sub") },
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			c := valid()
+			mutate(c)
+			_, authErr := s.Authenticate(ctx, sign(c))
+			assert.ErrorIs(t, authErr, ErrUnauthenticated)
+		})
+	}
+
+	// the HMAC signature by the public key is rejected
+	hmac, err := jwt.NewWithClaims(jwt.SigningMethodHS256, valid()).SignedString(der)
+	require.NoError(t, err)
+	_, err = s.Authenticate(ctx, hmac)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	// the static tokens still work
+	_, err = s.Authenticate(ctx, "oap-token")
+	assert.NoError(t, err)
+}
+
+func TestJWKS_Discovery(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"jwks_uri": issuer + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "EC", "kid": "k1", "use": "sig", "crv": "P-256",
+			"x": encode(key.X.FillBytes(make([]byte, 32))), "y": encode(key.Y.FillBytes(make([]byte, 32))),
+		}}})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	issuer = srv.URL
+
+	a, err := newJWTAuthenticator(&JWTConfig{Issuer: issuer})
+	require.NoError(t, err)
+	sign := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"iss": issuer, "sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+		token.Header["kid"] = kid
+		s, signErr := token.SignedString(key)
+		require.NoError(t, signErr)
+		return s
+	}
+	id, err := a.Authenticate(context.Background(), sign("k1"))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", id.Name)
+	_, err = a.Authenticate(context.Background(), sign("k2"))
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
@@ -0,0 +1,190 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package auth implements the authentication of the API requests by the static tokens or the JWTs,
+// and the role-based authorization of their operations on the groups.
+package auth
+
+import (
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Operation is the access a request makes to the groups.
+type Operation int
+
+// The operations, and admin implies the others.
+const (
+	OperationRead Operation = iota + 1
+	OperationWrite
+	OperationAdmin
+)
+
+var operationNames = map[Operation]string{
+	OperationRead:  "read",
+	OperationWrite: "write",
+	OperationAdmin: "admin",
+}
+
+func (o Operation) String() string {
+	return operationNames[o]
+}
+
+func parseOperation(name string) (Operation, error) {
+	for o, n := range operationNames {
+		if n == name {
+			return o, nil
+		}
+	}
+	return 0, errors.Errorf("unknown operation %q, which should be one of read, write and admin", name)
+}
+
+// Config is the config of the authentication and the authorization loaded from a YAML file, e.g.
+//
+//	users:
+//	- name: oap
+//	  token: a-long-random-token
+//	  roles: [writer]
+//	jwt:
+//	  issuer: https://accounts.example.com
+//	  audience: banyandb
+//	  roles_claim: groups
+//	roles:
+//	- name: writer
+//	  rules:
+//	  - groups: ["sw_*"]
+//	    operations: [read, write]
+//	- name: admin
+//	  rules:
+//	  - groups: ["*"]
+//	    operations: [admin]
+type Config struct {
+	JWT   *JWTConfig   `json:"jwt,omitempty"`
+	Users []UserConfig `json:"users,omitempty"`
+	Roles []RoleConfig `json:"roles"`
+}
+
+// UserConfig binds the roles to a user, who is authenticated by the static token if it's set,
+// or by a JWT whose username claim is the name.
+type UserConfig struct {
+	Name  string   `json:"name"`
+	Token string   `json:"token,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// RoleConfig grants the operations on the groups by the rules.
+type RoleConfig struct {
+	Name  string       `json:"name"`
+	Rules []RuleConfig `json:"rules"`
+}
+
+// RuleConfig grants the operations on the groups matching the patterns, e.g. "sw_*".
+// The requests without a group, e.g. listing the groups, are granted by the pattern "*" only.
+type RuleConfig struct {
+	Groups     []string `json:"groups"`
+	Operations []string `json:"operations"`
+}
+
+// JWTConfig verifies the JWTs issued by an identity provider. The keys are loaded from the key file if it's set,
+// or fetched from the JWKS URL, which is discovered from the OpenID configuration of the issuer if it's empty.
+type JWTConfig struct {
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience,omitempty"`
+	JWKSURL  string `json:"jwks_url,omitempty"`
+	// KeyFile is the PEM file of the public key verifying the JWTs.
+	KeyFile string `json:"key_file,omitempty"`
+	// UsernameClaim is the claim of the username, which is "sub" by default.
+	UsernameClaim string `json:"username_claim,omitempty"`
+	// RolesClaim is the claim of the roles, which are granted besides the ones bound to the user.
+	RolesClaim string `json:"roles_claim,omitempty"`
+}
+
+// LoadConfig loads the config from the YAML file.
+func LoadConfig(file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err = yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, errors.Wrapf(err, "invalid auth config %s", file)
+	}
+	if err = c.validate(); err != nil {
+		return nil, errors.WithMessagef(err, "invalid auth config %s", file)
+	}
+	return c, nil
+}
+
+func (c *Config) validate() error {
+	roles := make(map[string]struct{}, len(c.Roles))
+	for _, r := range c.Roles {
+		if r.Name == "" {
+			return errors.New("the name of a role is required")
+		}
+		if _, ok := roles[r.Name]; ok {
+			return errors.Errorf("duplicated role %s", r.Name)
+		}
+		roles[r.Name] = struct{}{}
+		for _, rule := range r.Rules {
+			if len(rule.Groups) == 0 || len(rule.Operations) == 0 {
+				return errors.Errorf("a rule of the role %s has no group or operation", r.Name)
+			}
+			for _, g := range rule.Groups {
+				if _, err := path.Match(g, ""); err != nil {
+					return errors.Wrapf(err, "invalid group pattern %q of the role %s", g, r.Name)
+				}
+			}
+			for _, o := range rule.Operations {
+				if _, err := parseOperation(o); err != nil {
+					return errors.WithMessagef(err, "invalid rule of the role %s", r.Name)
+				}
+			}
+		}
+	}
+	users := make(map[string]struct{}, len(c.Users))
+	tokens := make(map[string]struct{}, len(c.Users))
+	for _, u := range c.Users {
+		if u.Name == "" {
+			return errors.New("the name of a user is required")
+		}
+		if _, ok := users[u.Name]; ok {
+			return errors.Errorf("duplicated user %s", u.Name)
+		}
+		users[u.Name] = struct{}{}
+		if u.Token != "" {
+			if _, ok := tokens[u.Token]; ok {
+				return errors.Errorf("the token of the user %s is shared with another user", u.Name)
+			}
+			tokens[u.Token] = struct{}{}
+		}
+		for _, r := range u.Roles {
+			if _, ok := roles[r]; !ok {
+				return errors.Errorf("unknown role %s of the user %s", r, u.Name)
+			}
+		}
+	}
+	if len(tokens) == 0 && c.JWT == nil {
+		return errors.New("neither a user token nor jwt is set, which rejects all requests")
+	}
+	if c.JWT != nil && c.JWT.Issuer == "" {
+		return errors.New("the issuer of jwt is required")
+	}
+	return nil
+}
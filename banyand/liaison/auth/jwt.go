@@ -0,0 +1,294 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultUsernameClaim = "sub"
+	// jwksRefreshInterval is the interval of fetching the keys again, which picks the rotated keys.
+	jwksRefreshInterval = time.Hour
+	// jwksMinRefreshInterval bounds the fetches triggered by the unknown key ids.
+	jwksMinRefreshInterval = time.Minute
+	fetchTimeout           = 10 * time.Second
+)
+
+// validMethods are the asymmetric signing methods, which prevent a public key from verifying an HMAC signature.
+var validMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// jwtAuthenticator authenticates the JWTs issued by the issuer.
+type jwtAuthenticator struct {
+	key    crypto.PublicKey
+	jwks   *jwks
+	parser *jwt.Parser
+	cfg    JWTConfig
+}
+
+func newJWTAuthenticator(cfg *JWTConfig) (*jwtAuthenticator, error) {
+	a := &jwtAuthenticator{
+		cfg:    *cfg,
+		parser: jwt.NewParser(jwt.WithValidMethods(validMethods)),
+	}
+	if a.cfg.UsernameClaim == "" {
+		a.cfg.UsernameClaim = defaultUsernameClaim
+	}
+	if cfg.KeyFile != "" {
+		var err error
+		if a.key, err = loadPublicKey(cfg.KeyFile); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+	a.jwks = &jwks{issuer: cfg.Issuer, url: cfg.JWKSURL, client: &http.Client{Timeout: fetchTimeout}}
+	return a, nil
+}
+
+func loadPublicKey(file string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if k, errRSA := jwt.ParseRSAPublicKeyFromPEM(data); errRSA == nil {
+		return k, nil
+	}
+	if k, errEC := jwt.ParseECPublicKeyFromPEM(data); errEC == nil {
+		return k, nil
+	}
+	if k, errEd := jwt.ParseEdPublicKeyFromPEM(data); errEd == nil {
+		return k, nil
+	}
+	return nil, errors.Errorf("%s isn't a PEM encoded RSA, ECDSA or Ed25519 public key", file)
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	if strings.Count(token, ".") != 2 {
+		return nil, errors.WithMessage(ErrUnauthenticated, "unknown token")
+	}
+	var errKey error
+	t, err := a.parser.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if a.key != nil {
+			return a.key, nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		var k crypto.PublicKey
+		k, errKey = a.jwks.key(ctx, kid)
+		return k, errKey
+	})
+	if errKey != nil && !errors.Is(errKey, errUnknownKey) {
+		return nil, errKey
+	}
+	if err != nil {
+		return nil, errors.WithMessage(ErrUnauthenticated, err.Error())
+	}
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.WithMessage(ErrUnauthenticated, "invalid claims")
+	}
+	// the tokens never expiring are rejected, which can't be revoked
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.WithMessage(ErrUnauthenticated, "no expiration")
+	}
+	if !claims.VerifyIssuer(a.cfg.Issuer, true) {
+		return nil, errors.WithMessage(ErrUnauthenticated, "unexpected issuer")
+	}
+	if a.cfg.Audience != "" && !claims.VerifyAudience(a.cfg.Audience, true) {
+		return nil, errors.WithMessage(ErrUnauthenticated, "unexpected audience")
+	}
+	name, _ := claims[a.cfg.UsernameClaim].(string)
+	if name == "" {
+		return nil, errors.WithMessagef(ErrUnauthenticated, "no username claim %s", a.cfg.UsernameClaim)
+	}
+	id := &Identity{Name: name}
+	if a.cfg.RolesClaim != "" {
+		switch roles := claims[a.cfg.RolesClaim].(type) {
+		case string:
+			id.Roles = strings.Fields(roles)
+		case []interface{}:
+			for _, r := range roles {
+				if s, ok := r.(string); ok {
+					id.Roles = append(id.Roles, s)
+				}
+			}
+		}
+	}
+	return id, nil
+}
+
+var errUnknownKey = errors.New("unknown key")
+
+// jwks fetches the keys from the JWKS URL, which is discovered from the OpenID configuration of the issuer if it's empty.
+// The keys are fetched again every hour, or once an unknown key id shows up, which picks the rotated keys.
+type jwks struct {
+	fetchedAt time.Time
+	client    *http.Client
+	keys      map[string]crypto.PublicKey
+	issuer    string
+	url       string
+	mu        sync.Mutex
+}
+
+func (j *jwks) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	k, ok := j.keys[kid]
+	elapsed := time.Since(j.fetchedAt)
+	if (ok && elapsed < jwksRefreshInterval) || (!ok && elapsed < jwksMinRefreshInterval) {
+		if !ok {
+			return nil, errors.Wrapf(errUnknownKey, "kid %q", kid)
+		}
+		return k, nil
+	}
+	if err := j.fetch(ctx); err != nil {
+		if ok {
+			// the known key stays valid if the provider is unreachable for a while
+			return k, nil
+		}
+		return nil, err
+	}
+	if k, ok = j.keys[kid]; !ok {
+		return nil, errors.Wrapf(errUnknownKey, "kid %q", kid)
+	}
+	return k, nil
+}
+
+func (j *jwks) fetch(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	if j.url == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := j.get(ctx, strings.TrimSuffix(j.issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return errors.WithMessage(err, "failed to discover the jwks of the issuer")
+		}
+		if discovery.JWKSURI == "" {
+			return errors.Errorf("the issuer %s has no jwks_uri", j.issuer)
+		}
+		j.url = discovery.JWKSURI
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := j.get(ctx, j.url, &set); err != nil {
+		return errors.WithMessage(err, "failed to fetch the jwks")
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// the keys of the unsupported types are skipped
+		if k, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = k
+		}
+	}
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	return nil
+}
+
+func (j *jwks) get(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s responds %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// jsonWebKey is a public key of a JWKS defined by RFC 7517.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, errN := decodeBigInt(k.N)
+		e, errE := decodeBigInt(k.E)
+		if errN != nil || errE != nil || !e.IsInt64() {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, errX := decodeBigInt(k.X)
+		y, errY := decodeBigInt(k.Y)
+		if errX != nil || errY != nil {
+			return nil, errors.New("invalid EC key")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid OKP key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, errors.Errorf("unsupported key type %s", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,280 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	propertyv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/property/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/liaison/auth"
+	"github.com/apache/skywalking-banyandb/banyand/metadata"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+// anonymous is the actor of the requests if the authentication is disabled.
+const anonymous = "anonymous"
+
+var (
+	auditProvider          = observability.NewMeterProvider(observability.RootScope.SubScope("liaison").SubScope("audit"))
+	auditRecordedCounter   = auditProvider.Counter("recorded_total", "method")
+	auditWriteFailureCount = auditProvider.Counter("write_failure_total", "method")
+)
+
+// snapshotFunc returns the current version of the resource a request changes, or nil if it doesn't exist.
+type snapshotFunc func(ctx context.Context) (proto.Message, error)
+
+// auditTrail records the schema changes and the destructive operations to the audit stream, including the actor,
+// the request, and the snapshots of the resource before and after the change.
+// The records are written to the stream as the elements, so they're queried by the stream API.
+type auditTrail struct {
+	registry metadata.Repo
+	streams  *streamService
+	l        *logger.Logger
+	group    string
+	name     string
+}
+
+// auditedChange is a change recorded to the audit stream.
+type auditedChange struct {
+	target   *commonv1.Metadata
+	snapshot snapshotFunc
+}
+
+func (a *auditTrail) unaryInterceptor() grpclib.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+		change, ok := a.change(req)
+		if !ok {
+			return handler(ctx, req)
+		}
+		var before, after proto.Message
+		if change.snapshot != nil {
+			before = a.snapshot(ctx, info.FullMethod, change.snapshot)
+		}
+		resp, err := handler(ctx, req)
+		if err == nil && change.snapshot != nil {
+			after = a.snapshot(ctx, info.FullMethod, change.snapshot)
+		}
+		a.record(ctx, info.FullMethod, change.target, req.(proto.Message), before, after, err)
+		return resp, err
+	}
+}
+
+func (a *auditTrail) snapshot(ctx context.Context, method string, fn snapshotFunc) proto.Message {
+	m, err := fn(ctx)
+	if err != nil {
+		// the resource absent before a creation or after a deletion has no snapshot
+		if !errors.Is(err, schema.ErrGRPCResourceNotFound) {
+			a.l.Warn().Err(err).Str("method", method).Msg("failed to take the snapshot of the audited resource")
+		}
+		return nil
+	}
+	return m
+}
+
+// record writes the change to the audit stream. The failure is logged rather than failing the request, which has been done.
+func (a *auditTrail) record(ctx context.Context, method string, target *commonv1.Metadata, req, before, after proto.Message, errHandle error) {
+	actor := anonymous
+	if id := auth.FromContext(ctx); id != nil {
+		actor = id.Name
+	}
+	result := status.Code(errHandle).String()
+	tags := map[string]*modelv1.TagValue{
+		"actor":   strTagValue(actor),
+		"method":  strTagValue(method),
+		"group":   strTagValue(target.GetGroup()),
+		"name":    strTagValue(target.GetName()),
+		"status":  strTagValue(result),
+		"peer":    strTagValue(peerAddr(ctx)),
+		"request": jsonTagValue(req),
+		"before":  jsonTagValue(before),
+		"after":   jsonTagValue(after),
+	}
+	md := &commonv1.Metadata{Group: a.group, Name: a.name}
+	tagFamilies, ok := a.streams.entityRepo.getTagFamilies(getID(md))
+	if !ok {
+		auditWriteFailureCount.Inc(1, method)
+		a.l.Error().Str("method", method).Str("actor", actor).Msgf("the audit stream %s/%s doesn't exist", a.group, a.name)
+		return
+	}
+	element := &streamv1.ElementValue{ElementId: uuid.NewString(), Timestamp: timestamppb.New(time.Now())}
+	for _, tf := range tagFamilies {
+		family := &modelv1.TagFamilyForWrite{}
+		for _, t := range tf.GetTags() {
+			v, exist := tags[t.GetName()]
+			if !exist {
+				v = &modelv1.TagValue{Value: &modelv1.TagValue_Null{}}
+			}
+			family.Tags = append(family.Tags, v)
+		}
+		element.TagFamilies = append(element.TagFamilies, family)
+	}
+	publisher := a.streams.pipeline.NewBatchPublisher()
+	defer publisher.Close()
+	if st := a.streams.write(publisher, &streamv1.WriteRequest{Metadata: md, Element: element}, false); st != modelv1.Status_STATUS_SUCCEED {
+		auditWriteFailureCount.Inc(1, method)
+		a.l.Error().Str("method", method).Str("actor", actor).Stringer("status", st).Msg("failed to write the audit record")
+		return
+	}
+	auditRecordedCounter.Inc(1, method)
+}
+
+func strTagValue(s string) *modelv1.TagValue {
+	return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: s}}}
+}
+
+func jsonTagValue(m proto.Message) *modelv1.TagValue {
+	if m == nil {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Null{}}
+	}
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Null{}}
+	}
+	return strTagValue(string(data))
+}
+
+// change returns the change the request makes if it's audited, i.e. a mutation of the schemas, the groups and the tenants,
+// or a deletion of the data.
+func (a *auditTrail) change(req any) (auditedChange, bool) {
+	stream := func(md *commonv1.Metadata) auditedChange {
+		return auditedChange{target: md, snapshot: func(ctx context.Context) (proto.Message, error) {
+			return a.registry.StreamRegistry().GetStream(ctx, md)
+		}}
+	}
+	measure := func(md *commonv1.Metadata) auditedChange {
+		return auditedChange{target: md, snapshot: func(ctx context.Context) (proto.Message, error) {
+			return a.registry.MeasureRegistry().GetMeasure(ctx, md)
+		}}
+	}
+	indexRule := func(md *commonv1.Metadata) auditedChange {
+		return auditedChange{target: md, snapshot: func(ctx context.Context) (proto.Message, error) {
+			return a.registry.IndexRuleRegistry().GetIndexRule(ctx, md)
+		}}
+	}
+	indexRuleBinding := func(md *commonv1.Metadata) auditedChange {
+		return auditedChange{target: md, snapshot: func(ctx context.Context) (proto.Message, error) {
+			return a.registry.IndexRuleBindingRegistry().GetIndexRuleBinding(ctx, md)
+		}}
+	}
+	topN := func(md *commonv1.Metadata) auditedChange {
+		return auditedChange{target: md, snapshot: func(ctx context.Context) (proto.Message, error) {
+			return a.registry.TopNAggregationRegistry().GetTopNAggregation(ctx, md)
+		}}
+	}
+	alertRule := func(md *commonv1.Metadata) auditedChange {
+		return auditedChange{target: md, snapshot: func(ctx context.Context) (proto.Message, error) {
+			return a.registry.AlertRuleRegistry().GetAlertRule(ctx, md)
+		}}
+	}
+	group := func(name string) auditedChange {
+		return auditedChange{target: &commonv1.Metadata{Group: name}, snapshot: func(ctx context.Context) (proto.Message, error) {
+			return a.registry.GroupRegistry().GetGroup(ctx, name)
+		}}
+	}
+	tenant := func(name string) auditedChange {
+		return auditedChange{target: &commonv1.Metadata{Name: name}, snapshot: func(ctx context.Context) (proto.Message, error) {
+			return a.registry.TenantRegistry().GetTenant(ctx, name)
+		}}
+	}
+	switch r := req.(type) {
+	case *databasev1.StreamRegistryServiceCreateRequest:
+		return stream(r.GetStream().GetMetadata()), true
+	case *databasev1.StreamRegistryServiceUpdateRequest:
+		return stream(r.GetStream().GetMetadata()), true
+	case *databasev1.StreamRegistryServiceDeleteRequest:
+		return stream(r.GetMetadata()), true
+	case *databasev1.StreamRegistryServiceRollbackRequest:
+		return stream(r.GetMetadata()), true
+	case *databasev1.MeasureRegistryServiceCreateRequest:
+		return measure(r.GetMeasure().GetMetadata()), true
+	case *databasev1.MeasureRegistryServiceUpdateRequest:
+		return measure(r.GetMeasure().GetMetadata()), true
+	case *databasev1.MeasureRegistryServiceDeleteRequest:
+		return measure(r.GetMetadata()), true
+	case *databasev1.MeasureRegistryServiceRollbackRequest:
+		return measure(r.GetMetadata()), true
+	case *databasev1.IndexRuleRegistryServiceCreateRequest:
+		return indexRule(r.GetIndexRule().GetMetadata()), true
+	case *databasev1.IndexRuleRegistryServiceUpdateRequest:
+		return indexRule(r.GetIndexRule().GetMetadata()), true
+	case *databasev1.IndexRuleRegistryServiceDeleteRequest:
+		return indexRule(r.GetMetadata()), true
+	case *databasev1.IndexRuleRegistryServiceRollbackRequest:
+		return indexRule(r.GetMetadata()), true
+	case *databasev1.IndexRuleBindingRegistryServiceCreateRequest:
+		return indexRuleBinding(r.GetIndexRuleBinding().GetMetadata()), true
+	case *databasev1.IndexRuleBindingRegistryServiceUpdateRequest:
+		return indexRuleBinding(r.GetIndexRuleBinding().GetMetadata()), true
+	case *databasev1.IndexRuleBindingRegistryServiceDeleteRequest:
+		return indexRuleBinding(r.GetMetadata()), true
+	case *databasev1.TopNAggregationRegistryServiceCreateRequest:
+		return topN(r.GetTopNAggregation().GetMetadata()), true
+	case *databasev1.TopNAggregationRegistryServiceUpdateRequest:
+		return topN(r.GetTopNAggregation().GetMetadata()), true
+	case *databasev1.TopNAggregationRegistryServiceDeleteRequest:
+		return topN(r.GetMetadata()), true
+	case *databasev1.AlertRuleRegistryServiceCreateRequest:
+		return alertRule(r.GetAlertRule().GetMetadata()), true
+	case *databasev1.AlertRuleRegistryServiceUpdateRequest:
+		return alertRule(r.GetAlertRule().GetMetadata()), true
+	case *databasev1.AlertRuleRegistryServiceDeleteRequest:
+		return alertRule(r.GetMetadata()), true
+	case *databasev1.GroupRegistryServiceCreateRequest:
+		return group(r.GetGroup().GetMetadata().GetName()), true
+	case *databasev1.GroupRegistryServiceUpdateRequest:
+		// the changes of the retention, e.g. the TTL and the stages, are the updates of the groups
+		return group(r.GetGroup().GetMetadata().GetName()), true
+	case *databasev1.GroupRegistryServiceDeleteRequest:
+		return group(r.GetGroup()), true
+	case *databasev1.TenantRegistryServiceCreateRequest:
+		return tenant(r.GetTenant().GetMetadata().GetName()), true
+	case *databasev1.TenantRegistryServiceUpdateRequest:
+		return tenant(r.GetTenant().GetMetadata().GetName()), true
+	case *databasev1.TenantRegistryServiceDeleteRequest:
+		return tenant(r.GetName()), true
+	case *streamv1.DeleteRequest:
+		// the request has the query of the deleted elements, and the elements have no snapshot
+		return auditedChange{target: r.GetMetadata()}, true
+	case *streamv1.PurgeRequest:
+		return auditedChange{target: r.GetMetadata()}, !r.GetDryRun()
+	case *propertyv1.DeleteRequest:
+		md := r.GetMetadata()
+		return auditedChange{
+			target: &commonv1.Metadata{Group: md.GetContainer().GetGroup(), Name: md.GetContainer().GetName() + "/" + md.GetId()},
+			snapshot: func(ctx context.Context) (proto.Message, error) {
+				return a.registry.PropertyRegistry().GetProperty(ctx, md, r.GetTags())
+			},
+		}, true
+	}
+	return auditedChange{}, false
+}
@@ -0,0 +1,112 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	propertyv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/property/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+)
+
+func Test_auditTrail_change(t *testing.T) {
+	a := &auditTrail{}
+	md := &commonv1.Metadata{Group: "sw", Name: "segment"}
+	tests := map[string]struct {
+		req        any
+		target     *commonv1.Metadata
+		snapshot   bool
+		notAudited bool
+	}{
+		"stream update": {
+			req:      &databasev1.StreamRegistryServiceUpdateRequest{Stream: &databasev1.Stream{Metadata: md}},
+			target:   md,
+			snapshot: true,
+		},
+		"measure rollback": {
+			req:      &databasev1.MeasureRegistryServiceRollbackRequest{Metadata: md, ModRevision: 1},
+			target:   md,
+			snapshot: true,
+		},
+		"group retention": {
+			req:      &databasev1.GroupRegistryServiceUpdateRequest{Group: &commonv1.Group{Metadata: &commonv1.Metadata{Name: "sw"}}},
+			target:   &commonv1.Metadata{Group: "sw"},
+			snapshot: true,
+		},
+		"group deletion": {
+			req:      &databasev1.GroupRegistryServiceDeleteRequest{Group: "sw"},
+			target:   &commonv1.Metadata{Group: "sw"},
+			snapshot: true,
+		},
+		"tenant deletion": {
+			req:      &databasev1.TenantRegistryServiceDeleteRequest{Name: "acme"},
+			target:   &commonv1.Metadata{Name: "acme"},
+			snapshot: true,
+		},
+		"delete by query": {
+			req:    &streamv1.DeleteRequest{Metadata: md, TimeRange: &modelv1.TimeRange{}},
+			target: md,
+		},
+		"purge": {
+			req:    &streamv1.PurgeRequest{Metadata: md},
+			target: md,
+		},
+		"property deletion": {
+			req:      &propertyv1.DeleteRequest{Metadata: &propertyv1.Metadata{Container: &commonv1.Metadata{Group: "sw", Name: "ui"}, Id: "1"}},
+			target:   &commonv1.Metadata{Group: "sw", Name: "ui/1"},
+			snapshot: true,
+		},
+		"dry run purge": {
+			req:        &streamv1.PurgeRequest{Metadata: md, DryRun: true},
+			notAudited: true,
+		},
+		"query": {
+			req:        &streamv1.QueryRequest{Metadata: md},
+			notAudited: true,
+		},
+		"schema read": {
+			req:        &databasev1.StreamRegistryServiceGetRequest{Metadata: md},
+			notAudited: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			change, ok := a.change(tt.req)
+			if tt.notAudited {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tt.target.GetGroup(), change.target.GetGroup())
+			assert.Equal(t, tt.target.GetName(), change.target.GetName())
+			assert.Equal(t, tt.snapshot, change.snapshot != nil)
+		})
+	}
+}
+
+func Test_jsonTagValue(t *testing.T) {
+	assert.IsType(t, &modelv1.TagValue_Null{}, jsonTagValue(nil).GetValue())
+	v := jsonTagValue(&commonv1.Metadata{Group: "sw", Name: "segment"})
+	assert.JSONEq(t, `{"group":"sw","name":"segment"}`, v.GetStr().GetValue())
+}
@@ -0,0 +1,278 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/apache/skywalking-banyandb/banyand/liaison/auth"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+const (
+	metadataMessage protoreflect.FullName = "banyandb.common.v1.Metadata"
+	groupMessage    protoreflect.FullName = "banyandb.common.v1.Group"
+	healthService                         = "/grpc.health.v1.Health/"
+	bearerPrefix                          = "bearer "
+)
+
+var (
+	authProvider        = observability.NewMeterProvider(observability.RootScope.SubScope("liaison").SubScope("auth"))
+	authDecisionCounter = authProvider.Counter("decision_total", "operation", "decision")
+
+	// readMethods are the prefixes of the methods reading the data or the schemas, the others write or administer them.
+	readMethods = []string{"Get", "List", "Exist", "Query", "TopN", "Search", "Export", "Subscribe", "Verify", "TagValues", "DoGet"}
+	// dataServices are the services whose methods, besides reading, write the data, the others administer the database.
+	dataServices = []string{
+		"banyandb.stream.v1.StreamService", "banyandb.measure.v1.MeasureService",
+		"banyandb.property.v1.PropertyService", "arrow.flight.protocol.FlightService",
+	}
+	// adminDataMethods are the methods of the data services which administer the data.
+	adminDataMethods = []string{"Purge", "RebuildIndex"}
+)
+
+// authorizer authenticates the callers of the requests and authorizes their operations on the groups.
+// The decisions are written to the audit log, where the denied ones and the administrations are always kept,
+// and the reads and the writes are kept in the debug level.
+type authorizer struct {
+	svc   *auth.Service
+	audit *logger.Logger
+}
+
+func newAuthorizer(svc *auth.Service) *authorizer {
+	return &authorizer{svc: svc, audit: logger.GetLogger("liaison-audit")}
+}
+
+func (a *authorizer) unaryInterceptor() grpclib.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+		if strings.HasPrefix(info.FullMethod, healthService) {
+			return handler(ctx, req)
+		}
+		id, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		if msg, ok := req.(proto.Message); ok {
+			if err = a.authorize(ctx, id, info.FullMethod, requestGroups(msg)); err != nil {
+				return nil, err
+			}
+		}
+		return handler(auth.NewContext(ctx, id), req)
+	}
+}
+
+func (a *authorizer) streamInterceptor() grpclib.StreamServerInterceptor {
+	return func(srv any, ss grpclib.ServerStream, info *grpclib.StreamServerInfo, handler grpclib.StreamHandler) error {
+		if strings.HasPrefix(info.FullMethod, healthService) {
+			return handler(srv, ss)
+		}
+		id, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authorizedStream{
+			ServerStream: ss,
+			ctx:          auth.NewContext(ss.Context(), id),
+			authorizer:   a,
+			id:           id,
+			method:       info.FullMethod,
+		})
+	}
+}
+
+func (a *authorizer) authenticate(ctx context.Context, method string) (*auth.Identity, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, v := range md.Get("authorization") {
+			if len(v) > len(bearerPrefix) && strings.EqualFold(v[:len(bearerPrefix)], bearerPrefix) {
+				token = v[len(bearerPrefix):]
+				break
+			}
+		}
+	}
+	id, err := a.svc.Authenticate(ctx, token)
+	if err == nil {
+		return id, nil
+	}
+	op := operationOf(method)
+	authDecisionCounter.Inc(1, op.String(), "unauthenticated")
+	a.audit.Warn().Str("method", method).Str("peer", peerAddr(ctx)).Err(err).Msg("unauthenticated")
+	if errors.Is(err, auth.ErrUnauthenticated) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return nil, status.Errorf(codes.Unavailable, "failed to authenticate: %v", err)
+}
+
+func (a *authorizer) authorize(ctx context.Context, id *auth.Identity, method string, groups []string) error {
+	op := operationOf(method)
+	if err := a.svc.Authorize(id, op, groups); err != nil {
+		authDecisionCounter.Inc(1, op.String(), "denied")
+		a.audit.Warn().Str("user", id.Name).Str("method", method).Stringer("operation", op).
+			Strs("groups", groups).Str("peer", peerAddr(ctx)).Msg("denied")
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	authDecisionCounter.Inc(1, op.String(), "allowed")
+	e := a.audit.Debug()
+	if op == auth.OperationAdmin {
+		e = a.audit.Info()
+	}
+	e.Str("user", id.Name).Str("method", method).Stringer("operation", op).
+		Strs("groups", groups).Str("peer", peerAddr(ctx)).Msg("allowed")
+	return nil
+}
+
+// authorizedStream authorizes every message received, since the messages of a stream might access different groups.
+type authorizedStream struct {
+	grpclib.ServerStream
+	ctx        context.Context
+	authorizer *authorizer
+	id         *auth.Identity
+	// granted are the groups authorized before, which skips authorizing them again
+	granted map[string]struct{}
+	method  string
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authorizedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil
+	}
+	groups := requestGroups(msg)
+	if s.granted == nil {
+		s.granted = make(map[string]struct{})
+	} else if len(groups) > 0 && allGranted(s.granted, groups) {
+		return nil
+	}
+	if err := s.authorizer.authorize(s.ctx, s.id, s.method, groups); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		s.granted[g] = struct{}{}
+	}
+	return nil
+}
+
+func allGranted(granted map[string]struct{}, groups []string) bool {
+	for _, g := range groups {
+		if _, ok := granted[g]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// operationOf returns the operation of the method, e.g. /banyandb.stream.v1.StreamService/Query.
+func operationOf(fullMethod string) auth.Operation {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	for _, p := range readMethods {
+		if strings.HasPrefix(method, p) {
+			return auth.OperationRead
+		}
+	}
+	if slices.Contains(dataServices, service) && !slices.Contains(adminDataMethods, method) {
+		return auth.OperationWrite
+	}
+	return auth.OperationAdmin
+}
+
+// requestGroups returns the groups a request accesses, which are the groups of the metadata of the request and its fields,
+// e.g. the stream of a StreamRegistryServiceCreateRequest and the elements of a BulkWriteRequest,
+// the names of the groups of the group registry, and the fields named group or groups.
+func requestGroups(msg proto.Message) []string {
+	var groups []string
+	collectGroups(msg.ProtoReflect(), func(g string) {
+		if g != "" && !slices.Contains(groups, g) {
+			groups = append(groups, g)
+		}
+	})
+	return groups
+}
+
+func collectGroups(m protoreflect.Message, add func(string)) {
+	desc := m.Descriptor()
+	switch desc.FullName() {
+	case metadataMessage:
+		add(m.Get(desc.Fields().ByName("group")).String())
+		return
+	case groupMessage:
+		md := m.Get(desc.Fields().ByName("metadata")).Message()
+		add(md.Get(md.Descriptor().Fields().ByName("name")).String())
+		return
+	}
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch {
+		case fd.Kind() == protoreflect.StringKind && (fd.Name() == "group" || fd.Name() == "groups"):
+			if !fd.IsList() {
+				add(m.Get(fd).String())
+				continue
+			}
+			l := m.Get(fd).List()
+			for j := 0; j < l.Len(); j++ {
+				add(l.Get(j).String())
+			}
+		case fd.Kind() == protoreflect.MessageKind && !fd.IsMap() && mayCarryGroup(fd.Message()):
+			if !fd.IsList() {
+				if m.Has(fd) {
+					collectGroups(m.Get(fd).Message(), add)
+				}
+				continue
+			}
+			l := m.Get(fd).List()
+			for j := 0; j < l.Len(); j++ {
+				collectGroups(l.Get(j).Message(), add)
+			}
+		}
+	}
+}
+
+// mayCarryGroup prunes the messages which carry no group, e.g. the tags of a write.
+func mayCarryGroup(md protoreflect.MessageDescriptor) bool {
+	if md.FullName() == metadataMessage || md.FullName() == groupMessage {
+		return true
+	}
+	fields := md.Fields()
+	return fields.ByName("metadata") != nil || fields.ByName("container") != nil
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	propertyv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/property/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/liaison/auth"
+)
+
+func Test_operationOf(t *testing.T) {
+	tests := map[string]auth.Operation{
+		"/banyandb.stream.v1.StreamService/Query":                    auth.OperationRead,
+		"/banyandb.measure.v1.MeasureService/TopN":                   auth.OperationRead,
+		"/banyandb.stream.v1.StreamService/BulkWrite":                auth.OperationWrite,
+		"/banyandb.property.v1.PropertyService/Apply":                auth.OperationWrite,
+		"/banyandb.stream.v1.StreamService/Purge":                    auth.OperationAdmin,
+		"/banyandb.database.v1.StreamRegistryService/Get":            auth.OperationRead,
+		"/banyandb.database.v1.StreamRegistryService/Create":         auth.OperationAdmin,
+		"/banyandb.database.v1.GroupRegistryService/Delete":          auth.OperationAdmin,
+		"/banyandb.database.v1.ClusterStateService/GetDrainStatus":   auth.OperationRead,
+		"/banyandb.database.v1.ClusterStateService/DrainNode":        auth.OperationAdmin,
+		"/arrow.flight.protocol.FlightService/DoPut":                 auth.OperationWrite,
+		"/banyandb.database.v1.SnapshotService/Import":               auth.OperationAdmin,
+		"/banyandb.database.v1.DeadLetterService/Replay":             auth.OperationAdmin,
+		"/banyandb.database.v1.IndexRuleBindingRegistryService/List": auth.OperationRead,
+	}
+	for method, want := range tests {
+		assert.Equal(t, want, operationOf(method), method)
+	}
+}
+
+func Test_requestGroups(t *testing.T) {
+	tests := map[string]struct {
+		req  proto.Message
+		want []string
+	}{
+		"metadata": {
+			req:  &streamv1.QueryRequest{Metadata: &commonv1.Metadata{Group: "sw", Name: "segment"}, Groups: []string{"sw_a", "sw"}},
+			want: []string{"sw", "sw_a"},
+		},
+		"bulk elements": {
+			req: &streamv1.BulkWriteRequest{Elements: []*streamv1.WriteRequest{
+				{Metadata: &commonv1.Metadata{Group: "sw_a", Name: "segment"}},
+				{Metadata: &commonv1.Metadata{Group: "sw_b", Name: "segment"}},
+				{Metadata: &commonv1.Metadata{Group: "sw_a", Name: "log"}},
+			}},
+			want: []string{"sw_a", "sw_b"},
+		},
+		"property container": {
+			req: &propertyv1.ApplyRequest{Property: &propertyv1.Property{
+				Metadata: &propertyv1.Metadata{Container: &commonv1.Metadata{Group: "sw", Name: "ui"}, Id: "1"},
+			}},
+			want: []string{"sw"},
+		},
+		"group registry": {
+			req:  &databasev1.GroupRegistryServiceCreateRequest{Group: &commonv1.Group{Metadata: &commonv1.Metadata{Name: "sw"}}},
+			want: []string{"sw"},
+		},
+		"group field": {
+			req:  &databasev1.StreamRegistryServiceListRequest{Group: "sw"},
+			want: []string{"sw"},
+		},
+		"no group": {
+			req: &databasev1.GroupRegistryServiceListRequest{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, requestGroups(tt.req))
+		})
+	}
+}
@@ -0,0 +1,328 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+const (
+	// slowDownDelay is the delay hinted once the data nodes lag behind, which grows with the lag of the write-ahead logs.
+	slowDownDelay = 100 * time.Millisecond
+	// pauseDelay is the delay hinted once the writes are pushed back.
+	pauseDelay = time.Second
+
+	pressureMemory   = "memory"
+	pressureMemtable = "memtable"
+	pressureWAL      = "wal"
+)
+
+var (
+	bulkProvider     = observability.NewMeterProvider(observability.RootScope.SubScope("liaison").SubScope("bulk_write"))
+	bulkBatchCounter = bulkProvider.Counter("batches_total", "kind")
+	bulkHintCounter  = bulkProvider.Counter("backpressure_hints_total", "kind", "level", "reason")
+
+	errBatchIDNotAscending = errors.New("the batch ids should ascend")
+)
+
+type bulkOptions struct {
+	maxInflight     int
+	walLagThreshold int64
+}
+
+type bulkShardKey struct {
+	group   string
+	shardID uint32
+}
+
+type bulkShard struct {
+	nodes    [][]string
+	data     []any
+	failures []bulkFailure
+	keys     []dedupKey
+	r        replication
+}
+
+type bulkFailure struct {
+	metadata  *commonv1.Metadata
+	messageID uint64
+	status    modelv1.Status
+}
+
+// bulkBatch collects the writes of a batch by their shards, which are committed to the nodes of the shards at once.
+type bulkBatch struct {
+	shards   map[bulkShardKey]*bulkShard
+	failures []bulkFailure
+}
+
+func (b *bulkBatch) add(metadata *commonv1.Metadata, messageID uint64, shardID uint32, nodes [][]string, r replication, data any, reserved dedupKey) {
+	key := bulkShardKey{group: metadata.GetGroup(), shardID: shardID}
+	s, ok := b.shards[key]
+	if !ok {
+		s = &bulkShard{nodes: nodes, r: r}
+		b.shards[key] = s
+	}
+	s.data = append(s.data, data)
+	// the failure is reported only if the shard fails to commit
+	s.failures = append(s.failures, bulkFailure{metadata: metadata, messageID: messageID, status: modelv1.Status_STATUS_INTERNAL_ERROR})
+	s.keys = append(s.keys, reserved)
+}
+
+func (b *bulkBatch) fail(metadata *commonv1.Metadata, messageID uint64, st modelv1.Status) {
+	b.failures = append(b.failures, bulkFailure{metadata: metadata, messageID: messageID, status: st})
+}
+
+// bulkReply is the acknowledgement of a batch.
+type bulkReply struct {
+	hint     *modelv1.Backpressure
+	failures []bulkFailure
+	batchID  uint64
+	offset   uint64
+}
+
+// commitTracker computes the commit offset of the batches of a call,
+// which is the largest batch id whose batches up to it are all acknowledged.
+type commitTracker struct {
+	acked   map[uint64]struct{}
+	pending []uint64
+	last    uint64
+	offset  uint64
+	sync.Mutex
+}
+
+func newCommitTracker() *commitTracker {
+	return &commitTracker{acked: make(map[uint64]struct{})}
+}
+
+func (ct *commitTracker) receive(batchID uint64) error {
+	ct.Lock()
+	defer ct.Unlock()
+	if batchID <= ct.last {
+		return errors.WithMessagef(errBatchIDNotAscending, "batch %d is received after %d", batchID, ct.last)
+	}
+	ct.last = batchID
+	ct.pending = append(ct.pending, batchID)
+	return nil
+}
+
+func (ct *commitTracker) ack(batchID uint64) uint64 {
+	ct.Lock()
+	defer ct.Unlock()
+	ct.acked[batchID] = struct{}{}
+	for len(ct.pending) > 0 {
+		if _, ok := ct.acked[ct.pending[0]]; !ok {
+			break
+		}
+		ct.offset = ct.pending[0]
+		delete(ct.acked, ct.pending[0])
+		ct.pending = ct.pending[1:]
+	}
+	return ct.offset
+}
+
+// bulkSession pipelines the batches of a BulkWrite call, at most maxInflight batches are committed at the same time.
+// The batches are acknowledged in the order of their commits, along with the commit offset and the hint of the backpressure.
+type bulkSession struct {
+	ctx       context.Context
+	committer queue.CommitPublisher
+	dedup     *deduplicator
+	log       *logger.Logger
+	status    queue.NodeStatus
+	lagging   *laggingReplicas
+	tracker   *commitTracker
+	inflight  chan struct{}
+	sendErr   error
+	topic     bus.Topic
+	kind      string
+	opts      bulkOptions
+	wg        sync.WaitGroup
+	sendMu    sync.Mutex
+}
+
+func (ds *discoveryService) newBulkSession(ctx context.Context, pipeline queue.Client, topic bus.Topic, log *logger.Logger) (*bulkSession, error) {
+	committer, ok := pipeline.(queue.CommitPublisher)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "the pipeline can't commit the bulk writes")
+	}
+	maxInflight := ds.bulk.maxInflight
+	if maxInflight < 1 {
+		maxInflight = 1
+	}
+	bs := &bulkSession{
+		ctx:       ctx,
+		committer: committer,
+		dedup:     ds.dedup,
+		log:       log,
+		tracker:   newCommitTracker(),
+		inflight:  make(chan struct{}, maxInflight),
+		topic:     topic,
+		kind:      ds.kind.String(),
+		opts:      ds.bulk,
+		lagging:   ds.lagging,
+	}
+	// the standalone server has no data node to learn the pressure from, its own pressure is used instead
+	if s, isStatus := pipeline.(queue.NodeStatus); isStatus {
+		bs.status = s
+	}
+	return bs, nil
+}
+
+// submit builds the batch and commits it in the background, then replies the acknowledgement.
+// It blocks while maxInflight batches are being committed.
+func (bs *bulkSession) submit(batchID uint64, build func(b *bulkBatch), reply func(r *bulkReply) error) error {
+	if err := bs.tracker.receive(batchID); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	select {
+	case bs.inflight <- struct{}{}:
+	case <-bs.ctx.Done():
+		return bs.ctx.Err()
+	}
+	bs.wg.Add(1)
+	go func() {
+		defer func() {
+			<-bs.inflight
+			bs.wg.Done()
+		}()
+		b := &bulkBatch{shards: make(map[bulkShardKey]*bulkShard)}
+		build(b)
+		nodes := bs.commit(b)
+		bulkBatchCounter.Inc(1, bs.kind)
+		r := &bulkReply{batchID: batchID, failures: b.failures, hint: bs.hint(nodes)}
+		bs.sendMu.Lock()
+		defer bs.sendMu.Unlock()
+		r.offset = bs.tracker.ack(batchID)
+		if bs.sendErr != nil {
+			return
+		}
+		bs.sendErr = reply(r)
+	}()
+	return nil
+}
+
+// wait returns once the acknowledgements of the batches submitted are replied.
+func (bs *bulkSession) wait() error {
+	bs.wg.Wait()
+	return bs.sendErr
+}
+
+// commit publishes the shards of the batch in parallel, and returns the nodes written.
+// The writes of a shard failing to reach the quorum are appended to the failures of the batch, and their idempotency keys are released.
+// The keys of the others are committed.
+func (bs *bulkSession) commit(b *bulkBatch) map[string]struct{} {
+	nodes := make(map[string]struct{})
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for key, s := range b.shards {
+		for _, replicaNodes := range s.nodes {
+			for _, n := range replicaNodes {
+				nodes[n] = struct{}{}
+			}
+		}
+		wg.Add(1)
+		go func(key bulkShardKey, s *bulkShard) {
+			defer wg.Done()
+			if err := publishCommitReplicas(bs.ctx, bs.committer, bs.topic, s.r, s.nodes, s.data, bs.lagging); err != nil {
+				bs.log.Error().Err(err).Str("group", key.group).Uint32("shard", key.shardID).Int("size", len(s.data)).Msg("failed to commit the bulk writes")
+				for _, k := range s.keys {
+					bs.dedup.release(k)
+				}
+				mu.Lock()
+				b.failures = append(b.failures, s.failures...)
+				mu.Unlock()
+				return
+			}
+			for _, k := range s.keys {
+				bs.dedup.commit(k)
+			}
+		}(key, s)
+	}
+	wg.Wait()
+	return nodes
+}
+
+// hint returns the backpressure of the liaison and the nodes written.
+func (bs *bulkSession) hint(nodes map[string]struct{}) *modelv1.Backpressure {
+	var pressures []*clusterv1.WritePressure
+	if watchdog.Engaged(watchdog.ActionWriteBackpressure) || bs.status == nil {
+		pressures = append(pressures, watchdog.Pressure())
+	}
+	if bs.status != nil {
+		for n := range nodes {
+			if p, ok := bs.status.Pressure(n); ok {
+				pressures = append(pressures, p)
+			}
+		}
+	}
+	h := backpressureOf(pressures, bs.opts.walLagThreshold)
+	if h != nil {
+		bulkHintCounter.Inc(1, bs.kind, h.GetLevel().String(), h.GetReason())
+	}
+	return h
+}
+
+// backpressureOf returns the hint of the most severe pressure, or nil if the writes are kept up with.
+// The writes are paused if they're pushed back under the memory pressure, and they slow down
+// if the write-ahead logs lag behind by walLagThreshold or the flushes of the memory parts are delayed.
+func backpressureOf(pressures []*clusterv1.WritePressure, walLagThreshold int64) *modelv1.Backpressure {
+	var walLag uint64
+	var flushDelayed bool
+	for _, p := range pressures {
+		if p.GetWriteBackpressure() {
+			return &modelv1.Backpressure{
+				Level:  modelv1.BackpressureLevel_BACKPRESSURE_LEVEL_PAUSE,
+				Delay:  durationpb.New(pauseDelay),
+				Reason: pressureMemory,
+			}
+		}
+		flushDelayed = flushDelayed || p.GetFlushDelayed()
+		walLag = max(walLag, p.GetUnflushedWalBytes())
+	}
+	if walLagThreshold > 0 && walLag >= uint64(walLagThreshold) {
+		// the delay grows with the lag, up to the one of pausing
+		return &modelv1.Backpressure{
+			Level:  modelv1.BackpressureLevel_BACKPRESSURE_LEVEL_SLOW_DOWN,
+			Delay:  durationpb.New(min(time.Duration(walLag/uint64(walLagThreshold))*slowDownDelay, pauseDelay)),
+			Reason: pressureWAL,
+		}
+	}
+	if flushDelayed {
+		return &modelv1.Backpressure{
+			Level:  modelv1.BackpressureLevel_BACKPRESSURE_LEVEL_SLOW_DOWN,
+			Delay:  durationpb.New(slowDownDelay),
+			Reason: pressureMemtable,
+		}
+	}
+	return nil
+}
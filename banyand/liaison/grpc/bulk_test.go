@@ -0,0 +1,89 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+func Test_commitTracker(t *testing.T) {
+	ct := newCommitTracker()
+	for _, id := range []uint64{1, 2, 5, 7} {
+		require.NoError(t, ct.receive(id))
+	}
+	assert.ErrorIs(t, ct.receive(7), errBatchIDNotAscending)
+	assert.ErrorIs(t, ct.receive(3), errBatchIDNotAscending)
+
+	// the offset stays until the batches before the acknowledged one are all acknowledged
+	assert.Equal(t, uint64(0), ct.ack(2))
+	assert.Equal(t, uint64(0), ct.ack(5))
+	assert.Equal(t, uint64(5), ct.ack(1))
+	assert.Equal(t, uint64(7), ct.ack(7))
+	require.NoError(t, ct.receive(8))
+	assert.Equal(t, uint64(8), ct.ack(8))
+}
+
+func Test_backpressureOf(t *testing.T) {
+	const threshold = 100
+	tests := []struct {
+		want      *modelv1.Backpressure
+		name      string
+		pressures []*clusterv1.WritePressure
+	}{
+		{
+			name:      "keep up",
+			pressures: []*clusterv1.WritePressure{{UnflushedWalBytes: threshold - 1}, {}},
+		},
+		{
+			name:      "memtable",
+			pressures: []*clusterv1.WritePressure{{}, {FlushDelayed: true}},
+			want:      &modelv1.Backpressure{Level: modelv1.BackpressureLevel_BACKPRESSURE_LEVEL_SLOW_DOWN, Reason: pressureMemtable},
+		},
+		{
+			name:      "wal",
+			pressures: []*clusterv1.WritePressure{{FlushDelayed: true}, {UnflushedWalBytes: 3 * threshold}},
+			want:      &modelv1.Backpressure{Level: modelv1.BackpressureLevel_BACKPRESSURE_LEVEL_SLOW_DOWN, Reason: pressureWAL},
+		},
+		{
+			name:      "memory",
+			pressures: []*clusterv1.WritePressure{{UnflushedWalBytes: 3 * threshold}, {WriteBackpressure: true}},
+			want:      &modelv1.Backpressure{Level: modelv1.BackpressureLevel_BACKPRESSURE_LEVEL_PAUSE, Reason: pressureMemory},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := backpressureOf(tt.pressures, threshold)
+			if tt.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+			require.NotNil(t, got)
+			assert.Equal(t, tt.want.GetLevel(), got.GetLevel())
+			assert.Equal(t, tt.want.GetReason(), got.GetReason())
+		})
+	}
+	assert.Equal(t, 3*slowDownDelay, backpressureOf([]*clusterv1.WritePressure{{UnflushedWalBytes: 3 * threshold}}, threshold).GetDelay().AsDuration())
+	assert.Equal(t, pauseDelay, backpressureOf([]*clusterv1.WritePressure{{UnflushedWalBytes: 1000 * threshold}}, threshold).GetDelay().AsDuration())
+	assert.Nil(t, backpressureOf([]*clusterv1.WritePressure{{UnflushedWalBytes: 1 << 40}}, 0))
+}
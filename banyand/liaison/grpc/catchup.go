@@ -0,0 +1,517 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"golang.org/x/time/rate"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/api/data"
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+// maxShipChunk is the max size of the chunks the data nodes read, which the burst of the rate limit has to hold.
+const maxShipChunk = 1 << 20
+
+var (
+	catchUpProvider        = observability.NewMeterProvider(observability.RootScope.SubScope("liaison").SubScope("replica_catch_up"))
+	laggingReplicasGauge   = catchUpProvider.Gauge("lagging_replicas", "group")
+	shippedSegmentsCounter = catchUpProvider.Counter("segments_total", "group")
+	shippedBytesCounter    = catchUpProvider.Counter("bytes_total", "group")
+	catchUpFailureCounter  = catchUpProvider.Counter("failed_total", "group")
+
+	errNoShipSource = errors.New("no other replica to ship the parts from")
+)
+
+// laggingKey identifies a replica of a shard.
+type laggingKey struct {
+	group   string
+	node    string
+	shardID uint32
+	catalog commonv1.Catalog
+}
+
+// laggingRange is the time range of the writes a replica misses.
+type laggingRange struct {
+	// names are the streams or measures of the writes
+	names   map[string]struct{}
+	begin   time.Time
+	end     time.Time
+	updated time.Time
+	// version increases once the range is extended
+	version uint64
+}
+
+// laggingReplicas records the replicas failing to apply the writes.
+type laggingReplicas struct {
+	ranges map[laggingKey]*laggingRange
+	mu     sync.Mutex
+}
+
+func newLaggingReplicas() *laggingReplicas {
+	return &laggingReplicas{ranges: make(map[laggingKey]*laggingRange)}
+}
+
+// add extends the ranges of the replicas on the node with the writes it fails to apply.
+func (lr *laggingReplicas) add(nodeID string, writes ...any) {
+	if lr == nil {
+		return
+	}
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for _, w := range writes {
+		var key laggingKey
+		var metadata *commonv1.Metadata
+		var ts time.Time
+		switch d := w.(type) {
+		case *streamv1.InternalWriteRequest:
+			metadata = d.GetRequest().GetMetadata()
+			key = laggingKey{group: metadata.GetGroup(), node: nodeID, shardID: d.GetShardId(), catalog: commonv1.Catalog_CATALOG_STREAM}
+			ts = d.GetRequest().GetElement().GetTimestamp().AsTime()
+		case *measurev1.InternalWriteRequest:
+			metadata = d.GetRequest().GetMetadata()
+			key = laggingKey{group: metadata.GetGroup(), node: nodeID, shardID: d.GetShardId(), catalog: commonv1.Catalog_CATALOG_MEASURE}
+			ts = d.GetRequest().GetDataPoint().GetTimestamp().AsTime()
+		default:
+			continue
+		}
+		r, ok := lr.ranges[key]
+		if !ok {
+			r = &laggingRange{names: make(map[string]struct{}), begin: ts, end: ts}
+			lr.ranges[key] = r
+			lr.report(key.group)
+		}
+		r.names[metadata.GetName()] = struct{}{}
+		if ts.Before(r.begin) {
+			r.begin = ts
+		}
+		if ts.After(r.end) {
+			r.end = ts
+		}
+		r.updated = time.Now()
+		r.version++
+	}
+}
+
+// settled returns the copies of the ranges which aren't extended after the deadline.
+func (lr *laggingReplicas) settled(deadline time.Time) map[laggingKey]laggingRange {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	result := make(map[laggingKey]laggingRange)
+	for k, r := range lr.ranges {
+		if r.updated.After(deadline) {
+			continue
+		}
+		c := *r
+		c.names = maps.Clone(r.names)
+		result[k] = c
+	}
+	return result
+}
+
+// has returns true if the replica misses writes.
+func (lr *laggingReplicas) has(key laggingKey) bool {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	_, ok := lr.ranges[key]
+	return ok
+}
+
+// Missing implements queue.ReplicaLag.
+func (lr *laggingReplicas) Missing(nodeID, group string, shardID uint32, begin, end time.Time) bool {
+	if lr == nil {
+		return false
+	}
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for _, catalog := range []commonv1.Catalog{commonv1.Catalog_CATALOG_STREAM, commonv1.Catalog_CATALOG_MEASURE} {
+		r, ok := lr.ranges[laggingKey{group: group, node: nodeID, shardID: shardID, catalog: catalog}]
+		if ok && !r.begin.After(end) && !r.end.Before(begin) {
+			return true
+		}
+	}
+	return false
+}
+
+// remove removes the range of the replica if it isn't extended after the version.
+func (lr *laggingReplicas) remove(key laggingKey, version uint64) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if r, ok := lr.ranges[key]; ok && r.version == version {
+		delete(lr.ranges, key)
+		lr.report(key.group)
+	}
+}
+
+func (lr *laggingReplicas) report(group string) {
+	var n int
+	for k := range lr.ranges {
+		if k.group == group {
+			n++
+		}
+	}
+	laggingReplicasGauge.Set(float64(n), group)
+}
+
+// replicaCatcher catches the lagging replicas up with the others by shipping the parts.
+//
+// A replica failing to apply a write is recorded with the time range of the writes it misses.
+// Once the range settles for an interval, in which the other replicas flush the writes,
+// another replica of the shard archives its flushed parts overlapping the range, which
+// are shipped to the lagging one chunk by chunk through the liaison since the data nodes
+// don't talk to each other. The lagging replica links the archived segments it doesn't have,
+// and adds the archived parts to the measure segments it has, whose data points replace the ones
+// at the same timestamps. The elements of a stream aren't deduplicated, so the stream segments
+// it has are repaired element by element instead.
+//
+// The ranges are kept in the memory of the liaison, which are lost once it restarts.
+// A replica failing to catch up is retried in the next interval.
+type replicaCatcher struct {
+	lagging    *laggingReplicas
+	pipeline   queue.Client
+	streamSVC  *streamService
+	measureSVC *measureService
+	log        *logger.Logger
+	closer     *run.Closer
+	// limiter throttles the shipments, nil means no limit
+	limiter  *rate.Limiter
+	interval time.Duration
+}
+
+func (rc *replicaCatcher) serve() {
+	if rc.interval <= 0 || !rc.closer.AddRunning() {
+		return
+	}
+	go func() {
+		defer rc.closer.Done()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-rc.closer.CloseNotify()
+			cancel()
+		}()
+		ticker := time.NewTicker(rc.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rc.closer.CloseNotify():
+				return
+			case <-ticker.C:
+				rc.catchUp(ctx)
+			}
+		}
+	}()
+}
+
+func (rc *replicaCatcher) stop() {
+	rc.closer.Done()
+	rc.closer.CloseThenWait()
+}
+
+func (rc *replicaCatcher) catchUp(ctx context.Context) {
+	for key, r := range rc.lagging.settled(time.Now().Add(-rc.interval)) {
+		if err := rc.catchUpReplica(ctx, key, r); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			catchUpFailureCounter.Inc(1, key.group)
+			rc.log.Warn().Err(err).Str("group", key.group).Str("node", key.node).Uint32("shard", key.shardID).
+				Msg("failed to catch the replica up, retry in the next interval")
+			continue
+		}
+		rc.lagging.remove(key, r.version)
+		rc.log.Info().Str("group", key.group).Str("node", key.node).Uint32("shard", key.shardID).
+			Time("begin", r.begin).Time("end", r.end).Msg("the replica caught up")
+	}
+}
+
+// catchUpReplica ships the parts from one of the other replicas in turn until a shipment succeeds.
+func (rc *replicaCatcher) catchUpReplica(ctx context.Context, key laggingKey, r laggingRange) error {
+	sources, err := rc.sources(key, r)
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return errNoShipSource
+	}
+	var errShip error
+	for _, source := range sources {
+		merged, errOne := rc.ship(ctx, source, key, r, nil)
+		if errOne != nil {
+			errShip = multierr.Append(errShip, errors.WithMessagef(errOne, "failed to ship from node %s", source))
+			continue
+		}
+		shippedSegmentsCounter.Inc(float64(merged.GetSegments()), key.group)
+		if key.catalog != commonv1.Catalog_CATALOG_STREAM || merged.GetSkippedSegments() == 0 {
+			return nil
+		}
+		return rc.repairStreams(ctx, key, r)
+	}
+	return errShip
+}
+
+// sources returns the owners of the other replicas of the shard, which don't lag behind.
+func (rc *replicaCatcher) sources(key laggingKey, r laggingRange) ([]string, error) {
+	ds := rc.measureSVC.discoveryService
+	if key.catalog == commonv1.Catalog_CATALOG_STREAM {
+		ds = rc.streamSVC.discoveryService
+	}
+	var sources []string
+	seen := map[string]struct{}{key.node: {}}
+	for name := range r.names {
+		nodes, _, err := ds.locateReplicas(&commonv1.Metadata{Group: key.group, Name: name}, common.ShardID(key.shardID))
+		if err != nil {
+			return nil, err
+		}
+		for _, replicaNodes := range nodes {
+			n := replicaNodes[0]
+			if _, ok := seen[n]; ok {
+				continue
+			}
+			seen[n] = struct{}{}
+			lagging := key
+			lagging.node = n
+			if !rc.lagging.has(lagging) {
+				sources = append(sources, n)
+			}
+		}
+	}
+	return sources, nil
+}
+
+// ship archives the parts on the source, copies the files of the shipment to the lagging replica, then merges them.
+// The stream segments the lagging replica has are left out, which are counted as the skipped segments along with
+// the ones the merge skips.
+// The progress is called once all files of a part are copied if it isn't nil.
+func (rc *replicaCatcher) ship(ctx context.Context, source string, key laggingKey, r laggingRange,
+	progress func(copiedParts, totalParts uint32),
+) (*databasev1.SnapshotArchive, error) {
+	id := fmt.Sprintf("catch-up-%d", time.Now().UnixNano())
+	release := func(nodeID string) {
+		if _, err := rc.askNode(nodeID, &clusterv1.PartShipRequest{Action: clusterv1.PartShipAction_PART_SHIP_ACTION_RELEASE, Id: id}); err != nil {
+			rc.log.Warn().Err(err).Str("node", nodeID).Str("id", id).Msg("failed to release the shipment")
+		}
+	}
+	archive := &clusterv1.PartShipRequest{
+		Action:  clusterv1.PartShipAction_PART_SHIP_ACTION_ARCHIVE,
+		Id:      id,
+		Catalog: key.catalog,
+		Group:   key.group,
+		ShardId: key.shardID,
+		Begin:   timestamppb.New(r.begin),
+		End:     timestamppb.New(r.end),
+	}
+	var existing map[string]struct{}
+	if key.catalog == commonv1.Catalog_CATALOG_STREAM {
+		// archiving the same range on the lagging replica tells the segments it has
+		own, err := rc.askNode(key.node, archive)
+		release(key.node)
+		if err != nil {
+			return nil, err
+		}
+		existing = segmentsOf(own.GetFiles())
+	}
+	defer release(source)
+	defer release(key.node)
+	archived, err := rc.askNode(source, archive)
+	if err != nil {
+		return nil, err
+	}
+	var files []*clusterv1.ShippedFile
+	for _, f := range archived.GetFiles() {
+		if seg, ok := segmentOf(f.GetPath()); ok {
+			if _, skipped := existing[seg]; skipped {
+				continue
+			}
+		}
+		files = append(files, f)
+	}
+	remaining := filesPerPart(files)
+	totalParts, copiedParts := uint32(len(remaining)), uint32(0)
+	for _, f := range files {
+		if err = rc.copyFile(ctx, source, key, id, f); err != nil {
+			return nil, err
+		}
+		p, ok := partOf(f.GetPath())
+		if !ok {
+			continue
+		}
+		if remaining[p]--; remaining[p] == 0 && progress != nil {
+			copiedParts++
+			progress(copiedParts, totalParts)
+		}
+	}
+	merged, err := rc.askNode(key.node, &clusterv1.PartShipRequest{
+		Action:  clusterv1.PartShipAction_PART_SHIP_ACTION_MERGE,
+		Id:      id,
+		Catalog: key.catalog,
+		Group:   key.group,
+		ShardId: key.shardID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := merged.GetArchive()
+	if result == nil {
+		result = &databasev1.SnapshotArchive{}
+	}
+	for seg := range segmentsOf(archived.GetFiles()) {
+		if _, skipped := existing[seg]; skipped {
+			result.SkippedSegments++
+			result.SkippedSegmentSuffixes = append(result.SkippedSegmentSuffixes, suffixOf(seg))
+		}
+	}
+	return result, nil
+}
+
+// segmentOf returns the segment directory of a file in an archive, which is laid out as shard-<id>/seg-<suffix>/...
+func segmentOf(path string) (string, bool) {
+	elems := strings.SplitN(path, "/", 3)
+	if len(elems) < 3 {
+		return "", false
+	}
+	return elems[0] + "/" + elems[1], true
+}
+
+// suffixOf returns the suffix of a segment directory returned by segmentOf.
+func suffixOf(seg string) string {
+	return strings.TrimPrefix(seg[strings.Index(seg, "/")+1:], "seg-")
+}
+
+// partOf returns the part directory of a file in an archive, which is laid out as shard-<id>/seg-<suffix>/<part>/...
+func partOf(path string) (string, bool) {
+	elems := strings.SplitN(path, "/", 4)
+	if len(elems) < 4 {
+		return "", false
+	}
+	return strings.Join(elems[:3], "/"), true
+}
+
+// filesPerPart counts the files of every part.
+func filesPerPart(files []*clusterv1.ShippedFile) map[string]int {
+	result := make(map[string]int)
+	for _, f := range files {
+		if p, ok := partOf(f.GetPath()); ok {
+			result[p]++
+		}
+	}
+	return result
+}
+
+func segmentsOf(files []*clusterv1.ShippedFile) map[string]struct{} {
+	result := make(map[string]struct{})
+	for _, f := range files {
+		if seg, ok := segmentOf(f.GetPath()); ok {
+			result[seg] = struct{}{}
+		}
+	}
+	return result
+}
+
+func (rc *replicaCatcher) copyFile(ctx context.Context, source string, key laggingKey, id string, f *clusterv1.ShippedFile) error {
+	write := func(offset uint64, chunk []byte) error {
+		_, err := rc.askNode(key.node, &clusterv1.PartShipRequest{
+			Action: clusterv1.PartShipAction_PART_SHIP_ACTION_WRITE,
+			Id:     id,
+			Path:   f.GetPath(),
+			Offset: offset,
+			Data:   chunk,
+		})
+		return err
+	}
+	if f.GetSize() == 0 {
+		return write(0, nil)
+	}
+	for offset := uint64(0); offset < f.GetSize(); {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		read, err := rc.askNode(source, &clusterv1.PartShipRequest{
+			Action: clusterv1.PartShipAction_PART_SHIP_ACTION_READ,
+			Id:     id,
+			Path:   f.GetPath(),
+			Offset: offset,
+		})
+		if err != nil {
+			return err
+		}
+		if len(read.GetData()) == 0 {
+			return errors.Errorf("the file %s of %d bytes ends at %d", f.GetPath(), f.GetSize(), offset)
+		}
+		if rc.limiter != nil {
+			if err = rc.limiter.WaitN(ctx, len(read.GetData())); err != nil {
+				return err
+			}
+		}
+		if err = write(offset, read.GetData()); err != nil {
+			return err
+		}
+		offset += uint64(len(read.GetData()))
+		shippedBytesCounter.Inc(float64(len(read.GetData())), key.group)
+	}
+	return nil
+}
+
+// repairStreams copies the elements the lagging replica misses in the stream segments it has.
+func (rc *replicaCatcher) repairStreams(ctx context.Context, key laggingKey, r laggingRange) error {
+	timeRange := &modelv1.TimeRange{Begin: timestamppb.New(r.begin), End: timestamppb.New(r.end.Add(time.Nanosecond))}
+	for name := range r.names {
+		if _, err := rc.streamSVC.repair(ctx, &commonv1.Metadata{Group: key.group, Name: name}, timeRange); err != nil {
+			return errors.WithMessagef(err, "failed to repair stream %s", name)
+		}
+	}
+	return nil
+}
+
+func (rc *replicaCatcher) askNode(nodeID string, req *clusterv1.PartShipRequest) (*clusterv1.PartShipResponse, error) {
+	f, err := rc.pipeline.Publish(data.TopicPartShip, bus.NewMessageWithNode(bus.MessageID(time.Now().UnixNano()), nodeID, req))
+	if err != nil {
+		return nil, err
+	}
+	m, err := f.Get()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "node %s fails to %s", nodeID, req.GetAction())
+	}
+	switch d := m.Data().(type) {
+	case *clusterv1.PartShipResponse:
+		return d, nil
+	case common.Error:
+		return nil, errors.Errorf("node %s fails to %s: %s", nodeID, req.GetAction(), d.Msg())
+	}
+	return nil, errors.WithMessagef(errUnexpectedReply, "node %s replies %T", nodeID, m.Data())
+}
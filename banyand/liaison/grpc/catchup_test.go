@@ -0,0 +1,96 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+)
+
+func Test_laggingReplicas(t *testing.T) {
+	lr := newLaggingReplicas()
+	base := time.Unix(1700000000, 0)
+	measureWrite := func(name string, ts time.Time) *measurev1.InternalWriteRequest {
+		return &measurev1.InternalWriteRequest{
+			ShardId: 1,
+			Request: &measurev1.WriteRequest{
+				Metadata:  &commonv1.Metadata{Group: "sw_metric", Name: name},
+				DataPoint: &measurev1.DataPointValue{Timestamp: timestamppb.New(ts)},
+			},
+		}
+	}
+	lr.add("data-1", measureWrite("service_cpm_minute", base.Add(time.Minute)), measureWrite("instance_cpm_minute", base))
+	lr.add("data-1", &streamv1.InternalWriteRequest{
+		ShardId: 1,
+		Request: &streamv1.WriteRequest{
+			Metadata: &commonv1.Metadata{Group: "sw_stream", Name: "sw"},
+			Element:  &streamv1.ElementValue{Timestamp: timestamppb.New(base)},
+		},
+	})
+	// the writes of other kinds are ignored
+	lr.add("data-1", "unknown")
+
+	measureKey := laggingKey{group: "sw_metric", node: "data-1", shardID: 1, catalog: commonv1.Catalog_CATALOG_MEASURE}
+	streamKey := laggingKey{group: "sw_stream", node: "data-1", shardID: 1, catalog: commonv1.Catalog_CATALOG_STREAM}
+	assert.True(t, lr.has(measureKey))
+	assert.True(t, lr.has(streamKey))
+
+	// the ranges aren't settled until the deadline passes their last update
+	assert.Empty(t, lr.settled(time.Now().Add(-time.Hour)))
+	ranges := lr.settled(time.Now())
+	require.Len(t, ranges, 2)
+	r := ranges[measureKey]
+	assert.Equal(t, base, r.begin)
+	assert.Equal(t, base.Add(time.Minute), r.end)
+	assert.Len(t, r.names, 2)
+
+	// the range extended after it's settled isn't removed
+	lr.add("data-1", measureWrite("service_cpm_minute", base.Add(2*time.Minute)))
+	lr.remove(measureKey, r.version)
+	assert.True(t, lr.has(measureKey))
+	lr.remove(measureKey, lr.settled(time.Now())[measureKey].version)
+	assert.False(t, lr.has(measureKey))
+	assert.True(t, lr.has(streamKey))
+
+	var nilReplicas *laggingReplicas
+	nilReplicas.add("data-1", measureWrite("service_cpm_minute", base))
+}
+
+func Test_segmentOf(t *testing.T) {
+	seg, ok := segmentOf("shard-0/seg-20240101/part-1/meta.bin")
+	assert.True(t, ok)
+	assert.Equal(t, "shard-0/seg-20240101", seg)
+	assert.Equal(t, "20240101", suffixOf(seg))
+	_, ok = segmentOf("series.json")
+	assert.False(t, ok)
+	assert.Len(t, segmentsOf([]*clusterv1.ShippedFile{
+		{Path: "shard-0/seg-20240101/part-1/meta.bin"},
+		{Path: "shard-0/seg-20240101/part-2/meta.bin"},
+		{Path: "shard-0/seg-20240102/part-3/meta.bin"},
+		{Path: "series.json"},
+	}), 2)
+}
@@ -0,0 +1,101 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/node"
+)
+
+type clusterStateServer struct {
+	databasev1.UnimplementedClusterStateServiceServer
+	nodeRegistry    NodeRegistry
+	pipeline        queue.Client
+	metadataRepo    metadata.Repo
+	streamSVC       *streamService
+	measureSVC      *measureService
+	decommissions   map[string]*decommission
+	decommissionsMu sync.Mutex
+}
+
+func (cs *clusterStateServer) ListShardAssignments(_ context.Context, _ *databasev1.ClusterStateServiceListShardAssignmentsRequest) (
+	*databasev1.ClusterStateServiceListShardAssignmentsResponse, error,
+) {
+	assignments := cs.nodeRegistry.ShardAssignments()
+	resp := &databasev1.ClusterStateServiceListShardAssignmentsResponse{
+		Assignments: make([]*databasev1.ShardAssignment, 0, len(assignments)),
+	}
+	for _, a := range assignments {
+		sa := &databasev1.ShardAssignment{
+			Group:   a.Key.Group,
+			Name:    a.Key.Name,
+			ShardId: a.Key.ShardID,
+			Node:    a.Node,
+		}
+		if a.Move != nil {
+			sa.Move = toShardMove(a.Move)
+			sa.Move.Lag = durationpb.New(a.Move.Lag)
+			sa.Move.StartedAt = timestamppb.New(a.Move.StartedAt)
+		}
+		resp.Assignments = append(resp.Assignments, sa)
+	}
+	return resp, nil
+}
+
+func (cs *clusterStateServer) PlanShardMoves(_ context.Context, _ *databasev1.ClusterStateServicePlanShardMovesRequest) (
+	*databasev1.ClusterStateServicePlanShardMovesResponse, error,
+) {
+	moves := cs.nodeRegistry.PlanShardMoves()
+	resp := &databasev1.ClusterStateServicePlanShardMovesResponse{
+		Moves: make([]*databasev1.ShardAssignment, 0, len(moves)),
+	}
+	for i := range moves {
+		resp.Moves = append(resp.Moves, &databasev1.ShardAssignment{
+			Group:   moves[i].Key.Group,
+			Name:    moves[i].Key.Name,
+			ShardId: moves[i].Key.ShardID,
+			Node:    moves[i].From,
+			Move:    toShardMove(&moves[i]),
+		})
+	}
+	return resp, nil
+}
+
+func toShardMove(m *node.Move) *databasev1.ShardMove {
+	return &databasev1.ShardMove{
+		From:        m.From,
+		To:          m.To,
+		State:       m.State.String(),
+		CopiedParts: m.CopiedParts,
+		TotalParts:  m.TotalParts,
+	}
+}
+
+func (cs *clusterStateServer) Repair(ctx context.Context, req *databasev1.ClusterStateServiceRepairRequest) (
+	*databasev1.ClusterStateServiceRepairResponse, error,
+) {
+	return cs.streamSVC.repair(ctx, req.GetMetadata(), req.GetTimeRange())
+}
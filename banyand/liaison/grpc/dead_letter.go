@@ -0,0 +1,383 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+)
+
+const (
+	deadLetterFile     = "dead-letters"
+	deadLetterTempFile = "dead-letters.tmp"
+	// reasonShardRouting is the reason of the writes which can't be routed to a shard.
+	reasonShardRouting = "shard_routing"
+)
+
+// deadLetterStore keeps the writes rejected by the liaison in a directory of every group under root.
+// The file of a group is a sequence of the dead letters prefixed by their lengths, which is appended by
+// the rejections and rewritten by the removals. The dead letters are kept in memory as well to list them.
+// A group keeps the last maxPerGroup dead letters at most, the older ones are dropped.
+type deadLetterStore struct {
+	groups      map[string]*deadLetterGroup
+	root        string
+	maxPerGroup int
+	sync.Mutex
+}
+
+type deadLetterGroup struct {
+	file    *os.File
+	dir     string
+	letters []*databasev1.DeadLetter
+	nextID  uint64
+}
+
+// newDeadLetterStore opens the store under root, loading the dead letters kept before.
+func newDeadLetterStore(root string, maxPerGroup int) (*deadLetterStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, errors.WithMessagef(err, "failed to create the dead-letter root %s", root)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	s := &deadLetterStore{
+		groups:      make(map[string]*deadLetterGroup),
+		root:        root,
+		maxPerGroup: maxPerGroup,
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		g, errLoad := openDeadLetterGroup(filepath.Join(root, e.Name()))
+		if errLoad != nil {
+			_ = s.close()
+			return nil, errors.WithMessagef(errLoad, "failed to load the dead letters of %s", e.Name())
+		}
+		s.groups[e.Name()] = g
+	}
+	return s, nil
+}
+
+func openDeadLetterGroup(dir string) (*deadLetterGroup, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	g := &deadLetterGroup{dir: dir, nextID: 1}
+	path := filepath.Join(dir, deadLetterFile)
+	var torn bool
+	if f, err := os.Open(path); err == nil {
+		g.letters, torn, err = readDeadLetters(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(g.letters) > 0 {
+			g.nextID = g.letters[len(g.letters)-1].GetId() + 1
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	var err error
+	if g.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return nil, err
+	}
+	// the torn tail is dropped, otherwise the records appended after it can't be read
+	if torn {
+		if err = g.rewrite(g.letters); err != nil {
+			_ = g.file.Close()
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// readDeadLetters reads the dead letters of a file and reports whether its last record is torn by a crash.
+// The records before the torn one are kept.
+func readDeadLetters(r io.Reader) ([]*databasev1.DeadLetter, bool, error) {
+	br := bufio.NewReader(r)
+	var letters []*databasev1.DeadLetter
+	for {
+		size, err := binary.ReadUvarint(br)
+		if errors.Is(err, io.EOF) {
+			return letters, false, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return letters, true, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		buf := make([]byte, size)
+		if _, err = io.ReadFull(br, buf); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+				return letters, true, nil
+			}
+			return nil, false, err
+		}
+		dl := &databasev1.DeadLetter{}
+		if err = proto.Unmarshal(buf, dl); err != nil {
+			return nil, false, err
+		}
+		letters = append(letters, dl)
+	}
+}
+
+func appendDeadLetter(dst []byte, dl *databasev1.DeadLetter) ([]byte, error) {
+	data, err := proto.Marshal(dl)
+	if err != nil {
+		return dst, err
+	}
+	dst = binary.AppendUvarint(dst, uint64(len(data)))
+	return append(dst, data...), nil
+}
+
+// add assigns the dead letter an id and keeps it in the store of the group.
+func (s *deadLetterStore) add(group string, dl *databasev1.DeadLetter) error {
+	s.Lock()
+	defer s.Unlock()
+	g, ok := s.groups[group]
+	if !ok {
+		var err error
+		if g, err = openDeadLetterGroup(filepath.Join(s.root, group)); err != nil {
+			return err
+		}
+		s.groups[group] = g
+	}
+	dl.Id = g.nextID
+	dl.RejectedAt = timestamppb.Now()
+	data, err := appendDeadLetter(nil, dl)
+	if err != nil {
+		return err
+	}
+	if _, err = g.file.Write(data); err != nil {
+		return err
+	}
+	g.nextID++
+	g.letters = append(g.letters, dl)
+	// the file is rewritten once a quarter more than the bound is kept, rather than on every rejection
+	if s.maxPerGroup > 0 && len(g.letters) > s.maxPerGroup+s.maxPerGroup/4 {
+		return g.rewrite(g.letters[len(g.letters)-s.maxPerGroup:])
+	}
+	return nil
+}
+
+// list returns the dead letters of the group after the id afterID, limit of them at most.
+func (s *deadLetterStore) list(group string, afterID uint64, limit int) []*databasev1.DeadLetter {
+	s.Lock()
+	defer s.Unlock()
+	g, ok := s.groups[group]
+	if !ok {
+		return nil
+	}
+	i := sort.Search(len(g.letters), func(i int) bool {
+		return g.letters[i].GetId() > afterID
+	})
+	letters := g.letters[i:]
+	if limit > 0 && len(letters) > limit {
+		letters = letters[:limit]
+	}
+	result := make([]*databasev1.DeadLetter, len(letters))
+	for j := range letters {
+		result[j] = proto.Clone(letters[j]).(*databasev1.DeadLetter)
+	}
+	return result
+}
+
+// remove drops the dead letters of the group by their ids, or all of them if ids is nil,
+// and returns the number of the dropped ones.
+func (s *deadLetterStore) remove(group string, ids map[uint64]struct{}) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	g, ok := s.groups[group]
+	if !ok {
+		return 0, nil
+	}
+	kept := make([]*databasev1.DeadLetter, 0, len(g.letters))
+	for _, dl := range g.letters {
+		if _, ok = ids[dl.GetId()]; ids != nil && !ok {
+			kept = append(kept, dl)
+		}
+	}
+	removed := len(g.letters) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, g.rewrite(kept)
+}
+
+// rewrite replaces the file of the group by the dead letters kept, through a temporary file renamed to it.
+func (g *deadLetterGroup) rewrite(kept []*databasev1.DeadLetter) error {
+	var data []byte
+	var err error
+	for _, dl := range kept {
+		if data, err = appendDeadLetter(data, dl); err != nil {
+			return err
+		}
+	}
+	tmp := filepath.Join(g.dir, deadLetterTempFile)
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	path := filepath.Join(g.dir, deadLetterFile)
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	_ = g.file.Close()
+	if g.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return err
+	}
+	g.letters = append(g.letters[:0:0], kept...)
+	return nil
+}
+
+func (s *deadLetterStore) close() error {
+	s.Lock()
+	defer s.Unlock()
+	var err error
+	for _, g := range s.groups {
+		if g.file != nil {
+			if errClose := g.file.Close(); errClose != nil && err == nil {
+				err = errClose
+			}
+		}
+	}
+	return err
+}
+
+const defaultDeadLetterLimit = 100
+
+type deadLetterServer struct {
+	databasev1.UnimplementedDeadLetterServiceServer
+	store      *deadLetterStore
+	streamSVC  *streamService
+	measureSVC *measureService
+}
+
+func (d *deadLetterServer) checkStore(group string) error {
+	if group == "" {
+		return status.Error(codes.InvalidArgument, "group is absent")
+	}
+	if d.store == nil {
+		return status.Error(codes.FailedPrecondition, "the dead-letter store is disabled in the liaison")
+	}
+	return nil
+}
+
+// List returns the dead letters of a group in the order of their ids.
+func (d *deadLetterServer) List(_ context.Context, req *databasev1.DeadLetterServiceListRequest) (*databasev1.DeadLetterServiceListResponse, error) {
+	if err := d.checkStore(req.GetGroup()); err != nil {
+		return nil, err
+	}
+	limit := int(req.GetLimit())
+	if limit <= 0 {
+		limit = defaultDeadLetterLimit
+	}
+	return &databasev1.DeadLetterServiceListResponse{DeadLetters: d.store.list(req.GetGroup(), req.GetAfterId(), limit)}, nil
+}
+
+// Replay writes the dead letters of a group again against the current schemas.
+// The replayed ones are removed from the store, the failed ones are kept.
+func (d *deadLetterServer) Replay(_ context.Context, req *databasev1.DeadLetterServiceReplayRequest) (*databasev1.DeadLetterServiceReplayResponse, error) {
+	if err := d.checkStore(req.GetGroup()); err != nil {
+		return nil, err
+	}
+	var wanted map[uint64]struct{}
+	if len(req.GetIds()) > 0 {
+		wanted = make(map[uint64]struct{}, len(req.GetIds()))
+		for _, id := range req.GetIds() {
+			wanted[id] = struct{}{}
+		}
+	}
+	var streamIDs, measureIDs []uint64
+	var streamReqs []*streamv1.WriteRequest
+	var measureReqs []*measurev1.WriteRequest
+	for _, dl := range d.store.list(req.GetGroup(), 0, 0) {
+		if _, ok := wanted[dl.GetId()]; wanted != nil && !ok {
+			continue
+		}
+		switch r := dl.GetRequest().(type) {
+		case *databasev1.DeadLetter_Stream:
+			streamIDs = append(streamIDs, dl.GetId())
+			streamReqs = append(streamReqs, r.Stream)
+		case *databasev1.DeadLetter_Measure:
+			measureIDs = append(measureIDs, dl.GetId())
+			measureReqs = append(measureReqs, r.Measure)
+		}
+	}
+	replayed := make(map[uint64]struct{})
+	var failed uint32
+	collect := func(ids []uint64, statuses []modelv1.Status) {
+		for i, st := range statuses {
+			if st == modelv1.Status_STATUS_SUCCEED {
+				replayed[ids[i]] = struct{}{}
+				continue
+			}
+			failed++
+		}
+	}
+	if len(streamReqs) > 0 {
+		collect(streamIDs, d.streamSVC.replay(streamReqs))
+	}
+	if len(measureReqs) > 0 {
+		collect(measureIDs, d.measureSVC.replay(measureReqs))
+	}
+	if len(replayed) > 0 {
+		if _, err := d.store.remove(req.GetGroup(), replayed); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to remove the replayed dead letters: %v", err)
+		}
+	}
+	return &databasev1.DeadLetterServiceReplayResponse{Replayed: uint32(len(replayed)), Failed: failed}, nil
+}
+
+// Purge drops the dead letters of a group by their ids, or all of them if no id is given.
+func (d *deadLetterServer) Purge(_ context.Context, req *databasev1.DeadLetterServicePurgeRequest) (*databasev1.DeadLetterServicePurgeResponse, error) {
+	if err := d.checkStore(req.GetGroup()); err != nil {
+		return nil, err
+	}
+	var ids map[uint64]struct{}
+	if len(req.GetIds()) > 0 {
+		ids = make(map[uint64]struct{}, len(req.GetIds()))
+		for _, id := range req.GetIds() {
+			ids[id] = struct{}{}
+		}
+	}
+	purged, err := d.store.remove(req.GetGroup(), ids)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to purge the dead letters: %v", err)
+	}
+	return &databasev1.DeadLetterServicePurgeResponse{Purged: uint32(purged)}, nil
+}
@@ -0,0 +1,95 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+)
+
+func Test_deadLetterStore(t *testing.T) {
+	root := t.TempDir()
+	letter := func(name string) *databasev1.DeadLetter {
+		return &databasev1.DeadLetter{
+			Reason: ruleRequiredTags,
+			Request: &databasev1.DeadLetter_Stream{Stream: &streamv1.WriteRequest{
+				Metadata: &commonv1.Metadata{Group: "sw", Name: name},
+			}},
+		}
+	}
+	ids := func(letters []*databasev1.DeadLetter) []uint64 {
+		result := make([]uint64, 0, len(letters))
+		for _, dl := range letters {
+			result = append(result, dl.GetId())
+		}
+		return result
+	}
+	s, err := newDeadLetterStore(root, 8)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.add("sw", letter("sw")))
+	}
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, ids(s.list("sw", 0, 0)))
+	assert.Equal(t, []uint64{3, 4}, ids(s.list("sw", 2, 2)))
+	assert.Empty(t, s.list("absent", 0, 0))
+
+	removed, err := s.remove("sw", map[uint64]struct{}{2: {}, 4: {}, 9: {}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.Equal(t, []uint64{1, 3, 5}, ids(s.list("sw", 0, 0)))
+	require.NoError(t, s.close())
+
+	// the dead letters and their ids survive the restart, a torn tail is dropped
+	f, err := os.OpenFile(filepath.Join(root, "sw", deadLetterFile), os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0x10, 0x01})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	s, err = newDeadLetterStore(root, 8)
+	require.NoError(t, err)
+	letters := s.list("sw", 0, 0)
+	assert.Equal(t, []uint64{1, 3, 5}, ids(letters))
+	assert.Equal(t, "sw", letters[0].GetStream().GetMetadata().GetName())
+	assert.NotNil(t, letters[0].GetRejectedAt())
+	require.NoError(t, s.add("sw", letter("sw")))
+	assert.Equal(t, []uint64{1, 3, 5, 6}, ids(s.list("sw", 0, 0)))
+	require.NoError(t, s.close())
+	s, err = newDeadLetterStore(root, 8)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 3, 5, 6}, ids(s.list("sw", 0, 0)))
+
+	// the oldest dead letters are dropped once the bound is exceeded by a quarter
+	for i := 0; i < 7; i++ {
+		require.NoError(t, s.add("sw", letter("sw")))
+	}
+	assert.Equal(t, []uint64{6, 7, 8, 9, 10, 11, 12, 13}, ids(s.list("sw", 0, 0)))
+
+	removed, err = s.remove("sw", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 8, removed)
+	assert.Empty(t, s.list("sw", 0, 0))
+	assert.NoError(t, s.close())
+}
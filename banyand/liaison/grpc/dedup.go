@@ -0,0 +1,168 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"sync"
+	"time"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+)
+
+var (
+	dedupProvider       = observability.NewMeterProvider(observability.RootScope.SubScope("liaison").SubScope("deduplication"))
+	deduplicatedCounter = dedupProvider.Counter("deduplicated_total", "group")
+	dedupEvictedCounter = dedupProvider.Counter("evicted_total", "group")
+)
+
+// dedupPolicy is the deduplication of the writes to a group.
+type dedupPolicy struct {
+	window    time.Duration
+	elementID bool
+}
+
+// newDedupPolicy returns the policy of the options, or false if the writes aren't deduplicated.
+func newDedupPolicy(opts *commonv1.Deduplication) (dedupPolicy, bool) {
+	if opts.GetWindow() == nil || opts.GetWindow().AsDuration() <= 0 {
+		return dedupPolicy{}, false
+	}
+	return dedupPolicy{window: opts.GetWindow().AsDuration(), elementID: opts.GetElementId()}, true
+}
+
+// dedupKey identifies a write remembered by the deduplicator. The zero value means the write isn't deduplicated.
+type dedupKey struct {
+	group string
+	key   string
+}
+
+type dedupEntry struct {
+	expireAt time.Time
+	key      string
+}
+
+// dedupState is the state of a key reserved by a write.
+type dedupState struct {
+	expireAt time.Time
+	// committed is false while the write is in flight
+	committed bool
+}
+
+// dedupResult is the outcome of reserving the key of a write.
+type dedupResult int
+
+const (
+	// dedupReserved means the key isn't remembered, the write should go through.
+	dedupReserved dedupResult = iota
+	// dedupCommitted means the write of the key is committed within the window, the write should be skipped.
+	dedupCommitted
+	// dedupInFlight means the write of the key is in flight, whose outcome is unknown yet, the write should be retried later.
+	dedupInFlight
+)
+
+// dedupWindow is the keys of a group, which are evicted in the order of their reservations.
+type dedupWindow struct {
+	keys  map[string]dedupState
+	order []dedupEntry
+}
+
+// deduplicator remembers the idempotency keys of the writes in the windows of their groups,
+// so that the writes retried within the windows are skipped once the first ones are committed.
+// It bounds the keys of a group by maxKeys, beyond which the oldest ones are forgotten before they expire.
+type deduplicator struct {
+	groups  map[string]*dedupWindow
+	maxKeys int
+	sync.Mutex
+}
+
+func newDeduplicator(maxKeys int) *deduplicator {
+	return &deduplicator{groups: make(map[string]*dedupWindow), maxKeys: maxKeys}
+}
+
+// reserve remembers the key in flight until now+window if it isn't remembered yet.
+// Otherwise, it returns whether the write reserving the key is committed or still in flight.
+func (d *deduplicator) reserve(k dedupKey, window time.Duration, now time.Time) dedupResult {
+	d.Lock()
+	defer d.Unlock()
+	w, ok := d.groups[k.group]
+	if !ok {
+		w = &dedupWindow{keys: make(map[string]dedupState)}
+		d.groups[k.group] = w
+	}
+	w.evict(now)
+	if st, ok := w.keys[k.key]; ok && now.Before(st.expireAt) {
+		if st.committed {
+			return dedupCommitted
+		}
+		return dedupInFlight
+	}
+	for d.maxKeys > 0 && len(w.keys) >= d.maxKeys && w.pop() {
+		dedupEvictedCounter.Inc(1, k.group)
+	}
+	expireAt := now.Add(window)
+	w.keys[k.key] = dedupState{expireAt: expireAt}
+	w.order = append(w.order, dedupEntry{key: k.key, expireAt: expireAt})
+	return dedupReserved
+}
+
+// commit marks the key committed, whose write succeeds, so that retrying it is skipped.
+func (d *deduplicator) commit(k dedupKey) {
+	if k.key == "" {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	if w, ok := d.groups[k.group]; ok {
+		if st, ok := w.keys[k.key]; ok {
+			st.committed = true
+			w.keys[k.key] = st
+		}
+	}
+}
+
+// release forgets the key, whose write fails, so that retrying it goes through.
+func (d *deduplicator) release(k dedupKey) {
+	if k.key == "" {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	if w, ok := d.groups[k.group]; ok {
+		delete(w.keys, k.key)
+	}
+}
+
+func (w *dedupWindow) evict(now time.Time) {
+	for len(w.order) > 0 && !now.Before(w.order[0].expireAt) {
+		w.pop()
+	}
+}
+
+// pop forgets the oldest reservation, and returns false if there is none.
+// A reservation released or reserved again later is only removed from the order.
+func (w *dedupWindow) pop() bool {
+	for len(w.order) > 0 {
+		e := w.order[0]
+		w.order = w.order[1:]
+		if st, ok := w.keys[e.key]; ok && st.expireAt.Equal(e.expireAt) {
+			delete(w.keys, e.key)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_deduplicator(t *testing.T) {
+	d := newDeduplicator(0)
+	now := time.Now()
+	k := dedupKey{group: "sw", key: "service_traffic/1"}
+	assert.Equal(t, dedupReserved, d.reserve(k, time.Minute, now))
+	// the retry is rejected until the first write is committed, since it may still fail
+	assert.Equal(t, dedupInFlight, d.reserve(k, time.Minute, now.Add(10*time.Second)))
+	d.commit(k)
+	assert.Equal(t, dedupCommitted, d.reserve(k, time.Minute, now.Add(30*time.Second)))
+	// the key is remembered in the group only
+	assert.Equal(t, dedupReserved, d.reserve(dedupKey{group: "default", key: k.key}, time.Minute, now))
+
+	// the key expires with the window
+	assert.Equal(t, dedupReserved, d.reserve(k, time.Minute, now.Add(time.Minute)))
+	assert.Equal(t, dedupInFlight, d.reserve(k, time.Minute, now.Add(90*time.Second)))
+
+	// the key of a failed write is released for the retry
+	d.release(k)
+	assert.Equal(t, dedupReserved, d.reserve(k, time.Minute, now.Add(90*time.Second)))
+}
+
+func Test_deduplicator_maxKeys(t *testing.T) {
+	d := newDeduplicator(2)
+	now := time.Now()
+	keys := []dedupKey{{group: "sw", key: "a"}, {group: "sw", key: "b"}, {group: "sw", key: "c"}}
+	assert.Equal(t, dedupReserved, d.reserve(keys[0], time.Hour, now))
+	assert.Equal(t, dedupReserved, d.reserve(keys[1], time.Hour, now))
+	d.release(keys[1])
+	assert.Equal(t, dedupReserved, d.reserve(keys[1], time.Hour, now.Add(time.Second)))
+
+	// the oldest key is forgotten before it expires
+	assert.Equal(t, dedupReserved, d.reserve(keys[2], time.Hour, now.Add(2*time.Second)))
+	assert.Equal(t, dedupReserved, d.reserve(keys[0], time.Hour, now.Add(3*time.Second)))
+	d.commit(keys[2])
+	assert.Equal(t, dedupCommitted, d.reserve(keys[2], time.Hour, now.Add(4*time.Second)))
+}
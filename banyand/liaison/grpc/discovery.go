@@ -18,8 +18,11 @@
 package grpc
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -32,25 +35,43 @@ import (
 	"github.com/apache/skywalking-banyandb/banyand/tsdb"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/partition"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/query/logical"
 )
 
 var errNotExist = errors.New("the object doesn't exist")
 
 type discoveryService struct {
-	metadataRepo metadata.Repo
-	nodeRegistry NodeRegistry
-	shardRepo    *shardRepo
-	entityRepo   *entityRepo
-	log          *logger.Logger
-	kind         schema.Kind
+	metadataRepo     metadata.Repo
+	nodeRegistry     NodeRegistry
+	shardRepo        *shardRepo
+	entityRepo       *entityRepo
+	log              *logger.Logger
+	deadLetters      *deadLetterStore
+	dedup            *deduplicator
+	lagging          *laggingReplicas
+	tenants          *tenantRepo
+	bulk             bulkOptions
+	queryTimeout     time.Duration
+	kind             schema.Kind
+	allowUnknownTags bool
 }
 
 func newDiscoveryService(kind schema.Kind, metadataRepo metadata.Repo, nodeRegistry NodeRegistry) *discoveryService {
-	sr := &shardRepo{shardEventsMap: make(map[identity]uint32)}
-	er := &entityRepo{entitiesMap: make(map[identity]partition.EntityLocator)}
+	sr := &shardRepo{
+		shardEventsMap: make(map[identity]uint32),
+		replicationMap: make(map[identity]replication),
+		shardingMap:    make(map[identity]partition.Sharding),
+		validatorMap:   make(map[identity]*ingestionValidator),
+		dedupMap:       make(map[identity]dedupPolicy),
+	}
+	er := &entityRepo{
+		schemas: make(map[identity][]*compiledSchema),
+	}
 	return &discoveryService{
 		shardRepo:    sr,
 		entityRepo:   er,
+		dedup:        newDeduplicator(0),
 		kind:         kind,
 		metadataRepo: metadataRepo,
 		nodeRegistry: nodeRegistry,
@@ -70,17 +91,121 @@ func (ds *discoveryService) SetLogger(log *logger.Logger) {
 }
 
 func (ds *discoveryService) navigate(metadata *commonv1.Metadata, tagFamilies []*modelv1.TagFamilyForWrite) (tsdb.Entity, tsdb.EntityValues, common.ShardID, error) {
-	shardNum, existed := ds.shardRepo.shardNum(getID(&commonv1.Metadata{
+	return ds.locate(metadata, nil, tagFamilies)
+}
+
+// locate locates the tag families by the compiled schema cs, or by the current schema if cs is nil.
+func (ds *discoveryService) locate(metadata *commonv1.Metadata, cs *compiledSchema, tagFamilies []*modelv1.TagFamilyForWrite,
+) (tsdb.Entity, tsdb.EntityValues, common.ShardID, error) {
+	groupID := getID(&commonv1.Metadata{
 		Name: metadata.Group,
-	}))
+	})
+	shardNum, existed := ds.shardRepo.shardNum(groupID)
 	if !existed {
 		return nil, nil, common.ShardID(0), errors.Wrapf(errNotExist, "finding the shard num by: %v", metadata)
 	}
-	locator, existed := ds.entityRepo.getLocator(getID(metadata))
+	sharding, existed := ds.shardRepo.sharding(groupID)
+	if !existed {
+		return nil, nil, common.ShardID(0), errors.Wrapf(errNotExist, "finding the sharding by: %v", metadata)
+	}
+	if cs == nil {
+		if cs, _ = ds.entityRepo.get(getID(metadata), 0); cs == nil {
+			return nil, nil, common.ShardID(0), errors.Wrapf(errNotExist, "finding the locator by: %v", metadata)
+		}
+	}
+	return cs.locator.Locate(metadata.Name, tagFamilies, sharding, shardNum)
+}
+
+// fillTags fills the tag families written by the defaults and the derivations of the compiled schema cs,
+// or of the current schema if cs is nil. They're left as they are if the schema isn't known, locate reports it then.
+func (ds *discoveryService) fillTags(metadata *commonv1.Metadata, cs *compiledSchema, tagFamilies []*modelv1.TagFamilyForWrite,
+) []*modelv1.TagFamilyForWrite {
+	if cs == nil {
+		if cs, _ = ds.entityRepo.get(getID(metadata), 0); cs == nil {
+			return tagFamilies
+		}
+	}
+	return cs.filler.Fill(tagFamilies)
+}
+
+// validateWrite validates the tag families written at the timestamp ts by the ingestion validation rules of the group,
+// whose tags are located by the compiled schema cs, or by the current schema if cs is nil.
+// The rule the write breaks is returned as the reason of the rejection.
+func (ds *discoveryService) validateWrite(metadata *commonv1.Metadata, cs *compiledSchema, ts time.Time, tagFamilies []*modelv1.TagFamilyForWrite,
+) (reason string, err error) {
+	v := ds.shardRepo.validator(getID(&commonv1.Metadata{Name: metadata.Group}))
+	if v == nil {
+		return "", nil
+	}
+	if cs == nil {
+		if cs, _ = ds.entityRepo.get(getID(metadata), 0); cs == nil {
+			return "", nil
+		}
+	}
+	rule, err := v.validate(time.Now(), ts, cs.tagFamilies, tagFamilies)
+	if err != nil {
+		rejectedCounter.Inc(1, metadata.Group, rule)
+		return rule, err
+	}
+	return "", nil
+}
+
+// reserve reserves the idempotency key of the write to the resource, or its element id if the group takes them as the keys.
+// It returns dedupCommitted if the key is committed within the deduplication window of the group, then the write should be skipped,
+// or dedupInFlight if the write of the key is in flight, then the write should be retried later.
+// The key reserved has to be committed if the write succeeds, or released if it fails, so that retrying it goes through.
+func (ds *discoveryService) reserve(metadata *commonv1.Metadata, idempotencyKey, elementID string) (dedupKey, dedupResult) {
+	p, ok := ds.shardRepo.dedupPolicy(getID(&commonv1.Metadata{Name: metadata.GetGroup()}))
+	if !ok {
+		return dedupKey{}, dedupReserved
+	}
+	if idempotencyKey == "" && p.elementID {
+		idempotencyKey = elementID
+	}
+	if idempotencyKey == "" {
+		return dedupKey{}, dedupReserved
+	}
+	k := dedupKey{group: metadata.GetGroup(), key: metadata.GetName() + "/" + idempotencyKey}
+	switch r := ds.dedup.reserve(k, p.window, time.Now()); r {
+	case dedupCommitted:
+		deduplicatedCounter.Inc(1, metadata.GetGroup())
+		return dedupKey{}, r
+	case dedupInFlight:
+		return dedupKey{}, r
+	}
+	return k, dedupReserved
+}
+
+// keepsDeadLetters tells whether the rejected writes to the group are kept in the dead-letter store,
+// which the liaison enables and the group opts in by its ingestion validation.
+func (ds *discoveryService) keepsDeadLetters(group string) bool {
+	if ds.deadLetters == nil {
+		return false
+	}
+	v := ds.shardRepo.validator(getID(&commonv1.Metadata{Name: group}))
+	return v != nil && v.deadLetter
+}
+
+// keepDeadLetter keeps a rejected write of the group in the dead-letter store.
+func (ds *discoveryService) keepDeadLetter(group, reason string, cause error, dl *databasev1.DeadLetter) {
+	dl.Reason = reason
+	dl.Message = cause.Error()
+	if err := ds.deadLetters.add(group, dl); err != nil {
+		ds.log.Error().Err(err).Str("group", group).Msg("failed to keep the dead letter")
+		return
+	}
+	deadLetteredCounter.Inc(1, group)
+}
+
+// resolveProjection resolves the tag projection of a query against the schema of the subject.
+// The projection passes through if the schema isn't known yet, the query reports the absent subject then.
+func (ds *discoveryService) resolveProjection(metadata *commonv1.Metadata, projection *modelv1.TagProjection,
+) (normalized, known *modelv1.TagProjection, err error) {
+	tagFamilies, existed := ds.entityRepo.getTagFamilies(getID(metadata))
 	if !existed {
-		return nil, nil, common.ShardID(0), errors.Wrapf(errNotExist, "finding the locator by: %v", metadata)
+		return projection, projection, nil
 	}
-	return locator.Locate(metadata.Name, tagFamilies, shardNum)
+	return logical.ResolveTagProjection(tagFamilies, projection, ds.allowUnknownTags)
 }
 
 type identity struct {
@@ -97,9 +222,30 @@ var _ schema.EventHandler = (*shardRepo)(nil)
 type shardRepo struct {
 	log            *logger.Logger
 	shardEventsMap map[identity]uint32
+	replicationMap map[identity]replication
+	shardingMap    map[identity]partition.Sharding
+	validatorMap   map[identity]*ingestionValidator
+	dedupMap       map[identity]dedupPolicy
 	sync.RWMutex
 }
 
+// replication is the replication policy of a group.
+type replication struct {
+	replicas uint32
+	quorum   uint32
+}
+
+func newReplication(opts *commonv1.ResourceOpts) replication {
+	r := replication{replicas: opts.GetReplicas(), quorum: opts.GetWriteQuorum()}
+	if r.replicas < 1 {
+		r.replicas = 1
+	}
+	if r.quorum < 1 || r.quorum > r.replicas {
+		r.quorum = r.replicas
+	}
+	return r
+}
+
 func (s *shardRepo) OnAddOrUpdate(schemaMetadata schema.Metadata) {
 	if schemaMetadata.Kind != schema.KindGroup {
 		return
@@ -112,9 +258,28 @@ func (s *shardRepo) OnAddOrUpdate(schemaMetadata schema.Metadata) {
 	if le := s.log.Debug(); le.Enabled() {
 		le.Stringer("id", idx).Uint32("total", group.ResourceOpts.ShardNum).Msg("shard added or updated")
 	}
+	sharding, err := partition.NewSharding(group.ResourceOpts.Sharding)
 	s.RWMutex.Lock()
 	defer s.RWMutex.Unlock()
 	s.shardEventsMap[idx] = group.ResourceOpts.ShardNum
+	s.replicationMap[idx] = newReplication(group.ResourceOpts)
+	if v := newIngestionValidator(group.ResourceOpts.IngestionValidation); v != nil {
+		s.validatorMap[idx] = v
+	} else {
+		delete(s.validatorMap, idx)
+	}
+	if p, ok := newDedupPolicy(group.ResourceOpts.Deduplication); ok {
+		s.dedupMap[idx] = p
+	} else {
+		delete(s.dedupMap, idx)
+	}
+	if err != nil {
+		// writes are rejected rather than routed by a wrong strategy
+		s.log.Error().Err(err).Stringer("id", idx).Msg("invalid sharding")
+		delete(s.shardingMap, idx)
+		return
+	}
+	s.shardingMap[idx] = sharding
 }
 
 func (s *shardRepo) OnDelete(schemaMetadata schema.Metadata) {
@@ -132,6 +297,10 @@ func (s *shardRepo) OnDelete(schemaMetadata schema.Metadata) {
 	s.RWMutex.Lock()
 	defer s.RWMutex.Unlock()
 	delete(s.shardEventsMap, idx)
+	delete(s.replicationMap, idx)
+	delete(s.shardingMap, idx)
+	delete(s.validatorMap, idx)
+	delete(s.dedupMap, idx)
 }
 
 func (s *shardRepo) shardNum(idx identity) (uint32, bool) {
@@ -144,6 +313,36 @@ func (s *shardRepo) shardNum(idx identity) (uint32, bool) {
 	return sn, true
 }
 
+func (s *shardRepo) sharding(idx identity) (partition.Sharding, bool) {
+	s.RWMutex.RLock()
+	defer s.RWMutex.RUnlock()
+	sharding, ok := s.shardingMap[idx]
+	return sharding, ok
+}
+
+func (s *shardRepo) validator(idx identity) *ingestionValidator {
+	s.RWMutex.RLock()
+	defer s.RWMutex.RUnlock()
+	return s.validatorMap[idx]
+}
+
+func (s *shardRepo) dedupPolicy(idx identity) (dedupPolicy, bool) {
+	s.RWMutex.RLock()
+	defer s.RWMutex.RUnlock()
+	p, ok := s.dedupMap[idx]
+	return p, ok
+}
+
+func (s *shardRepo) replication(idx identity) replication {
+	s.RWMutex.RLock()
+	defer s.RWMutex.RUnlock()
+	r, ok := s.replicationMap[idx]
+	if !ok {
+		return replication{replicas: 1, quorum: 1}
+	}
+	return r
+}
+
 func getID(metadata *commonv1.Metadata) identity {
 	return identity{
 		name:  metadata.GetName(),
@@ -153,31 +352,84 @@ func getID(metadata *commonv1.Metadata) identity {
 
 var _ schema.EventHandler = (*entityRepo)(nil)
 
+// retainedRevisions is the number of the revisions of a schema kept to reconcile the writes built against them.
+const retainedRevisions = 4
+
+var errRevisionMismatch = errors.New("schema revision mismatch")
+
+// compiledSchema is a revision of the schema of a stream or a measure compiled for validating the writes and the queries.
+type compiledSchema struct {
+	// filler is nil if none of the tags has a default value or a derivation.
+	filler      *pbv1.TagFiller
+	tagFamilies []*databasev1.TagFamilySpec
+	locator     partition.EntityLocator
+	modRevision int64
+}
+
+// reconcile checks the tag families written against the layout of the schema s still locate the same tags
+// in the schema current, that is, every tag of them is at the same offsets with the same name and type in both.
+func (s *compiledSchema) reconcile(current *compiledSchema, tagFamilies []*modelv1.TagFamilyForWrite) error {
+	if s == current {
+		return nil
+	}
+	if len(tagFamilies) > len(s.tagFamilies) || len(tagFamilies) > len(current.tagFamilies) {
+		return errors.WithMessagef(errRevisionMismatch, "%d tag families are written against the revision %d, the revision %d has %d",
+			len(tagFamilies), s.modRevision, current.modRevision, len(current.tagFamilies))
+	}
+	for i, tf := range tagFamilies {
+		written, latest := s.tagFamilies[i], current.tagFamilies[i]
+		if written.GetName() != latest.GetName() || len(tf.GetTags()) > len(written.GetTags()) || len(tf.GetTags()) > len(latest.GetTags()) {
+			return errors.WithMessagef(errRevisionMismatch, "the tag family %s of the revision %d doesn't match the revision %d",
+				written.GetName(), s.modRevision, current.modRevision)
+		}
+		for j := range tf.GetTags() {
+			if written.Tags[j].GetName() != latest.Tags[j].GetName() || written.Tags[j].GetType() != latest.Tags[j].GetType() {
+				return errors.WithMessagef(errRevisionMismatch, "the tag %s.%s of the revision %d doesn't match the revision %d",
+					written.GetName(), written.Tags[j].GetName(), s.modRevision, current.modRevision)
+			}
+		}
+	}
+	return nil
+}
+
+// entityRepo caches the compiled schemas by their subjects and revisions. The schemas are compiled once
+// the metadata repo notifies their changes, and shared by the writes and the queries.
 type entityRepo struct {
-	log         *logger.Logger
-	entitiesMap map[identity]partition.EntityLocator
+	log *logger.Logger
+	// schemas holds the latest revisions of a subject in the ascending order, the current one goes last.
+	schemas map[identity][]*compiledSchema
 	sync.RWMutex
 }
 
+func compileSchema(tagFamilies []*databasev1.TagFamilySpec, entity *databasev1.Entity, modRevision int64) *compiledSchema {
+	return &compiledSchema{
+		tagFamilies: tagFamilies,
+		locator:     partition.NewEntityLocator(tagFamilies, entity, modRevision),
+		modRevision: modRevision,
+	}
+}
+
 // OnAddOrUpdate implements schema.EventHandler.
 func (e *entityRepo) OnAddOrUpdate(schemaMetadata schema.Metadata) {
-	var el partition.EntityLocator
+	var cs *compiledSchema
 	var id identity
-	var modRevision int64
 	switch schemaMetadata.Kind {
 	case schema.KindMeasure:
 		measure := schemaMetadata.Spec.(*databasev1.Measure)
-		modRevision = measure.GetMetadata().GetModRevision()
-		el = partition.NewEntityLocator(measure.TagFamilies, measure.Entity, modRevision)
+		cs = compileSchema(measure.GetTagFamilies(), measure.GetEntity(), measure.GetMetadata().GetModRevision())
 		id = getID(measure.GetMetadata())
 	case schema.KindStream:
 		stream := schemaMetadata.Spec.(*databasev1.Stream)
-		modRevision = stream.GetMetadata().GetModRevision()
-		el = partition.NewEntityLocator(stream.TagFamilies, stream.Entity, modRevision)
+		cs = compileSchema(stream.GetTagFamilies(), stream.GetEntity(), stream.GetMetadata().GetModRevision())
 		id = getID(stream.GetMetadata())
 	default:
 		return
 	}
+	var err error
+	// the registry rejects the invalid ones, the writes go on without filling the tags otherwise
+	if cs.filler, err = pbv1.NewTagFiller(cs.tagFamilies); err != nil {
+		e.log.Error().Err(err).Stringer("subject", id).Msg("the default values and the derivations of the tags are ignored")
+	}
 	if le := e.log.Debug(); le.Enabled() {
 		var kind string
 		switch schemaMetadata.Kind {
@@ -192,18 +444,27 @@ func (e *entityRepo) OnAddOrUpdate(schemaMetadata schema.Metadata) {
 			Str("action", "add_or_update").
 			Stringer("subject", id).
 			Str("kind", kind).
+			Int64("revision", cs.modRevision).
 			Msg("entity added or updated")
 	}
-	en := make([]partition.TagLocator, 0, len(el.TagLocators))
-	for _, l := range el.TagLocators {
-		en = append(en, partition.TagLocator{
-			FamilyOffset: l.FamilyOffset,
-			TagOffset:    l.TagOffset,
-		})
-	}
 	e.RWMutex.Lock()
 	defer e.RWMutex.Unlock()
-	e.entitiesMap[id] = partition.EntityLocator{TagLocators: en, ModRevision: modRevision}
+	revisions := e.schemas[id]
+	for i, r := range revisions {
+		// the watch might notify a revision again
+		if r.modRevision == cs.modRevision {
+			revisions = append(revisions[:i], revisions[i+1:]...)
+			break
+		}
+	}
+	revisions = append(revisions, cs)
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].modRevision < revisions[j].modRevision
+	})
+	if len(revisions) > retainedRevisions {
+		revisions = revisions[len(revisions)-retainedRevisions:]
+	}
+	e.schemas[id] = revisions
 }
 
 // OnDelete implements schema.EventHandler.
@@ -237,15 +498,56 @@ func (e *entityRepo) OnDelete(schemaMetadata schema.Metadata) {
 	}
 	e.RWMutex.Lock()
 	defer e.RWMutex.Unlock()
-	delete(e.entitiesMap, id)
+	delete(e.schemas, id)
 }
 
-func (e *entityRepo) getLocator(id identity) (partition.EntityLocator, bool) {
+// get returns the current revision of the schema, and the revision modRevision if it's retained.
+func (e *entityRepo) get(id identity, modRevision int64) (current, revision *compiledSchema) {
 	e.RWMutex.RLock()
 	defer e.RWMutex.RUnlock()
-	el, ok := e.entitiesMap[id]
-	if !ok {
-		return partition.EntityLocator{}, false
+	revisions := e.schemas[id]
+	if len(revisions) == 0 {
+		return nil, nil
+	}
+	for _, r := range revisions {
+		if r.modRevision == modRevision {
+			revision = r
+		}
+	}
+	return revisions[len(revisions)-1], revision
+}
+
+func (e *entityRepo) getTagFamilies(id identity) ([]*databasev1.TagFamilySpec, bool) {
+	current, _ := e.get(id, 0)
+	if current == nil {
+		return nil, false
+	}
+	return current.tagFamilies, true
+}
+
+// checkRevision checks the tag families written against the revision modRevision of the schema, and returns
+// the current revision to locate them. The writes against a revision other than the current one are accepted
+// only if the revision is retained and their tags are reconciled with the current revision, see compiledSchema.reconcile.
+func (e *entityRepo) checkRevision(id identity, modRevision int64, tagFamilies []*modelv1.TagFamilyForWrite,
+) (*compiledSchema, modelv1.Status, error) {
+	current, revision := e.get(id, modRevision)
+	if current == nil {
+		return nil, modelv1.Status_STATUS_NOT_FOUND, errors.Wrapf(errNotExist, "finding the schema of %s", id)
+	}
+	if revision == nil {
+		return nil, modelv1.Status_STATUS_SCHEMA_REVISION_MISMATCH, errors.WithMessagef(errRevisionMismatch,
+			"the revision %d isn't retained, the current revision is %d", modRevision, current.modRevision)
+	}
+	if err := revision.reconcile(current, tagFamilies); err != nil {
+		return nil, modelv1.Status_STATUS_SCHEMA_REVISION_MISMATCH, err
+	}
+	return current, modelv1.Status_STATUS_SUCCEED, nil
+}
+
+// queryContext bounds a query by the timeout if it's set. The query is canceled as well once the client gives up.
+func (ds *discoveryService) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ds.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
-	return el, true
+	return context.WithTimeout(ctx, ds.queryTimeout)
 }
@@ -0,0 +1,162 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+var swID = identity{group: "default", name: "sw"}
+
+func newTestEntityRepo() *entityRepo {
+	return &entityRepo{
+		log:     logger.GetLogger("test"),
+		schemas: make(map[identity][]*compiledSchema),
+	}
+}
+
+func updateStream(e *entityRepo, modRevision int64, tags ...*databasev1.TagSpec) {
+	e.OnAddOrUpdate(schema.Metadata{
+		TypeMeta: schema.TypeMeta{Kind: schema.KindStream},
+		Spec: &databasev1.Stream{
+			Metadata:    &commonv1.Metadata{Group: "default", Name: "sw", ModRevision: modRevision},
+			TagFamilies: []*databasev1.TagFamilySpec{{Name: "searchable", Tags: tags}},
+			Entity:      &databasev1.Entity{TagNames: []string{"trace_id"}},
+		},
+	})
+}
+
+var (
+	traceIDSpec   = &databasev1.TagSpec{Name: "trace_id", Type: databasev1.TagType_TAG_TYPE_STRING}
+	stateSpec     = &databasev1.TagSpec{Name: "state", Type: databasev1.TagType_TAG_TYPE_INT}
+	stateStrSpec  = &databasev1.TagSpec{Name: "state", Type: databasev1.TagType_TAG_TYPE_STRING}
+	durationSpec  = &databasev1.TagSpec{Name: "duration", Type: databasev1.TagType_TAG_TYPE_INT}
+	writtenTraces = []*modelv1.TagFamilyForWrite{{Tags: []*modelv1.TagValue{
+		{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: "t1"}}},
+		{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: 1}}},
+	}}}
+)
+
+func TestEntityRepoCheckRevision(t *testing.T) {
+	e := newTestEntityRepo()
+	_, st, err := e.checkRevision(swID, 1, writtenTraces)
+	assert.Equal(t, modelv1.Status_STATUS_NOT_FOUND, st)
+	assert.ErrorIs(t, err, errNotExist)
+
+	updateStream(e, 1, traceIDSpec, stateSpec)
+	cs, st, err := e.checkRevision(swID, 1, writtenTraces)
+	require.NoError(t, err)
+	assert.Equal(t, modelv1.Status_STATUS_SUCCEED, st)
+	assert.Equal(t, int64(1), cs.modRevision)
+
+	// appending a tag keeps the layout of the revision 1
+	updateStream(e, 2, traceIDSpec, stateSpec, durationSpec)
+	cs, _, err = e.checkRevision(swID, 1, writtenTraces)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cs.modRevision, "the current revision locates the write")
+
+	// changing the type of a tag doesn't
+	updateStream(e, 3, traceIDSpec, stateStrSpec, durationSpec)
+	_, st, err = e.checkRevision(swID, 2, writtenTraces)
+	assert.Equal(t, modelv1.Status_STATUS_SCHEMA_REVISION_MISMATCH, st)
+	assert.ErrorIs(t, err, errRevisionMismatch)
+	assert.ErrorContains(t, err, "searchable.state")
+	// the tags out of the layout of the revision 2 can't be reconciled either
+	_, _, err = e.checkRevision(swID, 2, []*modelv1.TagFamilyForWrite{writtenTraces[0], writtenTraces[0]})
+	assert.ErrorIs(t, err, errRevisionMismatch)
+	// but the tags ahead of the changed one can
+	_, _, err = e.checkRevision(swID, 2, []*modelv1.TagFamilyForWrite{{Tags: writtenTraces[0].Tags[:1]}})
+	assert.NoError(t, err)
+
+	// the revisions which aren't retained can't be reconciled
+	for rev := int64(4); rev < 4+retainedRevisions; rev++ {
+		updateStream(e, rev, traceIDSpec, stateStrSpec, durationSpec)
+	}
+	_, st, err = e.checkRevision(swID, 3, writtenTraces[:1])
+	assert.Equal(t, modelv1.Status_STATUS_SCHEMA_REVISION_MISMATCH, st)
+	assert.ErrorContains(t, err, "isn't retained")
+	_, _, err = e.checkRevision(swID, 10, writtenTraces[:1])
+	assert.ErrorIs(t, err, errRevisionMismatch, "the revision isn't propagated yet")
+
+	e.OnDelete(schema.Metadata{
+		TypeMeta: schema.TypeMeta{Kind: schema.KindStream},
+		Spec:     &databasev1.Stream{Metadata: &commonv1.Metadata{Group: "default", Name: "sw"}},
+	})
+	_, st, _ = e.checkRevision(swID, 7, writtenTraces[:1])
+	assert.Equal(t, modelv1.Status_STATUS_NOT_FOUND, st)
+}
+
+func TestEntityRepoOutOfOrderRevisions(t *testing.T) {
+	e := newTestEntityRepo()
+	updateStream(e, 2, traceIDSpec, stateSpec)
+	updateStream(e, 1, traceIDSpec)
+	updateStream(e, 2, traceIDSpec, stateSpec)
+	current, revision := e.get(swID, 1)
+	assert.Equal(t, int64(2), current.modRevision)
+	assert.Equal(t, int64(1), revision.modRevision)
+	assert.Len(t, e.schemas[swID], 2, "a revision notified again isn't duplicated")
+}
+
+func TestEntityRepoSchemaChangeRacingWrites(t *testing.T) {
+	e := newTestEntityRepo()
+	updateStream(e, 1, traceIDSpec, stateSpec)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for rev := int64(2); rev < 100; rev++ {
+			if rev%2 == 0 {
+				updateStream(e, rev, traceIDSpec, stateSpec, durationSpec)
+			} else {
+				updateStream(e, rev, traceIDSpec, stateStrSpec)
+			}
+		}
+	}()
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				current, _ := e.get(swID, 0)
+				cs, st, err := e.checkRevision(swID, current.modRevision, writtenTraces)
+				if err != nil {
+					// the revision is replaced before the write reaches the check
+					assert.True(t, errors.Is(err, errRevisionMismatch), err.Error())
+					assert.Equal(t, modelv1.Status_STATUS_SCHEMA_REVISION_MISMATCH, st)
+					continue
+				}
+				// the schema locating the write never misaligns its tags
+				tags := cs.tagFamilies[0].GetTags()
+				assert.Equal(t, "trace_id", tags[0].GetName())
+				assert.Equal(t, current.tagFamilies[0].GetTags()[1].GetType(), tags[1].GetType())
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,518 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/api/data"
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/banyand/tsdb"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+const (
+	drainPollInterval = time.Second
+	// the smallest time range to split when a node returns too much data
+	minCopyRange = time.Second
+)
+
+var (
+	errNodeStillRouted = errors.New("the node still receives writes")
+	errUnexpectedReply = errors.New("unexpected reply")
+)
+
+// decommission copies the data of a drained node to the remaining nodes.
+type decommission struct {
+	status *databasev1.NodeDrainStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+	mu     sync.RWMutex
+}
+
+func (d *decommission) snapshot() *databasev1.NodeDrainStatus {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return proto.Clone(d.status).(*databasev1.NodeDrainStatus)
+}
+
+func (d *decommission) update(fn func(s *databasev1.NodeDrainStatus)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(d.status)
+}
+
+func (d *decommission) finished() bool {
+	select {
+	case <-d.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (cs *clusterStateServer) DrainNode(_ context.Context, req *databasev1.ClusterStateServiceDrainNodeRequest) (
+	*databasev1.ClusterStateServiceDrainNodeResponse, error,
+) {
+	if req.GetNode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "node is required")
+	}
+	action := clusterv1.DrainAction_DRAIN_ACTION_DRAIN
+	if req.GetDecommission() {
+		action = clusterv1.DrainAction_DRAIN_ACTION_DECOMMISSION
+	}
+	resp, err := cs.askNode(req.GetNode(), action)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetDecommission() {
+		cs.startDecommission(req.GetNode())
+	}
+	return &databasev1.ClusterStateServiceDrainNodeResponse{Status: cs.drainStatus(req.GetNode(), resp)}, nil
+}
+
+func (cs *clusterStateServer) AbortDrain(_ context.Context, req *databasev1.ClusterStateServiceAbortDrainRequest) (
+	*databasev1.ClusterStateServiceAbortDrainResponse, error,
+) {
+	if req.GetNode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "node is required")
+	}
+	cs.decommissionsMu.Lock()
+	d, ok := cs.decommissions[req.GetNode()]
+	delete(cs.decommissions, req.GetNode())
+	cs.decommissionsMu.Unlock()
+	if ok {
+		d.cancel()
+		<-d.done
+	}
+	resp, err := cs.askNode(req.GetNode(), clusterv1.DrainAction_DRAIN_ACTION_ABORT)
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.ClusterStateServiceAbortDrainResponse{Status: cs.drainStatus(req.GetNode(), resp)}, nil
+}
+
+func (cs *clusterStateServer) GetDrainStatus(_ context.Context, req *databasev1.ClusterStateServiceGetDrainStatusRequest) (
+	*databasev1.ClusterStateServiceGetDrainStatusResponse, error,
+) {
+	if req.GetNode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "node is required")
+	}
+	cs.decommissionsMu.Lock()
+	d, ok := cs.decommissions[req.GetNode()]
+	cs.decommissionsMu.Unlock()
+	// a decommissioned node is removed from the registry, its final progress is kept by the liaison
+	if ok && d.finished() && d.snapshot().GetState() == databasev1.NodeState_NODE_STATE_DECOMMISSIONED {
+		return &databasev1.ClusterStateServiceGetDrainStatusResponse{Status: d.snapshot()}, nil
+	}
+	resp, err := cs.askNode(req.GetNode(), clusterv1.DrainAction_DRAIN_ACTION_STATUS)
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.ClusterStateServiceGetDrainStatusResponse{Status: cs.drainStatus(req.GetNode(), resp)}, nil
+}
+
+// drainStatus merges the state reported by the node with the progress of its decommission.
+func (cs *clusterStateServer) drainStatus(node string, resp *clusterv1.DrainResponse) *databasev1.NodeDrainStatus {
+	cs.decommissionsMu.Lock()
+	d, ok := cs.decommissions[node]
+	cs.decommissionsMu.Unlock()
+	result := &databasev1.NodeDrainStatus{Node: node}
+	if ok {
+		result = d.snapshot()
+	}
+	result.State = resp.GetState()
+	result.MemParts = resp.GetMemParts()
+	return result
+}
+
+func (cs *clusterStateServer) askNode(node string, action clusterv1.DrainAction) (*clusterv1.DrainResponse, error) {
+	f, err := cs.pipeline.Publish(data.TopicNodeDrain,
+		bus.NewMessageWithNode(bus.MessageID(time.Now().UnixNano()), node, &clusterv1.DrainRequest{Action: action}))
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to reach node %s: %v", node, err)
+	}
+	m, err := f.Get()
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "node %s fails to %s: %v", node, action, err)
+	}
+	resp, ok := m.Data().(*clusterv1.DrainResponse)
+	if !ok {
+		return nil, errors.WithMessagef(errUnexpectedReply, "node %s replies %T", node, m.Data())
+	}
+	return resp, nil
+}
+
+func (cs *clusterStateServer) startDecommission(node string) {
+	cs.decommissionsMu.Lock()
+	defer cs.decommissionsMu.Unlock()
+	if d, ok := cs.decommissions[node]; ok && (!d.finished() || d.snapshot().GetError() == "") {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &decommission{
+		status: &databasev1.NodeDrainStatus{Node: node, State: databasev1.NodeState_NODE_STATE_DECOMMISSIONING},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	cs.decommissions[node] = d
+	go func() {
+		defer close(d.done)
+		defer cancel()
+		if err := cs.decommission(ctx, node, d); err != nil {
+			cs.streamSVC.log.Error().Err(err).Str("node", node).Msg("failed to decommission the node")
+			d.update(func(s *databasev1.NodeDrainStatus) {
+				s.Error = err.Error()
+			})
+		}
+	}()
+}
+
+// decommission waits for the node to flush its in-memory data, copies all the data it holds
+// to the current owners of the shards, then removes the node from the registry.
+func (cs *clusterStateServer) decommission(ctx context.Context, node string, d *decommission) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		resp, err := cs.askNode(node, clusterv1.DrainAction_DRAIN_ACTION_STATUS)
+		if err != nil {
+			return err
+		}
+		d.update(func(s *databasev1.NodeDrainStatus) {
+			s.MemParts = resp.GetMemParts()
+		})
+		if resp.GetMemParts() == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	groups, err := cs.metadataRepo.GroupRegistry().ListGroup(ctx)
+	if err != nil {
+		return err
+	}
+	var streams []*databasev1.Stream
+	var measures []*databasev1.Measure
+	for _, g := range groups {
+		switch g.GetCatalog() {
+		case commonv1.Catalog_CATALOG_STREAM:
+			ss, errList := cs.metadataRepo.StreamRegistry().ListStream(ctx, schema.ListOpt{Group: g.GetMetadata().GetName()})
+			if errList != nil {
+				return errList
+			}
+			streams = append(streams, ss...)
+		case commonv1.Catalog_CATALOG_MEASURE:
+			ms, errList := cs.metadataRepo.MeasureRegistry().ListMeasure(ctx, schema.ListOpt{Group: g.GetMetadata().GetName()})
+			if errList != nil {
+				return errList
+			}
+			measures = append(measures, ms...)
+		default:
+		}
+	}
+	d.update(func(s *databasev1.NodeDrainStatus) {
+		s.TotalResources = uint32(len(streams) + len(measures))
+	})
+	groupRanges := make(map[string]*modelv1.TimeRange, len(groups))
+	for _, g := range groups {
+		groupRanges[g.GetMetadata().GetName()] = retainedRange(g)
+	}
+	report := func(copied, _ uint64) {
+		d.update(func(s *databasev1.NodeDrainStatus) {
+			s.CopiedElements += copied
+		})
+	}
+	for _, stream := range streams {
+		job := copyJob{node: node, owners: cs.streamSVC.owners}
+		if err = cs.streamSVC.copyFrom(ctx, job, stream, groupRanges[stream.GetMetadata().GetGroup()], report); err != nil {
+			return errors.WithMessagef(err, "failed to copy stream %s/%s", stream.GetMetadata().GetGroup(), stream.GetMetadata().GetName())
+		}
+		d.update(func(s *databasev1.NodeDrainStatus) {
+			s.CopiedResources++
+		})
+	}
+	for _, measure := range measures {
+		job := copyJob{node: node, owners: cs.measureSVC.owners}
+		if err = cs.measureSVC.copyFrom(ctx, job, measure, groupRanges[measure.GetMetadata().GetGroup()], report); err != nil {
+			return errors.WithMessagef(err, "failed to copy measure %s/%s", measure.GetMetadata().GetGroup(), measure.GetMetadata().GetName())
+		}
+		d.update(func(s *databasev1.NodeDrainStatus) {
+			s.CopiedResources++
+		})
+	}
+	resp, err := cs.askNode(node, clusterv1.DrainAction_DRAIN_ACTION_COMPLETE)
+	if err != nil {
+		return err
+	}
+	d.update(func(s *databasev1.NodeDrainStatus) {
+		s.State = resp.GetState()
+	})
+	cs.streamSVC.log.Info().Str("node", node).Msg("the node is decommissioned")
+	return nil
+}
+
+// retainedRange is the range of data which isn't expired in a group.
+// A segment is removed after all its data expire, so the range extends with a segment interval.
+func retainedRange(g *commonv1.Group) *modelv1.TimeRange {
+	now := time.Now()
+	retained := toDuration(g.GetResourceOpts().GetTtl()) + toDuration(g.GetResourceOpts().GetSegmentInterval())
+	return &modelv1.TimeRange{
+		Begin: timestamppb.New(now.Add(-retained)),
+		// tolerate the clock skew between nodes
+		End: timestamppb.New(now.Add(time.Hour)),
+	}
+}
+
+func toDuration(ir *commonv1.IntervalRule) time.Duration {
+	d := time.Duration(ir.GetNum()) * time.Hour
+	if ir.GetUnit() == commonv1.IntervalRule_UNIT_DAY {
+		d *= 24
+	}
+	return d
+}
+
+// copyRanges queries the data in time buckets. It splits a bucket if the node returns too much data.
+func copyRanges(ctx context.Context, timeRange *modelv1.TimeRange, copyRange func(begin, end time.Time) error) error {
+	var copyOrSplit func(begin, end time.Time) error
+	copyOrSplit = func(begin, end time.Time) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := copyRange(begin, end)
+		if !errors.Is(err, errRepairBucketTooLarge) || end.Sub(begin) <= minCopyRange {
+			return err
+		}
+		mid := begin.Add(end.Sub(begin) / 2)
+		if err = copyOrSplit(begin, mid); err != nil {
+			return err
+		}
+		return copyOrSplit(mid, end)
+	}
+	end := timeRange.GetEnd().AsTime()
+	for begin := timeRange.GetBegin().AsTime(); begin.Before(end); begin = begin.Add(repairBucketSize) {
+		bucketEnd := begin.Add(repairBucketSize)
+		if bucketEnd.After(end) {
+			bucketEnd = end
+		}
+		if err := copyOrSplit(begin, bucketEnd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyJob tells copyFrom where to read the data and where to write them.
+type copyJob struct {
+	// owners locates the nodes receiving the data of a shard
+	owners func(metadata *commonv1.Metadata, shardID common.ShardID) ([][]string, error)
+	node   string
+	// shardIDs limits the shards read from the node, all shards are read if it's empty
+	shardIDs []uint32
+}
+
+// owners returns the current owners of every replica of a shard.
+func (ds *discoveryService) owners(metadata *commonv1.Metadata, shardID common.ShardID) ([][]string, error) {
+	nodes, _, err := ds.locateReplicas(metadata, shardID)
+	return nodes, err
+}
+
+// publishToOwners writes the data to all the current owners of a shard.
+func publishToOwners(publisher queue.BatchPublisher, topic bus.Topic, source string, nodes [][]string, data any) error {
+	for _, replicaNodes := range nodes {
+		for _, nodeID := range replicaNodes {
+			if nodeID == source {
+				return errors.WithMessagef(errNodeStillRouted, "node %s", source)
+			}
+			if _, err := publisher.Publish(topic, bus.NewMessageWithNode(bus.MessageID(time.Now().UnixNano()), nodeID, data)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *streamService) copyFrom(ctx context.Context, job copyJob, stream *databasev1.Stream, timeRange *modelv1.TimeRange,
+	report func(copied, bytes uint64),
+) error {
+	projection := toTagProjection(stream.GetTagFamilies())
+	publisher := s.pipeline.NewBatchPublisher()
+	defer publisher.Close()
+	return copyRanges(ctx, timeRange, func(begin, end time.Time) error {
+		elements, err := s.queryNode(job.node, &streamv1.QueryRequest{
+			Metadata:   stream.GetMetadata(),
+			TimeRange:  &modelv1.TimeRange{Begin: timestamppb.New(begin), End: timestamppb.New(end)},
+			Projection: projection,
+			Limit:      maxRepairElements,
+			ShardIds:   job.shardIDs,
+		})
+		if err != nil {
+			return err
+		}
+		var copied, bytes uint64
+		defer func() {
+			report(copied, bytes)
+		}()
+		for _, e := range elements {
+			writeRequest := &streamv1.WriteRequest{
+				Metadata: stream.GetMetadata(),
+				Element: &streamv1.ElementValue{
+					ElementId:   e.ElementId,
+					Timestamp:   e.Timestamp,
+					TagFamilies: toTagFamiliesForWrite(stream.GetTagFamilies(), e.TagFamilies),
+				},
+			}
+			entity, tagValues, shardID, errNav := s.navigate(stream.GetMetadata(), writeRequest.Element.TagFamilies)
+			if errNav != nil {
+				return errNav
+			}
+			nodes, errLocate := job.owners(stream.GetMetadata(), shardID)
+			if errLocate != nil {
+				return errLocate
+			}
+			iwr := &streamv1.InternalWriteRequest{
+				Request:      writeRequest,
+				ShardId:      uint32(shardID),
+				SeriesHash:   tsdb.HashEntity(entity),
+				EntityValues: tagValues[1:].Encode(),
+			}
+			if err = publishToOwners(publisher, data.TopicStreamWrite, job.node, nodes, iwr); err != nil {
+				return err
+			}
+			copied++
+			bytes += uint64(proto.Size(iwr))
+		}
+		return nil
+	})
+}
+
+func (ms *measureService) copyFrom(ctx context.Context, job copyJob, measure *databasev1.Measure, timeRange *modelv1.TimeRange,
+	report func(copied, bytes uint64),
+) error {
+	fieldProjection := &measurev1.QueryRequest_FieldProjection{}
+	for _, f := range measure.GetFields() {
+		fieldProjection.Names = append(fieldProjection.Names, f.GetName())
+	}
+	tagProjection := toTagProjection(measure.GetTagFamilies())
+	publisher := ms.pipeline.NewBatchPublisher()
+	defer publisher.Close()
+	return copyRanges(ctx, timeRange, func(begin, end time.Time) error {
+		dataPoints, err := ms.queryNode(job.node, &measurev1.QueryRequest{
+			Metadata:        measure.GetMetadata(),
+			TimeRange:       &modelv1.TimeRange{Begin: timestamppb.New(begin), End: timestamppb.New(end)},
+			TagProjection:   tagProjection,
+			FieldProjection: fieldProjection,
+			Limit:           maxRepairElements,
+			ShardIds:        job.shardIDs,
+		})
+		if err != nil {
+			return err
+		}
+		var copied, bytes uint64
+		defer func() {
+			report(copied, bytes)
+		}()
+		for _, dp := range dataPoints {
+			writeRequest := &measurev1.WriteRequest{
+				Metadata: measure.GetMetadata(),
+				DataPoint: &measurev1.DataPointValue{
+					Timestamp:   dp.Timestamp,
+					TagFamilies: toTagFamiliesForWrite(measure.GetTagFamilies(), dp.TagFamilies),
+					Fields:      toFieldsForWrite(measure.GetFields(), dp.Fields),
+				},
+			}
+			entity, tagValues, shardID, errNav := ms.navigate(measure.GetMetadata(), writeRequest.DataPoint.TagFamilies)
+			if errNav != nil {
+				return errNav
+			}
+			nodes, errLocate := job.owners(measure.GetMetadata(), shardID)
+			if errLocate != nil {
+				return errLocate
+			}
+			iwr := &measurev1.InternalWriteRequest{
+				Request:      writeRequest,
+				ShardId:      uint32(shardID),
+				SeriesHash:   tsdb.HashEntity(entity),
+				EntityValues: tagValues[1:].Encode(),
+			}
+			if err = publishToOwners(publisher, data.TopicMeasureWrite, job.node, nodes, iwr); err != nil {
+				return err
+			}
+			copied++
+			bytes += uint64(proto.Size(iwr))
+		}
+		return nil
+	})
+}
+
+func (ms *measureService) queryNode(nodeID string, req *measurev1.QueryRequest) ([]*measurev1.DataPoint, error) {
+	f, err := ms.pipeline.Publish(data.TopicMeasureQuery, bus.NewMessageWithNode(bus.MessageID(time.Now().UnixNano()), nodeID, req))
+	if err != nil {
+		return nil, err
+	}
+	m, err := f.Get()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to query node %s", nodeID)
+	}
+	switch d := m.Data().(type) {
+	case *measurev1.QueryResponse:
+		if len(d.DataPoints) >= maxRepairElements {
+			return nil, errors.WithMessagef(errRepairBucketTooLarge, "node %s returns %d data points", nodeID, len(d.DataPoints))
+		}
+		return d.DataPoints, nil
+	case common.Error:
+		return nil, errors.WithMessage(errQueryMsg, d.Msg())
+	}
+	return nil, nil
+}
+
+// toFieldsForWrite converts the queried fields to the writing form following the order in the schema.
+func toFieldsForWrite(specs []*databasev1.FieldSpec, fields []*measurev1.DataPoint_Field) []*modelv1.FieldValue {
+	values := make(map[string]*modelv1.FieldValue, len(fields))
+	for _, f := range fields {
+		values[f.Name] = f.Value
+	}
+	result := make([]*modelv1.FieldValue, 0, len(specs))
+	for _, fs := range specs {
+		v, ok := values[fs.GetName()]
+		if !ok || v == nil {
+			v = pbv1.NullFieldValue
+		}
+		result = append(result, v)
+	}
+	return result
+}
@@ -0,0 +1,177 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.uber.org/multierr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+)
+
+const (
+	// federatedStreamLimit and federatedMeasureLimit are the default limits of the queries, which are the same as the data nodes'.
+	federatedStreamLimit  uint32 = 20
+	federatedMeasureLimit uint32 = 100
+)
+
+var (
+	federationProvider     = observability.NewMeterProvider(observability.RootScope.SubScope("liaison").SubScope("federation"))
+	federatedQueryCounter  = federationProvider.Counter("query_total", "catalog")
+	federatedGroupsCounter = federationProvider.Counter("groups_total", "catalog")
+)
+
+// federatedGroups returns the group of the metadata followed by the other groups, without duplicates.
+func federatedGroups(group string, others []string) []string {
+	groups := []string{group}
+	seen := map[string]struct{}{group: {}}
+	for _, g := range others {
+		if _, ok := seen[g]; ok {
+			continue
+		}
+		seen[g] = struct{}{}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// federate runs the query of every group concurrently, and returns the results in the order of the groups.
+func federate[R any](ctx context.Context, groups []string, query func(ctx context.Context, group string) (R, error)) ([]R, error) {
+	results := make([]R, len(groups))
+	errs := make([]error, len(groups))
+	var wg sync.WaitGroup
+	for i, g := range groups {
+		wg.Add(1)
+		go func(i int, g string) {
+			defer wg.Done()
+			results[i], errs[i] = query(ctx, g)
+		}(i, g)
+	}
+	wg.Wait()
+	if err := multierr.Combine(errs...); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// mergeByTimestamp merges the items of the groups by their timestamps, and pages the merged ones by the offset and the limit.
+// The items of every group are expected to be sorted already, so the merge is stable among the groups.
+func mergeByTimestamp[T any](lists [][]T, ts func(T) *timestamppb.Timestamp, sortOrder modelv1.Sort, offset, limit uint32) []T {
+	var merged []T
+	for _, l := range lists {
+		merged = append(merged, l...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, b := ts(merged[i]).AsTime(), ts(merged[j]).AsTime()
+		if sortOrder == modelv1.Sort_SORT_DESC {
+			return a.After(b)
+		}
+		return a.Before(b)
+	})
+	if int(offset) >= len(merged) {
+		return merged[:0]
+	}
+	merged = merged[offset:]
+	if int(limit) < len(merged) {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+// queryGroups runs the stream query in every group, and merges the elements by their timestamps.
+func (s *streamService) queryGroups(ctx context.Context, req *streamv1.QueryRequest) (*streamv1.QueryResponse, error) {
+	switch {
+	case req.GetOrderBy().GetIndexRuleName() != "":
+		return nil, status.Error(codes.InvalidArgument, "the elements of the groups have to be ordered by timestamps")
+	case req.GetColumnar(), req.GetExplain(), len(req.GetContinuationToken()) > 0:
+		return nil, status.Error(codes.InvalidArgument, "neither columnar, explain nor continuation_token supports the groups")
+	}
+	limit := req.GetLimit()
+	if limit == 0 {
+		limit = federatedStreamLimit
+	}
+	groups := federatedGroups(req.GetMetadata().GetGroup(), req.GetGroups())
+	federatedQueryCounter.Inc(1, "stream")
+	federatedGroupsCounter.Inc(float64(len(groups)), "stream")
+	responses, err := federate(ctx, groups, func(ctx context.Context, group string) (*streamv1.QueryResponse, error) {
+		r := proto.Clone(req).(*streamv1.QueryRequest)
+		r.Metadata.Group = group
+		r.Groups = nil
+		r.Offset, r.Limit = 0, req.GetOffset()+limit
+		return s.query(ctx, r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := &streamv1.QueryResponse{}
+	lists := make([][]*streamv1.Element, len(responses))
+	for i, r := range responses {
+		lists[i] = r.GetElements()
+		resp.NodeFailures = append(resp.NodeFailures, r.GetNodeFailures()...)
+		resp.Warnings = append(resp.Warnings, r.GetWarnings()...)
+	}
+	resp.Elements = mergeByTimestamp(lists, (*streamv1.Element).GetTimestamp, req.GetOrderBy().GetSort(), req.GetOffset(), limit)
+	return resp, nil
+}
+
+// queryGroups runs the measure query in every group, and merges the data points by their timestamps.
+func (ms *measureService) queryGroups(ctx context.Context, req *measurev1.QueryRequest) (*measurev1.QueryResponse, error) {
+	switch {
+	case req.GetOrderBy().GetIndexRuleName() != "":
+		return nil, status.Error(codes.InvalidArgument, "the data points of the groups have to be ordered by timestamps")
+	case req.GetAgg() != nil, req.GetGroupBy() != nil, req.GetTop() != nil:
+		return nil, status.Error(codes.InvalidArgument, "the data points of the groups can't be aggregated, grouped or ranked by top")
+	case len(req.GetContinuationToken()) > 0:
+		return nil, status.Error(codes.InvalidArgument, "continuation_token doesn't support the groups")
+	}
+	limit := req.GetLimit()
+	if limit == 0 {
+		limit = federatedMeasureLimit
+	}
+	groups := federatedGroups(req.GetMetadata().GetGroup(), req.GetGroups())
+	federatedQueryCounter.Inc(1, "measure")
+	federatedGroupsCounter.Inc(float64(len(groups)), "measure")
+	responses, err := federate(ctx, groups, func(ctx context.Context, group string) (*measurev1.QueryResponse, error) {
+		r := proto.Clone(req).(*measurev1.QueryRequest)
+		r.Metadata.Group = group
+		r.Groups = nil
+		r.Offset, r.Limit = 0, req.GetOffset()+limit
+		return ms.query(ctx, r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := &measurev1.QueryResponse{}
+	lists := make([][]*measurev1.DataPoint, len(responses))
+	for i, r := range responses {
+		lists[i] = r.GetDataPoints()
+		resp.NodeFailures = append(resp.NodeFailures, r.GetNodeFailures()...)
+	}
+	resp.DataPoints = mergeByTimestamp(lists, (*measurev1.DataPoint).GetTimestamp, req.GetOrderBy().GetSort(), req.GetOffset(), limit)
+	return resp, nil
+}
@@ -0,0 +1,60 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+)
+
+func Test_federatedGroups(t *testing.T) {
+	assert.Equal(t, []string{"tenant-a", "tenant-b", "tenant-c"}, federatedGroups("tenant-a", []string{"tenant-b", "tenant-a", "tenant-c", "tenant-b"}))
+}
+
+func Test_mergeByTimestamp(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	element := func(id string, offset int) *streamv1.Element {
+		return &streamv1.Element{ElementId: id, Timestamp: timestamppb.New(now.Add(time.Duration(offset) * time.Second))}
+	}
+	ids := func(elements []*streamv1.Element) []string {
+		var result []string
+		for _, e := range elements {
+			result = append(result, e.GetElementId())
+		}
+		return result
+	}
+	newLists := func() [][]*streamv1.Element {
+		return [][]*streamv1.Element{
+			{element("a1", 1), element("a3", 3), element("a5", 5)},
+			{element("b2", 2), element("b3", 3)},
+		}
+	}
+	assert.Equal(t, []string{"a1", "b2", "a3", "b3", "a5"},
+		ids(mergeByTimestamp(newLists(), (*streamv1.Element).GetTimestamp, modelv1.Sort_SORT_UNSPECIFIED, 0, 20)))
+	assert.Equal(t, []string{"b2", "a3"},
+		ids(mergeByTimestamp(newLists(), (*streamv1.Element).GetTimestamp, modelv1.Sort_SORT_ASC, 1, 2)))
+	assert.Equal(t, []string{"a5", "a3", "b3"},
+		ids(mergeByTimestamp(newLists(), (*streamv1.Element).GetTimestamp, modelv1.Sort_SORT_DESC, 0, 3)))
+	assert.Empty(t, mergeByTimestamp(newLists(), (*streamv1.Element).GetTimestamp, modelv1.Sort_SORT_DESC, 5, 3))
+}
@@ -0,0 +1,414 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"encoding/json"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/flight"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+)
+
+// flightBatchSize is the max number of rows in a record batch of DoGet.
+const flightBatchSize = exportBatchSize
+
+var (
+	errInvalidTicket = errors.New("the ticket should carry the query of either a stream or a measure")
+
+	flightTimestampType = &arrow.TimestampType{Unit: arrow.Nanosecond, TimeZone: "UTC"}
+)
+
+// flightTicket is the ticket of DoGet in JSON, which carries either the query of a stream or the one of a measure
+// in the JSON mapping of protobuf, e.g. {"stream": {"metadata": {"group": "default", "name": "sw"}, ...}}.
+type flightTicket struct {
+	Stream  json.RawMessage `json:"stream"`
+	Measure json.RawMessage `json:"measure"`
+}
+
+// flightServer serves the results of the queries as the Arrow record batches by the Arrow Flight protocol.
+// A tag is a column named by its family and its name, e.g. searchable.trace_id, and a field is a column named by its name.
+// The node failures, the warnings and the trace of a query go with the first batch as the app metadata in JSON.
+type flightServer struct {
+	flight.BaseFlightServer
+	mem        memory.Allocator
+	streamSVC  *streamService
+	measureSVC *measureService
+}
+
+func (f *flightServer) DoGet(ticket *flight.Ticket, fs flight.FlightService_DoGetServer) error {
+	var t flightTicket
+	if err := json.Unmarshal(ticket.GetTicket(), &t); err != nil {
+		return status.Errorf(codes.InvalidArgument, "%s: %v", errInvalidTicket, err)
+	}
+	switch {
+	case len(t.Stream) > 0 && len(t.Measure) == 0:
+		req := &streamv1.QueryRequest{}
+		if err := unmarshalTicket(t.Stream, req); err != nil {
+			return err
+		}
+		return f.doGetStream(req, fs)
+	case len(t.Measure) > 0 && len(t.Stream) == 0:
+		req := &measurev1.QueryRequest{}
+		if err := unmarshalTicket(t.Measure, req); err != nil {
+			return err
+		}
+		return f.doGetMeasure(req, fs)
+	}
+	return status.Error(codes.InvalidArgument, errInvalidTicket.Error())
+}
+
+func unmarshalTicket(data []byte, req interface {
+	proto.Message
+	Validate() error
+},
+) error {
+	if err := protojson.Unmarshal(data, req); err != nil {
+		return status.Errorf(codes.InvalidArgument, "%s: %v", errInvalidTicket, err)
+	}
+	if err := req.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+func (f *flightServer) doGetStream(req *streamv1.QueryRequest, fs flight.FlightService_DoGetServer) error {
+	specs, _ := f.streamSVC.entityRepo.getTagFamilies(getID(req.GetMetadata()))
+	// the columns are located before the query resolves the projection against the schema
+	rows := newFlightRows(tagColumns(req.GetProjection(), specs), nil,
+		arrow.Field{Name: "element_id", Type: arrow.BinaryTypes.String},
+		arrow.Field{Name: "timestamp", Type: flightTimestampType})
+	w := flight.NewRecordWriter(fs, ipc.WithSchema(rows.schema), ipc.WithAllocator(f.mem))
+	b := array.NewRecordBuilder(f.mem, rows.schema)
+	defer b.Release()
+	err := f.streamSVC.export(fs.Context(), req, func(elements []*streamv1.Element, nodeFailures []*modelv1.NodeFailure, warnings []*modelv1.BlockWarning) error {
+		var appMeta []byte
+		if nodeFailures != nil || warnings != nil {
+			var err error
+			if appMeta, err = protojson.Marshal(&streamv1.QueryResponse{NodeFailures: nodeFailures, Warnings: warnings}); err != nil {
+				return err
+			}
+		}
+		return writeRecords(w, b, len(elements), func(i int) {
+			e := elements[i]
+			b.Field(0).(*array.StringBuilder).Append(e.GetElementId())
+			b.Field(1).(*array.TimestampBuilder).Append(arrow.Timestamp(e.GetTimestamp().AsTime().UnixNano()))
+			rows.appendTags(b, e.GetTagFamilies())
+		}, appMeta)
+	})
+	if err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (f *flightServer) doGetMeasure(req *measurev1.QueryRequest, fs flight.FlightService_DoGetServer) error {
+	specs, _ := f.measureSVC.entityRepo.getTagFamilies(getID(req.GetMetadata()))
+	tags := tagColumns(req.GetTagProjection(), specs)
+	var fieldSpecs []*databasev1.FieldSpec
+	if m, err := f.measureSVC.metadataRepo.MeasureRegistry().GetMeasure(fs.Context(), req.GetMetadata()); err == nil {
+		fieldSpecs = m.GetFields()
+	}
+	resp, err := f.measureSVC.Query(fs.Context(), req)
+	if err != nil {
+		return err
+	}
+	dataPoints := resp.GetDataPoints()
+	rows := newFlightRows(tags, fieldColumns(req.GetFieldProjection().GetNames(), fieldSpecs, dataPoints),
+		arrow.Field{Name: "timestamp", Type: flightTimestampType})
+	w := flight.NewRecordWriter(fs, ipc.WithSchema(rows.schema), ipc.WithAllocator(f.mem))
+	b := array.NewRecordBuilder(f.mem, rows.schema)
+	defer b.Release()
+	var appMeta []byte
+	if resp.GetTrace() != nil || resp.GetNodeFailures() != nil {
+		if appMeta, err = protojson.Marshal(&measurev1.QueryResponse{Trace: resp.GetTrace(), NodeFailures: resp.GetNodeFailures()}); err != nil {
+			_ = w.Close()
+			return err
+		}
+	}
+	if err = writeRecords(w, b, len(dataPoints), func(i int) {
+		dp := dataPoints[i]
+		b.Field(0).(*array.TimestampBuilder).Append(arrow.Timestamp(dp.GetTimestamp().AsTime().UnixNano()))
+		rows.appendTags(b, dp.GetTagFamilies())
+		rows.appendFields(b, dp.GetFields())
+	}, appMeta); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// writeRecords appends n rows to the record batches of at most flightBatchSize rows, and writes them.
+// The app metadata goes with the first batch, which is written without rows if there is no row but the metadata.
+func writeRecords(w *flight.Writer, b *array.RecordBuilder, n int, appendRow func(i int), appMeta []byte) error {
+	for start := 0; start < n || appMeta != nil; start += flightBatchSize {
+		for i := start; i < min(start+flightBatchSize, n); i++ {
+			appendRow(i)
+		}
+		rec := b.NewRecord()
+		err := w.WriteWithAppMetadata(rec, appMeta)
+		rec.Release()
+		if err != nil {
+			return err
+		}
+		appMeta = nil
+	}
+	return nil
+}
+
+// flightColumn is a tag or a field of the results, whose values are null if they're absent or of another type.
+type flightColumn struct {
+	typ    arrow.DataType
+	family string
+	name   string
+}
+
+// tagColumns returns the columns of the tags in the projection, whose types are declared by the specs.
+// The tags absent in the specs are strings.
+func tagColumns(projection *modelv1.TagProjection, specs []*databasev1.TagFamilySpec) []flightColumn {
+	types := make(map[string]databasev1.TagType)
+	for _, tf := range specs {
+		for _, t := range tf.GetTags() {
+			types[tf.GetName()+"."+t.GetName()] = t.GetType()
+		}
+	}
+	var columns []flightColumn
+	for _, tf := range projection.GetTagFamilies() {
+		for _, name := range tf.GetTags() {
+			columns = append(columns, flightColumn{family: tf.GetName(), name: name, typ: arrowTagType(types[tf.GetName()+"."+name])})
+		}
+	}
+	return columns
+}
+
+// fieldColumns returns the columns of the fields in the projection, and the ones computed by the aggregations.
+// The type of a field is the one of its first value, which is declared by the specs if all its values are null.
+func fieldColumns(projection []string, specs []*databasev1.FieldSpec, dataPoints []*measurev1.DataPoint) []flightColumn {
+	types := make(map[string]arrow.DataType)
+	names := append([]string(nil), projection...)
+	for _, dp := range dataPoints {
+		for _, f := range dp.GetFields() {
+			typ, seen := types[f.GetName()]
+			if !seen {
+				names = appendIfAbsent(names, f.GetName())
+			}
+			if typ == nil {
+				types[f.GetName()] = arrowFieldValueType(f.GetValue())
+			}
+		}
+	}
+	for _, f := range specs {
+		if types[f.GetName()] == nil {
+			types[f.GetName()] = arrowFieldType(f.GetFieldType())
+		}
+	}
+	columns := make([]flightColumn, 0, len(names))
+	for _, name := range names {
+		typ := types[name]
+		if typ == nil {
+			typ = arrow.BinaryTypes.String
+		}
+		columns = append(columns, flightColumn{name: name, typ: typ})
+	}
+	return columns
+}
+
+func appendIfAbsent(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
+func arrowTagType(t databasev1.TagType) arrow.DataType {
+	switch t {
+	case databasev1.TagType_TAG_TYPE_INT:
+		return arrow.PrimitiveTypes.Int64
+	case databasev1.TagType_TAG_TYPE_STRING_ARRAY:
+		return arrow.ListOf(arrow.BinaryTypes.String)
+	case databasev1.TagType_TAG_TYPE_INT_ARRAY:
+		return arrow.ListOf(arrow.PrimitiveTypes.Int64)
+	case databasev1.TagType_TAG_TYPE_DATA_BINARY:
+		return arrow.BinaryTypes.Binary
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+func arrowFieldType(t databasev1.FieldType) arrow.DataType {
+	switch t {
+	case databasev1.FieldType_FIELD_TYPE_INT:
+		return arrow.PrimitiveTypes.Int64
+	case databasev1.FieldType_FIELD_TYPE_FLOAT:
+		return arrow.PrimitiveTypes.Float64
+	case databasev1.FieldType_FIELD_TYPE_DATA_BINARY:
+		return arrow.BinaryTypes.Binary
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// arrowFieldValueType returns the type of the value, or nil if it's null.
+func arrowFieldValueType(v *modelv1.FieldValue) arrow.DataType {
+	switch v.GetValue().(type) {
+	case *modelv1.FieldValue_Int:
+		return arrow.PrimitiveTypes.Int64
+	case *modelv1.FieldValue_Float:
+		return arrow.PrimitiveTypes.Float64
+	case *modelv1.FieldValue_BinaryData:
+		return arrow.BinaryTypes.Binary
+	case *modelv1.FieldValue_Str:
+		return arrow.BinaryTypes.String
+	default:
+		return nil
+	}
+}
+
+// flightRows appends the rows of the results to the builders of the columns, which follow the leading columns.
+type flightRows struct {
+	schema   *arrow.Schema
+	tagIndex map[string]int
+	tags     []*modelv1.TagValue
+	fields   []*modelv1.FieldValue
+	fieldIdx map[string]int
+	offset   int
+}
+
+func newFlightRows(tags, fields []flightColumn, leading ...arrow.Field) *flightRows {
+	r := &flightRows{
+		tagIndex: make(map[string]int, len(tags)),
+		fieldIdx: make(map[string]int, len(fields)),
+		tags:     make([]*modelv1.TagValue, len(tags)),
+		fields:   make([]*modelv1.FieldValue, len(fields)),
+		offset:   len(leading),
+	}
+	schemaFields := append([]arrow.Field(nil), leading...)
+	for i, c := range tags {
+		r.tagIndex[c.family+"."+c.name] = i
+		schemaFields = append(schemaFields, arrow.Field{Name: c.family + "." + c.name, Type: c.typ, Nullable: true})
+	}
+	for i, c := range fields {
+		r.fieldIdx[c.name] = i
+		schemaFields = append(schemaFields, arrow.Field{Name: c.name, Type: c.typ, Nullable: true})
+	}
+	r.schema = arrow.NewSchema(schemaFields, nil)
+	return r
+}
+
+func (r *flightRows) appendTags(b *array.RecordBuilder, tagFamilies []*modelv1.TagFamily) {
+	clear(r.tags)
+	for _, tf := range tagFamilies {
+		for _, t := range tf.GetTags() {
+			if i, ok := r.tagIndex[tf.GetName()+"."+t.GetKey()]; ok {
+				r.tags[i] = t.GetValue()
+			}
+		}
+	}
+	for i, v := range r.tags {
+		appendTagValue(b.Field(r.offset+i), v)
+	}
+}
+
+func (r *flightRows) appendFields(b *array.RecordBuilder, fields []*measurev1.DataPoint_Field) {
+	clear(r.fields)
+	for _, f := range fields {
+		if i, ok := r.fieldIdx[f.GetName()]; ok {
+			r.fields[i] = f.GetValue()
+		}
+	}
+	for i, v := range r.fields {
+		appendFieldValue(b.Field(r.offset+len(r.tags)+i), v)
+	}
+}
+
+func appendTagValue(b array.Builder, v *modelv1.TagValue) {
+	switch bb := b.(type) {
+	case *array.StringBuilder:
+		if x, ok := v.GetValue().(*modelv1.TagValue_Str); ok {
+			bb.Append(x.Str.GetValue())
+			return
+		}
+	case *array.Int64Builder:
+		if x, ok := v.GetValue().(*modelv1.TagValue_Int); ok {
+			bb.Append(x.Int.GetValue())
+			return
+		}
+	case *array.BinaryBuilder:
+		if x, ok := v.GetValue().(*modelv1.TagValue_BinaryData); ok {
+			bb.Append(x.BinaryData)
+			return
+		}
+	case *array.ListBuilder:
+		switch vb := bb.ValueBuilder().(type) {
+		case *array.StringBuilder:
+			if x, ok := v.GetValue().(*modelv1.TagValue_StrArray); ok {
+				bb.Append(true)
+				vb.AppendValues(x.StrArray.GetValue(), nil)
+				return
+			}
+		case *array.Int64Builder:
+			if x, ok := v.GetValue().(*modelv1.TagValue_IntArray); ok {
+				bb.Append(true)
+				vb.AppendValues(x.IntArray.GetValue(), nil)
+				return
+			}
+		}
+	}
+	b.AppendNull()
+}
+
+func appendFieldValue(b array.Builder, v *modelv1.FieldValue) {
+	switch bb := b.(type) {
+	case *array.StringBuilder:
+		if x, ok := v.GetValue().(*modelv1.FieldValue_Str); ok {
+			bb.Append(x.Str.GetValue())
+			return
+		}
+	case *array.Int64Builder:
+		if x, ok := v.GetValue().(*modelv1.FieldValue_Int); ok {
+			bb.Append(x.Int.GetValue())
+			return
+		}
+	case *array.Float64Builder:
+		if x, ok := v.GetValue().(*modelv1.FieldValue_Float); ok {
+			bb.Append(x.Float.GetValue())
+			return
+		}
+	case *array.BinaryBuilder:
+		if x, ok := v.GetValue().(*modelv1.FieldValue_BinaryData); ok {
+			bb.Append(x.BinaryData)
+			return
+		}
+	}
+	b.AppendNull()
+}
@@ -0,0 +1,100 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+func Test_flightRows(t *testing.T) {
+	specs := []*databasev1.TagFamilySpec{{
+		Name: "default",
+		Tags: []*databasev1.TagSpec{
+			{Name: "service", Type: databasev1.TagType_TAG_TYPE_STRING},
+			{Name: "ports", Type: databasev1.TagType_TAG_TYPE_INT_ARRAY},
+		},
+	}}
+	projection := &modelv1.TagProjection{TagFamilies: []*modelv1.TagProjection_TagFamily{{Name: "default", Tags: []string{"service", "ports", "unknown"}}}}
+	dataPoints := []*measurev1.DataPoint{
+		{
+			TagFamilies: []*modelv1.TagFamily{{Name: "default", Tags: []*modelv1.Tag{
+				{Key: "service", Value: &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: "svc"}}}},
+				{Key: "ports", Value: &modelv1.TagValue{Value: &modelv1.TagValue_IntArray{IntArray: &modelv1.IntArray{Value: []int64{80, 443}}}}},
+			}}},
+			Fields: []*measurev1.DataPoint_Field{{Name: "mean", Value: &modelv1.FieldValue{Value: &modelv1.FieldValue_Float{Float: &modelv1.Float{Value: 1.5}}}}},
+		},
+		{
+			// the tag of another type and the absent ones are nulls
+			TagFamilies: []*modelv1.TagFamily{{Name: "default", Tags: []*modelv1.Tag{
+				{Key: "service", Value: &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: 1}}}},
+			}}},
+			Fields: []*measurev1.DataPoint_Field{{Name: "total", Value: &modelv1.FieldValue{Value: &modelv1.FieldValue_Int{Int: &modelv1.Int{Value: 10}}}}},
+		},
+	}
+	fields := fieldColumns([]string{"total"}, []*databasev1.FieldSpec{{Name: "total", FieldType: databasev1.FieldType_FIELD_TYPE_INT}}, dataPoints)
+	rows := newFlightRows(tagColumns(projection, specs), fields, arrow.Field{Name: "timestamp", Type: flightTimestampType})
+
+	want := []arrow.Field{
+		{Name: "timestamp", Type: flightTimestampType},
+		{Name: "default.service", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "default.ports", Type: arrow.ListOf(arrow.PrimitiveTypes.Int64), Nullable: true},
+		{Name: "default.unknown", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "total", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+		{Name: "mean", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	}
+	require.Equal(t, len(want), rows.schema.NumFields())
+	for i, f := range want {
+		assert.True(t, arrow.TypeEqual(f.Type, rows.schema.Field(i).Type), f.Name)
+		assert.Equal(t, f.Name, rows.schema.Field(i).Name)
+	}
+
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), rows.schema)
+	defer b.Release()
+	for _, dp := range dataPoints {
+		b.Field(0).(*array.TimestampBuilder).Append(arrow.Timestamp(dp.GetTimestamp().AsTime().UnixNano()))
+		rows.appendTags(b, dp.GetTagFamilies())
+		rows.appendFields(b, dp.GetFields())
+	}
+	rec := b.NewRecord()
+	defer rec.Release()
+	require.Equal(t, int64(2), rec.NumRows())
+	service := rec.Column(1).(*array.String)
+	assert.Equal(t, "svc", service.Value(0))
+	assert.True(t, service.IsNull(1))
+	ports := rec.Column(2).(*array.List)
+	assert.True(t, ports.IsValid(0))
+	assert.Equal(t, []int64{80, 443}, ports.ListValues().(*array.Int64).Int64Values())
+	assert.True(t, ports.IsNull(1))
+	assert.Equal(t, 2, rec.Column(3).NullN())
+	total := rec.Column(4).(*array.Int64)
+	assert.True(t, total.IsNull(0))
+	assert.Equal(t, int64(10), total.Value(1))
+	mean := rec.Column(5).(*array.Float64)
+	assert.Equal(t, 1.5, mean.Value(0))
+	assert.True(t, mean.IsNull(1))
+}
@@ -25,17 +25,22 @@ import (
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/api/data"
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
 	"github.com/apache/skywalking-banyandb/banyand/queue"
 	"github.com/apache/skywalking-banyandb/banyand/tsdb"
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
 	"github.com/apache/skywalking-banyandb/pkg/accesslog"
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/query/logical"
 	"github.com/apache/skywalking-banyandb/pkg/timestamp"
 )
 
@@ -75,6 +80,9 @@ func (ms *measureService) Write(measure measurev1.MeasureService_WriteServer) er
 			return ctx.Err()
 		default:
 		}
+		if !watchdog.WaitReleased(watchdog.ActionWriteBackpressure, ctx.Done()) {
+			return ctx.Err()
+		}
 		writeRequest, err := measure.Recv()
 		if errors.Is(err, io.EOF) {
 			return nil
@@ -88,61 +96,202 @@ func (ms *measureService) Write(measure measurev1.MeasureService_WriteServer) er
 			reply(writeRequest.GetMetadata(), modelv1.Status_STATUS_INVALID_TIMESTAMP, writeRequest.GetMessageId(), measure, ms.sampled)
 			continue
 		}
-		if writeRequest.Metadata.ModRevision > 0 {
-			measureCache, existed := ms.entityRepo.getLocator(getID(writeRequest.GetMetadata()))
-			if !existed {
-				ms.sampled.Error().Err(err).Stringer("written", writeRequest).Msg("failed to measure schema not found")
-				reply(writeRequest.GetMetadata(), modelv1.Status_STATUS_NOT_FOUND, writeRequest.GetMessageId(), measure, ms.sampled)
-				continue
-			}
-			if writeRequest.Metadata.ModRevision != measureCache.ModRevision {
-				ms.sampled.Error().Stringer("written", writeRequest).Msg("the measure schema is expired")
-				reply(writeRequest.GetMetadata(), modelv1.Status_STATUS_EXPIRED_SCHEMA, writeRequest.GetMessageId(), measure, ms.sampled)
-				continue
-			}
-		}
-		entity, tagValues, shardID, err := ms.navigate(writeRequest.GetMetadata(), writeRequest.GetDataPoint().GetTagFamilies())
-		if err != nil {
-			ms.sampled.Error().Err(err).RawJSON("written", logger.Proto(writeRequest)).Msg("failed to navigate to the write target")
-			reply(writeRequest.GetMetadata(), modelv1.Status_STATUS_INTERNAL_ERROR, writeRequest.GetMessageId(), measure, ms.sampled)
+		key, dr := ms.reserve(writeRequest.GetMetadata(), writeRequest.GetIdempotencyKey(), "")
+		switch dr {
+		case dedupCommitted:
+			// the data point is retried within the deduplication window
+			reply(nil, modelv1.Status_STATUS_SUCCEED, writeRequest.GetMessageId(), measure, ms.sampled)
+			continue
+		case dedupInFlight:
+			// the data point is retried before the first write completes, which may still fail
+			reply(writeRequest.GetMetadata(), modelv1.Status_STATUS_IN_FLIGHT, writeRequest.GetMessageId(), measure, ms.sampled)
 			continue
 		}
-		if ms.ingestionAccessLog != nil {
-			if errAccessLog := ms.ingestionAccessLog.Write(writeRequest); errAccessLog != nil {
-				ms.sampled.Error().Err(errAccessLog).RawJSON("written", logger.Proto(writeRequest)).Msg("failed to write access log")
-			}
-		}
-		iwr := &measurev1.InternalWriteRequest{
-			Request:    writeRequest,
-			ShardId:    uint32(shardID),
-			SeriesHash: tsdb.HashEntity(entity),
-			// TODO: remove the first value (measure name) of tagValues
-			EntityValues: tagValues[1:].Encode(),
-		}
-		nodeID, errPickNode := ms.nodeRegistry.Locate(writeRequest.GetMetadata().GetGroup(), writeRequest.GetMetadata().GetName(), uint32(shardID))
-		if errPickNode != nil {
-			ms.sampled.Error().Err(errPickNode).RawJSON("written", logger.Proto(writeRequest)).Msg("failed to pick an available node")
-			reply(writeRequest.GetMetadata(), modelv1.Status_STATUS_INTERNAL_ERROR, writeRequest.GetMessageId(), measure, ms.sampled)
+		if st := ms.write(publisher, writeRequest, true); st != modelv1.Status_STATUS_SUCCEED {
+			ms.dedup.release(key)
+			reply(writeRequest.GetMetadata(), st, writeRequest.GetMessageId(), measure, ms.sampled)
 			continue
 		}
+		ms.dedup.commit(key)
+		reply(nil, modelv1.Status_STATUS_SUCCEED, writeRequest.GetMessageId(), measure, ms.sampled)
+	}
+}
+
+// write routes the data point to the data nodes and returns the status of the write.
+func (ms *measureService) write(publisher queue.BatchPublisher, writeRequest *measurev1.WriteRequest, keepDeadLetter bool) modelv1.Status {
+	iwr, nodes, r, st := ms.route(writeRequest, keepDeadLetter)
+	if st != modelv1.Status_STATUS_SUCCEED {
+		return st
+	}
+	if r.replicas > 1 {
+		if errWritePub := publishReplicas(ms.pipeline, data.TopicMeasureWrite, r, nodes, iwr, ms.lagging); errWritePub != nil {
+			ms.sampled.Error().Err(errWritePub).RawJSON("written", logger.Proto(writeRequest)).Msg("failed to replicate a message")
+			return modelv1.Status_STATUS_INTERNAL_ERROR
+		}
+		return modelv1.Status_STATUS_SUCCEED
+	}
+	for _, nodeID := range nodes[0] {
 		message := bus.NewBatchMessageWithNode(bus.MessageID(time.Now().UnixNano()), nodeID, iwr)
-		_, errWritePub := publisher.Publish(data.TopicMeasureWrite, message)
-		if errWritePub != nil {
+		if _, errWritePub := publisher.Publish(data.TopicMeasureWrite, message); errWritePub != nil {
 			ms.sampled.Error().Err(errWritePub).RawJSON("written", logger.Proto(writeRequest)).Str("nodeID", nodeID).Msg("failed to send a message")
-			reply(writeRequest.GetMetadata(), modelv1.Status_STATUS_INTERNAL_ERROR, writeRequest.GetMessageId(), measure, ms.sampled)
-			continue
+			return modelv1.Status_STATUS_INTERNAL_ERROR
+		}
+	}
+	return modelv1.Status_STATUS_SUCCEED
+}
+
+// route fills and validates the data point, then locates its shard and the nodes of the replicas.
+// If keepDeadLetter is true, the data point rejected by the ingestion validation or failing to be routed to a shard
+// is kept in the dead-letter store if its group opts in.
+func (ms *measureService) route(writeRequest *measurev1.WriteRequest, keepDeadLetter bool) (*measurev1.InternalWriteRequest, [][]string, replication, modelv1.Status) {
+	// the current revision checked against the one of the write locates it, even if the schema changes in between
+	var cs *compiledSchema
+	if writeRequest.Metadata.ModRevision > 0 {
+		var st modelv1.Status
+		var errRevision error
+		cs, st, errRevision = ms.entityRepo.checkRevision(getID(writeRequest.GetMetadata()), writeRequest.Metadata.ModRevision, writeRequest.GetDataPoint().GetTagFamilies())
+		if errRevision != nil {
+			ms.sampled.Error().Err(errRevision).Stringer("written", writeRequest).Msg("the measure schema doesn't match")
+			return nil, nil, replication{}, st
+		}
+	}
+	written := writeRequest.GetDataPoint().GetTagFamilies()
+	reject := func(reason string, cause error) {
+		if !keepDeadLetter || !ms.keepsDeadLetters(writeRequest.GetMetadata().GetGroup()) {
+			return
+		}
+		req := proto.Clone(writeRequest).(*measurev1.WriteRequest)
+		req.DataPoint.TagFamilies = written
+		ms.keepDeadLetter(writeRequest.GetMetadata().GetGroup(), reason, cause, &databasev1.DeadLetter{Request: &databasev1.DeadLetter_Measure{Measure: req}})
+	}
+	writeRequest.DataPoint.TagFamilies = ms.fillTags(writeRequest.GetMetadata(), cs, written)
+	reason, errValidate := ms.validateWrite(writeRequest.GetMetadata(), cs, writeRequest.GetDataPoint().GetTimestamp().AsTime(), writeRequest.GetDataPoint().GetTagFamilies())
+	if errValidate != nil {
+		ms.sampled.Error().Err(errValidate).RawJSON("written", logger.Proto(writeRequest)).Msg("the data point is rejected by the ingestion validation")
+		reject(reason, errValidate)
+		return nil, nil, replication{}, modelv1.Status_STATUS_INVALID_DATA
+	}
+	if st := ms.tenants.admitWrite(writeRequest.GetMetadata().GetGroup()); st != modelv1.Status_STATUS_SUCCEED {
+		ms.sampled.Error().Str("group", writeRequest.GetMetadata().GetGroup()).Msg("the data point exceeds the quotas of the tenant")
+		return nil, nil, replication{}, st
+	}
+	entity, tagValues, shardID, err := ms.locate(writeRequest.GetMetadata(), cs, writeRequest.GetDataPoint().GetTagFamilies())
+	if err != nil {
+		ms.sampled.Error().Err(err).RawJSON("written", logger.Proto(writeRequest)).Msg("failed to navigate to the write target")
+		reject(reasonShardRouting, err)
+		return nil, nil, replication{}, modelv1.Status_STATUS_INTERNAL_ERROR
+	}
+	if ms.ingestionAccessLog != nil {
+		if errAccessLog := ms.ingestionAccessLog.Write(writeRequest); errAccessLog != nil {
+			ms.sampled.Error().Err(errAccessLog).RawJSON("written", logger.Proto(writeRequest)).Msg("failed to write access log")
+		}
+	}
+	iwr := &measurev1.InternalWriteRequest{
+		Request:    writeRequest,
+		ShardId:    uint32(shardID),
+		SeriesHash: tsdb.HashEntity(entity),
+		// TODO: remove the first value (measure name) of tagValues
+		EntityValues: tagValues[1:].Encode(),
+	}
+	nodes, r, errPickNode := ms.locateReplicas(writeRequest.GetMetadata(), shardID)
+	if errPickNode != nil {
+		ms.sampled.Error().Err(errPickNode).RawJSON("written", logger.Proto(writeRequest)).Msg("failed to pick an available node")
+		reject(reasonShardRouting, errPickNode)
+		return nil, nil, r, modelv1.Status_STATUS_INTERNAL_ERROR
+	}
+	return iwr, nodes, r, modelv1.Status_STATUS_SUCCEED
+}
+
+// BulkWrite commits the batches of the data points to the data nodes, and acknowledges them with the hints of the backpressure.
+func (ms *measureService) BulkWrite(measure measurev1.MeasureService_BulkWriteServer) error {
+	bs, err := ms.newBulkSession(measure.Context(), ms.pipeline, data.TopicMeasureWrite, ms.sampled)
+	if err != nil {
+		return err
+	}
+	for {
+		req, errRecv := measure.Recv()
+		if errors.Is(errRecv, io.EOF) {
+			return bs.wait()
+		}
+		if errRecv != nil {
+			ms.sampled.Error().Err(errRecv).Msg("failed to receive the bulk writes")
+			_ = bs.wait()
+			return errRecv
+		}
+		if errSubmit := bs.submit(req.GetBatchId(), func(b *bulkBatch) {
+			for _, writeRequest := range req.GetDataPoints() {
+				if errTime := timestamp.CheckPb(writeRequest.GetDataPoint().GetTimestamp()); errTime != nil {
+					ms.sampled.Error().Stringer("written", writeRequest).Err(errTime).Msg("the data point time is invalid")
+					b.fail(writeRequest.GetMetadata(), writeRequest.GetMessageId(), modelv1.Status_STATUS_INVALID_TIMESTAMP)
+					continue
+				}
+				key, dr := ms.reserve(writeRequest.GetMetadata(), writeRequest.GetIdempotencyKey(), "")
+				if dr == dedupCommitted {
+					continue
+				}
+				if dr == dedupInFlight {
+					b.fail(writeRequest.GetMetadata(), writeRequest.GetMessageId(), modelv1.Status_STATUS_IN_FLIGHT)
+					continue
+				}
+				iwr, nodes, r, st := ms.route(writeRequest, true)
+				if st != modelv1.Status_STATUS_SUCCEED {
+					ms.dedup.release(key)
+					b.fail(writeRequest.GetMetadata(), writeRequest.GetMessageId(), st)
+					continue
+				}
+				b.add(writeRequest.GetMetadata(), writeRequest.GetMessageId(), iwr.GetShardId(), nodes, r, iwr, key)
+			}
+		}, func(r *bulkReply) error {
+			resp := &measurev1.BulkWriteResponse{BatchId: r.batchID, CommitOffset: r.offset, Backpressure: r.hint}
+			for _, f := range r.failures {
+				resp.Failures = append(resp.Failures, &measurev1.WriteResponse{MessageId: f.messageID, Status: f.status, Metadata: f.metadata})
+			}
+			return measure.Send(resp)
+		}); errSubmit != nil {
+			_ = bs.wait()
+			return errSubmit
 		}
-		reply(nil, modelv1.Status_STATUS_SUCCEED, writeRequest.GetMessageId(), measure, ms.sampled)
 	}
 }
 
+// replay writes the dead letters again against the current schema, and returns the statuses of them.
+func (ms *measureService) replay(reqs []*measurev1.WriteRequest) []modelv1.Status {
+	publisher := ms.pipeline.NewBatchPublisher()
+	defer publisher.Close()
+	statuses := make([]modelv1.Status, len(reqs))
+	for i, req := range reqs {
+		req.Metadata.ModRevision = 0
+		statuses[i] = ms.write(publisher, req, false)
+	}
+	return statuses
+}
+
 var emptyMeasureQueryResponse = &measurev1.QueryResponse{DataPoints: make([]*measurev1.DataPoint, 0)}
 
-func (ms *measureService) Query(_ context.Context, req *measurev1.QueryRequest) (*measurev1.QueryResponse, error) {
+func (ms *measureService) Query(ctx context.Context, req *measurev1.QueryRequest) (*measurev1.QueryResponse, error) {
+	if len(req.GetGroups()) > 0 {
+		return ms.queryGroups(ctx, req)
+	}
+	return ms.query(ctx, req)
+}
+
+func (ms *measureService) query(ctx context.Context, req *measurev1.QueryRequest) (*measurev1.QueryResponse, error) {
 	if err := timestamp.CheckTimeRange(req.GetTimeRange()); err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "%v is invalid :%s", req.GetTimeRange(), err)
 	}
-	message := bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req)
+	projection, known, err := ms.resolveProjection(req.GetMetadata(), req.GetTagProjection())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	req.TagProjection = known
+	ctx, cancel := ms.queryContext(ctx)
+	defer cancel()
+	release, errAdmit := ms.tenants.acquireQuery(req.GetMetadata().GetGroup())
+	if errAdmit != nil {
+		return nil, errAdmit
+	}
+	defer release()
+	message := bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req).WithContext(ctx)
 	feat, errQuery := ms.broadcaster.Publish(data.TopicMeasureQuery, message)
 	if errQuery != nil {
 		return nil, errQuery
@@ -157,6 +306,11 @@ func (ms *measureService) Query(_ context.Context, req *measurev1.QueryRequest)
 	data := msg.Data()
 	switch d := data.(type) {
 	case *measurev1.QueryResponse:
+		if projection != known {
+			for _, dp := range d.DataPoints {
+				dp.TagFamilies = logical.FillUnknownTags(dp.TagFamilies, projection, known)
+			}
+		}
 		return d, nil
 	case common.Error:
 		return nil, errors.WithMessage(errQueryMsg, d.Msg())
@@ -168,6 +322,11 @@ func (ms *measureService) TopN(_ context.Context, topNRequest *measurev1.TopNReq
 	if err := timestamp.CheckTimeRange(topNRequest.GetTimeRange()); err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "%v is invalid :%s", topNRequest.GetTimeRange(), err)
 	}
+	release, errAdmit := ms.tenants.acquireQuery(topNRequest.GetMetadata().GetGroup())
+	if errAdmit != nil {
+		return nil, errAdmit
+	}
+	defer release()
 
 	message := bus.NewMessage(bus.MessageID(time.Now().UnixNano()), topNRequest)
 	feat, errQuery := ms.broadcaster.Publish(data.TopicTopNQuery, message)
@@ -188,6 +347,65 @@ func (ms *measureService) TopN(_ context.Context, topNRequest *measurev1.TopNReq
 	return nil, nil
 }
 
+// Subscribe sends the data points of the measures once the data nodes flush them, in batches along with the resume tokens.
+// The data nodes are polled for the data points after the positions in the token, till the subscriber leaves.
+func (ms *measureService) Subscribe(req *measurev1.SubscribeRequest, stream measurev1.MeasureService_SubscribeServer) error {
+	names := req.GetNames()
+	if len(names) == 0 {
+		mm, err := ms.metadataRepo.MeasureRegistry().ListMeasure(stream.Context(), schema.ListOpt{Group: req.GetGroup()})
+		if err != nil {
+			return err
+		}
+		for _, m := range mm {
+			names = append(names, m.GetMetadata().GetName())
+		}
+	}
+	for _, name := range names {
+		metadata := &commonv1.Metadata{Group: req.GetGroup(), Name: name}
+		if _, ok := ms.entityRepo.getTagFamilies(getID(metadata)); !ok {
+			return status.Errorf(codes.NotFound, "measure %s doesn't exist", metadata)
+		}
+	}
+	token, err := decodeChangeToken(req.GetResumeToken())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "the resume token is invalid: %v", err)
+	}
+	limit := int(req.GetMaxDataPoints())
+	if limit == 0 {
+		limit = defaultSubscriptionBatch
+	}
+	batch := &measurev1.SubscribeResponse{}
+	cs := &changeSubscription{
+		pipeline: ms.pipeline,
+		token:    token,
+		topic:    data.TopicMeasureChanges,
+		errMsg:   errSubscribeMsg,
+		limit:    limit,
+		request: func(positions []*modelv1.ChangePosition, latest bool, n uint32) proto.Message {
+			return &measurev1.ChangesRequest{
+				Group:         req.GetGroup(),
+				Names:         names,
+				Positions:     positions,
+				Latest:        latest,
+				MaxDataPoints: n,
+			}
+		},
+		collect: func(d any) ([]*modelv1.ChangePosition, []string, int) {
+			resp, _ := d.(*measurev1.ChangesResponse)
+			batch.DataPoints = append(batch.DataPoints, resp.GetDataPoints()...)
+			return resp.GetPositions(), resp.GetExpired(), len(resp.GetDataPoints())
+		},
+		send: func(token string) error {
+			batch.ResumeToken = token
+			defer func() {
+				batch = &measurev1.SubscribeResponse{}
+			}()
+			return stream.Send(batch)
+		},
+	}
+	return cs.run(stream.Context())
+}
+
 func (ms *measureService) Close() error {
 	return ms.ingestionAccessLog.Close()
 }
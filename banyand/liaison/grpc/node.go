@@ -37,19 +37,33 @@ var (
 // together with the shardID calculated from the incoming data.
 type NodeRegistry interface {
 	Locate(group, name string, shardID uint32) (string, error)
+	// LocateReplicas returns the nodes of each replica of a shard.
+	// A replica has more than one node if it's moving.
+	LocateReplicas(group, name string, shardID uint32, replicas uint32) ([][]string, error)
+	// ShardAssignments returns the shard assignments if the selector keeps them.
+	ShardAssignments() []node.Assignment
+	// PlanShardMoves returns the moves balancing the shards without starting them if the selector rebalances them.
+	PlanShardMoves() []node.Move
+	// SetShardCopier sets the copier of the moved shards if the selector rebalances them.
+	SetShardCopier(copier node.ShardCopier)
+	// Placement returns the cold tier and the segments relocated to it, nil if there is no tier.
+	Placement() *node.Placement
 }
 
 type clusterNodeService struct {
-	pipeline queue.Client
-	sel      node.Selector
+	pipeline  queue.Client
+	sel       node.Selector
+	placement *node.Placement
 	sync.Once
 }
 
 // NewClusterNodeRegistry creates a cluster node registry.
-func NewClusterNodeRegistry(pipeline queue.Client, selector node.Selector) NodeRegistry {
+// The cold nodes are put into the placement instead of the selector if the placement isn't nil.
+func NewClusterNodeRegistry(pipeline queue.Client, selector node.Selector, placement *node.Placement) NodeRegistry {
 	nr := &clusterNodeService{
-		pipeline: pipeline,
-		sel:      selector,
+		pipeline:  pipeline,
+		sel:       selector,
+		placement: placement,
 	}
 	nr.init()
 	return nr
@@ -69,6 +83,53 @@ func (n *clusterNodeService) Locate(group, name string, shardID uint32) (string,
 	return nodeID, nil
 }
 
+func (n *clusterNodeService) LocateReplicas(group, name string, shardID uint32, replicas uint32) ([][]string, error) {
+	nodeIDs, err := node.PickReplicas(n.sel, group, name, shardID, int(replicas))
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to locate %s/%s(%d)", group, name, shardID)
+	}
+	result := make([][]string, 0, len(nodeIDs))
+	rs, ok := n.sel.(node.RebalanceSelector)
+	for i, nodeID := range nodeIDs {
+		if !ok {
+			result = append(result, []string{nodeID})
+			continue
+		}
+		// The replica is moving, the writes have to be routed to both nodes.
+		all, errPick := rs.PickAll(group, node.ReplicaName(name, i), shardID)
+		if errPick != nil || len(all) < 1 || all[0] != nodeID {
+			result = append(result, []string{nodeID})
+			continue
+		}
+		result = append(result, all)
+	}
+	return result, nil
+}
+
+func (n *clusterNodeService) ShardAssignments() []node.Assignment {
+	if rs, ok := n.sel.(node.RebalanceSelector); ok {
+		return rs.Assignments()
+	}
+	return nil
+}
+
+func (n *clusterNodeService) PlanShardMoves() []node.Move {
+	if rs, ok := n.sel.(node.RebalanceSelector); ok {
+		return rs.Plan()
+	}
+	return nil
+}
+
+func (n *clusterNodeService) SetShardCopier(copier node.ShardCopier) {
+	if rs, ok := n.sel.(node.RebalanceSelector); ok {
+		rs.SetCopier(copier)
+	}
+}
+
+func (n *clusterNodeService) Placement() *node.Placement {
+	return n.placement
+}
+
 func (n *clusterNodeService) OnAddOrUpdate(metadata schema.Metadata) {
 	switch metadata.Kind {
 	case schema.KindNode:
@@ -76,6 +137,20 @@ func (n *clusterNodeService) OnAddOrUpdate(metadata schema.Metadata) {
 		if inputNode.Metadata.GetName() == "" {
 			return
 		}
+		// A cold node only receives the relocated segments.
+		if n.placement != nil && node.Tier(inputNode) == node.TierCold {
+			n.sel.RemoveNode(inputNode)
+			n.placement.AddNode(inputNode)
+			return
+		}
+		if n.placement != nil {
+			n.placement.RemoveNode(inputNode)
+		}
+		// A draining node keeps serving queries but receives no more writes.
+		if !isActive(inputNode) {
+			n.sel.RemoveNode(inputNode)
+			return
+		}
 		n.sel.AddNode(inputNode)
 	default:
 	}
@@ -89,7 +164,19 @@ func (n *clusterNodeService) OnDelete(metadata schema.Metadata) {
 			return
 		}
 		n.sel.RemoveNode(dNode)
+		if n.placement != nil {
+			n.placement.RemoveNode(dNode)
+		}
+	default:
+	}
+}
+
+func isActive(node *databasev1.Node) bool {
+	switch node.GetState() {
+	case databasev1.NodeState_NODE_STATE_UNSPECIFIED, databasev1.NodeState_NODE_STATE_ACTIVE:
+		return true
 	default:
+		return false
 	}
 }
 
@@ -104,3 +191,26 @@ func NewLocalNodeRegistry() NodeRegistry {
 func (localNodeService) Locate(_, _ string, _ uint32) (string, error) {
 	return "local", nil
 }
+
+// LocateReplicas of localNodeService always returns local since there is no replica.
+func (localNodeService) LocateReplicas(_, _ string, _ uint32, _ uint32) ([][]string, error) {
+	return [][]string{{"local"}}, nil
+}
+
+// ShardAssignments of localNodeService returns nothing since there is only one node.
+func (localNodeService) ShardAssignments() []node.Assignment {
+	return nil
+}
+
+// PlanShardMoves of localNodeService returns nothing since there is only one node.
+func (localNodeService) PlanShardMoves() []node.Move {
+	return nil
+}
+
+// SetShardCopier of localNodeService does nothing since there is only one node.
+func (localNodeService) SetShardCopier(_ node.ShardCopier) {}
+
+// Placement of localNodeService returns nil since there is no tier.
+func (localNodeService) Placement() *node.Placement {
+	return nil
+}
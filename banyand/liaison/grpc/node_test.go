@@ -61,3 +61,92 @@ func TestClusterNodeRegistry(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, fakeNodeID, nodeID)
 }
+
+func TestClusterNodeRegistry_DrainingNode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	pipeline := queue.NewMockClient(ctrl)
+
+	sel, err := node.NewPickFirstSelector()
+	assert.NoError(t, err)
+
+	cnr := &clusterNodeService{
+		pipeline: pipeline,
+		sel:      sel,
+	}
+	pipeline.EXPECT().Register(gomock.Eq(cnr)).Return().Times(1)
+	cnr.init()
+	update := func(name string, state databasev1.NodeState) {
+		cnr.OnAddOrUpdate(schema.Metadata{
+			TypeMeta: schema.TypeMeta{
+				Kind: schema.KindNode,
+				Name: name,
+			},
+			Spec: &databasev1.Node{
+				Metadata: &commonv1.Metadata{
+					Name: name,
+				},
+				State: state,
+			},
+		})
+	}
+	update("data-node-1", databasev1.NodeState_NODE_STATE_UNSPECIFIED)
+	update("data-node-2", databasev1.NodeState_NODE_STATE_ACTIVE)
+	nodeID, err := cnr.Locate("metrics", "instance_traffic", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "data-node-1", nodeID)
+
+	update("data-node-1", databasev1.NodeState_NODE_STATE_DRAINING)
+	nodeID, err = cnr.Locate("metrics", "instance_traffic", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "data-node-2", nodeID)
+
+	update("data-node-1", databasev1.NodeState_NODE_STATE_ACTIVE)
+	nodeID, err = cnr.Locate("metrics", "instance_traffic", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "data-node-1", nodeID)
+}
+
+func TestClusterNodeRegistry_ColdNode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	pipeline := queue.NewMockClient(ctrl)
+
+	sel, err := node.NewPickFirstSelector()
+	assert.NoError(t, err)
+	placement, err := node.NewPlacement()
+	assert.NoError(t, err)
+
+	cnr := &clusterNodeService{
+		pipeline:  pipeline,
+		sel:       sel,
+		placement: placement,
+	}
+	pipeline.EXPECT().Register(gomock.Eq(cnr)).Return().Times(1)
+	cnr.init()
+	update := func(name, tier string) {
+		cnr.OnAddOrUpdate(schema.Metadata{
+			TypeMeta: schema.TypeMeta{
+				Kind: schema.KindNode,
+				Name: name,
+			},
+			Spec: &databasev1.Node{
+				Metadata: &commonv1.Metadata{
+					Name: name,
+				},
+				Labels: []*databasev1.NodeLabel{{Key: node.TierLabel, Value: tier}},
+			},
+		})
+	}
+	update("data-node-1", node.TierCold)
+	update("data-node-2", node.TierHot)
+	nodeID, err := cnr.Locate("metrics", "instance_traffic", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "data-node-2", nodeID)
+	assert.Equal(t, []string{"data-node-1"}, placement.ColdNodes())
+
+	// the node moves to the hot tier
+	update("data-node-1", node.TierHot)
+	nodeID, err = cnr.Locate("metrics", "instance_traffic", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "data-node-1", nodeID)
+	assert.Empty(t, placement.ColdNodes())
+}
@@ -0,0 +1,116 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/apache/skywalking-banyandb/api/data"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+func newProjectionStreamService(broadcaster queue.Client, allowUnknownTags bool) *streamService {
+	s := &streamService{
+		discoveryService: newDiscoveryService(schema.KindStream, nil, nil),
+		broadcaster:      broadcaster,
+	}
+	s.allowUnknownTags = allowUnknownTags
+	s.entityRepo.log = logger.GetLogger("test")
+	s.entityRepo.OnAddOrUpdate(schema.Metadata{
+		TypeMeta: schema.TypeMeta{Kind: schema.KindStream},
+		Spec: &databasev1.Stream{
+			Metadata: &commonv1.Metadata{Group: "default", Name: "sw"},
+			TagFamilies: []*databasev1.TagFamilySpec{{
+				Name: "searchable",
+				Tags: []*databasev1.TagSpec{{Name: "trace_id"}, {Name: "state"}},
+			}},
+			Entity: &databasev1.Entity{TagNames: []string{"trace_id"}},
+		},
+	})
+	return s
+}
+
+func newProjectionQueryRequest(tags ...string) *streamv1.QueryRequest {
+	return &streamv1.QueryRequest{
+		Metadata: &commonv1.Metadata{Group: "default", Name: "sw"},
+		Projection: &modelv1.TagProjection{TagFamilies: []*modelv1.TagProjection_TagFamily{
+			{Name: "searchable", Tags: tags},
+		}},
+	}
+}
+
+type fixedFuture struct {
+	msg bus.Message
+}
+
+func (f fixedFuture) Get() (bus.Message, error) {
+	return f.msg, nil
+}
+
+func (f fixedFuture) GetAll() ([]bus.Message, error) {
+	return []bus.Message{f.msg}, nil
+}
+
+func TestStreamQueryRejectsUnknownTags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// the query is rejected before it's published
+	s := newProjectionStreamService(queue.NewMockClient(ctrl), false)
+	_, err := s.Query(context.Background(), newProjectionQueryRequest("trace_id", "stat"))
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), "searchable.stat")
+	assert.Contains(t, err.Error(), "searchable[trace_id state]")
+}
+
+func TestStreamQueryAllowsUnknownTags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	broadcaster := queue.NewMockClient(ctrl)
+	s := newProjectionStreamService(broadcaster, true)
+	traceID := &modelv1.Tag{Key: "trace_id", Value: &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: "1"}}}}
+	broadcaster.EXPECT().Publish(data.TopicStreamQuery, gomock.Any()).DoAndReturn(func(_ bus.Topic, messages ...bus.Message) (bus.Future, error) {
+		req := messages[0].Data().(*streamv1.QueryRequest)
+		assert.Equal(t, []string{"trace_id"}, req.GetProjection().GetTagFamilies()[0].GetTags(), "the unknown tags aren't forwarded")
+		return fixedFuture{bus.NewMessage(1, &streamv1.QueryResponse{Elements: []*streamv1.Element{{
+			ElementId:   "1",
+			TagFamilies: []*modelv1.TagFamily{{Name: "searchable", Tags: []*modelv1.Tag{traceID}}},
+		}}})}, nil
+	})
+	resp, err := s.Query(context.Background(), newProjectionQueryRequest("trace_id", "stat", "trace_id"))
+	require.NoError(t, err)
+	require.Len(t, resp.Elements, 1)
+	tags := resp.Elements[0].TagFamilies[0].Tags
+	require.Len(t, tags, 2)
+	assert.Equal(t, "trace_id", tags[0].Key)
+	assert.Equal(t, "stat", tags[1].Key)
+	assert.Equal(t, pbv1.NullTagValue, tags[1].Value)
+}
@@ -95,6 +95,30 @@ func (rs *streamRegistryServer) List(ctx context.Context,
 	}, nil
 }
 
+func (rs *streamRegistryServer) GetSchemaHistory(ctx context.Context,
+	req *databasev1.StreamRegistryServiceGetSchemaHistoryRequest,
+) (*databasev1.StreamRegistryServiceGetSchemaHistoryResponse, error) {
+	versions, err := rs.schemaRegistry.StreamRegistry().GetStreamHistory(ctx, req.GetMetadata())
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.StreamRegistryServiceGetSchemaHistoryResponse{
+		Stream: versions,
+	}, nil
+}
+
+func (rs *streamRegistryServer) Rollback(ctx context.Context,
+	req *databasev1.StreamRegistryServiceRollbackRequest,
+) (*databasev1.StreamRegistryServiceRollbackResponse, error) {
+	modRevision, err := rs.schemaRegistry.StreamRegistry().RollbackStream(ctx, req.GetMetadata(), req.GetModRevision())
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.StreamRegistryServiceRollbackResponse{
+		ModRevision: modRevision,
+	}, nil
+}
+
 func (rs *streamRegistryServer) Exist(ctx context.Context, req *databasev1.StreamRegistryServiceExistRequest) (*databasev1.StreamRegistryServiceExistResponse, error) {
 	_, err := rs.Get(ctx, &databasev1.StreamRegistryServiceGetRequest{Metadata: req.Metadata})
 	if err == nil {
@@ -268,6 +292,27 @@ func (rs *indexRuleRegistryServer) List(ctx context.Context, req *databasev1.Ind
 	}, nil
 }
 
+func (rs *indexRuleRegistryServer) GetSchemaHistory(ctx context.Context, req *databasev1.IndexRuleRegistryServiceGetSchemaHistoryRequest) (
+	*databasev1.IndexRuleRegistryServiceGetSchemaHistoryResponse, error,
+) {
+	versions, err := rs.schemaRegistry.IndexRuleRegistry().GetIndexRuleHistory(ctx, req.GetMetadata())
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.IndexRuleRegistryServiceGetSchemaHistoryResponse{
+		IndexRule: versions,
+	}, nil
+}
+
+func (rs *indexRuleRegistryServer) Rollback(ctx context.Context, req *databasev1.IndexRuleRegistryServiceRollbackRequest) (
+	*databasev1.IndexRuleRegistryServiceRollbackResponse, error,
+) {
+	if err := rs.schemaRegistry.IndexRuleRegistry().RollbackIndexRule(ctx, req.GetMetadata(), req.GetModRevision()); err != nil {
+		return nil, err
+	}
+	return &databasev1.IndexRuleRegistryServiceRollbackResponse{}, nil
+}
+
 func (rs *indexRuleRegistryServer) Exist(ctx context.Context, req *databasev1.IndexRuleRegistryServiceExistRequest) (
 	*databasev1.IndexRuleRegistryServiceExistResponse, error,
 ) {
@@ -350,6 +395,30 @@ func (rs *measureRegistryServer) List(ctx context.Context, req *databasev1.Measu
 	}, nil
 }
 
+func (rs *measureRegistryServer) GetSchemaHistory(ctx context.Context, req *databasev1.MeasureRegistryServiceGetSchemaHistoryRequest) (
+	*databasev1.MeasureRegistryServiceGetSchemaHistoryResponse, error,
+) {
+	versions, err := rs.schemaRegistry.MeasureRegistry().GetMeasureHistory(ctx, req.GetMetadata())
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.MeasureRegistryServiceGetSchemaHistoryResponse{
+		Measure: versions,
+	}, nil
+}
+
+func (rs *measureRegistryServer) Rollback(ctx context.Context, req *databasev1.MeasureRegistryServiceRollbackRequest) (
+	*databasev1.MeasureRegistryServiceRollbackResponse, error,
+) {
+	modRevision, err := rs.schemaRegistry.MeasureRegistry().RollbackMeasure(ctx, req.GetMetadata(), req.GetModRevision())
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.MeasureRegistryServiceRollbackResponse{
+		ModRevision: modRevision,
+	}, nil
+}
+
 func (rs *measureRegistryServer) Exist(ctx context.Context, req *databasev1.MeasureRegistryServiceExistRequest) (*databasev1.MeasureRegistryServiceExistResponse, error) {
 	_, err := rs.Get(ctx, &databasev1.MeasureRegistryServiceGetRequest{Metadata: req.Metadata})
 	if err == nil {
@@ -514,3 +583,147 @@ func (ts *topNAggregationRegistryServer) Exist(ctx context.Context, req *databas
 	}
 	return &databasev1.TopNAggregationRegistryServiceExistResponse{HasGroup: exist, HasTopNAggregation: false}, nil
 }
+
+type alertRuleRegistryServer struct {
+	databasev1.UnimplementedAlertRuleRegistryServiceServer
+	schemaRegistry metadata.Repo
+}
+
+func (as *alertRuleRegistryServer) Create(ctx context.Context,
+	req *databasev1.AlertRuleRegistryServiceCreateRequest,
+) (*databasev1.AlertRuleRegistryServiceCreateResponse, error) {
+	if err := as.schemaRegistry.AlertRuleRegistry().CreateAlertRule(ctx, req.GetAlertRule()); err != nil {
+		return nil, err
+	}
+	return &databasev1.AlertRuleRegistryServiceCreateResponse{}, nil
+}
+
+func (as *alertRuleRegistryServer) Update(ctx context.Context,
+	req *databasev1.AlertRuleRegistryServiceUpdateRequest,
+) (*databasev1.AlertRuleRegistryServiceUpdateResponse, error) {
+	if err := as.schemaRegistry.AlertRuleRegistry().UpdateAlertRule(ctx, req.GetAlertRule()); err != nil {
+		return nil, err
+	}
+	return &databasev1.AlertRuleRegistryServiceUpdateResponse{}, nil
+}
+
+func (as *alertRuleRegistryServer) Delete(ctx context.Context,
+	req *databasev1.AlertRuleRegistryServiceDeleteRequest,
+) (*databasev1.AlertRuleRegistryServiceDeleteResponse, error) {
+	ok, err := as.schemaRegistry.AlertRuleRegistry().DeleteAlertRule(ctx, req.GetMetadata())
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.AlertRuleRegistryServiceDeleteResponse{
+		Deleted: ok,
+	}, nil
+}
+
+func (as *alertRuleRegistryServer) Get(ctx context.Context,
+	req *databasev1.AlertRuleRegistryServiceGetRequest,
+) (*databasev1.AlertRuleRegistryServiceGetResponse, error) {
+	entity, err := as.schemaRegistry.AlertRuleRegistry().GetAlertRule(ctx, req.GetMetadata())
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.AlertRuleRegistryServiceGetResponse{
+		AlertRule: entity,
+	}, nil
+}
+
+func (as *alertRuleRegistryServer) List(ctx context.Context,
+	req *databasev1.AlertRuleRegistryServiceListRequest,
+) (*databasev1.AlertRuleRegistryServiceListResponse, error) {
+	entities, err := as.schemaRegistry.AlertRuleRegistry().ListAlertRule(ctx, schema.ListOpt{Group: req.GetGroup()})
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.AlertRuleRegistryServiceListResponse{
+		AlertRule: entities,
+	}, nil
+}
+
+func (as *alertRuleRegistryServer) Exist(ctx context.Context, req *databasev1.AlertRuleRegistryServiceExistRequest) (
+	*databasev1.AlertRuleRegistryServiceExistResponse, error,
+) {
+	_, err := as.Get(ctx, &databasev1.AlertRuleRegistryServiceGetRequest{Metadata: req.Metadata})
+	if err == nil {
+		return &databasev1.AlertRuleRegistryServiceExistResponse{
+			HasGroup:     true,
+			HasAlertRule: true,
+		}, nil
+	}
+	exist, errGroup := groupExist(ctx, err, req.Metadata, as.schemaRegistry.GroupRegistry())
+	if errGroup != nil {
+		return nil, errGroup
+	}
+	return &databasev1.AlertRuleRegistryServiceExistResponse{HasGroup: exist, HasAlertRule: false}, nil
+}
+
+type tenantRegistryServer struct {
+	databasev1.UnimplementedTenantRegistryServiceServer
+	schemaRegistry metadata.Repo
+	tenants        *tenantRepo
+}
+
+func (ts *tenantRegistryServer) Create(ctx context.Context,
+	req *databasev1.TenantRegistryServiceCreateRequest,
+) (*databasev1.TenantRegistryServiceCreateResponse, error) {
+	if err := ts.schemaRegistry.TenantRegistry().CreateTenant(ctx, req.GetTenant()); err != nil {
+		return nil, err
+	}
+	return &databasev1.TenantRegistryServiceCreateResponse{}, nil
+}
+
+func (ts *tenantRegistryServer) Update(ctx context.Context,
+	req *databasev1.TenantRegistryServiceUpdateRequest,
+) (*databasev1.TenantRegistryServiceUpdateResponse, error) {
+	if err := ts.schemaRegistry.TenantRegistry().UpdateTenant(ctx, req.GetTenant()); err != nil {
+		return nil, err
+	}
+	return &databasev1.TenantRegistryServiceUpdateResponse{}, nil
+}
+
+func (ts *tenantRegistryServer) Delete(ctx context.Context,
+	req *databasev1.TenantRegistryServiceDeleteRequest,
+) (*databasev1.TenantRegistryServiceDeleteResponse, error) {
+	ok, err := ts.schemaRegistry.TenantRegistry().DeleteTenant(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.TenantRegistryServiceDeleteResponse{
+		Deleted: ok,
+	}, nil
+}
+
+func (ts *tenantRegistryServer) Get(ctx context.Context,
+	req *databasev1.TenantRegistryServiceGetRequest,
+) (*databasev1.TenantRegistryServiceGetResponse, error) {
+	entity, err := ts.schemaRegistry.TenantRegistry().GetTenant(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.TenantRegistryServiceGetResponse{
+		Tenant: entity,
+	}, nil
+}
+
+func (ts *tenantRegistryServer) List(ctx context.Context,
+	_ *databasev1.TenantRegistryServiceListRequest,
+) (*databasev1.TenantRegistryServiceListResponse, error) {
+	entities, err := ts.schemaRegistry.TenantRegistry().ListTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.TenantRegistryServiceListResponse{
+		Tenant: entities,
+	}, nil
+}
+
+func (ts *tenantRegistryServer) ListUsages(_ context.Context,
+	_ *databasev1.TenantRegistryServiceListUsagesRequest,
+) (*databasev1.TenantRegistryServiceListUsagesResponse, error) {
+	return &databasev1.TenantRegistryServiceListUsagesResponse{
+		Usages: ts.tenants.listUsages(),
+	}, nil
+}
@@ -0,0 +1,289 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/api/data"
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/node"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+var (
+	relocationProvider       = observability.NewMeterProvider(observability.RootScope.SubScope("liaison").SubScope("relocation"))
+	relocationPendingGauge   = relocationProvider.Gauge("pending_segments", "group")
+	relocatedSegmentsCounter = relocationProvider.Counter("segments_total", "group")
+	relocatedElementsCounter = relocationProvider.Counter("elements_total", "group")
+	relocatedBytesCounter    = relocationProvider.Counter("bytes_total", "group")
+	relocationFailureCounter = relocationProvider.Counter("failed_total", "group")
+)
+
+// relocator moves the segments older than the placement policy of their groups
+// from the hot tier to the cold tier.
+//
+// A segment is copied element by element from its hot owners to the cold nodes picked by
+// the placement, then the hot nodes drop it. The progress is kept in the placement,
+// which the distributed queries consult to read each time range from the right tier.
+// The progress is lost if the liaison restarts, the relocator restarts from the
+// oldest retained segment and the queries read the old data from both tiers meanwhile.
+type relocator struct {
+	placement    *node.Placement
+	metadataRepo metadata.Repo
+	nodeRegistry NodeRegistry
+	pipeline     queue.Client
+	streamSVC    *streamService
+	measureSVC   *measureService
+	log          *logger.Logger
+	closer       *run.Closer
+	interval     time.Duration
+}
+
+func (r *relocator) serve() {
+	if r.interval <= 0 || !r.closer.AddRunning() {
+		return
+	}
+	go func() {
+		defer r.closer.Done()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-r.closer.CloseNotify()
+			cancel()
+		}()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.closer.CloseNotify():
+				return
+			case <-ticker.C:
+				r.relocate(ctx)
+			}
+		}
+	}()
+}
+
+func (r *relocator) stop() {
+	r.closer.Done()
+	r.closer.CloseThenWait()
+}
+
+func (r *relocator) relocate(ctx context.Context) {
+	if len(r.placement.ColdNodes()) == 0 {
+		return
+	}
+	groups, err := r.metadataRepo.GroupRegistry().ListGroup(ctx)
+	if err != nil {
+		r.log.Error().Err(err).Msg("failed to list groups to relocate")
+		return
+	}
+	for _, g := range groups {
+		if err = r.relocateGroup(ctx, g); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			relocationFailureCounter.Inc(1, g.GetMetadata().GetName())
+			r.log.Error().Err(err).Str("group", g.GetMetadata().GetName()).Msg("failed to relocate segments to the cold tier")
+		}
+	}
+}
+
+func (r *relocator) relocateGroup(ctx context.Context, g *commonv1.Group) error {
+	group := g.GetMetadata().GetName()
+	opts := g.GetResourceOpts()
+	retained := retainedRange(g)
+	r.placement.Forget(group, retained.GetBegin().AsTime())
+	threshold, ok := node.ColdThreshold(opts, time.Now())
+	interval := toDuration(opts.GetSegmentInterval())
+	if !ok || interval <= 0 {
+		return nil
+	}
+	states := make(map[int64]node.SegmentState)
+	for _, s := range r.placement.Segments(group) {
+		states[s.Begin.UnixNano()] = s.State
+	}
+	var pending []node.TimeSpan
+	for begin := alignSegment(retained.GetBegin().AsTime(), opts.GetSegmentInterval()); !begin.Add(interval).After(threshold); begin = begin.Add(interval) {
+		if states[begin.UnixNano()] != node.SegmentCold {
+			pending = append(pending, node.TimeSpan{Begin: begin, End: begin.Add(interval)})
+		}
+	}
+	relocationPendingGauge.Set(float64(len(pending)), group)
+	if len(pending) == 0 {
+		return nil
+	}
+	hot := r.hotNodes()
+	if len(hot) == 0 {
+		return errors.New("no hot node holds the segments")
+	}
+	for i, span := range pending {
+		if states[span.Begin.UnixNano()] != node.SegmentCopied {
+			r.placement.Mark(group, span.Begin, span.End, node.SegmentRelocating)
+			if err := r.copySegment(ctx, g, hot, span); err != nil {
+				return errors.WithMessagef(err, "failed to copy the segment [%s, %s)", span.Begin, span.End)
+			}
+			r.placement.Mark(group, span.Begin, span.End, node.SegmentCopied)
+		}
+		if err := r.dropSegment(group, hot, span.End); err != nil {
+			return errors.WithMessagef(err, "failed to drop the segment [%s, %s)", span.Begin, span.End)
+		}
+		r.placement.Mark(group, span.Begin, span.End, node.SegmentCold)
+		relocatedSegmentsCounter.Inc(1, group)
+		relocationPendingGauge.Set(float64(len(pending)-i-1), group)
+		r.log.Info().Str("group", group).Time("begin", span.Begin).Time("end", span.End).Msg("the segment is relocated to the cold tier")
+	}
+	return nil
+}
+
+// copySegment copies all the streams or measures of a group in a segment to the cold tier.
+func (r *relocator) copySegment(ctx context.Context, g *commonv1.Group, hot []string, span node.TimeSpan) error {
+	group := g.GetMetadata().GetName()
+	timeRange := &modelv1.TimeRange{Begin: timestamppb.New(span.Begin), End: timestamppb.New(span.End)}
+	report := func(copied, bytes uint64) {
+		relocatedElementsCounter.Inc(float64(copied), group)
+		relocatedBytesCounter.Inc(float64(bytes), group)
+	}
+	owners := r.coldOwners(g.GetResourceOpts().GetReplicas())
+	switch g.GetCatalog() {
+	case commonv1.Catalog_CATALOG_STREAM:
+		streams, err := r.metadataRepo.StreamRegistry().ListStream(ctx, schema.ListOpt{Group: group})
+		if err != nil {
+			return err
+		}
+		for _, s := range streams {
+			for n, ids := range r.sources(s.GetMetadata(), g.GetResourceOpts(), hot) {
+				job := copyJob{node: n, shardIDs: ids, owners: owners}
+				if err = r.streamSVC.copyFrom(ctx, job, s, timeRange, report); err != nil {
+					return errors.WithMessagef(err, "stream %s", s.GetMetadata().GetName())
+				}
+			}
+		}
+	case commonv1.Catalog_CATALOG_MEASURE:
+		measures, err := r.metadataRepo.MeasureRegistry().ListMeasure(ctx, schema.ListOpt{Group: group})
+		if err != nil {
+			return err
+		}
+		for _, m := range measures {
+			for n, ids := range r.sources(m.GetMetadata(), g.GetResourceOpts(), hot) {
+				job := copyJob{node: n, shardIDs: ids, owners: owners}
+				if err = r.measureSVC.copyFrom(ctx, job, m, timeRange, report); err != nil {
+					return errors.WithMessagef(err, "measure %s", m.GetMetadata().GetName())
+				}
+			}
+		}
+	default:
+	}
+	return nil
+}
+
+// sources returns the hot nodes to read a resource from.
+// Every node is read if the group isn't replicated. Otherwise, a shard is only read from its primary replica.
+func (r *relocator) sources(metadata *commonv1.Metadata, opts *commonv1.ResourceOpts, hot []string) map[string][]uint32 {
+	result := make(map[string][]uint32)
+	if opts.GetReplicas() < 2 {
+		for _, n := range hot {
+			result[n] = nil
+		}
+		return result
+	}
+	for i := uint32(0); i < opts.GetShardNum(); i++ {
+		nodes, err := r.nodeRegistry.LocateReplicas(metadata.GetGroup(), metadata.GetName(), i, opts.GetReplicas())
+		if err != nil || len(nodes) == 0 || len(nodes[0]) == 0 {
+			continue
+		}
+		result[nodes[0][0]] = append(result[nodes[0][0]], i)
+	}
+	return result
+}
+
+func (r *relocator) coldOwners(replicas uint32) func(metadata *commonv1.Metadata, shardID common.ShardID) ([][]string, error) {
+	return func(metadata *commonv1.Metadata, shardID common.ShardID) ([][]string, error) {
+		nodes, err := node.PickReplicas(r.placement, metadata.GetGroup(), metadata.GetName(), uint32(shardID), int(replicas))
+		if err != nil {
+			return nil, err
+		}
+		result := make([][]string, 0, len(nodes))
+		for _, n := range nodes {
+			result = append(result, []string{n})
+		}
+		return result, nil
+	}
+}
+
+// dropSegment asks all hot nodes to drop the segments of a group ending at or before the deadline.
+func (r *relocator) dropSegment(group string, hot []string, deadline time.Time) error {
+	for _, n := range hot {
+		f, err := r.pipeline.Publish(data.TopicSegmentDrop, bus.NewMessageWithNode(bus.MessageID(time.Now().UnixNano()), n,
+			&clusterv1.DropSegmentsRequest{Group: group, Before: timestamppb.New(deadline)}))
+		if err != nil {
+			return err
+		}
+		m, err := f.Get()
+		if err != nil {
+			return errors.WithMessagef(err, "node %s", n)
+		}
+		if e, ok := m.Data().(common.Error); ok {
+			return errors.Errorf("node %s: %s", n, e.Msg())
+		}
+	}
+	return nil
+}
+
+// hotNodes returns the connected data nodes which aren't in the cold tier.
+func (r *relocator) hotNodes() []string {
+	nodes, ok := r.pipeline.(queue.NodePublisher)
+	if !ok {
+		return nil
+	}
+	cold := make(map[string]struct{})
+	for _, n := range r.placement.ColdNodes() {
+		cold[n] = struct{}{}
+	}
+	var result []string
+	for _, n := range nodes.Nodes() {
+		if _, ok := cold[n]; !ok {
+			result = append(result, n)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// alignSegment returns the begin of the segment containing t as the data nodes cut the segments.
+func alignSegment(t time.Time, ir *commonv1.IntervalRule) time.Time {
+	if ir.GetUnit() == commonv1.IntervalRule_UNIT_DAY {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
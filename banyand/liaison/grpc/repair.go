@@ -0,0 +1,277 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/api/data"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/tsdb"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+const (
+	repairBucketSize  = time.Hour
+	maxRepairElements = 100_000
+)
+
+var errRepairBucketTooLarge = errors.New("too many elements in a repair bucket")
+
+type repairKey struct {
+	id string
+	ts int64
+}
+
+// replicaBucket is the elements of a shard in a time bucket held by a node.
+type replicaBucket struct {
+	elements map[repairKey]*streamv1.Element
+	digest   uint64
+}
+
+func (rb *replicaBucket) add(e *streamv1.Element) {
+	k := repairKey{id: e.ElementId, ts: e.Timestamp.AsTime().UnixNano()}
+	if _, ok := rb.elements[k]; ok {
+		return
+	}
+	rb.elements[k] = e
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(k.id))
+	_, _ = h.Write([]byte(time.Unix(0, k.ts).Format(time.RFC3339Nano)))
+	// xor makes the digest independent of the order
+	rb.digest ^= h.Sum64()
+}
+
+// repair compares the replicas of a stream bucket by bucket, and copies the missing elements to the replicas lacking them.
+func (s *streamService) repair(ctx context.Context, metadata *commonv1.Metadata, timeRange *modelv1.TimeRange) (*databasev1.ClusterStateServiceRepairResponse, error) {
+	groupID := getID(&commonv1.Metadata{Name: metadata.GetGroup()})
+	r := s.shardRepo.replication(groupID)
+	if r.replicas < 2 {
+		return nil, status.Errorf(codes.InvalidArgument, "group %s isn't replicated", metadata.GetGroup())
+	}
+	shardNum, ok := s.shardRepo.shardNum(groupID)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "group %s is not found", metadata.GetGroup())
+	}
+	if timeRange == nil {
+		return nil, status.Error(codes.InvalidArgument, "time range is required")
+	}
+	if err := timestamp.CheckTimeRange(timeRange); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v is invalid :%s", timeRange, err)
+	}
+	stream, err := s.metadataRepo.StreamRegistry().GetStream(ctx, metadata)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[common.ShardID][]string, shardNum)
+	nodeSet := make(map[string]struct{})
+	for i := uint32(0); i < shardNum; i++ {
+		nodes, errLocate := s.nodeRegistry.LocateReplicas(metadata.GetGroup(), metadata.GetName(), i, r.replicas)
+		if errLocate != nil {
+			return nil, errLocate
+		}
+		for _, replicaNodes := range nodes {
+			owners[common.ShardID(i)] = append(owners[common.ShardID(i)], replicaNodes[0])
+			nodeSet[replicaNodes[0]] = struct{}{}
+		}
+	}
+	projection := toTagProjection(stream.GetTagFamilies())
+	resp := &databasev1.ClusterStateServiceRepairResponse{}
+	end := timeRange.GetEnd().AsTime()
+	for begin := timeRange.GetBegin().AsTime(); begin.Before(end); begin = begin.Add(repairBucketSize) {
+		if errCtx := ctx.Err(); errCtx != nil {
+			return resp, errCtx
+		}
+		bucketEnd := begin.Add(repairBucketSize)
+		if bucketEnd.After(end) {
+			bucketEnd = end
+		}
+		req := &streamv1.QueryRequest{
+			Metadata:   metadata,
+			TimeRange:  &modelv1.TimeRange{Begin: timestamppb.New(begin), End: timestamppb.New(bucketEnd)},
+			Projection: projection,
+			Limit:      maxRepairElements,
+		}
+		// shard -> node -> elements
+		buckets := make(map[common.ShardID]map[string]*replicaBucket, shardNum)
+		for shardID, nodes := range owners {
+			buckets[shardID] = make(map[string]*replicaBucket, len(nodes))
+			for _, n := range nodes {
+				buckets[shardID][n] = &replicaBucket{elements: make(map[repairKey]*streamv1.Element)}
+			}
+		}
+		for n := range nodeSet {
+			elements, errQuery := s.queryNode(n, req)
+			if errQuery != nil {
+				return resp, errQuery
+			}
+			for _, e := range elements {
+				_, _, shardID, errNav := s.navigate(metadata, toTagFamiliesForWrite(stream.GetTagFamilies(), e.TagFamilies))
+				if errNav != nil {
+					return resp, errNav
+				}
+				if rb, ok := buckets[shardID][n]; ok {
+					rb.add(e)
+				}
+			}
+		}
+		for shardID, replicas := range buckets {
+			resp.ComparedBuckets++
+			if !diverged(replicas) {
+				continue
+			}
+			resp.DivergedBuckets++
+			copied, errCopy := s.copyMissing(metadata, stream, shardID, replicas)
+			resp.CopiedElements += copied
+			if errCopy != nil {
+				return resp, errCopy
+			}
+		}
+	}
+	return resp, nil
+}
+
+func (s *streamService) queryNode(nodeID string, req *streamv1.QueryRequest) ([]*streamv1.Element, error) {
+	f, err := s.pipeline.Publish(data.TopicStreamQuery, bus.NewMessageWithNode(bus.MessageID(time.Now().UnixNano()), nodeID, req))
+	if err != nil {
+		return nil, err
+	}
+	m, err := f.Get()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to query node %s", nodeID)
+	}
+	switch d := m.Data().(type) {
+	case *streamv1.QueryResponse:
+		if len(d.Elements) >= maxRepairElements {
+			return nil, errors.WithMessagef(errRepairBucketTooLarge, "node %s returns %d elements", nodeID, len(d.Elements))
+		}
+		return d.Elements, nil
+	case common.Error:
+		return nil, errors.WithMessage(errQueryMsg, d.Msg())
+	}
+	return nil, nil
+}
+
+func diverged(replicas map[string]*replicaBucket) bool {
+	var digest uint64
+	var size int
+	first := true
+	for _, rb := range replicas {
+		if first {
+			digest, size, first = rb.digest, len(rb.elements), false
+			continue
+		}
+		if rb.digest != digest || len(rb.elements) != size {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *streamService) copyMissing(metadata *commonv1.Metadata, stream *databasev1.Stream, shardID common.ShardID,
+	replicas map[string]*replicaBucket,
+) (uint64, error) {
+	union := make(map[repairKey]*streamv1.Element)
+	for _, rb := range replicas {
+		for k, e := range rb.elements {
+			union[k] = e
+		}
+	}
+	var copied uint64
+	for n, rb := range replicas {
+		for k, e := range union {
+			if _, ok := rb.elements[k]; ok {
+				continue
+			}
+			writeRequest := &streamv1.WriteRequest{
+				Metadata: metadata,
+				Element: &streamv1.ElementValue{
+					ElementId:   e.ElementId,
+					Timestamp:   e.Timestamp,
+					TagFamilies: toTagFamiliesForWrite(stream.GetTagFamilies(), e.TagFamilies),
+				},
+			}
+			entity, tagValues, _, err := s.navigate(metadata, writeRequest.Element.TagFamilies)
+			if err != nil {
+				return copied, err
+			}
+			iwr := &streamv1.InternalWriteRequest{
+				Request:      writeRequest,
+				ShardId:      uint32(shardID),
+				SeriesHash:   tsdb.HashEntity(entity),
+				EntityValues: tagValues[1:].Encode(),
+			}
+			if err = publishSync(s.pipeline, data.TopicStreamWrite, n, iwr); err != nil {
+				return copied, errors.WithMessagef(err, "failed to copy element %s to node %s", k.id, n)
+			}
+			copied++
+		}
+	}
+	return copied, nil
+}
+
+// toTagProjection projects all tags defined in the schema.
+func toTagProjection(specs []*databasev1.TagFamilySpec) *modelv1.TagProjection {
+	projection := &modelv1.TagProjection{}
+	for _, tf := range specs {
+		ptf := &modelv1.TagProjection_TagFamily{Name: tf.GetName()}
+		for _, t := range tf.GetTags() {
+			ptf.Tags = append(ptf.Tags, t.GetName())
+		}
+		projection.TagFamilies = append(projection.TagFamilies, ptf)
+	}
+	return projection
+}
+
+// toTagFamiliesForWrite converts the queried tags to the writing form following the order in the schema.
+func toTagFamiliesForWrite(specs []*databasev1.TagFamilySpec, tagFamilies []*modelv1.TagFamily) []*modelv1.TagFamilyForWrite {
+	values := make(map[string]map[string]*modelv1.TagValue, len(tagFamilies))
+	for _, tf := range tagFamilies {
+		tags := make(map[string]*modelv1.TagValue, len(tf.Tags))
+		for _, t := range tf.Tags {
+			tags[t.Key] = t.Value
+		}
+		values[tf.Name] = tags
+	}
+	result := make([]*modelv1.TagFamilyForWrite, 0, len(specs))
+	for _, tfs := range specs {
+		tf := &modelv1.TagFamilyForWrite{}
+		for _, ts := range tfs.GetTags() {
+			v, ok := values[tfs.GetName()][ts.GetName()]
+			if !ok || v == nil {
+				v = pbv1.NullTagValue
+			}
+			tf.Tags = append(tf.Tags, v)
+		}
+		result = append(result, tf)
+	}
+	return result
+}
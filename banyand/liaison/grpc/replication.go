@@ -0,0 +1,148 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+)
+
+var errQuorumNotReached = errors.New("the write quorum is not reached")
+
+// locateReplicas returns the nodes of each replica of a shard together with the replication policy of the group.
+func (ds *discoveryService) locateReplicas(metadata *commonv1.Metadata, shardID common.ShardID) ([][]string, replication, error) {
+	r := ds.shardRepo.replication(getID(&commonv1.Metadata{Name: metadata.GetGroup()}))
+	nodes, err := ds.nodeRegistry.LocateReplicas(metadata.GetGroup(), metadata.GetName(), uint32(shardID), r.replicas)
+	if err != nil {
+		return nil, r, err
+	}
+	return nodes, r, nil
+}
+
+// publishReplicas sends the data to every replica synchronously and
+// returns once the quorum of replicas have applied it.
+// A moving replica is acknowledged by its current owner, the target node
+// receives the data in the best effort. The owners failing to apply the data are recorded as lagging.
+func publishReplicas(pipeline queue.Client, topic bus.Topic, r replication, nodes [][]string, data any, lagging *laggingReplicas) error {
+	results := make(chan error, len(nodes))
+	for _, replicaNodes := range nodes {
+		go func(replicaNodes []string) {
+			var ownerErr error
+			for i, nodeID := range replicaNodes {
+				err := publishSync(pipeline, topic, nodeID, data)
+				if i == 0 {
+					ownerErr = err
+				}
+				if i == 0 && err != nil {
+					lagging.add(nodeID, data)
+				}
+			}
+			results <- ownerErr
+		}(replicaNodes)
+	}
+	var acked uint32
+	var failed int
+	var allErr error
+	for range nodes {
+		err := <-results
+		if err == nil {
+			acked++
+			if acked >= r.quorum {
+				return nil
+			}
+			continue
+		}
+		allErr = multierr.Append(allErr, err)
+		failed++
+		if len(nodes)-failed < int(r.quorum) {
+			break
+		}
+	}
+	return errors.WithMessagef(errQuorumNotReached, "acked %d of %d replicas, quorum %d: %v", acked, len(nodes), r.quorum, allErr)
+}
+
+// publishCommitReplicas commits the batch to every replica like publishReplicas,
+// a replica acknowledges the batch once its owner applies the whole batch.
+func publishCommitReplicas(ctx context.Context, committer queue.CommitPublisher, topic bus.Topic, r replication, nodes [][]string, batch []any,
+	lagging *laggingReplicas,
+) error {
+	results := make(chan error, len(nodes))
+	for _, replicaNodes := range nodes {
+		go func(replicaNodes []string) {
+			var ownerErr error
+			for i, nodeID := range replicaNodes {
+				messages := make([]bus.Message, len(batch))
+				id := time.Now().UnixNano()
+				for j := range batch {
+					messages[j] = bus.NewBatchMessageWithNode(bus.MessageID(id+int64(j)), nodeID, batch[j])
+				}
+				err := committer.PublishCommit(ctx, topic, nodeID, messages...)
+				if i == 0 {
+					ownerErr = err
+				}
+				if i == 0 && err != nil && ctx.Err() == nil {
+					lagging.add(nodeID, batch...)
+				}
+			}
+			results <- ownerErr
+		}(replicaNodes)
+	}
+	var acked uint32
+	var failed int
+	var allErr error
+	for range nodes {
+		err := <-results
+		if err == nil {
+			acked++
+			if acked >= r.quorum {
+				return nil
+			}
+			continue
+		}
+		allErr = multierr.Append(allErr, err)
+		failed++
+		if len(nodes)-failed < int(r.quorum) {
+			break
+		}
+	}
+	return errors.WithMessagef(errQuorumNotReached, "acked %d of %d replicas, quorum %d: %v", acked, len(nodes), r.quorum, allErr)
+}
+
+func publishSync(pipeline queue.Client, topic bus.Topic, nodeID string, data any) error {
+	f, err := pipeline.Publish(topic, bus.NewMessageWithNode(bus.MessageID(time.Now().UnixNano()), nodeID, data))
+	if err != nil {
+		return err
+	}
+	m, err := f.Get()
+	if err != nil {
+		return err
+	}
+	// a replica rejecting the data replies an error, which isn't an ack
+	if e, ok := m.Data().(common.Error); ok {
+		return errors.New(e.Msg())
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/api/data"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+)
+
+// replicaPipeline replies the writes sent to the nodes in rejecting by common.Error, and acks the others.
+type replicaPipeline struct {
+	queue.Client
+	rejecting map[string]bool
+}
+
+func (p *replicaPipeline) Publish(_ bus.Topic, messages ...bus.Message) (bus.Future, error) {
+	if p.rejecting[messages[0].Node()] {
+		return replicaReply{data: common.NewError("stream %s is not found", "sw")}, nil
+	}
+	return replicaReply{}, nil
+}
+
+type replicaReply struct {
+	data any
+}
+
+func (r replicaReply) Get() (bus.Message, error) {
+	return bus.NewMessage(1, r.data), nil
+}
+
+func (r replicaReply) GetAll() ([]bus.Message, error) {
+	m, err := r.Get()
+	return []bus.Message{m}, err
+}
+
+func Test_publishReplicas_rejected(t *testing.T) {
+	write := &streamv1.InternalWriteRequest{
+		ShardId: 1,
+		Request: &streamv1.WriteRequest{
+			Metadata: &commonv1.Metadata{Group: "sw_stream", Name: "sw"},
+			Element:  &streamv1.ElementValue{Timestamp: timestamppb.Now()},
+		},
+	}
+	nodes := [][]string{{"data-1"}, {"data-2"}, {"data-3"}}
+	r := replication{replicas: 3, quorum: 2}
+
+	lagging := newLaggingReplicas()
+	pipeline := &replicaPipeline{rejecting: map[string]bool{"data-2": true}}
+	require.NoError(t, publishReplicas(pipeline, data.TopicStreamWrite, r, nodes, write, lagging))
+
+	lagging = newLaggingReplicas()
+	pipeline.rejecting["data-3"] = true
+	err := publishReplicas(pipeline, data.TopicStreamWrite, r, nodes, write, lagging)
+	assert.ErrorIs(t, err, errQuorumNotReached)
+	for _, nodeID := range []string{"data-2", "data-3"} {
+		assert.True(t, lagging.has(laggingKey{group: "sw_stream", node: nodeID, shardID: 1, catalog: commonv1.Catalog_CATALOG_STREAM}))
+	}
+	assert.False(t, lagging.has(laggingKey{group: "sw_stream", node: "data-1", shardID: 1, catalog: commonv1.Catalog_CATALOG_STREAM}))
+}
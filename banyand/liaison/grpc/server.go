@@ -25,9 +25,12 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/apache/arrow/go/v14/arrow/flight"
+	"github.com/apache/arrow/go/v14/arrow/memory"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	grpc_validator "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/validator"
 	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
 	grpclib "google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -39,12 +42,15 @@ import (
 	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
 	propertyv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/property/v1"
 	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/liaison/auth"
 	"github.com/apache/skywalking-banyandb/banyand/metadata"
 	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
 	"github.com/apache/skywalking-banyandb/banyand/observability"
 	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/grpchelper"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/run"
+	"github.com/apache/skywalking-banyandb/pkg/tlsconfig"
 )
 
 const defaultRecvSize = 10 << 20
@@ -53,14 +59,27 @@ var (
 	errServerCert        = errors.New("invalid server cert file")
 	errServerKey         = errors.New("invalid server key file")
 	errNoAddr            = errors.New("no address")
+	errAuditStream       = errors.New("the audit group and the audit stream should be set together")
 	errQueryMsg          = errors.New("invalid query message")
+	errPurgeMsg          = errors.New("failed to purge the stream")
+	errDeleteMsg         = errors.New("failed to delete the elements of the stream")
+	errRebuildIndexMsg   = errors.New("failed to rebuild the index of the stream")
+	errVerifyIndexMsg    = errors.New("failed to verify the index of the stream")
+	errSearchSeriesMsg   = errors.New("failed to search the series of the stream")
+	errTagValuesMsg      = errors.New("failed to enumerate the tag values of the stream")
+	errTopNSeriesMsg     = errors.New("failed to get the top series of the stream")
+	errSubscribeMsg      = errors.New("failed to read the changes of the group")
+	errSnapshotMsg       = errors.New("failed to operate the snapshot")
 	errAccessLogRootPath = errors.New("access log root path is required")
+	errBulkInflight      = errors.New("bulk-write-max-inflight-batches must be positive")
 )
 
 // Server defines the gRPC server.
 type Server interface {
 	run.Unit
 	GetPort() *uint32
+	// ReplicaLag returns the replicas missing writes, which are recorded if the catch-up is enabled.
+	ReplicaLag() queue.ReplicaLag
 }
 
 type server struct {
@@ -71,21 +90,49 @@ type server struct {
 	ser *grpclib.Server
 	*propertyServer
 	*topNAggregationRegistryServer
+	*alertRuleRegistryServer
+	*tenantRegistryServer
 	*groupRegistryServer
 	stopCh chan struct{}
 	*indexRuleRegistryServer
 	*measureRegistryServer
+	*clusterStateServer
+	snapshotServer           *snapshotServer
+	deadLetterServer         *deadLetterServer
+	flightServer             *flightServer
+	deadLetters              *deadLetterStore
+	tenants                  *tenantRepo
+	pipeline                 queue.Client
 	streamSVC                *streamService
 	measureSVC               *measureService
+	relocator                *relocator
+	catcher                  *replicaCatcher
+	tlsReloader              *tlsconfig.Reloader
+	authorizer               *authorizer
+	auditTrail               *auditTrail
 	host                     string
+	authConfigFile           string
+	auditGroup               string
+	auditStream              string
 	keyFile                  string
 	certFile                 string
+	clientCAFile             string
 	accessLogRootPath        string
+	deadLetterRootPath       string
+	compressor               string
 	addr                     string
 	accessLogRecorders       []accessLogRecorder
 	maxRecvMsgSize           run.Bytes
+	bulkWALLagThreshold      run.Bytes
+	partShipRate             run.Bytes
+	maxDeadLettersPerGroup   int
+	maxInflightBulkBatches   int
+	maxDedupKeysPerGroup     int
+	queryTimeout             time.Duration
 	port                     uint32
 	enableIngestionAccessLog bool
+	allowUnknownTags         bool
+	recvBufferPool           bool
 	tls                      bool
 }
 
@@ -101,9 +148,12 @@ func NewServer(_ context.Context, pipeline, broadcaster queue.Client, schemaRegi
 		pipeline:         pipeline,
 		broadcaster:      broadcaster,
 	}
+	tenants := newTenantRepo()
 	s := &server{
 		streamSVC:  streamSVC,
 		measureSVC: measureSVC,
+		tenants:    tenants,
+		pipeline:   pipeline,
 		streamRegistryServer: &streamRegistryServer{
 			schemaRegistry: schemaRegistry,
 		},
@@ -122,9 +172,54 @@ func NewServer(_ context.Context, pipeline, broadcaster queue.Client, schemaRegi
 		topNAggregationRegistryServer: &topNAggregationRegistryServer{
 			schemaRegistry: schemaRegistry,
 		},
+		alertRuleRegistryServer: &alertRuleRegistryServer{
+			schemaRegistry: schemaRegistry,
+		},
+		tenantRegistryServer: &tenantRegistryServer{
+			schemaRegistry: schemaRegistry,
+			tenants:        tenants,
+		},
 		propertyServer: &propertyServer{
 			schemaRegistry: schemaRegistry,
 		},
+		clusterStateServer: &clusterStateServer{
+			nodeRegistry:  nodeRegistry,
+			pipeline:      pipeline,
+			metadataRepo:  schemaRegistry,
+			streamSVC:     streamSVC,
+			measureSVC:    measureSVC,
+			decommissions: make(map[string]*decommission),
+		},
+		snapshotServer: &snapshotServer{
+			pipeline: pipeline,
+		},
+		deadLetterServer: &deadLetterServer{
+			streamSVC:  streamSVC,
+			measureSVC: measureSVC,
+		},
+		flightServer: &flightServer{
+			mem:        memory.DefaultAllocator,
+			streamSVC:  streamSVC,
+			measureSVC: measureSVC,
+		},
+	}
+	if placement := nodeRegistry.Placement(); placement != nil {
+		s.relocator = &relocator{
+			placement:    placement,
+			metadataRepo: schemaRegistry,
+			nodeRegistry: nodeRegistry,
+			pipeline:     pipeline,
+			streamSVC:    streamSVC,
+			measureSVC:   measureSVC,
+			closer:       run.NewCloser(1),
+		}
+	}
+	s.catcher = &replicaCatcher{
+		lagging:    newLaggingReplicas(),
+		pipeline:   pipeline,
+		streamSVC:  streamSVC,
+		measureSVC: measureSVC,
+		closer:     run.NewCloser(1),
 	}
 	s.accessLogRecorders = []accessLogRecorder{streamSVC, measureSVC}
 	return s
@@ -134,12 +229,37 @@ func (s *server) PreRun(_ context.Context) error {
 	s.log = logger.GetLogger("liaison-grpc")
 	s.streamSVC.setLogger(s.log)
 	s.measureSVC.setLogger(s.log)
+	if s.relocator != nil {
+		s.relocator.log = s.log.Named("relocation")
+	}
+	s.catcher.log = s.log.Named("replica-catch-up")
+	if s.partShipRate > 0 {
+		s.catcher.limiter = rate.NewLimiter(rate.Limit(s.partShipRate), int(max(int64(s.partShipRate), maxShipChunk)))
+	}
+	s.clusterStateServer.nodeRegistry.SetShardCopier(&shardCopier{catcher: s.catcher, metadataRepo: s.clusterStateServer.metadataRepo})
+	// the standalone server sums up the sizes of its own groups, since there is no data node to report them
+	if nodeStatus, ok := s.pipeline.(queue.NodeStatus); ok {
+		s.tenants.groupSize = nodeStatus.GroupSize
+	} else {
+		s.tenants.groupSize = func(group string) uint64 {
+			return observability.GroupSizes()[group]
+		}
+	}
+	s.tenantRegistryServer.schemaRegistry.RegisterHandler("liaison-tenant", schema.KindTenant, s.tenants)
 	components := []*discoveryService{
 		s.streamSVC.discoveryService,
 		s.measureSVC.discoveryService,
 	}
 	for _, c := range components {
 		c.SetLogger(s.log)
+		c.allowUnknownTags = s.allowUnknownTags
+		c.queryTimeout = s.queryTimeout
+		c.dedup = newDeduplicator(s.maxDedupKeysPerGroup)
+		c.tenants = s.tenants
+		c.bulk = bulkOptions{maxInflight: s.maxInflightBulkBatches, walLagThreshold: int64(s.bulkWALLagThreshold)}
+		if s.catcher.interval > 0 {
+			c.lagging = s.catcher.lagging
+		}
 		if err := c.initialize(); err != nil {
 			return err
 		}
@@ -152,6 +272,25 @@ func (s *server) PreRun(_ context.Context) error {
 			}
 		}
 	}
+	if s.auditStream != "" {
+		s.auditTrail = &auditTrail{
+			registry: s.groupRegistryServer.schemaRegistry,
+			streams:  s.streamSVC,
+			l:        s.log.Named("audit"),
+			group:    s.auditGroup,
+			name:     s.auditStream,
+		}
+	}
+	if s.deadLetterRootPath != "" {
+		deadLetters, err := newDeadLetterStore(s.deadLetterRootPath, s.maxDeadLettersPerGroup)
+		if err != nil {
+			return err
+		}
+		s.deadLetters = deadLetters
+		s.streamSVC.deadLetters = deadLetters
+		s.measureSVC.deadLetters = deadLetters
+		s.deadLetterServer.store = deadLetters
+	}
 	return nil
 }
 
@@ -167,6 +306,10 @@ func (s *server) GetPort() *uint32 {
 	return &s.port
 }
 
+func (s *server) ReplicaLag() queue.ReplicaLag {
+	return s.catcher.lagging
+}
+
 func (s *server) FlagSet() *run.FlagSet {
 	fs := run.NewFlagSet("grpc")
 	s.maxRecvMsgSize = defaultRecvSize
@@ -174,10 +317,45 @@ func (s *server) FlagSet() *run.FlagSet {
 	fs.BoolVar(&s.tls, "tls", false, "connection uses TLS if true, else plain TCP")
 	fs.StringVar(&s.certFile, "cert-file", "", "the TLS cert file")
 	fs.StringVar(&s.keyFile, "key-file", "", "the TLS key file")
+	fs.StringVar(&s.clientCAFile, "client-ca-file", "",
+		"the CA file verifying the client certificates, which requires the clients to present them if it's set. "+
+			"The TLS files are reloaded once they change or SIGHUP is received")
+	fs.StringVar(&s.authConfigFile, "auth-config-file", "",
+		"the file of the users, the roles and the JWT issuer authenticating and authorizing the requests, which are open to all if it's empty")
+	fs.StringVar(&s.auditGroup, "audit-group", "", "the group of the audit stream")
+	fs.StringVar(&s.auditStream, "audit-stream", "",
+		"the stream recording the schema changes and the destructive operations with their actors and snapshots, which disables the audit if it's empty")
 	fs.StringVar(&s.host, "grpc-host", "", "the host of banyand listens")
 	fs.Uint32Var(&s.port, "grpc-port", 17912, "the port of banyand listens")
+	fs.StringVar(&s.compressor, "grpc-compressor", grpchelper.CompressorNone,
+		"the compressor of the responses, which is one of none, gzip and zstd. It's used only if the client accepts it, "+
+			"and the requests compressed by any of them are accepted")
+	fs.BoolVar(&s.recvBufferPool, "grpc-recv-buffer-pool", true, "reuse the buffers receiving the messages to cut the allocations of the writes")
 	fs.BoolVar(&s.enableIngestionAccessLog, "enable-ingestion-access-log", false, "enable ingestion access log")
 	fs.StringVar(&s.accessLogRootPath, "access-log-root-path", "", "access log root path")
+	fs.StringVar(&s.deadLetterRootPath, "dead-letter-root-path", "",
+		"the root path of the dead-letter stores keeping the rejected writes of the groups enabling it, which are disabled if it's empty")
+	fs.IntVar(&s.maxDeadLettersPerGroup, "dead-letter-max-per-group", 10000,
+		"the number of the last rejected writes a group keeps in the dead-letter store, 0 means no bound")
+	fs.BoolVar(&s.allowUnknownTags, "query-allow-unknown-tags", false,
+		"project the tags absent in the schema as nulls instead of rejecting the query, which eases a schema change")
+	fs.DurationVar(&s.queryTimeout, "query-timeout", 0,
+		"the time a query runs before it's canceled, 0 means it runs until the client gives up")
+	fs.IntVar(&s.maxInflightBulkBatches, "bulk-write-max-inflight-batches", 4,
+		"the number of the batches of a bulk write committed at the same time, 1 commits them in order")
+	fs.IntVar(&s.maxDedupKeysPerGroup, "dedup-max-keys-per-group", 1000000,
+		"the number of the idempotency keys a group remembers in its deduplication window, beyond which the oldest ones are forgotten, 0 means no bound")
+	s.bulkWALLagThreshold = 256 << 20
+	fs.VarP(&s.bulkWALLagThreshold, "bulk-write-wal-lag-threshold", "",
+		"the size of the unflushed write-ahead logs of a data node from which the bulk writes are hinted to slow down, 0 disables the hint")
+	if s.relocator != nil {
+		fs.DurationVar(&s.relocator.interval, "relocation-interval", 5*time.Minute,
+			"the interval of relocating the old segments to the cold tier, 0 disables the relocation")
+	}
+	fs.DurationVar(&s.catcher.interval, "replica-catch-up-interval", time.Minute,
+		"the interval of shipping the parts to the replicas missing writes, which also delays a catch-up until the missed writes settle, 0 disables the catch-up")
+	fs.VarP(&s.partShipRate, "part-ship-rate", "",
+		"the max bytes per second of shipping the parts between the data nodes by the catch-ups and the shard moves, no limit if it's zero")
 	return fs
 }
 
@@ -189,6 +367,26 @@ func (s *server) Validate() error {
 	if s.enableIngestionAccessLog && s.accessLogRootPath == "" {
 		return errAccessLogRootPath
 	}
+	if s.maxInflightBulkBatches < 1 {
+		return errBulkInflight
+	}
+	if err := grpchelper.ValidateCompressor(s.compressor); err != nil {
+		return err
+	}
+	if (s.auditGroup == "") != (s.auditStream == "") {
+		return errAuditStream
+	}
+	if s.authConfigFile != "" {
+		authCfg, errAuth := auth.LoadConfig(s.authConfigFile)
+		if errAuth != nil {
+			return errAuth
+		}
+		authSVC, errAuth := auth.NewService(authCfg)
+		if errAuth != nil {
+			return errAuth
+		}
+		s.authorizer = newAuthorizer(authSVC)
+	}
 	observability.UpdateAddress("grpc", s.addr)
 	if !s.tls {
 		return nil
@@ -199,11 +397,12 @@ func (s *server) Validate() error {
 	if s.keyFile == "" {
 		return errServerKey
 	}
-	creds, errTLS := credentials.NewServerTLSFromFile(s.certFile, s.keyFile)
+	reloader, errTLS := tlsconfig.NewReloader(s.certFile, s.keyFile, s.clientCAFile)
 	if errTLS != nil {
-		return errors.Wrap(errTLS, "failed to load cert and key")
+		return errTLS
 	}
-	s.creds = creds
+	s.tlsReloader = reloader
+	s.creds = credentials.NewTLS(reloader.ServerConfig())
 	return nil
 }
 
@@ -211,6 +410,7 @@ func (s *server) Serve() run.StopNotify {
 	var opts []grpclib.ServerOption
 	if s.tls {
 		opts = []grpclib.ServerOption{grpclib.Creds(s.creds)}
+		s.tlsReloader.Start(s.log)
 	}
 	grpcPanicRecoveryHandler := func(p any) (err error) {
 		s.log.Error().Interface("panic", p).Str("stack", string(debug.Stack())).Msg("recovered from panic")
@@ -223,21 +423,42 @@ func (s *server) Serve() run.StopNotify {
 		grpc_validator.StreamServerInterceptor(),
 		recovery.StreamServerInterceptor(recovery.WithRecoveryHandler(grpcPanicRecoveryHandler)),
 	}
+	if s.authorizer != nil {
+		streamChain = append(streamChain, s.authorizer.streamInterceptor())
+	}
 	if streamMetrics != nil {
 		streamChain = append(streamChain, streamMetrics)
 	}
+	unaryCompressor, streamCompressor := grpchelper.SendCompressorInterceptors(s.compressor)
+	if streamCompressor != nil {
+		streamChain = append(streamChain, streamCompressor)
+	}
 	unaryChain := []grpclib.UnaryServerInterceptor{
 		grpc_validator.UnaryServerInterceptor(),
 		recovery.UnaryServerInterceptor(recovery.WithRecoveryHandler(grpcPanicRecoveryHandler)),
 	}
+	if s.authorizer != nil {
+		unaryChain = append(unaryChain, s.authorizer.unaryInterceptor())
+	}
+	if s.auditTrail != nil {
+		// the audit trail follows the authorizer, which puts the actors of the requests in their contexts
+		unaryChain = append(unaryChain, s.auditTrail.unaryInterceptor())
+	}
 	if unaryMetrics != nil {
 		unaryChain = append(unaryChain, unaryMetrics)
 	}
+	if unaryCompressor != nil {
+		unaryChain = append(unaryChain, unaryCompressor)
+	}
 
 	opts = append(opts, grpclib.MaxRecvMsgSize(int(s.maxRecvMsgSize)),
 		grpclib.ChainUnaryInterceptor(unaryChain...),
 		grpclib.ChainStreamInterceptor(streamChain...),
 	)
+	if s.recvBufferPool {
+		// the buffers receiving the messages are reused once the messages are unmarshaled
+		opts = append(opts, grpclib.RecvBufferPool(grpclib.NewSharedBufferPool()))
+	}
 	s.ser = grpclib.NewServer(opts...)
 
 	streamv1.RegisterStreamServiceServer(s.ser, s.streamSVC)
@@ -250,8 +471,18 @@ func (s *server) Serve() run.StopNotify {
 	databasev1.RegisterMeasureRegistryServiceServer(s.ser, s.measureRegistryServer)
 	propertyv1.RegisterPropertyServiceServer(s.ser, s.propertyServer)
 	databasev1.RegisterTopNAggregationRegistryServiceServer(s.ser, s.topNAggregationRegistryServer)
+	databasev1.RegisterAlertRuleRegistryServiceServer(s.ser, s.alertRuleRegistryServer)
+	databasev1.RegisterTenantRegistryServiceServer(s.ser, s.tenantRegistryServer)
+	databasev1.RegisterClusterStateServiceServer(s.ser, s.clusterStateServer)
+	databasev1.RegisterSnapshotServiceServer(s.ser, s.snapshotServer)
+	databasev1.RegisterDeadLetterServiceServer(s.ser, s.deadLetterServer)
+	flight.RegisterFlightServiceServer(s.ser, s.flightServer)
 	grpc_health_v1.RegisterHealthServer(s.ser, health.NewServer())
 
+	if s.relocator != nil {
+		s.relocator.serve()
+	}
+	s.catcher.serve()
 	s.stopCh = make(chan struct{})
 	go func() {
 		lis, err := net.Listen("tcp", s.addr)
@@ -272,6 +503,13 @@ func (s *server) Serve() run.StopNotify {
 
 func (s *server) GracefulStop() {
 	s.log.Info().Msg("stopping")
+	if s.relocator != nil {
+		s.relocator.stop()
+	}
+	s.catcher.stop()
+	if s.tlsReloader != nil {
+		s.tlsReloader.Stop()
+	}
 	stopped := make(chan struct{})
 	go func() {
 		s.ser.GracefulStop()
@@ -280,6 +518,9 @@ func (s *server) GracefulStop() {
 				_ = alr.Close()
 			}
 		}
+		if s.deadLetters != nil {
+			_ = s.deadLetters.close()
+		}
 		close(stopped)
 	}()
 
@@ -0,0 +1,174 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/banyand/metadata"
+	"github.com/apache/skywalking-banyandb/pkg/node"
+)
+
+// shardMoveSettle is the time the source of a move takes to flush the writes it receives before the move starts,
+// which is longer than the flush timeouts of the data nodes by default.
+const shardMoveSettle = 5 * time.Second
+
+var _ node.ShardCopier = (*shardCopier)(nil)
+
+// shardCopier copies the data of a moved shard, which are written before the move starts, to its new owner.
+// The new owner receives the writes since the move starts as well, so it has all data of the shard once the copy is done.
+//
+// The flushed parts of a measure shard are shipped, whose data points replace the ones at the same timestamps on the new owner.
+// The segments of a stream shard the new owner doesn't have are shipped as well. The elements of a stream aren't deduplicated,
+// so the ones of the segments it has, which are created by the writes since the move starts, are copied one by one instead.
+type shardCopier struct {
+	catcher      *replicaCatcher
+	metadataRepo metadata.Repo
+}
+
+func (sc *shardCopier) CopyShard(ctx context.Context, move node.Move, report func(copiedParts, totalParts uint32, lag time.Duration)) error {
+	// the move isn't flipped until the copy is done
+	report(0, 1, time.Since(move.StartedAt))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(move.StartedAt.Add(shardMoveSettle))):
+	}
+	g, err := sc.metadataRepo.GroupRegistry().GetGroup(ctx, move.Key.Group)
+	if err != nil {
+		return err
+	}
+	resource := &commonv1.Metadata{Group: move.Key.Group, Name: node.ResourceName(move.Key.Name)}
+	timeRange := retainedRange(g)
+	timeRange.End = timestamppb.New(move.StartedAt)
+	switch g.GetCatalog() {
+	case commonv1.Catalog_CATALOG_STREAM:
+		return sc.shipStream(ctx, move, resource, timeRange, g.GetResourceOpts().GetSegmentInterval(), report)
+	case commonv1.Catalog_CATALOG_MEASURE:
+		_, parts, errShip := sc.ship(ctx, move, resource, timeRange, commonv1.Catalog_CATALOG_MEASURE, report)
+		if errShip != nil {
+			return errShip
+		}
+		report(parts, parts, 0)
+		return nil
+	default:
+		return nil
+	}
+}
+
+// shipStream ships the segments of the stream shard, then copies the elements of the segments skipped by the shipment
+// one by one. Every segment copied one by one is reported as a part.
+func (sc *shardCopier) shipStream(ctx context.Context, move node.Move, resource *commonv1.Metadata, timeRange *modelv1.TimeRange,
+	segmentInterval *commonv1.IntervalRule, report func(copiedParts, totalParts uint32, lag time.Duration),
+) error {
+	merged, parts, err := sc.ship(ctx, move, resource, timeRange, commonv1.Catalog_CATALOG_STREAM, report)
+	if err != nil {
+		return err
+	}
+	begin, end := timeRange.GetBegin().AsTime(), timeRange.GetEnd().AsTime()
+	var skipped []*modelv1.TimeRange
+	for _, suffix := range merged.GetSkippedSegmentSuffixes() {
+		span, errSpan := storage.SegmentSpan(suffix, segmentInterval)
+		if errSpan != nil {
+			return errSpan
+		}
+		if !span.Start.Before(end) || !span.End.After(begin) {
+			continue
+		}
+		skipped = append(skipped, &modelv1.TimeRange{
+			Begin: timestamppb.New(maxTime(span.Start, begin)),
+			End:   timestamppb.New(minTime(span.End, end)),
+		})
+	}
+	total := parts + uint32(len(skipped))
+	for i, segmentRange := range skipped {
+		report(parts+uint32(i), total, time.Since(move.StartedAt))
+		if err = sc.copyStream(ctx, move, resource, segmentRange); err != nil {
+			return errors.WithMessagef(err, "failed to copy the elements in [%s, %s)",
+				segmentRange.GetBegin().AsTime(), segmentRange.GetEnd().AsTime())
+		}
+	}
+	report(total, total, 0)
+	return nil
+}
+
+func (sc *shardCopier) copyStream(ctx context.Context, move node.Move, resource *commonv1.Metadata, timeRange *modelv1.TimeRange) error {
+	stream, err := sc.metadataRepo.StreamRegistry().GetStream(ctx, resource)
+	if err != nil {
+		return err
+	}
+	job := copyJob{
+		node: move.From,
+		owners: func(_ *commonv1.Metadata, _ common.ShardID) ([][]string, error) {
+			return [][]string{{move.To}}, nil
+		},
+		shardIDs: []uint32{move.Key.ShardID},
+	}
+	return sc.catcher.streamSVC.copyFrom(ctx, job, stream, timeRange, func(_, bytes uint64) {
+		shippedBytesCounter.Inc(float64(bytes), resource.GetGroup())
+	})
+}
+
+// ship ships the flushed parts of the shard on the source to the new owner, and reports the parts copied until the last one.
+// It returns the archive merged by the new owner and the number of the parts shipped.
+func (sc *shardCopier) ship(ctx context.Context, move node.Move, resource *commonv1.Metadata, timeRange *modelv1.TimeRange,
+	catalog commonv1.Catalog, report func(copiedParts, totalParts uint32, lag time.Duration),
+) (*databasev1.SnapshotArchive, uint32, error) {
+	key := laggingKey{group: resource.GetGroup(), node: move.To, shardID: move.Key.ShardID, catalog: catalog}
+	r := laggingRange{
+		names: map[string]struct{}{resource.GetName(): {}},
+		begin: timeRange.GetBegin().AsTime(),
+		end:   timeRange.GetEnd().AsTime(),
+	}
+	var parts uint32
+	merged, err := sc.catcher.ship(ctx, move.From, key, r, func(copiedParts, totalParts uint32) {
+		parts = totalParts
+		// the parts are visible on the new owner once they're merged
+		if copiedParts < totalParts {
+			report(copiedParts, totalParts, time.Since(move.StartedAt))
+		}
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	shippedSegmentsCounter.Inc(float64(merged.GetSegments()), key.group)
+	return merged, parts, nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,135 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/api/data"
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+)
+
+// snapshotNameLayout names a snapshot by the time it's taken at, which sorts the snapshots by time.
+const snapshotNameLayout = "20060102T150405.000Z"
+
+type snapshotServer struct {
+	databasev1.UnimplementedSnapshotServiceServer
+	pipeline queue.Client
+}
+
+// Create asks all data nodes to take a snapshot with the same name.
+func (s *snapshotServer) Create(_ context.Context, req *databasev1.SnapshotServiceCreateRequest) (*databasev1.SnapshotServiceCreateResponse, error) {
+	now := time.Now().UTC()
+	snapshots, err := s.broadcast(&clusterv1.SnapshotRequest{
+		Action:    clusterv1.SnapshotAction_SNAPSHOT_ACTION_CREATE,
+		Name:      now.Format(snapshotNameLayout),
+		Groups:    req.GetGroups(),
+		CreatedAt: timestamppb.New(now),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, status.Error(codes.Unavailable, "no data node takes the snapshot")
+	}
+	return &databasev1.SnapshotServiceCreateResponse{Snapshot: snapshots[0]}, nil
+}
+
+// List returns the snapshots of all data nodes, the archives of a snapshot are gathered from the nodes.
+func (s *snapshotServer) List(_ context.Context, _ *databasev1.SnapshotServiceListRequest) (*databasev1.SnapshotServiceListResponse, error) {
+	snapshots, err := s.broadcast(&clusterv1.SnapshotRequest{Action: clusterv1.SnapshotAction_SNAPSHOT_ACTION_LIST})
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.SnapshotServiceListResponse{Snapshots: snapshots}, nil
+}
+
+// Delete removes the snapshot from all data nodes.
+func (s *snapshotServer) Delete(_ context.Context, req *databasev1.SnapshotServiceDeleteRequest) (*databasev1.SnapshotServiceDeleteResponse, error) {
+	snapshots, err := s.broadcast(&clusterv1.SnapshotRequest{
+		Action: clusterv1.SnapshotAction_SNAPSHOT_ACTION_DELETE,
+		Name:   req.GetName(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &databasev1.SnapshotServiceDeleteResponse{Deleted: len(snapshots) > 0}, nil
+}
+
+// Import asks all data nodes to register the segments archived in the snapshot.
+// A node fails if the snapshot isn't copied to its snapshot root.
+func (s *snapshotServer) Import(_ context.Context, req *databasev1.SnapshotServiceImportRequest) (*databasev1.SnapshotServiceImportResponse, error) {
+	snapshots, err := s.broadcast(&clusterv1.SnapshotRequest{
+		Action: clusterv1.SnapshotAction_SNAPSHOT_ACTION_IMPORT,
+		Name:   req.GetName(),
+		Groups: req.GetGroups(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, status.Errorf(codes.NotFound, "snapshot %s isn't found", req.GetName())
+	}
+	return &databasev1.SnapshotServiceImportResponse{Snapshot: snapshots[0]}, nil
+}
+
+// broadcast sends the request to all data nodes, and merges the snapshots they reply by name.
+func (s *snapshotServer) broadcast(req *clusterv1.SnapshotRequest) ([]*databasev1.Snapshot, error) {
+	futures, err := s.pipeline.Broadcast(data.TopicSnapshot, bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req))
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]*databasev1.Snapshot)
+	for _, f := range futures {
+		msg, errGet := f.Get()
+		if errGet != nil {
+			return nil, errGet
+		}
+		switch d := msg.Data().(type) {
+		case *clusterv1.SnapshotResponse:
+			for _, snp := range d.GetSnapshots() {
+				if m, ok := merged[snp.GetName()]; ok {
+					m.Archives = append(m.Archives, snp.GetArchives()...)
+					continue
+				}
+				merged[snp.GetName()] = snp
+			}
+		case common.Error:
+			return nil, errors.WithMessage(errSnapshotMsg, d.Msg())
+		}
+	}
+	result := make([]*databasev1.Snapshot, 0, len(merged))
+	for _, snp := range merged {
+		result = append(result, snp)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GetName() < result[j].GetName()
+	})
+	return result, nil
+}
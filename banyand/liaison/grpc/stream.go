@@ -25,17 +25,24 @@ import (
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/api/data"
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
 	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
 	"github.com/apache/skywalking-banyandb/banyand/queue"
 	"github.com/apache/skywalking-banyandb/banyand/tsdb"
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
 	"github.com/apache/skywalking-banyandb/pkg/accesslog"
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/query/logical"
+	"github.com/apache/skywalking-banyandb/pkg/query/spill"
 	"github.com/apache/skywalking-banyandb/pkg/timestamp"
 )
 
@@ -75,6 +82,9 @@ func (s *streamService) Write(stream streamv1.StreamService_WriteServer) error {
 			return ctx.Err()
 		default:
 		}
+		if !watchdog.WaitReleased(watchdog.ActionWriteBackpressure, ctx.Done()) {
+			return ctx.Err()
+		}
 		writeEntity, err := stream.Recv()
 		if errors.Is(err, io.EOF) {
 			return nil
@@ -88,56 +98,186 @@ func (s *streamService) Write(stream streamv1.StreamService_WriteServer) error {
 			reply(nil, modelv1.Status_STATUS_INVALID_TIMESTAMP, writeEntity.GetMessageId(), stream, s.sampled)
 			continue
 		}
-		if writeEntity.Metadata.ModRevision > 0 {
-			streamCache, existed := s.entityRepo.getLocator(getID(writeEntity.GetMetadata()))
-			if !existed {
-				s.sampled.Error().Err(err).Stringer("written", writeEntity).Msg("failed to stream schema not found")
-				reply(writeEntity.GetMetadata(), modelv1.Status_STATUS_NOT_FOUND, writeEntity.GetMessageId(), stream, s.sampled)
-				continue
-			}
-			if writeEntity.Metadata.ModRevision != streamCache.ModRevision {
-				s.sampled.Error().Stringer("written", writeEntity).Msg("the stream schema is expired")
-				reply(writeEntity.GetMetadata(), modelv1.Status_STATUS_EXPIRED_SCHEMA, writeEntity.GetMessageId(), stream, s.sampled)
-				continue
-			}
-		}
-		entity, tagValues, shardID, err := s.navigate(writeEntity.GetMetadata(), writeEntity.GetElement().GetTagFamilies())
-		if err != nil {
-			s.sampled.Error().Err(err).RawJSON("written", logger.Proto(writeEntity)).Msg("failed to navigate to the write target")
-			reply(writeEntity.GetMetadata(), modelv1.Status_STATUS_INTERNAL_ERROR, writeEntity.GetMessageId(), stream, s.sampled)
+		key, dr := s.reserve(writeEntity.GetMetadata(), writeEntity.GetIdempotencyKey(), writeEntity.GetElement().GetElementId())
+		switch dr {
+		case dedupCommitted:
+			// the element is retried within the deduplication window
+			reply(nil, modelv1.Status_STATUS_SUCCEED, writeEntity.GetMessageId(), stream, s.sampled)
+			continue
+		case dedupInFlight:
+			// the element is retried before the first write completes, which may still fail
+			reply(writeEntity.GetMetadata(), modelv1.Status_STATUS_IN_FLIGHT, writeEntity.GetMessageId(), stream, s.sampled)
 			continue
 		}
-		if s.ingestionAccessLog != nil {
-			if errAccessLog := s.ingestionAccessLog.Write(writeEntity); errAccessLog != nil {
-				s.sampled.Error().Err(errAccessLog).Msg("failed to write ingestion access log")
-			}
-		}
-		iwr := &streamv1.InternalWriteRequest{
-			Request:    writeEntity,
-			ShardId:    uint32(shardID),
-			SeriesHash: tsdb.HashEntity(entity),
-			// TODO: remove the first value (stream name) of tagValues
-			EntityValues: tagValues[1:].Encode(),
-		}
-		nodeID, errPickNode := s.nodeRegistry.Locate(writeEntity.GetMetadata().GetGroup(), writeEntity.GetMetadata().GetName(), uint32(shardID))
-		if errPickNode != nil {
-			s.sampled.Error().Err(errPickNode).RawJSON("written", logger.Proto(writeEntity)).Msg("failed to pick an available node")
-			reply(writeEntity.GetMetadata(), modelv1.Status_STATUS_INTERNAL_ERROR, writeEntity.GetMessageId(), stream, s.sampled)
+		if st := s.write(publisher, writeEntity, true); st != modelv1.Status_STATUS_SUCCEED {
+			s.dedup.release(key)
+			reply(writeEntity.GetMetadata(), st, writeEntity.GetMessageId(), stream, s.sampled)
 			continue
 		}
+		s.dedup.commit(key)
+		reply(nil, modelv1.Status_STATUS_SUCCEED, writeEntity.GetMessageId(), stream, s.sampled)
+	}
+}
+
+// write routes the element to the data nodes and returns the status of the write.
+func (s *streamService) write(publisher queue.BatchPublisher, writeEntity *streamv1.WriteRequest, keepDeadLetter bool) modelv1.Status {
+	iwr, nodes, r, st := s.route(writeEntity, keepDeadLetter)
+	if st != modelv1.Status_STATUS_SUCCEED {
+		return st
+	}
+	if r.replicas > 1 {
+		if errWritePub := publishReplicas(s.pipeline, data.TopicStreamWrite, r, nodes, iwr, s.lagging); errWritePub != nil {
+			s.sampled.Error().Err(errWritePub).RawJSON("written", logger.Proto(writeEntity)).Msg("failed to replicate a message")
+			return modelv1.Status_STATUS_INTERNAL_ERROR
+		}
+		return modelv1.Status_STATUS_SUCCEED
+	}
+	for _, nodeID := range nodes[0] {
 		message := bus.NewBatchMessageWithNode(bus.MessageID(time.Now().UnixNano()), nodeID, iwr)
-		_, errWritePub := publisher.Publish(data.TopicStreamWrite, message)
-		if errWritePub != nil {
+		if _, errWritePub := publisher.Publish(data.TopicStreamWrite, message); errWritePub != nil {
 			s.sampled.Error().Err(errWritePub).RawJSON("written", logger.Proto(writeEntity)).Str("nodeID", nodeID).Msg("failed to send a message")
-			reply(writeEntity.GetMetadata(), modelv1.Status_STATUS_INTERNAL_ERROR, writeEntity.GetMessageId(), stream, s.sampled)
+			return modelv1.Status_STATUS_INTERNAL_ERROR
 		}
-		reply(nil, modelv1.Status_STATUS_SUCCEED, writeEntity.GetMessageId(), stream, s.sampled)
 	}
+	return modelv1.Status_STATUS_SUCCEED
+}
+
+// route fills and validates the element, then locates its shard and the nodes of the replicas.
+// If keepDeadLetter is true, the element rejected by the ingestion validation or failing to be routed to a shard
+// is kept in the dead-letter store if its group opts in.
+func (s *streamService) route(writeEntity *streamv1.WriteRequest, keepDeadLetter bool) (*streamv1.InternalWriteRequest, [][]string, replication, modelv1.Status) {
+	// the current revision checked against the one of the write locates it, even if the schema changes in between
+	var cs *compiledSchema
+	if writeEntity.Metadata.ModRevision > 0 {
+		var st modelv1.Status
+		var errRevision error
+		cs, st, errRevision = s.entityRepo.checkRevision(getID(writeEntity.GetMetadata()), writeEntity.Metadata.ModRevision, writeEntity.GetElement().GetTagFamilies())
+		if errRevision != nil {
+			s.sampled.Error().Err(errRevision).Stringer("written", writeEntity).Msg("the stream schema doesn't match")
+			return nil, nil, replication{}, st
+		}
+	}
+	written := writeEntity.GetElement().GetTagFamilies()
+	reject := func(reason string, cause error) {
+		if !keepDeadLetter || !s.keepsDeadLetters(writeEntity.GetMetadata().GetGroup()) {
+			return
+		}
+		req := proto.Clone(writeEntity).(*streamv1.WriteRequest)
+		req.Element.TagFamilies = written
+		s.keepDeadLetter(writeEntity.GetMetadata().GetGroup(), reason, cause, &databasev1.DeadLetter{Request: &databasev1.DeadLetter_Stream{Stream: req}})
+	}
+	writeEntity.Element.TagFamilies = s.fillTags(writeEntity.GetMetadata(), cs, written)
+	reason, errValidate := s.validateWrite(writeEntity.GetMetadata(), cs, writeEntity.GetElement().GetTimestamp().AsTime(), writeEntity.GetElement().GetTagFamilies())
+	if errValidate != nil {
+		s.sampled.Error().Err(errValidate).RawJSON("written", logger.Proto(writeEntity)).Msg("the element is rejected by the ingestion validation")
+		reject(reason, errValidate)
+		return nil, nil, replication{}, modelv1.Status_STATUS_INVALID_DATA
+	}
+	if st := s.tenants.admitWrite(writeEntity.GetMetadata().GetGroup()); st != modelv1.Status_STATUS_SUCCEED {
+		s.sampled.Error().Str("group", writeEntity.GetMetadata().GetGroup()).Msg("the element exceeds the quotas of the tenant")
+		return nil, nil, replication{}, st
+	}
+	entity, tagValues, shardID, err := s.locate(writeEntity.GetMetadata(), cs, writeEntity.GetElement().GetTagFamilies())
+	if err != nil {
+		s.sampled.Error().Err(err).RawJSON("written", logger.Proto(writeEntity)).Msg("failed to navigate to the write target")
+		reject(reasonShardRouting, err)
+		return nil, nil, replication{}, modelv1.Status_STATUS_INTERNAL_ERROR
+	}
+	if s.ingestionAccessLog != nil {
+		if errAccessLog := s.ingestionAccessLog.Write(writeEntity); errAccessLog != nil {
+			s.sampled.Error().Err(errAccessLog).Msg("failed to write ingestion access log")
+		}
+	}
+	iwr := &streamv1.InternalWriteRequest{
+		Request:    writeEntity,
+		ShardId:    uint32(shardID),
+		SeriesHash: tsdb.HashEntity(entity),
+		// TODO: remove the first value (stream name) of tagValues
+		EntityValues: tagValues[1:].Encode(),
+	}
+	nodes, r, errPickNode := s.locateReplicas(writeEntity.GetMetadata(), shardID)
+	if errPickNode != nil {
+		s.sampled.Error().Err(errPickNode).RawJSON("written", logger.Proto(writeEntity)).Msg("failed to pick an available node")
+		reject(reasonShardRouting, errPickNode)
+		return nil, nil, r, modelv1.Status_STATUS_INTERNAL_ERROR
+	}
+	return iwr, nodes, r, modelv1.Status_STATUS_SUCCEED
+}
+
+// BulkWrite commits the batches of the elements to the data nodes, and acknowledges them with the hints of the backpressure.
+func (s *streamService) BulkWrite(stream streamv1.StreamService_BulkWriteServer) error {
+	bs, err := s.newBulkSession(stream.Context(), s.pipeline, data.TopicStreamWrite, s.sampled)
+	if err != nil {
+		return err
+	}
+	for {
+		req, errRecv := stream.Recv()
+		if errors.Is(errRecv, io.EOF) {
+			return bs.wait()
+		}
+		if errRecv != nil {
+			s.sampled.Error().Err(errRecv).Msg("failed to receive the bulk writes")
+			_ = bs.wait()
+			return errRecv
+		}
+		if errSubmit := bs.submit(req.GetBatchId(), func(b *bulkBatch) {
+			for _, writeEntity := range req.GetElements() {
+				if errTime := timestamp.CheckPb(writeEntity.GetElement().GetTimestamp()); errTime != nil {
+					s.sampled.Error().Stringer("written", writeEntity).Err(errTime).Msg("the element time is invalid")
+					b.fail(writeEntity.GetMetadata(), writeEntity.GetMessageId(), modelv1.Status_STATUS_INVALID_TIMESTAMP)
+					continue
+				}
+				key, dr := s.reserve(writeEntity.GetMetadata(), writeEntity.GetIdempotencyKey(), writeEntity.GetElement().GetElementId())
+				if dr == dedupCommitted {
+					continue
+				}
+				if dr == dedupInFlight {
+					b.fail(writeEntity.GetMetadata(), writeEntity.GetMessageId(), modelv1.Status_STATUS_IN_FLIGHT)
+					continue
+				}
+				iwr, nodes, r, st := s.route(writeEntity, true)
+				if st != modelv1.Status_STATUS_SUCCEED {
+					s.dedup.release(key)
+					b.fail(writeEntity.GetMetadata(), writeEntity.GetMessageId(), st)
+					continue
+				}
+				b.add(writeEntity.GetMetadata(), writeEntity.GetMessageId(), iwr.GetShardId(), nodes, r, iwr, key)
+			}
+		}, func(r *bulkReply) error {
+			resp := &streamv1.BulkWriteResponse{BatchId: r.batchID, CommitOffset: r.offset, Backpressure: r.hint}
+			for _, f := range r.failures {
+				resp.Failures = append(resp.Failures, &streamv1.WriteResponse{MessageId: f.messageID, Status: f.status, Metadata: f.metadata})
+			}
+			return stream.Send(resp)
+		}); errSubmit != nil {
+			_ = bs.wait()
+			return errSubmit
+		}
+	}
+}
+
+// replay writes the dead letters again against the current schema, and returns the statuses of them.
+func (s *streamService) replay(reqs []*streamv1.WriteRequest) []modelv1.Status {
+	publisher := s.pipeline.NewBatchPublisher()
+	defer publisher.Close()
+	statuses := make([]modelv1.Status, len(reqs))
+	for i, req := range reqs {
+		req.Metadata.ModRevision = 0
+		statuses[i] = s.write(publisher, req, false)
+	}
+	return statuses
 }
 
 var emptyStreamQueryResponse = &streamv1.QueryResponse{Elements: make([]*streamv1.Element, 0)}
 
-func (s *streamService) Query(_ context.Context, req *streamv1.QueryRequest) (*streamv1.QueryResponse, error) {
+func (s *streamService) Query(ctx context.Context, req *streamv1.QueryRequest) (*streamv1.QueryResponse, error) {
+	if len(req.GetGroups()) > 0 {
+		return s.queryGroups(ctx, req)
+	}
+	return s.query(ctx, req)
+}
+
+func (s *streamService) query(ctx context.Context, req *streamv1.QueryRequest) (*streamv1.QueryResponse, error) {
 	timeRange := req.GetTimeRange()
 	if timeRange == nil {
 		req.TimeRange = timestamp.DefaultTimeRange
@@ -145,7 +285,19 @@ func (s *streamService) Query(_ context.Context, req *streamv1.QueryRequest) (*s
 	if err := timestamp.CheckTimeRange(req.GetTimeRange()); err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "%v is invalid :%s", req.GetTimeRange(), err)
 	}
-	message := bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req)
+	projection, known, err := s.resolveProjection(req.GetMetadata(), req.GetProjection())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	req.Projection = known
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	release, errAdmit := s.tenants.acquireQuery(req.GetMetadata().GetGroup())
+	if errAdmit != nil {
+		return nil, errAdmit
+	}
+	defer release()
+	message := bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req).WithContext(ctx)
 	feat, errQuery := s.broadcaster.Publish(data.TopicStreamQuery, message)
 	if errQuery != nil {
 		if errors.Is(errQuery, io.EOF) {
@@ -160,6 +312,7 @@ func (s *streamService) Query(_ context.Context, req *streamv1.QueryRequest) (*s
 	data := msg.Data()
 	switch d := data.(type) {
 	case *streamv1.QueryResponse:
+		fillUnknownTags(d.Elements, projection, known)
 		return d, nil
 	case common.Error:
 		return nil, errors.WithMessage(errQueryMsg, d.Msg())
@@ -167,6 +320,385 @@ func (s *streamService) Query(_ context.Context, req *streamv1.QueryRequest) (*s
 	return nil, nil
 }
 
+// fillUnknownTags projects the tags absent in the schema as nulls.
+func fillUnknownTags(elements []*streamv1.Element, projection, known *modelv1.TagProjection) {
+	if projection == known {
+		return
+	}
+	for _, e := range elements {
+		e.TagFamilies = logical.FillUnknownTags(e.TagFamilies, projection, known)
+	}
+}
+
+// exportBatchSize is the max number of elements in a response of Export.
+const exportBatchSize = 1000
+
+func (s *streamService) Export(req *streamv1.QueryRequest, stream streamv1.StreamService_ExportServer) error {
+	return s.export(stream.Context(), req, func(elements []*streamv1.Element, nodeFailures []*modelv1.NodeFailure, warnings []*modelv1.BlockWarning) error {
+		return sendElements(stream, elements, nodeFailures, warnings)
+	})
+}
+
+// export runs the query, and passes the elements to send piece by piece, which are spilled to disk if there are too many of them.
+// The node failures and the warnings go with the first piece.
+func (s *streamService) export(ctx context.Context, req *streamv1.QueryRequest,
+	send func(elements []*streamv1.Element, nodeFailures []*modelv1.NodeFailure, warnings []*modelv1.BlockWarning) error,
+) error {
+	timeRange := req.GetTimeRange()
+	if timeRange == nil {
+		req.TimeRange = timestamp.DefaultTimeRange
+	}
+	if err := timestamp.CheckTimeRange(req.GetTimeRange()); err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v is invalid :%s", req.GetTimeRange(), err)
+	}
+	projection, known, err := s.resolveProjection(req.GetMetadata(), req.GetProjection())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	req.Projection = known
+	ctx, cancel := s.queryContext(ctx)
+	defer cancel()
+	release, errAdmit := s.tenants.acquireQuery(req.GetMetadata().GetGroup())
+	if errAdmit != nil {
+		return errAdmit
+	}
+	defer release()
+	message := bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req).WithContext(ctx)
+	feat, errQuery := s.broadcaster.Publish(data.TopicStreamExport, message)
+	if errQuery != nil {
+		if errors.Is(errQuery, io.EOF) {
+			return nil
+		}
+		return errQuery
+	}
+	msg, errFeat := feat.Get()
+	if errFeat != nil {
+		return errFeat
+	}
+	switch d := msg.Data().(type) {
+	case *streamv1.QueryResponse:
+		fillUnknownTags(d.Elements, projection, known)
+		return send(d.Elements, d.NodeFailures, d.Warnings)
+	case *spill.Export:
+		// closing the elements removes the spill file whether the export completes or is canceled
+		defer d.Elements.Close()
+		nodeFailures, warnings := d.NodeFailures, d.Warnings
+		for {
+			elements, err := d.Elements.Next()
+			if errors.Is(err, io.EOF) {
+				if nodeFailures != nil || warnings != nil {
+					return send(nil, nodeFailures, warnings)
+				}
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			fillUnknownTags(elements, projection, known)
+			if err = send(elements, nodeFailures, warnings); err != nil {
+				return err
+			}
+			nodeFailures, warnings = nil, nil
+		}
+	case common.Error:
+		return errors.WithMessage(errQueryMsg, d.Msg())
+	}
+	return nil
+}
+
+// sendElements sends the elements in batches, the node failures and the warnings go with the first batch.
+func sendElements(stream streamv1.StreamService_ExportServer, elements []*streamv1.Element,
+	nodeFailures []*modelv1.NodeFailure, warnings []*modelv1.BlockWarning,
+) error {
+	for {
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+		batch := elements
+		if len(batch) > exportBatchSize {
+			batch = batch[:exportBatchSize]
+		}
+		if err := stream.Send(&streamv1.QueryResponse{Elements: batch, NodeFailures: nodeFailures, Warnings: warnings}); err != nil {
+			return err
+		}
+		nodeFailures, warnings = nil, nil
+		elements = elements[len(batch):]
+		if len(elements) == 0 {
+			return nil
+		}
+	}
+}
+
+// Purge broadcasts the purge to all the data nodes, and sums up what they report.
+func (s *streamService) Purge(_ context.Context, req *streamv1.PurgeRequest) (*streamv1.PurgeResponse, error) {
+	if _, ok := s.entityRepo.getTagFamilies(getID(req.GetMetadata())); !ok {
+		return nil, status.Errorf(codes.NotFound, "stream %s doesn't exist", req.GetMetadata())
+	}
+	futures, err := s.pipeline.Broadcast(data.TopicStreamPurge, bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req))
+	if err != nil {
+		return nil, err
+	}
+	result := &streamv1.PurgeResponse{}
+	for _, f := range futures {
+		msg, errGet := f.Get()
+		if errGet != nil {
+			return nil, errGet
+		}
+		switch d := msg.Data().(type) {
+		case *streamv1.PurgeResponse:
+			result.Series += d.GetSeries()
+			result.Blocks += d.GetBlocks()
+			result.Bytes += d.GetBytes()
+			result.ReclaimedBytes += d.GetReclaimedBytes()
+		case common.Error:
+			return nil, errors.WithMessage(errPurgeMsg, d.Msg())
+		}
+	}
+	return result, nil
+}
+
+// Delete broadcasts the deletion to all the data nodes, and sums up the series they report.
+func (s *streamService) Delete(_ context.Context, req *streamv1.DeleteRequest) (*streamv1.DeleteResponse, error) {
+	if _, ok := s.entityRepo.getTagFamilies(getID(req.GetMetadata())); !ok {
+		return nil, status.Errorf(codes.NotFound, "stream %s doesn't exist", req.GetMetadata())
+	}
+	if err := timestamp.CheckTimeRange(req.GetTimeRange()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v is invalid :%s", req.GetTimeRange(), err)
+	}
+	futures, err := s.pipeline.Broadcast(data.TopicStreamDelete, bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req))
+	if err != nil {
+		return nil, err
+	}
+	result := &streamv1.DeleteResponse{}
+	for _, f := range futures {
+		msg, errGet := f.Get()
+		if errGet != nil {
+			return nil, errGet
+		}
+		switch d := msg.Data().(type) {
+		case *streamv1.DeleteResponse:
+			result.Series += d.GetSeries()
+		case common.Error:
+			return nil, errors.WithMessage(errDeleteMsg, d.Msg())
+		}
+	}
+	return result, nil
+}
+
+// RebuildIndex broadcasts the request to all the data nodes, each of which rebuilds the index of its parts in the background,
+// and sums up the progress they report.
+func (s *streamService) RebuildIndex(_ context.Context, req *streamv1.RebuildIndexRequest) (*streamv1.RebuildIndexResponse, error) {
+	if _, ok := s.entityRepo.getTagFamilies(getID(req.GetMetadata())); !ok {
+		return nil, status.Errorf(codes.NotFound, "stream %s doesn't exist", req.GetMetadata())
+	}
+	futures, err := s.pipeline.Broadcast(data.TopicStreamRebuildIndex, bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req))
+	if err != nil {
+		return nil, err
+	}
+	result := &streamv1.RebuildIndexResponse{}
+	for _, f := range futures {
+		msg, errGet := f.Get()
+		if errGet != nil {
+			return nil, errGet
+		}
+		switch d := msg.Data().(type) {
+		case *streamv1.RebuildIndexResponse:
+			result.TotalParts += d.GetTotalParts()
+			result.ScannedParts += d.GetScannedParts()
+			result.Elements += d.GetElements()
+			result.Running = result.Running || d.GetRunning()
+			result.Errors = append(result.Errors, d.GetErrors()...)
+		case common.Error:
+			return nil, errors.WithMessage(errRebuildIndexMsg, d.Msg())
+		}
+	}
+	return result, nil
+}
+
+// VerifyIndex broadcasts the request to all the data nodes, each of which checks the index of its parts,
+// and sums up the inconsistencies they report.
+func (s *streamService) VerifyIndex(_ context.Context, req *streamv1.VerifyIndexRequest) (*streamv1.VerifyIndexResponse, error) {
+	if _, ok := s.entityRepo.getTagFamilies(getID(req.GetMetadata())); !ok {
+		return nil, status.Errorf(codes.NotFound, "stream %s doesn't exist", req.GetMetadata())
+	}
+	if err := timestamp.CheckTimeRange(req.GetTimeRange()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v is invalid :%s", req.GetTimeRange(), err)
+	}
+	futures, err := s.pipeline.Broadcast(data.TopicStreamVerifyIndex, bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req))
+	if err != nil {
+		return nil, err
+	}
+	result := &streamv1.VerifyIndexResponse{}
+	for _, f := range futures {
+		msg, errGet := f.Get()
+		if errGet != nil {
+			return nil, errGet
+		}
+		switch d := msg.Data().(type) {
+		case *streamv1.VerifyIndexResponse:
+			result.Elements += d.GetElements()
+			result.Missing += d.GetMissing()
+			result.Orphaned += d.GetOrphaned()
+			result.Repaired += d.GetRepaired()
+			result.Inconsistencies = append(result.Inconsistencies, d.GetInconsistencies()...)
+		case common.Error:
+			return nil, errors.WithMessage(errVerifyIndexMsg, d.Msg())
+		}
+	}
+	return result, nil
+}
+
+// SearchSeries broadcasts the request to all the data nodes, each of which returns a page of its series,
+// and merges them into a page of all. A page of all is among the pages of the data nodes since they're sorted in the same order.
+func (s *streamService) SearchSeries(_ context.Context, req *streamv1.SearchSeriesRequest) (*streamv1.SearchSeriesResponse, error) {
+	if _, ok := s.entityRepo.getTagFamilies(getID(req.GetMetadata())); !ok {
+		return nil, status.Errorf(codes.NotFound, "stream %s doesn't exist", req.GetMetadata())
+	}
+	futures, err := s.pipeline.Broadcast(data.TopicStreamSearchSeries, bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req))
+	if err != nil {
+		return nil, err
+	}
+	var series []*streamv1.SeriesEntity
+	var more bool
+	for _, f := range futures {
+		msg, errGet := f.Get()
+		if errGet != nil {
+			return nil, errGet
+		}
+		switch d := msg.Data().(type) {
+		case *streamv1.SearchSeriesResponse:
+			series = append(series, d.GetSeries()...)
+			more = more || len(d.GetNextCursor()) > 0
+		case common.Error:
+			return nil, errors.WithMessage(errSearchSeriesMsg, d.Msg())
+		}
+	}
+	result := &streamv1.SearchSeriesResponse{}
+	if result.Series, result.NextCursor, err = pbv1.MergeSeriesEntities(req.GetMetadata().GetName(), series, req.GetLimit(), more); err != nil {
+		return nil, errors.WithMessage(errSearchSeriesMsg, err.Error())
+	}
+	return result, nil
+}
+
+// TagValues broadcasts the request to all the data nodes, each of which returns the values of the tag it has,
+// and merges them into the first ones of all.
+func (s *streamService) TagValues(_ context.Context, req *streamv1.TagValuesRequest) (*streamv1.TagValuesResponse, error) {
+	if _, ok := s.entityRepo.getTagFamilies(getID(req.GetMetadata())); !ok {
+		return nil, status.Errorf(codes.NotFound, "stream %s doesn't exist", req.GetMetadata())
+	}
+	if err := timestamp.CheckTimeRange(req.GetTimeRange()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v is invalid :%s", req.GetTimeRange(), err)
+	}
+	futures, err := s.pipeline.Broadcast(data.TopicStreamTagValues, bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req))
+	if err != nil {
+		return nil, err
+	}
+	result := &streamv1.TagValuesResponse{}
+	for _, f := range futures {
+		msg, errGet := f.Get()
+		if errGet != nil {
+			return nil, errGet
+		}
+		switch d := msg.Data().(type) {
+		case *streamv1.TagValuesResponse:
+			result.Values = append(result.Values, d.GetValues()...)
+			result.Truncated = result.Truncated || d.GetTruncated()
+		case common.Error:
+			return nil, errors.WithMessage(errTagValuesMsg, d.Msg())
+		}
+	}
+	result.Values, result.Truncated = pbv1.MergeTagValues(result.Values, req.GetLimit(), result.Truncated)
+	return result, nil
+}
+
+// TopNSeries broadcasts the request to all the data nodes, each of which returns its top series,
+// and picks the top ones of all. A series is stored by a single data node, so the top ones of all are among them.
+func (s *streamService) TopNSeries(_ context.Context, req *streamv1.TopNSeriesRequest) (*streamv1.TopNSeriesResponse, error) {
+	if _, ok := s.entityRepo.getTagFamilies(getID(req.GetMetadata())); !ok {
+		return nil, status.Errorf(codes.NotFound, "stream %s doesn't exist", req.GetMetadata())
+	}
+	futures, err := s.pipeline.Broadcast(data.TopicStreamTopNSeries, bus.NewMessage(bus.MessageID(time.Now().UnixNano()), req))
+	if err != nil {
+		return nil, err
+	}
+	result := &streamv1.TopNSeriesResponse{}
+	for _, f := range futures {
+		msg, errGet := f.Get()
+		if errGet != nil {
+			return nil, errGet
+		}
+		switch d := msg.Data().(type) {
+		case *streamv1.TopNSeriesResponse:
+			result.Series = append(result.Series, d.GetSeries()...)
+			result.TotalSeries += d.GetTotalSeries()
+			result.TotalCompressedBytes += d.GetTotalCompressedBytes()
+		case common.Error:
+			return nil, errors.WithMessage(errTopNSeriesMsg, d.Msg())
+		}
+	}
+	result.Series = pbv1.TopNSeriesStatistics(result.Series, req.GetOrderBy(), req.GetTopN())
+	return result, nil
+}
+
+// Subscribe sends the elements of the streams once the data nodes flush them, in batches along with the resume tokens.
+// The data nodes are polled for the elements after the positions in the token, till the subscriber leaves.
+func (s *streamService) Subscribe(req *streamv1.SubscribeRequest, stream streamv1.StreamService_SubscribeServer) error {
+	names := req.GetNames()
+	if len(names) == 0 {
+		ss, err := s.metadataRepo.StreamRegistry().ListStream(stream.Context(), schema.ListOpt{Group: req.GetGroup()})
+		if err != nil {
+			return err
+		}
+		for _, st := range ss {
+			names = append(names, st.GetMetadata().GetName())
+		}
+	}
+	for _, name := range names {
+		metadata := &commonv1.Metadata{Group: req.GetGroup(), Name: name}
+		if _, ok := s.entityRepo.getTagFamilies(getID(metadata)); !ok {
+			return status.Errorf(codes.NotFound, "stream %s doesn't exist", metadata)
+		}
+	}
+	token, err := decodeChangeToken(req.GetResumeToken())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "the resume token is invalid: %v", err)
+	}
+	limit := int(req.GetMaxElements())
+	if limit == 0 {
+		limit = defaultSubscriptionBatch
+	}
+	batch := &streamv1.SubscribeResponse{}
+	cs := &changeSubscription{
+		pipeline: s.pipeline,
+		token:    token,
+		topic:    data.TopicStreamChanges,
+		errMsg:   errSubscribeMsg,
+		limit:    limit,
+		request: func(positions []*modelv1.ChangePosition, latest bool, n uint32) proto.Message {
+			return &streamv1.ChangesRequest{
+				Group:       req.GetGroup(),
+				Names:       names,
+				Positions:   positions,
+				Latest:      latest,
+				MaxElements: n,
+			}
+		},
+		collect: func(d any) ([]*modelv1.ChangePosition, []string, int) {
+			resp, _ := d.(*streamv1.ChangesResponse)
+			batch.Elements = append(batch.Elements, resp.GetElements()...)
+			return resp.GetPositions(), resp.GetExpired(), len(resp.GetElements())
+		},
+		send: func(token string) error {
+			batch.ResumeToken = token
+			defer func() {
+				batch = &streamv1.SubscribeResponse{}
+			}()
+			return stream.Send(batch)
+		},
+	}
+	return cs.run(stream.Context())
+}
+
 func (s *streamService) Close() error {
 	return s.ingestionAccessLog.Close()
 }
@@ -0,0 +1,211 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+)
+
+const (
+	// subscriptionPollInterval is how often the subscriptions ask the data nodes for the changes once they catch up.
+	subscriptionPollInterval = time.Second
+	defaultSubscriptionBatch = 1000
+)
+
+// changePosition is a modelv1.ChangePosition in the resume tokens.
+type changePosition struct {
+	Table        string `json:"t"`
+	FlushSeq     uint64 `json:"f"`
+	PartFlushSeq uint64 `json:"p,omitempty"`
+	SeriesID     uint64 `json:"s,omitempty"`
+	Blocks       uint64 `json:"b,omitempty"`
+}
+
+// changeToken is the positions of the tables of the data nodes, by the names of the nodes.
+type changeToken map[string][]changePosition
+
+func decodeChangeToken(token string) (changeToken, error) {
+	ct := make(changeToken)
+	if token == "" {
+		return ct, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &ct); err != nil {
+		return nil, err
+	}
+	return ct, nil
+}
+
+func (ct changeToken) encode() (string, error) {
+	b, err := json.Marshal(ct)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (ct changeToken) positions(node string) ([]*modelv1.ChangePosition, bool) {
+	pp, ok := ct[node]
+	if !ok {
+		return nil, false
+	}
+	result := make([]*modelv1.ChangePosition, 0, len(pp))
+	for _, p := range pp {
+		result = append(result, &modelv1.ChangePosition{
+			Table:        p.Table,
+			FlushSeq:     p.FlushSeq,
+			PartFlushSeq: p.PartFlushSeq,
+			SeriesId:     p.SeriesID,
+			Blocks:       p.Blocks,
+		})
+	}
+	return result, true
+}
+
+func (ct changeToken) update(node string, positions []*modelv1.ChangePosition) {
+	pp := make([]changePosition, 0, len(positions))
+	for _, p := range positions {
+		pp = append(pp, changePosition{
+			Table:        p.GetTable(),
+			FlushSeq:     p.GetFlushSeq(),
+			PartFlushSeq: p.GetPartFlushSeq(),
+			SeriesID:     p.GetSeriesId(),
+			Blocks:       p.GetBlocks(),
+		})
+	}
+	ct[node] = pp
+}
+
+// changeSubscription polls the data nodes for the changes after the positions in the token, and sends them in batches.
+// The data nodes absent from the token, such as the ones joining afterwards, are read from the changes flushed after they're found.
+type changeSubscription struct {
+	pipeline queue.Client
+	token    changeToken
+	topic    bus.Topic
+	errMsg   error
+	// request builds the request to a data node, which asks for limit changes at most after the positions.
+	request func(positions []*modelv1.ChangePosition, latest bool, limit uint32) proto.Message
+	// collect adds the changes of the response of a data node to the batch,
+	// and returns the positions after them, the tables expired and the number of the changes.
+	collect func(data any) (positions []*modelv1.ChangePosition, expired []string, n int)
+	// send sends the batch along with the resume token, then resets the batch.
+	send  func(token string) error
+	limit int
+	round int
+}
+
+// run sends the batches until the subscriber leaves. The batch of the first poll is sent even if it's empty,
+// whose token marks where the subscription starts.
+func (cs *changeSubscription) run(ctx context.Context) error {
+	ticker := time.NewTicker(subscriptionPollInterval)
+	defer ticker.Stop()
+	for first := true; ; first = false {
+		n, err := cs.poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if n > 0 || first {
+			token, errEncode := cs.token.encode()
+			if errEncode != nil {
+				return errEncode
+			}
+			if err = cs.send(token); err != nil {
+				return err
+			}
+		}
+		// the data nodes have more changes if the batch is full
+		if n >= cs.limit {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll asks the data nodes one by one for the changes until the batch is full.
+// The node asked first rotates, so that a busy node doesn't starve the others.
+func (cs *changeSubscription) poll(ctx context.Context) (int, error) {
+	nodes := subscriptionNodes(cs.pipeline)
+	cs.round++
+	var n int
+	for i := range nodes {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		if n >= cs.limit {
+			break
+		}
+		node := nodes[(i+cs.round)%len(nodes)]
+		positions, ok := cs.token.positions(node)
+		f, err := cs.pipeline.Publish(cs.topic, bus.NewMessageWithNode(bus.MessageID(time.Now().UnixNano()), node,
+			cs.request(positions, !ok, uint32(cs.limit-n))))
+		if err != nil {
+			return n, err
+		}
+		m, err := f.Get()
+		if err != nil {
+			return n, errors.WithMessagef(err, "node %s", node)
+		}
+		if e, ok := m.Data().(common.Error); ok {
+			return n, errors.WithMessagef(cs.errMsg, "node %s: %s", node, e.Msg())
+		}
+		positions, expired, got := cs.collect(m.Data())
+		if len(expired) > 0 {
+			return n, status.Errorf(codes.OutOfRange, "the resume token is expired, the changes of the tables %s of the node %s are merged before they're read",
+				strings.Join(expired, ","), node)
+		}
+		cs.token.update(node, positions)
+		n += got
+	}
+	return n, nil
+}
+
+// subscriptionNodes returns the data nodes the changes are read from, which is the local one in the standalone mode.
+func subscriptionNodes(pipeline queue.Client) []string {
+	np, ok := pipeline.(queue.NodePublisher)
+	if !ok {
+		return []string{""}
+	}
+	nodes := np.Nodes()
+	sort.Strings(nodes)
+	return nodes
+}
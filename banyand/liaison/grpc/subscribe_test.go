@@ -0,0 +1,150 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/apache/skywalking-banyandb/api/data"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+)
+
+func Test_changeToken(t *testing.T) {
+	ct, err := decodeChangeToken("")
+	require.NoError(t, err)
+	assert.Empty(t, ct)
+	_, ok := ct.positions("node-1")
+	assert.False(t, ok)
+
+	ct.update("node-1", []*modelv1.ChangePosition{
+		{Table: "0/20240101", FlushSeq: 3},
+		{Table: "1/20240101", FlushSeq: 5, PartFlushSeq: 7, SeriesId: 42, Blocks: 2},
+	})
+	token, err := ct.encode()
+	require.NoError(t, err)
+	decoded, err := decodeChangeToken(token)
+	require.NoError(t, err)
+	positions, ok := decoded.positions("node-1")
+	require.True(t, ok)
+	require.Len(t, positions, 2)
+	assert.True(t, proto.Equal(&modelv1.ChangePosition{Table: "0/20240101", FlushSeq: 3}, positions[0]))
+	assert.True(t, proto.Equal(&modelv1.ChangePosition{Table: "1/20240101", FlushSeq: 5, PartFlushSeq: 7, SeriesId: 42, Blocks: 2}, positions[1]))
+
+	_, err = decodeChangeToken("not a token")
+	assert.Error(t, err)
+}
+
+// changesListener replies the elements after the flush sequence of a single table, one element per flush.
+type changesListener struct {
+	requests []*streamv1.ChangesRequest
+	flushed  uint64
+	expired  bool
+}
+
+func (l *changesListener) Rev(message bus.Message) bus.Message {
+	req := message.Data().(*streamv1.ChangesRequest)
+	l.requests = append(l.requests, req)
+	var seq uint64
+	for _, p := range req.GetPositions() {
+		seq = p.GetFlushSeq()
+	}
+	if req.GetLatest() {
+		seq = l.flushed
+	}
+	resp := &streamv1.ChangesResponse{}
+	if l.expired {
+		resp.Expired = []string{"0/20240101"}
+	}
+	for seq < l.flushed && len(resp.Elements) < int(req.GetMaxElements()) {
+		seq++
+		resp.Elements = append(resp.Elements, &streamv1.SubscribedElement{Element: &streamv1.Element{ElementId: string(rune('a' + seq))}})
+	}
+	resp.Positions = []*modelv1.ChangePosition{{Table: "0/20240101", FlushSeq: seq}}
+	return bus.NewMessage(message.ID(), resp)
+}
+
+func Test_changeSubscription_poll(t *testing.T) {
+	pipeline := queue.Local()
+	l := &changesListener{flushed: 3}
+	require.NoError(t, pipeline.Subscribe(data.TopicStreamChanges, l))
+	batch := &streamv1.SubscribeResponse{}
+	newSubscription := func(token changeToken) *changeSubscription {
+		return &changeSubscription{
+			pipeline: pipeline,
+			token:    token,
+			topic:    data.TopicStreamChanges,
+			errMsg:   errSubscribeMsg,
+			limit:    2,
+			request: func(positions []*modelv1.ChangePosition, latest bool, n uint32) proto.Message {
+				return &streamv1.ChangesRequest{Positions: positions, Latest: latest, MaxElements: n}
+			},
+			collect: func(d any) ([]*modelv1.ChangePosition, []string, int) {
+				resp := d.(*streamv1.ChangesResponse)
+				batch.Elements = append(batch.Elements, resp.GetElements()...)
+				return resp.GetPositions(), resp.GetExpired(), len(resp.GetElements())
+			},
+		}
+	}
+
+	// a new subscription starts from the elements flushed afterwards
+	cs := newSubscription(make(changeToken))
+	n, err := cs.poll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.True(t, l.requests[0].GetLatest())
+
+	l.flushed = 6
+	n, err = cs.poll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.False(t, l.requests[1].GetLatest())
+	n, err = cs.poll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	ids := make([]string, 0, len(batch.Elements))
+	for _, e := range batch.Elements {
+		ids = append(ids, e.GetElement().GetElementId())
+	}
+	assert.Equal(t, []string{"e", "f", "g"}, ids)
+
+	// the token resumes a subscription
+	token, err := cs.token.encode()
+	require.NoError(t, err)
+	resumed, err := decodeChangeToken(token)
+	require.NoError(t, err)
+	l.flushed = 7
+	batch = &streamv1.SubscribeResponse{}
+	n, err = newSubscription(resumed).poll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "h", batch.Elements[0].GetElement().GetElementId())
+
+	l.expired = true
+	_, err = newSubscription(resumed).poll(context.Background())
+	assert.Equal(t, codes.OutOfRange, status.Code(err))
+}
@@ -0,0 +1,254 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+)
+
+const (
+	// tenantStorageRefresh is how often the storage of a tenant is summed up again from the sizes reported by the data nodes.
+	tenantStorageRefresh = 10 * time.Second
+
+	reasonWriteRateLimit = "write_rate_limit"
+	reasonStorageQuota   = "storage_quota"
+	reasonQueryCap       = "max_concurrent_queries"
+)
+
+var (
+	tenantProvider        = observability.NewMeterProvider(observability.RootScope.SubScope("liaison").SubScope("tenant"))
+	tenantWrittenCounter  = tenantProvider.Counter("written_total", "tenant")
+	tenantQueryCounter    = tenantProvider.Counter("query_total", "tenant")
+	tenantRejectedCounter = tenantProvider.Counter("rejected_total", "tenant", "reason")
+	tenantStorageGauge    = tenantProvider.Gauge("storage_bytes", "tenant")
+)
+
+// tenantUsage is the usage of a tenant accounted since the liaison started, which survives the updates of the tenant.
+type tenantUsage struct {
+	written         atomic.Uint64
+	rateLimited     atomic.Uint64
+	quotaExceeded   atomic.Uint64
+	queries         atomic.Uint64
+	queriesRejected atomic.Uint64
+	running         atomic.Int32
+}
+
+// tenantState is the quotas of a tenant compiled from its schema.
+type tenantState struct {
+	usage        *tenantUsage
+	limiter      *rate.Limiter
+	queries      chan struct{}
+	name         string
+	groups       []string
+	storageQuota uint64
+	storageBytes atomic.Uint64
+	checkedAt    atomic.Int64
+}
+
+// tenantRepo enforces the quotas of the tenants on the writes and the queries of their groups, and accounts their usages.
+type tenantRepo struct {
+	byGroup   map[string]*tenantState
+	byName    map[string]*tenantState
+	usages    map[string]*tenantUsage
+	groupSize func(group string) uint64
+	sync.RWMutex
+}
+
+func newTenantRepo() *tenantRepo {
+	return &tenantRepo{
+		byGroup: make(map[string]*tenantState),
+		byName:  make(map[string]*tenantState),
+		usages:  make(map[string]*tenantUsage),
+	}
+}
+
+func (tr *tenantRepo) OnAddOrUpdate(md schema.Metadata) {
+	if md.Kind != schema.KindTenant {
+		return
+	}
+	tenant := md.Spec.(*databasev1.Tenant)
+	name := tenant.GetMetadata().GetName()
+	tr.Lock()
+	defer tr.Unlock()
+	usage, ok := tr.usages[name]
+	if !ok {
+		usage = &tenantUsage{}
+		tr.usages[name] = usage
+	}
+	ts := &tenantState{
+		usage:        usage,
+		name:         name,
+		groups:       tenant.GetGroups(),
+		storageQuota: tenant.GetStorageQuotaBytes(),
+	}
+	if limit := tenant.GetWriteRateLimit(); limit > 0 {
+		// the burst is the writes of a second
+		ts.limiter = rate.NewLimiter(rate.Limit(limit), int(limit))
+	}
+	if maxQueries := tenant.GetMaxConcurrentQueries(); maxQueries > 0 {
+		ts.queries = make(chan struct{}, maxQueries)
+	}
+	tr.removeLocked(name)
+	tr.byName[name] = ts
+	for _, g := range ts.groups {
+		tr.byGroup[g] = ts
+	}
+}
+
+func (tr *tenantRepo) OnDelete(md schema.Metadata) {
+	if md.Kind != schema.KindTenant {
+		return
+	}
+	tr.Lock()
+	defer tr.Unlock()
+	tr.removeLocked(md.Name)
+	delete(tr.usages, md.Name)
+}
+
+func (tr *tenantRepo) removeLocked(name string) {
+	old, ok := tr.byName[name]
+	if !ok {
+		return
+	}
+	for _, g := range old.groups {
+		if tr.byGroup[g] == old {
+			delete(tr.byGroup, g)
+		}
+	}
+	delete(tr.byName, name)
+}
+
+func (tr *tenantRepo) of(group string) *tenantState {
+	if tr == nil {
+		return nil
+	}
+	tr.RLock()
+	defer tr.RUnlock()
+	return tr.byGroup[group]
+}
+
+// admitWrite returns STATUS_QUOTA_EXCEEDED if a write to the group exceeds the write rate limit or the storage quota of its tenant.
+func (tr *tenantRepo) admitWrite(group string) modelv1.Status {
+	ts := tr.of(group)
+	if ts == nil {
+		return modelv1.Status_STATUS_SUCCEED
+	}
+	if ts.storageQuota > 0 && tr.storage(ts, false) >= ts.storageQuota {
+		ts.usage.quotaExceeded.Add(1)
+		tenantRejectedCounter.Inc(1, ts.name, reasonStorageQuota)
+		return modelv1.Status_STATUS_QUOTA_EXCEEDED
+	}
+	if ts.limiter != nil && !ts.limiter.Allow() {
+		ts.usage.rateLimited.Add(1)
+		tenantRejectedCounter.Inc(1, ts.name, reasonWriteRateLimit)
+		return modelv1.Status_STATUS_QUOTA_EXCEEDED
+	}
+	ts.usage.written.Add(1)
+	tenantWrittenCounter.Inc(1, ts.name)
+	return modelv1.Status_STATUS_SUCCEED
+}
+
+// acquireQuery takes a slot of the concurrent queries of the tenant owning the group, and returns the function releasing it.
+// The query is rejected with ResourceExhausted if the tenant runs max_concurrent_queries queries already.
+func (tr *tenantRepo) acquireQuery(group string) (func(), error) {
+	ts := tr.of(group)
+	if ts == nil {
+		return func() {}, nil
+	}
+	if ts.queries != nil {
+		select {
+		case ts.queries <- struct{}{}:
+		default:
+			ts.usage.queriesRejected.Add(1)
+			tenantRejectedCounter.Inc(1, ts.name, reasonQueryCap)
+			return nil, status.Errorf(codes.ResourceExhausted, "the tenant %s runs %d queries at most", ts.name, cap(ts.queries))
+		}
+	}
+	ts.usage.queries.Add(1)
+	ts.usage.running.Add(1)
+	tenantQueryCounter.Inc(1, ts.name)
+	slots := ts.queries
+	return func() {
+		ts.usage.running.Add(-1)
+		if slots != nil {
+			<-slots
+		}
+	}, nil
+}
+
+// storage returns the size of the data of the tenant's groups, which is summed up again if it's older than tenantStorageRefresh or forced.
+func (tr *tenantRepo) storage(ts *tenantState, forced bool) uint64 {
+	if tr.groupSize == nil {
+		return 0
+	}
+	checkedAt := ts.checkedAt.Load()
+	now := time.Now().UnixNano()
+	if !forced && now-checkedAt < int64(tenantStorageRefresh) {
+		return ts.storageBytes.Load()
+	}
+	if !forced && !ts.checkedAt.CompareAndSwap(checkedAt, now) {
+		// another write is summing it up
+		return ts.storageBytes.Load()
+	}
+	var size uint64
+	for _, g := range ts.groups {
+		size += tr.groupSize(g)
+	}
+	ts.storageBytes.Store(size)
+	tenantStorageGauge.Set(float64(size), ts.name)
+	return size
+}
+
+// listUsages returns the usages of the tenants in the order of their names.
+func (tr *tenantRepo) listUsages() []*databasev1.TenantUsage {
+	tr.RLock()
+	tenants := make([]*tenantState, 0, len(tr.byName))
+	for _, ts := range tr.byName {
+		tenants = append(tenants, ts)
+	}
+	tr.RUnlock()
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].name < tenants[j].name
+	})
+	result := make([]*databasev1.TenantUsage, 0, len(tenants))
+	for _, ts := range tenants {
+		result = append(result, &databasev1.TenantUsage{
+			Tenant:               ts.name,
+			Written:              ts.usage.written.Load(),
+			WriteRateLimited:     ts.usage.rateLimited.Load(),
+			StorageQuotaExceeded: ts.usage.quotaExceeded.Load(),
+			Queries:              ts.usage.queries.Load(),
+			QueriesRejected:      ts.usage.queriesRejected.Load(),
+			RunningQueries:       uint32(max(ts.usage.running.Load(), 0)),
+			StorageBytes:         tr.storage(ts, true),
+		})
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
+)
+
+func tenantMetadata(tenant *databasev1.Tenant) schema.Metadata {
+	return schema.Metadata{
+		TypeMeta: schema.TypeMeta{Kind: schema.KindTenant, Name: tenant.GetMetadata().GetName()},
+		Spec:     tenant,
+	}
+}
+
+func Test_tenantRepo_admitWrite(t *testing.T) {
+	sizes := map[string]uint64{"tenant-a-sw": 100}
+	tr := newTenantRepo()
+	tr.groupSize = func(group string) uint64 { return sizes[group] }
+	tenant := &databasev1.Tenant{
+		Metadata:       &commonv1.Metadata{Name: "tenant-a"},
+		Groups:         []string{"tenant-a-sw"},
+		WriteRateLimit: 2,
+	}
+	tr.OnAddOrUpdate(tenantMetadata(tenant))
+
+	// the burst is the writes of a second
+	assert.Equal(t, modelv1.Status_STATUS_SUCCEED, tr.admitWrite("tenant-a-sw"))
+	assert.Equal(t, modelv1.Status_STATUS_SUCCEED, tr.admitWrite("tenant-a-sw"))
+	assert.Equal(t, modelv1.Status_STATUS_QUOTA_EXCEEDED, tr.admitWrite("tenant-a-sw"))
+	// the groups without a tenant aren't limited
+	assert.Equal(t, modelv1.Status_STATUS_SUCCEED, tr.admitWrite("default"))
+
+	tenant.WriteRateLimit = 0
+	tenant.StorageQuotaBytes = 100
+	tr.OnAddOrUpdate(tenantMetadata(tenant))
+	assert.Equal(t, modelv1.Status_STATUS_QUOTA_EXCEEDED, tr.admitWrite("tenant-a-sw"))
+
+	// the usage survives the update of the tenant
+	usages := tr.listUsages()
+	require.Len(t, usages, 1)
+	assert.Equal(t, &databasev1.TenantUsage{
+		Tenant: "tenant-a", Written: 2, WriteRateLimited: 1, StorageQuotaExceeded: 1, StorageBytes: 100,
+	}, usages[0])
+
+	tr.OnDelete(tenantMetadata(tenant))
+	assert.Equal(t, modelv1.Status_STATUS_SUCCEED, tr.admitWrite("tenant-a-sw"))
+	assert.Empty(t, tr.listUsages())
+}
+
+func Test_tenantRepo_acquireQuery(t *testing.T) {
+	tr := newTenantRepo()
+	tr.OnAddOrUpdate(tenantMetadata(&databasev1.Tenant{
+		Metadata:             &commonv1.Metadata{Name: "tenant-a"},
+		Groups:               []string{"tenant-a-sw"},
+		MaxConcurrentQueries: 1,
+	}))
+	release, err := tr.acquireQuery("tenant-a-sw")
+	require.NoError(t, err)
+	_, err = tr.acquireQuery("tenant-a-sw")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, uint32(1), tr.listUsages()[0].GetRunningQueries())
+
+	release()
+	release, err = tr.acquireQuery("tenant-a-sw")
+	require.NoError(t, err)
+	release()
+	usage := tr.listUsages()[0]
+	assert.Equal(t, uint64(2), usage.GetQueries())
+	assert.Equal(t, uint64(1), usage.GetQueriesRejected())
+	assert.Zero(t, usage.GetRunningQueries())
+
+	var nilRepo *tenantRepo
+	release, err = nilRepo.acquireQuery("tenant-a-sw")
+	require.NoError(t, err)
+	release()
+}
@@ -0,0 +1,150 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+)
+
+const (
+	ruleRequiredTags    = "required_tags"
+	ruleMaxStringLength = "max_string_length"
+	ruleAllowedValues   = "allowed_values"
+	ruleMaxPastSkew     = "max_past_skew"
+	ruleMaxFutureSkew   = "max_future_skew"
+)
+
+var (
+	validationProvider  = observability.NewMeterProvider(observability.RootScope.SubScope("liaison").SubScope("ingestion_validation"))
+	rejectedCounter     = validationProvider.Counter("rejected_total", "group", "rule")
+	deadLetteredCounter = validationProvider.Counter("dead_lettered_total", "group")
+
+	errInvalidData = errors.New("the write breaks the ingestion validation rules")
+)
+
+// ingestionValidator validates the writes to a group by its ingestion validation rules.
+type ingestionValidator struct {
+	required        map[string]struct{}
+	allowed         map[string]map[string]struct{}
+	maxPastSkew     time.Duration
+	maxFutureSkew   time.Duration
+	maxStringLength int
+	deadLetter      bool
+}
+
+// newIngestionValidator compiles the rules, or returns nil if there is none.
+func newIngestionValidator(rules *commonv1.IngestionValidation) *ingestionValidator {
+	if rules == nil {
+		return nil
+	}
+	v := &ingestionValidator{
+		maxStringLength: int(rules.GetMaxStringLength()),
+		deadLetter:      rules.GetDeadLetter(),
+	}
+	if rules.GetMaxPastSkew() != nil {
+		v.maxPastSkew = rules.GetMaxPastSkew().AsDuration()
+	}
+	if rules.GetMaxFutureSkew() != nil {
+		v.maxFutureSkew = rules.GetMaxFutureSkew().AsDuration()
+	}
+	if len(rules.GetRequiredTags()) > 0 {
+		v.required = make(map[string]struct{}, len(rules.GetRequiredTags()))
+		for _, t := range rules.GetRequiredTags() {
+			v.required[t] = struct{}{}
+		}
+	}
+	if len(rules.GetAllowedValues()) > 0 {
+		v.allowed = make(map[string]map[string]struct{}, len(rules.GetAllowedValues()))
+		for _, av := range rules.GetAllowedValues() {
+			values := make(map[string]struct{}, len(av.GetValues()))
+			for _, value := range av.GetValues() {
+				values[value] = struct{}{}
+			}
+			v.allowed[av.GetTag()] = values
+		}
+	}
+	return v
+}
+
+// validate returns the rule the write breaks and the reason, or an empty rule if it passes all of them.
+// The tag families written are located by the tag families of the schema.
+func (v *ingestionValidator) validate(now, ts time.Time, specs []*databasev1.TagFamilySpec, tagFamilies []*modelv1.TagFamilyForWrite) (string, error) {
+	if v.maxPastSkew > 0 && ts.Before(now.Add(-v.maxPastSkew)) {
+		return ruleMaxPastSkew, errors.WithMessagef(errInvalidData, "the timestamp %s is older than %s before now", ts, v.maxPastSkew)
+	}
+	if v.maxFutureSkew > 0 && ts.After(now.Add(v.maxFutureSkew)) {
+		return ruleMaxFutureSkew, errors.WithMessagef(errInvalidData, "the timestamp %s is newer than %s after now", ts, v.maxFutureSkew)
+	}
+	for fi, family := range specs {
+		for ti, spec := range family.GetTags() {
+			var value *modelv1.TagValue
+			if fi < len(tagFamilies) && ti < len(tagFamilies[fi].GetTags()) {
+				value = tagFamilies[fi].Tags[ti]
+			}
+			if rule, err := v.validateTag(spec.GetName(), value); err != nil {
+				return rule, err
+			}
+		}
+	}
+	return "", nil
+}
+
+func (v *ingestionValidator) validateTag(name string, value *modelv1.TagValue) (string, error) {
+	var values []string
+	switch x := value.GetValue().(type) {
+	case *modelv1.TagValue_Str:
+		values = []string{x.Str.GetValue()}
+	case *modelv1.TagValue_StrArray:
+		values = x.StrArray.GetValue()
+	case *modelv1.TagValue_Int:
+		values = []string{strconv.FormatInt(x.Int.GetValue(), 10)}
+	case *modelv1.TagValue_IntArray:
+		for _, i := range x.IntArray.GetValue() {
+			values = append(values, strconv.FormatInt(i, 10))
+		}
+	case *modelv1.TagValue_BinaryData:
+	default:
+		if _, ok := v.required[name]; ok {
+			return ruleRequiredTags, errors.WithMessagef(errInvalidData, "the tag %s is required", name)
+		}
+		return "", nil
+	}
+	if v.maxStringLength > 0 && value.GetInt() == nil && value.GetIntArray() == nil {
+		for _, s := range values {
+			if len(s) > v.maxStringLength {
+				return ruleMaxStringLength, errors.WithMessagef(errInvalidData, "the tag %s is longer than %d", name, v.maxStringLength)
+			}
+		}
+	}
+	if allowed, ok := v.allowed[name]; ok {
+		for _, s := range values {
+			if _, ok = allowed[s]; !ok {
+				return ruleAllowedValues, errors.WithMessagef(errInvalidData, "the value %q of the tag %s isn't allowed", s, name)
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,90 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+func Test_ingestionValidator_validate(t *testing.T) {
+	v := newIngestionValidator(&commonv1.IngestionValidation{
+		RequiredTags:    []string{"service"},
+		MaxStringLength: 8,
+		AllowedValues: []*commonv1.AllowedTagValues{
+			{Tag: "status", Values: []string{"200", "404"}},
+			{Tag: "layers", Values: []string{"http", "db"}},
+		},
+		MaxPastSkew:   durationpb.New(time.Hour),
+		MaxFutureSkew: durationpb.New(time.Minute),
+	})
+	specs := []*databasev1.TagFamilySpec{{
+		Name: "default",
+		Tags: []*databasev1.TagSpec{
+			{Name: "service", Type: databasev1.TagType_TAG_TYPE_STRING},
+			{Name: "status", Type: databasev1.TagType_TAG_TYPE_INT},
+			{Name: "layers", Type: databasev1.TagType_TAG_TYPE_STRING_ARRAY},
+		},
+	}}
+	str := func(s string) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: s}}}
+	}
+	status := func(i int64) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: i}}}
+	}
+	layers := func(s ...string) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_StrArray{StrArray: &modelv1.StrArray{Value: s}}}
+	}
+	now := time.Now()
+	tests := []struct {
+		ts   time.Time
+		name string
+		rule string
+		tags []*modelv1.TagValue
+	}{
+		{name: "valid", ts: now, tags: []*modelv1.TagValue{str("svc"), status(404), layers("http", "db")}},
+		{name: "the optional tags absent", ts: now, tags: []*modelv1.TagValue{str("svc")}},
+		{name: "the required tag absent", ts: now, rule: ruleRequiredTags},
+		{name: "the required tag null", ts: now, rule: ruleRequiredTags, tags: []*modelv1.TagValue{{Value: &modelv1.TagValue_Null{}}}},
+		{name: "too long", ts: now, rule: ruleMaxStringLength, tags: []*modelv1.TagValue{str("long service")}},
+		{name: "too long element", ts: now, rule: ruleMaxStringLength, tags: []*modelv1.TagValue{str("svc"), status(200), layers("messaging")}},
+		{name: "not allowed int", ts: now, rule: ruleAllowedValues, tags: []*modelv1.TagValue{str("svc"), status(500)}},
+		{name: "not allowed element", ts: now, rule: ruleAllowedValues, tags: []*modelv1.TagValue{str("svc"), status(200), layers("http", "mq")}},
+		{name: "too old", ts: now.Add(-2 * time.Hour), rule: ruleMaxPastSkew, tags: []*modelv1.TagValue{str("svc")}},
+		{name: "too new", ts: now.Add(2 * time.Minute), rule: ruleMaxFutureSkew, tags: []*modelv1.TagValue{str("svc")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := v.validate(now, tt.ts, specs, []*modelv1.TagFamilyForWrite{{Tags: tt.tags}})
+			assert.Equal(t, tt.rule, rule)
+			if tt.rule == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, errInvalidData)
+		})
+	}
+	assert.Nil(t, newIngestionValidator(nil))
+}
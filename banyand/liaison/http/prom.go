@@ -0,0 +1,569 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/promql"
+)
+
+const (
+	promErrorBadData   = "bad_data"
+	promErrorExecution = "execution"
+
+	// promSeriesRange is the default time range of the series and the labels, which is the last hour.
+	promSeriesRange = time.Hour
+)
+
+var (
+	errNoMatch      = errors.New("no match[] parameter provided")
+	errInvalidParam = errors.New("invalid parameter")
+)
+
+// promAPI serves a subset of the HTTP API of Prometheus, which reads the measures by the subset of PromQL in promql,
+// e.g. for the Prometheus data source of Grafana.
+// A metric is a numeric field of a measure named <group>:<measure>:<field>, and its labels are the tags of the measure.
+type promAPI struct {
+	measure    measurev1.MeasureServiceClient
+	registry   databasev1.MeasureRegistryServiceClient
+	groups     databasev1.GroupRegistryServiceClient
+	writer     *promWriter
+	l          *logger.Logger
+	lookback   time.Duration
+	maxSamples uint32
+}
+
+func newPromAPI(conn *grpc.ClientConn, l *logger.Logger, lookback time.Duration, maxSamples uint32) *promAPI {
+	return &promAPI{
+		measure:    measurev1.NewMeasureServiceClient(conn),
+		registry:   databasev1.NewMeasureRegistryServiceClient(conn),
+		groups:     databasev1.NewGroupRegistryServiceClient(conn),
+		l:          l,
+		lookback:   lookback,
+		maxSamples: maxSamples,
+	}
+}
+
+func (p *promAPI) routes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(forwardAuthorization)
+	r.HandleFunc("/api/v1/query", p.query)
+	r.HandleFunc("/api/v1/query_range", p.queryRange)
+	r.HandleFunc("/api/v1/labels", p.labels)
+	r.HandleFunc("/api/v1/label/{name}/values", p.labelValues)
+	r.HandleFunc("/api/v1/series", p.series)
+	if p.writer != nil {
+		r.Post("/api/v1/write", p.writer.write)
+	}
+	return r
+}
+
+// forwardAuthorization forwards the authorization header to the gRPC server, which authenticates the calls of the requests.
+func forwardAuthorization(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a := r.Header.Get("Authorization"); a != "" {
+			r = r.WithContext(metadata.AppendToOutgoingContext(r.Context(), "authorization", a))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *promAPI) query(w http.ResponseWriter, r *http.Request) {
+	expr, err := promql.Parse(r.FormValue("query"))
+	if err != nil {
+		p.respondError(w, err)
+		return
+	}
+	ts, err := parsePromTime(r.FormValue("time"), time.Now())
+	if err != nil {
+		p.respondError(w, err)
+		return
+	}
+	series, err := promql.Eval(r.Context(), p, expr, ts, ts, time.Second, p.lookback)
+	if err != nil {
+		p.respondError(w, err)
+		return
+	}
+	result := make([]map[string]any, 0, len(series))
+	for _, s := range series {
+		result = append(result, map[string]any{"metric": s.Labels.Map(), "value": promSample(s.Samples[0])})
+	}
+	p.respond(w, map[string]any{"resultType": "vector", "result": result}, nil)
+}
+
+func (p *promAPI) queryRange(w http.ResponseWriter, r *http.Request) {
+	expr, err := promql.Parse(r.FormValue("query"))
+	if err != nil {
+		p.respondError(w, err)
+		return
+	}
+	var start, end time.Time
+	var step time.Duration
+	if start, err = parsePromTime(r.FormValue("start"), time.Time{}); err == nil {
+		if end, err = parsePromTime(r.FormValue("end"), time.Time{}); err == nil {
+			step, err = parsePromDuration(r.FormValue("step"))
+		}
+	}
+	switch {
+	case err != nil:
+	case start.IsZero() || end.IsZero():
+		err = errors.WithMessage(errInvalidParam, "the start and the end are required")
+	case end.Before(start):
+		err = errors.WithMessage(errInvalidParam, "the end should not be before the start")
+	case step <= 0:
+		err = errors.WithMessage(errInvalidParam, "the step should be positive")
+	}
+	if err != nil {
+		p.respondError(w, err)
+		return
+	}
+	series, err := promql.Eval(r.Context(), p, expr, start, end, step, p.lookback)
+	if err != nil {
+		p.respondError(w, err)
+		return
+	}
+	result := make([]map[string]any, 0, len(series))
+	for _, s := range series {
+		values := make([][2]any, 0, len(s.Samples))
+		for _, sample := range s.Samples {
+			values = append(values, promSample(sample))
+		}
+		result = append(result, map[string]any{"metric": s.Labels.Map(), "values": values})
+	}
+	p.respond(w, map[string]any{"resultType": "matrix", "result": result}, nil)
+}
+
+func (p *promAPI) labels(w http.ResponseWriter, r *http.Request) {
+	names := map[string]bool{promql.MetricNameLabel: true}
+	series, err := p.selectMatches(r)
+	switch {
+	case errors.Is(err, errNoMatch):
+		measures, listErr := p.listMeasures(r.Context())
+		if listErr != nil {
+			p.respondError(w, listErr)
+			return
+		}
+		for _, m := range measures {
+			for _, tf := range m.GetTagFamilies() {
+				for _, t := range tf.GetTags() {
+					if !isReservedLabel(t.GetName()) {
+						names[t.GetName()] = true
+					}
+				}
+			}
+		}
+	case err != nil:
+		p.respondError(w, err)
+		return
+	}
+	for _, s := range series {
+		for _, l := range s.Labels {
+			names[l.Name] = true
+		}
+	}
+	p.respond(w, sortedKeys(names), nil)
+}
+
+func (p *promAPI) labelValues(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	values := make(map[string]bool)
+	series, err := p.selectMatches(r)
+	switch {
+	case errors.Is(err, errNoMatch) && name == promql.MetricNameLabel:
+		measures, listErr := p.listMeasures(r.Context())
+		if listErr != nil {
+			p.respondError(w, listErr)
+			return
+		}
+		for _, m := range measures {
+			for _, f := range m.GetFields() {
+				if f.GetFieldType() == databasev1.FieldType_FIELD_TYPE_INT || f.GetFieldType() == databasev1.FieldType_FIELD_TYPE_FLOAT {
+					values[metricName(m.GetMetadata().GetGroup(), m.GetMetadata().GetName(), f.GetName())] = true
+				}
+			}
+		}
+	case errors.Is(err, errNoMatch):
+		// The values of the tags are listed from the data points, which are too many to read without a selector.
+		p.respond(w, []string{}, []string{"the values of " + name + " are only listed for the series of match[]"})
+		return
+	case err != nil:
+		p.respondError(w, err)
+		return
+	}
+	for _, s := range series {
+		if v := s.Labels.Get(name); v != "" {
+			values[v] = true
+		}
+	}
+	p.respond(w, sortedKeys(values), nil)
+}
+
+func (p *promAPI) series(w http.ResponseWriter, r *http.Request) {
+	series, err := p.selectMatches(r)
+	if err != nil {
+		p.respondError(w, err)
+		return
+	}
+	result := make([]map[string]string, 0, len(series))
+	for _, s := range series {
+		result = append(result, s.Labels.Map())
+	}
+	p.respond(w, result, nil)
+}
+
+// selectMatches returns the series of the selectors in match[] between the start and the end,
+// which are the last hour by default.
+func (p *promAPI) selectMatches(r *http.Request) ([]promql.Series, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, errors.WithMessage(errInvalidParam, err.Error())
+	}
+	matches := r.Form["match[]"]
+	if len(matches) == 0 {
+		return nil, errNoMatch
+	}
+	end, err := parsePromTime(r.FormValue("end"), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	start, err := parsePromTime(r.FormValue("start"), end.Add(-promSeriesRange))
+	if err != nil {
+		return nil, err
+	}
+	var result []promql.Series
+	for _, m := range matches {
+		expr, parseErr := promql.Parse(m)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		s, ok := expr.(*promql.VectorSelector)
+		if !ok {
+			return nil, errors.WithMessagef(errInvalidParam, "match[] %s should be a selector", m)
+		}
+		series, selectErr := p.Select(r.Context(), s, start, end)
+		if selectErr != nil {
+			return nil, selectErr
+		}
+		result = append(result, series...)
+	}
+	return result, nil
+}
+
+// Select returns the series of the field of a measure selected by the metric name, which is empty if there is
+// no such a measure or a numeric field. The equalities on the entity tags are pushed down to the query of the measure,
+// and the other matchers filter the data points in the liaison.
+func (p *promAPI) Select(ctx context.Context, s *promql.VectorSelector, start, end time.Time) ([]promql.Series, error) {
+	group, name, field, ok := splitMetricName(s.Name)
+	if !ok {
+		return nil, nil
+	}
+	resp, err := p.registry.Get(ctx, &databasev1.MeasureRegistryServiceGetRequest{Metadata: &commonv1.Metadata{Group: group, Name: name}})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := resp.GetMeasure()
+	if !hasNumericField(m, field) {
+		return nil, nil
+	}
+	criteria, ok := entityCriteria(m, s.Matchers)
+	if !ok {
+		return nil, nil
+	}
+	qr, err := p.measure.Query(ctx, &measurev1.QueryRequest{
+		Metadata:        m.GetMetadata(),
+		TimeRange:       &modelv1.TimeRange{Begin: timestamppb.New(start), End: timestamppb.New(end)},
+		Criteria:        criteria,
+		TagProjection:   tagProjection(m),
+		FieldProjection: &measurev1.QueryRequest_FieldProjection{Names: []string{field}},
+		Limit:           p.maxSamples + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(qr.GetDataPoints()) > int(p.maxSamples) {
+		return nil, errors.Errorf("%s selects more than %d samples, narrow the selector or the time range", s, p.maxSamples)
+	}
+	series := make(map[string]*promql.Series)
+	for _, dp := range qr.GetDataPoints() {
+		v, found := fieldValue(dp.GetFields(), field)
+		if !found {
+			continue
+		}
+		labels := map[string]string{promql.MetricNameLabel: s.Name}
+		for _, tf := range dp.GetTagFamilies() {
+			for _, t := range tf.GetTags() {
+				if !isReservedLabel(t.GetKey()) {
+					labels[t.GetKey()] = labelValue(t.GetValue())
+				}
+			}
+		}
+		ls := promql.NewLabels(labels)
+		if !ls.Matches(s.Matchers) {
+			continue
+		}
+		key := ls.String()
+		if _, exist := series[key]; !exist {
+			series[key] = &promql.Series{Labels: ls}
+		}
+		series[key].Samples = append(series[key].Samples, promql.Sample{T: dp.GetTimestamp().AsTime().UnixMilli(), V: v})
+	}
+	result := make([]promql.Series, 0, len(series))
+	for _, ps := range series {
+		sort.Slice(ps.Samples, func(i, j int) bool { return ps.Samples[i].T < ps.Samples[j].T })
+		result = append(result, *ps)
+	}
+	return result, nil
+}
+
+// listMeasures returns the measures of all the groups of measures.
+func (p *promAPI) listMeasures(ctx context.Context) ([]*databasev1.Measure, error) {
+	groups, err := p.groups.List(ctx, &databasev1.GroupRegistryServiceListRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var result []*databasev1.Measure
+	for _, g := range groups.GetGroup() {
+		if g.GetCatalog() != commonv1.Catalog_CATALOG_MEASURE {
+			continue
+		}
+		resp, listErr := p.registry.List(ctx, &databasev1.MeasureRegistryServiceListRequest{Group: g.GetMetadata().GetName()})
+		if listErr != nil {
+			return nil, listErr
+		}
+		result = append(result, resp.GetMeasure()...)
+	}
+	return result, nil
+}
+
+func (p *promAPI) respond(w http.ResponseWriter, data any, warnings []string) {
+	body := map[string]any{"status": "success", "data": data}
+	if len(warnings) > 0 {
+		body["warnings"] = warnings
+	}
+	p.write(w, http.StatusOK, body)
+}
+
+// respondError responds bad_data to the invalid queries or parameters, and execution to the failures of the queries.
+func (p *promAPI) respondError(w http.ResponseWriter, err error) {
+	errorType, code := promErrorExecution, http.StatusUnprocessableEntity
+	if errors.Is(err, promql.ErrSyntax) || errors.Is(err, promql.ErrTooManyPoints) || errors.Is(err, errInvalidParam) || errors.Is(err, errNoMatch) {
+		errorType, code = promErrorBadData, http.StatusBadRequest
+	}
+	switch status.Code(err) {
+	case codes.Unauthenticated:
+		code = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		code = http.StatusForbidden
+	}
+	p.write(w, code, map[string]any{"status": "error", "errorType": errorType, "error": err.Error()})
+}
+
+func (p *promAPI) write(w http.ResponseWriter, code int, body map[string]any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		p.l.Warn().Err(err).Msg("failed to write the response of the prometheus API")
+	}
+}
+
+func metricName(group, measure, field string) string {
+	return group + ":" + measure + ":" + field
+}
+
+func splitMetricName(name string) (group, measure, field string, ok bool) {
+	parts := strings.Split(name, ":")
+	if len(parts) != 3 {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
+
+// isReservedLabel returns whether the tag is named as a reserved label of Prometheus, which is left out of the labels,
+// e.g. the entity tag of the measures written by the remote write.
+func isReservedLabel(name string) bool {
+	return strings.HasPrefix(name, "__")
+}
+
+func hasNumericField(m *databasev1.Measure, field string) bool {
+	for _, f := range m.GetFields() {
+		if f.GetName() == field {
+			return f.GetFieldType() == databasev1.FieldType_FIELD_TYPE_INT || f.GetFieldType() == databasev1.FieldType_FIELD_TYPE_FLOAT
+		}
+	}
+	return false
+}
+
+// tagProjection projects all the tags of the measure except the binary ones.
+func tagProjection(m *databasev1.Measure) *modelv1.TagProjection {
+	projection := &modelv1.TagProjection{}
+	for _, tf := range m.GetTagFamilies() {
+		family := &modelv1.TagProjection_TagFamily{Name: tf.GetName()}
+		for _, t := range tf.GetTags() {
+			if t.GetType() != databasev1.TagType_TAG_TYPE_DATA_BINARY {
+				family.Tags = append(family.Tags, t.GetName())
+			}
+		}
+		if len(family.Tags) > 0 {
+			projection.TagFamilies = append(projection.TagFamilies, family)
+		}
+	}
+	return projection
+}
+
+// entityCriteria returns the criteria of the equalities on the entity tags, and false if the equalities match nothing.
+func entityCriteria(m *databasev1.Measure, matchers []*promql.Matcher) (*modelv1.Criteria, bool) {
+	types := make(map[string]databasev1.TagType)
+	for _, tf := range m.GetTagFamilies() {
+		for _, t := range tf.GetTags() {
+			types[t.GetName()] = t.GetType()
+		}
+	}
+	var criteria *modelv1.Criteria
+	for _, name := range m.GetEntity().GetTagNames() {
+		for _, matcher := range matchers {
+			if matcher.Name != name || matcher.Type != promql.MatchEqual || matcher.Value == "" {
+				continue
+			}
+			var value *modelv1.TagValue
+			switch types[name] {
+			case databasev1.TagType_TAG_TYPE_STRING:
+				value = &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: matcher.Value}}}
+			case databasev1.TagType_TAG_TYPE_INT:
+				i, err := strconv.ParseInt(matcher.Value, 10, 64)
+				if err != nil {
+					return nil, false
+				}
+				value = &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: i}}}
+			default:
+				continue
+			}
+			cond := &modelv1.Criteria{Exp: &modelv1.Criteria_Condition{Condition: &modelv1.Condition{
+				Name:  name,
+				Op:    modelv1.Condition_BINARY_OP_EQ,
+				Value: value,
+			}}}
+			if criteria != nil {
+				cond = &modelv1.Criteria{Exp: &modelv1.Criteria_Le{Le: &modelv1.LogicalExpression{
+					Op:    modelv1.LogicalExpression_LOGICAL_OP_AND,
+					Left:  criteria,
+					Right: cond,
+				}}}
+			}
+			criteria = cond
+		}
+	}
+	return criteria, true
+}
+
+// labelValue returns the value of a tag as a label, which joins the values of an array by commas.
+func labelValue(v *modelv1.TagValue) string {
+	switch v.GetValue().(type) {
+	case *modelv1.TagValue_Str:
+		return v.GetStr().GetValue()
+	case *modelv1.TagValue_Int:
+		return strconv.FormatInt(v.GetInt().GetValue(), 10)
+	case *modelv1.TagValue_StrArray:
+		return strings.Join(v.GetStrArray().GetValue(), ",")
+	case *modelv1.TagValue_IntArray:
+		ss := make([]string, 0, len(v.GetIntArray().GetValue()))
+		for _, i := range v.GetIntArray().GetValue() {
+			ss = append(ss, strconv.FormatInt(i, 10))
+		}
+		return strings.Join(ss, ",")
+	}
+	return ""
+}
+
+func fieldValue(fields []*measurev1.DataPoint_Field, name string) (float64, bool) {
+	for _, f := range fields {
+		if f.GetName() != name {
+			continue
+		}
+		switch f.GetValue().GetValue().(type) {
+		case *modelv1.FieldValue_Int:
+			return float64(f.GetValue().GetInt().GetValue()), true
+		case *modelv1.FieldValue_Float:
+			return f.GetValue().GetFloat().GetValue(), true
+		}
+	}
+	return 0, false
+}
+
+// promSample returns the sample as [<unix seconds>, "<value>"].
+func promSample(s promql.Sample) [2]any {
+	return [2]any{float64(s.T) / 1000, strconv.FormatFloat(s.V, 'f', -1, 64)}
+}
+
+// parsePromTime parses the time in RFC 3339 or in the unix seconds, which is def if it's empty.
+func parsePromTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.UnixMilli(int64(math.Round(f * 1000))), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, errors.WithMessagef(errInvalidParam, "invalid time %q", s)
+	}
+	return t, nil
+}
+
+// parsePromDuration parses the duration in PromQL or in seconds.
+func parsePromDuration(s string) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	d, err := promql.ParseDuration(s)
+	if err != nil {
+		return 0, errors.WithMessagef(errInvalidParam, "invalid duration %q", s)
+	}
+	return d, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
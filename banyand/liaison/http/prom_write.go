@@ -0,0 +1,350 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/promql"
+)
+
+const (
+	// promLabelFamily is the tag family of the labels of the measures written by the remote write.
+	promLabelFamily = "labels"
+	// promSeriesTag is the entity tag of the measures written by the remote write, which is all the labels of a series.
+	// It's named as a reserved label of Prometheus to avoid the conflicts with the labels.
+	promSeriesTag = "__series__"
+	// promValueField is the field of the values of the samples.
+	promValueField = "value"
+)
+
+// promWriter writes the samples of the remote-write requests of Prometheus to the measures of a group.
+// A metric is written to the measure of its name, whose colons are replaced by underscores, which is created along with
+// the first sample of the metric. The labels are the string tags appended to the measure once they arrive.
+type promWriter struct {
+	registry databasev1.MeasureRegistryServiceClient
+	measure  measurev1.MeasureServiceClient
+	l        *logger.Logger
+	schemas  map[string]*databasev1.Measure
+	group    string
+	mu       sync.Mutex
+}
+
+func newPromWriter(conn *grpc.ClientConn, l *logger.Logger, group string) *promWriter {
+	return &promWriter{
+		registry: databasev1.NewMeasureRegistryServiceClient(conn),
+		measure:  measurev1.NewMeasureServiceClient(conn),
+		l:        l,
+		schemas:  make(map[string]*databasev1.Measure),
+		group:    group,
+	}
+}
+
+// write serves the remote write, which responds 400 to the invalid requests that Prometheus drops,
+// and 500 to the failed writes that Prometheus retries.
+func (pw *promWriter) write(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	series, err := promql.DecodeWriteRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var reqs []*measurev1.WriteRequest
+	for name, ss := range groupByMetric(series) {
+		m, schemaErr := pw.schema(r.Context(), name, ss)
+		if schemaErr != nil {
+			pw.l.Error().Err(schemaErr).Str("metric", name).Msg("failed to update the measure of the metric")
+			http.Error(w, schemaErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, s := range ss {
+			reqs = appendWriteRequests(reqs, m, s)
+		}
+	}
+	if err = pw.send(r.Context(), reqs); err != nil {
+		pw.l.Error().Err(err).Int("data_points", len(reqs)).Msg("failed to write the samples of the remote write")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// schema returns the measure of the metric, which is created, or appended the labels absent in it.
+func (pw *promWriter) schema(ctx context.Context, metric string, series []promql.Series) (*databasev1.Measure, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	name := strings.ReplaceAll(metric, ":", "_")
+	m, ok := pw.schemas[name]
+	if !ok {
+		var err error
+		if m, err = pw.fetch(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+	labels := absentLabels(m, series)
+	if len(labels) > 0 || m.GetMetadata().GetModRevision() == 0 {
+		var err error
+		if m, err = pw.apply(ctx, m, labels); err != nil {
+			// the measure is fetched again by the retry of Prometheus, in case another liaison has changed it
+			delete(pw.schemas, name)
+			return nil, err
+		}
+	}
+	pw.schemas[name] = m
+	return m, nil
+}
+
+// fetch returns the measure, or the one to create if it's absent.
+func (pw *promWriter) fetch(ctx context.Context, name string) (*databasev1.Measure, error) {
+	resp, err := pw.registry.Get(ctx, &databasev1.MeasureRegistryServiceGetRequest{Metadata: &commonv1.Metadata{Group: pw.group, Name: name}})
+	if status.Code(err) == codes.NotFound {
+		return &databasev1.Measure{
+			Metadata: &commonv1.Metadata{Group: pw.group, Name: name},
+			TagFamilies: []*databasev1.TagFamilySpec{{
+				Name: promLabelFamily,
+				Tags: []*databasev1.TagSpec{{Name: promSeriesTag, Type: databasev1.TagType_TAG_TYPE_STRING}},
+			}},
+			Fields: []*databasev1.FieldSpec{{
+				Name:              promValueField,
+				FieldType:         databasev1.FieldType_FIELD_TYPE_FLOAT,
+				EncodingMethod:    databasev1.EncodingMethod_ENCODING_METHOD_GORILLA,
+				CompressionMethod: databasev1.CompressionMethod_COMPRESSION_METHOD_ZSTD,
+			}},
+			Entity: &databasev1.Entity{TagNames: []string{promSeriesTag}},
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := resp.GetMeasure()
+	if !isPromMeasure(m) {
+		return nil, errors.Errorf("the measure %s/%s isn't in the layout of the remote write", pw.group, name)
+	}
+	return m, nil
+}
+
+// apply appends the labels to the measure, which is created if it hasn't been.
+func (pw *promWriter) apply(ctx context.Context, m *databasev1.Measure, labels []string) (*databasev1.Measure, error) {
+	m = proto.Clone(m).(*databasev1.Measure)
+	family := m.GetTagFamilies()[0]
+	for _, l := range labels {
+		family.Tags = append(family.Tags, &databasev1.TagSpec{Name: l, Type: databasev1.TagType_TAG_TYPE_STRING})
+	}
+	var modRevision int64
+	if m.GetMetadata().GetModRevision() == 0 {
+		resp, err := pw.registry.Create(ctx, &databasev1.MeasureRegistryServiceCreateRequest{Measure: m})
+		if err != nil {
+			return nil, err
+		}
+		modRevision = resp.GetModRevision()
+	} else {
+		resp, err := pw.registry.Update(ctx, &databasev1.MeasureRegistryServiceUpdateRequest{Measure: m})
+		if err != nil {
+			return nil, err
+		}
+		modRevision = resp.GetModRevision()
+	}
+	m.Metadata.ModRevision = modRevision
+	return m, nil
+}
+
+// invalidate drops the cached measures, which are fetched again by the next writes.
+func (pw *promWriter) invalidate(names map[string]bool) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	for name := range names {
+		delete(pw.schemas, name)
+	}
+}
+
+// send writes the data points on a stream, and fails unless all of them succeed.
+// The measures whose schemas are expired are invalidated.
+func (pw *promWriter) send(ctx context.Context, reqs []*measurev1.WriteRequest) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ws, err := pw.measure.Write(ctx)
+	if err != nil {
+		return err
+	}
+	failed := make(map[modelv1.Status]int)
+	expired := make(map[string]bool)
+	var acknowledged int
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			resp, errRecv := ws.Recv()
+			if errors.Is(errRecv, io.EOF) {
+				recvErr <- nil
+				return
+			}
+			if errRecv != nil {
+				recvErr <- errRecv
+				return
+			}
+			acknowledged++
+			switch resp.GetStatus() {
+			case modelv1.Status_STATUS_SUCCEED:
+				continue
+			case modelv1.Status_STATUS_EXPIRED_SCHEMA, modelv1.Status_STATUS_SCHEMA_REVISION_MISMATCH:
+				expired[resp.GetMetadata().GetName()] = true
+			}
+			failed[resp.GetStatus()]++
+		}
+	}()
+	for _, req := range reqs {
+		if err = ws.Send(req); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = ws.CloseSend()
+	}
+	// the stream is aborted if Send returns io.EOF, Recv returns the status then
+	if err != nil && !errors.Is(err, io.EOF) {
+		cancel()
+		<-recvErr
+		return err
+	}
+	if err = <-recvErr; err != nil {
+		return err
+	}
+	pw.invalidate(expired)
+	if len(failed) > 0 || acknowledged < len(reqs) {
+		return errors.Errorf("%d of %d data points are unacknowledged or failed: %v", len(reqs)-acknowledged+sumCounts(failed), len(reqs), failed)
+	}
+	return nil
+}
+
+// groupByMetric groups the series by their metric names, and drops the ones without the names.
+func groupByMetric(series []promql.Series) map[string][]promql.Series {
+	result := make(map[string][]promql.Series)
+	for _, s := range series {
+		if name := s.Labels.Get(promql.MetricNameLabel); name != "" {
+			result[name] = append(result[name], s)
+		}
+	}
+	return result
+}
+
+// isPromMeasure returns whether the measure is in the layout of the remote write, which is a family of the string tags
+// identified by promSeriesTag, and the float field promValueField.
+func isPromMeasure(m *databasev1.Measure) bool {
+	if len(m.GetTagFamilies()) != 1 || m.GetTagFamilies()[0].GetName() != promLabelFamily ||
+		len(m.GetEntity().GetTagNames()) != 1 || m.GetEntity().GetTagNames()[0] != promSeriesTag {
+		return false
+	}
+	for _, t := range m.GetTagFamilies()[0].GetTags() {
+		if t.GetType() != databasev1.TagType_TAG_TYPE_STRING {
+			return false
+		}
+	}
+	return len(m.GetFields()) == 1 && m.GetFields()[0].GetName() == promValueField &&
+		m.GetFields()[0].GetFieldType() == databasev1.FieldType_FIELD_TYPE_FLOAT
+}
+
+// absentLabels returns the labels of the series absent in the measure in the order of their names.
+func absentLabels(m *databasev1.Measure, series []promql.Series) []string {
+	present := make(map[string]bool)
+	for _, t := range m.GetTagFamilies()[0].GetTags() {
+		present[t.GetName()] = true
+	}
+	var result []string
+	for _, s := range series {
+		for _, l := range s.Labels {
+			if l.Name != promql.MetricNameLabel && !present[l.Name] {
+				present[l.Name] = true
+				result = append(result, l.Name)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// appendWriteRequests appends the data points of the samples of the series, except the NaNs including the stale markers.
+func appendWriteRequests(reqs []*measurev1.WriteRequest, m *databasev1.Measure, s promql.Series) []*measurev1.WriteRequest {
+	labels := s.Labels.Map()
+	delete(labels, promql.MetricNameLabel)
+	specs := m.GetTagFamilies()[0].GetTags()
+	tags := make([]*modelv1.TagValue, 0, len(specs))
+	for _, t := range specs {
+		v, ok := labels[t.GetName()]
+		switch {
+		case t.GetName() == promSeriesTag:
+			tags = append(tags, strTagValue(promql.NewLabels(labels).String()))
+		case ok:
+			tags = append(tags, strTagValue(v))
+		default:
+			tags = append(tags, &modelv1.TagValue{Value: &modelv1.TagValue_Null{Null: structpb.NullValue_NULL_VALUE}})
+		}
+	}
+	for _, sample := range s.Samples {
+		if math.IsNaN(sample.V) {
+			continue
+		}
+		reqs = append(reqs, &measurev1.WriteRequest{
+			Metadata: m.GetMetadata(),
+			DataPoint: &measurev1.DataPointValue{
+				Timestamp:   timestamppb.New(time.UnixMilli(sample.T)),
+				TagFamilies: []*modelv1.TagFamilyForWrite{{Tags: tags}},
+				Fields:      []*modelv1.FieldValue{{Value: &modelv1.FieldValue_Float{Float: &modelv1.Float{Value: sample.V}}}},
+			},
+			MessageId: uint64(len(reqs) + 1),
+		})
+	}
+	return reqs
+}
+
+func strTagValue(v string) *modelv1.TagValue {
+	return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: v}}}
+}
+
+func sumCounts(counts map[modelv1.Status]int) int {
+	var sum int
+	for _, n := range counts {
+		sum += n
+	}
+	return sum
+}
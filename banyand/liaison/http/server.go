@@ -43,6 +43,7 @@ import (
 	"github.com/apache/skywalking-banyandb/banyand/observability"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/run"
+	"github.com/apache/skywalking-banyandb/pkg/tlsconfig"
 	"github.com/apache/skywalking-banyandb/ui"
 )
 
@@ -69,20 +70,29 @@ type Server interface {
 }
 
 type server struct {
-	creds        credentials.TransportCredentials
-	l            *logger.Logger
-	clientCloser context.CancelFunc
-	mux          *chi.Mux
-	srv          *http.Server
-	stopCh       chan struct{}
-	host         string
-	listenAddr   string
-	grpcAddr     string
-	keyFile      string
-	certFile     string
-	grpcCert     string
-	port         uint32
-	tls          bool
+	creds           credentials.TransportCredentials
+	l               *logger.Logger
+	tlsReloader     *tlsconfig.Reloader
+	grpcTLSReloader *tlsconfig.Reloader
+	clientCloser    context.CancelFunc
+	mux             *chi.Mux
+	srv             *http.Server
+	stopCh          chan struct{}
+	host            string
+	listenAddr      string
+	grpcAddr        string
+	keyFile         string
+	certFile        string
+	grpcCert        string
+	clientCAFile    string
+	grpcClientCert  string
+	grpcClientKey   string
+	promWriteGroup  string
+	maxRecvSize     run.Bytes
+	promLookback    time.Duration
+	promMaxSamples  uint32
+	port            uint32
+	tls             bool
 }
 
 func (p *server) FlagSet() *run.FlagSet {
@@ -93,7 +103,19 @@ func (p *server) FlagSet() *run.FlagSet {
 	flagSet.StringVar(&p.certFile, "http-cert-file", "", "the TLS cert file of http server")
 	flagSet.StringVar(&p.keyFile, "http-key-file", "", "the TLS key file of http server")
 	flagSet.StringVar(&p.grpcCert, "http-grpc-cert-file", "", "the grpc TLS cert file if grpc server enables tls")
+	flagSet.StringVar(&p.clientCAFile, "http-client-ca-file", "",
+		"the CA file verifying the client certificates, which requires the http clients to present them if it's set")
+	flagSet.StringVar(&p.grpcClientCert, "http-grpc-client-cert-file", "",
+		"the client cert file presented to the grpc server if it requires the client certificates")
+	flagSet.StringVar(&p.grpcClientKey, "http-grpc-client-key-file", "", "the key file of http-grpc-client-cert-file")
 	flagSet.BoolVar(&p.tls, "http-tls", false, "connection uses TLS if true, else plain HTTP")
+	p.maxRecvSize = 64 << 20
+	flagSet.VarP(&p.maxRecvSize, "http-grpc-max-recv-msg-size", "", "the size of the max gRPC response the http server receives, e.g. the result of a query")
+	flagSet.DurationVar(&p.promLookback, "http-prom-lookback", 5*time.Minute,
+		"the max time before an evaluation of the prometheus API within which the latest sample of a series is its value")
+	flagSet.Uint32Var(&p.promMaxSamples, "http-prom-max-samples", 100000, "the max number of the samples a selector of the prometheus API reads")
+	flagSet.StringVar(&p.promWriteGroup, "http-prom-write-group", "",
+		"the group of the measures the prometheus remote write writes the samples to, which is disabled if it's empty")
 	return flagSet
 }
 
@@ -103,12 +125,13 @@ func (p *server) Validate() error {
 		return errNoAddr
 	}
 	observability.UpdateAddress("http", p.listenAddr)
-	if p.grpcCert != "" {
-		creds, errTLS := credentials.NewClientTLSFromFile(p.grpcCert, "")
+	if p.grpcCert != "" || p.grpcClientCert != "" {
+		reloader, errTLS := tlsconfig.NewReloader(p.grpcClientCert, p.grpcClientKey, p.grpcCert)
 		if errTLS != nil {
 			return errors.Wrap(errTLS, "failed to load the grpc cert")
 		}
-		p.creds = creds
+		p.grpcTLSReloader = reloader
+		p.creds = credentials.NewTLS(reloader.ClientConfig())
 	}
 	if !p.tls {
 		return nil
@@ -119,6 +142,11 @@ func (p *server) Validate() error {
 	if p.keyFile == "" {
 		return errServerKey
 	}
+	reloader, err := tlsconfig.NewReloader(p.certFile, p.keyFile, p.clientCAFile)
+	if err != nil {
+		return err
+	}
+	p.tlsReloader = reloader
 	return nil
 }
 
@@ -151,13 +179,17 @@ func (p *server) PreRun(_ context.Context) error {
 		Handler:           p.mux,
 		ReadHeaderTimeout: 3 * time.Second,
 	}
+	if p.tlsReloader != nil {
+		p.srv.TLSConfig = p.tlsReloader.ServerConfig()
+	}
 	return nil
 }
 
 func (p *server) Serve() run.StopNotify {
 	var ctx context.Context
 	ctx, p.clientCloser = context.WithCancel(context.Background())
-	opts := make([]grpc.DialOption, 0, 1)
+	opts := make([]grpc.DialOption, 0, 2)
+	opts = append(opts, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(int(p.maxRecvSize))))
 	if p.creds == nil {
 		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	} else {
@@ -176,6 +208,11 @@ func (p *server) Serve() run.StopNotify {
 		databasev1.RegisterIndexRuleRegistryServiceHandlerFromEndpoint(ctx, gwMux, p.grpcAddr, opts),
 		databasev1.RegisterIndexRuleBindingRegistryServiceHandlerFromEndpoint(ctx, gwMux, p.grpcAddr, opts),
 		databasev1.RegisterGroupRegistryServiceHandlerFromEndpoint(ctx, gwMux, p.grpcAddr, opts),
+		databasev1.RegisterAlertRuleRegistryServiceHandlerFromEndpoint(ctx, gwMux, p.grpcAddr, opts),
+		databasev1.RegisterTenantRegistryServiceHandlerFromEndpoint(ctx, gwMux, p.grpcAddr, opts),
+		databasev1.RegisterClusterStateServiceHandlerFromEndpoint(ctx, gwMux, p.grpcAddr, opts),
+		databasev1.RegisterSnapshotServiceHandlerFromEndpoint(ctx, gwMux, p.grpcAddr, opts),
+		databasev1.RegisterDeadLetterServiceHandlerFromEndpoint(ctx, gwMux, p.grpcAddr, opts),
 		streamv1.RegisterStreamServiceHandlerFromEndpoint(ctx, gwMux, p.grpcAddr, opts),
 		measurev1.RegisterMeasureServiceHandlerFromEndpoint(ctx, gwMux, p.grpcAddr, opts),
 		propertyv1.RegisterPropertyServiceHandlerFromEndpoint(ctx, gwMux, p.grpcAddr, opts),
@@ -186,11 +223,22 @@ func (p *server) Serve() run.StopNotify {
 		return p.stopCh
 	}
 	p.mux.Mount("/api", http.StripPrefix("/api", gwMux))
+	prom := newPromAPI(client.conn, p.l, p.promLookback, p.promMaxSamples)
+	if p.promWriteGroup != "" {
+		prom.writer = newPromWriter(client.conn, p.l, p.promWriteGroup)
+	}
+	p.mux.Mount("/api/prometheus", prom.routes())
+	for _, r := range []*tlsconfig.Reloader{p.tlsReloader, p.grpcTLSReloader} {
+		if r != nil {
+			r.Start(p.l)
+		}
+	}
 	go func() {
 		p.l.Info().Str("listenAddr", p.listenAddr).Msg("Start liaison http server")
 		var err error
 		if p.tls {
-			err = p.srv.ListenAndServeTLS(p.certFile, p.keyFile)
+			// the certificate is picked from the TLS config, which reloads it once it changes
+			err = p.srv.ListenAndServeTLS("", "")
 		} else {
 			err = p.srv.ListenAndServe()
 		}
@@ -208,6 +256,11 @@ func (p *server) GracefulStop() {
 		p.l.Error().Err(err)
 	}
 	p.clientCloser()
+	for _, r := range []*tlsconfig.Reloader{p.tlsReloader, p.grpcTLSReloader} {
+		if r != nil {
+			r.Stop()
+		}
+	}
 }
 
 func intercept404(handler, on404 http.Handler) http.HandlerFunc {
@@ -0,0 +1,142 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/pkg/client"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+const (
+	encodingProtobuf = "protobuf"
+	encodingJSON     = "json"
+)
+
+// decoder decodes the records of the topics to the write requests.
+type decoder struct {
+	streamTopics  map[string]bool
+	measureTopics map[string]bool
+	encoding      string
+}
+
+// decode decodes the record into the batch. The idempotency key absent in the request is set to the position of
+// the record, so the records replayed after a failure are skipped by the groups deduplicating the writes.
+func (d *decoder) decode(b *batch, r *kgo.Record) error {
+	key := fmt.Sprintf("%s/%d/%d", r.Topic, r.Partition, r.Offset)
+	switch {
+	case d.streamTopics[r.Topic]:
+		req := &streamv1.WriteRequest{}
+		if err := d.unmarshal(r.Value, req); err != nil {
+			return err
+		}
+		if req.GetIdempotencyKey() == "" {
+			req.IdempotencyKey = key
+		}
+		b.streams = append(b.streams, req)
+	case d.measureTopics[r.Topic]:
+		req := &measurev1.WriteRequest{}
+		if err := d.unmarshal(r.Value, req); err != nil {
+			return err
+		}
+		if req.GetIdempotencyKey() == "" {
+			req.IdempotencyKey = key
+		}
+		b.measures = append(b.measures, req)
+	default:
+		return errors.Errorf("unknown topic %s", r.Topic)
+	}
+	return nil
+}
+
+func (d *decoder) unmarshal(data []byte, m proto.Message) error {
+	if d.encoding == encodingJSON {
+		return protojson.Unmarshal(data, m)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// batch is the write requests consumed since the last flush.
+type batch struct {
+	streams  []*streamv1.WriteRequest
+	measures []*measurev1.WriteRequest
+}
+
+func (b *batch) len() int {
+	return len(b.streams) + len(b.measures)
+}
+
+// write writes the requests of the batch, and keeps the ones to write again in it.
+// The ones the server rejects for good, e.g. the ones with invalid timestamps, are dropped.
+func (b *batch) write(ctx context.Context, c *client.Client, l *logger.Logger) error {
+	var err error
+	if len(b.streams) > 0 {
+		if b.streams, err = retry(b.streams, c.SendStream(ctx, b.streams...), l); err != nil {
+			return errors.WithMessage(err, "failed to write the streams")
+		}
+	}
+	if len(b.measures) > 0 {
+		if b.measures, err = retry(b.measures, c.SendMeasure(ctx, b.measures...), l); err != nil {
+			return errors.WithMessage(err, "failed to write the measures")
+		}
+	}
+	return nil
+}
+
+// retry returns the requests to write again by the error writing them, along with the error if there are any.
+func retry[R interface{ GetMetadata() *commonv1.Metadata }](reqs []R, err error, l *logger.Logger) ([]R, error) {
+	if err == nil {
+		return nil, nil
+	}
+	var writeErr *client.WriteError
+	if !errors.As(err, &writeErr) {
+		return reqs, err
+	}
+	var pending []R
+	for i, req := range reqs {
+		st, ok := writeErr.Failed[i]
+		switch {
+		case !ok:
+		case isRetryable(st):
+			pending = append(pending, req)
+		default:
+			l.Warn().Str("group", req.GetMetadata().GetGroup()).Str("name", req.GetMetadata().GetName()).
+				Stringer("status", st).Msg("drop the write rejected by the server")
+		}
+	}
+	if len(pending) > 0 {
+		return pending, errors.Errorf("%d writes are unacknowledged or failed by the server", len(pending))
+	}
+	return nil, nil
+}
+
+// isRetryable tells whether the write failed with the status may succeed if it's written again.
+func isRetryable(st modelv1.Status) bool {
+	return st == modelv1.Status_STATUS_UNSPECIFIED || st == modelv1.Status_STATUS_INTERNAL_ERROR || st == modelv1.Status_STATUS_IN_FLIGHT
+}
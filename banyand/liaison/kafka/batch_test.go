@@ -0,0 +1,82 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/pkg/client"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+func TestDecode(t *testing.T) {
+	metadata := &commonv1.Metadata{Group: "default", Name: "sw"}
+	streamReq, err := proto.Marshal(&streamv1.WriteRequest{Metadata: metadata, Element: &streamv1.ElementValue{ElementId: "1"}})
+	require.NoError(t, err)
+	measureReq, err := protojson.Marshal(&measurev1.WriteRequest{Metadata: metadata, IdempotencyKey: "k"})
+	require.NoError(t, err)
+
+	d := &decoder{streamTopics: toSet([]string{"streams"}), measureTopics: toSet([]string{"measures"}), encoding: encodingProtobuf}
+	b := &batch{}
+	require.NoError(t, d.decode(b, &kgo.Record{Topic: "streams", Partition: 1, Offset: 42, Value: streamReq}))
+	require.Len(t, b.streams, 1)
+	assert.Equal(t, "1", b.streams[0].GetElement().GetElementId())
+	assert.Equal(t, "streams/1/42", b.streams[0].GetIdempotencyKey(), "the position of the record is the idempotency key")
+	assert.Error(t, d.decode(b, &kgo.Record{Topic: "streams", Value: []byte("{")}))
+	assert.Error(t, d.decode(b, &kgo.Record{Topic: "unknown", Value: streamReq}))
+
+	d.encoding = encodingJSON
+	require.NoError(t, d.decode(b, &kgo.Record{Topic: "measures", Value: measureReq}))
+	require.Len(t, b.measures, 1)
+	assert.Equal(t, "k", b.measures[0].GetIdempotencyKey(), "the idempotency key of the producer is kept")
+	assert.Equal(t, 2, b.len())
+}
+
+func TestRetry(t *testing.T) {
+	l := logger.GetLogger("test")
+	reqs := []*streamv1.WriteRequest{{MessageId: 1}, {MessageId: 2}, {MessageId: 3}}
+
+	pending, err := retry(reqs, nil, l)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+
+	pending, err = retry(reqs, assert.AnError, l)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, reqs, pending, "all the requests are written again if the stream fails")
+
+	pending, err = retry(reqs, &client.WriteError{Failed: map[int]modelv1.Status{
+		0: modelv1.Status_STATUS_INTERNAL_ERROR,
+		2: modelv1.Status_STATUS_INVALID_TIMESTAMP,
+	}}, l)
+	assert.Error(t, err)
+	assert.Equal(t, reqs[:1], pending, "the requests rejected for good are dropped")
+
+	pending, err = retry(reqs, &client.WriteError{Failed: map[int]modelv1.Status{1: modelv1.Status_STATUS_NOT_FOUND}}, l)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
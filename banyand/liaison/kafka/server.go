@@ -0,0 +1,263 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package kafka implements the bridge consuming the write requests of the streams and the measures from Kafka.
+// The requests are written in batches, and the offsets are committed once the batches are flushed, so the records
+// are replayed from the last flush if the bridge fails.
+package kafka
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kgo"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/pkg/client"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+const maxBackoff = 30 * time.Second
+
+var (
+	_ run.Config  = (*bridge)(nil)
+	_ run.Service = (*bridge)(nil)
+
+	errNoTopic       = errors.New("kafka: no topic to consume")
+	errInvalidEncode = errors.New("kafka: the encoding should be protobuf or json")
+	errInvalidFlush  = errors.New("kafka: the flush size and the flush interval should be positive")
+)
+
+// NewBridge returns the Kafka bridge, which is disabled unless the brokers are set.
+func NewBridge() run.Unit {
+	return &bridge{
+		stopCh: make(chan struct{}),
+	}
+}
+
+type bridge struct {
+	clientCreds   credentials.TransportCredentials
+	l             *logger.Logger
+	kc            *kgo.Client
+	cancel        context.CancelFunc
+	stopCh        chan struct{}
+	decoder       *decoder
+	group         string
+	encoding      string
+	grpcAddr      string
+	grpcCert      string
+	grpcToken     string
+	brokers       []string
+	streamTopics  []string
+	measureTopics []string
+	flushInterval time.Duration
+	flushSize     int
+}
+
+func (b *bridge) FlagSet() *run.FlagSet {
+	fs := run.NewFlagSet("kafka")
+	fs.StringSliceVar(&b.brokers, "kafka-brokers", nil, "the seed brokers of the Kafka cluster, which enables the Kafka bridge")
+	fs.StringVar(&b.group, "kafka-consumer-group", "banyandb", "the consumer group of the Kafka bridge")
+	fs.StringSliceVar(&b.streamTopics, "kafka-stream-topics", nil, "the topics of the stream write requests")
+	fs.StringSliceVar(&b.measureTopics, "kafka-measure-topics", nil, "the topics of the measure write requests")
+	fs.StringVar(&b.encoding, "kafka-encoding", encodingProtobuf, "the encoding of the write requests in the records, protobuf or json")
+	fs.IntVar(&b.flushSize, "kafka-flush-size", 1000, "the number of the records the Kafka bridge flushes at most once")
+	fs.DurationVar(&b.flushInterval, "kafka-flush-interval", time.Second, "the interval the Kafka bridge flushes the records at, "+
+		"which should be shorter than the rebalance timeout of the consumer group")
+	fs.StringVar(&b.grpcAddr, "kafka-banyand-addr", "localhost:17912", "the address of the gRPC server the Kafka bridge writes to")
+	fs.StringVar(&b.grpcCert, "kafka-banyand-cert-file", "", "the TLS cert file of the gRPC server the Kafka bridge writes to if it enables TLS")
+	fs.StringVar(&b.grpcToken, "kafka-banyand-token", "", "the bearer token of the gRPC server the Kafka bridge writes to if it enables the authentication")
+	return fs
+}
+
+func (b *bridge) Validate() error {
+	if len(b.brokers) == 0 {
+		return nil
+	}
+	if len(b.streamTopics)+len(b.measureTopics) == 0 {
+		return errNoTopic
+	}
+	for _, topic := range b.streamTopics {
+		if slices.Contains(b.measureTopics, topic) {
+			return errors.Errorf("kafka: the topic %s carries either the stream or the measure write requests", topic)
+		}
+	}
+	if b.encoding != encodingProtobuf && b.encoding != encodingJSON {
+		return errInvalidEncode
+	}
+	if b.flushSize <= 0 || b.flushInterval <= 0 {
+		return errInvalidFlush
+	}
+	if b.grpcCert != "" {
+		var err error
+		if b.clientCreds, err = credentials.NewClientTLSFromFile(b.grpcCert, ""); err != nil {
+			return errors.Wrap(err, "failed to load the grpc cert")
+		}
+	}
+	b.decoder = &decoder{streamTopics: toSet(b.streamTopics), measureTopics: toSet(b.measureTopics), encoding: b.encoding}
+	return nil
+}
+
+func (b *bridge) Name() string {
+	return "liaison-kafka"
+}
+
+func (b *bridge) Role() databasev1.Role {
+	return databasev1.Role_ROLE_LIAISON
+}
+
+func (b *bridge) Serve() run.StopNotify {
+	b.l = logger.GetLogger(b.Name())
+	if len(b.brokers) == 0 {
+		return b.stopCh
+	}
+	kc, err := kgo.NewClient(
+		kgo.SeedBrokers(b.brokers...),
+		kgo.ConsumerGroup(b.group),
+		kgo.ConsumeTopics(append(slices.Clone(b.streamTopics), b.measureTopics...)...),
+		kgo.DisableAutoCommit(),
+		// the partitions aren't revoked until the records polled are flushed and committed
+		kgo.BlockRebalanceOnPoll(),
+	)
+	if err != nil {
+		b.l.Error().Err(err).Msg("failed to create the Kafka client")
+		close(b.stopCh)
+		return b.stopCh
+	}
+	b.kc = kc
+	var ctx context.Context
+	ctx, b.cancel = context.WithCancel(context.Background())
+	go func() {
+		defer close(b.stopCh)
+		defer kc.Close()
+		b.consume(ctx)
+	}()
+	return b.stopCh
+}
+
+func (b *bridge) GracefulStop() {
+	if len(b.brokers) == 0 {
+		close(b.stopCh)
+		return
+	}
+	// the records polled are flushed before the consumer leaves the group
+	if b.cancel != nil {
+		b.cancel()
+	}
+	<-b.stopCh
+}
+
+// consume polls the records and flushes them once there are enough of them or the flush interval elapses.
+// The offsets are committed after the records are flushed, and the rebalances are allowed then.
+func (b *bridge) consume(ctx context.Context) {
+	var c *client.Client
+	defer func() {
+		if c != nil {
+			_ = c.Close()
+		}
+	}()
+	pending := &batch{}
+	var polled int
+	deadline := time.Now().Add(b.flushInterval)
+	for ctx.Err() == nil {
+		pollCtx, cancel := context.WithDeadline(ctx, deadline)
+		fetches := b.kc.PollRecords(pollCtx, b.flushSize-polled)
+		cancel()
+		if fetches.IsClientClosed() {
+			return
+		}
+		fetches.EachError(func(topic string, partition int32, err error) {
+			if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+				b.l.Warn().Err(err).Str("topic", topic).Int32("partition", partition).Msg("failed to fetch the records")
+			}
+		})
+		fetches.EachRecord(func(r *kgo.Record) {
+			polled++
+			if err := b.decoder.decode(pending, r); err != nil {
+				b.l.Warn().Err(err).Str("topic", r.Topic).Int32("partition", r.Partition).Int64("offset", r.Offset).
+					Msg("drop the record which isn't a write request")
+			}
+		})
+		if polled < b.flushSize && time.Now().Before(deadline) && ctx.Err() == nil {
+			continue
+		}
+		if polled > 0 {
+			var err error
+			if c, err = b.flush(ctx, c, pending); err != nil {
+				// the records not committed are consumed again by the next owner of the partitions
+				b.l.Warn().Err(err).Int("records", polled).Msg("stop before the records are flushed")
+				return
+			}
+			if err = b.kc.CommitUncommittedOffsets(context.Background()); err != nil {
+				b.l.Warn().Err(err).Msg("failed to commit the offsets, the records flushed are consumed again")
+			}
+			polled = 0
+		}
+		b.kc.AllowRebalance()
+		deadline = time.Now().Add(b.flushInterval)
+	}
+}
+
+// flush writes the batch until its requests are written or dropped, which fails only if the bridge stops.
+// The client connecting the server is returned, which is connected if c is nil.
+func (b *bridge) flush(ctx context.Context, c *client.Client, pending *batch) (*client.Client, error) {
+	backoff := b.flushInterval
+	for {
+		err := b.connect(&c)
+		if err == nil {
+			if err = pending.write(context.Background(), c, b.l); err == nil {
+				return c, nil
+			}
+		}
+		b.l.Warn().Err(err).Int("pending", pending.len()).Dur("backoff", backoff).Msg("failed to flush the records")
+		select {
+		case <-ctx.Done():
+			return c, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+func (b *bridge) connect(c **client.Client) error {
+	if *c != nil {
+		return nil
+	}
+	var opts []client.Option
+	if b.clientCreds != nil {
+		opts = append(opts, client.WithDialOptions(grpclib.WithTransportCredentials(b.clientCreds)))
+	}
+	if b.grpcToken != "" {
+		opts = append(opts, client.WithToken(b.grpcToken))
+	}
+	var err error
+	*c, err = client.Connect(b.grpcAddr, opts...)
+	return err
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
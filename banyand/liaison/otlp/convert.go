@@ -0,0 +1,160 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	collogsv1 "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricsv1 "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// entry is an element or a data point converted from a span, a log record or a data point of a metric.
+type entry struct {
+	timestamp time.Time
+	tags      map[string]any
+	id        string
+	value     float64
+}
+
+// spanEntries converts the spans to the elements, whose IDs are the trace IDs followed by the span IDs in hex,
+// and whose timestamps are the start times.
+func spanEntries(rule *Rule, req *coltracev1.ExportTraceServiceRequest) []entry {
+	var entries []entry
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				r := &record{
+					resource:   rs.GetResource().GetAttributes(),
+					scope:      ss.GetScope(),
+					attributes: span.GetAttributes(),
+					fields: map[string]any{
+						"trace_id":       hexID(span.GetTraceId()),
+						"span_id":        hexID(span.GetSpanId()),
+						"parent_span_id": hexID(span.GetParentSpanId()),
+						"name":           nonEmpty(span.GetName()),
+						"kind":           span.GetKind().String(),
+						"status_code":    span.GetStatus().GetCode().String(),
+						"status_message": nonEmpty(span.GetStatus().GetMessage()),
+						"duration":       int64(span.GetEndTimeUnixNano() - span.GetStartTimeUnixNano()),
+					},
+				}
+				entries = append(entries, entry{
+					id:        hex.EncodeToString(span.GetTraceId()) + hex.EncodeToString(span.GetSpanId()),
+					timestamp: time.Unix(0, int64(span.GetStartTimeUnixNano())),
+					tags:      r.tags(rule),
+				})
+			}
+		}
+	}
+	return entries
+}
+
+// logEntries converts the log records to the elements, whose IDs are random, and whose timestamps are the times of
+// the events, or the observed times if the formers are absent.
+func logEntries(rule *Rule, req *collogsv1.ExportLogsServiceRequest) []entry {
+	var entries []entry
+	for _, rl := range req.GetResourceLogs() {
+		for _, sl := range rl.GetScopeLogs() {
+			for _, lr := range sl.GetLogRecords() {
+				r := &record{
+					resource:   rl.GetResource().GetAttributes(),
+					scope:      sl.GetScope(),
+					attributes: lr.GetAttributes(),
+					fields: map[string]any{
+						"trace_id":        hexID(lr.GetTraceId()),
+						"span_id":         hexID(lr.GetSpanId()),
+						"body":            anyValue(lr.GetBody()),
+						"severity_text":   nonEmpty(lr.GetSeverityText()),
+						"severity_number": int64(lr.GetSeverityNumber()),
+					},
+				}
+				ts := lr.GetTimeUnixNano()
+				if ts == 0 {
+					ts = lr.GetObservedTimeUnixNano()
+				}
+				entries = append(entries, entry{
+					id:        uuid.NewString(),
+					timestamp: time.Unix(0, int64(ts)),
+					tags:      r.tags(rule),
+				})
+			}
+		}
+	}
+	return entries
+}
+
+// metricEntries converts the data points of the metrics to the data points of the measure.
+// The values of the gauges and the sums are the values of the data points, and the histograms and the summaries are
+// converted to the sums and the counts of their data points, whose names are suffixed by _sum and _count.
+func metricEntries(rule *Rule, req *colmetricsv1.ExportMetricsServiceRequest) []entry {
+	var entries []entry
+	for _, rm := range req.GetResourceMetrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				add := func(name string, attributes []*commonv1.KeyValue, timeUnixNano uint64, value float64) {
+					r := &record{
+						resource:   rm.GetResource().GetAttributes(),
+						scope:      sm.GetScope(),
+						attributes: attributes,
+						fields:     map[string]any{"name": name, "unit": nonEmpty(m.GetUnit())},
+					}
+					entries = append(entries, entry{timestamp: time.Unix(0, int64(timeUnixNano)), tags: r.tags(rule), value: value})
+				}
+				switch data := m.GetData().(type) {
+				case *metricsv1.Metric_Gauge:
+					for _, dp := range data.Gauge.GetDataPoints() {
+						add(m.GetName(), dp.GetAttributes(), dp.GetTimeUnixNano(), numberValue(dp))
+					}
+				case *metricsv1.Metric_Sum:
+					for _, dp := range data.Sum.GetDataPoints() {
+						add(m.GetName(), dp.GetAttributes(), dp.GetTimeUnixNano(), numberValue(dp))
+					}
+				case *metricsv1.Metric_Histogram:
+					for _, dp := range data.Histogram.GetDataPoints() {
+						add(m.GetName()+"_sum", dp.GetAttributes(), dp.GetTimeUnixNano(), dp.GetSum())
+						add(m.GetName()+"_count", dp.GetAttributes(), dp.GetTimeUnixNano(), float64(dp.GetCount()))
+					}
+				case *metricsv1.Metric_ExponentialHistogram:
+					for _, dp := range data.ExponentialHistogram.GetDataPoints() {
+						add(m.GetName()+"_sum", dp.GetAttributes(), dp.GetTimeUnixNano(), dp.GetSum())
+						add(m.GetName()+"_count", dp.GetAttributes(), dp.GetTimeUnixNano(), float64(dp.GetCount()))
+					}
+				case *metricsv1.Metric_Summary:
+					for _, dp := range data.Summary.GetDataPoints() {
+						add(m.GetName()+"_sum", dp.GetAttributes(), dp.GetTimeUnixNano(), dp.GetSum())
+						add(m.GetName()+"_count", dp.GetAttributes(), dp.GetTimeUnixNano(), float64(dp.GetCount()))
+					}
+				}
+			}
+		}
+	}
+	return entries
+}
+
+func numberValue(dp *metricsv1.NumberDataPoint) float64 {
+	if v, ok := dp.GetValue().(*metricsv1.NumberDataPoint_AsInt); ok {
+		return float64(v.AsInt)
+	}
+	return dp.GetAsDouble()
+}
@@ -0,0 +1,140 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collogsv1 "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricsv1 "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcev1 "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracev1 "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+var testResource = &resourcev1.Resource{Attributes: []*commonv1.KeyValue{stringKV("service.name", "checkout")}}
+
+func stringKV(key, value string) *commonv1.KeyValue {
+	return &commonv1.KeyValue{Key: key, Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: value}}}
+}
+
+func TestSpanEntries(t *testing.T) {
+	rule := &Rule{Tags: map[string]string{
+		"trace_id": "trace_id",
+		"parent":   "parent_span_id",
+		"kind":     "kind",
+		"duration": "duration",
+		"service":  "resource.service.name",
+		"method":   "attributes.http.method",
+		"retried":  "attributes.retried",
+		"scope":    "scope.name",
+	}}
+	start := time.Unix(100, 0)
+	req := &coltracev1.ExportTraceServiceRequest{ResourceSpans: []*tracev1.ResourceSpans{{
+		Resource: testResource,
+		ScopeSpans: []*tracev1.ScopeSpans{{
+			Scope: &commonv1.InstrumentationScope{Name: "http"},
+			Spans: []*tracev1.Span{{
+				TraceId:           []byte{0x01, 0x02},
+				SpanId:            []byte{0x0a},
+				Kind:              tracev1.Span_SPAN_KIND_SERVER,
+				StartTimeUnixNano: uint64(start.UnixNano()),
+				EndTimeUnixNano:   uint64(start.Add(time.Millisecond).UnixNano()),
+				Attributes: []*commonv1.KeyValue{
+					stringKV("http.method", "GET"),
+					{Key: "retried", Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_BoolValue{BoolValue: true}}},
+				},
+			}},
+		}},
+	}}}
+	entries := spanEntries(rule, req)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "01020a", entries[0].id)
+	assert.True(t, start.Equal(entries[0].timestamp))
+	assert.Equal(t, map[string]any{
+		"trace_id": "0102",
+		"kind":     "SPAN_KIND_SERVER",
+		"duration": int64(time.Millisecond),
+		"service":  "checkout",
+		"method":   "GET",
+		"retried":  "true",
+		"scope":    "http",
+	}, entries[0].tags)
+}
+
+func TestLogEntries(t *testing.T) {
+	rule := &Rule{Tags: map[string]string{"body": "body", "severity": "severity_number", "service": "resource.service.name"}}
+	observed := time.Unix(200, 0)
+	req := &collogsv1.ExportLogsServiceRequest{ResourceLogs: []*logsv1.ResourceLogs{{
+		Resource: testResource,
+		ScopeLogs: []*logsv1.ScopeLogs{{LogRecords: []*logsv1.LogRecord{{
+			ObservedTimeUnixNano: uint64(observed.UnixNano()),
+			SeverityNumber:       logsv1.SeverityNumber_SEVERITY_NUMBER_WARN,
+			Body:                 &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: "slow query"}},
+		}}}},
+	}}}
+	entries := logEntries(rule, req)
+	require.Len(t, entries, 1)
+	assert.NotEmpty(t, entries[0].id)
+	assert.True(t, observed.Equal(entries[0].timestamp))
+	assert.Equal(t, map[string]any{"body": "slow query", "severity": int64(logsv1.SeverityNumber_SEVERITY_NUMBER_WARN), "service": "checkout"}, entries[0].tags)
+}
+
+func TestMetricEntries(t *testing.T) {
+	rule := &Rule{Tags: map[string]string{"name": "name", "unit": "unit", "route": "attributes.route"}, Field: defaultField}
+	ts := uint64(time.Unix(300, 0).UnixNano())
+	attributes := []*commonv1.KeyValue{stringKV("route", "/pay")}
+	req := &colmetricsv1.ExportMetricsServiceRequest{ResourceMetrics: []*metricsv1.ResourceMetrics{{
+		Resource: testResource,
+		ScopeMetrics: []*metricsv1.ScopeMetrics{{Metrics: []*metricsv1.Metric{
+			{
+				Name: "requests",
+				Data: &metricsv1.Metric_Sum{Sum: &metricsv1.Sum{DataPoints: []*metricsv1.NumberDataPoint{
+					{TimeUnixNano: ts, Attributes: attributes, Value: &metricsv1.NumberDataPoint_AsInt{AsInt: 7}},
+				}}},
+			},
+			{
+				Name: "latency",
+				Unit: "ms",
+				Data: &metricsv1.Metric_Histogram{Histogram: &metricsv1.Histogram{DataPoints: []*metricsv1.HistogramDataPoint{
+					{TimeUnixNano: ts, Attributes: attributes, Count: 4, Sum: func() *float64 { v := 10.5; return &v }()},
+				}}},
+			},
+		}}},
+	}}}
+	entries := metricEntries(rule, req)
+	require.Len(t, entries, 3)
+	want := []struct {
+		tags  map[string]any
+		value float64
+	}{
+		{map[string]any{"name": "requests", "route": "/pay"}, 7},
+		{map[string]any{"name": "latency_sum", "unit": "ms", "route": "/pay"}, 10.5},
+		{map[string]any{"name": "latency_count", "unit": "ms", "route": "/pay"}, 4},
+	}
+	for i, w := range want {
+		assert.Equal(t, w.tags, entries[i].tags)
+		assert.Equal(t, w.value, entries[i].value)
+	}
+}
@@ -0,0 +1,210 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"encoding/hex"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	resourcePrefix  = "resource."
+	attributePrefix = "attributes."
+	defaultField    = "value"
+)
+
+var (
+	// spanSources are the fields of a span as the sources, whose duration is in nanoseconds.
+	spanSources = []string{"trace_id", "span_id", "parent_span_id", "name", "kind", "status_code", "status_message", "duration"}
+	// logSources are the fields of a log record as the sources.
+	logSources = []string{"trace_id", "span_id", "body", "severity_text", "severity_number"}
+	// metricSources are the fields of a metric as the sources.
+	metricSources = []string{"name", "unit"}
+	// scopeSources are the fields of the instrumentation scope, which are the sources of all the signals.
+	scopeSources = []string{"scope.name", "scope.version"}
+)
+
+// Mapping maps the signals of OpenTelemetry to the streams and the measures, which is loaded from a YAML file, e.g.
+//
+//	traces:
+//	  group: otlp
+//	  name: span
+//	  tags:
+//	    trace_id: trace_id
+//	    service_name: resource.service.name
+//	    http_method: attributes.http.method
+//
+// The signals without a rule are rejected.
+type Mapping struct {
+	Traces  *Rule `json:"traces,omitempty"`
+	Metrics *Rule `json:"metrics,omitempty"`
+	Logs    *Rule `json:"logs,omitempty"`
+}
+
+// Rule maps a signal to a stream, or a measure of the metrics. The tags are set to the values of their sources,
+// which are the fields of the signal, e.g. trace_id, the attributes by the prefix "attributes.",
+// the attributes of the resource by the prefix "resource.", and the fields of the scope, i.e. scope.name and scope.version.
+// The tags of the absent sources are nulls.
+type Rule struct {
+	Tags  map[string]string `json:"tags"`
+	Group string            `json:"group"`
+	Name  string            `json:"name"`
+	// Field is the field of the values of the metrics, which is "value" by default.
+	Field string `json:"field,omitempty"`
+}
+
+// LoadMapping loads the mapping from the YAML file.
+func LoadMapping(path string) (*Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mapping{}
+	if err = yaml.UnmarshalStrict(data, m); err != nil {
+		return nil, errors.Wrapf(err, "invalid mapping %s", path)
+	}
+	if m.Traces == nil && m.Metrics == nil && m.Logs == nil {
+		return nil, errors.Errorf("the mapping %s has no rule", path)
+	}
+	for signal, r := range map[string]struct {
+		rule    *Rule
+		sources []string
+	}{"traces": {m.Traces, spanSources}, "metrics": {m.Metrics, metricSources}, "logs": {m.Logs, logSources}} {
+		if err = r.rule.validate(r.sources); err != nil {
+			return nil, errors.WithMessagef(err, "invalid rule of the %s", signal)
+		}
+	}
+	if m.Metrics != nil && m.Metrics.Field == "" {
+		m.Metrics.Field = defaultField
+	}
+	return m, nil
+}
+
+func (r *Rule) validate(sources []string) error {
+	if r == nil {
+		return nil
+	}
+	if r.Group == "" || r.Name == "" {
+		return errors.New("the group and the name are required")
+	}
+	for tag, source := range r.Tags {
+		if strings.HasPrefix(source, resourcePrefix) || strings.HasPrefix(source, attributePrefix) ||
+			slices.Contains(scopeSources, source) || slices.Contains(sources, source) {
+			continue
+		}
+		return errors.Errorf("unknown source %q of the tag %s, which should be one of %v, %v, or an attribute", source, tag, sources, scopeSources)
+	}
+	return nil
+}
+
+// record is a span, a log record or a data point, whose sources are resolved to the values of the tags.
+type record struct {
+	scope      *commonv1.InstrumentationScope
+	fields     map[string]any
+	resource   []*commonv1.KeyValue
+	attributes []*commonv1.KeyValue
+}
+
+// tags returns the values of the tags of the rule, which are strings, integers, slices of them or bytes.
+// The tags of the absent sources are absent, and so are all the tags if the rule is absent.
+func (r *record) tags(rule *Rule) map[string]any {
+	if rule == nil {
+		return nil
+	}
+	tags := make(map[string]any, len(rule.Tags))
+	for tag, source := range rule.Tags {
+		if v := r.value(source); v != nil {
+			tags[tag] = v
+		}
+	}
+	return tags
+}
+
+func (r *record) value(source string) any {
+	switch {
+	case strings.HasPrefix(source, resourcePrefix):
+		return attribute(r.resource, strings.TrimPrefix(source, resourcePrefix))
+	case strings.HasPrefix(source, attributePrefix):
+		return attribute(r.attributes, strings.TrimPrefix(source, attributePrefix))
+	case source == "scope.name":
+		return nonEmpty(r.scope.GetName())
+	case source == "scope.version":
+		return nonEmpty(r.scope.GetVersion())
+	}
+	return r.fields[source]
+}
+
+func attribute(attributes []*commonv1.KeyValue, key string) any {
+	for _, kv := range attributes {
+		if kv.GetKey() == key {
+			return anyValue(kv.GetValue())
+		}
+	}
+	return nil
+}
+
+// anyValue converts the value to a tag value. A bool or a double is a string, an array is the strings of its values,
+// and a key-value list is absent.
+func anyValue(v *commonv1.AnyValue) any {
+	switch v.GetValue().(type) {
+	case *commonv1.AnyValue_StringValue:
+		return v.GetStringValue()
+	case *commonv1.AnyValue_IntValue:
+		return v.GetIntValue()
+	case *commonv1.AnyValue_BoolValue:
+		return strconv.FormatBool(v.GetBoolValue())
+	case *commonv1.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.GetDoubleValue(), 'f', -1, 64)
+	case *commonv1.AnyValue_BytesValue:
+		return v.GetBytesValue()
+	case *commonv1.AnyValue_ArrayValue:
+		values := make([]string, 0, len(v.GetArrayValue().GetValues()))
+		for _, e := range v.GetArrayValue().GetValues() {
+			switch ev := anyValue(e).(type) {
+			case string:
+				values = append(values, ev)
+			case int64:
+				values = append(values, strconv.FormatInt(ev, 10))
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// hexID returns the ID in hex, which is absent if it's empty.
+func hexID(id []byte) any {
+	if len(id) == 0 {
+		return nil
+	}
+	return hex.EncodeToString(id)
+}
+
+func nonEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
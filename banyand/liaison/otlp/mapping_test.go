@@ -0,0 +1,97 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+		want    *Mapping
+	}{
+		{
+			name: "metrics by the default field",
+			content: `
+traces:
+  group: otlp
+  name: span
+  tags:
+    trace_id: trace_id
+    service: resource.service.name
+    method: attributes.http.method
+metrics:
+  group: otlp
+  name: metric
+  tags:
+    name: name
+    scope: scope.name
+`,
+			want: &Mapping{
+				Traces: &Rule{
+					Group: "otlp",
+					Name:  "span",
+					Tags:  map[string]string{"trace_id": "trace_id", "service": "resource.service.name", "method": "attributes.http.method"},
+				},
+				Metrics: &Rule{Group: "otlp", Name: "metric", Field: "value", Tags: map[string]string{"name": "name", "scope": "scope.name"}},
+			},
+		},
+		{
+			name:    "no rule",
+			content: "{}",
+			wantErr: "has no rule",
+		},
+		{
+			name:    "unknown field",
+			content: "spans:\n  group: otlp\n  name: span\n",
+			wantErr: "invalid mapping",
+		},
+		{
+			name:    "no name",
+			content: "logs:\n  group: otlp\n",
+			wantErr: "the group and the name are required",
+		},
+		{
+			name:    "unknown source",
+			content: "logs:\n  group: otlp\n  name: log\n  tags:\n    duration: duration\n",
+			wantErr: `unknown source "duration"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "mapping.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+			got, err := LoadMapping(path)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
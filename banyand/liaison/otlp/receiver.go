@@ -0,0 +1,169 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	collogsv1 "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricsv1 "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/apache/skywalking-banyandb/pkg/client"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+type traceServer struct {
+	coltracev1.UnimplementedTraceServiceServer
+	rule *Rule
+	w    *writer
+}
+
+func (ts *traceServer) Export(ctx context.Context, req *coltracev1.ExportTraceServiceRequest) (*coltracev1.ExportTraceServiceResponse, error) {
+	rejected, msg, err := ts.w.write(ctx, ts.rule, "traces", spanEntries(ts.rule, req), false)
+	if err != nil {
+		return nil, err
+	}
+	resp := &coltracev1.ExportTraceServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &coltracev1.ExportTracePartialSuccess{RejectedSpans: rejected, ErrorMessage: msg}
+	}
+	return resp, nil
+}
+
+type metricsServer struct {
+	colmetricsv1.UnimplementedMetricsServiceServer
+	rule *Rule
+	w    *writer
+}
+
+func (ms *metricsServer) Export(ctx context.Context, req *colmetricsv1.ExportMetricsServiceRequest) (*colmetricsv1.ExportMetricsServiceResponse, error) {
+	rejected, msg, err := ms.w.write(ctx, ms.rule, "metrics", metricEntries(ms.rule, req), true)
+	if err != nil {
+		return nil, err
+	}
+	resp := &colmetricsv1.ExportMetricsServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &colmetricsv1.ExportMetricsPartialSuccess{RejectedDataPoints: rejected, ErrorMessage: msg}
+	}
+	return resp, nil
+}
+
+type logsServer struct {
+	collogsv1.UnimplementedLogsServiceServer
+	rule *Rule
+	w    *writer
+}
+
+func (ls *logsServer) Export(ctx context.Context, req *collogsv1.ExportLogsServiceRequest) (*collogsv1.ExportLogsServiceResponse, error) {
+	rejected, msg, err := ls.w.write(ctx, ls.rule, "logs", logEntries(ls.rule, req), false)
+	if err != nil {
+		return nil, err
+	}
+	resp := &collogsv1.ExportLogsServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &collogsv1.ExportLogsPartialSuccess{RejectedLogRecords: rejected, ErrorMessage: msg}
+	}
+	return resp, nil
+}
+
+// writer writes the entries by the client, which connects the gRPC server once the first signal arrives.
+type writer struct {
+	client *client.Client
+	l      *logger.Logger
+	addr   string
+	opts   []client.Option
+	mu     sync.Mutex
+}
+
+func (w *writer) connect() (*client.Client, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.client == nil {
+		c, err := client.Connect(w.addr, w.opts...)
+		if err != nil {
+			return nil, err
+		}
+		w.client = c
+	}
+	return w.client, nil
+}
+
+func (w *writer) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.client != nil {
+		_ = w.client.Close()
+	}
+}
+
+// write writes the entries to the measure of the rule if toMeasure is set, or to the stream of the rule otherwise.
+// It returns the number of the entries rejected along with the reason, which aren't retried by the exporter,
+// e.g. the ones which don't match the schema. The error is returned if the entries should be retried,
+// e.g. the server is unavailable.
+func (w *writer) write(ctx context.Context, rule *Rule, signal string, entries []entry, toMeasure bool) (int64, string, error) {
+	if len(entries) == 0 {
+		return 0, "", nil
+	}
+	if rule == nil {
+		return int64(len(entries)), "no rule maps the " + signal, nil
+	}
+	c, err := w.connect()
+	if err != nil {
+		return 0, "", status.Errorf(codes.Unavailable, "failed to connect %s: %v", w.addr, err)
+	}
+	if toMeasure {
+		dataPoints := make([]*client.DataPoint, 0, len(entries))
+		for _, e := range entries {
+			dp := client.NewDataPoint(e.timestamp).Field(rule.Field, e.value)
+			for name, v := range e.tags {
+				dp.Tag(name, v)
+			}
+			dataPoints = append(dataPoints, dp)
+		}
+		err = c.WriteMeasure(ctx, rule.Group, rule.Name, dataPoints...)
+	} else {
+		elements := make([]*client.Element, 0, len(entries))
+		for _, e := range entries {
+			element := client.NewElement(e.id, e.timestamp)
+			for name, v := range e.tags {
+				element.Tag(name, v)
+			}
+			elements = append(elements, element)
+		}
+		err = c.WriteStream(ctx, rule.Group, rule.Name, elements...)
+	}
+	if err == nil {
+		return 0, "", nil
+	}
+	var writeErr *client.WriteError
+	if errors.As(err, &writeErr) {
+		w.l.Warn().Err(err).Str("signal", signal).Msg("failed to write some of the signals")
+		return int64(len(writeErr.Failed)), err.Error(), nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return 0, "", err
+	}
+	// the entries are built against the schema, which fails if any of them doesn't match it
+	w.l.Warn().Err(err).Str("signal", signal).Msg("the signals don't match the schema")
+	return int64(len(entries)), err.Error(), nil
+}
@@ -0,0 +1,171 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package otlp implements the receiver of the OpenTelemetry protocol over gRPC, which writes the spans and the logs to
+// the streams, and the metrics to the measures by the mapping rules, without a collector in between.
+package otlp
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/pkg/errors"
+	collogsv1 "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricsv1 "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/pkg/client"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+var (
+	_ run.Config  = (*server)(nil)
+	_ run.Service = (*server)(nil)
+
+	errServerCert = errors.New("otlp: invalid server cert file")
+	errServerKey  = errors.New("otlp: invalid server key file")
+)
+
+// NewServer returns the OTLP receiver, which is disabled unless the mapping file is set.
+func NewServer() run.Unit {
+	return &server{
+		stopCh: make(chan struct{}),
+	}
+}
+
+type server struct {
+	creds       credentials.TransportCredentials
+	clientCreds credentials.TransportCredentials
+	l           *logger.Logger
+	ser         *grpclib.Server
+	w           *writer
+	mapping     *Mapping
+	stopCh      chan struct{}
+	host        string
+	addr        string
+	grpcAddr    string
+	certFile    string
+	keyFile     string
+	grpcCert    string
+	grpcToken   string
+	mappingFile string
+	port        uint32
+	tls         bool
+}
+
+func (s *server) FlagSet() *run.FlagSet {
+	fs := run.NewFlagSet("otlp")
+	fs.StringVar(&s.mappingFile, "otlp-mapping-file", "", "the YAML file of the rules mapping the OTLP signals to the streams and the measures, "+
+		"which enables the OTLP receiver")
+	fs.StringVar(&s.host, "otlp-grpc-host", "", "the host of the OTLP receiver listens")
+	fs.Uint32Var(&s.port, "otlp-grpc-port", 4317, "the port of the OTLP receiver listens")
+	fs.StringVar(&s.grpcAddr, "otlp-banyand-addr", "localhost:17912", "the address of the gRPC server the OTLP receiver writes to")
+	fs.StringVar(&s.grpcCert, "otlp-banyand-cert-file", "", "the TLS cert file of the gRPC server the OTLP receiver writes to if it enables TLS")
+	fs.StringVar(&s.grpcToken, "otlp-banyand-token", "", "the bearer token of the gRPC server the OTLP receiver writes to if it enables the authentication")
+	fs.BoolVar(&s.tls, "otlp-tls", false, "the OTLP receiver uses TLS if true")
+	fs.StringVar(&s.certFile, "otlp-cert-file", "", "the TLS cert file of the OTLP receiver")
+	fs.StringVar(&s.keyFile, "otlp-key-file", "", "the TLS key file of the OTLP receiver")
+	return fs
+}
+
+func (s *server) Validate() error {
+	if s.mappingFile == "" {
+		return nil
+	}
+	mapping, err := LoadMapping(s.mappingFile)
+	if err != nil {
+		return err
+	}
+	s.mapping = mapping
+	s.addr = net.JoinHostPort(s.host, strconv.FormatUint(uint64(s.port), 10))
+	if s.grpcCert != "" {
+		if s.clientCreds, err = credentials.NewClientTLSFromFile(s.grpcCert, ""); err != nil {
+			return errors.Wrap(err, "failed to load the grpc cert")
+		}
+	}
+	if !s.tls {
+		return nil
+	}
+	if s.certFile == "" {
+		return errServerCert
+	}
+	if s.keyFile == "" {
+		return errServerKey
+	}
+	if s.creds, err = credentials.NewServerTLSFromFile(s.certFile, s.keyFile); err != nil {
+		return errors.Wrap(err, "failed to load cert and key")
+	}
+	return nil
+}
+
+func (s *server) Name() string {
+	return "liaison-otlp"
+}
+
+func (s *server) Role() databasev1.Role {
+	return databasev1.Role_ROLE_LIAISON
+}
+
+func (s *server) Serve() run.StopNotify {
+	s.l = logger.GetLogger(s.Name())
+	if s.mapping == nil {
+		return s.stopCh
+	}
+	var opts []grpclib.ServerOption
+	if s.tls {
+		opts = append(opts, grpclib.Creds(s.creds))
+	}
+	creds := s.clientCreds
+	if creds == nil {
+		creds = insecure.NewCredentials()
+	}
+	s.w = &writer{l: s.l, addr: s.grpcAddr, opts: []client.Option{client.WithDialOptions(grpclib.WithTransportCredentials(creds))}}
+	if s.grpcToken != "" {
+		s.w.opts = append(s.w.opts, client.WithToken(s.grpcToken))
+	}
+	s.ser = grpclib.NewServer(opts...)
+	coltracev1.RegisterTraceServiceServer(s.ser, &traceServer{rule: s.mapping.Traces, w: s.w})
+	colmetricsv1.RegisterMetricsServiceServer(s.ser, &metricsServer{rule: s.mapping.Metrics, w: s.w})
+	collogsv1.RegisterLogsServiceServer(s.ser, &logsServer{rule: s.mapping.Logs, w: s.w})
+	go func() {
+		defer close(s.stopCh)
+		lis, err := net.Listen("tcp", s.addr)
+		if err != nil {
+			s.l.Error().Err(err).Msg("Failed to listen")
+			return
+		}
+		s.l.Info().Str("addr", s.addr).Msg("Listening to")
+		if err = s.ser.Serve(lis); err != nil {
+			s.l.Error().Err(err).Msg("server is interrupted")
+		}
+	}()
+	return s.stopCh
+}
+
+func (s *server) GracefulStop() {
+	if s.ser == nil {
+		close(s.stopCh)
+		return
+	}
+	s.ser.GracefulStop()
+	s.w.close()
+}
@@ -0,0 +1,254 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+var (
+	_ storage.Archiver      = (*tsTable)(nil)
+	_ storage.RangeArchiver = (*tsTable)(nil)
+	_ storage.PartImporter  = (*tsTable)(nil)
+)
+
+// Archive links the flushed parts into the dir, and writes the snapshot listing them.
+// The in-memory parts are left out, which are flushed in the flush timeout.
+func (tst *tsTable) Archive(dir string) (int, uint64, error) {
+	return tst.archive(dir, func(*partMetadata) bool {
+		return true
+	})
+}
+
+// ArchiveRange archives the flushed parts overlapping the time range like Archive.
+func (tst *tsTable) ArchiveRange(dir string, timeRange timestamp.TimeRange) (int, uint64, error) {
+	return tst.archive(dir, func(pm *partMetadata) bool {
+		return timeRange.Overlapping(timestamp.NewInclusiveTimeRange(time.Unix(0, pm.MinTimestamp), time.Unix(0, pm.MaxTimestamp)))
+	})
+}
+
+func (tst *tsTable) archive(dir string, filter func(pm *partMetadata) bool) (int, uint64, error) {
+	tst.fileSystem.MkdirIfNotExist(dir, dirPermission)
+	snp := tst.currentSnapshot()
+	if snp == nil {
+		return 0, 0, nil
+	}
+	defer snp.decRef()
+	var size uint64
+	var partNames []string
+	for _, pw := range snp.parts {
+		if pw.mp != nil || !filter(&pw.p.partMetadata) {
+			continue
+		}
+		n, err := tst.fileSystem.CreateHardLink(partPath(tst.root, pw.ID()), partPath(dir, pw.ID()), nil)
+		if err != nil {
+			return 0, 0, err
+		}
+		size += n
+		partNames = append(partNames, partName(pw.ID()))
+	}
+	if len(partNames) == 0 {
+		return 0, 0, nil
+	}
+	data, err := json.Marshal(partNames)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err = tst.fileSystem.Write(data, filepath.Join(dir, snapshotName(snp.epoch)), filePermission); err != nil {
+		return 0, 0, err
+	}
+	return len(partNames), size, nil
+}
+
+// ImportParts links the parts listed by the snapshot in the dir into the table with new IDs.
+// A part with a larger ID wins over the others at the same timestamp of a series,
+// so the imported data points replace the ones the table has.
+func (tst *tsTable) ImportParts(dir string) (int, uint64, error) {
+	partNames, err := readArchivedParts(tst.fileSystem, dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	var size uint64
+	for i, name := range partNames {
+		src := filepath.Join(dir, name)
+		if err = validatePartMetadata(tst.fileSystem, src); err != nil {
+			return i, size, errors.WithMessagef(err, "invalid part %s", src)
+		}
+		id := atomic.AddUint64(&tst.curPartID, 1)
+		n, errLink := tst.fileSystem.CreateHardLink(src, partPath(tst.root, id), nil)
+		if errLink != nil {
+			return i, size, errLink
+		}
+		size += n
+		p := mustOpenFilePart(id, tst.root, tst.fileSystem)
+		p.partMetadata.ID = id
+		ind := generateIntroduction()
+		ind.memPart = newPartWrapper(nil, p)
+		ind.applied = make(chan struct{})
+		select {
+		case tst.imports <- ind:
+		case <-tst.loopCloser.CloseNotify():
+			releaseIntroduction(ind)
+			return i, size, errors.New("the table is closed")
+		}
+		<-ind.applied
+		releaseIntroduction(ind)
+	}
+	return len(partNames), size, nil
+}
+
+// readArchivedParts returns the parts listed by the snapshot Archive writes in the dir.
+func readArchivedParts(fileSystem fs.FileSystem, dir string) ([]string, error) {
+	for _, e := range fileSystem.ReadDir(dir) {
+		if e.IsDir() || filepath.Ext(e.Name()) != snapshotSuffix {
+			continue
+		}
+		data, err := fileSystem.Read(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var partNames []string
+		if err = json.Unmarshal(data, &partNames); err != nil {
+			return nil, errors.WithMessagef(err, "cannot parse %s", e.Name())
+		}
+		return partNames, nil
+	}
+	return nil, nil
+}
+
+// Archive writes the flushed data of the groups into the dir, one subdirectory for each group.
+func (s *service) Archive(dir string, groups []string) ([]*databasev1.SnapshotArchive, error) {
+	var result []*databasev1.SnapshotArchive
+	for _, g := range s.schemaRepo.LoadAllGroups() {
+		name := g.GetSchema().GetMetadata().GetName()
+		if len(groups) > 0 && !slices.Contains(groups, name) {
+			continue
+		}
+		tsdb, ok := g.SupplyTSDB().(storage.TSDB[*tsTable, option])
+		if !ok {
+			continue
+		}
+		analyzers, err := s.indexRuleAnalyzers(name)
+		if err != nil {
+			return nil, err
+		}
+		stats, err := tsdb.Archive(filepath.Join(dir, name), func(indexRuleID uint32) databasev1.IndexRule_Analyzer {
+			return analyzers[indexRuleID]
+		})
+		if err != nil {
+			return nil, errors.WithMessagef(err, "cannot archive group %s", name)
+		}
+		result = append(result, stats.ToArchive(name))
+	}
+	return result, nil
+}
+
+// indexRuleAnalyzers returns the analyzers of the index rules in a group, which restore the indexed tags of the series.
+func (s *service) indexRuleAnalyzers(group string) (map[uint32]databasev1.IndexRule_Analyzer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rules, err := s.metadata.IndexRuleRegistry().ListIndexRule(ctx, schema.ListOpt{Group: group})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "cannot list the index rules of group %s", group)
+	}
+	result := make(map[uint32]databasev1.IndexRule_Analyzer, len(rules))
+	for _, r := range rules {
+		result[r.GetMetadata().GetId()] = r.GetAnalyzer()
+	}
+	return result, nil
+}
+
+// Import registers the segments of the groups archived in the dir, all archived groups if groups is empty.
+func (s *service) Import(dir string, groups []string) ([]*databasev1.SnapshotArchive, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result []*databasev1.SnapshotArchive
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || (len(groups) > 0 && !slices.Contains(groups, name)) {
+			continue
+		}
+		g, ok := s.schemaRepo.LoadGroup(name)
+		if !ok {
+			return nil, errors.Errorf("group %s doesn't exist, create it before importing", name)
+		}
+		tsdb, ok := g.SupplyTSDB().(storage.TSDB[*tsTable, option])
+		if !ok {
+			return nil, errors.Errorf("group %s isn't a measure group", name)
+		}
+		stats, errImport := tsdb.Import(filepath.Join(dir, name))
+		if errImport != nil {
+			return nil, errors.WithMessagef(errImport, "cannot import group %s", name)
+		}
+		result = append(result, stats.ToArchive(name))
+	}
+	return result, nil
+}
+
+// ArchiveShard writes the flushed data of a shard of the group overlapping the time range into the dir.
+func (s *service) ArchiveShard(dir, group string, shardID common.ShardID, timeRange timestamp.TimeRange) (*databasev1.SnapshotArchive, error) {
+	tsdb, err := s.schemaRepo.loadTSDB(group)
+	if err != nil {
+		return nil, err
+	}
+	analyzers, err := s.indexRuleAnalyzers(group)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := tsdb.ArchiveShard(dir, shardID, timeRange, func(indexRuleID uint32) databasev1.IndexRule_Analyzer {
+		return analyzers[indexRuleID]
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "cannot archive shard %d of group %s", shardID, group)
+	}
+	return stats.ToArchive(group), nil
+}
+
+// Merge imports the data archived by ArchiveShard on another node into the group.
+// The archived parts are added to the segments the group has.
+func (s *service) Merge(dir, group string) (*databasev1.SnapshotArchive, error) {
+	tsdb, err := s.schemaRepo.loadTSDB(group)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := tsdb.Merge(dir)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "cannot merge the archive into group %s", group)
+	}
+	return stats.ToArchive(group), nil
+}
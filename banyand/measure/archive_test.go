@@ -0,0 +1,90 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/test/flags"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func Test_tsTable_ImportParts(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	fileSystem := fs.NewLocalFileSystem()
+	openTable := func(root string) *tsTable {
+		tst, err := newTSTable(fileSystem, root, common.Position{Database: "default"}, logger.GetLogger("test"),
+			timestamp.TimeRange{}, option{flushTimeout: 0, mergePolicy: newDefaultMergePolicyForTesting()})
+		require.NoError(t, err)
+		return tst
+	}
+	addFlushed := func(tst *tsTable, dps *dataPoints) {
+		tst.mustAddDataPoints(dps)
+		require.Eventually(t, func() bool {
+			snp := tst.currentSnapshot()
+			if snp == nil {
+				return false
+			}
+			defer snp.decRef()
+			return snp.memPartCount() == 0
+		}, flags.EventuallyTimeout, 100*time.Millisecond, "the data points are flushed")
+	}
+	maxTimestamps := func(tst *tsTable) []int64 {
+		snp := tst.currentSnapshot()
+		require.NotNil(t, snp)
+		defer snp.decRef()
+		var result []int64
+		for _, pw := range snp.parts {
+			result = append(result, pw.p.partMetadata.MaxTimestamp)
+		}
+		return result
+	}
+
+	src := openTable(filepath.Join(tmpPath, "src"))
+	defer src.Close()
+	addFlushed(src, dpsTS1)
+	addFlushed(src, dpsTS2)
+	archived := filepath.Join(tmpPath, "archived")
+	parts, size, err := src.ArchiveRange(archived, timestamp.NewInclusiveTimeRange(time.Unix(0, 2), time.Unix(0, 2)))
+	require.NoError(t, err)
+	require.Positive(t, parts)
+	assert.Greater(t, size, uint64(0))
+
+	dstRoot := filepath.Join(tmpPath, "dst")
+	dst := openTable(dstRoot)
+	addFlushed(dst, dpsTS1)
+	imported, _, err := dst.ImportParts(archived)
+	require.NoError(t, err)
+	assert.Equal(t, parts, imported)
+	assert.Contains(t, maxTimestamps(dst), int64(2), "the missed data points are imported")
+	require.NoError(t, dst.Close())
+
+	dst = openTable(dstRoot)
+	defer dst.Close()
+	assert.Contains(t, maxTimestamps(dst), int64(2), "the imported parts are persisted in the snapshot")
+}
@@ -18,6 +18,8 @@
 package measure
 
 import (
+	"context"
+	"slices"
 	"sort"
 	"sync"
 
@@ -146,7 +148,7 @@ func (b *block) mustWriteTo(sid common.SeriesID, bm *blockMetadata, ww *writers)
 	cc := f.columns
 	cmm := bm.field.resizeColumnMetadata(len(cc))
 	for i := range cc {
-		cc[i].mustWriteTo(&cmm[i], &ww.fieldValuesWriter)
+		cc[i].mustWriteFieldTo(&cmm[i], &ww.fieldValuesWriter)
 	}
 }
 
@@ -184,7 +186,7 @@ func (b *block) marshalTagFamily(tf columnFamily, bm *blockMetadata, ww *writers
 	for i := range cc {
 		cc[i].mustWriteTo(&cmm[i], w)
 	}
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(0)
 	defer bigValuePool.Release(bb)
 	bb.Buf = cfm.marshal(bb.Buf)
 	releaseColumnFamilyMetadata(cfm)
@@ -203,7 +205,7 @@ func (b *block) unmarshalTagFamily(decoder *encoding.BytesBlockDecoder, tfIndex
 	if len(tagProjection) < 1 {
 		return
 	}
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(int(columnFamilyMetadataBlock.size))
 	bb.Buf = bytes.ResizeExact(bb.Buf, int(columnFamilyMetadataBlock.size))
 	fs.MustReadData(metaReader, int64(columnFamilyMetadataBlock.offset), bb.Buf)
 	cfm := generateColumnFamilyMetadata()
@@ -235,7 +237,7 @@ func (b *block) unmarshalTagFamilyFromSeqReaders(decoder *encoding.BytesBlockDec
 	if columnFamilyMetadataBlock.offset != metaReader.bytesRead {
 		logger.Panicf("offset %d must be equal to bytesRead %d", columnFamilyMetadataBlock.offset, metaReader.bytesRead)
 	}
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(int(columnFamilyMetadataBlock.size))
 	bb.Buf = bytes.ResizeExact(bb.Buf, int(columnFamilyMetadataBlock.size))
 	metaReader.mustReadFull(bb.Buf)
 	cfm := generateColumnFamilyMetadata()
@@ -340,7 +342,7 @@ func (b *block) sortTagFamilies() {
 func mustWriteTimestampsTo(tm *timestampsMetadata, timestamps []int64, timestampsWriter *writer) {
 	tm.reset()
 
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(len(timestamps) * 8)
 	defer bigValuePool.Release(bb)
 	bb.Buf, tm.encodeType, tm.min = encoding.Int64ListToBytes(bb.Buf[:0], timestamps)
 	if len(bb.Buf) > maxTimestampsBlockSize {
@@ -353,7 +355,7 @@ func mustWriteTimestampsTo(tm *timestampsMetadata, timestamps []int64, timestamp
 }
 
 func mustReadTimestampsFrom(dst []int64, tm *timestampsMetadata, count int, reader fs.Reader) []int64 {
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(int(tm.size))
 	defer bigValuePool.Release(bb)
 	bb.Buf = bytes.ResizeExact(bb.Buf, int(tm.size))
 	fs.MustReadData(reader, int64(tm.offset), bb.Buf)
@@ -369,7 +371,7 @@ func mustSeqReadTimestampsFrom(dst []int64, tm *timestampsMetadata, count int, r
 	if tm.offset != reader.bytesRead {
 		logger.Panicf("offset %d must be equal to bytesRead %d", tm.offset, reader.bytesRead)
 	}
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(int(tm.size))
 	defer bigValuePool.Release(bb)
 	bb.Buf = bytes.ResizeExact(bb.Buf, int(tm.size))
 	reader.mustReadFull(bb.Buf)
@@ -391,6 +393,9 @@ func generateBlock() *block {
 
 func releaseBlock(b *block) {
 	b.reset()
+	if !blockCapacity.Retain(b.capacity()) {
+		return
+	}
 	blockPool.Put(b)
 }
 
@@ -602,7 +607,79 @@ func (bc *blockCursor) copyTo(r *pbv1.MeasureResult, entityValuesAll map[common.
 	}
 }
 
-func (bc *blockCursor) loadData(tmpBlock *block) bool {
+// skipBefore moves idx past the data points at or before the position. It returns false if none is left.
+func (bc *blockCursor) skipBefore(c *pbv1.Cursor, desc bool) bool {
+	ts := bc.timestamps
+	// the data point of the series at the timestamp of the position is returned already if the offset isn't zero
+	skipAt := bc.bm.seriesID < c.SeriesID || bc.bm.seriesID == c.SeriesID && c.Offset > 0
+	if desc {
+		n := sort.Search(len(ts), func(i int) bool { return ts[i] > c.Timestamp || ts[i] == c.Timestamp && skipAt })
+		bc.idx = min(bc.idx, n-1)
+		return bc.idx >= 0
+	}
+	bc.idx = max(bc.idx, sort.Search(len(ts), func(i int) bool { return ts[i] > c.Timestamp || ts[i] == c.Timestamp && !skipAt }))
+	return bc.idx < len(ts)
+}
+
+// readSize returns the compressed bytes loadData read, which are the timestamps, the projected fields, and the metadata
+// and the values of the projected tags. The metadata of the tag families is read again to tell the sizes of the values,
+// so it's only called by the traced queries.
+func (bc *blockCursor) readSize() (int64, error) {
+	size := int64(bc.bm.timestamps.size)
+	for i := range bc.bm.field.columnMetadata {
+		size += int64(bc.bm.field.columnMetadata[i].size)
+	}
+	cfm := generateColumnFamilyMetadata()
+	defer releaseColumnFamilyMetadata(cfm)
+	for _, tp := range bc.bm.tagProjection {
+		block, ok := bc.bm.tagFamilies[tp.Family]
+		if !ok {
+			continue
+		}
+		bb := bigValuePool.Acquire(int(block.size))
+		bb.Buf = bytes.ResizeExact(bb.Buf, int(block.size))
+		err := fs.ReadData(bc.p.tagFamilyMetadata[tp.Family], int64(block.offset), bb.Buf)
+		if err == nil {
+			cfm.reset()
+			_, err = cfm.unmarshal(bb.Buf)
+		}
+		bigValuePool.Release(bb)
+		if err != nil {
+			return size, err
+		}
+		size += int64(block.size)
+		for i := range cfm.columnMetadata {
+			if slices.Contains(tp.Names, cfm.columnMetadata[i].name) {
+				size += int64(cfm.columnMetadata[i].size)
+			}
+		}
+	}
+	return size, nil
+}
+
+// memSize estimates the bytes the decoded rows of the block hold.
+func (bc *blockCursor) memSize() int64 {
+	size := 8 * len(bc.timestamps)
+	for _, c := range bc.fields.columns {
+		for _, v := range c.values {
+			size += len(v)
+		}
+	}
+	for _, cf := range bc.tagFamilies {
+		for _, c := range cf.columns {
+			for _, v := range c.values {
+				size += len(v)
+			}
+		}
+	}
+	return int64(size)
+}
+
+func (bc *blockCursor) loadData(ctx context.Context, tmpBlock *block) bool {
+	// a canceled query reads no more blocks
+	if ctx.Err() != nil {
+		return false
+	}
 	tmpBlock.reset()
 	cfm := make([]columnMetadata, 0, len(bc.fieldProjection))
 	for j := range bc.fieldProjection {
@@ -683,6 +760,9 @@ func generateBlockCursor() *blockCursor {
 
 func releaseBlockCursor(bc *blockCursor) {
 	bc.reset()
+	if !blockCursorCapacity.Retain(bc.capacity()) {
+		return
+	}
 	blockCursorPool.Put(bc)
 }
 
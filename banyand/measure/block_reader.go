@@ -49,8 +49,13 @@ func (sr *seqReader) Path() string {
 }
 
 func (sr *seqReader) init(r fs.Reader) {
+	sr.initWithOptions(r, fs.IOOptions{})
+}
+
+// initWithOptions is init reading r with the ioOpts.
+func (sr *seqReader) initWithOptions(r fs.Reader, ioOpts fs.IOOptions) {
 	sr.reset()
-	sr.sr = r.SequentialRead()
+	sr.sr = fs.SequentialRead(r, ioOpts)
 	sr.r = r
 }
 
@@ -109,10 +114,15 @@ func (sr *seqReaders) reset() {
 }
 
 func (sr *seqReaders) init(p *part) {
+	sr.initWithOptions(p, fs.IOOptions{})
+}
+
+// initWithOptions is init reading the files of p with the ioOpts.
+func (sr *seqReaders) initWithOptions(p *part, ioOpts fs.IOOptions) {
 	sr.reset()
-	sr.primary.init(p.primary)
-	sr.timestamps.init(p.timestamps)
-	sr.fieldValues.init(p.fieldValues)
+	sr.primary.initWithOptions(p.primary, ioOpts)
+	sr.timestamps.initWithOptions(p.timestamps, ioOpts)
+	sr.fieldValues.initWithOptions(p.fieldValues, ioOpts)
 	if sr.tagFamilies == nil {
 		sr.tagFamilies = make(map[string]*seqReader)
 		sr.tagFamilyMetadata = make(map[string]*seqReader)
@@ -120,9 +130,9 @@ func (sr *seqReaders) init(p *part) {
 
 	for k, r := range p.tagFamilies {
 		sr.tagFamilies[k] = generateSeqReader()
-		sr.tagFamilies[k].init(r)
+		sr.tagFamilies[k].initWithOptions(r, ioOpts)
 		sr.tagFamilyMetadata[k] = generateSeqReader()
-		sr.tagFamilyMetadata[k].init(p.tagFamilyMetadata[k])
+		sr.tagFamilyMetadata[k].initWithOptions(p.tagFamilyMetadata[k], ioOpts)
 	}
 }
 
@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/pkg/bytes"
@@ -775,3 +776,35 @@ func Test_blockPointer_copyFrom(t *testing.T) {
 		})
 	}
 }
+
+func Test_blockCursor_skipBefore(t *testing.T) {
+	tests := []struct {
+		name    string
+		sid     common.SeriesID
+		offset  uint32
+		wantIdx int
+		want    bool
+		desc    bool
+	}{
+		{name: "lower series", sid: 1, wantIdx: 3, want: true},
+		{name: "same series returned", sid: 2, offset: 1, wantIdx: 3, want: true},
+		{name: "same series", sid: 2, wantIdx: 2, want: true},
+		{name: "higher series", sid: 3, offset: 1, wantIdx: 2, want: true},
+		{name: "desc lower series", sid: 1, desc: true, wantIdx: 1, want: true},
+		{name: "desc higher series", sid: 3, desc: true, wantIdx: 2, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := &blockCursor{bm: blockMetadata{seriesID: tt.sid}, timestamps: []int64{0, 1, 2, 3}}
+			if tt.desc {
+				bc.idx = len(bc.timestamps) - 1
+			}
+			assert.Equal(t, tt.want, bc.skipBefore(&pbv1.Cursor{Timestamp: 2, SeriesID: 2, Offset: tt.offset}, tt.desc))
+			assert.Equal(t, tt.wantIdx, bc.idx)
+		})
+	}
+	bc := &blockCursor{bm: blockMetadata{seriesID: 1}, timestamps: []int64{0, 1, 2}}
+	assert.False(t, bc.skipBefore(&pbv1.Cursor{Timestamp: 2, SeriesID: 2}, false))
+	bc = &blockCursor{bm: blockMetadata{seriesID: 1}, timestamps: []int64{2, 3}, idx: 1}
+	assert.False(t, bc.skipBefore(&pbv1.Cursor{Timestamp: 2, SeriesID: 2}, true))
+}
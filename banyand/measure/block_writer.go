@@ -40,10 +40,15 @@ func (w *writer) reset() {
 }
 
 func (w *writer) init(wc fs.Writer) {
+	w.initWithOptions(wc, fs.IOOptions{})
+}
+
+// initWithOptions is init writing wc with the ioOpts.
+func (w *writer) initWithOptions(wc fs.Writer, ioOpts fs.IOOptions) {
 	w.reset()
 
 	w.w = wc
-	w.sw = wc.SequentialWrite()
+	w.sw = fs.SequentialWrite(wc, ioOpts)
 }
 
 func (w *writer) MustWrite(data []byte) {
@@ -67,10 +72,13 @@ type writers struct {
 	tagFamilyWriters           map[string]*writer
 	timestampsWriter           writer
 	fieldValuesWriter          writer
+	// ioOpts hints how the files of a file part are written, which are the zero value for a mem part.
+	ioOpts fs.IOOptions
 }
 
 func (sw *writers) reset() {
 	sw.mustCreateTagFamilyWriters = nil
+	sw.ioOpts = fs.IOOptions{}
 	sw.metaWriter.reset()
 	sw.primaryWriter.reset()
 	sw.timestampsWriter.reset()
@@ -120,9 +128,9 @@ func (sw *writers) getColumnMetadataWriterAndColumnWriter(columnName string) (*w
 	}
 	hw, w := sw.mustCreateTagFamilyWriters(columnName)
 	chw = new(writer)
-	chw.init(hw)
+	chw.initWithOptions(hw, sw.ioOpts)
 	cw = new(writer)
-	cw.init(w)
+	cw.initWithOptions(w, sw.ioOpts)
 	sw.tagFamilyMetadataWriters[columnName] = chw
 	sw.tagFamilyWriters[columnName] = cw
 	return chw, cw
@@ -173,17 +181,19 @@ func (bw *blockWriter) MustInitForMemPart(mp *memPart) {
 	bw.writers.fieldValuesWriter.init(&mp.fieldValues)
 }
 
-func (bw *blockWriter) mustInitForFilePart(fileSystem fs.FileSystem, path string) {
+// mustInitForFilePart writes the blocks to the files of a new part at path with the ioOpts.
+func (bw *blockWriter) mustInitForFilePart(fileSystem fs.FileSystem, path string, ioOpts fs.IOOptions) {
 	bw.reset()
+	bw.writers.ioOpts = ioOpts
 	fileSystem.MkdirPanicIfExist(path, dirPermission)
 	bw.writers.mustCreateTagFamilyWriters = func(name string) (fs.Writer, fs.Writer) {
 		return fs.MustCreateFile(fileSystem, filepath.Join(path, name+tagFamiliesMetadataFilenameExt), filePermission),
 			fs.MustCreateFile(fileSystem, filepath.Join(path, name+tagFamiliesFilenameExt), filePermission)
 	}
-	bw.writers.metaWriter.init(fs.MustCreateFile(fileSystem, filepath.Join(path, metaFilename), filePermission))
-	bw.writers.primaryWriter.init(fs.MustCreateFile(fileSystem, filepath.Join(path, primaryFilename), filePermission))
-	bw.writers.timestampsWriter.init(fs.MustCreateFile(fileSystem, filepath.Join(path, timestampsFilename), filePermission))
-	bw.writers.fieldValuesWriter.init(fs.MustCreateFile(fileSystem, filepath.Join(path, fieldValuesFilename), filePermission))
+	bw.writers.metaWriter.initWithOptions(fs.MustCreateFile(fileSystem, filepath.Join(path, metaFilename), filePermission), ioOpts)
+	bw.writers.primaryWriter.initWithOptions(fs.MustCreateFile(fileSystem, filepath.Join(path, primaryFilename), filePermission), ioOpts)
+	bw.writers.timestampsWriter.initWithOptions(fs.MustCreateFile(fileSystem, filepath.Join(path, timestampsFilename), filePermission), ioOpts)
+	bw.writers.fieldValuesWriter.initWithOptions(fs.MustCreateFile(fileSystem, filepath.Join(path, fieldValuesFilename), filePermission), ioOpts)
 }
 
 func (bw *blockWriter) MustWriteDataPoints(sid common.SeriesID, timestamps []int64, tagFamilies [][]nameValues, fields []nameValues) {
@@ -264,7 +274,7 @@ func (bw *blockWriter) Flush(pm *partMetadata) {
 
 	bw.mustFlushPrimaryBlock(bw.primaryBlockData)
 
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(len(bw.metaData))
 	bb.Buf = zstd.Compress(bb.Buf[:0], bw.metaData, 1)
 	bw.writers.metaWriter.MustWrite(bb.Buf)
 	bigValuePool.Release(bb)
@@ -0,0 +1,326 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+const defaultMaxChangedDataPoints = 1000
+
+type changesCallback struct {
+	l          *logger.Logger
+	schemaRepo *schemaRepo
+}
+
+func setUpChangesCallback(l *logger.Logger, schemaRepo *schemaRepo) bus.MessageListener {
+	return &changesCallback{
+		l:          l,
+		schemaRepo: schemaRepo,
+	}
+}
+
+func (c *changesCallback) Rev(message bus.Message) (resp bus.Message) {
+	req, ok := message.Data().(*measurev1.ChangesRequest)
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("invalid event data type"))
+	}
+	tsdb, err := c.schemaRepo.loadTSDB(req.GetGroup())
+	if err != nil {
+		return bus.NewMessage(message.ID(), common.NewError("cannot load the group %s: %v", req.GetGroup(), err))
+	}
+	measures := make([]*measure, 0, len(req.GetNames()))
+	for _, name := range req.GetNames() {
+		m, ok := c.schemaRepo.loadMeasure(&commonv1.Metadata{Group: req.GetGroup(), Name: name})
+		if !ok {
+			return bus.NewMessage(message.ID(), common.NewError("measure %s/%s doesn't exist", req.GetGroup(), name))
+		}
+		measures = append(measures, m)
+	}
+	result, err := readChanges(message.Context(), tsdb, measures, req)
+	if err != nil {
+		c.l.Error().Err(err).Str("group", req.GetGroup()).Strs("measures", req.GetNames()).Msg("cannot read the changes")
+		return bus.NewMessage(message.ID(), common.NewError("cannot read the changes of the group %s: %v", req.GetGroup(), err))
+	}
+	return bus.NewMessage(message.ID(), result)
+}
+
+// changedSeries is a series of a subscribed measure, whose data points are read from the blocks by the projection.
+type changedSeries struct {
+	measure    *measure
+	series     *pbv1.Series
+	metadata   *commonv1.Metadata
+	projection []pbv1.TagProjection
+}
+
+// changeReader reads the data points of the subscribed series from the flushed parts, until it reaches the limit.
+type changeReader struct {
+	series     map[common.SeriesID]*changedSeries
+	result     *measurev1.ChangesResponse
+	sids       []common.SeriesID
+	decoder    encoding.BytesBlockDecoder
+	limit      int
+	dataPoints int
+}
+
+func readChanges(ctx context.Context, tsdb storage.TSDB[*tsTable, option], measures []*measure,
+	req *measurev1.ChangesRequest,
+) (*measurev1.ChangesResponse, error) {
+	cr := &changeReader{
+		series: make(map[common.SeriesID]*changedSeries),
+		result: &measurev1.ChangesResponse{},
+		limit:  int(req.GetMaxDataPoints()),
+	}
+	if cr.limit <= 0 {
+		cr.limit = defaultMaxChangedDataPoints
+	}
+	for _, m := range measures {
+		entity := make([]*modelv1.TagValue, len(m.schema.GetEntity().GetTagNames()))
+		for i := range entity {
+			entity[i] = pbv1.AnyTagValue
+		}
+		sl, err := tsdb.Lookup(ctx, &pbv1.Series{Subject: m.name, EntityValues: entity})
+		if err != nil {
+			return nil, errors.WithMessagef(err, "cannot look up the series of the measure %s", m.name)
+		}
+		metadata := &commonv1.Metadata{Group: m.group, Name: m.name}
+		projection := m.storedTagProjection()
+		for i := range sl {
+			cr.series[sl[i].ID] = &changedSeries{measure: m, series: sl[i], metadata: metadata, projection: projection}
+			cr.sids = append(cr.sids, sl[i].ID)
+		}
+	}
+	slices.Sort(cr.sids)
+	positions := make(map[string]*modelv1.ChangePosition, len(req.GetPositions()))
+	for _, pos := range req.GetPositions() {
+		positions[pos.GetTable()] = pos
+	}
+	tabWrappers := tsdb.SelectTSTables(timestamp.NewInclusiveTimeRange(time.Unix(0, 0), time.Unix(0, math.MaxInt64)))
+	defer func() {
+		for i := range tabWrappers {
+			tabWrappers[i].DecRef()
+		}
+	}()
+	for _, tw := range tabWrappers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := cr.readTable(tw.Table(), positions, req.GetLatest()); err != nil {
+			return nil, errors.WithMessagef(err, "cannot read the changes of the segment %s", tw.GetTimeRange())
+		}
+	}
+	return cr.result, nil
+}
+
+// readTable reads the flushed parts of the table after its position, and appends the position after them to the result.
+// The parts are read in the order of their flushes. The position expires if a part merges the data points read with the ones not,
+// or the parts not read overlap, which tells the subscription falls too far behind the merges.
+func (cr *changeReader) readTable(tst *tsTable, positions map[string]*modelv1.ChangePosition, latest bool) error {
+	key := tst.p.Shard + "/" + tst.p.Segment
+	snp := tst.currentSnapshot()
+	if snp != nil {
+		defer snp.decRef()
+	}
+	pos, ok := positions[key]
+	if !ok {
+		pos = &modelv1.ChangePosition{Table: key}
+		if latest && snp != nil {
+			for _, pw := range snp.parts {
+				if pw.mp == nil {
+					pos.FlushSeq = max(pos.FlushSeq, pw.p.partMetadata.FlushSeq)
+				}
+			}
+		}
+	}
+	if snp == nil || cr.dataPoints >= cr.limit {
+		cr.result.Positions = append(cr.result.Positions, pos)
+		return nil
+	}
+	var parts []*partWrapper
+	for _, pw := range snp.parts {
+		if pw.mp == nil && pw.p.partMetadata.FlushSeq > pos.GetFlushSeq() {
+			parts = append(parts, pw)
+		}
+	}
+	slices.SortFunc(parts, func(a, b *partWrapper) int {
+		return cmp.Compare(a.p.partMetadata.MinFlushSeq, b.p.partMetadata.MinFlushSeq)
+	})
+	if expired(parts, pos) {
+		cr.result.Positions = append(cr.result.Positions, pos)
+		cr.result.Expired = append(cr.result.Expired, key)
+		return nil
+	}
+	for _, pw := range parts {
+		done, err := cr.readPart(pw.p, pos)
+		if err != nil {
+			return errors.WithMessagef(err, "cannot read the part %d", pw.ID())
+		}
+		if !done {
+			break
+		}
+		pos = &modelv1.ChangePosition{Table: key, FlushSeq: pw.p.partMetadata.FlushSeq}
+	}
+	cr.result.Positions = append(cr.result.Positions, pos)
+	return nil
+}
+
+// expired tells whether the parts sorted by their flushes can't be read after the position.
+func expired(parts []*partWrapper, pos *modelv1.ChangePosition) bool {
+	for i, pw := range parts {
+		pm := &pw.p.partMetadata
+		if pm.MinFlushSeq <= pos.GetFlushSeq() {
+			return true
+		}
+		if i+1 < len(parts) && parts[i+1].p.partMetadata.MinFlushSeq <= pm.FlushSeq {
+			return true
+		}
+	}
+	return pos.GetPartFlushSeq() != 0 && (len(parts) == 0 || parts[0].p.partMetadata.FlushSeq != pos.GetPartFlushSeq())
+}
+
+// readPart reads the blocks of the part after the position, and tells whether the part is read through.
+// Otherwise, it records how far the part is read in the position.
+func (cr *changeReader) readPart(p *part, pos *modelv1.ChangePosition) (bool, error) {
+	resumed := pos.GetPartFlushSeq() != 0
+	sids := cr.sids
+	if resumed {
+		i, _ := slices.BinarySearch(sids, common.SeriesID(pos.GetSeriesId()))
+		sids = sids[i:]
+	}
+	var pi partIter
+	pi.init(p, sids, math.MinInt64, math.MaxInt64)
+	b := generateBlock()
+	defer releaseBlock(b)
+	var sid common.SeriesID
+	var blocks uint64
+	for pi.nextBlock() {
+		bm := pi.curBlock
+		if bm.seriesID != sid {
+			sid, blocks = bm.seriesID, 0
+		}
+		blocks++
+		if resumed && uint64(sid) == pos.GetSeriesId() && blocks <= pos.GetBlocks() {
+			continue
+		}
+		cs := cr.series[bm.seriesID]
+		bm.tagProjection = cs.projection
+		cr.decoder.Reset()
+		b.mustReadFrom(&cr.decoder, p, bm)
+		for idx := range b.timestamps {
+			cr.result.DataPoints = append(cr.result.DataPoints, &measurev1.SubscribedDataPoint{
+				Metadata:  cs.metadata,
+				DataPoint: cs.dataPoint(b, idx),
+			})
+		}
+		cr.dataPoints += len(b.timestamps)
+		if cr.dataPoints >= cr.limit {
+			pos.PartFlushSeq = p.partMetadata.FlushSeq
+			pos.SeriesId, pos.Blocks = uint64(sid), blocks
+			return false, nil
+		}
+	}
+	return true, pi.error()
+}
+
+// dataPoint builds the data point at idx of the block with all the tags and the fields of the schema.
+// The entity tags are taken from the series, and the tags and the fields absent from the block are nulls.
+func (cs *changedSeries) dataPoint(b *block, idx int) *measurev1.DataPoint {
+	dp := &measurev1.DataPoint{
+		Timestamp: timestamppb.New(time.Unix(0, b.timestamps[idx])),
+	}
+	entity := cs.measure.schema.GetEntity().GetTagNames()
+	for _, family := range cs.measure.schema.GetTagFamilies() {
+		tf := &modelv1.TagFamily{Name: family.GetName()}
+		for _, spec := range family.GetTags() {
+			value := pbv1.NullTagValue
+			if i := slices.Index(entity, spec.GetName()); i >= 0 {
+				if i < len(cs.series.EntityValues) {
+					value = cs.series.EntityValues[i]
+				}
+			} else if c := blockColumn(b.tagFamilies, family.GetName(), spec.GetName()); c != nil && idx < len(c.values) {
+				value = mustDecodeTagValue(c.valueType, c.values[idx])
+			}
+			tf.Tags = append(tf.Tags, &modelv1.Tag{Key: spec.GetName(), Value: value})
+		}
+		dp.TagFamilies = append(dp.TagFamilies, tf)
+	}
+	for _, spec := range cs.measure.schema.GetFields() {
+		value := pbv1.NullFieldValue
+		for i := range b.field.columns {
+			if c := &b.field.columns[i]; c.name == spec.GetName() && idx < len(c.values) {
+				value = mustDecodeFieldValue(c.valueType, c.values[idx])
+				break
+			}
+		}
+		dp.Fields = append(dp.Fields, &measurev1.DataPoint_Field{Name: spec.GetName(), Value: value})
+	}
+	return dp
+}
+
+// blockColumn returns the tag of the block read by the projection, or nil if it's absent.
+func blockColumn(tagFamilies []columnFamily, family, name string) *column {
+	for i := range tagFamilies {
+		if tagFamilies[i].name != family {
+			continue
+		}
+		for j := range tagFamilies[i].columns {
+			if tagFamilies[i].columns[j].name == name {
+				return &tagFamilies[i].columns[j]
+			}
+		}
+	}
+	return nil
+}
+
+// storedTagProjection returns the projection of the tags stored in the blocks,
+// which excludes the entity tags and the tags only indexed.
+func (m *measure) storedTagProjection() []pbv1.TagProjection {
+	entity := m.schema.GetEntity().GetTagNames()
+	var projection []pbv1.TagProjection
+	for _, family := range m.schema.GetTagFamilies() {
+		tp := pbv1.TagProjection{Family: family.GetName()}
+		for _, spec := range family.GetTags() {
+			if spec.GetIndexedOnly() || slices.Contains(entity, spec.GetName()) {
+				continue
+			}
+			tp.Names = append(tp.Names, spec.GetName())
+		}
+		if len(tp.Names) > 0 {
+			projection = append(projection, tp)
+		}
+	}
+	return projection
+}
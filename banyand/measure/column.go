@@ -18,7 +18,11 @@
 package measure
 
 import (
+	"encoding/binary"
+	"fmt"
+
 	"github.com/apache/skywalking-banyandb/pkg/bytes"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
@@ -51,32 +55,123 @@ func (c *column) resizeValues(valuesLen int) [][]byte {
 	return values
 }
 
+// fieldValuesSampleSize is the number of the leading values sampled to pick the encoding of a field.
+const fieldValuesSampleSize = 128
+
 func (c *column) mustWriteTo(ch *columnMetadata, columnWriter *writer) {
 	ch.reset()
 
 	ch.name = c.name
 	ch.valueType = c.valueType
 
-	// TODO: encoding values based on value type
-
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(0)
 	defer bigValuePool.Release(bb)
 
 	// marshal values
 	bb.Buf = encoding.EncodeBytesBlock(bb.Buf[:0], c.values)
-	ch.size = uint64(len(bb.Buf))
+	mustWriteValues(ch, columnWriter, bb.Buf)
+}
+
+// mustWriteFieldTo writes the values of a field, which are encoded by their type
+// if the sampled values are smaller in that way than in a bytes block.
+func (c *column) mustWriteFieldTo(ch *columnMetadata, columnWriter *writer) {
+	ch.reset()
+
+	ch.name = c.name
+	ch.valueType = c.valueType
+
+	bb := bigValuePool.Acquire(0)
+	defer bigValuePool.Release(bb)
+
+	if c.isEncodedByType() {
+		bb.Buf, ch.encodeType, ch.firstValue = encodeNumericValues(bb.Buf[:0], c.valueType, c.values)
+	} else {
+		bb.Buf = encoding.EncodeBytesBlock(bb.Buf[:0], c.values)
+	}
+	mustWriteValues(ch, columnWriter, bb.Buf)
+}
+
+func mustWriteValues(ch *columnMetadata, columnWriter *writer, values []byte) {
+	ch.size = uint64(len(values))
 	if ch.size > maxValuesBlockSize {
 		logger.Panicf("too valuesSize: %d bytes; mustn't exceed %d bytes", ch.size, maxValuesBlockSize)
 	}
 	ch.offset = columnWriter.bytesWritten
-	columnWriter.MustWrite(bb.Buf)
+	columnWriter.MustWrite(values)
+}
+
+// isEncodedByType tells whether the values should be encoded by their type.
+// The int64 values are encoded by the deltas, or the delta-of-deltas for the increasing counters,
+// and the float64 values are encoded by the XOR encoding.
+// Neither is picked if any value is null, or the sampled values are larger in that way than in a bytes block.
+func (c *column) isEncodedByType() bool {
+	if c.valueType != pbv1.ValueTypeInt64 && c.valueType != pbv1.ValueTypeFloat64 || len(c.values) < 2 {
+		return false
+	}
+	for _, v := range c.values {
+		if len(v) != 8 {
+			return false
+		}
+	}
+	sample := c.values[:min(len(c.values), fieldValuesSampleSize)]
+	bb := bigValuePool.Acquire(0)
+	defer bigValuePool.Release(bb)
+	bb.Buf, _, _ = encodeNumericValues(bb.Buf[:0], c.valueType, sample)
+	// the first value is stored in the metadata as a varint
+	encodedSize := len(bb.Buf) + binary.MaxVarintLen64
+	bb.Buf = encoding.EncodeBytesBlock(bb.Buf[:0], sample)
+	return encodedSize < len(bb.Buf)
+}
+
+func encodeNumericValues(dst []byte, valueType pbv1.ValueType, values [][]byte) ([]byte, encoding.EncodeType, int64) {
+	if valueType == pbv1.ValueTypeFloat64 {
+		ff := make([]float64, len(values))
+		for i, v := range values {
+			ff[i] = convert.BytesToFloat64(v)
+		}
+		return encoding.Float64ListToBytes(dst, ff), encoding.EncodeTypeXOR, 0
+	}
+	il := encoding.GenerateInt64List(len(values))
+	defer encoding.ReleaseInt64List(il)
+	for i, v := range values {
+		il.L[i] = convert.BytesToInt64(v)
+	}
+	return encoding.Int64ListToBytes(dst, il.L)
+}
+
+func decodeValues(dst [][]byte, decoder *encoding.BytesBlockDecoder, src []byte, cm *columnMetadata, count uint64) ([][]byte, error) {
+	switch cm.encodeType {
+	case encoding.EncodeTypeUnknown:
+		return decoder.Decode(dst, src, count)
+	case encoding.EncodeTypeXOR:
+		ff, err := encoding.BytesToFloat64List(make([]float64, 0, count), src, int(count))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode float64 values: %w", err)
+		}
+		for _, f := range ff {
+			dst = append(dst, convert.Float64ToBytes(f))
+		}
+		return dst, nil
+	default:
+		il := encoding.GenerateInt64List(int(count))
+		defer encoding.ReleaseInt64List(il)
+		var err error
+		il.L, err = encoding.BytesToInt64List(il.L[:0], src, cm.encodeType, cm.firstValue, int(count))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode int64 values: %w", err)
+		}
+		for _, v := range il.L {
+			dst = append(dst, convert.Int64ToBytes(v))
+		}
+		return dst, nil
+	}
 }
 
 func (c *column) mustReadValues(decoder *encoding.BytesBlockDecoder, reader fs.Reader, cm columnMetadata, count uint64) {
 	c.name = cm.name
 	c.valueType = cm.valueType
 
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(int(cm.size))
 	defer bigValuePool.Release(bb)
 	valuesSize := cm.size
 	if valuesSize > maxValuesBlockSize {
@@ -85,7 +180,7 @@ func (c *column) mustReadValues(decoder *encoding.BytesBlockDecoder, reader fs.R
 	bb.Buf = bytes.ResizeOver(bb.Buf, int(valuesSize))
 	fs.MustReadData(reader, int64(cm.offset), bb.Buf)
 	var err error
-	c.values, err = decoder.Decode(c.values[:0], bb.Buf, count)
+	c.values, err = decodeValues(c.values[:0], decoder, bb.Buf, &cm, count)
 	if err != nil {
 		logger.Panicf("%s: cannot decode values: %v", reader.Path(), err)
 	}
@@ -102,19 +197,19 @@ func (c *column) mustSeqReadValues(decoder *encoding.BytesBlockDecoder, reader *
 		logger.Panicf("%s: block size cannot exceed %d bytes; got %d bytes", reader.Path(), maxValuesBlockSize, valuesSize)
 	}
 
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(int(valuesSize))
 	defer bigValuePool.Release(bb)
 
 	bb.Buf = bytes.ResizeOver(bb.Buf, int(valuesSize))
 	reader.mustReadFull(bb.Buf)
 	var err error
-	c.values, err = decoder.Decode(c.values[:0], bb.Buf, count)
+	c.values, err = decodeValues(c.values[:0], decoder, bb.Buf, &cm, count)
 	if err != nil {
 		logger.Panicf("%s: cannot decode values: %v", reader.Path(), err)
 	}
 }
 
-var bigValuePool bytes.BufferPool
+var bigValuePool = bytes.NewBufferManager(bigValueCapacity, bufferRetainedGauge)
 
 type columnFamily struct {
 	name    string
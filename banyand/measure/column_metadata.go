@@ -43,27 +43,45 @@ import (
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 )
 
+// encodedValueTypeFlag is set in the marshaled value type of a column whose values are encoded by their type,
+// which is followed by the encode type and the first value.
+// The columns without it hold the bytes blocks, such as the ones written before the field values are encoded.
+const encodedValueTypeFlag = 0x80
+
 type columnMetadata struct {
 	name string
 	dataBlock
-	valueType pbv1.ValueType
+	// firstValue is the first value of the int64 values, which the encode type leaves out of the data block.
+	firstValue int64
+	valueType  pbv1.ValueType
+	// encodeType is how the values are encoded, EncodeTypeUnknown means a bytes block.
+	encodeType encoding.EncodeType
 }
 
 func (cm *columnMetadata) reset() {
 	cm.name = ""
 	cm.valueType = 0
+	cm.encodeType = encoding.EncodeTypeUnknown
+	cm.firstValue = 0
 	cm.dataBlock.reset()
 }
 
 func (cm *columnMetadata) copyFrom(src *columnMetadata) {
 	cm.name = src.name
 	cm.valueType = src.valueType
+	cm.encodeType = src.encodeType
+	cm.firstValue = src.firstValue
 	cm.dataBlock.copyFrom(&src.dataBlock)
 }
 
 func (cm *columnMetadata) marshal(dst []byte) []byte {
 	dst = encoding.EncodeBytes(dst, convert.StringToBytes(cm.name))
-	dst = append(dst, byte(cm.valueType))
+	if cm.encodeType == encoding.EncodeTypeUnknown {
+		dst = append(dst, byte(cm.valueType))
+	} else {
+		dst = append(dst, byte(cm.valueType)|encodedValueTypeFlag, byte(cm.encodeType))
+		dst = encoding.VarInt64ToBytes(dst, cm.firstValue)
+	}
 	dst = cm.dataBlock.marshal(dst)
 	return dst
 }
@@ -77,8 +95,21 @@ func (cm *columnMetadata) unmarshal(src []byte) ([]byte, error) {
 	if len(src) < 1 {
 		return nil, fmt.Errorf("cannot unmarshal columnMetadata.valueType: src is too short")
 	}
-	cm.valueType = pbv1.ValueType(src[0])
+	cm.valueType = pbv1.ValueType(src[0] &^ encodedValueTypeFlag)
+	encoded := src[0]&encodedValueTypeFlag != 0
 	src = src[1:]
+	cm.encodeType = encoding.EncodeTypeUnknown
+	cm.firstValue = 0
+	if encoded {
+		if len(src) < 1 {
+			return nil, fmt.Errorf("cannot unmarshal columnMetadata.encodeType: src is too short")
+		}
+		cm.encodeType = encoding.EncodeType(src[0])
+		src, cm.firstValue, err = encoding.BytesToVarInt64(src[1:])
+		if err != nil {
+			return nil, fmt.Errorf("cannot unmarshal columnMetadata.firstValue: %w", err)
+		}
+	}
 	src, err = cm.dataBlock.unmarshal(src)
 	if err != nil {
 		return nil, fmt.Errorf("cannot unmarshal columnMetadata.dataBlock: %w", err)
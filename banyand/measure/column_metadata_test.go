@@ -39,6 +39,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 )
 
@@ -87,6 +88,26 @@ func Test_columnMetadata_marshal(t *testing.T) {
 	assert.Equal(t, original, unmarshaled)
 }
 
+func Test_columnMetadata_marshal_encodeType(t *testing.T) {
+	original := &columnMetadata{
+		name:       "test",
+		valueType:  pbv1.ValueTypeInt64,
+		encodeType: encoding.EncodeTypeDeltaOfDelta,
+		firstValue: -100,
+		dataBlock:  dataBlock{offset: 1, size: 10},
+	}
+
+	marshaled := original.marshal(nil)
+	unmarshaled := &columnMetadata{}
+	_, err := unmarshaled.unmarshal(marshaled)
+	assert.Nil(t, err)
+	assert.Equal(t, original, unmarshaled)
+
+	unmarshaled.reset()
+	assert.Equal(t, encoding.EncodeTypeUnknown, unmarshaled.encodeType)
+	assert.Equal(t, int64(0), unmarshaled.firstValue)
+}
+
 func Test_columnFamilyMetadata_reset(t *testing.T) {
 	cfm := &columnFamilyMetadata{
 		columnMetadata: []columnMetadata{
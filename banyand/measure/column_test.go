@@ -18,11 +18,13 @@
 package measure
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/apache/skywalking-banyandb/pkg/bytes"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 )
@@ -84,6 +86,60 @@ func TestColumn_mustWriteTo_mustReadValues(t *testing.T) {
 	assert.Equal(t, original.values, unmarshaled.values)
 }
 
+func TestColumn_mustWriteFieldTo_mustReadValues(t *testing.T) {
+	counter := make([][]byte, 200)
+	for i := range counter {
+		counter[i] = convert.Int64ToBytes(int64(1000 + i*i))
+	}
+	gauge := make([][]byte, 200)
+	for i := range gauge {
+		gauge[i] = convert.Float64ToBytes(50 + math.Floor(10*math.Sin(float64(i)/10)))
+	}
+	tests := []struct {
+		name       string
+		original   *column
+		encodeType encoding.EncodeType
+	}{
+		{
+			name:       "counter",
+			original:   &column{name: "total", valueType: pbv1.ValueTypeInt64, values: counter},
+			encodeType: encoding.EncodeTypeDeltaOfDelta,
+		},
+		{
+			name:       "gauge",
+			original:   &column{name: "value", valueType: pbv1.ValueTypeFloat64, values: gauge},
+			encodeType: encoding.EncodeTypeXOR,
+		},
+		{
+			name: "null",
+			original: &column{name: "total", valueType: pbv1.ValueTypeInt64, values: [][]byte{
+				convert.Int64ToBytes(1), nil, convert.Int64ToBytes(3),
+			}},
+			encodeType: encoding.EncodeTypeUnknown,
+		},
+		{
+			name:       "string",
+			original:   &column{name: "name", valueType: pbv1.ValueTypeStr, values: [][]byte{[]byte("a"), []byte("b")}},
+			encodeType: encoding.EncodeTypeUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &columnMetadata{}
+			buf := &bytes.Buffer{}
+			w := &writer{}
+			w.init(buf)
+			tt.original.mustWriteFieldTo(cm, w)
+			assert.Equal(t, tt.encodeType, cm.encodeType)
+			assert.Equal(t, uint64(len(buf.Buf)), cm.size)
+
+			unmarshaled := &column{}
+			unmarshaled.mustReadValues(&encoding.BytesBlockDecoder{}, buf, *cm, uint64(len(tt.original.values)))
+			assert.Equal(t, tt.original.values, unmarshaled.values)
+		})
+	}
+}
+
 func TestColumnFamily_reset(t *testing.T) {
 	cf := &columnFamily{
 		name: "test",
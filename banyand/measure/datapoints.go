@@ -119,6 +119,8 @@ type dataPoints struct {
 	timestamps  []int64
 	tagFamilies [][]nameValues
 	fields      []nameValues
+	// walOffsets are the offsets of the writes in the WAL of the group, which are released once the data points are flushed.
+	walOffsets []uint64
 }
 
 func (d *dataPoints) Len() int {
@@ -20,8 +20,10 @@ package measure
 import (
 	"errors"
 	"math"
+	"sync/atomic"
 	"time"
 
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
 	"github.com/apache/skywalking-banyandb/pkg/watcher"
 )
 
@@ -53,7 +55,13 @@ func (tst *tsTable) flusherLoop(flushCh chan *flusherIntroduction, mergeCh chan
 			}
 			tst.RUnlock()
 			if curSnapshot != nil {
-				merged, err := tst.mergeMemParts(curSnapshot, mergeCh)
+				tst.assignFlushSeq(curSnapshot)
+				var merged bool
+				var err error
+				// under memory pressure, the in-memory parts are flushed without allocating a merged one
+				if !watchdog.Engaged(watchdog.ActionDelayFlushMerges) {
+					merged, err = tst.mergeMemParts(curSnapshot, mergeCh)
+				}
 				if err != nil {
 					tst.l.Logger.Warn().Err(err).Msgf("cannot merge snapshot: %d", curSnapshot.epoch)
 					curSnapshot.decRef()
@@ -62,6 +70,7 @@ func (tst *tsTable) flusherLoop(flushCh chan *flusherIntroduction, mergeCh chan
 				if !merged {
 					tst.flush(curSnapshot, flushCh)
 				}
+				tst.releaseWAL(curSnapshot)
 				epoch = curSnapshot.epoch
 				// Notify merger to start a new round of merge.
 				// This round might have be triggered in pauseFlusherToPileupMemParts.
@@ -80,6 +89,21 @@ func (tst *tsTable) flusherLoop(flushCh chan *flusherIntroduction, mergeCh chan
 	}
 }
 
+// releaseWAL releases the writes held by the memory parts of the snapshot, which are flushed.
+// The memory parts aren't flushed for sure if the table is closed while flushing, whose writes are replayed after a restart.
+func (tst *tsTable) releaseWAL(snp *snapshot) {
+	if tst.option.recorder == nil || tst.loopCloser.Closed() {
+		return
+	}
+	var offsets []uint64
+	for _, pw := range snp.parts {
+		if pw.mp != nil {
+			offsets = append(offsets, pw.walOffsets...)
+		}
+	}
+	tst.option.recorder.Release(tst.p.Database, offsets)
+}
+
 // pauseFlusherToPileupMemParts takes a pause to wait for in-memory parts to pile up.
 // If there is no in-memory part, we can skip the pause.
 // When a merging is finished, we can skip the pause.
@@ -100,6 +124,18 @@ func (tst *tsTable) pauseFlusherToPileupMemParts(epoch uint64, flushWatcher watc
 	return flusherWatchers
 }
 
+// assignFlushSeq numbers the memory parts to flush, in the order they're introduced.
+func (tst *tsTable) assignFlushSeq(snp *snapshot) {
+	for _, pw := range snp.parts {
+		if pw.mp == nil {
+			continue
+		}
+		seq := atomic.AddUint64(&tst.flushSeq, 1)
+		pw.mp.partMetadata.MinFlushSeq, pw.mp.partMetadata.FlushSeq = seq, seq
+		pw.p.partMetadata.MinFlushSeq, pw.p.partMetadata.FlushSeq = seq, seq
+	}
+}
+
 func (tst *tsTable) mergeMemParts(snp *snapshot, mergeCh chan *mergerIntroduction) (bool, error) {
 	var memParts []*partWrapper
 	mergedIDs := make(map[uint64]struct{})
@@ -137,7 +173,7 @@ func (tst *tsTable) flush(snapshot *snapshot, flushCh chan *flusherIntroduction)
 			continue
 		}
 		partPath := partPath(tst.root, pw.ID())
-		pw.mp.mustFlush(tst.fileSystem, partPath)
+		pw.mp.mustFlushWithOptions(tst.fileSystem, partPath, tst.option.ioOpts)
 		newPW := newPartWrapper(nil, mustOpenFilePart(pw.ID(), tst.root, tst.fileSystem))
 		newPW.p.partMetadata.ID = pw.ID()
 		ind.flushed[newPW.ID()] = newPW
@@ -122,6 +122,10 @@ func (tst *tsTable) introducerLoop(flushCh chan *flusherIntroduction, mergeCh ch
 			tst.introduceFlushed(next, epoch)
 			tst.gc.cleanSnapshots()
 			epoch++
+		case next := <-tst.imports:
+			tst.introduceImported(next, epoch)
+			tst.gc.cleanSnapshots()
+			epoch++
 		case next := <-mergeCh:
 			tst.introduceMerged(next, epoch)
 			tst.gc.clean()
@@ -152,6 +156,23 @@ func (tst *tsTable) introduceMemPart(nextIntroduction *introduction, epoch uint6
 	}
 }
 
+// introduceImported adds a file part imported from an archive, which is persisted at once.
+func (tst *tsTable) introduceImported(nextIntroduction *introduction, epoch uint64) {
+	cur := tst.currentSnapshot()
+	if cur != nil {
+		defer cur.decRef()
+	} else {
+		cur = new(snapshot)
+	}
+	nextSnp := cur.copyAllTo(epoch)
+	nextSnp.parts = append(nextSnp.parts, nextIntroduction.memPart)
+	nextSnp.creator = snapshotCreatorImporter
+	tst.replaceSnapshot(&nextSnp, true)
+	if nextIntroduction.applied != nil {
+		close(nextIntroduction.applied)
+	}
+}
+
 func (tst *tsTable) introduceFlushed(nextIntroduction *flusherIntroduction, epoch uint64) {
 	cur := tst.currentSnapshot()
 	if cur == nil {
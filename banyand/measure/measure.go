@@ -25,7 +25,10 @@ import (
 
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/banyand/internal/wal"
 	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/partition"
 	"github.com/apache/skywalking-banyandb/pkg/query/logical"
@@ -47,7 +50,12 @@ const (
 
 type option struct {
 	mergePolicy  *mergePolicy
+	seriesIndex  storage.SeriesIndexOpts
 	flushTimeout time.Duration
+	// ioOpts hints how the merges and the flushes read and write the files of the parts.
+	ioOpts fs.IOOptions
+	// recorder logs the writes before they're applied, it's nil if the writes aren't logged.
+	recorder wal.Recorder
 }
 
 type measure struct {
@@ -60,6 +68,7 @@ type measure struct {
 	indexRules        []*databasev1.IndexRule
 	indexRuleLocators []*partition.IndexRuleLocator
 	topNAggregations  []*databasev1.TopNAggregation
+	sharding          partition.Sharding
 	interval          time.Duration
 	shardNum          uint32
 }
@@ -114,10 +123,15 @@ type measureSpec struct {
 	topNAggregations []*databasev1.TopNAggregation
 }
 
-func openMeasure(shardNum uint32, db schema.Supplier, spec measureSpec, l *logger.Logger, pipeline queue.Queue,
+func openMeasure(groupOpts *commonv1.ResourceOpts, db schema.Supplier, spec measureSpec, l *logger.Logger, pipeline queue.Queue,
 ) (*measure, error) {
+	sharding, err := partition.NewSharding(groupOpts.GetSharding())
+	if err != nil {
+		return nil, err
+	}
 	m := &measure{
-		shardNum:         shardNum,
+		shardNum:         groupOpts.GetShardNum(),
+		sharding:         sharding,
 		schema:           spec.schema,
 		indexRules:       spec.indexRules,
 		topNAggregations: spec.topNAggregations,
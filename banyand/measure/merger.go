@@ -25,6 +25,7 @@ import (
 
 	"github.com/dustin/go-humanize"
 
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
 	"github.com/apache/skywalking-banyandb/pkg/watcher"
@@ -47,6 +48,9 @@ func (tst *tsTable) mergeLoop(merges chan *mergerIntroduction, flusherNotifier w
 		case <-tst.loopCloser.CloseNotify():
 			return
 		case <-ew.Watch():
+			if !watchdog.WaitReleased(watchdog.ActionPauseMerges, tst.loopCloser.CloseNotify()) {
+				return
+			}
 			curSnapshot := tst.currentSnapshot()
 			if curSnapshot == nil {
 				continue
@@ -93,7 +97,7 @@ func (tst *tsTable) mergePartsThenSendIntroduction(creator snapshotCreator, part
 	reservedSpace := tst.reserveSpace(parts)
 	defer releaseDiskSpace(reservedSpace)
 	start := time.Now()
-	newPart, err := mergeParts(tst.fileSystem, closeCh, parts, atomic.AddUint64(&tst.curPartID, 1), tst.root)
+	newPart, err := mergeParts(tst.fileSystem, closeCh, parts, atomic.AddUint64(&tst.curPartID, 1), tst.root, tst.option.ioOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -216,7 +220,8 @@ func (tst *tsTable) reserveSpace(parts []*partWrapper) uint64 {
 
 var errNoPartToMerge = fmt.Errorf("no part to merge")
 
-func mergeParts(fileSystem fs.FileSystem, closeCh <-chan struct{}, parts []*partWrapper, partID uint64, root string) (*partWrapper, error) {
+// mergeParts merges the parts into a new file part, whose files are read and written with the ioOpts.
+func mergeParts(fileSystem fs.FileSystem, closeCh <-chan struct{}, parts []*partWrapper, partID uint64, root string, ioOpts fs.IOOptions) (*partWrapper, error) {
 	if len(parts) == 0 {
 		return nil, errNoPartToMerge
 	}
@@ -224,13 +229,13 @@ func mergeParts(fileSystem fs.FileSystem, closeCh <-chan struct{}, parts []*part
 	pii := make([]*partMergeIter, 0, len(parts))
 	for i := range parts {
 		pmi := generatePartMergeIter()
-		pmi.mustInitFromPart(parts[i].p)
+		pmi.mustInitFromPartWithOptions(parts[i].p, ioOpts)
 		pii = append(pii, pmi)
 	}
 	br := generateBlockReader()
 	br.init(pii)
 	bw := generateBlockWriter()
-	bw.mustInitForFilePart(fileSystem, dstPath)
+	bw.mustInitForFilePart(fileSystem, dstPath, ioOpts)
 
 	pm, err := mergeBlocks(closeCh, bw, br)
 	releaseBlockWriter(bw)
@@ -241,6 +246,11 @@ func mergeParts(fileSystem fs.FileSystem, closeCh <-chan struct{}, parts []*part
 	if err != nil {
 		return nil, err
 	}
+	pm.MinFlushSeq, pm.FlushSeq = parts[0].p.partMetadata.MinFlushSeq, parts[0].p.partMetadata.FlushSeq
+	for _, pw := range parts[1:] {
+		pm.MinFlushSeq = min(pm.MinFlushSeq, pw.p.partMetadata.MinFlushSeq)
+		pm.FlushSeq = max(pm.FlushSeq, pw.p.partMetadata.FlushSeq)
+	}
 	pm.mustWriteMetadata(fileSystem, dstPath)
 	fileSystem.SyncPath(dstPath)
 	p := mustOpenFilePart(partID, root, fileSystem)
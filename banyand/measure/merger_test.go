@@ -301,7 +301,7 @@ func Test_mergeParts(t *testing.T) {
 			verify := func(t *testing.T, pp []*partWrapper, fileSystem fs.FileSystem, root string, partID uint64) {
 				closeCh := make(chan struct{})
 				defer close(closeCh)
-				p, err := mergeParts(fileSystem, closeCh, pp, partID, root)
+				p, err := mergeParts(fileSystem, closeCh, pp, partID, root, fs.IOOptions{})
 				if tt.wantErr != nil {
 					if !errors.Is(err, tt.wantErr) {
 						t.Fatalf("Unexpected error: got %v, want %v", err, tt.wantErr)
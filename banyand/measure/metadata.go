@@ -107,10 +107,19 @@ func (sr *schemaRepo) OnAddOrUpdate(metadata schema.Metadata) {
 			Metadata: g.GetMetadata(),
 		})
 	case schema.KindMeasure:
+		m := metadata.Spec.(*databasev1.Measure)
+		if len(m.GetRollups()) > 0 {
+			// createOrUpdate the rollup schemas in advance
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := createOrUpdateRollups(ctx, sr.metadata, m); err != nil {
+				sr.l.Warn().Err(err).Str("measure", m.GetMetadata().GetName()).Msg("fail to create the rollups")
+			}
+			cancel()
+		}
 		sr.SendMetadataEvent(resourceSchema.MetadataEvent{
 			Typ:      resourceSchema.EventAddOrUpdate,
 			Kind:     resourceSchema.EventKindResource,
-			Metadata: metadata.Spec.(*databasev1.Measure).GetMetadata(),
+			Metadata: m.GetMetadata(),
 		})
 	case schema.KindIndexRuleBinding:
 		irb, ok := metadata.Spec.(*databasev1.IndexRuleBinding)
@@ -164,12 +173,9 @@ func (sr *schemaRepo) OnAddOrUpdate(metadata schema.Metadata) {
 	}
 }
 
+// createOrUpdateTopNMeasure creates or updates the derived measures storing the ranks of the TopN aggregation and its dimensions.
+// It returns the one of the aggregation itself.
 func createOrUpdateTopNMeasure(ctx context.Context, measureSchemaRegistry schema.Measure, topNSchema *databasev1.TopNAggregation) (*databasev1.Measure, error) {
-	oldTopNSchema, err := measureSchemaRegistry.GetMeasure(ctx, topNSchema.GetMetadata())
-	if err != nil && !errors.Is(err, schema.ErrGRPCResourceNotFound) {
-		return nil, err
-	}
-
 	sourceMeasureSchema, err := measureSchemaRegistry.GetMeasure(ctx, topNSchema.GetSourceMeasure())
 	if err != nil {
 		return nil, err
@@ -196,9 +202,26 @@ func createOrUpdateTopNMeasure(ctx context.Context, measureSchemaRegistry schema
 		}
 	}
 
+	dimensions := topNDimensions(topNSchema)
+	for _, d := range dimensions[1:] {
+		if _, err = createOrUpdateTopNDimensionMeasure(ctx, measureSchemaRegistry, sourceMeasureSchema, seriesSpecs, d); err != nil {
+			return nil, err
+		}
+	}
+	return createOrUpdateTopNDimensionMeasure(ctx, measureSchemaRegistry, sourceMeasureSchema, seriesSpecs, dimensions[0])
+}
+
+func createOrUpdateTopNDimensionMeasure(ctx context.Context, measureSchemaRegistry schema.Measure, sourceMeasureSchema *databasev1.Measure,
+	seriesSpecs []*databasev1.TagSpec, dimension topNDimension,
+) (*databasev1.Measure, error) {
+	oldTopNSchema, err := measureSchemaRegistry.GetMeasure(ctx, dimension.metadata)
+	if err != nil && !errors.Is(err, schema.ErrGRPCResourceNotFound) {
+		return nil, err
+	}
+
 	// create a new "derived" measure for TopN result
 	newTopNMeasure := &databasev1.Measure{
-		Metadata: topNSchema.GetMetadata(),
+		Metadata: dimension.metadata,
 		Interval: sourceMeasureSchema.GetInterval(),
 		TagFamilies: []*databasev1.TagFamilySpec{
 			{
@@ -224,7 +247,7 @@ func createOrUpdateTopNMeasure(ctx context.Context, measureSchemaRegistry schema
 			TagNames: append([]string{
 				"sortDirection",
 				"rankNumber",
-			}, topNSchema.GetGroupByTagNames()...),
+			}, dimension.groupByTagNames...),
 		},
 	}
 	if oldTopNSchema == nil {
@@ -286,6 +309,11 @@ func (sr *schemaRepo) OnDelete(metadata schema.Metadata) {
 		}
 	case schema.KindIndexRule:
 	case schema.KindTopNAggregation:
+		for _, d := range topNDimensions(metadata.Spec.(*databasev1.TopNAggregation))[1:] {
+			if errDimension := sr.removeTopNMeasure(d.metadata); errDimension != nil {
+				sr.l.Warn().Err(errDimension).Str("measure", d.metadata.GetName()).Msg("fail to remove the TopN dimension")
+			}
+		}
 		err := sr.removeTopNMeasure(metadata.Spec.(*databasev1.TopNAggregation).GetSourceMeasure())
 		if err != nil {
 			return
@@ -342,9 +370,9 @@ func newSupplier(path string, svc *service) *supplier {
 	}
 }
 
-func (s *supplier) OpenResource(shardNum uint32, supplier resourceSchema.Supplier, spec resourceSchema.Resource) (io.Closer, error) {
+func (s *supplier) OpenResource(groupOpts *commonv1.ResourceOpts, supplier resourceSchema.Supplier, spec resourceSchema.Resource) (io.Closer, error) {
 	measureSchema := spec.Schema().(*databasev1.Measure)
-	return openMeasure(shardNum, supplier, measureSpec{
+	return openMeasure(groupOpts, supplier, measureSpec{
 		schema:           measureSchema,
 		indexRules:       spec.IndexRules(),
 		topNAggregations: spec.TopN(),
@@ -359,13 +387,13 @@ func (s *supplier) ResourceSchema(md *commonv1.Metadata) (resourceSchema.Resourc
 
 func (s *supplier) OpenDB(groupSchema *commonv1.Group) (io.Closer, error) {
 	opts := storage.TSDBOpts[*tsTable, option]{
-		ShardNum:                       groupSchema.ResourceOpts.ShardNum,
-		Location:                       path.Join(s.path, groupSchema.Metadata.Name),
-		TSTableCreator:                 newTSTable,
-		SegmentInterval:                storage.MustToIntervalRule(groupSchema.ResourceOpts.SegmentInterval),
-		TTL:                            storage.MustToIntervalRule(groupSchema.ResourceOpts.Ttl),
-		Option:                         s.option,
-		SeriesIndexFlushTimeoutSeconds: s.option.flushTimeout.Nanoseconds() / int64(time.Second),
+		ShardNum:        groupSchema.ResourceOpts.ShardNum,
+		Location:        path.Join(s.path, groupSchema.Metadata.Name),
+		TSTableCreator:  newTSTable,
+		SegmentInterval: storage.MustToIntervalRule(groupSchema.ResourceOpts.SegmentInterval),
+		TTL:             storage.MustToIntervalRule(groupSchema.ResourceOpts.Ttl),
+		Option:          s.option,
+		SeriesIndex:     s.option.seriesIndex,
 	}
 	name := groupSchema.Metadata.Name
 	return storage.OpenTSDB(
@@ -399,9 +427,9 @@ func (*portableSupplier) OpenDB(_ *commonv1.Group) (io.Closer, error) {
 	panic("do not support open db")
 }
 
-func (s *portableSupplier) OpenResource(shardNum uint32, _ resourceSchema.Supplier, spec resourceSchema.Resource) (io.Closer, error) {
+func (s *portableSupplier) OpenResource(groupOpts *commonv1.ResourceOpts, _ resourceSchema.Supplier, spec resourceSchema.Resource) (io.Closer, error) {
 	measureSchema := spec.Schema().(*databasev1.Measure)
-	return openMeasure(shardNum, nil, measureSpec{
+	return openMeasure(groupOpts, nil, measureSpec{
 		schema:           measureSchema,
 		indexRules:       spec.IndexRules(),
 		topNAggregations: spec.TopN(),
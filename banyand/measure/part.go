@@ -170,17 +170,22 @@ func (mp *memPart) mustInitFromDataPoints(dps *dataPoints) {
 }
 
 func (mp *memPart) mustFlush(fileSystem fs.FileSystem, path string) {
+	mp.mustFlushWithOptions(fileSystem, path, fs.IOOptions{})
+}
+
+// mustFlushWithOptions is mustFlush writing the data files with the ioOpts.
+func (mp *memPart) mustFlushWithOptions(fileSystem fs.FileSystem, path string, ioOpts fs.IOOptions) {
 	fileSystem.MkdirPanicIfExist(path, dirPermission)
 
-	fs.MustFlush(fileSystem, mp.meta.Buf, filepath.Join(path, metaFilename), filePermission)
-	fs.MustFlush(fileSystem, mp.primary.Buf, filepath.Join(path, primaryFilename), filePermission)
-	fs.MustFlush(fileSystem, mp.timestamps.Buf, filepath.Join(path, timestampsFilename), filePermission)
-	fs.MustFlush(fileSystem, mp.fieldValues.Buf, filepath.Join(path, fieldValuesFilename), filePermission)
+	fs.MustFlushWithOptions(fileSystem, mp.meta.Buf, filepath.Join(path, metaFilename), filePermission, ioOpts)
+	fs.MustFlushWithOptions(fileSystem, mp.primary.Buf, filepath.Join(path, primaryFilename), filePermission, ioOpts)
+	fs.MustFlushWithOptions(fileSystem, mp.timestamps.Buf, filepath.Join(path, timestampsFilename), filePermission, ioOpts)
+	fs.MustFlushWithOptions(fileSystem, mp.fieldValues.Buf, filepath.Join(path, fieldValuesFilename), filePermission, ioOpts)
 	for name, tf := range mp.tagFamilies {
-		fs.MustFlush(fileSystem, tf.Buf, filepath.Join(path, name+tagFamiliesFilenameExt), filePermission)
+		fs.MustFlushWithOptions(fileSystem, tf.Buf, filepath.Join(path, name+tagFamiliesFilenameExt), filePermission, ioOpts)
 	}
 	for name, tfh := range mp.tagFamilyMetadata {
-		fs.MustFlush(fileSystem, tfh.Buf, filepath.Join(path, name+tagFamiliesMetadataFilenameExt), filePermission)
+		fs.MustFlushWithOptions(fileSystem, tfh.Buf, filepath.Join(path, name+tagFamiliesMetadataFilenameExt), filePermission, ioOpts)
 	}
 
 	mp.partMetadata.mustWriteMetadata(fileSystem, path)
@@ -219,9 +224,11 @@ func releaseMemPart(mp *memPart) {
 var memPartPool sync.Pool
 
 type partWrapper struct {
-	mp  *memPart
-	p   *part
-	ref int32
+	mp *memPart
+	p  *part
+	// walOffsets are the offsets of the writes held by the memory part, see dataPoints.walOffsets.
+	walOffsets []uint64
+	ref        int32
 }
 
 func newPartWrapper(mp *memPart, p *part) *partWrapper {
@@ -259,8 +259,13 @@ func (pmi *partMergeIter) reset() {
 }
 
 func (pmi *partMergeIter) mustInitFromPart(p *part) {
+	pmi.mustInitFromPartWithOptions(p, fs.IOOptions{})
+}
+
+// mustInitFromPartWithOptions is mustInitFromPart reading the files of p with the ioOpts.
+func (pmi *partMergeIter) mustInitFromPartWithOptions(p *part, ioOpts fs.IOOptions) {
 	pmi.reset()
-	pmi.seqReaders.init(p)
+	pmi.seqReaders.initWithOptions(p, ioOpts)
 	pmi.primaryBlockMetadata = p.primaryBlockMetadata
 	pmi.partID = p.partMetadata.ID
 }
@@ -34,7 +34,11 @@ type partMetadata struct {
 	BlocksCount           uint64 `json:"blocksCount"`
 	MinTimestamp          int64  `json:"minTimestamp"`
 	MaxTimestamp          int64  `json:"maxTimestamp"`
-	ID                    uint64 `json:"-"`
+	// MinFlushSeq and FlushSeq are the range of the sequences of the flushes writing the data points in the part,
+	// which are 0 for the parts written before they're recorded. The subscriptions read the flushed parts in their order.
+	MinFlushSeq uint64 `json:"minFlushSeq,omitempty"`
+	FlushSeq    uint64 `json:"flushSeq,omitempty"`
+	ID          uint64 `json:"-"`
 }
 
 func (pm *partMetadata) reset() {
@@ -44,6 +48,8 @@ func (pm *partMetadata) reset() {
 	pm.BlocksCount = 0
 	pm.MinTimestamp = 0
 	pm.MaxTimestamp = 0
+	pm.MinFlushSeq = 0
+	pm.FlushSeq = 0
 	pm.ID = 0
 }
 
@@ -0,0 +1,67 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/pkg/pool"
+)
+
+const (
+	// the bytes of the headers retained by the pooled slices
+	timestampSize = 8
+	valueSize     = 24
+
+	defaultMaxPooledBufferSize = 8 << 20
+	defaultMaxPooledBlockSize  = 8 << 20
+	defaultPooledBuffersBudget = 256 << 20
+)
+
+var (
+	poolProvider        = observability.NewMeterProvider(observability.RootScope.SubScope("measure").SubScope("pool"))
+	poolDroppedCounter  = poolProvider.Counter("dropped_total", "pool")
+	bufferRetainedGauge = poolProvider.Gauge("buffer_retained_bytes", "class")
+
+	bigValueCapacity    = pool.NewCapacity(poolDroppedCounter, "big_value")
+	blockCapacity       = pool.NewCapacity(poolDroppedCounter, "block")
+	blockCursorCapacity = pool.NewCapacity(poolDroppedCounter, "block_cursor")
+)
+
+func (b *block) capacity() int {
+	n := cap(b.timestamps)*timestampSize + columnFamilyCapacity(b.field)
+	for _, cf := range b.tagFamilies[:cap(b.tagFamilies)] {
+		n += columnFamilyCapacity(cf)
+	}
+	return n
+}
+
+func (bc *blockCursor) capacity() int {
+	n := cap(bc.timestamps)*timestampSize + columnFamilyCapacity(bc.fields) + bc.columnValuesDecoder.Cap()
+	for _, cf := range bc.tagFamilies[:cap(bc.tagFamilies)] {
+		n += columnFamilyCapacity(cf)
+	}
+	return n
+}
+
+func columnFamilyCapacity(cf columnFamily) int {
+	var n int
+	for _, c := range cf.columns[:cap(cf.columns)] {
+		n += cap(c.values) * valueSize
+	}
+	return n
+}
@@ -49,7 +49,7 @@ func (ph *primaryBlockMetadata) mustWriteBlock(data []byte, sidFirst common.Seri
 	ph.minTimestamp = minTimestamp
 	ph.maxTimestamp = maxTimestamp
 
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(len(data))
 	bb.Buf = zstd.Compress(bb.Buf[:0], data, 1)
 	ph.offset = sw.primaryWriter.bytesWritten
 	ph.size = uint64(len(bb.Buf))
@@ -90,7 +90,7 @@ func mustReadPrimaryBlockMetadata(dst []primaryBlockMetadata, r fs.Reader) []pri
 	}
 	fs.MustClose(sr)
 
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(0)
 	bb.Buf, err = zstd.Decompress(bb.Buf[:0], data)
 	if err != nil {
 		logger.Panicf("cannot decompress indexBlockHeader entries from %s: %s", r.Path(), err)
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -34,6 +35,7 @@ import (
 	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/query/executor"
 	resourceSchema "github.com/apache/skywalking-banyandb/pkg/schema"
 )
 
@@ -71,19 +73,26 @@ func (s *measure) Query(ctx context.Context, mqo pbv1.MeasureQueryOptions) (pbv1
 	if len(mqo.TagProjection) == 0 && len(mqo.FieldProjection) == 0 {
 		return nil, errors.New("invalid query options: tagProjection or fieldProjection is required")
 	}
+	// the blocks selected here are loaded once the result is pulled, which the span observes as well
+	span := executor.FromSpan(ctx).Child("block scan")
+	start := time.Now()
+	defer func() { span.Observe(time.Since(start)) }()
 	tsdb := s.databaseSupplier.SupplyTSDB().(storage.TSDB[*tsTable, option])
-	tabWrappers := tsdb.SelectTSTables(*mqo.TimeRange)
+	tabWrappers := tsdb.SelectTSTables(*mqo.TimeRange, mqo.ShardIDs...)
 	defer func() {
 		for i := range tabWrappers {
 			tabWrappers[i].DecRef()
 		}
 	}()
 
+	lookup := span.Child("index lookup")
 	sl, err := tsdb.IndexDB().Search(ctx, &pbv1.Series{Subject: mqo.Name, EntityValues: mqo.Entity}, mqo.Filter, mqo.Order)
+	lookup.End()
 	if err != nil {
 		return nil, err
 	}
-	var result queryResult
+	lookup.Add(executor.CounterSeries, int64(len(sl)))
+	result := queryResult{ctx: ctx, span: span, account: executor.FromMemoryAccount(ctx)}
 	if len(sl) < 1 {
 		return &result, nil
 	}
@@ -149,6 +158,9 @@ func (s *measure) Query(ctx context.Context, mqo pbv1.MeasureQueryOptions) (pbv1
 	for i, si := range originalSids {
 		result.sidToIndex[si] = i
 	}
+	if mqo.Order == nil || mqo.Order.Index == nil {
+		result.after = mqo.After
+	}
 	if mqo.Order == nil {
 		result.orderByTS = true
 		result.ascTS = true
@@ -225,7 +237,7 @@ func mustDecodeTagValue(valueType pbv1.ValueType, value []byte) *modelv1.TagValu
 		return int64ArrTagValue(values)
 	case pbv1.ValueTypeStrArr:
 		var values []string
-		bb := bigValuePool.Generate()
+		bb := bigValuePool.Acquire(0)
 		var err error
 		for len(value) > 0 {
 			bb.Buf, value, err = unmarshalVarArray(bb.Buf[:0], value)
@@ -360,11 +372,16 @@ func binaryDataFieldValue(value []byte) *modelv1.FieldValue {
 }
 
 type queryResult struct {
+	ctx           context.Context
 	sidToIndex    map[common.SeriesID]int
 	entityValues  map[common.SeriesID]map[string]*modelv1.TagValue
 	tagProjection []pbv1.TagProjection
 	data          []*blockCursor
 	snapshots     []*snapshot
+	after         *pbv1.Cursor
+	span          *executor.Span
+	account       *executor.MemoryAccount
+	accounted     int64 // the bytes of the loaded blocks accounted to the query
 	loaded        bool
 	orderByTS     bool
 	ascTS         bool
@@ -375,25 +392,43 @@ func (qr *queryResult) Pull() *pbv1.MeasureResult {
 		if len(qr.data) == 0 {
 			return nil
 		}
+		start := time.Now()
 		// TODO:// Parallel load
 		tmpBlock := generateBlock()
 		defer releaseBlock(tmpBlock)
 		for i := 0; i < len(qr.data); i++ {
-			if !qr.data[i].loadData(tmpBlock) {
+			if qr.ctx.Err() != nil {
+				// the query is canceled, the blocks left are never loaded
+				qr.drop(i)
+				break
+			}
+			if !qr.data[i].loadData(qr.ctx, tmpBlock) {
+				qr.span.Add(executor.CounterBlocksSkipped, 1)
 				qr.data = append(qr.data[:i], qr.data[i+1:]...)
 				i--
-			}
-			if i < 0 {
 				continue
 			}
+			qr.trace(qr.data[i])
+			if !qr.accountBlock(qr.data[i]) {
+				// the query is canceled, the blocks left are never loaded
+				qr.drop(i)
+				break
+			}
 			if qr.orderByTimestampDesc() {
 				qr.data[i].idx = len(qr.data[i].timestamps) - 1
 			}
+			if qr.after != nil && !qr.data[i].skipBefore(qr.after, qr.orderByTimestampDesc()) {
+				releaseBlockCursor(qr.data[i])
+				qr.data = append(qr.data[:i], qr.data[i+1:]...)
+				i--
+			}
 		}
 		qr.loaded = true
 		heap.Init(qr)
+		qr.span.Observe(time.Since(start))
 	}
-	if len(qr.data) == 0 {
+	// the merge stops once the query is canceled, the blocks are released by Release
+	if len(qr.data) == 0 || qr.ctx.Err() != nil {
 		return nil
 	}
 	if len(qr.data) == 1 {
@@ -406,12 +441,47 @@ func (qr *queryResult) Pull() *pbv1.MeasureResult {
 	return qr.merge(qr.entityValues, qr.tagProjection)
 }
 
+// drop releases the cursors of the blocks from i on.
+func (qr *queryResult) drop(i int) {
+	for j := i; j < len(qr.data); j++ {
+		releaseBlockCursor(qr.data[j])
+		qr.data[j] = nil
+	}
+	qr.data = qr.data[:i]
+}
+
+// accountBlock accounts the decoded rows of the block to the memory budget of the query,
+// it returns false if the query exceeds the budget.
+func (qr *queryResult) accountBlock(bc *blockCursor) bool {
+	size := bc.memSize()
+	if err := qr.account.Grow(size); err != nil {
+		return false
+	}
+	qr.accounted += size
+	return true
+}
+
+// trace adds the loaded block to the span if the query is traced.
+func (qr *queryResult) trace(bc *blockCursor) {
+	if qr.span == nil {
+		return
+	}
+	qr.span.Add(executor.CounterBlocksScanned, 1)
+	qr.span.Add(executor.CounterRows, int64(len(bc.timestamps)))
+	// the size is left out if the metadata can't be read again, the block is loaded anyway
+	if size, err := bc.readSize(); err == nil {
+		qr.span.Add(executor.CounterBytesRead, size)
+	}
+}
+
 func (qr *queryResult) Release() {
 	for i, v := range qr.data {
 		releaseBlockCursor(v)
 		qr.data[i] = nil
 	}
 	qr.data = qr.data[:0]
+	qr.account.Shrink(qr.accounted)
+	qr.accounted = 0
 	for i := range qr.snapshots {
 		qr.snapshots[i].decRef()
 	}
@@ -0,0 +1,533 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	apiData "github.com/apache/skywalking-banyandb/api/data"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/metadata"
+	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/query/aggregation"
+	logical_measure "github.com/apache/skywalking-banyandb/pkg/query/logical/measure"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+const (
+	defaultRollupCheckInterval = time.Minute
+	defaultRollupDelay         = time.Minute
+)
+
+// rollupLevel is a resolution which the data points of a measure are rolled up into.
+type rollupLevel struct {
+	spec     *databasev1.RollupSpec
+	metadata *commonv1.Metadata
+	interval time.Duration
+}
+
+// parseRollups returns the rollups of the measure from the finest to the coarsest.
+// The interval of each rollup must be a multiple of the finer one's, or the measure's for the finest.
+func parseRollups(m *databasev1.Measure) ([]rollupLevel, error) {
+	levels := make([]rollupLevel, 0, len(m.GetRollups()))
+	for _, r := range m.GetRollups() {
+		interval, err := timestamp.ParseDuration(r.GetInterval())
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid rollup interval %q", r.GetInterval())
+		}
+		if interval <= 0 {
+			return nil, errors.Errorf("invalid rollup interval %q", r.GetInterval())
+		}
+		levels = append(levels, rollupLevel{
+			spec:     r,
+			metadata: logical_measure.RollupMetadata(m.GetMetadata(), r.GetInterval()),
+			interval: interval,
+		})
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].interval < levels[j].interval })
+	var finer time.Duration
+	if m.GetInterval() != "" {
+		var err error
+		if finer, err = timestamp.ParseDuration(m.GetInterval()); err != nil {
+			return nil, errors.WithMessagef(err, "invalid interval %q", m.GetInterval())
+		}
+	}
+	for _, l := range levels {
+		if finer > 0 && (l.interval <= finer || l.interval%finer != 0) {
+			return nil, errors.Errorf("rollup interval %s isn't a multiple of %s", l.spec.GetInterval(), finer)
+		}
+		finer = l.interval
+	}
+	return levels, nil
+}
+
+// newRollupMeasure returns the derived measure storing the data points rolled up at the level,
+// which has the fields aggregated by the rollups only.
+// The fields counting the distinct values or estimating the percentiles store the sketches, which are binary data.
+func newRollupMeasure(m *databasev1.Measure, level rollupLevel) *databasev1.Measure {
+	fields := make([]*databasev1.FieldSpec, 0, len(m.GetFields()))
+	for _, f := range m.GetFields() {
+		switch f.GetAggregateFunction() {
+		case modelv1.AggregationFunction_AGGREGATION_FUNCTION_UNSPECIFIED:
+		case modelv1.AggregationFunction_AGGREGATION_FUNCTION_COUNT_DISTINCT,
+			modelv1.AggregationFunction_AGGREGATION_FUNCTION_PERCENTILE:
+			sketchField := proto.Clone(f).(*databasev1.FieldSpec)
+			sketchField.FieldType = databasev1.FieldType_FIELD_TYPE_DATA_BINARY
+			sketchField.EncodingMethod = databasev1.EncodingMethod_ENCODING_METHOD_UNSPECIFIED
+			fields = append(fields, sketchField)
+		default:
+			fields = append(fields, f)
+		}
+	}
+	return &databasev1.Measure{
+		Metadata:    level.metadata,
+		TagFamilies: m.GetTagFamilies(),
+		Fields:      fields,
+		Entity:      m.GetEntity(),
+		Interval:    level.spec.GetInterval(),
+	}
+}
+
+// createOrUpdateRollups creates or updates the derived groups and measures storing the rollups of the measure.
+// A derived group has the resource options of the measure's group but the ttl of the rollup.
+func createOrUpdateRollups(ctx context.Context, repo metadata.Repo, m *databasev1.Measure) error {
+	levels, err := parseRollups(m)
+	if err != nil {
+		return err
+	}
+	group, err := repo.GroupRegistry().GetGroup(ctx, m.GetMetadata().GetGroup())
+	if err != nil {
+		return err
+	}
+	for _, level := range levels {
+		opts := proto.Clone(group.GetResourceOpts()).(*commonv1.ResourceOpts)
+		if level.spec.GetTtl() != nil {
+			opts.Ttl = level.spec.GetTtl()
+		}
+		if err = createOrUpdateRollupGroup(ctx, repo.GroupRegistry(), &commonv1.Group{
+			Metadata:     &commonv1.Metadata{Name: level.metadata.GetGroup()},
+			Catalog:      group.GetCatalog(),
+			ResourceOpts: opts,
+		}); err != nil {
+			return err
+		}
+		if err = createOrUpdateRollupMeasure(ctx, repo.MeasureRegistry(), newRollupMeasure(m, level)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createOrUpdateRollupGroup(ctx context.Context, groupRegistry schema.Group, group *commonv1.Group) error {
+	old, err := groupRegistry.GetGroup(ctx, group.GetMetadata().GetName())
+	if err != nil && !errors.Is(err, schema.ErrGRPCResourceNotFound) {
+		return err
+	}
+	if old == nil {
+		return groupRegistry.CreateGroup(ctx, group)
+	}
+	if cmp.Diff(group, old,
+		protocmp.IgnoreUnknown(),
+		protocmp.IgnoreFields(&commonv1.Group{}, "updated_at"),
+		protocmp.IgnoreFields(&commonv1.Metadata{}, "id", "create_revision", "mod_revision"),
+		protocmp.Transform()) == "" {
+		return nil
+	}
+	return groupRegistry.UpdateGroup(ctx, group)
+}
+
+func createOrUpdateRollupMeasure(ctx context.Context, measureRegistry schema.Measure, m *databasev1.Measure) error {
+	old, err := measureRegistry.GetMeasure(ctx, m.GetMetadata())
+	if err != nil && !errors.Is(err, schema.ErrGRPCResourceNotFound) {
+		return err
+	}
+	if old == nil {
+		_, err = measureRegistry.CreateMeasure(ctx, m)
+		return err
+	}
+	if cmp.Diff(m, old,
+		protocmp.IgnoreUnknown(),
+		protocmp.IgnoreFields(&databasev1.Measure{}, "updated_at"),
+		protocmp.IgnoreFields(&commonv1.Metadata{}, "id", "create_revision", "mod_revision"),
+		protocmp.Transform()) == "" {
+		return nil
+	}
+	_, err = measureRegistry.UpdateMeasure(ctx, m)
+	return err
+}
+
+// rollupManager rolls up the data points of the measures having rollups in the background.
+// Each round aggregates the windows of a rollup which are complete, that is,
+// the raw data points are older than the delay, or the finer rollup wrote them in the earlier rounds,
+// which leaves the time for the writes to be applied.
+// The windows are tracked in memory, so the latest complete window of each rollup is aggregated again after a restart,
+// which overwrites the data points written before.
+type rollupManager struct {
+	schemaRepo *schemaRepo
+	pipeline   queue.Queue
+	l          *logger.Logger
+	closer     *run.Closer
+	// watermarks are the ends of the windows rolled up, by the derived groups
+	watermarks map[string]time.Time
+	interval   time.Duration
+	delay      time.Duration
+}
+
+func newRollupManager(schemaRepo *schemaRepo, pipeline queue.Queue, l *logger.Logger, interval, delay time.Duration) *rollupManager {
+	return &rollupManager{
+		schemaRepo: schemaRepo,
+		pipeline:   pipeline,
+		l:          l,
+		closer:     run.NewCloser(1),
+		watermarks: make(map[string]time.Time),
+		interval:   interval,
+		delay:      delay,
+	}
+}
+
+func (r *rollupManager) start() {
+	go func() {
+		defer r.closer.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.closer.CloseNotify():
+				return
+			case now := <-ticker.C:
+				r.rollup(r.closer.Ctx(), now)
+			}
+		}
+	}()
+}
+
+func (r *rollupManager) close() {
+	r.closer.CloseThenWait()
+}
+
+func (r *rollupManager) rollup(ctx context.Context, now time.Time) {
+	for _, g := range r.schemaRepo.LoadAllGroups() {
+		group := g.GetSchema().GetMetadata().GetName()
+		listCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		measures, err := r.schemaRepo.metadata.MeasureRegistry().ListMeasure(listCtx, schema.ListOpt{Group: group})
+		cancel()
+		if err != nil {
+			r.l.Warn().Err(err).Str("group", group).Msg("fail to list the measures to roll up")
+			continue
+		}
+		for _, m := range measures {
+			if len(m.GetRollups()) == 0 {
+				continue
+			}
+			if err = r.rollupMeasure(ctx, m, now); err != nil {
+				r.l.Warn().Err(err).Str("group", group).Str("measure", m.GetMetadata().GetName()).Msg("fail to roll up the data points")
+			}
+		}
+	}
+}
+
+func (r *rollupManager) rollupMeasure(ctx context.Context, m *databasev1.Measure, now time.Time) error {
+	levels, err := parseRollups(m)
+	if err != nil {
+		return err
+	}
+	src, ok := r.schemaRepo.loadMeasure(m.GetMetadata())
+	if !ok {
+		return nil
+	}
+	end := now.Add(-r.delay)
+	raw := true
+	for _, level := range levels {
+		dst, ok := r.schemaRepo.loadMeasure(level.metadata)
+		if !ok {
+			return nil
+		}
+		key := level.metadata.GetGroup()
+		next, ok := r.watermarks[key]
+		if !ok {
+			next = end.Truncate(level.interval).Add(-level.interval)
+		}
+		finished := next
+		for ; !next.Add(level.interval).After(end); next = next.Add(level.interval) {
+			if err = r.rollupWindow(ctx, src, dst, raw, timestamp.NewSectionTimeRange(next, next.Add(level.interval))); err != nil {
+				return err
+			}
+			r.watermarks[key] = next.Add(level.interval)
+		}
+		src, end, raw = dst, finished, false
+	}
+	return nil
+}
+
+// rollupWindow aggregates the data points of src in the window into a data point per series, and writes them to dst.
+// The series are rolled up shard by shard, and written to the same shards of dst.
+func (r *rollupManager) rollupWindow(ctx context.Context, src, dst *measure, raw bool, window timestamp.TimeRange) error {
+	entity := make([]*modelv1.TagValue, len(src.schema.GetEntity().GetTagNames()))
+	for i := range entity {
+		entity[i] = pbv1.AnyTagValue
+	}
+	tagProjection := make([]pbv1.TagProjection, 0, len(dst.schema.GetTagFamilies()))
+	for _, tf := range dst.schema.GetTagFamilies() {
+		tp := pbv1.TagProjection{Family: tf.GetName()}
+		for _, t := range tf.GetTags() {
+			tp.Names = append(tp.Names, t.GetName())
+		}
+		tagProjection = append(tagProjection, tp)
+	}
+	fieldProjection := make([]string, 0, len(dst.schema.GetFields()))
+	for _, f := range dst.schema.GetFields() {
+		fieldProjection = append(fieldProjection, f.GetName())
+	}
+	for shardID := uint32(0); shardID < src.shardNum; shardID++ {
+		result, err := src.Query(ctx, pbv1.MeasureQueryOptions{
+			Name:            src.name,
+			TimeRange:       &window,
+			Entity:          entity,
+			TagProjection:   tagProjection,
+			FieldProjection: fieldProjection,
+			ShardIDs:        []common.ShardID{common.ShardID(shardID)},
+		})
+		if err != nil {
+			return err
+		}
+		series, err := aggregateRollup(result, dst.schema, raw)
+		result.Release()
+		if err != nil {
+			return err
+		}
+		if err = r.write(dst, shardID, window.Start, series); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *rollupManager) write(dst *measure, shardID uint32, ts time.Time, series map[common.SeriesID]*rollupSeries) error {
+	if len(series) == 0 {
+		return nil
+	}
+	publisher := r.pipeline.NewBatchPublisher()
+	for _, s := range series {
+		fields := make([]*modelv1.FieldValue, len(s.fields))
+		for i := range s.fields {
+			fields[i] = s.fields[i].val()
+		}
+		iwr := &measurev1.InternalWriteRequest{
+			Request: &measurev1.WriteRequest{
+				MessageId: uint64(time.Now().UnixNano()),
+				Metadata:  dst.schema.GetMetadata(),
+				DataPoint: &measurev1.DataPointValue{
+					Timestamp:   timestamppb.New(ts),
+					TagFamilies: s.tagFamilies,
+					Fields:      fields,
+				},
+			},
+			EntityValues: s.entityValues,
+			ShardId:      shardID,
+		}
+		message := bus.NewBatchMessageWithNode(bus.MessageID(time.Now().UnixNano()), "local", iwr)
+		if _, err := publisher.Publish(apiData.TopicMeasureWrite, message); err != nil {
+			_ = publisher.Close()
+			return err
+		}
+	}
+	return publisher.Close()
+}
+
+// rollupSeries is the data point a series is rolled up into, which has the tags of the latest data point in the window.
+type rollupSeries struct {
+	tagFamilies  []*modelv1.TagFamilyForWrite
+	entityValues []*modelv1.TagValue
+	fields       []fieldAggregator
+	latest       int64
+}
+
+// aggregateRollup aggregates the data points of the result by the series, whose fields are aggregated as the schema declares.
+// raw tells whether the data points are raw ones, otherwise they are the counts of a finer rollup which are summed up.
+func aggregateRollup(result pbv1.MeasureQueryResult, m *databasev1.Measure, raw bool) (map[common.SeriesID]*rollupSeries, error) {
+	series := make(map[common.SeriesID]*rollupSeries)
+	for r := result.Pull(); r != nil; r = result.Pull() {
+		s, ok := series[r.SID]
+		if !ok {
+			s = &rollupSeries{latest: -1}
+			for _, f := range m.GetFields() {
+				fa, err := newFieldAggregator(f, raw)
+				if err != nil {
+					return nil, err
+				}
+				s.fields = append(s.fields, fa)
+			}
+			series[r.SID] = s
+		}
+		for i, f := range m.GetFields() {
+			for _, rf := range r.Fields {
+				if rf.Name != f.GetName() {
+					continue
+				}
+				for _, v := range rf.Values {
+					s.fields[i].in(v)
+				}
+			}
+		}
+		latest := -1
+		for i, ts := range r.Timestamps {
+			if ts > s.latest {
+				s.latest, latest = ts, i
+			}
+		}
+		if latest >= 0 {
+			s.setTags(m, r.TagFamilies, latest)
+		}
+	}
+	return series, nil
+}
+
+func (s *rollupSeries) setTags(m *databasev1.Measure, tagFamilies []pbv1.TagFamily, idx int) {
+	values := make(map[string]*modelv1.TagValue)
+	for _, tf := range tagFamilies {
+		for _, t := range tf.Tags {
+			if idx < len(t.Values) {
+				values[t.Name] = t.Values[idx]
+			}
+		}
+	}
+	tagValue := func(name string) *modelv1.TagValue {
+		if v, ok := values[name]; ok && v != nil {
+			return v
+		}
+		return pbv1.NullTagValue
+	}
+	s.tagFamilies = make([]*modelv1.TagFamilyForWrite, len(m.GetTagFamilies()))
+	for i, tf := range m.GetTagFamilies() {
+		tags := make([]*modelv1.TagValue, len(tf.GetTags()))
+		for j, t := range tf.GetTags() {
+			tags[j] = tagValue(t.GetName())
+		}
+		s.tagFamilies[i] = &modelv1.TagFamilyForWrite{Tags: tags}
+	}
+	s.entityValues = make([]*modelv1.TagValue, len(m.GetEntity().GetTagNames()))
+	for i, name := range m.GetEntity().GetTagNames() {
+		s.entityValues[i] = tagValue(name)
+	}
+}
+
+// fieldAggregator aggregates the values of a field, skipping the null ones.
+type fieldAggregator interface {
+	in(v *modelv1.FieldValue)
+	val() *modelv1.FieldValue
+}
+
+func newFieldAggregator(spec *databasev1.FieldSpec, raw bool) (fieldAggregator, error) {
+	af := spec.GetAggregateFunction()
+	switch af {
+	case modelv1.AggregationFunction_AGGREGATION_FUNCTION_COUNT_DISTINCT:
+		return &sketchAggregator{sketch: aggregation.NewHyperLogLog()}, nil
+	case modelv1.AggregationFunction_AGGREGATION_FUNCTION_PERCENTILE:
+		return &sketchAggregator{sketch: aggregation.NewTDigest()}, nil
+	}
+	if !raw && af == modelv1.AggregationFunction_AGGREGATION_FUNCTION_COUNT {
+		af = modelv1.AggregationFunction_AGGREGATION_FUNCTION_SUM
+	}
+	switch spec.GetFieldType() {
+	case databasev1.FieldType_FIELD_TYPE_INT:
+		f, err := aggregation.NewFunc[int64](af)
+		if err != nil {
+			return nil, err
+		}
+		return &numberAggregator[int64]{f: f}, nil
+	case databasev1.FieldType_FIELD_TYPE_FLOAT:
+		f, err := aggregation.NewFunc[float64](af)
+		if err != nil {
+			return nil, err
+		}
+		return &numberAggregator[float64]{f: f}, nil
+	default:
+		return nil, errors.Errorf("field %s of type %s can't be rolled up", spec.GetName(), spec.GetFieldType())
+	}
+}
+
+type numberAggregator[N aggregation.Number] struct {
+	f     aggregation.Func[N]
+	count int
+}
+
+func (a *numberAggregator[N]) in(v *modelv1.FieldValue) {
+	n, err := aggregation.FromFieldValue[N](v)
+	if err != nil {
+		return
+	}
+	a.f.In(n)
+	a.count++
+}
+
+func (a *numberAggregator[N]) val() *modelv1.FieldValue {
+	if a.count == 0 {
+		return pbv1.NullFieldValue
+	}
+	v, err := aggregation.ToFieldValue(a.f.Val())
+	if err != nil {
+		return pbv1.NullFieldValue
+	}
+	return v
+}
+
+// sketch is a mergeable summary of the values, which is stored as binary data.
+type sketch interface {
+	AddFieldValue(v *modelv1.FieldValue) error
+	Marshal() []byte
+}
+
+// sketchAggregator adds the raw values to a sketch, or merges the sketches of a finer rollup.
+type sketchAggregator struct {
+	sketch sketch
+	count  int
+}
+
+func (a *sketchAggregator) in(v *modelv1.FieldValue) {
+	if _, isNull := v.GetValue().(*modelv1.FieldValue_Null); isNull {
+		return
+	}
+	if err := a.sketch.AddFieldValue(v); err != nil {
+		return
+	}
+	a.count++
+}
+
+func (a *sketchAggregator) val() *modelv1.FieldValue {
+	if a.count == 0 {
+		return pbv1.NullFieldValue
+	}
+	return &modelv1.FieldValue{Value: &modelv1.FieldValue_BinaryData{BinaryData: a.sketch.Marshal()}}
+}
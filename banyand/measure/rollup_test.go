@@ -0,0 +1,128 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+func Test_parseRollups(t *testing.T) {
+	m := &databasev1.Measure{
+		Metadata: &commonv1.Metadata{Group: "sw_metric", Name: "service_cpm"},
+		Interval: "1m",
+		Rollups:  []*databasev1.RollupSpec{{Interval: "1d"}, {Interval: "1h"}},
+	}
+	levels, err := parseRollups(m)
+	require.NoError(t, err)
+	require.Len(t, levels, 2)
+	assert.Equal(t, "sw_metric_rollup_1h", levels[0].metadata.GetGroup())
+	assert.Equal(t, "service_cpm", levels[0].metadata.GetName())
+	assert.Equal(t, "sw_metric_rollup_1d", levels[1].metadata.GetGroup())
+
+	m.Rollups = []*databasev1.RollupSpec{{Interval: "1h"}, {Interval: "90m"}}
+	_, err = parseRollups(m)
+	assert.Error(t, err, "90m isn't a multiple of 1h")
+	m.Rollups = []*databasev1.RollupSpec{{Interval: "1x"}}
+	_, err = parseRollups(m)
+	assert.Error(t, err)
+}
+
+type fakeMeasureQueryResult struct {
+	results []*pbv1.MeasureResult
+}
+
+func (f *fakeMeasureQueryResult) Pull() *pbv1.MeasureResult {
+	if len(f.results) == 0 {
+		return nil
+	}
+	r := f.results[0]
+	f.results = f.results[1:]
+	return r
+}
+
+func (f *fakeMeasureQueryResult) Release() {}
+
+func Test_aggregateRollup(t *testing.T) {
+	m := &databasev1.Measure{
+		TagFamilies: []*databasev1.TagFamilySpec{{
+			Name: "default",
+			Tags: []*databasev1.TagSpec{{Name: "id", Type: databasev1.TagType_TAG_TYPE_STRING}, {Name: "layer", Type: databasev1.TagType_TAG_TYPE_STRING}},
+		}},
+		Fields: []*databasev1.FieldSpec{
+			{Name: "total", FieldType: databasev1.FieldType_FIELD_TYPE_INT, AggregateFunction: modelv1.AggregationFunction_AGGREGATION_FUNCTION_SUM},
+			{Name: "calls", FieldType: databasev1.FieldType_FIELD_TYPE_INT, AggregateFunction: modelv1.AggregationFunction_AGGREGATION_FUNCTION_COUNT},
+			{Name: "latency", FieldType: databasev1.FieldType_FIELD_TYPE_FLOAT, AggregateFunction: modelv1.AggregationFunction_AGGREGATION_FUNCTION_MAX},
+		},
+		Entity: &databasev1.Entity{TagNames: []string{"id"}},
+	}
+	str := func(s string) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: s}}}
+	}
+	newResult := func() *fakeMeasureQueryResult {
+		return &fakeMeasureQueryResult{results: []*pbv1.MeasureResult{
+			{
+				SID:        1,
+				Timestamps: []int64{2, 1},
+				TagFamilies: []pbv1.TagFamily{{Name: "default", Tags: []pbv1.Tag{
+					{Name: "id", Values: []*modelv1.TagValue{str("svc"), str("svc")}},
+					{Name: "layer", Values: []*modelv1.TagValue{str("mesh"), str("general")}},
+				}}},
+				Fields: []pbv1.Field{
+					{Name: "total", Values: []*modelv1.FieldValue{int64FieldValue(3), int64FieldValue(4)}},
+					{Name: "calls", Values: []*modelv1.FieldValue{int64FieldValue(10), pbv1.NullFieldValue}},
+					{Name: "latency", Values: []*modelv1.FieldValue{float64FieldValue(0.5), float64FieldValue(1.5)}},
+				},
+			},
+			{
+				SID:        1,
+				Timestamps: []int64{0},
+				TagFamilies: []pbv1.TagFamily{{Name: "default", Tags: []pbv1.Tag{
+					{Name: "id", Values: []*modelv1.TagValue{str("svc")}},
+					{Name: "layer", Values: []*modelv1.TagValue{str("old")}},
+				}}},
+				Fields: []pbv1.Field{
+					{Name: "total", Values: []*modelv1.FieldValue{int64FieldValue(5)}},
+					{Name: "calls", Values: []*modelv1.FieldValue{int64FieldValue(20)}},
+					{Name: "latency", Values: []*modelv1.FieldValue{pbv1.NullFieldValue}},
+				},
+			},
+		}}
+	}
+
+	series, err := aggregateRollup(newResult(), m, true)
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	s := series[1]
+	assert.Equal(t, int64(12), s.fields[0].val().GetInt().GetValue())
+	assert.Equal(t, int64(2), s.fields[1].val().GetInt().GetValue(), "the non-null values are counted")
+	assert.Equal(t, 1.5, s.fields[2].val().GetFloat().GetValue())
+	assert.Equal(t, "mesh", s.tagFamilies[0].GetTags()[1].GetStr().GetValue(), "the tags of the latest data point are kept")
+	assert.Equal(t, []*modelv1.TagValue{str("svc")}, s.entityValues)
+
+	series, err = aggregateRollup(newResult(), m, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), series[1].fields[1].val().GetInt().GetValue(), "the counts of a finer rollup are summed up")
+}
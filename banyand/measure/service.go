@@ -21,12 +21,15 @@ import (
 	"context"
 	"math"
 	"path"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/apache/skywalking-banyandb/api/data"
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/banyand/internal/wal"
 	"github.com/apache/skywalking-banyandb/banyand/metadata"
 	"github.com/apache/skywalking-banyandb/banyand/observability"
 	"github.com/apache/skywalking-banyandb/banyand/queue"
@@ -34,6 +37,7 @@ import (
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/run"
 	resourceSchema "github.com/apache/skywalking-banyandb/pkg/schema"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
 )
 
 var (
@@ -48,19 +52,35 @@ type Service interface {
 	run.Config
 	run.Service
 	Query
+	// MemPartCount returns the number of in-memory parts which aren't flushed to the disk yet.
+	MemPartCount() int
+	// DropSegments removes the segments of a group ending at or before the deadline, and returns the number of them.
+	DropSegments(group string, deadline time.Time) int
+	// Archive writes the flushed data of the groups into the dir, all groups if groups is empty.
+	Archive(dir string, groups []string) ([]*databasev1.SnapshotArchive, error)
+	// Import registers the segments of the groups archived in the dir, which the node doesn't have.
+	Import(dir string, groups []string) ([]*databasev1.SnapshotArchive, error)
+	// SetRecorder makes the service log the writes to the recorder before applying them, it should be called before PreRun.
+	SetRecorder(recorder wal.Recorder)
 }
 
 var _ Service = (*service)(nil)
 
 type service struct {
-	schemaRepo    schemaRepo
-	writeListener bus.MessageListener
-	metadata      metadata.Repo
-	pipeline      queue.Server
-	localPipeline queue.Queue
-	option        option
-	l             *logger.Logger
-	root          string
+	schemaRepo          schemaRepo
+	writeListener       bus.MessageListener
+	rollup              *rollupManager
+	metadata            metadata.Repo
+	pipeline            queue.Server
+	localPipeline       queue.Queue
+	option              option
+	l                   *logger.Logger
+	root                string
+	maxPooledBufferSize run.Bytes
+	maxPooledBlockSize  run.Bytes
+	pooledBuffersBudget run.Bytes
+	rollupInterval      time.Duration
+	rollupDelay         time.Duration
 }
 
 func (s *service) Measure(metadata *commonv1.Metadata) (Measure, error) {
@@ -75,12 +95,61 @@ func (s *service) LoadGroup(name string) (resourceSchema.Group, bool) {
 	return s.schemaRepo.LoadGroup(name)
 }
 
+func (s *service) MemPartCount() int {
+	allTime := timestamp.NewInclusiveTimeRange(time.Unix(0, 0), time.Unix(0, math.MaxInt64))
+	var count int
+	for _, g := range s.schemaRepo.LoadAllGroups() {
+		tsdb, ok := g.SupplyTSDB().(storage.TSDB[*tsTable, option])
+		if !ok {
+			continue
+		}
+		for _, tw := range tsdb.SelectTSTables(allTime) {
+			if snp := tw.Table().currentSnapshot(); snp != nil {
+				count += snp.memPartCount()
+				snp.decRef()
+			}
+			tw.DecRef()
+		}
+	}
+	return count
+}
+
+func (s *service) DropSegments(group string, deadline time.Time) int {
+	g, ok := s.schemaRepo.LoadGroup(group)
+	if !ok {
+		return 0
+	}
+	tsdb, ok := g.SupplyTSDB().(storage.TSDB[*tsTable, option])
+	if !ok {
+		return 0
+	}
+	return tsdb.DropSegments(deadline)
+}
+
 func (s *service) FlagSet() *run.FlagSet {
 	flagS := run.NewFlagSet("storage")
 	flagS.StringVar(&s.root, "measure-root-path", "/tmp", "the root path of database")
 	flagS.DurationVar(&s.option.flushTimeout, "measure-flush-timeout", defaultFlushTimeout, "the memory data timeout of measure")
 	s.option.mergePolicy = newDefaultMergePolicy()
 	flagS.Uint64Var(&s.option.mergePolicy.maxFanOutSize, "max-fan-out-size", math.MaxUint64, "the upper bound of a single file size after merge")
+	flagS.DurationVar(&s.option.seriesIndex.CommitInterval, "measure-series-index-commit-interval", storage.DefaultSeriesIndexCommitInterval,
+		"the max time the new series wait in memory before they are committed to the series index")
+	flagS.IntVar(&s.option.seriesIndex.MaxBufferedDocs, "measure-series-index-max-buffered-docs", storage.DefaultSeriesIndexMaxBufferedDocs,
+		"the max number of the new series buffered in memory, 0 commits every write to the series index at once")
+	flagS.BoolVar(&s.option.seriesIndex.AsyncFsync, "measure-series-index-async-fsync", true,
+		"sync the series index to the disk in the background instead of on every commit")
+	s.maxPooledBufferSize = defaultMaxPooledBufferSize
+	flagS.VarP(&s.maxPooledBufferSize, "measure-max-pooled-buffer-size", "", "the max capacity of a pooled buffer, the larger ones are released to the runtime")
+	s.maxPooledBlockSize = defaultMaxPooledBlockSize
+	flagS.VarP(&s.maxPooledBlockSize, "measure-max-pooled-block-size", "", "the max capacity of a pooled block or block cursor, the larger ones are released to the runtime")
+	s.pooledBuffersBudget = defaultPooledBuffersBudget
+	flagS.VarP(&s.pooledBuffersBudget, "measure-pooled-buffers-budget", "", "the max bytes retained by all the pooled buffers, 0 means unbounded")
+	flagS.DurationVar(&s.rollupInterval, "measure-rollup-check-interval", defaultRollupCheckInterval, "how often to roll up the complete windows of the measures")
+	flagS.DurationVar(&s.rollupDelay, "measure-rollup-delay", defaultRollupDelay, "how long to wait for the late data points before a window is rolled up")
+	flagS.BoolVar(&s.option.ioOpts.Direct, "measure-direct-io", false,
+		"read and write the parts of the merges and the flushes with O_DIRECT, bypassing the page cache")
+	flagS.BoolVar(&s.option.ioOpts.DropCache, "measure-drop-cache", false,
+		"drop the pages of the parts read and written by the merges and the flushes, which would evict the ones the queries depend on")
 	return flagS
 }
 
@@ -88,9 +157,22 @@ func (s *service) Validate() error {
 	if s.root == "" {
 		return errEmptyRootPath
 	}
+	if s.option.seriesIndex.MaxBufferedDocs > 0 && s.option.seriesIndex.CommitInterval <= 0 {
+		return errors.New("measure-series-index-commit-interval must be positive to buffer the series")
+	}
+	if s.rollupInterval <= 0 {
+		return errors.New("measure-rollup-check-interval must be positive")
+	}
+	if s.rollupDelay < 0 {
+		return errors.New("measure-rollup-delay must not be negative")
+	}
 	return nil
 }
 
+func (s *service) SetRecorder(recorder wal.Recorder) {
+	s.option.recorder = recorder
+}
+
 func (s *service) Name() string {
 	return "measure"
 }
@@ -103,16 +185,32 @@ func (s *service) PreRun(_ context.Context) error {
 	s.l = logger.GetLogger(s.Name())
 	path := path.Join(s.root, s.Name())
 	observability.UpdatePath(path)
+	observability.UpdateGroupRoot(path)
+	bigValueCapacity.SetMax(int64(s.maxPooledBufferSize))
+	bigValuePool.SetBudget(int64(s.pooledBuffersBudget))
+	blockCapacity.SetMax(int64(s.maxPooledBlockSize))
+	blockCursorCapacity.SetMax(int64(s.maxPooledBlockSize))
+	if s.option.recorder != nil && !s.option.recorder.Enabled() {
+		s.option.recorder = nil
+	}
 	s.localPipeline = queue.Local()
 	s.schemaRepo = newSchemaRepo(path, s)
 	// run a serial watcher
 
-	s.writeListener = setUpWriteCallback(s.l, &s.schemaRepo)
+	s.writeListener = setUpWriteCallback(s.l, &s.schemaRepo, s.option.recorder)
 	err := s.pipeline.Subscribe(data.TopicMeasureWrite, s.writeListener)
 	if err != nil {
 		return err
 	}
-	return s.localPipeline.Subscribe(data.TopicMeasureWrite, s.writeListener)
+	if err = s.localPipeline.Subscribe(data.TopicMeasureWrite, s.writeListener); err != nil {
+		return err
+	}
+	if err = s.pipeline.Subscribe(data.TopicMeasureChanges, setUpChangesCallback(s.l, &s.schemaRepo)); err != nil {
+		return err
+	}
+	s.rollup = newRollupManager(&s.schemaRepo, s.localPipeline, s.l, s.rollupInterval, s.rollupDelay)
+	s.rollup.start()
+	return nil
 }
 
 func (s *service) Serve() run.StopNotify {
@@ -120,6 +218,9 @@ func (s *service) Serve() run.StopNotify {
 }
 
 func (s *service) GracefulStop() {
+	if s.rollup != nil {
+		s.rollup.close()
+	}
 	s.localPipeline.GracefulStop()
 	s.schemaRepo.Close()
 }
@@ -42,6 +42,7 @@ const (
 	snapshotCreatorFlusher
 	snapshotCreatorMerger
 	snapshotCreatorMergedFlusher
+	snapshotCreatorImporter
 )
 
 type snapshot struct {
@@ -65,6 +66,16 @@ func (s *snapshot) getParts(dst []*part, minTimestamp, maxTimestamp int64) ([]*p
 	return dst, count
 }
 
+func (s *snapshot) memPartCount() int {
+	var count int
+	for _, p := range s.parts {
+		if p.mp != nil {
+			count++
+		}
+	}
+	return count
+}
+
 func (s *snapshot) incRef() {
 	atomic.AddInt32(&s.ref, 1)
 }
@@ -39,6 +39,7 @@ import (
 	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
 	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/banyand/tsdb"
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 	"github.com/apache/skywalking-banyandb/pkg/flow"
 	"github.com/apache/skywalking-banyandb/pkg/flow/streaming"
@@ -47,6 +48,8 @@ import (
 	"github.com/apache/skywalking-banyandb/pkg/partition"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 	"github.com/apache/skywalking-banyandb/pkg/query/logical"
+	logical_measure "github.com/apache/skywalking-banyandb/pkg/query/logical/measure"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
 )
 
 const (
@@ -78,12 +81,17 @@ type topNStreamingProcessor struct {
 	l             *logger.Logger
 	pipeline      queue.Queue
 	topNSchema    *databasev1.TopNAggregation
+	metadata      *commonv1.Metadata
 	src           chan interface{}
 	in            chan flow.StreamRecord
 	errCh         <-chan error
 	stopCh        chan struct{}
+	// groupByTagNames are the tags of the dimension ranked by this processor
+	groupByTagNames []string
 	flow.ComponentState
+	// interval is the slide of the windows, which down-samples the results
 	interval      time.Duration
+	windowSize    time.Duration
 	sortDirection modelv1.Sort
 }
 
@@ -137,15 +145,16 @@ func (t *topNStreamingProcessor) writeStreamRecord(record flow.StreamRecord) err
 	if !ok {
 		return errors.New("invalid data type")
 	}
-	// down-sample the start of the timeWindow to a time-bucket
-	eventTime := t.downSampleTimeBucket(record.TimestampMillis())
+	// down-sample the start of the last slide in the timeWindow to a time-bucket,
+	// which is the start of the timeWindow if it's tumbling
+	eventTime := t.downSampleTimeBucket(record.TimestampMillis() + t.windowSize.Milliseconds() - t.interval.Milliseconds())
 	timeBucket := eventTime.Format(timeBucketFormat)
 	var err error
 	publisher := t.pipeline.NewBatchPublisher()
 	defer publisher.Close()
 	for group, tuples := range tuplesGroups {
 		if e := t.l.Debug(); e.Enabled() {
-			e.Str("TopN", t.topNSchema.GetMetadata().GetName()).
+			e.Str("TopN", t.metadata.GetName()).
 				Str("group", group).
 				Int("rankNums", len(tuples)).
 				Msg("Write tuples")
@@ -163,7 +172,7 @@ func (t *topNStreamingProcessor) writeData(publisher queue.BatchPublisher, event
 	group string, data flow.Data, rankNum int,
 ) error {
 	var tagValues []*modelv1.TagValue
-	if len(t.topNSchema.GetGroupByTagNames()) > 0 {
+	if len(t.groupByTagNames) > 0 {
 		var ok bool
 		if tagValues, ok = data[3].([]*modelv1.TagValue); !ok {
 			return errors.New("fail to extract tag values from topN result")
@@ -187,7 +196,7 @@ func (t *topNStreamingProcessor) writeData(publisher queue.BatchPublisher, event
 	iwr := &measurev1.InternalWriteRequest{
 		Request: &measurev1.WriteRequest{
 			MessageId: uint64(time.Now().UnixNano()),
-			Metadata:  t.topNSchema.GetMetadata(),
+			Metadata:  t.metadata,
 			DataPoint: &measurev1.DataPointValue{
 				Timestamp: timestamppb.New(eventTime),
 				TagFamilies: []*modelv1.TagFamilyForWrite{
@@ -244,7 +253,7 @@ func (t *topNStreamingProcessor) downSampleTimeBucket(eventTimeMillis int64) tim
 }
 
 func (t *topNStreamingProcessor) locate(tagValues []*modelv1.TagValue, rankNum int) (*pbv1.Series, common.ShardID, error) {
-	if len(tagValues) != 0 && len(t.topNSchema.GetGroupByTagNames()) != len(tagValues) {
+	if len(tagValues) != 0 && len(t.groupByTagNames) != len(tagValues) {
 		return nil, 0, errors.New("no enough tag values for the entity")
 	}
 	// Subject: topN aggregation Name
@@ -255,7 +264,7 @@ func (t *topNStreamingProcessor) locate(tagValues []*modelv1.TagValue, rankNum i
 	// 2) rank number
 	// >3) group tag values if needed
 	series := &pbv1.Series{
-		Subject:      t.topNSchema.GetMetadata().GetName(),
+		Subject:      t.metadata.GetName(),
 		EntityValues: make([]*modelv1.TagValue, 1+1+len(tagValues)),
 	}
 
@@ -281,21 +290,35 @@ func (t *topNStreamingProcessor) locate(tagValues []*modelv1.TagValue, rankNum i
 	if err := series.Marshal(); err != nil {
 		return nil, 0, fmt.Errorf("fail to marshal series: %w", err)
 	}
-	id, err := partition.ShardID(series.Buffer, t.m.shardNum)
+	id, err := t.shardID(series)
 	if err != nil {
 		return nil, 0, err
 	}
 	return series, common.ShardID(id), nil
 }
 
+func (t *topNStreamingProcessor) shardID(series *pbv1.Series) (uint, error) {
+	tagNum := t.m.sharding.RoutingTagNum(len(series.EntityValues))
+	if tagNum == len(series.EntityValues) {
+		return partition.ShardID(series.Buffer, t.m.shardNum)
+	}
+	values := append(tsdb.EntityValues{tsdb.StrValue(series.Subject)}, series.EntityValues[:tagNum]...)
+	entity, err := values.ToEntity()
+	if err != nil {
+		return 0, err
+	}
+	return t.m.sharding.ShardID(entity, t.m.shardNum)
+}
+
 func (t *topNStreamingProcessor) start() *topNStreamingProcessor {
-	t.errCh = t.streamingFlow.Window(streaming.NewTumblingTimeWindows(t.interval)).
-		AllowedMaxWindows(int(t.topNSchema.GetLruSize())).
+	// every data point falls into windowSize/interval windows, which are kept in the memory together
+	t.errCh = t.streamingFlow.Window(streaming.NewSlidingTimeWindows(t.windowSize, t.interval)).
+		AllowedMaxWindows(int(t.topNSchema.GetLruSize())*int(t.windowSize/t.interval)).
 		TopN(int(t.topNSchema.GetCountersNumber()),
 			streaming.WithSortKeyExtractor(func(record flow.StreamRecord) int64 {
 				return record.Data().(flow.Data)[2].(int64)
 			}),
-			orderBy(t.topNSchema.GetFieldValueSort()),
+			orderBy(t.sortDirection),
 			streaming.WithGroupKeyExtractor(func(record flow.StreamRecord) string {
 				return record.Data().(flow.Data)[1].(string)
 			}),
@@ -313,7 +336,7 @@ func orderBy(sort modelv1.Sort) streaming.TopNOption {
 
 func (t *topNStreamingProcessor) handleError() {
 	for err := range t.errCh {
-		t.l.Err(err).Str("topN", t.topNSchema.GetMetadata().GetName()).
+		t.l.Err(err).Str("topN", t.metadata.GetName()).
 			Msg("error occurred during flow setup or process")
 	}
 	t.stopCh <- struct{}{}
@@ -358,45 +381,53 @@ func (manager *topNProcessorManager) onMeasureWrite(request *measurev1.InternalW
 }
 
 func (manager *topNProcessorManager) start() error {
-	interval := manager.m.interval
 	for _, topNSchema := range manager.topNSchemas {
-		sortDirections := make([]modelv1.Sort, 0, 2)
-		if topNSchema.GetFieldValueSort() == modelv1.Sort_SORT_UNSPECIFIED {
-			sortDirections = append(sortDirections, modelv1.Sort_SORT_ASC, modelv1.Sort_SORT_DESC)
-		} else {
-			sortDirections = append(sortDirections, topNSchema.GetFieldValueSort())
+		windowSize, windowSlide, err := topNWindow(topNSchema, manager.m.interval)
+		if err != nil {
+			return err
 		}
-
-		processorList := make([]*topNStreamingProcessor, len(sortDirections))
-		for i, sortDirection := range sortDirections {
-			srcCh := make(chan interface{})
-			src, _ := sources.NewChannel(srcCh)
-			streamingFlow := streaming.New(src)
-
-			filters, buildErr := manager.buildFilter(topNSchema.GetCriteria())
-			if buildErr != nil {
-				return buildErr
+		var processorList []*topNStreamingProcessor
+		for _, dimension := range topNDimensions(topNSchema) {
+			sortDirections := make([]modelv1.Sort, 0, 2)
+			if dimension.fieldValueSort == modelv1.Sort_SORT_UNSPECIFIED {
+				sortDirections = append(sortDirections, modelv1.Sort_SORT_ASC, modelv1.Sort_SORT_DESC)
+			} else {
+				sortDirections = append(sortDirections, dimension.fieldValueSort)
 			}
-			streamingFlow = streamingFlow.Filter(filters)
 
-			mapper, innerErr := manager.buildMapper(topNSchema.GetFieldName(), topNSchema.GetGroupByTagNames()...)
-			if innerErr != nil {
-				return innerErr
-			}
-			streamingFlow = streamingFlow.Map(mapper)
-			processor := &topNStreamingProcessor{
-				m:             manager.m,
-				l:             manager.l,
-				interval:      interval,
-				topNSchema:    topNSchema,
-				sortDirection: sortDirection,
-				src:           srcCh,
-				in:            make(chan flow.StreamRecord),
-				stopCh:        make(chan struct{}),
-				streamingFlow: streamingFlow,
-				pipeline:      manager.pipeline,
+			for _, sortDirection := range sortDirections {
+				srcCh := make(chan interface{})
+				src, _ := sources.NewChannel(srcCh)
+				streamingFlow := streaming.New(src)
+
+				filters, buildErr := manager.buildFilter(topNSchema.GetCriteria())
+				if buildErr != nil {
+					return buildErr
+				}
+				streamingFlow = streamingFlow.Filter(filters)
+
+				mapper, innerErr := manager.buildMapper(topNSchema.GetFieldName(), dimension.groupByTagNames...)
+				if innerErr != nil {
+					return innerErr
+				}
+				streamingFlow = streamingFlow.Map(mapper)
+				processor := &topNStreamingProcessor{
+					m:               manager.m,
+					l:               manager.l,
+					interval:        windowSlide,
+					windowSize:      windowSize,
+					topNSchema:      topNSchema,
+					metadata:        dimension.metadata,
+					groupByTagNames: dimension.groupByTagNames,
+					sortDirection:   sortDirection,
+					src:             srcCh,
+					in:              make(chan flow.StreamRecord),
+					stopCh:          make(chan struct{}),
+					streamingFlow:   streamingFlow,
+					pipeline:        manager.pipeline,
+				}
+				processorList = append(processorList, processor.start())
 			}
-			processorList[i] = processor.start()
 		}
 
 		manager.processorMap[topNSchema.GetSourceMeasure()] = processorList
@@ -405,6 +436,56 @@ func (manager *topNProcessorManager) start() error {
 	return nil
 }
 
+// topNWindow parses the size and the slide of the windows ranking the data points.
+// The size defaults to the interval of the source measure, and the slide defaults to the size.
+func topNWindow(topNSchema *databasev1.TopNAggregation, interval time.Duration) (size, slide time.Duration, err error) {
+	size = interval
+	if topNSchema.GetWindowSize() != "" {
+		if size, err = timestamp.ParseDuration(topNSchema.GetWindowSize()); err != nil {
+			return 0, 0, errors.WithMessagef(err, "invalid window size %q", topNSchema.GetWindowSize())
+		}
+	}
+	slide = size
+	if topNSchema.GetWindowSlide() != "" {
+		if slide, err = timestamp.ParseDuration(topNSchema.GetWindowSlide()); err != nil {
+			return 0, 0, errors.WithMessagef(err, "invalid window slide %q", topNSchema.GetWindowSlide())
+		}
+	}
+	if size <= 0 || slide <= 0 || slide > size || size%slide != 0 {
+		return 0, 0, errors.Errorf("the window slide %s should divide the window size %s", slide, size)
+	}
+	return size, slide, nil
+}
+
+// topNDimension is a ranking of the TopN aggregation, which is stored in its own derived measure.
+type topNDimension struct {
+	metadata        *commonv1.Metadata
+	groupByTagNames []string
+	fieldValueSort  modelv1.Sort
+}
+
+// topNDimensions returns the ranking grouped by the group_by_tag_names of the aggregation, followed by its dimensions.
+func topNDimensions(topNSchema *databasev1.TopNAggregation) []topNDimension {
+	dimensions := make([]topNDimension, 0, 1+len(topNSchema.GetDimensions()))
+	dimensions = append(dimensions, topNDimension{
+		metadata:        topNSchema.GetMetadata(),
+		groupByTagNames: topNSchema.GetGroupByTagNames(),
+		fieldValueSort:  topNSchema.GetFieldValueSort(),
+	})
+	for _, d := range topNSchema.GetDimensions() {
+		fieldValueSort := d.GetFieldValueSort()
+		if fieldValueSort == modelv1.Sort_SORT_UNSPECIFIED {
+			fieldValueSort = topNSchema.GetFieldValueSort()
+		}
+		dimensions = append(dimensions, topNDimension{
+			metadata:        logical_measure.TopNDimensionMetadata(topNSchema.GetMetadata(), d.GetGroupByTagNames()),
+			groupByTagNames: d.GetGroupByTagNames(),
+			fieldValueSort:  fieldValueSort,
+		})
+	}
+	return dimensions
+}
+
 func (manager *topNProcessorManager) buildFilter(criteria *modelv1.Criteria) (flow.UnaryFunc[bool], error) {
 	// if criteria is nil, we handle all incoming elements
 	if criteria == nil {
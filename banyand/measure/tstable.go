@@ -120,11 +120,14 @@ type tsTable struct {
 	l             *logger.Logger
 	snapshot      *snapshot
 	introductions chan *introduction
+	imports       chan *introduction
 	loopCloser    *run.Closer
 	p             common.Position
 	root          string
 	gc            garbageCleaner
 	curPartID     uint64
+	// flushSeq is the sequence of the last flush, see partMetadata.FlushSeq.
+	flushSeq uint64
 	sync.RWMutex
 }
 
@@ -158,6 +161,7 @@ func (tst *tsTable) loadSnapshot(epoch uint64, loadedParts []uint64) {
 		if tst.curPartID < id {
 			tst.curPartID = id
 		}
+		tst.flushSeq = max(tst.flushSeq, p.partMetadata.FlushSeq)
 	}
 	tst.gc.registerSnapshot(&snp)
 	tst.gc.clean()
@@ -174,6 +178,7 @@ func (tst *tsTable) loadSnapshot(epoch uint64, loadedParts []uint64) {
 func (tst *tsTable) startLoop(cur uint64) {
 	tst.loopCloser = run.NewCloser(1 + 3)
 	tst.introductions = make(chan *introduction)
+	tst.imports = make(chan *introduction)
 	flushCh := make(chan *flusherIntroduction)
 	mergeCh := make(chan *mergerIntroduction)
 	introducerWatcher := make(watcher.Channel, 1)
@@ -260,6 +265,7 @@ func (tst *tsTable) mustAddDataPoints(dps *dataPoints) {
 	ind.applied = make(chan struct{})
 	ind.memPart = newPartWrapper(mp, p)
 	ind.memPart.p.partMetadata.ID = atomic.AddUint64(&tst.curPartID, 1)
+	ind.memPart.walOffsets = dps.walOffsets
 
 	select {
 	case tst.introductions <- ind:
@@ -19,15 +19,19 @@ package measure
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"time"
 
+	"go.uber.org/multierr"
 	"google.golang.org/protobuf/types/known/anypb"
 
 	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/api/data"
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/wal"
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/index"
@@ -40,16 +44,21 @@ import (
 type writeCallback struct {
 	l          *logger.Logger
 	schemaRepo *schemaRepo
+	recorder   wal.Recorder
 }
 
-func setUpWriteCallback(l *logger.Logger, schemaRepo *schemaRepo) bus.MessageListener {
+func setUpWriteCallback(l *logger.Logger, schemaRepo *schemaRepo, recorder wal.Recorder) bus.MessageListener {
 	return &writeCallback{
 		l:          l,
 		schemaRepo: schemaRepo,
+		recorder:   recorder,
 	}
 }
 
-func (w *writeCallback) handle(dst map[string]*dataPointsInGroup, writeEvent *measurev1.InternalWriteRequest) (map[string]*dataPointsInGroup, error) {
+// handle adds the data point to dst. walOffset is the offset of the write in the WAL of its group, 0 if it isn't logged.
+func (w *writeCallback) handle(dst map[string]*dataPointsInGroup, writeEvent *measurev1.InternalWriteRequest,
+	walOffset uint64,
+) (map[string]*dataPointsInGroup, error) {
 	req := writeEvent.Request
 	t := req.DataPoint.Timestamp.AsTime().Local()
 	if err := timestamp.Check(t); err != nil {
@@ -187,6 +196,7 @@ func (w *writeCallback) handle(dst map[string]*dataPointsInGroup, writeEvent *me
 				Key: index.FieldKey{
 					IndexRuleID: ruleIndex.Rule.GetMetadata().GetId(),
 					Analyzer:    ruleIndex.Rule.Analyzer,
+					Numeric:     ruleIndex.Rule.GetType() == databasev1.IndexRule_TYPE_RANGE,
 				},
 				Term: nv.value,
 			})
@@ -198,6 +208,7 @@ func (w *writeCallback) handle(dst map[string]*dataPointsInGroup, writeEvent *me
 				Key: index.FieldKey{
 					IndexRuleID: rule.GetMetadata().GetId(),
 					Analyzer:    rule.Analyzer,
+					Numeric:     rule.GetType() == databasev1.IndexRule_TYPE_RANGE,
 				},
 				Term: val,
 			})
@@ -208,12 +219,41 @@ func (w *writeCallback) handle(dst map[string]*dataPointsInGroup, writeEvent *me
 		EntityValues: series.Buffer,
 		Fields:       fields,
 	})
+	if walOffset > 0 {
+		dpt.dataPoints.walOffsets = append(dpt.dataPoints.walOffsets, walOffset)
+	}
 	return dst, nil
 }
 
+// record logs the write before it's applied, and returns its offset in the WAL of its group.
+func (w *writeCallback) record(ctx context.Context, writeEvent *measurev1.InternalWriteRequest) (uint64, error) {
+	if w.recorder == nil {
+		return 0, nil
+	}
+	offset, err := w.recorder.Record(ctx, data.TopicMeasureWrite.String(), writeEvent.GetRequest().GetMetadata().GetGroup(), writeEvent)
+	if err != nil {
+		return 0, fmt.Errorf("cannot record the write: %w", err)
+	}
+	return offset, nil
+}
+
+// release drops the write rejected from the WAL.
+func (w *writeCallback) release(group string, offset uint64) {
+	if w.recorder == nil || offset == 0 {
+		return
+	}
+	w.recorder.Release(group, []uint64{offset})
+}
+
 func (w *writeCallback) Rev(message bus.Message) (resp bus.Message) {
-	events, ok := message.Data().([]any)
-	if !ok {
+	var events []any
+	switch d := message.Data().(type) {
+	case []any:
+		events = d
+	case *measurev1.InternalWriteRequest:
+		// a replicated write is sent alone and applied synchronously
+		events = []any{d}
+	default:
 		w.l.Warn().Msg("invalid event data type")
 		return
 	}
@@ -222,6 +262,8 @@ func (w *writeCallback) Rev(message bus.Message) (resp bus.Message) {
 		return
 	}
 	groups := make(map[string]*dataPointsInGroup)
+	// the replicated writes failing to be handled are rejected, so that the liaison doesn't count them as the acks
+	var errHandle error
 	for i := range events {
 		var writeEvent *measurev1.InternalWriteRequest
 		switch e := events[i].(type) {
@@ -231,17 +273,28 @@ func (w *writeCallback) Rev(message bus.Message) (resp bus.Message) {
 			writeEvent = &measurev1.InternalWriteRequest{}
 			if err := e.UnmarshalTo(writeEvent); err != nil {
 				w.l.Error().Err(err).RawJSON("written", logger.Proto(e)).Msg("fail to unmarshal event")
+				errHandle = multierr.Append(errHandle, err)
 				continue
 			}
 		default:
 			w.l.Warn().Msg("invalid event data type")
+			errHandle = multierr.Append(errHandle, fmt.Errorf("invalid event data type %T", e))
 			continue
 		}
-		var err error
-		if groups, err = w.handle(groups, writeEvent); err != nil {
+		offset, err := w.record(message.Context(), writeEvent)
+		if err != nil {
 			w.l.Error().Err(err).Msg("cannot handle write event")
+			errHandle = multierr.Append(errHandle, err)
 			continue
 		}
+		handled, err := w.handle(groups, writeEvent, offset)
+		if err != nil {
+			w.release(writeEvent.GetRequest().GetMetadata().GetGroup(), offset)
+			w.l.Error().Err(err).Msg("cannot handle write event")
+			errHandle = multierr.Append(errHandle, err)
+			continue
+		}
+		groups = handled
 	}
 	for i := range groups {
 		g := groups[i]
@@ -254,6 +307,9 @@ func (w *writeCallback) Rev(message bus.Message) (resp bus.Message) {
 			w.l.Error().Err(err).Msg("cannot write index")
 		}
 	}
+	if errHandle != nil {
+		return bus.NewMessage(bus.MessageID(time.Now().UnixNano()), common.NewError("cannot handle write events: %v", errHandle))
+	}
 	return
 }
 
@@ -20,6 +20,7 @@ package metadata
 import (
 	"context"
 	"errors"
+	"sort"
 	"time"
 
 	"go.uber.org/multierr"
@@ -51,10 +52,14 @@ const flagEtcdTLSKeyFile = "etcd-tls-key-file"
 
 // NewClient returns a new metadata client.
 func NewClient(forceRegisterNode bool) (Service, error) {
+	return newClientService(forceRegisterNode), nil
+}
+
+func newClientService(forceRegisterNode bool) *clientService {
 	return &clientService{
 		closer:            run.NewCloser(1),
 		forceRegisterNode: forceRegisterNode,
-	}, nil
+	}
 }
 
 type clientService struct {
@@ -124,6 +129,7 @@ func (s *clientService) PreRun(ctx context.Context) error {
 		HttpAddress: node.HTTPAddress,
 		Roles:       nodeRoles,
 		CreatedAt:   timestamppb.Now(),
+		Labels:      toNodeLabels(node.Labels),
 	}
 	for {
 		ctxRegister, cancel := context.WithTimeout(ctx, time.Second*10)
@@ -182,6 +188,18 @@ func (s *clientService) PropertyRegistry() schema.Property {
 	return s.schemaRegistry
 }
 
+func (s *clientService) NodeRegistry() schema.Node {
+	return s.schemaRegistry
+}
+
+func (s *clientService) AlertRuleRegistry() schema.AlertRule {
+	return s.schemaRegistry
+}
+
+func (s *clientService) TenantRegistry() schema.Tenant {
+	return s.schemaRegistry
+}
+
 func (s *clientService) Name() string {
 	return "metadata"
 }
@@ -282,3 +300,14 @@ func contains(s []string, e string) bool {
 	}
 	return false
 }
+
+func toNodeLabels(labels map[string]string) []*databasev1.NodeLabel {
+	result := make([]*databasev1.NodeLabel, 0, len(labels))
+	for k, v := range labels {
+		result = append(result, &databasev1.NodeLabel{Key: k, Value: v})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
@@ -19,10 +19,12 @@
 package embeddedetcd
 
 import (
+	"fmt"
 	"io"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"go.etcd.io/etcd/server/v3/embed"
@@ -38,6 +40,8 @@ type Server interface {
 	ReadyNotify() <-chan struct{}
 	StopNotify() <-chan struct{}
 	StoppingNotify() <-chan struct{}
+	// IsLeader returns true if the member is the leader of the cluster elected by Raft.
+	IsLeader() bool
 }
 
 type server struct {
@@ -62,13 +66,39 @@ func ConfigureListener(lcs, lps []string) Option {
 	}
 }
 
+// ConfigureCluster makes the server a member of a cluster.
+// The initialCluster lists the members as name=peer-url pairs separated by commas, which includes the name.
+// The state is "new" if the members bootstrap the cluster together, or "existing" if the member joins a running cluster.
+func ConfigureCluster(name, initialCluster, state string) Option {
+	return func(config *config) {
+		config.name = name
+		config.initialCluster = initialCluster
+		config.clusterState = state
+	}
+}
+
+// ConfigureAdvertiseClientURLs sets the client urls advertised to the other members and the clients.
+func ConfigureAdvertiseClientURLs(urls []string) Option {
+	return func(config *config) {
+		config.advertiseClientURLs = urls
+	}
+}
+
 type config struct {
 	// rootDir is the root directory for etcd storage
 	rootDir string
+	// name is the member name
+	name string
+	// initialCluster is the members bootstrapping the cluster
+	initialCluster string
+	// clusterState is the initial cluster state
+	clusterState string
 	// listenerClientURLs is the listener for client
 	listenerClientURLs []string
 	// listenerPeerURLs is the listener for peer
 	listenerPeerURLs []string
+	// advertiseClientURLs is the client urls advertised
+	advertiseClientURLs []string
 }
 
 func (e *server) ReadyNotify() <-chan struct{} {
@@ -83,6 +113,10 @@ func (e *server) StoppingNotify() <-chan struct{} {
 	return e.server.Server.StoppingNotify()
 }
 
+func (e *server) IsLeader() bool {
+	return e.server.Server.Leader() == e.server.Server.ID()
+}
+
 func (e *server) Close() error {
 	e.server.Close()
 	<-e.server.Server.StopNotify()
@@ -95,6 +129,7 @@ func NewServer(options ...Option) (Server, error) {
 		rootDir:            os.TempDir(),
 		listenerClientURLs: []string{embed.DefaultListenClientURLs},
 		listenerPeerURLs:   []string{embed.DefaultListenPeerURLs},
+		name:               "meta",
 	}
 	for _, opt := range options {
 		opt(conf)
@@ -106,7 +141,6 @@ func NewServer(options ...Option) (Server, error) {
 	if l, err = zapCfg.Build(); err != nil {
 		return nil, err
 	}
-	// TODO: allow use cluster setting
 	embedConfig, err := newEmbedEtcdConfig(conf, l)
 	if err != nil {
 		return nil, err
@@ -148,13 +182,51 @@ func newEmbedEtcdConfig(config *config, logger *zap.Logger) (*embed.Config, erro
 	if err != nil {
 		return nil, err
 	}
-	cfg.Name = "meta"
+	cfg.Name = config.name
 	cfg.ListenClientUrls, cfg.AdvertiseClientUrls = cURLs, cURLs
 	cfg.ListenPeerUrls, cfg.AdvertisePeerUrls = pURLs, pURLs
 	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	if len(config.advertiseClientURLs) > 0 {
+		if cfg.AdvertiseClientUrls, err = parseURLs(config.advertiseClientURLs); err != nil {
+			return nil, err
+		}
+	}
+	if config.initialCluster != "" {
+		// the peer urls advertised have to be the ones of the member in the initial cluster
+		if cfg.AdvertisePeerUrls, err = memberPeerURLs(config.initialCluster, config.name); err != nil {
+			return nil, err
+		}
+		cfg.InitialCluster = config.initialCluster
+		cfg.InitialClusterToken = "banyandb-metadata"
+	}
+	if config.clusterState != "" {
+		cfg.ClusterState = config.clusterState
+	}
 
 	cfg.BackendBatchInterval = 500 * time.Millisecond
 	cfg.BackendBatchLimit = 10000
 	cfg.MaxRequestBytes = 10 * 1024 * 1024
 	return cfg, nil
 }
+
+func memberPeerURLs(initialCluster, name string) ([]url.URL, error) {
+	var uu []url.URL
+	for _, member := range strings.Split(initialCluster, ",") {
+		n, u, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid member %q of the initial cluster", member)
+		}
+		if n != name {
+			continue
+		}
+		pURL, err := url.Parse(u)
+		if err != nil {
+			return nil, err
+		}
+		uu = append(uu, *pURL)
+	}
+	if len(uu) == 0 {
+		return nil, fmt.Errorf("the member %q isn't in the initial cluster %q", name, initialCluster)
+	}
+	return uu, nil
+}
@@ -46,6 +46,9 @@ type Repo interface {
 	GroupRegistry() schema.Group
 	TopNAggregationRegistry() schema.TopNAggregation
 	PropertyRegistry() schema.Property
+	NodeRegistry() schema.Node
+	AlertRuleRegistry() schema.AlertRule
+	TenantRegistry() schema.Tenant
 	RegisterHandler(string, schema.Kind, schema.EventHandler)
 }
 
@@ -0,0 +1,127 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schema
+
+import (
+	"context"
+	"net/url"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+var alertRuleKeyPrefix = "/alertrule/"
+
+func (e *etcdSchemaRegistry) GetAlertRule(ctx context.Context, metadata *commonv1.Metadata) (*databasev1.AlertRule, error) {
+	var entity databasev1.AlertRule
+	if err := e.get(ctx, formatAlertRuleKey(metadata), &entity); err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+func (e *etcdSchemaRegistry) ListAlertRule(ctx context.Context, opt ListOpt) ([]*databasev1.AlertRule, error) {
+	if opt.Group == "" {
+		return nil, BadRequest("group", "group should not be empty")
+	}
+	messages, err := e.listWithPrefix(ctx, listPrefixesForEntity(opt.Group, alertRuleKeyPrefix), KindAlertRule)
+	if err != nil {
+		return nil, err
+	}
+	entities := make([]*databasev1.AlertRule, 0, len(messages))
+	for _, message := range messages {
+		entities = append(entities, message.(*databasev1.AlertRule))
+	}
+	return entities, nil
+}
+
+func (e *etcdSchemaRegistry) CreateAlertRule(ctx context.Context, alertRule *databasev1.AlertRule) error {
+	if err := validateAlertRule(alertRule); err != nil {
+		return err
+	}
+	if alertRule.UpdatedAt != nil {
+		alertRule.UpdatedAt = timestamppb.Now()
+	}
+	_, err := e.create(ctx, Metadata{
+		TypeMeta: TypeMeta{
+			Kind:  KindAlertRule,
+			Group: alertRule.GetMetadata().GetGroup(),
+			Name:  alertRule.GetMetadata().GetName(),
+		},
+		Spec: alertRule,
+	})
+	return err
+}
+
+func (e *etcdSchemaRegistry) UpdateAlertRule(ctx context.Context, alertRule *databasev1.AlertRule) error {
+	if err := validateAlertRule(alertRule); err != nil {
+		return err
+	}
+	_, err := e.update(ctx, Metadata{
+		TypeMeta: TypeMeta{
+			Kind:  KindAlertRule,
+			Group: alertRule.GetMetadata().GetGroup(),
+			Name:  alertRule.GetMetadata().GetName(),
+		},
+		Spec: alertRule,
+	})
+	return err
+}
+
+func (e *etcdSchemaRegistry) DeleteAlertRule(ctx context.Context, metadata *commonv1.Metadata) (bool, error) {
+	return e.delete(ctx, Metadata{
+		TypeMeta: TypeMeta{
+			Kind:  KindAlertRule,
+			Group: metadata.GetGroup(),
+			Name:  metadata.GetName(),
+		},
+	})
+}
+
+func formatAlertRuleKey(metadata *commonv1.Metadata) string {
+	return formatKey(alertRuleKeyPrefix, metadata)
+}
+
+// validateAlertRule rejects a rule which can't be evaluated, or whose events are sent nowhere.
+func validateAlertRule(alertRule *databasev1.AlertRule) error {
+	interval, err := timestamp.ParseDuration(alertRule.GetInterval())
+	if err != nil || interval <= 0 {
+		return BadRequest("interval", "interval should be a positive duration")
+	}
+	if alertRule.GetWindow() != "" {
+		if window, errWindow := timestamp.ParseDuration(alertRule.GetWindow()); errWindow != nil || window <= 0 {
+			return BadRequest("window", "window should be a positive duration")
+		}
+	}
+	if alertRule.GetWebhook() == "" && alertRule.GetStream() == nil {
+		return BadRequest("webhook", "either the webhook or the stream should be set to receive the events")
+	}
+	if alertRule.GetWebhook() != "" {
+		u, errURL := url.Parse(alertRule.GetWebhook())
+		if errURL != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return BadRequest("webhook", "webhook should be an absolute http or https URL")
+		}
+	}
+	if alertRule.GetStream() != nil && alertRule.GetStream().GetName() == "" {
+		return BadRequest("stream", "the name of the stream should be set")
+	}
+	return nil
+}
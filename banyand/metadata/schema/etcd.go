@@ -228,11 +228,11 @@ func (e *etcdSchemaRegistry) update(ctx context.Context, metadata Metadata) (int
 		return 0, ErrClosed
 	}
 	defer e.closer.Done()
-	key, err := metadata.key()
+	entityKey, err := metadata.key()
 	if err != nil {
 		return 0, err
 	}
-	key = e.prependNamespace(key)
+	key := e.prependNamespace(entityKey)
 	getResp, err := e.client.Get(ctx, key)
 	if err != nil {
 		return 0, err
@@ -261,9 +261,17 @@ func (e *etcdSchemaRegistry) update(ctx context.Context, metadata Metadata) (int
 	if modRevision == 0 {
 		modRevision = getResp.Kvs[0].ModRevision
 	}
+	ops := []clientv3.Op{clientv3.OpPut(key, string(val))}
+	if metadata.Kind.versioned() {
+		historyOps, innerErr := e.historyOps(ctx, entityKey, existingVal, getResp.Kvs[0].ModRevision)
+		if innerErr != nil {
+			return 0, innerErr
+		}
+		ops = append(ops, historyOps...)
+	}
 	txnResp, txnErr := e.client.Txn(ctx).
 		If(clientv3.Compare(clientv3.ModRevision(key), "=", modRevision)).
-		Then(clientv3.OpPut(key, string(val))).
+		Then(ops...).
 		Commit()
 	if txnErr != nil {
 		return 0, txnErr
@@ -341,12 +349,15 @@ func (e *etcdSchemaRegistry) delete(ctx context.Context, metadata Metadata) (boo
 	if err != nil {
 		return false, err
 	}
-	key = e.prependNamespace(key)
-	resp, err := e.client.Delete(ctx, key, clientv3.WithPrevKV())
+	ops := []clientv3.Op{clientv3.OpDelete(e.prependNamespace(key), clientv3.WithPrevKV())}
+	if metadata.Kind.versioned() {
+		ops = append(ops, clientv3.OpDelete(e.historyPrefix(key), clientv3.WithPrefix()))
+	}
+	resp, err := e.client.Txn(ctx).Then(ops...).Commit()
 	if err != nil {
 		return false, err
 	}
-	if resp.Deleted == 1 {
+	if resp.Responses[0].GetResponseDeleteRange().Deleted == 1 {
 		return true, nil
 	}
 	return false, nil
@@ -435,3 +435,54 @@ func Test_Etcd_Entity_Update(t *testing.T) {
 		})
 	}
 }
+
+func Test_Etcd_Schema_History(t *testing.T) {
+	req := require.New(t)
+	registry, closer := initServerAndRegister(t)
+	defer closer()
+	req.NoError(preloadSchema(registry))
+
+	ctx := context.TODO()
+	meta := &commonv1.Metadata{Name: "sw", Group: "default"}
+	update := func(change func(tags []*databasev1.TagSpec) []*databasev1.TagSpec) {
+		s, err := registry.GetStream(ctx, meta)
+		req.NoError(err)
+		s.TagFamilies[1].Tags = change(s.TagFamilies[1].Tags)
+		_, err = registry.UpdateStream(ctx, s)
+		req.NoError(err)
+	}
+	// drop the tag "mq.broker", then add it again in another type
+	update(func(tags []*databasev1.TagSpec) []*databasev1.TagSpec { return tags[:len(tags)-1] })
+	update(func(tags []*databasev1.TagSpec) []*databasev1.TagSpec {
+		return append(tags, &databasev1.TagSpec{Name: "mq.broker", Type: databasev1.TagType_TAG_TYPE_INT})
+	})
+
+	history, err := registry.GetStreamHistory(ctx, meta)
+	req.NoError(err)
+	req.Len(history, 3)
+	req.Len(history[0].TagFamilies[1].Tags, 14)
+	req.Len(history[1].TagFamilies[1].Tags, 13)
+	req.Len(history[2].TagFamilies[1].Tags, 14)
+	req.Greater(history[0].Metadata.ModRevision, history[1].Metadata.ModRevision)
+	req.Greater(history[1].Metadata.ModRevision, history[2].Metadata.ModRevision)
+
+	// the first version has "mq.broker" in the type differing from the current one
+	_, err = registry.RollbackStream(ctx, meta, history[2].Metadata.ModRevision)
+	req.Error(err)
+	_, err = registry.RollbackStream(ctx, meta, 1)
+	req.Error(err)
+	_, err = registry.RollbackStream(ctx, meta, history[1].Metadata.ModRevision)
+	req.NoError(err)
+	current, err := registry.GetStream(ctx, meta)
+	req.NoError(err)
+	req.Len(current.TagFamilies[1].Tags, 13)
+	history, err = registry.GetStreamHistory(ctx, meta)
+	req.NoError(err)
+	req.Len(history, 4)
+
+	// the history is dropped along with the stream
+	_, err = registry.DeleteStream(ctx, meta)
+	req.NoError(err)
+	_, err = registry.GetStreamHistory(ctx, meta)
+	req.ErrorIs(err, ErrGRPCResourceNotFound)
+}
@@ -0,0 +1,202 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schema
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/pkg/errors"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+// validateTagFamilies rejects the invalid default values and derivations of the tags.
+func validateTagFamilies(families []*databasev1.TagFamilySpec) error {
+	if _, err := pbv1.NewTagFiller(families); err != nil {
+		return BadRequest("tag_families", err.Error())
+	}
+	return nil
+}
+
+// checkStreamEvolution rejects the changes of a stream which its existing data can't be read by.
+func (e *etcdSchemaRegistry) checkStreamEvolution(ctx context.Context, stream *databasev1.Stream) error {
+	prev, err := e.GetStream(ctx, stream.GetMetadata())
+	if err != nil {
+		if errors.Is(err, ErrGRPCResourceNotFound) {
+			return nil
+		}
+		return err
+	}
+	return validateStreamEvolution(prev, stream)
+}
+
+// checkMeasureEvolution rejects the changes of a measure which its existing data can't be read by.
+func (e *etcdSchemaRegistry) checkMeasureEvolution(ctx context.Context, measure *databasev1.Measure) error {
+	prev, err := e.GetMeasure(ctx, measure.GetMetadata())
+	if err != nil {
+		if errors.Is(err, ErrGRPCResourceNotFound) {
+			return nil
+		}
+		return err
+	}
+	return validateMeasureEvolution(prev, measure)
+}
+
+// validateStreamEvolution allows appending tags and tag families to a stream and dropping them without rewriting its data,
+// where the blocks written before a tag is added have nulls for it.
+// The types and the families of the tags kept can't change.
+func validateStreamEvolution(prev, next *databasev1.Stream) error {
+	return validateTagFamiliesEvolution(prev.GetTagFamilies(), next.GetTagFamilies())
+}
+
+// validateMeasureEvolution is validateStreamEvolution of a measure, whose fields kept can't change their types either.
+func validateMeasureEvolution(prev, next *databasev1.Measure) error {
+	if err := validateTagFamiliesEvolution(prev.GetTagFamilies(), next.GetTagFamilies()); err != nil {
+		return err
+	}
+	return validateFieldTypes(prev.GetFields(), next.GetFields())
+}
+
+// validateStreamRollback checks the version of a stream rolled back to against the newer versions,
+// whose elements might be in the parts. The types and the families of its tags can't differ from any of them.
+func validateStreamRollback(newer []*databasev1.Stream, target *databasev1.Stream) error {
+	if err := validateStreamEvolution(newer[0], target); err != nil {
+		return err
+	}
+	for _, s := range newer[1:] {
+		if err := validateTagTypes(s.GetTagFamilies(), target.GetTagFamilies()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateMeasureRollback is validateStreamRollback of a measure, whose fields can't differ in their types either.
+func validateMeasureRollback(newer []*databasev1.Measure, target *databasev1.Measure) error {
+	if err := validateMeasureEvolution(newer[0], target); err != nil {
+		return err
+	}
+	for _, m := range newer[1:] {
+		if err := validateTagTypes(m.GetTagFamilies(), target.GetTagFamilies()); err != nil {
+			return err
+		}
+		if err := validateFieldTypes(m.GetFields(), target.GetFields()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTagFamiliesEvolution requires the tags and the tag families kept to stay in their order ahead of the added ones,
+// because the tags are written by their positions in the schema.
+func validateTagFamiliesEvolution(prev, next []*databasev1.TagFamilySpec) error {
+	if err := validateTagTypes(prev, next); err != nil {
+		return err
+	}
+	if !keptInOrder(familyNames(prev), familyNames(next)) {
+		return BadRequest("tag_families", "the tag families kept should stay in their order, and the added ones should be appended")
+	}
+	for _, tf := range next {
+		i := slices.IndexFunc(prev, func(pf *databasev1.TagFamilySpec) bool { return pf.GetName() == tf.GetName() })
+		if i >= 0 && !keptInOrder(tagNames(prev[i]), tagNames(tf)) {
+			return BadRequest("tag_families", fmt.Sprintf("the tags kept in family %q should stay in their order, and the added ones should be appended", tf.GetName()))
+		}
+	}
+	return nil
+}
+
+// validateTagTypes rejects the tags of next found in prev in another family or of another type.
+func validateTagTypes(prev, next []*databasev1.TagFamilySpec) error {
+	type location struct {
+		spec   *databasev1.TagSpec
+		family string
+	}
+	tags := make(map[string]location)
+	for _, tf := range prev {
+		for _, t := range tf.GetTags() {
+			tags[t.GetName()] = location{spec: t, family: tf.GetName()}
+		}
+	}
+	for _, tf := range next {
+		for _, t := range tf.GetTags() {
+			l, ok := tags[t.GetName()]
+			if !ok {
+				continue
+			}
+			if l.family != tf.GetName() {
+				return BadRequest("tag_families", fmt.Sprintf("tag %q can't move from family %q to %q", t.GetName(), l.family, tf.GetName()))
+			}
+			if pt := l.spec.GetType(); pt != t.GetType() {
+				return BadRequest("tag_families", fmt.Sprintf("the type of tag %q can't change from %s to %s, add a tag of another name instead",
+					t.GetName(), pt, t.GetType()))
+			}
+		}
+	}
+	return nil
+}
+
+// validateFieldTypes rejects the fields of next found in prev of another type.
+func validateFieldTypes(prev, next []*databasev1.FieldSpec) error {
+	for _, f := range next {
+		i := slices.IndexFunc(prev, func(pf *databasev1.FieldSpec) bool { return pf.GetName() == f.GetName() })
+		if i < 0 {
+			continue
+		}
+		if pt := prev[i].GetFieldType(); pt != f.GetFieldType() {
+			return BadRequest("fields", fmt.Sprintf("the type of field %q can't change from %s to %s, add a field of another name instead",
+				f.GetName(), pt, f.GetFieldType()))
+		}
+	}
+	return nil
+}
+
+// keptInOrder tells whether the names of next found in prev are in their order in prev, and ahead of the others.
+func keptInOrder(prev, next []string) bool {
+	last, added := -1, false
+	for _, name := range next {
+		i := slices.Index(prev, name)
+		if i < 0 {
+			added = true
+			continue
+		}
+		if added || i < last {
+			return false
+		}
+		last = i
+	}
+	return true
+}
+
+func familyNames(families []*databasev1.TagFamilySpec) []string {
+	names := make([]string, 0, len(families))
+	for _, tf := range families {
+		names = append(names, tf.GetName())
+	}
+	return names
+}
+
+func tagNames(family *databasev1.TagFamilySpec) []string {
+	names := make([]string, 0, len(family.GetTags()))
+	for _, t := range family.GetTags() {
+		names = append(names, t.GetName())
+	}
+	return names
+}
@@ -0,0 +1,143 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+)
+
+func tagFamily(name string, tags ...*databasev1.TagSpec) *databasev1.TagFamilySpec {
+	return &databasev1.TagFamilySpec{Name: name, Tags: tags}
+}
+
+func strTag(name string) *databasev1.TagSpec {
+	return &databasev1.TagSpec{Name: name, Type: databasev1.TagType_TAG_TYPE_STRING}
+}
+
+func intTag(name string) *databasev1.TagSpec {
+	return &databasev1.TagSpec{Name: name, Type: databasev1.TagType_TAG_TYPE_INT}
+}
+
+func Test_validateStreamEvolution(t *testing.T) {
+	prev := &databasev1.Stream{
+		TagFamilies: []*databasev1.TagFamilySpec{
+			tagFamily("searchable", strTag("trace_id"), intTag("duration"), strTag("endpoint")),
+			tagFamily("data", &databasev1.TagSpec{Name: "data_binary", Type: databasev1.TagType_TAG_TYPE_DATA_BINARY}),
+		},
+	}
+	tests := []struct {
+		name     string
+		families []*databasev1.TagFamilySpec
+		wantErr  bool
+	}{
+		{
+			name:     "unchanged",
+			families: prev.TagFamilies,
+		},
+		{
+			name: "append a tag and a tag family",
+			families: []*databasev1.TagFamilySpec{
+				tagFamily("searchable", strTag("trace_id"), intTag("duration"), strTag("endpoint"), intTag("status")),
+				prev.TagFamilies[1],
+				tagFamily("extra", strTag("region")),
+			},
+		},
+		{
+			name: "drop a tag and a tag family",
+			families: []*databasev1.TagFamilySpec{
+				tagFamily("searchable", strTag("trace_id"), strTag("endpoint")),
+			},
+		},
+		{
+			name: "change the type of a tag",
+			families: []*databasev1.TagFamilySpec{
+				tagFamily("searchable", strTag("trace_id"), strTag("duration"), strTag("endpoint")),
+				prev.TagFamilies[1],
+			},
+			wantErr: true,
+		},
+		{
+			name: "move a tag to another family",
+			families: []*databasev1.TagFamilySpec{
+				tagFamily("searchable", strTag("trace_id"), intTag("duration")),
+				tagFamily("data", prev.TagFamilies[1].Tags[0], strTag("endpoint")),
+			},
+			wantErr: true,
+		},
+		{
+			name: "insert a tag ahead of the kept ones",
+			families: []*databasev1.TagFamilySpec{
+				tagFamily("searchable", strTag("trace_id"), intTag("status"), intTag("duration"), strTag("endpoint")),
+				prev.TagFamilies[1],
+			},
+			wantErr: true,
+		},
+		{
+			name: "reorder the tag families",
+			families: []*databasev1.TagFamilySpec{
+				prev.TagFamilies[1],
+				prev.TagFamilies[0],
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStreamEvolution(prev, &databasev1.Stream{TagFamilies: tt.families})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_validateMeasureEvolution(t *testing.T) {
+	prev := &databasev1.Measure{
+		TagFamilies: []*databasev1.TagFamilySpec{tagFamily("default", strTag("id"))},
+		Fields: []*databasev1.FieldSpec{
+			{Name: "total", FieldType: databasev1.FieldType_FIELD_TYPE_INT},
+		},
+	}
+	next := &databasev1.Measure{
+		TagFamilies: []*databasev1.TagFamilySpec{tagFamily("default", strTag("id"), strTag("region"))},
+		Fields: []*databasev1.FieldSpec{
+			{Name: "total", FieldType: databasev1.FieldType_FIELD_TYPE_INT},
+			{Name: "value", FieldType: databasev1.FieldType_FIELD_TYPE_FLOAT},
+		},
+	}
+	assert.NoError(t, validateMeasureEvolution(prev, next))
+	next.Fields[0].FieldType = databasev1.FieldType_FIELD_TYPE_FLOAT
+	assert.Error(t, validateMeasureEvolution(prev, next))
+}
+
+func Test_validateStreamRollback(t *testing.T) {
+	v1 := &databasev1.Stream{TagFamilies: []*databasev1.TagFamilySpec{tagFamily("searchable", strTag("trace_id"), strTag("endpoint"))}}
+	v2 := &databasev1.Stream{TagFamilies: []*databasev1.TagFamilySpec{tagFamily("searchable", strTag("trace_id"))}}
+	v3 := &databasev1.Stream{TagFamilies: []*databasev1.TagFamilySpec{tagFamily("searchable", strTag("trace_id"), intTag("endpoint"))}}
+	v4 := &databasev1.Stream{TagFamilies: []*databasev1.TagFamilySpec{tagFamily("searchable", strTag("trace_id"), strTag("duration"))}}
+	// v3 written the tag "endpoint" in another type
+	assert.Error(t, validateStreamRollback([]*databasev1.Stream{v4, v3, v2}, v1))
+	assert.NoError(t, validateStreamRollback([]*databasev1.Stream{v4, v3}, v2))
+	assert.NoError(t, validateStreamRollback([]*databasev1.Stream{v2}, v1))
+}
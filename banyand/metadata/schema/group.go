@@ -20,6 +20,7 @@ package schema
 import (
 	"context"
 	"path"
+	"time"
 
 	"github.com/pkg/errors"
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -30,6 +31,13 @@ import (
 
 var groupsKeyPrefix = "/groups/"
 
+const (
+	// minStreamBlockSize and maxStreamBlockSize bound the max uncompressed size of the stream blocks of a group.
+	// Two of the biggest blocks merged still fit in a values block of the stream.
+	minStreamBlockSize = 64 << 10
+	maxStreamBlockSize = 4 << 20
+)
+
 func (e *etcdSchemaRegistry) GetGroup(ctx context.Context, group string) (*commonv1.Group, error) {
 	var entity commonv1.Group
 	err := e.get(ctx, formatGroupKey(group), &entity)
@@ -57,9 +65,10 @@ func (e *etcdSchemaRegistry) DeleteGroup(ctx context.Context, group string) (boo
 		return false, errors.Wrap(err, group)
 	}
 	keysToDelete := allKeys()
-	deleteOPs := make([]clientv3.Op, 0, len(keysToDelete)+1)
+	deleteOPs := make([]clientv3.Op, 0, 2*len(keysToDelete)+1)
 	for _, key := range keysToDelete {
-		deleteOPs = append(deleteOPs, clientv3.OpDelete(e.prependNamespace(listPrefixesForEntity(group, key)), clientv3.WithPrefix()))
+		deleteOPs = append(deleteOPs, clientv3.OpDelete(e.prependNamespace(listPrefixesForEntity(group, key)), clientv3.WithPrefix()),
+			clientv3.OpDelete(e.historyPrefix(listPrefixesForEntity(group, key)), clientv3.WithPrefix()))
 	}
 	deleteOPs = append(deleteOPs, clientv3.OpDelete(e.prependNamespace(formatGroupKey(group)), clientv3.WithPrefix()))
 	txnResponse, err := e.client.Txn(ctx).Then(deleteOPs...).Commit()
@@ -73,6 +82,18 @@ func (e *etcdSchemaRegistry) DeleteGroup(ctx context.Context, group string) (boo
 }
 
 func (e *etcdSchemaRegistry) CreateGroup(ctx context.Context, group *commonv1.Group) error {
+	if err := validateSharding(group.GetResourceOpts().GetSharding()); err != nil {
+		return err
+	}
+	if err := validatePlacement(group.GetResourceOpts()); err != nil {
+		return err
+	}
+	if err := validateStreamBlock(group.GetResourceOpts().GetStreamBlock()); err != nil {
+		return err
+	}
+	if err := validateStreamCompaction(group.GetResourceOpts()); err != nil {
+		return err
+	}
 	if group.UpdatedAt != nil {
 		group.UpdatedAt = timestamppb.Now()
 	}
@@ -87,6 +108,21 @@ func (e *etcdSchemaRegistry) CreateGroup(ctx context.Context, group *commonv1.Gr
 }
 
 func (e *etcdSchemaRegistry) UpdateGroup(ctx context.Context, group *commonv1.Group) error {
+	if err := validateSharding(group.GetResourceOpts().GetSharding()); err != nil {
+		return err
+	}
+	if err := validatePlacement(group.GetResourceOpts()); err != nil {
+		return err
+	}
+	if err := validateStreamBlock(group.GetResourceOpts().GetStreamBlock()); err != nil {
+		return err
+	}
+	if err := validateStreamCompaction(group.GetResourceOpts()); err != nil {
+		return err
+	}
+	if err := e.checkShardingChange(ctx, group); err != nil {
+		return err
+	}
 	_, err := e.update(ctx, Metadata{
 		TypeMeta: TypeMeta{
 			Kind: KindGroup,
@@ -97,6 +133,110 @@ func (e *etcdSchemaRegistry) UpdateGroup(ctx context.Context, group *commonv1.Gr
 	return err
 }
 
+// checkShardingChange rejects changing the sharding of a group with streams or measures,
+// because their existing series stay in the shards that the previous sharding chose.
+func (e *etcdSchemaRegistry) checkShardingChange(ctx context.Context, group *commonv1.Group) error {
+	name := group.GetMetadata().GetName()
+	prev, err := e.GetGroup(ctx, name)
+	if err != nil {
+		if errors.Is(err, ErrGRPCResourceNotFound) {
+			return nil
+		}
+		return err
+	}
+	if sameSharding(prev.GetResourceOpts().GetSharding(), group.GetResourceOpts().GetSharding()) {
+		return nil
+	}
+	streams, err := e.ListStream(ctx, ListOpt{Group: name})
+	if err != nil {
+		return err
+	}
+	measures, err := e.ListMeasure(ctx, ListOpt{Group: name})
+	if err != nil {
+		return err
+	}
+	if len(streams) > 0 || len(measures) > 0 {
+		return BadRequest("resource_opts.sharding",
+			"the sharding of a group with streams or measures can't be changed, because the existing data would be unreachable. "+
+				"Create a new group with the sharding and migrate the data to it instead")
+	}
+	return nil
+}
+
+func validateSharding(sharding *commonv1.Sharding) error {
+	if sharding.GetType() == commonv1.Sharding_TYPE_ENTITY_PREFIX && sharding.GetPrefixLength() < 1 {
+		return BadRequest("resource_opts.sharding.prefix_length", "prefix_length should be positive for the entity prefix sharding")
+	}
+	return nil
+}
+
+// validatePlacement rejects a policy which never relocates a segment before it expires.
+func validatePlacement(opts *commonv1.ResourceOpts) error {
+	coldAfter := opts.GetPlacement().GetColdAfter()
+	if coldAfter == nil {
+		return nil
+	}
+	if intervalHours(coldAfter) == 0 {
+		return BadRequest("resource_opts.placement.cold_after", "cold_after should be positive")
+	}
+	if intervalHours(coldAfter) >= intervalHours(opts.GetTtl()) {
+		return BadRequest("resource_opts.placement.cold_after", "cold_after should be shorter than the ttl")
+	}
+	return nil
+}
+
+// validateStreamBlock rejects the bounds of the stream blocks the stream can't write or read.
+func validateStreamBlock(opts *commonv1.StreamBlockOpts) error {
+	if size := opts.GetMaxUncompressedSize(); size > 0 && (size < minStreamBlockSize || size > maxStreamBlockSize) {
+		return BadRequest("resource_opts.stream_block.max_uncompressed_size", "max_uncompressed_size should be from 64KiB to 4MiB")
+	}
+	if opts.GetMinRows() > 0 && opts.GetMaxRows() > 0 && opts.GetMinRows() > opts.GetMaxRows() {
+		return BadRequest("resource_opts.stream_block.min_rows", "min_rows should be no more than max_rows")
+	}
+	if ft := opts.GetFlushTimeout(); ft != nil && ft.AsDuration() < time.Second {
+		return BadRequest("resource_opts.stream_block.flush_timeout", "flush_timeout should be at least a second")
+	}
+	return nil
+}
+
+// validateStreamCompaction rejects a time window longer than the ttl, or set for the other policies.
+func validateStreamCompaction(opts *commonv1.ResourceOpts) error {
+	window := opts.GetStreamCompaction().GetTimeWindow()
+	if window == nil {
+		return nil
+	}
+	if opts.GetStreamCompaction().GetPolicy() != commonv1.StreamCompactionOpts_POLICY_TIME_WINDOW {
+		return BadRequest("resource_opts.stream_compaction.time_window", "time_window is only for the time window policy")
+	}
+	if intervalHours(window) == 0 {
+		return BadRequest("resource_opts.stream_compaction.time_window", "time_window should be positive")
+	}
+	if intervalHours(window) > intervalHours(opts.GetTtl()) {
+		return BadRequest("resource_opts.stream_compaction.time_window", "time_window should be no longer than the ttl")
+	}
+	return nil
+}
+
+func intervalHours(ir *commonv1.IntervalRule) uint32 {
+	if ir.GetUnit() == commonv1.IntervalRule_UNIT_DAY {
+		return ir.GetNum() * 24
+	}
+	return ir.GetNum()
+}
+
+func sameSharding(a, b *commonv1.Sharding) bool {
+	typeOf := func(s *commonv1.Sharding) commonv1.Sharding_Type {
+		if s.GetType() == commonv1.Sharding_TYPE_UNSPECIFIED {
+			return commonv1.Sharding_TYPE_SERIES
+		}
+		return s.GetType()
+	}
+	if typeOf(a) != typeOf(b) {
+		return false
+	}
+	return typeOf(a) != commonv1.Sharding_TYPE_ENTITY_PREFIX || a.GetPrefixLength() == b.GetPrefixLength()
+}
+
 func formatGroupKey(group string) string {
 	return path.Join(groupsKeyPrefix, group)
 }
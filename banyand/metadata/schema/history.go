@@ -0,0 +1,145 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schema
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	schemaHistoryKeyPrefix = "/schema-history/"
+	// maxSchemaHistory is the number of the versions replaced by the updates kept for a resource.
+	maxSchemaHistory = 16
+)
+
+// historyPrefix is the prefix of the keys of the versions replaced of the resource at the key,
+// which are followed by their revisions padded to be sorted in the order of the revisions.
+func (e *etcdSchemaRegistry) historyPrefix(key string) string {
+	return e.prependNamespace(path.Join(schemaHistoryKeyPrefix, key)) + "/"
+}
+
+func formatRevision(revision int64) string {
+	return fmt.Sprintf("%020d", revision)
+}
+
+// historyOps keeps the version of the resource at the key replaced by an update in the history,
+// and drops the oldest ones beyond maxSchemaHistory.
+func (e *etcdSchemaRegistry) historyOps(ctx context.Context, key string, replaced Metadata, revision int64) ([]clientv3.Op, error) {
+	val, err := proto.Marshal(replaced.Spec.(proto.Message))
+	if err != nil {
+		return nil, err
+	}
+	prefix := e.historyPrefix(key)
+	resp, err := e.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+	ops := []clientv3.Op{clientv3.OpPut(prefix+formatRevision(revision), string(val))}
+	// the keys are in the ascending order of the revisions
+	for i := 0; i <= len(resp.Kvs)-maxSchemaHistory; i++ {
+		ops = append(ops, clientv3.OpDelete(string(resp.Kvs[i].Key)))
+	}
+	return ops, nil
+}
+
+// history returns the current version of the resource at the key followed by the ones replaced, the newer the earlier.
+// A version keeps the revisions it had when it was the current one.
+func (e *etcdSchemaRegistry) history(ctx context.Context, kind Kind, key string) ([]proto.Message, error) {
+	if !e.closer.AddRunning() {
+		return nil, ErrClosed
+	}
+	defer e.closer.Done()
+	current, err := e.client.Get(ctx, e.prependNamespace(key))
+	if err != nil {
+		return nil, err
+	}
+	if current.Count == 0 {
+		return nil, ErrGRPCResourceNotFound
+	}
+	md, err := kind.Unmarshal(current.Kvs[0])
+	if err != nil {
+		return nil, err
+	}
+	// the history is read at the same revision as the current version
+	resp, err := e.client.Get(ctx, e.historyPrefix(key), clientv3.WithPrefix(), clientv3.WithRev(current.Header.Revision),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortDescend))
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]proto.Message, 0, len(resp.Kvs)+1)
+	versions = append(versions, md.Spec.(proto.Message))
+	for _, kv := range resp.Kvs {
+		m, innerErr := kind.newMessage()
+		if innerErr != nil {
+			return nil, innerErr
+		}
+		if innerErr = proto.Unmarshal(kv.Value, m); innerErr != nil {
+			return nil, innerErr
+		}
+		versions = append(versions, m)
+	}
+	return versions, nil
+}
+
+// rollback makes the version of the resource at the revision the current one, which is checked against the newer versions by validate.
+func (e *etcdSchemaRegistry) rollback(ctx context.Context, metadata Metadata, revision int64,
+	validate func(newer []proto.Message, target proto.Message) error,
+) (int64, error) {
+	key, err := metadata.key()
+	if err != nil {
+		return 0, err
+	}
+	versions, err := e.history(ctx, metadata.Kind, key)
+	if err != nil {
+		return 0, err
+	}
+	i := slices.IndexFunc(versions, func(m proto.Message) bool {
+		return m.(HasMetadata).GetMetadata().GetModRevision() == revision
+	})
+	if i < 0 {
+		return 0, BadRequest("mod_revision", fmt.Sprintf("revision %d isn't in the history", revision))
+	}
+	if i == 0 {
+		return revision, nil
+	}
+	if validate != nil {
+		if err = validate(versions[:i], versions[i]); err != nil {
+			return 0, err
+		}
+	}
+	target := versions[i].(HasMetadata)
+	// the rollback fails if the resource is changed after the history is read
+	target.GetMetadata().ModRevision = versions[0].(HasMetadata).GetMetadata().GetModRevision()
+	metadata.ModRevision = target.GetMetadata().GetModRevision()
+	metadata.Spec = target
+	return e.update(ctx, metadata)
+}
+
+func fromMessages[T proto.Message](messages []proto.Message) []T {
+	ts := make([]T, 0, len(messages))
+	for _, m := range messages {
+		ts = append(ts, m.(T))
+	}
+	return ts
+}
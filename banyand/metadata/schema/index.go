@@ -171,6 +171,26 @@ func (e *etcdSchemaRegistry) DeleteIndexRule(ctx context.Context, metadata *comm
 	})
 }
 
+func (e *etcdSchemaRegistry) GetIndexRuleHistory(ctx context.Context, metadata *commonv1.Metadata) ([]*databasev1.IndexRule, error) {
+	versions, err := e.history(ctx, KindIndexRule, formatIndexRuleKey(metadata))
+	if err != nil {
+		return nil, err
+	}
+	return fromMessages[*databasev1.IndexRule](versions), nil
+}
+
+// RollbackIndexRule isn't checked against the data, because the index of the stream can be rebuilt by the rule rolled back to.
+func (e *etcdSchemaRegistry) RollbackIndexRule(ctx context.Context, metadata *commonv1.Metadata, modRevision int64) error {
+	_, err := e.rollback(ctx, Metadata{
+		TypeMeta: TypeMeta{
+			Kind:  KindIndexRule,
+			Name:  metadata.GetName(),
+			Group: metadata.GetGroup(),
+		},
+	}, modRevision, nil)
+	return err
+}
+
 func formatIndexRuleKey(metadata *commonv1.Metadata) string {
 	return formatKey(indexRuleKeyPrefix, metadata)
 }
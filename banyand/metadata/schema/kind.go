@@ -42,10 +42,12 @@ const (
 	KindTopNAggregation
 	KindProperty
 	KindNode
+	KindAlertRule
+	KindTenant
 	KindMask = KindGroup | KindStream | KindMeasure |
 		KindIndexRuleBinding | KindIndexRule |
-		KindTopNAggregation | KindProperty | KindNode
-	KindSize = 9
+		KindTopNAggregation | KindProperty | KindNode | KindAlertRule | KindTenant
+	KindSize = 10
 )
 
 func (k Kind) key() string {
@@ -66,6 +68,10 @@ func (k Kind) key() string {
 		return propertyKeyPrefix
 	case KindNode:
 		return nodeKeyPrefix
+	case KindAlertRule:
+		return alertRuleKeyPrefix
+	case KindTenant:
+		return tenantKeyPrefix
 	default:
 		return "unknown"
 	}
@@ -76,28 +82,11 @@ func (k Kind) Unmarshal(kv *mvccpb.KeyValue) (Metadata, error) {
 	if len(kv.Value) == 0 {
 		return Metadata{}, io.EOF
 	}
-	var m proto.Message
-	switch k {
-	case KindGroup:
-		m = &commonv1.Group{}
-	case KindStream:
-		m = &databasev1.Stream{}
-	case KindMeasure:
-		m = &databasev1.Measure{}
-	case KindIndexRuleBinding:
-		m = &databasev1.IndexRuleBinding{}
-	case KindIndexRule:
-		m = &databasev1.IndexRule{}
-	case KindProperty:
-		m = &propertyv1.Property{}
-	case KindTopNAggregation:
-		m = &databasev1.TopNAggregation{}
-	case KindNode:
-		m = &databasev1.Node{}
-	default:
-		return Metadata{}, errUnsupportedEntityType
+	m, err := k.newMessage()
+	if err != nil {
+		return Metadata{}, err
 	}
-	err := proto.Unmarshal(kv.Value, m)
+	err = proto.Unmarshal(kv.Value, m)
 	if err != nil {
 		return Metadata{}, err
 	}
@@ -120,6 +109,38 @@ func (k Kind) Unmarshal(kv *mvccpb.KeyValue) (Metadata, error) {
 	return Metadata{Spec: m}, nil
 }
 
+func (k Kind) newMessage() (proto.Message, error) {
+	switch k {
+	case KindGroup:
+		return &commonv1.Group{}, nil
+	case KindStream:
+		return &databasev1.Stream{}, nil
+	case KindMeasure:
+		return &databasev1.Measure{}, nil
+	case KindIndexRuleBinding:
+		return &databasev1.IndexRuleBinding{}, nil
+	case KindIndexRule:
+		return &databasev1.IndexRule{}, nil
+	case KindProperty:
+		return &propertyv1.Property{}, nil
+	case KindTopNAggregation:
+		return &databasev1.TopNAggregation{}, nil
+	case KindNode:
+		return &databasev1.Node{}, nil
+	case KindAlertRule:
+		return &databasev1.AlertRule{}, nil
+	case KindTenant:
+		return &databasev1.Tenant{}, nil
+	default:
+		return nil, errUnsupportedEntityType
+	}
+}
+
+// versioned tells whether the versions of the resources replaced by the updates are kept in the history.
+func (k Kind) versioned() bool {
+	return k&(KindStream|KindMeasure|KindIndexRule) != 0
+}
+
 func (k Kind) String() string {
 	switch k {
 	case KindGroup:
@@ -138,6 +159,10 @@ func (k Kind) String() string {
 		return "property"
 	case KindNode:
 		return "node"
+	case KindAlertRule:
+		return "alertRule"
+	case KindTenant:
+		return "tenant"
 	default:
 		return "unknown"
 	}
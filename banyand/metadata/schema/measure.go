@@ -21,6 +21,7 @@ import (
 	"context"
 
 	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
@@ -65,6 +66,9 @@ func (e *etcdSchemaRegistry) CreateMeasure(ctx context.Context, measure *databas
 			return 0, errors.Wrap(err, "interval is malformed")
 		}
 	}
+	if err := validateTagFamilies(measure.GetTagFamilies()); err != nil {
+		return 0, err
+	}
 	return e.create(ctx, Metadata{
 		TypeMeta: TypeMeta{
 			Kind:  KindMeasure,
@@ -81,6 +85,12 @@ func (e *etcdSchemaRegistry) UpdateMeasure(ctx context.Context, measure *databas
 			return 0, errors.Wrap(err, "interval is malformed")
 		}
 	}
+	if err := validateTagFamilies(measure.GetTagFamilies()); err != nil {
+		return 0, err
+	}
+	if err := e.checkMeasureEvolution(ctx, measure); err != nil {
+		return 0, err
+	}
 	return e.update(ctx, Metadata{
 		TypeMeta: TypeMeta{
 			Kind:        KindMeasure,
@@ -102,6 +112,26 @@ func (e *etcdSchemaRegistry) DeleteMeasure(ctx context.Context, metadata *common
 	})
 }
 
+func (e *etcdSchemaRegistry) GetMeasureHistory(ctx context.Context, metadata *commonv1.Metadata) ([]*databasev1.Measure, error) {
+	versions, err := e.history(ctx, KindMeasure, formatMeasureKey(metadata))
+	if err != nil {
+		return nil, err
+	}
+	return fromMessages[*databasev1.Measure](versions), nil
+}
+
+func (e *etcdSchemaRegistry) RollbackMeasure(ctx context.Context, metadata *commonv1.Metadata, modRevision int64) (int64, error) {
+	return e.rollback(ctx, Metadata{
+		TypeMeta: TypeMeta{
+			Kind:  KindMeasure,
+			Group: metadata.GetGroup(),
+			Name:  metadata.GetName(),
+		},
+	}, modRevision, func(newer []proto.Message, target proto.Message) error {
+		return validateMeasureRollback(fromMessages[*databasev1.Measure](newer), target.(*databasev1.Measure))
+	})
+}
+
 func (e *etcdSchemaRegistry) TopNAggregations(ctx context.Context, metadata *commonv1.Metadata) ([]*databasev1.TopNAggregation, error) {
 	aggregations, err := e.ListTopNAggregation(ctx, ListOpt{Group: metadata.GetGroup()})
 	if err != nil {
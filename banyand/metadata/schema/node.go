@@ -21,6 +21,9 @@ import (
 	"context"
 	"path"
 
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/protobuf/proto"
+
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 )
 
@@ -57,6 +60,40 @@ func (e *etcdSchemaRegistry) RegisterNode(ctx context.Context, node *databasev1.
 	}, forced)
 }
 
+// UpdateNode overwrites a registered node while keeping the lease of its registration.
+func (e *etcdSchemaRegistry) UpdateNode(ctx context.Context, node *databasev1.Node) error {
+	if !e.closer.AddRunning() {
+		return ErrClosed
+	}
+	defer e.closer.Done()
+	val, err := proto.Marshal(node)
+	if err != nil {
+		return err
+	}
+	key := e.prependNamespace(formatNodeKey(node.GetMetadata().GetName()))
+	response, err := e.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), ">", 0)).
+		Then(clientv3.OpPut(key, string(val), clientv3.WithIgnoreLease())).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !response.Succeeded {
+		return ErrGRPCResourceNotFound
+	}
+	return nil
+}
+
+// UnregisterNode removes a node from the registry before its lease expires.
+func (e *etcdSchemaRegistry) UnregisterNode(ctx context.Context, name string) error {
+	if !e.closer.AddRunning() {
+		return ErrClosed
+	}
+	defer e.closer.Done()
+	_, err := e.client.Delete(ctx, e.prependNamespace(formatNodeKey(name)))
+	return err
+}
+
 func formatNodeKey(name string) string {
 	return path.Join(nodeKeyPrefix, name)
 }
@@ -54,7 +54,13 @@ type Registry interface {
 	TopNAggregation
 	Property
 	Node
+	AlertRule
+	Tenant
 	RegisterHandler(string, Kind, EventHandler)
+	// Snapshot writes the schema state to w, and returns the number of the keys written.
+	Snapshot(ctx context.Context, w io.Writer) (int, error)
+	// Restore puts the keys of a snapshot missing in the registry, and returns the number of the keys put.
+	Restore(ctx context.Context, r io.Reader) (int, error)
 }
 
 // TypeMeta defines the identity and type of an Event.
@@ -110,6 +116,13 @@ func (m Metadata) key() (string, error) {
 		}), nil
 	case KindNode:
 		return formatNodeKey(m.Name), nil
+	case KindAlertRule:
+		return formatAlertRuleKey(&commonv1.Metadata{
+			Group: m.Group,
+			Name:  m.Name,
+		}), nil
+	case KindTenant:
+		return formatTenantKey(m.Name), nil
 	default:
 		return "", errUnsupportedEntityType
 	}
@@ -134,6 +147,8 @@ type Stream interface {
 	CreateStream(ctx context.Context, stream *databasev1.Stream) (int64, error)
 	UpdateStream(ctx context.Context, stream *databasev1.Stream) (int64, error)
 	DeleteStream(ctx context.Context, metadata *commonv1.Metadata) (bool, error)
+	GetStreamHistory(ctx context.Context, metadata *commonv1.Metadata) ([]*databasev1.Stream, error)
+	RollbackStream(ctx context.Context, metadata *commonv1.Metadata, modRevision int64) (int64, error)
 }
 
 // IndexRule allows CRUD index rule schemas in a group.
@@ -143,6 +158,8 @@ type IndexRule interface {
 	CreateIndexRule(ctx context.Context, indexRule *databasev1.IndexRule) error
 	UpdateIndexRule(ctx context.Context, indexRule *databasev1.IndexRule) error
 	DeleteIndexRule(ctx context.Context, metadata *commonv1.Metadata) (bool, error)
+	GetIndexRuleHistory(ctx context.Context, metadata *commonv1.Metadata) ([]*databasev1.IndexRule, error)
+	RollbackIndexRule(ctx context.Context, metadata *commonv1.Metadata, modRevision int64) error
 }
 
 // IndexRuleBinding allows CRUD index rule binding schemas in a group.
@@ -161,6 +178,8 @@ type Measure interface {
 	CreateMeasure(ctx context.Context, measure *databasev1.Measure) (int64, error)
 	UpdateMeasure(ctx context.Context, measure *databasev1.Measure) (int64, error)
 	DeleteMeasure(ctx context.Context, metadata *commonv1.Metadata) (bool, error)
+	GetMeasureHistory(ctx context.Context, metadata *commonv1.Metadata) ([]*databasev1.Measure, error)
+	RollbackMeasure(ctx context.Context, metadata *commonv1.Metadata, modRevision int64) (int64, error)
 	TopNAggregations(ctx context.Context, metadata *commonv1.Metadata) ([]*databasev1.TopNAggregation, error)
 }
 
@@ -183,6 +202,24 @@ type TopNAggregation interface {
 	DeleteTopNAggregation(ctx context.Context, metadata *commonv1.Metadata) (bool, error)
 }
 
+// AlertRule allows CRUD alert rules in a group.
+type AlertRule interface {
+	GetAlertRule(ctx context.Context, metadata *commonv1.Metadata) (*databasev1.AlertRule, error)
+	ListAlertRule(ctx context.Context, opt ListOpt) ([]*databasev1.AlertRule, error)
+	CreateAlertRule(ctx context.Context, alertRule *databasev1.AlertRule) error
+	UpdateAlertRule(ctx context.Context, alertRule *databasev1.AlertRule) error
+	DeleteAlertRule(ctx context.Context, metadata *commonv1.Metadata) (bool, error)
+}
+
+// Tenant allows CRUD tenants, which aren't in any group.
+type Tenant interface {
+	GetTenant(ctx context.Context, name string) (*databasev1.Tenant, error)
+	ListTenant(ctx context.Context) ([]*databasev1.Tenant, error)
+	CreateTenant(ctx context.Context, tenant *databasev1.Tenant) error
+	UpdateTenant(ctx context.Context, tenant *databasev1.Tenant) error
+	DeleteTenant(ctx context.Context, name string) (bool, error)
+}
+
 // Property allows CRUD properties or tags in group.
 type Property interface {
 	GetProperty(ctx context.Context, metadata *propertyv1.Metadata, tags []string) (*propertyv1.Property, error)
@@ -196,4 +233,6 @@ type Property interface {
 type Node interface {
 	ListNode(ctx context.Context, role databasev1.Role) ([]*databasev1.Node, error)
 	RegisterNode(ctx context.Context, node *databasev1.Node, forced bool) error
+	UpdateNode(ctx context.Context, node *databasev1.Node) error
+	UnregisterNode(ctx context.Context, name string) error
 }
@@ -0,0 +1,100 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// snapshotEntry is a line of a schema snapshot, whose key is relative to the namespace.
+type snapshotEntry struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// namespacePrefix is the prefix of all keys of the registry.
+func (e *etcdSchemaRegistry) namespacePrefix() string {
+	if e.namespace == "" {
+		return "/"
+	}
+	return path.Join("/", e.namespace) + "/"
+}
+
+// Snapshot writes the keys of the namespace at a revision to w as JSON lines.
+// The nodes and the leased properties are skipped since they expire once their owners are gone.
+func (e *etcdSchemaRegistry) Snapshot(ctx context.Context, w io.Writer) (int, error) {
+	if !e.closer.AddRunning() {
+		return 0, ErrClosed
+	}
+	defer e.closer.Done()
+	prefix := e.namespacePrefix()
+	resp, err := e.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(w)
+	var n int
+	for _, kv := range resp.Kvs {
+		key := strings.TrimPrefix(string(kv.Key), prefix)
+		if kv.Lease != 0 || strings.HasPrefix("/"+key, nodeKeyPrefix) {
+			continue
+		}
+		if err = enc.Encode(snapshotEntry{Key: key, Value: kv.Value}); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Restore puts the keys of a snapshot read from r into the namespace.
+// The existing keys are kept, so restoring a snapshot again only adds the keys missing.
+func (e *etcdSchemaRegistry) Restore(ctx context.Context, r io.Reader) (int, error) {
+	if !e.closer.AddRunning() {
+		return 0, ErrClosed
+	}
+	defer e.closer.Done()
+	prefix := e.namespacePrefix()
+	dec := json.NewDecoder(r)
+	var n int
+	for {
+		var entry snapshotEntry
+		if err := dec.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return n, nil
+			}
+			return n, errors.WithMessage(err, "invalid schema snapshot")
+		}
+		key := prefix + entry.Key
+		resp, err := e.client.Txn(ctx).If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+			Then(clientv3.OpPut(key, string(entry.Value))).Commit()
+		if err != nil {
+			return n, err
+		}
+		if resp.Succeeded {
+			n++
+		}
+	}
+}
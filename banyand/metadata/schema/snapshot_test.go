@@ -0,0 +1,65 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schema
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+)
+
+func Test_Etcd_Snapshot_Restore(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+	registry, closer := initServerAndRegister(t)
+	defer closer()
+	req.NoError(preloadSchema(registry))
+	// the nodes aren't in the snapshots
+	req.NoError(registry.RegisterNode(ctx, &databasev1.Node{Metadata: &commonv1.Metadata{Name: "data-1"}, Roles: []databasev1.Role{databasev1.Role_ROLE_DATA}}, false))
+
+	var buf bytes.Buffer
+	n, err := registry.Snapshot(ctx, &buf)
+	req.NoError(err)
+	req.Positive(n)
+	snapshot := buf.Bytes()
+
+	restored, restoredCloser := initServerAndRegister(t)
+	defer restoredCloser()
+	restoredN, err := restored.Restore(ctx, bytes.NewReader(snapshot))
+	req.NoError(err)
+	req.Equal(n, restoredN)
+	stream, err := restored.GetStream(ctx, &commonv1.Metadata{Name: "sw", Group: "default"})
+	req.NoError(err)
+	req.Equal("sw", stream.GetMetadata().GetName())
+	nodes, err := restored.ListNode(ctx, databasev1.Role_ROLE_DATA)
+	req.NoError(err)
+	req.Empty(nodes)
+
+	// the existing keys are kept
+	restoredN, err = restored.Restore(ctx, bytes.NewReader(snapshot))
+	req.NoError(err)
+	req.Zero(restoredN)
+
+	_, err = restored.Restore(ctx, bytes.NewReader([]byte("{")))
+	req.Error(err)
+}
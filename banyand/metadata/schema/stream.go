@@ -20,6 +20,7 @@ package schema
 import (
 	"context"
 
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
@@ -52,6 +53,12 @@ func (e *etcdSchemaRegistry) ListStream(ctx context.Context, opt ListOpt) ([]*da
 }
 
 func (e *etcdSchemaRegistry) UpdateStream(ctx context.Context, stream *databasev1.Stream) (int64, error) {
+	if err := validateTagFamilies(stream.GetTagFamilies()); err != nil {
+		return 0, err
+	}
+	if err := e.checkStreamEvolution(ctx, stream); err != nil {
+		return 0, err
+	}
 	return e.update(ctx, Metadata{
 		TypeMeta: TypeMeta{
 			Kind:        KindStream,
@@ -67,6 +74,9 @@ func (e *etcdSchemaRegistry) CreateStream(ctx context.Context, stream *databasev
 	if stream.UpdatedAt != nil {
 		stream.UpdatedAt = timestamppb.Now()
 	}
+	if err := validateTagFamilies(stream.GetTagFamilies()); err != nil {
+		return 0, err
+	}
 	group := stream.Metadata.GetGroup()
 	_, err := e.GetGroup(ctx, group)
 	if err != nil {
@@ -92,6 +102,26 @@ func (e *etcdSchemaRegistry) DeleteStream(ctx context.Context, metadata *commonv
 	})
 }
 
+func (e *etcdSchemaRegistry) GetStreamHistory(ctx context.Context, metadata *commonv1.Metadata) ([]*databasev1.Stream, error) {
+	versions, err := e.history(ctx, KindStream, formatStreamKey(metadata))
+	if err != nil {
+		return nil, err
+	}
+	return fromMessages[*databasev1.Stream](versions), nil
+}
+
+func (e *etcdSchemaRegistry) RollbackStream(ctx context.Context, metadata *commonv1.Metadata, modRevision int64) (int64, error) {
+	return e.rollback(ctx, Metadata{
+		TypeMeta: TypeMeta{
+			Kind:  KindStream,
+			Group: metadata.GetGroup(),
+			Name:  metadata.GetName(),
+		},
+	}, modRevision, func(newer []proto.Message, target proto.Message) error {
+		return validateStreamRollback(fromMessages[*databasev1.Stream](newer), target.(*databasev1.Stream))
+	})
+}
+
 func formatStreamKey(metadata *commonv1.Metadata) string {
 	return formatKey(streamKeyPrefix, metadata)
 }
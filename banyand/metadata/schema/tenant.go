@@ -0,0 +1,129 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schema
+
+import (
+	"context"
+	"path"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+)
+
+var tenantKeyPrefix = "/tenants/"
+
+func (e *etcdSchemaRegistry) GetTenant(ctx context.Context, name string) (*databasev1.Tenant, error) {
+	var entity databasev1.Tenant
+	if err := e.get(ctx, formatTenantKey(name), &entity); err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+func (e *etcdSchemaRegistry) ListTenant(ctx context.Context) ([]*databasev1.Tenant, error) {
+	messages, err := e.listWithPrefix(ctx, tenantKeyPrefix, KindTenant)
+	if err != nil {
+		return nil, err
+	}
+	entities := make([]*databasev1.Tenant, 0, len(messages))
+	for _, message := range messages {
+		entities = append(entities, message.(*databasev1.Tenant))
+	}
+	return entities, nil
+}
+
+func (e *etcdSchemaRegistry) CreateTenant(ctx context.Context, tenant *databasev1.Tenant) error {
+	if err := e.validateTenant(ctx, tenant); err != nil {
+		return err
+	}
+	if tenant.UpdatedAt != nil {
+		tenant.UpdatedAt = timestamppb.Now()
+	}
+	_, err := e.create(ctx, Metadata{
+		TypeMeta: TypeMeta{
+			Kind: KindTenant,
+			Name: tenant.GetMetadata().GetName(),
+		},
+		Spec: tenant,
+	})
+	return err
+}
+
+func (e *etcdSchemaRegistry) UpdateTenant(ctx context.Context, tenant *databasev1.Tenant) error {
+	if err := e.validateTenant(ctx, tenant); err != nil {
+		return err
+	}
+	_, err := e.update(ctx, Metadata{
+		TypeMeta: TypeMeta{
+			Kind: KindTenant,
+			Name: tenant.GetMetadata().GetName(),
+		},
+		Spec: tenant,
+	})
+	return err
+}
+
+func (e *etcdSchemaRegistry) DeleteTenant(ctx context.Context, name string) (bool, error) {
+	return e.delete(ctx, Metadata{
+		TypeMeta: TypeMeta{
+			Kind: KindTenant,
+			Name: name,
+		},
+	})
+}
+
+func formatTenantKey(name string) string {
+	return path.Join(tenantKeyPrefix, name)
+}
+
+// validateTenant rejects a tenant without a name, or owning a group which another tenant owns.
+func (e *etcdSchemaRegistry) validateTenant(ctx context.Context, tenant *databasev1.Tenant) error {
+	name := tenant.GetMetadata().GetName()
+	if name == "" {
+		return BadRequest("metadata.name", "name should not be empty")
+	}
+	if tenant.GetMetadata().GetGroup() != "" {
+		return BadRequest("metadata.group", "a tenant doesn't belong to a group")
+	}
+	owned := make(map[string]struct{}, len(tenant.GetGroups()))
+	for _, g := range tenant.GetGroups() {
+		if g == "" {
+			return BadRequest("groups", "group should not be empty")
+		}
+		if _, ok := owned[g]; ok {
+			return BadRequest("groups", "group "+g+" is listed more than once")
+		}
+		owned[g] = struct{}{}
+	}
+	others, err := e.ListTenant(ctx)
+	if err != nil {
+		return err
+	}
+	for _, other := range others {
+		if other.GetMetadata().GetName() == name {
+			continue
+		}
+		for _, g := range other.GetGroups() {
+			if _, ok := owned[g]; ok {
+				return BadRequest("groups", "group "+g+" is owned by the tenant "+other.GetMetadata().GetName())
+			}
+		}
+	}
+	return nil
+}
@@ -21,18 +21,36 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"time"
 
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	"github.com/apache/skywalking-banyandb/banyand/metadata/embeddedetcd"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/run"
 )
 
+const (
+	clusterStateNew      = "new"
+	clusterStateExisting = "existing"
+)
+
 type server struct {
 	Service
-	metaServer      embeddedetcd.Server
-	rootDir         string
-	listenClientURL []string
-	listenPeerURL   []string
+	metaServer         embeddedetcd.Server
+	client             *clientService
+	closer             *run.Closer
+	l                  *logger.Logger
+	rootDir            string
+	memberName         string
+	initialCluster     string
+	clusterState       string
+	restoreFile        string
+	listenClientURL    []string
+	listenPeerURL      []string
+	advertiseClientURL []string
+	snapshotInterval   time.Duration
+	// optional servers run only if they're members of a cluster, otherwise the client connects the external etcd.
+	optional bool
 }
 
 func (s *server) Name() string {
@@ -45,13 +63,32 @@ func (s *server) Role() databasev1.Role {
 
 func (s *server) FlagSet() *run.FlagSet {
 	fs := run.NewFlagSet("metadata")
+	if s.optional {
+		fs.AddFlagSet(s.client.FlagSet().FlagSet)
+	}
 	fs.StringVar(&s.rootDir, "metadata-root-path", "/tmp", "the root path of metadata")
 	fs.StringArrayVar(&s.listenClientURL, "etcd-listen-client-url", []string{"http://localhost:2379"}, "A URL to listen on for client traffic")
 	fs.StringArrayVar(&s.listenPeerURL, "etcd-listen-peer-url", []string{"http://localhost:2380"}, "A URL to listen on for peer traffic")
+	fs.StringArrayVar(&s.advertiseClientURL, "etcd-advertise-client-url", nil, "A URL advertised to the clients, which is the one to listen on if it's absent")
+	fs.StringVar(&s.memberName, "metadata-member-name", "", "the name of the member in the metadata-initial-cluster")
+	fs.StringVar(&s.initialCluster, "metadata-initial-cluster", "",
+		"the members of the embedded metadata cluster as name=peer-url pairs separated by commas, such as \"m1=http://10.0.0.1:2380,m2=http://10.0.0.2:2380\"")
+	fs.StringVar(&s.clusterState, "metadata-initial-cluster-state", clusterStateNew,
+		"\"new\" if the members bootstrap the metadata cluster together, or \"existing\" if the member joins a running one")
+	fs.DurationVar(&s.snapshotInterval, "metadata-snapshot-interval", time.Hour, "the interval of taking the snapshots of the schema state, 0 disables the snapshots")
+	fs.StringVar(&s.restoreFile, "metadata-restore-file", "", "a snapshot of the schema state restored once the metadata server is ready, which skips the existing resources")
 	return fs
 }
 
+// embedded returns true if the server runs an embedded etcd.
+func (s *server) embedded() bool {
+	return !s.optional || s.initialCluster != ""
+}
+
 func (s *server) Validate() error {
+	if !s.embedded() {
+		return s.Service.Validate()
+	}
 	if s.rootDir == "" {
 		return errors.New("rootDir is empty")
 	}
@@ -61,40 +98,91 @@ func (s *server) Validate() error {
 	if s.listenPeerURL == nil {
 		return errors.New("listenPeerURL is empty")
 	}
-	if err := s.Service.FlagSet().Set(flagEtcdEndpointsName,
-		strings.Join(s.listenClientURL, ",")); err != nil {
+	if s.initialCluster != "" && s.memberName == "" {
+		return errors.New("metadata-member-name is empty")
+	}
+	if s.clusterState != clusterStateNew && s.clusterState != clusterStateExisting {
+		return errors.New("metadata-initial-cluster-state should be \"new\" or \"existing\"")
+	}
+	if s.snapshotInterval < 0 {
+		return errors.New("metadata-snapshot-interval must not be negative")
+	}
+	endpoints := s.listenClientURL
+	if len(s.advertiseClientURL) > 0 {
+		endpoints = s.advertiseClientURL
+	}
+	if s.optional {
+		// the client flags are parsed along with the server's
+		s.client.endpoints = endpoints
+	} else if err := s.Service.FlagSet().Set(flagEtcdEndpointsName,
+		strings.Join(endpoints, ",")); err != nil {
 		return err
 	}
 	return s.Service.Validate()
 }
 
 func (s *server) PreRun(ctx context.Context) error {
+	s.l = logger.GetLogger("metadata-server")
+	if !s.embedded() {
+		return s.Service.PreRun(ctx)
+	}
+	opts := []embeddedetcd.Option{
+		embeddedetcd.RootDir(s.rootDir),
+		embeddedetcd.ConfigureListener(s.listenClientURL, s.listenPeerURL),
+		embeddedetcd.ConfigureAdvertiseClientURLs(s.advertiseClientURL),
+	}
+	if s.initialCluster != "" {
+		opts = append(opts, embeddedetcd.ConfigureCluster(s.memberName, s.initialCluster, s.clusterState))
+	}
 	var err error
-	s.metaServer, err = embeddedetcd.NewServer(embeddedetcd.RootDir(s.rootDir), embeddedetcd.ConfigureListener(s.listenClientURL, s.listenPeerURL))
+	s.metaServer, err = embeddedetcd.NewServer(opts...)
 	if err != nil {
 		return err
 	}
 	<-s.metaServer.ReadyNotify()
-	return s.Service.PreRun(ctx)
+	s.l.Info().Str("member", s.memberName).Bool("leader", s.metaServer.IsLeader()).Msg("the metadata server is ready")
+	if err = s.Service.PreRun(ctx); err != nil {
+		return err
+	}
+	if s.restoreFile != "" {
+		return s.restore(ctx)
+	}
+	return nil
 }
 
 func (s *server) Serve() run.StopNotify {
+	if !s.embedded() {
+		return s.Service.Serve()
+	}
+	if s.snapshotInterval > 0 {
+		go s.snapshotLoop()
+	} else {
+		s.closer.Done()
+	}
 	return s.metaServer.StoppingNotify()
 }
 
 func (s *server) GracefulStop() {
+	if s.embedded() {
+		s.closer.CloseThenWait()
+	}
 	s.Service.GracefulStop()
-	s.metaServer.Close()
-	<-s.metaServer.StopNotify()
+	if s.metaServer != nil {
+		s.metaServer.Close()
+		<-s.metaServer.StopNotify()
+	}
 }
 
 // NewService returns a new metadata repository Service.
 func NewService(_ context.Context) (Service, error) {
-	s := &server{}
-	var err error
-	s.Service, err = NewClient(true)
-	if err != nil {
-		return nil, err
-	}
-	return s, nil
+	client := newClientService(true)
+	return &server{Service: client, client: client, closer: run.NewCloser(1)}, nil
+}
+
+// NewClusterClient returns a metadata client of the liaison or data nodes.
+// The node runs a member of an embedded metadata cluster if metadata-initial-cluster is set,
+// which allows small clusters to run without an external etcd.
+func NewClusterClient(forceRegisterNode bool) (Service, error) {
+	client := newClientService(forceRegisterNode)
+	return &server{Service: client, client: client, closer: run.NewCloser(1), optional: true}, nil
 }
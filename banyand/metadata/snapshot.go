@@ -0,0 +1,118 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package metadata
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	schemaSnapshotDir        = "schema-snapshots"
+	schemaSnapshotPrefix     = "schema-"
+	schemaSnapshotSuffix     = ".json"
+	schemaSnapshotNameLayout = "20060102T150405Z"
+	// schemaSnapshotKeep is the number of the latest snapshots kept.
+	schemaSnapshotKeep = 3
+)
+
+// snapshotLoop takes the snapshots of the schema state periodically.
+// Every member takes its own, so the schema can be restored from any member left.
+func (s *server) snapshotLoop() {
+	defer s.closer.Done()
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closer.CloseNotify():
+			return
+		case now := <-ticker.C:
+			file, n, err := s.snapshot(s.closer.Ctx(), now)
+			if err != nil {
+				s.l.Error().Err(err).Msg("failed to take a snapshot of the schema")
+				continue
+			}
+			s.l.Info().Str("file", file).Int("keys", n).Bool("leader", s.metaServer.IsLeader()).Msg("took a snapshot of the schema")
+		}
+	}
+}
+
+// snapshot writes the schema state to a new file under the root, and removes the ones older than the latest schemaSnapshotKeep.
+func (s *server) snapshot(ctx context.Context, now time.Time) (string, int, error) {
+	dir := filepath.Join(s.rootDir, schemaSnapshotDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", 0, err
+	}
+	file := filepath.Join(dir, schemaSnapshotPrefix+now.UTC().Format(schemaSnapshotNameLayout)+schemaSnapshotSuffix)
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", 0, err
+	}
+	n, err := s.SchemaRegistry().Snapshot(ctx, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", 0, err
+	}
+	// the snapshot is visible once it's completed
+	if err = os.Rename(tmp, file); err != nil {
+		return "", 0, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return file, n, err
+	}
+	var snapshots []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), schemaSnapshotPrefix) && strings.HasSuffix(e.Name(), schemaSnapshotSuffix) {
+			snapshots = append(snapshots, e.Name())
+		}
+	}
+	// the names are sorted by the time they're taken
+	slices.Sort(snapshots)
+	for len(snapshots) > schemaSnapshotKeep {
+		if err = os.Remove(filepath.Join(dir, snapshots[0])); err != nil {
+			return file, n, err
+		}
+		snapshots = snapshots[1:]
+	}
+	return file, n, nil
+}
+
+func (s *server) restore(ctx context.Context) error {
+	f, err := os.Open(s.restoreFile)
+	if err != nil {
+		return errors.WithMessage(err, "failed to open the schema snapshot")
+	}
+	defer f.Close()
+	n, err := s.SchemaRegistry().Restore(ctx, f)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to restore the schema snapshot %s", s.restoreFile)
+	}
+	s.l.Info().Str("file", s.restoreFile).Int("keys", n).Msg("restored the schema snapshot")
+	return nil
+}
@@ -0,0 +1,87 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package observability
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// groupSizeTTL is how long the sizes of the groups are cached, since walking the directories is costly.
+const groupSizeTTL = 30 * time.Second
+
+var groupSizes = &groupSizeCache{roots: make(map[string]struct{})}
+
+type groupSizeCache struct {
+	roots     map[string]struct{}
+	sizes     map[string]uint64
+	updatedAt time.Time
+	sync.Mutex
+}
+
+// UpdateGroupRoot registers a path whose subdirectories hold the data of the groups, whose sizes are reported by GroupSizes.
+func UpdateGroupRoot(path string) {
+	groupSizes.Lock()
+	defer groupSizes.Unlock()
+	groupSizes.roots[path] = struct{}{}
+	groupSizes.updatedAt = time.Time{}
+}
+
+// GroupSizes returns the size in bytes of the files of every group summed up over the registered roots.
+// The sizes are refreshed at most once every groupSizeTTL.
+func GroupSizes() map[string]uint64 {
+	groupSizes.Lock()
+	defer groupSizes.Unlock()
+	if groupSizes.sizes != nil && time.Since(groupSizes.updatedAt) < groupSizeTTL {
+		return groupSizes.sizes
+	}
+	sizes := make(map[string]uint64)
+	for root := range groupSizes.roots {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			sizes[e.Name()] += dirSize(filepath.Join(root, e.Name()))
+		}
+	}
+	groupSizes.sizes = sizes
+	groupSizes.updatedAt = time.Now()
+	return sizes
+}
+
+// dirSize sums up the sizes of the files in the dir. The files removed during the walk are skipped.
+func dirSize(dir string) uint64 {
+	var size uint64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, errInfo := d.Info(); errInfo == nil {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size
+}
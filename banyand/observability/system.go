@@ -66,6 +66,18 @@ func getPath() map[string]*DiskUsage {
 	return systemInfoInstance.DiskUsages
 }
 
+// DiskUsages returns the disk usage of the monitored paths.
+// A path which can't be inspected is left out.
+func DiskUsages() map[string]DiskUsage {
+	result := make(map[string]DiskUsage)
+	for k := range getPath() {
+		if usage, err := getDiskUsage(k); err == nil {
+			result[k] = usage
+		}
+	}
+	return result
+}
+
 // SystemInfo represents the system information of a node.
 type SystemInfo struct {
 	Addresses   map[string]string     `json:"addresses"`
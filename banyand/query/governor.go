@@ -0,0 +1,89 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package query
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/pkg/query/executor"
+)
+
+var errTooManyQueries = errors.New("too many concurrent queries")
+
+// groupLimiter bounds the queries running concurrently in each group.
+type groupLimiter struct {
+	groups  map[string]chan struct{}
+	timeout time.Duration
+	limit   int
+	mu      sync.Mutex
+}
+
+func newGroupLimiter(limit int, timeout time.Duration) *groupLimiter {
+	return &groupLimiter{
+		groups:  make(map[string]chan struct{}),
+		limit:   limit,
+		timeout: timeout,
+	}
+}
+
+// acquire waits up to the timeout for a query of the group to start, and returns the function to call once it's done.
+// It never waits if the group is unlimited.
+func (gl *groupLimiter) acquire(group string) (func(), error) {
+	if gl.limit <= 0 {
+		return func() {}, nil
+	}
+	gl.mu.Lock()
+	sem, ok := gl.groups[group]
+	if !ok {
+		sem = make(chan struct{}, gl.limit)
+		gl.groups[group] = sem
+	}
+	gl.mu.Unlock()
+	release := func() { <-sem }
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+	timer := time.NewTimer(gl.timeout)
+	defer timer.Stop()
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, errors.WithMessagef(errTooManyQueries, "group %s runs %d queries", group, gl.limit)
+	}
+}
+
+// govern admits a query of the group, and returns the context accounting its memory.
+// The function returned has to be called once the query is done.
+func (q *queryService) govern(ctx context.Context, group string) (context.Context, *executor.MemoryAccount, func(), error) {
+	release, err := q.limiter.acquire(group)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	account, ctx := q.memoryPool.NewAccount(ctx, int64(q.maxQueryMemory))
+	return ctx, account, func() {
+		account.Close()
+		release()
+	}, nil
+}
@@ -21,7 +21,9 @@ import (
 	"context"
 	"time"
 
+	"github.com/pkg/errors"
 	"go.uber.org/multierr"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/api/data"
@@ -34,8 +36,10 @@ import (
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/query/executor"
+	"github.com/apache/skywalking-banyandb/pkg/query/logical"
 	logical_measure "github.com/apache/skywalking-banyandb/pkg/query/logical/measure"
 	logical_stream "github.com/apache/skywalking-banyandb/pkg/query/logical/stream"
+	"github.com/apache/skywalking-banyandb/pkg/query/spill"
 	"github.com/apache/skywalking-banyandb/pkg/run"
 )
 
@@ -45,6 +49,7 @@ const (
 
 var (
 	_ run.PreRunner       = (*queryService)(nil)
+	_ run.Config          = (*queryService)(nil)
 	_ bus.MessageListener = (*streamQueryProcessor)(nil)
 	_ bus.MessageListener = (*measureQueryProcessor)(nil)
 	_ bus.MessageListener = (*topNQueryProcessor)(nil)
@@ -53,11 +58,19 @@ var (
 type queryService struct {
 	log *logger.Logger
 	// TODO: remove the metaService once https://github.com/apache/skywalking/issues/10121 is fixed.
-	metaService metadata.Repo
-	pipeline    queue.Server
-	sqp         *streamQueryProcessor
-	mqp         *measureQueryProcessor
-	tqp         *topNQueryProcessor
+	metaService       metadata.Repo
+	pipeline          queue.Server
+	sqp               *streamQueryProcessor
+	mqp               *measureQueryProcessor
+	tqp               *topNQueryProcessor
+	memoryPool        *executor.MemoryPool
+	limiter           *groupLimiter
+	spillRoot         string
+	maxGroups         int
+	maxMemory         run.Bytes
+	maxQueryMemory    run.Bytes
+	maxGroupQueries   int
+	groupQueueTimeout time.Duration
 }
 
 type streamQueryProcessor struct {
@@ -88,7 +101,13 @@ func (p *streamQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 		return
 	}
 
+	var span *executor.Span
+	if queryCriteria.GetTrace() {
+		span = executor.NewSpan("stream query")
+	}
+	analyze := span.Child("analyze")
 	plan, err := logical_stream.Analyze(context.TODO(), queryCriteria, meta, s)
+	analyze.End()
 	if err != nil {
 		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to analyze the query request for stream %s: %v", meta.GetName(), err))
 		return
@@ -97,14 +116,52 @@ func (p *streamQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 	if p.log.Debug().Enabled() {
 		p.log.Debug().Str("plan", plan.String()).Msg("query plan")
 	}
-	entities, err := plan.(executor.StreamExecutable).Execute(executor.WithStreamExecutionContext(context.Background(), ec))
+	// the query is canceled once the liaison gives up
+	ctx, _, done, err := p.govern(message.Context(), meta.GetGroup())
+	if err != nil {
+		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to admit the query for stream %s: %v", meta.GetName(), err))
+		return
+	}
+	defer done()
+	// the blocks skipped by the query annotate the response
+	warnings := &executor.Warnings{}
+	ctx = executor.WithSpan(executor.WithWarnings(executor.WithStreamExecutionContext(ctx, ec), warnings), span)
+	var batches *executor.ColumnBatches
+	if queryCriteria.GetColumnar() {
+		batches = &executor.ColumnBatches{}
+		ctx = executor.WithColumnBatches(ctx, batches)
+	}
+	if queryCriteria.GetExplain() {
+		explained, errExplain := logical.Explain(ctx, plan)
+		if errExplain != nil {
+			resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to explain the query plan for stream %s: %v", meta.GetName(), errExplain))
+			return
+		}
+		resp = bus.NewMessage(bus.MessageID(now), &streamv1.QueryResponse{Plan: explained})
+		return
+	}
+	entities, err := plan.(executor.StreamExecutable).Execute(ctx)
+	// the query is cut short once it's canceled or exceeds the memory budget
+	if cause := context.Cause(ctx); cause != nil {
+		err = cause
+	}
 	if err != nil {
 		p.log.Error().Err(err).RawJSON("req", logger.Proto(queryCriteria)).Msg("fail to execute the query plan")
 		resp = bus.NewMessage(bus.MessageID(now), common.NewError("execute the query plan for stream %s: %v", meta.GetName(), err))
 		return
 	}
 
-	resp = bus.NewMessage(bus.MessageID(now), &streamv1.QueryResponse{Elements: entities})
+	span.End()
+	if batches != nil {
+		resp = bus.NewMessage(bus.MessageID(now), &streamv1.QueryResponse{Batches: batches.List(), Warnings: warnings.List(), Trace: span.Proto()})
+		return
+	}
+	resp = bus.NewMessage(bus.MessageID(now), &streamv1.QueryResponse{
+		Elements:          entities,
+		Warnings:          warnings.List(),
+		ContinuationToken: logical_stream.ContinuationToken(plan, entities),
+		Trace:             span.Proto(),
+	})
 
 	return
 }
@@ -132,6 +189,19 @@ func (p *measureQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to get execution context for measure %s: %v", meta.GetName(), err))
 		return
 	}
+	rollupCriteria, err := logical_measure.RollupRequest(ec.GetSchema(), queryCriteria)
+	if err != nil {
+		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to pick the rollup for measure %s: %v", meta.GetName(), err))
+		return
+	}
+	if rollupCriteria != queryCriteria {
+		queryCriteria, meta = rollupCriteria, rollupCriteria.GetMetadata()
+		if ec, err = p.measureService.Measure(meta); err != nil {
+			resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to get execution context for the rollup %s of measure %s: %v",
+				meta.GetGroup(), meta.GetName(), err))
+			return
+		}
+	}
 
 	s, err := logical_measure.BuildSchema(ec.GetSchema(), ec.GetIndexRules())
 	if err != nil {
@@ -139,7 +209,13 @@ func (p *measureQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 		return
 	}
 
+	var span *executor.Span
+	if queryCriteria.GetTrace() {
+		span = executor.NewSpan("measure query")
+	}
+	analyze := span.Child("analyze")
 	plan, err := logical_measure.Analyze(context.TODO(), queryCriteria, meta, s)
+	analyze.End()
 	if err != nil {
 		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to analyze the query request for measure %s: %v", meta.GetName(), err))
 		return
@@ -149,7 +225,15 @@ func (p *measureQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 		e.Str("plan", plan.String()).Msg("query plan")
 	}
 
-	mIterator, err := plan.(executor.MeasureExecutable).Execute(executor.WithMeasureExecutionContext(context.Background(), ec))
+	// the query is canceled once the liaison gives up
+	ctx, account, done, err := p.govern(message.Context(), meta.GetGroup())
+	if err != nil {
+		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to admit the query for measure %s: %v", meta.GetName(), err))
+		return
+	}
+	defer done()
+	ctx = executor.WithSpan(executor.WithGroupSpill(executor.WithMeasureExecutionContext(ctx, ec), p.groupSpill()), span)
+	mIterator, err := plan.(executor.MeasureExecutable).Execute(ctx)
 	if err != nil {
 		ml.Error().Err(err).RawJSON("req", logger.Proto(queryCriteria)).Msg("fail to close the query plan")
 		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to execute the query plan for measure %s: %v", meta.GetName(), err))
@@ -163,14 +247,29 @@ func (p *measureQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 	result := make([]*measurev1.DataPoint, 0)
 	for mIterator.Next() {
 		current := mIterator.Current()
-		if len(current) > 0 {
-			result = append(result, current[0])
+		if len(current) < 1 {
+			continue
 		}
+		if account.Grow(int64(proto.Size(current[0]))) != nil {
+			break
+		}
+		result = append(result, current[0])
+	}
+	// the query is cut short once it's canceled or exceeds the memory budget
+	if cause := context.Cause(ctx); cause != nil {
+		ml.Error().Err(cause).RawJSON("req", logger.Proto(queryCriteria)).Msg("fail to execute the query plan")
+		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to execute the query plan for measure %s: %v", meta.GetName(), cause))
+		return
 	}
 	if e := ml.Debug(); e.Enabled() {
 		e.RawJSON("ret", logger.Proto(&measurev1.QueryResponse{DataPoints: result})).Msg("got a measure")
 	}
-	resp = bus.NewMessage(bus.MessageID(now), &measurev1.QueryResponse{DataPoints: result})
+	span.End()
+	resp = bus.NewMessage(bus.MessageID(now), &measurev1.QueryResponse{
+		DataPoints:        result,
+		ContinuationToken: logical_measure.ContinuationToken(plan, result),
+		Trace:             span.Proto(),
+	})
 	return
 }
 
@@ -178,10 +277,52 @@ func (q *queryService) Name() string {
 	return moduleName
 }
 
+func (q *queryService) FlagSet() *run.FlagSet {
+	fs := run.NewFlagSet(moduleName)
+	fs.StringVar(&q.spillRoot, "query-spill-root", "",
+		"the directory to spill the groups of the measure queries, spilling is disabled if it's empty")
+	fs.IntVar(&q.maxGroups, "query-max-groups", 100000,
+		"the max groups a measure query aggregates in memory before spilling the data points of the others")
+	fs.VarP(&q.maxMemory, "query-max-memory", "", "the max bytes all the queries hold, 0 means unlimited")
+	fs.VarP(&q.maxQueryMemory, "query-max-memory-per-query", "", "the max bytes a query holds, 0 means unlimited")
+	fs.IntVar(&q.maxGroupQueries, "query-max-concurrency-per-group", 0,
+		"the max queries running concurrently in a group, 0 means unlimited")
+	fs.DurationVar(&q.groupQueueTimeout, "query-queue-timeout", 5*time.Second,
+		"the time a query waits for the others of its group to finish, it's rejected afterwards")
+	return fs
+}
+
+func (q *queryService) Validate() error {
+	if q.spillRoot != "" && q.maxGroups <= 0 {
+		return errors.New("query-max-groups must be positive")
+	}
+	if q.maxMemory < 0 || q.maxQueryMemory < 0 {
+		return errors.New("query-max-memory and query-max-memory-per-query must not be negative")
+	}
+	if q.maxGroupQueries < 0 {
+		return errors.New("query-max-concurrency-per-group must not be negative")
+	}
+	return nil
+}
+
+func (q *queryService) groupSpill() executor.GroupSpill {
+	return executor.GroupSpill{Dir: q.spillRoot, MaxGroups: q.maxGroups}
+}
+
 func (q *queryService) PreRun(_ context.Context) error {
 	q.log = logger.GetLogger(moduleName)
+	q.memoryPool = executor.NewMemoryPool(int64(q.maxMemory))
+	q.limiter = newGroupLimiter(q.maxGroupQueries, q.groupQueueTimeout)
+	if q.spillRoot != "" {
+		// the spill files of the previous process are useless since their queries are gone
+		if err := spill.Clean(q.spillRoot); err != nil {
+			return err
+		}
+	}
 	return multierr.Combine(
 		q.pipeline.Subscribe(data.TopicStreamQuery, q.sqp),
+		// a standalone server exports the elements without spilling them
+		q.pipeline.Subscribe(data.TopicStreamExport, q.sqp),
 		q.pipeline.Subscribe(data.TopicMeasureQuery, q.mqp),
 		q.pipeline.Subscribe(data.TopicTopNQuery, q.tqp),
 	)
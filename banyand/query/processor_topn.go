@@ -69,8 +69,13 @@ func (t *topNQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 			Msg("fail to get execution context")
 		return
 	}
-	if topNSchema.GetFieldValueSort() != modelv1.Sort_SORT_UNSPECIFIED &&
-		topNSchema.GetFieldValueSort() != request.GetFieldValueSort() {
+	dimensionMetadata, fieldValueSort, err := logical_measure.TopNDimension(topNSchema, request.GetGroupByTagNames())
+	if err != nil {
+		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to pick the dimension of topn %s: %v", topNMetadata.GetName(), err))
+		return
+	}
+	if fieldValueSort != modelv1.Sort_SORT_UNSPECIFIED &&
+		fieldValueSort != request.GetFieldValueSort() {
 		t.log.Warn().Msg("unmatched sort direction")
 		return
 	}
@@ -82,7 +87,7 @@ func (t *topNQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 		return
 	}
 
-	schema, err := t.metaService.MeasureRegistry().GetMeasure(context.TODO(), topNMetadata)
+	schema, err := t.metaService.MeasureRegistry().GetMeasure(context.TODO(), dimensionMetadata)
 	if err != nil {
 		t.log.Error().Err(err).
 			Str("topN", topNMetadata.GetName()).
@@ -108,7 +113,8 @@ func (t *topNQueryProcessor) Rev(message bus.Message) (resp bus.Message) {
 		e.Str("plan", plan.String()).Msg("topn plan")
 	}
 
-	mIterator, err := plan.(executor.MeasureExecutable).Execute(executor.WithMeasureExecutionContext(context.Background(), sourceMeasure))
+	ctx := executor.WithGroupSpill(executor.WithMeasureExecutionContext(context.Background(), sourceMeasure), t.groupSpill())
+	mIterator, err := plan.(executor.MeasureExecutable).Execute(ctx)
 	if err != nil {
 		ml.Error().Err(err).RawJSON("req", logger.Proto(request)).Msg("fail to close the topn plan")
 		resp = bus.NewMessage(bus.MessageID(now), common.NewError("fail to execute the topn plan for measure %s: %v", topNMetadata.GetName(), err))
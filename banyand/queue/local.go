@@ -19,14 +19,19 @@
 package queue
 
 import (
+	"context"
+	"errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 	"github.com/apache/skywalking-banyandb/pkg/run"
 )
 
 var (
-	_ bus.Publisher  = (*local)(nil)
-	_ bus.Subscriber = (*local)(nil)
+	_ bus.Publisher   = (*local)(nil)
+	_ bus.Subscriber  = (*local)(nil)
+	_ CommitPublisher = (*local)(nil)
 )
 
 type local struct {
@@ -63,6 +68,27 @@ func (l *local) Publish(topic bus.Topic, message ...bus.Message) (bus.Future, er
 	return l.local.Publish(topic, message...)
 }
 
+// PublishCommit hands the messages to the listener of the topic as a batch, and waits until it returns.
+// The listener gets ctx from the batch.
+func (l *local) PublishCommit(ctx context.Context, topic bus.Topic, _ string, messages ...bus.Message) error {
+	batch := make([]any, len(messages))
+	for i := range messages {
+		batch[i] = messages[i].Data()
+	}
+	f, err := l.local.PublishSync(topic, bus.NewMessage(1, batch).WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	m, err := f.Get()
+	if err != nil {
+		return err
+	}
+	if e, ok := m.Data().(common.Error); ok {
+		return errors.New(e.Msg())
+	}
+	return nil
+}
+
 func (l *local) Broadcast(topic bus.Topic, message bus.Message) ([]bus.Future, error) {
 	f, err := l.Publish(topic, message)
 	if err != nil {
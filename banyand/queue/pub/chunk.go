@@ -0,0 +1,114 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+)
+
+func (p *pub) BroadcastChunked(ctx context.Context, topic bus.Topic, chunkSize uint32, message bus.Message) ([]queue.ChunkStream, error) {
+	names := p.Nodes()
+	streams := make([]queue.ChunkStream, 0, len(names))
+	for _, n := range names {
+		s, err := p.PublishChunked(ctx, topic, chunkSize, bus.NewMessageWithNode(message.ID(), n, message.Data()))
+		if err != nil {
+			for _, opened := range streams {
+				_ = opened.Close()
+			}
+			return nil, err
+		}
+		streams = append(streams, s)
+	}
+	return streams, nil
+}
+
+func (p *pub) PublishChunked(ctx context.Context, topic bus.Topic, chunkSize uint32, message bus.Message) (queue.ChunkStream, error) {
+	r, err := messageToRequest(topic, message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message %T: %w", message, err)
+	}
+	r.ChunkSize = chunkSize
+	node := message.Node()
+	p.mu.RLock()
+	client, ok := p.clients[node]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("failed to get client for node %s", node)
+	}
+	// cancelling the call stops the node sending the remaining chunks
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := client.client.Send(ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to get stream for node %s: %w", node, err)
+	}
+	if err = stream.Send(r); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to send message to node %s: %w", node, err)
+	}
+	if err = stream.CloseSend(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to close the stream to node %s: %w", node, err)
+	}
+	return &chunkStream{node: node, topic: topic, client: stream, cancel: cancel}, nil
+}
+
+type chunkStream struct {
+	client clusterv1.Service_SendClient
+	cancel func()
+	node   string
+	topic  bus.Topic
+	// done is set by Close which may run while Recv is blocking
+	done atomic.Bool
+}
+
+func (c *chunkStream) Node() string {
+	return c.node
+}
+
+func (c *chunkStream) Recv() (bus.Message, error) {
+	if c.done.Load() {
+		return bus.Message{}, io.EOF
+	}
+	resp, err := c.client.Recv()
+	if err != nil {
+		c.done.Store(true)
+		if errors.Is(err, io.EOF) {
+			return bus.Message{}, fmt.Errorf("node %s closed the stream before the last chunk: %w", c.node, io.ErrUnexpectedEOF)
+		}
+		return bus.Message{}, err
+	}
+	if !resp.HasMore {
+		c.done.Store(true)
+	}
+	return responseToMessage(c.topic, resp)
+}
+
+func (c *chunkStream) Close() error {
+	c.done.Store(true)
+	c.cancel()
+	return nil
+}
@@ -77,11 +77,18 @@ func (p *pub) OnAddOrUpdate(md schema.Metadata) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// If the client already exists, just return
+	// If the client already exists, only pass the update, such as the state change, to the handler
 	if _, ok := p.clients[name]; ok {
+		if p.handler != nil {
+			p.handler.OnAddOrUpdate(md)
+		}
 		return
 	}
-	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithDefaultServiceConfig(retryPolicy))
+	creds := p.creds
+	if creds == nil {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(creds), grpc.WithDefaultServiceConfig(retryPolicy))
 	if err != nil {
 		p.log.Error().Err(err).Msg("failed to connect to grpc server")
 		return
@@ -115,6 +122,9 @@ func (p *pub) OnDelete(md schema.Metadata) {
 			client.conn.Close() // Close the client connection
 		}
 		delete(p.clients, name)
+		p.statesMu.Lock()
+		delete(p.states, name)
+		p.statesMu.Unlock()
 		if p.handler != nil {
 			p.handler.OnDelete(md)
 		}
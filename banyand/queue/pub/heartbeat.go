@@ -0,0 +1,180 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pub
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/apache/skywalking-banyandb/api/data"
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+)
+
+// heartbeatMissingLimit is the number of missing heartbeats after which a node's state is regarded as unknown.
+const heartbeatMissingLimit = 3
+
+var _ queue.NodeStatus = (*pub)(nil)
+
+type nodeState struct {
+	seenAt     time.Time
+	watermarks map[shardKey]time.Time
+	groupSizes map[string]uint64
+	pressure   *clusterv1.WritePressure
+	latency    time.Duration
+}
+
+type shardKey struct {
+	group   string
+	shardID uint32
+}
+
+func (p *pub) Watermark(node, group string, shardID uint32) (time.Time, bool) {
+	st, ok := p.state(node)
+	if !ok {
+		return time.Time{}, false
+	}
+	ts, ok := st.watermarks[shardKey{group: group, shardID: shardID}]
+	return ts, ok
+}
+
+func (p *pub) Latency(node string) (time.Duration, bool) {
+	st, ok := p.state(node)
+	if !ok {
+		return 0, false
+	}
+	return st.latency, true
+}
+
+func (p *pub) Pressure(node string) (*clusterv1.WritePressure, bool) {
+	st, ok := p.state(node)
+	if !ok || st.pressure == nil {
+		return nil, false
+	}
+	return st.pressure, true
+}
+
+func (p *pub) GroupSize(group string) uint64 {
+	p.statesMu.RLock()
+	defer p.statesMu.RUnlock()
+	var size uint64
+	for _, st := range p.states {
+		if time.Since(st.seenAt) > heartbeatMissingLimit*p.heartbeatInterval {
+			continue
+		}
+		size += st.groupSizes[group]
+	}
+	return size
+}
+
+func (p *pub) state(node string) (nodeState, bool) {
+	p.statesMu.RLock()
+	defer p.statesMu.RUnlock()
+	st, ok := p.states[node]
+	if !ok || time.Since(st.seenAt) > heartbeatMissingLimit*p.heartbeatInterval {
+		return nodeState{}, false
+	}
+	return st, true
+}
+
+func (p *pub) startHeartbeat() {
+	if p.heartbeatInterval <= 0 || !p.closer.AddRunning() {
+		return
+	}
+	go func() {
+		defer p.closer.Done()
+		ticker := time.NewTicker(p.heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.closer.CloseNotify():
+				return
+			case <-ticker.C:
+				p.heartbeatAll()
+			}
+		}
+	}()
+}
+
+func (p *pub) heartbeatAll() {
+	p.mu.RLock()
+	clients := make(map[string]*client, len(p.clients))
+	for n, c := range p.clients {
+		clients[n] = c
+	}
+	p.mu.RUnlock()
+	for n, c := range clients {
+		st, err := p.heartbeat(c)
+		if err != nil {
+			p.log.Debug().Err(err).Str("node", n).Msg("failed to send heartbeat")
+			continue
+		}
+		p.statesMu.Lock()
+		p.states[n] = st
+		p.statesMu.Unlock()
+	}
+}
+
+func (p *pub) heartbeat(c *client) (nodeState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.heartbeatInterval)
+	defer cancel()
+	start := time.Now()
+	r, err := messageToRequest(data.TopicHeartbeat, bus.NewMessage(bus.MessageID(start.UnixNano()), &clusterv1.HeartbeatRequest{}))
+	if err != nil {
+		return nodeState{}, err
+	}
+	stream, err := c.client.Send(ctx)
+	if err != nil {
+		return nodeState{}, err
+	}
+	if err = stream.Send(r); err != nil {
+		return nodeState{}, err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return nodeState{}, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nodeState{}, err
+	}
+	if resp.Error != "" {
+		return nodeState{}, errors.New(resp.Error)
+	}
+	hr := &clusterv1.HeartbeatResponse{}
+	if resp.Body != nil {
+		if err = resp.Body.UnmarshalTo(hr); err != nil {
+			return nodeState{}, err
+		}
+	}
+	st := nodeState{
+		seenAt:     time.Now(),
+		latency:    time.Since(start),
+		watermarks: make(map[shardKey]time.Time, len(hr.Watermarks)),
+		groupSizes: make(map[string]uint64, len(hr.GroupUsages)),
+		pressure:   hr.Pressure,
+	}
+	for _, w := range hr.Watermarks {
+		st.watermarks[shardKey{group: w.Group, shardID: w.ShardId}] = w.AppliedAt.AsTime()
+	}
+	for _, u := range hr.GroupUsages {
+		st.groupSizes[u.Group] = u.SizeBytes
+	}
+	return st, nil
+}
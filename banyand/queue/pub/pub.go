@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"go.uber.org/multierr"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 
@@ -38,20 +39,39 @@ import (
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/run"
+	"github.com/apache/skywalking-banyandb/pkg/tlsconfig"
 )
 
 var (
 	_ run.PreRunner = (*pub)(nil)
 	_ run.Service   = (*pub)(nil)
+	_ run.Config    = (*pub)(nil)
+
+	_ queue.ChunkedPublisher = (*pub)(nil)
+	_ queue.NodePublisher    = (*pub)(nil)
+	_ queue.CommitPublisher  = (*pub)(nil)
 )
 
 type pub struct {
-	metadata metadata.Repo
-	handler  schema.EventHandler
-	log      *logger.Logger
-	clients  map[string]*client
-	closer   *run.Closer
-	mu       sync.RWMutex
+	metadata          metadata.Repo
+	handler           schema.EventHandler
+	log               *logger.Logger
+	clients           map[string]*client
+	closer            *run.Closer
+	states            map[string]nodeState
+	spool             *spool
+	creds             credentials.TransportCredentials
+	tlsReloader       *tlsconfig.Reloader
+	spoolRoot         string
+	caFile            string
+	certFile          string
+	keyFile           string
+	spoolMaxBytes     run.Bytes
+	spoolMaxAge       time.Duration
+	heartbeatInterval time.Duration
+	tls               bool
+	mu                sync.RWMutex
+	statesMu          sync.RWMutex
 }
 
 func (p *pub) Register(handler schema.EventHandler) {
@@ -61,6 +81,14 @@ func (p *pub) Register(handler schema.EventHandler) {
 func (p *pub) GracefulStop() {
 	p.closer.Done()
 	p.closer.CloseThenWait()
+	if p.tlsReloader != nil {
+		p.tlsReloader.Stop()
+	}
+	if p.spool != nil {
+		if err := p.spool.close(); err != nil {
+			p.log.Error().Err(err).Msg("failed to close the spool")
+		}
+	}
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	for _, c := range p.clients {
@@ -70,18 +98,17 @@ func (p *pub) GracefulStop() {
 
 // Serve implements run.Service.
 func (p *pub) Serve() run.StopNotify {
+	if p.tlsReloader != nil {
+		p.tlsReloader.Start(p.log)
+	}
+	p.startHeartbeat()
+	p.startDrainer()
 	return p.closer.CloseNotify()
 }
 
 func (p *pub) Broadcast(topic bus.Topic, messages bus.Message) ([]bus.Future, error) {
-	var names []string
-	p.mu.RLock()
-	for k := range p.clients {
-		names = append(names, k)
-	}
-	p.mu.RUnlock()
 	var futures []bus.Future
-	for _, n := range names {
+	for _, n := range p.Nodes() {
 		f, err := p.Publish(topic, bus.NewMessageWithNode(messages.ID(), n, messages.Data()))
 		if err != nil {
 			return nil, err
@@ -91,7 +118,23 @@ func (p *pub) Broadcast(topic bus.Topic, messages bus.Message) ([]bus.Future, er
 	return futures, nil
 }
 
+// Nodes returns the names of the connected nodes.
+func (p *pub) Nodes() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	names := make([]string, 0, len(p.clients))
+	for k := range p.clients {
+		names = append(names, k)
+	}
+	return names
+}
+
 func (p *pub) Publish(topic bus.Topic, messages ...bus.Message) (bus.Future, error) {
+	return p.PublishContext(context.Background(), topic, messages...)
+}
+
+// PublishContext sends the messages to the nodes set in them. Canceling ctx aborts the calls.
+func (p *pub) PublishContext(ctx context.Context, topic bus.Topic, messages ...bus.Message) (bus.Future, error) {
 	var err error
 	f := &future{}
 	handleMessage := func(m bus.Message, err error) error {
@@ -106,14 +149,18 @@ func (p *pub) Publish(topic bus.Topic, messages ...bus.Message) (bus.Future, err
 		if !ok {
 			return multierr.Append(err, fmt.Errorf("failed to get client for node %s", node))
 		}
-		stream, errCreateStream := client.client.Send(context.Background())
-		if err != nil {
+		stream, errCreateStream := client.client.Send(ctx)
+		if errCreateStream != nil {
 			return multierr.Append(err, fmt.Errorf("failed to get stream for node %s: %w", node, errCreateStream))
 		}
 		errSend = stream.Send(r)
 		if errSend != nil {
 			return multierr.Append(err, fmt.Errorf("failed to send message to node %s: %w", node, errSend))
 		}
+		// The stream carries only one message, close it to let the server end the call after responding.
+		if errSend = stream.CloseSend(); errSend != nil {
+			return multierr.Append(err, fmt.Errorf("failed to close the stream to node %s: %w", node, errSend))
+		}
 		f.clients = append(f.clients, stream)
 		f.topics = append(f.topics, topic)
 		return err
@@ -124,6 +171,55 @@ func (p *pub) Publish(topic bus.Topic, messages ...bus.Message) (bus.Future, err
 	return f, err
 }
 
+// PublishCommit sends the messages to the node in the batch mode. The node applies them once the stream is closed,
+// and ends the call after that, so the call is received to the end to learn the result.
+func (p *pub) PublishCommit(ctx context.Context, topic bus.Topic, node string, messages ...bus.Message) error {
+	p.mu.Lock()
+	client, ok := p.clients[node]
+	p.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("failed to get client for node %s", node)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.client.Send(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get stream for node %s: %w", node, err)
+	}
+	for _, m := range messages {
+		r, errMarshal := messageToRequest(topic, m)
+		if errMarshal != nil {
+			return fmt.Errorf("failed to marshal message %T: %w", m, errMarshal)
+		}
+		r.BatchMod = true
+		if err = stream.Send(r); err != nil {
+			return fmt.Errorf("failed to send message to node %s: %w", node, err)
+		}
+		resp, errRecv := stream.Recv()
+		if errRecv != nil {
+			return fmt.Errorf("failed to receive the response from node %s: %w", node, errRecv)
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("node %s rejects the message: %s", node, resp.Error)
+		}
+	}
+	if err = stream.CloseSend(); err != nil {
+		return fmt.Errorf("failed to close the stream to node %s: %w", node, err)
+	}
+	for {
+		resp, errRecv := stream.Recv()
+		if errors.Is(errRecv, io.EOF) {
+			return nil
+		}
+		if errRecv != nil {
+			return fmt.Errorf("failed to receive the response from node %s: %w", node, errRecv)
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("node %s fails to apply the batch: %s", node, resp.Error)
+		}
+	}
+}
+
 // NewBatchPublisher returns a new batch publisher.
 func (p *pub) NewBatchPublisher() queue.BatchPublisher {
 	return &batchPublisher{pub: p, streams: make(map[string]writeStream)}
@@ -134,16 +230,56 @@ func New(metadata metadata.Repo) queue.Client {
 	return &pub{
 		metadata: metadata,
 		clients:  make(map[string]*client),
+		states:   make(map[string]nodeState),
 		closer:   run.NewCloser(1),
 	}
 }
 
+func (p *pub) FlagSet() *run.FlagSet {
+	fs := run.NewFlagSet("queue-client")
+	fs.DurationVar(&p.heartbeatInterval, "heartbeat-interval", 5*time.Second, "the interval of heartbeats to data nodes")
+	fs.StringVar(&p.spoolRoot, "spool-root", "", "the directory to spool messages for unreachable data nodes, spooling is disabled if it's empty")
+	p.spoolMaxBytes = 1 << 30
+	fs.VarP(&p.spoolMaxBytes, "spool-max-bytes", "", "the max bytes of spooled messages for a data node")
+	fs.DurationVar(&p.spoolMaxAge, "spool-max-age", time.Hour, "the max age of spooled messages, older ones are dropped instead of replaying")
+	fs.BoolVar(&p.tls, "data-tls", false, "connect to the data nodes with TLS if true, else plain TCP")
+	fs.StringVar(&p.caFile, "data-ca-file", "", "the CA file verifying the certificates of the data nodes, the system roots are used if it's empty")
+	fs.StringVar(&p.certFile, "data-cert-file", "", "the client cert file presented to the data nodes if they require the client certificates")
+	fs.StringVar(&p.keyFile, "data-key-file", "", "the key file of data-cert-file")
+	return fs
+}
+
+func (p *pub) Validate() error {
+	if p.heartbeatInterval <= 0 {
+		return errors.New("heartbeat-interval must be positive")
+	}
+	if p.spoolRoot != "" && p.spoolMaxBytes <= 0 {
+		return errors.New("spool-max-bytes must be positive")
+	}
+	if !p.tls {
+		return nil
+	}
+	reloader, err := tlsconfig.NewReloader(p.certFile, p.keyFile, p.caFile)
+	if err != nil {
+		return err
+	}
+	p.tlsReloader = reloader
+	p.creds = credentials.NewTLS(reloader.ClientConfig())
+	return nil
+}
+
 func (*pub) Name() string {
 	return "queue-client"
 }
 
 func (p *pub) PreRun(context.Context) error {
 	p.log = logger.GetLogger("server-queue")
+	if p.spoolRoot != "" {
+		var err error
+		if p.spool, err = openSpool(p.spoolRoot, int64(p.spoolMaxBytes), p.spoolMaxAge, p.log); err != nil {
+			return err
+		}
+	}
 	p.metadata.RegisterHandler("queue-client", schema.KindNode, p)
 	return nil
 }
@@ -167,62 +303,77 @@ func (bp *batchPublisher) Close() (err error) {
 }
 
 func (bp *batchPublisher) Publish(topic bus.Topic, messages ...bus.Message) (bus.Future, error) {
+	var err error
 	for _, m := range messages {
-		r, err := messageToRequest(topic, m)
-		if err != nil {
-			err = multierr.Append(err, fmt.Errorf("failed to marshal message %T: %w", m, err))
+		r, errMarshal := messageToRequest(topic, m)
+		if errMarshal != nil {
+			err = multierr.Append(err, fmt.Errorf("failed to marshal message %T: %w", m, errMarshal))
 			continue
 		}
 		node := m.Node()
-		sendData := func() (success bool) {
-			if stream, ok := bp.streams[node]; ok {
-				defer func() {
-					if !success {
-						delete(bp.streams, node)
-						stream.cancel()
-					}
-				}()
-				select {
-				case <-stream.client.Context().Done():
-					return false
-				default:
-				}
-				errSend := stream.client.Send(r)
-				if errSend != nil {
-					err = multierr.Append(err, fmt.Errorf("failed to send message to node %s: %w", node, errSend))
-					return false
-				}
-				_, errRecv := stream.client.Recv()
-				return errRecv == nil
-			}
-			return false
-		}
-		if sendData() {
+		// keep the order of messages while the spooled ones are replaying
+		if !bp.pub.spool.spooling(node) && bp.send(node, r) {
 			continue
 		}
+		if errSpool := bp.pub.spool.append(node, r); errSpool != nil {
+			err = multierr.Append(err, fmt.Errorf("failed to send message to node %s: %w", node, errSpool))
+		}
+	}
+	return nil, err
+}
 
-		bp.pub.mu.Lock()
-		client, ok := bp.pub.clients[node]
-		bp.pub.mu.Unlock()
+func (bp *batchPublisher) send(node string, r *clusterv1.SendRequest) bool {
+	sendData := func() (success bool) {
+		stream, ok := bp.streams[node]
 		if !ok {
-			err = multierr.Append(err, fmt.Errorf("failed to get client for node %s", node))
-			continue
+			return false
 		}
-		//nolint: govet
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		stream, errCreateStream := client.client.Send(ctx)
-		if err != nil {
-			err = multierr.Append(err, fmt.Errorf("failed to get stream for node %s: %w", node, errCreateStream))
-			continue
+		defer func() {
+			if !success {
+				delete(bp.streams, node)
+				stream.cancel()
+			}
+		}()
+		select {
+		case <-stream.client.Context().Done():
+			return false
+		default:
+		}
+		if errSend := stream.client.Send(r); errSend != nil {
+			bp.pub.log.Debug().Err(errSend).Str("node", node).Msg("failed to send message")
+			return false
+		}
+		resp, errRecv := stream.client.Recv()
+		if errRecv != nil {
+			return false
 		}
-		bp.streams[node] = writeStream{
-			client: stream,
-			cancel: cancel,
+		if resp.Error != "" {
+			bp.pub.log.Debug().Str("node", node).Str("error", resp.Error).Msg("the node fails to apply the message")
+			return false
 		}
-		_ = sendData()
+		return true
+	}
+	if sendData() {
+		return true
+	}
+
+	bp.pub.mu.Lock()
+	client, ok := bp.pub.clients[node]
+	bp.pub.mu.Unlock()
+	if !ok {
+		return false
 	}
-	//nolint: govet
-	return nil, nil
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	stream, errCreateStream := client.client.Send(ctx)
+	if errCreateStream != nil {
+		cancel()
+		return false
+	}
+	bp.streams[node] = writeStream{
+		client: stream,
+		cancel: cancel,
+	}
+	return sendData()
 }
 
 func messageToRequest(topic bus.Topic, m bus.Message) (*clusterv1.SendRequest, error) {
@@ -262,6 +413,10 @@ func (l *future) Get() (bus.Message, error) {
 	if err != nil {
 		return bus.Message{}, err
 	}
+	return responseToMessage(t, resp)
+}
+
+func responseToMessage(t bus.Topic, resp *clusterv1.SendResponse) (bus.Message, error) {
 	if resp.Error != "" {
 		return bus.Message{}, errors.New(resp.Error)
 	}
@@ -270,8 +425,7 @@ func (l *future) Get() (bus.Message, error) {
 	}
 	if messageSupplier, ok := data.TopicResponseMap[t]; ok {
 		m := messageSupplier()
-		err = resp.Body.UnmarshalTo(m)
-		if err != nil {
+		if err := resp.Body.UnmarshalTo(m); err != nil {
 			return bus.Message{}, err
 		}
 		return bus.NewMessage(
@@ -0,0 +1,514 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pub
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"google.golang.org/protobuf/proto"
+
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+const (
+	spoolFileSuffix  = ".spool"
+	offsetFileSuffix = ".offset"
+	// recordHeaderSize is the size of the header of a record: the length, the appended time and the CRC32 of the body.
+	recordHeaderSize  = 16
+	maxDrainChunkSize = 4 << 20
+	drainTimeout      = 30 * time.Second
+
+	dropReasonBytes     = "bytes"
+	dropReasonAge       = "age"
+	dropReasonCorrupted = "corrupted"
+)
+
+var (
+	errSpoolDisabled = errors.New("spooling is disabled")
+	errSpoolFull     = errors.New("spool is full")
+	errSpoolCorrupt  = errors.New("spool is corrupted")
+
+	spoolProvider      = observability.NewMeterProvider(observability.RootScope.SubScope("queue").SubScope("spool"))
+	spoolBytesGauge    = spoolProvider.Gauge("bytes", "node")
+	spoolDropCounter   = spoolProvider.Counter("dropped_total", "node", "reason")
+	spoolDrainLagGauge = spoolProvider.Gauge("drain_lag_seconds", "node")
+)
+
+// spool persists the messages for unreachable nodes, and replays them once the nodes recover.
+type spool struct {
+	l        *logger.Logger
+	targets  map[string]*targetSpool
+	root     string
+	maxBytes int64
+	maxAge   time.Duration
+	mu       sync.Mutex
+}
+
+// targetSpool is the spill file of a node.
+// Messages in [offset, size) are waiting for replaying.
+type targetSpool struct {
+	file   *os.File
+	node   string
+	path   string
+	size   int64
+	offset int64
+	mu     sync.Mutex
+}
+
+type spoolRecord struct {
+	req        *clusterv1.SendRequest
+	appendedAt time.Time
+	end        int64
+}
+
+func openSpool(root string, maxBytes int64, maxAge time.Duration, l *logger.Logger) (*spool, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create the spool root %s", root)
+	}
+	s := &spool{
+		l:        l,
+		root:     root,
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+		targets:  make(map[string]*targetSpool),
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the spool root %s", root)
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), spoolFileSuffix) {
+			continue
+		}
+		node, errName := url.PathUnescape(strings.TrimSuffix(e.Name(), spoolFileSuffix))
+		if errName != nil {
+			l.Warn().Str("file", e.Name()).Msg("skip a spool file with an invalid name")
+			continue
+		}
+		ts, errOpen := s.openTarget(node)
+		if errOpen != nil {
+			return nil, errOpen
+		}
+		l.Info().Str("node", node).Int64("bytes", ts.size-ts.offset).Msg("loaded a spool")
+	}
+	return s, nil
+}
+
+func (s *spool) openTarget(node string) (*targetSpool, error) {
+	path := filepath.Join(s.root, url.PathEscape(node)+spoolFileSuffix)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open the spool of %s", node)
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrapf(err, "failed to stat the spool of %s", node)
+	}
+	ts := &targetSpool{
+		file: f,
+		node: node,
+		path: path,
+		size: stat.Size(),
+	}
+	if ts.offset, err = readOffset(path + offsetFileSuffix); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if ts.offset > ts.size {
+		ts.offset = ts.size
+	}
+	torn, err := ts.truncateTorn()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if torn > 0 {
+		s.l.Warn().Str("node", node).Int64("bytes", torn).Msg("dropped the torn records at the end of the spool")
+		spoolDropCounter.Inc(1, node, dropReasonCorrupted)
+	}
+	s.targets[node] = ts
+	spoolBytesGauge.Set(float64(ts.size-ts.offset), node)
+	return ts, nil
+}
+
+func (s *spool) target(node string) (*targetSpool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ts, ok := s.targets[node]; ok {
+		return ts, nil
+	}
+	return s.openTarget(node)
+}
+
+// spooling returns true if some messages of the node are waiting for replaying.
+// New messages should be spooled too to keep the order.
+func (s *spool) spooling(node string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	ts, ok := s.targets[node]
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.size > ts.offset
+}
+
+func (s *spool) append(node string, req *clusterv1.SendRequest) error {
+	if s == nil {
+		return errSpoolDisabled
+	}
+	ts, err := s.target(node)
+	if err != nil {
+		return err
+	}
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	n := int64(recordHeaderSize + len(body))
+	if ts.size-ts.offset+n > s.maxBytes {
+		spoolDropCounter.Inc(1, node, dropReasonBytes)
+		return errors.WithMessagef(errSpoolFull, "node %s", node)
+	}
+	buf := make([]byte, n)
+	binary.LittleEndian.PutUint32(buf, uint32(len(body)))
+	binary.LittleEndian.PutUint64(buf[4:], uint64(time.Now().UnixNano()))
+	binary.LittleEndian.PutUint32(buf[12:], crc32.ChecksumIEEE(body))
+	copy(buf[recordHeaderSize:], body)
+	if _, err = ts.file.Write(buf); err != nil {
+		// drop the partially written record, which would misalign the ones behind it
+		return multierr.Append(errors.Wrapf(err, "failed to spool a message for %s", node), ts.file.Truncate(ts.size))
+	}
+	ts.size += n
+	spoolBytesGauge.Set(float64(ts.size-ts.offset), node)
+	return nil
+}
+
+func (s *spool) pendingNodes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var nodes []string
+	for n, ts := range s.targets {
+		ts.mu.Lock()
+		if ts.size > ts.offset {
+			nodes = append(nodes, n)
+		}
+		ts.mu.Unlock()
+	}
+	return nodes
+}
+
+func (p *pub) startDrainer() {
+	if p.spool == nil || !p.closer.AddRunning() {
+		return
+	}
+	go func() {
+		defer p.closer.Done()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.closer.CloseNotify():
+				return
+			case <-ticker.C:
+				for _, n := range p.spool.pendingNodes() {
+					p.mu.RLock()
+					c, ok := p.clients[n]
+					p.mu.RUnlock()
+					if !ok {
+						continue
+					}
+					if err := p.spool.drain(n, c); err != nil {
+						p.log.Warn().Err(err).Str("node", n).Msg("failed to replay spooled messages")
+					}
+				}
+			}
+		}
+	}()
+}
+
+// drain replays a chunk of the node's spooled messages in order.
+// The offset is checkpointed after the node applies them,
+// so the messages might be replayed again if the liaison crashes before that.
+// Nodes drop such duplicates by the message ids.
+func (s *spool) drain(node string, c *client) error {
+	ts, err := s.target(node)
+	if err != nil {
+		return err
+	}
+	ts.mu.Lock()
+	start, size := ts.offset, ts.size
+	ts.mu.Unlock()
+	if start >= size {
+		return nil
+	}
+	records, err := ts.read(start, size)
+	// next is the offset the drain resumes from once the records are applied
+	next := start
+	if len(records) > 0 {
+		next = records[len(records)-1].end
+	}
+	if errors.Is(err, errSpoolCorrupt) {
+		// the messages behind the corrupted record up to the size read can't be located, skip them to deliver the others.
+		// The ones appended after the size are kept, which start at the boundary of a record.
+		s.l.Error().Err(err).Str("node", node).Int64("bytes", size-next).Msg("dropped the corrupted records of the spool")
+		spoolDropCounter.Inc(1, node, dropReasonCorrupted)
+		next = size
+	} else if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return ts.ack(next)
+	}
+	spoolDrainLagGauge.Set(time.Since(records[0].appendedAt).Seconds(), node)
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	var stream clusterv1.Service_SendClient
+	var topic string
+	finish := func() error {
+		if stream == nil {
+			return nil
+		}
+		st := stream
+		stream = nil
+		if errClose := st.CloseSend(); errClose != nil {
+			return errClose
+		}
+		// the node applies the batch at the end of the stream
+		for {
+			if _, errRecv := st.Recv(); errRecv != nil {
+				if errors.Is(errRecv, io.EOF) {
+					return nil
+				}
+				return errRecv
+			}
+		}
+	}
+	for _, r := range records {
+		if s.maxAge > 0 && time.Since(r.appendedAt) > s.maxAge {
+			spoolDropCounter.Inc(1, node, dropReasonAge)
+			continue
+		}
+		if stream != nil && r.req.Topic != topic {
+			if err = finish(); err != nil {
+				return err
+			}
+		}
+		if stream == nil {
+			if stream, err = c.client.Send(ctx); err != nil {
+				return err
+			}
+			topic = r.req.Topic
+		}
+		r.req.Replay = true
+		if err = stream.Send(r.req); err != nil {
+			return err
+		}
+		if _, err = stream.Recv(); err != nil {
+			return err
+		}
+	}
+	if err = finish(); err != nil {
+		return err
+	}
+	return ts.ack(next)
+}
+
+func (ts *targetSpool) read(start, end int64) ([]spoolRecord, error) {
+	f, err := os.Open(ts.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err = f.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(io.LimitReader(f, end-start))
+	header := make([]byte, recordHeaderSize)
+	var records []spoolRecord
+	pos := start
+	for pos < end && pos-start < maxDrainChunkSize {
+		if _, err = io.ReadFull(reader, header); err != nil {
+			return records, readErr(err, ts.node, pos)
+		}
+		// the length isn't covered by the checksum, it's bounded by the end before allocating the body
+		n := int64(binary.LittleEndian.Uint32(header))
+		if pos+recordHeaderSize+n > end {
+			return records, errors.WithMessagef(errSpoolCorrupt, "node %s at %d: the length %d exceeds the end %d", ts.node, pos, n, end)
+		}
+		body := make([]byte, n)
+		if _, err = io.ReadFull(reader, body); err != nil {
+			return records, readErr(err, ts.node, pos)
+		}
+		req := &clusterv1.SendRequest{}
+		if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(header[12:]) {
+			return records, errors.WithMessagef(errSpoolCorrupt, "node %s at %d", ts.node, pos)
+		}
+		if err = proto.Unmarshal(body, req); err != nil {
+			return records, errors.WithMessagef(errSpoolCorrupt, "node %s at %d: %v", ts.node, pos, err)
+		}
+		pos += int64(recordHeaderSize + len(body))
+		records = append(records, spoolRecord{
+			req:        req,
+			appendedAt: time.Unix(0, int64(binary.LittleEndian.Uint64(header[4:]))),
+			end:        pos,
+		})
+	}
+	return records, nil
+}
+
+// readErr returns errSpoolCorrupt if the spill file ends before the complete records do,
+// since they'd never be read by retrying.
+func readErr(err error, node string, pos int64) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errors.WithMessagef(errSpoolCorrupt, "node %s at %d: %v", node, pos, err)
+	}
+	return errors.Wrapf(err, "failed to read the spool of %s at %d", node, pos)
+}
+
+// truncateTorn scans the records from the offset, and truncates the spill file to the end of the last complete one.
+// The record torn by a crash in the middle of appending it is dropped, which would fail the replaying forever.
+// It returns the number of the dropped bytes.
+func (ts *targetSpool) truncateTorn() (int64, error) {
+	if ts.offset >= ts.size {
+		return 0, nil
+	}
+	f, err := os.Open(ts.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err = f.Seek(ts.offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReader(f)
+	header := make([]byte, recordHeaderSize)
+	pos := ts.offset
+	for pos < ts.size {
+		if _, err = io.ReadFull(reader, header); err != nil {
+			break
+		}
+		n := int64(binary.LittleEndian.Uint32(header))
+		if pos+recordHeaderSize+n > ts.size {
+			break
+		}
+		body := make([]byte, n)
+		if _, err = io.ReadFull(reader, body); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(header[12:]) {
+			break
+		}
+		pos += recordHeaderSize + n
+	}
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, errors.Wrapf(err, "failed to read the spool of %s at %d", ts.node, pos)
+	}
+	torn := ts.size - pos
+	if torn == 0 {
+		return 0, nil
+	}
+	return torn, ts.truncate(pos)
+}
+
+// truncate drops the records from the size on.
+func (ts *targetSpool) truncate(size int64) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if err := ts.file.Truncate(size); err != nil {
+		return errors.Wrapf(err, "failed to truncate the spool of %s", ts.node)
+	}
+	ts.size = size
+	spoolBytesGauge.Set(float64(ts.size-ts.offset), ts.node)
+	return nil
+}
+
+// ack moves the offset forward, and truncates the spill file once it's drained.
+func (ts *targetSpool) ack(offset int64) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.offset = offset
+	spoolBytesGauge.Set(float64(ts.size-ts.offset), ts.node)
+	if ts.offset < ts.size {
+		return writeOffset(ts.path+offsetFileSuffix, ts.offset)
+	}
+	spoolDrainLagGauge.Set(0, ts.node)
+	if err := ts.file.Truncate(0); err != nil {
+		return err
+	}
+	ts.size, ts.offset = 0, 0
+	return writeOffset(ts.path+offsetFileSuffix, 0)
+}
+
+func (s *spool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for _, ts := range s.targets {
+		if errClose := ts.file.Close(); errClose != nil {
+			err = errClose
+		}
+	}
+	return err
+}
+
+func readOffset(path string) (int64, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to read the spool offset %s", path)
+	}
+	if len(b) != 8 {
+		return 0, fmt.Errorf("invalid spool offset %s", path)
+	}
+	return int64(binary.LittleEndian.Uint64(b)), nil
+}
+
+func writeOffset(path string, offset int64) error {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(offset))
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,163 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pub
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+func TestSpool(t *testing.T) {
+	root := t.TempDir()
+	l := logger.GetLogger("test")
+	s, err := openSpool(root, 1<<20, time.Hour, l)
+	require.NoError(t, err)
+	const node = "data-node-0:17912"
+	assert.False(t, s.spooling(node))
+	for i := uint64(1); i <= 3; i++ {
+		require.NoError(t, s.append(node, &clusterv1.SendRequest{Topic: "stream-write", MessageId: i, BatchMod: true}))
+	}
+	assert.True(t, s.spooling(node))
+	assert.Equal(t, []string{node}, s.pendingNodes())
+
+	ts, err := s.target(node)
+	require.NoError(t, err)
+	records, err := ts.read(ts.offset, ts.size)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	for i, r := range records {
+		assert.Equal(t, uint64(i+1), r.req.MessageId)
+	}
+	require.NoError(t, ts.ack(records[0].end))
+	require.NoError(t, s.close())
+
+	// the acked message isn't replayed after a restart
+	s, err = openSpool(root, 1<<20, time.Hour, l)
+	require.NoError(t, err)
+	defer s.close()
+	assert.True(t, s.spooling(node))
+	ts, err = s.target(node)
+	require.NoError(t, err)
+	records, err = ts.read(ts.offset, ts.size)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, uint64(2), records[0].req.MessageId)
+
+	require.NoError(t, ts.ack(records[1].end))
+	assert.False(t, s.spooling(node))
+	assert.Equal(t, int64(0), ts.size)
+}
+
+func TestSpoolFull(t *testing.T) {
+	s, err := openSpool(t.TempDir(), 64, time.Hour, logger.GetLogger("test"))
+	require.NoError(t, err)
+	defer s.close()
+	req := &clusterv1.SendRequest{Topic: "stream-write", MessageId: 1, BatchMod: true}
+	require.NoError(t, s.append("data-node-0", req))
+	for err == nil {
+		err = s.append("data-node-0", req)
+	}
+	assert.True(t, errors.Is(err, errSpoolFull))
+
+	var disabled *spool
+	assert.False(t, disabled.spooling("data-node-0"))
+	assert.True(t, errors.Is(disabled.append("data-node-0", req), errSpoolDisabled))
+}
+
+func TestSpoolTornRecord(t *testing.T) {
+	root := t.TempDir()
+	l := logger.GetLogger("test")
+	s, err := openSpool(root, 1<<20, time.Hour, l)
+	require.NoError(t, err)
+	const node = "data-node-0"
+	for i := uint64(1); i <= 3; i++ {
+		require.NoError(t, s.append(node, &clusterv1.SendRequest{Topic: "stream-write", MessageId: i, BatchMod: true}))
+	}
+	ts, err := s.target(node)
+	require.NoError(t, err)
+	records, err := ts.read(ts.offset, ts.size)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.NoError(t, s.close())
+
+	// the liaison crashes in the middle of appending the third record
+	require.NoError(t, os.Truncate(ts.path, records[2].end-3))
+	s, err = openSpool(root, 1<<20, time.Hour, l)
+	require.NoError(t, err)
+	defer s.close()
+	ts, err = s.target(node)
+	require.NoError(t, err)
+	assert.Equal(t, records[1].end, ts.size, "the torn record is dropped")
+	require.NoError(t, s.append(node, &clusterv1.SendRequest{Topic: "stream-write", MessageId: 4, BatchMod: true}))
+	replayed, err := ts.read(ts.offset, ts.size)
+	require.NoError(t, err)
+	require.Len(t, replayed, 3)
+	for i, id := range []uint64{1, 2, 4} {
+		assert.Equal(t, id, replayed[i].req.MessageId)
+	}
+
+	// a corrupted record stops the reading, the ones before it are still replayed
+	f, err := os.OpenFile(ts.path, os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, replayed[1].end-1)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	replayed, err = ts.read(ts.offset, ts.size)
+	assert.True(t, errors.Is(err, errSpoolCorrupt))
+	require.Len(t, replayed, 1)
+	assert.Equal(t, uint64(1), replayed[0].req.MessageId)
+}
+
+func TestSpoolCorruptedLength(t *testing.T) {
+	s, err := openSpool(t.TempDir(), 1<<20, time.Hour, logger.GetLogger("test"))
+	require.NoError(t, err)
+	defer s.close()
+	const node = "data-node-0"
+	for i := uint64(1); i <= 2; i++ {
+		require.NoError(t, s.append(node, &clusterv1.SendRequest{Topic: "stream-write", MessageId: i, BatchMod: true}))
+	}
+	ts, err := s.target(node)
+	require.NoError(t, err)
+
+	// the length of the first record is corrupted, which exceeds the records spooled
+	f, err := os.OpenFile(ts.path, os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff, 0xff, 0xff, 0x7f}, 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	records, err := ts.read(ts.offset, ts.size)
+	assert.True(t, errors.Is(err, errSpoolCorrupt))
+	assert.Empty(t, records)
+
+	// the records up to the size read are skipped, the ones appended later are kept
+	require.NoError(t, s.drain(node, nil))
+	assert.Equal(t, int64(0), ts.size, "the drained spool is truncated")
+	require.NoError(t, s.append(node, &clusterv1.SendRequest{Topic: "stream-write", MessageId: 3, BatchMod: true}))
+	records, err = ts.read(ts.offset, ts.size)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, uint64(3), records[0].req.MessageId)
+}
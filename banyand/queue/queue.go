@@ -18,8 +18,11 @@
 package queue
 
 import (
+	"context"
 	"io"
+	"time"
 
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
 	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 	"github.com/apache/skywalking-banyandb/pkg/run"
@@ -52,6 +55,62 @@ type Server interface {
 	GetPort() *uint32
 }
 
+// WriteGate allows a server to stop accepting writes while it keeps serving queries.
+type WriteGate interface {
+	// RejectWrites makes the server reply errors to the writes if reject is true.
+	RejectWrites(reject bool)
+}
+
+// NodeStatus reports the state of data nodes learned from heartbeats.
+type NodeStatus interface {
+	// Watermark returns the latest data timestamp a node has applied to a shard.
+	Watermark(node, group string, shardID uint32) (time.Time, bool)
+	// Latency returns the round-trip time of the last heartbeat to a node.
+	Latency(node string) (time.Duration, bool)
+	// Pressure returns how far a node lags behind the writes.
+	Pressure(node string) (*clusterv1.WritePressure, bool)
+	// GroupSize returns the size of the data of a group summed up over the nodes reporting it, replicas included.
+	GroupSize(group string) uint64
+}
+
+// ReplicaLag reports the replicas missing the writes they failed to apply, which aren't caught up yet.
+type ReplicaLag interface {
+	// Missing returns true if the replica of a shard on the node misses some writes in the time range.
+	Missing(node, group string, shardID uint32, begin, end time.Time) bool
+}
+
+// ChunkStream yields the chunks of a node's response in order.
+type ChunkStream interface {
+	// Node returns the node which sends the chunks.
+	Node() string
+	// Recv returns the next chunk, or io.EOF after the last one.
+	Recv() (bus.Message, error)
+	// Close stops receiving chunks. The node stops sending the remaining ones.
+	Close() error
+}
+
+// ChunkedPublisher sends messages whose responses are split into chunks.
+type ChunkedPublisher interface {
+	// PublishChunked sends the message to the node set in the message.
+	PublishChunked(ctx context.Context, topic bus.Topic, chunkSize uint32, message bus.Message) (ChunkStream, error)
+	// BroadcastChunked sends the message to all nodes.
+	BroadcastChunked(ctx context.Context, topic bus.Topic, chunkSize uint32, message bus.Message) ([]ChunkStream, error)
+}
+
+// NodePublisher sends messages to nodes one by one, which lets a caller pick and abort the calls.
+type NodePublisher interface {
+	// Nodes returns the names of the nodes which messages can be sent to.
+	Nodes() []string
+	// PublishContext sends the messages to the nodes set in them. Canceling ctx aborts the calls.
+	PublishContext(ctx context.Context, topic bus.Topic, messages ...bus.Message) (bus.Future, error)
+}
+
+// CommitPublisher sends a batch of writes and waits until they're applied.
+type CommitPublisher interface {
+	// PublishCommit sends the messages to the node in a batch, and returns once the node applies them all.
+	PublishCommit(ctx context.Context, topic bus.Topic, node string, messages ...bus.Message) error
+}
+
 // BatchPublisher is the interface for publishing data in batch.
 type BatchPublisher interface {
 	bus.Publisher
@@ -0,0 +1,76 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sub
+
+import (
+	"sync"
+	"time"
+)
+
+type replayEntry struct {
+	at time.Time
+	id uint64
+}
+
+// replayDedup remembers the ids of replayed messages applied within a window.
+// A liaison might replay the spooled messages again if it crashes before checkpointing.
+type replayDedup struct {
+	applied map[uint64]struct{}
+	entries []replayEntry
+	window  time.Duration
+	mu      sync.Mutex
+}
+
+func newReplayDedup() *replayDedup {
+	return &replayDedup{applied: make(map[uint64]struct{})}
+}
+
+func (d *replayDedup) isApplied(id uint64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire(time.Now())
+	_, ok := d.applied[id]
+	return ok
+}
+
+func (d *replayDedup) record(ids ...uint64) {
+	if len(ids) < 1 {
+		return
+	}
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire(now)
+	for _, id := range ids {
+		if _, ok := d.applied[id]; ok {
+			continue
+		}
+		d.applied[id] = struct{}{}
+		d.entries = append(d.entries, replayEntry{id: id, at: now})
+	}
+}
+
+func (d *replayDedup) expire(now time.Time) {
+	i := 0
+	for ; i < len(d.entries) && now.Sub(d.entries[i].at) > d.window; i++ {
+		delete(d.applied, d.entries[i].id)
+	}
+	if i > 0 {
+		d.entries = append(d.entries[:0], d.entries[i:]...)
+	}
+}
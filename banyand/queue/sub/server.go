@@ -23,6 +23,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
@@ -35,6 +36,7 @@ import (
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 
+	"github.com/apache/skywalking-banyandb/api/data"
 	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	"github.com/apache/skywalking-banyandb/banyand/observability"
@@ -42,6 +44,7 @@ import (
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/run"
+	"github.com/apache/skywalking-banyandb/pkg/tlsconfig"
 )
 
 const defaultRecvSize = 10 << 20
@@ -51,33 +54,46 @@ var (
 	errServerKey  = errors.New("invalid server key file")
 	errNoAddr     = errors.New("no address")
 
-	_ run.PreRunner = (*server)(nil)
-	_ run.Service   = (*server)(nil)
+	_ run.PreRunner   = (*server)(nil)
+	_ run.Service     = (*server)(nil)
+	_ queue.WriteGate = (*server)(nil)
 )
 
 type server struct {
-	creds     credentials.TransportCredentials
-	log       *logger.Logger
-	ser       *grpclib.Server
-	listeners map[bus.Topic]bus.MessageListener
+	creds       credentials.TransportCredentials
+	log         *logger.Logger
+	ser         *grpclib.Server
+	listeners   map[bus.Topic]bus.MessageListener
+	watermarks  *watermarks
+	dedup       *replayDedup
+	tlsReloader *tlsconfig.Reloader
 	*clusterv1.UnimplementedServiceServer
 	addr           string
 	certFile       string
 	keyFile        string
+	clientCAFile   string
 	host           string
 	maxRecvMsgSize run.Bytes
 	listenersLock  sync.RWMutex
 	port           uint32
 	tls            bool
+	rejectWrites   atomic.Bool
 }
 
 // NewServer returns a new gRPC server.
 func NewServer() queue.Server {
+	w := newWatermarks()
 	return &server{
-		listeners: make(map[bus.Topic]bus.MessageListener),
+		listeners:  map[bus.Topic]bus.MessageListener{data.TopicHeartbeat: w},
+		watermarks: w,
+		dedup:      newReplayDedup(),
 	}
 }
 
+func (s *server) RejectWrites(reject bool) {
+	s.rejectWrites.Store(reject)
+}
+
 func (s *server) PreRun(_ context.Context) error {
 	s.log = logger.GetLogger("server-queue")
 	return nil
@@ -102,8 +118,12 @@ func (s *server) FlagSet() *run.FlagSet {
 	fs.BoolVar(&s.tls, "tls", false, "connection uses TLS if true, else plain TCP")
 	fs.StringVar(&s.certFile, "cert-file", "", "the TLS cert file")
 	fs.StringVar(&s.keyFile, "key-file", "", "the TLS key file")
+	fs.StringVar(&s.clientCAFile, "client-ca-file", "",
+		"the CA file verifying the client certificates, which requires the liaisons to present them if it's set. "+
+			"The TLS files are reloaded once they change or SIGHUP is received")
 	fs.StringVar(&s.host, "grpc-host", "", "the host of banyand listens")
 	fs.Uint32Var(&s.port, "grpc-port", 17912, "the port of banyand listens")
+	fs.DurationVar(&s.dedup.window, "replay-dedup-window", 30*time.Minute, "the window to drop the replayed messages applied before")
 	return fs
 }
 
@@ -122,11 +142,12 @@ func (s *server) Validate() error {
 	if s.keyFile == "" {
 		return errServerKey
 	}
-	creds, errTLS := credentials.NewServerTLSFromFile(s.certFile, s.keyFile)
+	reloader, errTLS := tlsconfig.NewReloader(s.certFile, s.keyFile, s.clientCAFile)
 	if errTLS != nil {
-		return errors.Wrap(errTLS, "failed to load cert and key")
+		return errTLS
 	}
-	s.creds = creds
+	s.tlsReloader = reloader
+	s.creds = credentials.NewTLS(reloader.ServerConfig())
 	return nil
 }
 
@@ -134,6 +155,7 @@ func (s *server) Serve() run.StopNotify {
 	var opts []grpclib.ServerOption
 	if s.tls {
 		opts = []grpclib.ServerOption{grpclib.Creds(s.creds)}
+		s.tlsReloader.Start(s.log)
 	}
 	grpcPanicRecoveryHandler := func(p any) (err error) {
 		s.log.Error().Interface("panic", p).Str("stack", string(debug.Stack())).Msg("recovered from panic")
@@ -184,6 +206,9 @@ func (s *server) Serve() run.StopNotify {
 
 func (s *server) GracefulStop() {
 	s.log.Info().Msg("stopping")
+	if s.tlsReloader != nil {
+		s.tlsReloader.Stop()
+	}
 	stopped := make(chan struct{})
 	go func() {
 		s.ser.GracefulStop()
@@ -27,11 +27,20 @@ import (
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 
+	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/api/data"
 	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 )
 
+var errWriteRejected = errors.New("the node is draining and rejects writes")
+
+func isWrite(topic bus.Topic) bool {
+	return topic == data.TopicStreamWrite || topic == data.TopicMeasureWrite
+}
+
 func (s *server) Send(stream clusterv1.Service_SendServer) error {
 	reply := func(writeEntity *clusterv1.SendRequest, err error, message string) {
 		s.log.Error().Stringer("written", writeEntity).Err(err).Msg(message)
@@ -46,6 +55,9 @@ func (s *server) Send(stream clusterv1.Service_SendServer) error {
 	var topic *bus.Topic
 	var m bus.Message
 	var dataCollection []any
+	// batched writes are applied at the end of the stream
+	batched := newWatermarks()
+	var replayed []uint64
 	for {
 		select {
 		case <-ctx.Done():
@@ -62,7 +74,17 @@ func (s *server) Send(stream clusterv1.Service_SendServer) error {
 				reply(writeEntity, err, "no listener found")
 				return nil
 			}
-			_ = listener.Rev(bus.NewMessage(bus.MessageID(0), dataCollection))
+			// a committing publisher waits for the end of the stream, the error of applying the batch is sent before it
+			if applied := listener.Rev(bus.NewMessage(bus.MessageID(0), dataCollection)); applied.Data() != nil {
+				if e, isErr := applied.Data().(common.Error); isErr {
+					if errSend := stream.Send(&clusterv1.SendResponse{Error: e.Msg()}); errSend != nil {
+						s.log.Debug().Err(errSend).Msg("failed to send the error of the batch")
+					}
+					return nil
+				}
+			}
+			s.watermarks.merge(batched)
+			s.dedup.record(replayed...)
 			return nil
 		}
 		if err != nil {
@@ -85,19 +107,46 @@ func (s *server) Send(stream clusterv1.Service_SendServer) error {
 			continue
 		}
 
+		if s.rejectWrites.Load() && isWrite(*topic) {
+			if errSend := stream.Send(&clusterv1.SendResponse{
+				MessageId: writeEntity.MessageId,
+				Error:     errWriteRejected.Error(),
+			}); errSend != nil {
+				s.log.Error().Stringer("written", writeEntity).Err(errSend).Msg("failed to send response")
+			}
+			continue
+		}
+		// hold the write until the memory pressure is relieved, so that the flow control pushes back the sender
+		if isWrite(*topic) && !watchdog.WaitReleased(watchdog.ActionWriteBackpressure, ctx.Done()) {
+			return ctx.Err()
+		}
+		if writeEntity.Replay && s.dedup.isApplied(writeEntity.MessageId) {
+			if errSend := stream.Send(&clusterv1.SendResponse{
+				MessageId: writeEntity.MessageId,
+			}); errSend != nil {
+				s.log.Error().Stringer("written", writeEntity).Err(errSend).Msg("failed to send response")
+			}
+			continue
+		}
+		var req proto.Message
 		if reqSupplier, ok := data.TopicRequestMap[*topic]; ok {
-			req := reqSupplier()
+			req = reqSupplier()
 			if errUnmarshal := writeEntity.Body.UnmarshalTo(req); errUnmarshal != nil {
 				reply(writeEntity, errUnmarshal, "failed to unmarshal message")
 				continue
 			}
-			m = bus.NewMessage(bus.MessageID(writeEntity.MessageId), req)
+			// the call is canceled once the sender gives up, e.g., the query times out
+			m = bus.NewMessage(bus.MessageID(writeEntity.MessageId), req).WithContext(ctx)
 		} else {
 			reply(writeEntity, err, "unknown topic")
 			continue
 		}
 		if writeEntity.BatchMod {
 			dataCollection = append(dataCollection, writeEntity.Body)
+			batched.observe(req)
+			if writeEntity.Replay {
+				replayed = append(replayed, writeEntity.MessageId)
+			}
 			if errSend := stream.Send(&clusterv1.SendResponse{
 				MessageId: writeEntity.MessageId,
 			}); errSend != nil {
@@ -112,6 +161,10 @@ func (s *server) Send(stream clusterv1.Service_SendServer) error {
 		}
 
 		m = listener.Rev(m)
+		s.watermarks.observe(req)
+		if writeEntity.Replay {
+			s.dedup.record(writeEntity.MessageId)
+		}
 		if m.Data() == nil {
 			if errSend := stream.Send(&clusterv1.SendResponse{
 				MessageId: writeEntity.MessageId,
@@ -120,11 +173,21 @@ func (s *server) Send(stream clusterv1.Service_SendServer) error {
 			}
 			continue
 		}
+		if e, isErr := m.Data().(common.Error); isErr {
+			reply(writeEntity, nil, e.Msg())
+			continue
+		}
 		message, ok := m.Data().(proto.Message)
 		if !ok {
 			reply(writeEntity, err, "invalid response")
 			continue
 		}
+		if writeEntity.ChunkSize > 0 {
+			if errChunk := s.sendChunks(stream, writeEntity, message); errChunk != nil {
+				s.log.Error().Stringer("written", writeEntity).Err(errChunk).Msg("failed to send chunks")
+			}
+			continue
+		}
 		anyMessage, err := anypb.New(message)
 		if err != nil {
 			reply(writeEntity, err, "failed to marshal message")
@@ -139,6 +202,74 @@ func (s *server) Send(stream clusterv1.Service_SendServer) error {
 	}
 }
 
+// sendChunks splits the response into chunks and sends them in order.
+// It stops if the client cancels the call, for example, after it reaches the query's limit.
+func (s *server) sendChunks(stream clusterv1.Service_SendServer, writeEntity *clusterv1.SendRequest, message proto.Message) error {
+	chunks := splitResponse(message, int(writeEntity.ChunkSize))
+	for i, c := range chunks {
+		if err := stream.Context().Err(); err != nil {
+			s.log.Debug().Int("sent", i).Int("total", len(chunks)).Msg("the client stops receiving chunks")
+			return nil
+		}
+		anyMessage, err := anypb.New(c)
+		if err != nil {
+			return errors.WithMessage(err, "failed to marshal chunk")
+		}
+		if err = stream.Send(&clusterv1.SendResponse{
+			MessageId: writeEntity.MessageId,
+			Body:      anyMessage,
+			HasMore:   i < len(chunks)-1,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitResponse(message proto.Message, chunkSize int) []proto.Message {
+	resp, ok := message.(*streamv1.QueryResponse)
+	if ok && len(resp.Batches) > 0 {
+		return splitBatches(resp, chunkSize)
+	}
+	if !ok || len(resp.Elements) <= chunkSize {
+		return []proto.Message{message}
+	}
+	chunks := make([]proto.Message, 0, (len(resp.Elements)+chunkSize-1)/chunkSize)
+	for i := 0; i < len(resp.Elements); i += chunkSize {
+		end := i + chunkSize
+		if end > len(resp.Elements) {
+			end = len(resp.Elements)
+		}
+		chunks = append(chunks, &streamv1.QueryResponse{Elements: resp.Elements[i:end]})
+	}
+	// the warnings and the trace go with the first chunk
+	chunks[0].(*streamv1.QueryResponse).Warnings = resp.Warnings
+	chunks[0].(*streamv1.QueryResponse).Trace = resp.Trace
+	// the continuation token goes with the last one, which tells that all the elements are received
+	chunks[len(chunks)-1].(*streamv1.QueryResponse).ContinuationToken = resp.ContinuationToken
+	return chunks
+}
+
+// splitBatches splits the column batches of the response into chunks holding about chunkSize elements.
+// A batch is never split.
+func splitBatches(resp *streamv1.QueryResponse, chunkSize int) []proto.Message {
+	var chunks []proto.Message
+	var from, count int
+	for i, b := range resp.Batches {
+		count += int(b.GetCount())
+		if count < chunkSize && i < len(resp.Batches)-1 {
+			continue
+		}
+		chunks = append(chunks, &streamv1.QueryResponse{Batches: resp.Batches[from : i+1]})
+		from, count = i+1, 0
+	}
+	// the warnings, the node failures and the trace go with the first chunk
+	chunks[0].(*streamv1.QueryResponse).Warnings = resp.Warnings
+	chunks[0].(*streamv1.QueryResponse).NodeFailures = resp.NodeFailures
+	chunks[0].(*streamv1.QueryResponse).Trace = resp.Trace
+	return chunks
+}
+
 func (s *server) Subscribe(topic bus.Topic, listener bus.MessageListener) error {
 	s.listenersLock.Lock()
 	defer s.listenersLock.Unlock()
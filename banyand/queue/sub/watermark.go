@@ -0,0 +1,104 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sub
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+)
+
+type watermarkKey struct {
+	group   string
+	shardID uint32
+}
+
+// watermarks tracks the latest data timestamp applied to each shard.
+// They are reported to liaisons through heartbeats.
+type watermarks struct {
+	applied map[watermarkKey]time.Time
+	mu      sync.RWMutex
+}
+
+func newWatermarks() *watermarks {
+	return &watermarks{applied: make(map[watermarkKey]time.Time)}
+}
+
+func (w *watermarks) observe(req any) {
+	var key watermarkKey
+	var ts time.Time
+	switch r := req.(type) {
+	case *streamv1.InternalWriteRequest:
+		key = watermarkKey{group: r.GetRequest().GetMetadata().GetGroup(), shardID: r.GetShardId()}
+		ts = r.GetRequest().GetElement().GetTimestamp().AsTime()
+	case *measurev1.InternalWriteRequest:
+		key = watermarkKey{group: r.GetRequest().GetMetadata().GetGroup(), shardID: r.GetShardId()}
+		ts = r.GetRequest().GetDataPoint().GetTimestamp().AsTime()
+	default:
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.advance(key, ts)
+}
+
+func (w *watermarks) merge(other *watermarks) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for k, ts := range other.applied {
+		w.advance(k, ts)
+	}
+}
+
+func (w *watermarks) advance(key watermarkKey, ts time.Time) {
+	if ts.After(w.applied[key]) {
+		w.applied[key] = ts
+	}
+}
+
+// Rev answers a heartbeat with the watermarks, the write pressure and the sizes of the groups of the node.
+func (w *watermarks) Rev(message bus.Message) bus.Message {
+	sizes := observability.GroupSizes()
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	resp := &clusterv1.HeartbeatResponse{
+		Watermarks: make([]*clusterv1.ShardWatermark, 0, len(w.applied)),
+		Pressure:   watchdog.Pressure(),
+	}
+	for k, ts := range w.applied {
+		resp.Watermarks = append(resp.Watermarks, &clusterv1.ShardWatermark{
+			Group:     k.group,
+			ShardId:   k.shardID,
+			AppliedAt: timestamppb.New(ts),
+		})
+	}
+	for g, size := range sizes {
+		resp.GroupUsages = append(resp.GroupUsages, &clusterv1.GroupUsage{Group: g, SizeBytes: size})
+	}
+	return bus.NewMessage(message.ID(), resp)
+}
@@ -0,0 +1,612 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package shipping ships the writes of a primary server to a warm standby.
+//
+// The storage records the writes it accepts to a WAL per group before applying them,
+// and releases them once they're flushed. The records not released are replayed after a restart.
+// A standby tails the WALs over gRPC from the last records it has persisted, appends them to its
+// own WALs, and applies them to its storage. Once a standby is promoted, it stops
+// tailing and records the writes it accepts like a primary.
+package shipping
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/apache/skywalking-banyandb/api/data"
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/wal"
+	"github.com/apache/skywalking-banyandb/banyand/metadata"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+const (
+	moduleName         = "wal-shipping"
+	groupCheckInterval = 10 * time.Second
+	retryInterval      = time.Second
+	// maxApplyRetries bounds the retries of applying a record, the storage rejects the records
+	// until it loads the schemas of their groups.
+	maxApplyRetries = 30
+)
+
+var (
+	errNotStandby    = errors.New("the server isn't a standby")
+	errInvalidRecord = errors.New("invalid record")
+
+	_ run.PreRunner = (*service)(nil)
+	_ run.Config    = (*service)(nil)
+	_ run.Service   = (*service)(nil)
+)
+
+// Service ships the WAL of a primary to its standby, or tails the primary as a standby.
+type Service interface {
+	run.PreRunner
+	run.Config
+	run.Service
+	wal.Recorder
+	// WritePipeline wraps the pipeline the liaison publishes the writes to.
+	// Its publishers wait until the storage records the writes if the WALs are enabled.
+	WritePipeline(pipeline queue.Client) queue.Client
+}
+
+// offsetKey is the key of the offset in the context of a write, which is replayed or shipped from the WAL.
+type offsetKey struct{}
+
+type service struct {
+	committer  queue.CommitPublisher
+	listGroups func(ctx context.Context) ([]string, error)
+	l          *logger.Logger
+	ser        *grpclib.Server
+	wals       map[string]*groupWAL
+	cancelTail context.CancelFunc
+	// cancelReplay stops replaying the WALs, the records not replayed are replayed after the next restart
+	cancelReplay context.CancelFunc
+	stopCh       chan struct{}
+	clusterv1.UnimplementedShippingServiceServer
+	root         string
+	primaryAddr  string
+	host         string
+	addr         string
+	walSync      string
+	segmentSize  run.Bytes
+	maxSize      run.Bytes
+	syncInterval time.Duration
+	tailing      sync.WaitGroup
+	replaying    sync.WaitGroup
+	mu           sync.Mutex
+	sync         wal.SyncPolicy
+	port         uint32
+	standby      bool
+}
+
+// NewService returns a new WAL shipping service.
+func NewService(metadata metadata.Repo, pipeline queue.Queue) Service {
+	committer, _ := pipeline.(queue.CommitPublisher)
+	return &service{
+		committer: committer,
+		listGroups: func(ctx context.Context) ([]string, error) {
+			gg, err := metadata.GroupRegistry().ListGroup(ctx)
+			if err != nil {
+				return nil, err
+			}
+			var groups []string
+			for _, g := range gg {
+				if g.Catalog == commonv1.Catalog_CATALOG_STREAM || g.Catalog == commonv1.Catalog_CATALOG_MEASURE {
+					groups = append(groups, g.Metadata.Name)
+				}
+			}
+			return groups, nil
+		},
+		wals:   make(map[string]*groupWAL),
+		stopCh: make(chan struct{}),
+	}
+}
+
+func (s *service) Name() string {
+	return moduleName
+}
+
+func (s *service) FlagSet() *run.FlagSet {
+	fs := run.NewFlagSet(moduleName)
+	fs.StringVar(&s.root, "wal-root", "", "the directory of the WALs the writes are recorded to and shipped from, the WALs are disabled if it's empty")
+	s.segmentSize = 64 << 20
+	fs.VarP(&s.segmentSize, "wal-segment-size", "", "the size of a WAL segment")
+	s.maxSize = 10 << 30
+	fs.VarP(&s.maxSize, "wal-max-size", "", "the max size of the WAL of a group, the oldest segments are removed even if the standby hasn't received them")
+	fs.StringVar(&s.walSync, "wal-sync", "interval", "when the WALs are synced to the disk: always, interval or never")
+	fs.DurationVar(&s.syncInterval, "wal-sync-interval", time.Second, "the interval the WALs are synced to the disk in if wal-sync is interval")
+	fs.StringVar(&s.host, "wal-ship-host", "", "the host of the WAL shipping server listens")
+	fs.Uint32Var(&s.port, "wal-ship-port", 17915, "the port of the WAL shipping server listens")
+	fs.StringVar(&s.primaryAddr, "wal-standby-of", "", "the address of the primary's WAL shipping server, the server runs as a standby if it's set")
+	return fs
+}
+
+func (s *service) Validate() error {
+	if s.root == "" {
+		return nil
+	}
+	if s.committer == nil {
+		return errors.New("the WALs are only supported by the standalone server")
+	}
+	if s.segmentSize <= 0 {
+		return errors.New("wal-segment-size must be positive")
+	}
+	var err error
+	if s.sync, err = wal.ParseSyncPolicy(s.walSync); err != nil {
+		return err
+	}
+	if s.sync == wal.SyncInterval && s.syncInterval <= 0 {
+		return errors.New("wal-sync-interval must be positive")
+	}
+	s.addr = net.JoinHostPort(s.host, strconv.FormatUint(uint64(s.port), 10))
+	return nil
+}
+
+func (s *service) PreRun(_ context.Context) error {
+	s.l = logger.GetLogger(moduleName)
+	s.standby = s.primaryAddr != ""
+	return nil
+}
+
+func (s *service) Serve() run.StopNotify {
+	if s.root == "" {
+		return s.stopCh
+	}
+	s.ser = grpclib.NewServer()
+	clusterv1.RegisterShippingServiceServer(s.ser, s)
+	go func() {
+		lis, err := net.Listen("tcp", s.addr)
+		if err != nil {
+			s.l.Error().Err(err).Msg("failed to listen")
+			return
+		}
+		s.l.Info().Str("addr", s.addr).Bool("standby", s.standby).Msg("listening to")
+		if err = s.ser.Serve(lis); err != nil {
+			s.l.Error().Err(err).Msg("server is interrupted")
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancelReplay = cancel
+	s.replaying.Add(1)
+	go func() {
+		defer s.replaying.Done()
+		s.replay(ctx)
+	}()
+	if s.standby {
+		s.startTailing()
+	}
+	return s.stopCh
+}
+
+func (s *service) GracefulStop() {
+	if s.ser != nil {
+		// shipping streams never end by themselves
+		s.ser.Stop()
+	}
+	s.stopTailing()
+	if s.cancelReplay != nil {
+		s.cancelReplay()
+		s.replaying.Wait()
+	}
+	s.mu.Lock()
+	for _, w := range s.wals {
+		if err := w.Close(); err != nil {
+			s.l.Error().Err(err).Str("dir", w.dir).Msg("failed to close the wal")
+		}
+	}
+	s.mu.Unlock()
+	close(s.stopCh)
+}
+
+func (s *service) Enabled() bool {
+	return s.root != ""
+}
+
+// Record appends the write to the WAL of its group, whose offset is held until it's released.
+// A write replayed or shipped from the WAL is held at the offset it's logged.
+func (s *service) Record(ctx context.Context, topic, group string, write proto.Message) (uint64, error) {
+	if s.root == "" {
+		return 0, nil
+	}
+	w, err := s.wal(group)
+	if err != nil {
+		return 0, err
+	}
+	if offset, ok := ctx.Value(offsetKey{}).(uint64); ok {
+		w.hold(offset)
+		return offset, nil
+	}
+	s.mu.Lock()
+	standby := s.standby
+	s.mu.Unlock()
+	if standby {
+		// a standby only logs the writes shipped from the primary
+		return 0, nil
+	}
+	body, err := anypb.New(write)
+	if err != nil {
+		return 0, err
+	}
+	payload, err := proto.Marshal(&clusterv1.WALRecord{Topic: topic, Body: body})
+	if err != nil {
+		return 0, err
+	}
+	offset, err := w.append(payload)
+	if err != nil {
+		return 0, errors.WithMessagef(err, "failed to record the write of group %s", group)
+	}
+	return offset, nil
+}
+
+// Release drops the holds on the offsets, and removes the segments flushed by the storage and persisted by the standby.
+func (s *service) Release(group string, offsets []uint64) {
+	if len(offsets) == 0 {
+		return
+	}
+	s.mu.Lock()
+	w, ok := s.wals[group]
+	s.mu.Unlock()
+	if ok {
+		w.release(offsets)
+	}
+}
+
+func (s *service) WritePipeline(pipeline queue.Client) queue.Client {
+	return &writePipeline{Client: pipeline, s: s}
+}
+
+func (s *service) wal(group string) (*groupWAL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w, ok := s.wals[group]; ok {
+		return w, nil
+	}
+	w, err := openGroupWAL(filepath.Join(s.root, group), wal.Options{
+		SegmentSize:  int64(s.segmentSize),
+		MaxSize:      int64(s.maxSize),
+		Sync:         s.sync,
+		SyncInterval: s.syncInterval,
+	}, s.l.Named(group))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to open the wal of group %s", group)
+	}
+	s.wals[group] = w
+	return w, nil
+}
+
+// replay applies the records after the checkpoints of the groups, which the storage hadn't flushed before the restart.
+func (s *service) replay(ctx context.Context) {
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			s.l.Error().Err(err).Str("root", s.root).Msg("failed to list the wals")
+		}
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		w, errOpen := s.wal(e.Name())
+		if errOpen != nil {
+			s.l.Error().Err(errOpen).Msg("failed to replay the wal")
+			continue
+		}
+		if errReplay := s.replayGroup(ctx, e.Name(), w); errReplay != nil && ctx.Err() == nil {
+			s.l.Error().Err(errReplay).Str("group", e.Name()).Msg("failed to replay the wal")
+		}
+	}
+}
+
+func (s *service) replayGroup(ctx context.Context, group string, w *groupWAL) error {
+	checkpoint := w.holdCheckpoint()
+	from := checkpoint
+	if first := w.FirstOffset(); from+1 < first {
+		s.l.Warn().Str("group", group).Uint64("checkpoint", checkpoint).Uint64("first", first).
+			Msg("the records not flushed are removed since the wal was full")
+		from = first - 1
+	}
+	var count int
+	err := w.Replay(from, func(offset uint64, payload []byte) error {
+		rec := &clusterv1.WALRecord{}
+		if err := proto.Unmarshal(payload, rec); err != nil {
+			return errors.WithMessagef(errInvalidRecord, "offset %d: %v", offset, err)
+		}
+		rec.Offset = offset
+		if err := s.applyWithRetry(ctx, rec); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if count > 0 {
+		s.l.Info().Str("group", group).Uint64("checkpoint", checkpoint).Int("records", count).Msg("replayed the wal")
+	}
+	if ctx.Err() != nil {
+		// the checkpoint stays before the records not replayed
+		return ctx.Err()
+	}
+	w.release([]uint64{checkpoint + 1})
+	return err
+}
+
+// Ship sends the records after the standby's offset, and removes the ones it has acknowledged.
+func (s *service) Ship(stream clusterv1.ShippingService_ShipServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	w, err := s.wal(req.Group)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	r, err := w.NewReader(req.Offset)
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	defer r.Close()
+	w.ack(req.Offset)
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			ack, errRecv := stream.Recv()
+			if errRecv != nil {
+				return
+			}
+			w.ack(ack.Offset)
+		}
+	}()
+	for {
+		offset, payload, errRead := r.Read(ctx)
+		if errRead != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return status.Error(codes.Internal, errRead.Error())
+		}
+		rec := &clusterv1.WALRecord{}
+		if errUnmarshal := proto.Unmarshal(payload, rec); errUnmarshal != nil {
+			return status.Error(codes.Internal, errUnmarshal.Error())
+		}
+		rec.Offset = offset
+		if errSend := stream.Send(rec); errSend != nil {
+			return errSend
+		}
+	}
+}
+
+// Promote stops tailing the primary, and records the writes the server accepts from now on.
+func (s *service) Promote(_ context.Context, _ *clusterv1.PromoteRequest) (*clusterv1.PromoteResponse, error) {
+	s.mu.Lock()
+	if !s.standby {
+		s.mu.Unlock()
+		return nil, status.Error(codes.FailedPrecondition, errNotStandby.Error())
+	}
+	s.mu.Unlock()
+	s.stopTailing()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.standby = false
+	resp := &clusterv1.PromoteResponse{}
+	for group, w := range s.wals {
+		resp.Offsets = append(resp.Offsets, &clusterv1.GroupOffset{Group: group, Offset: w.LastOffset()})
+	}
+	sort.Slice(resp.Offsets, func(i, j int) bool { return resp.Offsets[i].Group < resp.Offsets[j].Group })
+	s.l.Info().Interface("offsets", resp.Offsets).Msg("promoted to the primary")
+	return resp, nil
+}
+
+func (s *service) startTailing() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancelTail = cancel
+	s.mu.Unlock()
+	s.tailing.Add(1)
+	go func() {
+		defer s.tailing.Done()
+		conn, err := grpclib.Dial(s.primaryAddr, grpclib.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			s.l.Error().Err(err).Str("primary", s.primaryAddr).Msg("failed to connect to the primary")
+			return
+		}
+		defer conn.Close()
+		client := clusterv1.NewShippingServiceClient(conn)
+		tailed := make(map[string]struct{})
+		ticker := time.NewTicker(groupCheckInterval)
+		defer ticker.Stop()
+		for {
+			groups, errList := s.listGroups(ctx)
+			if errList != nil {
+				s.l.Error().Err(errList).Msg("failed to list groups")
+			}
+			for _, g := range groups {
+				if _, ok := tailed[g]; ok {
+					continue
+				}
+				tailed[g] = struct{}{}
+				s.tailing.Add(1)
+				go s.tailGroup(ctx, client, g)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *service) stopTailing() {
+	s.mu.Lock()
+	cancel := s.cancelTail
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	s.tailing.Wait()
+}
+
+func (s *service) tailGroup(ctx context.Context, client clusterv1.ShippingServiceClient, group string) {
+	defer s.tailing.Done()
+	for {
+		err := s.tail(ctx, client, group)
+		if ctx.Err() != nil {
+			return
+		}
+		s.l.Warn().Err(err).Str("group", group).Msg("failed to tail the primary, retry later")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// tail persists a record before applying it, and acknowledges it after that.
+// The primary resends the records after the last persisted one on reconnecting.
+func (s *service) tail(ctx context.Context, client clusterv1.ShippingServiceClient, group string) error {
+	w, err := s.wal(group)
+	if err != nil {
+		return err
+	}
+	stream, err := client.Ship(ctx)
+	if err != nil {
+		return err
+	}
+	if err = stream.Send(&clusterv1.ShipRequest{Group: group, Offset: w.LastOffset()}); err != nil {
+		return err
+	}
+	for {
+		rec, errRecv := stream.Recv()
+		if errRecv != nil {
+			return errRecv
+		}
+		payload, errMarshal := proto.Marshal(&clusterv1.WALRecord{Topic: rec.Topic, Body: rec.Body})
+		if errMarshal != nil {
+			return errMarshal
+		}
+		// the record isn't released before the storage holds it
+		w.hold(rec.Offset)
+		if err = w.AppendAt(rec.Offset, payload); err != nil {
+			w.release([]uint64{rec.Offset})
+			return err
+		}
+		if err = s.applyWithRetry(ctx, rec); err != nil {
+			// the record stays held, which is replayed after the next restart
+			return err
+		}
+		w.release([]uint64{rec.Offset})
+		if err = stream.Send(&clusterv1.ShipRequest{Group: group, Offset: rec.Offset}); err != nil {
+			return err
+		}
+	}
+}
+
+// applyWithRetry applies the record until the storage accepts it.
+// The record is dropped if the storage keeps rejecting it.
+func (s *service) applyWithRetry(ctx context.Context, rec *clusterv1.WALRecord) error {
+	for i := 0; ; i++ {
+		err := s.apply(ctx, rec)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errInvalidRecord) || i >= maxApplyRetries {
+			s.l.Error().Err(err).Uint64("offset", rec.Offset).Msg("drop the record failing to be applied")
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// apply hands the write of the record to the storage, which holds it at the offset of the record.
+func (s *service) apply(ctx context.Context, rec *clusterv1.WALRecord) error {
+	topic, ok := data.TopicMap[rec.Topic]
+	if !ok {
+		return errors.WithMessagef(errInvalidRecord, "unknown topic %s", rec.Topic)
+	}
+	supplier, ok := data.TopicRequestMap[topic]
+	if !ok {
+		return errors.WithMessagef(errInvalidRecord, "no request of topic %s", rec.Topic)
+	}
+	req := supplier()
+	if err := rec.Body.UnmarshalTo(req); err != nil {
+		return errors.WithMessagef(errInvalidRecord, "offset %d: %v", rec.Offset, err)
+	}
+	return s.committer.PublishCommit(context.WithValue(ctx, offsetKey{}, rec.Offset), topic, "",
+		bus.NewMessage(bus.MessageID(rec.Offset), req))
+}
+
+// writePipeline publishes the writes one by one, and waits until the storage records and applies them.
+// So the liaison acknowledges a write after it's in the WAL.
+type writePipeline struct {
+	queue.Client
+	s *service
+}
+
+func (p *writePipeline) NewBatchPublisher() queue.BatchPublisher {
+	if !p.s.Enabled() {
+		return p.Client.NewBatchPublisher()
+	}
+	return &recordingPublisher{committer: p.s.committer}
+}
+
+// PublishCommit keeps the bulk writes committed through the pipeline, the storage records them as well.
+func (p *writePipeline) PublishCommit(ctx context.Context, topic bus.Topic, node string, messages ...bus.Message) error {
+	return p.s.committer.PublishCommit(ctx, topic, node, messages...)
+}
+
+type recordingPublisher struct {
+	committer queue.CommitPublisher
+}
+
+func (p *recordingPublisher) Publish(topic bus.Topic, messages ...bus.Message) (bus.Future, error) {
+	for _, m := range messages {
+		if err := p.committer.PublishCommit(context.Background(), topic, m.Node(), m); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (p *recordingPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,302 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package shipping
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/apache/skywalking-banyandb/api/data"
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/wal"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+)
+
+const testGroup = "sw_record"
+
+// fakeStorage records the writes like the write callbacks of the storage, and holds them until they're flushed.
+type fakeStorage struct {
+	recorder wal.Recorder
+	applied  []proto.Message
+	held     []uint64
+	mu       sync.Mutex
+}
+
+func (p *fakeStorage) PublishCommit(ctx context.Context, topic bus.Topic, _ string, messages ...bus.Message) error {
+	for _, m := range messages {
+		w := m.Data().(*streamv1.InternalWriteRequest)
+		offset, err := p.recorder.Record(ctx, topic.String(), w.Request.Metadata.Group, w)
+		if err != nil {
+			return err
+		}
+		p.mu.Lock()
+		p.applied = append(p.applied, w)
+		if offset > 0 {
+			p.held = append(p.held, offset)
+		}
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+func (p *fakeStorage) write(i int) {
+	if err := p.PublishCommit(context.Background(), data.TopicStreamWrite, "", bus.NewMessage(bus.MessageID(i), newWrite(i))); err != nil {
+		panic(err)
+	}
+}
+
+func (p *fakeStorage) flush() {
+	p.mu.Lock()
+	held := p.held
+	p.held = nil
+	p.mu.Unlock()
+	p.recorder.Release(testGroup, held)
+}
+
+func (p *fakeStorage) appliedWrites() []proto.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]proto.Message(nil), p.applied...)
+}
+
+func newTestService(t *testing.T, root, primaryAddr string) (*service, *fakeStorage) {
+	p := &fakeStorage{}
+	s := &service{
+		committer: p,
+		listGroups: func(context.Context) ([]string, error) {
+			return []string{testGroup}, nil
+		},
+		wals:        make(map[string]*groupWAL),
+		stopCh:      make(chan struct{}),
+		root:        root,
+		primaryAddr: primaryAddr,
+		segmentSize: 4 << 10,
+		sync:        wal.SyncNever,
+	}
+	p.recorder = s
+	require.NoError(t, s.PreRun(context.Background()))
+	return s, p
+}
+
+func newWrite(i int) *streamv1.InternalWriteRequest {
+	return &streamv1.InternalWriteRequest{
+		Request: &streamv1.WriteRequest{
+			Metadata:  &commonv1.Metadata{Group: testGroup, Name: "sw"},
+			Element:   &streamv1.ElementValue{ElementId: strconv.Itoa(i)},
+			MessageId: uint64(i + 1),
+		},
+	}
+}
+
+func readAll(t *testing.T, w *groupWAL, after uint64) []*clusterv1.WALRecord {
+	var records []*clusterv1.WALRecord
+	require.NoError(t, w.Replay(after, func(offset uint64, payload []byte) error {
+		rec := &clusterv1.WALRecord{}
+		if err := proto.Unmarshal(payload, rec); err != nil {
+			return err
+		}
+		rec.Offset = offset
+		records = append(records, rec)
+		return nil
+	}))
+	return records
+}
+
+func serve(t *testing.T, s *service) (string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ser := grpclib.NewServer()
+	clusterv1.RegisterShippingServiceServer(ser, s)
+	go func() {
+		_ = ser.Serve(lis)
+	}()
+	return lis.Addr().String(), ser.Stop
+}
+
+func TestStandbyAfterPrimaryFailure(t *testing.T) {
+	primary, primaryStorage := newTestService(t, t.TempDir(), "")
+	addr, stopPrimary := serve(t, primary)
+	standby, standbyStorage := newTestService(t, t.TempDir(), addr)
+	assert.True(t, standby.standby)
+	standby.startTailing()
+	defer standby.stopTailing()
+
+	stopWriting := make(chan struct{})
+	writing := make(chan struct{})
+	go func() {
+		defer close(writing)
+		for i := 0; ; i++ {
+			select {
+			case <-stopWriting:
+				return
+			default:
+			}
+			// a batch like the one written by the liaison
+			_ = primaryStorage.PublishCommit(context.Background(), data.TopicStreamWrite, "",
+				bus.NewMessage(bus.MessageID(i), newWrite(2*i)), bus.NewMessage(bus.MessageID(i), newWrite(2*i+1)))
+			if i%10 == 0 {
+				primaryStorage.flush()
+			}
+		}
+	}()
+	primaryWAL, err := primary.wal(testGroup)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(standbyStorage.appliedWrites()) >= 200
+	}, 10*time.Second, 10*time.Millisecond)
+	// the primary removes the segments flushed and acknowledged by the standby
+	require.Eventually(t, func() bool {
+		return primaryWAL.FirstOffset() > 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// kill the primary while it's shipping
+	stopPrimary()
+	close(stopWriting)
+	<-writing
+
+	resp, err := standby.Promote(context.Background(), &clusterv1.PromoteRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Offsets, 1)
+	assert.Equal(t, testGroup, resp.Offsets[0].Group)
+	shipped := resp.Offsets[0].Offset
+	assert.GreaterOrEqual(t, shipped, uint64(200))
+
+	require.LessOrEqual(t, shipped, primaryWAL.LastOffset())
+	standbyWAL, err := standby.wal(testGroup)
+	require.NoError(t, err)
+	got := readAll(t, standbyWAL, 0)
+	require.Len(t, got, int(shipped))
+	applied := standbyStorage.appliedWrites()
+	require.Len(t, applied, int(shipped))
+	for i, r := range got {
+		w := &streamv1.InternalWriteRequest{}
+		require.NoError(t, r.Body.UnmarshalTo(w))
+		require.True(t, proto.Equal(w, applied[i]), "write %d differs", r.Offset)
+		// the writes are shipped in order
+		assert.Equal(t, strconv.Itoa(i), w.Request.Element.ElementId)
+	}
+	// the retained records on the primary are the same as the shipped ones
+	retained := primaryWAL.FirstOffset()
+	for i, r := range readAll(t, primaryWAL, retained-1) {
+		if r.Offset > shipped {
+			break
+		}
+		require.True(t, proto.Equal(r, got[int(retained)-1+i]), "record %d differs", r.Offset)
+	}
+
+	// the promoted standby records the writes it accepts after the shipped ones
+	standbyStorage.write(int(shipped))
+	assert.Equal(t, shipped+1, standbyWAL.LastOffset())
+
+	_, err = standby.Promote(context.Background(), &clusterv1.PromoteRequest{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	for _, w := range []*groupWAL{primaryWAL, standbyWAL} {
+		require.NoError(t, w.Close())
+	}
+}
+
+func TestStandbyResume(t *testing.T) {
+	primary, primaryStorage := newTestService(t, t.TempDir(), "")
+	addr, stopPrimary := serve(t, primary)
+	defer stopPrimary()
+	for i := 0; i < 10; i++ {
+		primaryStorage.write(i)
+	}
+
+	standby, standbyStorage := newTestService(t, t.TempDir(), addr)
+	standbyWAL, err := standby.wal(testGroup)
+	require.NoError(t, err)
+	// the standby has persisted the first records before restarting
+	primaryWAL, err := primary.wal(testGroup)
+	require.NoError(t, err)
+	for _, r := range readAll(t, primaryWAL, 0)[:4] {
+		payload, errMarshal := proto.Marshal(&clusterv1.WALRecord{Topic: r.Topic, Body: r.Body})
+		require.NoError(t, errMarshal)
+		require.NoError(t, standbyWAL.AppendAt(r.Offset, payload))
+	}
+	standby.startTailing()
+	require.Eventually(t, func() bool {
+		return standbyWAL.LastOffset() == 10
+	}, 10*time.Second, 10*time.Millisecond)
+	standby.stopTailing()
+	applied := standbyStorage.appliedWrites()
+	require.Len(t, applied, 6)
+	assert.Equal(t, "4", applied[0].(*streamv1.InternalWriteRequest).Request.Element.ElementId)
+}
+
+func TestReplayAfterRestart(t *testing.T) {
+	root := t.TempDir()
+	s, storage := newTestService(t, root, "")
+	for i := 0; i < 5; i++ {
+		storage.write(i)
+	}
+	// the storage flushes the first writes before crashing
+	storage.mu.Lock()
+	flushed := storage.held[:3]
+	storage.held = storage.held[3:]
+	storage.mu.Unlock()
+	s.Release(testGroup, flushed)
+	w, err := s.wal(testGroup)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), w.checkpoint)
+	require.NoError(t, w.Close())
+
+	s, storage = newTestService(t, root, "")
+	s.replay(context.Background())
+	applied := storage.appliedWrites()
+	require.Len(t, applied, 2, "only the writes not flushed are replayed")
+	assert.Equal(t, "3", applied[0].(*streamv1.InternalWriteRequest).Request.Element.ElementId)
+	w, err = s.wal(testGroup)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), w.LastOffset(), "the replayed writes aren't logged again")
+	assert.Equal(t, uint64(3), w.checkpoint, "the replayed writes are held until they're flushed")
+	storage.write(5)
+	storage.flush()
+	assert.Equal(t, uint64(6), w.checkpoint)
+	require.NoError(t, w.Close())
+
+	s, storage = newTestService(t, root, "")
+	s.replay(context.Background())
+	assert.Empty(t, storage.appliedWrites(), "nothing is replayed once the writes are flushed")
+}
+
+func TestWritePipeline(t *testing.T) {
+	s, storage := newTestService(t, t.TempDir(), "")
+	publisher := s.WritePipeline(nil).NewBatchPublisher()
+	_, err := publisher.Publish(data.TopicStreamWrite, bus.NewBatchMessageWithNode(1, "local", newWrite(0)))
+	require.NoError(t, err)
+	w, err := s.wal(testGroup)
+	require.NoError(t, err)
+	defer w.Close()
+	assert.Equal(t, uint64(1), w.LastOffset(), "the write is recorded before the publisher returns")
+	assert.Len(t, storage.appliedWrites(), 1)
+	require.NoError(t, publisher.Close())
+}
@@ -0,0 +1,162 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package shipping
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/banyand/internal/wal"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+const (
+	checkpointFilename = "checkpoint"
+	filePermission     = 0o600
+)
+
+// groupWAL is the wal of a group, which tracks the records the storage hasn't flushed yet.
+type groupWAL struct {
+	*wal.Log
+	l *logger.Logger
+	// pending counts the holds on the offsets of the records not flushed yet
+	pending map[uint64]int
+	dir     string
+	// checkpoint is the offset at or before which the records are all flushed, it's persisted in the dir.
+	checkpoint uint64
+	// acked is the last offset the standby has persisted
+	acked uint64
+	mu    sync.Mutex
+}
+
+func openGroupWAL(dir string, opts wal.Options, l *logger.Logger) (*groupWAL, error) {
+	log, err := wal.Open(dir, opts, l)
+	if err != nil {
+		return nil, err
+	}
+	w := &groupWAL{
+		Log:     log,
+		l:       l,
+		pending: make(map[uint64]int),
+		dir:     dir,
+	}
+	data, err := os.ReadFile(filepath.Join(dir, checkpointFilename))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		_ = log.Close()
+		return nil, err
+	case len(data) != 8:
+		_ = log.Close()
+		return nil, errors.Errorf("invalid checkpoint of %d bytes in %s", len(data), dir)
+	default:
+		// the torn records dropped by opening the log are never acknowledged
+		w.checkpoint = min(binary.BigEndian.Uint64(data), log.LastOffset())
+	}
+	return w, nil
+}
+
+// hold keeps the checkpoint before the offset until it's released.
+func (w *groupWAL) hold(offset uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending[offset]++
+}
+
+// holdCheckpoint holds the offset after the checkpoint, and returns the checkpoint.
+func (w *groupWAL) holdCheckpoint() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending[w.checkpoint+1]++
+	return w.checkpoint
+}
+
+// append logs the payload, whose offset is held until it's released.
+func (w *groupWAL) append(payload []byte) (uint64, error) {
+	// the offset to append at is held before appending, so that the checkpoint never passes the record
+	w.mu.Lock()
+	next := w.LastOffset() + 1
+	w.pending[next]++
+	w.mu.Unlock()
+	offset, err := w.Append(payload)
+	if err == nil {
+		w.hold(offset)
+	}
+	w.release([]uint64{next})
+	return offset, err
+}
+
+// release drops the holds on the offsets, and moves the checkpoint to the last offset before the ones still held.
+func (w *groupWAL) release(offsets []uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, offset := range offsets {
+		if w.pending[offset] > 1 {
+			w.pending[offset]--
+			continue
+		}
+		delete(w.pending, offset)
+	}
+	checkpoint := w.LastOffset()
+	for offset := range w.pending {
+		checkpoint = min(checkpoint, offset-1)
+	}
+	if checkpoint <= w.checkpoint {
+		return
+	}
+	if err := w.persistCheckpoint(checkpoint); err != nil {
+		w.l.Error().Err(err).Str("dir", w.dir).Uint64("checkpoint", checkpoint).Msg("failed to persist the checkpoint")
+		return
+	}
+	w.checkpoint = checkpoint
+	w.Truncate(min(w.checkpoint, w.acked))
+}
+
+// ack removes the segments persisted by the standby and flushed by the storage.
+func (w *groupWAL) ack(offset uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if offset <= w.acked {
+		return
+	}
+	w.acked = offset
+	w.Truncate(min(w.checkpoint, w.acked))
+}
+
+func (w *groupWAL) persistCheckpoint(checkpoint uint64) error {
+	path := filepath.Join(w.dir, checkpointFilename)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePermission)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(binary.BigEndian.AppendUint64(nil, checkpoint)); err == nil {
+		err = f.Sync()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
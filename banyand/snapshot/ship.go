@@ -0,0 +1,205 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snapshot
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+const (
+	// shipmentsDir holds the shipments under the snapshot root, which isn't listed as a snapshot.
+	shipmentsDir = ".shipments"
+	// shipChunkSize is the largest chunk of a file read from a shipment, which fits in a message.
+	shipChunkSize = 1 << 20
+)
+
+var (
+	errShipmentExist = errors.New("the shipment exists")
+	errInvalidPath   = errors.New("invalid path of the shipment")
+
+	_ bus.MessageListener = (*shipper)(nil)
+)
+
+// shipper ships the parts of a shard from a replica to another lagging behind.
+//
+// The source replica archives the flushed parts of the shard overlapping a time range into a shipment,
+// whose files are read chunk by chunk and written to a shipment of the same id on the lagging replica
+// by a liaison. The lagging replica merges the shipment into the shard once all files arrive.
+// Both replicas remove the shipment once the liaison releases it.
+type shipper struct {
+	*service
+}
+
+func (sh *shipper) Rev(message bus.Message) (resp bus.Message) {
+	req, ok := message.Data().(*clusterv1.PartShipRequest)
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("invalid event data type"))
+	}
+	if !validName(req.GetId()) {
+		return bus.NewMessage(message.ID(), common.NewError("%v: %q", errInvalidName, req.GetId()))
+	}
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	result, err := sh.ship(req)
+	if err != nil {
+		sh.l.Error().Err(err).Stringer("action", req.GetAction()).Str("id", req.GetId()).Msg("failed to ship the parts")
+		return bus.NewMessage(message.ID(), common.NewError("failed to %s: %v", req.GetAction(), err))
+	}
+	return bus.NewMessage(message.ID(), result)
+}
+
+func (sh *shipper) ship(req *clusterv1.PartShipRequest) (*clusterv1.PartShipResponse, error) {
+	dir := filepath.Join(sh.root, shipmentsDir, req.GetId())
+	switch req.GetAction() {
+	case clusterv1.PartShipAction_PART_SHIP_ACTION_ARCHIVE:
+		return sh.archive(dir, req)
+	case clusterv1.PartShipAction_PART_SHIP_ACTION_READ:
+		return sh.read(dir, req)
+	case clusterv1.PartShipAction_PART_SHIP_ACTION_WRITE:
+		return sh.write(dir, req)
+	case clusterv1.PartShipAction_PART_SHIP_ACTION_MERGE:
+		archiver, err := sh.archiverOf(req.GetCatalog())
+		if err != nil {
+			return nil, err
+		}
+		archive, err := archiver.archiver.Merge(dir, req.GetGroup())
+		if err != nil {
+			return nil, err
+		}
+		sh.l.Info().Str("id", req.GetId()).Str("group", req.GetGroup()).Uint32("shard", req.GetShardId()).
+			Uint32("segments", archive.GetSegments()).Msg("merged the shipment")
+		return &clusterv1.PartShipResponse{Archive: sh.fill([]*databasev1.SnapshotArchive{archive}, archiver.catalog)[0]}, nil
+	case clusterv1.PartShipAction_PART_SHIP_ACTION_RELEASE:
+		return &clusterv1.PartShipResponse{}, os.RemoveAll(dir)
+	default:
+		return nil, errors.Errorf("unknown action %s", req.GetAction())
+	}
+}
+
+// archive links the parts into the shipment, and lists its files.
+func (sh *shipper) archive(dir string, req *clusterv1.PartShipRequest) (*clusterv1.PartShipResponse, error) {
+	archiver, err := sh.archiverOf(req.GetCatalog())
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WithMessage(errShipmentExist, req.GetId())
+	}
+	if err = os.MkdirAll(filepath.Dir(dir), 0o700); err != nil {
+		return nil, err
+	}
+	timeRange := timestamp.NewInclusiveTimeRange(req.GetBegin().AsTime(), req.GetEnd().AsTime())
+	archive, err := archiver.archiver.ArchiveShard(dir, req.GetGroup(), common.ShardID(req.GetShardId()), timeRange)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	resp := &clusterv1.PartShipResponse{Archive: sh.fill([]*databasev1.SnapshotArchive{archive}, archiver.catalog)[0]}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, errWalk error) error {
+		if errWalk != nil || d.IsDir() {
+			return errWalk
+		}
+		info, errInfo := d.Info()
+		if errInfo != nil {
+			return errInfo
+		}
+		rel, errRel := filepath.Rel(dir, path)
+		if errRel != nil {
+			return errRel
+		}
+		resp.Files = append(resp.Files, &clusterv1.ShippedFile{Path: filepath.ToSlash(rel), Size: uint64(info.Size())})
+		return nil
+	})
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// read returns the chunk of a file at the offset, which is empty at the end of the file.
+func (sh *shipper) read(dir string, req *clusterv1.PartShipRequest) (*clusterv1.PartShipResponse, error) {
+	path, err := shippedPath(dir, req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, shipChunkSize)
+	n, err := f.ReadAt(buf, int64(req.GetOffset()))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return &clusterv1.PartShipResponse{Data: buf[:n]}, nil
+}
+
+// write writes the chunk of a file at the offset, creating the file and its directory if they don't exist.
+func (sh *shipper) write(dir string, req *clusterv1.PartShipRequest) (*clusterv1.PartShipResponse, error) {
+	path, err := shippedPath(dir, req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.WriteAt(req.GetData(), int64(req.GetOffset()))
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &clusterv1.PartShipResponse{}, nil
+}
+
+func (sh *shipper) archiverOf(catalog commonv1.Catalog) (catalogArchiver, error) {
+	for _, ca := range sh.archivers {
+		if ca.catalog == catalog {
+			return ca, nil
+		}
+	}
+	return catalogArchiver{}, errors.Errorf("unsupported catalog %s", catalog)
+}
+
+// shippedPath rejects the paths escaping the shipment.
+func shippedPath(dir, path string) (string, error) {
+	path = filepath.FromSlash(path)
+	if !filepath.IsLocal(path) {
+		return "", errors.WithMessagef(errInvalidPath, "%q", path)
+	}
+	return filepath.Join(dir, path), nil
+}
@@ -0,0 +1,335 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package snapshot implements the snapshots of the data on a data node.
+//
+// A snapshot hard-links the flushed parts of the groups into a directory under the snapshot root,
+// which is portable and copied elsewhere as a backup. The parts are immutable, so a snapshot takes
+// little extra space until they are merged away. Importing a snapshot registers the archived segments
+// which the node doesn't have, which restores a backup on a fresh node.
+//
+// The same archives ship the parts of a shard from one replica to another lagging behind, see shipper.
+package snapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/api/data"
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+// metadataFilename is the file describing a snapshot, which is written once the groups are archived.
+const metadataFilename = "snapshot.json"
+
+var (
+	errEmptyRootPath    = errors.New("snapshot root path is empty")
+	errInvalidName      = errors.New("invalid snapshot name")
+	errSnapshotExist    = errors.New("the snapshot exists")
+	errSnapshotNotExist = errors.New("the snapshot doesn't exist")
+
+	_ run.PreRunner       = (*service)(nil)
+	_ run.Config          = (*service)(nil)
+	_ bus.MessageListener = (*service)(nil)
+)
+
+// Archiver archives the groups of a catalog into a snapshot, and imports them from one.
+type Archiver interface {
+	// Archive writes the flushed data of the groups into the dir, all groups if groups is empty.
+	Archive(dir string, groups []string) ([]*databasev1.SnapshotArchive, error)
+	// Import registers the segments of the groups archived in the dir, which the node doesn't have.
+	Import(dir string, groups []string) ([]*databasev1.SnapshotArchive, error)
+	// ArchiveShard writes the flushed data of a shard of the group overlapping the time range into the dir.
+	ArchiveShard(dir, group string, shardID common.ShardID, timeRange timestamp.TimeRange) (*databasev1.SnapshotArchive, error)
+	// Merge imports the data archived by ArchiveShard on another node into the group.
+	Merge(dir, group string) (*databasev1.SnapshotArchive, error)
+}
+
+// Service manages the snapshots of a data node.
+type Service interface {
+	run.PreRunner
+	run.Config
+	// Create takes a snapshot of the groups, all groups if groups is empty.
+	Create(name string, groups []string) (*databasev1.Snapshot, error)
+	// Delete removes the snapshot. It's a no-op if the snapshot doesn't exist.
+	Delete(name string) error
+	// Path returns the directory of the snapshot.
+	Path(name string) string
+}
+
+type catalogArchiver struct {
+	archiver Archiver
+	dir      string
+	catalog  commonv1.Catalog
+}
+
+type service struct {
+	pipeline  queue.Server
+	l         *logger.Logger
+	root      string
+	nodeID    string
+	archivers []catalogArchiver
+	mu        sync.Mutex
+}
+
+// NewService returns a service taking the snapshots of the streams and measures on the demand of liaisons.
+func NewService(pipeline queue.Server, streamArchiver, measureArchiver Archiver) Service {
+	return &service{
+		pipeline: pipeline,
+		archivers: []catalogArchiver{
+			{catalog: commonv1.Catalog_CATALOG_STREAM, dir: "stream", archiver: streamArchiver},
+			{catalog: commonv1.Catalog_CATALOG_MEASURE, dir: "measure", archiver: measureArchiver},
+		},
+	}
+}
+
+func (s *service) Name() string {
+	return "snapshot"
+}
+
+func (s *service) FlagSet() *run.FlagSet {
+	flagS := run.NewFlagSet("snapshot")
+	flagS.StringVar(&s.root, "snapshot-root-path", "/tmp/snapshots",
+		"the root path of the snapshots, which should be on the same device as the data to link the parts")
+	return flagS
+}
+
+func (s *service) Validate() error {
+	if s.root == "" {
+		return errEmptyRootPath
+	}
+	return nil
+}
+
+func (s *service) PreRun(ctx context.Context) error {
+	s.l = logger.GetLogger(s.Name())
+	val := ctx.Value(common.ContextNodeKey)
+	if val == nil {
+		return errors.New("node id is empty")
+	}
+	s.nodeID = val.(common.Node).NodeID
+	if err := os.MkdirAll(s.root, 0o700); err != nil {
+		return err
+	}
+	if err := s.pipeline.Subscribe(data.TopicSnapshot, s); err != nil {
+		return err
+	}
+	return s.pipeline.Subscribe(data.TopicPartShip, &shipper{service: s})
+}
+
+func (s *service) Rev(message bus.Message) (resp bus.Message) {
+	req, ok := message.Data().(*clusterv1.SnapshotRequest)
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("invalid event data type"))
+	}
+	if req.GetAction() != clusterv1.SnapshotAction_SNAPSHOT_ACTION_LIST && !validName(req.GetName()) {
+		return bus.NewMessage(message.ID(), common.NewError("%v: %q", errInvalidName, req.GetName()))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var snapshots []*databasev1.Snapshot
+	var snp *databasev1.Snapshot
+	var err error
+	switch req.GetAction() {
+	case clusterv1.SnapshotAction_SNAPSHOT_ACTION_CREATE:
+		snp, err = s.create(req)
+	case clusterv1.SnapshotAction_SNAPSHOT_ACTION_LIST:
+		snapshots, err = s.list()
+	case clusterv1.SnapshotAction_SNAPSHOT_ACTION_DELETE:
+		snp, err = s.delete(req.GetName())
+	case clusterv1.SnapshotAction_SNAPSHOT_ACTION_IMPORT:
+		snp, err = s.importSnapshot(req)
+	default:
+		err = errors.Errorf("unknown action %s", req.GetAction())
+	}
+	if err != nil {
+		s.l.Error().Err(err).Stringer("action", req.GetAction()).Str("name", req.GetName()).Msg("failed to operate the snapshot")
+		return bus.NewMessage(message.ID(), common.NewError("failed to %s: %v", req.GetAction(), err))
+	}
+	if snp != nil {
+		snapshots = append(snapshots, snp)
+	}
+	return bus.NewMessage(message.ID(), &clusterv1.SnapshotResponse{Snapshots: snapshots})
+}
+
+func (s *service) Create(name string, groups []string) (*databasev1.Snapshot, error) {
+	if !validName(name) {
+		return nil, errors.WithMessagef(errInvalidName, "%q", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.create(&clusterv1.SnapshotRequest{Name: name, Groups: groups, CreatedAt: timestamppb.Now()})
+}
+
+func (s *service) Delete(name string) error {
+	if !validName(name) {
+		return errors.WithMessagef(errInvalidName, "%q", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.delete(name)
+	return err
+}
+
+func (s *service) Path(name string) string {
+	return filepath.Join(s.root, name)
+}
+
+func (s *service) create(req *clusterv1.SnapshotRequest) (*databasev1.Snapshot, error) {
+	dir := filepath.Join(s.root, req.GetName())
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WithMessage(errSnapshotExist, req.GetName())
+	}
+	snp := &databasev1.Snapshot{
+		Name:      req.GetName(),
+		CreatedAt: req.GetCreatedAt(),
+	}
+	for _, ca := range s.archivers {
+		archives, err := ca.archiver.Archive(filepath.Join(dir, ca.dir), req.GetGroups())
+		if err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, err
+		}
+		snp.Archives = append(snp.Archives, s.fill(archives, ca.catalog)...)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	data, err := protojson.Marshal(snp)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, metadataFilename), data, 0o600)
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	s.l.Info().Str("name", snp.GetName()).Int("archives", len(snp.GetArchives())).Msg("created the snapshot")
+	return snp, nil
+}
+
+func (s *service) list() ([]*databasev1.Snapshot, error) {
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		return nil, err
+	}
+	var result []*databasev1.Snapshot
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		snp, errRead := s.read(e.Name())
+		if errors.Is(errRead, errSnapshotNotExist) {
+			// the snapshot is being created or was left behind by a crash
+			continue
+		}
+		if errRead != nil {
+			return nil, errRead
+		}
+		result = append(result, snp)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GetName() < result[j].GetName()
+	})
+	return result, nil
+}
+
+// delete removes the snapshot. It returns nil if the node doesn't have the snapshot.
+func (s *service) delete(name string) (*databasev1.Snapshot, error) {
+	dir := filepath.Join(s.root, name)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	snp, err := s.read(name)
+	if errors.Is(err, errSnapshotNotExist) {
+		snp, err = &databasev1.Snapshot{Name: name}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	s.l.Info().Str("name", name).Msg("deleted the snapshot")
+	return snp, nil
+}
+
+// importSnapshot registers the archived segments. The snapshot might be copied from another node,
+// so the archives are reported by this node.
+func (s *service) importSnapshot(req *clusterv1.SnapshotRequest) (*databasev1.Snapshot, error) {
+	archived, err := s.read(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	snp := &databasev1.Snapshot{
+		Name:      archived.GetName(),
+		CreatedAt: archived.GetCreatedAt(),
+	}
+	dir := filepath.Join(s.root, req.GetName())
+	for _, ca := range s.archivers {
+		archives, errImport := ca.archiver.Import(filepath.Join(dir, ca.dir), req.GetGroups())
+		if errImport != nil {
+			return nil, errImport
+		}
+		snp.Archives = append(snp.Archives, s.fill(archives, ca.catalog)...)
+	}
+	s.l.Info().Str("name", snp.GetName()).Int("archives", len(snp.GetArchives())).Msg("imported the snapshot")
+	return snp, nil
+}
+
+func (s *service) read(name string) (*databasev1.Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(s.root, name, metadataFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WithMessage(errSnapshotNotExist, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	snp := &databasev1.Snapshot{}
+	if err = protojson.Unmarshal(data, snp); err != nil {
+		return nil, errors.WithMessagef(err, "cannot parse the snapshot %s", name)
+	}
+	return snp, nil
+}
+
+func (s *service) fill(archives []*databasev1.SnapshotArchive, catalog commonv1.Catalog) []*databasev1.SnapshotArchive {
+	for _, a := range archives {
+		a.Node = s.nodeID
+		a.Catalog = catalog
+	}
+	return archives
+}
+
+// validName rejects the names escaping the snapshot root.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
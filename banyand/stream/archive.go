@@ -0,0 +1,169 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/pkg/index"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+var _ storage.Archiver = (*tsTable)(nil)
+
+// Archive links the flushed parts and the deletions into the dir, and writes the snapshot listing the parts.
+// The element index is archived as well. The in-memory parts are left out, which are flushed in the flush timeout.
+func (tst *tsTable) Archive(dir string) (int, uint64, error) {
+	tst.fileSystem.MkdirIfNotExist(dir, dirPermission)
+	var size uint64
+	var partNames []string
+	snp := tst.currentSnapshot()
+	if snp != nil {
+		defer snp.decRef()
+		for _, pw := range snp.parts {
+			if pw.mp != nil {
+				continue
+			}
+			n, err := tst.fileSystem.CreateHardLink(partPath(tst.root, pw.ID()), partPath(dir, pw.ID()), nil)
+			if err != nil {
+				return 0, 0, err
+			}
+			size += n
+			partNames = append(partNames, partName(pw.ID()))
+		}
+	}
+	for _, name := range []string{tombstonesFilename, deletionsFilename} {
+		if _, err := os.Stat(filepath.Join(tst.root, name)); err != nil {
+			continue
+		}
+		n, err := tst.fileSystem.CreateHardLink(filepath.Join(tst.root, name), filepath.Join(dir, name), nil)
+		if err != nil {
+			return 0, 0, err
+		}
+		size += n
+	}
+	if archiver, ok := tst.index.store.(index.Archiver); ok {
+		if err := archiver.Archive(filepath.Join(dir, elementIndexFilename)); err != nil {
+			return 0, 0, fmt.Errorf("cannot archive the element index: %w", err)
+		}
+	}
+	if len(partNames) == 0 {
+		return 0, size, nil
+	}
+	data, err := json.Marshal(partNames)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err = tst.fileSystem.Write(data, filepath.Join(dir, snapshotName(snp.epoch)), filePermission); err != nil {
+		return 0, 0, err
+	}
+	return len(partNames), size, nil
+}
+
+// Archive writes the flushed data of the groups into the dir, one subdirectory for each group.
+func (s *service) Archive(dir string, groups []string) ([]*databasev1.SnapshotArchive, error) {
+	var result []*databasev1.SnapshotArchive
+	for _, g := range s.schemaRepo.LoadAllGroups() {
+		name := g.GetSchema().GetMetadata().GetName()
+		if len(groups) > 0 && !slices.Contains(groups, name) {
+			continue
+		}
+		tsdb, ok := g.SupplyTSDB().(storage.TSDB[*tsTable, option])
+		if !ok {
+			continue
+		}
+		// the series of the streams are indexed by their entities only
+		stats, err := tsdb.Archive(filepath.Join(dir, name), func(uint32) databasev1.IndexRule_Analyzer {
+			return databasev1.IndexRule_ANALYZER_UNSPECIFIED
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot archive group %s: %w", name, err)
+		}
+		result = append(result, stats.ToArchive(name))
+	}
+	return result, nil
+}
+
+// Import registers the segments of the groups archived in the dir, all archived groups if groups is empty.
+func (s *service) Import(dir string, groups []string) ([]*databasev1.SnapshotArchive, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result []*databasev1.SnapshotArchive
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || (len(groups) > 0 && !slices.Contains(groups, name)) {
+			continue
+		}
+		g, ok := s.schemaRepo.LoadGroup(name)
+		if !ok {
+			return nil, fmt.Errorf("group %s doesn't exist, create it before importing", name)
+		}
+		tsdb, ok := g.SupplyTSDB().(storage.TSDB[*tsTable, option])
+		if !ok {
+			return nil, fmt.Errorf("group %s isn't a stream group", name)
+		}
+		stats, errImport := tsdb.Import(filepath.Join(dir, name))
+		if errImport != nil {
+			return nil, fmt.Errorf("cannot import group %s: %w", name, errImport)
+		}
+		result = append(result, stats.ToArchive(name))
+	}
+	return result, nil
+}
+
+// ArchiveShard writes the flushed data of a shard of the group overlapping the time range into the dir.
+func (s *service) ArchiveShard(dir, group string, shardID common.ShardID, timeRange timestamp.TimeRange) (*databasev1.SnapshotArchive, error) {
+	tsdb, err := s.schemaRepo.loadTSDB(group)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := tsdb.ArchiveShard(dir, shardID, timeRange, func(uint32) databasev1.IndexRule_Analyzer {
+		return databasev1.IndexRule_ANALYZER_UNSPECIFIED
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot archive shard %d of group %s: %w", shardID, group, err)
+	}
+	return stats.ToArchive(group), nil
+}
+
+// Merge imports the data archived by ArchiveShard on another node into the group.
+// The elements aren't deduplicated, so the archived segments the group has are skipped.
+func (s *service) Merge(dir, group string) (*databasev1.SnapshotArchive, error) {
+	tsdb, err := s.schemaRepo.loadTSDB(group)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := tsdb.Merge(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot merge the archive into group %s: %w", group, err)
+	}
+	return stats.ToArchive(group), nil
+}
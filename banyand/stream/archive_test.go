@@ -0,0 +1,79 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/test/flags"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func Test_tsTable_Archive(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	fileSystem := fs.NewLocalFileSystem()
+	openTable := func(root string, flushTimeout time.Duration) *tsTable {
+		tst, err := newTSTable(fileSystem, root, common.Position{Database: "default"}, logger.GetLogger("test"),
+			timestamp.TimeRange{}, option{flushTimeout: flushTimeout, mergePolicy: newDefaultMergePolicyForTesting()})
+		require.NoError(t, err)
+		return tst
+	}
+	root := filepath.Join(tmpPath, "root")
+	tst := openTable(root, defaultFlushTimeout)
+	require.NoError(t, tst.addElements(esTS1))
+	require.Eventually(t, func() bool {
+		snp := tst.currentSnapshot()
+		if snp == nil {
+			return false
+		}
+		defer snp.decRef()
+		return snp.memPartCount() == 0
+	}, flags.EventuallyTimeout, 100*time.Millisecond, "the elements are flushed")
+	require.NoError(t, tst.Close())
+	tst = openTable(root, time.Hour)
+	require.NoError(t, tst.addElements(esTS2))
+
+	archived := filepath.Join(tmpPath, "archived")
+	parts, size, err := tst.Archive(archived)
+	require.NoError(t, err)
+	assert.Equal(t, 1, parts, "the in-memory part is left out")
+	assert.Greater(t, size, uint64(0))
+	require.NoError(t, tst.Close())
+	fileSystem.MustRMAll(root)
+
+	tst = openTable(archived, time.Hour)
+	defer tst.Close()
+	e, _, err := tst.getElement(2, 1, nil)
+	require.NoError(t, err, "the archived part outlives the table it's linked from")
+	assert.Equal(t, "21", e.elementID)
+	_, _, err = tst.getElement(2, 2, nil)
+	require.Error(t, err)
+	_, err = os.Stat(filepath.Join(archived, elementIndexFilename))
+	require.NoError(t, err, "the element index is archived")
+}
@@ -18,13 +18,19 @@
 package stream
 
 import (
+	"context"
+	"fmt"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/pkg/bytes"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/intern"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 	"github.com/apache/skywalking-banyandb/pkg/timestamp"
@@ -38,7 +44,11 @@ type block struct {
 
 func (b *block) reset() {
 	b.timestamps = b.timestamps[:0]
-	b.elementIDs = b.elementIDs[:0]
+	elementIDs := b.elementIDs
+	for i := range elementIDs {
+		elementIDs[i] = ""
+	}
+	b.elementIDs = elementIDs[:0]
 
 	tff := b.tagFamilies
 	for i := range tff {
@@ -57,21 +67,41 @@ func (b *block) mustInitFromElements(timestamps []int64, elementIDs []string, ta
 		logger.Panicf("the number of timestamps %d must match the number of tagFamilies %d", size, len(tagFamilies))
 	}
 
-	assertTimestampsSorted(timestamps)
+	sortByTimestamp(timestamps, elementIDs, tagFamilies)
 	b.timestamps = append(b.timestamps, timestamps...)
 	b.elementIDs = append(b.elementIDs, elementIDs...)
 	b.mustInitFromTags(tagFamilies)
 }
 
-func assertTimestampsSorted(timestamps []int64) {
-	for i := range timestamps {
-		if i > 0 && timestamps[i-1] > timestamps[i] {
-			logger.Panicf("log entries must be sorted by timestamp; got the previous entry with bigger timestamp %d than the current entry with timestamp %d",
-				timestamps[i-1], timestamps[i])
-		}
+// sortByTimestamp sorts the elements of a series by timestamp if they are out of order.
+// The sort is stable, so the elements with the same timestamp keep their arrival order.
+func sortByTimestamp(timestamps []int64, elementIDs []string, tagFamilies [][]tagValues) {
+	es := elementsByTimestamp{timestamps: timestamps, elementIDs: elementIDs, tagFamilies: tagFamilies}
+	if !sort.IsSorted(es) {
+		sort.Stable(es)
 	}
 }
 
+type elementsByTimestamp struct {
+	timestamps  []int64
+	elementIDs  []string
+	tagFamilies [][]tagValues
+}
+
+func (e elementsByTimestamp) Len() int {
+	return len(e.timestamps)
+}
+
+func (e elementsByTimestamp) Less(i, j int) bool {
+	return e.timestamps[i] < e.timestamps[j]
+}
+
+func (e elementsByTimestamp) Swap(i, j int) {
+	e.timestamps[i], e.timestamps[j] = e.timestamps[j], e.timestamps[i]
+	e.elementIDs[i], e.elementIDs[j] = e.elementIDs[j], e.elementIDs[i]
+	e.tagFamilies[i], e.tagFamilies[j] = e.tagFamilies[j], e.tagFamilies[i]
+}
+
 func (b *block) mustInitFromTags(tagFamilies [][]tagValues) {
 	elementsLen := len(tagFamilies)
 	if elementsLen == 0 {
@@ -83,6 +113,9 @@ func (b *block) mustInitFromTags(tagFamilies [][]tagValues) {
 }
 
 func (b *block) processTagFamilies(tff []tagValues, i int, elementsLen int) {
+	if err := checkDuplicatedTags(tff); err != nil {
+		logger.Panicf("the element %d of the block is invalid: %v", i, err)
+	}
 	tagFamilies := b.resizeTagFamilies(len(tff))
 	for j, tf := range tff {
 		tagFamilies[j].name = tf.tag
@@ -96,6 +129,7 @@ func (b *block) processTags(tf tagValues, tagFamilyIdx, i int, elementsLen int)
 		tags[j].name = t.tag
 		tags[j].resizeValues(elementsLen)
 		tags[j].valueType = t.valueType
+		tags[j].bloomFilter = tags[j].bloomFilter || t.bloomFilter
 		tags[j].values[i] = t.marshal()
 	}
 }
@@ -159,9 +193,10 @@ func (b *block) marshalTagFamily(tf tagFamily, bm *blockMetadata, ww *writers) {
 	cfm := generateTagFamilyMetadata()
 	cmm := cfm.resizeTagMetadata(len(cc))
 	for i := range cc {
-		cc[i].mustWriteTo(&cmm[i], w)
+		cc[i].mustWriteTo(&cmm[i], w, ww.tagCodec)
+		bm.tagStats = appendTagStats(bm.tagStats, tf.name, &cc[i])
 	}
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(0)
 	defer bigValuePool.Release(bb)
 	bb.Buf = cfm.marshal(bb.Buf)
 	releaseTagFamilyMetadata(cfm)
@@ -176,116 +211,396 @@ func (b *block) marshalTagFamily(tf tagFamily, bm *blockMetadata, ww *writers) {
 
 func (b *block) unmarshalTagFamily(decoder *encoding.BytesBlockDecoder, tfIndex int, name string,
 	tagFamilyMetadataBlock *dataBlock, tagProjection []string, metaReader, valueReader fs.Reader,
-) {
+) error {
 	if len(tagProjection) < 1 {
-		return
+		return nil
 	}
-	bb := bigValuePool.Generate()
-	bb.Buf = bytes.ResizeExact(bb.Buf, int(tagFamilyMetadataBlock.size))
-	fs.MustReadData(metaReader, int64(tagFamilyMetadataBlock.offset), bb.Buf)
 	tfm := generateTagFamilyMetadata()
 	defer releaseTagFamilyMetadata(tfm)
-	err := tfm.unmarshal(bb.Buf)
-	if err != nil {
-		logger.Panicf("%s: cannot unmarshal tagFamilyMetadata: %v", metaReader.Path(), err)
-	}
-	bigValuePool.Release(bb)
-	b.tagFamilies[tfIndex].name = name
-	if len(tagProjection) < 1 {
-		return
+	if err := readTagFamilyMetadata(tfm, tagFamilyMetadataBlock, metaReader); err != nil {
+		return err
 	}
+	b.tagFamilies[tfIndex].name = intern.String(name)
 	cc := b.tagFamilies[tfIndex].resizeTags(len(tagProjection))
 	for j := range tagProjection {
 		for i := range tfm.tagMetadata {
 			if tagProjection[j] == tfm.tagMetadata[i].name {
-				cc[j].mustReadValues(decoder, valueReader, tfm.tagMetadata[i], uint64(b.Len()))
+				if err := cc[j].readValues(decoder, valueReader, tfm.tagMetadata[i], uint64(b.Len())); err != nil {
+					return err
+				}
 				break
 			}
 		}
 	}
+	return nil
+}
+
+// readTagFamilyMetadata reads the metadata of a tag family in the block from metaReader into tfm.
+func readTagFamilyMetadata(tfm *tagFamilyMetadata, tagFamilyMetadataBlock *dataBlock, metaReader fs.Reader) error {
+	bb := bigValuePool.Acquire(int(tagFamilyMetadataBlock.size))
+	defer bigValuePool.Release(bb)
+	bb.Buf = bytes.ResizeExact(bb.Buf, int(tagFamilyMetadataBlock.size))
+	if err := fs.ReadData(metaReader, int64(tagFamilyMetadataBlock.offset), bb.Buf); err != nil {
+		return fmt.Errorf("cannot read tagFamilyMetadata: %w", err)
+	}
+	if err := tfm.unmarshal(bb.Buf); err != nil {
+		return fmt.Errorf("%s: cannot unmarshal tagFamilyMetadata: %w", metaReader.Path(), err)
+	}
+	return nil
 }
 
+// unmarshalTagFamilyFromSeqReaders decodes the tags in tagProjection, or all of them if tagProjection is nil.
+// The values of the tags left out are skipped once the next tag is read.
 func (b *block) unmarshalTagFamilyFromSeqReaders(decoder *encoding.BytesBlockDecoder, tfIndex int, name string,
-	columnFamilyMetadataBlock *dataBlock, metaReader, valueReader *seqReader,
-) {
-	if columnFamilyMetadataBlock.offset != metaReader.bytesRead {
-		logger.Panicf("offset %d must be equal to bytesRead %d", columnFamilyMetadataBlock.offset, metaReader.bytesRead)
+	columnFamilyMetadataBlock *dataBlock, metaReader, valueReader *seqReader, tagProjection []string,
+) error {
+	if err := metaReader.align(columnFamilyMetadataBlock.offset); err != nil {
+		return err
 	}
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(int(columnFamilyMetadataBlock.size))
 	bb.Buf = bytes.ResizeExact(bb.Buf, int(columnFamilyMetadataBlock.size))
-	metaReader.mustReadFull(bb.Buf)
+	if err := metaReader.readFull(bb.Buf); err != nil {
+		bigValuePool.Release(bb)
+		return err
+	}
 	tfm := generateTagFamilyMetadata()
 	defer releaseTagFamilyMetadata(tfm)
 	err := tfm.unmarshal(bb.Buf)
+	bigValuePool.Release(bb)
 	if err != nil {
-		logger.Panicf("%s: cannot unmarshal columnFamilyMetadata: %v", metaReader.Path(), err)
+		return fmt.Errorf("%s: cannot unmarshal columnFamilyMetadata: %w", metaReader.Path(), err)
 	}
-	bigValuePool.Release(bb)
-	b.tagFamilies[tfIndex].name = name
+	b.tagFamilies[tfIndex].name = intern.String(name)
 
 	cc := b.tagFamilies[tfIndex].resizeTags(len(tfm.tagMetadata))
+	n := 0
 	for i := range tfm.tagMetadata {
-		cc[i].mustSeqReadValues(decoder, valueReader, tfm.tagMetadata[i], uint64(b.Len()))
+		if tagProjection != nil && !slices.Contains(tagProjection, tfm.tagMetadata[i].name) {
+			valueReader.resync = true
+			continue
+		}
+		if err := cc[n].seqReadValues(decoder, valueReader, tfm.tagMetadata[i], uint64(b.Len())); err != nil {
+			return err
+		}
+		n++
+	}
+	for i := n; i < len(cc); i++ {
+		cc[i].reset()
 	}
+	b.tagFamilies[tfIndex].tags = cc[:n]
+	// The parts written before the duplicated tags were rejected might have them.
+	// The queries read the first one, so the merge keeps it as well.
+	if n := b.tagFamilies[tfIndex].dropDuplicatedTags(); n > 0 {
+		logger.GetLogger("stream").Warn().Str("path", metaReader.Path()).Str("tagFamily", name).Int("dropped", n).
+			Msg("the duplicated tags are dropped by the merge")
+	}
+	return nil
 }
 
+// timestampSizeBytes is the size of a timestamp in the size model of a block.
+const timestampSizeBytes = 8
+
+// uncompressedSizeBytes estimates the size of the block before compression, which is the size model
+// bounding the blocks by maxUncompressedBlockSize.
+//
+// Every element is charged its timestamp, and every non-empty tag value is charged the names of
+// its tag family and its tag besides its bytes, as if the elements were stored row by row.
+// The null values, which are empty, are free. The size model has to be kept in line with
+// what mustWriteTo writes once the format of the tag values changes.
 func (b *block) uncompressedSizeBytes() uint64 {
-	elementsCount := uint64(b.Len())
+	n := uint64(b.Len()) * timestampSizeBytes
+	for i := range b.tagFamilies {
+		tf := &b.tagFamilies[i]
+		for j := range tf.tags {
+			n += tagValuesSizeBytes(tf.name, tf.tags[j].name, tf.tags[j].values)
+		}
+	}
+	return n
+}
 
-	n := elementsCount * 8
+// tagValuesSizeBytes returns the size of the values of a tag in the size model of a block.
+func tagValuesSizeBytes(tagFamilyName, tagName string, values [][]byte) uint64 {
+	nameLen := uint64(len(tagFamilyName) + len(tagName))
+	var n uint64
+	for _, v := range values {
+		if len(v) > 0 {
+			n += nameLen + uint64(len(v))
+		}
+	}
+	return n
+}
 
-	tff := b.tagFamilies
-	for i := range tff {
-		tf := tff[i]
-		nameLen := uint64(len(tf.name))
-		for _, c := range tf.tags {
-			nameLen += uint64(len(c.name))
-			for _, v := range c.values {
-				if len(v) > 0 {
-					n += nameLen + uint64(len(v))
+// readFrom decodes the elementIDs and the tag values into the buffer of decoder.
+// They are valid until the decoder is reset.
+// It returns an error if the data of the block is corrupted, then the block is left partially decoded.
+func (b *block) readFrom(decoder *encoding.BytesBlockDecoder, p *part, bm blockMetadata) error {
+	b.reset()
+
+	// the timestamps, the elementIDs and the metadata of the tag families are read in a batch,
+	// then the values of the tags located by the metadata in another one.
+	br := generateBatchReads()
+	defer releaseBatchReads(br)
+	timestamps := br.add(p.timestamps, bm.timestamps.dataBlock)
+	elementIDs := br.add(p.elementIDs, bm.elementIDs.dataBlock)
+	tff := b.resizeTagFamilies(len(bm.tagProjection))
+	metadata := make([]int, len(bm.tagProjection))
+	for i := range bm.tagProjection {
+		metadata[i] = -1
+		block, ok := bm.tagFamilies[bm.tagProjection[i].Family]
+		if !ok || len(bm.tagProjection[i].Names) < 1 {
+			continue
+		}
+		metadata[i] = br.add(p.tagFamilyMetadata[bm.tagProjection[i].Family], *block)
+	}
+	if err := br.read(); err != nil {
+		return fmt.Errorf("cannot read the block: %w", err)
+	}
+	var err error
+	if b.timestamps, err = decodeTimestamps(b.timestamps, &bm.timestamps, int(bm.count), br.data(timestamps), p.timestamps.Path()); err != nil {
+		return err
+	}
+	if b.elementIDs, err = decodeElementIDs(b.elementIDs, decoder, int(bm.count), br.data(elementIDs), p.elementIDs.Path()); err != nil {
+		return err
+	}
+	tfms := make([]*tagFamilyMetadata, 0, len(bm.tagProjection))
+	defer func() {
+		for _, tfm := range tfms {
+			releaseTagFamilyMetadata(tfm)
+		}
+	}()
+	var values []tagValuesRead
+	for i := range bm.tagProjection {
+		if metadata[i] < 0 {
+			continue
+		}
+		name := bm.tagProjection[i].Family
+		metaReader := p.tagFamilyMetadata[name]
+		tfm := generateTagFamilyMetadata()
+		tfms = append(tfms, tfm)
+		if err = tfm.unmarshal(br.data(metadata[i])); err != nil {
+			return fmt.Errorf("cannot decode tag family %q: %s: cannot unmarshal tagFamilyMetadata: %w", name, metaReader.Path(), err)
+		}
+		tff[i].name = intern.String(name)
+		cc := tff[i].resizeTags(len(bm.tagProjection[i].Names))
+		for j, tagName := range bm.tagProjection[i].Names {
+			for k := range tfm.tagMetadata {
+				if tagName != tfm.tagMetadata[k].name {
+					continue
 				}
+				values = append(values, tagValuesRead{family: name, tag: &cc[j], metadata: tfm.tagMetadata[k]})
+				break
 			}
 		}
 	}
-	return n
+	br.reset()
+	for i := range values {
+		valueReader := p.tagFamilies[values[i].family]
+		if values[i].metadata.size > maxValuesBlockSize {
+			return fmt.Errorf("cannot decode tag family %q: %s: block size cannot exceed %d bytes; got %d bytes",
+				values[i].family, valueReader.Path(), maxValuesBlockSize, values[i].metadata.size)
+		}
+		values[i].req = br.add(valueReader, values[i].metadata.dataBlock)
+	}
+	if err = br.read(); err != nil {
+		return fmt.Errorf("cannot read the tag values of the block: %w", err)
+	}
+	for i := range values {
+		if err = values[i].tag.decodeValues(decoder, br.data(values[i].req), values[i].metadata, uint64(b.Len()),
+			p.tagFamilies[values[i].family].Path()); err != nil {
+			return fmt.Errorf("cannot decode tag family %q: %w", values[i].family, err)
+		}
+	}
+	return nil
+}
+
+// tagValuesRead locates the values of a tag read by readFrom.
+type tagValuesRead struct {
+	tag      *tag
+	family   string
+	metadata tagMetadata
+	req      int
+}
+
+// batchReads collects the reads submitted in a batch, whose buffers are from bigValuePool.
+type batchReads struct {
+	reqs []fs.ReadRequest
+	bufs []*bytes.Buffer
+}
+
+func (br *batchReads) reset() {
+	for i := range br.bufs {
+		bigValuePool.Release(br.bufs[i])
+		br.bufs[i] = nil
+	}
+	br.bufs = br.bufs[:0]
+	for i := range br.reqs {
+		br.reqs[i] = fs.ReadRequest{}
+	}
+	br.reqs = br.reqs[:0]
+}
+
+// add appends the read of db in r, and returns its index.
+func (br *batchReads) add(r fs.Reader, db dataBlock) int {
+	bb := bigValuePool.Acquire(int(db.size))
+	bb.Buf = bytes.ResizeExact(bb.Buf, int(db.size))
+	br.bufs = append(br.bufs, bb)
+	br.reqs = append(br.reqs, fs.ReadRequest{Reader: r, Buf: bb.Buf, Offset: int64(db.offset)})
+	return len(br.reqs) - 1
 }
 
-func (b *block) mustReadFrom(decoder *encoding.BytesBlockDecoder, p *part, bm blockMetadata) {
+func (br *batchReads) read() error {
+	return fs.ReadDataBatch(br.reqs)
+}
+
+func (br *batchReads) data(i int) []byte {
+	return br.reqs[i].Buf
+}
+
+func generateBatchReads() *batchReads {
+	if v := batchReadsPool.Get(); v != nil {
+		return v.(*batchReads)
+	}
+	return &batchReads{}
+}
+
+func releaseBatchReads(br *batchReads) {
+	br.reset()
+	batchReadsPool.Put(br)
+}
+
+var batchReadsPool sync.Pool
+
+// readFromInParallel is readFrom decoding the tag families in the goroutines the workers allow.
+// A tag family decoded in a goroutine refers to a decoder from the pool, which is appended to arenas.
+// The tag families left once no worker is available are decoded by the caller into decoder.
+// The arenas are returned even if it fails, so that the caller can release them.
+func (b *block) readFromInParallel(decoder *encoding.BytesBlockDecoder, arenas []*encoding.BytesBlockDecoder,
+	p *part, bm blockMetadata, workers workerBudget,
+) ([]*encoding.BytesBlockDecoder, error) {
 	b.reset()
 
-	b.timestamps = mustReadTimestampsFrom(b.timestamps, &bm.timestamps, int(bm.count), p.timestamps)
-	b.elementIDs = mustReadElementIDsFrom(b.elementIDs, &bm.elementIDs, int(bm.count), p.elementIDs)
+	var err error
+	if b.timestamps, err = readTimestampsFrom(b.timestamps, &bm.timestamps, int(bm.count), p.timestamps); err != nil {
+		return arenas, err
+	}
+	if b.elementIDs, err = decodeElementIDsFrom(b.elementIDs, decoder, &bm.elementIDs, int(bm.count), p.elementIDs); err != nil {
+		return arenas, err
+	}
 
 	_ = b.resizeTagFamilies(len(bm.tagProjection))
+	var wg sync.WaitGroup
+	// the goroutines mustn't outlive the block even if the caller panics
+	defer wg.Wait()
+	var errOnce, panicOnce sync.Once
+	var firstErr error
+	var panicked any
 	for i := range bm.tagProjection {
 		name := bm.tagProjection[i].Family
 		block, ok := bm.tagFamilies[name]
 		if !ok {
 			continue
 		}
-		b.unmarshalTagFamily(decoder, i, name, block,
-			bm.tagProjection[i].Names, p.tagFamilyMetadata[name],
-			p.tagFamilies[name])
+		if !workers.tryAcquire() {
+			if err = b.unmarshalTagFamily(decoder, i, name, block,
+				bm.tagProjection[i].Names, p.tagFamilyMetadata[name],
+				p.tagFamilies[name]); err != nil {
+				errOnce.Do(func() { firstErr = fmt.Errorf("cannot decode tag family %q: %w", name, err) })
+			}
+			continue
+		}
+		arena := generateColumnValuesDecoder()
+		arenas = append(arenas, arena)
+		wg.Add(1)
+		go func(i int, name string, block *dataBlock) {
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() { panicked = r })
+				}
+				workers.release()
+				wg.Done()
+			}()
+			if err := b.unmarshalTagFamily(arena, i, name, block,
+				bm.tagProjection[i].Names, p.tagFamilyMetadata[name],
+				p.tagFamilies[name]); err != nil {
+				errOnce.Do(func() { firstErr = fmt.Errorf("cannot decode tag family %q: %w", name, err) })
+			}
+		}(i, name, block)
 	}
+	wg.Wait()
+	if panicked != nil {
+		panic(panicked)
+	}
+	return arenas, firstErr
+}
+
+func (b *block) seqReadFrom(decoder *encoding.BytesBlockDecoder, seqReaders *seqReaders, bm blockMetadata) error {
+	return b.seqReadFromProjection(decoder, seqReaders, bm, nil)
 }
 
-func (b *block) mustSeqReadFrom(decoder *encoding.BytesBlockDecoder, seqReaders *seqReaders, bm blockMetadata) {
+// seqReadFromProjection is seqReadFrom decoding only the tag families and the tags in tagProjection,
+// or all of them if tagProjection is nil. The tag families are in the order of their names,
+// and the tags in the order they are written.
+//
+// The readers of the data left out are marked to resync, so that they skip it once they read the next block,
+// and they are never read if the data is left out of all the blocks.
+func (b *block) seqReadFromProjection(decoder *encoding.BytesBlockDecoder, seqReaders *seqReaders, bm blockMetadata,
+	tagProjection []pbv1.TagProjection,
+) error {
 	b.reset()
 
-	b.timestamps = mustSeqReadTimestampsFrom(b.timestamps, &bm.timestamps, int(bm.count), &seqReaders.timestamps)
-	b.elementIDs = mustSeqReadElementIDsFrom(b.elementIDs, &bm.elementIDs, int(bm.count), &seqReaders.elementIDs)
+	var err error
+	if b.timestamps, err = seqReadTimestampsFrom(b.timestamps, &bm.timestamps, int(bm.count), &seqReaders.timestamps); err != nil {
+		return err
+	}
+	if b.elementIDs, err = seqReadElementIDsFrom(b.elementIDs, &bm.elementIDs, int(bm.count), &seqReaders.elementIDs); err != nil {
+		return err
+	}
 
-	_ = b.resizeTagFamilies(len(bm.tagFamilies))
 	keys := make([]string, 0, len(bm.tagFamilies))
 	for k := range bm.tagFamilies {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
+	var projected [][]string
+	if tagProjection != nil {
+		projected = make([][]string, 0, len(keys))
+		n := 0
+		for _, name := range keys {
+			names, ok := findTagProjection(tagProjection, name)
+			if !ok {
+				seqReaders.tagFamilyMetadata[name].resync = true
+				seqReaders.tagFamilies[name].resync = true
+				continue
+			}
+			keys[n] = name
+			projected = append(projected, names)
+			n++
+		}
+		keys = keys[:n]
+	}
+	_ = b.resizeTagFamilies(len(keys))
 	for i, name := range keys {
+		var names []string
+		if projected != nil {
+			names = projected[i]
+		}
 		block := bm.tagFamilies[name]
-		b.unmarshalTagFamilyFromSeqReaders(decoder, i, name, block,
-			seqReaders.tagFamilyMetadata[name], seqReaders.tagFamilies[name])
+		if err = b.unmarshalTagFamilyFromSeqReaders(decoder, i, name, block,
+			seqReaders.tagFamilyMetadata[name], seqReaders.tagFamilies[name], names); err != nil {
+			return fmt.Errorf("cannot decode tag family %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// findTagProjection returns the names of the tags projected in the tag family.
+func findTagProjection(tagProjection []pbv1.TagProjection, tagFamily string) ([]string, bool) {
+	for i := range tagProjection {
+		if tagProjection[i].Family == tagFamily {
+			return tagProjection[i].Names, true
+		}
 	}
+	return nil, false
 }
 
 // For testing purpose only.
@@ -298,7 +613,7 @@ func (b *block) sortTagFamilies() {
 func mustWriteTimestampsTo(tm *timestampsMetadata, timestamps []int64, timestampsWriter *writer) {
 	tm.reset()
 
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(len(timestamps) * 8)
 	defer bigValuePool.Release(bb)
 	bb.Buf, tm.encodeType, tm.min = encoding.Int64ListToBytes(bb.Buf[:0], timestamps)
 	if len(bb.Buf) > maxTimestampsBlockSize {
@@ -310,23 +625,29 @@ func mustWriteTimestampsTo(tm *timestampsMetadata, timestamps []int64, timestamp
 	timestampsWriter.MustWrite(bb.Buf)
 }
 
-func mustReadTimestampsFrom(dst []int64, tm *timestampsMetadata, count int, reader fs.Reader) []int64 {
-	bb := bigValuePool.Generate()
+func readTimestampsFrom(dst []int64, tm *timestampsMetadata, count int, reader fs.Reader) ([]int64, error) {
+	bb := bigValuePool.Acquire(int(tm.size))
 	defer bigValuePool.Release(bb)
 	bb.Buf = bytes.ResizeExact(bb.Buf, int(tm.size))
-	fs.MustReadData(reader, int64(tm.offset), bb.Buf)
+	if err := fs.ReadData(reader, int64(tm.offset), bb.Buf); err != nil {
+		return dst, fmt.Errorf("cannot read timestamps: %w", err)
+	}
+	return decodeTimestamps(dst, tm, count, bb.Buf, reader.Path())
+}
+
+func decodeTimestamps(dst []int64, tm *timestampsMetadata, count int, data []byte, path string) ([]int64, error) {
 	var err error
-	dst, err = encoding.BytesToInt64List(dst, bb.Buf, tm.encodeType, tm.min, count)
+	dst, err = encoding.BytesToInt64List(dst, data, tm.encodeType, tm.min, count)
 	if err != nil {
-		logger.Panicf("%s: cannot unmarshal timestamps: %v", reader.Path(), err)
+		return dst, fmt.Errorf("%s: cannot unmarshal timestamps: %w", path, err)
 	}
-	return dst
+	return dst, nil
 }
 
 func mustWriteElementIDsTo(em *elementIDsMetadata, elementIDs []string, elementIDsWriter *writer) {
 	em.reset()
 
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(0)
 	defer bigValuePool.Release(bb)
 	elementIDsByteSlice := make([][]byte, len(elementIDs))
 	for i, elementID := range elementIDs {
@@ -342,57 +663,111 @@ func mustWriteElementIDsTo(em *elementIDsMetadata, elementIDs []string, elementI
 	elementIDsWriter.MustWrite(bb.Buf)
 }
 
-func mustReadElementIDsFrom(dst []string, em *elementIDsMetadata, count int, reader fs.Reader) []string {
-	bb := bigValuePool.Generate()
+func readElementIDsFrom(dst []string, em *elementIDsMetadata, count int, reader fs.Reader) ([]string, error) {
+	bb := bigValuePool.Acquire(int(em.size))
 	defer bigValuePool.Release(bb)
 	bb.Buf = bytes.ResizeExact(bb.Buf, int(em.size))
-	fs.MustReadData(reader, int64(em.offset), bb.Buf)
+	if err := fs.ReadData(reader, int64(em.offset), bb.Buf); err != nil {
+		return dst, fmt.Errorf("cannot read elementIDs: %w", err)
+	}
 	decoder := encoding.BytesBlockDecoder{}
 	var elementIDsByteSlice [][]byte
 	elementIDsByteSlice, err := decoder.Decode(elementIDsByteSlice, bb.Buf, uint64(count))
 	if err != nil {
-		logger.Panicf("%s: cannot unmarshal elementIDs: %v", reader.Path(), err)
+		return dst, fmt.Errorf("%s: cannot unmarshal elementIDs: %w", reader.Path(), err)
 	}
 	for _, elementID := range elementIDsByteSlice {
 		dst = append(dst, string(elementID))
 	}
-	return dst
+	return dst, nil
+}
+
+// decodeElementIDsFrom is like readElementIDsFrom, but the elementIDs refer to the buffer of decoder
+// instead of being allocated one by one.
+func decodeElementIDsFrom(dst []string, decoder *encoding.BytesBlockDecoder, em *elementIDsMetadata, count int, reader fs.Reader) ([]string, error) {
+	bb := bigValuePool.Acquire(int(em.size))
+	defer bigValuePool.Release(bb)
+	bb.Buf = bytes.ResizeExact(bb.Buf, int(em.size))
+	if err := fs.ReadData(reader, int64(em.offset), bb.Buf); err != nil {
+		return dst, fmt.Errorf("cannot read elementIDs: %w", err)
+	}
+	return decodeElementIDs(dst, decoder, count, bb.Buf, reader.Path())
+}
+
+func decodeElementIDs(dst []string, decoder *encoding.BytesBlockDecoder, count int, data []byte, path string) ([]string, error) {
+	elementIDsByteSlice := generateElementIDsByteSlice()
+	defer releaseElementIDsByteSlice(elementIDsByteSlice)
+	var err error
+	elementIDsByteSlice.l, err = decoder.Decode(elementIDsByteSlice.l[:0], data, uint64(count))
+	if err != nil {
+		return dst, fmt.Errorf("%s: cannot unmarshal elementIDs: %w", path, err)
+	}
+	for _, elementID := range elementIDsByteSlice.l {
+		dst = append(dst, convert.BytesToString(elementID))
+	}
+	return dst, nil
+}
+
+type elementIDsByteSlice struct {
+	l [][]byte
+}
+
+var elementIDsByteSlicePool sync.Pool
+
+func generateElementIDsByteSlice() *elementIDsByteSlice {
+	v := elementIDsByteSlicePool.Get()
+	if v == nil {
+		return &elementIDsByteSlice{}
+	}
+	return v.(*elementIDsByteSlice)
 }
 
-func mustSeqReadTimestampsFrom(dst []int64, tm *timestampsMetadata, count int, reader *seqReader) []int64 {
-	if tm.offset != reader.bytesRead {
-		logger.Panicf("offset %d must be equal to bytesRead %d", tm.offset, reader.bytesRead)
+func releaseElementIDsByteSlice(s *elementIDsByteSlice) {
+	for i := range s.l {
+		s.l[i] = nil
 	}
-	bb := bigValuePool.Generate()
+	s.l = s.l[:0]
+	elementIDsByteSlicePool.Put(s)
+}
+
+func seqReadTimestampsFrom(dst []int64, tm *timestampsMetadata, count int, reader *seqReader) ([]int64, error) {
+	if err := reader.align(tm.offset); err != nil {
+		return dst, err
+	}
+	bb := bigValuePool.Acquire(int(tm.size))
 	defer bigValuePool.Release(bb)
 	bb.Buf = bytes.ResizeExact(bb.Buf, int(tm.size))
-	reader.mustReadFull(bb.Buf)
+	if err := reader.readFull(bb.Buf); err != nil {
+		return dst, err
+	}
 	var err error
 	dst, err = encoding.BytesToInt64List(dst, bb.Buf, tm.encodeType, tm.min, count)
 	if err != nil {
-		logger.Panicf("%s: cannot unmarshal timestamps: %v", reader.Path(), err)
+		return dst, fmt.Errorf("%s: cannot unmarshal timestamps: %w", reader.Path(), err)
 	}
-	return dst
+	return dst, nil
 }
 
-func mustSeqReadElementIDsFrom(dst []string, em *elementIDsMetadata, count int, reader *seqReader) []string {
-	if em.offset != reader.bytesRead {
-		logger.Panicf("offset %d must be equal to bytesRead %d", em.offset, reader.bytesRead)
+func seqReadElementIDsFrom(dst []string, em *elementIDsMetadata, count int, reader *seqReader) ([]string, error) {
+	if err := reader.align(em.offset); err != nil {
+		return dst, err
 	}
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(int(em.size))
 	defer bigValuePool.Release(bb)
 	bb.Buf = bytes.ResizeExact(bb.Buf, int(em.size))
-	reader.mustReadFull(bb.Buf)
+	if err := reader.readFull(bb.Buf); err != nil {
+		return dst, err
+	}
 	decoder := encoding.BytesBlockDecoder{}
 	var elementIDsByteSlice [][]byte
 	elementIDsByteSlice, err := decoder.Decode(elementIDsByteSlice, bb.Buf, uint64(count))
 	if err != nil {
-		logger.Panicf("%s: cannot unmarshal elementIDs: %v", reader.Path(), err)
+		return dst, fmt.Errorf("%s: cannot unmarshal elementIDs: %w", reader.Path(), err)
 	}
 	for _, elementID := range elementIDsByteSlice {
 		dst = append(dst, string(elementID))
 	}
-	return dst
+	return dst, nil
 }
 
 func generateBlock() *block {
@@ -405,40 +780,80 @@ func generateBlock() *block {
 
 func releaseBlock(b *block) {
 	b.reset()
+	if !blockCapacity.Retain(b.capacity()) {
+		return
+	}
 	blockPool.Put(b)
 }
 
 var blockPool sync.Pool
 
+const (
+	// the tag families of a block are decoded in parallel if at least these many of them are projected
+	parallelDecodeMinTagFamilies = 6
+	// and the block holds at least these many elements
+	parallelDecodeMinElements = 1024
+)
+
+// blockCursor owns the elementIDs and the tag values it loads: they refer to the arena and the arenas
+// of the tag families decoded in parallel, which are freed wholesale in reset.
+// They are copied out when copying to a pbv1.StreamResult.
 type blockCursor struct {
-	p                *part
-	timestamps       []int64
-	elementIDs       []string
-	tagFamilies      []tagFamily
-	tagValuesDecoder encoding.BytesBlockDecoder
-	tagProjection    []pbv1.TagProjection
-	bm               blockMetadata
-	idx              int
-	minTimestamp     int64
-	maxTimestamp     int64
+	p               *part
+	timestamps      []int64
+	elementIDs      []string
+	tagFamilies     []tagFamily
+	tagFamilyArenas []*encoding.BytesBlockDecoder
+	arena           encoding.BytesBlockDecoder
+	// columns allocates the typed views of the tags, see tag.AsInt64s.
+	columns       columnArena
+	tagProjection []pbv1.TagProjection
+	tagPredicates []tagPredicate
+	// tagTypes are the types of the tags in the schema if the part is older than it, see staleTag.
+	tagTypes map[string]pbv1.ValueType
+	bm       blockMetadata
+	// the rows in [from, to) are left to copy, and idx is the next one copyTo copies.
+	// They are set by initWindow.
+	idx          int
+	from         int
+	to           int
+	minTimestamp int64
+	maxTimestamp int64
+	desc         bool
 }
 
 func (bc *blockCursor) reset() {
 	bc.idx = 0
+	bc.from = 0
+	bc.to = 0
+	bc.desc = false
 	bc.p = nil
 	bc.bm = blockMetadata{}
 	bc.minTimestamp = 0
 	bc.maxTimestamp = 0
 	bc.tagProjection = bc.tagProjection[:0]
+	bc.tagPredicates = nil
+	bc.tagTypes = nil
 
 	bc.timestamps = bc.timestamps[:0]
-	bc.elementIDs = bc.elementIDs[:0]
+	elementIDs := bc.elementIDs
+	for i := range elementIDs {
+		elementIDs[i] = ""
+	}
+	bc.elementIDs = elementIDs[:0]
 
 	tff := bc.tagFamilies
 	for i := range tff {
 		tff[i].reset()
 	}
 	bc.tagFamilies = tff[:0]
+	bc.columns.reset()
+	bc.arena.Reset()
+	for i := range bc.tagFamilyArenas {
+		releaseColumnValuesDecoder(bc.tagFamilyArenas[i])
+		bc.tagFamilyArenas[i] = nil
+	}
+	bc.tagFamilyArenas = bc.tagFamilyArenas[:0]
 }
 
 func (bc *blockCursor) init(p *part, bm blockMetadata, queryOpts queryOptions) {
@@ -448,31 +863,86 @@ func (bc *blockCursor) init(p *part, bm blockMetadata, queryOpts queryOptions) {
 	bc.minTimestamp = queryOpts.minTimestamp
 	bc.maxTimestamp = queryOpts.maxTimestamp
 	bc.tagProjection = queryOpts.TagProjection
+	bc.tagPredicates = queryOpts.tagPredicates
+	if p != nil && p.partMetadata.SchemaRevision < queryOpts.schemaRevision {
+		bc.tagTypes = queryOpts.tagTypes
+	}
 }
 
-func (bc *blockCursor) copyAllTo(r *pbv1.StreamResult, desc bool) {
-	var idx, offset int
-	if desc {
-		idx = 0
-		offset = bc.idx + 1
+// staleTag tells whether the tag of the block is of another type than the one in the schema,
+// i.e. it was dropped and added again in another type after the part was written, then its values are nulls.
+func (bc *blockCursor) staleTag(t *tag) bool {
+	vt, ok := bc.tagTypes[t.name]
+	return ok && vt != t.valueType
+}
+
+// initWindow makes all the rows left to copy. They are copied in the descending order of timestamps if desc.
+func (bc *blockCursor) initWindow(desc bool) {
+	bc.desc = desc
+	bc.from, bc.to = 0, len(bc.timestamps)
+	bc.seek()
+}
+
+// skipBefore drops the rows ordered before the position from the window, and those at it up to *left,
+// which counts the rows of the series at the timestamp of the position left to drop across its blocks.
+func (bc *blockCursor) skipBefore(c *pbv1.Cursor, left *uint32) {
+	ts := bc.timestamps
+	lo := sort.Search(len(ts), func(i int) bool { return ts[i] >= c.Timestamp })
+	hi := sort.Search(len(ts), func(i int) bool { return ts[i] > c.Timestamp })
+	// the rows in [lo, hi) are at the timestamp of the position
+	var n int
+	switch {
+	case bc.bm.seriesID < c.SeriesID:
+		n = hi - lo
+	case bc.bm.seriesID == c.SeriesID:
+		n = min(hi-lo, int(*left))
+		*left -= uint32(n)
+	}
+	if bc.desc {
+		bc.to = min(bc.to, hi-n)
+	} else {
+		bc.from = max(bc.from, lo+n)
+	}
+	bc.seek()
+}
+
+// seek points idx to the next row to copy, which is the first row of the window in the ascending order,
+// and the last one in the descending order.
+func (bc *blockCursor) seek() {
+	if bc.desc {
+		bc.idx = bc.to - 1
 	} else {
-		idx = bc.idx
-		offset = len(bc.timestamps)
+		bc.idx = bc.from
 	}
+}
+
+// exhausted reports whether all the rows are copied.
+func (bc *blockCursor) exhausted() bool {
+	return bc.from >= bc.to
+}
+
+// copyAllTo copies the rows left in the ascending order of timestamps, whatever the order of the window is.
+// The window is empty then, so that a subsequent call doesn't copy them again.
+func (bc *blockCursor) copyAllTo(r *pbv1.StreamResult) {
+	idx, offset := bc.from, bc.to
 	if offset <= idx {
 		return
 	}
+	bc.from = bc.to
+	bc.seek()
 	r.SID = bc.bm.seriesID
 	r.Timestamps = append(r.Timestamps, bc.timestamps[idx:offset]...)
-	r.ElementIDs = append(r.ElementIDs, bc.elementIDs[idx:offset]...)
+	for _, elementID := range bc.elementIDs[idx:offset] {
+		r.ElementIDs = append(r.ElementIDs, strings.Clone(elementID))
+	}
 	if len(r.TagFamilies) != len(bc.tagProjection) {
 		for _, tp := range bc.tagProjection {
 			tf := pbv1.TagFamily{
-				Name: tp.Family,
+				Name: intern.String(tp.Family),
 			}
 			for _, n := range tp.Names {
 				t := pbv1.Tag{
-					Name: n,
+					Name: intern.String(n),
 				}
 				tf.Tags = append(tf.Tags, t)
 			}
@@ -480,32 +950,45 @@ func (bc *blockCursor) copyAllTo(r *pbv1.StreamResult, desc bool) {
 		}
 	}
 	for i, cf := range bc.tagFamilies {
-		for i2, c := range cf.tags {
-			if c.values != nil {
-				for _, v := range c.values[idx:offset] {
-					r.TagFamilies[i].Tags[i2].Values = append(r.TagFamilies[i].Tags[i2].Values, mustDecodeTagValue(c.valueType, v))
-				}
-			} else {
-				for j := idx; j < offset; j++ {
-					r.TagFamilies[i].Tags[i2].Values = append(r.TagFamilies[i].Tags[i2].Values, pbv1.NullTagValue)
-				}
-			}
+		for i2 := range cf.tags {
+			t := &r.TagFamilies[i].Tags[i2]
+			t.Values = appendColumnValues(t.Values, &cf.tags[i2], idx, offset)
 		}
 	}
 }
 
+// take drops the row at idx from the window, and returns its index.
+// The caller has to check the cursor isn't exhausted.
+func (bc *blockCursor) take() int {
+	idx := bc.idx
+	if bc.desc {
+		bc.to--
+	} else {
+		bc.from++
+	}
+	bc.seek()
+	return idx
+}
+
+// copyTo copies the row at idx, and drops it from the window.
+// The caller has to check the cursor isn't exhausted.
 func (bc *blockCursor) copyTo(r *pbv1.StreamResult) {
+	bc.copyRowTo(r, bc.take())
+}
+
+// copyRowTo copies the row at idx.
+func (bc *blockCursor) copyRowTo(r *pbv1.StreamResult, idx int) {
 	r.SID = bc.bm.seriesID
-	r.Timestamps = append(r.Timestamps, bc.timestamps[bc.idx])
-	r.ElementIDs = append(r.ElementIDs, bc.elementIDs[bc.idx])
+	r.Timestamps = append(r.Timestamps, bc.timestamps[idx])
+	r.ElementIDs = append(r.ElementIDs, strings.Clone(bc.elementIDs[idx]))
 	if len(r.TagFamilies) != len(bc.tagProjection) {
 		for _, tp := range bc.tagProjection {
 			tf := pbv1.TagFamily{
-				Name: tp.Family,
+				Name: intern.String(tp.Family),
 			}
 			for _, n := range tp.Names {
 				t := pbv1.Tag{
-					Name: n,
+					Name: intern.String(n),
 				}
 				tf.Tags = append(tf.Tags, t)
 			}
@@ -519,60 +1002,158 @@ func (bc *blockCursor) copyTo(r *pbv1.StreamResult) {
 		if len(r.TagFamilies[i].Tags) != len(cf.tags) {
 			logger.Panicf("unexpected number of tags: got %d; want %d", len(r.TagFamilies[i].Tags), len(bc.tagProjection[i].Names))
 		}
-		for i2, c := range cf.tags {
-			if c.values != nil {
-				r.TagFamilies[i].Tags[i2].Values = append(r.TagFamilies[i].Tags[i2].Values, mustDecodeTagValue(c.valueType, c.values[bc.idx]))
-			} else {
-				r.TagFamilies[i].Tags[i2].Values = append(r.TagFamilies[i].Tags[i2].Values, pbv1.NullTagValue)
-			}
+		for i2 := range cf.tags {
+			r.TagFamilies[i].Tags[i2].Values = append(r.TagFamilies[i].Tags[i2].Values, columnValue(&cf.tags[i2], idx))
 		}
 	}
 }
 
-func (bc *blockCursor) loadData(tmpBlock *block) bool {
+// loadData decodes the block, and the tag families in parallel if the block is wide and large enough.
+// The goroutines decoding the tag families are taken from the workers of the query.
+// It returns false if no element of the block is in the time range,
+// and an error if the block is corrupted, then the cursor should be skipped.
+func (bc *blockCursor) loadData(ctx context.Context, tmpBlock *block, workers workerBudget) (bool, error) {
+	// the cursor of a recent window holds the rows already
+	if bc.p == nil {
+		return len(bc.timestamps) > 0, nil
+	}
+	// a canceled query reads no more blocks
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if ok, err := bc.mightMatch(); !ok || err != nil {
+		return false, err
+	}
 	tmpBlock.reset()
-	bc.bm.tagProjection = bc.tagProjection
-	tf := make(map[string]*dataBlock, len(bc.tagProjection))
-	for i := range bc.tagProjection {
+	minTimestamp, maxTimestamp := max(bc.bm.timestamps.min, bc.minTimestamp), min(bc.bm.timestamps.max, bc.maxTimestamp)
+	deletions := bc.p.tombstones.deletionsOf(nil, bc.bm.seriesID, bc.p.partMetadata.ID, minTimestamp, maxTimestamp)
+	for _, d := range deletions {
+		if d.coversBlock(minTimestamp, maxTimestamp) {
+			return false, nil
+		}
+	}
+	// the tags the deletions are evaluated on are read along with the projected ones
+	bc.bm.tagProjection = withDeletedTags(bc.tagProjection, deletions)
+	tf := make(map[string]*dataBlock, len(bc.bm.tagProjection))
+	for i := range bc.bm.tagProjection {
 		for tfName, block := range bc.bm.tagFamilies {
-			if bc.tagProjection[i].Family == tfName {
+			if bc.bm.tagProjection[i].Family == tfName {
 				tf[tfName] = block
 			}
 		}
 	}
 	bc.bm.tagFamilies = tf
-	tmpBlock.mustReadFrom(&bc.tagValuesDecoder, bc.p, bc.bm)
+	var err error
+	if workers != nil && len(tf) >= parallelDecodeMinTagFamilies && bc.bm.count >= parallelDecodeMinElements {
+		bc.tagFamilyArenas, err = tmpBlock.readFromInParallel(&bc.arena, bc.tagFamilyArenas, bc.p, bc.bm, workers)
+	} else {
+		err = tmpBlock.readFrom(&bc.arena, bc.p, bc.bm)
+	}
+	if err != nil {
+		return false, err
+	}
 
 	start, end, ok := timestamp.FindRange(tmpBlock.timestamps, bc.minTimestamp, bc.maxTimestamp)
 	if !ok {
-		return false
+		return false, nil
+	}
+	var deleted []bool
+	if len(deletions) > 0 {
+		deleted = make([]bool, len(tmpBlock.timestamps))
+		if deletedRows(deleted, deletions, tmpBlock.timestamps, tmpBlock.tagFamilies) == 0 {
+			deleted = nil
+		}
 	}
 	bc.timestamps = append(bc.timestamps, tmpBlock.timestamps[start:end+1]...)
 	bc.elementIDs = append(bc.elementIDs, tmpBlock.elementIDs[start:end+1]...)
 
-	for i, projection := range bc.bm.tagProjection {
+	for i, projection := range bc.tagProjection {
 		tf := tagFamily{
-			name: projection.Family,
+			name: intern.String(projection.Family),
 		}
-		blockIndex := 0
 		for _, name := range projection.Names {
 			t := tag{
-				name: name,
+				name:  intern.String(name),
+				arena: &bc.columns,
 			}
-			if tmpBlock.tagFamilies[i].tags[blockIndex].name == name {
-				t.valueType = tmpBlock.tagFamilies[i].tags[blockIndex].valueType
-				if len(tmpBlock.tagFamilies[i].tags[blockIndex].values) != len(tmpBlock.timestamps) {
-					logger.Panicf("unexpected number of values for tags %q: got %d; want %d",
-						tmpBlock.tagFamilies[i].tags[blockIndex].name, len(tmpBlock.tagFamilies[i].tags[blockIndex].values), len(tmpBlock.timestamps))
+			// the tags missing from the block, e.g. added to the schema after the part was written, are nulls
+			if bt := findTag(tmpBlock.tagFamilies[i].tags, name); bt != nil && !bc.staleTag(bt) {
+				t.valueType = bt.valueType
+				if len(bt.values) != len(tmpBlock.timestamps) {
+					return false, fmt.Errorf("unexpected number of values for tags %q: got %d; want %d",
+						bt.name, len(bt.values), len(tmpBlock.timestamps))
 				}
-				t.values = append(t.values, tmpBlock.tagFamilies[i].tags[blockIndex].values[start:end+1]...)
+				t.values = append(t.values, bt.values[start:end+1]...)
 			}
-			blockIndex++
 			tf.tags = append(tf.tags, t)
 		}
 		bc.tagFamilies = append(bc.tagFamilies, tf)
 	}
-	return true
+	if deleted != nil && retainRows(&bc.timestamps, &bc.elementIDs, bc.tagFamilies, func(idx int) bool {
+		return !deleted[start+idx]
+	}) == 0 {
+		return false, nil
+	}
+	if len(bc.tagPredicates) > 0 && !bc.filterRows(bc.tagPredicates) {
+		return false, nil
+	}
+	bc.initWindow(false)
+	return true, nil
+}
+
+// readSize returns the compressed bytes loadData read, which are the timestamps, the element IDs, and the metadata
+// and the values of the projected tags. The metadata of the tag families is read again to tell the sizes of the values,
+// so it's only called by the traced queries.
+func (bc *blockCursor) readSize() (int64, error) {
+	if bc.p == nil {
+		return 0, nil
+	}
+	size := int64(bc.bm.timestamps.size + bc.bm.elementIDs.size)
+	tfm := generateTagFamilyMetadata()
+	defer releaseTagFamilyMetadata(tfm)
+	for _, tp := range bc.bm.tagProjection {
+		block, ok := bc.bm.tagFamilies[tp.Family]
+		if !ok {
+			continue
+		}
+		tfm.reset()
+		if err := readTagFamilyMetadata(tfm, block, bc.p.tagFamilyMetadata[tp.Family]); err != nil {
+			return size, err
+		}
+		size += int64(block.size)
+		for i := range tfm.tagMetadata {
+			if slices.Contains(tp.Names, tfm.tagMetadata[i].name) {
+				size += int64(tfm.tagMetadata[i].size)
+			}
+		}
+	}
+	return size, nil
+}
+
+// memSize estimates the bytes the decoded rows of the block hold.
+func (bc *blockCursor) memSize() int64 {
+	size := 8 * len(bc.timestamps)
+	for _, id := range bc.elementIDs {
+		size += len(id)
+	}
+	for _, tf := range bc.tagFamilies {
+		for _, t := range tf.tags {
+			for _, v := range t.values {
+				size += len(v)
+			}
+		}
+	}
+	return int64(size)
+}
+
+// findTag returns the tag of the name, or nil if tags doesn't contain it.
+func findTag(tags []tag, name string) *tag {
+	for i := range tags {
+		if tags[i].name == name {
+			return &tags[i]
+		}
+	}
+	return nil
 }
 
 var blockCursorPool sync.Pool
@@ -587,6 +1168,9 @@ func generateBlockCursor() *blockCursor {
 
 func releaseBlockCursor(bc *blockCursor) {
 	bc.reset()
+	if !blockCursorCapacity.Retain(bc.capacity()) {
+		return
+	}
 	blockCursorPool.Put(bc)
 }
 
@@ -612,13 +1196,34 @@ func (bi *blockPointer) copyFrom(src *blockPointer) {
 	bi.appendAll(src)
 }
 
+// appendAll appends the elements of b from its idx.
+// If bi is empty and b is appended from the start, bi adopts the columns of b instead of copying them,
+// and b is left empty with the columns bi held before. So b mustn't be read again until it's reloaded.
 func (bi *blockPointer) appendAll(b *blockPointer) {
 	if len(b.timestamps) == 0 {
 		return
 	}
+	if b.idx == 0 && len(bi.timestamps) == 0 && len(bi.tagFamilies) == 0 {
+		bi.timestamps, b.timestamps = b.timestamps, bi.timestamps[:0]
+		bi.elementIDs, b.elementIDs = b.elementIDs, bi.elementIDs[:0]
+		bi.tagFamilies, b.tagFamilies = b.tagFamilies, bi.tagFamilies[:0]
+		return
+	}
 	bi.append(b, len(b.timestamps))
 }
 
+// reserve grows the columns to append n elements without reallocating them.
+func (bi *blockPointer) reserve(n int) {
+	bi.timestamps = slices.Grow(bi.timestamps, n)
+	bi.elementIDs = slices.Grow(bi.elementIDs, n)
+	for i := range bi.tagFamilies {
+		tags := bi.tagFamilies[i].tags
+		for j := range tags {
+			tags[j].values = slices.Grow(tags[j].values, n)
+		}
+	}
+}
+
 func (bi *blockPointer) append(b *blockPointer, offset int) {
 	if offset <= b.idx {
 		return
@@ -627,8 +1232,10 @@ func (bi *blockPointer) append(b *blockPointer, offset int) {
 		for _, tf := range b.tagFamilies {
 			tFamily := tagFamily{name: tf.name}
 			for _, c := range tf.tags {
-				col := tag{name: c.name, valueType: c.valueType}
+				col := tag{name: c.name, valueType: c.valueType, bloomFilter: c.bloomFilter}
 				assertIdxAndOffset(col.name, len(c.values), b.idx, offset)
+				// the reserved capacity of the timestamps is the expected number of values
+				col.values = make([][]byte, 0, max(offset-b.idx, cap(bi.timestamps)-len(bi.timestamps)))
 				col.values = append(col.values, c.values[b.idx:offset]...)
 				tFamily.tags = append(tFamily.tags, col)
 			}
@@ -650,6 +1257,8 @@ func (bi *blockPointer) append(b *blockPointer, offset int) {
 					logger.Panicf("unexpected tag name for tag family %q: got %q; want %q", bi.tagFamilies[i].name, bi.tagFamilies[i].tags[j].name, b.tagFamilies[i].tags[j].name)
 				}
 				assertIdxAndOffset(b.tagFamilies[i].tags[j].name, len(b.tagFamilies[i].tags[j].values), b.idx, offset)
+				// the merged block keeps the bloom filter if any of the blocks has it
+				bi.tagFamilies[i].tags[j].bloomFilter = bi.tagFamilies[i].tags[j].bloomFilter || b.tagFamilies[i].tags[j].bloomFilter
 				bi.tagFamilies[i].tags[j].values = append(bi.tagFamilies[i].tags[j].values, b.tagFamilies[i].tags[j].values[b.idx:offset]...)
 			}
 		}
@@ -669,8 +1278,8 @@ func assertIdxAndOffset(name string, length int, idx int, offset int) {
 	}
 }
 
-func (bi *blockPointer) isFull() bool {
-	return bi.bm.uncompressedSizeBytes >= maxUncompressedBlockSize
+func (bi *blockPointer) isFull(cutter blockCutter) bool {
+	return cutter.isFull(uint64(len(bi.timestamps)), bi.bm.uncompressedSizeBytes)
 }
 
 func (bi *blockPointer) reset() {
@@ -26,6 +26,7 @@ import (
 	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/intern"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 )
 
@@ -66,8 +67,11 @@ func (h *dataBlock) unmarshal(src []byte) ([]byte, error) {
 }
 
 type blockMetadata struct {
-	tagFamilies           map[string]*dataBlock
-	tagProjection         []pbv1.TagProjection
+	tagFamilies   map[string]*dataBlock
+	tagProjection []pbv1.TagProjection
+	// tagStats is the statistics of the string and int64 tags, which are absent in the parts
+	// written before partFormatVersionTagStats.
+	tagStats              []tagStats
 	timestamps            timestampsMetadata
 	elementIDs            elementIDsMetadata
 	seriesID              common.SeriesID
@@ -88,6 +92,7 @@ func (bh *blockMetadata) copyFrom(src *blockMetadata) {
 		bh.tagFamilies[k] = &dataBlock{}
 		bh.tagFamilies[k].copyFrom(db)
 	}
+	bh.tagStats = append(bh.tagStats[:0], src.tagStats...)
 }
 
 func (bh *blockMetadata) getTagFamilyMetadata(name string) *dataBlock {
@@ -113,6 +118,8 @@ func (bh *blockMetadata) reset() {
 		delete(bh.tagFamilies, k)
 	}
 	bh.tagProjection = bh.tagProjection[:0]
+	clear(bh.tagStats)
+	bh.tagStats = bh.tagStats[:0]
 }
 
 func (bh *blockMetadata) marshal(dst []byte) []byte {
@@ -133,10 +140,15 @@ func (bh *blockMetadata) marshal(dst []byte) []byte {
 		dst = encoding.EncodeBytes(dst, convert.StringToBytes(name))
 		dst = cf.marshal(dst)
 	}
+	dst = encoding.VarUint64ToBytes(dst, uint64(len(bh.tagStats)))
+	for i := range bh.tagStats {
+		dst = bh.tagStats[i].marshal(dst)
+	}
 	return dst
 }
 
-func (bh *blockMetadata) unmarshal(src []byte) ([]byte, error) {
+// unmarshal decodes the block metadata written by the parts of the formatVersion.
+func (bh *blockMetadata) unmarshal(src []byte, formatVersion uint64) ([]byte, error) {
 	if len(src) < 8 {
 		return nil, errors.New("cannot unmarshal blockMetadata from less than 8 bytes")
 	}
@@ -181,12 +193,25 @@ func (bh *blockMetadata) unmarshal(src []byte) ([]byte, error) {
 			if err != nil {
 				return nil, fmt.Errorf("cannot unmarshal tagFamily dataBlock: %w", err)
 			}
-			bh.tagFamilies[convert.BytesToString(nameBytes)] = tf
+			bh.tagFamilies[intern.Bytes(nameBytes)] = tf
 		}
 	}
 	if err != nil {
 		return nil, fmt.Errorf("cannot unmarshal tagFamilyMetadata: %w", err)
 	}
+	if formatVersion < partFormatVersionTagStats {
+		return src, nil
+	}
+	src, n, err = encoding.BytesToVarUint64(src)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal tagStats count: %w", err)
+	}
+	for i := uint64(0); i < n; i++ {
+		bh.tagStats = append(bh.tagStats, tagStats{})
+		if src, err = bh.tagStats[len(bh.tagStats)-1].unmarshal(src); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal tagStats %d: %w", i, err)
+		}
+	}
 	return src, nil
 }
 
@@ -284,7 +309,7 @@ func (th *elementIDsMetadata) unmarshal(src []byte) ([]byte, error) {
 	return src[1:], nil
 }
 
-func unmarshalBlockMetadata(dst []blockMetadata, src []byte) ([]blockMetadata, error) {
+func unmarshalBlockMetadata(dst []blockMetadata, src []byte, formatVersion uint64) ([]blockMetadata, error) {
 	dstOrig := dst
 	var pre *blockMetadata
 	for len(src) > 0 {
@@ -294,7 +319,7 @@ func unmarshalBlockMetadata(dst []blockMetadata, src []byte) ([]blockMetadata, e
 			dst = append(dst, blockMetadata{})
 		}
 		bm := &dst[len(dst)-1]
-		tail, err := bm.unmarshal(src)
+		tail, err := bm.unmarshal(src, formatVersion)
 		if err != nil {
 			return dstOrig, fmt.Errorf("cannot unmarshal blockMetadata entries: %w", err)
 		}
@@ -0,0 +1,150 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
+)
+
+const defaultBlockMetadataCacheSize = 64 << 20
+
+var (
+	metadataCacheProvider = observability.NewMeterProvider(observability.RootScope.SubScope("stream").SubScope("block_metadata_cache"))
+	metadataCacheCounter  = metadataCacheProvider.Counter("requests_total", "result")
+	metadataCacheGauge    = metadataCacheProvider.Gauge("resident_bytes")
+
+	blockMetadataCaches = &metadataCacheRegistry{
+		parts:  make(map[*part]struct{}),
+		budget: defaultBlockMetadataCacheSize,
+	}
+)
+
+// blockMetadataCache holds the block metadata of the primary blocks of a part by their offsets,
+// which are unmarshaled on the first read. The cached metadata is shared by the readers, so it mustn't be modified.
+type blockMetadataCache struct {
+	entries map[uint64][]blockMetadata
+	size    int64
+	mu      sync.RWMutex
+}
+
+// metadataCacheRegistry accounts the block metadata cached by all the parts against the budget.
+// The primary blocks are read on demand once the budget is used up.
+type metadataCacheRegistry struct {
+	parts    map[*part]struct{}
+	budget   int64
+	resident int64
+	mu       sync.Mutex
+}
+
+func (r *metadataCacheRegistry) setBudget(budget int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.budget = budget
+}
+
+// get returns the block metadata of the primary block at the offset of the part, and whether it's cached.
+func (r *metadataCacheRegistry) get(p *part, offset uint64) ([]blockMetadata, bool) {
+	c := &p.metadataCache
+	c.mu.RLock()
+	bms, ok := c.entries[offset]
+	c.mu.RUnlock()
+	if ok {
+		metadataCacheCounter.Inc(1, "hit")
+	} else {
+		metadataCacheCounter.Inc(1, "miss")
+	}
+	return bms, ok
+}
+
+// put caches the block metadata of the primary block at the offset of the part, unless it's over the budget
+// or the caches are being evicted under the memory pressure.
+func (r *metadataCacheRegistry) put(p *part, offset uint64, bms []blockMetadata) {
+	if watchdog.Engaged(watchdog.ActionEvictCaches) {
+		return
+	}
+	size := blockMetadataSize(bms)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.resident+size > r.budget {
+		return
+	}
+	c := &p.metadataCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[offset]; ok {
+		return
+	}
+	if c.entries == nil {
+		c.entries = make(map[uint64][]blockMetadata)
+	}
+	c.entries[offset] = bms
+	c.size += size
+	r.parts[p] = struct{}{}
+	r.resident += size
+	metadataCacheGauge.Set(float64(r.resident))
+}
+
+// evict drops the block metadata cached by the part, which is called once the part is released.
+func (r *metadataCacheRegistry) evict(p *part) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.parts[p]; !ok {
+		return
+	}
+	r.evictLocked(p)
+	metadataCacheGauge.Set(float64(r.resident))
+}
+
+// evictAll drops the block metadata cached by all the parts.
+func (r *metadataCacheRegistry) evictAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for p := range r.parts {
+		r.evictLocked(p)
+	}
+	metadataCacheGauge.Set(float64(r.resident))
+}
+
+func (r *metadataCacheRegistry) evictLocked(p *part) {
+	c := &p.metadataCache
+	c.mu.Lock()
+	r.resident -= c.size
+	c.entries = nil
+	c.size = 0
+	c.mu.Unlock()
+	delete(r.parts, p)
+}
+
+// blockMetadataSize estimates the bytes of the block metadata in memory.
+func blockMetadataSize(bms []blockMetadata) int64 {
+	n := int64(cap(bms)) * int64(unsafe.Sizeof(blockMetadata{}))
+	for i := range bms {
+		for name := range bms[i].tagFamilies {
+			// the key, the pointer and the bucket overhead of an entry, along with the data block it points to
+			n += int64(len(name)) + stringSize + 8 + int64(unsafe.Sizeof(dataBlock{})) + 16
+		}
+		for j := range bms[i].tagStats {
+			n += int64(unsafe.Sizeof(tagStats{})) + int64(len(bms[i].tagStats[j].min)+len(bms[i].tagStats[j].max))
+		}
+	}
+	return n
+}
@@ -0,0 +1,121 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+)
+
+// openManyBlockPart writes the rows of the series into a file part whose blocks hold 16 rows at most.
+func openManyBlockPart(t testing.TB, root string, rows, series int) *partWrapper {
+	es := &elements{}
+	wide := generateWideEs(rows, 1)
+	for i := 0; i < rows; i++ {
+		es.seriesIDs = append(es.seriesIDs, common.SeriesID(i%series+1))
+		es.timestamps = append(es.timestamps, wide.timestamps[i])
+		es.elementIDs = append(es.elementIDs, wide.elementIDs[i])
+		es.tagFamilies = append(es.tagFamilies, wide.tagFamilies[i])
+	}
+	fileSystem := fs.NewLocalFileSystem()
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.sizing = newBlockSizing(1, 16, maxUncompressedBlockSize)
+	mp.mustInitFromElements(es)
+	mp.mustFlush(fileSystem, partPath(root, 1))
+	pw := newPartWrapper(nil, mustOpenFilePart(1, root, fileSystem))
+	require.Greater(t, len(pw.p.primaryBlockMetadata), 1)
+	return pw
+}
+
+func countBlocks(t testing.TB, p *part, sids []common.SeriesID) int {
+	var pi partIter
+	pi.init(p, sids, math.MinInt64, math.MaxInt64)
+	var n int
+	for pi.nextBlock() {
+		n++
+	}
+	require.NoError(t, pi.error())
+	return n
+}
+
+func Test_blockMetadataCache(t *testing.T) {
+	defer blockMetadataCaches.setBudget(defaultBlockMetadataCacheSize)
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	pw := openManyBlockPart(t, tmpPath, 200000, 10)
+	sids := []common.SeriesID{2, 5, 9}
+	resident := blockMetadataCaches.resident
+
+	blockMetadataCaches.setBudget(0)
+	want := countBlocks(t, pw.p, sids)
+	assert.Empty(t, pw.p.metadataCache.entries, "the primary blocks are read on demand over the budget")
+
+	blockMetadataCaches.setBudget(defaultBlockMetadataCacheSize)
+	assert.Equal(t, want, countBlocks(t, pw.p, sids))
+	assert.NotEmpty(t, pw.p.metadataCache.entries)
+	assert.Greater(t, blockMetadataCaches.resident, resident)
+	assert.Equal(t, want, countBlocks(t, pw.p, sids), "the cached block metadata is read again")
+	e, _, err := pw.p.getElement(5, 15, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), e.timestamp)
+
+	blockMetadataCaches.evictAll()
+	assert.Empty(t, pw.p.metadataCache.entries)
+	assert.Zero(t, blockMetadataCaches.resident)
+
+	assert.Equal(t, want, countBlocks(t, pw.p, sids))
+	assert.Positive(t, blockMetadataCaches.resident)
+	pw.decRef()
+	assert.Zero(t, blockMetadataCaches.resident, "the block metadata is evicted once the part is released")
+}
+
+// Benchmark_partIter_blockMetadataCache compares the repeated queries of a part with many blocks
+// with and without the block metadata cached.
+func Benchmark_partIter_blockMetadataCache(b *testing.B) {
+	defer blockMetadataCaches.setBudget(defaultBlockMetadataCacheSize)
+	tmpPath, defFn := test.Space(require.New(b))
+	defer defFn()
+	pw := openManyBlockPart(b, tmpPath, 400000, 100)
+	defer pw.decRef()
+	sids := []common.SeriesID{3, 30, 60, 90}
+	for _, bc := range []struct {
+		name   string
+		budget int64
+	}{
+		{name: "uncached", budget: 0},
+		{name: "cached", budget: defaultBlockMetadataCacheSize},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			blockMetadataCaches.setBudget(bc.budget)
+			blockMetadataCaches.evictAll()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				countBlocks(b, pw.p, sids)
+			}
+		})
+	}
+}
@@ -24,7 +24,9 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 )
 
 func Test_dataBlock_reset(t *testing.T) {
@@ -211,6 +213,10 @@ func Test_blockMetadata_marshal_unmarshal(t *testing.T) {
 						size:   3,
 					},
 				},
+				tagStats: []tagStats{
+					{family: "tag1", name: "str", valueType: pbv1.ValueTypeStr, min: []byte("a"), max: []byte("z")},
+					{family: "tag2", name: "int", valueType: pbv1.ValueTypeInt64, min: convert.Int64ToBytes(-1), max: convert.Int64ToBytes(1)},
+				},
 			},
 		},
 	}
@@ -223,7 +229,7 @@ func Test_blockMetadata_marshal_unmarshal(t *testing.T) {
 				tagFamilies: make(map[string]*dataBlock),
 			}
 
-			_, err := unmarshaled.unmarshal(marshaled)
+			_, err := unmarshaled.unmarshal(marshaled, currentPartFormatVersion)
 			require.NoError(t, err)
 
 			assert.Equal(t, tc.original.seriesID, unmarshaled.seriesID)
@@ -231,8 +237,23 @@ func Test_blockMetadata_marshal_unmarshal(t *testing.T) {
 			assert.Equal(t, tc.original.count, unmarshaled.count)
 			assert.Equal(t, tc.original.timestamps, unmarshaled.timestamps)
 			assert.Equal(t, tc.original.tagFamilies, unmarshaled.tagFamilies)
+			assert.Equal(t, tc.original.tagStats, unmarshaled.tagStats)
 		})
 	}
+
+	t.Run("Before the tag stats", func(t *testing.T) {
+		original := testCases[1].original
+		// the parts of the old format end with the tag families
+		marshaled := original.marshal(nil)
+		marshaled = marshaled[:len(marshaled)-1]
+
+		unmarshaled := blockMetadata{}
+		tail, err := unmarshaled.unmarshal(marshaled, 0)
+		require.NoError(t, err)
+		assert.Empty(t, tail)
+		assert.Equal(t, original.tagFamilies, unmarshaled.tagFamilies)
+		assert.Empty(t, unmarshaled.tagStats)
+	})
 }
 
 func Test_unmarshalBlockMetadata(t *testing.T) {
@@ -269,7 +290,7 @@ func Test_unmarshalBlockMetadata(t *testing.T) {
 			marshaled = bm.marshal(marshaled)
 		}
 
-		unmarshaled, err := unmarshalBlockMetadata(nil, marshaled)
+		unmarshaled, err := unmarshalBlockMetadata(nil, marshaled, currentPartFormatVersion)
 		require.NoError(t, err)
 		require.Equal(t, original, unmarshaled)
 	})
@@ -307,7 +328,7 @@ func Test_unmarshalBlockMetadata(t *testing.T) {
 			marshaled = bm.marshal(marshaled)
 		}
 
-		_, err := unmarshalBlockMetadata(nil, marshaled)
+		_, err := unmarshalBlockMetadata(nil, marshaled, currentPartFormatVersion)
 		require.Error(t, err)
 	})
 }
@@ -24,15 +24,18 @@ import (
 	"io"
 	"sync"
 
+	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
-	"github.com/apache/skywalking-banyandb/pkg/logger"
 )
 
 type seqReader struct {
 	sr        fs.SeqReader
 	r         fs.Reader
 	bytesRead uint64
+	// resync is set once a block is skipped, whose data may be left partially read,
+	// or the data is left out of the projection
+	resync bool
 }
 
 func (sr *seqReader) reset() {
@@ -42,6 +45,7 @@ func (sr *seqReader) reset() {
 	}
 	sr.sr = nil
 	sr.bytesRead = 0
+	sr.resync = false
 }
 
 func (sr *seqReader) Path() string {
@@ -49,23 +53,52 @@ func (sr *seqReader) Path() string {
 }
 
 func (sr *seqReader) init(r fs.Reader) {
+	sr.initWithOptions(r, fs.IOOptions{})
+}
+
+// initWithOptions is init reading r with the ioOpts.
+func (sr *seqReader) initWithOptions(r fs.Reader, ioOpts fs.IOOptions) {
 	sr.reset()
-	sr.sr = r.SequentialRead()
+	sr.sr = fs.SequentialRead(r, ioOpts)
 	sr.r = r
 }
 
-func (sr *seqReader) mustReadFull(data []byte) {
+func (sr *seqReader) readFull(data []byte) error {
 	n, err := io.ReadFull(sr.sr, data)
 	if err != nil {
-		if errors.Is(err, io.EOF) {
-			return
+		// the file is truncated if it ends before the data
+		return fmt.Errorf("%s: cannot read %d bytes at offset %d: %w", sr.Path(), len(data), sr.bytesRead, err)
+	}
+	sr.bytesRead += uint64(n)
+	return nil
+}
+
+// align checks the reader is at the offset of the data to read.
+// A reader resyncing after a skipped block discards the data up to the offset.
+func (sr *seqReader) align(offset uint64) error {
+	if sr.resync && offset > sr.bytesRead {
+		if err := sr.discard(offset - sr.bytesRead); err != nil {
+			return fmt.Errorf("%s: cannot skip to offset %d: %w", sr.Path(), offset, err)
 		}
-		logger.Panicf("cannot read data: %v", err)
 	}
-	if n != len(data) {
-		logger.Panicf("cannot read full data: %d/%d", n, len(data))
+	sr.resync = false
+	if offset != sr.bytesRead {
+		return fmt.Errorf("%s: offset %d must be equal to bytesRead %d", sr.Path(), offset, sr.bytesRead)
 	}
-	sr.bytesRead += uint64(n)
+	return nil
+}
+
+// discard skips the next n bytes. It seeks if the underlying reader supports, otherwise the bytes are read and dropped.
+func (sr *seqReader) discard(n uint64) error {
+	var d int64
+	var err error
+	if discarder, ok := sr.sr.(fs.Discarder); ok {
+		d, err = discarder.Discard(int64(n))
+	} else {
+		d, err = io.CopyN(io.Discard, sr.sr, int64(n))
+	}
+	sr.bytesRead += uint64(d)
+	return err
 }
 
 func generateSeqReader() *seqReader {
@@ -108,11 +141,28 @@ func (sr *seqReaders) reset() {
 	}
 }
 
+// resync makes the readers of the data skip to the next block they read.
+func (sr *seqReaders) resync() {
+	sr.timestamps.resync = true
+	sr.elementIDs.resync = true
+	for _, r := range sr.tagFamilyMetadata {
+		r.resync = true
+	}
+	for _, r := range sr.tagFamilies {
+		r.resync = true
+	}
+}
+
 func (sr *seqReaders) init(p *part) {
+	sr.initWithOptions(p, fs.IOOptions{})
+}
+
+// initWithOptions is init reading the files of p with the ioOpts.
+func (sr *seqReaders) initWithOptions(p *part, ioOpts fs.IOOptions) {
 	sr.reset()
-	sr.primary.init(p.primary)
-	sr.timestamps.init(p.timestamps)
-	sr.elementIDs.init(p.elementIDs)
+	sr.primary.initWithOptions(p.primary, ioOpts)
+	sr.timestamps.initWithOptions(p.timestamps, ioOpts)
+	sr.elementIDs.initWithOptions(p.elementIDs, ioOpts)
 	if sr.tagFamilies == nil {
 		sr.tagFamilies = make(map[string]*seqReader)
 		sr.tagFamilyMetadata = make(map[string]*seqReader)
@@ -120,21 +170,26 @@ func (sr *seqReaders) init(p *part) {
 
 	for k, r := range p.tagFamilies {
 		sr.tagFamilies[k] = generateSeqReader()
-		sr.tagFamilies[k].init(r)
+		sr.tagFamilies[k].initWithOptions(r, ioOpts)
 		sr.tagFamilyMetadata[k] = generateSeqReader()
-		sr.tagFamilyMetadata[k].init(p.tagFamilyMetadata[k])
+		sr.tagFamilyMetadata[k].initWithOptions(p.tagFamilyMetadata[k], ioOpts)
 	}
 }
 
 type blockReader struct {
-	err           error
-	block         *blockPointer
+	err   error
+	block *blockPointer
+	// reclaimed is the estimated size of the purged blocks skipped by their series.
+	reclaimed     map[common.SeriesID]uint64
 	pih           partMergeIterHeap
 	nextBlockNoop bool
 }
 
 func (br *blockReader) reset() {
 	br.block = nil
+	for k := range br.reclaimed {
+		delete(br.reclaimed, k)
+	}
 	for i := range br.pih {
 		br.pih[i] = nil
 	}
@@ -208,8 +263,45 @@ func (br *blockReader) nextMetadata() error {
 	return nil
 }
 
-func (br *blockReader) loadBlockData(decoder *encoding.BytesBlockDecoder) {
-	br.pih[0].mustLoadBlockData(decoder, br.block)
+// purged reports whether the series of the current block is purged from its part.
+func (br *blockReader) purged() bool {
+	pmi := br.pih[0]
+	return pmi.p.tombstones.covers(br.block.bm.seriesID, pmi.p.partMetadata.ID)
+}
+
+// skipPurgedBlock drops the current block without reading its data, and counts the size of it estimated from the metadata.
+func (br *blockReader) skipPurgedBlock() {
+	pmi := br.pih[0]
+	pmi.seqReaders.resync()
+	if br.reclaimed == nil {
+		br.reclaimed = make(map[common.SeriesID]uint64)
+	}
+	br.reclaimed[br.block.bm.seriesID] += pmi.p.estimatedBlockSize(&br.block.bm)
+}
+
+// loadBlockData reads the current block, and drops the rows deleted from its part so that the merges compact them away.
+// The block might be left empty.
+func (br *blockReader) loadBlockData(decoder *encoding.BytesBlockDecoder) error {
+	pmi := br.pih[0]
+	if err := pmi.loadBlockData(decoder, br.block); err != nil {
+		return err
+	}
+	b := br.block
+	deletions := pmi.p.tombstones.deletionsOf(nil, b.bm.seriesID, pmi.p.partMetadata.ID, b.bm.timestamps.min, b.bm.timestamps.max)
+	if len(deletions) == 0 {
+		return nil
+	}
+	deleted := make([]bool, len(b.timestamps))
+	n := deletedRows(deleted, deletions, b.timestamps, b.tagFamilies)
+	if n == 0 {
+		return nil
+	}
+	retainRows(&b.timestamps, &b.elementIDs, b.tagFamilies, func(idx int) bool {
+		return !deleted[idx]
+	})
+	b.updateMetadata()
+	deletedElementsCount.Inc(float64(n), pmi.p.tombstones.group)
+	return nil
 }
 
 func (br *blockReader) error() error {
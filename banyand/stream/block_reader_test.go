@@ -45,8 +45,8 @@ func Test_blockReader_nextBlock(t *testing.T) {
 			name:   "Test with single part",
 			esList: []*elements{esTS1},
 			want: []blockMetadata{
-				{seriesID: 1, count: 1, uncompressedSizeBytes: 881},
-				{seriesID: 2, count: 1, uncompressedSizeBytes: 55},
+				{seriesID: 1, count: 1, uncompressedSizeBytes: 863},
+				{seriesID: 2, count: 1, uncompressedSizeBytes: 48},
 				{seriesID: 3, count: 1, uncompressedSizeBytes: 8},
 			},
 		},
@@ -54,10 +54,10 @@ func Test_blockReader_nextBlock(t *testing.T) {
 			name:   "Test with multiple parts with different ts",
 			esList: []*elements{esTS1, esTS2},
 			want: []blockMetadata{
-				{seriesID: 1, count: 1, uncompressedSizeBytes: 881},
-				{seriesID: 1, count: 1, uncompressedSizeBytes: 881},
-				{seriesID: 2, count: 1, uncompressedSizeBytes: 55},
-				{seriesID: 2, count: 1, uncompressedSizeBytes: 55},
+				{seriesID: 1, count: 1, uncompressedSizeBytes: 863},
+				{seriesID: 1, count: 1, uncompressedSizeBytes: 863},
+				{seriesID: 2, count: 1, uncompressedSizeBytes: 48},
+				{seriesID: 2, count: 1, uncompressedSizeBytes: 48},
 				{seriesID: 3, count: 1, uncompressedSizeBytes: 8},
 				{seriesID: 3, count: 1, uncompressedSizeBytes: 8},
 			},
@@ -66,10 +66,10 @@ func Test_blockReader_nextBlock(t *testing.T) {
 			name:   "Test with multiple parts with same ts",
 			esList: []*elements{esTS1, esTS1},
 			want: []blockMetadata{
-				{seriesID: 1, count: 1, uncompressedSizeBytes: 881},
-				{seriesID: 1, count: 1, uncompressedSizeBytes: 881},
-				{seriesID: 2, count: 1, uncompressedSizeBytes: 55},
-				{seriesID: 2, count: 1, uncompressedSizeBytes: 55},
+				{seriesID: 1, count: 1, uncompressedSizeBytes: 863},
+				{seriesID: 1, count: 1, uncompressedSizeBytes: 863},
+				{seriesID: 2, count: 1, uncompressedSizeBytes: 48},
+				{seriesID: 2, count: 1, uncompressedSizeBytes: 48},
 				{seriesID: 3, count: 1, uncompressedSizeBytes: 8},
 				{seriesID: 3, count: 1, uncompressedSizeBytes: 8},
 			},
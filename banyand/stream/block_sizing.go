@@ -0,0 +1,102 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"math"
+	"sync"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+)
+
+const (
+	defaultBlockMinRows = 256
+	defaultBlockMaxRows = 8192
+
+	// maxUncompressedWideBlockSize bounds the blocks of the wide rows which are cut by the rows rather than by maxUncompressedBlockSize.
+	// Two of them merged are still smaller than maxValuesBlockSize.
+	maxUncompressedWideBlockSize = 4 * 1024 * 1024
+
+	// rowWidthWeight is the weight of the latest observation in the average size of the rows of a series.
+	rowWidthWeight = 0.2
+)
+
+// blockSizing cuts the blocks of a series at a number of rows derived from the average size of its rows.
+// The blocks of the narrow rows are bounded by maxRows, so that a point lookup doesn't decode a huge block,
+// and the blocks of the wide rows hold minRows at least, so that the block metadata doesn't outgrow the data.
+// The blocks are cut by maxBytes alone if maxRows is 0.
+type blockSizing struct {
+	widths   map[common.SeriesID]float64
+	minRows  uint64
+	maxRows  uint64
+	maxBytes uint64
+	mu       sync.RWMutex
+}
+
+func newBlockSizing(minRows, maxRows, maxBytes uint64) *blockSizing {
+	return &blockSizing{
+		widths:   make(map[common.SeriesID]float64),
+		minRows:  minRows,
+		maxRows:  maxRows,
+		maxBytes: maxBytes,
+	}
+}
+
+// observe adds the size of the rows to the exponentially weighted moving average of the series.
+func (bs *blockSizing) observe(seriesID common.SeriesID, sizeBytes, rows uint64) {
+	if bs == nil || bs.maxRows == 0 || rows == 0 {
+		return
+	}
+	w := float64(sizeBytes) / float64(rows)
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if prev, ok := bs.widths[seriesID]; ok {
+		w = prev + rowWidthWeight*(w-prev)
+	}
+	bs.widths[seriesID] = w
+}
+
+// cutter returns the bounds of the blocks of the series. The blocks are bounded by maxUncompressedBlockSize alone
+// if the sizing is nil, by maxBytes alone if maxRows is 0, and by maxRows as well if no row of the series is observed.
+func (bs *blockSizing) cutter(seriesID common.SeriesID) blockCutter {
+	if bs == nil {
+		return blockCutter{maxRows: math.MaxUint64, maxBytes: maxUncompressedBlockSize}
+	}
+	if bs.maxRows == 0 {
+		return blockCutter{maxRows: math.MaxUint64, maxBytes: bs.maxBytes}
+	}
+	bs.mu.RLock()
+	w, ok := bs.widths[seriesID]
+	bs.mu.RUnlock()
+	if !ok || w <= 0 {
+		return blockCutter{maxRows: bs.maxRows, maxBytes: bs.maxBytes}
+	}
+	rows := uint64(float64(bs.maxBytes) / w)
+	rows = max(bs.minRows, min(bs.maxRows, rows))
+	return blockCutter{maxRows: max(rows, 1), maxBytes: maxUncompressedWideBlockSize}
+}
+
+// blockCutter bounds the rows and the uncompressed bytes of a block.
+type blockCutter struct {
+	maxRows  uint64
+	maxBytes uint64
+}
+
+func (bc blockCutter) isFull(rows, sizeBytes uint64) bool {
+	return rows >= bc.maxRows || sizeBytes >= bc.maxBytes
+}
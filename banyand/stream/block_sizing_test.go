@@ -0,0 +1,223 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+)
+
+func Test_blockSizing_cutter(t *testing.T) {
+	var nilSizing *blockSizing
+	assert.Equal(t, blockCutter{maxRows: math.MaxUint64, maxBytes: maxUncompressedBlockSize}, nilSizing.cutter(1))
+
+	bs := newBlockSizing(256, 8192, maxUncompressedBlockSize)
+	assert.Equal(t, blockCutter{maxRows: 8192, maxBytes: maxUncompressedBlockSize}, bs.cutter(1), "no row is observed")
+
+	bs.observe(1, 64*1000, 1000)
+	assert.Equal(t, blockCutter{maxRows: 8192, maxBytes: maxUncompressedWideBlockSize}, bs.cutter(1), "narrow rows")
+	bs.observe(2, 16*1024*100, 100)
+	assert.Equal(t, blockCutter{maxRows: 256, maxBytes: maxUncompressedWideBlockSize}, bs.cutter(2), "wide rows")
+	bs.observe(3, 1024*100, 100)
+	assert.Equal(t, uint64(2048), bs.cutter(3).maxRows)
+
+	bs.observe(3, 2048*100, 100)
+	assert.InDelta(t, 1024*(1-rowWidthWeight)+2048*rowWidthWeight, bs.widths[3], 0.001)
+}
+
+func Test_blockSizing_cutter_maxBytes(t *testing.T) {
+	bytesOnly := newBlockSizing(0, 0, 256*1024)
+	bytesOnly.observe(1, 1024*100, 100)
+	assert.Equal(t, blockCutter{maxRows: math.MaxUint64, maxBytes: 256 * 1024}, bytesOnly.cutter(1))
+	assert.Empty(t, bytesOnly.widths)
+
+	small := newBlockSizing(16, 8192, 256*1024)
+	assert.Equal(t, blockCutter{maxRows: 8192, maxBytes: 256 * 1024}, small.cutter(1), "no row is observed")
+	small.observe(1, 1024*100, 100)
+	assert.Equal(t, uint64(256), small.cutter(1).maxRows)
+}
+
+func Test_option_withStreamBlock(t *testing.T) {
+	node := option{flushTimeout: time.Second, blockMinRows: defaultBlockMinRows, blockMaxRows: defaultBlockMaxRows}
+	assert.Equal(t, node, node.withStreamBlock(nil), "the options of the data node apply")
+
+	got := node.withStreamBlock(&commonv1.StreamBlockOpts{
+		MaxUncompressedSize: 4 << 20,
+		MaxRows:             100,
+		FlushTimeout:        durationpb.New(time.Minute),
+	})
+	assert.Equal(t, option{flushTimeout: time.Minute, blockMinRows: 100, blockMaxRows: 100, blockMaxSize: 4 << 20}, got)
+
+	got = node.withStreamBlock(&commonv1.StreamBlockOpts{MinRows: 16})
+	assert.Equal(t, uint64(16), got.blockMinRows)
+	assert.Equal(t, uint64(defaultBlockMaxRows), got.blockMaxRows)
+}
+
+func Test_memPart_blockSizing(t *testing.T) {
+	const rows = 20000
+	es := generateWideEs(rows, 1)
+	tests := []struct {
+		sizing  *blockSizing
+		name    string
+		maxRows uint64
+	}{
+		{name: "fixed", maxRows: rows},
+		{name: "adaptive", sizing: newBlockSizing(256, 4096, maxUncompressedBlockSize), maxRows: 4096},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp := generateMemPart()
+			defer releaseMemPart(mp)
+			mp.sizing = tt.sizing
+			mp.mustInitFromElements(es)
+			p := openMemPart(mp)
+			defer p.close()
+
+			var pi partIter
+			pi.init(p, []common.SeriesID{1}, math.MinInt64, math.MaxInt64)
+			var blocks int
+			var count uint64
+			for pi.nextBlock() {
+				blocks++
+				count += pi.curBlock.count
+				assert.LessOrEqual(t, pi.curBlock.count, tt.maxRows)
+			}
+			require.NoError(t, pi.error())
+			assert.Equal(t, uint64(rows), count)
+			if tt.sizing != nil {
+				assert.Greater(t, blocks, 1)
+			}
+
+			for _, ts := range []common.ItemID{1, rows / 2, rows} {
+				e, _, err := p.getElement(1, ts, nil)
+				require.NoError(t, err, "the element at %d is found in any block of the series", ts)
+				assert.Equal(t, int64(ts), e.timestamp)
+			}
+		})
+	}
+}
+
+func Test_mergeParts_blockSizing(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	var pp []*partWrapper
+	defer func() {
+		for _, pw := range pp {
+			pw.decRef()
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		es := generateWideEs(1000, 1)
+		for j := range es.timestamps {
+			es.timestamps[j] += int64(i * 1000)
+		}
+		mp := generateMemPart()
+		mp.mustInitFromElements(es)
+		pp = append(pp, newPartWrapper(mp, openMemPart(mp)))
+	}
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	p, _, err := mergeParts(fs.NewLocalFileSystem(), closeCh, pp, 4, tmpPath, newBlockSizing(256, 1500, maxUncompressedBlockSize), encoding.CodecZSTD, fs.IOOptions{})
+	require.NoError(t, err)
+	defer p.decRef()
+
+	var pi partIter
+	pi.init(p.p, []common.SeriesID{1}, math.MinInt64, math.MaxInt64)
+	var counts []uint64
+	for pi.nextBlock() {
+		counts = append(counts, pi.curBlock.count)
+	}
+	require.NoError(t, pi.error())
+	// the blocks are merged until they are full, then they are written as a whole
+	assert.Equal(t, []uint64{2000, 1000}, counts)
+}
+
+// Benchmark_blockSizing compares the point lookups and the scans of a narrow and a wide stream
+// in the blocks cut by bytes alone and in the ones cut by the adaptive sizing.
+func Benchmark_blockSizing(b *testing.B) {
+	streams := []struct {
+		name     string
+		rows     int
+		families int
+	}{
+		{name: "narrow", rows: 200000, families: 1},
+		{name: "wide", rows: 2048, families: 400},
+	}
+	for _, s := range streams {
+		es := generateWideEs(s.rows, s.families)
+		opts := queryOptions{minTimestamp: 1, maxTimestamp: int64(s.rows)}
+		opts.TagProjection = wideTagProjection(s.families)
+		for _, sizing := range []struct {
+			bs   *blockSizing
+			name string
+		}{
+			{name: "fixed"},
+			{name: "adaptive", bs: newBlockSizing(defaultBlockMinRows, defaultBlockMaxRows, maxUncompressedBlockSize)},
+		} {
+			mp := generateMemPart()
+			mp.sizing = sizing.bs
+			mp.mustInitFromElements(es)
+			p := openMemPart(mp)
+			b.Run(fmt.Sprintf("%s/%s/lookup", s.name, sizing.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					ts := common.ItemID(i%s.rows + 1)
+					if _, _, err := p.getElement(1, ts, opts.TagProjection); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.Run(fmt.Sprintf("%s/%s/scan", s.name, sizing.name), func(b *testing.B) {
+				b.ReportAllocs()
+				tmpBlock := generateBlock()
+				defer releaseBlock(tmpBlock)
+				for i := 0; i < b.N; i++ {
+					var pi partIter
+					pi.init(p, []common.SeriesID{1}, opts.minTimestamp, opts.maxTimestamp)
+					for pi.nextBlock() {
+						bc := generateBlockCursor()
+						bc.init(p, pi.curBlock, opts)
+						if _, err := bc.loadData(context.Background(), tmpBlock, nil); err != nil {
+							b.Fatal(err)
+						}
+						releaseBlockCursor(bc)
+					}
+					if err := pi.error(); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.SetBytes(int64(mp.partMetadata.UncompressedSizeBytes))
+			})
+			p.close()
+			releaseMemPart(mp)
+		}
+	}
+}
@@ -18,19 +18,30 @@
 package stream
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/binary"
+	"fmt"
 	"reflect"
+	"runtime"
+	"strconv"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/testing/protocmp"
 
 	"github.com/apache/skywalking-banyandb/api/common"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
 	"github.com/apache/skywalking-banyandb/pkg/bytes"
 	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/test"
 )
 
 func Test_block_reset(t *testing.T) {
@@ -243,9 +254,10 @@ func Test_mustWriteAndReadTimestamps(t *testing.T) {
 			w := new(writer)
 			w.init(b)
 			mustWriteTimestampsTo(tm, tt.args, w)
-			timestamps := mustReadTimestampsFrom(nil, tm, len(tt.args), b)
+			timestamps, err := readTimestampsFrom(nil, tm, len(tt.args), b)
+			require.NoError(t, err)
 			if !reflect.DeepEqual(timestamps, tt.args) {
-				t.Errorf("mustReadTimestampsFrom() = %v, want %v", timestamps, tt.args)
+				t.Errorf("readTimestampsFrom() = %v, want %v", timestamps, tt.args)
 			}
 		})
 	}
@@ -277,9 +289,10 @@ func Test_mustWriteAndReadElementIDs(t *testing.T) {
 			w := new(writer)
 			w.init(b)
 			mustWriteElementIDsTo(em, tt.args, w)
-			elementIDs := mustReadElementIDsFrom(nil, em, len(tt.args), b)
+			elementIDs, err := readElementIDsFrom(nil, em, len(tt.args), b)
+			require.NoError(t, err)
 			if !reflect.DeepEqual(elementIDs, tt.args) {
-				t.Errorf("mustReadElementIDsFrom() = %v, want %v", elementIDs, tt.args)
+				t.Errorf("readElementIDsFrom() = %v, want %v", elementIDs, tt.args)
 			}
 		})
 	}
@@ -330,7 +343,7 @@ func Test_marshalAndUnmarshalTagFamily(t *testing.T) {
 	unmarshaled.timestamps = make([]int64, len(b.timestamps))
 	unmarshaled.resizeTagFamilies(1)
 
-	unmarshaled.unmarshalTagFamily(decoder, tfIndex, name, bm.getTagFamilyMetadata(name), tagProjection[name], metaBuffer, dataBuffer)
+	require.NoError(t, unmarshaled.unmarshalTagFamily(decoder, tfIndex, name, bm.getTagFamilyMetadata(name), tagProjection[name], metaBuffer, dataBuffer))
 
 	if diff := cmp.Diff(unmarshaled.tagFamilies[0], b.tagFamilies[0],
 		cmp.AllowUnexported(tagFamily{}, tag{}),
@@ -350,7 +363,7 @@ func Test_marshalAndUnmarshalTagFamily(t *testing.T) {
 	defer releaseSeqReader(valueReader)
 	valueReader.init(dataBuffer)
 
-	unmarshaled2.unmarshalTagFamilyFromSeqReaders(decoder, tfIndex, name, bm.getTagFamilyMetadata(name), metaReader, valueReader)
+	require.NoError(t, unmarshaled2.unmarshalTagFamilyFromSeqReaders(decoder, tfIndex, name, bm.getTagFamilyMetadata(name), metaReader, valueReader, nil))
 
 	if diff := cmp.Diff(unmarshaled2.tagFamilies[0], b.tagFamilies[0],
 		cmp.AllowUnexported(tagFamily{}, tag{}),
@@ -359,6 +372,97 @@ func Test_marshalAndUnmarshalTagFamily(t *testing.T) {
 	}
 }
 
+func Test_block_mustInitFromElementsRejectsDuplicatedTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tff  []tagValues
+	}{
+		{
+			name: "duplicated tag families",
+			tff: []tagValues{
+				{tag: "arrTag", values: []*tagValue{{tag: "strArrTag", valueType: pbv1.ValueTypeStrArr}}},
+				{tag: "arrTag", values: []*tagValue{{tag: "intArrTag", valueType: pbv1.ValueTypeInt64Arr}}},
+			},
+		},
+		{
+			name: "duplicated tags",
+			tff: []tagValues{
+				{tag: "singleTag", values: []*tagValue{
+					{tag: "strTag", valueType: pbv1.ValueTypeStr, value: []byte("a")},
+					{tag: "intTag", valueType: pbv1.ValueTypeInt64, value: convert.Int64ToBytes(1)},
+					{tag: "strTag", valueType: pbv1.ValueTypeStr, value: []byte("b")},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDuplicatedTags(tt.tff)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, errDuplicatedTag))
+			b := &block{}
+			assert.Panics(t, func() {
+				b.mustInitFromElements([]int64{1}, []string{"0"}, [][]tagValues{tt.tff})
+			})
+		})
+	}
+}
+
+func Test_unmarshalTagFamilyWithDuplicatedTags(t *testing.T) {
+	metaBuffer, dataBuffer := &bytes.Buffer{}, &bytes.Buffer{}
+	ww := &writers{
+		mustCreateTagFamilyWriters: func(name string) (fs.Writer, fs.Writer) {
+			return metaBuffer, dataBuffer
+		},
+		tagFamilyMetadataWriters: make(map[string]*writer),
+		tagFamilyWriters:         make(map[string]*writer),
+	}
+	// the block is built by hand since the elements with the duplicated tags are rejected now,
+	// while the parts written by the earlier versions might have them.
+	b := &block{
+		timestamps: []int64{1, 2},
+		elementIDs: []string{"0", "1"},
+		tagFamilies: []tagFamily{{
+			name: "singleTag",
+			tags: []tag{
+				{name: "strTag", valueType: pbv1.ValueTypeStr, values: [][]byte{[]byte("first0"), []byte("first1")}},
+				{name: "intTag", valueType: pbv1.ValueTypeInt64, values: [][]byte{convert.Int64ToBytes(1), convert.Int64ToBytes(2)}},
+				{name: "strTag", valueType: pbv1.ValueTypeStr, values: [][]byte{[]byte("second0"), []byte("second1")}},
+			},
+		}},
+	}
+	want := tagFamily{name: "singleTag", tags: b.tagFamilies[0].tags[:2]}
+	name := "singleTag"
+	decoder := &encoding.BytesBlockDecoder{}
+	bm := &blockMetadata{}
+	b.marshalTagFamily(b.tagFamilies[0], bm, ww)
+
+	unmarshaled := generateBlock()
+	defer releaseBlock(unmarshaled)
+	unmarshaled.timestamps = make([]int64, len(b.timestamps))
+	unmarshaled.resizeTagFamilies(1)
+	require.NoError(t, unmarshaled.unmarshalTagFamily(decoder, 0, name, bm.getTagFamilyMetadata(name),
+		[]string{"strTag", "intTag"}, metaBuffer, dataBuffer))
+	if diff := cmp.Diff(unmarshaled.tagFamilies[0], want, cmp.AllowUnexported(tagFamily{}, tag{})); diff != "" {
+		t.Errorf("block.unmarshalTagFamily() (-got +want):\n%s", diff)
+	}
+
+	merged := generateBlock()
+	defer releaseBlock(merged)
+	merged.timestamps = make([]int64, len(b.timestamps))
+	merged.resizeTagFamilies(1)
+	metaReader := generateSeqReader()
+	defer releaseSeqReader(metaReader)
+	metaReader.init(metaBuffer)
+	valueReader := generateSeqReader()
+	defer releaseSeqReader(valueReader)
+	valueReader.init(dataBuffer)
+	require.NoError(t, merged.unmarshalTagFamilyFromSeqReaders(decoder, 0, name, bm.getTagFamilyMetadata(name), metaReader, valueReader, nil))
+	if diff := cmp.Diff(merged.tagFamilies[0], want, cmp.AllowUnexported(tagFamily{}, tag{})); diff != "" {
+		t.Errorf("block.unmarshalTagFamilyFromSeqReaders() (-got +want):\n%s", diff)
+	}
+}
+
 func Test_marshalAndUnmarshalBlock(t *testing.T) {
 	timestampBuffer, elementIDsBuffer := &bytes.Buffer{}, &bytes.Buffer{}
 	timestampWriter, elementIDsWriter := &writer{}, &writer{}
@@ -407,12 +511,12 @@ func Test_marshalAndUnmarshalBlock(t *testing.T) {
 		})
 	}
 	bm.tagProjection = tp
-	unmarshaled.mustReadFrom(decoder, p, bm)
+	require.NoError(t, unmarshaled.readFrom(decoder, p, bm))
 	// blockMetadata is using a map, so the order of tag families is not guaranteed
 	unmarshaled.sortTagFamilies()
 
 	if !reflect.DeepEqual(b, unmarshaled) {
-		t.Errorf("block.mustReadFrom() = %+v, want %+v", unmarshaled, b)
+		t.Errorf("block.readFrom() = %+v, want %+v", unmarshaled, b)
 	}
 
 	unmarshaled2 := generateBlock()
@@ -421,9 +525,131 @@ func Test_marshalAndUnmarshalBlock(t *testing.T) {
 	sr.init(p)
 	defer sr.reset()
 
-	unmarshaled2.mustSeqReadFrom(decoder, &sr, bm)
+	require.NoError(t, unmarshaled2.seqReadFrom(decoder, &sr, bm))
 	if !reflect.DeepEqual(b, unmarshaled2) {
-		t.Errorf("block.mustSeqReadFrom() = %+v, want %+v", unmarshaled, b)
+		t.Errorf("block.seqReadFrom() = %+v, want %+v", unmarshaled, b)
+	}
+}
+
+func Test_block_uncompressedSizeBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		b    block
+		want uint64
+	}{
+		{
+			name: "timestamps only",
+			b:    block{timestamps: []int64{1, 2, 3}, elementIDs: []string{"0", "1", "2"}},
+			want: 3 * timestampSizeBytes,
+		},
+		{
+			name: "several tags in a family",
+			b: block{
+				timestamps: []int64{1, 2},
+				elementIDs: []string{"0", "1"},
+				tagFamilies: []tagFamily{
+					{
+						name: "family",
+						tags: []tag{
+							{name: "a", values: [][]byte{[]byte("1234"), []byte("12")}},
+							{name: "bb", values: [][]byte{[]byte("1"), []byte("123")}},
+							{name: "ccc", values: [][]byte{[]byte("12345"), []byte("1")}},
+						},
+					},
+				},
+			},
+			// every value is charged the family name and its own tag name only
+			want: 2*timestampSizeBytes + 2*(6+1) + 6 + 2*(6+2) + 4 + 2*(6+3) + 6,
+		},
+		{
+			name: "null values",
+			b: block{
+				timestamps: []int64{1, 2, 3},
+				elementIDs: []string{"0", "1", "2"},
+				tagFamilies: []tagFamily{
+					{
+						name: "f1",
+						tags: []tag{
+							{name: "t1", values: [][]byte{nil, []byte("12"), nil}},
+						},
+					},
+					{
+						name: "f2",
+						tags: []tag{
+							{name: "t2", values: [][]byte{nil, nil, nil}},
+							{name: "t3", values: [][]byte{[]byte("1"), nil, []byte("1")}},
+						},
+					},
+				},
+			},
+			want: 3*timestampSizeBytes + (4 + 2) + 2*(4+1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.b.uncompressedSizeBytes())
+		})
+	}
+}
+
+// Test_block_uncompressedSizeBytesAgainstWrittenBytes checks the size model of the blocks against
+// the bytes mustWriteTo writes. The values are random, so that the compression doesn't shrink them.
+func Test_block_uncompressedSizeBytesAgainstWrittenBytes(t *testing.T) {
+	const tolerance = 0.2
+	randomBytes := func(n int) []byte {
+		b := make([]byte, n)
+		_, err := rand.Read(b)
+		require.NoError(t, err)
+		return b
+	}
+	newBlock := func(elements, tagFamilies, tagsPerFamily, valueSize, nullEvery int) *block {
+		b := &block{}
+		for i := 0; i < elements; i++ {
+			b.timestamps = append(b.timestamps, int64(i*1000))
+			b.elementIDs = append(b.elementIDs, strconv.Itoa(i))
+		}
+		for i := 0; i < tagFamilies; i++ {
+			tf := tagFamily{name: "tag_family_" + strconv.Itoa(i)}
+			for j := 0; j < tagsPerFamily; j++ {
+				tg := tag{name: "tag_name_" + strconv.Itoa(j), valueType: pbv1.ValueTypeBinaryData}
+				for k := 0; k < elements; k++ {
+					if nullEvery > 0 && k%nullEvery == 0 {
+						tg.values = append(tg.values, nil)
+						continue
+					}
+					tg.values = append(tg.values, randomBytes(valueSize))
+				}
+				tf.tags = append(tf.tags, tg)
+			}
+			b.tagFamilies = append(b.tagFamilies, tf)
+		}
+		return b
+	}
+	tests := []struct {
+		b    *block
+		name string
+	}{
+		{name: "a wide family", b: newBlock(100, 1, 16, 256, 0)},
+		{name: "several families", b: newBlock(100, 4, 4, 512, 0)},
+		{name: "sparse values", b: newBlock(100, 2, 8, 1024, 2)},
+		{name: "a single tag", b: newBlock(1000, 1, 1, 512, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp := generateMemPart()
+			defer releaseMemPart(mp)
+			bw := generateBlockWriter()
+			defer releaseBlockWriter(bw)
+			bw.MustInitForMemPart(mp)
+			bm := generateBlockMetadata()
+			defer releaseBlockMetadata(bm)
+			tt.b.mustWriteTo(1, bm, &bw.writers)
+
+			written := float64(bw.writers.totalBytesWritten())
+			estimated := float64(bm.uncompressedSizeBytes)
+			require.InDelta(t, written, estimated, written*tolerance,
+				"the estimated size %.0f is too far from the written size %.0f", estimated, written)
+		})
 	}
 }
 
@@ -730,3 +956,480 @@ func Test_blockPointer_copyFrom(t *testing.T) {
 		})
 	}
 }
+
+func Benchmark_blockCursor_loadData(b *testing.B) {
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.mustInitFromElements(generateHugeEs(1, 5000, 1))
+	p := openMemPart(mp)
+	defer p.close()
+	opts := queryOptions{
+		minTimestamp: 1,
+		maxTimestamp: 5000,
+	}
+	opts.TagProjection = []pbv1.TagProjection{
+		{Family: "arrTag", Names: []string{"strArrTag", "intArrTag"}},
+		{Family: "binaryTag", Names: []string{"binaryTag"}},
+		{Family: "singleTag", Names: []string{"strTag", "intTag"}},
+	}
+	tmpBlock := generateBlock()
+	defer releaseBlock(tmpBlock)
+
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	pauseTotalNs, numGC := ms.PauseTotalNs, ms.NumGC
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pi := partIter{}
+		pi.init(p, []common.SeriesID{1}, opts.minTimestamp, opts.maxTimestamp)
+		for pi.nextBlock() {
+			bc := generateBlockCursor()
+			bc.init(p, pi.curBlock, opts)
+			if ok, _ := bc.loadData(context.Background(), tmpBlock, nil); ok {
+				bc.copyAllTo(&pbv1.StreamResult{})
+			}
+			releaseBlockCursor(bc)
+		}
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&ms)
+	b.ReportMetric(float64(ms.PauseTotalNs-pauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+	b.ReportMetric(float64(ms.NumGC-numGC)/float64(b.N), "gc/op")
+}
+
+func Test_blockCursor_loadDataInParallel(t *testing.T) {
+	const families = 8
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.mustInitFromElements(generateWideEs(parallelDecodeMinElements*2, families))
+	p := openMemPart(mp)
+	defer p.close()
+	opts := queryOptions{
+		minTimestamp: 1,
+		maxTimestamp: parallelDecodeMinElements * 2,
+	}
+	opts.TagProjection = wideTagProjection(families)
+
+	load := func(workers workerBudget) *pbv1.StreamResult {
+		tmpBlock := generateBlock()
+		defer releaseBlock(tmpBlock)
+		result := &pbv1.StreamResult{}
+		pi := partIter{}
+		pi.init(p, []common.SeriesID{1}, opts.minTimestamp, opts.maxTimestamp)
+		for pi.nextBlock() {
+			bc := generateBlockCursor()
+			bc.init(p, pi.curBlock, opts)
+			ok, err := bc.loadData(context.Background(), tmpBlock, workers)
+			require.NoError(t, err)
+			if ok {
+				bc.copyAllTo(result)
+			}
+			releaseBlockCursor(bc)
+		}
+		return result
+	}
+	want := load(nil)
+	if len(want.Timestamps) != parallelDecodeMinElements*2 {
+		t.Fatalf("unexpected number of elements: got %d", len(want.Timestamps))
+	}
+	for _, workers := range []int{2, 4, families + 1} {
+		if diff := cmp.Diff(want, load(newWorkerBudget(workers)), protocmp.Transform()); diff != "" {
+			t.Errorf("unexpected result decoded by %d workers (-want +got):\n%s", workers, diff)
+		}
+	}
+}
+
+func Test_blockCursor_loadDataByIOURing(t *testing.T) {
+	const families = 8
+	ring, err := fs.NewIOURing(1, 4)
+	if err != nil {
+		t.Skipf("io_uring is unavailable: %v", err)
+	}
+	defer ring.Close()
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.mustInitFromElements(generateWideEs(1000, families))
+	mp.mustFlush(fs.NewLocalFileSystem(), partPath(tmpPath, 1))
+	opts := queryOptions{
+		minTimestamp: 1,
+		maxTimestamp: 1000,
+	}
+	opts.TagProjection = wideTagProjection(families)
+
+	// the reads of a block outnumber the entries of the ring
+	load := func(fileSystem fs.FileSystem) *pbv1.StreamResult {
+		p := mustOpenFilePart(1, tmpPath, fileSystem)
+		defer p.close()
+		tmpBlock := generateBlock()
+		defer releaseBlock(tmpBlock)
+		result := &pbv1.StreamResult{}
+		pi := partIter{}
+		pi.init(p, []common.SeriesID{1}, opts.minTimestamp, opts.maxTimestamp)
+		for pi.nextBlock() {
+			bc := generateBlockCursor()
+			bc.init(p, pi.curBlock, opts)
+			ok, err := bc.loadData(context.Background(), tmpBlock, nil)
+			require.NoError(t, err)
+			if ok {
+				bc.copyAllTo(result)
+			}
+			releaseBlockCursor(bc)
+		}
+		require.NoError(t, pi.error())
+		return result
+	}
+	want := load(fs.NewLocalFileSystem())
+	require.Len(t, want.Timestamps, 1000)
+	if diff := cmp.Diff(want, load(fs.NewLocalFileSystemWithIOURing(logger.GetLogger("test"), ring)), protocmp.Transform()); diff != "" {
+		t.Errorf("unexpected result read by io_uring (-want +got):\n%s", diff)
+	}
+}
+
+func Test_blockCursor_loadDataCanceled(t *testing.T) {
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.mustInitFromElements(esTS1)
+	p := openMemPart(mp)
+	defer p.close()
+	opts := queryOptions{
+		minTimestamp: 1,
+		maxTimestamp: 1,
+	}
+	opts.TagProjection = tagProjections[1]
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tmpBlock := generateBlock()
+	defer releaseBlock(tmpBlock)
+	pi := partIter{}
+	pi.init(p, []common.SeriesID{1, 2, 3}, opts.minTimestamp, opts.maxTimestamp)
+	require.True(t, pi.nextBlock())
+	bc := generateBlockCursor()
+	defer releaseBlockCursor(bc)
+	bc.init(p, pi.curBlock, opts)
+	ok, err := bc.loadData(ctx, tmpBlock, nil)
+	require.ErrorIs(t, err, context.Canceled)
+	require.False(t, ok)
+	require.Empty(t, bc.timestamps)
+	require.Empty(t, bc.tagFamilies)
+}
+
+func Test_blockCursor_loadDataProjection(t *testing.T) {
+	// the part is written before the schema adds the tag "newTag" and the tag family "newFamily"
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.mustInitFromElements(&elements{
+		seriesIDs:  []common.SeriesID{1, 1},
+		timestamps: []int64{1, 2},
+		elementIDs: []string{"0", "1"},
+		tagFamilies: [][]tagValues{
+			{{tag: "singleTag", values: []*tagValue{
+				{tag: "strTag", valueType: pbv1.ValueTypeStr, value: []byte("a")},
+				{tag: "intTag", valueType: pbv1.ValueTypeInt64, value: convert.Int64ToBytes(1)},
+			}}},
+			{{tag: "singleTag", values: []*tagValue{
+				{tag: "strTag", valueType: pbv1.ValueTypeStr, value: []byte("b")},
+				{tag: "intTag", valueType: pbv1.ValueTypeInt64, value: convert.Int64ToBytes(2)},
+			}}},
+		},
+	})
+	p := openMemPart(mp)
+	defer p.close()
+
+	str := func(v string) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: v}}}
+	}
+	int64Value := func(v int64) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: v}}}
+	}
+	strTag := pbv1.Tag{Name: "strTag", Values: []*modelv1.TagValue{str("a"), str("b")}}
+	intTag := pbv1.Tag{Name: "intTag", Values: []*modelv1.TagValue{int64Value(1), int64Value(2)}}
+	newTag := pbv1.Tag{Name: "newTag", Values: []*modelv1.TagValue{pbv1.NullTagValue, pbv1.NullTagValue}}
+	retypedTag := pbv1.Tag{Name: "intTag", Values: []*modelv1.TagValue{pbv1.NullTagValue, pbv1.NullTagValue}}
+	tests := []struct {
+		tagTypes       map[string]pbv1.ValueType
+		name           string
+		projection     []pbv1.TagProjection
+		want           []pbv1.TagFamily
+		schemaRevision int64
+	}{
+		{
+			name:       "superset",
+			projection: []pbv1.TagProjection{{Family: "singleTag", Names: []string{"strTag", "newTag", "intTag"}}},
+			want:       []pbv1.TagFamily{{Name: "singleTag", Tags: []pbv1.Tag{strTag, newTag, intTag}}},
+		},
+		{
+			name:       "subset",
+			projection: []pbv1.TagProjection{{Family: "singleTag", Names: []string{"intTag"}}},
+			want:       []pbv1.TagFamily{{Name: "singleTag", Tags: []pbv1.Tag{intTag}}},
+		},
+		{
+			name:       "reordering",
+			projection: []pbv1.TagProjection{{Family: "singleTag", Names: []string{"intTag", "strTag"}}},
+			want:       []pbv1.TagFamily{{Name: "singleTag", Tags: []pbv1.Tag{intTag, strTag}}},
+		},
+		{
+			name: "missing tag family",
+			projection: []pbv1.TagProjection{
+				{Family: "newFamily", Names: []string{"newTag"}},
+				{Family: "singleTag", Names: []string{"newTag", "strTag"}},
+			},
+			want: []pbv1.TagFamily{
+				{Name: "newFamily", Tags: []pbv1.Tag{newTag}},
+				{Name: "singleTag", Tags: []pbv1.Tag{newTag, strTag}},
+			},
+		},
+		{
+			name:           "retyped tag",
+			projection:     []pbv1.TagProjection{{Family: "singleTag", Names: []string{"strTag", "intTag"}}},
+			tagTypes:       map[string]pbv1.ValueType{"strTag": pbv1.ValueTypeStr, "intTag": pbv1.ValueTypeStr},
+			schemaRevision: 1,
+			want:           []pbv1.TagFamily{{Name: "singleTag", Tags: []pbv1.Tag{strTag, retypedTag}}},
+		},
+		{
+			name:       "part as new as the schema",
+			projection: []pbv1.TagProjection{{Family: "singleTag", Names: []string{"strTag", "intTag"}}},
+			tagTypes:   map[string]pbv1.ValueType{"strTag": pbv1.ValueTypeStr, "intTag": pbv1.ValueTypeStr},
+			want:       []pbv1.TagFamily{{Name: "singleTag", Tags: []pbv1.Tag{strTag, intTag}}},
+		},
+	}
+	// the block is shared by the cases to catch the tags left by the previous one
+	tmpBlock := generateBlock()
+	defer releaseBlock(tmpBlock)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := queryOptions{
+				tagTypes:       tt.tagTypes,
+				minTimestamp:   1,
+				maxTimestamp:   2,
+				schemaRevision: tt.schemaRevision,
+			}
+			opts.TagProjection = tt.projection
+			result := &pbv1.StreamResult{}
+			pi := partIter{}
+			pi.init(p, []common.SeriesID{1}, opts.minTimestamp, opts.maxTimestamp)
+			for pi.nextBlock() {
+				bc := generateBlockCursor()
+				bc.init(p, pi.curBlock, opts)
+				ok, err := bc.loadData(context.Background(), tmpBlock, nil)
+				require.NoError(t, err)
+				require.True(t, ok)
+				bc.copyAllTo(result)
+				releaseBlockCursor(bc)
+			}
+			require.NoError(t, pi.error())
+			if diff := cmp.Diff(tt.want, result.TagFamilies, protocmp.Transform()); diff != "" {
+				t.Errorf("unexpected tag families (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_blockCursor_copyToAndCopyAllTo(t *testing.T) {
+	newBlockCursor := func(rows int) *blockCursor {
+		bc := &blockCursor{
+			bm:            blockMetadata{seriesID: 1},
+			tagProjection: []pbv1.TagProjection{{Family: "singleTag", Names: []string{"intTag"}}},
+			tagFamilies:   []tagFamily{{name: "singleTag", tags: []tag{{name: "intTag", valueType: pbv1.ValueTypeInt64}}}},
+		}
+		for i := 0; i < rows; i++ {
+			bc.timestamps = append(bc.timestamps, int64(i))
+			bc.elementIDs = append(bc.elementIDs, strconv.Itoa(i))
+			bc.tagFamilies[0].tags[0].values = append(bc.tagFamilies[0].tags[0].values, convert.Int64ToBytes(int64(i)))
+		}
+		return bc
+	}
+	// want returns the rows copied by the single-row copies, then the ones copied by copyAllTo
+	want := func(rows, copies int, desc bool) (single, all []int64) {
+		if desc {
+			for i := rows - 1; i >= rows-copies; i-- {
+				single = append(single, int64(i))
+			}
+			for i := 0; i < rows-copies; i++ {
+				all = append(all, int64(i))
+			}
+			return single, all
+		}
+		for i := 0; i < copies; i++ {
+			single = append(single, int64(i))
+		}
+		for i := copies; i < rows; i++ {
+			all = append(all, int64(i))
+		}
+		return single, all
+	}
+	for _, rows := range []int{0, 1, 5} {
+		for _, copies := range []int{0, 1, 3, 5} {
+			if copies > rows {
+				continue
+			}
+			for _, desc := range []bool{false, true} {
+				t.Run(fmt.Sprintf("rows=%d,copies=%d,desc=%t", rows, copies, desc), func(t *testing.T) {
+					bc := newBlockCursor(rows)
+					bc.initWindow(desc)
+					wantSingle, wantAll := want(rows, copies, desc)
+
+					single := &pbv1.StreamResult{}
+					for i := 0; i < copies; i++ {
+						require.False(t, bc.exhausted())
+						bc.copyTo(single)
+					}
+					assert.Equal(t, wantSingle, single.Timestamps)
+					assert.Equal(t, rows == copies, bc.exhausted())
+
+					all := &pbv1.StreamResult{}
+					bc.copyAllTo(all)
+					assert.Equal(t, wantAll, all.Timestamps)
+					assert.True(t, bc.exhausted())
+					for _, r := range []*pbv1.StreamResult{single, all} {
+						require.Len(t, r.ElementIDs, len(r.Timestamps))
+						for i, ts := range r.Timestamps {
+							assert.Equal(t, strconv.FormatInt(ts, 10), r.ElementIDs[i])
+							if len(r.TagFamilies) > 0 {
+								assert.Equal(t, ts, r.TagFamilies[0].Tags[0].Values[i].GetInt().GetValue())
+							}
+						}
+					}
+
+					again := &pbv1.StreamResult{}
+					bc.copyAllTo(again)
+					assert.Empty(t, again.Timestamps, "the rows are copied only once")
+				})
+			}
+		}
+	}
+}
+
+func Test_blockCursor_skipBefore(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     []int64
+		sid      common.SeriesID
+		left     uint32
+		wantLeft uint32
+		desc     bool
+	}{
+		{name: "lower series", sid: 1, want: []int64{3, 4}},
+		{name: "same series", sid: 2, left: 1, want: []int64{2, 3, 4}},
+		{name: "same series all dropped", sid: 2, left: 5, wantLeft: 3, want: []int64{3, 4}},
+		{name: "higher series", sid: 3, left: 1, wantLeft: 1, want: []int64{2, 2, 3, 4}},
+		{name: "desc lower series", sid: 1, desc: true, want: []int64{1, 0}},
+		{name: "desc same series", sid: 2, left: 1, desc: true, want: []int64{2, 1, 0}},
+		{name: "desc higher series", sid: 3, desc: true, want: []int64{2, 2, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := &blockCursor{bm: blockMetadata{seriesID: tt.sid}, timestamps: []int64{0, 1, 2, 2, 3, 4}}
+			bc.elementIDs = make([]string, len(bc.timestamps))
+			bc.initWindow(tt.desc)
+			left := tt.left
+			bc.skipBefore(&pbv1.Cursor{Timestamp: 2, SeriesID: 2}, &left)
+			assert.Equal(t, tt.wantLeft, left)
+			var got []int64
+			for !bc.exhausted() {
+				got = append(got, bc.timestamps[bc.take()])
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Benchmark_blockCursor_loadDataWide(b *testing.B) {
+	const families = 12
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.mustInitFromElements(generateWideEs(8192, families))
+	p := openMemPart(mp)
+	defer p.close()
+	opts := queryOptions{
+		minTimestamp: 1,
+		maxTimestamp: 8192,
+	}
+	opts.TagProjection = wideTagProjection(families)
+
+	for _, workers := range []int{1, runtime.GOMAXPROCS(0)} {
+		b.Run("workers="+strconv.Itoa(workers), func(b *testing.B) {
+			tmpBlock := generateBlock()
+			defer releaseBlock(tmpBlock)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pi := partIter{}
+				pi.init(p, []common.SeriesID{1}, opts.minTimestamp, opts.maxTimestamp)
+				for pi.nextBlock() {
+					bc := generateBlockCursor()
+					bc.init(p, pi.curBlock, opts)
+					_, _ = bc.loadData(context.Background(), tmpBlock, newWorkerBudget(workers))
+					releaseBlockCursor(bc)
+				}
+			}
+		})
+	}
+}
+
+func generateWideEs(count, families int) *elements {
+	es := &elements{}
+	for i := 1; i <= count; i++ {
+		es.seriesIDs = append(es.seriesIDs, 1)
+		es.timestamps = append(es.timestamps, int64(i))
+		es.elementIDs = append(es.elementIDs, strconv.Itoa(i))
+		tff := make([]tagValues, 0, families)
+		for j := 0; j < families; j++ {
+			tff = append(tff, tagValues{
+				tag: "family" + strconv.Itoa(j), values: []*tagValue{
+					{tag: "strTag", valueType: pbv1.ValueTypeStr, value: []byte("value" + strconv.Itoa(i))},
+					{tag: "intTag", valueType: pbv1.ValueTypeInt64, value: convert.Int64ToBytes(int64(i))},
+				},
+			})
+		}
+		es.tagFamilies = append(es.tagFamilies, tff)
+	}
+	return es
+}
+
+func wideTagProjection(families int) []pbv1.TagProjection {
+	tp := make([]pbv1.TagProjection, 0, families)
+	for j := 0; j < families; j++ {
+		tp = append(tp, pbv1.TagProjection{Family: "family" + strconv.Itoa(j), Names: []string{"strTag", "intTag"}})
+	}
+	return tp
+}
+
+func Benchmark_blockCursor_copyAllTo(b *testing.B) {
+	const rows, tags = 8192, 20
+	bc := &blockCursor{}
+	tf := tagFamily{name: "benchmark"}
+	tp := pbv1.TagProjection{Family: "benchmark"}
+	for i := 0; i < rows; i++ {
+		bc.timestamps = append(bc.timestamps, int64(i))
+		bc.elementIDs = append(bc.elementIDs, strconv.Itoa(i))
+	}
+	for i := 0; i < tags; i++ {
+		t := tag{name: "tag" + strconv.Itoa(i), valueType: pbv1.ValueTypeStr}
+		if i%2 == 0 {
+			t.valueType = pbv1.ValueTypeInt64
+		}
+		for j := 0; j < rows; j++ {
+			if t.valueType == pbv1.ValueTypeInt64 {
+				t.values = append(t.values, convert.Int64ToBytes(int64(j)))
+			} else {
+				t.values = append(t.values, []byte("value"+strconv.Itoa(j)))
+			}
+		}
+		tf.tags = append(tf.tags, t)
+		tp.Names = append(tp.Names, t.name)
+	}
+	bc.tagFamilies = []tagFamily{tf}
+	bc.tagProjection = []pbv1.TagProjection{tp}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bc.initWindow(false)
+		bc.copyAllTo(&pbv1.StreamResult{})
+	}
+}
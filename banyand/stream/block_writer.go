@@ -19,10 +19,12 @@ package stream
 
 import (
 	"path/filepath"
+	"slices"
 	"sync"
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/pkg/compress/zstd"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 )
@@ -40,10 +42,15 @@ func (w *writer) reset() {
 }
 
 func (w *writer) init(wc fs.Writer) {
+	w.initWithOptions(wc, fs.IOOptions{})
+}
+
+// initWithOptions is init writing wc with the ioOpts.
+func (w *writer) initWithOptions(wc fs.Writer, ioOpts fs.IOOptions) {
 	w.reset()
 
 	w.w = wc
-	w.sw = wc.SequentialWrite()
+	w.sw = fs.SequentialWrite(wc, ioOpts)
 }
 
 func (w *writer) MustWrite(data []byte) {
@@ -66,10 +73,15 @@ type writers struct {
 	tagFamilyWriters           map[string]*writer
 	timestampsWriter           writer
 	elementIDsWriter           writer
+	tagCodec                   encoding.Codec
+	// ioOpts hints how the files of a file part are written, which are the zero value for a mem part.
+	ioOpts fs.IOOptions
 }
 
 func (sw *writers) reset() {
 	sw.mustCreateTagFamilyWriters = nil
+	sw.ioOpts = fs.IOOptions{}
+	sw.tagCodec = encoding.CodecZSTD
 	sw.metaWriter.reset()
 	sw.primaryWriter.reset()
 	sw.timestampsWriter.reset()
@@ -119,9 +131,9 @@ func (sw *writers) getTagMetadataWriterAndTagWriter(tagName string) (*writer, *w
 	}
 	hw, w := sw.mustCreateTagFamilyWriters(tagName)
 	thw = new(writer)
-	thw.init(hw)
+	thw.initWithOptions(hw, sw.ioOpts)
 	tw = new(writer)
-	tw.init(w)
+	tw.initWithOptions(w, sw.ioOpts)
 	sw.tagFamilyMetadataWriters[tagName] = thw
 	sw.tagFamilyWriters[tagName] = tw
 	return thw, tw
@@ -131,6 +143,7 @@ type blockWriter struct {
 	writers                    writers
 	metaData                   []byte
 	primaryBlockData           []byte
+	seriesStats                []seriesStats
 	primaryBlockMetadata       primaryBlockMetadata
 	totalBlocksCount           uint64
 	maxTimestamp               int64
@@ -160,6 +173,7 @@ func (bw *blockWriter) reset() {
 	bw.totalMaxTimestamp = 0
 	bw.primaryBlockData = bw.primaryBlockData[:0]
 	bw.metaData = bw.metaData[:0]
+	bw.seriesStats = bw.seriesStats[:0]
 	bw.primaryBlockMetadata.reset()
 }
 
@@ -170,19 +184,23 @@ func (bw *blockWriter) MustInitForMemPart(mp *memPart) {
 	bw.writers.primaryWriter.init(&mp.primary)
 	bw.writers.timestampsWriter.init(&mp.timestamps)
 	bw.writers.elementIDsWriter.init(&mp.elementIDs)
+	bw.writers.tagCodec = mp.tagCodec
 }
 
-func (bw *blockWriter) mustInitForFilePart(fileSystem fs.FileSystem, path string) {
+// mustInitForFilePart writes the blocks to the files of a new part at path with the ioOpts.
+func (bw *blockWriter) mustInitForFilePart(fileSystem fs.FileSystem, path string, tagCodec encoding.Codec, ioOpts fs.IOOptions) {
 	bw.reset()
+	bw.writers.tagCodec = tagCodec
+	bw.writers.ioOpts = ioOpts
 	fileSystem.MkdirPanicIfExist(path, dirPermission)
 	bw.writers.mustCreateTagFamilyWriters = func(name string) (fs.Writer, fs.Writer) {
 		return fs.MustCreateFile(fileSystem, filepath.Join(path, name+tagFamiliesMetadataFilenameExt), filePermission),
 			fs.MustCreateFile(fileSystem, filepath.Join(path, name+tagFamiliesFilenameExt), filePermission)
 	}
-	bw.writers.metaWriter.init(fs.MustCreateFile(fileSystem, filepath.Join(path, metaFilename), filePermission))
-	bw.writers.primaryWriter.init(fs.MustCreateFile(fileSystem, filepath.Join(path, primaryFilename), filePermission))
-	bw.writers.timestampsWriter.init(fs.MustCreateFile(fileSystem, filepath.Join(path, timestampsFilename), filePermission))
-	bw.writers.elementIDsWriter.init(fs.MustCreateFile(fileSystem, filepath.Join(path, elementIDsFilename), filePermission))
+	bw.writers.metaWriter.initWithOptions(fs.MustCreateFile(fileSystem, filepath.Join(path, metaFilename), filePermission), ioOpts)
+	bw.writers.primaryWriter.initWithOptions(fs.MustCreateFile(fileSystem, filepath.Join(path, primaryFilename), filePermission), ioOpts)
+	bw.writers.timestampsWriter.initWithOptions(fs.MustCreateFile(fileSystem, filepath.Join(path, timestampsFilename), filePermission), ioOpts)
+	bw.writers.elementIDsWriter.initWithOptions(fs.MustCreateFile(fileSystem, filepath.Join(path, elementIDsFilename), filePermission), ioOpts)
 }
 
 func (bw *blockWriter) MustWriteElements(sid common.SeriesID, timestamps []int64, elementIDs []string, tagFamilies [][]tagValues) {
@@ -200,6 +218,46 @@ func (bw *blockWriter) mustWriteBlock(sid common.SeriesID, b *block) {
 	if b.Len() == 0 {
 		return
 	}
+	bm := generateBlockMetadata()
+	written := bw.writers.totalBytesWritten()
+	b.mustWriteTo(sid, bm, &bw.writers)
+	bw.addBlockMetadata(bm, bw.writers.totalBytesWritten()-written)
+	releaseBlockMetadata(bm)
+}
+
+// mustWriteRawBlock writes the encoded data of the block as is, and rewrites the offsets of it in the metadata.
+func (bw *blockWriter) mustWriteRawBlock(rb *rawBlock) {
+	bm := &rb.bm
+	written := bw.writers.totalBytesWritten()
+	bm.timestamps.offset = bw.writers.timestampsWriter.bytesWritten
+	bw.writers.timestampsWriter.MustWrite(rb.timestamps)
+	bm.elementIDs.offset = bw.writers.elementIDsWriter.bytesWritten
+	bw.writers.elementIDsWriter.MustWrite(rb.elementIDs)
+
+	bb := bigValuePool.Acquire(0)
+	defer bigValuePool.Release(bb)
+	for i := range rb.tagFamilies {
+		rtf := &rb.tagFamilies[i]
+		hw, w := bw.writers.getTagMetadataWriterAndTagWriter(rtf.name)
+		values := rtf.values
+		for j := range rtf.tfm.tagMetadata {
+			tm := &rtf.tfm.tagMetadata[j]
+			tm.offset = w.bytesWritten
+			w.MustWrite(values[:tm.size])
+			values = values[tm.size:]
+		}
+		bb.Buf = rtf.tfm.marshal(bb.Buf[:0])
+		tfm := bm.getTagFamilyMetadata(rtf.name)
+		tfm.offset = hw.bytesWritten
+		tfm.size = uint64(len(bb.Buf))
+		hw.MustWrite(bb.Buf)
+	}
+	bw.addBlockMetadata(bm, bw.writers.totalBytesWritten()-written)
+}
+
+// addBlockMetadata adds the metadata of a block just written, whose data takes compressedSizeBytes.
+func (bw *blockWriter) addBlockMetadata(bm *blockMetadata, compressedSizeBytes uint64) {
+	sid := bm.seriesID
 	if sid < bw.sidLast {
 		logger.Panicf("the sid=%d cannot be smaller than the previously written sid=%d", sid, &bw.sidLast)
 	}
@@ -211,8 +269,6 @@ func (bw *blockWriter) mustWriteBlock(sid common.SeriesID, b *block) {
 	isSeenSid := sid == bw.sidLast
 	bw.sidLast = sid
 
-	bm := generateBlockMetadata()
-	b.mustWriteTo(sid, bm, &bw.writers)
 	th := &bm.timestamps
 	if bw.totalCount == 0 || th.min < bw.totalMinTimestamp {
 		bw.totalMinTimestamp = th.min
@@ -234,9 +290,9 @@ func (bw *blockWriter) mustWriteBlock(sid common.SeriesID, b *block) {
 	bw.totalUncompressedSizeBytes += bm.uncompressedSizeBytes
 	bw.totalCount += bm.count
 	bw.totalBlocksCount++
+	bw.seriesStats = appendSeriesStats(bw.seriesStats, sid, bm.count, compressedSizeBytes, bm.uncompressedSizeBytes)
 
 	bw.primaryBlockData = bm.marshal(bw.primaryBlockData)
-	releaseBlockMetadata(bm)
 	if len(bw.primaryBlockData) > maxUncompressedPrimaryBlockSize {
 		bw.mustFlushPrimaryBlock(bw.primaryBlockData)
 		bw.primaryBlockData = bw.primaryBlockData[:0]
@@ -260,10 +316,13 @@ func (bw *blockWriter) Flush(pm *partMetadata) {
 	pm.BlocksCount = bw.totalBlocksCount
 	pm.MinTimestamp = bw.totalMinTimestamp
 	pm.MaxTimestamp = bw.totalMaxTimestamp
+	pm.FormatVersion = currentPartFormatVersion
+	pm.TagCodec = bw.writers.tagCodec
+	pm.SeriesStats = slices.Clone(bw.seriesStats)
 
 	bw.mustFlushPrimaryBlock(bw.primaryBlockData)
 
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(len(bw.metaData))
 	bb.Buf = zstd.Compress(bb.Buf[:0], bw.metaData, 1)
 	bw.writers.metaWriter.MustWrite(bb.Buf)
 	bigValuePool.Release(bb)
@@ -0,0 +1,136 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+func Test_expired(t *testing.T) {
+	newPart := func(minSeq, maxSeq uint64) *partWrapper {
+		var p part
+		p.partMetadata.MinFlushSeq, p.partMetadata.FlushSeq = minSeq, maxSeq
+		return &partWrapper{p: &p}
+	}
+	tests := []struct {
+		pos     *modelv1.ChangePosition
+		name    string
+		parts   []*partWrapper
+		expired bool
+	}{
+		{
+			name:  "flushed parts",
+			pos:   &modelv1.ChangePosition{FlushSeq: 2},
+			parts: []*partWrapper{newPart(3, 3), newPart(4, 4)},
+		},
+		{
+			name:  "parts merged after the position",
+			pos:   &modelv1.ChangePosition{FlushSeq: 2},
+			parts: []*partWrapper{newPart(3, 5), newPart(6, 6)},
+		},
+		{
+			name:    "a part merged with the ones read",
+			pos:     &modelv1.ChangePosition{FlushSeq: 2},
+			parts:   []*partWrapper{newPart(1, 3)},
+			expired: true,
+		},
+		{
+			name:    "overlapping parts",
+			pos:     &modelv1.ChangePosition{FlushSeq: 2},
+			parts:   []*partWrapper{newPart(3, 5), newPart(4, 4)},
+			expired: true,
+		},
+		{
+			name:  "the part read partially",
+			pos:   &modelv1.ChangePosition{FlushSeq: 2, PartFlushSeq: 3, SeriesId: 1, Blocks: 1},
+			parts: []*partWrapper{newPart(3, 3), newPart(4, 4)},
+		},
+		{
+			name:    "the part read partially is merged",
+			pos:     &modelv1.ChangePosition{FlushSeq: 2, PartFlushSeq: 3, SeriesId: 1, Blocks: 1},
+			parts:   []*partWrapper{newPart(3, 4)},
+			expired: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expired, expired(tt.parts, tt.pos))
+		})
+	}
+}
+
+func Test_changeReader_readPart(t *testing.T) {
+	mp := generateMemPart()
+	mp.mustInitFromElements(esTS1)
+	mp.partMetadata.MinFlushSeq, mp.partMetadata.FlushSeq = 3, 3
+	pw := newPartWrapper(mp, openMemPart(mp))
+	defer pw.decRef()
+
+	s := &stream{schema: &databasev1.Stream{
+		TagFamilies: []*databasev1.TagFamilySpec{{Name: "singleTag", Tags: []*databasev1.TagSpec{{Name: "strTag", Type: databasev1.TagType_TAG_TYPE_STRING}}}},
+		Entity:      &databasev1.Entity{},
+	}}
+	newReader := func(limit int) *changeReader {
+		cr := &changeReader{
+			series: make(map[common.SeriesID]*changedSeries),
+			result: &streamv1.ChangesResponse{},
+			limit:  limit,
+		}
+		for _, sid := range []common.SeriesID{1, 2, 3} {
+			cr.series[sid] = &changedSeries{stream: s, series: &pbv1.Series{ID: sid}, projection: s.storedTagProjection()}
+			cr.sids = append(cr.sids, sid)
+		}
+		return cr
+	}
+	elementIDs := func(cr *changeReader) []string {
+		var ids []string
+		for _, e := range cr.result.Elements {
+			ids = append(ids, e.GetElement().GetElementId())
+		}
+		return ids
+	}
+
+	// the position records how far the part is read once the limit is reached
+	pos := &modelv1.ChangePosition{FlushSeq: 2}
+	cr := newReader(2)
+	done, err := cr.readPart(pw.p, pos)
+	require.NoError(t, err)
+	assert.False(t, done)
+	assert.Equal(t, []string{"11", "21"}, elementIDs(cr))
+	assert.True(t, proto.Equal(&modelv1.ChangePosition{FlushSeq: 2, PartFlushSeq: 3, SeriesId: 2, Blocks: 1}, pos))
+
+	// the part is resumed after the position
+	cr = newReader(2)
+	done, err = cr.readPart(pw.p, pos)
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, []string{"31"}, elementIDs(cr))
+	tags := cr.result.Elements[0].GetElement().GetTagFamilies()
+	require.Len(t, tags, 1)
+	assert.Equal(t, "strTag", tags[0].GetTags()[0].GetKey())
+}
@@ -0,0 +1,168 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/fs/remote"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+const (
+	coldTierCheckInterval = time.Minute
+	// coldTierBlockSize is the bytes of the ranges downloaded and cached at a time.
+	coldTierBlockSize        = 1 << 20
+	defaultColdTierAge       = 7 * 24 * time.Hour
+	defaultColdTierCacheSize = 1 << 30
+)
+
+var (
+	coldTierProvider      = observability.NewMeterProvider(observability.RootScope.SubScope("stream").SubScope("cold_tier"))
+	offloadedPartsCounter = coldTierProvider.Counter("offloaded_parts_total", "group")
+	offloadedBytesCounter = coldTierProvider.Counter("offloaded_bytes_total", "group")
+
+	_ storage.Offloader = (*tsTable)(nil)
+)
+
+// coldTierOptions are the flags of the cold tier, which is disabled if the destination of the store is empty.
+type coldTierOptions struct {
+	store     remote.Config
+	cachePath string
+	age       time.Duration
+	cacheSize run.Bytes
+}
+
+func (o *coldTierOptions) addFlags(flagS *run.FlagSet) {
+	o.store.AddFlags(flagS.FlagSet, "stream-cold", "the parts offloaded to the cold tier")
+	flagS.DurationVar(&o.age, "stream-cold-age", defaultColdTierAge,
+		"the age of the newest element of a part, beyond which the part is offloaded to the cold tier")
+	flagS.StringVar(&o.cachePath, "stream-cold-cache-path", "",
+		"the directory caching the blocks read from the cold tier, which is \"cold-cache\" under stream-root-path if it's empty")
+	o.cacheSize = defaultColdTierCacheSize
+	flagS.VarP(&o.cacheSize, "stream-cold-cache-size", "", "the max bytes of the blocks cached from the cold tier")
+}
+
+func (o *coldTierOptions) enabled() bool {
+	return o.store.Dest != ""
+}
+
+func (o *coldTierOptions) validate() error {
+	if !o.enabled() {
+		return nil
+	}
+	if o.age <= 0 {
+		return errors.New("stream-cold-age must be positive")
+	}
+	if o.cacheSize < coldTierBlockSize {
+		return errors.Errorf("stream-cold-cache-size must be at least %d bytes", coldTierBlockSize)
+	}
+	return nil
+}
+
+// open returns the cold tier keeping the parts offloaded from the root under the node in the store.
+func (o *coldTierOptions) open(local fs.FileSystem, root, node string) (*coldTier, error) {
+	store, err := o.store.Open()
+	if err != nil {
+		return nil, err
+	}
+	cache, err := remote.NewBlockCache(o.cachePath, coldTierBlockSize, int64(o.cacheSize))
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot create the cache of the cold tier")
+	}
+	return &coldTier{
+		fileSystem: remote.NewFileSystem(local, store, cache, root, node),
+		age:        o.age,
+		interval:   coldTierCheckInterval,
+	}, nil
+}
+
+// coldTier offloads the file parts whose elements are older than the age to the object store.
+// The offloaded parts are read through the block cache, and they aren't merged any more.
+type coldTier struct {
+	fileSystem *remote.FileSystem
+	age        time.Duration
+	// interval is how often the merger looks for the parts to offload.
+	interval time.Duration
+}
+
+// isHotFile reports whether a file of a part stays on the local disk once the part is offloaded,
+// which are read as the part is opened.
+func isHotFile(name string) bool {
+	return name == metadataFilename || name == metaFilename
+}
+
+// offloadColdParts offloads the file parts of the snapshot out of the age one by one.
+// Each offloaded part is reopened from the cold tier, which replaces the local one in the next snapshot.
+func (tst *tsTable) offloadColdParts(snp *snapshot, merges chan *mergerIntroduction) error {
+	cold := tst.option.cold
+	deadline := time.Now().Add(-cold.age).UnixNano()
+	closeCh := tst.loopCloser.CloseNotify()
+	for _, pw := range snp.parts {
+		if pw.mp != nil || pw.p.cold || pw.p.partMetadata.MaxTimestamp >= deadline {
+			continue
+		}
+		select {
+		case <-closeCh:
+			return errClosed
+		default:
+		}
+		size, err := cold.fileSystem.Offload(context.Background(), pw.p.path, func(name string) bool {
+			return !isHotFile(name)
+		})
+		if err != nil {
+			return errors.WithMessagef(err, "cannot offload the part %d", pw.ID())
+		}
+		p := mustOpenFilePart(pw.ID(), tst.root, tst.fileSystem)
+		mi := generateMergerIntroduction()
+		mi.creator = snapshotCreatorMerger
+		mi.merged = map[uint64]struct{}{pw.ID(): {}}
+		mi.newPart = newPartWrapper(nil, p)
+		mi.applied = make(chan struct{})
+		select {
+		case merges <- mi:
+		case <-closeCh:
+			releaseMergerIntroduction(mi)
+			return errClosed
+		}
+		select {
+		case <-mi.applied:
+		case <-closeCh:
+			releaseMergerIntroduction(mi)
+			return errClosed
+		}
+		releaseMergerIntroduction(mi)
+		offloadedPartsCounter.Inc(1, tst.p.Database)
+		offloadedBytesCounter.Inc(float64(size), tst.p.Database)
+		tst.l.Info().Uint64("part", pw.ID()).Uint64("size", size).Msg("offload the part to the cold tier")
+	}
+	return nil
+}
+
+// RemoveOffloaded removes the parts offloaded to the cold tier once the segment of the table is deleted.
+func (tst *tsTable) RemoveOffloaded() {
+	if tst.option.cold != nil {
+		tst.option.cold.fileSystem.RemoveOffloaded(tst.root)
+	}
+}
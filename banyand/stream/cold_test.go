@@ -0,0 +1,104 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/fs/remote"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/test/flags"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func Test_tsTable_coldTier(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	root := filepath.Join(tmpPath, "data")
+	store := remote.NewFileStore(filepath.Join(tmpPath, "remote"))
+	cache, err := remote.NewBlockCache(filepath.Join(tmpPath, "cache"), 64, 1<<20)
+	require.NoError(t, err)
+	cold := &coldTier{
+		fileSystem: remote.NewFileSystem(fs.NewLocalFileSystem(), store, cache, tmpPath, "node"),
+		age:        time.Hour,
+		interval:   10 * time.Millisecond,
+	}
+	newTable := func() *tsTable {
+		tst, errTable := newTSTable(fs.NewLocalFileSystem(), root, common.Position{Database: "default"}, logger.GetLogger("test"),
+			timestamp.TimeRange{}, option{flushTimeout: 10 * time.Millisecond, mergePolicy: newDefaultMergePolicyForTesting(), cold: cold})
+		require.NoError(t, errTable)
+		return tst
+	}
+	allCold := func(tst *tsTable) bool {
+		snp := tst.currentSnapshot()
+		if snp == nil {
+			return false
+		}
+		defer snp.decRef()
+		for _, pw := range snp.parts {
+			if pw.mp != nil || !pw.p.cold {
+				return false
+			}
+		}
+		return len(snp.parts) > 0
+	}
+
+	tst := newTable()
+	// the elements at 1 and 2 in nanoseconds are older than the age
+	require.NoError(t, tst.addElements(esTS1))
+	require.NoError(t, tst.addElements(esTS2))
+	require.Eventually(t, func() bool {
+		return allCold(tst)
+	}, flags.EventuallyTimeout, 10*time.Millisecond, "the flushed parts are offloaded")
+	keys, err := store.List(context.Background(), "node/")
+	require.NoError(t, err)
+	assert.NotEmpty(t, keys)
+	for _, ts := range []common.ItemID{1, 2} {
+		_, _, err = tst.getElement(3, ts, nil)
+		require.NoError(t, err, "the elements are read from the cold tier")
+	}
+	require.NoError(t, tst.Close())
+
+	tst = newTable()
+	assert.True(t, allCold(tst), "the offloaded parts are opened from the cold tier")
+	e, _, err := tst.getElement(2, 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "21", e.elementID)
+	snp := tst.currentSnapshot()
+	for _, pw := range snp.parts {
+		_, errStat := os.Stat(filepath.Join(pw.p.path, primaryFilename))
+		assert.True(t, os.IsNotExist(errStat), "the offloaded data isn't kept on the local disk")
+	}
+	snp.decRef()
+	require.NoError(t, tst.Close())
+
+	tst.RemoveOffloaded()
+	keys, err = store.List(context.Background(), "node/")
+	require.NoError(t, err)
+	assert.Empty(t, keys, "the offloaded parts are removed along with the segment")
+}
@@ -0,0 +1,365 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"slices"
+	"strings"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+// column is the typed view of the values of a tag. It's decoded by the first consumer of the tag,
+// and shared by the others until the tag is reset.
+// The elements of the arrays are flattened into int64s or strs, and bounded by offsets.
+type column struct {
+	int64s  []int64
+	strs    []string
+	nulls   []bool
+	offsets []int
+	decoded bool
+}
+
+func (c *column) reset() {
+	c.int64s = nil
+	c.strs = nil
+	c.nulls = nil
+	c.offsets = nil
+	c.decoded = false
+}
+
+// columnArena allocates the typed views of the tags a cursor loads. The views are sliced from its buffers,
+// which are truncated in reset and reused for the next block.
+type columnArena struct {
+	int64s  []int64
+	strs    []string
+	nulls   []bool
+	offsets []int
+	// scratch holds the elements of the string arrays which are unescaped.
+	scratch []byte
+}
+
+func (ca *columnArena) reset() {
+	clear(ca.strs)
+	ca.int64s = ca.int64s[:0]
+	ca.strs = ca.strs[:0]
+	ca.nulls = ca.nulls[:0]
+	ca.offsets = ca.offsets[:0]
+	ca.scratch = ca.scratch[:0]
+}
+
+func (ca *columnArena) capacity() int {
+	return (cap(ca.int64s)+cap(ca.offsets))*timestampSize + cap(ca.strs)*stringSize + cap(ca.nulls) + cap(ca.scratch)
+}
+
+// alloc returns n elements sliced from buf. The buffer is replaced by a larger one if it runs out,
+// the views sliced before keep the old one.
+func alloc[T any](buf *[]T, n int) []T {
+	b := *buf
+	if cap(b)-len(b) < n {
+		b = make([]T, 0, max(2*cap(b), n))
+	}
+	l := len(b)
+	b = b[:l+n]
+	*buf = b
+	return b[l : l+n : l+n]
+}
+
+func (t *tag) mustBeType(valueType pbv1.ValueType) {
+	if t.valueType != valueType {
+		logger.Panicf("tag %q is of the type %v, not %v", t.name, t.valueType, valueType)
+	}
+}
+
+// nullMask returns the null mask of the values, which is allocated by the arena if it's not nil.
+func (t *tag) nullMask() []bool {
+	if t.arena == nil {
+		return make([]bool, len(t.values))
+	}
+	return alloc(&t.arena.nulls, len(t.values))
+}
+
+// arrayOffsets returns the offsets of the arrays of the values, which is allocated by the arena if it's not nil.
+func (t *tag) arrayOffsets() []int {
+	if t.arena == nil {
+		return make([]int, len(t.values)+1)
+	}
+	return alloc(&t.arena.offsets, len(t.values)+1)
+}
+
+// AsInt64s returns the values of the int64 tag, and whether each one is null.
+// They are decoded once, and valid until the tag is reset.
+func (t *tag) AsInt64s() ([]int64, []bool) {
+	t.mustBeType(pbv1.ValueTypeInt64)
+	if !t.column.decoded {
+		if t.arena == nil {
+			t.column.int64s = make([]int64, len(t.values))
+		} else {
+			t.column.int64s = alloc(&t.arena.int64s, len(t.values))
+		}
+		t.column.nulls = t.nullMask()
+		for i, v := range t.values {
+			if v == nil {
+				t.column.int64s[i], t.column.nulls[i] = 0, true
+				continue
+			}
+			t.column.int64s[i], t.column.nulls[i] = convert.BytesToInt64(v), false
+		}
+		t.column.decoded = true
+	}
+	return t.column.int64s, t.column.nulls
+}
+
+// AsStrings returns the values of the string tag, and whether each one is null.
+// They are decoded once, and valid until the tag is reset since they refer to the values decoded.
+func (t *tag) AsStrings() ([]string, []bool) {
+	t.mustBeType(pbv1.ValueTypeStr)
+	if !t.column.decoded {
+		if t.arena == nil {
+			t.column.strs = make([]string, len(t.values))
+		} else {
+			t.column.strs = alloc(&t.arena.strs, len(t.values))
+		}
+		t.column.nulls = t.nullMask()
+		for i, v := range t.values {
+			t.column.strs[i], t.column.nulls[i] = convert.BytesToString(v), v == nil
+		}
+		t.column.decoded = true
+	}
+	return t.column.strs, t.column.nulls
+}
+
+// AsInt64Arrays returns the elements of the values of the int64 array tag, the elements of the i-th value are
+// elements[offsets[i]:offsets[i+1]]. A null value has no elements.
+// They are decoded once, and valid until the tag is reset.
+func (t *tag) AsInt64Arrays() (elements []int64, offsets []int) {
+	t.mustBeType(pbv1.ValueTypeInt64Arr)
+	if !t.column.decoded {
+		var n int
+		for _, v := range t.values {
+			if len(v)%8 != 0 {
+				logger.Panicf("tag %q has an int64 array of %d bytes", t.name, len(v))
+			}
+			n += len(v) / 8
+		}
+		if t.arena == nil {
+			t.column.int64s = make([]int64, n)
+		} else {
+			t.column.int64s = alloc(&t.arena.int64s, n)
+		}
+		t.column.offsets = t.arrayOffsets()
+		var k int
+		for i, v := range t.values {
+			t.column.offsets[i] = k
+			for ; len(v) > 0; v = v[8:] {
+				t.column.int64s[k] = convert.BytesToInt64(v[:8])
+				k++
+			}
+		}
+		t.column.offsets[len(t.values)] = k
+		t.column.decoded = true
+	}
+	return t.column.int64s, t.column.offsets
+}
+
+// AsStrArrays returns the elements of the values of the string array tag, see AsInt64Arrays.
+// The elements refer to the values decoded, except the escaped ones which are unescaped into the scratch of the arena.
+func (t *tag) AsStrArrays() (elements []string, offsets []int) {
+	t.mustBeType(pbv1.ValueTypeStrArr)
+	if !t.column.decoded {
+		var n int
+		for _, v := range t.values {
+			for len(v) > 0 {
+				_, rest, _, err := nextVarArrayElement(v)
+				if err != nil {
+					logger.Panicf("tag %q has an invalid string array: %v", t.name, err)
+				}
+				n++
+				v = rest
+			}
+		}
+		// the unescaped elements are appended to the scratch, which leaves the views before intact
+		scratch := new([]byte)
+		if t.arena == nil {
+			t.column.strs = make([]string, n)
+		} else {
+			t.column.strs = alloc(&t.arena.strs, n)
+			scratch = &t.arena.scratch
+		}
+		t.column.offsets = t.arrayOffsets()
+		var k int
+		for i, v := range t.values {
+			t.column.offsets[i] = k
+			for len(v) > 0 {
+				elem, rest, isEscaped, _ := nextVarArrayElement(v)
+				if isEscaped {
+					l := len(*scratch)
+					*scratch = unescapeVarArrayElement(*scratch, elem)
+					elem = (*scratch)[l:]
+				}
+				t.column.strs[k] = convert.BytesToString(elem)
+				k++
+				v = rest
+			}
+		}
+		t.column.offsets[len(t.values)] = k
+		t.column.decoded = true
+	}
+	return t.column.strs, t.column.offsets
+}
+
+// appendColumnValues appends the values of the tag in [from, to) to dst. The values of int64, string and array tags are
+// taken from their typed views, others are decoded by mustDecodeTagValues.
+func appendColumnValues(dst []*modelv1.TagValue, t *tag, from, to int) []*modelv1.TagValue {
+	if t.values == nil {
+		return appendNullTagValues(dst, to-from)
+	}
+	n := len(dst)
+	switch t.valueType {
+	case pbv1.ValueTypeInt64:
+		int64s, nulls := t.AsInt64s()
+		dst = slices.Grow(dst, to-from)[:n+to-from]
+		values := dst[n:]
+		tvs := make([]modelv1.TagValue, to-from)
+		ints := make([]modelv1.TagValue_Int, to-from)
+		int64Values := make([]modelv1.Int, to-from)
+		for i := range values {
+			if nulls[from+i] {
+				values[i] = mustDecodeTagValue(t.valueType, nil)
+				continue
+			}
+			int64Values[i].Value = int64s[from+i]
+			ints[i].Int = &int64Values[i]
+			tvs[i].Value = &ints[i]
+			values[i] = &tvs[i]
+		}
+	case pbv1.ValueTypeStr:
+		strs, nulls := t.AsStrings()
+		dst = slices.Grow(dst, to-from)[:n+to-from]
+		values := dst[n:]
+		tvs := make([]modelv1.TagValue, to-from)
+		strTagValues := make([]modelv1.TagValue_Str, to-from)
+		strValues := make([]modelv1.Str, to-from)
+		// the strings are copied out of the cursor in a single allocation
+		all := strings.Join(strs[from:to], "")
+		var offset int
+		for i := range values {
+			if nulls[from+i] {
+				values[i] = pbv1.EmptyStrTagValue
+				continue
+			}
+			s := strs[from+i]
+			strValues[i].Value = all[offset : offset+len(s)]
+			offset += len(s)
+			strTagValues[i].Str = &strValues[i]
+			tvs[i].Value = &strTagValues[i]
+			values[i] = &tvs[i]
+		}
+	case pbv1.ValueTypeInt64Arr:
+		elements, offsets := t.AsInt64Arrays()
+		dst = slices.Grow(dst, to-from)[:n+to-from]
+		values := dst[n:]
+		tvs := make([]modelv1.TagValue, to-from)
+		arrTagValues := make([]modelv1.TagValue_IntArray, to-from)
+		arrValues := make([]modelv1.IntArray, to-from)
+		// the elements are copied out of the cursor in a single allocation
+		all := slices.Clone(elements[offsets[from]:offsets[to]])
+		for i := range values {
+			start, end := offsets[from+i]-offsets[from], offsets[from+i+1]-offsets[from]
+			if start == end {
+				values[i] = pbv1.EmptyIntArrTagValue
+				continue
+			}
+			arrValues[i].Value = all[start:end:end]
+			arrTagValues[i].IntArray = &arrValues[i]
+			tvs[i].Value = &arrTagValues[i]
+			values[i] = &tvs[i]
+		}
+	case pbv1.ValueTypeStrArr:
+		elements, offsets := t.AsStrArrays()
+		dst = slices.Grow(dst, to-from)[:n+to-from]
+		values := dst[n:]
+		tvs := make([]modelv1.TagValue, to-from)
+		arrTagValues := make([]modelv1.TagValue_StrArray, to-from)
+		arrValues := make([]modelv1.StrArray, to-from)
+		strs := make([]string, offsets[to]-offsets[from])
+		all := strings.Join(elements[offsets[from]:offsets[to]], "")
+		var offset int
+		for i := range strs {
+			s := elements[offsets[from]+i]
+			strs[i] = all[offset : offset+len(s)]
+			offset += len(s)
+		}
+		for i := range values {
+			start, end := offsets[from+i]-offsets[from], offsets[from+i+1]-offsets[from]
+			if start == end {
+				values[i] = pbv1.EmptyStrArrTagValue
+				continue
+			}
+			arrValues[i].Value = strs[start:end:end]
+			arrTagValues[i].StrArray = &arrValues[i]
+			tvs[i].Value = &arrTagValues[i]
+			values[i] = &tvs[i]
+		}
+	default:
+		dst = mustDecodeTagValues(dst, t.valueType, t.values[from:to])
+	}
+	return dst
+}
+
+// columnValue returns the value of the tag at idx, see appendColumnValues.
+func columnValue(t *tag, idx int) *modelv1.TagValue {
+	if t.values == nil {
+		return pbv1.NullTagValue
+	}
+	switch t.valueType {
+	case pbv1.ValueTypeInt64:
+		int64s, nulls := t.AsInt64s()
+		if nulls[idx] {
+			return mustDecodeTagValue(t.valueType, nil)
+		}
+		return int64TagValue(int64s[idx])
+	case pbv1.ValueTypeStr:
+		strs, nulls := t.AsStrings()
+		if nulls[idx] {
+			return pbv1.EmptyStrTagValue
+		}
+		return strTagValue(strings.Clone(strs[idx]))
+	case pbv1.ValueTypeInt64Arr:
+		elements, offsets := t.AsInt64Arrays()
+		if offsets[idx] == offsets[idx+1] {
+			return pbv1.EmptyIntArrTagValue
+		}
+		return int64ArrTagValue(slices.Clone(elements[offsets[idx]:offsets[idx+1]]))
+	case pbv1.ValueTypeStrArr:
+		elements, offsets := t.AsStrArrays()
+		if offsets[idx] == offsets[idx+1] {
+			return pbv1.EmptyStrArrTagValue
+		}
+		strs := make([]string, offsets[idx+1]-offsets[idx])
+		for i := range strs {
+			strs[i] = strings.Clone(elements[offsets[idx]+i])
+		}
+		return strArrTagValue(strs)
+	default:
+		return mustDecodeTagValue(t.valueType, t.values[idx])
+	}
+}
@@ -0,0 +1,69 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"container/heap"
+
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+// PullBatch returns the rows left in the next cursor in a column batch, which are encoded from the loaded block
+// without being copied into tag values. The cursors are taken in the order Pull merges them,
+// but the rows of the batches aren't merged: the batches of a series may overlap in time.
+func (qr *queryResult) PullBatch() *streamv1.ColumnBatch {
+	qr.load()
+	for len(qr.data) > 0 && !qr.canceled() {
+		bc := heap.Pop(qr).(*blockCursor)
+		cb := bc.encodeBatch()
+		releaseBlockCursor(bc)
+		if cb != nil {
+			return cb
+		}
+	}
+	return nil
+}
+
+// encodeBatch encodes the rows in the window of the cursor in the ascending order of timestamps,
+// or returns nil if the window is empty.
+func (bc *blockCursor) encodeBatch() *streamv1.ColumnBatch {
+	from, to := bc.from, bc.to
+	if to <= from {
+		return nil
+	}
+	cb := pbv1.NewColumnBatch(bc.bm.seriesID, bc.timestamps[from:to], bc.elementIDs[from:to])
+	for i, tp := range bc.tagProjection {
+		tcf := &streamv1.TagColumnFamily{Name: tp.Family}
+		for j, name := range tp.Names {
+			var values [][]byte
+			var valueType pbv1.ValueType
+			if i < len(bc.tagFamilies) && j < len(bc.tagFamilies[i].tags) {
+				t := &bc.tagFamilies[i].tags[j]
+				if t.values != nil {
+					values, valueType = t.values[from:to], t.valueType
+				}
+			}
+			tcf.Columns = append(tcf.Columns, pbv1.EncodeTagColumn(name, valueType, values))
+		}
+		cb.TagFamilies = append(cb.TagFamilies, tcf)
+	}
+	bc.from = bc.to
+	bc.seek()
+	return cb
+}
@@ -0,0 +1,121 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+// Test_queryResult_PullBatch verifies the rows pulled in column batches are the rows pulled one by one.
+func Test_queryResult_PullBatch(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	tst, err := newTSTable(fs.NewLocalFileSystem(), tmpPath, common.Position{},
+		logger.GetLogger("test"), timestamp.TimeRange{}, option{flushTimeout: time.Hour, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer tst.Close()
+	for _, es := range []*elements{esTS1, esTS2, generateHugeEs(3, 5000, 6)} {
+		require.NoError(t, tst.addElements(es))
+	}
+	sids := []common.SeriesID{1, 2, 3}
+	qo := queryOptions{minTimestamp: 1, maxTimestamp: 4000}
+	// the tags missing from the blocks of a series are nulls
+	qo.TagProjection = []pbv1.TagProjection{
+		{Family: "arrTag", Names: []string{"strArrTag", "intArrTag"}},
+		{Family: "binaryTag", Names: []string{"binaryTag"}},
+		{Family: "singleTag", Names: []string{"strTag", "intTag", "strTag1", "strTag2"}},
+	}
+
+	rows := queryResult{orderByTS: true, ascTS: true}
+	defer rows.Release()
+	require.NoError(t, rows.selectBlocks([]*tsTable{tst}, sids, qo))
+	var want []pbv1.StreamResult
+	for r := rows.Pull(); r != nil; r = rows.Pull() {
+		want = append(want, *r)
+	}
+
+	columns := queryResult{orderByTS: true, ascTS: true}
+	defer columns.Release()
+	require.NoError(t, columns.selectBlocks([]*tsTable{tst}, sids, qo))
+	var got []pbv1.StreamResult
+	for cb := columns.PullBatch(); cb != nil; cb = columns.PullBatch() {
+		data, err := proto.Marshal(cb)
+		require.NoError(t, err)
+		received := &streamv1.ColumnBatch{}
+		require.NoError(t, proto.Unmarshal(data, received))
+		r, err := pbv1.DecodeColumnBatch(received)
+		require.NoError(t, err)
+		got = append(got, *r)
+	}
+
+	wantRows := flattenStreamResults(want)
+	assert.Len(t, wantRows, 3+3+3998+2)
+	if diff := cmp.Diff(wantRows, flattenStreamResults(got), protocmp.Transform(), cmp.AllowUnexported(streamRow{})); diff != "" {
+		t.Errorf("Unexpected rows (-want +got):\n%s", diff)
+	}
+}
+
+type streamRow struct {
+	elementID string
+	values    []*modelv1.TagValue
+	timestamp int64
+	sid       common.SeriesID
+}
+
+// flattenStreamResults returns the rows of the results in the order of their series and timestamps.
+func flattenStreamResults(rs []pbv1.StreamResult) []streamRow {
+	var rows []streamRow
+	for _, r := range rs {
+		for i := range r.Timestamps {
+			row := streamRow{sid: r.SID, timestamp: r.Timestamps[i], elementID: r.ElementIDs[i]}
+			for _, tf := range r.TagFamilies {
+				for _, t := range tf.Tags {
+					row.values = append(row.values, t.Values[i])
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].sid != rows[j].sid {
+			return rows[i].sid < rows[j].sid
+		}
+		if rows[i].timestamp != rows[j].timestamp {
+			return rows[i].timestamp < rows[j].timestamp
+		}
+		return rows[i].elementID < rows[j].elementID
+	})
+	return rows
+}
@@ -0,0 +1,160 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+func TestTagTypedColumns(t *testing.T) {
+	var arena columnArena
+	it := tag{
+		name:      "int",
+		valueType: pbv1.ValueTypeInt64,
+		values:    [][]byte{convert.Int64ToBytes(1), nil, convert.Int64ToBytes(-3)},
+		arena:     &arena,
+	}
+	int64s, nulls := it.AsInt64s()
+	assert.Equal(t, []int64{1, 0, -3}, int64s)
+	assert.Equal(t, []bool{false, true, false}, nulls)
+	it.values[0] = convert.Int64ToBytes(100)
+	int64s, _ = it.AsInt64s()
+	assert.Equal(t, int64(1), int64s[0], "the values are decoded once")
+	assert.Panics(t, func() { it.AsStrings() })
+
+	st := tag{
+		name:      "str",
+		valueType: pbv1.ValueTypeStr,
+		values:    [][]byte{[]byte("a"), nil, {}, []byte("bc")},
+		arena:     &arena,
+	}
+	strs, nulls := st.AsStrings()
+	assert.Equal(t, []string{"a", "", "", "bc"}, strs)
+	assert.Equal(t, []bool{false, true, false, false}, nulls)
+	assert.Len(t, arena.strs, 4, "the views are allocated by the arena")
+
+	capacity := arena.capacity()
+	arena.reset()
+	it.reset()
+	it.valueType = pbv1.ValueTypeInt64
+	it.values = [][]byte{convert.Int64ToBytes(2)}
+	it.arena = &arena
+	int64s, _ = it.AsInt64s()
+	assert.Equal(t, []int64{2}, int64s)
+	assert.Equal(t, capacity, arena.capacity(), "the buffers of the arena are reused")
+}
+
+func TestTagArrayColumns(t *testing.T) {
+	var arena columnArena
+	it := tag{
+		name:      "intArr",
+		valueType: pbv1.ValueTypeInt64Arr,
+		values:    [][]byte{encodeInt64Arr(1, 2), nil, encodeInt64Arr(3)},
+		arena:     &arena,
+	}
+	int64s, offsets := it.AsInt64Arrays()
+	assert.Equal(t, []int64{1, 2, 3}, int64s)
+	assert.Equal(t, []int{0, 2, 2, 3}, offsets)
+	assert.Panics(t, func() { it.AsStrArrays() })
+
+	st := tag{
+		name:      "strArr",
+		valueType: pbv1.ValueTypeStrArr,
+		values:    [][]byte{encodeStrArr("a|b", ""), nil, encodeStrArr("c"), encodeStrArr("d\\")},
+		arena:     &arena,
+	}
+	strs, offsets := st.AsStrArrays()
+	assert.Equal(t, []string{"a|b", "", "c", "d\\"}, strs)
+	assert.Equal(t, []int{0, 2, 2, 3, 4}, offsets)
+	assert.Equal(t, "a|bd\\", string(arena.scratch), "the escaped elements are unescaped into the scratch")
+
+	arena.reset()
+	st.reset()
+	st.valueType = pbv1.ValueTypeStrArr
+	st.values = [][]byte{encodeStrArr("x|")}
+	st.arena = &arena
+	strs, _ = st.AsStrArrays()
+	assert.Equal(t, []string{"x|"}, strs)
+	assert.Equal(t, "x|", string(arena.scratch), "the scratch is reused")
+}
+
+func encodeInt64Arr(values ...int64) []byte {
+	tv := tagValue{valueType: pbv1.ValueTypeInt64Arr, valueArr: make([][]byte, 0, len(values))}
+	for _, v := range values {
+		tv.valueArr = append(tv.valueArr, convert.Int64ToBytes(v))
+	}
+	return tv.marshal()
+}
+
+func encodeStrArr(values ...string) []byte {
+	tv := tagValue{valueType: pbv1.ValueTypeStrArr, valueArr: make([][]byte, 0, len(values))}
+	for _, v := range values {
+		tv.valueArr = append(tv.valueArr, []byte(v))
+	}
+	return tv.marshal()
+}
+
+func TestAppendColumnValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    [][]byte
+		valueType pbv1.ValueType
+	}{
+		{name: "int64", valueType: pbv1.ValueTypeInt64, values: [][]byte{convert.Int64ToBytes(1), convert.Int64ToBytes(-2), convert.Int64ToBytes(3)}},
+		{name: "str", valueType: pbv1.ValueTypeStr, values: [][]byte{[]byte("a"), nil, {}, []byte("bc")}},
+		{name: "binary", valueType: pbv1.ValueTypeBinaryData, values: [][]byte{longText, nil, []byte("b")}},
+		{name: "null", valueType: pbv1.ValueTypeStr},
+		{name: "int64Arr", valueType: pbv1.ValueTypeInt64Arr, values: [][]byte{
+			encodeInt64Arr(1, 2), encodeInt64Arr(3), nil, encodeInt64Arr(), encodeInt64Arr(-4, 5, 6),
+		}},
+		{name: "strArr", valueType: pbv1.ValueTypeStrArr, values: [][]byte{
+			encodeStrArr("a"), encodeStrArr("b|c", "d\\"), nil, encodeStrArr(""), encodeStrArr("e"), encodeStrArr(),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var arena columnArena
+			c := tag{name: tt.name, valueType: tt.valueType, values: tt.values, arena: &arena}
+			head := []*modelv1.TagValue{pbv1.NullTagValue}
+			want := append([]*modelv1.TagValue{}, head...)
+			from, to := 1, max(len(tt.values), 2)
+			for i := from; i < to; i++ {
+				if tt.values == nil {
+					want = append(want, pbv1.NullTagValue)
+					continue
+				}
+				want = append(want, mustDecodeTagValue(tt.valueType, tt.values[i]))
+			}
+			got := appendColumnValues(head, &c, from, to)
+			if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("Unexpected tag values (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(want[1], columnValue(&c, from), protocmp.Transform()); diff != "" {
+				t.Errorf("Unexpected tag value (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
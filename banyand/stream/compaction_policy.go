@@ -0,0 +1,182 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+)
+
+const (
+	// defaultLevelBaseSize is the max compressed bytes of the parts of level 0 in the leveled policy.
+	defaultLevelBaseSize = 1 << 20
+	// defaultLevelMultiplier is how many times the parts of a level are larger than the ones of the level below.
+	defaultLevelMultiplier = 10
+)
+
+var expiredPartsCounter = observability.NewMeterProvider(observability.RootScope.SubScope("stream").SubScope("compaction")).
+	Counter("expired_parts_total")
+
+// compactionPolicy picks the file parts of a table merged together.
+type compactionPolicy interface {
+	getPartsToMerge(dst, src []*partWrapper, freeDiskSize uint64) []*partWrapper
+}
+
+var (
+	_ compactionPolicy = (*mergePolicy)(nil)
+	_ compactionPolicy = (*leveledPolicy)(nil)
+	_ compactionPolicy = (*timeWindowPolicy)(nil)
+)
+
+// newCompactionPolicy creates the policy of a group, whose bounds of the merges are the ones of the size-tiered policy.
+// ttl is the ttl of the group, by which the time window policy drops the parts.
+func newCompactionPolicy(opts *commonv1.StreamCompactionOpts, sizeTiered *mergePolicy, ttl *commonv1.IntervalRule) compactionPolicy {
+	switch opts.GetPolicy() {
+	case commonv1.StreamCompactionOpts_POLICY_LEVELED:
+		return &leveledPolicy{
+			baseSize:        defaultLevelBaseSize,
+			levelMultiplier: defaultLevelMultiplier,
+			maxParts:        sizeTiered.maxParts,
+			maxFanOutSize:   sizeTiered.maxFanOutSize,
+		}
+	case commonv1.StreamCompactionOpts_POLICY_TIME_WINDOW:
+		window := intervalDuration(opts.GetTimeWindow())
+		if window <= 0 {
+			window = intervalDuration(&commonv1.IntervalRule{Unit: ttl.GetUnit(), Num: 1})
+		}
+		return &timeWindowPolicy{
+			sizeTiered: sizeTiered,
+			window:     window,
+			ttl:        intervalDuration(ttl),
+		}
+	default:
+		return sizeTiered
+	}
+}
+
+func intervalDuration(ir *commonv1.IntervalRule) time.Duration {
+	d := time.Duration(ir.GetNum()) * time.Hour
+	if ir.GetUnit() == commonv1.IntervalRule_UNIT_DAY {
+		d *= 24
+	}
+	return d
+}
+
+// leveledPolicy places a part in the level of the power of levelMultiplier its size is of baseSize.
+// The parts of the lowest level holding more than one part are merged along with the smallest part of the next level,
+// so that the tables keep fewer parts than the size-tiered policy at the cost of rewriting the larger parts more often.
+type leveledPolicy struct {
+	baseSize        uint64
+	levelMultiplier float64
+	maxParts        int
+	maxFanOutSize   uint64
+}
+
+func (lp *leveledPolicy) level(size uint64) int {
+	if size <= lp.baseSize {
+		return 0
+	}
+	return int(math.Log(float64(size)/float64(lp.baseSize))/math.Log(lp.levelMultiplier)) + 1
+}
+
+func (lp *leveledPolicy) getPartsToMerge(dst, src []*partWrapper, freeDiskSize uint64) []*partWrapper {
+	if len(src) < 2 {
+		return dst
+	}
+	maxFanOut := min(freeDiskSize, lp.maxFanOutSize)
+	levels := make(map[int][]*partWrapper)
+	maxLevel := 0
+	for _, pw := range src {
+		l := lp.level(pw.p.partMetadata.CompressedSizeBytes)
+		levels[l] = append(levels[l], pw)
+		maxLevel = max(maxLevel, l)
+	}
+	for l := 0; l <= maxLevel; l++ {
+		pws := levels[l]
+		if len(pws) < 2 {
+			continue
+		}
+		sortPartsForOptimalMerge(pws)
+		if len(pws) > lp.maxParts {
+			pws = pws[:lp.maxParts]
+		}
+		if next := levels[l+1]; len(next) > 0 && len(pws) < lp.maxParts {
+			sortPartsForOptimalMerge(next)
+			pws = append(pws[:len(pws):len(pws)], next[0])
+		}
+		for len(pws) >= 2 && sumCompressedSize(pws) > maxFanOut {
+			pws = pws[:len(pws)-1]
+		}
+		if len(pws) < 2 {
+			continue
+		}
+		return append(dst, pws...)
+	}
+	return dst
+}
+
+// timeWindowPolicy merges the parts in a time window alone by the size-tiered policy, where a part is in the window
+// of its max timestamp. Since the parts of the windows are never merged together, a part out of the ttl is dropped
+// as a whole instead of waiting for its segment to expire.
+type timeWindowPolicy struct {
+	sizeTiered *mergePolicy
+	window     time.Duration
+	ttl        time.Duration
+}
+
+func (tp *timeWindowPolicy) windowOf(pw *partWrapper) int64 {
+	return pw.p.partMetadata.MaxTimestamp / int64(tp.window)
+}
+
+func (tp *timeWindowPolicy) getPartsToMerge(dst, src []*partWrapper, freeDiskSize uint64) []*partWrapper {
+	windows := make(map[int64][]*partWrapper)
+	var keys []int64
+	for _, pw := range src {
+		w := tp.windowOf(pw)
+		if _, ok := windows[w]; !ok {
+			keys = append(keys, w)
+		}
+		windows[w] = append(windows[w], pw)
+	}
+	slices.Sort(keys)
+	for _, w := range keys {
+		n := len(dst)
+		if dst = tp.sizeTiered.getPartsToMerge(dst, windows[w], freeDiskSize); len(dst) > n {
+			return dst
+		}
+	}
+	return dst
+}
+
+// getExpiredParts appends the parts whose elements are all out of the ttl at now.
+func (tp *timeWindowPolicy) getExpiredParts(dst, src []*partWrapper, now time.Time) []*partWrapper {
+	if tp.ttl <= 0 {
+		return dst
+	}
+	deadline := now.Add(-tp.ttl).UnixNano()
+	for _, pw := range src {
+		if pw.p.partMetadata.MaxTimestamp < deadline {
+			dst = append(dst, pw)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,119 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+)
+
+func newTestPart(id, size uint64, maxTimestamp int64) *partWrapper {
+	return &partWrapper{p: &part{partMetadata: partMetadata{
+		ID: id, CompressedSizeBytes: size, TotalCount: 1, MinTimestamp: maxTimestamp, MaxTimestamp: maxTimestamp,
+	}}}
+}
+
+func partSizes(pws []*partWrapper) []uint64 {
+	var sizes []uint64
+	for _, pw := range pws {
+		sizes = append(sizes, pw.p.partMetadata.CompressedSizeBytes)
+	}
+	return sizes
+}
+
+func Test_leveledPolicy_getPartsToMerge(t *testing.T) {
+	lp := &leveledPolicy{baseSize: 100, levelMultiplier: 10, maxParts: 4, maxFanOutSize: math.MaxUint64}
+	tests := []struct {
+		name         string
+		sizes        []uint64
+		want         []uint64
+		freeDiskSize uint64
+	}{
+		{name: "a level absorbed by the next one", sizes: []uint64{5000, 70, 500, 60, 50}, freeDiskSize: math.MaxUint64, want: []uint64{50, 60, 70, 500}},
+		{name: "the top level", sizes: []uint64{50, 600, 500, 5000}, freeDiskSize: math.MaxUint64, want: []uint64{500, 600, 5000}},
+		{name: "a part per level", sizes: []uint64{50, 500, 5000}, freeDiskSize: math.MaxUint64},
+		{name: "free disk space", sizes: []uint64{70, 500, 60, 50}, freeDiskSize: 200, want: []uint64{50, 60, 70}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var src []*partWrapper
+			for i, size := range tt.sizes {
+				src = append(src, newTestPart(uint64(i), size, 1))
+			}
+			assert.Equal(t, tt.want, partSizes(lp.getPartsToMerge(nil, src, tt.freeDiskSize)))
+		})
+	}
+}
+
+func Test_timeWindowPolicy(t *testing.T) {
+	tp := &timeWindowPolicy{sizeTiered: newDefaultMergePolicyForTesting(), window: 10, ttl: 20}
+	var src []*partWrapper
+	// the single part of window 0 is never merged with the ones of window 1
+	src = append(src, newTestPart(0, 100, 3))
+	for i := 0; i < 4; i++ {
+		src = append(src, newTestPart(uint64(i+1), 100, int64(11+i)))
+	}
+	got := tp.getPartsToMerge(nil, src, math.MaxUint64)
+	require.Len(t, got, 4)
+	for _, pw := range got {
+		assert.Equal(t, int64(1), tp.windowOf(pw))
+	}
+	// the window of the older parts is merged first
+	src = append(src, newTestPart(5, 100, 1), newTestPart(6, 100, 2))
+	got = tp.getPartsToMerge(nil, src, math.MaxUint64)
+	require.Len(t, got, 3)
+	for _, pw := range got {
+		assert.Equal(t, int64(0), tp.windowOf(pw))
+	}
+
+	expired := tp.getExpiredParts(nil, src, time.Unix(0, 32))
+	var ids []uint64
+	for _, pw := range expired {
+		ids = append(ids, pw.ID())
+	}
+	assert.ElementsMatch(t, []uint64{0, 5, 6, 1}, ids)
+}
+
+func Test_newCompactionPolicy(t *testing.T) {
+	sizeTiered := newDefaultMergePolicyForTesting()
+	ttl := &commonv1.IntervalRule{Unit: commonv1.IntervalRule_UNIT_DAY, Num: 7}
+	assert.Same(t, sizeTiered, newCompactionPolicy(nil, sizeTiered, ttl))
+	assert.IsType(t, &leveledPolicy{}, newCompactionPolicy(&commonv1.StreamCompactionOpts{
+		Policy: commonv1.StreamCompactionOpts_POLICY_LEVELED,
+	}, sizeTiered, ttl))
+
+	tp, ok := newCompactionPolicy(&commonv1.StreamCompactionOpts{
+		Policy: commonv1.StreamCompactionOpts_POLICY_TIME_WINDOW,
+	}, sizeTiered, ttl).(*timeWindowPolicy)
+	require.True(t, ok)
+	assert.Equal(t, 24*time.Hour, tp.window)
+	assert.Equal(t, 7*24*time.Hour, tp.ttl)
+
+	tp, ok = newCompactionPolicy(&commonv1.StreamCompactionOpts{
+		Policy:     commonv1.StreamCompactionOpts_POLICY_TIME_WINDOW,
+		TimeWindow: &commonv1.IntervalRule{Unit: commonv1.IntervalRule_UNIT_HOUR, Num: 6},
+	}, sizeTiered, ttl).(*timeWindowPolicy)
+	require.True(t, ok)
+	assert.Equal(t, 6*time.Hour, tp.window)
+}
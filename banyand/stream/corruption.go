@@ -0,0 +1,200 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+)
+
+const (
+	// quarantineDirname is the directory under the root of a tsTable holding the corrupted parts.
+	// They are kept for the investigation and the repair instead of being removed.
+	quarantineDirname = "quarantine"
+	// skippedBlocksFilename lists the blocks of a quarantined part which a merge skipped.
+	skippedBlocksFilename = "skipped_blocks.json"
+)
+
+// maxSkippedBlocksPerPart is the number of the corrupted blocks of a part a merge skips at most.
+// The merge aborts once a part has more corrupted blocks, and the part is quarantined as a whole.
+var maxSkippedBlocksPerPart = 8
+
+var (
+	corruptionProvider      = observability.NewMeterProvider(observability.RootScope.SubScope("stream").SubScope("corruption"))
+	skippedBlocksCounter    = corruptionProvider.Counter("skipped_blocks_total", "source")
+	quarantinedPartsCounter = corruptionProvider.Counter("quarantined_parts_total")
+)
+
+// blockError is a block of a part which fails to decode.
+type blockError struct {
+	err      error
+	part     string
+	partID   uint64
+	offset   uint64
+	seriesID common.SeriesID
+}
+
+func newBlockError(p *part, bm *blockMetadata, err error) *blockError {
+	return &blockError{
+		err:      err,
+		part:     p.path,
+		partID:   p.partMetadata.ID,
+		seriesID: bm.seriesID,
+		offset:   bm.timestamps.offset,
+	}
+}
+
+func (e *blockError) Error() string {
+	return fmt.Sprintf("part %s: block of series %d at %d is corrupted: %v", e.part, e.seriesID, e.offset, e.err)
+}
+
+func (e *blockError) Unwrap() error {
+	return e.err
+}
+
+func (e *blockError) warning() *modelv1.BlockWarning {
+	return &modelv1.BlockWarning{
+		Part:        e.part,
+		SeriesId:    uint64(e.seriesID),
+		BlockOffset: e.offset,
+		Error:       e.err.Error(),
+	}
+}
+
+// partError is a part whose metadata fails to decode.
+type partError struct {
+	err    error
+	part   string
+	partID uint64
+}
+
+func newPartError(p *part, err error) *partError {
+	return &partError{
+		err:    err,
+		part:   p.path,
+		partID: p.partMetadata.ID,
+	}
+}
+
+func (e *partError) Error() string {
+	return fmt.Sprintf("part %s is corrupted: %v", e.part, e.err)
+}
+
+func (e *partError) Unwrap() error {
+	return e.err
+}
+
+// skippedBlock is a corrupted block a merge skipped, which is listed in the skipped blocks file of the part.
+type skippedBlock struct {
+	Error    string          `json:"error"`
+	SeriesID common.SeriesID `json:"seriesID"`
+	Offset   uint64          `json:"offset"`
+}
+
+// corruptedPartID returns the ID of the part err blames.
+func corruptedPartID(err error) (uint64, bool) {
+	var be *blockError
+	if errors.As(err, &be) {
+		return be.partID, true
+	}
+	var pe *partError
+	if errors.As(err, &pe) {
+		return pe.partID, true
+	}
+	return 0, false
+}
+
+// quarantinePart drops the part from the snapshot and moves its files to the quarantine directory.
+func (tst *tsTable) quarantinePart(pw *partWrapper, cause error, merges chan *mergerIntroduction) error {
+	mi := generateMergerIntroduction()
+	defer releaseMergerIntroduction(mi)
+	mi.creator = snapshotCreatorMerger
+	mi.merged = map[uint64]struct{}{pw.ID(): {}}
+	mi.quarantined = mi.merged
+	mi.applied = make(chan struct{})
+	select {
+	case merges <- mi:
+	case <-tst.loopCloser.CloseNotify():
+		return errClosed
+	}
+	select {
+	case <-mi.applied:
+	case <-tst.loopCloser.CloseNotify():
+		return errClosed
+	}
+	quarantinedPartsCounter.Inc(1)
+	tst.l.Error().Err(cause).Str("part", pw.p.path).Msg("the corrupted part is quarantined")
+	return nil
+}
+
+// moveToQuarantine is called by the introducer once the part is dropped from the snapshot,
+// before the garbage cleaner removes the files of the parts which are no longer alive.
+func (tst *tsTable) moveToQuarantine(partID uint64) {
+	dir := filepath.Join(tst.root, quarantineDirname)
+	tst.fileSystem.MkdirIfNotExist(dir, dirPermission)
+	if err := tst.fileSystem.Rename(partPath(tst.root, partID), filepath.Join(dir, partName(partID))); err != nil {
+		tst.l.Warn().Err(err).Uint64("part", partID).Msg("cannot move the corrupted part to the quarantine, it's removed")
+	}
+}
+
+// skippedParts returns the file parts which have the skipped blocks.
+// The memory parts are left out since they have no files to keep.
+func skippedParts(parts []*partWrapper, skipped []*blockError) map[uint64]struct{} {
+	if len(skipped) == 0 {
+		return nil
+	}
+	result := make(map[uint64]struct{})
+	for _, be := range skipped {
+		for _, pw := range parts {
+			if pw.ID() == be.partID && pw.mp == nil {
+				result[be.partID] = struct{}{}
+			}
+		}
+	}
+	return result
+}
+
+// writeSkippedBlocks lists the skipped blocks of the quarantined parts for the repair.
+func (tst *tsTable) writeSkippedBlocks(quarantined map[uint64]struct{}, skipped []*blockError) {
+	for partID := range quarantined {
+		var sbb []skippedBlock
+		for _, be := range skipped {
+			if be.partID == partID {
+				sbb = append(sbb, skippedBlock{Error: be.err.Error(), SeriesID: be.seriesID, Offset: be.offset})
+			}
+		}
+		quarantinedPartsCounter.Inc(1)
+		tst.l.Error().Uint64("part", partID).Int("skippedBlocks", len(sbb)).
+			Msg("the merge skips the corrupted blocks, the source part is quarantined")
+		data, err := json.Marshal(sbb)
+		if err != nil {
+			tst.l.Warn().Err(err).Uint64("part", partID).Msg("cannot marshal the skipped blocks")
+			continue
+		}
+		name := filepath.Join(tst.root, quarantineDirname, partName(partID), skippedBlocksFilename)
+		if _, err = tst.fileSystem.Write(data, name, filePermission); err != nil {
+			tst.l.Warn().Err(err).Uint64("part", partID).Msg("cannot write the skipped blocks")
+		}
+	}
+}
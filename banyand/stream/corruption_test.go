@@ -0,0 +1,298 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/query/executor"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/watcher"
+)
+
+// the blocks are written in the order of the series, so the data of series 3 lies at the end of every file.
+var esCorruption = func() *elements {
+	es := &elements{}
+	for _, sid := range []common.SeriesID{1, 2, 3} {
+		for i, ts := range []int64{1, 4, 20} {
+			es.seriesIDs = append(es.seriesIDs, sid)
+			es.timestamps = append(es.timestamps, ts+int64(sid))
+			es.elementIDs = append(es.elementIDs, fmt.Sprintf("%d%d", sid, i))
+			es.tagFamilies = append(es.tagFamilies, []tagValues{{
+				tag: "singleTag", values: []*tagValue{
+					{tag: "strTag", valueType: pbv1.ValueTypeStr, value: []byte(es.elementIDs[len(es.elementIDs)-1])},
+				},
+			}})
+		}
+	}
+	return es
+}()
+
+var corruptibleFiles = []string{
+	timestampsFilename,
+	elementIDsFilename,
+	"singleTag" + tagFamiliesMetadataFilenameExt,
+	"singleTag" + tagFamiliesFilenameExt,
+}
+
+// mustCreateCorruptedPart flushes esCorruption to a file part, and truncates the last byte of the file.
+func mustCreateCorruptedPart(t *testing.T, fileSystem fs.FileSystem, root string, id uint64, filename string) *part {
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.mustInitFromElements(esCorruption)
+	mp.mustFlush(fileSystem, partPath(root, id))
+	if filename != "" {
+		name := path.Join(partPath(root, id), filename)
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		require.NoError(t, os.Truncate(name, info.Size()-1))
+	}
+	return mustOpenFilePart(id, root, fileSystem)
+}
+
+func TestQueryResultSkipsCorruptedBlocks(t *testing.T) {
+	for _, filename := range corruptibleFiles {
+		t.Run(filename, func(t *testing.T) {
+			tmpPath, defFn := test.Space(require.New(t))
+			defer defFn()
+			p := mustCreateCorruptedPart(t, fs.NewLocalFileSystem(), tmpPath, 1, filename)
+			defer p.close()
+
+			ti := &tstIter{}
+			ti.init([]*part{p}, []common.SeriesID{1, 2, 3}, 0, math.MaxInt64)
+			result := queryResult{
+				orderByTS: true,
+				ascTS:     true,
+				warnings:  &executor.Warnings{},
+			}
+			defer result.Release()
+			for ti.nextBlock() {
+				bc := generateBlockCursor()
+				pi := ti.piHeap[0]
+				bc.init(pi.p, pi.curBlock, queryOptions{
+					minTimestamp: 0,
+					maxTimestamp: math.MaxInt64,
+					StreamQueryOptions: pbv1.StreamQueryOptions{
+						TagProjection: []pbv1.TagProjection{{Family: "singleTag", Names: []string{"strTag"}}},
+					},
+				})
+				result.data = append(result.data, bc)
+			}
+			require.NoError(t, ti.Error())
+
+			sids := make(map[common.SeriesID]int)
+			for r := result.Pull(); r != nil; r = result.Pull() {
+				sids[r.SID] += len(r.Timestamps)
+			}
+			assert.Equal(t, map[common.SeriesID]int{1: 3, 2: 3}, sids)
+
+			warnings := result.warnings.List()
+			require.Len(t, warnings, 1)
+			assert.Equal(t, p.path, warnings[0].Part)
+			assert.Equal(t, uint64(3), warnings[0].SeriesId)
+			assert.NotEmpty(t, warnings[0].Error)
+		})
+	}
+}
+
+// mustCorruptTagFamilyMetadata overwrites the tag family metadata of the block of the series, which fails to decode then.
+func mustCorruptTagFamilyMetadata(t *testing.T, p *part, sid common.SeriesID) {
+	pmi := generatePartMergeIter()
+	defer releasePartMergeIter(pmi)
+	pmi.mustInitFromPart(p)
+	for pmi.nextBlockMetadata() {
+		if pmi.block.bm.seriesID != sid {
+			continue
+		}
+		db := pmi.block.bm.tagFamilies["singleTag"]
+		f, err := os.OpenFile(path.Join(p.path, "singleTag"+tagFamiliesMetadataFilenameExt), os.O_WRONLY, 0)
+		require.NoError(t, err)
+		defer f.Close()
+		_, err = f.WriteAt(bytes.Repeat([]byte{0xff}, int(db.size)), int64(db.offset))
+		require.NoError(t, err)
+		return
+	}
+	t.Fatalf("series %d isn't found", sid)
+}
+
+func countElements(t *testing.T, p *part) map[common.SeriesID]uint64 {
+	pmi := generatePartMergeIter()
+	defer releasePartMergeIter(pmi)
+	pmi.mustInitFromPart(p)
+	result := make(map[common.SeriesID]uint64)
+	for pmi.nextBlockMetadata() {
+		result[pmi.block.bm.seriesID] += pmi.block.bm.count
+	}
+	require.NoError(t, pmi.error())
+	return result
+}
+
+func TestMergeSkipsCorruptedBlocks(t *testing.T) {
+	type testCtx struct {
+		corrupt     func(t *testing.T, root string, p *part)
+		name        string
+		want        map[common.SeriesID]uint64
+		wantSkipped common.SeriesID
+	}
+	tests := []testCtx{
+		{
+			name: "a block in the middle",
+			corrupt: func(t *testing.T, _ string, p *part) {
+				mustCorruptTagFamilyMetadata(t, p, 2)
+			},
+			want:        map[common.SeriesID]uint64{1: 6, 2: 3, 3: 6},
+			wantSkipped: 2,
+		},
+	}
+	for _, filename := range corruptibleFiles {
+		filename := filename
+		tests = append(tests, testCtx{
+			name: "the truncated " + filename,
+			corrupt: func(t *testing.T, root string, _ *part) {
+				name := path.Join(partPath(root, 2), filename)
+				info, err := os.Stat(name)
+				require.NoError(t, err)
+				require.NoError(t, os.Truncate(name, info.Size()-1))
+			},
+			want:        map[common.SeriesID]uint64{1: 6, 2: 6, 3: 3},
+			wantSkipped: 3,
+		})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpPath, defFn := test.Space(require.New(t))
+			defer defFn()
+			fileSystem := fs.NewLocalFileSystem()
+			healthy := newPartWrapper(nil, mustCreateCorruptedPart(t, fileSystem, tmpPath, 1, ""))
+			defer healthy.decRef()
+			corrupted := newPartWrapper(nil, mustCreateCorruptedPart(t, fileSystem, tmpPath, 2, ""))
+			defer corrupted.decRef()
+			tt.corrupt(t, tmpPath, corrupted.p)
+
+			closeCh := make(chan struct{})
+			defer close(closeCh)
+			pw, skipped, err := mergeParts(fileSystem, closeCh, []*partWrapper{healthy, corrupted}, 3, tmpPath, nil, encoding.CodecZSTD, fs.IOOptions{})
+			require.NoError(t, err)
+			defer pw.decRef()
+			require.Len(t, skipped, 1)
+			assert.Equal(t, uint64(2), skipped[0].partID)
+			assert.Equal(t, tt.wantSkipped, skipped[0].seriesID)
+			assert.Equal(t, tt.want, countElements(t, pw.p))
+		})
+	}
+}
+
+func TestMergeQuarantinesCorruptedPart(t *testing.T) {
+	mergeCorruptedPart := func(t *testing.T) (tst *tsTable, release func(), err error) {
+		tmpPath, defFn := test.Space(require.New(t))
+		fileSystem := fs.NewLocalFileSystem()
+		healthy := newPartWrapper(nil, mustCreateCorruptedPart(t, fileSystem, tmpPath, 1, ""))
+		corrupted := newPartWrapper(nil, mustCreateCorruptedPart(t, fileSystem, tmpPath, 2, ""))
+		mustCorruptTagFamilyMetadata(t, corrupted.p, 2)
+
+		tst = &tsTable{
+			loopCloser: run.NewCloser(2),
+			fileSystem: fileSystem,
+			root:       tmpPath,
+			l:          logger.GetLogger("test"),
+			curPartID:  2,
+			snapshot: &snapshot{
+				parts: []*partWrapper{healthy, corrupted},
+				epoch: 1,
+				ref:   1,
+			},
+		}
+		tst.gc.init(tst)
+		flushCh := make(chan *flusherIntroduction)
+		mergeCh := make(chan *mergerIntroduction)
+		go tst.introducerLoop(flushCh, mergeCh, make(watcher.Channel, 1), 1)
+
+		closeCh := make(chan struct{})
+		s := tst.currentSnapshot()
+		_, err = tst.mergePartsThenSendIntroduction(snapshotCreatorMerger, s.parts,
+			map[uint64]struct{}{1: {}, 2: {}}, mergeCh, closeCh)
+		s.decRef()
+		return tst, func() {
+			close(closeCh)
+			tst.loopCloser.Done()
+			tst.loopCloser.CloseThenWait()
+			tst.snapshot.decRef()
+			defFn()
+		}, err
+	}
+
+	t.Run("skip the corrupted blocks", func(t *testing.T) {
+		tst, release, err := mergeCorruptedPart(t)
+		defer release()
+		require.NoError(t, err)
+
+		s := tst.currentSnapshot()
+		require.Len(t, s.parts, 1)
+		assert.Equal(t, uint64(3), s.parts[0].ID())
+		assert.Equal(t, map[common.SeriesID]uint64{1: 6, 2: 3, 3: 6}, countElements(t, s.parts[0].p))
+		s.decRef()
+
+		data, err := os.ReadFile(filepath.Join(tst.root, quarantineDirname, partName(2), skippedBlocksFilename))
+		require.NoError(t, err, "the source part is kept in the quarantine with its skipped blocks")
+		var sbb []skippedBlock
+		require.NoError(t, json.Unmarshal(data, &sbb))
+		require.Len(t, sbb, 1)
+		assert.Equal(t, common.SeriesID(2), sbb[0].SeriesID)
+		_, err = os.Stat(partPath(tst.root, 2))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("quarantine the part with too many corrupted blocks", func(t *testing.T) {
+		defer func(n int) { maxSkippedBlocksPerPart = n }(maxSkippedBlocksPerPart)
+		maxSkippedBlocksPerPart = 0
+		tst, release, err := mergeCorruptedPart(t)
+		defer release()
+		require.Error(t, err)
+		partID, ok := corruptedPartID(err)
+		require.True(t, ok)
+		assert.Equal(t, uint64(2), partID)
+
+		s := tst.currentSnapshot()
+		require.Len(t, s.parts, 1)
+		assert.Equal(t, uint64(1), s.parts[0].ID())
+		s.decRef()
+
+		_, err = os.Stat(filepath.Join(tst.root, quarantineDirname, partName(2), metadataFilename))
+		assert.NoError(t, err, "the corrupted part is moved to the quarantine")
+		_, err = os.Stat(partPath(tst.root, 2))
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(partPath(tst.root, 3))
+		assert.True(t, os.IsNotExist(err), "the incomplete merged part is removed")
+	})
+}
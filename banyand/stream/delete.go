@@ -0,0 +1,143 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+type deleteCallback struct {
+	l          *logger.Logger
+	schemaRepo *schemaRepo
+}
+
+func setUpDeleteCallback(l *logger.Logger, schemaRepo *schemaRepo) bus.MessageListener {
+	return &deleteCallback{
+		l:          l,
+		schemaRepo: schemaRepo,
+	}
+}
+
+func (d *deleteCallback) Rev(message bus.Message) (resp bus.Message) {
+	req, ok := message.Data().(*streamv1.DeleteRequest)
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("invalid event data type"))
+	}
+	s, ok := d.schemaRepo.loadStream(req.GetMetadata())
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("stream %s doesn't exist", req.GetMetadata()))
+	}
+	result, err := s.delete(context.Background(), req)
+	if err != nil {
+		d.l.Error().Err(err).Stringer("stream", req.GetMetadata()).Msg("cannot delete the elements")
+		return bus.NewMessage(message.ID(), common.NewError("cannot delete the elements of the stream %s: %v", req.GetMetadata(), err))
+	}
+	d.l.Info().Stringer("stream", req.GetMetadata()).Uint64("series", result.GetSeries()).Msg("deleted the elements")
+	return bus.NewMessage(message.ID(), result)
+}
+
+// delete deletes the elements of the series picked by the conditions of the entity tags, which are in the time range
+// and equal the conditions of the other tags. The deletions are added to the tsTables of the time range,
+// by which the queries skip the elements and the merges drop them.
+func (s *stream) delete(ctx context.Context, req *streamv1.DeleteRequest) (*streamv1.DeleteResponse, error) {
+	entity, tags, err := s.parseDeleteConditions(req.GetConditions())
+	if err != nil {
+		return nil, err
+	}
+	tr := timestamp.NewInclusiveTimeRange(req.GetTimeRange().GetBegin().AsTime(), req.GetTimeRange().GetEnd().AsTime())
+	tsdb := s.databaseSupplier.SupplyTSDB().(storage.TSDB[*tsTable, option])
+	sl, err := tsdb.Lookup(ctx, &pbv1.Series{Subject: s.name, EntityValues: entity})
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot look up the series")
+	}
+	result := &streamv1.DeleteResponse{Series: uint64(len(sl))}
+	if len(sl) == 0 {
+		return result, nil
+	}
+	seriesIDs := make([]common.SeriesID, 0, len(sl))
+	for i := range sl {
+		seriesIDs = append(seriesIDs, sl[i].ID)
+	}
+	tabWrappers := tsdb.SelectTSTables(tr)
+	defer func() {
+		for i := range tabWrappers {
+			tabWrappers[i].DecRef()
+		}
+	}()
+	for _, tw := range tabWrappers {
+		tw.Table().deleteElements(&deletion{
+			Series:       seriesIDs,
+			Tags:         tags,
+			MinTimestamp: tr.Start.UnixNano(),
+			MaxTimestamp: tr.End.UnixNano(),
+		})
+	}
+	return result, nil
+}
+
+// parseDeleteConditions splits the conditions into the entity values picking the series and the tags the deleted elements equal.
+func (s *stream) parseDeleteConditions(conditions []*modelv1.Condition) ([]*modelv1.TagValue, []deletedTag, error) {
+	entityNames := s.schema.GetEntity().GetTagNames()
+	entity := make([]*modelv1.TagValue, len(entityNames))
+	for i := range entity {
+		entity[i] = pbv1.AnyTagValue
+	}
+	var tags []deletedTag
+NEXT:
+	for _, c := range conditions {
+		if c.GetOp() != modelv1.Condition_BINARY_OP_EQ {
+			return nil, nil, errors.Errorf("the condition of the tag %s isn't BINARY_OP_EQ", c.GetName())
+		}
+		fi, _, spec := pbv1.FindTagByName(s.schema.GetTagFamilies(), c.GetName())
+		if spec == nil {
+			return nil, nil, errors.Errorf("the tag %s isn't defined", c.GetName())
+		}
+		if spec.GetType() != databasev1.TagType_TAG_TYPE_STRING && spec.GetType() != databasev1.TagType_TAG_TYPE_INT {
+			return nil, nil, errors.Errorf("the tag %s is neither a string nor an int", c.GetName())
+		}
+		for i, name := range entityNames {
+			if name == c.GetName() {
+				entity[i] = c.GetValue()
+				continue NEXT
+			}
+		}
+		tv := encodeTagValue(c.GetName(), spec.GetType(), c.GetValue())
+		if tv.value == nil {
+			return nil, nil, errors.Errorf("the value of the tag %s doesn't match its type", c.GetName())
+		}
+		tags = append(tags, deletedTag{
+			Family:    s.schema.GetTagFamilies()[fi].GetName(),
+			Name:      c.GetName(),
+			Value:     tv.value,
+			ValueType: tv.valueType,
+		})
+	}
+	return entity, tags, nil
+}
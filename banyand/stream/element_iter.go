@@ -0,0 +1,79 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"container/heap"
+	"strings"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/intern"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+var _ pbv1.StreamIterResult = (*queryResult)(nil)
+
+// Next returns the next element of the cursors in the order Pull merges them, which is copied out of
+// the loaded block alone. A cursor is released once it's exhausted, so that its block is freed
+// before the rest are iterated.
+func (qr *queryResult) Next() (pbv1.StreamElement, bool) {
+	qr.load()
+	if qr.topK != nil {
+		return qr.topK.next()
+	}
+	if len(qr.data) == 0 || qr.canceled() {
+		return pbv1.StreamElement{}, false
+	}
+	bc := qr.data[0]
+	e := bc.nextElement()
+	if bc.exhausted() {
+		releaseBlockCursor(heap.Pop(qr).(*blockCursor))
+	} else {
+		heap.Fix(qr, 0)
+	}
+	return e, true
+}
+
+// nextElement copies the row at idx into an element, and drops it from the window.
+// The caller has to check the cursor isn't exhausted.
+func (bc *blockCursor) nextElement() pbv1.StreamElement {
+	return bc.element(bc.take())
+}
+
+// element copies the row at idx into an element.
+func (bc *blockCursor) element(idx int) pbv1.StreamElement {
+	e := pbv1.StreamElement{
+		SID:         bc.bm.seriesID,
+		Timestamp:   bc.timestamps[idx],
+		ElementID:   strings.Clone(bc.elementIDs[idx]),
+		TagFamilies: make([]pbv1.TagFamily, len(bc.tagProjection)),
+	}
+	for i, tp := range bc.tagProjection {
+		tf := &e.TagFamilies[i]
+		tf.Name = intern.String(tp.Family)
+		tf.Tags = make([]pbv1.Tag, len(tp.Names))
+		for j, name := range tp.Names {
+			value := pbv1.NullTagValue
+			if i < len(bc.tagFamilies) && j < len(bc.tagFamilies[i].tags) {
+				value = columnValue(&bc.tagFamilies[i].tags[j], idx)
+			}
+			tf.Tags[j] = pbv1.Tag{Name: intern.String(name), Values: []*modelv1.TagValue{value}}
+		}
+	}
+	return e
+}
@@ -0,0 +1,98 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+// Test_queryResult_Next verifies the elements iterated are the rows pulled, in the order of the query.
+func Test_queryResult_Next(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	tst, err := newTSTable(fs.NewLocalFileSystem(), tmpPath, common.Position{},
+		logger.GetLogger("test"), timestamp.TimeRange{}, option{flushTimeout: time.Hour, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer tst.Close()
+	for _, es := range []*elements{esTS1, esTS2, generateHugeEs(3, 5000, 6)} {
+		require.NoError(t, tst.addElements(es))
+	}
+	sids := []common.SeriesID{1, 2, 3}
+	qo := queryOptions{minTimestamp: 1, maxTimestamp: 4000}
+	qo.TagProjection = []pbv1.TagProjection{
+		{Family: "arrTag", Names: []string{"strArrTag", "intArrTag"}},
+		{Family: "binaryTag", Names: []string{"binaryTag"}},
+		{Family: "singleTag", Names: []string{"strTag", "intTag", "strTag1", "strTag2"}},
+	}
+
+	for _, asc := range []bool{true, false} {
+		t.Run(strconv.FormatBool(asc), func(t *testing.T) {
+			rows := queryResult{orderByTS: true, ascTS: true}
+			defer rows.Release()
+			require.NoError(t, rows.selectBlocks([]*tsTable{tst}, sids, qo))
+			var want []pbv1.StreamResult
+			for r := rows.Pull(); r != nil; r = rows.Pull() {
+				want = append(want, *r)
+			}
+
+			iter := queryResult{orderByTS: true, ascTS: asc}
+			defer iter.Release()
+			require.NoError(t, iter.selectBlocks([]*tsTable{tst}, sids, qo))
+			var got []pbv1.StreamResult
+			var timestamps []int64
+			for e, ok := iter.Next(); ok; e, ok = iter.Next() {
+				timestamps = append(timestamps, e.Timestamp)
+				got = append(got, pbv1.StreamResult{
+					SID:         e.SID,
+					Timestamps:  []int64{e.Timestamp},
+					ElementIDs:  []string{e.ElementID},
+					TagFamilies: e.TagFamilies,
+				})
+			}
+			// the exhausted cursors are released while iterating
+			assert.Empty(t, iter.data)
+			assert.True(t, sort.SliceIsSorted(timestamps, func(i, j int) bool {
+				if asc {
+					return timestamps[i] < timestamps[j]
+				}
+				return timestamps[i] > timestamps[j]
+			}))
+
+			wantRows := flattenStreamResults(want)
+			assert.Len(t, wantRows, 3+3+3998+2)
+			if diff := cmp.Diff(wantRows, flattenStreamResults(got), protocmp.Transform(), cmp.AllowUnexported(streamRow{})); diff != "" {
+				t.Errorf("Unexpected rows (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
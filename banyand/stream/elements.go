@@ -34,6 +34,8 @@ type tagValue struct {
 	value     []byte
 	valueArr  [][]byte
 	valueType pbv1.ValueType
+	// bloomFilter makes the blocks build a bloom filter of the values of the tag.
+	bloomFilter bool
 }
 
 func (t *tagValue) size() int {
@@ -109,16 +111,79 @@ func unmarshalVarArray(dest, src []byte) ([]byte, []byte, error) {
 	return nil, nil, errors.New("invalid variable array")
 }
 
+// nextVarArrayElement returns the next element of the string array src in its escaped form, and the rest of src.
+// The element refers to src, and has to be unescaped by unescapeVarArrayElement if escaped is true.
+func nextVarArrayElement(src []byte) (elem, rest []byte, escaped bool, err error) {
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case escape:
+			if i+1 >= len(src) {
+				return nil, nil, false, errors.New("invalid escape character")
+			}
+			escaped = true
+			i++
+		case entityDelimiter:
+			return src[:i], src[i+1:], escaped, nil
+		}
+	}
+	return nil, nil, false, errors.New("invalid variable array")
+}
+
+// unescapeVarArrayElement appends the element returned by nextVarArrayElement to dest without the escape characters.
+func unescapeVarArrayElement(dest, elem []byte) []byte {
+	for i := 0; i < len(elem); i++ {
+		if elem[i] == escape {
+			i++
+		}
+		dest = append(dest, elem[i])
+	}
+	return dest
+}
+
 type tagValues struct {
 	tag    string
 	values []*tagValue
 }
 
+var errDuplicatedTag = errors.New("duplicated tag")
+
+// checkDuplicatedTags returns an error if the tag families of an element share a name,
+// or the tags of a tag family do.
+func checkDuplicatedTags(tff []tagValues) error {
+	for i := range tff {
+		for j := 0; j < i; j++ {
+			if tff[i].tag == tff[j].tag {
+				return errors.WithMessagef(errDuplicatedTag, "tag family %q appears more than once", tff[i].tag)
+			}
+		}
+		values := tff[i].values
+		for k := range values {
+			for l := 0; l < k; l++ {
+				if values[k].tag == values[l].tag {
+					return errors.WithMessagef(errDuplicatedTag, "tag %q appears more than once in tag family %q", values[k].tag, tff[i].tag)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 type elements struct {
 	seriesIDs   []common.SeriesID
 	timestamps  []int64
 	elementIDs  []string
 	tagFamilies [][]tagValues
+	// walOffsets are the offsets of the writes in the WAL of the group, which are released once the elements are flushed.
+	walOffsets []uint64
+	// schemaRevision is the oldest revision of the stream schemas the elements are written by.
+	schemaRevision int64
+}
+
+// observeSchemaRevision records the revision of the schema an element is written by.
+func (e *elements) observeSchemaRevision(revision int64) {
+	if e.schemaRevision == 0 || revision < e.schemaRevision {
+		e.schemaRevision = revision
+	}
 }
 
 func (e *elements) Len() int {
@@ -143,13 +208,13 @@ type elementsInTable struct {
 	timeRange timestamp.TimeRange
 	tsTable   storage.TSTableWrapper[*tsTable]
 
-	elements elements
-	docs     index.Documents
+	elements   elements
+	docs       index.Documents
+	seriesDocs index.Documents
 }
 
 type elementsInGroup struct {
 	tsdb storage.TSDB[*tsTable, option]
 
-	docs   index.Documents
 	tables []*elementsInTable
 }
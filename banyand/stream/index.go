@@ -23,6 +23,8 @@ import (
 	"path"
 	"sort"
 
+	"github.com/pkg/errors"
+
 	"github.com/apache/skywalking-banyandb/api/common"
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
@@ -73,9 +75,46 @@ func (e *elementIndex) Write(docs index.Documents) error {
 	return nil
 }
 
-func (e *elementIndex) Search(_ context.Context, seriesList pbv1.SeriesList, filter index.Filter) ([]elementRef, error) {
+// Delete removes the documents of the elements, and waits until they're applied.
+func (e *elementIndex) Delete(deletions []index.SeriesDocID) error {
+	applied := make(chan struct{})
+	err := e.store.Batch(index.Batch{
+		SeriesDeletions: deletions,
+		Applied:         applied,
+	})
+	if err != nil {
+		return err
+	}
+	<-applied
+	return nil
+}
+
+// VisitDocuments visits the documents of the elements of the series whose timestamps are in [minTimestamp, maxTimestamp].
+func (e *elementIndex) VisitDocuments(seriesID common.SeriesID, minTimestamp, maxTimestamp int64,
+	analyzerOf func(indexRuleID uint32) databasev1.IndexRule_Analyzer, visitor func(index.Document) error,
+) error {
+	v, ok := e.store.(index.SeriesDocumentVisitor)
+	if !ok {
+		return errors.New("the element index can't visit the documents")
+	}
+	return v.VisitSeriesDocuments(seriesID, uint64(minTimestamp), uint64(maxTimestamp), analyzerOf, visitor)
+}
+
+// VisitTerms visits the values of the field indexed without an analyzer, which start with prefix, in order.
+func (e *elementIndex) VisitTerms(fieldKey index.FieldKey, prefix []byte, visitor func(term []byte) error) error {
+	v, ok := e.store.(index.TermVisitor)
+	if !ok {
+		return errors.New("the element index can't visit the terms")
+	}
+	return v.VisitTerms(fieldKey, prefix, visitor)
+}
+
+func (e *elementIndex) Search(ctx context.Context, seriesList pbv1.SeriesList, filter index.Filter) ([]elementRef, error) {
 	pm := make(map[common.SeriesID][]uint64)
 	for _, series := range seriesList {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		pl, err := filter.Execute(func(ruleType databasev1.IndexRule_Type) (index.Searcher, error) {
 			return e.store, nil
 		}, series.ID)
@@ -82,6 +82,8 @@ type mergerIntroduction struct {
 	newPart *partWrapper
 	applied chan struct{}
 	creator snapshotCreator
+	// quarantined are the merged parts whose files are moved to the quarantine instead of being removed
+	quarantined map[uint64]struct{}
 }
 
 func (i *mergerIntroduction) reset() {
@@ -91,6 +93,7 @@ func (i *mergerIntroduction) reset() {
 	i.newPart = nil
 	i.applied = nil
 	i.creator = 0
+	i.quarantined = nil
 }
 
 var mergerIntroductionPool = sync.Pool{}
@@ -143,6 +146,7 @@ func (tst *tsTable) introduceMemPart(nextIntroduction *introduction, epoch uint6
 	}
 
 	next := nextIntroduction.memPart
+	next.p.tombstones = &tst.tombstones
 	nextSnp := cur.copyAllTo(epoch)
 	nextSnp.parts = append(nextSnp.parts, next)
 	nextSnp.creator = snapshotCreatorMemPart
@@ -158,6 +162,9 @@ func (tst *tsTable) introduceFlushed(nextIntroduction *flusherIntroduction, epoc
 		tst.l.Panic().Msg("current snapshot is nil")
 	}
 	defer cur.decRef()
+	for _, pw := range nextIntroduction.flushed {
+		pw.p.tombstones = &tst.tombstones
+	}
 	nextSnp := cur.merge(epoch, nextIntroduction.flushed)
 	nextSnp.creator = snapshotCreatorFlusher
 	tst.replaceSnapshot(&nextSnp)
@@ -175,10 +182,17 @@ func (tst *tsTable) introduceMerged(nextIntroduction *mergerIntroduction, epoch
 	}
 	defer cur.decRef()
 	nextSnp := cur.remove(epoch, nextIntroduction.merged)
-	nextSnp.parts = append(nextSnp.parts, nextIntroduction.newPart)
+	if nextIntroduction.newPart != nil {
+		nextIntroduction.newPart.p.tombstones = &tst.tombstones
+		nextSnp.parts = append(nextSnp.parts, nextIntroduction.newPart)
+	}
 	nextSnp.creator = nextIntroduction.creator
 	tst.replaceSnapshot(&nextSnp)
 	tst.persistSnapshot(&nextSnp)
+	tst.tombstones.prune(nextSnp.minPartID())
+	for partID := range nextIntroduction.quarantined {
+		tst.moveToQuarantine(partID)
+	}
 	if nextIntroduction.applied != nil {
 		close(nextIntroduction.applied)
 	}
@@ -97,7 +97,7 @@ func buildSeriesByIndex(s *iterBuilder) (series []*searcherIterator, err error)
 		switch s.indexRuleForSorting.GetType() {
 		case databasev1.IndexRule_TYPE_TREE:
 			inner, err = tw.Table().Index().Iterator(fieldKey, rangeOpts, s.order)
-		case databasev1.IndexRule_TYPE_INVERTED:
+		case databasev1.IndexRule_TYPE_INVERTED, databasev1.IndexRule_TYPE_RANGE:
 			inner, err = tw.Table().Index().Iterator(fieldKey, rangeOpts, s.order)
 		case databasev1.IndexRule_TYPE_UNSPECIFIED:
 			return nil, errors.WithMessagef(errUnspecifiedIndexType, "index rule:%v", s.indexRuleForSorting)
@@ -0,0 +1,113 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+)
+
+var (
+	mergeSchedulerMeter      = observability.NewMeterProvider(observability.RootScope.SubScope("stream").SubScope("merge_scheduler"))
+	mergeThrottledCount      = mergeSchedulerMeter.Counter("throttled_total")
+	mergeThrottledSecondsSum = mergeSchedulerMeter.Counter("throttled_seconds_total")
+)
+
+// mergeWorkers schedules the background merges of the file parts of all the tables.
+var mergeWorkers = newMergeScheduler(defaultMergeWorkers(), 0)
+
+func defaultMergeWorkers() int {
+	return max(1, runtime.GOMAXPROCS(0)/4)
+}
+
+// mergeScheduler bounds the background merges running at a time, and throttles them to a write rate of the disk.
+// The merges of the in-memory parts run by the flushers aren't scheduled, since delaying them piles up the memory.
+type mergeScheduler struct {
+	slots chan struct{}
+	// nextFree is when the bytes written by the merges so far are paid off at maxWriteRate.
+	nextFree     time.Time
+	now          func() time.Time
+	maxWriteRate uint64
+	mu           sync.Mutex
+}
+
+// newMergeScheduler creates a scheduler running up to workers merges at a time.
+// maxWriteRate is the max bytes per second written by the merges, 0 means unbounded.
+func newMergeScheduler(workers int, maxWriteRate uint64) *mergeScheduler {
+	return &mergeScheduler{
+		slots:        make(chan struct{}, max(workers, 1)),
+		maxWriteRate: maxWriteRate,
+		now:          time.Now,
+	}
+}
+
+// workers returns the max number of the merges running at a time.
+func (ms *mergeScheduler) workers() int {
+	return cap(ms.slots)
+}
+
+// acquire waits for a free worker, then waits until the bytes written by the previous merges are paid off.
+// It returns false if closeCh is closed in the meantime.
+func (ms *mergeScheduler) acquire(closeCh <-chan struct{}) bool {
+	select {
+	case ms.slots <- struct{}{}:
+	case <-closeCh:
+		return false
+	}
+	delay := ms.throttle()
+	if delay <= 0 {
+		return true
+	}
+	mergeThrottledCount.Inc(1)
+	mergeThrottledSecondsSum.Inc(delay.Seconds())
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-closeCh:
+		<-ms.slots
+		return false
+	}
+}
+
+func (ms *mergeScheduler) throttle() time.Duration {
+	if ms.maxWriteRate == 0 {
+		return 0
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.nextFree.Sub(ms.now())
+}
+
+// release frees the worker, and charges the bytes written by its merge to the write rate.
+func (ms *mergeScheduler) release(written uint64) {
+	if ms.maxWriteRate > 0 && written > 0 {
+		ms.mu.Lock()
+		now := ms.now()
+		if ms.nextFree.Before(now) {
+			ms.nextFree = now
+		}
+		ms.nextFree = ms.nextFree.Add(time.Duration(float64(written) / float64(ms.maxWriteRate) * float64(time.Second)))
+		ms.mu.Unlock()
+	}
+	<-ms.slots
+}
@@ -0,0 +1,123 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_mergeScheduler_workers(t *testing.T) {
+	ms := newMergeScheduler(2, 0)
+	closeCh := make(chan struct{})
+	require.True(t, ms.acquire(closeCh))
+	require.True(t, ms.acquire(closeCh))
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- ms.acquire(closeCh)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("a third merge runs along with two workers")
+	case <-time.After(50 * time.Millisecond):
+	}
+	ms.release(0)
+	assert.True(t, <-acquired)
+
+	go func() {
+		acquired <- ms.acquire(closeCh)
+	}()
+	close(closeCh)
+	assert.False(t, <-acquired)
+}
+
+func Test_mergeScheduler_throttle(t *testing.T) {
+	now := time.Unix(0, 0)
+	ms := newMergeScheduler(1, 1000)
+	ms.now = func() time.Time { return now }
+	assert.Zero(t, ms.throttle())
+
+	ms.release(reserveSlot(ms, 500))
+	assert.Equal(t, 500*time.Millisecond, ms.throttle())
+	ms.release(reserveSlot(ms, 2000))
+	assert.Equal(t, 2500*time.Millisecond, ms.throttle())
+
+	// the debt is paid off as time goes by, and the idle time isn't saved up
+	now = now.Add(10 * time.Second)
+	assert.Negative(t, ms.throttle())
+	ms.release(reserveSlot(ms, 1000))
+	assert.Equal(t, time.Second, ms.throttle())
+
+	unbounded := newMergeScheduler(1, 0)
+	unbounded.release(reserveSlot(unbounded, math.MaxUint32))
+	assert.Zero(t, unbounded.throttle())
+}
+
+func reserveSlot(ms *mergeScheduler, written uint64) uint64 {
+	ms.slots <- struct{}{}
+	return written
+}
+
+func Test_tsTable_getPartSetsToMerge(t *testing.T) {
+	var parts []*partWrapper
+	for i := 0; i < 9; i++ {
+		parts = append(parts, &partWrapper{p: &part{partMetadata: partMetadata{
+			ID: uint64(i), CompressedSizeBytes: 100, TotalCount: 10, MinTimestamp: int64(i),
+		}}})
+	}
+	// the in-memory parts are never merged by the merger
+	parts = append(parts, &partWrapper{mp: &memPart{}, p: &part{partMetadata: partMetadata{ID: 9, CompressedSizeBytes: 100, TotalCount: 10}}})
+	tst := &tsTable{option: option{mergePolicy: newDefaultMergePolicyForTesting()}}
+	s := &snapshot{parts: parts}
+
+	tests := []struct {
+		name         string
+		dropped      []*partWrapper
+		wantSizes    []int
+		freeDiskSize uint64
+		n            int
+	}{
+		{name: "a worker", n: 1, freeDiskSize: math.MaxUint64, wantSizes: []int{4}},
+		{name: "workers", n: 3, freeDiskSize: math.MaxUint64, wantSizes: []int{4, 4}},
+		{name: "free disk space", n: 3, freeDiskSize: 600, wantSizes: []int{4, 2}},
+		{name: "dropped", n: 3, freeDiskSize: math.MaxUint64, dropped: parts[:3], wantSizes: []int{4, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sets := tst.getPartSetsToMerge(s, tt.dropped, tt.freeDiskSize, tt.n)
+			seen := make(map[uint64]struct{})
+			var sizes []int
+			for _, set := range sets {
+				sizes = append(sizes, len(set))
+				for _, pw := range set {
+					require.Nil(t, pw.mp)
+					require.NotContains(t, tt.dropped, pw)
+					_, ok := seen[pw.ID()]
+					require.False(t, ok, "part %d is merged twice", pw.ID())
+					seen[pw.ID()] = struct{}{}
+				}
+			}
+			assert.Equal(t, tt.wantSizes, sizes)
+		})
+	}
+}
@@ -20,13 +20,17 @@ package stream
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/dustin/go-humanize"
 
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/watcher"
 )
 
@@ -39,21 +43,40 @@ func (tst *tsTable) mergeLoop(merges chan *mergerIntroduction, flusherNotifier w
 	if ew == nil {
 		return
 	}
-
-	var pwsChunk []*partWrapper
+	var offloadCh <-chan time.Time
+	if tst.option.cold != nil {
+		ticker := time.NewTicker(tst.option.cold.interval)
+		defer ticker.Stop()
+		offloadCh = ticker.C
+	}
 
 	for {
 		select {
 		case <-tst.loopCloser.CloseNotify():
 			return
+		case <-offloadCh:
+			curSnapshot := tst.currentSnapshot()
+			if curSnapshot == nil {
+				continue
+			}
+			err := tst.offloadColdParts(curSnapshot, merges)
+			curSnapshot.decRef()
+			if errors.Is(err, errClosed) {
+				return
+			}
+			if err != nil {
+				tst.l.Logger.Warn().Err(err).Msg("cannot offload the parts to the cold tier")
+			}
 		case <-ew.Watch():
+			if !watchdog.WaitReleased(watchdog.ActionPauseMerges, tst.loopCloser.CloseNotify()) {
+				return
+			}
 			curSnapshot := tst.currentSnapshot()
 			if curSnapshot == nil {
 				continue
 			}
 			if curSnapshot.epoch != epoch {
-				var err error
-				if pwsChunk, err = tst.mergeSnapshot(curSnapshot, merges, pwsChunk[:0]); err != nil {
+				if err := tst.mergeSnapshot(curSnapshot, merges); err != nil {
 					if errors.Is(err, errClosed) {
 						curSnapshot.decRef()
 						return
@@ -73,18 +96,44 @@ func (tst *tsTable) mergeLoop(merges chan *mergerIntroduction, flusherNotifier w
 	}
 }
 
-func (tst *tsTable) mergeSnapshot(curSnapshot *snapshot, merges chan *mergerIntroduction, dst []*partWrapper) ([]*partWrapper, error) {
+// mergeSnapshot merges the disjoint sets of the file parts picked by the merge policy in parallel,
+// each of which takes a worker of the merge scheduler.
+func (tst *tsTable) mergeSnapshot(curSnapshot *snapshot, merges chan *mergerIntroduction) error {
+	closeCh := tst.loopCloser.CloseNotify()
+	expired, err := tst.dropExpiredParts(curSnapshot, merges, closeCh)
+	if err != nil {
+		return err
+	}
 	freeDiskSize := tst.freeDiskSpace(tst.root)
-	var toBeMerged map[uint64]struct{}
-	dst, toBeMerged = tst.getPartsToMerge(curSnapshot, freeDiskSize, dst)
-	if len(dst) < 2 {
-		return nil, nil
+	sets := tst.getPartSetsToMerge(curSnapshot, expired, freeDiskSize, mergeWorkers.workers())
+	if len(sets) == 0 {
+		return nil
 	}
-	if _, err := tst.mergePartsThenSendIntroduction(snapshotCreatorMerger, dst,
-		toBeMerged, merges, tst.loopCloser.CloseNotify()); err != nil {
-		return dst, err
+	errs := make([]error, len(sets))
+	var wg sync.WaitGroup
+	for i := range sets {
+		if !mergeWorkers.acquire(closeCh) {
+			errs[i] = errClosed
+			break
+		}
+		wg.Add(1)
+		go func(parts []*partWrapper, errPtr *error) {
+			defer wg.Done()
+			toBeMerged := make(map[uint64]struct{}, len(parts))
+			for _, pw := range parts {
+				toBeMerged[pw.ID()] = struct{}{}
+			}
+			newPart, err := tst.mergePartsThenSendIntroduction(snapshotCreatorMerger, parts, toBeMerged, merges, closeCh)
+			var written uint64
+			if newPart != nil {
+				written = newPart.p.partMetadata.CompressedSizeBytes
+			}
+			mergeWorkers.release(written)
+			*errPtr = err
+		}(sets[i], &errs[i])
 	}
-	return dst, nil
+	wg.Wait()
+	return errors.Join(errs...)
 }
 
 func (tst *tsTable) mergePartsThenSendIntroduction(creator snapshotCreator, parts []*partWrapper, merged map[uint64]struct{}, merges chan *mergerIntroduction,
@@ -93,8 +142,18 @@ func (tst *tsTable) mergePartsThenSendIntroduction(creator snapshotCreator, part
 	reservedSpace := tst.reserveSpace(parts)
 	defer releaseDiskSpace(reservedSpace)
 	start := time.Now()
-	newPart, err := mergeParts(tst.fileSystem, closeCh, parts, atomic.AddUint64(&tst.curPartID, 1), tst.root)
+	newPart, skipped, err := mergeParts(tst.fileSystem, closeCh, parts, atomic.AddUint64(&tst.curPartID, 1), tst.root, tst.sizing, tst.option.tagCodec, tst.option.ioOpts)
 	if err != nil {
+		if partID, ok := corruptedPartID(err); ok {
+			for _, pw := range parts {
+				if pw.ID() != partID || pw.mp != nil {
+					continue
+				}
+				if errQuarantine := tst.quarantinePart(pw, err, merges); errQuarantine != nil {
+					return nil, errQuarantine
+				}
+			}
+		}
 		return nil, err
 	}
 	elapsed := time.Since(start)
@@ -138,6 +197,8 @@ func (tst *tsTable) mergePartsThenSendIntroduction(creator snapshotCreator, part
 	mi.creator = creator
 	mi.newPart = newPart
 	mi.merged = merged
+	// the parts with the skipped blocks are kept for the repair
+	mi.quarantined = skippedParts(parts, skipped)
 	mi.applied = make(chan struct{})
 	select {
 	case merges <- mi:
@@ -149,6 +210,7 @@ func (tst *tsTable) mergePartsThenSendIntroduction(creator snapshotCreator, part
 	case <-tst.loopCloser.CloseNotify():
 		return newPart, errClosed
 	}
+	tst.writeSkippedBlocks(mi.quarantined, skipped)
 	return newPart, nil
 }
 
@@ -181,26 +243,72 @@ func releaseDiskSpace(n uint64) {
 
 var reservedDiskSpace uint64
 
-func (tst *tsTable) getPartsToMerge(snapshot *snapshot, freeDiskSize uint64, dst []*partWrapper) ([]*partWrapper, map[uint64]struct{}) {
+// getPartSetsToMerge picks up to n disjoint sets of the file parts by the compaction policy, which share the free disk space.
+// The dropped parts and the ones offloaded to the cold tier are skipped.
+func (tst *tsTable) getPartSetsToMerge(snapshot *snapshot, dropped []*partWrapper, freeDiskSize uint64, n int) [][]*partWrapper {
 	var parts []*partWrapper
 
 	for _, pw := range snapshot.parts {
-		if pw.mp != nil || pw.p.partMetadata.TotalCount < 1 {
+		if pw.mp != nil || pw.p.cold || pw.p.partMetadata.TotalCount < 1 || slices.Contains(dropped, pw) {
 			continue
 		}
 		parts = append(parts, pw)
 	}
 
-	dst = tst.option.mergePolicy.getPartsToMerge(dst, parts, freeDiskSize)
-	if len(dst) == 0 {
-		return nil, nil
+	policy := tst.option.compaction()
+	var sets [][]*partWrapper
+	for len(sets) < n {
+		set := policy.getPartsToMerge(nil, parts, freeDiskSize)
+		if len(set) < 2 {
+			break
+		}
+		sets = append(sets, set)
+		freeDiskSize -= min(freeDiskSize, sumCompressedSize(set))
+		parts = slices.DeleteFunc(parts, func(pw *partWrapper) bool {
+			return slices.Contains(set, pw)
+		})
 	}
+	return sets
+}
 
-	toBeMerged := make(map[uint64]struct{})
-	for _, pw := range dst {
-		toBeMerged[pw.ID()] = struct{}{}
+// dropExpiredParts drops the file parts out of the ttl as a whole, if the parts are merged within the time windows.
+// It returns the parts dropped.
+func (tst *tsTable) dropExpiredParts(snapshot *snapshot, merges chan *mergerIntroduction, closeCh <-chan struct{}) ([]*partWrapper, error) {
+	tp, ok := tst.option.compaction().(*timeWindowPolicy)
+	if !ok {
+		return nil, nil
+	}
+	var parts []*partWrapper
+	for _, pw := range snapshot.parts {
+		if pw.mp == nil {
+			parts = append(parts, pw)
+		}
+	}
+	expired := tp.getExpiredParts(nil, parts, time.Now())
+	if len(expired) == 0 {
+		return nil, nil
+	}
+	mi := generateMergerIntroduction()
+	defer releaseMergerIntroduction(mi)
+	mi.creator = snapshotCreatorMerger
+	mi.merged = make(map[uint64]struct{}, len(expired))
+	for _, pw := range expired {
+		mi.merged[pw.ID()] = struct{}{}
+	}
+	mi.applied = make(chan struct{})
+	select {
+	case merges <- mi:
+	case <-closeCh:
+		return nil, errClosed
 	}
-	return dst, toBeMerged
+	select {
+	case <-mi.applied:
+	case <-closeCh:
+		return nil, errClosed
+	}
+	expiredPartsCounter.Inc(float64(len(expired)))
+	tst.l.Info().Int("parts", len(expired)).Msg("drop the parts out of the ttl")
+	return expired, nil
 }
 
 func (tst *tsTable) reserveSpace(parts []*partWrapper) uint64 {
@@ -216,44 +324,85 @@ func (tst *tsTable) reserveSpace(parts []*partWrapper) uint64 {
 
 var errNoPartToMerge = fmt.Errorf("no part to merge")
 
-func mergeParts(fileSystem fs.FileSystem, closeCh <-chan struct{}, parts []*partWrapper, partID uint64, root string) (*partWrapper, error) {
+// mergeParts merges the parts into a new file part, whose blocks are cut by the sizing and whose tag values are compressed by the tagCodec.
+// The files of the parts are read and written with the ioOpts. It returns the corrupted blocks it skips as well.
+func mergeParts(fileSystem fs.FileSystem, closeCh <-chan struct{}, parts []*partWrapper, partID uint64, root string,
+	sizing *blockSizing, tagCodec encoding.Codec, ioOpts fs.IOOptions,
+) (*partWrapper, []*blockError, error) {
 	if len(parts) == 0 {
-		return nil, errNoPartToMerge
+		return nil, nil, errNoPartToMerge
 	}
 	dstPath := partPath(root, partID)
 	pii := make([]*partMergeIter, 0, len(parts))
 	for i := range parts {
 		pmi := generatePartMergeIter()
-		pmi.mustInitFromPart(parts[i].p)
+		pmi.mustInitFromPartWithOptions(parts[i].p, ioOpts)
 		pii = append(pii, pmi)
 	}
 	br := generateBlockReader()
 	br.init(pii)
 	bw := generateBlockWriter()
-	bw.mustInitForFilePart(fileSystem, dstPath)
+	bw.mustInitForFilePart(fileSystem, dstPath, tagCodec, ioOpts)
 
-	pm, err := mergeBlocks(closeCh, bw, br)
+	pm, skipped, err := mergeBlocks(closeCh, bw, br, sizing)
+	if err != nil {
+		// the unfinished part is removed
+		bw.writers.MustClose()
+		fileSystem.MustRMAll(dstPath)
+	} else {
+		parts[0].p.tombstones.reclaim(br.reclaimed)
+	}
 	releaseBlockWriter(bw)
 	releaseBlockReader(br)
 	for i := range pii {
 		releasePartMergeIter(pii[i])
 	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	pm.SchemaRevision = parts[0].p.partMetadata.SchemaRevision
+	for _, pw := range parts[1:] {
+		pm.SchemaRevision = min(pm.SchemaRevision, pw.p.partMetadata.SchemaRevision)
+	}
+	pm.MinFlushSeq, pm.FlushSeq = parts[0].p.partMetadata.MinFlushSeq, parts[0].p.partMetadata.FlushSeq
+	for _, pw := range parts[1:] {
+		pm.MinFlushSeq = min(pm.MinFlushSeq, pw.p.partMetadata.MinFlushSeq)
+		pm.FlushSeq = max(pm.FlushSeq, pw.p.partMetadata.FlushSeq)
 	}
 	pm.mustWriteMetadata(fileSystem, dstPath)
 	fileSystem.SyncPath(dstPath)
 	p := mustOpenFilePart(partID, root, fileSystem)
-	return newPartWrapper(nil, p), nil
+	return newPartWrapper(nil, p), skipped, nil
 }
 
 var errClosed = fmt.Errorf("the merger is closed")
 
-func mergeBlocks(closeCh <-chan struct{}, bw *blockWriter, br *blockReader) (*partMetadata, error) {
+// mergeBlocks drops the blocks of the purged series, and skips the blocks failing to decode.
+// The full blocks needing no merge are copied without decoding them if their tag values are compressed by the same codec.
+// It returns the skipped ones along with the metadata of the merged part.
+// It fails if a part has more than maxSkippedBlocksPerPart corrupted blocks.
+func mergeBlocks(closeCh <-chan struct{}, bw *blockWriter, br *blockReader, sizing *blockSizing) (*partMetadata, []*blockError, error) {
+	var skipped []*blockError
+	skippedPerPart := make(map[uint64]int)
+	skip := func(err error) bool {
+		var be *blockError
+		if !errors.As(err, &be) {
+			return false
+		}
+		skippedPerPart[be.partID]++
+		if skippedPerPart[be.partID] > maxSkippedBlocksPerPart {
+			return false
+		}
+		skipped = append(skipped, be)
+		skippedBlocksCounter.Inc(1, "merge")
+		logger.GetLogger("stream").Warn().Err(be).Msg("skip the corrupted block in the merge")
+		return true
+	}
 	pendingBlockIsEmpty := true
 	pendingBlock := generateBlockPointer()
 	defer releaseBlockPointer(pendingBlock)
 	var tmpBlock *blockPointer
+	var rb *rawBlock
 	var decoder *encoding.BytesBlockDecoder
 	getDecoder := func() *encoding.BytesBlockDecoder {
 		if decoder == nil {
@@ -267,27 +416,76 @@ func mergeBlocks(closeCh <-chan struct{}, bw *blockWriter, br *blockReader) (*pa
 			decoder = nil
 		}
 	}
+	defer releaseDecoder()
 	for br.nextBlockMetadata() {
 		select {
 		case <-closeCh:
-			return nil, errClosed
+			return nil, skipped, errClosed
 		default:
 		}
 		b := br.block
+		if br.purged() {
+			br.skipPurgedBlock()
+			continue
+		}
+		sizing.observe(b.bm.seriesID, b.bm.uncompressedSizeBytes, b.bm.count)
+
+		// a full block merged with no other one is copied as it's encoded
+		if (pendingBlockIsEmpty || pendingBlock.bm.seriesID != b.bm.seriesID) &&
+			sizing.cutter(b.bm.seriesID).isFull(b.bm.count, b.bm.uncompressedSizeBytes) && br.rawCopyable(bw.writers.tagCodec) {
+			if !pendingBlockIsEmpty {
+				bw.mustWriteBlock(pendingBlock.bm.seriesID, &pendingBlock.block)
+				releaseDecoder()
+				pendingBlock.reset()
+				pendingBlockIsEmpty = true
+			}
+			if rb == nil {
+				rb = generateRawBlock()
+				defer releaseRawBlock(rb)
+			}
+			if err := br.loadRawBlock(rb); err != nil {
+				if skip(err) {
+					continue
+				}
+				return nil, skipped, err
+			}
+			bw.mustWriteRawBlock(rb)
+			rawCopiedBlocksCounter.Inc(1)
+			continue
+		}
 
 		if pendingBlockIsEmpty {
-			br.loadBlockData(getDecoder())
+			if err := br.loadBlockData(getDecoder()); err != nil {
+				if skip(err) {
+					continue
+				}
+				return nil, skipped, err
+			}
+			// all the elements of the block are deleted
+			if len(b.timestamps) == 0 {
+				continue
+			}
 			pendingBlock.copyFrom(b)
 			pendingBlockIsEmpty = false
 			continue
 		}
 
 		if pendingBlock.bm.seriesID != b.bm.seriesID ||
-			(pendingBlock.isFull() && pendingBlock.bm.timestamps.max <= b.bm.timestamps.min) {
+			(pendingBlock.isFull(sizing.cutter(pendingBlock.bm.seriesID)) && pendingBlock.bm.timestamps.max <= b.bm.timestamps.min) {
 			bw.mustWriteBlock(pendingBlock.bm.seriesID, &pendingBlock.block)
 			releaseDecoder()
 			pendingBlock.reset()
-			br.loadBlockData(getDecoder())
+			if err := br.loadBlockData(getDecoder()); err != nil {
+				if skip(err) {
+					pendingBlockIsEmpty = true
+					continue
+				}
+				return nil, skipped, err
+			}
+			if len(b.timestamps) == 0 {
+				pendingBlockIsEmpty = true
+				continue
+			}
 			pendingBlock.copyFrom(b)
 			continue
 		}
@@ -298,9 +496,17 @@ func mergeBlocks(closeCh <-chan struct{}, bw *blockWriter, br *blockReader) (*pa
 		}
 		tmpBlock.reset()
 		tmpBlock.bm.seriesID = b.bm.seriesID
-		br.loadBlockData(getDecoder())
+		if err := br.loadBlockData(getDecoder()); err != nil {
+			if skip(err) {
+				continue
+			}
+			return nil, skipped, err
+		}
+		if len(b.timestamps) == 0 {
+			continue
+		}
 		mergeTwoBlocks(tmpBlock, pendingBlock, b)
-		if tmpBlock.uncompressedSizeBytes() <= maxUncompressedBlockSize {
+		if !sizing.cutter(tmpBlock.bm.seriesID).isFull(uint64(len(tmpBlock.timestamps)), tmpBlock.uncompressedSizeBytes()) {
 			if len(tmpBlock.timestamps) == 0 {
 				pendingBlockIsEmpty = true
 			}
@@ -314,7 +520,7 @@ func mergeBlocks(closeCh <-chan struct{}, bw *blockWriter, br *blockReader) (*pa
 		pendingBlockIsEmpty = true
 	}
 	if err := br.error(); err != nil {
-		return nil, fmt.Errorf("cannot read block to merge: %w", err)
+		return nil, skipped, fmt.Errorf("cannot read block to merge: %w", err)
 	}
 	if !pendingBlockIsEmpty {
 		bw.mustWriteBlock(pendingBlock.bm.seriesID, &pendingBlock.block)
@@ -322,7 +528,7 @@ func mergeBlocks(closeCh <-chan struct{}, bw *blockWriter, br *blockReader) (*pa
 	releaseDecoder()
 	var result partMetadata
 	bw.Flush(&result)
-	return &result, nil
+	return &result, skipped, nil
 }
 
 func mergeTwoBlocks(target, left, right *blockPointer) {
@@ -336,20 +542,26 @@ func mergeTwoBlocks(target, left, right *blockPointer) {
 
 	defer target.updateMetadata()
 
+	// the target adopts the columns of the first block, then grows them once to hold the second one
+	appendInOrder := func(first, second *blockPointer) {
+		target.appendAll(first)
+		target.reserve(len(second.timestamps) - second.idx)
+		target.appendAll(second)
+	}
 	if left.bm.timestamps.max < right.bm.timestamps.min {
-		target.appendAll(left)
-		target.appendAll(right)
+		appendInOrder(left, right)
 		return
 	}
 	if right.bm.timestamps.max < left.bm.timestamps.min {
-		target.appendAll(right)
-		target.appendAll(left)
+		appendInOrder(right, left)
 		return
 	}
 	if appendIfEmpty(left, right) || appendIfEmpty(right, left) {
 		return
 	}
 
+	target.reserve(len(left.timestamps) - left.idx + len(right.timestamps) - right.idx)
+
 	for {
 		i := left.idx
 		ts2 := right.timestamps[right.idx]
@@ -19,13 +19,17 @@ package stream
 
 import (
 	"errors"
+	"fmt"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 	"github.com/apache/skywalking-banyandb/pkg/test"
@@ -248,8 +252,8 @@ func Test_mergeParts(t *testing.T) {
 			name:   "Test with single part",
 			esList: []*elements{esTS1},
 			want: []blockMetadata{
-				{seriesID: 1, count: 1, uncompressedSizeBytes: 881},
-				{seriesID: 2, count: 1, uncompressedSizeBytes: 55},
+				{seriesID: 1, count: 1, uncompressedSizeBytes: 863},
+				{seriesID: 2, count: 1, uncompressedSizeBytes: 48},
 				{seriesID: 3, count: 1, uncompressedSizeBytes: 8},
 			},
 		},
@@ -257,8 +261,8 @@ func Test_mergeParts(t *testing.T) {
 			name:   "Test with multiple parts with different ts",
 			esList: []*elements{esTS1, esTS2, esTS2},
 			want: []blockMetadata{
-				{seriesID: 1, count: 3, uncompressedSizeBytes: 2643},
-				{seriesID: 2, count: 3, uncompressedSizeBytes: 165},
+				{seriesID: 1, count: 3, uncompressedSizeBytes: 2589},
+				{seriesID: 2, count: 3, uncompressedSizeBytes: 144},
 				{seriesID: 3, count: 3, uncompressedSizeBytes: 24},
 			},
 		},
@@ -266,8 +270,8 @@ func Test_mergeParts(t *testing.T) {
 			name:   "Test with multiple parts with same ts",
 			esList: []*elements{esTS1, esTS1, esTS1},
 			want: []blockMetadata{
-				{seriesID: 1, count: 3, uncompressedSizeBytes: 2643},
-				{seriesID: 2, count: 3, uncompressedSizeBytes: 165},
+				{seriesID: 1, count: 3, uncompressedSizeBytes: 2589},
+				{seriesID: 2, count: 3, uncompressedSizeBytes: 144},
 				{seriesID: 3, count: 3, uncompressedSizeBytes: 24},
 			},
 		},
@@ -275,12 +279,10 @@ func Test_mergeParts(t *testing.T) {
 			name:   "Test with multiple parts with a large quantity of different ts",
 			esList: []*elements{generateHugeEs(1, 5000, 1), generateHugeEs(5001, 10000, 2)},
 			want: []blockMetadata{
-				{seriesID: 1, count: 2395, uncompressedSizeBytes: 2109995},
-				{seriesID: 1, count: 2395, uncompressedSizeBytes: 2109995},
-				{seriesID: 1, count: 2605, uncompressedSizeBytes: 2295005},
-				{seriesID: 1, count: 2395, uncompressedSizeBytes: 2109995},
-				{seriesID: 1, count: 210, uncompressedSizeBytes: 185010},
-				{seriesID: 2, count: 2, uncompressedSizeBytes: 110},
+				{seriesID: 1, count: 4790, uncompressedSizeBytes: 4133770},
+				{seriesID: 1, count: 2605, uncompressedSizeBytes: 2248115},
+				{seriesID: 1, count: 2605, uncompressedSizeBytes: 2248115},
+				{seriesID: 2, count: 2, uncompressedSizeBytes: 96},
 				{seriesID: 3, count: 2, uncompressedSizeBytes: 16},
 			},
 		},
@@ -291,13 +293,14 @@ func Test_mergeParts(t *testing.T) {
 			verify := func(t *testing.T, pp []*partWrapper, fileSystem fs.FileSystem, root string, partID uint64) {
 				closeCh := make(chan struct{})
 				defer close(closeCh)
-				p, err := mergeParts(fileSystem, closeCh, pp, partID, root)
+				p, skipped, err := mergeParts(fileSystem, closeCh, pp, partID, root, nil, encoding.CodecZSTD, fs.IOOptions{})
 				if tt.wantErr != nil {
 					if !errors.Is(err, tt.wantErr) {
 						t.Fatalf("Unexpected error: got %v, want %v", err, tt.wantErr)
 					}
 					return
 				}
+				require.Empty(t, skipped)
 				defer p.decRef()
 				pmi := &partMergeIter{}
 				pmi.mustInitFromPart(p.p)
@@ -360,3 +363,183 @@ func Test_mergeParts(t *testing.T) {
 		})
 	}
 }
+
+// Test_mergeParts_tagCodec verifies the parts written by a codec are merged into a part written by another one.
+func Test_mergeParts_tagCodec(t *testing.T) {
+	merge := func(t *testing.T, partCodec, codec encoding.Codec) (rows []string, compressedSizeBytes uint64) {
+		tmpPath, defFn := test.Space(require.New(t))
+		defer defFn()
+		var pp []*partWrapper
+		defer func() {
+			for _, pw := range pp {
+				pw.decRef()
+			}
+		}()
+		for _, es := range []*elements{esTS1, generateHugeEs(10, 500, 4)} {
+			mp := generateMemPart()
+			mp.tagCodec = partCodec
+			mp.mustInitFromElements(es)
+			pp = append(pp, newPartWrapper(mp, openMemPart(mp)))
+		}
+		closeCh := make(chan struct{})
+		defer close(closeCh)
+		p, skipped, err := mergeParts(fs.NewLocalFileSystem(), closeCh, pp, 1, tmpPath, nil, codec, fs.IOOptions{})
+		require.NoError(t, err)
+		require.Empty(t, skipped)
+		defer p.decRef()
+
+		pmi := &partMergeIter{}
+		pmi.mustInitFromPart(p.p)
+		reader := &blockReader{}
+		reader.init([]*partMergeIter{pmi})
+		decoder := &encoding.BytesBlockDecoder{}
+		for reader.nextBlockMetadata() {
+			require.NoError(t, reader.loadBlockData(decoder))
+			b := reader.block
+			for i := range b.timestamps {
+				row := fmt.Sprintf("%d/%d/%s", b.bm.seriesID, b.timestamps[i], b.elementIDs[i])
+				for _, tf := range b.tagFamilies {
+					for _, tg := range tf.tags {
+						row += fmt.Sprintf("/%s.%s=%x", tf.name, tg.name, tg.values[i])
+					}
+				}
+				rows = append(rows, row)
+			}
+		}
+		require.NoError(t, reader.error())
+		return rows, p.p.partMetadata.CompressedSizeBytes
+	}
+
+	want, zstdSizeBytes := merge(t, encoding.CodecZSTD, encoding.CodecZSTD)
+	require.Len(t, want, 3+491+2)
+	for _, codec := range []encoding.Codec{encoding.CodecSnappy, encoding.CodecNone} {
+		t.Run(fmt.Sprintf("codec %d", codec), func(t *testing.T) {
+			got, _ := merge(t, encoding.CodecZSTD, codec)
+			assert.Equal(t, want, got)
+			got, sizeBytes := merge(t, codec, encoding.CodecZSTD)
+			assert.Equal(t, want, got)
+			assert.Equal(t, zstdSizeBytes, sizeBytes)
+		})
+	}
+	_, noneSizeBytes := merge(t, encoding.CodecZSTD, encoding.CodecNone)
+	assert.Greater(t, noneSizeBytes, zstdSizeBytes)
+}
+
+func Test_mergeParts_schemaRevision(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	var pp []*partWrapper
+	defer func() {
+		for _, pw := range pp {
+			pw.decRef()
+		}
+	}()
+	for i, es := range []*elements{esTS1, esTS2} {
+		mp := generateMemPart()
+		mp.mustInitFromElements(es)
+		mp.partMetadata.SchemaRevision = int64(5 - 2*i)
+		pp = append(pp, newPartWrapper(mp, openMemPart(mp)))
+	}
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	p, _, err := mergeParts(fs.NewLocalFileSystem(), closeCh, pp, 1, tmpPath, nil, encoding.CodecZSTD, fs.IOOptions{})
+	require.NoError(t, err)
+	defer p.decRef()
+	// the merged part is as old as the oldest schema its elements are written by
+	assert.Equal(t, int64(3), p.p.partMetadata.SchemaRevision)
+}
+
+func Test_mergeParts_flushSeq(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	var pp []*partWrapper
+	defer func() {
+		for _, pw := range pp {
+			pw.decRef()
+		}
+	}()
+	for i, es := range []*elements{esTS1, esTS2} {
+		mp := generateMemPart()
+		mp.mustInitFromElements(es)
+		mp.partMetadata.MinFlushSeq, mp.partMetadata.FlushSeq = uint64(4-2*i), uint64(5-2*i)
+		pp = append(pp, newPartWrapper(mp, openMemPart(mp)))
+	}
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	p, _, err := mergeParts(fs.NewLocalFileSystem(), closeCh, pp, 1, tmpPath, nil, encoding.CodecZSTD, fs.IOOptions{})
+	require.NoError(t, err)
+	defer p.decRef()
+	// the merged part covers the flushes of all the parts
+	assert.Equal(t, uint64(2), p.p.partMetadata.MinFlushSeq)
+	assert.Equal(t, uint64(5), p.p.partMetadata.FlushSeq)
+}
+
+func Benchmark_mergeParts(b *testing.B) {
+	const partsCount = 30
+	var pp []*partWrapper
+	for i := 0; i < partsCount; i++ {
+		mp := generateMemPart()
+		mp.mustInitFromElements(generateHugeEs(int64(i*200+1), int64((i+1)*200), 1))
+		pp = append(pp, newPartWrapper(mp, openMemPart(mp)))
+	}
+	defer func() {
+		for _, pw := range pp {
+			pw.decRef()
+		}
+	}()
+	tmpPath, defFn := test.Space(require.New(b))
+	defer defFn()
+	fileSystem := fs.NewLocalFileSystem()
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p, _, err := mergeParts(fileSystem, closeCh, pp, uint64(i+1), tmpPath, nil, encoding.CodecZSTD, fs.IOOptions{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		p.decRef()
+	}
+}
+
+func Test_mergeParts_ioOptions(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	fileSystem := fs.NewLocalFileSystem()
+	ioOpts := fs.IOOptions{Direct: true, DropCache: true}
+	var pp []*partWrapper
+	defer func() {
+		for _, pw := range pp {
+			pw.decRef()
+		}
+	}()
+	for i, es := range []*elements{esTS1, esTS2} {
+		mp := generateMemPart()
+		mp.mustInitFromElements(es)
+		mp.mustFlushWithOptions(fileSystem, partPath(tmpPath, uint64(i)), ioOpts)
+		releaseMemPart(mp)
+		pp = append(pp, newPartWrapper(nil, mustOpenFilePart(uint64(i), tmpPath, fileSystem)))
+	}
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	// the hints change how the files are read and written, but not their content
+	want, _, err := mergeParts(fileSystem, closeCh, pp, 10, tmpPath, nil, encoding.CodecZSTD, fs.IOOptions{})
+	require.NoError(t, err)
+	defer want.decRef()
+	got, _, err := mergeParts(fileSystem, closeCh, pp, 11, tmpPath, nil, encoding.CodecZSTD, ioOpts)
+	require.NoError(t, err)
+	defer got.decRef()
+	for _, entry := range fileSystem.ReadDir(partPath(tmpPath, 10)) {
+		wantData, readErr := fileSystem.Read(filepath.Join(partPath(tmpPath, 10), entry.Name()))
+		require.NoError(t, readErr)
+		gotData, readErr := fileSystem.Read(filepath.Join(partPath(tmpPath, 11), entry.Name()))
+		require.NoError(t, readErr)
+		if entry.Name() != metadataFilename {
+			assert.Equal(t, wantData, gotData, entry.Name())
+		}
+	}
+	assert.Equal(t, want.p.partMetadata.TotalCount, got.p.partMetadata.TotalCount)
+	assert.Equal(t, want.p.partMetadata.CompressedSizeBytes, got.p.partMetadata.CompressedSizeBytes)
+}
@@ -33,6 +33,8 @@ import (
 	"github.com/apache/skywalking-banyandb/banyand/metadata"
 	"github.com/apache/skywalking-banyandb/banyand/metadata/schema"
 	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/intern"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	resourceSchema "github.com/apache/skywalking-banyandb/pkg/schema"
 )
@@ -105,10 +107,13 @@ func (sr *schemaRepo) OnAddOrUpdate(metadata schema.Metadata) {
 			Metadata: g.GetMetadata(),
 		})
 	case schema.KindStream:
+		streamMetadata := metadata.Spec.(*databasev1.Stream).GetMetadata()
+		// the names of the old schema are dropped from the intern table if they aren't used anymore
+		intern.Rotate(streamMetadata.GetModRevision())
 		sr.SendMetadataEvent(resourceSchema.MetadataEvent{
 			Typ:      resourceSchema.EventAddOrUpdate,
 			Kind:     resourceSchema.EventKindResource,
-			Metadata: metadata.Spec.(*databasev1.Stream).GetMetadata(),
+			Metadata: streamMetadata,
 		})
 	case schema.KindIndexRuleBinding:
 		irb, ok := metadata.Spec.(*databasev1.IndexRuleBinding)
@@ -228,9 +233,9 @@ func newSupplier(path string, svc *service) *supplier {
 	}
 }
 
-func (s *supplier) OpenResource(shardNum uint32, supplier resourceSchema.Supplier, spec resourceSchema.Resource) (io.Closer, error) {
+func (s *supplier) OpenResource(groupOpts *commonv1.ResourceOpts, supplier resourceSchema.Supplier, spec resourceSchema.Resource) (io.Closer, error) {
 	streamSchema := spec.Schema().(*databasev1.Stream)
-	return openStream(shardNum, supplier, streamSpec{
+	return openStream(groupOpts.GetShardNum(), supplier, streamSpec{
 		schema:     streamSchema,
 		indexRules: spec.IndexRules(),
 	}, s.l), nil
@@ -243,23 +248,67 @@ func (s *supplier) ResourceSchema(md *commonv1.Metadata) (resourceSchema.Resourc
 }
 
 func (s *supplier) OpenDB(groupSchema *commonv1.Group) (io.Closer, error) {
+	tableOpt := s.option
+	tableOpt.tagCodec = tagCodec(groupSchema.ResourceOpts.GetTagCompression())
+	tableOpt = tableOpt.withStreamBlock(groupSchema.ResourceOpts.GetStreamBlock())
+	tableOpt.compactionPolicy = newCompactionPolicy(groupSchema.ResourceOpts.GetStreamCompaction(), s.option.mergePolicy, groupSchema.ResourceOpts.Ttl)
 	opts := storage.TSDBOpts[*tsTable, option]{
-		ShardNum:                       groupSchema.ResourceOpts.ShardNum,
-		Location:                       path.Join(s.path, groupSchema.Metadata.Name),
-		TSTableCreator:                 newTSTable,
-		SegmentInterval:                storage.MustToIntervalRule(groupSchema.ResourceOpts.SegmentInterval),
-		TTL:                            storage.MustToIntervalRule(groupSchema.ResourceOpts.Ttl),
-		Option:                         s.option,
-		SeriesIndexFlushTimeoutSeconds: s.option.flushTimeout.Nanoseconds() / int64(time.Second),
+		ShardNum:        groupSchema.ResourceOpts.ShardNum,
+		Location:        path.Join(s.path, groupSchema.Metadata.Name),
+		TSTableCreator:  newTSTable,
+		SegmentInterval: storage.MustToIntervalRule(groupSchema.ResourceOpts.SegmentInterval),
+		TTL:             storage.MustToIntervalRule(groupSchema.ResourceOpts.Ttl),
+		Option:          tableOpt,
+		SeriesIndex:     s.option.seriesIndex,
 	}
 	name := groupSchema.Metadata.Name
-	return storage.OpenTSDB(
+	db, err := storage.OpenTSDB(
 		common.SetPosition(context.Background(), func(p common.Position) common.Position {
 			p.Module = "stream"
 			p.Database = name
 			return p
 		}),
 		opts)
+	if err != nil {
+		return nil, err
+	}
+	if tableOpt.wal.enabled {
+		if err = writeReplayedSeries(db); err != nil {
+			s.l.Error().Err(err).Str("group", name).Msg("cannot write the series replayed from the wal")
+		}
+	}
+	return db, nil
+}
+
+// tagCodec returns the codec compressing the tag values of the blocks of a group.
+func tagCodec(compression commonv1.Compression) encoding.Codec {
+	switch compression {
+	case commonv1.Compression_COMPRESSION_SNAPPY:
+		return encoding.CodecSnappy
+	case commonv1.Compression_COMPRESSION_NONE:
+		return encoding.CodecNone
+	default:
+		return encoding.CodecZSTD
+	}
+}
+
+// withStreamBlock overrides the bounds of the blocks and the flushTimeout by the ones set by a group.
+// The min rows of the data node are lowered to the max rows of the group, so that the bounds stay in order.
+func (o option) withStreamBlock(opts *commonv1.StreamBlockOpts) option {
+	if size := opts.GetMaxUncompressedSize(); size > 0 {
+		o.blockMaxSize = size
+	}
+	if rows := opts.GetMinRows(); rows > 0 {
+		o.blockMinRows = rows
+	}
+	if rows := opts.GetMaxRows(); rows > 0 {
+		o.blockMaxRows = rows
+		o.blockMinRows = min(o.blockMinRows, rows)
+	}
+	if ft := opts.GetFlushTimeout(); ft != nil && ft.AsDuration() > 0 {
+		o.flushTimeout = ft.AsDuration()
+	}
+	return o
 }
 
 type portableSupplier struct {
@@ -284,9 +333,9 @@ func (*portableSupplier) OpenDB(_ *commonv1.Group) (io.Closer, error) {
 	panic("do not support open db")
 }
 
-func (s *portableSupplier) OpenResource(shardNum uint32, _ resourceSchema.Supplier, spec resourceSchema.Resource) (io.Closer, error) {
+func (s *portableSupplier) OpenResource(groupOpts *commonv1.ResourceOpts, _ resourceSchema.Supplier, spec resourceSchema.Resource) (io.Closer, error) {
 	streamSchema := spec.Schema().(*databasev1.Stream)
-	return openStream(shardNum, nil, streamSpec{
+	return openStream(groupOpts.GetShardNum(), nil, streamSpec{
 		schema:     streamSchema,
 		indexRules: spec.IndexRules(),
 	}, s.l), nil
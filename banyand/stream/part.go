@@ -32,6 +32,7 @@ import (
 	"github.com/apache/skywalking-banyandb/pkg/compress/zstd"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/fs/remote"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 )
@@ -109,20 +110,34 @@ type element struct {
 }
 
 type part struct {
-	path                 string
-	primary              fs.Reader
-	timestamps           fs.Reader
-	elementIDs           fs.Reader
-	tagFamilyMetadata    map[string]fs.Reader
-	tagFamilies          map[string]fs.Reader
+	path              string
+	primary           fs.Reader
+	timestamps        fs.Reader
+	elementIDs        fs.Reader
+	tagFamilyMetadata map[string]fs.Reader
+	tagFamilies       map[string]fs.Reader
+	// tombstones are the series purged from the tsTable the part belongs to, which is set once the part is introduced.
+	tombstones           *tombstones
+	metadataCache        blockMetadataCache
 	primaryBlockMetadata []primaryBlockMetadata
 	partMetadata         partMetadata
+	// cold is set if the part is offloaded to the cold tier.
+	cold bool
 }
 
 func (p *part) containTimestamp(timestamp common.ItemID) bool {
 	return timestamp >= common.ItemID(p.partMetadata.MinTimestamp) && timestamp <= common.ItemID(p.partMetadata.MaxTimestamp)
 }
 
+// estimatedBlockSize estimates the size of the block on disk from its metadata by the compression ratio of the part,
+// since the sizes of the tag values are only recorded in the tag family metadata.
+func (p *part) estimatedBlockSize(bm *blockMetadata) uint64 {
+	if p.partMetadata.UncompressedSizeBytes == 0 {
+		return 0
+	}
+	return uint64(float64(bm.uncompressedSizeBytes) * float64(p.partMetadata.CompressedSizeBytes) / float64(p.partMetadata.UncompressedSizeBytes))
+}
+
 func (p *part) close() {
 	fs.MustClose(p.primary)
 	fs.MustClose(p.timestamps)
@@ -142,43 +157,48 @@ func (p *part) String() string {
 func (p *part) getElement(seriesID common.SeriesID, timestamp common.ItemID, tagProjection []pbv1.TagProjection) (*element, int, error) {
 	// TODO: refactor to column-based query
 	// TODO: cache blocks
-	for i, primaryMeta := range p.primaryBlockMetadata {
-		if i != len(p.primaryBlockMetadata)-1 && seriesID >= p.primaryBlockMetadata[i+1].seriesID {
+	for i := range p.primaryBlockMetadata {
+		// the blocks of a series might span several primary blocks
+		if i != len(p.primaryBlockMetadata)-1 && seriesID > p.primaryBlockMetadata[i+1].seriesID {
 			continue
 		}
-		if seriesID < p.primaryBlockMetadata[i].seriesID ||
-			timestamp < common.ItemID(p.primaryBlockMetadata[i].minTimestamp) ||
-			timestamp > common.ItemID(p.primaryBlockMetadata[i].maxTimestamp) {
+		if seriesID < p.primaryBlockMetadata[i].seriesID {
 			break
 		}
-
-		compressedPrimaryBuf := make([]byte, primaryMeta.size)
-		fs.MustReadData(p.primary, int64(primaryMeta.offset), compressedPrimaryBuf)
-		var err error
-		primaryBuf := make([]byte, 0)
-		primaryBuf, err = zstd.Decompress(primaryBuf[:0], compressedPrimaryBuf)
-		if err != nil {
-			return nil, 0, fmt.Errorf("cannot decompress index block: %w", err)
+		if timestamp < common.ItemID(p.primaryBlockMetadata[i].minTimestamp) ||
+			timestamp > common.ItemID(p.primaryBlockMetadata[i].maxTimestamp) {
+			continue
 		}
-		bm := make([]blockMetadata, 0)
-		bm, err = unmarshalBlockMetadata(bm, primaryBuf)
+
+		bm, err := p.readBlockMetadata(&p.primaryBlockMetadata[i])
 		if err != nil {
-			return nil, 0, fmt.Errorf("cannot unmarshal index block: %w", err)
+			return nil, 0, newPartError(p, err)
 		}
 		var targetBlockMetadata blockMetadata
+		var found bool
 		for _, blockMetadata := range bm {
-			if blockMetadata.seriesID == seriesID {
+			// a series is cut into several blocks, the one whose time range covers the timestamp is read
+			if blockMetadata.seriesID == seriesID &&
+				timestamp >= common.ItemID(blockMetadata.timestamps.min) && timestamp <= common.ItemID(blockMetadata.timestamps.max) {
 				targetBlockMetadata = blockMetadata
+				found = true
 				break
 			}
 		}
+		if !found {
+			continue
+		}
 
-		timestamps := make([]int64, 0)
-		timestamps = mustReadTimestampsFrom(timestamps, &targetBlockMetadata.timestamps, int(targetBlockMetadata.count), p.timestamps)
+		timestamps, err := readTimestampsFrom(make([]int64, 0), &targetBlockMetadata.timestamps, int(targetBlockMetadata.count), p.timestamps)
+		if err != nil {
+			return nil, 0, newBlockError(p, &targetBlockMetadata, err)
+		}
 		for i, ts := range timestamps {
 			if timestamp == common.ItemID(ts) {
-				elementIDs := make([]string, 0)
-				elementIDs = mustReadElementIDsFrom(elementIDs, &targetBlockMetadata.elementIDs, int(targetBlockMetadata.count), p.elementIDs)
+				elementIDs, err := readElementIDsFrom(make([]string, 0), &targetBlockMetadata.elementIDs, int(targetBlockMetadata.count), p.elementIDs)
+				if err != nil {
+					return nil, 0, newBlockError(p, &targetBlockMetadata, err)
+				}
 				tfs := make([]*tagFamily, 0)
 				for j := range tagProjection {
 					name := tagProjection[j].Family
@@ -187,7 +207,10 @@ func (p *part) getElement(seriesID common.SeriesID, timestamp common.ItemID, tag
 						continue
 					}
 					decoder := &encoding.BytesBlockDecoder{}
-					tf := unmarshalTagFamily(decoder, name, block, tagProjection[j].Names, p.tagFamilyMetadata[name], p.tagFamilies[name], len(timestamps))
+					tf, err := unmarshalTagFamily(decoder, name, block, tagProjection[j].Names, p.tagFamilyMetadata[name], p.tagFamilies[name], len(timestamps))
+					if err != nil {
+						return nil, 0, newBlockError(p, &targetBlockMetadata, fmt.Errorf("cannot decode tag family %q: %w", name, err))
+					}
 					tfs = append(tfs, tf)
 				}
 
@@ -206,22 +229,46 @@ func (p *part) getElement(seriesID common.SeriesID, timestamp common.ItemID, tag
 	return nil, 0, errors.New("element not found")
 }
 
+// readBlockMetadata returns the block metadata of the primary block, which is cached by the part once it's read.
+func (p *part) readBlockMetadata(pbm *primaryBlockMetadata) ([]blockMetadata, error) {
+	if bm, ok := blockMetadataCaches.get(p, pbm.offset); ok {
+		return bm, nil
+	}
+	compressedPrimaryBuf := make([]byte, pbm.size)
+	if err := fs.ReadData(p.primary, int64(pbm.offset), compressedPrimaryBuf); err != nil {
+		return nil, fmt.Errorf("cannot read index block: %w", err)
+	}
+	primaryBuf, err := zstd.Decompress(nil, compressedPrimaryBuf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress index block: %w", err)
+	}
+	bm, err := unmarshalBlockMetadata(nil, primaryBuf, p.partMetadata.FormatVersion)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal index block: %w", err)
+	}
+	blockMetadataCaches.put(p, pbm.offset, bm)
+	return bm, nil
+}
+
 func unmarshalTagFamily(decoder *encoding.BytesBlockDecoder, name string,
 	tagFamilyMetadataBlock *dataBlock, tagProjection []string, metaReader, valueReader fs.Reader, count int,
-) *tagFamily {
+) (*tagFamily, error) {
 	if len(tagProjection) < 1 {
-		return &tagFamily{}
+		return &tagFamily{}, nil
 	}
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(int(tagFamilyMetadataBlock.size))
 	bb.Buf = bytes.ResizeExact(bb.Buf, int(tagFamilyMetadataBlock.size))
-	fs.MustReadData(metaReader, int64(tagFamilyMetadataBlock.offset), bb.Buf)
+	if err := fs.ReadData(metaReader, int64(tagFamilyMetadataBlock.offset), bb.Buf); err != nil {
+		bigValuePool.Release(bb)
+		return nil, fmt.Errorf("cannot read tagFamilyMetadata: %w", err)
+	}
 	tfm := generateTagFamilyMetadata()
 	defer releaseTagFamilyMetadata(tfm)
 	err := tfm.unmarshal(bb.Buf)
+	bigValuePool.Release(bb)
 	if err != nil {
-		logger.Panicf("%s: cannot unmarshal tagFamilyMetadata: %v", metaReader.Path(), err)
+		return nil, fmt.Errorf("%s: cannot unmarshal tagFamilyMetadata: %w", metaReader.Path(), err)
 	}
-	bigValuePool.Release(bb)
 	tf := tagFamily{}
 	tf.name = name
 	tf.tags = tf.resizeTags(len(tagProjection))
@@ -229,12 +276,14 @@ func unmarshalTagFamily(decoder *encoding.BytesBlockDecoder, name string,
 	for j := range tagProjection {
 		for i := range tfm.tagMetadata {
 			if tagProjection[j] == tfm.tagMetadata[i].name {
-				tf.tags[j].mustReadValues(decoder, valueReader, tfm.tagMetadata[i], uint64(count))
+				if err := tf.tags[j].readValues(decoder, valueReader, tfm.tagMetadata[i], uint64(count)); err != nil {
+					return nil, err
+				}
 				break
 			}
 		}
 	}
-	return &tf
+	return &tf, nil
 }
 
 func openMemPart(mp *memPart) *part {
@@ -261,11 +310,15 @@ func openMemPart(mp *memPart) *part {
 type memPart struct {
 	tagFamilyMetadata map[string]*bytes.Buffer
 	tagFamilies       map[string]*bytes.Buffer
-	meta              bytes.Buffer
-	primary           bytes.Buffer
-	timestamps        bytes.Buffer
-	elementIDs        bytes.Buffer
-	partMetadata      partMetadata
+	// sizing is the block sizing of the tsTable the elements are written to, which cuts the blocks by bytes alone if it's nil.
+	sizing *blockSizing
+	// tagCodec compresses the tag values of the blocks.
+	tagCodec     encoding.Codec
+	meta         bytes.Buffer
+	primary      bytes.Buffer
+	timestamps   bytes.Buffer
+	elementIDs   bytes.Buffer
+	partMetadata partMetadata
 }
 
 func (mp *memPart) mustCreateMemTagFamilyWriters(name string) (fs.Writer, fs.Writer) {
@@ -287,6 +340,8 @@ func (mp *memPart) mustCreateMemTagFamilyWriters(name string) (fs.Writer, fs.Wri
 
 func (mp *memPart) reset() {
 	mp.partMetadata.reset()
+	mp.sizing = nil
+	mp.tagCodec = encoding.CodecZSTD
 	mp.meta.Reset()
 	mp.primary.Reset()
 	mp.timestamps.Reset()
@@ -304,50 +359,86 @@ func (mp *memPart) reset() {
 }
 
 func (mp *memPart) mustInitFromElements(es *elements) {
+	// the options of the tsTable the elements are written to survive the reset
+	sizing, tagCodec := mp.sizing, mp.tagCodec
 	mp.reset()
+	mp.sizing, mp.tagCodec = sizing, tagCodec
 
 	if len(es.timestamps) == 0 {
 		return
 	}
 
-	sort.Sort(es)
+	// the elements are sorted by series and timestamp. The sort is stable to keep
+	// the arrival order of the elements with the same timestamp.
+	if !sort.IsSorted(es) {
+		sort.Stable(es)
+	}
 
 	bsw := generateBlockWriter()
 	bsw.MustInitForMemPart(mp)
+	sizes := make([]uint64, len(es.timestamps))
+	for i := range sizes {
+		sizes[i] = uncompressedElementSizeBytes(i, es)
+	}
+	if mp.sizing != nil {
+		// the rows of every series are observed before the blocks are cut,
+		// so that the blocks of a series never seen are sized by its own rows.
+		var start int
+		var sizeBytes uint64
+		for i := range sizes {
+			sizeBytes += sizes[i]
+			if i == len(sizes)-1 || es.seriesIDs[i+1] != es.seriesIDs[i] {
+				mp.sizing.observe(es.seriesIDs[i], sizeBytes, uint64(i+1-start))
+				start = i + 1
+				sizeBytes = 0
+			}
+		}
+	}
 	var sidPrev common.SeriesID
+	var cutter blockCutter
 	uncompressedBlockSizeBytes := uint64(0)
 	var indexPrev int
 	for i := range es.timestamps {
 		sid := es.seriesIDs[i]
 		if sidPrev == 0 {
 			sidPrev = sid
+			cutter = mp.sizing.cutter(sid)
 		}
 
-		if uncompressedBlockSizeBytes >= maxUncompressedBlockSize || sid != sidPrev {
+		if cutter.isFull(uint64(i-indexPrev), uncompressedBlockSizeBytes) || sid != sidPrev {
 			bsw.MustWriteElements(sidPrev, es.timestamps[indexPrev:i], es.elementIDs[indexPrev:i], es.tagFamilies[indexPrev:i])
+			if sid != sidPrev {
+				cutter = mp.sizing.cutter(sid)
+			}
 			sidPrev = sid
 			indexPrev = i
 			uncompressedBlockSizeBytes = 0
 		}
-		uncompressedBlockSizeBytes += uncompressedElementSizeBytes(i, es)
+		uncompressedBlockSizeBytes += sizes[i]
 	}
 	bsw.MustWriteElements(sidPrev, es.timestamps[indexPrev:], es.elementIDs[indexPrev:], es.tagFamilies[indexPrev:])
 	bsw.Flush(&mp.partMetadata)
+	mp.partMetadata.SchemaRevision = es.schemaRevision
 	releaseBlockWriter(bsw)
 }
 
 func (mp *memPart) mustFlush(fileSystem fs.FileSystem, path string) {
+	mp.mustFlushWithOptions(fileSystem, path, fs.IOOptions{})
+}
+
+// mustFlushWithOptions is mustFlush writing the data files with the ioOpts.
+func (mp *memPart) mustFlushWithOptions(fileSystem fs.FileSystem, path string, ioOpts fs.IOOptions) {
 	fileSystem.MkdirPanicIfExist(path, dirPermission)
 
-	fs.MustFlush(fileSystem, mp.meta.Buf, filepath.Join(path, metaFilename), filePermission)
-	fs.MustFlush(fileSystem, mp.primary.Buf, filepath.Join(path, primaryFilename), filePermission)
-	fs.MustFlush(fileSystem, mp.timestamps.Buf, filepath.Join(path, timestampsFilename), filePermission)
-	fs.MustFlush(fileSystem, mp.elementIDs.Buf, filepath.Join(path, elementIDsFilename), filePermission)
+	fs.MustFlushWithOptions(fileSystem, mp.meta.Buf, filepath.Join(path, metaFilename), filePermission, ioOpts)
+	fs.MustFlushWithOptions(fileSystem, mp.primary.Buf, filepath.Join(path, primaryFilename), filePermission, ioOpts)
+	fs.MustFlushWithOptions(fileSystem, mp.timestamps.Buf, filepath.Join(path, timestampsFilename), filePermission, ioOpts)
+	fs.MustFlushWithOptions(fileSystem, mp.elementIDs.Buf, filepath.Join(path, elementIDsFilename), filePermission, ioOpts)
 	for name, tf := range mp.tagFamilies {
-		fs.MustFlush(fileSystem, tf.Buf, filepath.Join(path, name+tagFamiliesFilenameExt), filePermission)
+		fs.MustFlushWithOptions(fileSystem, tf.Buf, filepath.Join(path, name+tagFamiliesFilenameExt), filePermission, ioOpts)
 	}
 	for name, tfh := range mp.tagFamilyMetadata {
-		fs.MustFlush(fileSystem, tfh.Buf, filepath.Join(path, name+tagFamiliesMetadataFilenameExt), filePermission)
+		fs.MustFlushWithOptions(fileSystem, tfh.Buf, filepath.Join(path, name+tagFamiliesMetadataFilenameExt), filePermission, ioOpts)
 	}
 
 	mp.partMetadata.mustWriteMetadata(fileSystem, path)
@@ -382,9 +473,11 @@ func releaseMemPart(mp *memPart) {
 var memPartPool sync.Pool
 
 type partWrapper struct {
-	mp  *memPart
-	p   *part
-	ref int32
+	mp *memPart
+	p  *part
+	// walOffsets are the offsets of the writes held by the memory part, see elements.walOffsets.
+	walOffsets []uint64
+	ref        int32
 }
 
 func newPartWrapper(mp *memPart, p *part) *partWrapper {
@@ -400,6 +493,7 @@ func (pw *partWrapper) decRef() {
 	if n > 0 {
 		return
 	}
+	blockMetadataCaches.evict(pw.p)
 	if pw.mp != nil {
 		releaseMemPart(pw.mp)
 		pw.mp = nil
@@ -419,6 +513,9 @@ func mustOpenFilePart(id uint64, root string, fileSystem fs.FileSystem) *part {
 	p.path = partPath
 	p.partMetadata.mustReadMetadata(fileSystem, partPath)
 	p.partMetadata.ID = id
+	if tiered, ok := fileSystem.(*remote.FileSystem); ok {
+		p.cold = tiered.Offloaded(partPath)
+	}
 
 	metaPath := path.Join(partPath, metaFilename)
 	pr := mustOpenReader(metaPath, fileSystem)
@@ -30,6 +30,7 @@ import (
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 )
 
 type partIter struct {
@@ -44,6 +45,9 @@ type partIter struct {
 	sidIdx               int
 	minTimestamp         int64
 	maxTimestamp         int64
+	cacheHits            int64
+	cacheMisses          int64
+	bytesRead            int64
 }
 
 func (pi *partIter) reset() {
@@ -56,6 +60,7 @@ func (pi *partIter) reset() {
 	pi.compressedPrimaryBuf = pi.compressedPrimaryBuf[:0]
 	pi.primaryBuf = pi.primaryBuf[:0]
 	pi.err = nil
+	pi.cacheHits, pi.cacheMisses, pi.bytesRead = 0, 0, 0
 }
 
 func (pi *partIter) init(p *part, sids []common.SeriesID, minTimestamp, maxTimestamp int64) {
@@ -177,9 +182,18 @@ func searchPBM(pbmIndex []primaryBlockMetadata, sid common.SeriesID) []primaryBl
 	return pbmIndex[n-1:]
 }
 
+// readPrimaryBlock returns the block metadata of the primary block, which is cached by the part once it's read.
 func (pi *partIter) readPrimaryBlock(mr *primaryBlockMetadata) ([]blockMetadata, error) {
+	if bms, ok := blockMetadataCaches.get(pi.p, mr.offset); ok {
+		pi.cacheHits++
+		return bms, nil
+	}
+	pi.cacheMisses++
+	pi.bytesRead += int64(mr.size)
 	pi.compressedPrimaryBuf = bytes.ResizeOver(pi.compressedPrimaryBuf, int(mr.size))
-	fs.MustReadData(pi.p.primary, int64(mr.offset), pi.compressedPrimaryBuf)
+	if err := fs.ReadData(pi.p.primary, int64(mr.offset), pi.compressedPrimaryBuf); err != nil {
+		return nil, fmt.Errorf("cannot read index block: %w", err)
+	}
 
 	var err error
 	pi.primaryBuf, err = zstd.Decompress(pi.primaryBuf[:0], pi.compressedPrimaryBuf)
@@ -187,10 +201,11 @@ func (pi *partIter) readPrimaryBlock(mr *primaryBlockMetadata) ([]blockMetadata,
 		return nil, fmt.Errorf("cannot decompress index block: %w", err)
 	}
 	bm := make([]blockMetadata, 0)
-	bm, err = unmarshalBlockMetadata(bm, pi.primaryBuf)
+	bm, err = unmarshalBlockMetadata(bm, pi.primaryBuf, pi.p.partMetadata.FormatVersion)
 	if err != nil {
 		return nil, fmt.Errorf("cannot unmarshal index block: %w", err)
 	}
+	blockMetadataCaches.put(pi.p, mr.offset, bm)
 	return bm, nil
 }
 
@@ -237,17 +252,23 @@ func (pi *partIter) findBlock() bool {
 }
 
 type partMergeIter struct {
+	p                    *part
 	seqReaders           seqReaders
 	err                  error
 	primaryBlockMetadata []primaryBlockMetadata
 	compressedPrimaryBuf []byte
 	primaryBuf           []byte
-	block                blockPointer
-	primaryMetadataIdx   int
+	// tagProjection limits the tag families and the tags loadBlockData decodes, which are all of them if it's nil.
+	// The merges need all of them, while the jobs reading a few tags of a part can save decoding the rest.
+	tagProjection      []pbv1.TagProjection
+	block              blockPointer
+	primaryMetadataIdx int
 }
 
 func (pmi *partMergeIter) reset() {
+	pmi.p = nil
 	pmi.err = nil
+	pmi.tagProjection = nil
 	pmi.seqReaders.reset()
 	pmi.primaryBlockMetadata = nil
 	pmi.primaryMetadataIdx = 0
@@ -257,11 +278,23 @@ func (pmi *partMergeIter) reset() {
 }
 
 func (pmi *partMergeIter) mustInitFromPart(p *part) {
+	pmi.mustInitFromPartWithOptions(p, fs.IOOptions{})
+}
+
+// mustInitFromPartWithOptions is mustInitFromPart reading the files of p with the ioOpts.
+func (pmi *partMergeIter) mustInitFromPartWithOptions(p *part, ioOpts fs.IOOptions) {
 	pmi.reset()
-	pmi.seqReaders.init(p)
+	pmi.p = p
+	pmi.seqReaders.initWithOptions(p, ioOpts)
 	pmi.primaryBlockMetadata = p.primaryBlockMetadata
 }
 
+// mustInitFromPartWithProjection is mustInitFromPart decoding only the tag families and the tags in tagProjection.
+func (pmi *partMergeIter) mustInitFromPartWithProjection(p *part, tagProjection []pbv1.TagProjection) {
+	pmi.mustInitFromPart(p)
+	pmi.tagProjection = tagProjection
+}
+
 func (pmi *partMergeIter) error() error {
 	if pmi.err == nil || errors.Is(pmi.err, io.EOF) {
 		return nil
@@ -277,11 +310,14 @@ func (pmi *partMergeIter) nextBlockMetadata() bool {
 	if len(pmi.primaryBuf) == 0 {
 		if err := pmi.loadPrimaryBuf(); err != nil {
 			pmi.err = err
+			if !errors.Is(err, io.EOF) {
+				pmi.err = newPartError(pmi.p, err)
+			}
 			return false
 		}
 	}
 	if err := pmi.loadBlockMetadata(); err != nil {
-		pmi.err = err
+		pmi.err = newPartError(pmi.p, err)
 		return false
 	}
 	return true
@@ -293,7 +329,9 @@ func (pmi *partMergeIter) loadPrimaryBuf() error {
 	}
 	pm := pmi.primaryBlockMetadata[pmi.primaryMetadataIdx]
 	pmi.compressedPrimaryBuf = bytes.ResizeOver(pmi.compressedPrimaryBuf, int(pm.size))
-	pmi.seqReaders.primary.mustReadFull(pmi.compressedPrimaryBuf)
+	if err := pmi.seqReaders.primary.readFull(pmi.compressedPrimaryBuf); err != nil {
+		return fmt.Errorf("cannot read primary block: %w", err)
+	}
 	var err error
 	pmi.primaryBuf, err = zstd.Decompress(pmi.primaryBuf[:0], pmi.compressedPrimaryBuf)
 	if err != nil {
@@ -306,7 +344,7 @@ func (pmi *partMergeIter) loadPrimaryBuf() error {
 func (pmi *partMergeIter) loadBlockMetadata() error {
 	pmi.block.reset()
 	var err error
-	pmi.primaryBuf, err = pmi.block.bm.unmarshal(pmi.primaryBuf)
+	pmi.primaryBuf, err = pmi.block.bm.unmarshal(pmi.primaryBuf, pmi.p.partMetadata.FormatVersion)
 	if err != nil {
 		pm := pmi.primaryBlockMetadata[pmi.primaryMetadataIdx-1]
 		return fmt.Errorf("can't read block metadata from primary at %d: %w", pm.offset, err)
@@ -314,8 +352,13 @@ func (pmi *partMergeIter) loadBlockMetadata() error {
 	return nil
 }
 
-func (pmi *partMergeIter) mustLoadBlockData(decoder *encoding.BytesBlockDecoder, block *blockPointer) {
-	block.block.mustSeqReadFrom(decoder, &pmi.seqReaders, pmi.block.bm)
+func (pmi *partMergeIter) loadBlockData(decoder *encoding.BytesBlockDecoder, block *blockPointer) error {
+	if err := block.block.seqReadFromProjection(decoder, &pmi.seqReaders, pmi.block.bm, pmi.tagProjection); err != nil {
+		// the next block can be read even if this one is skipped
+		pmi.seqReaders.resync()
+		return newBlockError(pmi.p, &pmi.block.bm, err)
+	}
+	return nil
 }
 
 func generatePartMergeIter() *partMergeIter {
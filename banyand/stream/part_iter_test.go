@@ -23,11 +23,13 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 	"github.com/apache/skywalking-banyandb/pkg/test"
 )
 
@@ -184,7 +186,7 @@ func Test_partMergeIter_nextBlock(t *testing.T) {
 				for pi.nextBlockMetadata() {
 					got = append(got, pi.block.bm)
 					require.Nil(t, pi.block.bm.tagProjection)
-					pi.mustLoadBlockData(decoder, &pi.block)
+					require.NoError(t, pi.loadBlockData(decoder, &pi.block))
 					require.Equal(t, len(pi.block.bm.tagFamilies), len(pi.block.tagFamilies))
 				}
 
@@ -221,3 +223,67 @@ func Test_partMergeIter_nextBlock(t *testing.T) {
 		})
 	}
 }
+
+func Test_partMergeIter_loadBlockDataWithProjection(t *testing.T) {
+	es := generateWideEs(30, 3)
+	for i := range es.seriesIDs {
+		es.seriesIDs[i] = common.SeriesID(i%3 + 1)
+	}
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.mustInitFromElements(es)
+	decoder := generateColumnValuesDecoder()
+	defer releaseColumnValuesDecoder(decoder)
+
+	// load returns the elementIDs and the values of the tag family1.intTag of every block
+	load := func(p *part, tagProjection []pbv1.TagProjection) (elementIDs [][]string, values [][][]byte) {
+		pi := generatePartMergeIter()
+		defer releasePartMergeIter(pi)
+		pi.mustInitFromPartWithProjection(p, tagProjection)
+		for pi.nextBlockMetadata() {
+			require.NoError(t, pi.loadBlockData(decoder, &pi.block))
+			elementIDs = append(elementIDs, append([]string(nil), pi.block.elementIDs...))
+			if tagProjection != nil {
+				require.Len(t, pi.block.tagFamilies, 1)
+				require.Equal(t, "family1", pi.block.tagFamilies[0].name)
+				require.Len(t, pi.block.tagFamilies[0].tags, 1)
+			}
+			for _, tf := range pi.block.tagFamilies {
+				if tf.name != "family1" {
+					continue
+				}
+				for _, tag := range tf.tags {
+					if tag.name != "intTag" {
+						continue
+					}
+					var vv [][]byte
+					for _, v := range tag.values {
+						vv = append(vv, append([]byte(nil), v...))
+					}
+					values = append(values, vv)
+				}
+			}
+		}
+		require.NoError(t, pi.error())
+		if tagProjection != nil {
+			assert.Zero(t, pi.seqReaders.tagFamilyMetadata["family0"].bytesRead, "the tag families left out aren't read")
+			assert.Zero(t, pi.seqReaders.tagFamilies["family0"].bytesRead, "the tag families left out aren't read")
+		}
+		return elementIDs, values
+	}
+	verifyPart := func(p *part) {
+		defer p.close()
+		wantElementIDs, wantValues := load(p, nil)
+		require.Len(t, wantValues, 3)
+		gotElementIDs, gotValues := load(p, []pbv1.TagProjection{{Family: "family1", Names: []string{"intTag"}}})
+		assert.Equal(t, wantElementIDs, gotElementIDs)
+		assert.Equal(t, wantValues, gotValues)
+	}
+
+	verifyPart(openMemPart(mp))
+	tmpDir, defFn := test.Space(require.New(t))
+	defer defFn()
+	fileSystem := fs.NewLocalFileSystem()
+	mp.mustFlush(fileSystem, partPath(tmpDir, 1))
+	verifyPart(mustOpenFilePart(1, tmpDir, fileSystem))
+}
@@ -21,10 +21,21 @@ import (
 	"encoding/json"
 	"path/filepath"
 
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 )
 
+const (
+	// partFormatVersionTagStats is the version since which the block metadata have the statistics of the tags.
+	partFormatVersionTagStats = 1
+	// partFormatVersionTagCodec is the version since which the part metadata have the codec of the tag values,
+	// whose blocks are copied by the merges as they're encoded.
+	partFormatVersionTagCodec = 2
+	// currentPartFormatVersion is the version of the parts written.
+	currentPartFormatVersion = partFormatVersionTagCodec
+)
+
 type partMetadata struct {
 	CompressedSizeBytes   uint64 `json:"compressedSizeBytes"`
 	UncompressedSizeBytes uint64 `json:"uncompressedSizeBytes"`
@@ -32,7 +43,22 @@ type partMetadata struct {
 	BlocksCount           uint64 `json:"blocksCount"`
 	MinTimestamp          int64  `json:"minTimestamp"`
 	MaxTimestamp          int64  `json:"maxTimestamp"`
-	ID                    uint64 `json:"-"`
+	// FormatVersion is the version of the format of the part, which is 0 for the parts written before it's recorded.
+	FormatVersion uint64 `json:"formatVersion,omitempty"`
+	// TagCodec is the codec compressing the tag values, which is recorded since partFormatVersionTagCodec.
+	TagCodec encoding.Codec `json:"tagCodec,omitempty"`
+	// SeriesStats are the statistics of the series in the part sorted by their IDs,
+	// which are absent from the parts written before they're recorded.
+	SeriesStats []seriesStats `json:"seriesStats,omitempty"`
+	// SchemaRevision is the oldest revision of the stream schemas the elements in the part are written by,
+	// which is 0 for the parts written before it's recorded or replayed from the wal. The tags of the parts older than the schema of a stream
+	// might have been dropped and added again in another type, see blockCursor.staleTag.
+	SchemaRevision int64 `json:"schemaRevision,omitempty"`
+	// MinFlushSeq and FlushSeq are the range of the sequences of the flushes writing the elements in the part,
+	// which are 0 for the parts written before they're recorded. The subscriptions read the flushed parts in their order.
+	MinFlushSeq uint64 `json:"minFlushSeq,omitempty"`
+	FlushSeq    uint64 `json:"flushSeq,omitempty"`
+	ID          uint64 `json:"-"`
 }
 
 func (pm *partMetadata) reset() {
@@ -42,6 +68,12 @@ func (pm *partMetadata) reset() {
 	pm.BlocksCount = 0
 	pm.MinTimestamp = 0
 	pm.MaxTimestamp = 0
+	pm.FormatVersion = 0
+	pm.TagCodec = 0
+	pm.SeriesStats = nil
+	pm.SchemaRevision = 0
+	pm.MinFlushSeq = 0
+	pm.FlushSeq = 0
 	pm.ID = 0
 }
 
@@ -18,6 +18,10 @@
 package stream
 
 import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -25,6 +29,7 @@ import (
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 	"github.com/apache/skywalking-banyandb/pkg/test"
@@ -117,6 +122,84 @@ func TestMustInitFromElements(t *testing.T) {
 	}
 }
 
+func TestMustInitFromShuffledElements(t *testing.T) {
+	shuffled := &elements{}
+	for i := 0; i < 120; i++ {
+		id := strconv.Itoa(i)
+		shuffled.seriesIDs = append(shuffled.seriesIDs, common.SeriesID(i%3+1))
+		// every timestamp is shared by two elements of a series
+		shuffled.timestamps = append(shuffled.timestamps, int64(i/6))
+		shuffled.elementIDs = append(shuffled.elementIDs, id)
+		shuffled.tagFamilies = append(shuffled.tagFamilies, []tagValues{{
+			tag: "singleTag", values: []*tagValue{{tag: "strTag", valueType: pbv1.ValueTypeStr, value: []byte(id)}},
+		}})
+	}
+	rand.New(rand.NewSource(1)).Shuffle(shuffled.Len(), shuffled.Swap)
+
+	// the elements with the same timestamp are expected in their arrival order
+	type arrival struct {
+		id string
+		ts int64
+	}
+	want := make(map[common.SeriesID][]arrival)
+	for i, sid := range shuffled.seriesIDs {
+		want[sid] = append(want[sid], arrival{id: shuffled.elementIDs[i], ts: shuffled.timestamps[i]})
+	}
+	for _, aa := range want {
+		sort.SliceStable(aa, func(i, j int) bool { return aa[i].ts < aa[j].ts })
+	}
+
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.mustInitFromElements(shuffled)
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	fileSystem := fs.NewLocalFileSystem()
+	mp.mustFlush(fileSystem, partPath(tmpPath, 1))
+	p := mustOpenFilePart(1, tmpPath, fileSystem)
+	defer p.close()
+
+	pi := generatePartMergeIter()
+	defer releasePartMergeIter(pi)
+	pi.mustInitFromPart(p)
+	decoder := &encoding.BytesBlockDecoder{}
+	got := make(map[common.SeriesID][]arrival)
+	for pi.nextBlockMetadata() {
+		require.NoError(t, pi.loadBlockData(decoder, &pi.block))
+		b := &pi.block.block
+		require.NotPanics(t, b.validate)
+		for i := range b.timestamps {
+			require.Equal(t, b.elementIDs[i], string(b.tagFamilies[0].tags[0].values[i]), "the tag values follow their elements")
+			got[pi.block.bm.seriesID] = append(got[pi.block.bm.seriesID], arrival{id: b.elementIDs[i], ts: b.timestamps[i]})
+		}
+	}
+	require.NoError(t, pi.error())
+	assert.Equal(t, want, got)
+
+	ti := &tstIter{}
+	ti.init([]*part{p}, []common.SeriesID{1, 2, 3}, 0, math.MaxInt64)
+	result := queryResult{orderByTS: true, ascTS: true}
+	defer result.Release()
+	for ti.nextBlock() {
+		bc := generateBlockCursor()
+		bc.init(ti.piHeap[0].p, ti.piHeap[0].curBlock, queryOptions{
+			minTimestamp: 0,
+			maxTimestamp: math.MaxInt64,
+			StreamQueryOptions: pbv1.StreamQueryOptions{
+				TagProjection: []pbv1.TagProjection{{Family: "singleTag", Names: []string{"strTag"}}},
+			},
+		})
+		result.data = append(result.data, bc)
+	}
+	require.NoError(t, ti.Error())
+	var timestamps []int64
+	for r := result.Pull(); r != nil; r = result.Pull() {
+		timestamps = append(timestamps, r.Timestamps...)
+	}
+	assert.Len(t, timestamps, shuffled.Len())
+	assert.True(t, sort.SliceIsSorted(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] }))
+}
+
 var es = &elements{
 	seriesIDs:  []common.SeriesID{1, 1, 2, 2, 3, 3},
 	timestamps: []int64{1, 2, 8, 10, 100, 220},
@@ -0,0 +1,73 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apache/skywalking-banyandb/pkg/bytes"
+)
+
+func TestPoolCapacity(t *testing.T) {
+	const limit = 1 << 20
+	bigValueCapacity.SetMax(limit)
+	blockCursorCapacity.SetMax(limit)
+	defer func() {
+		bigValueCapacity.SetMax(0)
+		blockCursorCapacity.SetMax(0)
+	}()
+	baseline := heapInuse()
+	for round := 0; round < 3; round++ {
+		// the huge phase holds several buffers and cursors at once, as the concurrent queries do
+		bbs := make([]*bytes.Buffer, 8)
+		bcs := make([]*blockCursor, 8)
+		for i := range bbs {
+			bbs[i] = bigValuePool.Acquire(0)
+			bbs[i].Buf = bytes.ResizeOver(bbs[i].Buf, 4<<20)
+			bcs[i] = generateBlockCursor()
+			bcs[i].timestamps = make([]int64, 1<<18)
+		}
+		for i := range bbs {
+			bigValuePool.Release(bbs[i])
+			releaseBlockCursor(bcs[i])
+		}
+		// the tiny phase only gets the objects under the limit
+		for i := 0; i < 16; i++ {
+			bb := bigValuePool.Acquire(0)
+			assert.LessOrEqual(t, cap(bb.Buf), limit)
+			bb.Buf = append(bb.Buf, "tiny"...)
+			bigValuePool.Release(bb)
+			bc := generateBlockCursor()
+			assert.LessOrEqual(t, bc.capacity(), limit)
+			bc.timestamps = append(bc.timestamps, 1)
+			releaseBlockCursor(bc)
+		}
+		assert.Less(t, heapInuse(), baseline+16<<20, "the memory of the huge phase is released")
+	}
+}
+
+// heapInuse runs a single GC, which leaves the pooled objects alive in the victim cache.
+func heapInuse() uint64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapInuse
+}
@@ -0,0 +1,110 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+type purgeCallback struct {
+	l          *logger.Logger
+	schemaRepo *schemaRepo
+}
+
+func setUpPurgeCallback(l *logger.Logger, schemaRepo *schemaRepo) bus.MessageListener {
+	return &purgeCallback{
+		l:          l,
+		schemaRepo: schemaRepo,
+	}
+}
+
+func (p *purgeCallback) Rev(message bus.Message) (resp bus.Message) {
+	req, ok := message.Data().(*streamv1.PurgeRequest)
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("invalid event data type"))
+	}
+	s, ok := p.schemaRepo.loadStream(req.GetMetadata())
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("stream %s doesn't exist", req.GetMetadata()))
+	}
+	result, err := s.purge(context.Background(), req.GetDryRun())
+	if err != nil {
+		p.l.Error().Err(err).Stringer("stream", req.GetMetadata()).Msg("cannot purge the stream")
+		return bus.NewMessage(message.ID(), common.NewError("cannot purge the stream %s: %v", req.GetMetadata(), err))
+	}
+	if !req.GetDryRun() {
+		p.l.Info().Stringer("stream", req.GetMetadata()).Uint64("series", result.GetSeries()).
+			Uint64("blocks", result.GetBlocks()).Uint64("bytes", result.GetBytes()).Msg("purged the stream")
+	}
+	return bus.NewMessage(message.ID(), result)
+}
+
+// purge removes all the data of the stream. The series are tombstoned in every tsTable before they are removed
+// from the series index, so that the queries never read their blocks in between.
+// The blocks and the bytes are estimated from the block metadata, and nothing changes if dryRun is true.
+func (s *stream) purge(ctx context.Context, dryRun bool) (*streamv1.PurgeResponse, error) {
+	tsdb := s.databaseSupplier.SupplyTSDB().(storage.TSDB[*tsTable, option])
+	entity := make([]*modelv1.TagValue, len(s.schema.GetEntity().GetTagNames()))
+	for i := range entity {
+		entity[i] = pbv1.AnyTagValue
+	}
+	sl, err := tsdb.Lookup(ctx, &pbv1.Series{Subject: s.name, EntityValues: entity})
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot look up the series")
+	}
+	seriesIDs := make([]common.SeriesID, 0, len(sl))
+	for i := range sl {
+		seriesIDs = append(seriesIDs, sl[i].ID)
+	}
+	result := &streamv1.PurgeResponse{Series: uint64(len(seriesIDs))}
+	tabWrappers := tsdb.SelectTSTables(timestamp.NewInclusiveTimeRange(time.Unix(0, 0), time.Unix(0, math.MaxInt64)))
+	defer func() {
+		for i := range tabWrappers {
+			tabWrappers[i].DecRef()
+		}
+	}()
+	for _, tw := range tabWrappers {
+		stats, errPurge := tw.Table().purge(s.name, seriesIDs, dryRun)
+		if errPurge != nil {
+			return nil, errors.WithMessagef(errPurge, "cannot purge the segment %s", tw.GetTimeRange())
+		}
+		result.Blocks += stats.blocks
+		result.Bytes += stats.bytes
+		result.ReclaimedBytes += stats.reclaimedBytes
+	}
+	if dryRun {
+		return result, nil
+	}
+	if err = tsdb.IndexDB().Delete(seriesIDs); err != nil {
+		return nil, errors.WithMessage(err, "cannot remove the series from the index")
+	}
+	return result, nil
+}
@@ -18,11 +18,16 @@
 package stream
 
 import (
+	"bytes"
 	"container/heap"
 	"context"
 	"errors"
 	"fmt"
+	"runtime"
+	"slices"
 	"sort"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/multierr"
 
@@ -35,12 +40,28 @@ import (
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/partition"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/query/executor"
 )
 
 type queryOptions struct {
 	pbv1.StreamQueryOptions
-	minTimestamp int64
-	maxTimestamp int64
+	// tagTypes are the types of the tags in the schema, which the tags of the parts older than it are checked against.
+	tagTypes       map[string]pbv1.ValueType
+	tagPredicates  []tagPredicate
+	minTimestamp   int64
+	maxTimestamp   int64
+	schemaRevision int64
+}
+
+// schemaTagTypes returns the types of the tags of the stream by their names.
+func schemaTagTypes(schema *databasev1.Stream) map[string]pbv1.ValueType {
+	types := make(map[string]pbv1.ValueType)
+	for _, tf := range schema.GetTagFamilies() {
+		for _, t := range tf.GetTags() {
+			types[t.GetName()] = encodeTagValue(t.GetName(), t.GetType(), pbv1.NullTagValue).valueType
+		}
+	}
+	return types
 }
 
 func mustEncodeTagValue(name string, tagType databasev1.TagType, tagValue *modelv1.TagValue, num int) [][]byte {
@@ -85,7 +106,7 @@ func mustDecodeTagValue(valueType pbv1.ValueType, value []byte) *modelv1.TagValu
 		return int64ArrTagValue(values)
 	case pbv1.ValueTypeStrArr:
 		var values []string
-		bb := bigValuePool.Generate()
+		bb := bigValuePool.Acquire(0)
 		var err error
 		for len(value) > 0 {
 			bb.Buf, value, err = unmarshalVarArray(bb.Buf[:0], value)
@@ -101,6 +122,79 @@ func mustDecodeTagValue(valueType pbv1.ValueType, value []byte) *modelv1.TagValu
 	}
 }
 
+// mustDecodeTagValues appends the decoded values of src to dst.
+// The tag values of int64, string and binary data are allocated in bulk, others are decoded one by one.
+func mustDecodeTagValues(dst []*modelv1.TagValue, valueType pbv1.ValueType, src [][]byte) []*modelv1.TagValue {
+	n := len(dst)
+	dst = slices.Grow(dst, len(src))[:n+len(src)]
+	values := dst[n:]
+	switch valueType {
+	case pbv1.ValueTypeInt64:
+		tvs := make([]modelv1.TagValue, len(src))
+		ints := make([]modelv1.TagValue_Int, len(src))
+		int64s := make([]modelv1.Int, len(src))
+		for i, v := range src {
+			if v == nil {
+				values[i] = mustDecodeTagValue(valueType, v)
+				continue
+			}
+			int64s[i].Value = convert.BytesToInt64(v)
+			ints[i].Int = &int64s[i]
+			tvs[i].Value = &ints[i]
+			values[i] = &tvs[i]
+		}
+	case pbv1.ValueTypeStr:
+		tvs := make([]modelv1.TagValue, len(src))
+		strs := make([]modelv1.TagValue_Str, len(src))
+		strValues := make([]modelv1.Str, len(src))
+		// the strings share a single allocation
+		all := string(bytes.Join(src, nil))
+		var offset int
+		for i, v := range src {
+			if v == nil {
+				values[i] = pbv1.EmptyStrTagValue
+				continue
+			}
+			strValues[i].Value = all[offset : offset+len(v)]
+			offset += len(v)
+			strs[i].Str = &strValues[i]
+			tvs[i].Value = &strs[i]
+			values[i] = &tvs[i]
+		}
+	case pbv1.ValueTypeBinaryData:
+		tvs := make([]modelv1.TagValue, len(src))
+		binaries := make([]modelv1.TagValue_BinaryData, len(src))
+		all := bytes.Join(src, nil)
+		var offset int
+		for i, v := range src {
+			if v == nil {
+				values[i] = pbv1.EmptyBinaryTagValue
+				continue
+			}
+			// the capacity is limited to prevent an append from overwriting the next value
+			binaries[i].BinaryData = all[offset : offset+len(v) : offset+len(v)]
+			offset += len(v)
+			tvs[i].Value = &binaries[i]
+			values[i] = &tvs[i]
+		}
+	default:
+		for i, v := range src {
+			values[i] = mustDecodeTagValue(valueType, v)
+		}
+	}
+	return dst
+}
+
+// appendNullTagValues appends n null tag values to dst.
+func appendNullTagValues(dst []*modelv1.TagValue, n int) []*modelv1.TagValue {
+	l := len(dst)
+	dst = slices.Grow(dst, n)[:l+n]
+	for i := l; i < len(dst); i++ {
+		dst[i] = pbv1.NullTagValue
+	}
+	return dst
+}
+
 func int64TagValue(value int64) *modelv1.TagValue {
 	return &modelv1.TagValue{
 		Value: &modelv1.TagValue_Int{
@@ -151,7 +245,39 @@ func strArrTagValue(values []string) *modelv1.TagValue {
 	}
 }
 
+// queryWorkers is the max number of goroutines a query decodes the blocks in, 0 means GOMAXPROCS.
+var queryWorkers atomic.Int64
+
+// workerBudget bounds the goroutines a query spawns besides the one pulling the result.
+// A nil budget spawns no goroutine.
+type workerBudget chan struct{}
+
+func newWorkerBudget(workers int) workerBudget {
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers <= 1 {
+		return nil
+	}
+	return make(workerBudget, workers-1)
+}
+
+// tryAcquire returns false if all the workers are busy, then the caller does the work by itself.
+func (wb workerBudget) tryAcquire() bool {
+	select {
+	case wb <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (wb workerBudget) release() {
+	<-wb
+}
+
 type queryResult struct {
+	ctx          context.Context
 	entityMap    map[string]int
 	sidToIndex   map[common.SeriesID]int
 	tagNameIndex map[string]partition.TagLocator
@@ -159,70 +285,27 @@ type queryResult struct {
 	data         []*blockCursor
 	snapshots    []*snapshot
 	seriesList   pbv1.SeriesList
+	workers      workerBudget
+	warnings     *executor.Warnings
+	span         *executor.Span
+	account      *executor.MemoryAccount
+	topK         *topK
+	after        *pbv1.Cursor
+	accounted    int64  // the bytes of the loaded blocks accounted to the query
+	afterLeft    uint32 // the elements at the position of after left to drop
 	loaded       bool
 	orderByTS    bool
 	ascTS        bool
 }
 
 func (qr *queryResult) Pull() *pbv1.StreamResult {
-	if !qr.loaded {
-		if len(qr.data) == 0 {
-			return nil
-		}
-		// TODO:// Parallel load
-		tmpBlock := generateBlock()
-		defer releaseBlock(tmpBlock)
-		for i := 0; i < len(qr.data); i++ {
-			if !qr.data[i].loadData(tmpBlock) {
-				qr.data = append(qr.data[:i], qr.data[i+1:]...)
-				i--
-			}
-			if qr.schema.GetEntity() == nil || len(qr.schema.GetEntity().GetTagNames()) == 0 {
-				continue
-			}
-			sidIndex := qr.sidToIndex[qr.data[i].bm.seriesID]
-			series := qr.seriesList[sidIndex]
-			entityMap := make(map[string]int)
-			tagFamilyMap := make(map[string]int)
-			for idx, entity := range qr.schema.GetEntity().GetTagNames() {
-				entityMap[entity] = idx + 1
-			}
-			for idx, tagFamily := range qr.data[i].tagFamilies {
-				tagFamilyMap[tagFamily.name] = idx + 1
-			}
-			for _, tagFamilyProj := range qr.data[i].tagProjection {
-				for j, tagProj := range tagFamilyProj.Names {
-					offset := qr.tagNameIndex[tagProj]
-					tagFamilySpec := qr.schema.GetTagFamilies()[offset.FamilyOffset]
-					tagSpec := tagFamilySpec.GetTags()[offset.TagOffset]
-					if tagSpec.IndexedOnly {
-						continue
-					}
-					entityPos := entityMap[tagProj]
-					tagFamilyPos := tagFamilyMap[tagFamilyProj.Family]
-					if entityPos == 0 {
-						continue
-					}
-					if tagFamilyPos == 0 {
-						qr.data[i].tagFamilies[tagFamilyPos-1] = tagFamily{
-							name: tagFamilyProj.Family,
-							tags: make([]tag, 0),
-						}
-					}
-					valueType := pbv1.MustTagValueToValueType(series.EntityValues[entityPos-1])
-					qr.data[i].tagFamilies[tagFamilyPos-1].tags[j] = tag{
-						name:      tagProj,
-						values:    mustEncodeTagValue(tagProj, tagSpec.GetType(), series.EntityValues[entityPos-1], len(qr.data[i].timestamps)),
-						valueType: valueType,
-					}
-				}
-			}
-			if qr.orderByTimestampDesc() {
-				qr.data[i].idx = len(qr.data[i].timestamps) - 1
-			}
-		}
-		qr.loaded = true
-		heap.Init(qr)
+	qr.load()
+	// the merge stops once the query is canceled, the blocks are released by Release
+	if qr.canceled() {
+		return nil
+	}
+	if qr.topK != nil {
+		return qr.topK.pull()
 	}
 	if len(qr.data) == 0 {
 		return nil
@@ -230,19 +313,174 @@ func (qr *queryResult) Pull() *pbv1.StreamResult {
 	if len(qr.data) == 1 {
 		r := &pbv1.StreamResult{}
 		bc := qr.data[0]
-		bc.copyAllTo(r, qr.orderByTimestampDesc())
+		bc.copyAllTo(r)
 		qr.data = qr.data[:0]
 		return r
 	}
 	return qr.merge()
 }
 
+// load decodes the blocks on the first pull, and drops those out of the time range or corrupted.
+func (qr *queryResult) load() {
+	if qr.loaded || len(qr.data) == 0 {
+		return
+	}
+	start := time.Now()
+	defer func() { qr.span.Observe(time.Since(start)) }()
+	if qr.topK != nil {
+		qr.loadTopK()
+		qr.loaded = true
+		return
+	}
+	// TODO:// Parallel load
+	tmpBlock := generateBlock()
+	defer releaseBlock(tmpBlock)
+	for i := 0; i < len(qr.data); i++ {
+		ok, err := qr.data[i].loadData(qr.queryContext(), tmpBlock, qr.workers)
+		if qr.canceled() {
+			// the query is canceled, the blocks left are never loaded
+			qr.drop(i)
+			break
+		}
+		if err != nil {
+			qr.skip(qr.data[i], err)
+		}
+		if !ok {
+			qr.span.Add(executor.CounterBlocksSkipped, 1)
+			releaseBlockCursor(qr.data[i])
+			qr.data = append(qr.data[:i], qr.data[i+1:]...)
+			i--
+			continue
+		}
+		qr.trace(qr.data[i])
+		if !qr.accountBlock(qr.data[i]) {
+			// the query is canceled, the blocks left are never loaded
+			qr.drop(i)
+			break
+		}
+		qr.data[i].initWindow(qr.orderByTimestampDesc())
+		if qr.after != nil {
+			if qr.data[i].skipBefore(qr.after, &qr.afterLeft); qr.data[i].exhausted() {
+				releaseBlockCursor(qr.data[i])
+				qr.data = append(qr.data[:i], qr.data[i+1:]...)
+				i--
+				continue
+			}
+		}
+		qr.fillEntityTags(qr.data[i])
+	}
+	qr.loaded = true
+	heap.Init(qr)
+}
+
+// queryContext returns the context of the query, a result built without one is never canceled.
+func (qr *queryResult) queryContext() context.Context {
+	if qr.ctx == nil {
+		return context.Background()
+	}
+	return qr.ctx
+}
+
+func (qr *queryResult) canceled() bool {
+	return qr.ctx != nil && qr.ctx.Err() != nil
+}
+
+// drop releases the cursors of the blocks from i on.
+func (qr *queryResult) drop(i int) {
+	for j := i; j < len(qr.data); j++ {
+		releaseBlockCursor(qr.data[j])
+		qr.data[j] = nil
+	}
+	qr.data = qr.data[:i]
+}
+
+// accountBlock accounts the decoded rows of the block to the memory budget of the query,
+// it returns false if the query exceeds the budget.
+func (qr *queryResult) accountBlock(bc *blockCursor) bool {
+	size := bc.memSize()
+	if err := qr.account.Grow(size); err != nil {
+		return false
+	}
+	qr.accounted += size
+	return true
+}
+
+// trace adds the loaded block to the span if the query is traced.
+func (qr *queryResult) trace(bc *blockCursor) {
+	if qr.span == nil {
+		return
+	}
+	qr.span.Add(executor.CounterBlocksScanned, 1)
+	qr.span.Add(executor.CounterRows, int64(len(bc.timestamps)))
+	// the size is left out if the metadata can't be read again, the block is loaded anyway
+	if size, err := bc.readSize(); err == nil {
+		qr.span.Add(executor.CounterBytesRead, size)
+	}
+}
+
+// fillEntityTags fills the projected entity tags of the loaded block by the values of its series, which aren't stored in the blocks.
+func (qr *queryResult) fillEntityTags(bc *blockCursor) {
+	if qr.schema.GetEntity() == nil || len(qr.schema.GetEntity().GetTagNames()) == 0 {
+		return
+	}
+	sidIndex := qr.sidToIndex[bc.bm.seriesID]
+	series := qr.seriesList[sidIndex]
+	entityMap := make(map[string]int)
+	tagFamilyMap := make(map[string]int)
+	for idx, entity := range qr.schema.GetEntity().GetTagNames() {
+		entityMap[entity] = idx + 1
+	}
+	for idx, tagFamily := range bc.tagFamilies {
+		tagFamilyMap[tagFamily.name] = idx + 1
+	}
+	for _, tagFamilyProj := range bc.tagProjection {
+		for j, tagProj := range tagFamilyProj.Names {
+			offset := qr.tagNameIndex[tagProj]
+			tagFamilySpec := qr.schema.GetTagFamilies()[offset.FamilyOffset]
+			tagSpec := tagFamilySpec.GetTags()[offset.TagOffset]
+			if tagSpec.IndexedOnly {
+				continue
+			}
+			entityPos := entityMap[tagProj]
+			tagFamilyPos := tagFamilyMap[tagFamilyProj.Family]
+			if entityPos == 0 {
+				continue
+			}
+			if tagFamilyPos == 0 {
+				bc.tagFamilies[tagFamilyPos-1] = tagFamily{
+					name: tagFamilyProj.Family,
+					tags: make([]tag, 0),
+				}
+			}
+			valueType := pbv1.MustTagValueToValueType(series.EntityValues[entityPos-1])
+			bc.tagFamilies[tagFamilyPos-1].tags[j] = tag{
+				name:      tagProj,
+				values:    mustEncodeTagValue(tagProj, tagSpec.GetType(), series.EntityValues[entityPos-1], len(bc.timestamps)),
+				valueType: valueType,
+			}
+		}
+	}
+}
+
+// skip records the block which fails to decode, the query goes on without it.
+func (qr *queryResult) skip(bc *blockCursor, err error) {
+	be := newBlockError(bc.p, &bc.bm, err)
+	skippedBlocksCounter.Inc(1, "query")
+	logger.GetLogger("stream").Warn().Err(be).Msg("skip the corrupted block")
+	qr.warnings.Add(be.warning())
+}
+
 func (qr *queryResult) Release() {
 	for i, v := range qr.data {
 		releaseBlockCursor(v)
 		qr.data[i] = nil
 	}
 	qr.data = qr.data[:0]
+	qr.account.Shrink(qr.accounted)
+	qr.accounted = 0
+	if qr.topK != nil {
+		qr.topK.rows = nil
+	}
 	for i := range qr.snapshots {
 		qr.snapshots[i].decRef()
 	}
@@ -257,6 +495,10 @@ func (qr queryResult) Less(i, j int) bool {
 	leftTS := qr.data[i].timestamps[qr.data[i].idx]
 	rightTS := qr.data[j].timestamps[qr.data[j].idx]
 	if qr.orderByTS {
+		if leftTS == rightTS {
+			// sort seriesID in ascending order if timestamps are equal, which the continuation tokens rely on
+			return qr.data[i].bm.seriesID < qr.data[j].bm.seriesID
+		}
 		if qr.ascTS {
 			return leftTS < rightTS
 		}
@@ -288,10 +530,6 @@ func (qr *queryResult) orderByTimestampDesc() bool {
 }
 
 func (qr *queryResult) merge() *pbv1.StreamResult {
-	step := 1
-	if qr.orderByTimestampDesc() {
-		step = -1
-	}
 	result := &pbv1.StreamResult{}
 	var lastSid common.SeriesID
 
@@ -303,20 +541,10 @@ func (qr *queryResult) merge() *pbv1.StreamResult {
 		lastSid = topBC.bm.seriesID
 
 		topBC.copyTo(result)
-		topBC.idx += step
-
-		if qr.orderByTimestampDesc() {
-			if topBC.idx < 0 {
-				heap.Pop(qr)
-			} else {
-				heap.Fix(qr, 0)
-			}
+		if topBC.exhausted() {
+			heap.Pop(qr)
 		} else {
-			if topBC.idx >= len(topBC.timestamps) {
-				heap.Pop(qr)
-			} else {
-				heap.Fix(qr, 0)
-			}
+			heap.Fix(qr, 0)
 		}
 	}
 
@@ -364,7 +592,7 @@ func (s *stream) Filter(ctx context.Context, sfo pbv1.StreamFilterOptions) (sfr
 		return nil, errors.New("invalid query options: tagProjection is required")
 	}
 	tsdb := s.databaseSupplier.SupplyTSDB().(storage.TSDB[*tsTable, option])
-	tabWrappers := tsdb.SelectTSTables(*sfo.TimeRange)
+	tabWrappers := tsdb.SelectTSTables(*sfo.TimeRange, sfo.ShardIDs...)
 	sort.Slice(tabWrappers, func(i, j int) bool {
 		return tabWrappers[i].GetTimeRange().Start.Before(tabWrappers[j].GetTimeRange().Start)
 	})
@@ -398,7 +626,13 @@ func (s *stream) Filter(ctx context.Context, sfo pbv1.StreamFilterOptions) (sfr
 			erl = erl[:sfo.MaxElementSize-len(ces.timestamp)]
 		}
 		for _, er := range erl {
+			if err = ctx.Err(); err != nil {
+				return nil, err
+			}
 			e, count, err := tw.Table().getElement(er.seriesID, common.ItemID(er.timestamp), sfo.TagProjection)
+			if errors.Is(err, errElementPurged) {
+				continue
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -431,7 +665,7 @@ func (s *stream) Sort(ctx context.Context, sso pbv1.StreamSortOptions) (ssr pbv1
 		return nil, errors.New("invalid query options: tagProjection is required")
 	}
 	tsdb := s.databaseSupplier.SupplyTSDB().(storage.TSDB[*tsTable, option])
-	tabWrappers := tsdb.SelectTSTables(*sso.TimeRange)
+	tabWrappers := tsdb.SelectTSTables(*sso.TimeRange, sso.ShardIDs...)
 	defer func() {
 		for i := range tabWrappers {
 			tabWrappers[i].DecRef()
@@ -472,8 +706,14 @@ func (s *stream) Sort(ctx context.Context, sso pbv1.StreamSortOptions) (ssr pbv1
 
 	ces := newColumnElements()
 	for it.Next() {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
 		nextItem := it.Val()
 		e, count, err := nextItem.Element()
+		if errors.Is(err, errElementPurged) {
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -507,8 +747,12 @@ func (s *stream) Query(ctx context.Context, sqo pbv1.StreamQueryOptions) (pbv1.S
 	if len(sqo.TagProjection) == 0 {
 		return nil, errors.New("invalid query options: tagProjection is required")
 	}
+	// the blocks selected here are loaded once the result is pulled, which the span observes as well
+	span := executor.FromSpan(ctx).Child("block scan")
+	start := time.Now()
+	defer func() { span.Observe(time.Since(start)) }()
 	tsdb := s.databaseSupplier.SupplyTSDB().(storage.TSDB[*tsTable, option])
-	tabWrappers := tsdb.SelectTSTables(*sqo.TimeRange)
+	tabWrappers := tsdb.SelectTSTables(*sqo.TimeRange, sqo.ShardIDs...)
 	defer func() {
 		for i := range tabWrappers {
 			tabWrappers[i].DecRef()
@@ -519,7 +763,7 @@ func (s *stream) Query(ctx context.Context, sqo pbv1.StreamQueryOptions) (pbv1.S
 		return nil, err
 	}
 
-	var result queryResult
+	result := queryResult{ctx: ctx, span: span}
 	if len(sl) < 1 {
 		return &result, nil
 	}
@@ -527,42 +771,23 @@ func (s *stream) Query(ctx context.Context, sqo pbv1.StreamQueryOptions) (pbv1.S
 	for i := range sl {
 		sids = append(sids, sl[i].ID)
 	}
-	var parts []*part
 	qo := queryOptions{
 		StreamQueryOptions: sqo,
+		tagPredicates:      newTagPredicates(sqo.TagPredicates),
+		tagTypes:           schemaTagTypes(s.schema),
 		minTimestamp:       sqo.TimeRange.Start.UnixNano(),
 		maxTimestamp:       sqo.TimeRange.End.UnixNano(),
+		schemaRevision:     s.schema.GetMetadata().GetModRevision(),
 	}
-	var n int
+	tables := make([]*tsTable, 0, len(tabWrappers))
 	for i := range tabWrappers {
-		s := tabWrappers[i].Table().currentSnapshot()
-		if s == nil {
-			continue
-		}
-		parts, n = s.getParts(parts, qo.minTimestamp, qo.maxTimestamp)
-		if n < 1 {
-			s.decRef()
-			continue
-		}
-		result.snapshots = append(result.snapshots, s)
+		tables = append(tables, tabWrappers[i].Table())
 	}
-	// TODO: cache tstIter
-	var tstIter tstIter
 	originalSids := make([]common.SeriesID, len(sids))
 	copy(originalSids, sids)
 	sort.Slice(sids, func(i, j int) bool { return sids[i] < sids[j] })
-	tstIter.init(parts, sids, qo.minTimestamp, qo.maxTimestamp)
-	if tstIter.Error() != nil {
-		return nil, fmt.Errorf("cannot init tstIter: %w", tstIter.Error())
-	}
-	for tstIter.nextBlock() {
-		bc := generateBlockCursor()
-		p := tstIter.piHeap[0]
-		bc.init(p.p, p.curBlock, qo)
-		result.data = append(result.data, bc)
-	}
-	if tstIter.Error() != nil {
-		return nil, fmt.Errorf("cannot iterate tstIter: %w", tstIter.Error())
+	if err = result.selectBlocks(tables, sids, qo); err != nil {
+		return nil, err
 	}
 
 	entityMap, _, _, sidToIndex := s.genIndex(sqo.TagProjection, sl)
@@ -571,6 +796,9 @@ func (s *stream) Query(ctx context.Context, sqo pbv1.StreamQueryOptions) (pbv1.S
 	result.tagNameIndex = make(map[string]partition.TagLocator)
 	result.schema = s.schema
 	result.seriesList = sl
+	result.workers = newWorkerBudget(int(queryWorkers.Load()))
+	result.warnings = executor.FromWarnings(ctx)
+	result.account = executor.FromMemoryAccount(ctx)
 	for i, si := range originalSids {
 		result.sidToIndex[si] = i
 	}
@@ -582,6 +810,12 @@ func (s *stream) Query(ctx context.Context, sqo pbv1.StreamQueryOptions) (pbv1.S
 			}
 		}
 	}
+	if sqo.Order == nil || sqo.Order.Index == nil {
+		result.after = sqo.After
+		if sqo.After != nil {
+			result.afterLeft = sqo.After.Offset
+		}
+	}
 	if sqo.Order == nil {
 		result.orderByTS = true
 		result.ascTS = true
@@ -594,10 +828,71 @@ func (s *stream) Query(ctx context.Context, sqo pbv1.StreamQueryOptions) (pbv1.S
 		}
 		return &result, nil
 	}
-
+	result.topK = newTopK(sqo.Order, sqo.TagProjection, sqo.MaxElementSize)
 	return &result, nil
 }
 
+// selectBlocks adds the cursors of the blocks of the sorted series in the tables to the result.
+// The elements of a hot series covered by its recent window are taken from the window instead of the blocks.
+func (qr *queryResult) selectBlocks(tables []*tsTable, sids []common.SeriesID, qo queryOptions) error {
+	recentWindows.touch(sids)
+	var parts []*part
+	var covered map[*part]map[common.SeriesID]int64
+	var n int
+	for _, tst := range tables {
+		s := tst.currentSnapshot()
+		if s == nil {
+			continue
+		}
+		start := len(parts)
+		parts, n = s.getParts(parts, qo.minTimestamp, qo.maxTimestamp)
+		bcs, cover := tst.windows.covers(tst, sids, qo)
+		qr.data = append(qr.data, bcs...)
+		if cover != nil {
+			if covered == nil {
+				covered = make(map[*part]map[common.SeriesID]int64)
+			}
+			for _, p := range parts[start:] {
+				covered[p] = cover
+			}
+		}
+		if n < 1 {
+			s.decRef()
+			continue
+		}
+		qr.snapshots = append(qr.snapshots, s)
+	}
+	// TODO: cache tstIter
+	var tstIter tstIter
+	tstIter.init(parts, sids, qo.minTimestamp, qo.maxTimestamp)
+	if tstIter.Error() != nil {
+		return fmt.Errorf("cannot init tstIter: %w", tstIter.Error())
+	}
+	defer tstIter.trace(qr.span)
+	for tstIter.nextBlock() {
+		p := tstIter.piHeap[0]
+		// the series written again after a purge are found, while their blocks before the purge are skipped
+		if p.p.tombstones.covers(p.curBlock.seriesID, p.p.partMetadata.ID) {
+			continue
+		}
+		opts := qo
+		if coverFrom, ok := covered[p.p][p.curBlock.seriesID]; ok {
+			if p.curBlock.timestamps.min >= coverFrom {
+				windowSkippedBlocksCount.Inc(1)
+				continue
+			}
+			opts.maxTimestamp = min(opts.maxTimestamp, coverFrom-1)
+		}
+		bc := generateBlockCursor()
+		bc.init(p.p, p.curBlock, opts)
+		qr.data = append(qr.data, bc)
+	}
+	if tstIter.Error() != nil {
+		return fmt.Errorf("cannot iterate tstIter: %w", tstIter.Error())
+	}
+	return nil
+}
+
 // newItemIter returns a ItemIterator which mergers several tsdb.Iterator by input sorting order.
 func newItemIter(iters []*searcherIterator, s modelv1.Sort) itersort.Iterator[item] {
 	var ii []itersort.Iterator[item]
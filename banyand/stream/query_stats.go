@@ -0,0 +1,136 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+// maxFilteredStatsBlocks bounds the blocks whose bloom filters are read by Stats, the rest are told by the min and max of the tags only.
+const maxFilteredStatsBlocks = 1024
+
+// Stats returns the statistics of the blocks of the series in the time range, and of those which might match each predicate.
+// Unlike Query, it reads the metadata of the blocks but not their rows.
+func (s *stream) Stats(ctx context.Context, sqo pbv1.StreamQueryOptions) (pbv1.StreamScanStats, error) {
+	stats := pbv1.StreamScanStats{Predicates: make([]pbv1.BlockStats, len(sqo.TagPredicates))}
+	if sqo.TimeRange == nil || sqo.Entity == nil {
+		return stats, errors.New("invalid query options: timeRange and series are required")
+	}
+	tsdb := s.databaseSupplier.SupplyTSDB().(storage.TSDB[*tsTable, option])
+	tabWrappers := tsdb.SelectTSTables(*sqo.TimeRange, sqo.ShardIDs...)
+	defer func() {
+		for i := range tabWrappers {
+			tabWrappers[i].DecRef()
+		}
+	}()
+	sl, err := tsdb.Lookup(ctx, &pbv1.Series{Subject: sqo.Name, EntityValues: sqo.Entity})
+	if err != nil || len(sl) < 1 {
+		return stats, err
+	}
+	sids := make([]common.SeriesID, 0, len(sl))
+	for i := range sl {
+		sids = append(sids, sl[i].ID)
+	}
+	sort.Slice(sids, func(i, j int) bool { return sids[i] < sids[j] })
+	qo := queryOptions{
+		StreamQueryOptions: sqo,
+		minTimestamp:       sqo.TimeRange.Start.UnixNano(),
+		maxTimestamp:       sqo.TimeRange.End.UnixNano(),
+	}
+	var parts []*part
+	var snapshots []*snapshot
+	defer func() {
+		for _, ss := range snapshots {
+			ss.decRef()
+		}
+	}()
+	for i := range tabWrappers {
+		ss := tabWrappers[i].Table().currentSnapshot()
+		if ss == nil {
+			continue
+		}
+		snapshots = append(snapshots, ss)
+		parts, _ = ss.getParts(parts, qo.minTimestamp, qo.maxTimestamp)
+	}
+	return scanStats(parts, sids, qo)
+}
+
+// scanStats returns the statistics of the blocks of the sorted series in the parts.
+func scanStats(parts []*part, sids []common.SeriesID, qo queryOptions) (pbv1.StreamScanStats, error) {
+	stats := pbv1.StreamScanStats{Predicates: make([]pbv1.BlockStats, len(qo.TagPredicates)), Parts: len(parts)}
+	// each predicate is told apart, a predicate unsupported by the blocks is met by all of them
+	predicates := make([][]tagPredicate, len(qo.TagPredicates))
+	for i := range qo.TagPredicates {
+		predicates[i] = newTagPredicates(qo.TagPredicates[i : i+1])
+	}
+	var tstIter tstIter
+	tstIter.init(parts, sids, qo.minTimestamp, qo.maxTimestamp)
+	if tstIter.Error() != nil {
+		return stats, fmt.Errorf("cannot init tstIter: %w", tstIter.Error())
+	}
+	bc := generateBlockCursor()
+	defer releaseBlockCursor(bc)
+	var filtered int
+	for tstIter.nextBlock() {
+		p := tstIter.piHeap[0]
+		if p.p.tombstones.covers(p.curBlock.seriesID, p.p.partMetadata.ID) {
+			continue
+		}
+		bc.init(p.p, p.curBlock, qo)
+		block := pbv1.BlockStats{Blocks: 1, Rows: p.curBlock.count}
+		stats.Total.Add(block)
+		matched := true
+		for i := range predicates {
+			if len(predicates[i]) == 0 {
+				stats.Predicates[i].Add(block)
+				continue
+			}
+			might := predicates[i][0].mightMatchStats(p.curBlock.tagStats)
+			if might && filtered < maxFilteredStatsBlocks {
+				skip, errSkip := bc.skipBy(predicates[i])
+				if errSkip != nil {
+					return stats, errSkip
+				}
+				might = skip == blockSkipNone
+			}
+			if might {
+				stats.Predicates[i].Add(block)
+			} else {
+				matched = false
+			}
+		}
+		if len(predicates) > 0 {
+			filtered++
+		}
+		if matched {
+			stats.Matched.Add(block)
+		}
+	}
+	if tstIter.Error() != nil {
+		return stats, fmt.Errorf("cannot iterate tstIter: %w", tstIter.Error())
+	}
+	return stats, nil
+}
@@ -0,0 +1,83 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func Test_scanStats(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	tst, err := newTSTable(fs.NewLocalFileSystem(), tmpPath, common.Position{},
+		logger.GetLogger("test"), timestamp.TimeRange{}, option{flushTimeout: time.Hour, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer tst.Close()
+	// every batch is a block, and the block of the last one is written without the bloom filters
+	const batches, count = 4, 10
+	for batch := 0; batch < batches; batch++ {
+		require.NoError(t, tst.addElements(generateTraceEs(batch, count, batch < batches-1)))
+	}
+	s := tst.currentSnapshot()
+	require.NotNil(t, s)
+	defer s.decRef()
+	parts, _ := s.getParts(nil, 1, batches*count)
+
+	qo := queryOptions{minTimestamp: 1, maxTimestamp: batches * count}
+	qo.TagPredicates = []pbv1.TagPredicate{
+		// the min and max of the codes skip the first two blocks
+		{Family: "trace", Name: "code", RangeOp: modelv1.Condition_BINARY_OP_GE, Value: &modelv1.TagValue{
+			Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: 200}},
+		}},
+		// the bloom filters skip the blocks of the first and the third batches
+		{Family: "trace", Name: "tags", Value: &modelv1.TagValue{
+			Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: "batch-1"}},
+		}},
+		// the blocks evaluate no equality to the binary data, which every block might match
+		{Family: "trace", Name: "code", Equal: true, Value: &modelv1.TagValue{
+			Value: &modelv1.TagValue_BinaryData{BinaryData: []byte("code")},
+		}},
+	}
+	stats, err := scanStats(parts, []common.SeriesID{1}, qo)
+	require.NoError(t, err)
+	assert.Equal(t, len(parts), stats.Parts)
+	assert.Equal(t, pbv1.BlockStats{Blocks: batches, Rows: batches * count}, stats.Total)
+	assert.Equal(t, []pbv1.BlockStats{
+		{Blocks: 2, Rows: 2 * count},
+		{Blocks: 2, Rows: 2 * count},
+		{Blocks: batches, Rows: batches * count},
+	}, stats.Predicates)
+	assert.Equal(t, pbv1.BlockStats{Blocks: 1, Rows: count}, stats.Matched)
+
+	stats, err = scanStats(parts, []common.SeriesID{2}, qo)
+	require.NoError(t, err)
+	assert.Equal(t, pbv1.BlockStats{}, stats.Total)
+	assert.Len(t, stats.Predicates, len(qo.TagPredicates))
+}
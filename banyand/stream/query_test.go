@@ -18,9 +18,11 @@
 package stream
 
 import (
+	"container/heap"
 	"context"
 	"errors"
 	"sort"
+	"strconv"
 	"testing"
 	"time"
 
@@ -30,6 +32,8 @@ import (
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
@@ -405,7 +409,7 @@ func TestQueryResult(t *testing.T) {
 				introducerWatcher := make(watcher.Channel, 1)
 				go tst.introducerLoop(flushCh, mergeCh, introducerWatcher, 1)
 				for _, es := range tt.esList {
-					tst.mustAddElements(es)
+					require.NoError(t, tst.addElements(es))
 					time.Sleep(100 * time.Millisecond)
 				}
 				verify(t, tst)
@@ -420,7 +424,7 @@ func TestQueryResult(t *testing.T) {
 					logger.GetLogger("test"), timestamp.TimeRange{}, option{flushTimeout: defaultFlushTimeout, mergePolicy: newDefaultMergePolicyForTesting()})
 				require.NoError(t, err)
 				for _, es := range tt.esList {
-					tst.mustAddElements(es)
+					require.NoError(t, tst.addElements(es))
 					time.Sleep(100 * time.Millisecond)
 				}
 				// wait until the introducer is done
@@ -451,3 +455,109 @@ func TestQueryResult(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryResultMerge(t *testing.T) {
+	newBlockCursor := func(timestamps ...int64) *blockCursor {
+		bc := &blockCursor{bm: blockMetadata{seriesID: 1}}
+		for _, ts := range timestamps {
+			bc.timestamps = append(bc.timestamps, ts)
+			bc.elementIDs = append(bc.elementIDs, strconv.FormatInt(ts, 10))
+		}
+		return bc
+	}
+	for _, asc := range []bool{true, false} {
+		t.Run(strconv.FormatBool(asc), func(t *testing.T) {
+			qr := &queryResult{
+				data:      []*blockCursor{newBlockCursor(1, 3, 5), newBlockCursor(2), newBlockCursor(4, 6)},
+				orderByTS: true,
+				ascTS:     asc,
+			}
+			for _, bc := range qr.data {
+				bc.initWindow(qr.orderByTimestampDesc())
+			}
+			heap.Init(qr)
+			want := []int64{1, 2, 3, 4, 5, 6}
+			if !asc {
+				want = []int64{6, 5, 4, 3, 2, 1}
+			}
+			require.Equal(t, want, qr.merge().Timestamps)
+			require.Zero(t, qr.Len())
+		})
+	}
+}
+
+// cancelingContext cancels itself once its error is checked the given times.
+type cancelingContext struct {
+	context.Context
+	cancel context.CancelFunc
+	checks int
+}
+
+func (c *cancelingContext) Err() error {
+	if c.checks--; c.checks == 0 {
+		c.cancel()
+	}
+	return c.Context.Err()
+}
+
+func TestQueryResultLoadCanceled(t *testing.T) {
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.mustInitFromElements(esTS1)
+	p := openMemPart(mp)
+	defer p.close()
+	opts := queryOptions{
+		minTimestamp: 1,
+		maxTimestamp: 1,
+	}
+	opts.TagProjection = tagProjections[1]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the query is canceled while loading the second block: the first block checks the error
+	// before and after reading, the second one before reading
+	msg := bus.NewMessage(1, nil).WithContext(&cancelingContext{Context: ctx, cancel: cancel, checks: 3})
+	qr := &queryResult{ctx: msg.Context(), orderByTS: true, ascTS: true}
+	pi := partIter{}
+	pi.init(p, []common.SeriesID{1, 2, 3}, opts.minTimestamp, opts.maxTimestamp)
+	for pi.nextBlock() {
+		bc := generateBlockCursor()
+		bc.init(p, pi.curBlock, opts)
+		qr.data = append(qr.data, bc)
+	}
+	require.Len(t, qr.data, 3)
+	defer qr.Release()
+
+	require.Nil(t, qr.Pull())
+	require.ErrorIs(t, context.Cause(msg.Context()), context.Canceled)
+	// the blocks after the cancellation are never read
+	require.Len(t, qr.data, 1)
+	require.NotEmpty(t, qr.data[0].timestamps)
+}
+
+func TestMustDecodeTagValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    [][]byte
+		valueType pbv1.ValueType
+	}{
+		{name: "int64", valueType: pbv1.ValueTypeInt64, values: [][]byte{convert.Int64ToBytes(1), convert.Int64ToBytes(-2)}},
+		{name: "str", valueType: pbv1.ValueTypeStr, values: [][]byte{[]byte("a"), nil, {}, []byte("bc")}},
+		{name: "binary", valueType: pbv1.ValueTypeBinaryData, values: [][]byte{longText, nil, []byte("b")}},
+		{name: "str array", valueType: pbv1.ValueTypeStrArr, values: [][]byte{marshalStrArr([][]byte{[]byte("a"), []byte("b")}), nil}},
+		{name: "int64 array", valueType: pbv1.ValueTypeInt64Arr, values: [][]byte{marshalIntArr([][]byte{convert.Int64ToBytes(1)}), nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head := []*modelv1.TagValue{pbv1.NullTagValue}
+			want := append([]*modelv1.TagValue{}, head...)
+			for _, v := range tt.values {
+				want = append(want, mustDecodeTagValue(tt.valueType, v))
+			}
+			got := mustDecodeTagValues(head, tt.valueType, tt.values)
+			if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("Unexpected tag values (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
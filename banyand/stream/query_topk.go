@@ -0,0 +1,258 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"bytes"
+	"cmp"
+	"container/heap"
+	"slices"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+var topKSkippedBlocksCount = observability.NewMeterProvider(observability.RootScope.SubScope("stream").SubScope("top_k")).
+	Counter("skipped_blocks_total")
+
+// topK keeps the rows of the top k values of a tag, which a query is ordered by instead of timestamps.
+// Once k rows are kept, the blocks whose statistics show none of their values beat the k-th one are skipped
+// without being read. The values are compared as they're encoded, and the nulls come last in both orders.
+type topK struct {
+	// refs counts the rows kept in each cursor, a cursor is released once none of its rows are kept.
+	refs map[*blockCursor]int
+	// rows is a heap whose root is the k-th row while loading, then they're sorted to be returned in order.
+	rows      []topKRow
+	family    string
+	name      string
+	familyIdx int
+	tagIdx    int
+	k         int
+	pos       int
+	desc      bool
+}
+
+type topKRow struct {
+	bc    *blockCursor
+	value []byte
+	idx   int
+}
+
+// newTopK returns the top k of the tag of the index rule the query is ordered by.
+// It's nil if the rule doesn't have a single tag, the tag isn't projected, or k isn't positive.
+func newTopK(order *pbv1.OrderBy, tagProjection []pbv1.TagProjection, k int) *topK {
+	if order == nil || len(order.Index.GetTags()) != 1 || k <= 0 {
+		return nil
+	}
+	name := order.Index.GetTags()[0]
+	for i, tp := range tagProjection {
+		for j, n := range tp.Names {
+			if n != name {
+				continue
+			}
+			return &topK{
+				refs:      make(map[*blockCursor]int),
+				family:    tp.Family,
+				name:      name,
+				familyIdx: i,
+				tagIdx:    j,
+				k:         k,
+				desc:      order.Sort == modelv1.Sort_SORT_DESC,
+			}
+		}
+	}
+	return nil
+}
+
+// worse reports whether the value a comes after b in the order.
+func (t *topK) worse(a, b []byte) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == 0 && len(b) > 0
+	}
+	if t.desc {
+		return bytes.Compare(a, b) < 0
+	}
+	return bytes.Compare(a, b) > 0
+}
+
+func (t *topK) Len() int {
+	return len(t.rows)
+}
+
+func (t *topK) Less(i, j int) bool {
+	return t.worse(t.rows[i].value, t.rows[j].value)
+}
+
+func (t *topK) Swap(i, j int) {
+	t.rows[i], t.rows[j] = t.rows[j], t.rows[i]
+}
+
+func (t *topK) Push(x any) {
+	t.rows = append(t.rows, x.(topKRow))
+}
+
+func (t *topK) Pop() any {
+	old := t.rows
+	n := len(old)
+	x := old[n-1]
+	t.rows = old[0 : n-1]
+	return x
+}
+
+// mightBeat reports whether some values of the tag in the block might beat the k-th row, by the statistics of the block.
+// It's true if fewer than k rows are kept, or the tag has no statistics.
+func (t *topK) mightBeat(bm *blockMetadata) bool {
+	if len(t.rows) < t.k || len(t.rows[0].value) == 0 {
+		return true
+	}
+	for i := range bm.tagStats {
+		ts := &bm.tagStats[i]
+		if ts.family != t.family || ts.name != t.name {
+			continue
+		}
+		v := t.rows[0].value
+		// the prefixes of the strings equal to the bounds might be greater or less than the bounds
+		strict := ts.valueType == pbv1.ValueTypeInt64
+		if !strict && len(v) > maxTagStatsPrefixLen {
+			v = v[:maxTagStatsPrefixLen]
+		}
+		if t.desc {
+			c := bytes.Compare(ts.max, v)
+			return c > 0 || c == 0 && !strict
+		}
+		c := bytes.Compare(ts.min, v)
+		return c < 0 || c == 0 && !strict
+	}
+	return true
+}
+
+// offer keeps the row at idx of the cursor if it's among the top k rows. It returns the cursor
+// none of whose rows are kept any longer because of the row evicted, which the caller releases.
+func (t *topK) offer(bc *blockCursor, idx int) *blockCursor {
+	var value []byte
+	if t.familyIdx < len(bc.tagFamilies) && t.tagIdx < len(bc.tagFamilies[t.familyIdx].tags) {
+		if values := bc.tagFamilies[t.familyIdx].tags[t.tagIdx].values; idx < len(values) {
+			value = values[idx]
+		}
+	}
+	row := topKRow{bc: bc, value: value, idx: idx}
+	if len(t.rows) < t.k {
+		heap.Push(t, row)
+		t.refs[bc]++
+		return nil
+	}
+	if !t.worse(t.rows[0].value, value) {
+		return nil
+	}
+	evicted := t.rows[0].bc
+	t.rows[0] = row
+	heap.Fix(t, 0)
+	t.refs[bc]++
+	if t.refs[evicted]--; t.refs[evicted] > 0 {
+		return nil
+	}
+	delete(t.refs, evicted)
+	return evicted
+}
+
+// sort orders the rows kept, the ties of which are ordered by their timestamps in the same direction.
+func (t *topK) sort() {
+	slices.SortStableFunc(t.rows, func(a, b topKRow) int {
+		if t.worse(b.value, a.value) {
+			return -1
+		}
+		if t.worse(a.value, b.value) {
+			return 1
+		}
+		c := cmp.Compare(a.bc.timestamps[a.idx], b.bc.timestamps[b.idx])
+		if t.desc {
+			return -c
+		}
+		return c
+	})
+}
+
+func (t *topK) next() (pbv1.StreamElement, bool) {
+	if t.pos >= len(t.rows) {
+		return pbv1.StreamElement{}, false
+	}
+	row := t.rows[t.pos]
+	t.pos++
+	return row.bc.element(row.idx), true
+}
+
+// pull copies all the rows left into a result. Its SID is the series of the last row, since the rows are from several series.
+func (t *topK) pull() *pbv1.StreamResult {
+	if t.pos >= len(t.rows) {
+		return nil
+	}
+	r := &pbv1.StreamResult{}
+	for ; t.pos < len(t.rows); t.pos++ {
+		t.rows[t.pos].bc.copyRowTo(r, t.rows[t.pos].idx)
+	}
+	return r
+}
+
+// loadTopK decodes the blocks one by one, and keeps the rows of the top values of the tag.
+// A block is released as soon as none of its rows are kept, and the rest are released along with the result.
+func (qr *queryResult) loadTopK() {
+	tmpBlock := generateBlock()
+	defer releaseBlock(tmpBlock)
+	t := qr.topK
+	cursors := qr.data
+	qr.data = nil
+	for i, bc := range cursors {
+		cursors[i] = nil
+		if qr.canceled() {
+			// the query is canceled, the blocks left are never loaded
+			releaseBlockCursor(bc)
+			continue
+		}
+		if !t.mightBeat(&bc.bm) {
+			topKSkippedBlocksCount.Inc(1)
+			releaseBlockCursor(bc)
+			continue
+		}
+		ok, err := bc.loadData(qr.queryContext(), tmpBlock, qr.workers)
+		if qr.canceled() {
+			releaseBlockCursor(bc)
+			continue
+		}
+		if err != nil {
+			qr.skip(bc, err)
+		}
+		if !ok {
+			releaseBlockCursor(bc)
+			continue
+		}
+		qr.fillEntityTags(bc)
+		for idx := range bc.timestamps {
+			if evicted := t.offer(bc, idx); evicted != nil {
+				releaseBlockCursor(evicted)
+			}
+		}
+		if t.refs[bc] == 0 {
+			releaseBlockCursor(bc)
+		}
+	}
+	for bc := range t.refs {
+		qr.data = append(qr.data, bc)
+	}
+	t.sort()
+}
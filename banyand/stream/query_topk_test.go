@@ -0,0 +1,175 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+func newTopKCursor(timestamps []int64, durations ...[]byte) *blockCursor {
+	return &blockCursor{
+		timestamps: timestamps,
+		tagFamilies: []tagFamily{{
+			name: "default",
+			tags: []tag{{name: "duration", valueType: pbv1.ValueTypeInt64, values: durations}},
+		}},
+	}
+}
+
+func Test_topK(t *testing.T) {
+	projection := []pbv1.TagProjection{{Family: "default", Names: []string{"duration"}}}
+	order := func(sort modelv1.Sort) *pbv1.OrderBy {
+		return &pbv1.OrderBy{Index: &databasev1.IndexRule{Tags: []string{"duration"}}, Sort: sort}
+	}
+	require.Nil(t, newTopK(order(modelv1.Sort_SORT_DESC), projection, 0))
+	require.Nil(t, newTopK(order(modelv1.Sort_SORT_DESC), []pbv1.TagProjection{{Family: "default", Names: []string{"trace_id"}}}, 2))
+	tk := newTopK(order(modelv1.Sort_SORT_DESC), []pbv1.TagProjection{{Family: "searchable", Names: []string{"trace_id", "duration"}}}, 2)
+	require.NotNil(t, tk)
+	assert.Equal(t, "searchable", tk.family)
+	assert.Equal(t, 1, tk.tagIdx)
+
+	tests := []struct {
+		name string
+		want []int64
+		sort modelv1.Sort
+	}{
+		{name: "desc", sort: modelv1.Sort_SORT_DESC, want: []int64{50, 30, 20}},
+		{name: "asc", sort: modelv1.Sort_SORT_ASC, want: []int64{-5, 10, 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk := newTopK(order(tt.sort), projection, 3)
+			require.NotNil(t, tk)
+			bc1 := newTopKCursor([]int64{1, 2, 3}, convert.Int64ToBytes(10), convert.Int64ToBytes(50), nil)
+			bc2 := newTopKCursor([]int64{4, 5, 6}, convert.Int64ToBytes(-5), convert.Int64ToBytes(20), convert.Int64ToBytes(30))
+			var evicted []*blockCursor
+			for _, bc := range []*blockCursor{bc1, bc2} {
+				for idx := range bc.timestamps {
+					if e := tk.offer(bc, idx); e != nil {
+						evicted = append(evicted, e)
+					}
+				}
+			}
+			assert.Empty(t, evicted)
+			tk.sort()
+			var got []int64
+			for _, row := range tk.rows {
+				got = append(got, convert.BytesToInt64(row.value))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_topK_evict(t *testing.T) {
+	tk := &topK{refs: make(map[*blockCursor]int), k: 2, desc: true}
+	bc1 := newTopKCursor([]int64{1, 2}, convert.Int64ToBytes(1), convert.Int64ToBytes(2))
+	bc2 := newTopKCursor([]int64{3, 4}, convert.Int64ToBytes(3), convert.Int64ToBytes(4))
+	assert.Nil(t, tk.offer(bc1, 0))
+	assert.Nil(t, tk.offer(bc1, 1))
+	assert.Nil(t, tk.offer(bc2, 0))
+	assert.Same(t, bc1, tk.offer(bc2, 1))
+	assert.Equal(t, map[*blockCursor]int{bc2: 2}, tk.refs)
+	// the tie doesn't replace the k-th row
+	assert.Nil(t, tk.offer(newTopKCursor([]int64{5}, convert.Int64ToBytes(3)), 0))
+}
+
+func Test_topK_mightBeat(t *testing.T) {
+	newStats := func(valueType pbv1.ValueType, lower, upper []byte) *blockMetadata {
+		return &blockMetadata{tagStats: []tagStats{{family: "default", name: "duration", valueType: valueType, min: lower, max: upper}}}
+	}
+	full := func(desc bool, threshold []byte) *topK {
+		return &topK{family: "default", name: "duration", k: 1, desc: desc, rows: []topKRow{{value: threshold}}}
+	}
+	tests := []struct {
+		bm   *blockMetadata
+		tk   *topK
+		name string
+		want bool
+	}{
+		{
+			name: "not full",
+			tk:   &topK{family: "default", name: "duration", k: 1, desc: true},
+			bm:   newStats(pbv1.ValueTypeInt64, convert.Int64ToBytes(1), convert.Int64ToBytes(2)),
+			want: true,
+		},
+		{
+			name: "no stats",
+			tk:   full(true, convert.Int64ToBytes(10)),
+			bm:   &blockMetadata{},
+			want: true,
+		},
+		{
+			name: "desc max below",
+			tk:   full(true, convert.Int64ToBytes(10)),
+			bm:   newStats(pbv1.ValueTypeInt64, convert.Int64ToBytes(1), convert.Int64ToBytes(9)),
+		},
+		{
+			name: "desc max equal",
+			tk:   full(true, convert.Int64ToBytes(10)),
+			bm:   newStats(pbv1.ValueTypeInt64, convert.Int64ToBytes(1), convert.Int64ToBytes(10)),
+		},
+		{
+			name: "desc max above",
+			tk:   full(true, convert.Int64ToBytes(10)),
+			bm:   newStats(pbv1.ValueTypeInt64, convert.Int64ToBytes(1), convert.Int64ToBytes(11)),
+			want: true,
+		},
+		{
+			name: "asc min above",
+			tk:   full(false, convert.Int64ToBytes(10)),
+			bm:   newStats(pbv1.ValueTypeInt64, convert.Int64ToBytes(11), convert.Int64ToBytes(20)),
+		},
+		{
+			name: "asc min below",
+			tk:   full(false, convert.Int64ToBytes(10)),
+			bm:   newStats(pbv1.ValueTypeInt64, convert.Int64ToBytes(9), convert.Int64ToBytes(20)),
+			want: true,
+		},
+		{
+			name: "desc truncated string max",
+			tk:   full(true, []byte("z-longer-than-the-prefix")),
+			bm:   newStats(pbv1.ValueTypeStr, []byte("a"), []byte("z-longer-than-th")),
+			want: true,
+		},
+		{
+			name: "desc string max below",
+			tk:   full(true, []byte("z-longer-than-the-prefix")),
+			bm:   newStats(pbv1.ValueTypeStr, []byte("a"), []byte("y")),
+		},
+		{
+			name: "null threshold",
+			tk:   full(true, nil),
+			bm:   newStats(pbv1.ValueTypeInt64, convert.Int64ToBytes(1), convert.Int64ToBytes(2)),
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.tk.mightBeat(tt.bm))
+		})
+	}
+}
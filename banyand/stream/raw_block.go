@@ -0,0 +1,152 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+)
+
+var rawCopiedBlocksCounter = observability.NewMeterProvider(observability.RootScope.SubScope("stream").SubScope("merge")).
+	Counter("raw_copied_blocks_total")
+
+// rawBlock is a block as it's encoded in a part, which a merge copies to the new part without decoding it.
+type rawBlock struct {
+	timestamps  []byte
+	elementIDs  []byte
+	tagFamilies []rawTagFamily
+	bm          blockMetadata
+}
+
+// rawTagFamily is the metadata of the tags in a tag family along with their values, which are in the order of the tags.
+type rawTagFamily struct {
+	name   string
+	values []byte
+	tfm    tagFamilyMetadata
+}
+
+func (rb *rawBlock) reset() {
+	rb.bm.reset()
+	rb.timestamps = rb.timestamps[:0]
+	rb.elementIDs = rb.elementIDs[:0]
+	for i := range rb.tagFamilies {
+		rb.tagFamilies[i].name = ""
+		rb.tagFamilies[i].values = rb.tagFamilies[i].values[:0]
+		rb.tagFamilies[i].tfm.reset()
+	}
+	rb.tagFamilies = rb.tagFamilies[:0]
+}
+
+func (rb *rawBlock) seqReadFrom(seqReaders *seqReaders, bm *blockMetadata) error {
+	rb.reset()
+	rb.bm.copyFrom(bm)
+	var err error
+	if rb.timestamps, err = seqReadRaw(rb.timestamps, &bm.timestamps.dataBlock, maxTimestampsBlockSize, &seqReaders.timestamps); err != nil {
+		return err
+	}
+	if rb.elementIDs, err = seqReadRaw(rb.elementIDs, &bm.elementIDs.dataBlock, maxElementIDsBlockSize, &seqReaders.elementIDs); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(bm.tagFamilies))
+	for k := range bm.tagFamilies {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	bb := bigValuePool.Acquire(0)
+	defer bigValuePool.Release(bb)
+	for _, name := range keys {
+		if bb.Buf, err = seqReadRaw(bb.Buf[:0], bm.tagFamilies[name], maxTagFamiliesMetadataSize, seqReaders.tagFamilyMetadata[name]); err != nil {
+			return fmt.Errorf("cannot read the metadata of tag family %q: %w", name, err)
+		}
+		if n := len(rb.tagFamilies); n < cap(rb.tagFamilies) {
+			rb.tagFamilies = rb.tagFamilies[:n+1]
+		} else {
+			rb.tagFamilies = append(rb.tagFamilies, rawTagFamily{})
+		}
+		rtf := &rb.tagFamilies[len(rb.tagFamilies)-1]
+		rtf.name = name
+		if err = rtf.tfm.unmarshal(bb.Buf); err != nil {
+			return fmt.Errorf("cannot unmarshal the metadata of tag family %q: %w", name, err)
+		}
+		for i := range rtf.tfm.tagMetadata {
+			if rtf.values, err = seqReadRaw(rtf.values, &rtf.tfm.tagMetadata[i].dataBlock, maxValuesBlockSize, seqReaders.tagFamilies[name]); err != nil {
+				return fmt.Errorf("cannot read the values of tag %q: %w", rtf.tfm.tagMetadata[i].name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// seqReadRaw appends the data of the block read from the reader to dst.
+func seqReadRaw(dst []byte, db *dataBlock, maxSize uint64, reader *seqReader) ([]byte, error) {
+	if db.size > maxSize {
+		return dst, fmt.Errorf("%s: block size cannot exceed %d bytes; got %d bytes", reader.Path(), maxSize, db.size)
+	}
+	if err := reader.align(db.offset); err != nil {
+		return dst, err
+	}
+	n := len(dst)
+	dst = slices.Grow(dst, int(db.size))[:n+int(db.size)]
+	if err := reader.readFull(dst[n:]); err != nil {
+		return dst[:n], err
+	}
+	return dst, nil
+}
+
+// rawCopyable reports whether the current block can be copied to a part whose tag values are compressed by tagCodec as is,
+// which is the case if its part records the same codec and none of its elements is deleted.
+func (br *blockReader) rawCopyable(tagCodec encoding.Codec) bool {
+	pmi := br.pih[0]
+	pm := &pmi.p.partMetadata
+	if pm.FormatVersion < partFormatVersionTagCodec || pm.TagCodec != tagCodec || pmi.tagProjection != nil {
+		return false
+	}
+	bm := &br.block.bm
+	return len(pmi.p.tombstones.deletionsOf(nil, bm.seriesID, pm.ID, bm.timestamps.min, bm.timestamps.max)) == 0
+}
+
+// loadRawBlock reads the current block without decoding it.
+func (br *blockReader) loadRawBlock(rb *rawBlock) error {
+	pmi := br.pih[0]
+	if err := rb.seqReadFrom(&pmi.seqReaders, &pmi.block.bm); err != nil {
+		// the next block can be read even if this one is skipped
+		pmi.seqReaders.resync()
+		return newBlockError(pmi.p, &pmi.block.bm, err)
+	}
+	return nil
+}
+
+func generateRawBlock() *rawBlock {
+	v := rawBlockPool.Get()
+	if v == nil {
+		return &rawBlock{}
+	}
+	return v.(*rawBlock)
+}
+
+func releaseRawBlock(rb *rawBlock) {
+	rb.reset()
+	rawBlockPool.Put(rb)
+}
+
+var rawBlockPool sync.Pool
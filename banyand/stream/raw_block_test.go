@@ -0,0 +1,132 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+)
+
+// Test_mergeParts_rawBlocks verifies the full blocks copied as they're encoded are read the same as the ones re-encoded.
+func Test_mergeParts_rawBlocks(t *testing.T) {
+	merge := func(t *testing.T, codec encoding.Codec) (rows []string, bms []blockMetadata) {
+		tmpPath, defFn := test.Space(require.New(t))
+		defer defFn()
+		fileSystem := fs.NewLocalFileSystem()
+		var pp []*partWrapper
+		defer func() {
+			for _, pw := range pp {
+				pw.decRef()
+			}
+		}()
+		for i, es := range []*elements{esTS1, esTS2} {
+			mp := generateMemPart()
+			mp.mustInitFromElements(es)
+			mp.mustFlush(fileSystem, partPath(tmpPath, uint64(i)))
+			pw := newPartWrapper(nil, mustOpenFilePart(uint64(i), tmpPath, fileSystem))
+			pw.p.partMetadata.ID = uint64(i)
+			pp = append(pp, pw)
+			releaseMemPart(mp)
+		}
+		closeCh := make(chan struct{})
+		defer close(closeCh)
+		// every block is full as it holds a row at most
+		p, skipped, err := mergeParts(fileSystem, closeCh, pp, 2, tmpPath, newBlockSizing(1, 1, maxUncompressedBlockSize), codec, fs.IOOptions{})
+		require.NoError(t, err)
+		require.Empty(t, skipped)
+		defer p.decRef()
+
+		pmi := &partMergeIter{}
+		pmi.mustInitFromPart(p.p)
+		reader := &blockReader{}
+		reader.init([]*partMergeIter{pmi})
+		decoder := &encoding.BytesBlockDecoder{}
+		for reader.nextBlockMetadata() {
+			var bm blockMetadata
+			bm.copyFrom(&reader.block.bm)
+			bms = append(bms, bm)
+			require.NoError(t, reader.loadBlockData(decoder))
+			b := reader.block
+			for i := range b.timestamps {
+				row := fmt.Sprintf("%d/%d/%s", b.bm.seriesID, b.timestamps[i], b.elementIDs[i])
+				for _, tf := range b.tagFamilies {
+					for _, tg := range tf.tags {
+						row += fmt.Sprintf("/%s.%s=%x", tf.name, tg.name, tg.values[i])
+					}
+				}
+				rows = append(rows, row)
+			}
+		}
+		require.NoError(t, reader.error())
+		return rows, bms
+	}
+
+	// the blocks compressed by another codec are re-encoded
+	want, wantBMs := merge(t, encoding.CodecSnappy)
+	got, gotBMs := merge(t, encoding.CodecZSTD)
+	require.Len(t, got, 6)
+	assert.Equal(t, want, got)
+	require.Len(t, gotBMs, len(wantBMs))
+	for i := range gotBMs {
+		assert.Equal(t, wantBMs[i].seriesID, gotBMs[i].seriesID)
+		assert.Equal(t, wantBMs[i].count, gotBMs[i].count)
+		assert.Equal(t, wantBMs[i].uncompressedSizeBytes, gotBMs[i].uncompressedSizeBytes)
+		assert.Equal(t, wantBMs[i].tagStats, gotBMs[i].tagStats)
+	}
+}
+
+func Test_blockReader_rawCopyable(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	var ts tombstones
+	ts.init(fs.NewLocalFileSystem(), tmpPath, "default", logger.GetLogger("test"))
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.mustInitFromElements(esTS1)
+	p := openMemPart(mp)
+	defer p.close()
+	p.partMetadata.ID = 1
+	p.tombstones = &ts
+
+	newReader := func() *blockReader {
+		pmi := &partMergeIter{}
+		pmi.mustInitFromPart(p)
+		br := &blockReader{}
+		br.init([]*partMergeIter{pmi})
+		require.True(t, br.nextBlockMetadata())
+		return br
+	}
+	assert.True(t, newReader().rawCopyable(encoding.CodecZSTD))
+	assert.False(t, newReader().rawCopyable(encoding.CodecSnappy), "the tag values are compressed by another codec")
+
+	p.partMetadata.FormatVersion = partFormatVersionTagStats
+	assert.False(t, newReader().rawCopyable(encoding.CodecZSTD), "the part doesn't record the codec")
+	p.partMetadata.FormatVersion = currentPartFormatVersion
+
+	ts.delete(&deletion{Series: []common.SeriesID{1}, MaxPartID: 1, MinTimestamp: 1, MaxTimestamp: 1})
+	assert.False(t, newReader().rawCopyable(encoding.CodecZSTD), "the block has deleted elements")
+}
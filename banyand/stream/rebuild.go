@@ -0,0 +1,385 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/index"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/partition"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+// the documents of the elements written to the element index at a time by a rebuild
+const rebuildIndexBatchSize = 1024
+
+// indexRebuild is the progress of indexing the elements of a stream in the background.
+type indexRebuild struct {
+	status *streamv1.RebuildIndexResponse
+	cancel context.CancelFunc
+	done   chan struct{}
+	mu     sync.RWMutex
+}
+
+func (ir *indexRebuild) snapshot() *streamv1.RebuildIndexResponse {
+	ir.mu.RLock()
+	defer ir.mu.RUnlock()
+	return proto.Clone(ir.status).(*streamv1.RebuildIndexResponse)
+}
+
+func (ir *indexRebuild) update(fn func(s *streamv1.RebuildIndexResponse)) {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	fn(ir.status)
+}
+
+func (ir *indexRebuild) finished() bool {
+	select {
+	case <-ir.done:
+		return true
+	default:
+		return false
+	}
+}
+
+type rebuildIndexCallback struct {
+	l          *logger.Logger
+	schemaRepo *schemaRepo
+	rebuilds   map[string]*indexRebuild
+	mu         sync.Mutex
+}
+
+func setUpRebuildIndexCallback(l *logger.Logger, schemaRepo *schemaRepo) *rebuildIndexCallback {
+	return &rebuildIndexCallback{
+		l:          l,
+		schemaRepo: schemaRepo,
+		rebuilds:   make(map[string]*indexRebuild),
+	}
+}
+
+// Rev starts a rebuild of the stream unless one is going on, and returns the progress of the last one.
+func (r *rebuildIndexCallback) Rev(message bus.Message) (resp bus.Message) {
+	req, ok := message.Data().(*streamv1.RebuildIndexRequest)
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("invalid event data type"))
+	}
+	s, ok := r.schemaRepo.loadStream(req.GetMetadata())
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("stream %s doesn't exist", req.GetMetadata()))
+	}
+	key := req.GetMetadata().GetGroup() + "/" + req.GetMetadata().GetName()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ir, ok := r.rebuilds[key]
+	if req.GetStatusOnly() || (ok && !ir.finished()) {
+		if !ok {
+			return bus.NewMessage(message.ID(), &streamv1.RebuildIndexResponse{})
+		}
+		return bus.NewMessage(message.ID(), ir.snapshot())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ir = &indexRebuild{
+		status: &streamv1.RebuildIndexResponse{Running: true},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	r.rebuilds[key] = ir
+	go func() {
+		defer close(ir.done)
+		defer cancel()
+		start := time.Now()
+		err := s.rebuildIndex(ctx, ir)
+		ir.update(func(st *streamv1.RebuildIndexResponse) {
+			st.Running = false
+			if err != nil {
+				st.Errors = []string{err.Error()}
+			}
+		})
+		if err != nil {
+			r.l.Error().Err(err).Str("stream", key).Msg("failed to rebuild the index of the stream")
+			return
+		}
+		st := ir.snapshot()
+		r.l.Info().Str("stream", key).Uint32("parts", st.GetScannedParts()).Uint64("elements", st.GetElements()).
+			Dur("elapsed", time.Since(start)).Msg("rebuilt the index of the stream")
+	}()
+	return bus.NewMessage(message.ID(), ir.snapshot())
+}
+
+// stop cancels the rebuilds going on, and waits for them to quit.
+func (r *rebuildIndexCallback) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ir := range r.rebuilds {
+		ir.cancel()
+		<-ir.done
+	}
+}
+
+// indexSource is where the value of a tag an index rule refers to is read from,
+// which is either the entity of the series or the tag read from the blocks.
+type indexSource struct {
+	spec *databasev1.TagSpec
+	// entity is the position of the tag in the entity, or -1 if the tag is stored in the blocks
+	entity int
+	// family and tag are the position of the tag in the blocks read by the projection
+	family, tag int
+	// familyOffset and tagOffset are the position of the tag in the schema
+	familyOffset, tagOffset int
+}
+
+// rebuildIndex writes the index documents of the elements in the parts which exist when it starts, by the current index rules.
+// The elements written afterwards are indexed by the writes, which go on without being blocked.
+// A document replaces the one of the same element, so all the rules of an element are indexed again.
+func (s *stream) rebuildIndex(ctx context.Context, ir *indexRebuild) error {
+	sources, projection, err := s.indexSources()
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	tsdb := s.databaseSupplier.SupplyTSDB().(storage.TSDB[*tsTable, option])
+	entity := make([]*modelv1.TagValue, len(s.schema.GetEntity().GetTagNames()))
+	for i := range entity {
+		entity[i] = pbv1.AnyTagValue
+	}
+	sl, err := tsdb.Lookup(ctx, &pbv1.Series{Subject: s.name, EntityValues: entity})
+	if err != nil {
+		return errors.WithMessage(err, "cannot look up the series")
+	}
+	series := make(map[common.SeriesID]*pbv1.Series, len(sl))
+	sids := make([]common.SeriesID, 0, len(sl))
+	for i := range sl {
+		series[sl[i].ID] = sl[i]
+		sids = append(sids, sl[i].ID)
+	}
+	slices.Sort(sids)
+	tabWrappers := tsdb.SelectTSTables(timestamp.NewInclusiveTimeRange(time.Unix(0, 0), time.Unix(0, math.MaxInt64)))
+	defer func() {
+		for i := range tabWrappers {
+			tabWrappers[i].DecRef()
+		}
+	}()
+	// the parts are counted up front, so that the progress is told by them
+	snapshots := make([]*snapshot, len(tabWrappers))
+	defer func() {
+		for _, snp := range snapshots {
+			if snp != nil {
+				snp.decRef()
+			}
+		}
+	}()
+	var total int
+	for i, tw := range tabWrappers {
+		if snapshots[i] = tw.Table().currentSnapshot(); snapshots[i] != nil {
+			total += len(snapshots[i].parts)
+		}
+	}
+	ir.update(func(st *streamv1.RebuildIndexResponse) {
+		st.TotalParts = uint32(total)
+	})
+	if len(sids) == 0 {
+		return nil
+	}
+	ri := newIndexRebuilder(s, sources, projection, series)
+	for i, snp := range snapshots {
+		if snp == nil {
+			continue
+		}
+		for _, pw := range snp.parts {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+			n, errRebuild := ri.rebuildPart(ctx, tabWrappers[i].Table().Index(), pw.p, sids)
+			if errRebuild != nil {
+				return errors.WithMessagef(errRebuild, "cannot index the part %d of the segment %s", pw.ID(), tabWrappers[i].GetTimeRange())
+			}
+			ir.update(func(st *streamv1.RebuildIndexResponse) {
+				st.ScannedParts++
+				st.Elements += n
+			})
+		}
+	}
+	return nil
+}
+
+// indexSources returns where the values of the tags the index rules refer to are read from,
+// and the projection reading those stored in the blocks.
+// The tags which are only indexed aren't stored, so the rules referring to them can't be rebuilt.
+func (s *stream) indexSources() ([]indexSource, []pbv1.TagProjection, error) {
+	entityIndex := make(map[string]int)
+	for i, name := range s.schema.GetEntity().GetTagNames() {
+		entityIndex[name] = i
+	}
+	families := s.schema.GetTagFamilies()
+	var sources []indexSource
+	var projection []pbv1.TagProjection
+	for _, l := range s.indexRuleLocators {
+		if len(l.TagIndices) != 1 {
+			return nil, nil, errors.Errorf("the index rule %s refers to several tags", l.Rule.GetMetadata().GetName())
+		}
+		ti := l.TagIndices[0]
+		family := families[ti.FamilyOffset]
+		spec := family.GetTags()[ti.TagOffset]
+		if spec.GetIndexedOnly() {
+			return nil, nil, errors.Errorf("the tag %s of the index rule %s isn't stored", spec.GetName(), l.Rule.GetMetadata().GetName())
+		}
+		src := indexSource{spec: spec, entity: -1, familyOffset: ti.FamilyOffset, tagOffset: ti.TagOffset}
+		if i, ok := entityIndex[spec.GetName()]; ok {
+			src.entity = i
+			sources = append(sources, src)
+			continue
+		}
+		src.family = slices.IndexFunc(projection, func(tp pbv1.TagProjection) bool {
+			return tp.Family == family.GetName()
+		})
+		if src.family < 0 {
+			src.family = len(projection)
+			projection = append(projection, pbv1.TagProjection{Family: family.GetName()})
+		}
+		tp := &projection[src.family]
+		if src.tag = slices.Index(tp.Names, spec.GetName()); src.tag < 0 {
+			src.tag = len(tp.Names)
+			tp.Names = append(tp.Names, spec.GetName())
+		}
+		sources = append(sources, src)
+	}
+	return sources, projection, nil
+}
+
+// indexRebuilder reads the indexed tags of the elements from the blocks of the parts, and writes their index documents.
+type indexRebuilder struct {
+	series     map[common.SeriesID]*pbv1.Series
+	locators   []*partition.IndexRuleLocator
+	sources    []indexSource
+	projection []pbv1.TagProjection
+	// tagFamilies are the values of the indexed tags of a row, in the layout of the schema the locators refer to
+	tagFamilies []tagValues
+	docs        index.Documents
+	decoder     encoding.BytesBlockDecoder
+}
+
+func newIndexRebuilder(s *stream, sources []indexSource, projection []pbv1.TagProjection,
+	series map[common.SeriesID]*pbv1.Series,
+) *indexRebuilder {
+	families := s.schema.GetTagFamilies()
+	ri := &indexRebuilder{
+		series:      series,
+		locators:    s.indexRuleLocators,
+		sources:     sources,
+		projection:  projection,
+		tagFamilies: make([]tagValues, len(families)),
+	}
+	for i := range families {
+		ri.tagFamilies[i].tag = families[i].GetName()
+		ri.tagFamilies[i].values = make([]*tagValue, len(families[i].GetTags()))
+	}
+	return ri
+}
+
+// rebuildPart indexes the elements of the series in the part, and returns the number of them.
+// The blocks of the purged series are skipped.
+func (ri *indexRebuilder) rebuildPart(ctx context.Context, ei *elementIndex, p *part, sids []common.SeriesID) (uint64, error) {
+	var pi partIter
+	pi.init(p, sids, math.MinInt64, math.MaxInt64)
+	b := generateBlock()
+	defer releaseBlock(b)
+	var n uint64
+	for pi.nextBlock() {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		bm := pi.curBlock
+		if p.tombstones.covers(bm.seriesID, p.partMetadata.ID) {
+			continue
+		}
+		bm.tagProjection = ri.projection
+		ri.decoder.Reset()
+		if err := b.readFrom(&ri.decoder, p, bm); err != nil {
+			return n, err
+		}
+		for idx := range b.timestamps {
+			fields := appendIndexFields(nil, ri.locators, ri.tagValues(b, idx, ri.series[bm.seriesID]), bm.seriesID)
+			if len(fields) == 0 {
+				continue
+			}
+			ri.docs = append(ri.docs, index.Document{
+				DocID:  uint64(b.timestamps[idx]),
+				Fields: fields,
+			})
+		}
+		if len(ri.docs) >= rebuildIndexBatchSize {
+			if err := ei.Write(ri.docs); err != nil {
+				return n, err
+			}
+			n += uint64(len(ri.docs))
+			ri.docs = ri.docs[:0]
+		}
+	}
+	if err := pi.error(); err != nil {
+		return n, err
+	}
+	if len(ri.docs) > 0 {
+		if err := ei.Write(ri.docs); err != nil {
+			return n, err
+		}
+		n += uint64(len(ri.docs))
+		ri.docs = ri.docs[:0]
+	}
+	return n, nil
+}
+
+// tagValues returns the values of the indexed tags of the row at idx. The tags absent from the block are left nil.
+func (ri *indexRebuilder) tagValues(b *block, idx int, series *pbv1.Series) []tagValues {
+	for _, src := range ri.sources {
+		var value *modelv1.TagValue
+		if src.entity >= 0 {
+			if series != nil && src.entity < len(series.EntityValues) {
+				value = series.EntityValues[src.entity]
+			}
+		} else if src.family < len(b.tagFamilies) && src.tag < len(b.tagFamilies[src.family].tags) {
+			t := &b.tagFamilies[src.family].tags[src.tag]
+			if idx < len(t.values) && t.values[idx] != nil {
+				value = mustDecodeTagValue(t.valueType, t.values[idx])
+			}
+		}
+		var tv *tagValue
+		if value != nil {
+			tv = encodeTagValue(src.spec.GetName(), src.spec.GetType(), value)
+		}
+		ri.tagFamilies[src.familyOffset].values[src.tagOffset] = tv
+	}
+	return ri.tagFamilies
+}
@@ -0,0 +1,129 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/index"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/partition"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func newRebuildTestStream(indexedOnly bool) *stream {
+	s := &stream{
+		schema: &databasev1.Stream{
+			Entity: &databasev1.Entity{TagNames: []string{"strTag2"}},
+			TagFamilies: []*databasev1.TagFamilySpec{
+				{
+					Name: "singleTag",
+					Tags: []*databasev1.TagSpec{
+						{Name: "strTag", Type: databasev1.TagType_TAG_TYPE_STRING, IndexedOnly: indexedOnly},
+						{Name: "intTag", Type: databasev1.TagType_TAG_TYPE_INT},
+						{Name: "strTag2", Type: databasev1.TagType_TAG_TYPE_STRING},
+					},
+				},
+			},
+		},
+	}
+	s.indexRuleLocators = []*partition.IndexRuleLocator{
+		{
+			Rule:       &databasev1.IndexRule{Metadata: &commonv1.Metadata{Name: "str", Id: 1}},
+			TagIndices: []partition.TagLocator{{FamilyOffset: 0, TagOffset: 0}},
+		},
+		{
+			Rule:       &databasev1.IndexRule{Metadata: &commonv1.Metadata{Name: "int", Id: 2}},
+			TagIndices: []partition.TagLocator{{FamilyOffset: 0, TagOffset: 1}},
+		},
+		{
+			Rule:       &databasev1.IndexRule{Metadata: &commonv1.Metadata{Name: "entity", Id: 3}},
+			TagIndices: []partition.TagLocator{{FamilyOffset: 0, TagOffset: 2}},
+		},
+	}
+	return s
+}
+
+func Test_stream_indexSources(t *testing.T) {
+	sources, projection, err := newRebuildTestStream(false).indexSources()
+	require.NoError(t, err)
+	require.Len(t, sources, 3)
+	assert.Equal(t, []pbv1.TagProjection{{Family: "singleTag", Names: []string{"strTag", "intTag"}}}, projection)
+	assert.Equal(t, -1, sources[1].entity)
+	assert.Equal(t, 1, sources[1].tag)
+	assert.Equal(t, 0, sources[2].entity, "the entity tags are read from the series")
+
+	_, _, err = newRebuildTestStream(true).indexSources()
+	assert.Error(t, err, "the tags only indexed can't be read back")
+}
+
+func Test_indexRebuilder_rebuildPart(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	tst, err := newTSTable(fs.NewLocalFileSystem(), tmpPath, common.Position{}, logger.GetLogger("test"),
+		timestamp.TimeRange{}, option{flushTimeout: defaultFlushTimeout, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer tst.Close()
+	require.NoError(t, tst.addElements(esTS1))
+
+	s := newRebuildTestStream(false)
+	sources, projection, err := s.indexSources()
+	require.NoError(t, err)
+	series := map[common.SeriesID]*pbv1.Series{
+		1: {ID: 1, EntityValues: []*modelv1.TagValue{strTagValue("svc1")}},
+		2: {ID: 2, EntityValues: []*modelv1.TagValue{strTagValue("svc2")}},
+	}
+	ri := newIndexRebuilder(s, sources, projection, series)
+	snp := tst.currentSnapshot()
+	require.NotNil(t, snp)
+	defer snp.decRef()
+	require.Len(t, snp.parts, 1)
+	n, err := ri.rebuildPart(context.Background(), tst.Index(), snp.parts[0].p, []common.SeriesID{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), n, "the element of the series 3 has none of the indexed tags")
+
+	match := func(ruleID uint32, sid common.SeriesID, term []byte) []uint64 {
+		pl, errMatch := tst.Index().store.MatchTerms(index.Field{
+			Key:  index.FieldKey{IndexRuleID: ruleID, SeriesID: sid},
+			Term: term,
+		})
+		require.NoError(t, errMatch)
+		return pl.ToSlice()
+	}
+	assert.Equal(t, []uint64{1}, match(1, 1, []byte("value1")))
+	assert.Equal(t, []uint64{1}, match(2, 1, convert.Int64ToBytes(10)))
+	assert.Equal(t, []uint64{1}, match(3, 2, []byte("svc2")))
+	assert.Empty(t, match(1, 2, []byte("value1")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ri.rebuildPart(ctx, tst.Index(), snp.parts[0].p, []common.SeriesID{1, 2, 3})
+	assert.ErrorIs(t, err, context.Canceled)
+}
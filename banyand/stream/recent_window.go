@@ -0,0 +1,498 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+const (
+	defaultRecentWindowSpan   = 5 * time.Minute
+	defaultRecentWindowSeries = 16
+	// the hot series are picked again at this interval, when the query counts are halved
+	hotSeriesInterval = 10 * time.Second
+	// a series is hot once it's queried at least these many times in an interval, decayed
+	minHotSeriesQueries = 4
+	// the bytes of a row besides its element ID and tag values
+	windowRowOverhead = 64
+	// the bytes of a tag of a row besides its name and value
+	windowTagOverhead = 48
+)
+
+var (
+	recentWindowProvider      = observability.NewMeterProvider(observability.RootScope.SubScope("stream").SubScope("recent_window"))
+	recentWindowResidentGauge = recentWindowProvider.Gauge("resident_bytes")
+	hotSeriesGauge            = recentWindowProvider.Gauge("hot_series")
+	windowSkippedBlocksCount  = recentWindowProvider.Counter("skipped_blocks_total")
+
+	recentWindows = &recentWindowRegistry{
+		heat:   make(map[common.SeriesID]float64),
+		tables: make(map[*tableWindows]struct{}),
+		span:   defaultRecentWindowSpan,
+		series: defaultRecentWindowSeries,
+	}
+)
+
+// recentWindowRegistry tracks how often the series are queried, and keeps a recent window of the freshest elements
+// for each of the hottest ones in every tsTable they're written to. The windows are disabled if the budget is 0.
+type recentWindowRegistry struct {
+	hot        atomic.Pointer[map[common.SeriesID]struct{}]
+	heat       map[common.SeriesID]float64
+	tables     map[*tableWindows]struct{}
+	rebalanced time.Time
+	budget     int64
+	span       time.Duration
+	series     int
+	resident   atomic.Int64
+	mu         sync.Mutex
+}
+
+// configure sets the bytes held by all the windows, the time span of a window and the number of the hot series.
+func (r *recentWindowRegistry) configure(budget int64, span time.Duration, series int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.budget = budget
+	r.span = span
+	r.series = series
+	if budget <= 0 {
+		r.hot.Store(nil)
+		clear(r.heat)
+		r.evictAllLocked()
+	}
+}
+
+// windowBytes is the bytes a window holds at most, which splits the budget among the hot series.
+func (r *recentWindowRegistry) windowBytes() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.series < 1 {
+		return 0
+	}
+	return r.budget / int64(r.series)
+}
+
+func (r *recentWindowRegistry) windowSpan() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.span
+}
+
+// touch counts a query of the series, and picks the hot series again once the interval elapses.
+func (r *recentWindowRegistry) touch(sids []common.SeriesID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.budget <= 0 {
+		return
+	}
+	for _, sid := range sids {
+		r.heat[sid]++
+	}
+	if now := time.Now(); now.Sub(r.rebalanced) >= hotSeriesInterval {
+		r.rebalanceLocked(now)
+	}
+}
+
+func (r *recentWindowRegistry) isHot(sid common.SeriesID) bool {
+	hot := r.hot.Load()
+	if hot == nil {
+		return false
+	}
+	_, ok := (*hot)[sid]
+	return ok
+}
+
+func (r *recentWindowRegistry) rebalance(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rebalanceLocked(now)
+}
+
+// rebalanceLocked picks the most queried series as the hot ones, and drops the windows of the others.
+// The query counts are halved, so that the series queried no more cool down.
+func (r *recentWindowRegistry) rebalanceLocked(now time.Time) {
+	r.rebalanced = now
+	candidates := make([]common.SeriesID, 0, len(r.heat))
+	for sid, n := range r.heat {
+		if n >= minHotSeriesQueries {
+			candidates = append(candidates, sid)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if r.heat[candidates[i]] != r.heat[candidates[j]] {
+			return r.heat[candidates[i]] > r.heat[candidates[j]]
+		}
+		return candidates[i] < candidates[j]
+	})
+	if len(candidates) > r.series {
+		candidates = candidates[:r.series]
+	}
+	hot := make(map[common.SeriesID]struct{}, len(candidates))
+	for _, sid := range candidates {
+		hot[sid] = struct{}{}
+	}
+	r.hot.Store(&hot)
+	hotSeriesGauge.Set(float64(len(hot)))
+	for sid := range r.heat {
+		if r.heat[sid] /= 2; r.heat[sid] < 1 {
+			delete(r.heat, sid)
+		}
+	}
+	for tw := range r.tables {
+		tw.retain(hot)
+	}
+}
+
+func (r *recentWindowRegistry) register(tw *tableWindows) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tables[tw] = struct{}{}
+}
+
+func (r *recentWindowRegistry) unregister(tw *tableWindows) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tables, tw)
+}
+
+// evictAll drops the windows of all the tsTables, which are filled again by the writes afterwards.
+func (r *recentWindowRegistry) evictAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.evictAllLocked()
+}
+
+func (r *recentWindowRegistry) evictAllLocked() {
+	for tw := range r.tables {
+		tw.retain(nil)
+	}
+}
+
+func (r *recentWindowRegistry) grow(delta int64) {
+	recentWindowResidentGauge.Set(float64(r.resident.Add(delta)))
+}
+
+// tableWindows are the recent windows of the hot series written to a tsTable.
+type tableWindows struct {
+	series map[common.SeriesID]*recentWindow
+	// count is the number of the windows, the writes skip looking them up if it's 0
+	count atomic.Int32
+	mu    sync.RWMutex
+}
+
+// acquire returns the window of the series, which is opened if there's none.
+// A window opened covers the elements written from then on, whose timestamps are greater than all the ones in the snapshot.
+// It returns nil if the caches are being evicted under the memory pressure.
+func (tw *tableWindows) acquire(tst *tsTable, sid common.SeriesID) *recentWindow {
+	tw.mu.RLock()
+	w := tw.series[sid]
+	tw.mu.RUnlock()
+	if w != nil {
+		return w
+	}
+	if watchdog.Engaged(watchdog.ActionEvictCaches) {
+		return nil
+	}
+	recentWindows.register(tw)
+	tw.mu.Lock()
+	if w = tw.series[sid]; w != nil {
+		tw.mu.Unlock()
+		return w
+	}
+	if tw.series == nil {
+		tw.series = make(map[common.SeriesID]*recentWindow)
+	}
+	w = &recentWindow{coverFrom: math.MinInt64}
+	tw.series[sid] = w
+	tw.count.Add(1)
+	tw.mu.Unlock()
+
+	// the snapshot is taken after the window is opened, so that the elements not in it are added to the window
+	maxTimestamp := int64(math.MinInt64)
+	if snp := tst.currentSnapshot(); snp != nil {
+		for _, pw := range snp.parts {
+			maxTimestamp = max(maxTimestamp, pw.p.partMetadata.MaxTimestamp)
+		}
+		snp.decRef()
+	}
+	w.open(maxTimestamp, time.Now().Add(-recentWindows.windowSpan()).UnixNano())
+	return w
+}
+
+// add adds the elements of the series having windows to them.
+// It's called once the elements are introduced, so the window of a series opened in between covers them by the snapshot.
+func (tw *tableWindows) add(es *elements) {
+	if tw.count.Load() == 0 {
+		return
+	}
+	maxBytes := recentWindows.windowBytes()
+	tw.mu.RLock()
+	defer tw.mu.RUnlock()
+	for i, sid := range es.seriesIDs {
+		if w := tw.series[sid]; w != nil {
+			w.add(es.timestamps[i], es.elementIDs[i], es.tagFamilies[i], maxBytes)
+		}
+	}
+}
+
+// trim drops the elements older than the span from the windows.
+func (tw *tableWindows) trim() {
+	if tw.count.Load() == 0 {
+		return
+	}
+	from := time.Now().Add(-recentWindows.windowSpan()).UnixNano()
+	tw.mu.RLock()
+	defer tw.mu.RUnlock()
+	for _, w := range tw.series {
+		w.trim(from)
+	}
+}
+
+// drop drops the windows of the series, e.g. the purged ones.
+func (tw *tableWindows) drop(sids []common.SeriesID) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	for _, sid := range sids {
+		if w, ok := tw.series[sid]; ok {
+			w.release()
+			delete(tw.series, sid)
+			tw.count.Add(-1)
+		}
+	}
+}
+
+// retain drops the windows of the series not in hot.
+func (tw *tableWindows) retain(hot map[common.SeriesID]struct{}) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	for sid, w := range tw.series {
+		if _, ok := hot[sid]; !ok {
+			w.release()
+			delete(tw.series, sid)
+			tw.count.Add(-1)
+		}
+	}
+}
+
+// close drops all the windows once the tsTable is closed.
+func (tw *tableWindows) close() {
+	recentWindows.unregister(tw)
+	tw.retain(nil)
+}
+
+// covers returns the cursors of the windows of the hot series, and the timestamps from which the windows cover them.
+// The blocks of the series are read for the elements before the timestamps only.
+func (tw *tableWindows) covers(tst *tsTable, sids []common.SeriesID, qo queryOptions) ([]*blockCursor, map[common.SeriesID]int64) {
+	var bcs []*blockCursor
+	var covered map[common.SeriesID]int64
+	for _, sid := range sids {
+		if !recentWindows.isHot(sid) {
+			continue
+		}
+		w := tw.acquire(tst, sid)
+		if w == nil {
+			continue
+		}
+		bc, coverFrom, ok := w.cursor(sid, qo)
+		if !ok {
+			continue
+		}
+		if covered == nil {
+			covered = make(map[common.SeriesID]int64)
+		}
+		covered[sid] = coverFrom
+		if bc != nil {
+			bcs = append(bcs, bc)
+		}
+	}
+	return bcs, covered
+}
+
+type windowTag struct {
+	family    string
+	name      string
+	value     []byte
+	valueType pbv1.ValueType
+}
+
+type windowRow struct {
+	elementID string
+	tags      []windowTag
+	timestamp int64
+	size      int64
+}
+
+// recentWindow holds the freshest elements of a series decoded, in the order of timestamps.
+// It covers all the elements of the series whose timestamps are coverFrom or later once it's ready.
+type recentWindow struct {
+	rows      []windowRow
+	coverFrom int64
+	size      int64
+	ready     bool
+	// closed is set once the window is dropped, then the elements are no more added to it
+	closed bool
+	mu     sync.RWMutex
+}
+
+// open makes the window cover the elements after maxTimestamp, the greatest timestamp of the snapshot,
+// and from onward at the earliest.
+func (w *recentWindow) open(maxTimestamp, from int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if maxTimestamp < math.MaxInt64 {
+		from = max(from, maxTimestamp+1)
+	} else {
+		from = math.MaxInt64
+	}
+	w.trimLocked(from)
+	w.ready = !w.closed
+}
+
+func (w *recentWindow) add(ts int64, elementID string, tff []tagValues, maxBytes int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed || ts < w.coverFrom {
+		return
+	}
+	row := windowRow{
+		timestamp: ts,
+		elementID: elementID,
+		size:      int64(len(elementID)) + windowRowOverhead,
+	}
+	for _, tf := range tff {
+		for _, tv := range tf.values {
+			// the values are copied, they may refer to the buffers of the write
+			wt := windowTag{family: tf.tag, name: tv.tag, valueType: tv.valueType}
+			if v := tv.marshal(); v != nil {
+				wt.value = append([]byte{}, v...)
+			}
+			row.tags = append(row.tags, wt)
+			row.size += int64(len(tf.tag)+len(tv.tag)+len(wt.value)) + windowTagOverhead
+		}
+	}
+	// the elements with the same timestamp keep their arrival order
+	idx := sort.Search(len(w.rows), func(i int) bool { return w.rows[i].timestamp > ts })
+	w.rows = append(w.rows, windowRow{})
+	copy(w.rows[idx+1:], w.rows[idx:])
+	w.rows[idx] = row
+	w.size += row.size
+	recentWindows.grow(row.size)
+	// the oldest elements are dropped to keep it within the bytes, and the window covers the elements after them
+	for w.size > maxBytes && len(w.rows) > 0 {
+		w.trimLocked(w.rows[0].timestamp + 1)
+	}
+}
+
+func (w *recentWindow) trim(from int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.trimLocked(from)
+}
+
+// trimLocked drops the elements before from, and the window covers the elements from it.
+func (w *recentWindow) trimLocked(from int64) {
+	if from <= w.coverFrom {
+		return
+	}
+	w.coverFrom = from
+	n := sort.Search(len(w.rows), func(i int) bool { return w.rows[i].timestamp >= from })
+	if n == 0 {
+		return
+	}
+	var size int64
+	for i := range w.rows[:n] {
+		size += w.rows[i].size
+	}
+	l := len(w.rows)
+	w.rows = append(w.rows[:0], w.rows[n:]...)
+	clear(w.rows[len(w.rows):l])
+	w.size -= size
+	recentWindows.grow(-size)
+}
+
+func (w *recentWindow) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	recentWindows.grow(-w.size)
+	w.rows = nil
+	w.size = 0
+	w.ready = false
+	w.closed = true
+}
+
+// cursor returns a cursor of the elements of the window in the time range, which is nil if there's none,
+// along with the timestamp from which the window covers the series.
+// It returns false if the window isn't ready or covers none of the time range.
+func (w *recentWindow) cursor(sid common.SeriesID, qo queryOptions) (*blockCursor, int64, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if !w.ready || w.coverFrom > qo.maxTimestamp {
+		return nil, 0, false
+	}
+	start := sort.Search(len(w.rows), func(i int) bool { return w.rows[i].timestamp >= qo.minTimestamp })
+	end := sort.Search(len(w.rows), func(i int) bool { return w.rows[i].timestamp > qo.maxTimestamp })
+	if start >= end {
+		return nil, w.coverFrom, true
+	}
+	rows := w.rows[start:end]
+	bc := generateBlockCursor()
+	bc.init(nil, blockMetadata{seriesID: sid, count: uint64(len(rows))}, qo)
+	for i := range rows {
+		bc.timestamps = append(bc.timestamps, rows[i].timestamp)
+		bc.elementIDs = append(bc.elementIDs, rows[i].elementID)
+	}
+	for _, tp := range qo.TagProjection {
+		tf := tagFamily{name: tp.Family}
+		for _, name := range tp.Names {
+			t := tag{name: name, arena: &bc.columns}
+			for i := range rows {
+				wt := rows[i].find(tp.Family, name)
+				if wt == nil {
+					continue
+				}
+				if t.values == nil {
+					t.values = make([][]byte, len(rows))
+				}
+				// the values are shared with the window, which never modifies them
+				t.values[i] = wt.value
+				t.valueType = wt.valueType
+			}
+			tf.tags = append(tf.tags, t)
+		}
+		bc.tagFamilies = append(bc.tagFamilies, tf)
+	}
+	bc.initWindow(false)
+	return bc, w.coverFrom, true
+}
+
+func (r *windowRow) find(family, name string) *windowTag {
+	for i := range r.tags {
+		if r.tags[i].family == family && r.tags[i].name == name {
+			return &r.tags[i]
+		}
+	}
+	return nil
+}
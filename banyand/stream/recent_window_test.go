@@ -0,0 +1,241 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func Test_recentWindow(t *testing.T) {
+	defer recentWindows.configure(0, defaultRecentWindowSpan, defaultRecentWindowSeries)
+	recentWindows.configure(1<<20, time.Minute, 1)
+	es := generateWideEs(10, 1)
+	w := &recentWindow{coverFrom: math.MinInt64}
+	add := func(ts int64, maxBytes int64) {
+		w.add(es.timestamps[ts-1], es.elementIDs[ts-1], es.tagFamilies[ts-1], maxBytes)
+	}
+	windowTimestamps := func() []int64 {
+		var tss []int64
+		for i := range w.rows {
+			tss = append(tss, w.rows[i].timestamp)
+		}
+		return tss
+	}
+	qo := queryOptions{minTimestamp: 0, maxTimestamp: 100}
+	qo.TagProjection = wideTagProjection(1)
+
+	add(4, math.MaxInt64)
+	add(2, math.MaxInt64)
+	_, _, ok := w.cursor(1, qo)
+	assert.False(t, ok, "the window isn't ready until it's opened")
+	w.open(2, 0)
+	assert.Equal(t, int64(3), w.coverFrom, "the window covers the elements after the snapshot")
+	assert.Equal(t, []int64{4}, windowTimestamps())
+
+	add(1, math.MaxInt64)
+	add(7, math.MaxInt64)
+	add(5, math.MaxInt64)
+	assert.Equal(t, []int64{4, 5, 7}, windowTimestamps(), "the elements before the window are ignored")
+
+	qo.minTimestamp = 5
+	bc, coverFrom, ok := w.cursor(1, qo)
+	require.True(t, ok)
+	assert.Equal(t, int64(3), coverFrom)
+	assert.Equal(t, []int64{5, 7}, bc.timestamps)
+	assert.Equal(t, []string{"5", "7"}, bc.elementIDs)
+	strs, _ := bc.tagFamilies[0].tags[0].AsStrings()
+	assert.Equal(t, []string{"value5", "value7"}, strs)
+	int64s, _ := bc.tagFamilies[0].tags[1].AsInt64s()
+	assert.Equal(t, []int64{5, 7}, int64s)
+	releaseBlockCursor(bc)
+	_, _, ok = w.cursor(1, queryOptions{minTimestamp: 0, maxTimestamp: 2})
+	assert.False(t, ok, "the window covers none of the time range")
+
+	add(8, w.size)
+	assert.Equal(t, []int64{5, 7, 8}, windowTimestamps(), "the oldest element is dropped beyond the bytes")
+	assert.Equal(t, int64(5), w.coverFrom)
+	assert.Equal(t, w.size, recentWindows.resident.Load())
+
+	w.trim(8)
+	assert.Equal(t, []int64{8}, windowTimestamps())
+	assert.Equal(t, int64(8), w.coverFrom)
+
+	w.release()
+	add(9, math.MaxInt64)
+	assert.Empty(t, w.rows, "no element is added to the dropped window")
+	assert.Zero(t, recentWindows.resident.Load())
+}
+
+func Test_queryResult_recentWindow(t *testing.T) {
+	defer recentWindows.configure(0, defaultRecentWindowSpan, defaultRecentWindowSeries)
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	tst, err := newTSTable(fs.NewLocalFileSystem(), tmpPath, common.Position{},
+		logger.GetLogger("test"), timestamp.TimeRange{}, option{flushTimeout: time.Hour, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer tst.Close()
+
+	// the memory parts are neither flushed nor merged in the test
+	now := time.Now().UnixNano()
+	minute, second := time.Minute.Nanoseconds(), time.Second.Nanoseconds()
+	require.NoError(t, tst.addElements(generateRecentEs(now-10*minute, now-minute, second, 1, 2)))
+	qo := queryOptions{minTimestamp: now - 10*minute, maxTimestamp: now + minute}
+	qo.TagProjection = wideTagProjection(1)
+
+	recentWindows.configure(1<<20, defaultRecentWindowSpan, 1)
+	heatSeries(1)
+	_, _ = queryRecent(t, tst, 1, qo)
+	w := tst.windows.series[1]
+	require.NotNil(t, w, "the window of the hot series is opened by the query")
+	assert.Nil(t, tst.windows.series[2])
+	assert.Greater(t, w.coverFrom, now-minute)
+
+	require.NoError(t, tst.addElements(generateRecentEs(now, now+30*second, second, 1, 2)))
+	assert.Len(t, w.rows, 31)
+	got, windowLoads := queryRecent(t, tst, 1, qo)
+
+	recentWindows.configure(0, defaultRecentWindowSpan, 1)
+	assert.Empty(t, tst.windows.series, "the windows are dropped once they're disabled")
+	want, loads := queryRecent(t, tst, 1, qo)
+	if diff := cmp.Diff(want, got, protocmp.IgnoreUnknown(), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected []pbv1.StreamResult (-want +got):\n%s", diff)
+	}
+	assert.Equal(t, loads-1, windowLoads, "the block covered by the window isn't loaded")
+
+	recentWindows.configure(1<<20, defaultRecentWindowSpan, 1)
+	heatSeries(1)
+	_, _ = queryRecent(t, tst, 1, qo)
+	require.NotNil(t, tst.windows.series[1])
+	require.NoError(t, tst.addElements(generateRecentEs(now+minute, now+minute, second, 1)))
+	assert.Positive(t, recentWindows.resident.Load())
+	_, err = tst.purge("test", []common.SeriesID{1}, false)
+	require.NoError(t, err)
+	assert.Nil(t, tst.windows.series[1], "the window of the purged series is dropped")
+	assert.Zero(t, recentWindows.resident.Load())
+}
+
+// Benchmark_recentWindow runs a 5-minute dashboard query against a hot series,
+// whose freshest elements are in its recent window or in the memory parts alone.
+func Benchmark_recentWindow(b *testing.B) {
+	defer recentWindows.configure(0, defaultRecentWindowSpan, defaultRecentWindowSeries)
+	tmpPath, defFn := test.Space(require.New(b))
+	defer defFn()
+	tst, err := newTSTable(fs.NewLocalFileSystem(), tmpPath, common.Position{},
+		logger.GetLogger("test"), timestamp.TimeRange{}, option{flushTimeout: time.Hour, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(b, err)
+	defer tst.Close()
+
+	now := time.Now().UnixNano()
+	minute := time.Minute.Nanoseconds()
+	step := (100 * time.Millisecond).Nanoseconds()
+	require.NoError(b, tst.addElements(generateRecentEs(now-30*minute, now-5*minute-step, step, 1, 2, 3)))
+	qo := queryOptions{minTimestamp: now - 5*minute, maxTimestamp: now}
+	qo.TagProjection = wideTagProjection(1)
+
+	recentWindows.configure(64<<20, 10*time.Minute, 1)
+	heatSeries(1)
+	_, _ = queryRecent(b, tst, 1, qo)
+	// the elements of the last 5 minutes are written in batches once the window is opened
+	for from := now - 5*minute; from < now; from += 30 * time.Second.Nanoseconds() {
+		require.NoError(b, tst.addElements(generateRecentEs(from, from+30*time.Second.Nanoseconds()-step, step, 1, 2, 3)))
+	}
+	for _, bc := range []struct {
+		name   string
+		budget int64
+	}{
+		{name: "window", budget: 64 << 20},
+		{name: "parts", budget: 0},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			if bc.budget == 0 {
+				recentWindows.configure(0, 10*time.Minute, 1)
+			}
+			var loads int
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, n := queryRecent(b, tst, 1, qo)
+				loads += n
+			}
+			b.ReportMetric(float64(loads)/float64(b.N), "blocks/op")
+		})
+	}
+}
+
+// heatSeries makes the series the hot ones.
+func heatSeries(sids ...common.SeriesID) {
+	for i := 0; i < 2*minHotSeriesQueries; i++ {
+		recentWindows.touch(sids)
+	}
+	recentWindows.rebalance(time.Now())
+}
+
+// queryRecent queries the series in the table, and returns the results along with the number of the blocks loaded.
+func queryRecent(t testing.TB, tst *tsTable, sid common.SeriesID, qo queryOptions) ([]pbv1.StreamResult, int) {
+	qr := queryResult{orderByTS: true, ascTS: true}
+	defer qr.Release()
+	require.NoError(t, qr.selectBlocks([]*tsTable{tst}, []common.SeriesID{sid}, qo))
+	var loads int
+	for _, bc := range qr.data {
+		if bc.p != nil {
+			loads++
+		}
+	}
+	var got []pbv1.StreamResult
+	for r := qr.Pull(); r != nil; r = qr.Pull() {
+		got = append(got, *r)
+	}
+	return got, loads
+}
+
+// generateRecentEs generates the elements of the series from the timestamp to the other one at the step.
+func generateRecentEs(from, to, step int64, sids ...common.SeriesID) *elements {
+	es := &elements{}
+	for ts := from; ts <= to; ts += step {
+		for _, sid := range sids {
+			es.seriesIDs = append(es.seriesIDs, sid)
+			es.timestamps = append(es.timestamps, ts)
+			es.elementIDs = append(es.elementIDs, strconv.FormatInt(ts, 10))
+			es.tagFamilies = append(es.tagFamilies, []tagValues{
+				{
+					tag: "family0", values: []*tagValue{
+						{tag: "strTag", valueType: pbv1.ValueTypeStr, value: []byte("value" + strconv.FormatInt(ts, 10))},
+						{tag: "intTag", valueType: pbv1.ValueTypeInt64, value: convert.Int64ToBytes(ts)},
+					},
+				},
+			})
+		}
+	}
+	return es
+}
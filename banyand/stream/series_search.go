@@ -0,0 +1,95 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+type searchSeriesCallback struct {
+	l          *logger.Logger
+	schemaRepo *schemaRepo
+}
+
+func setUpSearchSeriesCallback(l *logger.Logger, schemaRepo *schemaRepo) bus.MessageListener {
+	return &searchSeriesCallback{
+		l:          l,
+		schemaRepo: schemaRepo,
+	}
+}
+
+func (ss *searchSeriesCallback) Rev(message bus.Message) (resp bus.Message) {
+	req, ok := message.Data().(*streamv1.SearchSeriesRequest)
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("invalid event data type"))
+	}
+	s, ok := ss.schemaRepo.loadStream(req.GetMetadata())
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("stream %s doesn't exist", req.GetMetadata()))
+	}
+	result, err := s.searchSeries(req)
+	if err != nil {
+		ss.l.Error().Err(err).Stringer("stream", req.GetMetadata()).Msg("cannot search the series")
+		return bus.NewMessage(message.ID(), common.NewError("cannot search the series of the stream %s: %v", req.GetMetadata(), err))
+	}
+	return bus.NewMessage(message.ID(), result)
+}
+
+// searchSeries returns a page of the series whose entities start with the values and the prefix of the request,
+// in order of their marshaled entities.
+func (s *stream) searchSeries(req *streamv1.SearchSeriesRequest) (*streamv1.SearchSeriesResponse, error) {
+	tagNames := s.schema.GetEntity().GetTagNames()
+	if len(req.GetEntity()) > len(tagNames) || req.GetPrefix() != "" && len(req.GetEntity()) == len(tagNames) {
+		return nil, errors.Errorf("the stream has %d entity tags, but %d values are given", len(tagNames), len(req.GetEntity()))
+	}
+	if req.GetPrefix() != "" {
+		name := tagNames[len(req.GetEntity())]
+		if _, _, spec := pbv1.FindTagByName(s.schema.GetTagFamilies(), name); spec.GetType() != databasev1.TagType_TAG_TYPE_STRING {
+			return nil, errors.Errorf("the entity tag %s isn't a string tag", name)
+		}
+	}
+	prefix := &pbv1.Series{Subject: s.name, EntityValues: req.GetEntity()}
+	if err := prefix.MarshalPrefix(req.GetPrefix()); err != nil {
+		return nil, err
+	}
+	limit := req.GetLimit()
+	if limit == 0 {
+		limit = pbv1.DefaultSearchSeriesLimit
+	}
+	tsdb := s.databaseSupplier.SupplyTSDB().(storage.TSDB[*tsTable, option])
+	sl, more, err := tsdb.IndexDB().SearchPrefix(prefix.Buffer, req.GetCursor(), int(limit))
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot search the series index")
+	}
+	result := &streamv1.SearchSeriesResponse{}
+	for _, series := range sl {
+		result.Series = append(result.Series, &streamv1.SeriesEntity{Entity: series.EntityValues})
+	}
+	if more {
+		result.NextCursor = sl[len(sl)-1].Buffer
+	}
+	return result, nil
+}
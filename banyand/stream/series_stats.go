@@ -0,0 +1,153 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+// seriesStats is the elements and the bytes of the blocks of a series, which are recorded as the blocks are written.
+type seriesStats struct {
+	SeriesID              common.SeriesID `json:"seriesID"`
+	Count                 uint64          `json:"count"`
+	CompressedSizeBytes   uint64          `json:"compressedSizeBytes"`
+	UncompressedSizeBytes uint64          `json:"uncompressedSizeBytes"`
+}
+
+func (ss *seriesStats) add(other *seriesStats) {
+	ss.Count += other.Count
+	ss.CompressedSizeBytes += other.CompressedSizeBytes
+	ss.UncompressedSizeBytes += other.UncompressedSizeBytes
+}
+
+// appendSeriesStats adds a block to the statistics of its series, which is the last one since the blocks are written in order of the series.
+func appendSeriesStats(dst []seriesStats, sid common.SeriesID, count, compressedSizeBytes, uncompressedSizeBytes uint64) []seriesStats {
+	block := seriesStats{SeriesID: sid, Count: count, CompressedSizeBytes: compressedSizeBytes, UncompressedSizeBytes: uncompressedSizeBytes}
+	if n := len(dst); n > 0 && dst[n-1].SeriesID == sid {
+		dst[n-1].add(&block)
+		return dst
+	}
+	return append(dst, block)
+}
+
+// collectSeriesStats adds the statistics of the series in the parts of the table to dst, skipping the purged series.
+// The elements deleted but not merged away yet are still counted.
+func (tst *tsTable) collectSeriesStats(dst map[common.SeriesID]*seriesStats) {
+	snp := tst.currentSnapshot()
+	if snp == nil {
+		return
+	}
+	defer snp.decRef()
+	for _, pw := range snp.parts {
+		stats := pw.p.partMetadata.SeriesStats
+		for i := range stats {
+			if tst.tombstones.covers(stats[i].SeriesID, pw.ID()) {
+				continue
+			}
+			ss, ok := dst[stats[i].SeriesID]
+			if !ok {
+				ss = &seriesStats{SeriesID: stats[i].SeriesID}
+				dst[stats[i].SeriesID] = ss
+			}
+			ss.add(&stats[i])
+		}
+	}
+}
+
+type topNSeriesCallback struct {
+	l          *logger.Logger
+	schemaRepo *schemaRepo
+}
+
+func setUpTopNSeriesCallback(l *logger.Logger, schemaRepo *schemaRepo) bus.MessageListener {
+	return &topNSeriesCallback{
+		l:          l,
+		schemaRepo: schemaRepo,
+	}
+}
+
+func (t *topNSeriesCallback) Rev(message bus.Message) (resp bus.Message) {
+	req, ok := message.Data().(*streamv1.TopNSeriesRequest)
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("invalid event data type"))
+	}
+	s, ok := t.schemaRepo.loadStream(req.GetMetadata())
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("stream %s doesn't exist", req.GetMetadata()))
+	}
+	result, err := s.topNSeries(context.Background(), req)
+	if err != nil {
+		t.l.Error().Err(err).Stringer("stream", req.GetMetadata()).Msg("cannot get the top series")
+		return bus.NewMessage(message.ID(), common.NewError("cannot get the top series of the stream %s: %v", req.GetMetadata(), err))
+	}
+	return bus.NewMessage(message.ID(), result)
+}
+
+// topNSeries aggregates the statistics of the series of the stream across the parts of all the tsTables,
+// and returns the top ones along with their entities.
+func (s *stream) topNSeries(ctx context.Context, req *streamv1.TopNSeriesRequest) (*streamv1.TopNSeriesResponse, error) {
+	tsdb := s.databaseSupplier.SupplyTSDB().(storage.TSDB[*tsTable, option])
+	entity := make([]*modelv1.TagValue, len(s.schema.GetEntity().GetTagNames()))
+	for i := range entity {
+		entity[i] = pbv1.AnyTagValue
+	}
+	sl, err := tsdb.Lookup(ctx, &pbv1.Series{Subject: s.name, EntityValues: entity})
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot look up the series")
+	}
+	result := &streamv1.TopNSeriesResponse{}
+	if len(sl) == 0 {
+		return result, nil
+	}
+	all := make(map[common.SeriesID]*seriesStats)
+	tabWrappers := tsdb.SelectTSTables(timestamp.NewInclusiveTimeRange(time.Unix(0, 0), time.Unix(0, math.MaxInt64)))
+	for _, tw := range tabWrappers {
+		tw.Table().collectSeriesStats(all)
+		tw.DecRef()
+	}
+	stats := make([]*streamv1.SeriesStatistics, 0, len(sl))
+	for _, series := range sl {
+		ss, ok := all[series.ID]
+		if !ok {
+			continue
+		}
+		result.TotalSeries++
+		result.TotalCompressedBytes += ss.CompressedSizeBytes
+		stats = append(stats, &streamv1.SeriesStatistics{
+			Entity:            series.EntityValues,
+			Elements:          ss.Count,
+			CompressedBytes:   ss.CompressedSizeBytes,
+			UncompressedBytes: ss.UncompressedSizeBytes,
+		})
+	}
+	result.Series = pbv1.TopNSeriesStatistics(stats, req.GetOrderBy(), req.GetTopN())
+	return result, nil
+}
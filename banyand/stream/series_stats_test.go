@@ -0,0 +1,87 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func Test_appendSeriesStats(t *testing.T) {
+	var stats []seriesStats
+	stats = appendSeriesStats(stats, 1, 2, 10, 20)
+	stats = appendSeriesStats(stats, 1, 3, 5, 10)
+	stats = appendSeriesStats(stats, 2, 1, 1, 2)
+	assert.Equal(t, []seriesStats{
+		{SeriesID: 1, Count: 5, CompressedSizeBytes: 15, UncompressedSizeBytes: 30},
+		{SeriesID: 2, Count: 1, CompressedSizeBytes: 1, UncompressedSizeBytes: 2},
+	}, stats)
+}
+
+func Test_memPart_seriesStats(t *testing.T) {
+	mp := generateMemPart()
+	defer releaseMemPart(mp)
+	mp.mustInitFromElements(esTS1)
+
+	stats := mp.partMetadata.SeriesStats
+	require.Len(t, stats, 3)
+	var count, compressed, uncompressed uint64
+	for i, ss := range stats {
+		assert.Equal(t, common.SeriesID(i+1), ss.SeriesID)
+		count += ss.Count
+		compressed += ss.CompressedSizeBytes
+		uncompressed += ss.UncompressedSizeBytes
+	}
+	assert.Equal(t, mp.partMetadata.TotalCount, count)
+	assert.Equal(t, mp.partMetadata.UncompressedSizeBytes, uncompressed)
+	assert.Greater(t, compressed, uint64(0))
+	assert.LessOrEqual(t, compressed, mp.partMetadata.CompressedSizeBytes, "the meta and the primary blocks aren't counted")
+}
+
+func Test_tsTable_collectSeriesStats(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	tst, err := newTSTable(fs.NewLocalFileSystem(), tmpPath, common.Position{}, logger.GetLogger("test"),
+		timestamp.TimeRange{}, option{flushTimeout: defaultFlushTimeout, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer tst.Close()
+	require.NoError(t, tst.addElements(esTS1))
+	require.NoError(t, tst.addElements(esTS2))
+
+	all := make(map[common.SeriesID]*seriesStats)
+	tst.collectSeriesStats(all)
+	require.Len(t, all, 3)
+	for _, ss := range all {
+		assert.Equal(t, uint64(2), ss.Count, "the statistics of the series are aggregated across the parts")
+	}
+
+	_, err = tst.purge("sw", []common.SeriesID{1}, false)
+	require.NoError(t, err)
+	clear(all)
+	tst.collectSeriesStats(all)
+	assert.NotContains(t, all, common.SeriesID(1), "the purged series are skipped")
+	assert.Len(t, all, 2)
+}
@@ -21,19 +21,28 @@ import (
 	"context"
 	"math"
 	"path"
+	"path/filepath"
+	"runtime"
+	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/api/data"
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/banyand/internal/wal"
 	"github.com/apache/skywalking-banyandb/banyand/metadata"
 	"github.com/apache/skywalking-banyandb/banyand/observability"
 	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
 	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/run"
 	resourceSchema "github.com/apache/skywalking-banyandb/pkg/schema"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
 )
 
 var (
@@ -48,19 +57,49 @@ type Service interface {
 	run.Config
 	run.Service
 	Query
+	// MemPartCount returns the number of in-memory parts which aren't flushed to the disk yet.
+	MemPartCount() int
+	// DropSegments removes the segments of a group ending at or before the deadline, and returns the number of them.
+	DropSegments(group string, deadline time.Time) int
+	// Archive writes the flushed data of the groups into the dir, all groups if groups is empty.
+	Archive(dir string, groups []string) ([]*databasev1.SnapshotArchive, error)
+	// Import registers the segments of the groups archived in the dir, which the node doesn't have.
+	Import(dir string, groups []string) ([]*databasev1.SnapshotArchive, error)
+	// SetRecorder makes the service log the writes to the recorder before applying them, it should be called before PreRun.
+	SetRecorder(recorder wal.Recorder)
 }
 
 var _ Service = (*service)(nil)
 
 type service struct {
-	schemaRepo    schemaRepo
-	writeListener bus.MessageListener
-	metadata      metadata.Repo
-	pipeline      queue.Server
-	localPipeline queue.Queue
-	l             *logger.Logger
-	root          string
-	option        option
+	schemaRepo          schemaRepo
+	writeListener       bus.MessageListener
+	rebuildIndex        *rebuildIndexCallback
+	metadata            metadata.Repo
+	pipeline            queue.Server
+	localPipeline       queue.Queue
+	l                   *logger.Logger
+	root                string
+	option              option
+	maxPooledBufferSize run.Bytes
+	maxPooledBlockSize  run.Bytes
+	pooledBuffersBudget run.Bytes
+	// blockMetadataCacheSize is the budget of the block metadata cached by the parts.
+	blockMetadataCacheSize run.Bytes
+	// recentWindowSize is the budget of the recent windows of the hot series, 0 disables them.
+	recentWindowSize   run.Bytes
+	recentWindowSpan   time.Duration
+	recentWindowSeries int
+	queryWorkers       int
+	mergeWorkers       int
+	// mergeMaxWriteRate is the max bytes per second written by the background merges, 0 means unbounded.
+	mergeMaxWriteRate run.Bytes
+	// walSync is the fsync policy of the write-ahead log, which is parsed into the option.
+	walSync  string
+	coldTier coldTierOptions
+	// ioURing reads the blocks of the queries by io_uring, whose ring is closed once the service stops.
+	ioURing bool
+	ring    *fs.IOURing
 }
 
 func (s *service) Stream(metadata *commonv1.Metadata) (Stream, error) {
@@ -75,6 +114,41 @@ func (s *service) LoadGroup(name string) (resourceSchema.Group, bool) {
 	return s.schemaRepo.LoadGroup(name)
 }
 
+func (s *service) MemPartCount() int {
+	allTime := timestamp.NewInclusiveTimeRange(time.Unix(0, 0), time.Unix(0, math.MaxInt64))
+	var count int
+	for _, g := range s.schemaRepo.LoadAllGroups() {
+		tsdb, ok := g.SupplyTSDB().(storage.TSDB[*tsTable, option])
+		if !ok {
+			continue
+		}
+		for _, tw := range tsdb.SelectTSTables(allTime) {
+			if snp := tw.Table().currentSnapshot(); snp != nil {
+				count += snp.memPartCount()
+				snp.decRef()
+			}
+			tw.DecRef()
+		}
+	}
+	return count
+}
+
+func (s *service) DropSegments(group string, deadline time.Time) int {
+	g, ok := s.schemaRepo.LoadGroup(group)
+	if !ok {
+		return 0
+	}
+	tsdb, ok := g.SupplyTSDB().(storage.TSDB[*tsTable, option])
+	if !ok {
+		return 0
+	}
+	return tsdb.DropSegments(deadline)
+}
+
+func (s *service) SetRecorder(recorder wal.Recorder) {
+	s.option.recorder = recorder
+}
+
 func (s *service) FlagSet() *run.FlagSet {
 	flagS := run.NewFlagSet("storage")
 	flagS.StringVar(&s.root, "stream-root-path", "/tmp", "the root path of database")
@@ -82,6 +156,51 @@ func (s *service) FlagSet() *run.FlagSet {
 	flagS.DurationVar(&s.option.elementIndexFlushTimeout, "element-index-flush-timeout", defaultFlushTimeout, "the elementIndex timeout of stream")
 	s.option.mergePolicy = newDefaultMergePolicy()
 	flagS.Uint64Var(&s.option.mergePolicy.maxFanOutSize, "max-fan-out-size", math.MaxUint64, "the upper bound of a single file size after merge")
+	flagS.DurationVar(&s.option.seriesIndex.CommitInterval, "stream-series-index-commit-interval", storage.DefaultSeriesIndexCommitInterval,
+		"the max time the new series wait in memory before they are committed to the series index")
+	flagS.IntVar(&s.option.seriesIndex.MaxBufferedDocs, "stream-series-index-max-buffered-docs", storage.DefaultSeriesIndexMaxBufferedDocs,
+		"the max number of the new series buffered in memory, 0 commits every write to the series index at once")
+	flagS.BoolVar(&s.option.seriesIndex.AsyncFsync, "stream-series-index-async-fsync", true,
+		"sync the series index to the disk in the background instead of on every commit")
+	flagS.Uint64Var(&s.option.blockMinRows, "stream-block-min-rows", defaultBlockMinRows,
+		"the min rows of a block of the wide rows, which grows beyond the block size limit until it holds them")
+	flagS.Uint64Var(&s.option.blockMaxRows, "stream-block-max-rows", defaultBlockMaxRows,
+		"the max rows of a block of the narrow rows, 0 cuts the blocks by the block size limit alone")
+	s.maxPooledBufferSize = defaultMaxPooledBufferSize
+	flagS.VarP(&s.maxPooledBufferSize, "stream-max-pooled-buffer-size", "", "the max capacity of a pooled buffer, the larger ones are released to the runtime")
+	s.maxPooledBlockSize = defaultMaxPooledBlockSize
+	flagS.VarP(&s.maxPooledBlockSize, "stream-max-pooled-block-size", "", "the max capacity of a pooled block or block cursor, the larger ones are released to the runtime")
+	s.blockMetadataCacheSize = defaultBlockMetadataCacheSize
+	flagS.VarP(&s.blockMetadataCacheSize, "stream-block-metadata-cache-size", "",
+		"the max bytes of the block metadata cached by all the parts, the primary blocks are read on demand beyond it")
+	flagS.VarP(&s.recentWindowSize, "stream-recent-window-size", "",
+		"the max bytes of the recent windows kept for the hot series, 0 disables them")
+	flagS.DurationVar(&s.recentWindowSpan, "stream-recent-window-span", defaultRecentWindowSpan,
+		"the time span of the freshest elements a recent window keeps")
+	flagS.IntVar(&s.recentWindowSeries, "stream-recent-window-series", defaultRecentWindowSeries,
+		"the number of the most queried series which keep the recent windows")
+	s.pooledBuffersBudget = defaultPooledBuffersBudget
+	flagS.VarP(&s.pooledBuffersBudget, "stream-pooled-buffers-budget", "", "the max bytes retained by all the pooled buffers, 0 means unbounded")
+	flagS.IntVar(&s.queryWorkers, "stream-query-workers", runtime.GOMAXPROCS(0),
+		"the max number of goroutines a query decodes the blocks in")
+	flagS.IntVar(&s.mergeWorkers, "stream-merge-workers", defaultMergeWorkers(),
+		"the max number of the background merges of the parts running at a time")
+	flagS.VarP(&s.mergeMaxWriteRate, "stream-merge-max-write-rate", "",
+		"the max bytes per second written by the background merges, which are delayed beyond it, 0 means unbounded")
+	flagS.BoolVar(&s.option.wal.enabled, "stream-wal", false,
+		"log the elements to the write-ahead log before acknowledging them, which are replayed after a crash. "+
+			"It's skipped if wal-root is set, whose WALs log the writes instead")
+	flagS.StringVar(&s.walSync, "stream-wal-sync", "interval",
+		"when the write-ahead log is synced to the disk: always, interval or never")
+	flagS.DurationVar(&s.option.wal.syncInterval, "stream-wal-sync-interval", defaultWALSyncInterval,
+		"the interval the write-ahead log is synced to the disk in if stream-wal-sync is interval")
+	flagS.BoolVar(&s.option.ioOpts.Direct, "stream-direct-io", false,
+		"read and write the parts of the merges and the flushes with O_DIRECT, bypassing the page cache")
+	flagS.BoolVar(&s.option.ioOpts.DropCache, "stream-drop-cache", false,
+		"drop the pages of the parts read and written by the merges and the flushes, which would evict the ones the queries depend on")
+	flagS.BoolVar(&s.ioURing, "stream-io-uring", false,
+		"read the timestamps, the elementIDs and the tags of a block in a batch by io_uring on linux, which falls back to pread if it's unavailable")
+	s.coldTier.addFlags(flagS)
 	return flagS
 }
 
@@ -89,7 +208,29 @@ func (s *service) Validate() error {
 	if s.root == "" {
 		return errEmptyRootPath
 	}
-	return nil
+	if s.queryWorkers < 1 {
+		return errors.New("stream-query-workers must be positive")
+	}
+	if s.mergeWorkers < 1 {
+		return errors.New("stream-merge-workers must be positive")
+	}
+	if s.option.blockMaxRows > 0 && s.option.blockMinRows > s.option.blockMaxRows {
+		return errors.New("stream-block-min-rows mustn't exceed stream-block-max-rows")
+	}
+	if s.recentWindowSize > 0 && (s.recentWindowSpan <= 0 || s.recentWindowSeries < 1) {
+		return errors.New("stream-recent-window-span and stream-recent-window-series must be positive")
+	}
+	if s.option.seriesIndex.MaxBufferedDocs > 0 && s.option.seriesIndex.CommitInterval <= 0 {
+		return errors.New("stream-series-index-commit-interval must be positive to buffer the series")
+	}
+	var err error
+	if s.option.wal.sync, err = wal.ParseSyncPolicy(s.walSync); err != nil {
+		return err
+	}
+	if s.option.wal.sync == wal.SyncInterval && s.option.wal.syncInterval <= 0 {
+		return errors.New("stream-wal-sync-interval must be positive")
+	}
+	return s.coldTier.validate()
 }
 
 func (s *service) Name() string {
@@ -100,19 +241,87 @@ func (s *service) Role() databasev1.Role {
 	return databasev1.Role_ROLE_DATA
 }
 
-func (s *service) PreRun(_ context.Context) error {
+func (s *service) PreRun(ctx context.Context) error {
 	s.l = logger.GetLogger(s.Name())
+	if s.option.recorder != nil && !s.option.recorder.Enabled() {
+		s.option.recorder = nil
+	}
+	if s.option.recorder != nil && s.option.wal.enabled {
+		// the WALs of the groups log and replay the writes already, syncing another log would only slow them down
+		s.l.Warn().Msg("stream-wal is skipped, since the writes are logged by the WALs of the groups")
+		s.option.wal.enabled = false
+	}
 	path := path.Join(s.root, s.Name())
 	observability.UpdatePath(path)
+	observability.UpdateGroupRoot(path)
+	if s.coldTier.enabled() {
+		val := ctx.Value(common.ContextNodeKey)
+		if val == nil {
+			return errors.New("node id is empty")
+		}
+		if s.coldTier.cachePath == "" {
+			s.coldTier.cachePath = filepath.Join(s.root, "cold-cache")
+		}
+		cold, err := s.coldTier.open(fs.NewLocalFileSystem(), path, val.(common.Node).NodeID)
+		if err != nil {
+			return err
+		}
+		s.option.cold = cold
+		s.l.Info().Str("dest", s.coldTier.store.Dest).Dur("age", s.coldTier.age).Msg("the cold tier is enabled")
+	}
+	if s.ioURing {
+		ring, err := fs.NewIOURing(runtime.GOMAXPROCS(0), ioURingEntries)
+		if err != nil {
+			s.l.Warn().Err(err).Msg("io_uring is unavailable, the blocks are read by pread")
+		} else {
+			s.ring = ring
+			s.option.fileSystem = fs.NewLocalFileSystemWithIOURing(s.l, ring)
+		}
+	}
+	bigValueCapacity.SetMax(int64(s.maxPooledBufferSize))
+	bigValuePool.SetBudget(int64(s.pooledBuffersBudget))
+	blockCapacity.SetMax(int64(s.maxPooledBlockSize))
+	blockCursorCapacity.SetMax(int64(s.maxPooledBlockSize))
+	queryWorkers.Store(int64(s.queryWorkers))
+	mergeWorkers = newMergeScheduler(s.mergeWorkers, uint64(s.mergeMaxWriteRate))
+	blockMetadataCaches.setBudget(int64(s.blockMetadataCacheSize))
+	watchdog.OnEvictCaches(blockMetadataCaches.evictAll)
+	recentWindows.configure(int64(s.recentWindowSize), s.recentWindowSpan, s.recentWindowSeries)
+	watchdog.OnEvictCaches(recentWindows.evictAll)
 	s.localPipeline = queue.Local()
 	s.schemaRepo = newSchemaRepo(path, s)
 	// run a serial watcher
 
-	s.writeListener = setUpWriteCallback(s.l, &s.schemaRepo)
+	s.writeListener = setUpWriteCallback(s.l, &s.schemaRepo, s.option.recorder)
 	err := s.pipeline.Subscribe(data.TopicStreamWrite, s.writeListener)
 	if err != nil {
 		return err
 	}
+	if err = s.pipeline.Subscribe(data.TopicStreamPurge, setUpPurgeCallback(s.l, &s.schemaRepo)); err != nil {
+		return err
+	}
+	if err = s.pipeline.Subscribe(data.TopicStreamDelete, setUpDeleteCallback(s.l, &s.schemaRepo)); err != nil {
+		return err
+	}
+	if err = s.pipeline.Subscribe(data.TopicStreamTopNSeries, setUpTopNSeriesCallback(s.l, &s.schemaRepo)); err != nil {
+		return err
+	}
+	if err = s.pipeline.Subscribe(data.TopicStreamVerifyIndex, setUpVerifyIndexCallback(s.l, &s.schemaRepo)); err != nil {
+		return err
+	}
+	if err = s.pipeline.Subscribe(data.TopicStreamSearchSeries, setUpSearchSeriesCallback(s.l, &s.schemaRepo)); err != nil {
+		return err
+	}
+	if err = s.pipeline.Subscribe(data.TopicStreamTagValues, setUpTagValuesCallback(s.l, &s.schemaRepo)); err != nil {
+		return err
+	}
+	if err = s.pipeline.Subscribe(data.TopicStreamChanges, setUpChangesCallback(s.l, &s.schemaRepo)); err != nil {
+		return err
+	}
+	s.rebuildIndex = setUpRebuildIndexCallback(s.l, &s.schemaRepo)
+	if err = s.pipeline.Subscribe(data.TopicStreamRebuildIndex, s.rebuildIndex); err != nil {
+		return err
+	}
 	return s.localPipeline.Subscribe(data.TopicStreamWrite, s.writeListener)
 }
 
@@ -122,7 +331,15 @@ func (s *service) Serve() run.StopNotify {
 
 func (s *service) GracefulStop() {
 	s.localPipeline.GracefulStop()
+	if s.rebuildIndex != nil {
+		s.rebuildIndex.stop()
+	}
 	s.schemaRepo.Close()
+	if s.ring != nil {
+		if err := s.ring.Close(); err != nil {
+			s.l.Warn().Err(err).Msg("cannot close io_uring")
+		}
+	}
 }
 
 // NewService returns a new service.
@@ -20,6 +20,7 @@ package stream
 import (
 	"errors"
 	"fmt"
+	"math"
 	"path/filepath"
 	"sync/atomic"
 )
@@ -65,6 +66,27 @@ func (s *snapshot) getParts(dst []*part, minTimestamp, maxTimestamp int64) ([]*p
 	return dst, count
 }
 
+func (s *snapshot) memPartCount() int {
+	var count int
+	for _, p := range s.parts {
+		if p.mp != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// minPartID returns the smallest ID of the parts, which is math.MaxUint64 if there is no part.
+func (s *snapshot) minPartID() uint64 {
+	result := uint64(math.MaxUint64)
+	for _, p := range s.parts {
+		if p.ID() < result {
+			result = p.ID()
+		}
+	}
+	return result
+}
+
 func (s *snapshot) incRef() {
 	atomic.AddInt32(&s.ref, 1)
 }
@@ -26,6 +26,10 @@ import (
 
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/banyand/internal/wal"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/partition"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
@@ -41,12 +45,44 @@ const (
 	maxUncompressedPrimaryBlockSize = 128 * 1024
 
 	defaultFlushTimeout = 5 * time.Second
+	// defaultWALSyncInterval is the interval the write-ahead log is synced in by the interval policy.
+	defaultWALSyncInterval = time.Second
+	// ioURingEntries bounds the reads a ring submits in a syscall.
+	ioURingEntries = 64
 )
 
 type option struct {
 	mergePolicy              *mergePolicy
 	flushTimeout             time.Duration
 	elementIndexFlushTimeout time.Duration
+	// blockMinRows and blockMaxRows bound the rows of a block, the blocks are cut by bytes alone if blockMaxRows is 0.
+	blockMinRows uint64
+	blockMaxRows uint64
+	// blockMaxSize bounds the uncompressed bytes of a block, which is maxUncompressedBlockSize if it's 0.
+	// It's set by the group along with the bounds of the rows and the flushTimeout.
+	blockMaxSize uint64
+	seriesIndex  storage.SeriesIndexOpts
+	// tagCodec compresses the tag values of the blocks, it's set by the group.
+	tagCodec encoding.Codec
+	// compactionPolicy picks the parts to merge, it's set by the group. The mergePolicy applies if it's nil.
+	compactionPolicy compactionPolicy
+	// cold offloads the old parts to the object store, it's nil if the cold tier is disabled.
+	cold *coldTier
+	wal  walOptions
+	// ioOpts hints how the merges and the flushes read and write the files of the parts.
+	ioOpts fs.IOOptions
+	// fileSystem reads the blocks of the parts by io_uring, it replaces the one of the TSDB unless the cold tier is enabled.
+	fileSystem fs.FileSystem
+	// recorder logs the writes before they're applied, it's nil if the writes aren't logged.
+	// The wal of the tables is disabled if it's set, since the writes it logs are replayed by it.
+	recorder wal.Recorder
+}
+
+func (o option) compaction() compactionPolicy {
+	if o.compactionPolicy != nil {
+		return o.compactionPolicy
+	}
+	return o.mergePolicy
 }
 
 // Query allow to retrieve elements in a series of streams.
@@ -63,6 +99,7 @@ type Stream interface {
 	Query(ctx context.Context, opts pbv1.StreamQueryOptions) (pbv1.StreamQueryResult, error)
 	Sort(ctx context.Context, opts pbv1.StreamSortOptions) (pbv1.StreamSortResult, error)
 	Filter(ctx context.Context, opts pbv1.StreamFilterOptions) (pbv1.StreamFilterResult, error)
+	Stats(ctx context.Context, opts pbv1.StreamQueryOptions) (pbv1.StreamScanStats, error)
 }
 
 var _ Stream = (*stream)(nil)
@@ -18,6 +18,8 @@
 package stream
 
 import (
+	"fmt"
+
 	"github.com/apache/skywalking-banyandb/pkg/bytes"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
@@ -26,13 +28,21 @@ import (
 )
 
 type tag struct {
+	// arena allocates the typed view of the values, which is the one of the cursor loading the tag.
+	arena     *columnArena
 	name      string
 	values    [][]byte
+	column    column
 	valueType pbv1.ValueType
+	// bloomFilter makes the block build a bloom filter of the values, which is written to the tag metadata.
+	bloomFilter bool
 }
 
 func (t *tag) reset() {
 	t.name = ""
+	t.bloomFilter = false
+	t.arena = nil
+	t.column.reset()
 
 	values := t.values
 	for i := range values {
@@ -51,19 +61,22 @@ func (t *tag) resizeValues(valuesLen int) [][]byte {
 	return values
 }
 
-func (t *tag) mustWriteTo(ch *tagMetadata, tagWriter *writer) {
+func (t *tag) mustWriteTo(ch *tagMetadata, tagWriter *writer, codec encoding.Codec) {
 	ch.reset()
 
 	ch.name = t.name
 	ch.valueType = t.valueType
+	if t.bloomFilter {
+		ch.filter = newTagBloomFilter(t.valueType, t.values)
+	}
 
 	// TODO: encoding values based on value type
 
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(0)
 	defer bigValuePool.Release(bb)
 
-	// marshal values
-	bb.Buf = encoding.EncodeBytesBlock(bb.Buf[:0], t.values)
+	// marshal values, the codec is recorded in the block for decoding
+	bb.Buf = encoding.EncodeBytesBlockWithCodec(bb.Buf[:0], t.values, codec)
 	ch.size = uint64(len(bb.Buf))
 	if ch.size > maxValuesBlockSize {
 		logger.Panicf("too valuesSize: %d bytes; mustn't exceed %d bytes", ch.size, maxValuesBlockSize)
@@ -72,49 +85,61 @@ func (t *tag) mustWriteTo(ch *tagMetadata, tagWriter *writer) {
 	tagWriter.MustWrite(bb.Buf)
 }
 
-func (t *tag) mustReadValues(decoder *encoding.BytesBlockDecoder, reader fs.Reader, cm tagMetadata, count uint64) {
-	t.name = cm.name
-	t.valueType = cm.valueType
-
-	bb := bigValuePool.Generate()
-	defer bigValuePool.Release(bb)
+func (t *tag) readValues(decoder *encoding.BytesBlockDecoder, reader fs.Reader, cm tagMetadata, count uint64) error {
 	valuesSize := cm.size
 	if valuesSize > maxValuesBlockSize {
-		logger.Panicf("%s: block size cannot exceed %d bytes; got %d bytes", reader.Path(), maxValuesBlockSize, valuesSize)
+		return fmt.Errorf("%s: block size cannot exceed %d bytes; got %d bytes", reader.Path(), maxValuesBlockSize, valuesSize)
 	}
+	bb := bigValuePool.Acquire(int(valuesSize))
+	defer bigValuePool.Release(bb)
 	bb.Buf = bytes.ResizeOver(bb.Buf, int(valuesSize))
-	fs.MustReadData(reader, int64(cm.offset), bb.Buf)
+	if err := fs.ReadData(reader, int64(cm.offset), bb.Buf); err != nil {
+		return fmt.Errorf("cannot read values of tag %q: %w", cm.name, err)
+	}
+	return t.decodeValues(decoder, bb.Buf, cm, count, reader.Path())
+}
+
+// decodeValues decodes the values of the tag from data, which are read from the file at path.
+func (t *tag) decodeValues(decoder *encoding.BytesBlockDecoder, data []byte, cm tagMetadata, count uint64, path string) error {
+	t.name = cm.name
+	t.valueType = cm.valueType
+	t.bloomFilter = cm.filter != nil
 	var err error
-	t.values, err = decoder.Decode(t.values[:0], bb.Buf, count)
+	t.values, err = decoder.Decode(t.values[:0], data, count)
 	if err != nil {
-		logger.Panicf("%s: cannot decode values: %v", reader.Path(), err)
+		return fmt.Errorf("%s: cannot decode values of tag %q: %w", path, cm.name, err)
 	}
+	return nil
 }
 
-func (t *tag) mustSeqReadValues(decoder *encoding.BytesBlockDecoder, reader *seqReader, cm tagMetadata, count uint64) {
+func (t *tag) seqReadValues(decoder *encoding.BytesBlockDecoder, reader *seqReader, cm tagMetadata, count uint64) error {
 	t.name = cm.name
 	t.valueType = cm.valueType
-	if cm.offset != reader.bytesRead {
-		logger.Panicf("%s: offset mismatch: %d vs %d", reader.Path(), cm.offset, reader.bytesRead)
+	t.bloomFilter = cm.filter != nil
+	if err := reader.align(cm.offset); err != nil {
+		return err
 	}
 	valuesSize := cm.size
 	if valuesSize > maxValuesBlockSize {
-		logger.Panicf("%s: block size cannot exceed %d bytes; got %d bytes", reader.Path(), maxValuesBlockSize, valuesSize)
+		return fmt.Errorf("%s: block size cannot exceed %d bytes; got %d bytes", reader.Path(), maxValuesBlockSize, valuesSize)
 	}
 
-	bb := bigValuePool.Generate()
+	bb := bigValuePool.Acquire(int(valuesSize))
 	defer bigValuePool.Release(bb)
 
 	bb.Buf = bytes.ResizeOver(bb.Buf, int(valuesSize))
-	reader.mustReadFull(bb.Buf)
+	if err := reader.readFull(bb.Buf); err != nil {
+		return err
+	}
 	var err error
 	t.values, err = decoder.Decode(t.values[:0], bb.Buf, count)
 	if err != nil {
-		logger.Panicf("%s: cannot decode values: %v", reader.Path(), err)
+		return fmt.Errorf("%s: cannot decode values of tag %q: %w", reader.Path(), cm.name, err)
 	}
+	return nil
 }
 
-var bigValuePool bytes.BufferPool
+var bigValuePool = bytes.NewBufferManager(bigValueCapacity, bufferRetainedGauge)
 
 type tagFamily struct {
 	name string
@@ -131,6 +156,33 @@ func (tf *tagFamily) reset() {
 	tf.tags = tags[:0]
 }
 
+// dropDuplicatedTags keeps the first of the tags sharing a name and returns the number of the dropped ones.
+// The dropped tags are swapped to the end instead of being overwritten, so that the values aren't shared.
+func (tf *tagFamily) dropDuplicatedTags() int {
+	tags := tf.tags
+	n := 0
+	for i := range tags {
+		duplicated := false
+		for j := 0; j < n; j++ {
+			if tags[j].name == tags[i].name {
+				duplicated = true
+				break
+			}
+		}
+		if duplicated {
+			continue
+		}
+		tags[n], tags[i] = tags[i], tags[n]
+		n++
+	}
+	dropped := len(tags) - n
+	for i := n; i < len(tags); i++ {
+		tags[i].reset()
+	}
+	tf.tags = tags[:n]
+	return dropped
+}
+
 func (tf *tagFamily) resizeTags(tagsLen int) []tag {
 	tags := tf.tags
 	if n := tagsLen - cap(tags); n > 0 {
@@ -0,0 +1,145 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/pkg/filter"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+var bloomFilterSkippedBlocksCount = observability.NewMeterProvider(observability.RootScope.SubScope("stream").SubScope("bloom_filter")).
+	Counter("skipped_blocks_total")
+
+// newTagBloomFilter returns the bloom filter of the values of a tag.
+// The elements of the arrays are added in the form they're stored, instead of the arrays.
+func newTagBloomFilter(valueType pbv1.ValueType, values [][]byte) *filter.BloomFilter {
+	bf := filter.NewBloomFilter(len(values))
+	for _, v := range values {
+		switch valueType {
+		case pbv1.ValueTypeInt64Arr:
+			for ; len(v) >= 8; v = v[8:] {
+				bf.Add(v[:8])
+			}
+		case pbv1.ValueTypeStrArr:
+			for len(v) > 0 {
+				elem, rest, _, err := nextVarArrayElement(v)
+				if err != nil {
+					break
+				}
+				bf.Add(elem)
+				v = rest
+			}
+		default:
+			bf.Add(v)
+		}
+	}
+	return bf
+}
+
+// blockSkip tells what skips a block.
+type blockSkip int
+
+const (
+	blockSkipNone blockSkip = iota
+	blockSkipByStats
+	blockSkipByBloomFilter
+)
+
+// mightMatch reports whether some rows of the block might match the predicates, by the statistics of the tags
+// in the block metadata, then by the bloom filters of the tags. It reads the metadata of the tag families
+// having the predicates instead of the values of the tags, so that the blocks which can't match are skipped
+// at the cost of the small reads.
+func (bc *blockCursor) mightMatch() (bool, error) {
+	skip, err := bc.skipBy(bc.tagPredicates)
+	switch skip {
+	case blockSkipByStats:
+		statsSkippedBlocksCount.Inc(1)
+	case blockSkipByBloomFilter:
+		bloomFilterSkippedBlocksCount.Inc(1)
+	}
+	return err == nil && skip == blockSkipNone, err
+}
+
+// skipBy returns what skips the block by the predicates, which is blockSkipNone if some rows might match.
+func (bc *blockCursor) skipBy(predicates []tagPredicate) (blockSkip, error) {
+	var tfm *tagFamilyMetadata
+	defer func() {
+		if tfm != nil {
+			releaseTagFamilyMetadata(tfm)
+		}
+	}()
+	for i := range predicates {
+		if !predicates[i].mightMatchStats(bc.bm.tagStats) {
+			return blockSkipByStats, nil
+		}
+	}
+	read := make(map[string]struct{})
+	for i := range predicates {
+		family := predicates[i].family
+		if _, ok := read[family]; ok || !predicates[i].filtered() {
+			continue
+		}
+		read[family] = struct{}{}
+		block, ok := bc.bm.tagFamilies[family]
+		if !ok {
+			continue
+		}
+		if tfm == nil {
+			tfm = generateTagFamilyMetadata()
+		} else {
+			tfm.reset()
+		}
+		if err := readTagFamilyMetadata(tfm, block, bc.p.tagFamilyMetadata[family]); err != nil {
+			return blockSkipNone, err
+		}
+		for j := range predicates {
+			if tp := &predicates[j]; tp.family == family && tp.filtered() && !tp.mightMatch(tfm) {
+				return blockSkipByBloomFilter, nil
+			}
+		}
+	}
+	return blockSkipNone, nil
+}
+
+// filtered reports whether the predicate is evaluated by the bloom filters, which tell the elements absent only.
+func (tp *tagPredicate) filtered() bool {
+	return !tp.not && tp.op == modelv1.Condition_BINARY_OP_UNSPECIFIED
+}
+
+// mightMatch reports whether the bloom filter of the tag in the tag family might contain all the elements.
+// It's true if the tag has no bloom filter, or its type differs, then the rows are left to filterRows.
+func (tp *tagPredicate) mightMatch(tfm *tagFamilyMetadata) bool {
+	for i := range tfm.tagMetadata {
+		tm := &tfm.tagMetadata[i]
+		if tm.name != tp.name {
+			continue
+		}
+		if tm.filter == nil || tm.valueType != tp.valueType {
+			return true
+		}
+		for _, elem := range tp.elements {
+			if !tm.filter.MightContain(elem) {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
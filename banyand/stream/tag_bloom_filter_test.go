@@ -0,0 +1,191 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+// generateTraceEs generates the elements of the batch, whose traceIDs are "trace-<batch>-<i>",
+// codes are batch*100+i, and tags contain "batch-<batch>".
+func generateTraceEs(batch, count int, bloomFilter bool) *elements {
+	es := &elements{}
+	for i := 0; i < count; i++ {
+		es.seriesIDs = append(es.seriesIDs, 1)
+		es.timestamps = append(es.timestamps, int64(batch*count+i+1))
+		es.elementIDs = append(es.elementIDs, fmt.Sprintf("e-%d-%d", batch, i))
+		es.tagFamilies = append(es.tagFamilies, []tagValues{{tag: "trace", values: []*tagValue{
+			{tag: "traceID", valueType: pbv1.ValueTypeStr, value: []byte(fmt.Sprintf("trace-%d-%d", batch, i)), bloomFilter: bloomFilter},
+			{tag: "code", valueType: pbv1.ValueTypeInt64, value: convert.Int64ToBytes(int64(batch*100 + i)), bloomFilter: bloomFilter},
+			{tag: "tags", valueType: pbv1.ValueTypeStrArr, valueArr: [][]byte{[]byte(fmt.Sprintf("batch-%d", batch))}, bloomFilter: bloomFilter},
+		}}})
+	}
+	return es
+}
+
+func Test_queryResult_bloomFilter(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	tst, err := newTSTable(fs.NewLocalFileSystem(), tmpPath, common.Position{},
+		logger.GetLogger("test"), timestamp.TimeRange{}, option{flushTimeout: time.Hour, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer tst.Close()
+	// every batch is a block, and the block of the last one is written without the bloom filters
+	const batches, count = 4, 10
+	for batch := 0; batch < batches; batch++ {
+		require.NoError(t, tst.addElements(generateTraceEs(batch, count, batch < batches-1)))
+	}
+
+	str := func(s string) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: s}}}
+	}
+	tests := []struct {
+		name       string
+		predicates []pbv1.TagPredicate
+		want       []string
+		// wantLen is the number of the rows left if want is nil, which are evaluated by the query plan later
+		wantLen int
+		// wantMightMatch is the number of the blocks which might match by the bloom filters
+		wantMightMatch int
+	}{
+		{
+			name:           "equal",
+			predicates:     []pbv1.TagPredicate{{Name: "traceID", Value: str("trace-1-3"), Equal: true}},
+			want:           []string{"e-1-3"},
+			wantMightMatch: 2,
+		},
+		{
+			name: "equal int64",
+			predicates: []pbv1.TagPredicate{{Name: "code", Equal: true, Value: &modelv1.TagValue{
+				Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: 205}},
+			}}},
+			want:           []string{"e-2-5"},
+			wantMightMatch: 2,
+		},
+		{
+			name:           "equal to the block without bloom filters",
+			predicates:     []pbv1.TagPredicate{{Name: "traceID", Value: str("trace-3-0"), Equal: true}},
+			want:           []string{"e-3-0"},
+			wantMightMatch: 1,
+		},
+		{
+			name:           "contains",
+			predicates:     []pbv1.TagPredicate{{Name: "tags", Value: str("batch-0")}},
+			want:           []string{"e-0-0", "e-0-1", "e-0-2", "e-0-3", "e-0-4", "e-0-5", "e-0-6", "e-0-7", "e-0-8", "e-0-9"},
+			wantMightMatch: 2,
+		},
+		{
+			name:           "not contains",
+			predicates:     []pbv1.TagPredicate{{Name: "tags", Value: str("batch-0"), Not: true}},
+			wantLen:        (batches - 1) * count,
+			wantMightMatch: batches,
+		},
+		{
+			name:           "mismatched type",
+			predicates:     []pbv1.TagPredicate{{Name: "code", Value: str("205"), Equal: true}},
+			wantLen:        batches * count,
+			wantMightMatch: batches,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.predicates {
+				tt.predicates[i].Family = "trace"
+			}
+			qo := queryOptions{minTimestamp: 1, maxTimestamp: batches * count, tagPredicates: newTagPredicates(tt.predicates)}
+			qo.TagProjection = []pbv1.TagProjection{{Family: "trace", Names: []string{"traceID", "code", "tags"}}}
+			qr := queryResult{orderByTS: true, ascTS: true}
+			defer qr.Release()
+			require.NoError(t, qr.selectBlocks([]*tsTable{tst}, []common.SeriesID{1}, qo))
+			require.Len(t, qr.data, batches)
+			var mightMatch int
+			for _, bc := range qr.data {
+				ok, err := bc.mightMatch()
+				require.NoError(t, err)
+				if ok {
+					mightMatch++
+				}
+			}
+			assert.Equal(t, tt.wantMightMatch, mightMatch)
+
+			var got []string
+			for r := qr.Pull(); r != nil; r = qr.Pull() {
+				got = append(got, r.ElementIDs...)
+			}
+			if tt.want == nil {
+				assert.Len(t, got, tt.wantLen)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_mergeParts_bloomFilter(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	var pp []*partWrapper
+	defer func() {
+		for _, pw := range pp {
+			pw.decRef()
+		}
+	}()
+	for batch, bloomFilter := range []bool{true, false} {
+		mp := generateMemPart()
+		mp.mustInitFromElements(generateTraceEs(batch, 10, bloomFilter))
+		pp = append(pp, newPartWrapper(mp, openMemPart(mp)))
+	}
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	p, skipped, err := mergeParts(fs.NewLocalFileSystem(), closeCh, pp, 1, tmpPath, nil, encoding.CodecZSTD, fs.IOOptions{})
+	require.NoError(t, err)
+	require.Empty(t, skipped)
+	defer p.decRef()
+
+	pmi := &partMergeIter{}
+	pmi.mustInitFromPart(p.p)
+	require.True(t, pmi.nextBlockMetadata())
+	// the merged block has the bloom filters of the values of both parts
+	tfm := generateTagFamilyMetadata()
+	defer releaseTagFamilyMetadata(tfm)
+	require.NoError(t, readTagFamilyMetadata(tfm, pmi.block.bm.tagFamilies["trace"], p.p.tagFamilyMetadata["trace"]))
+	for _, tm := range tfm.tagMetadata {
+		require.NotNil(t, tm.filter, tm.name)
+	}
+	tp := newTagPredicates([]pbv1.TagPredicate{{Family: "trace", Name: "traceID", Equal: true, Value: &modelv1.TagValue{
+		Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: "trace-1-9"}},
+	}}})
+	assert.True(t, tp[0].mightMatch(tfm))
+	tp[0].elements[0] = []byte("trace-2-0")
+	assert.False(t, tp[0].mightMatch(tfm))
+}
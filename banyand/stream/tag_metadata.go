@@ -23,11 +23,16 @@ import (
 
 	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/filter"
+	"github.com/apache/skywalking-banyandb/pkg/intern"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 )
 
 type tagMetadata struct {
-	name string
+	// filter is the bloom filter of the values, it's nil if the tag has none.
+	// It's marshaled by the tagFamilyMetadata.
+	filter *filter.BloomFilter
+	name   string
 	dataBlock
 	valueType pbv1.ValueType
 }
@@ -35,12 +40,14 @@ type tagMetadata struct {
 func (tm *tagMetadata) reset() {
 	tm.name = ""
 	tm.valueType = 0
+	tm.filter = nil
 	tm.dataBlock.reset()
 }
 
 func (tm *tagMetadata) copyFrom(src *tagMetadata) {
 	tm.name = src.name
 	tm.valueType = src.valueType
+	tm.filter = src.filter
 	tm.dataBlock.copyFrom(&src.dataBlock)
 }
 
@@ -56,7 +63,7 @@ func (tm *tagMetadata) unmarshal(src []byte) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("cannot unmarshal tagMetadata.name: %w", err)
 	}
-	tm.name = string(nameBytes)
+	tm.name = intern.Bytes(nameBytes)
 	if len(src) < 1 {
 		return nil, fmt.Errorf("cannot unmarshal tagMetadata.valueType: src is too short")
 	}
@@ -99,11 +106,27 @@ func (tfm *tagFamilyMetadata) resizeTagMetadata(tagMetadataLen int) []tagMetadat
 	return tms
 }
 
+// marshal appends the metadata of the tags, followed by the bloom filters of the tags having them.
+// The parts written before the bloom filters end with the metadata of the tags.
 func (tfm *tagFamilyMetadata) marshal(dst []byte) []byte {
 	tms := tfm.tagMetadata
 	dst = encoding.VarUint64ToBytes(dst, uint64(len(tms)))
+	var filters uint64
 	for i := range tms {
 		dst = tms[i].marshal(dst)
+		if tms[i].filter != nil {
+			filters++
+		}
+	}
+	if filters == 0 {
+		return dst
+	}
+	dst = encoding.VarUint64ToBytes(dst, filters)
+	for i := range tms {
+		if tms[i].filter != nil {
+			dst = encoding.VarUint64ToBytes(dst, uint64(i))
+			dst = tms[i].filter.Marshal(dst)
+		}
 	}
 	return dst
 }
@@ -123,6 +146,26 @@ func (tfm *tagFamilyMetadata) unmarshal(src []byte) error {
 			return fmt.Errorf("cannot unmarshal tagMetadata %d: %w", i, err)
 		}
 	}
+	if len(src) == 0 {
+		return nil
+	}
+	src, filters, err := encoding.BytesToVarUint64(src)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal the count of the bloom filters: %w", err)
+	}
+	for ; filters > 0; filters-- {
+		var i uint64
+		if src, i, err = encoding.BytesToVarUint64(src); err != nil {
+			return fmt.Errorf("cannot unmarshal the tag of a bloom filter: %w", err)
+		}
+		if i >= uint64(len(tms)) {
+			return fmt.Errorf("the bloom filter of the tag %d is out of %d tags", i, len(tms))
+		}
+		tms[i].filter = &filter.BloomFilter{}
+		if src, err = tms[i].filter.Unmarshal(src); err != nil {
+			return fmt.Errorf("cannot unmarshal the bloom filter of tag %q: %w", tms[i].name, err)
+		}
+	}
 	return nil
 }
 
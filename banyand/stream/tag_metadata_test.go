@@ -151,6 +151,24 @@ func Test_tagFamilyMetadata_marshalUnmarshal(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "With bloom filters",
+			original: &tagFamilyMetadata{
+				tagMetadata: []tagMetadata{
+					{
+						name:      "test1",
+						valueType: pbv1.ValueTypeStr,
+						dataBlock: dataBlock{offset: 1, size: 10},
+					},
+					{
+						name:      "test2",
+						valueType: pbv1.ValueTypeStr,
+						dataBlock: dataBlock{offset: 2, size: 20},
+						filter:    newTagBloomFilter(pbv1.ValueTypeStr, [][]byte{[]byte("a"), nil}),
+					},
+				},
+			},
+		},
 		{
 			name:     "Empty tagMetadata",
 			original: &tagFamilyMetadata{},
@@ -0,0 +1,227 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"bytes"
+	"strings"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+// tagPredicate is a pbv1.TagPredicate whose elements are encoded as they're stored in the blocks,
+// so that it's evaluated on the values of the array tag without decoding them.
+type tagPredicate struct {
+	family   string
+	name     string
+	elements [][]byte
+	// valueType is the type of the arrays which may contain the elements,
+	// or the type of the tag equal to the only element.
+	valueType pbv1.ValueType
+	// op is the comparison of the tag to the only element, which is BINARY_OP_UNSPECIFIED for the other predicates.
+	op    modelv1.Condition_BinaryOp
+	not   bool
+	equal bool
+}
+
+func newTagPredicates(tps []pbv1.TagPredicate) []tagPredicate {
+	if len(tps) == 0 {
+		return nil
+	}
+	predicates := make([]tagPredicate, 0, len(tps))
+	for _, tp := range tps {
+		p := tagPredicate{family: tp.Family, name: tp.Name, not: tp.Not}
+		if tp.Equal || tp.RangeOp != modelv1.Condition_BINARY_OP_UNSPECIFIED {
+			p.not, p.equal, p.op = false, tp.Equal, tp.RangeOp
+			switch v := tp.Value.GetValue().(type) {
+			case *modelv1.TagValue_Str:
+				p.valueType = pbv1.ValueTypeStr
+				p.elements = append(p.elements, []byte(v.Str.GetValue()))
+			case *modelv1.TagValue_Int:
+				p.valueType = pbv1.ValueTypeInt64
+				p.elements = append(p.elements, convert.Int64ToBytes(v.Int.GetValue()))
+			default:
+				// the equalities and the comparisons to the other values are left to the query plan
+				continue
+			}
+			predicates = append(predicates, p)
+			continue
+		}
+		switch v := tp.Value.GetValue().(type) {
+		case *modelv1.TagValue_Str:
+			p.valueType = pbv1.ValueTypeStrArr
+			p.elements = append(p.elements, escapeVarArrayElement(v.Str.GetValue()))
+		case *modelv1.TagValue_StrArray:
+			p.valueType = pbv1.ValueTypeStrArr
+			for _, s := range v.StrArray.GetValue() {
+				p.elements = append(p.elements, escapeVarArrayElement(s))
+			}
+		case *modelv1.TagValue_Int:
+			p.valueType = pbv1.ValueTypeInt64Arr
+			p.elements = append(p.elements, convert.Int64ToBytes(v.Int.GetValue()))
+		case *modelv1.TagValue_IntArray:
+			p.valueType = pbv1.ValueTypeInt64Arr
+			for _, i := range v.IntArray.GetValue() {
+				p.elements = append(p.elements, convert.Int64ToBytes(i))
+			}
+		}
+		// the arrays contain no other value, whose valueType is ValueTypeUnknown
+		predicates = append(predicates, p)
+	}
+	return predicates
+}
+
+// scalar reports whether the predicate is an equality or a comparison of a string or int64 tag.
+func (tp *tagPredicate) scalar() bool {
+	return tp.equal || tp.op != modelv1.Condition_BINARY_OP_UNSPECIFIED
+}
+
+// escapeVarArrayElement returns the element of a string array as it's stored, without the delimiter.
+func escapeVarArrayElement(s string) []byte {
+	if !strings.ContainsAny(s, string([]byte{entityDelimiter, escape})) {
+		return []byte(s)
+	}
+	dst := marshalVarArray(nil, []byte(s))
+	return dst[:len(dst)-1]
+}
+
+// match reports whether the value at idx of the array tag matches the predicate.
+// A tag missing from the block is a null, which contains nothing.
+func (tp *tagPredicate) match(t *tag, idx int) bool {
+	if tp.equal {
+		return bytes.Equal(t.values[idx], tp.elements[0])
+	}
+	if tp.op != modelv1.Condition_BINARY_OP_UNSPECIFIED {
+		// a null can't be told from an empty string, which is left to the query plan
+		return t.values[idx] == nil || compareValue(tp.op, t.values[idx], tp.elements[0])
+	}
+	return tp.containsAll(t, idx) != tp.not
+}
+
+func (tp *tagPredicate) containsAll(t *tag, idx int) bool {
+	if t.values == nil || t.valueType != tp.valueType {
+		return false
+	}
+	value := t.values[idx]
+	for _, elem := range tp.elements {
+		if !containsElement(t.valueType, value, elem) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsElement reports whether the encoded array contains the element, it stops at the first one equal.
+func containsElement(valueType pbv1.ValueType, value, elem []byte) bool {
+	if valueType == pbv1.ValueTypeInt64Arr {
+		for ; len(value) >= 8; value = value[8:] {
+			if bytes.Equal(value[:8], elem) {
+				return true
+			}
+		}
+		return false
+	}
+	for len(value) > 0 {
+		e, rest, _, err := nextVarArrayElement(value)
+		if err != nil {
+			return false
+		}
+		if bytes.Equal(e, elem) {
+			return true
+		}
+		value = rest
+	}
+	return false
+}
+
+// filterRows drops the rows which don't match all the predicates before the tags are decoded.
+// The predicates on the tags not loaded, or not arrays, are left to the query plan,
+// except the equalities and the comparisons on the tags of their types.
+// It returns false if no row is left.
+func (bc *blockCursor) filterRows(predicates []tagPredicate) bool {
+	tags := make([]*tag, len(predicates))
+	for i := range predicates {
+		for j := range bc.tagFamilies {
+			if bc.tagFamilies[j].name != predicates[i].family {
+				continue
+			}
+			t := findTag(bc.tagFamilies[j].tags, predicates[i].name)
+			if t == nil {
+				continue
+			}
+			if predicates[i].scalar() {
+				if t.values != nil && t.valueType == predicates[i].valueType {
+					tags[i] = t
+				}
+				continue
+			}
+			if t.values == nil || t.valueType.ElementType() != pbv1.ValueTypeUnknown {
+				tags[i] = t
+			}
+		}
+	}
+	return retainRows(&bc.timestamps, &bc.elementIDs, bc.tagFamilies, func(idx int) bool {
+		return matchRow(predicates, tags, idx)
+	}) > 0
+}
+
+// retainRows keeps the rows for which keep returns true in place, and returns the number of them.
+// The values of the tags not loaded, which are nil, are left as they are.
+func retainRows(timestamps *[]int64, elementIDs *[]string, tagFamilies []tagFamily, keep func(idx int) bool) int {
+	n := 0
+	for idx := range *timestamps {
+		if !keep(idx) {
+			continue
+		}
+		if n != idx {
+			(*timestamps)[n] = (*timestamps)[idx]
+			(*elementIDs)[n] = (*elementIDs)[idx]
+			for i := range tagFamilies {
+				for j := range tagFamilies[i].tags {
+					if values := tagFamilies[i].tags[j].values; values != nil {
+						values[n] = values[idx]
+					}
+				}
+			}
+		}
+		n++
+	}
+	clear((*elementIDs)[n:])
+	*timestamps = (*timestamps)[:n]
+	*elementIDs = (*elementIDs)[:n]
+	for i := range tagFamilies {
+		for j := range tagFamilies[i].tags {
+			if t := &tagFamilies[i].tags[j]; t.values != nil {
+				clear(t.values[n:])
+				t.values = t.values[:n]
+			}
+		}
+	}
+	return n
+}
+
+func matchRow(predicates []tagPredicate, tags []*tag, idx int) bool {
+	for i := range predicates {
+		if tags[i] != nil && !predicates[i].match(tags[i], idx) {
+			return false
+		}
+	}
+	return true
+}
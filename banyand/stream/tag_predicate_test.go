@@ -0,0 +1,220 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+// arrayRows are the string and int64 arrays of the elements e1 to e6, a nil array is a null.
+var arrayRows = []struct {
+	strs []string
+	ints []int64
+}{
+	{strs: []string{"a", "b"}, ints: []int64{1, 2}},
+	{strs: []string{}, ints: []int64{}},
+	{},
+	{strs: []string{"a"}, ints: []int64{2}},
+	{strs: []string{"b|c", ""}, ints: []int64{3}},
+	{strs: []string{"c\\"}, ints: []int64{1}},
+}
+
+// generateArrayEs generates the elements of arrayRows in [from, to).
+func generateArrayEs(from, to int) *elements {
+	es := &elements{}
+	for i := from; i < to; i++ {
+		strArr := &tagValue{tag: "strArr", valueType: pbv1.ValueTypeStrArr}
+		if arrayRows[i].strs != nil {
+			strArr.valueArr = make([][]byte, 0, len(arrayRows[i].strs))
+			for _, s := range arrayRows[i].strs {
+				strArr.valueArr = append(strArr.valueArr, []byte(s))
+			}
+		}
+		intArr := &tagValue{tag: "intArr", valueType: pbv1.ValueTypeInt64Arr}
+		if arrayRows[i].ints != nil {
+			intArr.valueArr = make([][]byte, 0, len(arrayRows[i].ints))
+			for _, v := range arrayRows[i].ints {
+				intArr.valueArr = append(intArr.valueArr, convert.Int64ToBytes(v))
+			}
+		}
+		es.seriesIDs = append(es.seriesIDs, 1)
+		es.timestamps = append(es.timestamps, int64(i+1))
+		es.elementIDs = append(es.elementIDs, "e"+strconv.Itoa(i+1))
+		es.tagFamilies = append(es.tagFamilies, []tagValues{{tag: "arr", values: []*tagValue{strArr, intArr}}})
+	}
+	return es
+}
+
+// wantArrayValues returns the values of the arrays of the i-th row, the nulls are empty arrays.
+func wantArrayValues(i int) []*modelv1.TagValue {
+	return []*modelv1.TagValue{
+		{Value: &modelv1.TagValue_StrArray{StrArray: &modelv1.StrArray{Value: arrayRows[i].strs}}},
+		{Value: &modelv1.TagValue_IntArray{IntArray: &modelv1.IntArray{Value: arrayRows[i].ints}}},
+	}
+}
+
+func Test_queryResult_arrayTags(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	tst, err := newTSTable(fs.NewLocalFileSystem(), tmpPath, common.Position{},
+		logger.GetLogger("test"), timestamp.TimeRange{}, option{flushTimeout: time.Hour, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer tst.Close()
+	require.NoError(t, tst.addElements(generateArrayEs(0, 3)))
+	require.NoError(t, tst.addElements(generateArrayEs(3, 6)))
+
+	strValue := func(s ...string) *modelv1.TagValue {
+		if len(s) == 1 {
+			return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: s[0]}}}
+		}
+		return &modelv1.TagValue{Value: &modelv1.TagValue_StrArray{StrArray: &modelv1.StrArray{Value: s}}}
+	}
+	intValue := func(v ...int64) *modelv1.TagValue {
+		if len(v) == 1 {
+			return &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: v[0]}}}
+		}
+		return &modelv1.TagValue{Value: &modelv1.TagValue_IntArray{IntArray: &modelv1.IntArray{Value: v}}}
+	}
+	tests := []struct {
+		name       string
+		predicates []pbv1.TagPredicate
+		want       []int
+	}{
+		{name: "no predicate", want: []int{0, 1, 2, 3, 4, 5}},
+		{name: "contains", predicates: []pbv1.TagPredicate{{Name: "strArr", Value: strValue("a")}}, want: []int{0, 3}},
+		{name: "contains all", predicates: []pbv1.TagPredicate{{Name: "strArr", Value: strValue("a", "b")}}, want: []int{0}},
+		{name: "not contains", predicates: []pbv1.TagPredicate{{Name: "strArr", Value: strValue("a"), Not: true}}, want: []int{1, 2, 4, 5}},
+		{name: "escaped", predicates: []pbv1.TagPredicate{{Name: "strArr", Value: strValue("b|c")}}, want: []int{4}},
+		{name: "escape", predicates: []pbv1.TagPredicate{{Name: "strArr", Value: strValue("c\\")}}, want: []int{5}},
+		{name: "empty element", predicates: []pbv1.TagPredicate{{Name: "strArr", Value: strValue("")}}, want: []int{4}},
+		{name: "int64", predicates: []pbv1.TagPredicate{{Name: "intArr", Value: intValue(2)}}, want: []int{0, 3}},
+		{name: "empty array", predicates: []pbv1.TagPredicate{{Name: "intArr", Value: intValue()}}, want: []int{0, 1, 2, 3, 4, 5}},
+		{name: "mismatched type", predicates: []pbv1.TagPredicate{{Name: "intArr", Value: strValue("a")}}},
+		{name: "not mismatched type", predicates: []pbv1.TagPredicate{{Name: "intArr", Value: strValue("a"), Not: true}}, want: []int{0, 1, 2, 3, 4, 5}},
+		{
+			name: "conjunction",
+			predicates: []pbv1.TagPredicate{
+				{Name: "intArr", Value: intValue(1)},
+				{Name: "strArr", Value: strValue("a"), Not: true},
+			},
+			want: []int{5},
+		},
+		{name: "not projected", predicates: []pbv1.TagPredicate{{Family: "other", Name: "strArr", Value: strValue("z")}}, want: []int{0, 1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.predicates {
+				if tt.predicates[i].Family == "" {
+					tt.predicates[i].Family = "arr"
+				}
+			}
+			qo := queryOptions{minTimestamp: 1, maxTimestamp: 10, tagPredicates: newTagPredicates(tt.predicates)}
+			qo.TagProjection = []pbv1.TagProjection{{Family: "arr", Names: []string{"strArr", "intArr"}}}
+			qr := queryResult{orderByTS: true, ascTS: true}
+			defer qr.Release()
+			require.NoError(t, qr.selectBlocks([]*tsTable{tst}, []common.SeriesID{1}, qo))
+			var got []pbv1.StreamResult
+			for r := qr.Pull(); r != nil; r = qr.Pull() {
+				got = append(got, *r)
+			}
+			want := make([]streamRow, 0, len(tt.want))
+			for _, i := range tt.want {
+				want = append(want, streamRow{sid: 1, timestamp: int64(i + 1), elementID: "e" + strconv.Itoa(i+1), values: wantArrayValues(i)})
+			}
+			if diff := cmp.Diff(want, flattenStreamResults(got), protocmp.Transform(), cmp.AllowUnexported(streamRow{}), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Unexpected rows (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_mergeParts_arrayTags(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	var pp []*partWrapper
+	defer func() {
+		for _, pw := range pp {
+			pw.decRef()
+		}
+	}()
+	for _, es := range []*elements{generateArrayEs(0, 3), generateArrayEs(3, 6)} {
+		mp := generateMemPart()
+		mp.mustInitFromElements(es)
+		pp = append(pp, newPartWrapper(mp, openMemPart(mp)))
+	}
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	p, skipped, err := mergeParts(fs.NewLocalFileSystem(), closeCh, pp, 1, tmpPath, nil, encoding.CodecZSTD, fs.IOOptions{})
+	require.NoError(t, err)
+	require.Empty(t, skipped)
+	defer p.decRef()
+
+	pmi := &partMergeIter{}
+	pmi.mustInitFromPart(p.p)
+	reader := &blockReader{}
+	reader.init([]*partMergeIter{pmi})
+	decoder := &encoding.BytesBlockDecoder{}
+	var rows int
+	for reader.nextBlockMetadata() {
+		require.NoError(t, reader.loadBlockData(decoder))
+		b := reader.block
+		require.Len(t, b.tagFamilies, 1)
+		for i, ts := range b.timestamps {
+			var got []*modelv1.TagValue
+			for _, name := range []string{"strArr", "intArr"} {
+				tg := findTag(b.tagFamilies[0].tags, name)
+				require.NotNil(t, tg)
+				got = append(got, mustDecodeTagValue(tg.valueType, tg.values[i]))
+			}
+			if diff := cmp.Diff(wantArrayValues(int(ts-1)), got, protocmp.Transform()); diff != "" {
+				t.Errorf("Unexpected arrays of %s (-want +got):\n%s", b.elementIDs[i], diff)
+			}
+			rows++
+		}
+	}
+	require.NoError(t, reader.error())
+	assert.Equal(t, len(arrayRows), rows)
+}
+
+func Test_containsElement(t *testing.T) {
+	assert.True(t, containsElement(pbv1.ValueTypeStrArr, encodeStrArr("a|b", "c"), escapeVarArrayElement("a|b")))
+	assert.False(t, containsElement(pbv1.ValueTypeStrArr, encodeStrArr("a|b", "c"), escapeVarArrayElement("a")))
+	assert.False(t, containsElement(pbv1.ValueTypeStrArr, encodeStrArr("ab"), escapeVarArrayElement("b")), "an element isn't a substring")
+	assert.True(t, containsElement(pbv1.ValueTypeStrArr, encodeStrArr("x", ""), escapeVarArrayElement("")))
+	assert.False(t, containsElement(pbv1.ValueTypeStrArr, nil, escapeVarArrayElement("")))
+	assert.True(t, containsElement(pbv1.ValueTypeInt64Arr, encodeInt64Arr(1, 2), convert.Int64ToBytes(2)))
+	assert.False(t, containsElement(pbv1.ValueTypeInt64Arr, encodeInt64Arr(1, 2), convert.Int64ToBytes(3)))
+}
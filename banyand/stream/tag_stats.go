@@ -0,0 +1,177 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"bytes"
+	"fmt"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/intern"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+// maxTagStatsPrefixLen is the max length of the min and max of a string tag, which are the prefixes of the values.
+const maxTagStatsPrefixLen = 16
+
+var statsSkippedBlocksCount = observability.NewMeterProvider(observability.RootScope.SubScope("stream").SubScope("tag_stats")).
+	Counter("skipped_blocks_total")
+
+// tagStats is the min and max of the values of a string or int64 tag in a block, which are compared as they're encoded.
+// The min and max of a string tag are truncated to maxTagStatsPrefixLen bytes, so they bound the prefixes of the values.
+type tagStats struct {
+	family    string
+	name      string
+	min       []byte
+	max       []byte
+	valueType pbv1.ValueType
+}
+
+func (ts *tagStats) marshal(dst []byte) []byte {
+	dst = encoding.EncodeBytes(dst, convert.StringToBytes(ts.family))
+	dst = encoding.EncodeBytes(dst, convert.StringToBytes(ts.name))
+	dst = append(dst, byte(ts.valueType))
+	dst = encoding.EncodeBytes(dst, ts.min)
+	return encoding.EncodeBytes(dst, ts.max)
+}
+
+func (ts *tagStats) unmarshal(src []byte) ([]byte, error) {
+	src, family, err := encoding.DecodeBytes(src)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal tagStats.family: %w", err)
+	}
+	ts.family = intern.Bytes(family)
+	src, name, err := encoding.DecodeBytes(src)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal tagStats.name: %w", err)
+	}
+	ts.name = intern.Bytes(name)
+	if len(src) < 1 {
+		return nil, fmt.Errorf("cannot unmarshal tagStats.valueType: src is too short")
+	}
+	ts.valueType = pbv1.ValueType(src[0])
+	src, minValue, err := encoding.DecodeBytes(src[1:])
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal tagStats.min: %w", err)
+	}
+	src, maxValue, err := encoding.DecodeBytes(src)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal tagStats.max: %w", err)
+	}
+	// the buffer of src is reused by the merges
+	ts.min, ts.max = bytes.Clone(minValue), bytes.Clone(maxValue)
+	return src, nil
+}
+
+// appendTagStats appends the statistics of the tag of the family to dst, if it's a string or int64 tag having a value.
+// The nulls of an int64 tag are skipped, while the ones of a string tag are taken as the empty strings
+// they can't be told from once they're read, which are the min of any strings.
+func appendTagStats(dst []tagStats, family string, t *tag) []tagStats {
+	if t.valueType != pbv1.ValueTypeStr && t.valueType != pbv1.ValueTypeInt64 {
+		return dst
+	}
+	var minValue, maxValue []byte
+	found := false
+	for _, v := range t.values {
+		if v == nil && t.valueType == pbv1.ValueTypeInt64 {
+			continue
+		}
+		if len(v) > maxTagStatsPrefixLen {
+			v = v[:maxTagStatsPrefixLen]
+		}
+		if !found {
+			minValue, maxValue, found = v, v, true
+			continue
+		}
+		if bytes.Compare(v, minValue) < 0 {
+			minValue = v
+		}
+		if bytes.Compare(v, maxValue) > 0 {
+			maxValue = v
+		}
+	}
+	if !found {
+		return dst
+	}
+	return append(dst, tagStats{
+		family:    family,
+		name:      t.name,
+		valueType: t.valueType,
+		min:       bytes.Clone(minValue),
+		max:       bytes.Clone(maxValue),
+	})
+}
+
+// mightMatchStats reports whether some values of the tag in the block might match the equality or the comparison,
+// by the statistics of the tags in the block. It's true if the tag has no statistics, or its type differs.
+func (tp *tagPredicate) mightMatchStats(stats []tagStats) bool {
+	if !tp.equal && tp.op == modelv1.Condition_BINARY_OP_UNSPECIFIED {
+		return true
+	}
+	for i := range stats {
+		ts := &stats[i]
+		if ts.family != tp.family || ts.name != tp.name {
+			continue
+		}
+		if ts.valueType != tp.valueType {
+			return true
+		}
+		v := tp.elements[0]
+		if len(v) > maxTagStatsPrefixLen {
+			v = v[:maxTagStatsPrefixLen]
+		}
+		// the prefixes of the strings equal to the bounds might be greater or less than the bounds
+		strict := ts.valueType == pbv1.ValueTypeInt64
+		if tp.equal {
+			return bytes.Compare(v, ts.min) >= 0 && bytes.Compare(v, ts.max) <= 0
+		}
+		switch tp.op {
+		case modelv1.Condition_BINARY_OP_GT:
+			c := bytes.Compare(ts.max, v)
+			return c > 0 || c == 0 && !strict
+		case modelv1.Condition_BINARY_OP_GE:
+			return bytes.Compare(ts.max, v) >= 0
+		case modelv1.Condition_BINARY_OP_LT:
+			c := bytes.Compare(ts.min, v)
+			return c < 0 || c == 0 && !strict
+		case modelv1.Condition_BINARY_OP_LE:
+			return bytes.Compare(ts.min, v) <= 0
+		}
+		return true
+	}
+	return true
+}
+
+// compareValue reports whether the value satisfies the comparison to elem, both of which are encoded as they're stored.
+func compareValue(op modelv1.Condition_BinaryOp, value, elem []byte) bool {
+	c := bytes.Compare(value, elem)
+	switch op {
+	case modelv1.Condition_BINARY_OP_GT:
+		return c > 0
+	case modelv1.Condition_BINARY_OP_GE:
+		return c >= 0
+	case modelv1.Condition_BINARY_OP_LT:
+		return c < 0
+	case modelv1.Condition_BINARY_OP_LE:
+		return c <= 0
+	}
+	return true
+}
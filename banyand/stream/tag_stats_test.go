@@ -0,0 +1,194 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func Test_appendTagStats(t *testing.T) {
+	tests := []struct {
+		want *tagStats
+		tag  tag
+		name string
+	}{
+		{
+			name: "int64",
+			tag: tag{name: "t", valueType: pbv1.ValueTypeInt64, values: [][]byte{
+				convert.Int64ToBytes(3), nil, convert.Int64ToBytes(-5), convert.Int64ToBytes(7),
+			}},
+			want: &tagStats{family: "f", name: "t", valueType: pbv1.ValueTypeInt64, min: convert.Int64ToBytes(-5), max: convert.Int64ToBytes(7)},
+		},
+		{
+			name: "int64 nulls",
+			tag:  tag{name: "t", valueType: pbv1.ValueTypeInt64, values: [][]byte{nil, nil}},
+		},
+		{
+			name: "string prefixes",
+			tag: tag{name: "t", valueType: pbv1.ValueTypeStr, values: [][]byte{
+				[]byte("b"), []byte("z-longer-than-the-prefix"), []byte("c"),
+			}},
+			want: &tagStats{family: "f", name: "t", valueType: pbv1.ValueTypeStr, min: []byte("b"), max: []byte("z-longer-than-th")},
+		},
+		{
+			name: "string nulls",
+			tag:  tag{name: "t", valueType: pbv1.ValueTypeStr, values: [][]byte{[]byte("b"), nil}},
+			want: &tagStats{family: "f", name: "t", valueType: pbv1.ValueTypeStr, min: []byte{}, max: []byte("b")},
+		},
+		{
+			name: "array",
+			tag:  tag{name: "t", valueType: pbv1.ValueTypeStrArr, values: [][]byte{[]byte("b")}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := appendTagStats(nil, "f", &tt.tag)
+			if tt.want == nil {
+				assert.Empty(t, got)
+				return
+			}
+			require.Len(t, got, 1)
+			assert.Equal(t, tt.want.valueType, got[0].valueType)
+			assert.Equal(t, string(tt.want.min), string(got[0].min))
+			assert.Equal(t, string(tt.want.max), string(got[0].max))
+		})
+	}
+}
+
+func Test_queryResult_tagStats(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	tst, err := newTSTable(fs.NewLocalFileSystem(), tmpPath, common.Position{},
+		logger.GetLogger("test"), timestamp.TimeRange{}, option{flushTimeout: time.Hour, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer tst.Close()
+	// the codes of the block of a batch are from batch*100 to batch*100+9
+	const batches, count = 4, 10
+	for batch := 0; batch < batches; batch++ {
+		require.NoError(t, tst.addElements(generateTraceEs(batch, count, false)))
+	}
+
+	code := func(op modelv1.Condition_BinaryOp, v int64) pbv1.TagPredicate {
+		return pbv1.TagPredicate{Name: "code", RangeOp: op, Value: &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: v}}}}
+	}
+	traceID := func(op modelv1.Condition_BinaryOp, v string) pbv1.TagPredicate {
+		return pbv1.TagPredicate{Name: "traceID", RangeOp: op, Value: &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: v}}}}
+	}
+	batchOf := func(batch int) []string {
+		ids := make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			ids = append(ids, fmt.Sprintf("e-%d-%d", batch, i))
+		}
+		return ids
+	}
+	tests := []struct {
+		name           string
+		predicates     []pbv1.TagPredicate
+		want           []string
+		wantMightMatch int
+	}{
+		{
+			name:           "greater than",
+			predicates:     []pbv1.TagPredicate{code(modelv1.Condition_BINARY_OP_GT, 250)},
+			want:           batchOf(3),
+			wantMightMatch: 1,
+		},
+		{
+			name:           "less than the min",
+			predicates:     []pbv1.TagPredicate{code(modelv1.Condition_BINARY_OP_LT, 100)},
+			want:           batchOf(0),
+			wantMightMatch: 1,
+		},
+		{
+			name:           "less than or equal to the min",
+			predicates:     []pbv1.TagPredicate{code(modelv1.Condition_BINARY_OP_LE, 100)},
+			want:           append(batchOf(0), "e-1-0"),
+			wantMightMatch: 2,
+		},
+		{
+			name: "between",
+			predicates: []pbv1.TagPredicate{
+				code(modelv1.Condition_BINARY_OP_GE, 105), code(modelv1.Condition_BINARY_OP_LT, 202),
+			},
+			want:           append(batchOf(1)[5:], "e-2-0", "e-2-1"),
+			wantMightMatch: 2,
+		},
+		{
+			name:           "string greater than or equal to",
+			predicates:     []pbv1.TagPredicate{traceID(modelv1.Condition_BINARY_OP_GE, "trace-3")},
+			want:           batchOf(3),
+			wantMightMatch: 1,
+		},
+		{
+			name: "equal",
+			predicates: []pbv1.TagPredicate{{Name: "traceID", Equal: true, Value: &modelv1.TagValue{
+				Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: "trace-2-5"}},
+			}}},
+			want:           []string{"e-2-5"},
+			wantMightMatch: 1,
+		},
+		{
+			name:           "out of all",
+			predicates:     []pbv1.TagPredicate{code(modelv1.Condition_BINARY_OP_GT, 1000)},
+			wantMightMatch: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.predicates {
+				tt.predicates[i].Family = "trace"
+			}
+			qo := queryOptions{minTimestamp: 1, maxTimestamp: batches * count, tagPredicates: newTagPredicates(tt.predicates)}
+			qo.TagProjection = []pbv1.TagProjection{{Family: "trace", Names: []string{"traceID", "code"}}}
+			qr := queryResult{orderByTS: true, ascTS: true}
+			defer qr.Release()
+			require.NoError(t, qr.selectBlocks([]*tsTable{tst}, []common.SeriesID{1}, qo))
+			require.Len(t, qr.data, batches)
+			var mightMatch int
+			for _, bc := range qr.data {
+				require.NotEmpty(t, bc.bm.tagStats)
+				ok, err := bc.mightMatch()
+				require.NoError(t, err)
+				if ok {
+					mightMatch++
+				}
+			}
+			assert.Equal(t, tt.wantMightMatch, mightMatch)
+
+			var got []string
+			for r := qr.Pull(); r != nil; r = qr.Pull() {
+				got = append(got, r.ElementIDs...)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -60,28 +60,33 @@ func TestTag_mustWriteTo_mustReadValues(t *testing.T) {
 		valueType: pbv1.ValueTypeStr,
 		values:    [][]byte{[]byte("value1"), nil, []byte("value2"), nil},
 	}
+	for i := 0; i < 50; i++ {
+		original.values = append(original.values, []byte("repetitive-value"))
+	}
 
-	tm := &tagMetadata{}
+	for _, codec := range []encoding.Codec{encoding.CodecZSTD, encoding.CodecSnappy, encoding.CodecNone} {
+		tm := &tagMetadata{}
 
-	buf := &bytes.Buffer{}
-	w := &writer{}
-	w.init(buf)
-	original.mustWriteTo(tm, w)
-	assert.Equal(t, w.bytesWritten, tm.size)
-	assert.Equal(t, uint64(len(buf.Buf)), tm.size)
-	assert.Equal(t, uint64(0), tm.offset)
-	assert.Equal(t, original.name, tm.name)
-	assert.Equal(t, original.valueType, tm.valueType)
+		buf := &bytes.Buffer{}
+		w := &writer{}
+		w.init(buf)
+		original.mustWriteTo(tm, w, codec)
+		assert.Equal(t, w.bytesWritten, tm.size)
+		assert.Equal(t, uint64(len(buf.Buf)), tm.size)
+		assert.Equal(t, uint64(0), tm.offset)
+		assert.Equal(t, original.name, tm.name)
+		assert.Equal(t, original.valueType, tm.valueType)
 
-	decoder := &encoding.BytesBlockDecoder{}
+		decoder := &encoding.BytesBlockDecoder{}
 
-	unmarshaled := &tag{}
-	unmarshaled.mustReadValues(decoder, buf, *tm, uint64(len(original.values)))
+		unmarshaled := &tag{}
+		assert.NoError(t, unmarshaled.readValues(decoder, buf, *tm, uint64(len(original.values))))
 
-	// Check that the original and new instances are equal
-	assert.Equal(t, original.name, unmarshaled.name)
-	assert.Equal(t, original.valueType, unmarshaled.valueType)
-	assert.Equal(t, original.values, unmarshaled.values)
+		// Check that the original and new instances are equal
+		assert.Equal(t, original.name, unmarshaled.name)
+		assert.Equal(t, original.valueType, unmarshaled.valueType)
+		assert.Equal(t, original.values, unmarshaled.values)
+	}
 }
 
 func TestTagFamily_reset(t *testing.T) {
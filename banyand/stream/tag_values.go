@@ -0,0 +1,220 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/index"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+type tagValuesCallback struct {
+	l          *logger.Logger
+	schemaRepo *schemaRepo
+}
+
+func setUpTagValuesCallback(l *logger.Logger, schemaRepo *schemaRepo) bus.MessageListener {
+	return &tagValuesCallback{
+		l:          l,
+		schemaRepo: schemaRepo,
+	}
+}
+
+func (t *tagValuesCallback) Rev(message bus.Message) (resp bus.Message) {
+	req, ok := message.Data().(*streamv1.TagValuesRequest)
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("invalid event data type"))
+	}
+	s, ok := t.schemaRepo.loadStream(req.GetMetadata())
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("stream %s doesn't exist", req.GetMetadata()))
+	}
+	result, err := s.tagValues(message.Context(), req)
+	if err != nil {
+		t.l.Error().Err(err).Stringer("stream", req.GetMetadata()).Str("tag", req.GetTagName()).Msg("cannot enumerate the tag values")
+		return bus.NewMessage(message.ID(), common.NewError("cannot enumerate the values of the tag %s of the stream %s: %v",
+			req.GetTagName(), req.GetMetadata(), err))
+	}
+	return bus.NewMessage(message.ID(), result)
+}
+
+// tagValueSource tells where the values of a tag are enumerated from, which is either the entities of the series
+// or the terms of an index rule.
+type tagValueSource struct {
+	decode      func(term []byte) *modelv1.TagValue
+	entity      int
+	indexRuleID uint32
+}
+
+func (s *stream) tagValueSource(tagName string, prefix string) (tagValueSource, error) {
+	fi, ti, spec := pbv1.FindTagByName(s.schema.GetTagFamilies(), tagName)
+	if spec == nil {
+		return tagValueSource{}, errors.Errorf("the tag %s isn't defined", tagName)
+	}
+	src := tagValueSource{entity: slices.Index(s.schema.GetEntity().GetTagNames(), tagName)}
+	switch spec.GetType() {
+	case databasev1.TagType_TAG_TYPE_STRING, databasev1.TagType_TAG_TYPE_STRING_ARRAY:
+		src.decode = func(term []byte) *modelv1.TagValue {
+			return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: string(term)}}}
+		}
+	case databasev1.TagType_TAG_TYPE_INT, databasev1.TagType_TAG_TYPE_INT_ARRAY:
+		if prefix != "" {
+			return tagValueSource{}, errors.Errorf("the tag %s isn't a string tag the prefix applies to", tagName)
+		}
+		src.decode = func(term []byte) *modelv1.TagValue {
+			return &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: convert.BytesToInt64(term)}}}
+		}
+	default:
+		return tagValueSource{}, errors.Errorf("the values of the tag %s can't be enumerated, whose type is %s", tagName, spec.GetType())
+	}
+	if src.entity >= 0 {
+		return src, nil
+	}
+	for _, l := range s.indexRuleLocators {
+		if len(l.TagIndices) != 1 || l.TagIndices[0].FamilyOffset != fi || l.TagIndices[0].TagOffset != ti ||
+			l.Rule.GetAnalyzer() != databasev1.IndexRule_ANALYZER_UNSPECIFIED {
+			continue
+		}
+		src.indexRuleID = l.Rule.GetMetadata().GetId()
+		return src, nil
+	}
+	return tagValueSource{}, errors.Errorf("the tag %s is neither an entity tag nor indexed without an analyzer", tagName)
+}
+
+// tagValues enumerates the distinct values of the tag of the series having blocks in the time range,
+// from the entities of the series or the terms of the index of the segments overlapping the time range.
+func (s *stream) tagValues(ctx context.Context, req *streamv1.TagValuesRequest) (*streamv1.TagValuesResponse, error) {
+	src, err := s.tagValueSource(req.GetTagName(), req.GetPrefix())
+	if err != nil {
+		return nil, err
+	}
+	tsdb := s.databaseSupplier.SupplyTSDB().(storage.TSDB[*tsTable, option])
+	entity := make([]*modelv1.TagValue, len(s.schema.GetEntity().GetTagNames()))
+	for i := range entity {
+		entity[i] = pbv1.AnyTagValue
+	}
+	sl, err := tsdb.Lookup(ctx, &pbv1.Series{Subject: s.name, EntityValues: entity})
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot look up the series")
+	}
+	result := &streamv1.TagValuesResponse{}
+	if len(sl) == 0 {
+		return result, nil
+	}
+	series := make(map[common.SeriesID]*pbv1.Series, len(sl))
+	sids := make([]common.SeriesID, 0, len(sl))
+	for i := range sl {
+		series[sl[i].ID] = sl[i]
+		sids = append(sids, sl[i].ID)
+	}
+	slices.Sort(sids)
+
+	var values []*modelv1.TagValue
+	seen := make(map[string]struct{})
+	add := func(key string, value *modelv1.TagValue) {
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		values = append(values, value)
+	}
+	tr := timestamp.NewInclusiveTimeRange(req.GetTimeRange().GetBegin().AsTime(), req.GetTimeRange().GetEnd().AsTime())
+	tabWrappers := tsdb.SelectTSTables(tr)
+	defer func() {
+		for i := range tabWrappers {
+			tabWrappers[i].DecRef()
+		}
+	}()
+	for _, tw := range tabWrappers {
+		active, errActive := tw.Table().seriesInTimeRange(ctx, sids, tr.Start.UnixNano(), tr.End.UnixNano())
+		if errActive != nil {
+			return nil, errors.WithMessagef(errActive, "cannot pick the series of the segment %s", tw.GetTimeRange())
+		}
+		for _, sid := range active {
+			if src.entity >= 0 {
+				// the values are keyed as the terms are
+				switch tv := series[sid].EntityValues[src.entity]; tv.GetValue().(type) {
+				case *modelv1.TagValue_Str:
+					if strings.HasPrefix(tv.GetStr().GetValue(), req.GetPrefix()) {
+						add(tv.GetStr().GetValue(), tv)
+					}
+				case *modelv1.TagValue_Int:
+					add(string(convert.Int64ToBytes(tv.GetInt().GetValue())), tv)
+				}
+				continue
+			}
+			err = tw.Table().Index().VisitTerms(index.FieldKey{SeriesID: sid, IndexRuleID: src.indexRuleID}, []byte(req.GetPrefix()),
+				func(term []byte) error {
+					add(string(term), src.decode(term))
+					return nil
+				})
+			if err != nil {
+				return nil, errors.WithMessagef(err, "cannot visit the terms of the segment %s", tw.GetTimeRange())
+			}
+		}
+	}
+	result.Values, result.Truncated = pbv1.MergeTagValues(values, req.GetLimit(), false)
+	return result, nil
+}
+
+// seriesInTimeRange returns the series having blocks overlapping the time range in order, which are told by the block metadata.
+// The series purged are skipped.
+func (tst *tsTable) seriesInTimeRange(ctx context.Context, sids []common.SeriesID, minTimestamp, maxTimestamp int64) ([]common.SeriesID, error) {
+	snp := tst.currentSnapshot()
+	if snp == nil {
+		return nil, nil
+	}
+	defer snp.decRef()
+	found := make(map[common.SeriesID]struct{})
+	var pi partIter
+	for _, pw := range snp.parts {
+		pi.init(pw.p, sids, minTimestamp, maxTimestamp)
+		for pi.nextBlock() {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if pw.p.tombstones.covers(pi.curBlock.seriesID, pw.p.partMetadata.ID) {
+				continue
+			}
+			found[pi.curBlock.seriesID] = struct{}{}
+		}
+		if err := pi.error(); err != nil {
+			return nil, err
+		}
+	}
+	result := make([]common.SeriesID, 0, len(found))
+	for sid := range found {
+		result = append(result, sid)
+	}
+	slices.Sort(result)
+	return result, nil
+}
@@ -0,0 +1,74 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func Test_stream_tagValueSource(t *testing.T) {
+	s := newRebuildTestStream(false)
+	src, err := s.tagValueSource("strTag", "val")
+	require.NoError(t, err)
+	assert.Equal(t, -1, src.entity)
+	assert.Equal(t, uint32(1), src.indexRuleID)
+	assert.Equal(t, "value1", src.decode([]byte("value1")).GetStr().GetValue())
+
+	src, err = s.tagValueSource("strTag2", "")
+	require.NoError(t, err)
+	assert.Equal(t, 0, src.entity, "the values of an entity tag are of the series")
+
+	_, err = s.tagValueSource("intTag", "1")
+	assert.Error(t, err, "the prefix applies to the string tags only")
+	_, err = s.tagValueSource("absent", "")
+	assert.Error(t, err)
+
+	s.indexRuleLocators = s.indexRuleLocators[1:]
+	_, err = s.tagValueSource("strTag", "")
+	assert.Error(t, err, "the tag isn't indexed")
+}
+
+func Test_tsTable_seriesInTimeRange(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	tst, err := newTSTable(fs.NewLocalFileSystem(), tmpPath, common.Position{}, logger.GetLogger("test"),
+		timestamp.TimeRange{}, option{flushTimeout: defaultFlushTimeout, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer tst.Close()
+	require.NoError(t, tst.addElements(esTS1))
+
+	sids, err := tst.seriesInTimeRange(context.Background(), []common.SeriesID{1, 2, 3}, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []common.SeriesID{1, 2, 3}, sids)
+	sids, err = tst.seriesInTimeRange(context.Background(), []common.SeriesID{2}, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []common.SeriesID{2}, sids)
+	sids, err = tst.seriesInTimeRange(context.Background(), []common.SeriesID{1, 2, 3}, 2, 5)
+	require.NoError(t, err)
+	assert.Empty(t, sids, "the blocks are out of the time range")
+}
@@ -0,0 +1,503 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+const (
+	// tombstonesFilename lists the streams purged from a tsTable, whose blocks are not merged away yet.
+	tombstonesFilename = "tombstones.json"
+	// deletionsFilename lists the elements deleted from a tsTable, which are not merged away yet.
+	deletionsFilename = "deletions.json"
+)
+
+var (
+	purgeProvider         = observability.NewMeterProvider(observability.RootScope.SubScope("stream").SubScope("purge"))
+	purgedSeriesCounter   = purgeProvider.Counter("purged_series_total", "group")
+	reclaimedBytesCounter = purgeProvider.Counter("reclaimed_bytes_total", "group")
+	deletionsCounter      = purgeProvider.Counter("deletions_total", "group")
+	deletedElementsCount  = purgeProvider.Counter("deleted_elements_total", "group")
+)
+
+// purge is a stream purged from a tsTable.
+type purge struct {
+	Series []common.SeriesID `json:"series"`
+	// MaxPartID is the ID of the last part allocated when the stream is purged.
+	// The blocks of the series are dropped from the parts whose IDs are not greater than it,
+	// so the elements written to the series after the purge are kept.
+	MaxPartID uint64 `json:"maxPartID"`
+	// ReclaimedBytes is the estimated size of the blocks the merges have dropped.
+	ReclaimedBytes uint64 `json:"reclaimedBytes"`
+}
+
+// deletion is the elements of the series in a time range deleted from a tsTable, which equal all the tags.
+type deletion struct {
+	Series []common.SeriesID `json:"series"`
+	Tags   []deletedTag      `json:"tags,omitempty"`
+	// MaxPartID is the ID of the last part allocated when the elements are deleted, the elements written afterwards are kept.
+	MaxPartID    uint64 `json:"maxPartID"`
+	MinTimestamp int64  `json:"minTimestamp"`
+	MaxTimestamp int64  `json:"maxTimestamp"`
+}
+
+// deletedTag is a tag the deleted elements equal, whose value is encoded as it's stored in the blocks.
+type deletedTag struct {
+	Family    string         `json:"family"`
+	Name      string         `json:"name"`
+	Value     []byte         `json:"value"`
+	ValueType pbv1.ValueType `json:"valueType"`
+}
+
+// coversBlock reports whether all the elements of the block are deleted, without reading its tags.
+func (d *deletion) coversBlock(minTimestamp, maxTimestamp int64) bool {
+	return len(d.Tags) == 0 && d.MinTimestamp <= minTimestamp && d.MaxTimestamp >= maxTimestamp
+}
+
+// tombstones are the streams purged from a tsTable. The queries skip the blocks of their series,
+// and the merges drop them without decoding. A purge is forgotten once all the parts it covers are merged away.
+type tombstones struct {
+	fileSystem fs.FileSystem
+	l          *logger.Logger
+	// series maps the purged series to the MaxPartID of their purges. It's replaced as a whole on every change,
+	// so that the queries and the merges look it up without locking.
+	series atomic.Pointer[map[common.SeriesID]uint64]
+	// deleted maps the series to their deletions, which is replaced as a whole like series.
+	deleted       atomic.Pointer[map[common.SeriesID][]*deletion]
+	purges        map[string]*purge
+	deletions     []*deletion
+	owners        map[common.SeriesID]string
+	group         string
+	path          string
+	deletionsPath string
+	mu            sync.Mutex
+}
+
+func (t *tombstones) init(fileSystem fs.FileSystem, root, group string, l *logger.Logger) {
+	t.fileSystem = fileSystem
+	t.path = filepath.Join(root, tombstonesFilename)
+	t.deletionsPath = filepath.Join(root, deletionsFilename)
+	t.group = group
+	t.l = l
+	t.purges = make(map[string]*purge)
+	t.owners = make(map[common.SeriesID]string)
+}
+
+func (t *tombstones) mustLoad() {
+	data, err := t.fileSystem.Read(t.path)
+	if err != nil {
+		logger.Panicf("cannot read %s: %s", t.path, err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err = json.Unmarshal(data, &t.purges); err != nil {
+		logger.Panicf("cannot parse %s: %s", t.path, err)
+	}
+	t.rebuild()
+}
+
+func (t *tombstones) mustLoadDeletions() {
+	data, err := t.fileSystem.Read(t.deletionsPath)
+	if err != nil {
+		logger.Panicf("cannot read %s: %s", t.deletionsPath, err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err = json.Unmarshal(data, &t.deletions); err != nil {
+		logger.Panicf("cannot parse %s: %s", t.deletionsPath, err)
+	}
+	t.rebuildDeletions()
+}
+
+// covers reports whether the blocks of the series in the part are purged.
+func (t *tombstones) covers(seriesID common.SeriesID, partID uint64) bool {
+	if t == nil {
+		return false
+	}
+	series := t.series.Load()
+	if series == nil {
+		return false
+	}
+	maxPartID, ok := (*series)[seriesID]
+	return ok && partID <= maxPartID
+}
+
+// deletionsOf appends the deletions of the series covering the part, which overlap the time range.
+func (t *tombstones) deletionsOf(dst []*deletion, seriesID common.SeriesID, partID uint64, minTimestamp, maxTimestamp int64) []*deletion {
+	if t == nil {
+		return dst
+	}
+	deleted := t.deleted.Load()
+	if deleted == nil {
+		return dst
+	}
+	for _, d := range (*deleted)[seriesID] {
+		if partID <= d.MaxPartID && d.MinTimestamp <= maxTimestamp && d.MaxTimestamp >= minTimestamp {
+			dst = append(dst, d)
+		}
+	}
+	return dst
+}
+
+// pending returns the series of the purge of the stream which isn't finished yet, along with the bytes reclaimed so far.
+func (t *tombstones) pending(name string) ([]common.SeriesID, uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.purges[name]
+	if !ok {
+		return nil, 0
+	}
+	return append([]common.SeriesID(nil), p.Series...), p.ReclaimedBytes
+}
+
+// add purges the series of the stream from the parts whose IDs are not greater than maxPartID.
+// The series of a purge of the stream which isn't finished are purged again with the later maxPartID.
+func (t *tombstones) add(name string, seriesIDs []common.SeriesID, maxPartID uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.purges[name]
+	if !ok {
+		p = &purge{}
+		t.purges[name] = p
+	}
+	p.Series = mergeSeriesIDs(p.Series, seriesIDs)
+	p.MaxPartID = maxPartID
+	t.rebuild()
+	t.persist()
+	purgedSeriesCounter.Inc(float64(len(seriesIDs)), t.group)
+}
+
+// delete deletes the elements of the deletion from the parts whose IDs are not greater than its MaxPartID.
+func (t *tombstones) delete(d *deletion) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.deletions = append(t.deletions, d)
+	t.rebuildDeletions()
+	t.persistDeletions()
+	deletionsCounter.Inc(1, t.group)
+}
+
+// reclaim adds the bytes of the blocks a merge has dropped to the purges of their series.
+func (t *tombstones) reclaim(reclaimed map[common.SeriesID]uint64) {
+	if t == nil || len(reclaimed) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var total uint64
+	for seriesID, n := range reclaimed {
+		total += n
+		if p, ok := t.purges[t.owners[seriesID]]; ok {
+			p.ReclaimedBytes += n
+		}
+	}
+	t.persist()
+	reclaimedBytesCounter.Inc(float64(total), t.group)
+}
+
+// prune forgets the purges and the deletions covering no part whose ID is minPartID or greater, which have all been merged away.
+func (t *tombstones) prune(minPartID uint64) {
+	if t.series.Load() == nil && t.deleted.Load() == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pruneDeletions(minPartID)
+	var pruned bool
+	for name, p := range t.purges {
+		if p.MaxPartID < minPartID {
+			delete(t.purges, name)
+			pruned = true
+			t.l.Info().Str("stream", name).Int("series", len(p.Series)).Uint64("reclaimedBytes", p.ReclaimedBytes).
+				Msg("the purged stream is merged away")
+		}
+	}
+	if !pruned {
+		return
+	}
+	t.rebuild()
+	t.persist()
+}
+
+func (t *tombstones) pruneDeletions(minPartID uint64) {
+	n := len(t.deletions)
+	t.deletions = slices.DeleteFunc(t.deletions, func(d *deletion) bool {
+		return d.MaxPartID < minPartID
+	})
+	if n == len(t.deletions) {
+		return
+	}
+	t.l.Info().Int("deletions", n-len(t.deletions)).Msg("the deleted elements are merged away")
+	t.rebuildDeletions()
+	t.persistDeletions()
+}
+
+func (t *tombstones) rebuild() {
+	for k := range t.owners {
+		delete(t.owners, k)
+	}
+	if len(t.purges) == 0 {
+		t.series.Store(nil)
+		return
+	}
+	series := make(map[common.SeriesID]uint64)
+	for name, p := range t.purges {
+		for _, seriesID := range p.Series {
+			series[seriesID] = p.MaxPartID
+			t.owners[seriesID] = name
+		}
+	}
+	t.series.Store(&series)
+}
+
+func (t *tombstones) rebuildDeletions() {
+	if len(t.deletions) == 0 {
+		t.deleted.Store(nil)
+		return
+	}
+	deleted := make(map[common.SeriesID][]*deletion)
+	for _, d := range t.deletions {
+		for _, seriesID := range d.Series {
+			deleted[seriesID] = append(deleted[seriesID], d)
+		}
+	}
+	t.deleted.Store(&deleted)
+}
+
+// persist replaces the tombstones file, or removes it once there is no purge.
+// The streams purged are queried again if it fails, which is logged rather than failing the purge.
+func (t *tombstones) persist() {
+	if len(t.purges) == 0 {
+		if err := t.fileSystem.DeleteFile(t.path); err != nil {
+			t.l.Debug().Err(err).Str("path", t.path).Msg("cannot remove the tombstones")
+		}
+		return
+	}
+	data, err := json.Marshal(t.purges)
+	if err != nil {
+		logger.Panicf("cannot marshal the tombstones to JSON: %s", err)
+	}
+	tmp := t.path + ".tmp"
+	if _, err = t.fileSystem.Write(data, tmp, filePermission); err != nil {
+		t.l.Warn().Err(err).Str("path", tmp).Msg("cannot write the tombstones")
+		return
+	}
+	if err = t.fileSystem.Rename(tmp, t.path); err != nil {
+		t.l.Warn().Err(err).Str("path", t.path).Msg("cannot replace the tombstones")
+	}
+}
+
+// persistDeletions replaces the deletions file, or removes it once there is no deletion.
+// The elements deleted are queried again if it fails, which is logged rather than failing the deletion.
+func (t *tombstones) persistDeletions() {
+	if len(t.deletions) == 0 {
+		if err := t.fileSystem.DeleteFile(t.deletionsPath); err != nil {
+			t.l.Debug().Err(err).Str("path", t.deletionsPath).Msg("cannot remove the deletions")
+		}
+		return
+	}
+	data, err := json.Marshal(t.deletions)
+	if err != nil {
+		logger.Panicf("cannot marshal the deletions to JSON: %s", err)
+	}
+	tmp := t.deletionsPath + ".tmp"
+	if _, err = t.fileSystem.Write(data, tmp, filePermission); err != nil {
+		t.l.Warn().Err(err).Str("path", tmp).Msg("cannot write the deletions")
+		return
+	}
+	if err = t.fileSystem.Rename(tmp, t.deletionsPath); err != nil {
+		t.l.Warn().Err(err).Str("path", t.deletionsPath).Msg("cannot replace the deletions")
+	}
+}
+
+// withDeletedTags returns the projection along with the tags the deletions are evaluated on, which are appended to
+// the families of them or as new families. The projection is cloned rather than changed.
+func withDeletedTags(projection []pbv1.TagProjection, deletions []*deletion) []pbv1.TagProjection {
+	result := projection
+	var cloned bool
+	for _, d := range deletions {
+		for _, dt := range d.Tags {
+			i := slices.IndexFunc(result, func(tp pbv1.TagProjection) bool {
+				return tp.Family == dt.Family
+			})
+			if i >= 0 && slices.Contains(result[i].Names, dt.Name) {
+				continue
+			}
+			if !cloned {
+				result = slices.Clone(projection)
+				cloned = true
+			}
+			if i < 0 {
+				result = append(result, pbv1.TagProjection{Family: dt.Family, Names: []string{dt.Name}})
+				continue
+			}
+			result[i].Names = append(slices.Clip(result[i].Names), dt.Name)
+		}
+	}
+	return result
+}
+
+// elementDeleted reports whether the element of the series at the timestamp in the part is deleted by the deletions,
+// which cover the timestamp. The tags the deletions are evaluated on are read alone.
+func elementDeleted(p *part, seriesID common.SeriesID, timestamp common.ItemID, deletions []*deletion) (bool, error) {
+	for _, d := range deletions {
+		if len(d.Tags) == 0 {
+			return true, nil
+		}
+	}
+	e, _, err := p.getElement(seriesID, timestamp, withDeletedTags(nil, deletions))
+	if err != nil {
+		return false, err
+	}
+NEXT:
+	for _, d := range deletions {
+		for _, dt := range d.Tags {
+			if !elementTagEquals(e, dt) {
+				continue NEXT
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func elementTagEquals(e *element, dt deletedTag) bool {
+	for _, tf := range e.tagFamilies {
+		if tf.name != dt.Family {
+			continue
+		}
+		t := findTag(tf.tags, dt.Name)
+		return t != nil && e.index < len(t.values) && t.valueType == dt.ValueType && bytes.Equal(t.values[e.index], dt.Value)
+	}
+	return false
+}
+
+// deletedRows marks the rows deleted by the deletions, whose tags are looked up in the tag families of the rows.
+// A tag missing from the rows is a null, which equals no value. It returns the number of the rows deleted.
+func deletedRows(deleted []bool, deletions []*deletion, timestamps []int64, tagFamilies []tagFamily) int {
+	var n int
+	tags := make([]*tag, 0, 4)
+NEXT:
+	for _, d := range deletions {
+		tags = tags[:0]
+		for _, dt := range d.Tags {
+			var t *tag
+			for i := range tagFamilies {
+				if tagFamilies[i].name == dt.Family {
+					t = findTag(tagFamilies[i].tags, dt.Name)
+					break
+				}
+			}
+			if t == nil || t.values == nil || t.valueType != dt.ValueType {
+				continue NEXT
+			}
+			tags = append(tags, t)
+		}
+	ROWS:
+		for idx, ts := range timestamps {
+			if deleted[idx] || ts < d.MinTimestamp || ts > d.MaxTimestamp {
+				continue
+			}
+			for i, t := range tags {
+				if !bytes.Equal(t.values[idx], d.Tags[i].Value) {
+					continue ROWS
+				}
+			}
+			deleted[idx] = true
+			n++
+		}
+	}
+	return n
+}
+
+func mergeSeriesIDs(dst, src []common.SeriesID) []common.SeriesID {
+	dst = append(dst, src...)
+	sort.Slice(dst, func(i, j int) bool { return dst[i] < dst[j] })
+	n := 0
+	for i := range dst {
+		if i > 0 && dst[i] == dst[n-1] {
+			continue
+		}
+		dst[n] = dst[i]
+		n++
+	}
+	return dst[:n]
+}
+
+// purgeStats are the blocks of the purged series on disk, and the bytes of them estimated from the block metadata.
+type purgeStats struct {
+	blocks         uint64
+	bytes          uint64
+	reclaimedBytes uint64
+}
+
+// purge tombstones the series of the stream in the parts allocated so far, and returns the blocks of them on disk.
+// The series of a purge of the stream which isn't finished are counted and purged again.
+// It only estimates the blocks if dryRun is true.
+func (tst *tsTable) purge(name string, seriesIDs []common.SeriesID, dryRun bool) (purgeStats, error) {
+	pending, reclaimed := tst.tombstones.pending(name)
+	seriesIDs = mergeSeriesIDs(pending, seriesIDs)
+	stats := purgeStats{reclaimedBytes: reclaimed}
+	if len(seriesIDs) == 0 {
+		return stats, nil
+	}
+	snp := tst.currentSnapshot()
+	if snp == nil {
+		return stats, nil
+	}
+	defer snp.decRef()
+	var pi partIter
+	for _, pw := range snp.parts {
+		pi.init(pw.p, seriesIDs, math.MinInt64, math.MaxInt64)
+		for pi.nextBlock() {
+			stats.blocks++
+			stats.bytes += pw.p.estimatedBlockSize(&pi.curBlock)
+		}
+		if err := pi.error(); err != nil {
+			return stats, err
+		}
+	}
+	if dryRun {
+		return stats, nil
+	}
+	tst.tombstones.add(name, seriesIDs, atomic.LoadUint64(&tst.curPartID))
+	tst.windows.drop(seriesIDs)
+	return stats, nil
+}
+
+// deleteElements deletes the elements of the series in the parts allocated so far, which are skipped by the queries
+// and dropped by the merges.
+func (tst *tsTable) deleteElements(d *deletion) {
+	d.MaxPartID = atomic.LoadUint64(&tst.curPartID)
+	tst.tombstones.delete(d)
+	tst.windows.drop(d.Series)
+}
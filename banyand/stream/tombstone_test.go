@@ -0,0 +1,244 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func Test_tsTable_purge(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	fileSystem := fs.NewLocalFileSystem()
+	tst, err := newTSTable(fileSystem, tmpPath, common.Position{}, logger.GetLogger("test"),
+		timestamp.TimeRange{}, option{flushTimeout: defaultFlushTimeout, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	require.NoError(t, tst.addElements(esTS1))
+
+	stats, err := tst.purge("sw", []common.SeriesID{1, 2}, true)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), stats.blocks)
+	assert.Greater(t, stats.bytes, uint64(0))
+	_, _, err = tst.getElement(1, 1, nil)
+	require.NoError(t, err, "a dry-run doesn't purge the series")
+
+	_, err = tst.purge("sw", []common.SeriesID{1, 2}, false)
+	require.NoError(t, err)
+	_, _, err = tst.getElement(1, 1, nil)
+	require.ErrorIs(t, err, errElementPurged)
+	_, _, err = tst.getElement(3, 1, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, tst.addElements(esTS2))
+	_, _, err = tst.getElement(1, 2, nil)
+	require.NoError(t, err, "the elements written after the purge are kept")
+
+	series, _ := tst.tombstones.pending("sw")
+	assert.Equal(t, []common.SeriesID{1, 2}, series)
+	require.NoError(t, tst.Close())
+
+	reopened, err := newTSTable(fileSystem, tmpPath, common.Position{}, logger.GetLogger("test"),
+		timestamp.TimeRange{}, option{flushTimeout: defaultFlushTimeout, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer reopened.Close()
+	assert.True(t, reopened.tombstones.covers(1, 1), "the tombstones are loaded again")
+	assert.False(t, reopened.tombstones.covers(3, 1))
+
+	reopened.tombstones.prune(reopened.tombstones.purges["sw"].MaxPartID + 1)
+	assert.False(t, reopened.tombstones.covers(1, 1))
+	_, err = fileSystem.Read(filepath.Join(tmpPath, tombstonesFilename))
+	assert.Error(t, err, "the tombstones file is removed once there is no purge")
+}
+
+func Test_mergeParts_purged(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	fileSystem := fs.NewLocalFileSystem()
+	var ts tombstones
+	ts.init(fileSystem, tmpPath, "default", logger.GetLogger("test"))
+	ts.add("sw", []common.SeriesID{1}, 1)
+
+	var pp []*partWrapper
+	defer func() {
+		for _, pw := range pp {
+			pw.decRef()
+		}
+	}()
+	for i, es := range []*elements{esTS1, esTS2} {
+		partID := uint64(i + 1)
+		mp := generateMemPart()
+		mp.mustInitFromElements(es)
+		mp.mustFlush(fileSystem, partPath(tmpPath, partID))
+		pw := newPartWrapper(nil, mustOpenFilePart(partID, tmpPath, fileSystem))
+		pw.p.partMetadata.ID = partID
+		pw.p.tombstones = &ts
+		pp = append(pp, pw)
+		releaseMemPart(mp)
+	}
+
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	p, skipped, err := mergeParts(fileSystem, closeCh, pp, 3, tmpPath, nil, encoding.CodecZSTD, fs.IOOptions{})
+	require.NoError(t, err)
+	require.Empty(t, skipped)
+	defer p.decRef()
+
+	pmi := &partMergeIter{}
+	pmi.mustInitFromPart(p.p)
+	reader := &blockReader{}
+	reader.init([]*partMergeIter{pmi})
+	got := make(map[common.SeriesID]uint64)
+	for reader.nextBlockMetadata() {
+		got[reader.block.bm.seriesID] += reader.block.bm.count
+	}
+	require.NoError(t, reader.error())
+	assert.Equal(t, map[common.SeriesID]uint64{1: 1, 2: 2, 3: 2}, got, "the purged block of the series 1 is dropped")
+	_, reclaimed := ts.pending("sw")
+	assert.Greater(t, reclaimed, uint64(0))
+}
+
+func Test_tsTable_deleteElements(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	fileSystem := fs.NewLocalFileSystem()
+	tst, err := newTSTable(fileSystem, tmpPath, common.Position{}, logger.GetLogger("test"),
+		timestamp.TimeRange{}, option{flushTimeout: defaultFlushTimeout, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	require.NoError(t, tst.addElements(esTS1))
+	require.NoError(t, tst.addElements(esTS2))
+
+	tst.deleteElements(&deletion{
+		Series:       []common.SeriesID{1},
+		Tags:         []deletedTag{{Family: "singleTag", Name: "strTag", Value: []byte("value3"), ValueType: pbv1.ValueTypeStr}},
+		MinTimestamp: 1,
+		MaxTimestamp: 2,
+	})
+	_, _, err = tst.getElement(1, 1, nil)
+	require.NoError(t, err, "the element doesn't equal the tag")
+	_, _, err = tst.getElement(1, 2, nil)
+	require.ErrorIs(t, err, errElementPurged)
+
+	tst.deleteElements(&deletion{Series: []common.SeriesID{2}, MinTimestamp: 2, MaxTimestamp: 2})
+	_, _, err = tst.getElement(2, 1, nil)
+	require.NoError(t, err, "the element is out of the time range")
+	_, _, err = tst.getElement(2, 2, nil)
+	require.ErrorIs(t, err, errElementPurged)
+	_, _, err = tst.getElement(3, 2, nil)
+	require.NoError(t, err)
+	require.NoError(t, tst.Close())
+
+	reopened, err := newTSTable(fileSystem, tmpPath, common.Position{}, logger.GetLogger("test"),
+		timestamp.TimeRange{}, option{flushTimeout: defaultFlushTimeout, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer reopened.Close()
+	assert.Len(t, reopened.tombstones.deletionsOf(nil, 1, 1, math.MinInt64, math.MaxInt64), 1, "the deletions are loaded again")
+	assert.Empty(t, reopened.tombstones.deletionsOf(nil, 2, 1, 1, 1))
+
+	reopened.tombstones.prune(reopened.tombstones.deletions[1].MaxPartID + 1)
+	assert.Empty(t, reopened.tombstones.deletionsOf(nil, 1, 1, math.MinInt64, math.MaxInt64))
+	_, err = fileSystem.Read(filepath.Join(tmpPath, deletionsFilename))
+	assert.Error(t, err, "the deletions file is removed once there is no deletion")
+}
+
+func Test_mergeParts_deleted(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	fileSystem := fs.NewLocalFileSystem()
+	var ts tombstones
+	ts.init(fileSystem, tmpPath, "default", logger.GetLogger("test"))
+	ts.delete(&deletion{Series: []common.SeriesID{1, 2}, MaxPartID: 2, MinTimestamp: 2, MaxTimestamp: 2})
+	ts.delete(&deletion{
+		Series:       []common.SeriesID{1},
+		Tags:         []deletedTag{{Family: "singleTag", Name: "strTag", Value: []byte("value1"), ValueType: pbv1.ValueTypeStr}},
+		MaxPartID:    2,
+		MinTimestamp: 1,
+		MaxTimestamp: 1,
+	})
+
+	var pp []*partWrapper
+	defer func() {
+		for _, pw := range pp {
+			pw.decRef()
+		}
+	}()
+	for i, es := range []*elements{esTS1, esTS2} {
+		partID := uint64(i + 1)
+		mp := generateMemPart()
+		mp.mustInitFromElements(es)
+		mp.mustFlush(fileSystem, partPath(tmpPath, partID))
+		pw := newPartWrapper(nil, mustOpenFilePart(partID, tmpPath, fileSystem))
+		pw.p.partMetadata.ID = partID
+		pw.p.tombstones = &ts
+		pp = append(pp, pw)
+		releaseMemPart(mp)
+	}
+
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	p, skipped, err := mergeParts(fileSystem, closeCh, pp, 3, tmpPath, nil, encoding.CodecZSTD, fs.IOOptions{})
+	require.NoError(t, err)
+	require.Empty(t, skipped)
+	defer p.decRef()
+
+	pmi := &partMergeIter{}
+	pmi.mustInitFromPart(p.p)
+	reader := &blockReader{}
+	reader.init([]*partMergeIter{pmi})
+	got := make(map[common.SeriesID]uint64)
+	for reader.nextBlockMetadata() {
+		got[reader.block.bm.seriesID] += reader.block.bm.count
+	}
+	require.NoError(t, reader.error())
+	assert.Equal(t, map[common.SeriesID]uint64{2: 1, 3: 2}, got, "the deleted elements are dropped")
+}
+
+func Test_deletedRows(t *testing.T) {
+	tagFamilies := []tagFamily{{name: "tf", tags: []tag{{
+		name: "service", valueType: pbv1.ValueTypeStr, values: [][]byte{[]byte("a"), []byte("b"), []byte("a"), nil},
+	}}}}
+	deletions := []*deletion{
+		{Tags: []deletedTag{{Family: "tf", Name: "service", Value: []byte("a"), ValueType: pbv1.ValueTypeStr}}, MinTimestamp: 1, MaxTimestamp: 3},
+		{MinTimestamp: 4, MaxTimestamp: 4},
+	}
+	deleted := make([]bool, 4)
+	assert.Equal(t, 3, deletedRows(deleted, deletions, []int64{1, 2, 3, 4}, tagFamilies))
+	assert.Equal(t, []bool{true, false, true, true}, deleted)
+
+	projection := []pbv1.TagProjection{{Family: "tf", Names: []string{"endpoint"}}}
+	got := withDeletedTags(projection, deletions)
+	assert.Equal(t, []pbv1.TagProjection{{Family: "tf", Names: []string{"endpoint", "service"}}}, got)
+	assert.Equal(t, []string{"endpoint"}, projection[0].Names, "the projection isn't modified")
+}
+
+func Test_mergeSeriesIDs(t *testing.T) {
+	assert.Equal(t, []common.SeriesID{1, 2, 3}, mergeSeriesIDs([]common.SeriesID{3, 1}, []common.SeriesID{2, 3, 1}))
+	assert.Empty(t, mergeSeriesIDs(nil, nil))
+}
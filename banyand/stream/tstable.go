@@ -32,9 +32,12 @@ import (
 	"time"
 
 	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/banyand/internal/wal"
 	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/index"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/query/executor"
 	"github.com/apache/skywalking-banyandb/pkg/run"
 	"github.com/apache/skywalking-banyandb/pkg/timestamp"
 	"github.com/apache/skywalking-banyandb/pkg/watcher"
@@ -57,7 +60,18 @@ type tsTable struct {
 	p             common.Position
 	root          string
 	gc            garbageCleaner
-	curPartID     uint64
+	tombstones    tombstones
+	sizing        *blockSizing
+	windows       tableWindows
+	// wal logs the elements before they're introduced, it's nil if the write-ahead log is disabled.
+	wal *wal.Log
+	// replayedSeries are the series documents replayed from the wal, which the database writes to its series index.
+	replayedSeries index.Documents
+	// walMu excludes the appends while the flusher closes a segment of the wal.
+	walMu     sync.RWMutex
+	curPartID uint64
+	// flushSeq is the sequence of the last flush, see partMetadata.FlushSeq.
+	flushSeq uint64
 	sync.RWMutex
 }
 
@@ -79,10 +93,12 @@ func (tst *tsTable) loadSnapshot(epoch uint64, loadedParts []uint64) {
 		}
 		p := mustOpenFilePart(id, tst.root, tst.fileSystem)
 		p.partMetadata.ID = id
+		p.tombstones = &tst.tombstones
 		snp.parts = append(snp.parts, newPartWrapper(nil, p))
 		if tst.curPartID < id {
 			tst.curPartID = id
 		}
+		tst.flushSeq = max(tst.flushSeq, p.partMetadata.FlushSeq)
 	}
 	tst.gc.registerSnapshot(&snp)
 	tst.gc.clean()
@@ -93,7 +109,9 @@ func (tst *tsTable) loadSnapshot(epoch uint64, loadedParts []uint64) {
 	tst.snapshot = &snp
 }
 
-func (tst *tsTable) startLoop(cur uint64) {
+// startLoop runs the loops of the table. The flusher flushes the memory parts introduced after the epoch flushed,
+// and the introducer introduces the next ones after the epoch cur.
+func (tst *tsTable) startLoop(flushed, cur uint64) {
 	tst.loopCloser = run.NewCloser(1 + 3)
 	tst.introductions = make(chan *introduction)
 	flushCh := make(chan *flusherIntroduction)
@@ -101,7 +119,7 @@ func (tst *tsTable) startLoop(cur uint64) {
 	introducerWatcher := make(watcher.Channel, 1)
 	flusherWatcher := make(watcher.Channel, 1)
 	go tst.introducerLoop(flushCh, mergeCh, introducerWatcher, cur+1)
-	go tst.flusherLoop(flushCh, mergeCh, introducerWatcher, flusherWatcher, cur)
+	go tst.flusherLoop(flushCh, mergeCh, introducerWatcher, flusherWatcher, flushed)
 	go tst.mergeLoop(mergeCh, flusherWatcher)
 }
 
@@ -156,6 +174,11 @@ func (tst *tsTable) mustReadSnapshot(snapshot uint64) []uint64 {
 func newTSTable(fileSystem fs.FileSystem, rootPath string, p common.Position,
 	l *logger.Logger, _ timestamp.TimeRange, option option,
 ) (*tsTable, error) {
+	if option.cold != nil {
+		fileSystem = option.cold.fileSystem
+	} else if option.fileSystem != nil {
+		fileSystem = option.fileSystem
+	}
 	index, err := newElementIndex(context.TODO(), rootPath, option.elementIndexFlushTimeout.Nanoseconds()/int64(time.Second))
 	if err != nil {
 		return nil, err
@@ -168,19 +191,25 @@ func newTSTable(fileSystem fs.FileSystem, rootPath string, p common.Position,
 		l:          l,
 		p:          p,
 	}
+	if option.blockMaxRows > 0 || option.blockMaxSize > 0 {
+		maxBytes := option.blockMaxSize
+		if maxBytes == 0 {
+			maxBytes = maxUncompressedBlockSize
+		}
+		tst.sizing = newBlockSizing(option.blockMinRows, option.blockMaxRows, maxBytes)
+	}
 	tst.gc.init(&tst)
+	tst.tombstones.init(fileSystem, rootPath, p.Database, l)
 	ee := fileSystem.ReadDir(rootPath)
 	if len(ee) == 0 {
-		t := &tst
-		t.startLoop(uint64(time.Now().UnixNano()))
-		return t, nil
+		return tst.start(uint64(time.Now().UnixNano()))
 	}
 	var loadedParts []uint64
 	var loadedSnapshots []uint64
 	var needToDelete []string
 	for i := range ee {
 		if ee[i].IsDir() {
-			if ee[i].Name() == elementIndexFilename {
+			if ee[i].Name() == elementIndexFilename || ee[i].Name() == quarantineDirname || ee[i].Name() == walDirname {
 				continue
 			}
 			p, err := parseEpoch(ee[i].Name())
@@ -192,6 +221,14 @@ func newTSTable(fileSystem fs.FileSystem, rootPath string, p common.Position,
 			loadedParts = append(loadedParts, p)
 			continue
 		}
+		if ee[i].Name() == tombstonesFilename {
+			tst.tombstones.mustLoad()
+			continue
+		}
+		if ee[i].Name() == deletionsFilename {
+			tst.tombstones.mustLoadDeletions()
+			continue
+		}
 		if filepath.Ext(ee[i].Name()) != snapshotSuffix {
 			continue
 		}
@@ -209,18 +246,28 @@ func newTSTable(fileSystem fs.FileSystem, rootPath string, p common.Position,
 		}
 	}
 	if len(loadedParts) == 0 || len(loadedSnapshots) == 0 {
-		t := &tst
-		t.startLoop(uint64(time.Now().UnixNano()))
-		return t, nil
+		return tst.start(uint64(time.Now().UnixNano()))
 	}
 	sort.Slice(loadedSnapshots, func(i, j int) bool {
 		return loadedSnapshots[i] > loadedSnapshots[j]
 	})
 	epoch := loadedSnapshots[0]
-	t := &tst
-	t.loadSnapshot(epoch, loadedParts)
-	t.startLoop(epoch)
-	return t, nil
+	tst.loadSnapshot(epoch, loadedParts)
+	return tst.start(epoch)
+}
+
+// start replays the elements logged by the wal but not flushed before, then runs the loops of the table.
+func (tst *tsTable) start(epoch uint64) (*tsTable, error) {
+	cur := epoch
+	if tst.option.wal.enabled {
+		var err error
+		if cur, err = tst.openWAL(epoch); err != nil {
+			_ = tst.Close()
+			return nil, fmt.Errorf("cannot open the wal of %s: %w", tst.root, err)
+		}
+	}
+	tst.startLoop(epoch, cur)
+	return tst, nil
 }
 
 func (tst *tsTable) Index() *elementIndex {
@@ -232,6 +279,12 @@ func (tst *tsTable) Close() error {
 		tst.loopCloser.Done()
 		tst.loopCloser.CloseThenWait()
 	}
+	tst.windows.close()
+	if tst.wal != nil {
+		if err := tst.wal.Close(); err != nil {
+			tst.l.Error().Err(err).Str("root", tst.root).Msg("cannot close the wal")
+		}
+	}
 	tst.RLock()
 	defer tst.RUnlock()
 	if tst.snapshot == nil {
@@ -242,20 +295,36 @@ func (tst *tsTable) Close() error {
 	return tst.index.Close()
 }
 
-func (tst *tsTable) mustAddElements(es *elements) {
+// errElementPurged is returned for an element whose series is purged, which is still in the element index.
+var errElementPurged = errors.New("the element is purged")
+
+// addElements introduces the elements as a memory part without their index documents, see writeElements.
+func (tst *tsTable) addElements(es *elements) error {
+	return tst.writeElements(es, nil, nil)
+}
+
+// writeElements introduces the elements as a memory part.
+// The elements are logged by the wal first if it's enabled, along with their element documents and series documents,
+// so that replaying the wal restores the indexes as well. The error of the logging is returned.
+func (tst *tsTable) writeElements(es *elements, docs, seriesDocs index.Documents) error {
 	if len(es.seriesIDs) == 0 {
-		return
+		return nil
 	}
+	if tst.wal != nil {
+		tst.walMu.RLock()
+		defer tst.walMu.RUnlock()
+		if _, err := tst.wal.Append(marshalWALRecord(nil, es, docs, seriesDocs)); err != nil {
+			return fmt.Errorf("cannot log the elements: %w", err)
+		}
+	}
+	tst.introduceElements(es)
+	return nil
+}
 
-	mp := generateMemPart()
-	mp.mustInitFromElements(es)
-	p := openMemPart(mp)
-
-	ind := generateIntroduction()
+func (tst *tsTable) introduceElements(es *elements) {
+	ind := tst.newMemPartIntroduction(es)
 	defer releaseIntroduction(ind)
 	ind.applied = make(chan struct{})
-	ind.memPart = newPartWrapper(mp, p)
-	ind.memPart.p.partMetadata.ID = atomic.AddUint64(&tst.curPartID, 1)
 	select {
 	case tst.introductions <- ind:
 	case <-tst.loopCloser.CloseNotify():
@@ -264,21 +333,53 @@ func (tst *tsTable) mustAddElements(es *elements) {
 	select {
 	case <-ind.applied:
 	case <-tst.loopCloser.CloseNotify():
+		return
 	}
+	tst.windows.add(es)
+}
+
+// newMemPartIntroduction builds the memory part of the elements.
+func (tst *tsTable) newMemPartIntroduction(es *elements) *introduction {
+	mp := generateMemPart()
+	mp.sizing = tst.sizing
+	mp.tagCodec = tst.option.tagCodec
+	mp.mustInitFromElements(es)
+	p := openMemPart(mp)
+
+	ind := generateIntroduction()
+	ind.memPart = newPartWrapper(mp, p)
+	ind.memPart.p.partMetadata.ID = atomic.AddUint64(&tst.curPartID, 1)
+	ind.memPart.walOffsets = es.walOffsets
+	return ind
 }
 
 func (tst *tsTable) getElement(seriesID common.SeriesID, timestamp common.ItemID, tagProjection []pbv1.TagProjection) (*element, int, error) {
 	tst.RLock()
 	defer tst.RUnlock()
+	var purged bool
 	for _, p := range tst.currentSnapshot().parts {
 		if !p.p.containTimestamp(timestamp) {
 			continue
 		}
+		if tst.tombstones.covers(seriesID, p.ID()) {
+			purged = true
+			continue
+		}
+		if deletions := tst.tombstones.deletionsOf(nil, seriesID, p.ID(), int64(timestamp), int64(timestamp)); len(deletions) > 0 {
+			deleted, err := elementDeleted(p.p, seriesID, timestamp, deletions)
+			if err == nil && deleted {
+				purged = true
+				continue
+			}
+		}
 		elem, count, err := p.p.getElement(seriesID, timestamp, tagProjection)
 		if err == nil {
 			return elem, count, nil
 		}
 	}
+	if purged {
+		return nil, 0, fmt.Errorf("%w: seriesID %d and timestamp %d", errElementPurged, seriesID, timestamp)
+	}
 	return nil, 0, fmt.Errorf("cannot find element with seriesID %d and timestamp %d", seriesID, timestamp)
 }
 
@@ -310,6 +411,22 @@ func (ti *tstIter) reset() {
 	ti.nextBlockNoop = false
 }
 
+// trace adds the primary blocks the iterators of the parts read, or find in the caches, to the span.
+func (ti *tstIter) trace(span *executor.Span) {
+	if span == nil {
+		return
+	}
+	var hits, misses, bytesRead int64
+	for i := range ti.piPool {
+		hits += ti.piPool[i].cacheHits
+		misses += ti.piPool[i].cacheMisses
+		bytesRead += ti.piPool[i].bytesRead
+	}
+	span.Add(executor.CounterCacheHits, hits)
+	span.Add(executor.CounterCacheMisses, misses)
+	span.Add(executor.CounterBytesRead, bytesRead)
+}
+
 func (ti *tstIter) init(parts []*part, sids []common.SeriesID, minTimestamp, maxTimestamp int64) {
 	ti.reset()
 	ti.parts = parts
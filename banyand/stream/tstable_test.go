@@ -103,7 +103,7 @@ func Test_tsTable_mustAddDataPoints(t *testing.T) {
 			go tst.introducerLoop(flushCh, mergeCh, introducerWatcher, 1)
 			defer tst.Close()
 			for _, es := range tt.esList {
-				tst.mustAddElements(es)
+				require.NoError(t, tst.addElements(es))
 				time.Sleep(100 * time.Millisecond)
 			}
 			s := tst.currentSnapshot()
@@ -186,8 +186,8 @@ func Test_tstIter(t *testing.T) {
 				minTimestamp: 1,
 				maxTimestamp: 1,
 				want: []blockMetadata{
-					{seriesID: 1, count: 1, uncompressedSizeBytes: 881},
-					{seriesID: 2, count: 1, uncompressedSizeBytes: 55},
+					{seriesID: 1, count: 1, uncompressedSizeBytes: 863},
+					{seriesID: 2, count: 1, uncompressedSizeBytes: 48},
 					{seriesID: 3, count: 1, uncompressedSizeBytes: 8},
 				},
 			},
@@ -198,10 +198,10 @@ func Test_tstIter(t *testing.T) {
 				minTimestamp: 1,
 				maxTimestamp: 2,
 				want: []blockMetadata{
-					{seriesID: 1, count: 1, uncompressedSizeBytes: 881},
-					{seriesID: 1, count: 1, uncompressedSizeBytes: 881},
-					{seriesID: 2, count: 1, uncompressedSizeBytes: 55},
-					{seriesID: 2, count: 1, uncompressedSizeBytes: 55},
+					{seriesID: 1, count: 1, uncompressedSizeBytes: 863},
+					{seriesID: 1, count: 1, uncompressedSizeBytes: 863},
+					{seriesID: 2, count: 1, uncompressedSizeBytes: 48},
+					{seriesID: 2, count: 1, uncompressedSizeBytes: 48},
 					{seriesID: 3, count: 1, uncompressedSizeBytes: 8},
 					{seriesID: 3, count: 1, uncompressedSizeBytes: 8},
 				},
@@ -213,10 +213,10 @@ func Test_tstIter(t *testing.T) {
 				minTimestamp: 1,
 				maxTimestamp: 2,
 				want: []blockMetadata{
-					{seriesID: 1, count: 1, uncompressedSizeBytes: 881},
-					{seriesID: 1, count: 1, uncompressedSizeBytes: 881},
-					{seriesID: 2, count: 1, uncompressedSizeBytes: 55},
-					{seriesID: 2, count: 1, uncompressedSizeBytes: 55},
+					{seriesID: 1, count: 1, uncompressedSizeBytes: 863},
+					{seriesID: 1, count: 1, uncompressedSizeBytes: 863},
+					{seriesID: 2, count: 1, uncompressedSizeBytes: 48},
+					{seriesID: 2, count: 1, uncompressedSizeBytes: 48},
 					{seriesID: 3, count: 1, uncompressedSizeBytes: 8},
 					{seriesID: 3, count: 1, uncompressedSizeBytes: 8},
 				},
@@ -240,7 +240,7 @@ func Test_tstIter(t *testing.T) {
 				introducerWatcher := make(watcher.Channel, 1)
 				go tst.introducerLoop(flushCh, mergeCh, introducerWatcher, 1)
 				for _, es := range tt.esList {
-					tst.mustAddElements(es)
+					require.NoError(t, tst.addElements(es))
 					time.Sleep(100 * time.Millisecond)
 				}
 				verify(t, tt, tst)
@@ -264,8 +264,8 @@ func Test_tstIter(t *testing.T) {
 				minTimestamp: 1,
 				maxTimestamp: 1,
 				want: []blockMetadata{
-					{seriesID: 1, count: 1, uncompressedSizeBytes: 881},
-					{seriesID: 2, count: 1, uncompressedSizeBytes: 55},
+					{seriesID: 1, count: 1, uncompressedSizeBytes: 863},
+					{seriesID: 2, count: 1, uncompressedSizeBytes: 48},
 					{seriesID: 3, count: 1, uncompressedSizeBytes: 8},
 				},
 			},
@@ -276,8 +276,8 @@ func Test_tstIter(t *testing.T) {
 				minTimestamp: 1,
 				maxTimestamp: 2,
 				want: []blockMetadata{
-					{seriesID: 1, count: 3, uncompressedSizeBytes: 2643},
-					{seriesID: 2, count: 3, uncompressedSizeBytes: 165},
+					{seriesID: 1, count: 3, uncompressedSizeBytes: 2589},
+					{seriesID: 2, count: 3, uncompressedSizeBytes: 144},
 					{seriesID: 3, count: 3, uncompressedSizeBytes: 24},
 				},
 			},
@@ -288,8 +288,8 @@ func Test_tstIter(t *testing.T) {
 				minTimestamp: 1,
 				maxTimestamp: 2,
 				want: []blockMetadata{
-					{seriesID: 1, count: 2, uncompressedSizeBytes: 1762},
-					{seriesID: 2, count: 2, uncompressedSizeBytes: 110},
+					{seriesID: 1, count: 2, uncompressedSizeBytes: 1726},
+					{seriesID: 2, count: 2, uncompressedSizeBytes: 96},
 					{seriesID: 3, count: 2, uncompressedSizeBytes: 16},
 				},
 			},
@@ -305,7 +305,7 @@ func Test_tstIter(t *testing.T) {
 						logger.GetLogger("test"), timestamp.TimeRange{}, option{flushTimeout: 0, elementIndexFlushTimeout: 0, mergePolicy: newDefaultMergePolicyForTesting()})
 					require.NoError(t, err)
 					for i, es := range tt.esList {
-						tst.mustAddElements(es)
+						require.NoError(t, tst.addElements(es))
 						for {
 							snp := tst.currentSnapshot()
 							if snp == nil {
@@ -358,7 +358,7 @@ func Test_tstIter(t *testing.T) {
 						})
 					require.NoError(t, err)
 					for _, es := range tt.esList {
-						tst.mustAddElements(es)
+						require.NoError(t, tst.addElements(es))
 						time.Sleep(100 * time.Millisecond)
 					}
 					// wait until the introducer is done
@@ -0,0 +1,309 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/index"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+// maxIndexInconsistencies bounds the inconsistent entries a data node reports, while all of them are counted.
+const maxIndexInconsistencies = 100
+
+type verifyIndexCallback struct {
+	l          *logger.Logger
+	schemaRepo *schemaRepo
+}
+
+func setUpVerifyIndexCallback(l *logger.Logger, schemaRepo *schemaRepo) bus.MessageListener {
+	return &verifyIndexCallback{
+		l:          l,
+		schemaRepo: schemaRepo,
+	}
+}
+
+func (v *verifyIndexCallback) Rev(message bus.Message) (resp bus.Message) {
+	req, ok := message.Data().(*streamv1.VerifyIndexRequest)
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("invalid event data type"))
+	}
+	s, ok := v.schemaRepo.loadStream(req.GetMetadata())
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("stream %s doesn't exist", req.GetMetadata()))
+	}
+	result, err := s.verifyIndex(message.Context(), req)
+	if err != nil {
+		v.l.Error().Err(err).Stringer("stream", req.GetMetadata()).Msg("cannot verify the index")
+		return bus.NewMessage(message.ID(), common.NewError("cannot verify the index of the stream %s: %v", req.GetMetadata(), err))
+	}
+	if result.GetMissing() > 0 || result.GetOrphaned() > 0 {
+		v.l.Warn().Stringer("stream", req.GetMetadata()).Uint64("missing", result.GetMissing()).
+			Uint64("orphaned", result.GetOrphaned()).Uint64("repaired", result.GetRepaired()).Msg("found the inconsistent index entries")
+	}
+	return bus.NewMessage(message.ID(), result)
+}
+
+// expectedDocument is the index document of an element built from the tags in the parts.
+type expectedDocument struct {
+	elementID string
+	fields    []index.Field
+	visited   bool
+}
+
+// verifyIndex cross-checks the index documents of the elements in the time range against the elements in the parts.
+// An element is missing if its document is absent or has different terms of the index rules, and a document is orphaned
+// if its element isn't in the parts or has none of the indexed tags. The terms of the rules no longer bound to the stream aren't compared.
+// The documents of the missing elements are written again and the orphaned ones are removed if the request asks to repair.
+func (s *stream) verifyIndex(ctx context.Context, req *streamv1.VerifyIndexRequest) (*streamv1.VerifyIndexResponse, error) {
+	entity, tags, err := s.parseDeleteConditions(req.GetConditions())
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		return nil, errors.Errorf("the tag %s isn't an entity tag", tags[0].Name)
+	}
+	sources, projection, err := s.indexSources()
+	if err != nil {
+		return nil, err
+	}
+	result := &streamv1.VerifyIndexResponse{}
+	if len(sources) == 0 {
+		return result, nil
+	}
+	tr := timestamp.NewInclusiveTimeRange(req.GetTimeRange().GetBegin().AsTime(), req.GetTimeRange().GetEnd().AsTime())
+	tsdb := s.databaseSupplier.SupplyTSDB().(storage.TSDB[*tsTable, option])
+	sl, err := tsdb.Lookup(ctx, &pbv1.Series{Subject: s.name, EntityValues: entity})
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot look up the series")
+	}
+	if len(sl) == 0 {
+		return result, nil
+	}
+	series := make(map[common.SeriesID]*pbv1.Series, len(sl))
+	sids := make([]common.SeriesID, 0, len(sl))
+	for i := range sl {
+		series[sl[i].ID] = sl[i]
+		sids = append(sids, sl[i].ID)
+	}
+	slices.Sort(sids)
+	analyzers := make(map[uint32]databasev1.IndexRule_Analyzer, len(s.indexRuleLocators))
+	for _, l := range s.indexRuleLocators {
+		analyzers[l.Rule.GetMetadata().GetId()] = l.Rule.GetAnalyzer()
+	}
+	v := &indexVerifier{
+		ri:        newIndexRebuilder(s, sources, projection, series),
+		analyzers: analyzers,
+		sids:      sids,
+		tr:        tr,
+		repair:    req.GetRepair(),
+		result:    result,
+	}
+	tabWrappers := tsdb.SelectTSTables(tr)
+	defer func() {
+		for i := range tabWrappers {
+			tabWrappers[i].DecRef()
+		}
+	}()
+	for _, tw := range tabWrappers {
+		if err = v.verifyTable(ctx, tw.Table()); err != nil {
+			return nil, errors.WithMessagef(err, "cannot verify the segment %s", tw.GetTimeRange())
+		}
+	}
+	return result, nil
+}
+
+type indexVerifier struct {
+	ri        *indexRebuilder
+	analyzers map[uint32]databasev1.IndexRule_Analyzer
+	result    *streamv1.VerifyIndexResponse
+	sids      []common.SeriesID
+	tr        timestamp.TimeRange
+	repair    bool
+}
+
+func (v *indexVerifier) analyzerOf(indexRuleID uint32) databasev1.IndexRule_Analyzer {
+	return v.analyzers[indexRuleID]
+}
+
+// verifyTable compares the documents of a tsTable with the elements of its parts, series by series.
+func (v *indexVerifier) verifyTable(ctx context.Context, tst *tsTable) error {
+	minTimestamp, maxTimestamp := v.tr.Start.UnixNano(), v.tr.End.UnixNano()
+	expected, err := v.expectedDocuments(ctx, tst, minTimestamp, maxTimestamp)
+	if err != nil {
+		return err
+	}
+	var docs index.Documents
+	var deletions []index.SeriesDocID
+	for _, sid := range v.sids {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		elements := expected[sid]
+		err = tst.Index().VisitDocuments(sid, minTimestamp, maxTimestamp, v.analyzerOf, func(doc index.Document) error {
+			ed, ok := elements[int64(doc.DocID)]
+			if !ok {
+				v.result.Orphaned++
+				v.report(sid, int64(doc.DocID), "")
+				deletions = append(deletions, index.SeriesDocID{SeriesID: sid, DocID: doc.DocID})
+				return nil
+			}
+			ed.visited = true
+			if !sameTerms(ed.fields, doc.Fields, v.analyzers) {
+				v.result.Missing++
+				v.report(sid, int64(doc.DocID), ed.elementID)
+				docs = append(docs, index.Document{DocID: doc.DocID, Fields: ed.fields})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for ts, ed := range elements {
+			if ed.visited {
+				continue
+			}
+			v.result.Missing++
+			v.report(sid, ts, ed.elementID)
+			docs = append(docs, index.Document{DocID: uint64(ts), Fields: ed.fields})
+		}
+	}
+	if !v.repair {
+		return nil
+	}
+	if len(docs) > 0 {
+		if err = tst.Index().Write(docs); err != nil {
+			return err
+		}
+	}
+	if len(deletions) > 0 {
+		if err = tst.Index().Delete(deletions); err != nil {
+			return err
+		}
+	}
+	v.result.Repaired += uint64(len(docs) + len(deletions))
+	return nil
+}
+
+// expectedDocuments builds the documents of the elements in the parts of the tsTable, by their series and timestamps.
+// The elements without any indexed tag have no document.
+func (v *indexVerifier) expectedDocuments(ctx context.Context, tst *tsTable, minTimestamp, maxTimestamp int64,
+) (map[common.SeriesID]map[int64]*expectedDocument, error) {
+	expected := make(map[common.SeriesID]map[int64]*expectedDocument)
+	snp := tst.currentSnapshot()
+	if snp == nil {
+		return expected, nil
+	}
+	defer snp.decRef()
+	b := generateBlock()
+	defer releaseBlock(b)
+	var pi partIter
+	for _, pw := range snp.parts {
+		pi.init(pw.p, v.sids, minTimestamp, maxTimestamp)
+		for pi.nextBlock() {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			bm := pi.curBlock
+			if pw.p.tombstones.covers(bm.seriesID, pw.p.partMetadata.ID) {
+				continue
+			}
+			bm.tagProjection = v.ri.projection
+			v.ri.decoder.Reset()
+			if err := b.readFrom(&v.ri.decoder, pw.p, bm); err != nil {
+				return nil, err
+			}
+			for idx, ts := range b.timestamps {
+				if ts < minTimestamp || ts > maxTimestamp {
+					continue
+				}
+				fields := appendIndexFields(nil, v.ri.locators, v.ri.tagValues(b, idx, v.ri.series[bm.seriesID]), bm.seriesID)
+				if len(fields) == 0 {
+					continue
+				}
+				elements, ok := expected[bm.seriesID]
+				if !ok {
+					elements = make(map[int64]*expectedDocument)
+					expected[bm.seriesID] = elements
+				}
+				if _, ok = elements[ts]; !ok {
+					v.result.Elements++
+				}
+				elements[ts] = &expectedDocument{elementID: strings.Clone(b.elementIDs[idx]), fields: fields}
+			}
+		}
+		if err := pi.error(); err != nil {
+			return nil, err
+		}
+	}
+	return expected, nil
+}
+
+func (v *indexVerifier) report(sid common.SeriesID, ts int64, elementID string) {
+	if len(v.result.Inconsistencies) >= maxIndexInconsistencies {
+		return
+	}
+	v.result.Inconsistencies = append(v.result.Inconsistencies, &streamv1.IndexInconsistency{
+		SeriesId:  uint64(sid),
+		Timestamp: timestamppb.New(time.Unix(0, ts)),
+		ElementId: elementID,
+		Orphaned:  elementID == "",
+	})
+}
+
+// sameTerms reports whether the document has the same terms of the index rules as the expected fields.
+// The fields of the other rules in the document are ignored.
+func sameTerms(expected, actual []index.Field, rules map[uint32]databasev1.IndexRule_Analyzer) bool {
+	actual = slices.DeleteFunc(slices.Clone(actual), func(f index.Field) bool {
+		_, ok := rules[f.Key.IndexRuleID]
+		return !ok
+	})
+	if len(expected) != len(actual) {
+		return false
+	}
+	compare := func(a, b index.Field) int {
+		if a.Key.IndexRuleID != b.Key.IndexRuleID {
+			if a.Key.IndexRuleID < b.Key.IndexRuleID {
+				return -1
+			}
+			return 1
+		}
+		return bytes.Compare(a.Term, b.Term)
+	}
+	expected = slices.Clone(expected)
+	slices.SortFunc(expected, compare)
+	slices.SortFunc(actual, compare)
+	return slices.EqualFunc(expected, actual, func(a, b index.Field) bool {
+		return compare(a, b) == 0
+	})
+}
@@ -0,0 +1,115 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/index"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func Test_indexVerifier_verifyTable(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	tst, err := newTSTable(fs.NewLocalFileSystem(), tmpPath, common.Position{}, logger.GetLogger("test"),
+		timestamp.TimeRange{}, option{flushTimeout: defaultFlushTimeout, mergePolicy: newDefaultMergePolicyForTesting()})
+	require.NoError(t, err)
+	defer tst.Close()
+	require.NoError(t, tst.addElements(esTS1))
+
+	s := newRebuildTestStream(false)
+	sources, projection, err := s.indexSources()
+	require.NoError(t, err)
+	series := map[common.SeriesID]*pbv1.Series{
+		1: {ID: 1, EntityValues: []*modelv1.TagValue{strTagValue("svc1")}},
+		2: {ID: 2, EntityValues: []*modelv1.TagValue{strTagValue("svc2")}},
+	}
+	keyword := databasev1.IndexRule_ANALYZER_UNSPECIFIED
+	verify := func(repair bool) *streamv1.VerifyIndexResponse {
+		v := &indexVerifier{
+			ri:        newIndexRebuilder(s, sources, projection, series),
+			analyzers: map[uint32]databasev1.IndexRule_Analyzer{1: keyword, 2: keyword, 3: keyword},
+			sids:      []common.SeriesID{1, 2, 3},
+			tr:        timestamp.NewInclusiveTimeRange(time.Unix(0, 0), time.Unix(0, 10)),
+			repair:    repair,
+			result:    &streamv1.VerifyIndexResponse{},
+		}
+		require.NoError(t, v.verifyTable(context.Background(), tst))
+		return v.result
+	}
+
+	result := verify(false)
+	assert.Equal(t, uint64(2), result.Elements, "the element of the series 3 has none of the indexed tags")
+	assert.Equal(t, uint64(2), result.Missing)
+	assert.Zero(t, result.Repaired)
+	require.Len(t, result.Inconsistencies, 2)
+	assert.False(t, result.Inconsistencies[0].Orphaned)
+
+	require.NoError(t, tst.Index().Write(index.Documents{{
+		DocID: 5,
+		Fields: []index.Field{{
+			Key:  index.FieldKey{IndexRuleID: 1, SeriesID: 1},
+			Term: []byte("value5"),
+		}},
+	}}))
+	result = verify(true)
+	assert.Equal(t, uint64(2), result.Missing)
+	assert.Equal(t, uint64(1), result.Orphaned)
+	assert.Equal(t, uint64(3), result.Repaired)
+	assert.Equal(t, "", result.Inconsistencies[0].ElementId, "the orphaned entries of a series are found before the absent ones")
+
+	result = verify(false)
+	assert.Equal(t, uint64(2), result.Elements)
+	assert.Zero(t, result.Missing)
+	assert.Zero(t, result.Orphaned)
+	assert.Empty(t, result.Inconsistencies)
+
+	pl, err := tst.Index().store.MatchTerms(index.Field{
+		Key:  index.FieldKey{IndexRuleID: 2, SeriesID: 1},
+		Term: convert.Int64ToBytes(10),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1}, pl.ToSlice())
+}
+
+func Test_sameTerms(t *testing.T) {
+	keyword := databasev1.IndexRule_ANALYZER_UNSPECIFIED
+	rules := map[uint32]databasev1.IndexRule_Analyzer{1: keyword, 2: keyword}
+	f := func(ruleID uint32, term string) index.Field {
+		return index.Field{Key: index.FieldKey{IndexRuleID: ruleID}, Term: []byte(term)}
+	}
+	assert.True(t, sameTerms([]index.Field{f(1, "a"), f(2, "b")}, []index.Field{f(2, "b"), f(1, "a")}, rules))
+	assert.True(t, sameTerms([]index.Field{f(1, "a")}, []index.Field{f(1, "a"), f(3, "c")}, rules), "the rule 3 isn't bound")
+	assert.False(t, sameTerms([]index.Field{f(1, "a")}, []index.Field{f(1, "b")}, rules))
+	assert.False(t, sameTerms([]index.Field{f(1, "a"), f(2, "b")}, []index.Field{f(1, "a")}, rules))
+}
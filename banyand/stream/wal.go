@@ -0,0 +1,440 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"time"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/storage"
+	"github.com/apache/skywalking-banyandb/banyand/internal/wal"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+	"github.com/apache/skywalking-banyandb/pkg/index"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+// walDirname is the directory under the root of a tsTable holding the write-ahead log of the elements.
+const walDirname = "wal"
+
+var (
+	walProvider             = observability.NewMeterProvider(observability.RootScope.SubScope("stream").SubScope("wal"))
+	walCommittedBatches     = walProvider.Counter("committed_batches_total", "group")
+	walCommittedRecords     = walProvider.Counter("committed_records_total", "group")
+	walReplayedElementCount = walProvider.Counter("replayed_elements_total", "group")
+)
+
+type walOptions struct {
+	enabled      bool
+	sync         wal.SyncPolicy
+	syncInterval time.Duration
+}
+
+// openWAL opens the write-ahead log of the table, and replays the records not flushed before.
+// It runs before the loops of the table start, so the replayed elements are introduced as memory parts directly
+// from the epoch, and the epoch of the last one is returned.
+func (tst *tsTable) openWAL(epoch uint64) (uint64, error) {
+	group := tst.p.Database
+	w, err := wal.Open(filepath.Join(tst.root, walDirname), wal.Options{
+		Sync:         tst.option.wal.sync,
+		SyncInterval: tst.option.wal.syncInterval,
+		OnCommit: func(records int) {
+			walCommittedBatches.Inc(1, group)
+			walCommittedRecords.Inc(float64(records), group)
+		},
+		OnResize: watchdog.AddUnflushedWAL,
+	}, tst.l)
+	if err != nil {
+		return 0, err
+	}
+	tst.wal = w
+	var records int
+	err = w.Replay(w.FirstOffset()-1, func(offset uint64, payload []byte) error {
+		introduced, errReplay := tst.replayRecord(payload, epoch+1)
+		if errReplay != nil {
+			return fmt.Errorf("cannot replay the record at %d: %w", offset, errReplay)
+		}
+		if introduced {
+			epoch++
+		}
+		records++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if records > 0 {
+		tst.l.Info().Str("root", tst.root).Int("records", records).Msg("replayed the wal")
+	}
+	return epoch, nil
+}
+
+// replayRecord writes the index documents of a wal record, and introduces its elements as a memory part at the epoch.
+// The elements found in the file parts are dropped, which are flushed before the segment holding them is removed.
+// The series documents are kept until the database writes them to its series index, see writeReplayedSeries.
+func (tst *tsTable) replayRecord(payload []byte, epoch uint64) (bool, error) {
+	es := &elements{}
+	docs, seriesDocs, err := unmarshalWALRecord(es, payload)
+	if err != nil {
+		return false, err
+	}
+	// the documents are indexed by their ids, writing them again is harmless
+	if len(docs) > 0 {
+		if err = tst.index.Write(docs); err != nil {
+			return false, fmt.Errorf("cannot write the element index: %w", err)
+		}
+	}
+	tst.replayedSeries = append(tst.replayedSeries, seriesDocs...)
+	if snp := tst.currentSnapshot(); snp != nil {
+		var retained int
+		for i := range es.seriesIDs {
+			if snp.flushedElement(es.seriesIDs[i], es.timestamps[i], es.elementIDs[i]) {
+				continue
+			}
+			es.seriesIDs[retained] = es.seriesIDs[i]
+			es.timestamps[retained] = es.timestamps[i]
+			es.elementIDs[retained] = es.elementIDs[i]
+			es.tagFamilies[retained] = es.tagFamilies[i]
+			retained++
+		}
+		snp.decRef()
+		es.seriesIDs = es.seriesIDs[:retained]
+		es.timestamps = es.timestamps[:retained]
+		es.elementIDs = es.elementIDs[:retained]
+		es.tagFamilies = es.tagFamilies[:retained]
+	}
+	if len(es.seriesIDs) == 0 {
+		return false, nil
+	}
+	ind := tst.newMemPartIntroduction(es)
+	defer releaseIntroduction(ind)
+	tst.introduceMemPart(ind, epoch)
+	walReplayedElementCount.Inc(float64(len(es.seriesIDs)), tst.p.Database)
+	return true, nil
+}
+
+// writeReplayedSeries writes the series documents replayed by the tables of the database to its series index.
+func writeReplayedSeries(db storage.TSDB[*tsTable, option]) error {
+	tabWrappers := db.SelectTSTables(timestamp.NewInclusiveTimeRange(time.Unix(0, 0), time.Unix(0, math.MaxInt64)))
+	defer func() {
+		for i := range tabWrappers {
+			tabWrappers[i].DecRef()
+		}
+	}()
+	for _, tw := range tabWrappers {
+		tst := tw.Table()
+		docs := tst.replayedSeries
+		tst.replayedSeries = nil
+		if len(docs) == 0 {
+			continue
+		}
+		if err := db.IndexDB().Write(docs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushedElement reports whether a file part of the snapshot holds the element.
+func (s *snapshot) flushedElement(seriesID common.SeriesID, ts int64, elementID string) bool {
+	for _, pw := range s.parts {
+		if pw.mp != nil || !pw.p.containTimestamp(common.ItemID(ts)) {
+			continue
+		}
+		if e, _, err := pw.p.getElement(seriesID, common.ItemID(ts), nil); err == nil && e.elementID == elementID {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotToFlush returns the snapshot the flusher works on if it's newer than the epoch.
+// The active segment of the wal is closed along with taking the snapshot, and the offset of its last record is returned,
+// so that the records of the closed segments are all in the memory parts of the snapshot.
+func (tst *tsTable) snapshotToFlush(epoch uint64) (*snapshot, uint64) {
+	if tst.wal != nil {
+		tst.walMu.Lock()
+		defer tst.walMu.Unlock()
+	}
+	tst.RLock()
+	if tst.snapshot == nil || tst.snapshot.epoch <= epoch {
+		tst.RUnlock()
+		return nil, 0
+	}
+	snp := tst.snapshot
+	snp.incRef()
+	tst.RUnlock()
+	if tst.wal == nil {
+		return snp, 0
+	}
+	offset, err := tst.wal.Rotate()
+	if err != nil {
+		tst.l.Warn().Err(err).Str("root", tst.root).Msg("cannot rotate the wal")
+		return snp, 0
+	}
+	return snp, offset
+}
+
+// truncateWAL removes the wal segments up to the offset once the memory parts holding their records are flushed.
+func (tst *tsTable) truncateWAL(offset uint64) {
+	if tst.wal == nil || offset == 0 || tst.loopCloser.Closed() {
+		return
+	}
+	tst.wal.Truncate(offset)
+}
+
+const (
+	walTagBloomFilter byte = 1 << iota
+	walTagValue
+	walTagValueArr
+)
+
+// marshalWALRecord encodes the elements along with their element documents and series documents as the payload of a wal record.
+func marshalWALRecord(dst []byte, es *elements, docs, seriesDocs index.Documents) []byte {
+	dst = marshalElements(dst, es)
+	dst = marshalDocuments(dst, docs)
+	return marshalDocuments(dst, seriesDocs)
+}
+
+// unmarshalWALRecord appends the elements decoded from the payload of a wal record to es,
+// and returns the element documents and the series documents.
+func unmarshalWALRecord(es *elements, src []byte) (index.Documents, index.Documents, error) {
+	src, err := unmarshalElements(es, src)
+	if err != nil {
+		return nil, nil, err
+	}
+	var docs, seriesDocs index.Documents
+	if src, docs, err = unmarshalDocuments(src); err != nil {
+		return nil, nil, fmt.Errorf("cannot unmarshal the element documents: %w", err)
+	}
+	if src, seriesDocs, err = unmarshalDocuments(src); err != nil {
+		return nil, nil, fmt.Errorf("cannot unmarshal the series documents: %w", err)
+	}
+	if len(src) > 0 {
+		return nil, nil, fmt.Errorf("unexpected %d bytes after the documents", len(src))
+	}
+	return docs, seriesDocs, nil
+}
+
+// marshalElements encodes the elements.
+func marshalElements(dst []byte, es *elements) []byte {
+	dst = encoding.VarUint64ToBytes(dst, uint64(len(es.seriesIDs)))
+	for i := range es.seriesIDs {
+		dst = es.seriesIDs[i].AppendToBytes(dst)
+		dst = encoding.VarInt64ToBytes(dst, es.timestamps[i])
+		dst = encoding.EncodeBytes(dst, []byte(es.elementIDs[i]))
+		dst = encoding.VarUint64ToBytes(dst, uint64(len(es.tagFamilies[i])))
+		for _, tf := range es.tagFamilies[i] {
+			dst = encoding.EncodeBytes(dst, []byte(tf.tag))
+			dst = encoding.VarUint64ToBytes(dst, uint64(len(tf.values)))
+			for _, tv := range tf.values {
+				dst = encoding.EncodeBytes(dst, []byte(tv.tag))
+				var flags byte
+				if tv.bloomFilter {
+					flags |= walTagBloomFilter
+				}
+				if tv.value != nil {
+					flags |= walTagValue
+				}
+				if tv.valueArr != nil {
+					flags |= walTagValueArr
+				}
+				dst = append(dst, byte(tv.valueType), flags)
+				if tv.value != nil {
+					dst = encoding.EncodeBytes(dst, tv.value)
+				}
+				if tv.valueArr != nil {
+					dst = encoding.VarUint64ToBytes(dst, uint64(len(tv.valueArr)))
+					for _, v := range tv.valueArr {
+						dst = encoding.EncodeBytes(dst, v)
+					}
+				}
+			}
+		}
+	}
+	return dst
+}
+
+// unmarshalElements appends the elements decoded from src to es, and returns the rest of src.
+func unmarshalElements(es *elements, src []byte) ([]byte, error) {
+	src, n, err := encoding.BytesToVarUint64(src)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal the count of elements: %w", err)
+	}
+	var b []byte
+	var count uint64
+	for i := uint64(0); i < n; i++ {
+		if len(src) < 8 {
+			return nil, errors.New("cannot unmarshal seriesID from less than 8 bytes")
+		}
+		es.seriesIDs = append(es.seriesIDs, common.SeriesID(encoding.BytesToUint64(src)))
+		var ts int64
+		if src, ts, err = encoding.BytesToVarInt64(src[8:]); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal timestamp: %w", err)
+		}
+		es.timestamps = append(es.timestamps, ts)
+		if src, b, err = encoding.DecodeBytes(src); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal elementID: %w", err)
+		}
+		es.elementIDs = append(es.elementIDs, string(b))
+		if src, count, err = encoding.BytesToVarUint64(src); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal the count of tag families: %w", err)
+		}
+		tff := make([]tagValues, count)
+		for j := range tff {
+			if src, err = unmarshalTagValues(&tff[j], src); err != nil {
+				return nil, err
+			}
+		}
+		es.tagFamilies = append(es.tagFamilies, tff)
+	}
+	return src, nil
+}
+
+func unmarshalTagValues(tf *tagValues, src []byte) ([]byte, error) {
+	src, b, err := encoding.DecodeBytes(src)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal tag family name: %w", err)
+	}
+	tf.tag = string(b)
+	src, n, err := encoding.BytesToVarUint64(src)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal the count of tags: %w", err)
+	}
+	for i := uint64(0); i < n; i++ {
+		tv := &tagValue{}
+		if src, b, err = encoding.DecodeBytes(src); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal tag name: %w", err)
+		}
+		tv.tag = string(b)
+		if len(src) < 2 {
+			return nil, fmt.Errorf("cannot unmarshal the type of tag %s", tv.tag)
+		}
+		tv.valueType = pbv1.ValueType(src[0])
+		flags := src[1]
+		src = src[2:]
+		tv.bloomFilter = flags&walTagBloomFilter != 0
+		if flags&walTagValue != 0 {
+			if src, b, err = encoding.DecodeBytes(src); err != nil {
+				return nil, fmt.Errorf("cannot unmarshal the value of tag %s: %w", tv.tag, err)
+			}
+			tv.value = append(make([]byte, 0, len(b)), b...)
+		}
+		if flags&walTagValueArr != 0 {
+			var count uint64
+			if src, count, err = encoding.BytesToVarUint64(src); err != nil {
+				return nil, fmt.Errorf("cannot unmarshal the count of values of tag %s: %w", tv.tag, err)
+			}
+			tv.valueArr = make([][]byte, count)
+			for j := range tv.valueArr {
+				if src, b, err = encoding.DecodeBytes(src); err != nil {
+					return nil, fmt.Errorf("cannot unmarshal the values of tag %s: %w", tv.tag, err)
+				}
+				tv.valueArr[j] = append(make([]byte, 0, len(b)), b...)
+			}
+		}
+		tf.values = append(tf.values, tv)
+	}
+	return src, nil
+}
+
+// marshalDocuments encodes the index documents.
+func marshalDocuments(dst []byte, docs index.Documents) []byte {
+	dst = encoding.VarUint64ToBytes(dst, uint64(len(docs)))
+	for i := range docs {
+		dst = encoding.VarUint64ToBytes(dst, docs[i].DocID)
+		dst = encoding.EncodeBytes(dst, docs[i].EntityValues)
+		dst = encoding.VarUint64ToBytes(dst, uint64(len(docs[i].Fields)))
+		for _, f := range docs[i].Fields {
+			dst = f.Key.SeriesID.AppendToBytes(dst)
+			dst = encoding.VarUint64ToBytes(dst, uint64(f.Key.IndexRuleID))
+			dst = encoding.VarInt64ToBytes(dst, int64(f.Key.Analyzer))
+			var numeric byte
+			if f.Key.Numeric {
+				numeric = 1
+			}
+			dst = append(dst, numeric)
+			dst = encoding.EncodeBytes(dst, f.Term)
+		}
+	}
+	return dst
+}
+
+// unmarshalDocuments decodes the index documents from src, and returns the rest of src.
+func unmarshalDocuments(src []byte) ([]byte, index.Documents, error) {
+	src, n, err := encoding.BytesToVarUint64(src)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot unmarshal the count of documents: %w", err)
+	}
+	if n == 0 {
+		return src, nil, nil
+	}
+	docs := make(index.Documents, n)
+	var b []byte
+	var count, u uint64
+	var v int64
+	for i := range docs {
+		if src, docs[i].DocID, err = encoding.BytesToVarUint64(src); err != nil {
+			return nil, nil, fmt.Errorf("cannot unmarshal docID: %w", err)
+		}
+		if src, b, err = encoding.DecodeBytes(src); err != nil {
+			return nil, nil, fmt.Errorf("cannot unmarshal entity values: %w", err)
+		}
+		if len(b) > 0 {
+			docs[i].EntityValues = append(make([]byte, 0, len(b)), b...)
+		}
+		if src, count, err = encoding.BytesToVarUint64(src); err != nil {
+			return nil, nil, fmt.Errorf("cannot unmarshal the count of fields: %w", err)
+		}
+		if count == 0 {
+			continue
+		}
+		docs[i].Fields = make([]index.Field, count)
+		for j := range docs[i].Fields {
+			f := &docs[i].Fields[j]
+			if len(src) < 8 {
+				return nil, nil, errors.New("cannot unmarshal the seriesID of a field from less than 8 bytes")
+			}
+			f.Key.SeriesID = common.SeriesID(encoding.BytesToUint64(src))
+			if src, u, err = encoding.BytesToVarUint64(src[8:]); err != nil {
+				return nil, nil, fmt.Errorf("cannot unmarshal indexRuleID: %w", err)
+			}
+			f.Key.IndexRuleID = uint32(u)
+			if src, v, err = encoding.BytesToVarInt64(src); err != nil {
+				return nil, nil, fmt.Errorf("cannot unmarshal analyzer: %w", err)
+			}
+			f.Key.Analyzer = databasev1.IndexRule_Analyzer(v)
+			if len(src) < 1 {
+				return nil, nil, errors.New("cannot unmarshal the numeric flag of a field")
+			}
+			f.Key.Numeric = src[0] == 1
+			if src, b, err = encoding.DecodeBytes(src[1:]); err != nil {
+				return nil, nil, fmt.Errorf("cannot unmarshal term: %w", err)
+			}
+			f.Term = append(make([]byte, 0, len(b)), b...)
+		}
+	}
+	return src, docs, nil
+}
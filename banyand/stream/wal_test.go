@@ -0,0 +1,146 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/wal"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/index"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+	"github.com/apache/skywalking-banyandb/pkg/test/flags"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func Test_marshalWALRecord(t *testing.T) {
+	src := &elements{
+		seriesIDs:  []common.SeriesID{1, 2, 3},
+		timestamps: []int64{-1, 0, 1 << 60},
+		elementIDs: []string{"11", "", "31"},
+		tagFamilies: [][]tagValues{
+			esTS1.tagFamilies[0],
+			{
+				{
+					tag: "nullable", values: []*tagValue{
+						{tag: "null", valueType: pbv1.ValueTypeStr},
+						{tag: "empty", valueType: pbv1.ValueTypeStr, value: []byte{}},
+						{tag: "bloom", valueType: pbv1.ValueTypeStr, value: []byte("trace"), bloomFilter: true},
+						{tag: "emptyArr", valueType: pbv1.ValueTypeStrArr, valueArr: [][]byte{}},
+					},
+				},
+			},
+			{},
+		},
+	}
+	docs := index.Documents{
+		{DocID: 1, Fields: []index.Field{
+			{Key: index.FieldKey{SeriesID: 1, IndexRuleID: 2, Analyzer: databasev1.IndexRule_ANALYZER_SIMPLE}, Term: []byte("value1")},
+			{Key: index.FieldKey{SeriesID: 1, IndexRuleID: 3, Numeric: true}, Term: convert.Int64ToBytes(10)},
+		}},
+		{DocID: 2},
+	}
+	seriesDocs := index.Documents{{DocID: 1, EntityValues: []byte("entity1")}}
+	got := &elements{}
+	gotDocs, gotSeriesDocs, err := unmarshalWALRecord(got, marshalWALRecord(nil, src, docs, seriesDocs))
+	require.NoError(t, err)
+	assert.Equal(t, src, got)
+	assert.Equal(t, docs, gotDocs)
+	assert.Equal(t, seriesDocs, gotSeriesDocs)
+
+	gotDocs, gotSeriesDocs, err = unmarshalWALRecord(&elements{}, marshalWALRecord(nil, src, nil, nil))
+	require.NoError(t, err)
+	assert.Nil(t, gotDocs)
+	assert.Nil(t, gotSeriesDocs)
+
+	payload := marshalWALRecord(nil, src, docs, seriesDocs)
+	_, _, err = unmarshalWALRecord(&elements{}, payload[:len(payload)-1])
+	require.Error(t, err)
+}
+
+func Test_tsTable_wal(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	fileSystem := fs.NewLocalFileSystem()
+	newTable := func(flushTimeout time.Duration) *tsTable {
+		tst, err := newTSTable(fileSystem, tmpPath, common.Position{Database: "default"}, logger.GetLogger("test"),
+			timestamp.TimeRange{}, option{
+				flushTimeout: flushTimeout, mergePolicy: newDefaultMergePolicyForTesting(),
+				wal: walOptions{enabled: true, sync: wal.SyncInterval, syncInterval: 10 * time.Millisecond},
+			})
+		require.NoError(t, err)
+		return tst
+	}
+
+	// the memory parts are never flushed before the table is closed, and the documents are only logged
+	docs := index.Documents{{DocID: 1, Fields: []index.Field{{Key: index.FieldKey{SeriesID: 2, IndexRuleID: 1}, Term: []byte("value1")}}}}
+	seriesDocs := index.Documents{{DocID: 2, EntityValues: []byte("entity2")}}
+	tst := newTable(time.Hour)
+	require.NoError(t, tst.writeElements(esTS1, docs, seriesDocs))
+	require.NoError(t, tst.Close())
+
+	tst = newTable(time.Hour)
+	e, _, err := tst.getElement(2, 1, nil)
+	require.NoError(t, err, "the elements are replayed from the wal")
+	assert.Equal(t, "21", e.elementID)
+	var visited []uint64
+	require.NoError(t, tst.Index().VisitDocuments(2, 0, 10, func(uint32) databasev1.IndexRule_Analyzer {
+		return databasev1.IndexRule_ANALYZER_UNSPECIFIED
+	}, func(doc index.Document) error {
+		visited = append(visited, doc.DocID)
+		return nil
+	}))
+	assert.Equal(t, []uint64{1}, visited, "the element documents are replayed to the element index")
+	assert.Equal(t, seriesDocs, tst.replayedSeries, "the series documents are kept for the series index")
+	require.NoError(t, tst.addElements(esTS2))
+	require.NoError(t, tst.Close())
+
+	tst = newTable(defaultFlushTimeout)
+	for _, ts := range []common.ItemID{1, 2} {
+		_, _, err = tst.getElement(3, ts, nil)
+		require.NoError(t, err)
+	}
+	require.Eventually(t, func() bool {
+		snp := tst.currentSnapshot()
+		defer snp.decRef()
+		return snp.memPartCount() == 0
+	}, flags.EventuallyTimeout, 100*time.Millisecond, "the replayed elements are flushed")
+	require.Eventually(t, func() bool {
+		entries, errRead := os.ReadDir(filepath.Join(tmpPath, walDirname))
+		return errRead == nil && len(entries) == 1
+	}, flags.EventuallyTimeout, 100*time.Millisecond, "the segments are removed once the elements are flushed")
+	require.NoError(t, tst.Close())
+
+	tst = newTable(time.Hour)
+	snp := tst.currentSnapshot()
+	require.NotNil(t, snp)
+	assert.Equal(t, 0, snp.memPartCount(), "nothing is replayed once the elements are flushed")
+	snp.decRef()
+	require.NoError(t, tst.Close())
+}
@@ -19,14 +19,20 @@ package stream
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"go.uber.org/multierr"
 	"google.golang.org/protobuf/types/known/anypb"
 
 	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/api/data"
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
 	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/banyand/internal/wal"
 	"github.com/apache/skywalking-banyandb/pkg/bus"
 	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/index"
@@ -36,19 +42,26 @@ import (
 	"github.com/apache/skywalking-banyandb/pkg/timestamp"
 )
 
+var errOutOfTimeRange = errors.New("the element is out of the time range of the segment")
+
 type writeCallback struct {
 	l          *logger.Logger
 	schemaRepo *schemaRepo
+	recorder   wal.Recorder
 }
 
-func setUpWriteCallback(l *logger.Logger, schemaRepo *schemaRepo) bus.MessageListener {
+func setUpWriteCallback(l *logger.Logger, schemaRepo *schemaRepo, recorder wal.Recorder) bus.MessageListener {
 	return &writeCallback{
 		l:          l,
 		schemaRepo: schemaRepo,
+		recorder:   recorder,
 	}
 }
 
-func (w *writeCallback) handle(dst map[string]*elementsInGroup, writeEvent *streamv1.InternalWriteRequest) (map[string]*elementsInGroup, error) {
+// handle adds the element to dst. walOffset is the offset of the write in the WAL of its group, 0 if it isn't logged.
+func (w *writeCallback) handle(dst map[string]*elementsInGroup, writeEvent *streamv1.InternalWriteRequest,
+	walOffset uint64,
+) (map[string]*elementsInGroup, error) {
 	req := writeEvent.Request
 	t := req.Element.Timestamp.AsTime().Local()
 	if err := timestamp.Check(t); err != nil {
@@ -83,14 +96,17 @@ func (w *writeCallback) handle(dst map[string]*elementsInGroup, writeEvent *stre
 		if err != nil {
 			return nil, fmt.Errorf("cannot create ts table: %w", err)
 		}
+		// only the offending element is rejected, the others of the batch are still written
+		if err = checkTimeRange(tstb.GetTimeRange(), ts, req.Element.GetElementId()); err != nil {
+			tstb.DecRef()
+			return nil, err
+		}
 		et = &elementsInTable{
 			timeRange: tstb.GetTimeRange(),
 			tsTable:   tstb,
 		}
 		eg.tables = append(eg.tables, et)
 	}
-	et.elements.timestamps = append(et.elements.timestamps, int64(ts))
-	et.elements.elementIDs = append(et.elements.elementIDs, writeEvent.Request.Element.GetElementId())
 	stm, ok := w.schemaRepo.loadStream(writeEvent.GetRequest().GetMetadata())
 	if !ok {
 		return nil, fmt.Errorf("cannot find stream definition: %s", writeEvent.GetRequest().GetMetadata())
@@ -109,12 +125,10 @@ func (w *writeCallback) handle(dst map[string]*elementsInGroup, writeEvent *stre
 	if err := series.Marshal(); err != nil {
 		return nil, fmt.Errorf("cannot marshal series: %w", err)
 	}
-	et.elements.seriesIDs = append(et.elements.seriesIDs, series.ID)
 
 	tagFamilies := make([]tagValues, len(stm.schema.TagFamilies))
 	tagFamiliesForIndexWrite := make([]tagValues, len(stm.schema.TagFamilies))
 	entityMap := make(map[string]bool)
-	et.elements.tagFamilies = append(et.elements.tagFamilies, tagFamilies)
 	for _, entity := range stm.GetSchema().GetEntity().GetTagNames() {
 		entityMap[entity] = true
 	}
@@ -139,6 +153,7 @@ func (w *writeCallback) handle(dst map[string]*elementsInGroup, writeEvent *stre
 				tagFamilySpec.Tags[j].Name,
 				tagFamilySpec.Tags[j].Type,
 				tagValue)
+			encodeTagValue.bloomFilter = tagFamilySpec.Tags[j].BloomFilter
 			tagFamiliesForIndexWrite[i].values = append(tagFamiliesForIndexWrite[i].values, encodeTagValue)
 			if tagFamilySpec.Tags[j].IndexedOnly || entityMap[tagFamilySpec.Tags[j].Name] {
 				continue
@@ -146,51 +161,72 @@ func (w *writeCallback) handle(dst map[string]*elementsInGroup, writeEvent *stre
 			tagFamilies[i].values = append(tagFamilies[i].values, encodeTagValue)
 		}
 	}
-
-	var fields []index.Field
-	for _, indexRule := range stm.indexRuleLocators {
-		tv := getIndexValue(indexRule, tagFamiliesForIndexWrite)
-		if tv == nil {
-			continue
-		}
-		if tv.value != nil {
-			fields = append(fields, index.Field{
-				Key: index.FieldKey{
-					IndexRuleID: indexRule.Rule.GetMetadata().GetId(),
-					Analyzer:    indexRule.Rule.Analyzer,
-					SeriesID:    series.ID,
-				},
-				Term: tv.value,
-			})
-			continue
-		}
-		for _, val := range tv.valueArr {
-			fields = append(fields, index.Field{
-				Key: index.FieldKey{
-					IndexRuleID: indexRule.Rule.GetMetadata().GetId(),
-					Analyzer:    indexRule.Rule.Analyzer,
-					SeriesID:    series.ID,
-				},
-				Term: val,
-			})
-		}
+	// the duplicated names make the tags ambiguous to the queries
+	if err := checkDuplicatedTags(tagFamilies); err != nil {
+		return nil, fmt.Errorf("element %q of %s is rejected: %w", req.Element.GetElementId(), req.Metadata, err)
 	}
+	et.elements.timestamps = append(et.elements.timestamps, int64(ts))
+	et.elements.elementIDs = append(et.elements.elementIDs, req.Element.GetElementId())
+	et.elements.seriesIDs = append(et.elements.seriesIDs, series.ID)
+	et.elements.tagFamilies = append(et.elements.tagFamilies, tagFamilies)
+	et.elements.observeSchemaRevision(stm.schema.GetMetadata().GetModRevision())
+
+	fields := appendIndexFields(nil, stm.indexRuleLocators, tagFamiliesForIndexWrite, series.ID)
 
 	et.docs = append(et.docs, index.Document{
 		DocID:  ts,
 		Fields: fields,
 	})
 
-	eg.docs = append(eg.docs, index.Document{
+	et.seriesDocs = append(et.seriesDocs, index.Document{
 		DocID:        uint64(series.ID),
 		EntityValues: series.Buffer,
 	})
+	if walOffset > 0 {
+		et.elements.walOffsets = append(et.elements.walOffsets, walOffset)
+	}
 	return dst, nil
 }
 
+// checkTimeRange returns an error if the segment doesn't cover the timestamp of the element,
+// which happens if the segment created for the element ends at the next one.
+func checkTimeRange(tr timestamp.TimeRange, ts uint64, elementID string) error {
+	if tr.Contains(ts) {
+		return nil
+	}
+	return fmt.Errorf("%w: element %q at %s, segment %s", errOutOfTimeRange,
+		elementID, time.Unix(0, int64(ts)).Format(time.RFC3339Nano), tr)
+}
+
+// record logs the write before it's applied, and returns its offset in the WAL of its group.
+func (w *writeCallback) record(ctx context.Context, writeEvent *streamv1.InternalWriteRequest) (uint64, error) {
+	if w.recorder == nil {
+		return 0, nil
+	}
+	offset, err := w.recorder.Record(ctx, data.TopicStreamWrite.String(), writeEvent.GetRequest().GetMetadata().GetGroup(), writeEvent)
+	if err != nil {
+		return 0, fmt.Errorf("cannot record the write: %w", err)
+	}
+	return offset, nil
+}
+
+// release drops the writes rejected from the WAL.
+func (w *writeCallback) release(group string, offsets []uint64) {
+	if w.recorder == nil || len(offsets) == 0 {
+		return
+	}
+	w.recorder.Release(group, offsets)
+}
+
 func (w *writeCallback) Rev(message bus.Message) (resp bus.Message) {
-	events, ok := message.Data().([]any)
-	if !ok {
+	var events []any
+	switch d := message.Data().(type) {
+	case []any:
+		events = d
+	case *streamv1.InternalWriteRequest:
+		// a replicated write is sent alone and applied synchronously
+		events = []any{d}
+	default:
 		w.l.Warn().Msg("invalid event data type")
 		return
 	}
@@ -199,6 +235,8 @@ func (w *writeCallback) Rev(message bus.Message) (resp bus.Message) {
 		return
 	}
 	groups := make(map[string]*elementsInGroup)
+	// the replicated writes failing to be handled are rejected, so that the liaison doesn't count them as the acks
+	var errHandle error
 	for i := range events {
 		var writeEvent *streamv1.InternalWriteRequest
 		switch e := events[i].(type) {
@@ -208,33 +246,59 @@ func (w *writeCallback) Rev(message bus.Message) (resp bus.Message) {
 			writeEvent = &streamv1.InternalWriteRequest{}
 			if err := e.UnmarshalTo(writeEvent); err != nil {
 				w.l.Error().Err(err).RawJSON("written", logger.Proto(e)).Msg("fail to unmarshal event")
+				errHandle = multierr.Append(errHandle, err)
 				continue
 			}
 		default:
 			w.l.Warn().Msg("invalid event data type")
+			errHandle = multierr.Append(errHandle, fmt.Errorf("invalid event data type %T", e))
 			continue
 		}
-		var err error
-		if groups, err = w.handle(groups, writeEvent); err != nil {
+		offset, err := w.record(message.Context(), writeEvent)
+		if err != nil {
 			w.l.Error().Err(err).Msg("cannot handle write event")
+			errHandle = multierr.Append(errHandle, err)
 			continue
 		}
+		handled, err := w.handle(groups, writeEvent, offset)
+		if err != nil {
+			if offset > 0 {
+				w.release(writeEvent.GetRequest().GetMetadata().GetGroup(), []uint64{offset})
+			}
+			w.l.Error().Err(err).Msg("cannot handle write event")
+			errHandle = multierr.Append(errHandle, err)
+			continue
+		}
+		groups = handled
 	}
+	var errAdd error
 	for i := range groups {
 		g := groups[i]
 		for j := range g.tables {
 			es := g.tables[j]
-			es.tsTable.Table().mustAddElements(&es.elements)
+			if err := es.tsTable.Table().writeElements(&es.elements, es.docs, es.seriesDocs); err != nil {
+				w.l.Error().Err(err).Msg("cannot add elements")
+				errAdd = err
+				w.release(i, es.elements.walOffsets)
+				es.tsTable.DecRef()
+				continue
+			}
 			index := es.tsTable.Table().Index()
 			if err := index.Write(es.docs); err != nil {
 				w.l.Error().Err(err).Msg("cannot write element index")
 			}
 			es.tsTable.DecRef()
-		}
-		if err := g.tsdb.IndexDB().Write(g.docs); err != nil {
-			w.l.Error().Err(err).Msg("cannot write series index")
+			if err := g.tsdb.IndexDB().Write(es.seriesDocs); err != nil {
+				w.l.Error().Err(err).Msg("cannot write series index")
+			}
 		}
 	}
+	if errAdd != nil {
+		return bus.NewMessage(bus.MessageID(time.Now().UnixNano()), common.NewError("cannot write elements: %v", errAdd))
+	}
+	if errHandle != nil {
+		return bus.NewMessage(bus.MessageID(time.Now().UnixNano()), common.NewError("cannot handle write events: %v", errHandle))
+	}
 	return
 }
 
@@ -280,6 +344,32 @@ func encodeTagValue(name string, tagType databasev1.TagType, tagVal *modelv1.Tag
 	return tv
 }
 
+// appendIndexFields appends the fields of an element's index document, which are the values of the tags the index rules refer to.
+func appendIndexFields(dst []index.Field, indexRuleLocators []*partition.IndexRuleLocator, tagFamilies []tagValues,
+	seriesID common.SeriesID,
+) []index.Field {
+	for _, indexRule := range indexRuleLocators {
+		tv := getIndexValue(indexRule, tagFamilies)
+		if tv == nil {
+			continue
+		}
+		key := index.FieldKey{
+			IndexRuleID: indexRule.Rule.GetMetadata().GetId(),
+			Analyzer:    indexRule.Rule.Analyzer,
+			SeriesID:    seriesID,
+			Numeric:     indexRule.Rule.GetType() == databasev1.IndexRule_TYPE_RANGE,
+		}
+		if tv.value != nil {
+			dst = append(dst, index.Field{Key: key, Term: tv.value})
+			continue
+		}
+		for _, val := range tv.valueArr {
+			dst = append(dst, index.Field{Key: key, Term: val})
+		}
+	}
+	return dst
+}
+
 func getIndexValue(ruleIndex *partition.IndexRuleLocator, tagFamilies []tagValues) *tagValue {
 	if len(ruleIndex.TagIndices) != 1 {
 		logger.Panicf("the index rule %s(%v) didn't support composited tags",
@@ -0,0 +1,36 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func Test_checkTimeRange(t *testing.T) {
+	// the segment created for an element may end at the next segment
+	tr := timestamp.NewSectionTimeRange(time.Unix(0, 100), time.Unix(0, 200))
+	require.NoError(t, checkTimeRange(tr, 100, "start"))
+	require.NoError(t, checkTimeRange(tr, 199, "inside"))
+	require.ErrorIs(t, checkTimeRange(tr, 200, "end"), errOutOfTimeRange)
+	require.ErrorIs(t, checkTimeRange(tr, 99, "before"), errOutOfTimeRange)
+}
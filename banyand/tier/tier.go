@@ -0,0 +1,154 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package tier implements the data node side of the hot and cold tiers.
+//
+// A hot node drops the segments relocated to the cold tier on the demand of liaisons.
+// Every data node reports the disk capacity of its tier.
+package tier
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/api/data"
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/node"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+const capacityInterval = 30 * time.Second
+
+var (
+	_ run.PreRunner       = (*service)(nil)
+	_ run.Service         = (*service)(nil)
+	_ bus.MessageListener = (*service)(nil)
+
+	tierProvider          = observability.NewMeterProvider(observability.RootScope.SubScope("tier"))
+	diskCapacityGauge     = tierProvider.Gauge("disk_capacity_bytes", "tier", "path")
+	diskUsedGauge         = tierProvider.Gauge("disk_used_bytes", "tier", "path")
+	droppedSegmentCounter = tierProvider.Counter("dropped_segments_total", "group")
+)
+
+// SegmentDropper removes the segments of a group.
+type SegmentDropper interface {
+	DropSegments(group string, deadline time.Time) int
+}
+
+// Service serves the tier of a data node.
+type Service interface {
+	run.PreRunner
+	run.Service
+}
+
+type service struct {
+	pipeline queue.Server
+	l        *logger.Logger
+	closer   *run.Closer
+	tier     string
+	droppers []SegmentDropper
+}
+
+// NewService returns a service dropping the relocated segments through the droppers.
+func NewService(pipeline queue.Server, droppers ...SegmentDropper) Service {
+	return &service{
+		pipeline: pipeline,
+		droppers: droppers,
+		closer:   run.NewCloser(1),
+	}
+}
+
+func (s *service) Name() string {
+	return "tier"
+}
+
+func (s *service) PreRun(ctx context.Context) error {
+	s.l = logger.GetLogger(s.Name())
+	val := ctx.Value(common.ContextNodeKey)
+	if val == nil {
+		return errors.New("node id is empty")
+	}
+	s.tier = node.TierHot
+	if val.(common.Node).Labels[node.TierLabel] == node.TierCold {
+		s.tier = node.TierCold
+	}
+	s.l.Info().Str("tier", s.tier).Msg("the node joins the tier")
+	return s.pipeline.Subscribe(data.TopicSegmentDrop, s)
+}
+
+func (s *service) Serve() run.StopNotify {
+	if !s.closer.AddRunning() {
+		return s.closer.CloseNotify()
+	}
+	go func() {
+		defer s.closer.Done()
+		s.reportCapacity()
+		ticker := time.NewTicker(capacityInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.closer.CloseNotify():
+				return
+			case <-ticker.C:
+				s.reportCapacity()
+			}
+		}
+	}()
+	return s.closer.CloseNotify()
+}
+
+func (s *service) GracefulStop() {
+	s.closer.Done()
+	s.closer.CloseThenWait()
+}
+
+func (s *service) Rev(message bus.Message) (resp bus.Message) {
+	req, ok := message.Data().(*clusterv1.DropSegmentsRequest)
+	if !ok {
+		return bus.NewMessage(message.ID(), common.NewError("invalid event data type"))
+	}
+	if s.tier == node.TierCold {
+		return bus.NewMessage(message.ID(), common.NewError("a cold node keeps the relocated segments"))
+	}
+	if req.GetGroup() == "" || req.GetBefore() == nil {
+		return bus.NewMessage(message.ID(), common.NewError("group and before are required"))
+	}
+	var dropped int
+	for _, d := range s.droppers {
+		dropped += d.DropSegments(req.GetGroup(), req.GetBefore().AsTime())
+	}
+	if dropped > 0 {
+		droppedSegmentCounter.Inc(float64(dropped), req.GetGroup())
+		s.l.Info().Str("group", req.GetGroup()).Time("before", req.GetBefore().AsTime()).Int("dropped", dropped).
+			Msg("dropped the segments relocated to the cold tier")
+	}
+	return bus.NewMessage(message.ID(), &clusterv1.DropSegmentsResponse{Dropped: uint32(dropped)})
+}
+
+func (s *service) reportCapacity() {
+	for p, usage := range observability.DiskUsages() {
+		diskCapacityGauge.Set(float64(usage.Capacity), s.tier, p)
+		diskUsedGauge.Set(float64(usage.Used), s.tier, p)
+	}
+}
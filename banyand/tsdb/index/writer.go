@@ -106,7 +106,7 @@ func NewWriter(ctx context.Context, options WriterOptions) *Writer {
 			continue
 		}
 		switch rule.Type {
-		case databasev1.IndexRule_TYPE_INVERTED:
+		case databasev1.IndexRule_TYPE_INVERTED, databasev1.IndexRule_TYPE_RANGE:
 			key |= inverted
 		case databasev1.IndexRule_TYPE_TREE:
 			key |= tree
@@ -155,6 +155,7 @@ func (s *Writer) writeGlobalIndex(scope tsdb.Entry, ref tsdb.GlobalItemID, value
 					Key: index.FieldKey{
 						IndexRuleID: rule.GetMetadata().GetId(),
 						Analyzer:    rule.Analyzer,
+						Numeric:     rule.GetType() == databasev1.IndexRule_TYPE_RANGE,
 					},
 					Term: val,
 				})
@@ -209,6 +210,7 @@ func (s *Writer) writeLocalIndex(writer tsdb.IndexWriter, value Value) (err erro
 					Key: index.FieldKey{
 						IndexRuleID: rule.GetMetadata().GetId(),
 						Analyzer:    rule.Analyzer,
+						Numeric:     rule.GetType() == databasev1.IndexRule_TYPE_RANGE,
 					},
 					Term: val,
 				})
@@ -37,7 +37,7 @@ func (s *seekerBuilder) buildIndexFilter(block blockDelegate) (filterFn, error)
 	}
 	allItemIDs, err := s.predicator.Execute(func(typ databasev1.IndexRule_Type) (index.Searcher, error) {
 		switch typ {
-		case databasev1.IndexRule_TYPE_INVERTED:
+		case databasev1.IndexRule_TYPE_INVERTED, databasev1.IndexRule_TYPE_RANGE:
 			return block.invertedIndexReader(), nil
 		case databasev1.IndexRule_TYPE_TREE:
 			return block.lsmIndexReader(), nil
@@ -86,7 +86,7 @@ func (s *seekerBuilder) buildSeriesByIndex() (series []Iterator, err error) {
 		switch s.indexRuleForSorting.GetType() {
 		case databasev1.IndexRule_TYPE_TREE:
 			inner, err = b.lsmIndexReader().Iterator(fieldKey, rangeOpts, s.order)
-		case databasev1.IndexRule_TYPE_INVERTED:
+		case databasev1.IndexRule_TYPE_INVERTED, databasev1.IndexRule_TYPE_RANGE:
 			inner, err = b.invertedIndexReader().Iterator(fieldKey, rangeOpts, s.order)
 		case databasev1.IndexRule_TYPE_UNSPECIFIED:
 			return nil, errors.WithMessagef(errUnspecifiedIndexType, "index rule:%v", s.indexRuleForSorting)
@@ -498,7 +498,7 @@ func (s *seriesDB) Search(ctx context.Context, path Path, filter index.Filter, o
 	switch order.Index.Type {
 	case databasev1.IndexRule_TYPE_TREE:
 		iter, err = s.lsmIndex.Iterator(fieldKey, rangeOpts, order.Sort)
-	case databasev1.IndexRule_TYPE_INVERTED:
+	case databasev1.IndexRule_TYPE_INVERTED, databasev1.IndexRule_TYPE_RANGE:
 		iter, err = s.invertedIndex.Iterator(fieldKey, rangeOpts, order.Sort)
 	default:
 		return nil, errUnspecifiedIndexType
@@ -548,7 +548,7 @@ func (s *seriesDB) filterSeries(ctx context.Context, path Path, filter index.Fil
 		switch ruleType {
 		case databasev1.IndexRule_TYPE_TREE:
 			return s.lsmIndex, nil
-		case databasev1.IndexRule_TYPE_INVERTED:
+		case databasev1.IndexRule_TYPE_INVERTED, databasev1.IndexRule_TYPE_RANGE:
 			return s.invertedIndex, nil
 		default:
 			return nil, errUnspecifiedIndexType
@@ -0,0 +1,142 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package watchdog
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// cgroup v1 reports a huge number rather than "max" if the memory is unlimited.
+const cgroupV1Unlimited = 1 << 62
+
+var cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupFiles locates the memory stats of a cgroup version.
+type cgroupFiles struct {
+	limit        string
+	usage        string
+	stat         string
+	inactiveFile string
+}
+
+var (
+	cgroupV2 = cgroupFiles{limit: "memory.max", usage: "memory.current", stat: "memory.stat", inactiveFile: "inactive_file"}
+	cgroupV1 = cgroupFiles{
+		limit:        "memory/memory.limit_in_bytes",
+		usage:        "memory/memory.usage_in_bytes",
+		stat:         "memory/memory.stat",
+		inactiveFile: "total_inactive_file",
+	}
+)
+
+// memorySampler samples the memory usage of the cgroup, or of the runtime if the process isn't in a cgroup.
+type memorySampler struct {
+	cgroup *cgroupFiles
+	limit  uint64
+}
+
+// newMemorySampler returns a sampler against the limit, or the limit of the cgroup if limit is 0.
+// It returns nil if no limit is known.
+func newMemorySampler(limit uint64) (*memorySampler, error) {
+	s := &memorySampler{limit: limit}
+	for _, files := range []*cgroupFiles{&cgroupV2, &cgroupV1} {
+		cgroupLimit, err := readCgroupValue(files.limit)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.cgroup = files
+		if s.limit == 0 && cgroupLimit < cgroupV1Unlimited {
+			s.limit = cgroupLimit
+		}
+		break
+	}
+	if s.limit == 0 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// usage returns the memory the process can't give back without swapping.
+// The inactive page cache of the cgroup is excluded since the kernel reclaims it first.
+func (s *memorySampler) usage() (uint64, error) {
+	if s.cgroup == nil {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		return ms.Sys - ms.HeapReleased, nil
+	}
+	usage, err := readCgroupValue(s.cgroup.usage)
+	if err != nil {
+		return 0, err
+	}
+	inactive, err := readCgroupStat(s.cgroup.stat, s.cgroup.inactiveFile)
+	if err != nil {
+		return 0, err
+	}
+	if inactive > usage {
+		return 0, nil
+	}
+	return usage - inactive, nil
+}
+
+func readCgroupValue(name string) (uint64, error) {
+	b, err := os.ReadFile(filepath.Join(cgroupRoot, name))
+	if err != nil {
+		return 0, err
+	}
+	v := string(bytes.TrimSpace(b))
+	if v == "max" {
+		return cgroupV1Unlimited, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid value of %s", name)
+	}
+	return n, nil
+}
+
+func readCgroupStat(name, key string) (uint64, error) {
+	f, err := os.Open(filepath.Join(cgroupRoot, name))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), " ")
+		if !ok || k != key {
+			continue
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid %s of %s", key, name)
+		}
+		return n, nil
+	}
+	return 0, scanner.Err()
+}
@@ -0,0 +1,48 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package watchdog
+
+import (
+	"sync/atomic"
+
+	clusterv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/cluster/v1"
+)
+
+var (
+	unflushedWAL      atomic.Int64
+	unflushedWALGauge = watchdogProvider.Gauge("unflushed_wal_bytes")
+)
+
+// AddUnflushedWAL accounts the bytes appended to the write-ahead logs, or removed from them once their records are flushed.
+func AddUnflushedWAL(delta int64) {
+	unflushedWALGauge.Set(float64(unflushedWAL.Add(delta)))
+}
+
+// UnflushedWAL returns the size of the write-ahead logs of the process whose records aren't flushed to the file parts yet.
+func UnflushedWAL() int64 {
+	return unflushedWAL.Load()
+}
+
+// Pressure returns how far the process lags behind the writes, which is reported to the liaisons to pace the bulk writes.
+func Pressure() *clusterv1.WritePressure {
+	return &clusterv1.WritePressure{
+		WriteBackpressure: Engaged(ActionWriteBackpressure),
+		FlushDelayed:      Engaged(ActionDelayFlushMerges),
+		UnflushedWalBytes: uint64(max(UnflushedWAL(), 0)),
+	}
+}
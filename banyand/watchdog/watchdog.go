@@ -0,0 +1,308 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package watchdog relieves the memory pressure of the process before it runs out of memory.
+//
+// The watchdog samples the memory usage against the limit periodically. Once the usage
+// reaches the level of an action, the action is engaged: the caches are evicted, the pools
+// are shrunk, the merges are paused and, at last, the writes are pushed back. An action is
+// released after the usage falls below its level by the hysteresis.
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/banyand/observability"
+	"github.com/apache/skywalking-banyandb/pkg/bytes"
+	"github.com/apache/skywalking-banyandb/pkg/intern"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/pool"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+// The actions taken under memory pressure.
+const (
+	// ActionEvictCaches drops the entries of the caches.
+	ActionEvictCaches = "evict-caches"
+	// ActionShrinkPools drops the pooled objects above the minimal capacity.
+	ActionShrinkPools = "shrink-pools"
+	// ActionPauseMerges stops starting new merges of the file parts.
+	ActionPauseMerges = "pause-merges"
+	// ActionDelayFlushMerges flushes the in-memory parts one by one instead of merging them first.
+	ActionDelayFlushMerges = "delay-flush-merges"
+	// ActionWriteBackpressure stops receiving the writes.
+	ActionWriteBackpressure = "write-backpressure"
+)
+
+const (
+	levelShed = iota
+	levelPause
+	levelBackpressure
+)
+
+const releasePollInterval = 100 * time.Millisecond
+
+var (
+	_ run.PreRunner = (*service)(nil)
+	_ run.Config    = (*service)(nil)
+	_ run.Service   = (*service)(nil)
+
+	watchdogProvider  = observability.NewMeterProvider(observability.RootScope.SubScope("watchdog"))
+	pressureGauge     = watchdogProvider.Gauge("memory_pressure_ratio")
+	engagedGauge      = watchdogProvider.Gauge("engaged", "action")
+	activationCounter = watchdogProvider.Counter("activations_total", "action")
+
+	allActions = []string{ActionEvictCaches, ActionShrinkPools, ActionPauseMerges, ActionDelayFlushMerges, ActionWriteBackpressure}
+	engaged    = map[string]*atomic.Bool{
+		ActionEvictCaches:       {},
+		ActionShrinkPools:       {},
+		ActionPauseMerges:       {},
+		ActionDelayFlushMerges:  {},
+		ActionWriteBackpressure: {},
+	}
+)
+
+// Engaged returns true if the action is engaged.
+func Engaged(action string) bool {
+	e, ok := engaged[action]
+	return ok && e.Load()
+}
+
+var evictors struct {
+	fns []func()
+	sync.Mutex
+}
+
+// OnEvictCaches registers fn dropping the entries of a cache, which is called once ActionEvictCaches is engaged.
+func OnEvictCaches(fn func()) {
+	evictors.Lock()
+	defer evictors.Unlock()
+	evictors.fns = append(evictors.fns, fn)
+}
+
+func evictCaches() {
+	intern.Reset()
+	evictors.Lock()
+	defer evictors.Unlock()
+	for _, fn := range evictors.fns {
+		fn()
+	}
+}
+
+// WaitReleased blocks while the action is engaged. It returns false if closeCh is closed in the meantime.
+func WaitReleased(action string, closeCh <-chan struct{}) bool {
+	for Engaged(action) {
+		select {
+		case <-closeCh:
+			return false
+		case <-time.After(releasePollInterval):
+		}
+	}
+	return true
+}
+
+type action struct {
+	engage  func()
+	release func()
+	name    string
+	level   int
+}
+
+// Service watches the memory pressure of the process.
+type Service interface {
+	run.PreRunner
+	run.Config
+	run.Service
+}
+
+type service struct {
+	sampler           *memorySampler
+	l                 *logger.Logger
+	closer            *run.Closer
+	enabled           []string
+	actions           []action
+	thresholds        [3]float64
+	interval          time.Duration
+	limit             run.Bytes
+	minPoolCapacity   run.Bytes
+	shedRatio         float64
+	pauseRatio        float64
+	backpressureRatio float64
+	hysteresis        float64
+}
+
+// NewService returns a new memory watchdog.
+func NewService() Service {
+	return &service{
+		closer: run.NewCloser(1),
+	}
+}
+
+func (s *service) Name() string {
+	return "watchdog"
+}
+
+func (s *service) FlagSet() *run.FlagSet {
+	fs := run.NewFlagSet("watchdog")
+	fs.DurationVar(&s.interval, "watchdog-interval", time.Second, "the interval of sampling the memory usage")
+	fs.VarP(&s.limit, "watchdog-memory-limit", "",
+		"the memory limit of the process, the limit of the cgroup is used if it's 0, the watchdog is disabled if neither is set")
+	fs.Float64Var(&s.shedRatio, "watchdog-shed-ratio", 0.8,
+		"the ratio of the memory usage to the limit at which the caches are evicted and the pools are shrunk")
+	fs.Float64Var(&s.pauseRatio, "watchdog-pause-ratio", 0.9,
+		"the ratio of the memory usage to the limit at which the merges are paused and the flushes stop merging in-memory parts")
+	fs.Float64Var(&s.backpressureRatio, "watchdog-backpressure-ratio", 0.95,
+		"the ratio of the memory usage to the limit at which the writes are pushed back")
+	fs.Float64Var(&s.hysteresis, "watchdog-hysteresis", 0.05,
+		"the ratio the memory usage has to fall below the level of an action to release it")
+	fs.StringSliceVar(&s.enabled, "watchdog-actions", allActions, "the actions taken under memory pressure")
+	s.minPoolCapacity = 64 << 10
+	fs.VarP(&s.minPoolCapacity, "watchdog-min-pooled-size", "",
+		"the max bytes of a pooled object while the pools are shrunk")
+	return fs
+}
+
+func (s *service) Validate() error {
+	if s.interval <= 0 {
+		return errors.New("watchdog-interval must be positive")
+	}
+	if s.shedRatio <= 0 || s.shedRatio > s.pauseRatio || s.pauseRatio > s.backpressureRatio || s.backpressureRatio > 1 {
+		return errors.New("the watchdog ratios must satisfy 0 < shed <= pause <= backpressure <= 1")
+	}
+	if s.hysteresis < 0 || s.hysteresis >= s.shedRatio {
+		return errors.New("watchdog-hysteresis must be in [0, watchdog-shed-ratio)")
+	}
+	for _, a := range s.enabled {
+		if !slices.Contains(allActions, a) {
+			return fmt.Errorf("unknown watchdog action %q, the actions are %v", a, allActions)
+		}
+	}
+	return nil
+}
+
+func (s *service) PreRun(_ context.Context) error {
+	s.l = logger.GetLogger(s.Name())
+	s.thresholds = [3]float64{s.shedRatio, s.pauseRatio, s.backpressureRatio}
+	s.actions = s.actions[:0]
+	for _, a := range s.availableActions() {
+		if slices.Contains(s.enabled, a.name) {
+			s.actions = append(s.actions, a)
+		}
+	}
+	sampler, err := newMemorySampler(uint64(s.limit))
+	if err != nil {
+		return err
+	}
+	if sampler == nil {
+		s.l.Info().Msg("the memory limit is unknown, the watchdog is disabled")
+		return nil
+	}
+	s.sampler = sampler
+	s.l.Info().Uint64("limit", sampler.limit).Strs("actions", s.enabled).Msg("the watchdog watches the memory usage")
+	return nil
+}
+
+func (s *service) availableActions() []action {
+	return []action{
+		{
+			name:    ActionEvictCaches,
+			level:   levelShed,
+			engage:  evictCaches,
+			release: func() {},
+		},
+		{
+			name:  ActionShrinkPools,
+			level: levelShed,
+			engage: func() {
+				pool.Shrink(int64(s.minPoolCapacity))
+				bytes.TrimBufferManagers(int(s.minPoolCapacity))
+				debug.FreeOSMemory()
+			},
+			release: pool.Restore,
+		},
+		{name: ActionPauseMerges, level: levelPause, engage: func() {}, release: func() {}},
+		{name: ActionDelayFlushMerges, level: levelPause, engage: func() {}, release: func() {}},
+		{name: ActionWriteBackpressure, level: levelBackpressure, engage: func() {}, release: func() {}},
+	}
+}
+
+func (s *service) Serve() run.StopNotify {
+	if s.sampler == nil || !s.closer.AddRunning() {
+		return s.closer.CloseNotify()
+	}
+	go func() {
+		defer s.closer.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.closer.CloseNotify():
+				return
+			case <-ticker.C:
+				usage, err := s.sampler.usage()
+				if err != nil {
+					s.l.Warn().Err(err).Msg("failed to sample the memory usage")
+					continue
+				}
+				s.check(float64(usage) / float64(s.sampler.limit))
+			}
+		}
+	}()
+	return s.closer.CloseNotify()
+}
+
+func (s *service) GracefulStop() {
+	s.closer.Done()
+	s.closer.CloseThenWait()
+	for _, a := range s.actions {
+		if engaged[a.name].CompareAndSwap(true, false) {
+			a.release()
+			engagedGauge.Set(0, a.name)
+		}
+	}
+}
+
+// check engages the actions whose level is reached, and releases the ones whose level is left.
+func (s *service) check(ratio float64) {
+	pressureGauge.Set(ratio)
+	for _, a := range s.actions {
+		threshold := s.thresholds[a.level]
+		e := engaged[a.name]
+		switch {
+		case !e.Load() && ratio >= threshold:
+			a.engage()
+			e.Store(true)
+			engagedGauge.Set(1, a.name)
+			activationCounter.Inc(1, a.name)
+			s.l.Warn().Str("action", a.name).Float64("ratio", ratio).Float64("threshold", threshold).
+				Msg("the memory pressure engages an action")
+		case e.Load() && ratio < threshold-s.hysteresis:
+			e.Store(false)
+			a.release()
+			engagedGauge.Set(0, a.name)
+			s.l.Info().Str("action", a.name).Float64("ratio", ratio).Msg("the memory pressure releases an action")
+		}
+	}
+}
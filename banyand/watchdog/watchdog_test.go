@@ -0,0 +1,136 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package watchdog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	setCgroup(t, map[string]string{
+		"memory.max":     "1000\n",
+		"memory.current": "0\n",
+		"memory.stat":    "anon 0\ninactive_file 0\n",
+	})
+	s := newTestService(t, ActionShrinkPools, ActionPauseMerges, ActionWriteBackpressure)
+	defer s.GracefulStop()
+
+	s.check(0.85)
+	assert.True(t, Engaged(ActionShrinkPools))
+	assert.False(t, Engaged(ActionPauseMerges))
+	assert.False(t, Engaged(ActionEvictCaches), "a disabled action isn't engaged")
+
+	s.check(0.96)
+	assert.True(t, Engaged(ActionPauseMerges))
+	assert.True(t, Engaged(ActionWriteBackpressure))
+
+	s.check(0.92)
+	assert.True(t, Engaged(ActionWriteBackpressure), "the hysteresis keeps the action engaged")
+	s.check(0.89)
+	assert.False(t, Engaged(ActionWriteBackpressure))
+	assert.True(t, Engaged(ActionPauseMerges))
+
+	s.check(0.5)
+	for _, a := range allActions {
+		assert.False(t, Engaged(a))
+	}
+}
+
+func TestEvictCaches(t *testing.T) {
+	setCgroup(t, map[string]string{
+		"memory.max":     "1000\n",
+		"memory.current": "0\n",
+		"memory.stat":    "anon 0\ninactive_file 0\n",
+	})
+	s := newTestService(t, ActionEvictCaches)
+	defer s.GracefulStop()
+	var evicted int
+	OnEvictCaches(func() { evicted++ })
+
+	s.check(0.85)
+	assert.Equal(t, 1, evicted)
+	s.check(0.86)
+	assert.Equal(t, 1, evicted, "the caches are evicted once the action is engaged")
+	s.check(0.5)
+}
+
+func TestWaitReleased(t *testing.T) {
+	assert.True(t, WaitReleased(ActionPauseMerges, nil))
+	engaged[ActionPauseMerges].Store(true)
+	defer engaged[ActionPauseMerges].Store(false)
+	closeCh := make(chan struct{})
+	close(closeCh)
+	assert.False(t, WaitReleased(ActionPauseMerges, closeCh))
+}
+
+func TestMemorySampler(t *testing.T) {
+	setCgroup(t, map[string]string{
+		"memory.max":     "max\n",
+		"memory.current": "700\n",
+		"memory.stat":    "anon 500\ninactive_file 200\n",
+	})
+	s, err := newMemorySampler(0)
+	require.NoError(t, err)
+	assert.Nil(t, s, "the cgroup is unlimited")
+
+	s, err = newMemorySampler(1000)
+	require.NoError(t, err)
+	usage, err := s.usage()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(500), usage)
+
+	setCgroup(t, map[string]string{
+		"memory/memory.limit_in_bytes": "2000\n",
+		"memory/memory.usage_in_bytes": "900\n",
+		"memory/memory.stat":           "cache 300\ntotal_inactive_file 100\n",
+	})
+	s, err = newMemorySampler(0)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2000), s.limit)
+	usage, err = s.usage()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(800), usage)
+}
+
+func newTestService(t *testing.T, actions ...string) *service {
+	s := NewService().(*service)
+	require.NoError(t, s.FlagSet().Parse(nil))
+	s.enabled = actions
+	require.NoError(t, s.Validate())
+	require.NoError(t, s.PreRun(context.Background()))
+	require.NotNil(t, s.sampler)
+	return s
+}
+
+func setCgroup(t *testing.T, files map[string]string) {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	old := cgroupRoot
+	cgroupRoot = root
+	t.Cleanup(func() { cgroupRoot = old })
+}
@@ -0,0 +1,172 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/pkg/version"
+)
+
+const drainPollInterval = time.Second
+
+func newClusterCmd() *cobra.Command {
+	clusterCmd := &cobra.Command{
+		Use:     "cluster",
+		Version: version.Build(),
+		Short:   "Cluster operation",
+	}
+
+	var decommission, wait bool
+	drainCmd := &cobra.Command{
+		Use:     "drain node [--decommission] [--wait]",
+		Version: version.Build(),
+		Short:   "Drain a data node before stopping it, or decommission it to remove it from the cluster",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) (err error) {
+			node := args[0]
+			var last *databasev1.NodeDrainStatus
+			err = rest(nil, func(request request) (*resty.Response, error) {
+				b, errMarshal := protojson.Marshal(&databasev1.ClusterStateServiceDrainNodeRequest{
+					Node:         node,
+					Decommission: decommission,
+				})
+				if errMarshal != nil {
+					return nil, errMarshal
+				}
+				return request.req.SetBody(b).SetPathParam("node", node).Post(getPath("/api/v1/cluster/nodes/{node}/drain"))
+			}, drainStatusPrinter(&last), enableTLS, insecure, grpcCert)
+			if err != nil || !wait {
+				return err
+			}
+			return waitDrained(node, decommission, last)
+		},
+	}
+	drainCmd.Flags().BoolVarP(&decommission, "decommission", "", false,
+		"copy the data of the node to the remaining nodes, then remove it from the registry")
+	drainCmd.Flags().BoolVarP(&wait, "wait", "w", false,
+		"report the progress until the node is drained or decommissioned, the drain continues if the command is interrupted")
+
+	statusCmd := &cobra.Command{
+		Use:     "drain-status node",
+		Version: version.Build(),
+		Short:   "Show the progress of draining or decommissioning a data node",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) (err error) {
+			var last *databasev1.NodeDrainStatus
+			return rest(nil, func(request request) (*resty.Response, error) {
+				return request.req.SetPathParam("node", args[0]).Get(getPath("/api/v1/cluster/nodes/{node}/drain"))
+			}, drainStatusPrinter(&last), enableTLS, insecure, grpcCert)
+		},
+	}
+
+	abortCmd := &cobra.Command{
+		Use:     "abort-drain node",
+		Version: version.Build(),
+		Short:   "Abort draining or decommissioning a data node, which accepts writes again",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) (err error) {
+			var last *databasev1.NodeDrainStatus
+			return rest(nil, func(request request) (*resty.Response, error) {
+				return request.req.SetPathParam("node", args[0]).Post(getPath("/api/v1/cluster/nodes/{node}/abort-drain"))
+			}, drainStatusPrinter(&last), enableTLS, insecure, grpcCert)
+		},
+	}
+
+	planCmd := &cobra.Command{
+		Use:     "plan",
+		Version: version.Build(),
+		Short:   "Show the shard moves the rebalancing would start, without starting them",
+		RunE: func(_ *cobra.Command, _ []string) (err error) {
+			return rest(nil, func(request request) (*resty.Response, error) {
+				return request.req.Get(getPath("/api/v1/cluster/shards/plan"))
+			}, yamlPrinter, enableTLS, insecure, grpcCert)
+		},
+	}
+
+	bindTLSRelatedFlag(drainCmd, statusCmd, abortCmd, planCmd)
+	clusterCmd.AddCommand(drainCmd, statusCmd, abortCmd, planCmd)
+	return clusterCmd
+}
+
+// waitDrained polls the status and prints it once it changes.
+func waitDrained(node string, decommission bool, last *databasev1.NodeDrainStatus) error {
+	target := databasev1.NodeState_NODE_STATE_DRAINED
+	if decommission {
+		target = databasev1.NodeState_NODE_STATE_DECOMMISSIONED
+	}
+	for last == nil || (last.GetState() != target && last.GetError() == "") {
+		time.Sleep(drainPollInterval)
+		prev := last
+		var current *databasev1.NodeDrainStatus
+		err := rest(nil, func(request request) (*resty.Response, error) {
+			return request.req.SetPathParam("node", node).Get(getPath("/api/v1/cluster/nodes/{node}/drain"))
+		}, func(_ int, _ reqBody, body []byte) error {
+			resp := new(databasev1.ClusterStateServiceGetDrainStatusResponse)
+			if errUnmarshal := protojson.Unmarshal(body, resp); errUnmarshal != nil {
+				return errUnmarshal
+			}
+			current = resp.GetStatus()
+			return nil
+		}, enableTLS, insecure, grpcCert)
+		if err != nil {
+			return err
+		}
+		last = current
+		if formatDrainStatus(prev) != formatDrainStatus(last) {
+			fmt.Println(formatDrainStatus(last))
+		}
+	}
+	if last.GetError() != "" {
+		return fmt.Errorf("failed to decommission node %s: %s", node, last.GetError())
+	}
+	return nil
+}
+
+func drainStatusPrinter(last **databasev1.NodeDrainStatus) printer {
+	return func(_ int, _ reqBody, body []byte) error {
+		// the responses of drain operations share the same layout
+		resp := new(databasev1.ClusterStateServiceGetDrainStatusResponse)
+		if err := protojson.Unmarshal(body, resp); err != nil {
+			return err
+		}
+		*last = resp.GetStatus()
+		fmt.Println(formatDrainStatus(resp.GetStatus()))
+		return nil
+	}
+}
+
+func formatDrainStatus(s *databasev1.NodeDrainStatus) string {
+	if s == nil {
+		return ""
+	}
+	result := fmt.Sprintf("node %s is %s, %d in-memory parts left", s.GetNode(), s.GetState(), s.GetMemParts())
+	if s.GetTotalResources() > 0 {
+		result += fmt.Sprintf(", %d/%d resources (%d elements) copied", s.GetCopiedResources(), s.GetTotalResources(), s.GetCopiedElements())
+	}
+	if s.GetError() != "" {
+		result += ", error: " + s.GetError()
+	}
+	return result
+}
@@ -139,7 +139,9 @@ func newIndexRuleCmd() *cobra.Command {
 
 	bindFileFlag(createCmd, updateCmd)
 
+	historyCmd, rollbackCmd := newSchemaHistoryCmds("indexRule", indexRuleSchemaPath)
+
 	bindTLSRelatedFlag(getCmd, createCmd, deleteCmd, updateCmd, listCmd)
-	indexRuleCmd.AddCommand(getCmd, createCmd, deleteCmd, updateCmd, listCmd)
+	indexRuleCmd.AddCommand(getCmd, createCmd, deleteCmd, updateCmd, listCmd, historyCmd, rollbackCmd)
 	return indexRuleCmd
 }
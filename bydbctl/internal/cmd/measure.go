@@ -149,10 +149,24 @@ func newMeasureCmd() *cobra.Command {
 				}, yamlPrinter, enableTLS, insecure, grpcCert)
 		},
 	}
-	bindFileFlag(createCmd, updateCmd, queryCmd)
-	bindTimeRangeFlag(queryCmd)
+	topNCmd := &cobra.Command{
+		Use:     "topn [-s start_time] [-e end_time] -f [file|dir|-]",
+		Version: version.Build(),
+		Short:   "Query the top-n entities of a measure by its topN aggregation",
+		Long:    timeRangeUsage,
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
+			return rest(func() ([]reqBody, error) { return parseTimeRangeFromFlagAndYAML(cmd.InOrStdin()) },
+				func(request request) (*resty.Response, error) {
+					return request.req.SetBody(request.data).Post(getPath("/api/v1/measure/topn"))
+				}, yamlPrinter, enableTLS, insecure, grpcCert)
+		},
+	}
+	bindFileFlag(createCmd, updateCmd, queryCmd, topNCmd)
+	bindTimeRangeFlag(queryCmd, topNCmd)
+
+	historyCmd, rollbackCmd := newSchemaHistoryCmds("measure", measureSchemaPath)
 
-	bindTLSRelatedFlag(getCmd, createCmd, deleteCmd, updateCmd, listCmd, queryCmd)
-	measureCmd.AddCommand(getCmd, createCmd, deleteCmd, updateCmd, listCmd, queryCmd)
+	bindTLSRelatedFlag(getCmd, createCmd, deleteCmd, updateCmd, listCmd, queryCmd, topNCmd)
+	measureCmd.AddCommand(getCmd, createCmd, deleteCmd, updateCmd, listCmd, queryCmd, topNCmd, historyCmd, rollbackCmd)
 	return measureCmd
 }
@@ -243,6 +243,40 @@ tagProjection:
 		}, flags.EventuallyTimeout).Should(Equal(6))
 	})
 
+	It("query the top-n entities", func() {
+		conn, err := grpclib.Dial(
+			grpcAddr,
+			grpclib.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		cases_measure_data.Write(conn, "service_instance_cpm_minute", "sw_metric", "service_instance_cpm_minute_data.json", now, time.Minute)
+		rootCmd.SetArgs([]string{"measure", "topn", "-a", addr, "--start", startStr, "--end", endStr, "-f", "-"})
+		issue := func() string {
+			rootCmd.SetIn(strings.NewReader(`
+metadata:
+  name: service_instance_cpm_minute_top_bottom_100
+  group: sw_metric
+topN: 3
+agg: AGGREGATION_FUNCTION_MAX
+fieldValueSort: SORT_DESC`))
+			return capturer.CaptureStdout(func() {
+				err := rootCmd.Execute()
+				Expect(err).NotTo(HaveOccurred())
+			})
+		}
+		Eventually(issue, flags.EventuallyTimeout).ShouldNot(ContainSubstring("code:"))
+		Eventually(func() int {
+			out := issue()
+			resp := new(measurev1.TopNResponse)
+			helpers.UnmarshalYAML([]byte(out), resp)
+			GinkgoWriter.Println(resp)
+			if len(resp.Lists) != 1 {
+				return 0
+			}
+			return len(resp.Lists[0].Items)
+		}, flags.EventuallyTimeout).Should(BeNumerically(">", 0))
+	})
+
 	DescribeTable("query measure data with time range flags", func(timeArgs ...string) {
 		conn, err := grpclib.Dial(
 			grpcAddr,
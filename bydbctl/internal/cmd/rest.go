@@ -324,6 +324,9 @@ func rest(pfn paramsFn, fn reqFn, printer printer, enableTLS bool, insecure bool
 			}
 			client.SetTLSClientConfig(&config)
 		}
+		if token := viper.GetString("token"); token != "" {
+			client.SetAuthToken(token)
+		}
 		req := client.R()
 		resp, err := fn(request{
 			reqBody: r,
@@ -22,7 +22,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 
+	"github.com/go-resty/resty/v2"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
@@ -40,6 +42,7 @@ var (
 	enableTLS bool
 	insecure  bool
 	grpcCert  string
+	revision  int64
 	rootCmd   = &cobra.Command{
 		DisableAutoGenTag: true,
 		Version:           version.Build(),
@@ -53,6 +56,7 @@ func ResetFlags() {
 	name = ""
 	start = ""
 	end = ""
+	revision = 0
 }
 
 // Execute executes the root command.
@@ -66,10 +70,12 @@ func RootCmdFlags(command *cobra.Command) {
 	command.PersistentFlags().StringP("group", "g", "", "If present, list objects in this group.")
 	command.PersistentFlags().StringP("addr", "a", "", "Server's address, the format is Schema://Domain:Port")
 	_ = viper.BindPFlag("group", command.PersistentFlags().Lookup("group"))
+	command.PersistentFlags().String("token", "", "the bearer token authenticating the requests if the server enables the authentication")
 	_ = viper.BindPFlag("addr", command.PersistentFlags().Lookup("addr"))
+	_ = viper.BindPFlag("token", command.PersistentFlags().Lookup("token"))
 	viper.SetDefault("addr", "http://localhost:17913")
 
-	command.AddCommand(newGroupCmd(), newUserCmd(), newStreamCmd(), newMeasureCmd(), newIndexRuleCmd(), newIndexRuleBindingCmd(), newPropertyCmd(), newHealthCheckCmd())
+	command.AddCommand(newGroupCmd(), newUserCmd(), newStreamCmd(), newMeasureCmd(), newIndexRuleCmd(), newIndexRuleBindingCmd(), newPropertyCmd(), newAlertRuleCmd(), newTenantCmd(), newHealthCheckCmd(), newClusterCmd())
 }
 
 func init() {
@@ -145,6 +151,42 @@ func bindNameAndIDAndTagsFlag(commands ...*cobra.Command) {
 	}
 }
 
+// newSchemaHistoryCmds returns the commands listing the versions of a resource at the schema path and rolling it back.
+func newSchemaHistoryCmds(resource, schemaPath string) (historyCmd *cobra.Command, rollbackCmd *cobra.Command) {
+	historyCmd = &cobra.Command{
+		Use:     "history [-g group] -n name",
+		Version: version.Build(),
+		Short:   fmt.Sprintf("List the versions of a %s, the newer the earlier", resource),
+		RunE: func(_ *cobra.Command, _ []string) (err error) {
+			return rest(parseFromFlags, func(request request) (*resty.Response, error) {
+				return request.req.SetPathParam("name", request.name).SetPathParam("group", request.group).
+					Get(getPath(schemaPath + "/history" + pathTemp))
+			}, yamlPrinter, enableTLS, insecure, grpcCert)
+		},
+	}
+	rollbackCmd = &cobra.Command{
+		Use:     "rollback [-g group] -n name -r revision",
+		Version: version.Build(),
+		Short:   fmt.Sprintf("Roll a %s back to the version at the mod revision", resource),
+		RunE: func(_ *cobra.Command, _ []string) (err error) {
+			return rest(parseFromFlags, func(request request) (*resty.Response, error) {
+				return request.req.SetPathParam("name", request.name).SetPathParam("group", request.group).
+					SetBody(map[string]string{"modRevision": strconv.FormatInt(revision, 10)}).
+					Post(getPath(schemaPath + "/rollback" + pathTemp))
+			}, func(_ int, reqBody reqBody, _ []byte) error {
+				fmt.Printf("%s %s.%s is rolled back to revision %d", resource, reqBody.group, reqBody.name, revision)
+				fmt.Println()
+				return nil
+			}, enableTLS, insecure, grpcCert)
+		},
+	}
+	bindNameFlag(historyCmd, rollbackCmd)
+	rollbackCmd.Flags().Int64VarP(&revision, "revision", "r", 0, "the mod revision of the version to roll back to")
+	_ = rollbackCmd.MarkFlagRequired("revision")
+	bindTLSRelatedFlag(historyCmd, rollbackCmd)
+	return historyCmd, rollbackCmd
+}
+
 func bindTLSRelatedFlag(commands ...*cobra.Command) {
 	for _, c := range commands {
 		c.Flags().BoolVarP(&enableTLS, "enable-tls", "", false, "Used to enable tls")
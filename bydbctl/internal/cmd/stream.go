@@ -154,10 +154,24 @@ func newStreamCmd() *cobra.Command {
 				}, yamlPrinter, enableTLS, insecure, grpcCert)
 		},
 	}
-	bindFileFlag(createCmd, updateCmd, queryCmd)
-	bindTimeRangeFlag(queryCmd)
+	verifyIndexCmd := &cobra.Command{
+		Use:     "verify-index [-s start_time] [-e end_time] -f [file|dir|-]",
+		Version: version.Build(),
+		Short:   "Verify the index of the elements in a stream against the elements, and repair it if the input asks to",
+		Long:    timeRangeUsage,
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
+			return rest(func() ([]reqBody, error) { return parseTimeRangeFromFlagAndYAML(cmd.InOrStdin()) },
+				func(request request) (*resty.Response, error) {
+					return request.req.SetBody(request.data).Post(getPath("/api/v1/stream/verify-index"))
+				}, yamlPrinter, enableTLS, insecure, grpcCert)
+		},
+	}
+	bindFileFlag(createCmd, updateCmd, queryCmd, verifyIndexCmd)
+	bindTimeRangeFlag(queryCmd, verifyIndexCmd)
+
+	historyCmd, rollbackCmd := newSchemaHistoryCmds("stream", streamSchemaPath)
 
-	bindTLSRelatedFlag(getCmd, createCmd, deleteCmd, updateCmd, listCmd, queryCmd)
-	streamCmd.AddCommand(getCmd, createCmd, deleteCmd, updateCmd, listCmd, queryCmd)
+	bindTLSRelatedFlag(getCmd, createCmd, deleteCmd, updateCmd, listCmd, queryCmd, verifyIndexCmd)
+	streamCmd.AddCommand(getCmd, createCmd, deleteCmd, updateCmd, listCmd, queryCmd, verifyIndexCmd, historyCmd, rollbackCmd)
 	return streamCmd
 }
@@ -19,6 +19,7 @@ package cmd_test
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -154,6 +155,53 @@ entity:
 		Expect(err).To(MatchError("rpc error: code = NotFound desc = banyandb: resource not found"))
 	})
 
+	It("roll back stream schema", func() {
+		rootCmd.SetArgs([]string{"stream", "update", "-f", "-"})
+		rootCmd.SetIn(strings.NewReader(`
+metadata:
+  name: name1
+  group: group1
+tagFamilies:
+  - name: searchable
+    tags: 
+      - name: trace_id
+        type: TAG_TYPE_STRING
+      - name: duration
+        type: TAG_TYPE_INT
+entity:
+  tagNames: ["tag1"]`))
+		out := capturer.CaptureStdout(func() {
+			err := rootCmd.Execute()
+			Expect(err).NotTo(HaveOccurred())
+		})
+		Expect(out).To(ContainSubstring("stream group1.name1 is updated"))
+		rootCmd.SetArgs([]string{"stream", "history", "-g", "group1", "-n", "name1"})
+		out = capturer.CaptureStdout(func() {
+			err := rootCmd.Execute()
+			Expect(err).NotTo(HaveOccurred())
+		})
+		history := new(databasev1.StreamRegistryServiceGetSchemaHistoryResponse)
+		helpers.UnmarshalYAML([]byte(out), history)
+		Expect(history.Stream).To(HaveLen(2))
+		Expect(history.Stream[0].TagFamilies[0].Tags).To(HaveLen(2))
+		Expect(history.Stream[1].TagFamilies[0].Tags).To(HaveLen(1))
+		revision := strconv.FormatInt(history.Stream[1].Metadata.ModRevision, 10)
+		rootCmd.SetArgs([]string{"stream", "rollback", "-g", "group1", "-n", "name1", "-r", revision})
+		out = capturer.CaptureStdout(func() {
+			err := rootCmd.Execute()
+			Expect(err).NotTo(HaveOccurred())
+		})
+		Expect(out).To(ContainSubstring("stream group1.name1 is rolled back to revision " + revision))
+		rootCmd.SetArgs([]string{"stream", "get", "-g", "group1", "-n", "name1"})
+		out = capturer.CaptureStdout(func() {
+			err := rootCmd.Execute()
+			Expect(err).NotTo(HaveOccurred())
+		})
+		resp := new(databasev1.StreamRegistryServiceGetResponse)
+		helpers.UnmarshalYAML([]byte(out), resp)
+		Expect(resp.Stream.TagFamilies[0].Tags).To(HaveLen(1))
+	})
+
 	It("list stream schema", func() {
 		// create another stream schema for list operation
 		rootCmd.SetArgs([]string{"stream", "create", "-f", "-"})
@@ -244,6 +292,38 @@ projection:
 		}, flags.EventuallyTimeout).Should(Equal(5))
 	})
 
+	It("verify stream index", func() {
+		conn, err := grpclib.Dial(
+			grpcAddr,
+			grpclib.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		cases_stream_data.Write(conn, "data.json", now, interval)
+		rootCmd.SetArgs([]string{"stream", "verify-index", "-a", addr, "-f", "-"})
+		issue := func() string {
+			rootCmd.SetIn(strings.NewReader(fmt.Sprintf(`
+metadata:
+  name: sw
+  group: default
+timeRange:
+  begin: %s
+  end: %s`, nowStr, endStr)))
+			return capturer.CaptureStdout(func() {
+				err := rootCmd.Execute()
+				Expect(err).NotTo(HaveOccurred())
+			})
+		}
+		Eventually(issue, flags.EventuallyTimeout).ShouldNot(ContainSubstring("code:"))
+		Eventually(func() string {
+			out := issue()
+			resp := new(streamv1.VerifyIndexResponse)
+			helpers.UnmarshalYAML([]byte(out), resp)
+			GinkgoWriter.Println(resp)
+			return fmt.Sprintf("elements: %d, missing: %d, orphaned: %d", resp.GetElements(), resp.GetMissing(), resp.GetOrphaned())
+		}, flags.EventuallyTimeout).Should(Equal("elements: 5, missing: 0, orphaned: 0"))
+	})
+
 	DescribeTable("query stream data with time range flags", func(timeArgs ...string) {
 		conn, err := grpclib.Dial(
 			grpcAddr,
@@ -0,0 +1,160 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/pkg/version"
+)
+
+const (
+	tenantSchemaPath           = "/api/v1/tenant/schema"
+	tenantSchemaPathWithParams = tenantSchemaPath + "/{name}"
+)
+
+func newTenantCmd() *cobra.Command {
+	tenantCmd := &cobra.Command{
+		Use:     "tenant",
+		Version: version.Build(),
+		Short:   "Tenant operation",
+	}
+
+	createCmd := &cobra.Command{
+		Use:     "create -f [file|dir|-]",
+		Version: version.Build(),
+		Short:   "Create tenants from files",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return rest(func() ([]reqBody, error) { return parseNameFromYAML(cmd.InOrStdin()) },
+				func(request request) (*resty.Response, error) {
+					t := new(databasev1.Tenant)
+					err := protojson.Unmarshal(request.data, t)
+					if err != nil {
+						return nil, err
+					}
+					cr := &databasev1.TenantRegistryServiceCreateRequest{
+						Tenant: t,
+					}
+					b, err := protojson.Marshal(cr)
+					if err != nil {
+						return nil, err
+					}
+					return request.req.SetBody(b).Post(getPath(tenantSchemaPath))
+				},
+				func(_ int, reqBody reqBody, _ []byte) error {
+					fmt.Printf("tenant %s is created", reqBody.name)
+					fmt.Println()
+					return nil
+				}, enableTLS, insecure, grpcCert)
+		},
+	}
+
+	updateCmd := &cobra.Command{
+		Use:     "update -f [file|dir|-]",
+		Version: version.Build(),
+		Short:   "Update tenants from files",
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
+			return rest(func() ([]reqBody, error) { return parseNameFromYAML(cmd.InOrStdin()) },
+				func(request request) (*resty.Response, error) {
+					t := new(databasev1.Tenant)
+					err := protojson.Unmarshal(request.data, t)
+					if err != nil {
+						return nil, err
+					}
+					cr := &databasev1.TenantRegistryServiceUpdateRequest{
+						Tenant: t,
+					}
+					b, err := protojson.Marshal(cr)
+					if err != nil {
+						return nil, err
+					}
+					return request.req.SetBody(b).SetPathParam("name", request.name).Put(getPath(tenantSchemaPathWithParams))
+				},
+				func(_ int, reqBody reqBody, _ []byte) error {
+					fmt.Printf("tenant %s is updated", reqBody.name)
+					fmt.Println()
+					return nil
+				}, enableTLS, insecure, grpcCert)
+		},
+	}
+
+	getCmd := &cobra.Command{
+		Use:     "get -n name",
+		Version: version.Build(),
+		Short:   "Get a tenant",
+		RunE: func(_ *cobra.Command, _ []string) (err error) {
+			return rest(parseTenantFromFlags, func(request request) (*resty.Response, error) {
+				return request.req.SetPathParam("name", request.name).Get(getPath(tenantSchemaPathWithParams))
+			}, yamlPrinter, enableTLS, insecure, grpcCert)
+		},
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:     "delete -n name",
+		Version: version.Build(),
+		Short:   "Delete a tenant",
+		RunE: func(_ *cobra.Command, _ []string) (err error) {
+			return rest(parseTenantFromFlags, func(request request) (*resty.Response, error) {
+				return request.req.SetPathParam("name", request.name).Delete(getPath(tenantSchemaPathWithParams))
+			}, func(_ int, reqBody reqBody, _ []byte) error {
+				fmt.Printf("tenant %s is deleted", reqBody.name)
+				fmt.Println()
+				return nil
+			}, enableTLS, insecure, grpcCert)
+		},
+	}
+	bindNameFlag(getCmd, deleteCmd)
+
+	listCmd := &cobra.Command{
+		Use:     "list",
+		Version: version.Build(),
+		Short:   "List all tenants",
+		RunE: func(_ *cobra.Command, _ []string) (err error) {
+			return rest(nil, func(request request) (*resty.Response, error) {
+				return request.req.Get(getPath("/api/v1/tenant/schema/lists"))
+			}, yamlPrinter, enableTLS, insecure, grpcCert)
+		},
+	}
+
+	usageCmd := &cobra.Command{
+		Use:     "usage",
+		Version: version.Build(),
+		Short:   "List the usages of the tenants accounted by the liaison",
+		RunE: func(_ *cobra.Command, _ []string) (err error) {
+			return rest(nil, func(request request) (*resty.Response, error) {
+				return request.req.Get(getPath("/api/v1/tenant/usages"))
+			}, yamlPrinter, enableTLS, insecure, grpcCert)
+		},
+	}
+
+	bindFileFlag(createCmd, updateCmd)
+
+	bindTLSRelatedFlag(getCmd, createCmd, deleteCmd, updateCmd, listCmd, usageCmd)
+	tenantCmd.AddCommand(getCmd, createCmd, deleteCmd, updateCmd, listCmd, usageCmd)
+	return tenantCmd
+}
+
+// parseTenantFromFlags takes the name only, since a tenant isn't in any group.
+func parseTenantFromFlags() ([]reqBody, error) {
+	return []reqBody{{name: name}}, nil
+}
@@ -20,6 +20,7 @@
 package bus
 
 import (
+	"context"
 	"errors"
 	"io"
 	"sync"
@@ -45,6 +46,7 @@ type (
 // Message is send on the bus to all subscribed listeners.
 type Message struct {
 	payload   payload
+	ctx       context.Context
 	node      string
 	id        MessageID
 	batchMode bool
@@ -65,6 +67,21 @@ func (m Message) Node() string {
 	return m.node
 }
 
+// Context returns the context of the request carried by the Message, which is canceled once its sender gives up.
+// It's the background context if the Message carries none.
+func (m Message) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// WithContext returns a copy of the Message carrying ctx.
+func (m Message) WithContext(ctx context.Context) Message {
+	m.ctx = ctx
+	return m
+}
+
 // BatchModeEnabled returns whether the Message is sent in batch mode.
 func (m Message) BatchModeEnabled() bool {
 	return m.batchMode
@@ -209,6 +226,16 @@ type event struct {
 
 // Publish sends Messages to a Topic.
 func (b *Bus) Publish(topic Topic, message ...Message) (Future, error) {
+	return b.publish(topic, topic.typ == chTypeBidirectional, message...)
+}
+
+// PublishSync sends Messages to a Topic, and returns the Future of the responses even if the Topic is unidirectional.
+// It lets the publisher wait until the Messages are handled.
+func (b *Bus) PublishSync(topic Topic, message ...Message) (Future, error) {
+	return b.publish(topic, true, message...)
+}
+
+func (b *Bus) publish(topic Topic, withFuture bool, message ...Message) (Future, error) {
 	if topic.id == "" {
 		return nil, errTopicEmpty
 	}
@@ -219,10 +246,7 @@ func (b *Bus) Publish(topic Topic, message ...Message) (Future, error) {
 		return nil, ErrTopicNotExist
 	}
 	var f Future
-	switch topic.typ {
-	case chTypeUnidirectional:
-		f = nil
-	case chTypeBidirectional:
+	if withFuture {
 		f = &localFuture{retCount: len(message), retCh: make(chan Message)}
 	}
 	for _, each := range cc {
@@ -18,6 +18,7 @@
 package bus
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"sort"
@@ -216,6 +217,64 @@ func TestBus_PubAndSub(t *testing.T) {
 	}
 }
 
+func TestBus_PublishSync(t *testing.T) {
+	e := NewBus()
+	topic := UniTopic("sync")
+	ret := make(chan Message, 2)
+	ret <- NewMessage(1, nil)
+	ret <- NewMessage(2, nil)
+	ml := &mockListener{ret: ret}
+	if err := e.Subscribe(topic, ml); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	f, err := e.PublishSync(topic, NewMessage(1, nil), NewMessage(2, nil))
+	if err != nil {
+		t.Fatalf("PublishSync() error = %v", err)
+	}
+	got, err := f.GetAll()
+	if err != nil {
+		t.Fatalf("PublishSync()'s return message error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("PublishSync()'s return = %v, want 2 messages", got)
+	}
+	if !reflect.DeepEqual(ml.queue, []MessageID{1, 2}) {
+		t.Errorf("Bus got = %v, wanted %v", ml.queue, []MessageID{1, 2})
+	}
+}
+
+func TestBus_MessageContext(t *testing.T) {
+	if err := NewMessage(1, nil).Context().Err(); err != nil {
+		t.Fatalf("the context of a Message without one = %v, want the background context", err)
+	}
+	e := NewBus()
+	topic := BiTopic("context")
+	if err := e.Subscribe(topic, &contextListener{}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := e.Publish(topic, NewMessage(1, nil).WithContext(ctx))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	// the listener waits until the publisher gives up
+	cancel()
+	got, err := f.Get()
+	if err != nil {
+		t.Fatalf("Publish()'s return message error = %v", err)
+	}
+	if errData, _ := got.Data().(error); !errors.Is(errData, context.Canceled) {
+		t.Errorf("the listener got = %v, want %v", got.Data(), context.Canceled)
+	}
+}
+
+type contextListener struct{}
+
+func (contextListener) Rev(message Message) Message {
+	<-message.Context().Done()
+	return NewMessage(message.ID(), message.Context().Err())
+}
+
 func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
 	c := make(chan struct{})
 	go func() {
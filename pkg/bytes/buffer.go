@@ -21,7 +21,6 @@ package bytes
 import (
 	"fmt"
 	"io"
-	"sync"
 
 	"github.com/apache/skywalking-banyandb/pkg/fs"
 )
@@ -106,23 +105,3 @@ func (r *reader) Close() error {
 	r.MustClose()
 	return nil
 }
-
-// BufferPool is a pool of Buffer.
-type BufferPool struct {
-	p sync.Pool
-}
-
-// Generate generates a Buffer.
-func (bp *BufferPool) Generate() *Buffer {
-	bbv := bp.p.Get()
-	if bbv == nil {
-		return &Buffer{}
-	}
-	return bbv.(*Buffer)
-}
-
-// Release releases a Buffer.
-func (bp *BufferPool) Release(b *Buffer) {
-	b.Reset()
-	bp.p.Put(b)
-}
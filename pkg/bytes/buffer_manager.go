@@ -0,0 +1,204 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package bytes
+
+import (
+	"math/bits"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apache/skywalking-banyandb/pkg/meter"
+	"github.com/apache/skywalking-banyandb/pkg/pool"
+)
+
+const (
+	minSizeClassBits = 10
+	maxSizeClassBits = 26
+	sizeClassCount   = maxSizeClassBits - minSizeClassBits + 1
+
+	// the bytes a size class retains at most, which bounds the number of its buffers
+	sizeClassBytes        = 16 << 20
+	minBuffersInSizeClass = 2
+	maxBuffersInSizeClass = 256
+)
+
+var (
+	managersMu sync.Mutex
+	managers   []*BufferManager
+)
+
+// TrimBufferManagers drops the retained buffers larger than maxSize bytes from all the BufferManagers.
+func TrimBufferManagers(maxSize int) {
+	managersMu.Lock()
+	defer managersMu.Unlock()
+	for _, bm := range managers {
+		bm.Trim(maxSize)
+	}
+}
+
+// BufferManager hands out the Buffers from the pools of power-of-two size classes,
+// so that a buffer is reused by the requests of a similar size rather than regrown by every one of them.
+// The size classes range from 1KiB to 64MiB. A buffer larger than the largest class isn't retained.
+//
+// A size class retains a bounded number of buffers, and all of them retain at most the budget.
+// A released buffer is dropped once its size class is full, the budget is used up,
+// or its capacity exceeds the limit of capacity.
+type BufferManager struct {
+	capacity *pool.Capacity
+	retained meter.Gauge
+	classes  [sizeClassCount]sizeClass
+	budget   atomic.Int64
+	bytes    atomic.Int64
+}
+
+type sizeClass struct {
+	free     chan *Buffer
+	name     string
+	acquired atomic.Uint64
+	reused   atomic.Uint64
+	size     int
+}
+
+// SizeClassStats is the utilization of a size class.
+type SizeClassStats struct {
+	Size     int
+	Retained int
+	Acquired uint64
+	Reused   uint64
+}
+
+// NewBufferManager returns a BufferManager dropping the buffers whose capacity exceeds the limit of capacity.
+// The bytes retained by every size class are reported by retained, which is labeled by the size class.
+func NewBufferManager(capacity *pool.Capacity, retained meter.Gauge) *BufferManager {
+	bm := &BufferManager{capacity: capacity, retained: retained}
+	for i := range bm.classes {
+		size := 1 << (minSizeClassBits + i)
+		n := min(max(sizeClassBytes/size, minBuffersInSizeClass), maxBuffersInSizeClass)
+		bm.classes[i] = sizeClass{
+			free: make(chan *Buffer, n),
+			name: strconv.Itoa(size),
+			size: size,
+		}
+	}
+	managersMu.Lock()
+	managers = append(managers, bm)
+	managersMu.Unlock()
+	return bm
+}
+
+// SetBudget sets the max bytes of the buffers retained by all the size classes. Non-positive means unbounded.
+func (bm *BufferManager) SetBudget(n int64) {
+	bm.budget.Store(n)
+}
+
+// Acquire returns an empty Buffer whose capacity is at least n bytes.
+func (bm *BufferManager) Acquire(n int) *Buffer {
+	i := classOf(n)
+	if i >= sizeClassCount {
+		return &Buffer{Buf: make([]byte, 0, n)}
+	}
+	c := &bm.classes[i]
+	c.acquired.Add(1)
+	select {
+	case b := <-c.free:
+		c.reused.Add(1)
+		bm.untrack(c, b)
+		return b
+	default:
+		return &Buffer{Buf: make([]byte, 0, c.size)}
+	}
+}
+
+// Release resets the Buffer and returns it to the size class its capacity fits in.
+func (bm *BufferManager) Release(b *Buffer) {
+	b.Reset()
+	size := cap(b.Buf)
+	if size < 1<<minSizeClassBits || size > 1<<maxSizeClassBits || !bm.capacity.Retain(size) {
+		return
+	}
+	// the largest class the buffer can serve
+	i := bits.Len(uint(size)) - 1 - minSizeClassBits
+	if budget := bm.budget.Load(); budget > 0 && bm.bytes.Load()+int64(size) > budget {
+		return
+	}
+	c := &bm.classes[i]
+	select {
+	case c.free <- b:
+		bm.bytes.Add(int64(size))
+		if bm.retained != nil {
+			bm.retained.Add(float64(size), c.name)
+		}
+	default:
+	}
+}
+
+func (bm *BufferManager) untrack(c *sizeClass, b *Buffer) {
+	size := cap(b.Buf)
+	bm.bytes.Add(-int64(size))
+	if bm.retained != nil {
+		bm.retained.Add(-float64(size), c.name)
+	}
+}
+
+// Trim drops the retained buffers larger than maxSize bytes.
+func (bm *BufferManager) Trim(maxSize int) {
+	for i := range bm.classes {
+		c := &bm.classes[i]
+		if c.size <= maxSize {
+			continue
+		}
+		for {
+			select {
+			case b := <-c.free:
+				bm.untrack(c, b)
+				continue
+			default:
+			}
+			break
+		}
+	}
+}
+
+// RetainedBytes returns the bytes of the buffers retained by all the size classes.
+func (bm *BufferManager) RetainedBytes() int64 {
+	return bm.bytes.Load()
+}
+
+// Stats returns the utilization of the size classes.
+func (bm *BufferManager) Stats() []SizeClassStats {
+	stats := make([]SizeClassStats, 0, sizeClassCount)
+	for i := range bm.classes {
+		c := &bm.classes[i]
+		stats = append(stats, SizeClassStats{
+			Size:     c.size,
+			Retained: len(c.free),
+			Acquired: c.acquired.Load(),
+			Reused:   c.reused.Load(),
+		})
+	}
+	return stats
+}
+
+// classOf returns the smallest size class holding n bytes.
+func classOf(n int) int {
+	if n <= 1<<minSizeClassBits {
+		return 0
+	}
+	return bits.Len(uint(n-1)) - minSizeClassBits
+}
@@ -0,0 +1,86 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package bytes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apache/skywalking-banyandb/pkg/pool"
+)
+
+func TestBufferManagerAcquire(t *testing.T) {
+	bm := NewBufferManager(nil, nil)
+	for _, n := range []int{0, 1, 1024, 1025, 5000, 64 << 20} {
+		b := bm.Acquire(n)
+		assert.Empty(t, b.Buf)
+		assert.GreaterOrEqual(t, cap(b.Buf), n)
+		bm.Release(b)
+	}
+	huge := bm.Acquire(64<<20 + 1)
+	assert.Equal(t, 64<<20+1, cap(huge.Buf))
+	bm.Release(huge)
+	assert.Equal(t, int64(1024+2048+8192+64<<20), bm.RetainedBytes(), "the huge buffer isn't retained")
+}
+
+func TestBufferManagerReuse(t *testing.T) {
+	bm := NewBufferManager(nil, nil)
+	b := bm.Acquire(3000)
+	assert.Equal(t, 4096, cap(b.Buf))
+	b.Buf = append(b.Buf, make([]byte, 10000)...)
+	bm.Release(b)
+	assert.GreaterOrEqual(t, cap(b.Buf), 8192)
+	assert.Same(t, b, bm.Acquire(5000), "a grown buffer serves the size class fitting its capacity")
+
+	stats := bm.Stats()
+	assert.Equal(t, 8192, stats[3].Size)
+	assert.Equal(t, uint64(1), stats[3].Acquired)
+	assert.Equal(t, uint64(1), stats[3].Reused)
+	assert.Zero(t, stats[3].Retained)
+}
+
+func TestBufferManagerBudget(t *testing.T) {
+	c := pool.NewCapacity(nil, "test")
+	bm := NewBufferManager(c, nil)
+	bm.SetBudget(4096)
+	a, b := bm.Acquire(4096), bm.Acquire(4096)
+	bm.Release(a)
+	bm.Release(b)
+	assert.Equal(t, int64(4096), bm.RetainedBytes(), "the buffer over the budget is dropped")
+
+	c.SetMax(1024)
+	bm.SetBudget(0)
+	bm.Release(bm.Acquire(2048))
+	assert.Equal(t, int64(4096), bm.RetainedBytes(), "the buffer over the capacity is dropped")
+
+	for i := 0; i < maxBuffersInSizeClass+1; i++ {
+		bm.Release(&Buffer{Buf: make([]byte, 0, 1024)})
+	}
+	assert.Equal(t, int64(4096+maxBuffersInSizeClass*1024), bm.RetainedBytes(), "the full size class drops the buffer")
+}
+
+func TestBufferManagerTrim(t *testing.T) {
+	bm := NewBufferManager(nil, nil)
+	small, big := bm.Acquire(1024), bm.Acquire(1<<20)
+	bm.Release(small)
+	bm.Release(big)
+	TrimBufferManagers(64 << 10)
+	assert.Equal(t, int64(1024), bm.RetainedBytes())
+	assert.Same(t, small, bm.Acquire(1024))
+}
@@ -0,0 +1,241 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package client implements a client of BanyanDB, which builds the writes and the queries of streams and measures.
+//
+// The writes are built from the tag and the field names, and the client maps them to the order of the schema,
+// which is fetched from the server and cached.
+package client
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/pkg/grpchelper"
+)
+
+const (
+	defaultConnTimeout = 10 * time.Second
+	defaultRPCTimeout  = 30 * time.Second
+)
+
+// RetryPolicy decides how the calls failing with a transient error are retried.
+// The transient errors are the ones with the codes Unavailable, ResourceExhausted and Aborted.
+type RetryPolicy struct {
+	// MaxAttempts is the number of the attempts of a call, including the first one. The calls aren't retried if it's 1.
+	MaxAttempts int
+	// InitialBackoff is the backoff before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the backoff which grows by Multiplier after every retry.
+	MaxBackoff time.Duration
+	Multiplier float64
+}
+
+// DefaultRetryPolicy is the retry policy of a client unless WithRetryPolicy sets another one.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+}
+
+// backoff returns the backoff before the retry following the attempt, which is jittered by up to 20%.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		d *= p.Multiplier
+		if d >= float64(p.MaxBackoff) {
+			d = float64(p.MaxBackoff)
+			break
+		}
+	}
+	// nolint: gosec
+	return time.Duration(d * (0.8 + 0.2*rand.Float64()))
+}
+
+// Option configures a Client.
+type Option func(*options)
+
+type options struct {
+	dialOptions []grpc.DialOption
+	retry       RetryPolicy
+	connTimeout time.Duration
+	rpcTimeout  time.Duration
+}
+
+// WithDialOptions appends the options dialing the server, e.g. the transport credentials.
+// The connection is insecure unless they set the credentials.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
+
+// WithConnTimeout sets the timeout of connecting the server.
+func WithConnTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.connTimeout = timeout
+	}
+}
+
+// WithRPCTimeout sets the timeout of every attempt of a call.
+func WithRPCTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.rpcTimeout = timeout
+	}
+}
+
+// WithRetryPolicy sets the policy retrying the calls failing with a transient error.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// WithToken sets the bearer token authenticating the calls, which is sent in the plain text unless the connection uses TLS.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.dialOptions = append(o.dialOptions, grpc.WithPerRPCCredentials(bearerToken(token)))
+	}
+}
+
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(_ context.Context, _ ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
+
+// Client accesses the streams and the measures of a server. It's safe for concurrent use.
+type Client struct {
+	conn            *grpc.ClientConn
+	streamRegistry  databasev1.StreamRegistryServiceClient
+	measureRegistry databasev1.MeasureRegistryServiceClient
+	streamService   streamv1.StreamServiceClient
+	measureService  measurev1.MeasureServiceClient
+	streams         *schemaCache[*databasev1.Stream]
+	measures        *schemaCache[*databasev1.Measure]
+	opts            options
+	messageID       atomic.Uint64
+}
+
+// Connect returns a Client once it connects the server at addr.
+func Connect(addr string, opts ...Option) (*Client, error) {
+	o := options{
+		dialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		connTimeout: defaultConnTimeout,
+		rpcTimeout:  defaultRPCTimeout,
+		retry:       DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	conn, err := grpchelper.Conn(addr, o.connTimeout, o.dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(conn, o), nil
+}
+
+func newClient(conn *grpc.ClientConn, o options) *Client {
+	c := &Client{
+		conn:            conn,
+		opts:            o,
+		streamRegistry:  databasev1.NewStreamRegistryServiceClient(conn),
+		measureRegistry: databasev1.NewMeasureRegistryServiceClient(conn),
+		streamService:   streamv1.NewStreamServiceClient(conn),
+		measureService:  measurev1.NewMeasureServiceClient(conn),
+	}
+	c.streams = newSchemaCache(func(ctx context.Context, group, name string) (*databasev1.Stream, error) {
+		var s *databasev1.Stream
+		err := c.do(ctx, func(ctx context.Context) error {
+			resp, err := c.streamRegistry.Get(ctx, &databasev1.StreamRegistryServiceGetRequest{Metadata: metadata(group, name)})
+			s = resp.GetStream()
+			return err
+		})
+		return s, err
+	})
+	c.measures = newSchemaCache(func(ctx context.Context, group, name string) (*databasev1.Measure, error) {
+		var m *databasev1.Measure
+		err := c.do(ctx, func(ctx context.Context) error {
+			resp, err := c.measureRegistry.Get(ctx, &databasev1.MeasureRegistryServiceGetRequest{Metadata: metadata(group, name)})
+			m = resp.GetMeasure()
+			return err
+		})
+		return m, err
+	})
+	// the message IDs have to be positive
+	c.messageID.Store(uint64(time.Now().UnixNano()))
+	return c
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) nextMessageID() uint64 {
+	return c.messageID.Add(1)
+}
+
+// do calls fn until it succeeds, fails with an error which isn't transient, or runs out of the attempts.
+// Every attempt is bounded by the RPC timeout.
+func (c *Client) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := grpchelper.Request(ctx, c.opts.rpcTimeout, fn)
+		if err == nil || !isTransient(err) || attempt >= c.opts.retry.MaxAttempts {
+			return err
+		}
+		if err = sleep(ctx, c.opts.retry.backoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// isTransient reports whether the call failing with err might succeed once it's retried.
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,330 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+)
+
+var testTagFamilies = []*databasev1.TagFamilySpec{
+	{
+		Name: "data",
+		Tags: []*databasev1.TagSpec{{Name: "data_binary", Type: databasev1.TagType_TAG_TYPE_DATA_BINARY}},
+	},
+	{
+		Name: "searchable",
+		Tags: []*databasev1.TagSpec{
+			{Name: "trace_id", Type: databasev1.TagType_TAG_TYPE_STRING},
+			{Name: "state", Type: databasev1.TagType_TAG_TYPE_INT},
+			{Name: "extended_tags", Type: databasev1.TagType_TAG_TYPE_STRING_ARRAY},
+		},
+	},
+}
+
+func str(v string) *modelv1.TagValue {
+	return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: v}}}
+}
+
+func TestBuildTagFamilies(t *testing.T) {
+	tff, err := buildTagFamilies(testTagFamilies, map[string]any{
+		"state":    1,
+		"trace_id": "t1",
+	})
+	require.NoError(t, err)
+	want := []*modelv1.TagFamilyForWrite{
+		{Tags: []*modelv1.TagValue{nullTagValue}},
+		{Tags: []*modelv1.TagValue{
+			str("t1"),
+			{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: 1}}},
+			nullTagValue,
+		}},
+	}
+	assert.Empty(t, cmp.Diff(want, tff, protocmp.Transform()))
+
+	_, err = buildTagFamilies(testTagFamilies, map[string]any{"trace_id": "t1", "trace": "t1"})
+	assert.ErrorContains(t, err, "trace")
+	_, err = buildTagFamilies(testTagFamilies, map[string]any{"state": "1"})
+	assert.ErrorContains(t, err, "state")
+	_, err = buildTagFamilies(testTagFamilies, map[string]any{"state": 1.5})
+	assert.ErrorContains(t, err, "unsupported")
+}
+
+func TestBuildFields(t *testing.T) {
+	specs := []*databasev1.FieldSpec{
+		{Name: "total", FieldType: databasev1.FieldType_FIELD_TYPE_INT},
+		{Name: "ratio", FieldType: databasev1.FieldType_FIELD_TYPE_FLOAT},
+		{Name: "name", FieldType: databasev1.FieldType_FIELD_TYPE_STRING},
+	}
+	fields, err := buildFields(specs, map[string]any{"total": int32(3), "ratio": 2})
+	require.NoError(t, err)
+	want := []*modelv1.FieldValue{
+		{Value: &modelv1.FieldValue_Int{Int: &modelv1.Int{Value: 3}}},
+		{Value: &modelv1.FieldValue_Float{Float: &modelv1.Float{Value: 2}}},
+		nullFieldValue,
+	}
+	assert.Empty(t, cmp.Diff(want, fields, protocmp.Transform()))
+
+	_, err = buildFields(specs, map[string]any{"total": 1.5})
+	assert.ErrorContains(t, err, "total")
+	_, err = buildFields(specs, map[string]any{"value": 1})
+	assert.ErrorContains(t, err, "value")
+}
+
+func TestCriteria(t *testing.T) {
+	c := And(Eq("a", "1"), Or(Eq("b", 2), Eq("c", "3")))
+	require.NoError(t, c.err)
+	le := c.c.GetLe()
+	assert.Equal(t, modelv1.LogicalExpression_LOGICAL_OP_AND, le.GetOp())
+	assert.Equal(t, "a", le.GetLeft().GetCondition().GetName())
+	assert.Equal(t, modelv1.LogicalExpression_LOGICAL_OP_OR, le.GetRight().GetLe().GetOp())
+
+	c = And(Eq("a", "1"), Eq("b", struct{}{}))
+	assert.ErrorContains(t, c.err, `"b"`)
+	q := NewStreamQuery("default", "sw").Where(c)
+	_, err := (&Client{}).QueryStream(context.Background(), q)
+	assert.Error(t, err, "the query isn't sent")
+}
+
+func TestPages(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  page
+		total int
+		want  [][2]uint32
+	}{
+		{
+			name:  "no page size",
+			page:  page{offset: 2, limit: 10},
+			total: 100,
+			want:  [][2]uint32{{2, 10}},
+		},
+		{
+			name:  "run out of data",
+			page:  page{pageSize: 4},
+			total: 10,
+			want:  [][2]uint32{{0, 4}, {4, 4}, {8, 4}},
+		},
+		{
+			name:  "data in full pages",
+			page:  page{pageSize: 5},
+			total: 10,
+			want:  [][2]uint32{{0, 5}, {5, 5}, {10, 5}},
+		},
+		{
+			name:  "limit",
+			page:  page{offset: 1, limit: 7, pageSize: 3},
+			total: 100,
+			want:  [][2]uint32{{1, 3}, {4, 3}, {7, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][2]uint32
+			require.NoError(t, tt.page.pages(func(offset, limit uint32) (int, error) {
+				got = append(got, [2]uint32{offset, limit})
+				return max(0, min(int(limit), tt.total-int(offset))), nil
+			}))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 4}
+	for attempt, want := range []time.Duration{100 * time.Millisecond, 400 * time.Millisecond, time.Second, time.Second} {
+		got := p.backoff(attempt + 1)
+		assert.LessOrEqual(t, got, want)
+		assert.GreaterOrEqual(t, got, want*8/10)
+	}
+	assert.True(t, isTransient(status.Error(codes.Unavailable, "")))
+	assert.False(t, isTransient(status.Error(codes.InvalidArgument, "")))
+	assert.False(t, isTransient(errors.New("")))
+}
+
+// fakeWriteStream acknowledges the requests with the status of reply, and aborts once it acknowledges failAfter of them.
+type fakeWriteStream struct {
+	reply     func(req *streamv1.WriteRequest) modelv1.Status
+	responses chan *streamv1.WriteResponse
+	sent      *[]uint64
+	failAfter int
+	mu        sync.Mutex
+	acked     int
+}
+
+func (f *fakeWriteStream) Send(req *streamv1.WriteRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failAfter > 0 && f.acked >= f.failAfter {
+		return io.EOF
+	}
+	*f.sent = append(*f.sent, req.GetMessageId())
+	f.acked++
+	f.responses <- &streamv1.WriteResponse{MessageId: req.GetMessageId(), Status: f.reply(req)}
+	if f.failAfter > 0 && f.acked >= f.failAfter {
+		close(f.responses)
+	}
+	return nil
+}
+
+func (f *fakeWriteStream) Recv() (*streamv1.WriteResponse, error) {
+	resp, ok := <-f.responses
+	if !ok {
+		if f.failAfter > 0 {
+			return nil, status.Error(codes.Unavailable, "the stream is aborted")
+		}
+		return nil, io.EOF
+	}
+	return resp, nil
+}
+
+func (f *fakeWriteStream) CloseSend() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failAfter == 0 {
+		close(f.responses)
+	}
+	return nil
+}
+
+func newTestClient(fetch func(ctx context.Context, group, name string) (*databasev1.Stream, error)) *Client {
+	c := &Client{opts: options{
+		rpcTimeout: 10 * time.Second,
+		retry:      RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1},
+	}}
+	c.messageID.Store(1)
+	c.streams = newSchemaCache(fetch)
+	return c
+}
+
+func TestWriteRetriesUnacknowledged(t *testing.T) {
+	c := newTestClient(nil)
+	var reqs []*streamv1.WriteRequest
+	for i := 0; i < 5; i++ {
+		reqs = append(reqs, &streamv1.WriteRequest{MessageId: c.nextMessageID()})
+	}
+	var sent []uint64
+	streams := 0
+	statuses, err := writeAll(context.Background(), c, reqs,
+		func(context.Context) (writeStream[*streamv1.WriteRequest, *streamv1.WriteResponse], error) {
+			streams++
+			ws := &fakeWriteStream{
+				responses: make(chan *streamv1.WriteResponse, len(reqs)),
+				sent:      &sent,
+				reply:     func(*streamv1.WriteRequest) modelv1.Status { return modelv1.Status_STATUS_SUCCEED },
+			}
+			if streams == 1 {
+				ws.failAfter = 2
+			}
+			return ws, nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, 2, streams)
+	assert.Len(t, statuses, 5)
+	assert.Equal(t, []uint64{2, 3, 4, 5, 6}, sent, "the acknowledged requests aren't sent again")
+}
+
+func TestWriteStreamRefreshesExpiredSchema(t *testing.T) {
+	fetched := 0
+	c := newTestClient(func(_ context.Context, group, name string) (*databasev1.Stream, error) {
+		fetched++
+		s := &databasev1.Stream{Metadata: metadata(group, name), TagFamilies: testTagFamilies}
+		s.Metadata.ModRevision = int64(fetched)
+		return s, nil
+	})
+	var sent []uint64
+	open := func(context.Context) (writeStream[*streamv1.WriteRequest, *streamv1.WriteResponse], error) {
+		return &fakeWriteStream{
+			responses: make(chan *streamv1.WriteResponse, 10),
+			sent:      &sent,
+			reply: func(req *streamv1.WriteRequest) modelv1.Status {
+				switch {
+				case req.GetElement().GetElementId() == "bad":
+					return modelv1.Status_STATUS_INVALID_TIMESTAMP
+				case req.GetMetadata().GetModRevision() < 2:
+					return modelv1.Status_STATUS_EXPIRED_SCHEMA
+				}
+				return modelv1.Status_STATUS_SUCCEED
+			},
+		}, nil
+	}
+	build := func(s *databasev1.Stream, i int, messageID uint64) (*streamv1.WriteRequest, error) {
+		id := "good"
+		if i == 1 {
+			id = "bad"
+		}
+		tff, err := buildTagFamilies(s.GetTagFamilies(), map[string]any{"trace_id": id})
+		if err != nil {
+			return nil, err
+		}
+		return &streamv1.WriteRequest{
+			Metadata:  s.GetMetadata(),
+			Element:   &streamv1.ElementValue{ElementId: id, TagFamilies: tff},
+			MessageId: messageID,
+		}, nil
+	}
+	err := writeWithSchema(context.Background(), c, c.streams, "default", "sw", 3, build, open)
+	assert.Equal(t, 2, fetched, "the schema is fetched again once it's expired")
+	assert.Len(t, sent, 5, "the elements rejected by the expired schema are sent again")
+	var we *WriteError
+	require.ErrorAs(t, err, &we)
+	assert.Equal(t, map[int]modelv1.Status{1: modelv1.Status_STATUS_INVALID_TIMESTAMP}, we.Failed)
+}
+
+func TestSendRequestsReportsFailedIndexes(t *testing.T) {
+	c := newTestClient(nil)
+	reqs := []*streamv1.WriteRequest{
+		{Element: &streamv1.ElementValue{ElementId: "a"}},
+		{Element: &streamv1.ElementValue{ElementId: "b"}},
+		{Element: &streamv1.ElementValue{ElementId: "c"}},
+	}
+	var sent []uint64
+	err := sendRequests(context.Background(), c, reqs,
+		func(req *streamv1.WriteRequest, messageID uint64) { req.MessageId = messageID },
+		func(context.Context) (writeStream[*streamv1.WriteRequest, *streamv1.WriteResponse], error) {
+			return &fakeWriteStream{
+				responses: make(chan *streamv1.WriteResponse, len(reqs)),
+				sent:      &sent,
+				reply: func(req *streamv1.WriteRequest) modelv1.Status {
+					if req.GetElement().GetElementId() == "b" {
+						return modelv1.Status_STATUS_INVALID_TIMESTAMP
+					}
+					return modelv1.Status_STATUS_SUCCEED
+				},
+			}, nil
+		})
+	var writeErr *WriteError
+	require.ErrorAs(t, err, &writeErr)
+	assert.Equal(t, map[int]modelv1.Status{1: modelv1.Status_STATUS_INVALID_TIMESTAMP}, writeErr.Failed)
+	assert.Equal(t, []uint64{2, 3, 4}, sent, "the message IDs are assigned by the client")
+}
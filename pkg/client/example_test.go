@@ -0,0 +1,92 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/client"
+)
+
+func Example_stream() {
+	c, err := client.Connect("127.0.0.1:17912")
+	if err != nil {
+		panic(err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+	now := time.Now()
+	err = c.WriteStream(ctx, "default", "sw",
+		client.NewElement("1", now).
+			Tag("trace_id", "trace-1").
+			Tag("state", 1).
+			Tag("service_id", "webapp").
+			Tag("service_instance_id", "10.0.0.1_id"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	elements, err := c.QueryStream(ctx, client.NewStreamQuery("default", "sw").
+		TimeRange(now.Add(-time.Hour), now.Add(time.Hour)).
+		Where(client.And(client.Eq("service_id", "webapp"), client.Eq("state", 1))).
+		Project("searchable", "trace_id", "state").
+		OrderBy("", modelv1.Sort_SORT_DESC).
+		Limit(100).
+		PageSize(20))
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range elements {
+		fmt.Println(e.GetElementId(), e.GetTagFamilies())
+	}
+}
+
+func Example_measure() {
+	c, err := client.Connect("127.0.0.1:17912")
+	if err != nil {
+		panic(err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+	now := time.Now()
+	err = c.WriteMeasure(ctx, "sw_metric", "service_cpm_minute",
+		client.NewDataPoint(now).
+			Tag("id", "1").
+			Tag("entity_id", "entity_1").
+			Field("total", 100).
+			Field("value", 1),
+	)
+	if err != nil {
+		panic(err)
+	}
+	dataPoints, err := c.QueryMeasure(ctx, client.NewMeasureQuery("sw_metric", "service_cpm_minute").
+		TimeRange(now.Add(-time.Hour), now.Add(time.Hour)).
+		Project("default", "entity_id").
+		Fields("value").
+		GroupBy("value", "default", "entity_id").
+		Aggregate(modelv1.AggregationFunction_AGGREGATION_FUNCTION_SUM, "value"))
+	if err != nil {
+		panic(err)
+	}
+	for _, dp := range dataPoints {
+		fmt.Println(dp.GetTagFamilies(), dp.GetFields())
+	}
+}
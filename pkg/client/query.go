@@ -0,0 +1,333 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+)
+
+// Criteria filters the elements or the data points by their tags.
+type Criteria struct {
+	c   *modelv1.Criteria
+	err error
+}
+
+// Cond returns the criteria comparing the tag with the value by op. See DataPoint.Tag for the values supported.
+func Cond(tag string, op modelv1.Condition_BinaryOp, value any) Criteria {
+	tv, err := toTagValue(value)
+	if err != nil {
+		return Criteria{err: fmt.Errorf("condition on %q: %w", tag, err)}
+	}
+	return Criteria{c: &modelv1.Criteria{Exp: &modelv1.Criteria_Condition{Condition: &modelv1.Condition{
+		Name:  tag,
+		Op:    op,
+		Value: tv,
+	}}}}
+}
+
+// Eq returns the criteria matching the tag equal to the value.
+func Eq(tag string, value any) Criteria {
+	return Cond(tag, modelv1.Condition_BINARY_OP_EQ, value)
+}
+
+// And returns the criteria matching all of cc.
+func And(cc ...Criteria) Criteria {
+	return logical(modelv1.LogicalExpression_LOGICAL_OP_AND, cc)
+}
+
+// Or returns the criteria matching any of cc.
+func Or(cc ...Criteria) Criteria {
+	return logical(modelv1.LogicalExpression_LOGICAL_OP_OR, cc)
+}
+
+// Expr returns the criteria of the expression as it is, e.g. the one of a schema.
+func Expr(c *modelv1.Criteria) Criteria {
+	return Criteria{c: c}
+}
+
+func logical(op modelv1.LogicalExpression_LogicalOp, cc []Criteria) Criteria {
+	if len(cc) == 0 {
+		return Criteria{err: errors.New("a logical expression needs at least one criteria")}
+	}
+	result := cc[0]
+	for _, c := range cc[1:] {
+		if result.err != nil {
+			return result
+		}
+		if c.err != nil {
+			return c
+		}
+		result = Criteria{c: &modelv1.Criteria{Exp: &modelv1.Criteria_Le{Le: &modelv1.LogicalExpression{
+			Op:    op,
+			Left:  result.c,
+			Right: c.c,
+		}}}}
+	}
+	return result
+}
+
+// page is the offset and the limit of a query.
+type page struct {
+	offset   uint32
+	limit    uint32
+	pageSize uint32
+}
+
+// pages calls query with the offset and the limit of every page, until a page is shorter than its limit,
+// or the limit of the query is reached. The query is called once with the offset and the limit as they are
+// if the page size is 0.
+func (p page) pages(query func(offset, limit uint32) (int, error)) error {
+	if p.pageSize == 0 {
+		_, err := query(p.offset, p.limit)
+		return err
+	}
+	offset := p.offset
+	var fetched uint32
+	for {
+		limit := p.pageSize
+		if p.limit > 0 && p.limit-fetched < limit {
+			limit = p.limit - fetched
+		}
+		n, err := query(offset, limit)
+		if err != nil {
+			return err
+		}
+		fetched += uint32(n)
+		offset += uint32(n)
+		if uint32(n) < limit || (p.limit > 0 && fetched >= p.limit) {
+			return nil
+		}
+	}
+}
+
+// StreamQuery is a query of a stream, which is built by NewStreamQuery.
+type StreamQuery struct {
+	req  *streamv1.QueryRequest
+	err  error
+	page page
+}
+
+// NewStreamQuery returns a query of the stream.
+func NewStreamQuery(group, name string) *StreamQuery {
+	return &StreamQuery{req: &streamv1.QueryRequest{
+		Metadata:   metadata(group, name),
+		Projection: &modelv1.TagProjection{},
+	}}
+}
+
+// TimeRange limits the elements to the ones whose timestamps are in [begin, end].
+func (q *StreamQuery) TimeRange(begin, end time.Time) *StreamQuery {
+	q.req.TimeRange = &modelv1.TimeRange{Begin: timestamppb.New(begin), End: timestamppb.New(end)}
+	return q
+}
+
+// Where filters the elements by the criteria.
+func (q *StreamQuery) Where(c Criteria) *StreamQuery {
+	if c.err != nil {
+		q.err = c.err
+	}
+	q.req.Criteria = c.c
+	return q
+}
+
+// Project adds the tags of the tag family to the projection.
+func (q *StreamQuery) Project(tagFamily string, tags ...string) *StreamQuery {
+	q.req.Projection = addProjection(q.req.Projection, tagFamily, tags)
+	return q
+}
+
+// OrderBy sorts the elements by the index rule, or by their timestamps if indexRule is empty.
+func (q *StreamQuery) OrderBy(indexRule string, sort modelv1.Sort) *StreamQuery {
+	q.req.OrderBy = &modelv1.QueryOrder{IndexRuleName: indexRule, Sort: sort}
+	return q
+}
+
+// Offset skips the first n elements.
+func (q *StreamQuery) Offset(n uint32) *StreamQuery {
+	q.page.offset = n
+	return q
+}
+
+// Limit returns n elements at most. The server decides the limit if it isn't set.
+func (q *StreamQuery) Limit(n uint32) *StreamQuery {
+	q.page.limit = n
+	return q
+}
+
+// PageSize fetches the elements in pages of n elements, until the limit is reached or the elements run out.
+// The pages are fetched by the offsets, so they might miss or repeat the elements written in between.
+func (q *StreamQuery) PageSize(n uint32) *StreamQuery {
+	q.page.pageSize = n
+	return q
+}
+
+// QueryStream returns the elements matching the query.
+func (c *Client) QueryStream(ctx context.Context, q *StreamQuery) ([]*streamv1.Element, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	var result []*streamv1.Element
+	err := q.page.pages(func(offset, limit uint32) (int, error) {
+		req := proto.Clone(q.req).(*streamv1.QueryRequest)
+		req.Offset, req.Limit = offset, limit
+		var resp *streamv1.QueryResponse
+		if err := c.do(ctx, func(ctx context.Context) (err error) {
+			resp, err = c.streamService.Query(ctx, req)
+			return err
+		}); err != nil {
+			return 0, err
+		}
+		result = append(result, resp.GetElements()...)
+		return len(resp.GetElements()), nil
+	})
+	return result, err
+}
+
+// MeasureQuery is a query of a measure, which is built by NewMeasureQuery.
+type MeasureQuery struct {
+	req  *measurev1.QueryRequest
+	err  error
+	page page
+}
+
+// NewMeasureQuery returns a query of the measure.
+func NewMeasureQuery(group, name string) *MeasureQuery {
+	return &MeasureQuery{req: &measurev1.QueryRequest{Metadata: metadata(group, name)}}
+}
+
+// TimeRange limits the data points to the ones whose timestamps are in [begin, end]. It's required.
+func (q *MeasureQuery) TimeRange(begin, end time.Time) *MeasureQuery {
+	q.req.TimeRange = &modelv1.TimeRange{Begin: timestamppb.New(begin), End: timestamppb.New(end)}
+	return q
+}
+
+// Where filters the data points by the criteria.
+func (q *MeasureQuery) Where(c Criteria) *MeasureQuery {
+	if c.err != nil {
+		q.err = c.err
+	}
+	q.req.Criteria = c.c
+	return q
+}
+
+// Project adds the tags of the tag family to the projection.
+func (q *MeasureQuery) Project(tagFamily string, tags ...string) *MeasureQuery {
+	q.req.TagProjection = addProjection(q.req.TagProjection, tagFamily, tags)
+	return q
+}
+
+// Fields adds the fields to the projection.
+func (q *MeasureQuery) Fields(names ...string) *MeasureQuery {
+	if q.req.FieldProjection == nil {
+		q.req.FieldProjection = &measurev1.QueryRequest_FieldProjection{}
+	}
+	q.req.FieldProjection.Names = append(q.req.FieldProjection.Names, names...)
+	return q
+}
+
+// GroupBy groups the data points by the tags of the tag family, and the field is the one aggregated in a group.
+func (q *MeasureQuery) GroupBy(field, tagFamily string, tags ...string) *MeasureQuery {
+	if q.req.GroupBy == nil {
+		q.req.GroupBy = &measurev1.QueryRequest_GroupBy{}
+	}
+	q.req.GroupBy.FieldName = field
+	q.req.GroupBy.TagProjection = addProjection(q.req.GroupBy.TagProjection, tagFamily, tags)
+	return q
+}
+
+// Aggregate aggregates the field by the function, in every group if GroupBy is set.
+func (q *MeasureQuery) Aggregate(function modelv1.AggregationFunction, field string) *MeasureQuery {
+	q.req.Agg = &measurev1.QueryRequest_Aggregation{Function: function, FieldName: field}
+	return q
+}
+
+// Top returns the top n data points sorted by the field.
+func (q *MeasureQuery) Top(n int32, field string, sort modelv1.Sort) *MeasureQuery {
+	q.req.Top = &measurev1.QueryRequest_Top{Number: n, FieldName: field, FieldValueSort: sort}
+	return q
+}
+
+// OrderBy sorts the data points by the index rule, or by their timestamps if indexRule is empty.
+func (q *MeasureQuery) OrderBy(indexRule string, sort modelv1.Sort) *MeasureQuery {
+	q.req.OrderBy = &modelv1.QueryOrder{IndexRuleName: indexRule, Sort: sort}
+	return q
+}
+
+// Offset skips the first n data points.
+func (q *MeasureQuery) Offset(n uint32) *MeasureQuery {
+	q.page.offset = n
+	return q
+}
+
+// Limit returns n data points at most. The server decides the limit if it isn't set.
+func (q *MeasureQuery) Limit(n uint32) *MeasureQuery {
+	q.page.limit = n
+	return q
+}
+
+// PageSize fetches the data points in pages of n data points, see StreamQuery.PageSize.
+func (q *MeasureQuery) PageSize(n uint32) *MeasureQuery {
+	q.page.pageSize = n
+	return q
+}
+
+// QueryMeasure returns the data points matching the query.
+func (c *Client) QueryMeasure(ctx context.Context, q *MeasureQuery) ([]*measurev1.DataPoint, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	var result []*measurev1.DataPoint
+	err := q.page.pages(func(offset, limit uint32) (int, error) {
+		req := proto.Clone(q.req).(*measurev1.QueryRequest)
+		req.Offset, req.Limit = offset, limit
+		var resp *measurev1.QueryResponse
+		if err := c.do(ctx, func(ctx context.Context) (err error) {
+			resp, err = c.measureService.Query(ctx, req)
+			return err
+		}); err != nil {
+			return 0, err
+		}
+		result = append(result, resp.GetDataPoints()...)
+		return len(resp.GetDataPoints()), nil
+	})
+	return result, err
+}
+
+func addProjection(projection *modelv1.TagProjection, tagFamily string, tags []string) *modelv1.TagProjection {
+	if projection == nil {
+		projection = &modelv1.TagProjection{}
+	}
+	for _, tf := range projection.TagFamilies {
+		if tf.Name == tagFamily {
+			tf.Tags = append(tf.Tags, tags...)
+			return projection
+		}
+	}
+	projection.TagFamilies = append(projection.TagFamilies, &modelv1.TagProjection_TagFamily{Name: tagFamily, Tags: tags})
+	return projection
+}
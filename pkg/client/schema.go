@@ -0,0 +1,267 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+func metadata(group, name string) *commonv1.Metadata {
+	return &commonv1.Metadata{Group: group, Name: name}
+}
+
+// schemaCache caches the schemas fetched from the server by their groups and names.
+type schemaCache[T any] struct {
+	fetch   func(ctx context.Context, group, name string) (T, error)
+	schemas map[string]T
+	mu      sync.RWMutex
+}
+
+func newSchemaCache[T any](fetch func(ctx context.Context, group, name string) (T, error)) *schemaCache[T] {
+	return &schemaCache[T]{
+		fetch:   fetch,
+		schemas: make(map[string]T),
+	}
+}
+
+func (sc *schemaCache[T]) load(ctx context.Context, group, name string) (T, error) {
+	key := group + "/" + name
+	sc.mu.RLock()
+	s, ok := sc.schemas[key]
+	sc.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
+	s, err := sc.fetch(ctx, group, name)
+	if err != nil {
+		return s, fmt.Errorf("cannot fetch the schema of %s: %w", key, err)
+	}
+	sc.mu.Lock()
+	sc.schemas[key] = s
+	sc.mu.Unlock()
+	return s, nil
+}
+
+// invalidate drops the schema, which is fetched again by the next load.
+func (sc *schemaCache[T]) invalidate(group, name string) {
+	sc.mu.Lock()
+	delete(sc.schemas, group+"/"+name)
+	sc.mu.Unlock()
+}
+
+// buildTagFamilies maps the tags keyed by their names to the tag families in the order of the schema.
+// The tags absent in tags are nulls.
+func buildTagFamilies(specs []*databasev1.TagFamilySpec, tags map[string]any) ([]*modelv1.TagFamilyForWrite, error) {
+	result := make([]*modelv1.TagFamilyForWrite, 0, len(specs))
+	found := 0
+	for _, tfs := range specs {
+		tf := &modelv1.TagFamilyForWrite{Tags: make([]*modelv1.TagValue, 0, len(tfs.GetTags()))}
+		for _, ts := range tfs.GetTags() {
+			v, ok := tags[ts.GetName()]
+			if !ok {
+				tf.Tags = append(tf.Tags, nullTagValue)
+				continue
+			}
+			found++
+			tv, err := toTagValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("tag %q: %w", ts.GetName(), err)
+			}
+			if err = checkTagType(tv, ts.GetType()); err != nil {
+				return nil, fmt.Errorf("tag %q: %w", ts.GetName(), err)
+			}
+			tf.Tags = append(tf.Tags, tv)
+		}
+		result = append(result, tf)
+	}
+	if found < len(tags) {
+		return nil, fmt.Errorf("the tags %v aren't in the schema", unknownNames(tags, func(name string) bool {
+			for _, tfs := range specs {
+				for _, ts := range tfs.GetTags() {
+					if ts.GetName() == name {
+						return true
+					}
+				}
+			}
+			return false
+		}))
+	}
+	return result, nil
+}
+
+// buildFields maps the fields keyed by their names to the order of the schema. The fields absent in fields are nulls.
+func buildFields(specs []*databasev1.FieldSpec, fields map[string]any) ([]*modelv1.FieldValue, error) {
+	result := make([]*modelv1.FieldValue, 0, len(specs))
+	found := 0
+	for _, fs := range specs {
+		v, ok := fields[fs.GetName()]
+		if !ok {
+			result = append(result, nullFieldValue)
+			continue
+		}
+		found++
+		fv, err := toFieldValue(v, fs.GetFieldType())
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", fs.GetName(), err)
+		}
+		result = append(result, fv)
+	}
+	if found < len(fields) {
+		return nil, fmt.Errorf("the fields %v aren't in the schema", unknownNames(fields, func(name string) bool {
+			for _, fs := range specs {
+				if fs.GetName() == name {
+					return true
+				}
+			}
+			return false
+		}))
+	}
+	return result, nil
+}
+
+func unknownNames(values map[string]any, known func(name string) bool) []string {
+	var names []string
+	for name := range values {
+		if !known(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+var (
+	nullTagValue   = &modelv1.TagValue{Value: &modelv1.TagValue_Null{Null: structpb.NullValue_NULL_VALUE}}
+	nullFieldValue = &modelv1.FieldValue{Value: &modelv1.FieldValue_Null{Null: structpb.NullValue_NULL_VALUE}}
+)
+
+// toTagValue converts a Go value to a tag value: the strings, the integers, the slices of them, and the bytes.
+// A nil is a null, and a *modelv1.TagValue is taken as it is.
+func toTagValue(v any) (*modelv1.TagValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return nullTagValue, nil
+	case *modelv1.TagValue:
+		return v, nil
+	case string:
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: v}}}, nil
+	case []string:
+		return &modelv1.TagValue{Value: &modelv1.TagValue_StrArray{StrArray: &modelv1.StrArray{Value: v}}}, nil
+	case []byte:
+		return &modelv1.TagValue{Value: &modelv1.TagValue_BinaryData{BinaryData: v}}, nil
+	case []int64:
+		return &modelv1.TagValue{Value: &modelv1.TagValue_IntArray{IntArray: &modelv1.IntArray{Value: v}}}, nil
+	case []int:
+		arr := make([]int64, 0, len(v))
+		for _, i := range v {
+			arr = append(arr, int64(i))
+		}
+		return &modelv1.TagValue{Value: &modelv1.TagValue_IntArray{IntArray: &modelv1.IntArray{Value: arr}}}, nil
+	}
+	if i, ok := toInt64(v); ok {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: i}}}, nil
+	}
+	return nil, fmt.Errorf("unsupported tag value %v of type %T", v, v)
+}
+
+func checkTagType(tv *modelv1.TagValue, t databasev1.TagType) error {
+	var ok bool
+	switch tv.GetValue().(type) {
+	case *modelv1.TagValue_Null:
+		ok = true
+	case *modelv1.TagValue_Str:
+		ok = t == databasev1.TagType_TAG_TYPE_STRING
+	case *modelv1.TagValue_StrArray:
+		ok = t == databasev1.TagType_TAG_TYPE_STRING_ARRAY
+	case *modelv1.TagValue_Int:
+		ok = t == databasev1.TagType_TAG_TYPE_INT
+	case *modelv1.TagValue_IntArray:
+		ok = t == databasev1.TagType_TAG_TYPE_INT_ARRAY
+	case *modelv1.TagValue_BinaryData:
+		ok = t == databasev1.TagType_TAG_TYPE_DATA_BINARY
+	}
+	if !ok {
+		return fmt.Errorf("the value %v doesn't match the type %s", tv, t)
+	}
+	return nil
+}
+
+// toFieldValue converts a Go value to a field value of the type.
+// The integers are converted to the floats if the type is FIELD_TYPE_FLOAT.
+func toFieldValue(v any, t databasev1.FieldType) (*modelv1.FieldValue, error) {
+	if v == nil {
+		return nullFieldValue, nil
+	}
+	if fv, ok := v.(*modelv1.FieldValue); ok {
+		return fv, nil
+	}
+	switch t {
+	case databasev1.FieldType_FIELD_TYPE_STRING:
+		if s, ok := v.(string); ok {
+			return &modelv1.FieldValue{Value: &modelv1.FieldValue_Str{Str: &modelv1.Str{Value: s}}}, nil
+		}
+	case databasev1.FieldType_FIELD_TYPE_INT:
+		if i, ok := toInt64(v); ok {
+			return &modelv1.FieldValue{Value: &modelv1.FieldValue_Int{Int: &modelv1.Int{Value: i}}}, nil
+		}
+	case databasev1.FieldType_FIELD_TYPE_DATA_BINARY:
+		if b, ok := v.([]byte); ok {
+			return &modelv1.FieldValue{Value: &modelv1.FieldValue_BinaryData{BinaryData: b}}, nil
+		}
+	case databasev1.FieldType_FIELD_TYPE_FLOAT:
+		switch f := v.(type) {
+		case float64:
+			return &modelv1.FieldValue{Value: &modelv1.FieldValue_Float{Float: &modelv1.Float{Value: f}}}, nil
+		case float32:
+			return &modelv1.FieldValue{Value: &modelv1.FieldValue_Float{Float: &modelv1.Float{Value: float64(f)}}}, nil
+		}
+		if i, ok := toInt64(v); ok {
+			return &modelv1.FieldValue{Value: &modelv1.FieldValue_Float{Float: &modelv1.Float{Value: float64(i)}}}, nil
+		}
+	}
+	return nil, fmt.Errorf("the value %v of type %T doesn't match the type %s", v, v, t)
+}
+
+func toInt64(v any) (int64, bool) {
+	switch i := v.(type) {
+	case int:
+		return int64(i), true
+	case int8:
+		return int64(i), true
+	case int16:
+		return int64(i), true
+	case int32:
+		return int64(i), true
+	case int64:
+		return i, true
+	case uint8:
+		return int64(i), true
+	case uint16:
+		return int64(i), true
+	case uint32:
+		return int64(i), true
+	}
+	return 0, false
+}
@@ -0,0 +1,350 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+)
+
+// WriteError reports the elements or the data points the server failed to write.
+type WriteError struct {
+	// Failed maps the indexes of the failed ones in the batch to their statuses.
+	Failed map[int]modelv1.Status
+}
+
+func (e *WriteError) Error() string {
+	indexes := make([]int, 0, len(e.Failed))
+	for i := range e.Failed {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d writes failed:", len(indexes))
+	for _, i := range indexes {
+		fmt.Fprintf(&sb, " [%d] %s", i, e.Failed[i])
+	}
+	return sb.String()
+}
+
+// Element is a stream element to write, which is built by NewElement.
+type Element struct {
+	timestamp time.Time
+	tags      map[string]any
+	id        string
+}
+
+// NewElement returns an element with the ID and the timestamp.
+func NewElement(id string, timestamp time.Time) *Element {
+	return &Element{id: id, timestamp: timestamp, tags: make(map[string]any)}
+}
+
+// Tag sets the value of the tag, see DataPoint.Tag for the values supported.
+func (e *Element) Tag(name string, value any) *Element {
+	e.tags[name] = value
+	return e
+}
+
+// DataPoint is a measure data point to write, which is built by NewDataPoint.
+type DataPoint struct {
+	timestamp time.Time
+	tags      map[string]any
+	fields    map[string]any
+}
+
+// NewDataPoint returns a data point with the timestamp.
+func NewDataPoint(timestamp time.Time) *DataPoint {
+	return &DataPoint{timestamp: timestamp, tags: make(map[string]any), fields: make(map[string]any)}
+}
+
+// Tag sets the value of the tag. The value could be a string, an integer, a []string, a []int64, a []int, a []byte,
+// a *modelv1.TagValue, or a nil for a null. It has to match the type of the tag in the schema.
+func (dp *DataPoint) Tag(name string, value any) *DataPoint {
+	dp.tags[name] = value
+	return dp
+}
+
+// Field sets the value of the field. The value could be a string, an integer, a float, a []byte,
+// a *modelv1.FieldValue, or a nil for a null. It has to match the type of the field in the schema,
+// except that an integer is converted to a float for a float field.
+func (dp *DataPoint) Field(name string, value any) *DataPoint {
+	dp.fields[name] = value
+	return dp
+}
+
+// WriteStream writes the elements to the stream, and returns once the server acknowledges all of them.
+// The tags absent in an element are written as nulls.
+//
+// The elements the server rejects since the schema doesn't match its revision are written again with the schema fetched again.
+// A *WriteError reports the elements the server fails to write.
+func (c *Client) WriteStream(ctx context.Context, group, name string, elements ...*Element) error {
+	return writeWithSchema(ctx, c, c.streams, group, name, len(elements),
+		func(s *databasev1.Stream, i int, messageID uint64) (*streamv1.WriteRequest, error) {
+			e := elements[i]
+			tff, err := buildTagFamilies(s.GetTagFamilies(), e.tags)
+			if err != nil {
+				return nil, fmt.Errorf("element %q: %w", e.id, err)
+			}
+			return &streamv1.WriteRequest{
+				Metadata: s.GetMetadata(),
+				Element: &streamv1.ElementValue{
+					ElementId:   e.id,
+					Timestamp:   timestamppb.New(e.timestamp),
+					TagFamilies: tff,
+				},
+				MessageId: messageID,
+			}, nil
+		},
+		func(ctx context.Context) (writeStream[*streamv1.WriteRequest, *streamv1.WriteResponse], error) {
+			return c.streamService.Write(ctx)
+		})
+}
+
+// WriteMeasure writes the data points to the measure, and returns once the server acknowledges all of them.
+// The tags and the fields absent in a data point are written as nulls.
+//
+// The data points the server rejects since the schema doesn't match its revision are written again with the schema fetched again.
+// A *WriteError reports the data points the server fails to write.
+func (c *Client) WriteMeasure(ctx context.Context, group, name string, dataPoints ...*DataPoint) error {
+	return writeWithSchema(ctx, c, c.measures, group, name, len(dataPoints),
+		func(m *databasev1.Measure, i int, messageID uint64) (*measurev1.WriteRequest, error) {
+			dp := dataPoints[i]
+			tff, err := buildTagFamilies(m.GetTagFamilies(), dp.tags)
+			if err != nil {
+				return nil, fmt.Errorf("data point %d: %w", i, err)
+			}
+			fields, err := buildFields(m.GetFields(), dp.fields)
+			if err != nil {
+				return nil, fmt.Errorf("data point %d: %w", i, err)
+			}
+			return &measurev1.WriteRequest{
+				Metadata: m.GetMetadata(),
+				DataPoint: &measurev1.DataPointValue{
+					Timestamp:   timestamppb.New(dp.timestamp),
+					TagFamilies: tff,
+					Fields:      fields,
+				},
+				MessageId: messageID,
+			}, nil
+		},
+		func(ctx context.Context) (writeStream[*measurev1.WriteRequest, *measurev1.WriteResponse], error) {
+			return c.measureService.Write(ctx)
+		})
+}
+
+// SendStream writes the requests built by the caller, e.g. the ones decoded from a queue, and returns once the server
+// acknowledges all of them. Their message IDs are overwritten.
+// A *WriteError reports the requests the server fails to write by their indexes.
+func (c *Client) SendStream(ctx context.Context, reqs ...*streamv1.WriteRequest) error {
+	return sendRequests(ctx, c, reqs, func(req *streamv1.WriteRequest, messageID uint64) { req.MessageId = messageID },
+		func(ctx context.Context) (writeStream[*streamv1.WriteRequest, *streamv1.WriteResponse], error) {
+			return c.streamService.Write(ctx)
+		})
+}
+
+// SendMeasure writes the requests built by the caller, e.g. the ones decoded from a queue, and returns once the server
+// acknowledges all of them. Their message IDs are overwritten.
+// A *WriteError reports the requests the server fails to write by their indexes.
+func (c *Client) SendMeasure(ctx context.Context, reqs ...*measurev1.WriteRequest) error {
+	return sendRequests(ctx, c, reqs, func(req *measurev1.WriteRequest, messageID uint64) { req.MessageId = messageID },
+		func(ctx context.Context) (writeStream[*measurev1.WriteRequest, *measurev1.WriteResponse], error) {
+			return c.measureService.Write(ctx)
+		})
+}
+
+func sendRequests[Req writeRequest, Resp writeResponse](ctx context.Context, c *Client, reqs []Req,
+	setMessageID func(req Req, messageID uint64), open func(ctx context.Context) (writeStream[Req, Resp], error),
+) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+	for _, req := range reqs {
+		setMessageID(req, c.nextMessageID())
+	}
+	statuses, err := writeAll(ctx, c, reqs, open)
+	if err != nil {
+		return err
+	}
+	failed := make(map[int]modelv1.Status)
+	for i, req := range reqs {
+		if st, ok := statuses[req.GetMessageId()]; !ok {
+			// the server ends the stream without acknowledging it
+			failed[i] = modelv1.Status_STATUS_UNSPECIFIED
+		} else if st != modelv1.Status_STATUS_SUCCEED {
+			failed[i] = st
+		}
+	}
+	if len(failed) > 0 {
+		return &WriteError{Failed: failed}
+	}
+	return nil
+}
+
+type writeRequest interface {
+	GetMessageId() uint64
+}
+
+type writeResponse interface {
+	GetMessageId() uint64
+	GetStatus() modelv1.Status
+}
+
+type writeStream[Req writeRequest, Resp writeResponse] interface {
+	Send(Req) error
+	Recv() (Resp, error)
+	CloseSend() error
+}
+
+// writeWithSchema builds the requests with the cached schema and writes them.
+// The requests rejected since the schema is expired, or doesn't match the current revision of the server,
+// are built again with the schema fetched again, only once.
+func writeWithSchema[S any, Req writeRequest, Resp writeResponse](ctx context.Context, c *Client, schemas *schemaCache[S],
+	group, name string, n int, build func(schema S, i int, messageID uint64) (Req, error),
+	open func(ctx context.Context) (writeStream[Req, Resp], error),
+) error {
+	pending := make([]int, n)
+	for i := range pending {
+		pending[i] = i
+	}
+	failed := make(map[int]modelv1.Status)
+	for attempt := 0; attempt < 2 && len(pending) > 0; attempt++ {
+		s, err := schemas.load(ctx, group, name)
+		if err != nil {
+			return err
+		}
+		reqs := make([]Req, 0, len(pending))
+		indexes := make(map[uint64]int, len(pending))
+		for _, i := range pending {
+			req, errBuild := build(s, i, c.nextMessageID())
+			if errBuild != nil {
+				return errBuild
+			}
+			reqs = append(reqs, req)
+			indexes[req.GetMessageId()] = i
+		}
+		statuses, err := writeAll(ctx, c, reqs, open)
+		if err != nil {
+			return err
+		}
+		pending = pending[:0]
+		for id, i := range indexes {
+			st, ok := statuses[id]
+			if !ok {
+				// the server ends the stream without acknowledging it
+				failed[i] = modelv1.Status_STATUS_UNSPECIFIED
+				continue
+			}
+			switch st {
+			case modelv1.Status_STATUS_SUCCEED:
+				delete(failed, i)
+			case modelv1.Status_STATUS_EXPIRED_SCHEMA, modelv1.Status_STATUS_SCHEMA_REVISION_MISMATCH:
+				failed[i] = st
+				pending = append(pending, i)
+			default:
+				failed[i] = st
+			}
+		}
+		if len(pending) > 0 {
+			schemas.invalidate(group, name)
+		}
+	}
+	if len(failed) > 0 {
+		return &WriteError{Failed: failed}
+	}
+	return nil
+}
+
+// writeAll sends the requests and returns the statuses the server acknowledges them with by their message IDs.
+// Once the stream fails with a transient error, the requests unacknowledged are sent again on a new stream.
+func writeAll[Req writeRequest, Resp writeResponse](ctx context.Context, c *Client, reqs []Req,
+	open func(ctx context.Context) (writeStream[Req, Resp], error),
+) (map[uint64]modelv1.Status, error) {
+	statuses := make(map[uint64]modelv1.Status, len(reqs))
+	err := c.do(ctx, func(ctx context.Context) error {
+		unacknowledged := make([]Req, 0, len(reqs))
+		for _, req := range reqs {
+			if _, ok := statuses[req.GetMessageId()]; !ok {
+				unacknowledged = append(unacknowledged, req)
+			}
+		}
+		return sendAll(ctx, unacknowledged, statuses, open)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// sendAll sends the requests on a new stream, and records the statuses of the responses until the stream ends.
+// The responses are received while sending, so that neither side blocks on a full window.
+func sendAll[Req writeRequest, Resp writeResponse](ctx context.Context, reqs []Req, statuses map[uint64]modelv1.Status,
+	open func(ctx context.Context) (writeStream[Req, Resp], error),
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ws, err := open(ctx)
+	if err != nil {
+		return err
+	}
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			resp, errRecv := ws.Recv()
+			if errors.Is(errRecv, io.EOF) {
+				recvErr <- nil
+				return
+			}
+			if errRecv != nil {
+				recvErr <- errRecv
+				return
+			}
+			statuses[resp.GetMessageId()] = resp.GetStatus()
+		}
+	}()
+	for _, req := range reqs {
+		if err = ws.Send(req); err != nil {
+			break
+		}
+	}
+	// the stream is aborted if Send returns io.EOF, Recv returns the status then
+	if err != nil && !errors.Is(err, io.EOF) {
+		cancel()
+		<-recvErr
+		return err
+	}
+	if err == nil {
+		if err = ws.CloseSend(); err != nil {
+			cancel()
+			<-recvErr
+			return err
+		}
+	}
+	return <-recvErr
+}
@@ -24,12 +24,17 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/banyand/backup"
+	"github.com/apache/skywalking-banyandb/banyand/drain"
 	"github.com/apache/skywalking-banyandb/banyand/measure"
 	"github.com/apache/skywalking-banyandb/banyand/metadata"
 	"github.com/apache/skywalking-banyandb/banyand/observability"
 	"github.com/apache/skywalking-banyandb/banyand/query"
 	"github.com/apache/skywalking-banyandb/banyand/queue/sub"
+	"github.com/apache/skywalking-banyandb/banyand/snapshot"
 	"github.com/apache/skywalking-banyandb/banyand/stream"
+	"github.com/apache/skywalking-banyandb/banyand/tier"
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/run"
 	"github.com/apache/skywalking-banyandb/pkg/version"
@@ -38,7 +43,7 @@ import (
 func newDataCmd(runners ...run.Unit) *cobra.Command {
 	l := logger.GetLogger("bootstrap")
 	ctx := context.Background()
-	metaSvc, err := metadata.NewClient(false)
+	metaSvc, err := metadata.NewClusterClient(false)
 	if err != nil {
 		l.Fatal().Err(err).Msg("failed to initiate metadata service")
 	}
@@ -56,7 +61,12 @@ func newDataCmd(runners ...run.Unit) *cobra.Command {
 	if err != nil {
 		l.Fatal().Err(err).Msg("failed to initiate query processor")
 	}
+	drainSvc := drain.NewService(metaSvc, pipeline, streamSvc, measureSvc)
+	tierSvc := tier.NewService(pipeline, streamSvc, measureSvc)
+	snapshotSvc := snapshot.NewService(pipeline, streamSvc, measureSvc)
+	backupSvc := backup.NewService(snapshotSvc)
 	profSvc := observability.NewProfService()
+	watchdogSvc := watchdog.NewService()
 	metricSvc := observability.NewMetricService()
 
 	var units []run.Unit
@@ -67,7 +77,12 @@ func newDataCmd(runners ...run.Unit) *cobra.Command {
 		measureSvc,
 		streamSvc,
 		q,
+		drainSvc,
+		tierSvc,
+		snapshotSvc,
+		backupSvc,
 		profSvc,
+		watchdogSvc,
 	)
 	if metricSvc != nil {
 		units = append(units, metricSvc)
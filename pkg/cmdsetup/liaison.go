@@ -25,12 +25,16 @@ import (
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	"github.com/apache/skywalking-banyandb/banyand/dquery"
+	"github.com/apache/skywalking-banyandb/banyand/liaison/alert"
 	"github.com/apache/skywalking-banyandb/banyand/liaison/grpc"
 	"github.com/apache/skywalking-banyandb/banyand/liaison/http"
+	"github.com/apache/skywalking-banyandb/banyand/liaison/kafka"
+	"github.com/apache/skywalking-banyandb/banyand/liaison/otlp"
 	"github.com/apache/skywalking-banyandb/banyand/metadata"
 	"github.com/apache/skywalking-banyandb/banyand/observability"
 	"github.com/apache/skywalking-banyandb/banyand/queue"
 	"github.com/apache/skywalking-banyandb/banyand/queue/pub"
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/node"
 	"github.com/apache/skywalking-banyandb/pkg/run"
@@ -40,21 +44,23 @@ import (
 func newLiaisonCmd(runners ...run.Unit) *cobra.Command {
 	l := logger.GetLogger("bootstrap")
 	ctx := context.Background()
-	metaSvc, err := metadata.NewClient(false)
+	metaSvc, err := metadata.NewClusterClient(false)
 	if err != nil {
 		l.Fatal().Err(err).Msg("failed to initiate metadata service")
 	}
 	pipeline := pub.New(metaSvc)
 	localPipeline := queue.Local()
-	nodeSel, err := node.NewMaglevSelector()
+	nodeSel := node.NewRebalanceSelector()
+	placement, err := node.NewPlacement()
 	if err != nil {
-		l.Fatal().Err(err).Msg("failed to initiate required node selector")
+		l.Fatal().Err(err).Msg("failed to initiate the placement of tiers")
 	}
-	grpcServer := grpc.NewServer(ctx, pipeline, localPipeline, metaSvc, grpc.NewClusterNodeRegistry(pipeline, nodeSel))
+	grpcServer := grpc.NewServer(ctx, pipeline, localPipeline, metaSvc, grpc.NewClusterNodeRegistry(pipeline, nodeSel, placement))
 	profSvc := observability.NewProfService()
+	watchdogSvc := watchdog.NewService()
 	metricSvc := observability.NewMetricService()
 	httpServer := http.NewServer()
-	dQuery, err := dquery.NewService(metaSvc, localPipeline, pipeline)
+	dQuery, err := dquery.NewService(metaSvc, localPipeline, pipeline, nodeSel, placement, grpcServer.ReplicaLag())
 	if err != nil {
 		l.Fatal().Err(err).Msg("failed to initiate distributed query service")
 	}
@@ -67,7 +73,12 @@ func newLiaisonCmd(runners ...run.Unit) *cobra.Command {
 		dQuery,
 		grpcServer,
 		httpServer,
+		otlp.NewServer(),
+		kafka.NewBridge(),
+		alert.NewEvaluator(metaSvc),
 		profSvc,
+		watchdogSvc,
+		nodeSel,
 	)
 	if metricSvc != nil {
 		units = append(units, metricSvc)
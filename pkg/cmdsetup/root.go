@@ -24,6 +24,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/banyand/backup"
 	"github.com/apache/skywalking-banyandb/pkg/config"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/run"
@@ -60,6 +61,8 @@ BanyanDB, as an observability database, aims to ingest, analyze and store Metric
 	cmd.PersistentFlags().Var(&nodeIDProviderValue{&common.FlagNodeHostProvider},
 		"node-host-provider", "the node host provider, can be hostname, ip or flag, default is hostname")
 	cmd.PersistentFlags().StringVar(&common.FlagNodeHost, "node-host", "", "the node host of the server only used when node-host-provider is \"flag\"")
+	cmd.PersistentFlags().StringToStringVar(&common.FlagNodeLabels, "node-labels", nil,
+		"the labels of the node, \"tier=cold\" places a data node in the cold tier")
 	cmd.PersistentFlags().StringVar(&logging.Env, "logging-env", "prod", "the logging")
 	cmd.PersistentFlags().StringVar(&logging.Level, "logging-level", "info", "the root level of logging")
 	cmd.PersistentFlags().StringArrayVar(&logging.Modules, "logging-modules", nil, "the specific module")
@@ -67,6 +70,7 @@ BanyanDB, as an observability database, aims to ingest, analyze and store Metric
 	cmd.AddCommand(newStandaloneCmd(runners...))
 	cmd.AddCommand(newDataCmd(runners...))
 	cmd.AddCommand(newLiaisonCmd(runners...))
+	cmd.AddCommand(backup.NewRestoreCommand())
 	return cmd
 }
 
@@ -24,14 +24,21 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/banyand/backup"
+	"github.com/apache/skywalking-banyandb/banyand/liaison/alert"
 	"github.com/apache/skywalking-banyandb/banyand/liaison/grpc"
 	"github.com/apache/skywalking-banyandb/banyand/liaison/http"
+	"github.com/apache/skywalking-banyandb/banyand/liaison/kafka"
+	"github.com/apache/skywalking-banyandb/banyand/liaison/otlp"
 	"github.com/apache/skywalking-banyandb/banyand/measure"
 	"github.com/apache/skywalking-banyandb/banyand/metadata"
 	"github.com/apache/skywalking-banyandb/banyand/observability"
 	"github.com/apache/skywalking-banyandb/banyand/query"
 	"github.com/apache/skywalking-banyandb/banyand/queue"
+	"github.com/apache/skywalking-banyandb/banyand/shipping"
+	"github.com/apache/skywalking-banyandb/banyand/snapshot"
 	"github.com/apache/skywalking-banyandb/banyand/stream"
+	"github.com/apache/skywalking-banyandb/banyand/watchdog"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 	"github.com/apache/skywalking-banyandb/pkg/run"
 	"github.com/apache/skywalking-banyandb/pkg/version"
@@ -57,8 +64,14 @@ func newStandaloneCmd(runners ...run.Unit) *cobra.Command {
 	if err != nil {
 		l.Fatal().Err(err).Msg("failed to initiate query processor")
 	}
-	grpcServer := grpc.NewServer(ctx, pipeline, pipeline, metaSvc, grpc.NewLocalNodeRegistry())
+	shippingSvc := shipping.NewService(metaSvc, pipeline)
+	streamSvc.SetRecorder(shippingSvc)
+	measureSvc.SetRecorder(shippingSvc)
+	snapshotSvc := snapshot.NewService(pipeline, streamSvc, measureSvc)
+	backupSvc := backup.NewService(snapshotSvc)
+	grpcServer := grpc.NewServer(ctx, shippingSvc.WritePipeline(pipeline), pipeline, metaSvc, grpc.NewLocalNodeRegistry())
 	profSvc := observability.NewProfService()
+	watchdogSvc := watchdog.NewService()
 	metricSvc := observability.NewMetricService()
 	httpServer := http.NewServer()
 
@@ -70,9 +83,16 @@ func newStandaloneCmd(runners ...run.Unit) *cobra.Command {
 		measureSvc,
 		streamSvc,
 		q,
+		shippingSvc,
+		snapshotSvc,
+		backupSvc,
 		grpcServer,
 		httpServer,
+		otlp.NewServer(),
+		kafka.NewBridge(),
+		alert.NewEvaluator(metaSvc),
 		profSvc,
+		watchdogSvc,
 	)
 	if metricSvc != nil {
 		units = append(units, metricSvc)
@@ -20,8 +20,11 @@ package encoding
 import (
 	"fmt"
 
+	"github.com/golang/snappy"
+
 	"github.com/apache/skywalking-banyandb/pkg/bytes"
 	"github.com/apache/skywalking-banyandb/pkg/compress/zstd"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
 )
 
 // EncodeBytes encodes a string into dst.
@@ -44,24 +47,44 @@ func DecodeBytes(src []byte) ([]byte, []byte, error) {
 	return src[n:], src[:n], nil
 }
 
+// Codec is the codec compressing the data of a bytes block.
+type Codec byte
+
+const (
+	// CodecZSTD compresses the data with zstd, which is the default.
+	CodecZSTD Codec = iota
+	// CodecSnappy compresses the data with snappy.
+	CodecSnappy
+	// CodecNone stores the data uncompressed.
+	CodecNone
+)
+
 // EncodeBytesBlock encodes a block of strings into dst.
 func EncodeBytesBlock(dst []byte, a [][]byte) []byte {
+	return EncodeBytesBlockWithCodec(dst, a, CodecZSTD)
+}
+
+// EncodeBytesBlockWithCodec encodes a block of strings into dst, whose data is compressed by the codec.
+// The codec is recorded in the block, which BytesBlockDecoder decodes whatever the codec is.
+func EncodeBytesBlockWithCodec(dst []byte, a [][]byte, codec Codec) []byte {
 	u64s := GenerateUint64List(len(a))
 	aLens := u64s.L[:0]
+	var size int
 	for _, s := range a {
 		aLens = append(aLens, uint64(len(s)))
+		size += len(s)
 	}
 	u64s.L = aLens
 	dst = encodeUint64Block(dst, u64s.L)
 	ReleaseUint64List(u64s)
 
-	bb := bbPool.Generate()
+	bb := bbPool.Acquire(size)
 	b := bb.Buf
 	for _, s := range a {
 		b = append(b, s...)
 	}
 	bb.Buf = b
-	dst = compressBlock(dst, bb.Buf)
+	dst = compressBlockWithCodec(dst, bb.Buf, codec)
 	bbPool.Release(bb)
 
 	return dst
@@ -78,6 +101,11 @@ func (bbd *BytesBlockDecoder) Reset() {
 	bbd.data = bbd.data[:0]
 }
 
+// Cap returns the capacity of the buffer holding the decoded bytes.
+func (bbd *BytesBlockDecoder) Cap() int {
+	return cap(bbd.data)
+}
+
 // Decode decodes a block of strings from src.
 func (bbd *BytesBlockDecoder) Decode(dst [][]byte, src []byte, itemsCount uint64) ([][]byte, error) {
 	u64List := GenerateUint64List(0)
@@ -118,7 +146,7 @@ func (bbd *BytesBlockDecoder) Decode(dst [][]byte, src []byte, itemsCount uint64
 }
 
 func encodeUint64Block(dst []byte, a []uint64) []byte {
-	bb := bbPool.Generate()
+	bb := bbPool.Acquire(len(a) * 8)
 	bb.Buf = encodeUint64List(bb.Buf[:0], a)
 	dst = compressBlock(dst, bb.Buf)
 	bbPool.Release(bb)
@@ -126,7 +154,7 @@ func encodeUint64Block(dst []byte, a []uint64) []byte {
 }
 
 func decodeUint64Block(dst []uint64, src []byte, itemsCount uint64) ([]uint64, []byte, error) {
-	bb := bbPool.Generate()
+	bb := bbPool.Acquire(int(itemsCount) * 8)
 	defer bbPool.Release(bb)
 
 	var err error
@@ -230,23 +258,40 @@ func decodeUint64List(dst []uint64, src []byte, itemsCount uint64) ([]uint64, er
 }
 
 const (
-	compressTypePlain = 0
-	compressTypeZSTD  = 1
+	compressTypePlain  = 0
+	compressTypeZSTD   = 1
+	compressTypeSnappy = 2
+	// compressTypeRaw is an uncompressed block too big to be a plain one.
+	compressTypeRaw = 3
 )
 
 func compressBlock(dst, src []byte) []byte {
+	return compressBlockWithCodec(dst, src, CodecZSTD)
+}
+
+func compressBlockWithCodec(dst, src []byte, codec Codec) []byte {
 	if len(src) < 128 {
 		dst = append(dst, compressTypePlain, byte(len(src)))
 		return append(dst, src...)
 	}
 
-	dst = append(dst, compressTypeZSTD)
-	bb := bbPool.Generate()
-	bb.Buf = zstd.Compress(bb.Buf[:0], src, 1)
+	bb := bbPool.Acquire(len(src))
+	defer bbPool.Release(bb)
+	switch codec {
+	case CodecZSTD:
+		dst = append(dst, compressTypeZSTD)
+		bb.Buf = zstd.Compress(bb.Buf[:0], src, 1)
+	case CodecSnappy:
+		dst = append(dst, compressTypeSnappy)
+		bb.Buf = snappy.Encode(bb.Buf[:cap(bb.Buf)], src)
+	case CodecNone:
+		dst = append(dst, compressTypeRaw)
+		bb.Buf = append(bb.Buf[:0], src...)
+	default:
+		logger.Panicf("unknown codec: %d", codec)
+	}
 	dst = VarUint64ToBytes(dst, uint64(len(bb.Buf)))
-	dst = append(dst, bb.Buf...)
-	bbPool.Release(bb)
-	return dst
+	return append(dst, bb.Buf...)
 }
 
 func decompressBlock(dst, src []byte) ([]byte, []byte, error) {
@@ -269,7 +314,7 @@ func decompressBlock(dst, src []byte) ([]byte, []byte, error) {
 		dst = append(dst, src[:blockLen]...)
 		src = src[blockLen:]
 		return dst, src, nil
-	case compressTypeZSTD:
+	case compressTypeZSTD, compressTypeSnappy, compressTypeRaw:
 		tail, blockLen, err := BytesToVarUint64(src)
 		if err != nil {
 			return dst, src, fmt.Errorf("cannot decode compressed block size: %w", err)
@@ -281,20 +326,29 @@ func decompressBlock(dst, src []byte) ([]byte, []byte, error) {
 		compressedBlock := src[:blockLen]
 		src = src[blockLen:]
 
+		if blockType == compressTypeRaw {
+			dst = append(dst, compressedBlock...)
+			return dst, src, nil
+		}
+
 		// Decompress the block
-		bb := bbPool.Generate()
-		bb.Buf, err = zstd.Decompress(bb.Buf[:0], compressedBlock)
+		bb := bbPool.Acquire(0)
+		defer bbPool.Release(bb)
+		if blockType == compressTypeSnappy {
+			bb.Buf, err = snappy.Decode(bb.Buf[:cap(bb.Buf)], compressedBlock)
+		} else {
+			bb.Buf, err = zstd.Decompress(bb.Buf[:0], compressedBlock)
+		}
 		if err != nil {
 			return dst, src, fmt.Errorf("cannot decompress block: %w", err)
 		}
 
 		// Copy the decompressed block to dst.
 		dst = append(dst, bb.Buf...)
-		bbPool.Release(bb)
 		return dst, src, nil
 	default:
-		return dst, src, fmt.Errorf("unexpected block type: %d; supported types: 0, 1", blockType)
+		return dst, src, fmt.Errorf("unexpected block type: %d; supported types: 0, 1, 2, 3", blockType)
 	}
 }
 
-var bbPool bytes.BufferPool
+var bbPool = bytes.NewBufferManager(nil, nil)
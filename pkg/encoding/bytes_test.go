@@ -18,6 +18,8 @@
 package encoding_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -60,3 +62,19 @@ func TestEncodeBlockAndDecode(t *testing.T) {
 		assert.Equal(t, slice, decoded[i])
 	}
 }
+
+func TestEncodeBlockWithCodecAndDecode(t *testing.T) {
+	var slices [][]byte
+	for i := 0; i < 100; i++ {
+		slices = append(slices, []byte(fmt.Sprintf("service-%d", i%3)), nil, []byte(strings.Repeat("x", i+1)))
+	}
+	for _, codec := range []encoding.Codec{encoding.CodecZSTD, encoding.CodecSnappy, encoding.CodecNone} {
+		t.Run(fmt.Sprintf("codec %d", codec), func(t *testing.T) {
+			encoded := encoding.EncodeBytesBlockWithCodec(nil, slices, codec)
+			blockDecoder := &encoding.BytesBlockDecoder{}
+			decoded, err := blockDecoder.Decode(nil, encoded, uint64(len(slices)))
+			require.NoError(t, err)
+			assert.Equal(t, slices, decoded)
+		})
+	}
+}
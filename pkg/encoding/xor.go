@@ -18,6 +18,9 @@
 package encoding
 
 import (
+	"bytes"
+	"fmt"
+	"math"
 	"math/bits"
 )
 
@@ -42,6 +45,8 @@ func NewXOREncoder(bw *Writer) *XOREncoder {
 	return &XOREncoder{
 		bw:    bw,
 		first: true,
+		// no meaningful bits block to reuse until the first one is written
+		leading: 64,
 	}
 }
 
@@ -170,3 +175,48 @@ func (d *XORDecoder) Value() uint64 {
 func (d *XORDecoder) Err() error {
 	return d.err
 }
+
+// Float64ListToBytes encodes a list of float64 into dst by the XOR encoding,
+// which shrinks the adjacent values sharing the sign, the exponent and the high bits of the mantissa.
+func Float64ListToBytes(dst []byte, a []float64) []byte {
+	w := &sliceWriter{buf: dst}
+	bw := NewWriter()
+	bw.Reset(w)
+	e := NewXOREncoder(bw)
+	for _, v := range a {
+		e.Write(math.Float64bits(v))
+	}
+	bw.Flush()
+	return w.buf
+}
+
+// BytesToFloat64List decodes itemsCount float64 encoded by Float64ListToBytes from src into dst.
+func BytesToFloat64List(dst []float64, src []byte, itemsCount int) ([]float64, error) {
+	d := NewXORDecoder(NewReader(bytes.NewReader(src)))
+	for i := 0; i < itemsCount; i++ {
+		if !d.Next() {
+			return nil, fmt.Errorf("cannot decode the float64 %d of %d: %w", i, itemsCount, d.Err())
+		}
+		dst = append(dst, math.Float64frombits(d.Value()))
+	}
+	return dst, nil
+}
+
+// sliceWriter appends the bytes written to a slice.
+type sliceWriter struct {
+	buf []byte
+}
+
+func (w *sliceWriter) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+	return len(data), nil
+}
+
+func (w *sliceWriter) WriteByte(b byte) error {
+	w.buf = append(w.buf, b)
+	return nil
+}
+
+func (w *sliceWriter) Bytes() []byte {
+	return w.buf
+}
@@ -20,6 +20,7 @@ package encoding
 import (
 	"bytes"
 	"io"
+	"math"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -57,3 +58,28 @@ func verify(d *XORDecoder, a *assert.Assertions, except uint64) {
 	}
 	a.Equal(except, d.Value())
 }
+
+func TestFloat64List(t *testing.T) {
+	a := assert.New(t)
+	for _, values := range [][]float64{
+		{1},
+		{0.5, 0.5, 0.5, 0.5},
+		{12.5, 12.75, 13, 12.25, 99.125, -3},
+		{math.NaN(), math.Inf(1), math.Inf(-1), 0, math.SmallestNonzeroFloat64},
+	} {
+		data := Float64ListToBytes([]byte("prefix"), values)
+		a.Equal("prefix", string(data[:6]), "the values are appended")
+		got, err := BytesToFloat64List(nil, data[6:], len(values))
+		a.NoError(err)
+		a.Equal(len(values), len(got))
+		for i := range values {
+			a.Equal(math.Float64bits(values[i]), math.Float64bits(got[i]))
+		}
+	}
+	data := Float64ListToBytes(nil, []float64{0.5, 0.5, 0.5, 0.5})
+	a.Less(len(data), 4*8, "the repeated values take a bit each")
+	data = Float64ListToBytes(nil, []float64{10, 10.5, 11, 11.5, 12, 12.5, 13, 13.5})
+	a.Less(len(data), 8*8/2, "the close values share the meaningful bits")
+	_, err := BytesToFloat64List(nil, data, 100)
+	a.Error(err)
+}
@@ -0,0 +1,105 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package filter implements the filters telling whether a set may contain an item.
+package filter
+
+import (
+	"fmt"
+
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+)
+
+const (
+	// bitsPerItem and hashCount keep the false positive rate of a filter about 1%.
+	bitsPerItem = 10
+	hashCount   = 7
+)
+
+// BloomFilter is a bloom filter of byte strings. It never reports an item added is absent,
+// and reports an item never added is present at a rate about 1%.
+type BloomFilter struct {
+	bits []uint64
+	k    int
+}
+
+// NewBloomFilter returns a bloom filter sized for n items.
+func NewBloomFilter(n int) *BloomFilter {
+	words := (max(n, 1)*bitsPerItem + 63) / 64
+	return &BloomFilter{bits: make([]uint64, words), k: hashCount}
+}
+
+// Add adds the item to the filter.
+func (bf *BloomFilter) Add(item []byte) {
+	h1, h2, m := bf.hash(item)
+	for i := 0; i < bf.k; i++ {
+		pos := (h1 + uint64(i)*h2) % m
+		bf.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+// MightContain reports whether the item might be added to the filter.
+// It's false only if the item is never added.
+func (bf *BloomFilter) MightContain(item []byte) bool {
+	h1, h2, m := bf.hash(item)
+	for i := 0; i < bf.k; i++ {
+		pos := (h1 + uint64(i)*h2) % m
+		if bf.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hash derives the hashes of the item by double hashing.
+func (bf *BloomFilter) hash(item []byte) (h1, h2, m uint64) {
+	h := convert.Hash(item)
+	// h2 is odd, so that the positions of an item never collapse into one
+	return h & 0xffffffff, h>>32 | 1, uint64(len(bf.bits)) * 64
+}
+
+// Marshal appends the filter to dst.
+func (bf *BloomFilter) Marshal(dst []byte) []byte {
+	dst = append(dst, byte(bf.k))
+	dst = encoding.VarUint64ToBytes(dst, uint64(len(bf.bits)))
+	for _, w := range bf.bits {
+		dst = encoding.Uint64ToBytes(dst, w)
+	}
+	return dst
+}
+
+// Unmarshal decodes the filter from src, and returns the bytes left.
+func (bf *BloomFilter) Unmarshal(src []byte) ([]byte, error) {
+	if len(src) < 1 {
+		return nil, fmt.Errorf("cannot unmarshal the hash count of a bloom filter from empty src")
+	}
+	bf.k = int(src[0])
+	src, n, err := encoding.BytesToVarUint64(src[1:])
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal the size of a bloom filter: %w", err)
+	}
+	if n == 0 || uint64(len(src)) < n*8 {
+		return nil, fmt.Errorf("cannot unmarshal a bloom filter of %d words from %d bytes", n, len(src))
+	}
+	bf.bits = make([]uint64, n)
+	for i := range bf.bits {
+		bf.bits[i] = encoding.BytesToUint64(src)
+		src = src[8:]
+	}
+	return src, nil
+}
@@ -0,0 +1,58 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBloomFilter(t *testing.T) {
+	const n = 1000
+	bf := NewBloomFilter(n)
+	for i := 0; i < n; i++ {
+		bf.Add([]byte(fmt.Sprintf("trace-%d", i)))
+	}
+	bf.Add(nil)
+
+	data := bf.Marshal([]byte("prefix"))
+	var decoded BloomFilter
+	tail, err := decoded.Unmarshal(data[len("prefix"):])
+	require.NoError(t, err)
+	assert.Empty(t, tail)
+
+	for _, f := range []*BloomFilter{bf, &decoded} {
+		for i := 0; i < n; i++ {
+			assert.True(t, f.MightContain([]byte(fmt.Sprintf("trace-%d", i))))
+		}
+		assert.True(t, f.MightContain([]byte{}), "an empty item is a nil one")
+		var falsePositives int
+		for i := n; i < 2*n; i++ {
+			if f.MightContain([]byte(fmt.Sprintf("trace-%d", i))) {
+				falsePositives++
+			}
+		}
+		assert.Less(t, falsePositives, n/20)
+	}
+
+	_, err = decoded.Unmarshal(data[len("prefix") : len(data)-1])
+	assert.Error(t, err)
+}
@@ -79,6 +79,7 @@ type tumblingTimeWindows struct {
 	out                chan flow.StreamRecord
 	flow.ComponentState
 	windowSize       int64
+	windowSlide      int64
 	windowCount      int
 	currentWatermark int64
 	timerMu          sync.Mutex
@@ -97,6 +98,10 @@ func (s *tumblingTimeWindows) Setup(_ context.Context) (err error) {
 		if s.windowCount <= 0 {
 			s.windowCount = defaultCacheSize
 		}
+		// every element is assigned to size/slide windows, which should be kept in the cache together
+		if minCount := int(s.windowSize/s.windowSlide) + 1; s.windowCount < minCount {
+			s.windowCount = minCount
+		}
 		s.snapshots, err = lru.NewWithEvict(s.windowCount, func(key interface{}, value interface{}) {
 			s.flushSnapshot(key.(timeWindow), value.(flow.AggregationOp))
 		})
@@ -191,14 +196,14 @@ func (s *tumblingTimeWindows) receive() {
 			s.flushDueWindows()
 
 			// flush dirty windows if the necessary
-			// use 40% of the data point interval as the flush interval,
+			// use 40% of the window slide as the flush interval,
 			// which means roughly the record located in the same time bucket will be persistent twice.
 			// |---------------------------------|
 			// |    40%     |    40%     |  20%  |
 			// |          flush        flush     |
 			// |---------------------------------|
 			// TODO: how to determine the threshold
-			if previousWaterMark > 0 && float64(pastDur) > float64(s.windowSize)*0.4 {
+			if previousWaterMark > 0 && float64(pastDur) > float64(s.windowSlide)*0.4 {
 				s.flushDirtyWindows()
 			}
 		}
@@ -227,8 +232,18 @@ func (s *tumblingTimeWindows) Exec(downstream flow.Inlet) {
 
 // NewTumblingTimeWindows return tumbling-time windows.
 func NewTumblingTimeWindows(size time.Duration) flow.WindowAssigner {
+	return NewSlidingTimeWindows(size, size)
+}
+
+// NewSlidingTimeWindows return sliding-time windows of the given size, which start every slide.
+// The windows overlap if the slide is less than the size, and they are tumbling if both are equal.
+func NewSlidingTimeWindows(size, slide time.Duration) flow.WindowAssigner {
+	if slide <= 0 || slide > size {
+		slide = size
+	}
 	return &tumblingTimeWindows{
-		windowSize: size.Milliseconds(),
+		windowSize:  size.Milliseconds(),
+		windowSlide: slide.Milliseconds(),
 		timerHeap: flow.NewPriorityQueue(func(a, b interface{}) int {
 			return int(a.(*internalTimer).triggerTimeMillis - b.(*internalTimer).triggerTimeMillis)
 		}, false),
@@ -248,15 +263,17 @@ func (t timeWindow) MaxTimestamp() int64 {
 }
 
 // AssignWindows assigns windows according to the given timestamp.
+// The timestamp belongs to every window starting in (timestamp-size, timestamp].
 func (s *tumblingTimeWindows) AssignWindows(timestamp int64) ([]flow.Window, error) {
 	if timestamp > math.MinInt64 {
-		start := getWindowStart(timestamp, s.windowSize)
-		return []flow.Window{
-			timeWindow{
+		windows := make([]flow.Window, 0, s.windowSize/s.windowSlide)
+		for start := getWindowStart(timestamp, s.windowSlide); start > timestamp-s.windowSize; start -= s.windowSlide {
+			windows = append(windows, timeWindow{
 				start: start,
 				end:   start + s.windowSize,
-			},
-		}, nil
+			})
+		}
+		return windows, nil
 	}
 	return nil, errors.New("invalid timestamp from the element")
 }
@@ -135,3 +135,30 @@ var _ = g.Describe("Sliding Window", func() {
 		})
 	})
 })
+
+var _ = g.Describe("Overlapped Sliding Window", func() {
+	g.It("Should assign an element to every window covering it", func() {
+		slidingWindows := NewSlidingTimeWindows(time.Minute*15, time.Minute*5).(*tumblingTimeWindows)
+		ts := time.Date(2024, 1, 1, 10, 12, 0, 0, time.UTC)
+		windows, err := slidingWindows.AssignWindows(ts.UnixMilli())
+		gomega.Expect(err).ShouldNot(gomega.HaveOccurred())
+		gomega.Expect(windows).Should(gomega.HaveLen(3))
+		for i, start := range []time.Time{
+			ts.Add(-time.Minute * 2),
+			ts.Add(-time.Minute * 7),
+			ts.Add(-time.Minute * 12),
+		} {
+			gomega.Expect(windows[i]).Should(gomega.Equal(timeWindow{
+				start: start.UnixMilli(),
+				end:   start.Add(time.Minute * 15).UnixMilli(),
+			}))
+		}
+	})
+
+	g.It("Should fall back to the tumbling windows if the slide exceeds the size", func() {
+		slidingWindows := NewSlidingTimeWindows(time.Minute, time.Hour).(*tumblingTimeWindows)
+		windows, err := slidingWindows.AssignWindows(time.Date(2024, 1, 1, 10, 12, 30, 0, time.UTC).UnixMilli())
+		gomega.Expect(err).ShouldNot(gomega.HaveOccurred())
+		gomega.Expect(windows).Should(gomega.HaveLen(1))
+	})
+})
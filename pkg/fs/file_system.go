@@ -19,6 +19,7 @@
 package fs
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/apache/skywalking-banyandb/pkg/logger"
@@ -55,6 +56,33 @@ type SeqReader interface {
 	Close() error
 }
 
+// Discarder is implemented by the SeqReaders which can skip the data without reading it.
+type Discarder interface {
+	// Discard skips the next n bytes, and returns the number of the bytes skipped.
+	Discard(n int64) (int64, error)
+}
+
+// IOOptions hints how the sequential readers and writers use the page cache.
+// The merges and the flushes go through the large files once, whose pages would evict the ones the queries depend on.
+type IOOptions struct {
+	// Direct reads and writes the file bypassing the page cache.
+	Direct bool
+	// DropCache advises the kernel to drop the pages of the file once they are read or written.
+	DropCache bool
+}
+
+// HintedReader is implemented by the Readers which can read sequentially with the IOOptions.
+type HintedReader interface {
+	// SequentialReadWithOptions reads the entire file using sequential read with the IOOptions.
+	SequentialReadWithOptions(opts IOOptions) SeqReader
+}
+
+// HintedWriter is implemented by the Writers which can write sequentially with the IOOptions.
+type HintedWriter interface {
+	// SequentialWriteWithOptions appends consecutive buffers to the end of the file with the IOOptions.
+	SequentialWriteWithOptions(opts IOOptions) SeqWriter
+}
+
 // Reader allows reading data from a file.
 type Reader interface {
 	// Read the entire file at a specified offset.
@@ -67,6 +95,20 @@ type Reader interface {
 	Close() error
 }
 
+// ReadRequest reads len(Buf) bytes at Offset of Reader.
+type ReadRequest struct {
+	Reader Reader
+	Buf    []byte
+	Offset int64
+}
+
+// BatchReader is implemented by the Readers which can submit the reads of several files in a batch.
+type BatchReader interface {
+	// ReadBatch reads all the requests, whose Readers might be of other files.
+	// It returns an error if a request reads fewer bytes than its buffer.
+	ReadBatch(reqs []ReadRequest) error
+}
+
 // Closer allows closing a file.
 type Closer interface {
 	// Returns the absolute path of the file.
@@ -119,6 +161,12 @@ type FileSystem interface {
 	SyncPath(path string)
 	// MustGetFreeSpace returns the free space of the file system.
 	MustGetFreeSpace(path string) uint64
+	// Rename moves the file or directory from oldPath to newPath.
+	Rename(oldPath, newPath string) error
+	// CreateHardLink links the files under the src directory into the dest directory recursively,
+	// the ones which can't be linked, such as those on another device, are copied.
+	// Only the files the filter accepts are linked if it isn't nil. It returns the total size of the files.
+	CreateHardLink(src, dest string, filter func(path string) bool) (uint64, error)
 }
 
 // DirEntry is the interface that wraps the basic information about a file or directory.
@@ -164,6 +212,35 @@ func MustWriteData(w SeqWriter, data []byte) {
 	}
 }
 
+// SequentialRead reads r sequentially with the IOOptions, which are ignored if r isn't a HintedReader.
+func SequentialRead(r Reader, opts IOOptions) SeqReader {
+	if hr, ok := r.(HintedReader); ok && opts != (IOOptions{}) {
+		return hr.SequentialReadWithOptions(opts)
+	}
+	return r.SequentialRead()
+}
+
+// SequentialWrite writes w sequentially with the IOOptions, which are ignored if w isn't a HintedWriter.
+func SequentialWrite(w Writer, opts IOOptions) SeqWriter {
+	if hw, ok := w.(HintedWriter); ok && opts != (IOOptions{}) {
+		return hw.SequentialWriteWithOptions(opts)
+	}
+	return w.SequentialWrite()
+}
+
+// MustFlushWithOptions is MustFlush writing the file with the IOOptions.
+func MustFlushWithOptions(fs FileSystem, buffer []byte, name string, permission Mode, opts IOOptions) {
+	if opts == (IOOptions{}) {
+		MustFlush(fs, buffer, name, permission)
+		return
+	}
+	f := MustCreateFile(fs, name, permission)
+	w := SequentialWrite(f, opts)
+	MustWriteData(w, buffer)
+	MustClose(w)
+	MustClose(f)
+}
+
 // MustReadData reads data from r and panics if it cannot read all data.
 func MustReadData(r Reader, offset int64, buff []byte) {
 	n, err := r.Read(offset, buff)
@@ -175,6 +252,19 @@ func MustReadData(r Reader, offset int64, buff []byte) {
 	}
 }
 
+// ReadData reads len(buff) bytes at offset from r.
+// Unlike MustReadData, it returns an error rather than panicking, since the data might be corrupted or truncated.
+func ReadData(r Reader, offset int64, buff []byte) error {
+	n, err := r.Read(offset, buff)
+	if err != nil {
+		return fmt.Errorf("%s: cannot read %d bytes at offset %d: %w", r.Path(), len(buff), offset, err)
+	}
+	if n != len(buff) {
+		return fmt.Errorf("%s: read %d bytes at offset %d, expected %d bytes", r.Path(), n, offset, len(buff))
+	}
+	return nil
+}
+
 // MustClose closes c and panics if it cannot close.
 func MustClose(c Closer) {
 	err := c.Close()
@@ -182,3 +272,31 @@ func MustClose(c Closer) {
 		logger.GetLogger().Panic().Err(err).Str("path", c.Path()).Msg("cannot close file")
 	}
 }
+
+// ReadDataBatch reads all the requests in a batch if the Reader of the first one is a BatchReader,
+// otherwise they are read one by one by ReadData.
+func ReadDataBatch(reqs []ReadRequest) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+	if br, ok := reqs[0].Reader.(BatchReader); ok {
+		return br.ReadBatch(reqs)
+	}
+	return readDataOneByOne(reqs)
+}
+
+// MustReadDataBatch reads all the requests like ReadDataBatch and panics if it cannot read all data.
+func MustReadDataBatch(reqs []ReadRequest) {
+	if err := ReadDataBatch(reqs); err != nil {
+		logger.GetLogger().Panic().Err(err).Int("requests", len(reqs)).Msg("cannot read data")
+	}
+}
+
+func readDataOneByOne(reqs []ReadRequest) error {
+	for i := range reqs {
+		if err := ReadData(reqs[i].Reader, reqs[i].Offset, reqs[i].Buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+// +build linux
+
+package fs
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"go.uber.org/multierr"
+	"golang.org/x/sys/unix"
+
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+const (
+	ioringOpRead         = 22
+	ioringEnterGetEvents = 1 << 0
+	ioringFeatSingleMmap = 1 << 0
+	ioringOffSQRing      = 0
+	ioringOffCQRing      = 0x8000000
+	ioringOffSQEs        = 0x10000000
+)
+
+// ioURingParams is struct io_uring_params.
+type ioURingParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFD         uint32
+	resv         [3]uint32
+	sqOff        ioSQRingOffsets
+	cqOff        ioCQRingOffsets
+}
+
+type ioSQRingOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	flags       uint32
+	dropped     uint32
+	array       uint32
+	resv1       uint32
+	userAddr    uint64
+}
+
+type ioCQRingOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	overflow    uint32
+	cqes        uint32
+	flags       uint32
+	resv1       uint32
+	userAddr    uint64
+}
+
+// ioURingSQE is struct io_uring_sqe.
+type ioURingSQE struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFDIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+// ioURingCQE is struct io_uring_cqe.
+type ioURingCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// IOURing submits the reads of the files in batches by io_uring.
+// It holds a few rings, each of which serves a batch at a time.
+type IOURing struct {
+	rings chan *ioURing
+	all   []*ioURing
+}
+
+// NewIOURing sets up the rings with the entries, which bound the reads submitted in a syscall.
+// It fails if the kernel doesn't support io_uring or forbids it.
+func NewIOURing(rings int, entries uint32) (*IOURing, error) {
+	u := &IOURing{rings: make(chan *ioURing, rings)}
+	for i := 0; i < rings; i++ {
+		r, err := newIOURing(entries)
+		if err != nil {
+			_ = u.Close()
+			return nil, err
+		}
+		u.all = append(u.all, r)
+		u.rings <- r
+	}
+	return u, nil
+}
+
+// Close releases the rings, the reads after it are done one by one.
+func (u *IOURing) Close() error {
+	var err error
+	for range u.all {
+		if r := <-u.rings; r != nil {
+			err = multierr.Append(err, r.close())
+		}
+	}
+	u.all = nil
+	close(u.rings)
+	return err
+}
+
+func (u *IOURing) readBatch(reqs []ReadRequest) error {
+	r, ok := <-u.rings
+	if !ok {
+		return readDataOneByOne(reqs)
+	}
+	defer func() { u.rings <- r }()
+	return r.readBatch(reqs)
+}
+
+type ioURing struct {
+	// enter is io_uring_enter, which is replaced by the tests to fail it.
+	enter   func(toSubmit, minComplete, flags uint32) unix.Errno
+	sqRing  []byte
+	cqRing  []byte
+	sqeMem  []byte
+	sqes    []ioURingSQE
+	cqes    []ioURingCQE
+	sqHead  *uint32
+	sqTail  *uint32
+	sqMask  uint32
+	cqHead  *uint32
+	cqTail  *uint32
+	cqMask  uint32
+	entries uint32
+	fd      int
+	// singleMmap is set if the completion queue shares the mapping of the submission queue.
+	singleMmap bool
+	// broken is set once io_uring_enter fails unexpectedly, the reads after it are done one by one.
+	broken bool
+}
+
+func newIOURing(entries uint32) (*ioURing, error) {
+	var p ioURingParams
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("cannot set up io_uring: %w", errno)
+	}
+	r := &ioURing{fd: int(fd), entries: p.sqEntries}
+	r.enter = r.syscallEnter
+	if err := r.mmap(&p); err != nil {
+		_ = r.close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *ioURing) mmap(p *ioURingParams) error {
+	sqSize := int(p.sqOff.array + p.sqEntries*4)
+	cqSize := int(p.cqOff.cqes) + int(p.cqEntries)*int(unsafe.Sizeof(ioURingCQE{}))
+	r.singleMmap = p.features&ioringFeatSingleMmap != 0
+	if r.singleMmap {
+		sqSize = max(sqSize, cqSize)
+	}
+	var err error
+	if r.sqRing, err = unix.Mmap(r.fd, ioringOffSQRing, sqSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		return fmt.Errorf("cannot map the submission queue of io_uring: %w", err)
+	}
+	if r.singleMmap {
+		r.cqRing = r.sqRing
+	} else if r.cqRing, err = unix.Mmap(r.fd, ioringOffCQRing, cqSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		return fmt.Errorf("cannot map the completion queue of io_uring: %w", err)
+	}
+	sqeSize := int(p.sqEntries) * int(unsafe.Sizeof(ioURingSQE{}))
+	if r.sqeMem, err = unix.Mmap(r.fd, ioringOffSQEs, sqeSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		return fmt.Errorf("cannot map the submission entries of io_uring: %w", err)
+	}
+	r.sqHead = (*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.head]))
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.tail]))
+	r.sqMask = *(*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.ringMask]))
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.tail]))
+	r.cqMask = *(*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.ringMask]))
+	r.sqes = unsafe.Slice((*ioURingSQE)(unsafe.Pointer(&r.sqeMem[0])), p.sqEntries)
+	r.cqes = unsafe.Slice((*ioURingCQE)(unsafe.Pointer(&r.cqRing[p.cqOff.cqes])), p.cqEntries)
+	// the submission entries are used in the order of the slots, so that the array maps them one to one
+	array := unsafe.Slice((*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.array])), p.sqEntries)
+	for i := range array {
+		array[i] = uint32(i)
+	}
+	return nil
+}
+
+// readBatch submits the reads of the LocalFiles in batches of the entries, and waits for their completions.
+// The reads of the other Readers, the ones failing with the opcode unsupported by the kernel and the short ones are completed by ReadData.
+func (r *ioURing) readBatch(reqs []ReadRequest) error {
+	for len(reqs) > 0 {
+		if r.broken {
+			return readDataOneByOne(reqs)
+		}
+		batch := reqs[:min(len(reqs), int(r.entries))]
+		reqs = reqs[len(batch):]
+		if err := r.submitAndWait(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ioURing) submitAndWait(batch []ReadRequest) error {
+	defer runtime.KeepAlive(batch)
+	tail := *r.sqTail
+	var submitted uint32
+	var firstErr error
+	for i := range batch {
+		lf, ok := batch[i].Reader.(*LocalFile)
+		if !ok || len(batch[i].Buf) == 0 {
+			if err := ReadData(batch[i].Reader, batch[i].Offset, batch[i].Buf); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		sqe := &r.sqes[tail&r.sqMask]
+		*sqe = ioURingSQE{
+			opcode:   ioringOpRead,
+			fd:       int32(lf.file.Fd()),
+			off:      uint64(batch[i].Offset),
+			addr:     uint64(uintptr(unsafe.Pointer(&batch[i].Buf[0]))),
+			len:      uint32(len(batch[i].Buf)),
+			userData: uint64(i),
+		}
+		tail++
+		submitted++
+	}
+	atomic.StoreUint32(r.sqTail, tail)
+	for completed := uint32(0); completed < submitted; {
+		// the entries the kernel hasn't consumed are submitted again along with waiting for a completion
+		toSubmit := tail - atomic.LoadUint32(r.sqHead)
+		if errno := r.enter(toSubmit, 1, ioringEnterGetEvents); !isTransient(errno) {
+			r.abort(batch, tail, submitted-completed, &firstErr, errno)
+			return firstErr
+		}
+		completed += r.reap(batch, &firstErr)
+	}
+	return firstErr
+}
+
+// abort takes back the entries the kernel hasn't consumed, and waits for the reads in flight,
+// which still write the buffers, before the taken back ones are read by ReadData.
+// The ring is broken since then, the reads after it are done one by one.
+func (r *ioURing) abort(batch []ReadRequest, tail, pending uint32, firstErr *error, errno unix.Errno) {
+	r.broken = true
+	logger.GetLogger(moduleName).Warn().Err(errno).Msg("cannot enter io_uring, fall back to reading the data one by one")
+	head := atomic.LoadUint32(r.sqHead)
+	taken := make([]uint64, 0, tail-head)
+	for i := head; i != tail; i++ {
+		taken = append(taken, r.sqes[i&r.sqMask].userData)
+	}
+	atomic.StoreUint32(r.sqTail, head)
+	for inFlight := pending - uint32(len(taken)); inFlight > 0; {
+		if n := r.reap(batch, firstErr); n > 0 {
+			inFlight -= n
+			continue
+		}
+		// the kernel completes the reads in flight anyway, they're polled if the ring can't be waited on
+		if !isTransient(r.enter(0, 1, ioringEnterGetEvents)) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for _, i := range taken {
+		if err := ReadData(batch[i].Reader, batch[i].Offset, batch[i].Buf); err != nil && *firstErr == nil {
+			*firstErr = err
+		}
+	}
+}
+
+// reap completes the reads of the completion queue, and returns how many they are.
+func (r *ioURing) reap(batch []ReadRequest, firstErr *error) uint32 {
+	var n uint32
+	head := *r.cqHead
+	for cqTail := atomic.LoadUint32(r.cqTail); head != cqTail; head++ {
+		cqe := r.cqes[head&r.cqMask]
+		n++
+		if err := r.complete(&batch[cqe.userData], cqe.res); err != nil && *firstErr == nil {
+			*firstErr = err
+		}
+	}
+	atomic.StoreUint32(r.cqHead, head)
+	return n
+}
+
+func (r *ioURing) syscallEnter(toSubmit, minComplete, flags uint32) unix.Errno {
+	_, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd), uintptr(toSubmit), uintptr(minComplete), uintptr(flags), 0, 0)
+	return errno
+}
+
+// isTransient tells whether io_uring_enter succeeds or fails temporarily, which is retried.
+func isTransient(errno unix.Errno) bool {
+	return errno == 0 || errno == unix.EINTR || errno == unix.EAGAIN || errno == unix.EBUSY
+}
+
+func (r *ioURing) complete(req *ReadRequest, res int32) error {
+	switch {
+	case res < 0 && (unix.Errno(-res) == unix.EINVAL || unix.Errno(-res) == unix.EOPNOTSUPP):
+		// the kernel before 5.6 doesn't support IORING_OP_READ
+		return ReadData(req.Reader, req.Offset, req.Buf)
+	case res < 0:
+		return fmt.Errorf("%s: cannot read %d bytes at offset %d: %w", req.Reader.Path(), len(req.Buf), req.Offset, unix.Errno(-res))
+	case res == 0:
+		return fmt.Errorf("%s: read 0 bytes at offset %d, expected %d bytes", req.Reader.Path(), req.Offset, len(req.Buf))
+	case int(res) < len(req.Buf):
+		return ReadData(req.Reader, req.Offset+int64(res), req.Buf[res:])
+	}
+	return nil
+}
+
+func (r *ioURing) close() error {
+	var err error
+	if r.sqeMem != nil {
+		err = multierr.Append(err, unix.Munmap(r.sqeMem))
+	}
+	if r.cqRing != nil && !r.singleMmap {
+		err = multierr.Append(err, unix.Munmap(r.cqRing))
+	}
+	if r.sqRing != nil {
+		err = multierr.Append(err, unix.Munmap(r.sqRing))
+	}
+	return multierr.Append(err, unix.Close(r.fd))
+}
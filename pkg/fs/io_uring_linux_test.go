@@ -0,0 +1,234 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+// +build linux
+
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"golang.org/x/sys/unix"
+
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+var _ = ginkgo.Describe("IO URing", func() {
+	const entries = 4
+
+	var (
+		content   []byte
+		path      string
+		ring      *IOURing
+		uringFile File
+		plainFile File
+	)
+
+	// requests reads the content at the offsets in the buffers of the sizes, mixing the files with and without the ring.
+	requests := func(n int) []ReadRequest {
+		reqs := make([]ReadRequest, 0, n)
+		for i := 0; i < n; i++ {
+			var r Reader = uringFile
+			if i%5 == 3 {
+				r = plainFile
+			}
+			reqs = append(reqs, ReadRequest{Reader: r, Offset: int64(i * 1013 % (len(content) - 4096)), Buf: make([]byte, i*37%4096)})
+		}
+		return reqs
+	}
+	expectRead := func(reqs []ReadRequest) {
+		for _, req := range reqs {
+			gomega.Expect(req.Buf).To(gomega.Equal(content[req.Offset : req.Offset+int64(len(req.Buf))]))
+		}
+	}
+	// takeRing runs f with the only ring, which isn't serving any batch.
+	takeRing := func(f func(r *ioURing)) {
+		r := <-ring.rings
+		defer func() { ring.rings <- r }()
+		f(r)
+	}
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		ring, err = NewIOURing(1, entries)
+		if err != nil {
+			ginkgo.Skip("io_uring is unavailable: " + err.Error())
+		}
+		dir, err := os.MkdirTemp("", "io-uring")
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		ginkgo.DeferCleanup(os.RemoveAll, dir)
+		content = make([]byte, 1<<16)
+		for i := range content {
+			content[i] = byte(i * 7)
+		}
+		path = filepath.Join(dir, "data")
+		gomega.Expect(os.WriteFile(path, content, 0o600)).To(gomega.Succeed())
+		uringFile, err = NewLocalFileSystemWithIOURing(logger.GetLogger("test"), ring).OpenFile(path)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		plainFile, err = NewLocalFileSystem().OpenFile(path)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+	})
+
+	ginkgo.AfterEach(func() {
+		if ring == nil {
+			return
+		}
+		gomega.Expect(uringFile.Close()).To(gomega.Succeed())
+		gomega.Expect(plainFile.Close()).To(gomega.Succeed())
+		gomega.Expect(ring.Close()).To(gomega.Succeed())
+		ring = nil
+	})
+
+	ginkgo.It("reads the batches outnumbering the entries", func() {
+		reqs := requests(10*entries + 3)
+		gomega.Expect(ReadDataBatch(reqs)).To(gomega.Succeed())
+		expectRead(reqs)
+	})
+
+	ginkgo.It("reads the requests one by one if the first one isn't read by the ring", func() {
+		reqs := requests(2 * entries)
+		reqs[0].Reader = plainFile
+		gomega.Expect(ReadDataBatch(reqs)).To(gomega.Succeed())
+		expectRead(reqs)
+	})
+
+	ginkgo.It("fails the reads beyond the end of the file", func() {
+		reqs := requests(entries)
+		reqs = append(reqs, ReadRequest{Reader: uringFile, Offset: int64(len(content)), Buf: make([]byte, 10)})
+		err := ReadDataBatch(reqs)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("read 0 bytes at offset %d", len(content))))
+		expectRead(reqs[:entries])
+
+		err = ReadDataBatch([]ReadRequest{{Reader: uringFile, Offset: int64(len(content) - 10), Buf: make([]byte, 100)}})
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(path)))
+	})
+
+	ginkgo.It("reads the data one by one after io_uring_enter fails before consuming the entries", func() {
+		var calls int
+		takeRing(func(r *ioURing) {
+			r.enter = func(_, _, _ uint32) unix.Errno {
+				calls++
+				return unix.EBADF
+			}
+		})
+		reqs := requests(3 * entries)
+		gomega.Expect(ReadDataBatch(reqs)).To(gomega.Succeed())
+		expectRead(reqs)
+		gomega.Expect(calls).To(gomega.Equal(1))
+		takeRing(func(r *ioURing) {
+			gomega.Expect(r.broken).To(gomega.BeTrue())
+			gomega.Expect(*r.sqTail).To(gomega.Equal(*r.sqHead))
+		})
+
+		reqs = requests(3 * entries)
+		gomega.Expect(ReadDataBatch(reqs)).To(gomega.Succeed())
+		expectRead(reqs)
+		gomega.Expect(calls).To(gomega.Equal(1))
+	})
+
+	ginkgo.It("waits for the reads in flight after io_uring_enter fails", func() {
+		var calls int
+		takeRing(func(r *ioURing) {
+			// the entries are consumed by the kernel, while both the submission and the waits report the failure
+			r.enter = func(toSubmit, minComplete, flags uint32) unix.Errno {
+				calls++
+				if errno := r.syscallEnter(toSubmit, minComplete, flags); errno != 0 {
+					return errno
+				}
+				return unix.EBADF
+			}
+		})
+		reqs := requests(entries)
+		gomega.Expect(ReadDataBatch(reqs)).To(gomega.Succeed())
+		expectRead(reqs)
+		gomega.Expect(calls).To(gomega.BeNumerically(">=", 1))
+		takeRing(func(r *ioURing) {
+			gomega.Expect(r.broken).To(gomega.BeTrue())
+			gomega.Expect(*r.cqHead).To(gomega.Equal(*r.cqTail))
+		})
+	})
+
+	ginkgo.It("reads the data one by one after the rings are closed", func() {
+		gomega.Expect(ring.Close()).To(gomega.Succeed())
+		reqs := requests(2 * entries)
+		gomega.Expect(ReadDataBatch(reqs)).To(gomega.Succeed())
+		expectRead(reqs)
+		gomega.Expect(uringFile.Close()).To(gomega.Succeed())
+		gomega.Expect(plainFile.Close()).To(gomega.Succeed())
+		ring = nil
+	})
+
+	ginkgo.It("serves the batches of the goroutines by the rings", func() {
+		rings, err := NewIOURing(2, entries)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		defer rings.Close()
+		f, err := NewLocalFileSystemWithIOURing(logger.GetLogger("test"), rings).OpenFile(path)
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		defer f.Close()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer ginkgo.GinkgoRecover()
+				defer wg.Done()
+				reqs := requests(3 * entries)
+				for j := range reqs {
+					reqs[j].Reader = f
+				}
+				gomega.Expect(ReadDataBatch(reqs)).To(gomega.Succeed())
+				expectRead(reqs)
+			}()
+		}
+		wg.Wait()
+	})
+
+	ginkgo.DescribeTable("completes the reads", func(res int32, wantErr error) {
+		req := ReadRequest{Reader: uringFile, Offset: 100, Buf: make([]byte, 200)}
+		if res > 0 {
+			// the head is read by the ring, and the rest by ReadData
+			copy(req.Buf, content[100:100+res])
+		}
+		var err error
+		takeRing(func(r *ioURing) {
+			err = r.complete(&req, res)
+		})
+		if wantErr != nil {
+			gomega.Expect(errors.Is(err, wantErr)).To(gomega.BeTrue(), "unexpected error: %v", err)
+			return
+		}
+		gomega.Expect(err).ToNot(gomega.HaveOccurred())
+		gomega.Expect(req.Buf).To(gomega.Equal(content[100:300]))
+	},
+		ginkgo.Entry("by the unsupported opcode", -int32(unix.EINVAL), nil),
+		ginkgo.Entry("failing", -int32(unix.EIO), unix.EIO),
+		ginkgo.Entry("short", int32(50), nil),
+		ginkgo.Entry("full", int32(200), nil),
+	)
+
+	ginkgo.It("fails the completions of the empty reads", func() {
+		req := ReadRequest{Reader: uringFile, Offset: 100, Buf: make([]byte, 200)}
+		takeRing(func(r *ioURing) {
+			gomega.Expect(r.complete(&req, 0)).To(gomega.MatchError(gomega.ContainSubstring("read 0 bytes at offset 100")))
+		})
+	})
+})
@@ -0,0 +1,42 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !linux
+// +build !linux
+
+package fs
+
+import "errors"
+
+var errIOURingUnsupported = errors.New("io_uring is only supported on linux")
+
+// IOURing submits the reads of the files in batches by io_uring, which is only supported on linux.
+type IOURing struct{}
+
+// NewIOURing fails since io_uring is only supported on linux.
+func NewIOURing(_ int, _ uint32) (*IOURing, error) {
+	return nil, errIOURingUnsupported
+}
+
+// Close does nothing.
+func (*IOURing) Close() error {
+	return nil
+}
+
+func (*IOURing) readBatch(reqs []ReadRequest) error {
+	return readDataOneByOne(reqs)
+}
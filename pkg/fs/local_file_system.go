@@ -35,14 +35,19 @@ import (
 
 const defaultIOSize = 256 * 1024
 
+var errHintsUnsupported = errors.New("the io options are unsupported on the platform")
+
 // localFileSystem implements the File System interface.
 type localFileSystem struct {
 	logger *logger.Logger
+	// ring reads the files in batches, it's nil if io_uring isn't enabled.
+	ring *IOURing
 }
 
 // LocalFile implements the File interface.
 type LocalFile struct {
 	file *os.File
+	ring *IOURing
 }
 
 // NewLocalFileSystem is used to create the Local File system.
@@ -59,6 +64,14 @@ func NewLocalFileSystemWithLogger(parent *logger.Logger) FileSystem {
 	}
 }
 
+// NewLocalFileSystemWithIOURing is used to create the Local File system whose files read in batches by ring.
+func NewLocalFileSystemWithIOURing(parent *logger.Logger, ring *IOURing) FileSystem {
+	return &localFileSystem{
+		logger: parent.Named(moduleName),
+		ring:   ring,
+	}
+}
+
 func readErrorHandle(operation string, err error, name string, size int) (int, error) {
 	switch {
 	case errors.Is(err, io.EOF):
@@ -132,6 +145,7 @@ func (fs *localFileSystem) CreateFile(name string, permission Mode) (File, error
 	case err == nil:
 		return &LocalFile{
 			file: file,
+			ring: fs.ring,
 		}, nil
 	case os.IsExist(err):
 		return nil, &FileSystemError{
@@ -157,6 +171,7 @@ func (fs *localFileSystem) OpenFile(name string) (File, error) {
 	case err == nil:
 		return &LocalFile{
 			file: file,
+			ring: fs.ring,
 		}, nil
 	case os.IsNotExist(err):
 		return nil, &FileSystemError{
@@ -272,6 +287,30 @@ func (fs *localFileSystem) MustRMAll(path string) {
 	fs.logger.Panic().Str("path", path).Msg("failed to remove all files under path")
 }
 
+// Rename moves the file or directory from oldPath to newPath.
+func (fs *localFileSystem) Rename(oldPath, newPath string) error {
+	err := os.Rename(oldPath, newPath)
+	switch {
+	case err == nil:
+		return nil
+	case os.IsNotExist(err):
+		return &FileSystemError{
+			Code:    IsNotExistError,
+			Message: fmt.Sprintf("File is not exist, file name: %s, error message: %s", oldPath, err),
+		}
+	case os.IsPermission(err):
+		return &FileSystemError{
+			Code:    permissionError,
+			Message: fmt.Sprintf("There is not enough permission, file name: %s, error message: %s", oldPath, err),
+		}
+	default:
+		return &FileSystemError{
+			Code:    otherError,
+			Message: fmt.Sprintf("Rename file error, file name: %s, new name: %s, error message: %s", oldPath, newPath, err),
+		}
+	}
+}
+
 func (fs *localFileSystem) MustGetFreeSpace(path string) uint64 {
 	usage, err := disk.Usage(path)
 	if err != nil {
@@ -280,6 +319,68 @@ func (fs *localFileSystem) MustGetFreeSpace(path string) uint64 {
 	return usage.Free
 }
 
+// CreateHardLink links the files under src into dest, which keeps the layout of the directories.
+func (fs *localFileSystem) CreateHardLink(src, dest string, filter func(path string) bool) (uint64, error) {
+	var size uint64
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o700)
+		}
+		if filter != nil && !filter(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err = os.Link(path, target); err != nil {
+			fs.logger.Debug().Err(err).Str("path", path).Msg("cannot link the file, copy it instead")
+			if err = copyFile(path, target, info.Mode()); err != nil {
+				return err
+			}
+		}
+		size += uint64(info.Size())
+		return nil
+	})
+	if err != nil {
+		return 0, &FileSystemError{
+			Code:    otherError,
+			Message: fmt.Sprintf("Link directory error, src: %s, dest: %s, error message: %s", src, dest, err),
+		}
+	}
+	fs.SyncPath(dest)
+	return size, nil
+}
+
+func copyFile(src, dest string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
 // Write adds new data to the end of a file.
 func (file *LocalFile) Write(buffer []byte) (int, error) {
 	// TODO: use bufio.Writer to optimize performance.
@@ -342,6 +443,19 @@ func (file *LocalFile) SequentialWrite() SeqWriter {
 	return &seqWriter{writer: writer, fileName: file.file.Name()}
 }
 
+// SequentialWriteWithOptions appends consecutive buffers to the end of the file with the IOOptions.
+// It falls back to SequentialWrite if the platform doesn't support them or the file can't be reopened with them.
+func (file *LocalFile) SequentialWriteWithOptions(opts IOOptions) SeqWriter {
+	sw, err := openHintedWriter(file.file.Name(), opts)
+	if err != nil {
+		if !errors.Is(err, errHintsUnsupported) {
+			logger.GetLogger(moduleName).Warn().Err(err).Str("path", file.file.Name()).Msg("cannot write the file with the io options, fall back to the buffered write")
+		}
+		return file.SequentialWrite()
+	}
+	return sw
+}
+
 // Read is used to read a specified location of file.
 func (file *LocalFile) Read(offset int64, buffer []byte) (int, error) {
 	rsize, err := file.file.ReadAt(buffer, offset)
@@ -371,7 +485,28 @@ func (file *LocalFile) Readv(offset int64, iov *[][]byte) (int, error) {
 // SequentialRead is used to read the entire file using streaming read.
 func (file *LocalFile) SequentialRead() SeqReader {
 	reader := generateReader(file.file)
-	return &seqReader{reader: reader, fileName: file.file.Name()}
+	return &seqReader{reader: reader, file: file.file, fileName: file.file.Name()}
+}
+
+// ReadBatch reads all the requests in a batch by io_uring if the file system enables it, otherwise one by one.
+func (file *LocalFile) ReadBatch(reqs []ReadRequest) error {
+	if file.ring == nil {
+		return readDataOneByOne(reqs)
+	}
+	return file.ring.readBatch(reqs)
+}
+
+// SequentialReadWithOptions is used to read the entire file using streaming read with the IOOptions.
+// It falls back to SequentialRead if the platform doesn't support them or the file can't be reopened with them.
+func (file *LocalFile) SequentialReadWithOptions(opts IOOptions) SeqReader {
+	sr, err := openHintedReader(file.file.Name(), opts)
+	if err != nil {
+		if !errors.Is(err, errHintsUnsupported) {
+			logger.GetLogger(moduleName).Warn().Err(err).Str("path", file.file.Name()).Msg("cannot read the file with the io options, fall back to the buffered read")
+		}
+		return file.SequentialRead()
+	}
+	return sr
 }
 
 // Size is used to get the file written data's size and return an error if the file does not exist. The unit of file size is Byte.
@@ -419,6 +554,7 @@ func (file *LocalFile) Close() error {
 
 type seqReader struct {
 	reader   *bufio.Reader
+	file     *os.File
 	fileName string
 }
 
@@ -434,6 +570,26 @@ func (i *seqReader) Path() string {
 	return i.fileName
 }
 
+// Discard skips the buffered data, and seeks the file over the rest instead of reading it.
+func (i *seqReader) Discard(n int64) (int64, error) {
+	buffered := int64(i.reader.Buffered())
+	if n <= buffered {
+		d, err := i.reader.Discard(int(n))
+		return int64(d), err
+	}
+	if _, err := i.reader.Discard(int(buffered)); err != nil {
+		return 0, err
+	}
+	if _, err := i.file.Seek(n-buffered, io.SeekCurrent); err != nil {
+		return buffered, &FileSystemError{
+			Code:    readError,
+			Message: fmt.Sprintf("Discard operation failed, seek file error, file name: %s, error message: %s", i.fileName, err),
+		}
+	}
+	i.reader.Reset(i.file)
+	return n, nil
+}
+
 func (i *seqReader) Close() error {
 	releaseReader(i.reader)
 	return nil
@@ -0,0 +1,317 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+// +build linux
+
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// directIOAlignment aligns the buffers, the offsets and the lengths of the direct I/O, which suits the logical blocks of the common devices.
+	directIOAlignment = 4096
+	// dropCacheInterval is the bytes read or written between two hints dropping the pages.
+	dropCacheInterval = 8 << 20
+)
+
+// openHintedReader reopens the file, so that the hints don't affect the reads sharing the descriptor of the file.
+func openHintedReader(name string, opts IOOptions) (SeqReader, error) {
+	fd, direct, err := openHinted(name, unix.O_RDONLY, opts.Direct)
+	if err != nil {
+		return nil, err
+	}
+	if !direct {
+		// the hint is advisory, the read goes on even if it fails
+		_ = unix.Fadvise(fd, 0, 0, unix.FADV_SEQUENTIAL)
+	}
+	return &hintedReader{
+		fileName:  name,
+		buf:       generateAlignedBuf(),
+		fd:        fd,
+		direct:    direct,
+		dropCache: opts.DropCache,
+	}, nil
+}
+
+// openHintedWriter reopens the file created by the caller, whose data is written through the new descriptor.
+func openHintedWriter(name string, opts IOOptions) (SeqWriter, error) {
+	fd, direct, err := openHinted(name, unix.O_WRONLY, opts.Direct)
+	if err != nil {
+		return nil, err
+	}
+	return &hintedWriter{
+		fileName:  name,
+		buf:       generateAlignedBuf(),
+		fd:        fd,
+		direct:    direct,
+		dropCache: opts.DropCache,
+	}, nil
+}
+
+// openHinted opens the file with O_DIRECT if direct is set. The file is opened without it if the file system rejects it, such as tmpfs.
+func openHinted(name string, flag int, direct bool) (int, bool, error) {
+	flag |= unix.O_CLOEXEC
+	if direct {
+		fd, err := unix.Open(name, flag|unix.O_DIRECT, 0)
+		if err == nil {
+			return fd, true, nil
+		}
+		if !errors.Is(err, unix.EINVAL) {
+			return -1, false, &os.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+	fd, err := unix.Open(name, flag, 0)
+	if err != nil {
+		return -1, false, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return fd, false, nil
+}
+
+type hintedReader struct {
+	fileName string
+	buf      []byte
+	fd       int
+	// off is the offset the next fill reads at, which is aligned for the direct I/O.
+	off int64
+	// dropped is the offset before which the pages are dropped.
+	dropped int64
+	r       int
+	w       int
+	// skip is the bytes the next fill skips, which lie between the aligned offset and the one discarded to.
+	skip      int
+	direct    bool
+	dropCache bool
+	eof       bool
+}
+
+func (hr *hintedReader) Read(p []byte) (int, error) {
+	if hr.r == hr.w {
+		if err := hr.fill(); err != nil {
+			return readErrorHandle("ReadStream operation", err, hr.fileName, 0)
+		}
+	}
+	n := copy(p, hr.buf[hr.r:hr.w])
+	hr.r += n
+	return n, nil
+}
+
+func (hr *hintedReader) fill() error {
+	for hr.r == hr.w {
+		if hr.eof {
+			return io.EOF
+		}
+		n, err := pread(hr.fd, hr.buf, hr.off)
+		if err != nil {
+			return err
+		}
+		// a short read only happens at the end of a regular file,
+		// reading on at the unaligned offset would fail with the direct I/O.
+		hr.eof = n < len(hr.buf)
+		hr.off += int64(n)
+		hr.r, hr.w = min(hr.skip, n), n
+		hr.skip = 0
+		hr.dropPages(false)
+	}
+	return nil
+}
+
+func (hr *hintedReader) Path() string {
+	return hr.fileName
+}
+
+// Discard skips the buffered data, and moves the offset of the next fill over the rest instead of reading it.
+func (hr *hintedReader) Discard(n int64) (int64, error) {
+	buffered := int64(hr.w - hr.r)
+	if n <= buffered {
+		hr.r += int(n)
+		return n, nil
+	}
+	target := hr.off - buffered + int64(hr.skip) + n
+	hr.r, hr.w = 0, 0
+	hr.off, hr.skip = target, 0
+	if hr.direct {
+		hr.off = target &^ (directIOAlignment - 1)
+		hr.skip = int(target - hr.off)
+	}
+	return n, nil
+}
+
+// dropPages advises the kernel to drop the pages read since the last time once they exceed the interval.
+func (hr *hintedReader) dropPages(force bool) {
+	if !hr.dropCache || hr.off <= hr.dropped || (!force && hr.off-hr.dropped < dropCacheInterval) {
+		return
+	}
+	_ = unix.Fadvise(hr.fd, hr.dropped, hr.off-hr.dropped, unix.FADV_DONTNEED)
+	hr.dropped = hr.off
+}
+
+func (hr *hintedReader) Close() error {
+	if hr.dropCache {
+		// the discarded ranges might be cached by the readahead as well
+		_ = unix.Fadvise(hr.fd, 0, 0, unix.FADV_DONTNEED)
+	}
+	releaseAlignedBuf(hr.buf)
+	hr.buf = nil
+	if err := unix.Close(hr.fd); err != nil {
+		return &FileSystemError{
+			Code:    closeError,
+			Message: fmt.Sprintf("Close File error, file name: %s, error message: %s", hr.fileName, err),
+		}
+	}
+	return nil
+}
+
+type hintedWriter struct {
+	fileName string
+	buf      []byte
+	fd       int
+	// off is the offset the next flush writes at.
+	off int64
+	// dropped is the offset before which the pages are written back and dropped.
+	dropped   int64
+	n         int
+	direct    bool
+	dropCache bool
+}
+
+func (hw *hintedWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		c := copy(hw.buf[hw.n:], p)
+		hw.n += c
+		p = p[c:]
+		// the buffer is only flushed once it's full, so that the writes are aligned for the direct I/O
+		if hw.n == len(hw.buf) {
+			if err := hw.flush(); err != nil {
+				return written, err
+			}
+		}
+		written += c
+	}
+	return written, nil
+}
+
+func (hw *hintedWriter) flush() error {
+	for written := 0; written < hw.n; {
+		n, err := unix.Pwrite(hw.fd, hw.buf[written:hw.n], hw.off)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return &FileSystemError{
+				Code:    writeError,
+				Message: fmt.Sprintf("Write file error, file name: %s, error message: %s", hw.fileName, err),
+			}
+		}
+		written += n
+		hw.off += int64(n)
+	}
+	hw.n = 0
+	hw.dropPages(false)
+	return nil
+}
+
+// dropPages writes back the pages written since the last time and drops them once they exceed the interval.
+// The dirty pages can't be dropped until they are written back.
+func (hw *hintedWriter) dropPages(force bool) {
+	if !hw.dropCache || hw.off <= hw.dropped || (!force && hw.off-hw.dropped < dropCacheInterval) {
+		return
+	}
+	_ = unix.SyncFileRange(hw.fd, hw.dropped, hw.off-hw.dropped,
+		unix.SYNC_FILE_RANGE_WAIT_BEFORE|unix.SYNC_FILE_RANGE_WRITE|unix.SYNC_FILE_RANGE_WAIT_AFTER)
+	_ = unix.Fadvise(hw.fd, hw.dropped, hw.off-hw.dropped, unix.FADV_DONTNEED)
+	hw.dropped = hw.off
+}
+
+func (hw *hintedWriter) Path() string {
+	return hw.fileName
+}
+
+func (hw *hintedWriter) Close() error {
+	var err error
+	if hw.n > 0 && hw.direct && hw.n%directIOAlignment != 0 {
+		// the unaligned tail is written through the page cache
+		err = clearDirect(hw.fd)
+	}
+	if err == nil && hw.n > 0 {
+		err = hw.flush()
+	}
+	if err == nil {
+		hw.dropPages(true)
+	}
+	releaseAlignedBuf(hw.buf)
+	hw.buf = nil
+	if closeErr := unix.Close(hw.fd); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		return &FileSystemError{
+			Code:    closeError,
+			Message: fmt.Sprintf("Flush File error, file name: %s, error message: %s", hw.fileName, err),
+		}
+	}
+	return nil
+}
+
+func clearDirect(fd int) error {
+	flag, err := unix.FcntlInt(uintptr(fd), unix.F_GETFL, 0)
+	if err != nil {
+		return err
+	}
+	_, err = unix.FcntlInt(uintptr(fd), unix.F_SETFL, flag&^unix.O_DIRECT)
+	return err
+}
+
+func pread(fd int, p []byte, off int64) (int, error) {
+	for {
+		n, err := unix.Pread(fd, p, off)
+		if !errors.Is(err, unix.EINTR) {
+			return n, err
+		}
+	}
+}
+
+func generateAlignedBuf() []byte {
+	if v := alignedBufPool.Get(); v != nil {
+		return *v.(*[]byte)
+	}
+	b := make([]byte, defaultIOSize+directIOAlignment)
+	shift := int(uintptr(unsafe.Pointer(&b[0])) & (directIOAlignment - 1))
+	if shift > 0 {
+		shift = directIOAlignment - shift
+	}
+	return b[shift : shift+defaultIOSize : shift+defaultIOSize]
+}
+
+func releaseAlignedBuf(b []byte) {
+	if b == nil {
+		return
+	}
+	alignedBufPool.Put(&b)
+}
+
+var alignedBufPool sync.Pool
@@ -23,9 +23,12 @@ import (
 	"errors"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/onsi/ginkgo/v2"
 	"github.com/onsi/gomega"
+
+	"github.com/apache/skywalking-banyandb/pkg/logger"
 )
 
 var _ = ginkgo.Describe("Loacl File System", func() {
@@ -122,6 +125,109 @@ var _ = ginkgo.Describe("Loacl File System", func() {
 			}
 		})
 
+		ginkgo.It("Stream Discard Test", func() {
+			// the data spans several buffers, so that the discard seeks the file beyond the buffered data
+			content := bytes.Repeat([]byte(data), 3*defaultIOSize/len(data))
+			_, err := file.Write(content)
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+			// the file is read by another handle, which reads it from the start
+			f, err := fs.OpenFile(fileName)
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			defer f.Close()
+			iter := f.SequentialRead()
+			defer iter.Close()
+			discarder, ok := iter.(Discarder)
+			gomega.Expect(ok).To(gomega.BeTrue())
+			buffer := make([]byte, len(data))
+			offset := 0
+			for _, n := range []int{1, 10, defaultIOSize, 2 * len(data)} {
+				_, err = io.ReadFull(iter, buffer)
+				gomega.Expect(err).ToNot(gomega.HaveOccurred())
+				gomega.Expect(buffer).To(gomega.Equal(content[offset : offset+len(buffer)]))
+				offset += len(buffer)
+				d, err := discarder.Discard(int64(n))
+				gomega.Expect(err).ToNot(gomega.HaveOccurred())
+				gomega.Expect(d).To(gomega.Equal(int64(n)))
+				offset += n
+			}
+			_, err = io.ReadFull(iter, buffer)
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			gomega.Expect(buffer).To(gomega.Equal(content[offset : offset+len(buffer)]))
+		})
+
+		ginkgo.It("Stream Read And Write With IO Options Test", func() {
+			// the unaligned tail and the discards test the aligned buffers of the direct I/O
+			content := bytes.Repeat([]byte(data), 3*defaultIOSize/len(data)+1000)
+			for _, opts := range []IOOptions{{Direct: true}, {DropCache: true}, {Direct: true, DropCache: true}} {
+				f, err := fs.CreateFile(fileName, 0o777)
+				gomega.Expect(err).ToNot(gomega.HaveOccurred())
+				w := SequentialWrite(f, opts)
+				for offset := 0; offset < len(content); offset += 1000 {
+					MustWriteData(w, content[offset:min(offset+1000, len(content))])
+				}
+				gomega.Expect(w.Close()).To(gomega.Succeed())
+				gomega.Expect(f.Close()).To(gomega.Succeed())
+				written, err := os.ReadFile(fileName)
+				gomega.Expect(err).ToNot(gomega.HaveOccurred())
+				gomega.Expect(written).To(gomega.Equal(content))
+
+				f, err = fs.OpenFile(fileName)
+				gomega.Expect(err).ToNot(gomega.HaveOccurred())
+				iter := SequentialRead(f, opts)
+				discarder, ok := iter.(Discarder)
+				gomega.Expect(ok).To(gomega.BeTrue())
+				buffer := make([]byte, len(data))
+				offset := 0
+				for _, n := range []int{1, 10, 5000, defaultIOSize, 2 * len(data)} {
+					_, err = io.ReadFull(iter, buffer)
+					gomega.Expect(err).ToNot(gomega.HaveOccurred())
+					gomega.Expect(buffer).To(gomega.Equal(content[offset : offset+len(buffer)]))
+					offset += len(buffer)
+					d, discardErr := discarder.Discard(int64(n))
+					gomega.Expect(discardErr).ToNot(gomega.HaveOccurred())
+					gomega.Expect(d).To(gomega.Equal(int64(n)))
+					offset += n
+				}
+				rest, err := io.ReadAll(iter)
+				gomega.Expect(err).ToNot(gomega.HaveOccurred())
+				gomega.Expect(rest).To(gomega.Equal(content[offset:]))
+				gomega.Expect(iter.Close()).To(gomega.Succeed())
+				gomega.Expect(f.Close()).To(gomega.Succeed())
+			}
+		})
+
+		ginkgo.It("Read Batch Test", func() {
+			ring, err := NewIOURing(1, 4)
+			if err != nil {
+				ginkgo.Skip("io_uring is unavailable: " + err.Error())
+			}
+			defer ring.Close()
+			content := bytes.Repeat([]byte(data), 1000)
+			_, err = file.Write(content)
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			uringFile, err := NewLocalFileSystemWithIOURing(logger.GetLogger("test"), ring).OpenFile(fileName)
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			defer uringFile.Close()
+
+			// the requests outnumber the entries of the ring, and mix the files with and without it
+			var reqs []ReadRequest
+			for i := 0; i < 10; i++ {
+				var r Reader = uringFile
+				if i%3 == 1 {
+					r = file
+				}
+				reqs = append(reqs, ReadRequest{Reader: r, Offset: int64(i * 500), Buf: make([]byte, 100+i)})
+			}
+			gomega.Expect(ReadDataBatch(reqs)).To(gomega.Succeed())
+			for _, req := range reqs {
+				gomega.Expect(req.Buf).To(gomega.Equal(content[req.Offset : req.Offset+int64(len(req.Buf))]))
+			}
+			// the short read at the end of the file fails
+			err = ReadDataBatch([]ReadRequest{{Reader: uringFile, Offset: int64(len(content) - 10), Buf: make([]byte, 100)}})
+			gomega.Expect(err).To(gomega.HaveOccurred())
+		})
+
 		ginkgo.It("Size Test", func() {
 			size, err := file.Write([]byte(data))
 			gomega.Expect(err).ToNot(gomega.HaveOccurred())
@@ -145,5 +251,25 @@ var _ = ginkgo.Describe("Loacl File System", func() {
 			_, err = os.Stat(fileName)
 			gomega.Expect(err).To(gomega.HaveOccurred())
 		})
+
+		ginkgo.It("Hard Link Test", func() {
+			const linkDir = "tmpLinkDir"
+			defer os.RemoveAll(linkDir)
+			_, err := file.Write([]byte(data))
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			gomega.Expect(os.MkdirAll("tmpDir/sub", 0o777)).To(gomega.Succeed())
+			_, err = fs.Write([]byte(data), "tmpDir/sub/skipped", 0o600)
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			size, err := fs.CreateHardLink(dirName, linkDir, func(path string) bool {
+				return filepath.Base(path) != "skipped"
+			})
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			gomega.Expect(size).To(gomega.Equal(uint64(len(data))))
+			linked, err := os.ReadFile(filepath.Join(linkDir, "temFile"))
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			gomega.Expect(string(linked)).To(gomega.Equal(data))
+			gomega.Expect(filepath.Join(linkDir, "sub")).To(gomega.BeADirectory())
+			gomega.Expect(filepath.Join(linkDir, "sub", "skipped")).ToNot(gomega.BeAnExistingFile())
+		})
 	})
 })
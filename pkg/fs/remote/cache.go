@@ -0,0 +1,114 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	"github.com/pkg/errors"
+)
+
+// BlockCache keeps the blocks of the remote files on the local disk, so that the blocks read again aren't downloaded.
+// The least recently used blocks are removed once the cache is full.
+type BlockCache struct {
+	lru       *simplelru.LRU[string, struct{}]
+	dir       string
+	blockSize int64
+	mu        sync.Mutex
+}
+
+// NewBlockCache returns the cache keeping at most maxSize bytes of the blocks in the dir.
+// The blocks left in the dir by the previous process are dropped.
+func NewBlockCache(dir string, blockSize, maxSize int64) (*BlockCache, error) {
+	if blockSize <= 0 {
+		return nil, errors.Errorf("invalid block size %d", blockSize)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	c := &BlockCache{dir: dir, blockSize: blockSize}
+	lru, err := simplelru.NewLRU[string, struct{}](int(max(1, maxSize/blockSize)), func(name string, _ struct{}) {
+		_ = os.Remove(filepath.Join(dir, name))
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.lru = lru
+	return c, nil
+}
+
+// block returns the idx-th block of the object, whose size is the total bytes of the object.
+func (c *BlockCache) block(ctx context.Context, store ObjectStore, key string, idx, size int64) ([]byte, error) {
+	name := blockName(key, idx)
+	p := filepath.Join(c.dir, name)
+	c.mu.Lock()
+	_, ok := c.lru.Get(name)
+	c.mu.Unlock()
+	if ok {
+		// the block might be evicted once the lock is released, which is downloaded again
+		if data, err := os.ReadFile(p); err == nil {
+			return data, nil
+		}
+	}
+
+	offset := idx * c.blockSize
+	length := min(c.blockSize, size-offset)
+	if length <= 0 {
+		return nil, io.EOF
+	}
+	body, err := store.GetRange(ctx, key, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(body)
+	_ = body.Close()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "cannot download the block %d of %s", idx, key)
+	}
+	if int64(len(data)) != length {
+		return nil, errors.Errorf("the block %d of %s has %d bytes, expected %d bytes", idx, key, len(data), length)
+	}
+	tmp := fmt.Sprintf("%s.%p.tmp", p, &data)
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	c.mu.Lock()
+	c.lru.Add(name, struct{}{})
+	c.mu.Unlock()
+	return data, nil
+}
+
+func blockName(key string, idx int64) string {
+	h := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h[:16]), idx)
+}
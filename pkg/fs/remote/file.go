@@ -0,0 +1,149 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package remote
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+)
+
+var errReadOnly = errors.New("the offloaded file is read-only")
+
+// file reads an offloaded file in the blocks of the cache. It can't be written.
+type file struct {
+	ctx   context.Context
+	store ObjectStore
+	cache *BlockCache
+	key   string
+	path  string
+	size  int64
+}
+
+func (f *file) Read(offset int64, buffer []byte) (int, error) {
+	var n int
+	for n < len(buffer) && offset < f.size {
+		idx := offset / f.cache.blockSize
+		data, err := f.cache.block(f.ctx, f.store, f.key, idx, f.size)
+		if err != nil {
+			return n, errors.WithMessagef(err, "cannot read %s at offset %d", f.path, offset)
+		}
+		copied := copy(buffer[n:], data[offset-idx*f.cache.blockSize:])
+		n += copied
+		offset += int64(copied)
+	}
+	if n < len(buffer) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *file) Readv(offset int64, iov *[][]byte) (int, error) {
+	var size int
+	for _, buffer := range *iov {
+		n, err := f.Read(offset, buffer)
+		size += n
+		if err != nil {
+			return size, err
+		}
+		offset += int64(n)
+	}
+	return size, nil
+}
+
+func (f *file) SequentialRead() fs.SeqReader {
+	return &seqReader{f: f}
+}
+
+func (f *file) Size() (int64, error) {
+	return f.size, nil
+}
+
+func (f *file) Write([]byte) (int, error) {
+	return 0, errReadOnly
+}
+
+func (f *file) Writev(*[][]byte) (int, error) {
+	return 0, errReadOnly
+}
+
+func (f *file) SequentialWrite() fs.SeqWriter {
+	return &readOnlyWriter{path: f.path}
+}
+
+func (f *file) Path() string {
+	return f.path
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+type seqReader struct {
+	f      *file
+	offset int64
+}
+
+func (sr *seqReader) Read(p []byte) (int, error) {
+	if sr.offset >= sr.f.size {
+		return 0, io.EOF
+	}
+	n, err := sr.f.Read(sr.offset, p)
+	sr.offset += int64(n)
+	if n > 0 && errors.Is(err, io.EOF) {
+		return n, nil
+	}
+	return n, err
+}
+
+// Discard skips the blocks, which aren't downloaded.
+func (sr *seqReader) Discard(n int64) (int64, error) {
+	d := min(n, sr.f.size-sr.offset)
+	sr.offset += d
+	if d < n {
+		return d, io.EOF
+	}
+	return d, nil
+}
+
+func (sr *seqReader) Path() string {
+	return sr.f.path
+}
+
+func (sr *seqReader) Close() error {
+	return nil
+}
+
+type readOnlyWriter struct {
+	path string
+}
+
+func (w *readOnlyWriter) Write([]byte) (int, error) {
+	return 0, errReadOnly
+}
+
+func (w *readOnlyWriter) Path() string {
+	return w.path
+}
+
+func (w *readOnlyWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,130 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package remote
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// fileStore keeps the objects in a directory, which is usually a mounted network file system.
+type fileStore struct {
+	root string
+}
+
+// NewFileStore returns the object store keeping the objects under the root directory.
+func NewFileStore(root string) ObjectStore {
+	return &fileStore{root: root}
+}
+
+func (s *fileStore) Put(_ context.Context, key string, body io.Reader, _ int64) error {
+	p := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, body)
+	if err == nil {
+		err = f.Sync()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmp, p)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+func (s *fileStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WithMessage(ErrObjectNotExist, key)
+	}
+	return f, err
+}
+
+func (s *fileStore) GetRange(_ context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WithMessage(ErrObjectNotExist, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sectionReadCloser{Reader: io.NewSectionReader(f, offset, length), Closer: f}, nil
+}
+
+type sectionReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (s *fileStore) List(_ context.Context, prefix string) ([]string, error) {
+	// walk the deepest directory containing all keys with the prefix
+	dir := path.Dir(prefix + "x")
+	var keys []string
+	err := filepath.WalkDir(s.path(dir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(p, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (s *fileStore) Delete(_ context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *fileStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
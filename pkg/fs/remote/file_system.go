@@ -0,0 +1,264 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+const (
+	// ManifestFilename is the file listing the files offloaded from its directory.
+	ManifestFilename = "remote.json"
+	filePermission   = 0o600
+)
+
+type manifest struct {
+	// Files maps the names of the offloaded files to their sizes.
+	Files map[string]int64 `json:"files"`
+}
+
+// FileSystem offloads the files of the directories under the root to an object store.
+// The files offloaded are read from the object store in the blocks of the cache,
+// and the others are operated by the local file system it wraps.
+type FileSystem struct {
+	fs.FileSystem
+	store  ObjectStore
+	cache  *BlockCache
+	l      *logger.Logger
+	root   string
+	prefix string
+}
+
+// NewFileSystem returns the file system keeping the files offloaded from the root under the prefix of the store.
+func NewFileSystem(local fs.FileSystem, store ObjectStore, cache *BlockCache, root, prefix string) *FileSystem {
+	return &FileSystem{
+		FileSystem: local,
+		store:      store,
+		cache:      cache,
+		l:          logger.GetLogger("remote"),
+		root:       root,
+		prefix:     prefix,
+	}
+}
+
+// OpenFile opens the local file, or the offloaded one if the local file doesn't exist.
+func (t *FileSystem) OpenFile(name string) (fs.File, error) {
+	f, err := t.FileSystem.OpenFile(name)
+	if !isNotExist(err) {
+		return f, err
+	}
+	size, ok := t.mustReadManifest(filepath.Dir(name)).Files[filepath.Base(name)]
+	if !ok {
+		return nil, err
+	}
+	return &file{
+		ctx:   context.Background(),
+		store: t.store,
+		cache: t.cache,
+		key:   t.key(name),
+		path:  name,
+		size:  size,
+	}, nil
+}
+
+// Read reads the entire local file, or downloads the offloaded one if the local file doesn't exist.
+func (t *FileSystem) Read(name string) ([]byte, error) {
+	data, err := t.FileSystem.Read(name)
+	if !isNotExist(err) {
+		return data, err
+	}
+	f, errOpen := t.OpenFile(name)
+	if errOpen != nil {
+		return nil, errOpen
+	}
+	data = make([]byte, f.(*file).size)
+	if err = fs.ReadData(f, 0, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ReadDir lists the local entries and the offloaded files of the directory, which hides the manifest.
+func (t *FileSystem) ReadDir(dirname string) []fs.DirEntry {
+	entries := t.FileSystem.ReadDir(dirname)
+	m := t.mustReadManifest(dirname)
+	if len(m.Files) == 0 {
+		return entries
+	}
+	result := make([]fs.DirEntry, 0, len(entries)+len(m.Files))
+	for _, e := range entries {
+		if e.Name() == ManifestFilename {
+			continue
+		}
+		delete(m.Files, e.Name())
+		result = append(result, e)
+	}
+	for name := range m.Files {
+		result = append(result, fileEntry(name))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result
+}
+
+// MustRMAll removes the offloaded files under the path from the object store before removing the path.
+func (t *FileSystem) MustRMAll(path string) {
+	t.RemoveOffloaded(path)
+	t.FileSystem.MustRMAll(path)
+}
+
+// RemoveOffloaded deletes the files offloaded from the directories under the path, which are kept on the local disk.
+// The objects failed to delete are left in the store, which the lifecycle rules of the bucket could expire.
+func (t *FileSystem) RemoveOffloaded(path string) {
+	var walk func(dir string)
+	walk = func(dir string) {
+		for _, e := range t.FileSystem.ReadDir(dir) {
+			if e.IsDir() {
+				walk(filepath.Join(dir, e.Name()))
+				continue
+			}
+			if e.Name() != ManifestFilename {
+				continue
+			}
+			for name := range t.mustReadManifest(dir).Files {
+				key := t.key(filepath.Join(dir, name))
+				if err := t.store.Delete(context.Background(), key); err != nil {
+					t.l.Warn().Err(err).Str("key", key).Msg("cannot delete the offloaded file")
+				}
+			}
+		}
+	}
+	if t.isOffloading(path) {
+		walk(path)
+	}
+}
+
+// Offload uploads the files of the directory the filter accepts, then removes them from the local disk.
+// The subdirectories are left. It returns the total size of the offloaded files.
+func (t *FileSystem) Offload(ctx context.Context, dir string, filter func(name string) bool) (uint64, error) {
+	if !t.isOffloading(dir) {
+		return 0, errors.Errorf("%s isn't under %s", dir, t.root)
+	}
+	m := manifest{Files: make(map[string]int64)}
+	var total uint64
+	for _, e := range t.FileSystem.ReadDir(dir) {
+		if e.IsDir() || e.Name() == ManifestFilename || (filter != nil && !filter(e.Name())) {
+			continue
+		}
+		name := filepath.Join(dir, e.Name())
+		size, err := t.upload(ctx, name)
+		if err != nil {
+			return 0, errors.WithMessagef(err, "cannot offload %s", name)
+		}
+		m.Files[e.Name()] = size
+		total += uint64(size)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return 0, err
+	}
+	// the local files are kept until the manifest is durable, and the local ones are read first if a crash leaves both
+	if _, err = t.FileSystem.Write(data, filepath.Join(dir, ManifestFilename), filePermission); err != nil {
+		return 0, err
+	}
+	t.FileSystem.SyncPath(dir)
+	for name := range m.Files {
+		if err = t.FileSystem.DeleteFile(filepath.Join(dir, name)); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// Offloaded reports whether the directory is offloaded.
+func (t *FileSystem) Offloaded(dir string) bool {
+	_, err := t.FileSystem.Read(filepath.Join(dir, ManifestFilename))
+	return err == nil
+}
+
+func (t *FileSystem) upload(ctx context.Context, name string) (int64, error) {
+	f, err := t.FileSystem.OpenFile(name)
+	if err != nil {
+		return 0, err
+	}
+	defer fs.MustClose(f)
+	size, err := f.Size()
+	if err != nil {
+		return 0, err
+	}
+	sr := f.SequentialRead()
+	defer fs.MustClose(sr)
+	return size, t.store.Put(ctx, t.key(name), sr, size)
+}
+
+func (t *FileSystem) mustReadManifest(dir string) manifest {
+	var m manifest
+	data, err := t.FileSystem.Read(filepath.Join(dir, ManifestFilename))
+	if isNotExist(err) {
+		return m
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &m)
+	}
+	if err != nil {
+		t.l.Panic().Err(err).Str("dir", dir).Msg("cannot read the manifest of the offloaded files")
+	}
+	return m
+}
+
+func (t *FileSystem) isOffloading(path string) bool {
+	rel, err := filepath.Rel(t.root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// key is the prefix followed by the path relative to the root in slashes.
+func (t *FileSystem) key(path string) string {
+	rel, err := filepath.Rel(t.root, path)
+	if err != nil {
+		t.l.Panic().Err(err).Str("path", path).Msg("the path isn't under the root")
+	}
+	if t.prefix == "" {
+		return filepath.ToSlash(rel)
+	}
+	return t.prefix + "/" + filepath.ToSlash(rel)
+}
+
+func isNotExist(err error) bool {
+	var fsErr *fs.FileSystemError
+	return errors.As(err, &fsErr) && fsErr.Code == fs.IsNotExistError
+}
+
+type fileEntry string
+
+func (e fileEntry) Name() string {
+	return string(e)
+}
+
+func (e fileEntry) IsDir() bool {
+	return false
+}
@@ -0,0 +1,142 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/pkg/fs"
+	"github.com/apache/skywalking-banyandb/pkg/test"
+)
+
+// countingStore counts the ranges downloaded.
+type countingStore struct {
+	ObjectStore
+	ranges atomic.Int32
+}
+
+func (s *countingStore) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	s.ranges.Add(1)
+	return s.ObjectStore.GetRange(ctx, key, offset, length)
+}
+
+func Test_FileSystem_offload(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	root := filepath.Join(tmpPath, "data")
+	dir := filepath.Join(root, "seg-1", "0000000000000001")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o700))
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data.bin"), data, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "meta.bin"), []byte("meta"), 0o600))
+
+	store := &countingStore{ObjectStore: NewFileStore(filepath.Join(tmpPath, "remote"))}
+	cache, err := NewBlockCache(filepath.Join(tmpPath, "cache"), 16, 32)
+	require.NoError(t, err)
+	tiered := NewFileSystem(fs.NewLocalFileSystem(), store, cache, root, "node")
+
+	assert.False(t, tiered.Offloaded(dir))
+	size, err := tiered.Offload(context.Background(), dir, func(name string) bool {
+		return name != "meta.bin"
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(len(data)), size)
+	assert.True(t, tiered.Offloaded(dir))
+	_, err = os.Stat(filepath.Join(dir, "data.bin"))
+	assert.True(t, os.IsNotExist(err), "the offloaded file is removed from the local disk")
+	keys, err := store.List(context.Background(), "node/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"node/seg-1/0000000000000001/data.bin"}, keys)
+
+	var names []string
+	for _, e := range tiered.ReadDir(dir) {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{"data.bin", "meta.bin", "sub"}, names, "the manifest is hidden")
+
+	f, err := tiered.OpenFile(filepath.Join(dir, "data.bin"))
+	require.NoError(t, err)
+	got := make([]byte, 20)
+	require.NoError(t, fs.ReadData(f, 10, got))
+	assert.Equal(t, data[10:30], got, "the read spans two blocks")
+	require.NoError(t, fs.ReadData(f, 12, got[:4]))
+	assert.Equal(t, int32(2), store.ranges.Load(), "the cached blocks aren't downloaded again")
+	_, err = f.Read(95, got)
+	assert.ErrorIs(t, err, io.EOF)
+	_, err = f.Write(data)
+	assert.Error(t, err, "the offloaded file is read-only")
+
+	sr := f.SequentialRead()
+	head := make([]byte, 5)
+	_, err = io.ReadFull(sr, head)
+	require.NoError(t, err)
+	d, err := sr.(fs.Discarder).Discard(80)
+	require.NoError(t, err)
+	assert.Equal(t, int64(80), d)
+	tail, err := io.ReadAll(sr)
+	require.NoError(t, err)
+	assert.Equal(t, data[85:], tail)
+	require.NoError(t, sr.Close())
+	require.NoError(t, f.Close())
+
+	whole, err := tiered.Read(filepath.Join(dir, "data.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, data, whole)
+	meta, err := tiered.Read(filepath.Join(dir, "meta.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "meta", string(meta), "the local file is read from the local disk")
+
+	tiered.MustRMAll(filepath.Join(root, "seg-1"))
+	keys, err = store.List(context.Background(), "node/")
+	require.NoError(t, err)
+	assert.Empty(t, keys, "the offloaded files are removed along with the directory")
+}
+
+func Test_BlockCache_evict(t *testing.T) {
+	tmpPath, defFn := test.Space(require.New(t))
+	defer defFn()
+	store := &countingStore{ObjectStore: NewFileStore(filepath.Join(tmpPath, "remote"))}
+	data := []byte("0123456789")
+	require.NoError(t, store.Put(context.Background(), "key", bytes.NewReader(data), int64(len(data))))
+	cache, err := NewBlockCache(filepath.Join(tmpPath, "cache"), 4, 8)
+	require.NoError(t, err)
+
+	for _, idx := range []int64{0, 1, 0, 2} {
+		block, errBlock := cache.block(context.Background(), store, "key", idx, int64(len(data)))
+		require.NoError(t, errBlock)
+		assert.Equal(t, data[idx*4:min(idx*4+4, int64(len(data)))], block)
+	}
+	assert.Equal(t, int32(3), store.ranges.Load())
+	entries, err := os.ReadDir(filepath.Join(tmpPath, "cache"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "the least recently used block is evicted")
+	_, err = cache.block(context.Background(), store, "key", 1, int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), store.ranges.Load(), "the evicted block is downloaded again")
+	_, err = cache.block(context.Background(), store, "missing", 0, 4)
+	assert.ErrorIs(t, err, ErrObjectNotExist)
+}
@@ -0,0 +1,105 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package remote implements the object storages keeping the files off the local disk,
+// and the file system reading the files offloaded to them.
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// ErrObjectNotExist is returned if the object to download doesn't exist.
+	ErrObjectNotExist = errors.New("the object doesn't exist")
+
+	errEmptyDest       = errors.New("the destination is empty")
+	errUnsupportedDest = errors.New("unsupported destination, it should be s3://bucket/prefix or file:///path")
+)
+
+// ObjectStore is a flat namespace of objects, such as an S3 bucket.
+type ObjectStore interface {
+	// Put uploads the object of the size, which replaces the existing one.
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	// Get downloads the object. It returns ErrObjectNotExist if the object doesn't exist.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// GetRange downloads the length bytes of the object from the offset.
+	// It returns ErrObjectNotExist if the object doesn't exist.
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// List returns the keys of the objects with the prefix in ascending order.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes the object. Deleting a missing object isn't an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Config locates an object store by the flags sharing a prefix.
+type Config struct {
+	Dest      string
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+}
+
+// AddFlags registers the flags named after the prefix, such as "<prefix>-dest" and "<prefix>-s3-endpoint".
+// The usage describes what the store keeps.
+func (c *Config) AddFlags(flagS *pflag.FlagSet, prefix, usage string) {
+	flagS.StringVar(&c.Dest, prefix+"-dest", "", fmt.Sprintf("the destination of %s, s3://bucket/prefix or file:///path", usage))
+	flagS.StringVar(&c.Endpoint, prefix+"-s3-endpoint", "",
+		"the endpoint of the S3-compatible storage, such as http://minio:9000 or https://storage.googleapis.com. AWS S3 is used if it's empty")
+	flagS.StringVar(&c.Region, prefix+"-s3-region", "us-east-1", "the region of the bucket, \"auto\" for Google Cloud Storage")
+	flagS.StringVar(&c.AccessKey, prefix+"-s3-access-key", "", "the access key, AWS_ACCESS_KEY_ID is used if it's empty")
+	flagS.StringVar(&c.SecretKey, prefix+"-s3-secret-key", "", "the secret key, AWS_SECRET_ACCESS_KEY is used if it's empty")
+	flagS.BoolVar(&c.PathStyle, prefix+"-s3-path-style", false, "address the bucket in the path instead of the host name, which MinIO needs")
+}
+
+// Open returns the object store of the destination.
+func (c *Config) Open() (ObjectStore, error) {
+	if c.Dest == "" {
+		return nil, errEmptyDest
+	}
+	u, err := url.Parse(c.Dest)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid destination %s", c.Dest)
+	}
+	switch u.Scheme {
+	case "file":
+		return NewFileStore(u.Path), nil
+	case "s3":
+		if u.Host == "" {
+			return nil, errors.WithMessage(errUnsupportedDest, c.Dest)
+		}
+		accessKey, secretKey := c.AccessKey, c.SecretKey
+		if accessKey == "" {
+			accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		}
+		if secretKey == "" {
+			secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		return newS3Store(c.Endpoint, c.Region, u.Host, strings.Trim(u.Path, "/"), accessKey, secretKey, c.PathStyle)
+	}
+	return nil, errors.WithMessage(errUnsupportedDest, c.Dest)
+}
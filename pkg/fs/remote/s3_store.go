@@ -0,0 +1,301 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package remote
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// unsignedPayload skips hashing the bodies streamed from the files, which are verified by the manifests.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// emptyPayloadHash is the SHA256 of an empty body.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	amzDateFormat    = "20060102T150405Z"
+	signAlgorithm    = "AWS4-HMAC-SHA256"
+	maxErrorBody     = 4 << 10
+)
+
+// s3Store talks to an S3-compatible storage in the REST API signed by the signature version 4.
+type s3Store struct {
+	client    *http.Client
+	endpoint  *url.URL
+	now       func() time.Time
+	bucket    string
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+}
+
+func newS3Store(endpoint, region, bucket, prefix, accessKey, secretKey string, pathStyle bool) (*s3Store, error) {
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("the access key and the secret key of the S3-compatible storage are required")
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("invalid endpoint %q", endpoint)
+	}
+	return &s3Store{
+		client:    &http.Client{},
+		endpoint:  u,
+		now:       time.Now,
+		bucket:    bucket,
+		prefix:    prefix,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		pathStyle: pathStyle,
+	}, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, "", body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := s.do(req, key, nil, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "", http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, key, nil, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3Store) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "", http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	resp, err := s.do(req, key, nil, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, "", http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, key, nil, emptyPayloadHash)
+	if errors.Is(err, ErrObjectNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+type listBucketResult struct {
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated bool `xml:"IsTruncated"`
+}
+
+func (s *s3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	query := url.Values{"list-type": {"2"}, "prefix": {s.objectKey(prefix)}}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "", http.NoBody)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, "", query, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, errors.WithMessage(err, "cannot parse the objects listed")
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, strings.TrimPrefix(c.Key, s.objectKey("")))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+func (s *s3Store) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+// do sends the request to the object of the key, or the bucket if the key is empty.
+// It returns ErrObjectNotExist if the object is missing, and an error carrying the message of the storage if the request fails.
+func (s *s3Store) do(req *http.Request, key string, query url.Values, payloadHash string) (*http.Response, error) {
+	u := *s.endpoint
+	objectPath := "/"
+	if key != "" {
+		objectPath += s.objectKey(key)
+	}
+	if s.pathStyle {
+		objectPath = "/" + s.bucket + objectPath
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.Path = objectPath
+	u.RawPath = escapePath(objectPath)
+	u.RawQuery = canonicalQuery(query)
+	req.URL = &u
+	req.Host = u.Host
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	s.sign(req, payloadHash, s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		return nil, errors.WithMessage(ErrObjectNotExist, key)
+	}
+	return nil, errors.Errorf("%s %s: %s: %s", req.Method, u.Path, resp.Status, msg)
+}
+
+// sign adds the authorization header of the signature version 4, which signs the host and the headers of the request.
+func (s *s3Store) sign(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k)
+		canonicalHeaders.WriteByte(':')
+		canonicalHeaders.WriteString(strings.TrimSpace(headers[k]))
+		canonicalHeaders.WriteByte('\n')
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format("20060102"), s.region, "s3", "aws4_request"}, "/")
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{signAlgorithm, amzDate, scope, hex.EncodeToString(hashed[:])}, "\n")
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signAlgorithm, s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery sorts the parameters by the names, and escapes them as the signature version 4 requires.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for k := range query {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var params []string
+	for _, k := range names {
+		for _, v := range query[k] {
+			params = append(params, escape(k)+"="+escape(v))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i := range segments {
+		segments[i] = escape(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+// escape encodes all bytes except the unreserved characters.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,164 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpchelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+)
+
+const (
+	// CompressorNone sends the messages uncompressed.
+	CompressorNone = "none"
+	// CompressorGzip is the name of the gzip compressor.
+	CompressorGzip = gzip.Name
+	// CompressorZstd is the name of the zstd compressor.
+	CompressorZstd = "zstd"
+)
+
+func init() {
+	encoding.RegisterCompressor(newZstdCompressor())
+}
+
+// ValidateCompressor checks whether the compressor is registered, or it's CompressorNone.
+func ValidateCompressor(name string) error {
+	if name == CompressorNone || encoding.GetCompressor(name) != nil {
+		return nil
+	}
+	return fmt.Errorf("unknown compressor %q, which should be one of %s, %s and %s", name, CompressorNone, CompressorGzip, CompressorZstd)
+}
+
+// SendCompressorInterceptors compress the responses of a server by the compressor if the clients accept it.
+// The requests are decompressed by the compressors the clients use, whichever is registered.
+func SendCompressorInterceptors(name string) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	if name == "" || name == CompressorNone {
+		return nil, nil
+	}
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			setSendCompressor(ctx, name)
+			return handler(ctx, req)
+		}, func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			setSendCompressor(ss.Context(), name)
+			return handler(srv, ss)
+		}
+}
+
+func setSendCompressor(ctx context.Context, name string) {
+	accepted, err := grpc.ClientSupportedCompressors(ctx)
+	if err != nil {
+		return
+	}
+	for _, c := range accepted {
+		if c == name {
+			_ = grpc.SetSendCompressor(ctx, name)
+			return
+		}
+	}
+}
+
+// zstdCompressor pools the encoders and the decoders of the streaming zstd frames.
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+func newZstdCompressor() *zstdCompressor {
+	c := &zstdCompressor{}
+	c.encoders.New = func() any {
+		e, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedFastest))
+		if err != nil {
+			logger.Panicf("failed to create ZSTD writer: %v", err)
+		}
+		return e
+	}
+	c.decoders.New = func() any {
+		d, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			logger.Panicf("failed to create ZSTD reader: %v", err)
+		}
+		return d
+	}
+	return c
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	e := c.encoders.Get().(*zstd.Encoder)
+	e.Reset(w)
+	return &zstdWriter{encoder: e, pool: &c.encoders}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	d := c.decoders.Get().(*zstd.Decoder)
+	if err := d.Reset(r); err != nil {
+		c.decoders.Put(d)
+		return nil, err
+	}
+	return &zstdReader{decoder: d, pool: &c.decoders}, nil
+}
+
+func (c *zstdCompressor) Name() string {
+	return CompressorZstd
+}
+
+type zstdWriter struct {
+	encoder *zstd.Encoder
+	pool    *sync.Pool
+}
+
+func (z *zstdWriter) Write(p []byte) (int, error) {
+	return z.encoder.Write(p)
+}
+
+// Close ends the frame, then returns the encoder to the pool.
+func (z *zstdWriter) Close() error {
+	if z.encoder == nil {
+		return nil
+	}
+	err := z.encoder.Close()
+	z.pool.Put(z.encoder)
+	z.encoder = nil
+	return err
+}
+
+type zstdReader struct {
+	decoder *zstd.Decoder
+	pool    *sync.Pool
+}
+
+// Read returns the decoder to the pool once the frame is read up.
+func (z *zstdReader) Read(p []byte) (int, error) {
+	if z.decoder == nil {
+		return 0, io.EOF
+	}
+	n, err := z.decoder.Read(p)
+	if errors.Is(err, io.EOF) {
+		z.pool.Put(z.decoder)
+		z.decoder = nil
+	}
+	return n, err
+}
@@ -0,0 +1,57 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grpchelper
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/encoding"
+)
+
+func TestZstdCompressor(t *testing.T) {
+	c := encoding.GetCompressor(CompressorZstd)
+	require.NotNil(t, c)
+	// the encoders and the decoders are reused by the rounds
+	for i := 0; i < 3; i++ {
+		msg := bytes.Repeat([]byte("banyandb"), 1024*(i+1))
+		var buf bytes.Buffer
+		w, err := c.Compress(&buf)
+		require.NoError(t, err)
+		_, err = w.Write(msg)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		assert.Less(t, buf.Len(), len(msg))
+
+		r, err := c.Decompress(&buf)
+		require.NoError(t, err)
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, msg, got)
+	}
+}
+
+func TestValidateCompressor(t *testing.T) {
+	for _, name := range []string{CompressorNone, CompressorGzip, CompressorZstd} {
+		assert.NoError(t, ValidateCompressor(name))
+	}
+	assert.Error(t, ValidateCompressor("snappy"))
+}
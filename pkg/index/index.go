@@ -39,6 +39,8 @@ type FieldKey struct {
 	SeriesID    common.SeriesID
 	IndexRuleID uint32
 	Analyzer    databasev1.IndexRule_Analyzer
+	// Numeric indicates the terms are int64 values indexed by a range index, which looks up the ranges at several precisions.
+	Numeric bool
 }
 
 // MarshalIndexRule encodes the index rule id to string representation.
@@ -205,6 +207,16 @@ type Documents []Document
 type Batch struct {
 	Applied   chan struct{}
 	Documents Documents
+	// Deletions are the IDs of the documents to delete, which are the series documents without any field.
+	Deletions []uint64
+	// SeriesDeletions are the documents keyed by their series to delete.
+	SeriesDeletions []SeriesDocID
+}
+
+// SeriesDocID identifies a document keyed by its series, whose fields have the series id.
+type SeriesDocID struct {
+	SeriesID common.SeriesID
+	DocID    uint64
 }
 
 // Writer allows writing fields and docID in a document to a index.
@@ -222,6 +234,8 @@ type FieldIterable interface {
 type Searcher interface {
 	FieldIterable
 	Match(fieldKey FieldKey, match []string) (list posting.List, err error)
+	MatchPhrase(fieldKey FieldKey, phrases []string) (list posting.List, err error)
+	MatchPattern(fieldKey FieldKey, pattern *Pattern) (list posting.List, err error)
 	MatchField(fieldKey FieldKey) (list posting.List, err error)
 	MatchTerms(field Field) (list posting.List, err error)
 	Range(fieldKey FieldKey, opts RangeOpts) (list posting.List, err error)
@@ -247,6 +261,29 @@ type SeriesStore interface {
 	Search([]byte) (common.SeriesID, error)
 	SearchPrefix([]byte) ([]Series, error)
 	SearchWildcard([]byte) ([]Series, error)
+	// Documents visits the documents of all series. The terms of a field are restored as they're stored
+	// if analyzerOf reports the index rule of the field is analyzed.
+	Documents(analyzerOf func(indexRuleID uint32) databasev1.IndexRule_Analyzer, visitor func(Document) error) error
+}
+
+// Archiver copies the documents of a store into a directory, where a store is opened later.
+type Archiver interface {
+	Archive(dir string) error
+}
+
+// SeriesDocumentVisitor visits the documents keyed by a series.
+type SeriesDocumentVisitor interface {
+	// VisitSeriesDocuments visits the documents of the series whose IDs are in [minDocID, maxDocID].
+	// The terms of a field are restored as they're stored if analyzerOf reports the index rule of the field is analyzed.
+	VisitSeriesDocuments(seriesID common.SeriesID, minDocID, maxDocID uint64,
+		analyzerOf func(indexRuleID uint32) databasev1.IndexRule_Analyzer, visitor func(Document) error) error
+}
+
+// TermVisitor visits the terms of the fields not analyzed.
+type TermVisitor interface {
+	// VisitTerms visits the terms of the field starting with prefix in order, which are the values of the documents.
+	// The terms of the documents deleted might be visited until the index merges them away.
+	VisitTerms(fieldKey FieldKey, prefix []byte, visitor func(term []byte) error) error
 }
 
 // GetSearcher returns a searcher associated with input index rule type.
@@ -31,6 +31,7 @@ import (
 	"github.com/blugelabs/bluge"
 	"github.com/blugelabs/bluge/analysis"
 	"github.com/blugelabs/bluge/analysis/analyzer"
+	"github.com/blugelabs/bluge/analysis/lang/cjk"
 	blugeIndex "github.com/blugelabs/bluge/index"
 	"github.com/blugelabs/bluge/search"
 	"github.com/dgraph-io/badger/v3/y"
@@ -66,6 +67,7 @@ func init() {
 		databasev1.IndexRule_ANALYZER_KEYWORD:  analyzer.NewKeywordAnalyzer(),
 		databasev1.IndexRule_ANALYZER_SIMPLE:   analyzer.NewSimpleAnalyzer(),
 		databasev1.IndexRule_ANALYZER_STANDARD: analyzer.NewStandardAnalyzer(),
+		databasev1.IndexRule_ANALYZER_CJK:      cjk.Analyzer(),
 	}
 }
 
@@ -270,15 +272,109 @@ func (s *store) Match(fieldKey index.FieldKey, matches []string) (posting.List,
 		query.AddMust(bluge.NewMatchQuery(m).SetField(fk).
 			SetAnalyzer(analyzer))
 	}
+	return searchPostings(reader, query, fk, false)
+}
+
+// MatchPhrase searches the documents containing all the phrases, whose tokens have to be adjacent and in order.
+func (s *store) MatchPhrase(fieldKey index.FieldKey, phrases []string) (posting.List, error) {
+	if len(phrases) == 0 || fieldKey.Analyzer == databasev1.IndexRule_ANALYZER_UNSPECIFIED {
+		return roaring.DummyPostingList, nil
+	}
+	reader, err := s.writer.Reader()
+	if err != nil {
+		return nil, err
+	}
+	analyzer := analyzers[fieldKey.Analyzer]
+	fk := fieldKey.MarshalIndexRule()
+	query := bluge.NewBooleanQuery()
+	if fieldKey.HasSeriesID() {
+		query.AddMust(bluge.NewTermQuery(string(fieldKey.SeriesID.Marshal())).SetField(seriesIDField))
+	}
+	for _, p := range phrases {
+		query.AddMust(bluge.NewMatchPhraseQuery(p).SetField(fk).
+			SetAnalyzer(analyzer))
+	}
+	return searchPostings(reader, query, fk, false)
+}
+
+// MatchPattern scans the term dictionary of the field from the literal prefix of the pattern,
+// then searches the documents containing the terms matching it.
+func (s *store) MatchPattern(fieldKey index.FieldKey, pattern *index.Pattern) (list posting.List, err error) {
+	reader, err := s.writer.Reader()
+	if err != nil {
+		return nil, err
+	}
+	fk := fieldKey.MarshalIndexRule()
+	analyzed := fieldKey.Analyzer != databasev1.IndexRule_ANALYZER_UNSPECIFIED
+	// the terms of a field not analyzed are prefixed by the field key
+	var keyPrefix []byte
+	if !analyzed {
+		keyPrefix = []byte(index.FieldStr(fieldKey, nil))
+	}
+	start := append(append([]byte{}, keyPrefix...), pattern.Prefix()...)
+	dict, err := reader.DictionaryIterator(fk, nil, start, index.PrefixUpperBound(start))
+	if err != nil {
+		return nil, multierr.Append(err, reader.Close())
+	}
+	terms := bluge.NewBooleanQuery()
+	var matched int
+	entry, err := dict.Next()
+	for ; err == nil && entry != nil; entry, err = dict.Next() {
+		term := entry.Term()
+		if !pattern.Match([]byte(term[len(keyPrefix):])) {
+			continue
+		}
+		terms.AddShould(bluge.NewTermQuery(term).SetField(fk))
+		matched++
+	}
+	if err = multierr.Append(err, dict.Close()); err != nil {
+		return nil, multierr.Append(err, reader.Close())
+	}
+	if matched == 0 {
+		return roaring.NewPostingList(), reader.Close()
+	}
+	var query bluge.Query = terms
+	if analyzed && fieldKey.HasSeriesID() {
+		query = bluge.NewBooleanQuery().
+			AddMust(terms).
+			AddMust(bluge.NewTermQuery(string(fieldKey.SeriesID.Marshal())).SetField(seriesIDField))
+	}
+	return searchPostings(reader, query, fk, !analyzed)
+}
+
+// VisitTerms implements index.TermVisitor.
+func (s *store) VisitTerms(fieldKey index.FieldKey, prefix []byte, visitor func(term []byte) error) error {
+	reader, err := s.writer.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	// the terms of a field not analyzed are prefixed by the field key
+	keyPrefix := []byte(index.FieldStr(fieldKey, nil))
+	start := append(append([]byte{}, keyPrefix...), prefix...)
+	dict, err := reader.DictionaryIterator(fieldKey.MarshalIndexRule(), nil, start, index.PrefixUpperBound(start))
+	if err != nil {
+		return err
+	}
+	entry, err := dict.Next()
+	for ; err == nil && entry != nil; entry, err = dict.Next() {
+		if err = visitor([]byte(entry.Term()[len(keyPrefix):])); err != nil {
+			break
+		}
+	}
+	return multierr.Append(err, dict.Close())
+}
+
+func searchPostings(reader *bluge.Reader, query bluge.Query, fk string, shouldDecodeTerm bool) (list posting.List, err error) {
 	documentMatchIterator, err := reader.Search(context.Background(), bluge.NewAllMatches(query))
 	if err != nil {
 		return nil, err
 	}
-	iter := newBlugeMatchIterator(documentMatchIterator, fk, false, reader)
+	iter := newBlugeMatchIterator(documentMatchIterator, fk, shouldDecodeTerm, reader)
 	defer func() {
 		err = multierr.Append(err, iter.Close())
 	}()
-	list := roaring.NewPostingList()
+	list = roaring.NewPostingList()
 	for iter.Next() {
 		err = multierr.Append(err, list.Union(iter.Val().Value))
 	}
@@ -286,6 +382,11 @@ func (s *store) Match(fieldKey index.FieldKey, matches []string) (posting.List,
 }
 
 func (s *store) Range(fieldKey index.FieldKey, opts index.RangeOpts) (list posting.List, err error) {
+	if fieldKey.Numeric {
+		if lo, hi, ok := numericBounds(opts.Lower, opts.Upper, opts.IncludesLower, opts.IncludesUpper); ok {
+			return s.numericRange(fieldKey, lo, hi)
+		}
+	}
 	iter, err := s.Iterator(fieldKey, opts, modelv1.Sort_SORT_ASC)
 	if err != nil {
 		return roaring.DummyPostingList, err
@@ -298,6 +399,30 @@ func (s *store) Range(fieldKey index.FieldKey, opts index.RangeOpts) (list posti
 	return
 }
 
+// numericRange looks up the terms of the numeric field covering the range at several precisions,
+// instead of scanning all the values in the range.
+func (s *store) numericRange(fieldKey index.FieldKey, lo, hi uint64) (posting.List, error) {
+	if lo > hi {
+		return roaring.NewPostingList(), nil
+	}
+	reader, err := s.writer.Reader()
+	if err != nil {
+		return nil, err
+	}
+	fk := fieldKey.MarshalIndexRule()
+	prefix := fieldKey.SeriesID.Marshal()
+	query := bluge.NewBooleanQuery()
+	splitRange(lo, hi, func(shift uint, from, to uint64) {
+		query.AddShould(bluge.NewTermRangeInclusiveQuery(
+			string(numericTerm(prefix, shift, from)),
+			string(numericTerm(prefix, shift, to)),
+			true,
+			true,
+		).SetField(fk + rangeFieldSuffix))
+	})
+	return searchPostings(reader, query, fk, true)
+}
+
 func (s *store) SizeOnDisk() int64 {
 	_, bytes := s.writer.DirectoryStats()
 	return int64(bytes)
@@ -345,6 +470,8 @@ func (s *store) run() {
 					close(d.onComplete)
 				case index.Document, index.Batch:
 					var docs []index.Document
+					var deletions []uint64
+					var seriesDeletions []index.SeriesDocID
 					var isBatch bool
 					var applied chan struct{}
 					switch v := d.(type) {
@@ -352,6 +479,8 @@ func (s *store) run() {
 						docs = []index.Document{v}
 					case index.Batch:
 						docs = v.Documents
+						deletions = v.Deletions
+						seriesDeletions = v.SeriesDeletions
 						applied = v.Applied
 						isBatch = true
 					}
@@ -372,10 +501,16 @@ func (s *store) run() {
 						for _, f := range d.Fields {
 							if f.Key.Analyzer == databasev1.IndexRule_ANALYZER_UNSPECIFIED {
 								doc.AddField(bluge.NewKeywordFieldBytes(f.Key.MarshalIndexRule(), f.Marshal()).StoreValue().Sortable())
+								if f.Key.Numeric && len(f.Term) == 8 {
+									for _, term := range numericTerms(f.Key.SeriesID.Marshal(), convert.BytesToUint64(f.Term)) {
+										doc.AddField(bluge.NewKeywordFieldBytes(f.Key.MarshalIndexRule()+rangeFieldSuffix, term))
+									}
+								}
 							} else {
 								toAddSeriesIDField = true
+								// the positions of the tokens are kept for the phrase queries
 								doc.AddField(bluge.NewKeywordFieldBytes(f.Key.MarshalIndexRule(), f.Term).StoreValue().Sortable().
-									WithAnalyzer(analyzers[f.Key.Analyzer]))
+									SearchTermPositions().WithAnalyzer(analyzers[f.Key.Analyzer]))
 							}
 						}
 						if fk != nil && toAddSeriesIDField && fk.HasSeriesID() {
@@ -389,6 +524,17 @@ func (s *store) run() {
 						size++
 						batch.Update(doc.ID(), doc)
 					}
+					for _, docID := range deletions {
+						size++
+						batch.Delete(bluge.Identifier(convert.Uint64ToBytes(docID)))
+					}
+					for _, d := range seriesDeletions {
+						size++
+						docIDBuffer.Reset()
+						docIDBuffer.Write(d.SeriesID.Marshal())
+						docIDBuffer.Write(convert.Uint64ToBytes(d.DocID))
+						batch.Delete(bluge.Identifier(docIDBuffer.String()))
+					}
 					if isBatch || size >= batchSize {
 						flush(applied)
 					}
@@ -19,13 +19,16 @@
 package inverted
 
 import (
+	"bytes"
 	"context"
+	"os"
 
 	"github.com/blugelabs/bluge"
 	"github.com/blugelabs/bluge/search"
 	"github.com/pkg/errors"
 
 	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/index"
 )
@@ -75,6 +78,112 @@ func (s *store) SearchPrefix(prefix []byte) ([]index.Series, error) {
 	return parseResult(dmi)
 }
 
+// Documents implements index.SeriesStore.
+func (s *store) Documents(analyzerOf func(indexRuleID uint32) databasev1.IndexRule_Analyzer, visitor func(index.Document) error) error {
+	reader, err := s.writer.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	dmi, err := reader.Search(context.Background(), bluge.NewAllMatches(bluge.NewMatchAllQuery()))
+	if err != nil {
+		return err
+	}
+	return visitDocuments(dmi, analyzerOf, visitor)
+}
+
+// VisitSeriesDocuments implements index.SeriesDocumentVisitor.
+func (s *store) VisitSeriesDocuments(seriesID common.SeriesID, minDocID, maxDocID uint64,
+	analyzerOf func(indexRuleID uint32) databasev1.IndexRule_Analyzer, visitor func(index.Document) error,
+) error {
+	reader, err := s.writer.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	// the ID of a document keyed by its series is the series id followed by the document id, both of which are big-endian
+	prefix := seriesID.Marshal()
+	query := bluge.NewTermRangeInclusiveQuery(
+		string(append(bytes.Clone(prefix), convert.Uint64ToBytes(minDocID)...)),
+		string(append(bytes.Clone(prefix), convert.Uint64ToBytes(maxDocID)...)),
+		true, true).SetField(docIDField)
+	dmi, err := reader.Search(context.Background(), bluge.NewAllMatches(query))
+	if err != nil {
+		return err
+	}
+	return visitDocuments(dmi, analyzerOf, func(doc index.Document) error {
+		for i := range doc.Fields {
+			doc.Fields[i].Key.SeriesID = seriesID
+		}
+		return visitor(doc)
+	})
+}
+
+func visitDocuments(dmi search.DocumentMatchIterator, analyzerOf func(indexRuleID uint32) databasev1.IndexRule_Analyzer,
+	visitor func(index.Document) error,
+) error {
+	next, err := dmi.Next()
+	for err == nil && next != nil {
+		var doc index.Document
+		var errField error
+		err = next.VisitStoredFields(func(field string, value []byte) bool {
+			switch field {
+			case docIDField:
+				// the ID of a document keyed by its series is prefixed by the series id
+				if len(value) == 16 {
+					value = value[8:]
+				}
+				doc.DocID = convert.BytesToUint64(value)
+			case entityField:
+				doc.EntityValues = bytes.Clone(value)
+			default:
+				f := index.Field{Key: index.FieldKey{IndexRuleID: convert.BytesToUint32([]byte(field))}}
+				f.Key.Analyzer = analyzerOf(f.Key.IndexRuleID)
+				if f.Key.Analyzer == databasev1.IndexRule_ANALYZER_UNSPECIFIED {
+					// the stored value of a keyword is the marshaled field
+					if len(value) < 12 {
+						errField = errors.Errorf("malformed field %x: expected at least 12 bytes, got %d", field, len(value))
+						return false
+					}
+					f.Term = index.UnmarshalTerm(value)
+				} else {
+					f.Term = bytes.Clone(value)
+				}
+				doc.Fields = append(doc.Fields, f)
+			}
+			return true
+		})
+		if err == nil {
+			err = errField
+		}
+		if err != nil {
+			return errors.WithMessage(err, "visit stored fields")
+		}
+		if err = visitor(doc); err != nil {
+			return err
+		}
+		next, err = dmi.Next()
+	}
+	if err != nil {
+		return errors.WithMessage(err, "iterate document match iterator")
+	}
+	return nil
+}
+
+// Archive implements index.Archiver. The documents which aren't persisted yet are archived as well.
+func (s *store) Archive(dir string) error {
+	s.flush()
+	reader, err := s.writer.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	return reader.Backup(dir, nil)
+}
+
 // SearchWildcard implements index.SeriesStore.
 func (s *store) SearchWildcard(wildcard []byte) ([]index.Series, error) {
 	reader, err := s.writer.Reader()
@@ -104,7 +213,7 @@ func parseResult(dmi search.DocumentMatchIterator) ([]index.Series, error) {
 				}
 			}
 			if field == entityField {
-				series.EntityValues = value
+				series.EntityValues = bytes.Clone(value)
 			}
 			return true
 		})
@@ -18,12 +18,14 @@
 package inverted
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/apache/skywalking-banyandb/api/common"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	"github.com/apache/skywalking-banyandb/pkg/index"
 	"github.com/apache/skywalking-banyandb/pkg/logger"
 )
@@ -192,6 +194,53 @@ func TestStore_SearchPrefix(t *testing.T) {
 	}
 }
 
+func TestStore_Documents(t *testing.T) {
+	tester := assert.New(t)
+	path, fn := setUp(require.New(t))
+	defer fn()
+	s, err := NewStore(StoreOpts{
+		Path:   filepath.Join(path, "src"),
+		Logger: logger.GetLogger("test"),
+	})
+	require.NoError(t, err)
+	keyword := index.Field{Key: index.FieldKey{IndexRuleID: 10}, Term: []byte("value")}
+	analyzed := index.Field{Key: index.FieldKey{IndexRuleID: 11, Analyzer: databasev1.IndexRule_ANALYZER_SIMPLE}, Term: []byte("hello world")}
+	docs := index.Documents{
+		{DocID: 1, EntityValues: []byte("test1")},
+		{DocID: 2, EntityValues: []byte("test2"), Fields: []index.Field{keyword, analyzed}},
+	}
+	applied := make(chan struct{})
+	require.NoError(t, s.Batch(index.Batch{Applied: applied, Documents: docs}))
+	<-applied
+	analyzerOf := func(indexRuleID uint32) databasev1.IndexRule_Analyzer {
+		if indexRuleID == analyzed.Key.IndexRuleID {
+			return analyzed.Key.Analyzer
+		}
+		return databasev1.IndexRule_ANALYZER_UNSPECIFIED
+	}
+	var got index.Documents
+	tester.NoError(s.Documents(analyzerOf, func(doc index.Document) error {
+		got = append(got, doc)
+		return nil
+	}))
+	tester.ElementsMatch(docs, got)
+
+	archived := filepath.Join(path, "archived")
+	require.NoError(t, s.(index.Archiver).Archive(archived))
+	tester.NoError(s.Close())
+	s, err = NewStore(StoreOpts{
+		Path:   archived,
+		Logger: logger.GetLogger("test"),
+	})
+	require.NoError(t, err)
+	defer func() {
+		tester.NoError(s.Close())
+	}()
+	id, err := s.Search([]byte("test2"))
+	tester.NoError(err)
+	tester.Equal(common.SeriesID(2), id, "the archive is opened as a store")
+}
+
 func setupData(tester *assert.Assertions, s index.SeriesStore) {
 	series1 := index.Document{
 		DocID:        1,
@@ -18,6 +18,7 @@
 package inverted
 
 import (
+	"math"
 	"strings"
 	"testing"
 
@@ -26,6 +27,7 @@ import (
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/index"
 	"github.com/apache/skywalking-banyandb/pkg/index/posting"
 	"github.com/apache/skywalking-banyandb/pkg/index/posting/roaring"
@@ -189,6 +191,368 @@ func TestStore_SeriesMatch(t *testing.T) {
 	}
 }
 
+func TestStore_MatchPhrase(t *testing.T) {
+	tester := assert.New(t)
+	path, fn := setUp(require.New(t))
+	s, err := NewStore(StoreOpts{
+		Path:   path,
+		Logger: logger.GetLogger("test"),
+	})
+	tester.NoError(err)
+	defer func() {
+		tester.NoError(s.Close())
+		fn()
+	}()
+	serviceName := index.FieldKey{
+		// http_method
+		IndexRuleID: 6,
+		SeriesID:    common.SeriesID(11),
+		Analyzer:    databasev1.IndexRule_ANALYZER_SIMPLE,
+	}
+	setup(tester, s, serviceName, index.FieldKey{
+		// http_method
+		IndexRuleID: 6,
+		SeriesID:    common.SeriesID(1),
+		Analyzer:    databasev1.IndexRule_ANALYZER_SIMPLE,
+	})
+
+	tests := []struct {
+		want    posting.List
+		phrases []string
+	}{
+		{
+			phrases: []string{"product order"},
+			want:    roaring.NewPostingListWithInitialData(1),
+		},
+		{
+			phrases: []string{"/root/product"},
+			want:    roaring.NewPostingListWithInitialData(2),
+		},
+		{
+			phrases: []string{"product root"},
+			want:    roaring.NewPostingListWithInitialData(),
+		},
+		{
+			phrases: []string{"OrderService.order"},
+			want:    roaring.NewPostingListWithInitialData(3),
+		},
+		{
+			phrases: []string{"GET product", "product order"},
+			want:    roaring.NewPostingListWithInitialData(1),
+		},
+		{
+			phrases: []string{"GET order"},
+			want:    roaring.NewPostingListWithInitialData(),
+		},
+	}
+	for _, tt := range tests {
+		name := strings.Join(tt.phrases, " and ")
+		t.Run(name, func(t *testing.T) {
+			list, err := s.MatchPhrase(serviceName, tt.phrases)
+			require.NoError(t, err)
+			require.NotNil(t, list)
+			assert.Equal(t, tt.want, list)
+		})
+	}
+}
+
+func TestStore_MatchCJK(t *testing.T) {
+	tester := assert.New(t)
+	path, fn := setUp(require.New(t))
+	s, err := NewStore(StoreOpts{
+		Path:   path,
+		Logger: logger.GetLogger("test"),
+	})
+	tester.NoError(err)
+	defer func() {
+		tester.NoError(s.Close())
+		fn()
+	}()
+	message := index.FieldKey{
+		IndexRuleID: 7,
+		SeriesID:    common.SeriesID(11),
+		Analyzer:    databasev1.IndexRule_ANALYZER_CJK,
+	}
+	for i, term := range []string{"数据库连接超时", "连接数据库失败", "Database Timeout"} {
+		tester.NoError(s.Write([]index.Field{{
+			Key:  message,
+			Term: []byte(term),
+		}}, uint64(i+1)))
+	}
+	s.(*store).flush()
+
+	tests := []struct {
+		want   posting.List
+		query  string
+		phrase bool
+	}{
+		{
+			query: "数据库",
+			want:  roaring.NewPostingListWithInitialData(1, 2),
+		},
+		{
+			query: "超时",
+			want:  roaring.NewPostingListWithInitialData(1),
+		},
+		{
+			query: "timeout",
+			want:  roaring.NewPostingListWithInitialData(3),
+		},
+		{
+			query:  "连接超时",
+			phrase: true,
+			want:   roaring.NewPostingListWithInitialData(1),
+		},
+		{
+			query:  "连接数据",
+			phrase: true,
+			want:   roaring.NewPostingListWithInitialData(2),
+		},
+		{
+			query:  "超时连接",
+			phrase: true,
+			want:   roaring.NewPostingListWithInitialData(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var list posting.List
+			var err error
+			if tt.phrase {
+				list, err = s.MatchPhrase(message, []string{tt.query})
+			} else {
+				list, err = s.Match(message, []string{tt.query})
+			}
+			require.NoError(t, err)
+			require.NotNil(t, list)
+			assert.Equal(t, tt.want, list)
+		})
+	}
+}
+
+func TestStore_MatchPattern(t *testing.T) {
+	tester := assert.New(t)
+	path, fn := setUp(require.New(t))
+	s, err := NewStore(StoreOpts{
+		Path:   path,
+		Logger: logger.GetLogger("test"),
+	})
+	tester.NoError(err)
+	defer func() {
+		tester.NoError(s.Close())
+		fn()
+	}()
+	analyzed := index.FieldKey{
+		// http_method
+		IndexRuleID: 6,
+		SeriesID:    common.SeriesID(11),
+		Analyzer:    databasev1.IndexRule_ANALYZER_SIMPLE,
+	}
+	setup(tester, s, analyzed, index.FieldKey{
+		// http_method
+		IndexRuleID: 6,
+		SeriesID:    common.SeriesID(1),
+		Analyzer:    databasev1.IndexRule_ANALYZER_SIMPLE,
+	})
+	// the documents are identified by the series and the item ids, so the series differs from the analyzed one
+	endpoint := index.FieldKey{
+		IndexRuleID: 8,
+		SeriesID:    common.SeriesID(12),
+	}
+	for i, term := range []string{"GET::/product/order", "GET::/root/product", "POST::/product"} {
+		tester.NoError(s.Write([]index.Field{{
+			Key:  endpoint,
+			Term: []byte(term),
+		}}, uint64(i+1)))
+	}
+	s.(*store).flush()
+
+	tests := []struct {
+		want     posting.List
+		expr     string
+		key      index.FieldKey
+		wildcard bool
+	}{
+		{
+			key:  endpoint,
+			expr: "GET::/.*",
+			want: roaring.NewPostingListWithInitialData(1, 2),
+		},
+		{
+			key:  endpoint,
+			expr: ".*product",
+			want: roaring.NewPostingListWithInitialData(2, 3),
+		},
+		{
+			key:      endpoint,
+			expr:     "*/product*",
+			wildcard: true,
+			want:     roaring.NewPostingListWithInitialData(1, 2, 3),
+		},
+		{
+			key:      endpoint,
+			expr:     "GET::/?oot/*",
+			wildcard: true,
+			want:     roaring.NewPostingListWithInitialData(2),
+		},
+		{
+			key:      endpoint,
+			expr:     "PUT*",
+			wildcard: true,
+			want:     roaring.NewPostingListWithInitialData(),
+		},
+		{
+			key:  analyzed,
+			expr: "order.*",
+			want: roaring.NewPostingListWithInitialData(1, 3),
+		},
+		{
+			key:      analyzed,
+			expr:     "r??t",
+			wildcard: true,
+			want:     roaring.NewPostingListWithInitialData(2),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			var pattern *index.Pattern
+			var err error
+			if tt.wildcard {
+				pattern, err = index.NewWildcardPattern(tt.expr)
+			} else {
+				pattern, err = index.NewRegexPattern(tt.expr)
+			}
+			require.NoError(t, err)
+			list, err := s.MatchPattern(tt.key, pattern)
+			require.NoError(t, err)
+			require.NotNil(t, list)
+			assert.Equal(t, tt.want, list)
+		})
+	}
+}
+
+func TestStore_VisitTerms(t *testing.T) {
+	tester := assert.New(t)
+	path, fn := setUp(require.New(t))
+	s, err := NewStore(StoreOpts{
+		Path:   path,
+		Logger: logger.GetLogger("test"),
+	})
+	tester.NoError(err)
+	defer func() {
+		tester.NoError(s.Close())
+		fn()
+	}()
+	endpoint := index.FieldKey{
+		IndexRuleID: 8,
+		SeriesID:    common.SeriesID(12),
+	}
+	for i, term := range []string{"POST::/product", "GET::/root/product", "GET::/product/order", "GET::/product/order"} {
+		tester.NoError(s.Write([]index.Field{{
+			Key:  endpoint,
+			Term: []byte(term),
+		}}, uint64(i+1)))
+	}
+	other := endpoint
+	other.SeriesID = 13
+	tester.NoError(s.Write([]index.Field{{Key: other, Term: []byte("GET::/other")}}, 1))
+	s.(*store).flush()
+
+	visit := func(key index.FieldKey, prefix string) []string {
+		var terms []string
+		require.NoError(t, s.(index.TermVisitor).VisitTerms(key, []byte(prefix), func(term []byte) error {
+			terms = append(terms, string(term))
+			return nil
+		}))
+		return terms
+	}
+	tester.Equal([]string{"GET::/product/order", "GET::/root/product", "POST::/product"}, visit(endpoint, ""))
+	tester.Equal([]string{"GET::/product/order", "GET::/root/product"}, visit(endpoint, "GET"))
+	tester.Equal([]string{"GET::/other"}, visit(other, ""))
+	tester.Empty(visit(endpoint, "PUT"))
+}
+
+func TestStore_NumericRange(t *testing.T) {
+	tester := assert.New(t)
+	path, fn := setUp(require.New(t))
+	s, err := NewStore(StoreOpts{
+		Path:   path,
+		Logger: logger.GetLogger("test"),
+	})
+	tester.NoError(err)
+	defer func() {
+		tester.NoError(s.Close())
+		fn()
+	}()
+	latency := index.FieldKey{
+		IndexRuleID: 9,
+		SeriesID:    common.SeriesID(11),
+		Numeric:     true,
+	}
+	values := []int64{math.MinInt64, -70000, -5, 0, 3, 255, 256, 1000, 70000, math.MaxInt64}
+	for i, v := range values {
+		tester.NoError(s.Write([]index.Field{{
+			Key:  latency,
+			Term: convert.Int64ToBytes(v),
+		}}, uint64(i+1)))
+	}
+	s.(*store).flush()
+
+	bound := func(v int64) []byte {
+		return convert.Int64ToBytes(v)
+	}
+	tests := []struct {
+		name string
+		opts index.RangeOpts
+		in   func(v int64) bool
+	}{
+		{
+			name: "[-5, 256]",
+			opts: index.RangeOpts{Lower: bound(-5), Upper: bound(256), IncludesLower: true, IncludesUpper: true},
+			in:   func(v int64) bool { return v >= -5 && v <= 256 },
+		},
+		{
+			name: "(0, 1000)",
+			opts: index.RangeOpts{Lower: bound(0), Upper: bound(1000)},
+			in:   func(v int64) bool { return v > 0 && v < 1000 },
+		},
+		{
+			name: "(-inf, 0]",
+			opts: index.RangeOpts{Upper: bound(0), IncludesUpper: true},
+			in:   func(v int64) bool { return v <= 0 },
+		},
+		{
+			name: "(256, +inf)",
+			opts: index.RangeOpts{Lower: bound(256)},
+			in:   func(v int64) bool { return v > 256 },
+		},
+		{
+			name: "[1, 2]",
+			opts: index.RangeOpts{Lower: bound(1), Upper: bound(2), IncludesLower: true, IncludesUpper: true},
+			in:   func(int64) bool { return false },
+		},
+		{
+			name: "all",
+			opts: index.RangeOpts{},
+			in:   func(int64) bool { return true },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := roaring.NewPostingList()
+			for i, v := range values {
+				if tt.in(v) {
+					want.Insert(uint64(i + 1))
+				}
+			}
+			list, err := s.Range(latency, tt.opts)
+			require.NoError(t, err)
+			require.NotNil(t, list)
+			assert.Equal(t, want.ToSlice(), list.ToSlice())
+		})
+	}
+}
+
 func setup(tester *assert.Assertions, s index.Store, serviceName, serviceName1 index.FieldKey) {
 	tester.NoError(s.Write([]index.Field{{
 		Key:  serviceName,
@@ -0,0 +1,117 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package inverted
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// rangeStep is the bits a precision level of a numeric term drops from the previous one.
+// A value is indexed as 64/rangeStep terms, and a range is covered by 2*(2^rangeStep-1) terms per level at most.
+const rangeStep = 8
+
+// rangeFieldSuffix makes the field of the numeric terms apart from the field of the values.
+const rangeFieldSuffix = "#r"
+
+// numericTerms returns the terms of a value at all the precision levels.
+// Each term is the prefix followed by the level and the value dropping the low bits of the level.
+func numericTerms(prefix []byte, value uint64) [][]byte {
+	terms := make([][]byte, 0, 64/rangeStep)
+	for shift := uint(0); shift < 64; shift += rangeStep {
+		terms = append(terms, numericTerm(prefix, shift, value>>shift))
+	}
+	return terms
+}
+
+// numericTerm encodes a value shifted by shift bits in the least bytes keeping its order.
+func numericTerm(prefix []byte, shift uint, shifted uint64) []byte {
+	n := (64 - shift + 7) / 8
+	term := make([]byte, len(prefix)+1+int(n))
+	copy(term, prefix)
+	term[len(prefix)] = byte(shift)
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], shifted)
+	copy(term[len(prefix)+1:], b[8-n:])
+	return term
+}
+
+// splitRange splits the inclusive range [lo, hi] into the fewest ranges of the shifted values at each level,
+// whose union is the range. visit receives each range by its shift and its shifted bounds.
+func splitRange(lo, hi uint64, visit func(shift uint, lo, hi uint64)) {
+	if lo > hi {
+		return
+	}
+	const low = 1<<rangeStep - 1
+	for shift := uint(0); ; shift += rangeStep {
+		if shift+rangeStep >= 64 {
+			visit(shift, lo, hi)
+			return
+		}
+		// the values at the lower edge which don't fill a range of the next level
+		if lo&low != 0 {
+			end := lo | low
+			if end >= hi {
+				visit(shift, lo, hi)
+				return
+			}
+			visit(shift, lo, end)
+			lo = end + 1
+		}
+		// the values at the upper edge likewise
+		if hi&low != low {
+			start := hi &^ low
+			if start <= lo {
+				visit(shift, lo, hi)
+				return
+			}
+			visit(shift, start, hi)
+			hi = start - 1
+		}
+		lo >>= rangeStep
+		hi >>= rangeStep
+	}
+}
+
+// numericBounds returns the inclusive bounds of the range of the encoded int64 values, where lo > hi if the range is empty.
+// It returns false if the bounds aren't encoded int64 values.
+func numericBounds(lower, upper []byte, includesLower, includesUpper bool) (lo, hi uint64, ok bool) {
+	if lower != nil && len(lower) != 8 || upper != nil && len(upper) != 8 {
+		return 0, 0, false
+	}
+	lo, hi = 0, math.MaxUint64
+	if lower != nil {
+		lo = binary.BigEndian.Uint64(lower)
+		if !includesLower {
+			if lo == math.MaxUint64 {
+				return 1, 0, true
+			}
+			lo++
+		}
+	}
+	if upper != nil {
+		hi = binary.BigEndian.Uint64(upper)
+		if !includesUpper {
+			if hi == 0 {
+				return 1, 0, true
+			}
+			hi--
+		}
+	}
+	return lo, hi, true
+}
@@ -62,6 +62,22 @@ func (s *store) Range(fieldKey index.FieldKey, opts index.RangeOpts) (list posti
 	return
 }
 
+// MatchPattern scans the terms starting with the literal prefix of the pattern, and collects the items of the matching ones.
+func (s *store) MatchPattern(fieldKey index.FieldKey, pattern *index.Pattern) (list posting.List, err error) {
+	iter, err := s.Iterator(fieldKey, pattern.Range(), modelv1.Sort_SORT_ASC)
+	if err != nil {
+		return roaring.DummyPostingList, err
+	}
+	list = roaring.NewPostingList()
+	for iter.Next() {
+		if pattern.Match(iter.Val().Term) {
+			err = multierr.Append(err, list.Union(iter.Val().Value))
+		}
+	}
+	err = multierr.Append(err, iter.Close())
+	return
+}
+
 func (s *store) Iterator(fieldKey index.FieldKey, termRange index.RangeOpts, order modelv1.Sort) (index.FieldIterator, error) {
 	if !s.closer.AddRunning() {
 		return nil, errors.New("lsm index store is closed")
@@ -98,3 +114,7 @@ func (s *store) Iterator(fieldKey index.FieldKey, termRange index.RangeOpts, ord
 func (s *store) Match(_ index.FieldKey, _ []string) (posting.List, error) {
 	return nil, errors.WithMessage(errUnsupportedOperation, "LSM-Tree index doesn't support full-text searching")
 }
+
+func (s *store) MatchPhrase(_ index.FieldKey, _ []string) (posting.List, error) {
+	return nil, errors.WithMessage(errUnsupportedOperation, "LSM-Tree index doesn't support full-text searching")
+}
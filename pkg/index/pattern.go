@@ -0,0 +1,122 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package index
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var errInvalidPattern = errors.New("invalid pattern")
+
+// Pattern is a regular expression or a wildcard expression a term has to match as a whole.
+// The terms matching it share its literal prefix, which narrows the terms to scan.
+type Pattern struct {
+	re     *regexp.Regexp
+	expr   string
+	prefix []byte
+}
+
+// NewRegexPattern returns a Pattern matching the terms by the regular expression in the RE2 syntax.
+func NewRegexPattern(expr string) (*Pattern, error) {
+	re, err := regexp.Compile(`^(?:` + expr + `)$`)
+	if err != nil {
+		return nil, errors.WithMessagef(errInvalidPattern, "regex %q: %v", expr, err)
+	}
+	prefix, _ := re.LiteralPrefix()
+	return &Pattern{re: re, expr: expr, prefix: []byte(prefix)}, nil
+}
+
+// NewWildcardPattern returns a Pattern matching the terms by the wildcard expression,
+// in which "*" matches any sequence of characters, "?" matches a character and "\" escapes the next one.
+func NewWildcardPattern(expr string) (*Pattern, error) {
+	var b strings.Builder
+	var literal strings.Builder
+	escaped := false
+	for _, r := range expr {
+		switch {
+		case escaped:
+			literal.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*' || r == '?':
+			b.WriteString(regexp.QuoteMeta(literal.String()))
+			literal.Reset()
+			if r == '*' {
+				b.WriteString(`.*`)
+			} else {
+				b.WriteString(`.`)
+			}
+		default:
+			literal.WriteRune(r)
+		}
+	}
+	if escaped {
+		return nil, errors.WithMessagef(errInvalidPattern, "wildcard %q ends with an escape", expr)
+	}
+	b.WriteString(regexp.QuoteMeta(literal.String()))
+	re, err := regexp.Compile(`^(?s:` + b.String() + `)$`)
+	if err != nil {
+		return nil, errors.WithMessagef(errInvalidPattern, "wildcard %q: %v", expr, err)
+	}
+	prefix, _ := re.LiteralPrefix()
+	return &Pattern{re: re, expr: expr, prefix: []byte(prefix)}, nil
+}
+
+// Match reports whether the whole term matches the pattern.
+func (p *Pattern) Match(term []byte) bool {
+	return p.re.Match(term)
+}
+
+// Prefix returns the literal prefix of the terms matching the pattern, which might be empty.
+func (p *Pattern) Prefix() []byte {
+	return p.prefix
+}
+
+// Range returns the range of the terms starting with the prefix, out of which no term matches the pattern.
+func (p *Pattern) Range() RangeOpts {
+	if len(p.prefix) == 0 {
+		return RangeOpts{}
+	}
+	return RangeOpts{
+		Lower:         p.prefix,
+		IncludesLower: true,
+		Upper:         PrefixUpperBound(p.prefix),
+	}
+}
+
+func (p *Pattern) String() string {
+	return p.expr
+}
+
+// PrefixUpperBound returns the least key greater than all the keys starting with prefix,
+// or nil if there's no such key.
+func PrefixUpperBound(prefix []byte) []byte {
+	upper := make([]byte, len(prefix))
+	copy(upper, prefix)
+	for i := len(upper) - 1; i >= 0; i-- {
+		if upper[i] < 0xff {
+			upper[i]++
+			return upper[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package intern shares a single copy of the identical strings, such as the names of tags and tag families.
+package intern
+
+import (
+	"strings"
+	"sync"
+)
+
+const defaultMaxSize = 4096
+
+var defaultTable = NewTable(defaultMaxSize)
+
+// Bytes returns the interned string equal to b from the table of the process.
+func Bytes(b []byte) string {
+	return defaultTable.Bytes(b)
+}
+
+// String returns the interned string equal to s from the table of the process.
+func String(s string) string {
+	return defaultTable.String(s)
+}
+
+// Rotate starts a new generation of the table of the process if the schema revision is newer.
+func Rotate(revision int64) {
+	defaultTable.Rotate(revision)
+}
+
+// Reset drops all the strings of the table of the process.
+func Reset() {
+	defaultTable.Reset()
+}
+
+// Table is a concurrent intern table evicting the strings by generations.
+// The strings are added to the current generation. Once it's full or the schemas change,
+// it becomes the previous generation, whose strings are dropped unless they're used again
+// before the next rotation. So the table holds at most twice of maxSize strings.
+type Table struct {
+	cur      map[string]string
+	prev     map[string]string
+	maxSize  int
+	revision int64
+	mu       sync.RWMutex
+}
+
+// NewTable returns a Table whose generation holds at most maxSize strings.
+func NewTable(maxSize int) *Table {
+	return &Table{
+		cur:     make(map[string]string),
+		maxSize: maxSize,
+	}
+}
+
+// Bytes returns the interned string equal to b.
+func (t *Table) Bytes(b []byte) string {
+	t.mu.RLock()
+	// the conversion doesn't allocate when looking up a map
+	s, ok := t.cur[string(b)]
+	t.mu.RUnlock()
+	if ok {
+		return s
+	}
+	return t.add(string(b))
+}
+
+// String returns the interned string equal to s.
+// s is cloned before being added, so that the table doesn't refer to the memory s refers to.
+func (t *Table) String(s string) string {
+	t.mu.RLock()
+	interned, ok := t.cur[s]
+	t.mu.RUnlock()
+	if ok {
+		return interned
+	}
+	return t.add(strings.Clone(s))
+}
+
+func (t *Table) add(s string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if interned, ok := t.cur[s]; ok {
+		return interned
+	}
+	if interned, ok := t.prev[s]; ok {
+		s = interned
+	}
+	if len(t.cur) >= t.maxSize {
+		t.rotate()
+	}
+	t.cur[s] = s
+	return s
+}
+
+// Rotate starts a new generation if the schema revision is newer than the last one.
+func (t *Table) Rotate(revision int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if revision <= t.revision {
+		return
+	}
+	t.revision = revision
+	t.rotate()
+}
+
+func (t *Table) rotate() {
+	t.prev = t.cur
+	t.cur = make(map[string]string, len(t.prev))
+}
+
+// Reset drops the strings of both generations.
+func (t *Table) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prev = nil
+	t.cur = make(map[string]string)
+}
+
+// Len returns the number of entries in both generations.
+func (t *Table) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.cur) + len(t.prev)
+}
@@ -0,0 +1,102 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package intern
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTable(t *testing.T) {
+	table := NewTable(2)
+	a := table.Bytes([]byte("a"))
+	assert.Equal(t, "a", a)
+	assert.True(t, same(a, table.String("a")))
+	assert.True(t, same(a, table.Bytes([]byte("a"))))
+
+	table.String("b")
+	// the generation is full, so "c" rotates it
+	table.String("c")
+	assert.True(t, same(a, table.String("a")), "a string of the previous generation is promoted")
+	table.String("d")
+	table.String("e")
+	table.String("f")
+	assert.LessOrEqual(t, table.Len(), 4)
+	assert.False(t, same(a, table.String("a")), "a string unused for two generations is dropped")
+}
+
+func TestTableRotate(t *testing.T) {
+	table := NewTable(10)
+	a := table.String("a")
+	table.Rotate(1)
+	table.Rotate(1)
+	assert.True(t, same(a, table.String("a")), "the same revision doesn't rotate again")
+	table.Rotate(2)
+	table.Rotate(3)
+	assert.False(t, same(a, table.String("a")))
+}
+
+func TestTableReset(t *testing.T) {
+	table := NewTable(10)
+	a := table.String("a")
+	table.Reset()
+	assert.Zero(t, table.Len())
+	assert.False(t, same(a, table.String("a")))
+}
+
+func TestTableConcurrency(t *testing.T) {
+	table := NewTable(16)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				name := "tag" + strconv.Itoa(j%32)
+				assert.Equal(t, name, table.Bytes([]byte(name)))
+				assert.Equal(t, name, table.String(name))
+				if j%100 == 0 {
+					table.Rotate(int64(i*1000 + j))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, table.Len(), 32)
+}
+
+func BenchmarkTableBytes(b *testing.B) {
+	table := NewTable(defaultMaxSize)
+	names := make([][]byte, 32)
+	for i := range names {
+		names[i] = []byte("tag_family.tag" + strconv.Itoa(i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = table.Bytes(names[i%len(names)])
+	}
+}
+
+func same(a, b string) bool {
+	return unsafe.StringData(a) == unsafe.StringData(b)
+}
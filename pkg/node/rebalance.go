@@ -0,0 +1,482 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package node
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+var _ RebalanceSelector = (*rebalanceSelector)(nil)
+
+// ShardKey identifies a shard of a resource.
+type ShardKey struct {
+	Group   string
+	Name    string
+	ShardID uint32
+}
+
+func (k ShardKey) String() string {
+	return formatSearchKey(k.Group, k.Name, k.ShardID)
+}
+
+// MoveState is the state of a shard move.
+type MoveState int
+
+// MoveState values.
+const (
+	// MoveStateCopying means parts are being copied to the new owner while writes go to both owners.
+	MoveStateCopying MoveState = iota
+	// MoveStateCatchingUp means all pinned parts are copied, and the move waits for the lag to fall into the bound.
+	MoveStateCatchingUp
+	// MoveStatePlanned means the move is planned but not started.
+	MoveStatePlanned
+)
+
+func (s MoveState) String() string {
+	switch s {
+	case MoveStateCopying:
+		return "copying"
+	case MoveStateCatchingUp:
+		return "catching-up"
+	case MoveStatePlanned:
+		return "planned"
+	}
+	return "unknown"
+}
+
+// Move describes a shard which is being transferred from one node to another.
+type Move struct {
+	StartedAt   time.Time
+	Key         ShardKey
+	From        string
+	To          string
+	State       MoveState
+	Lag         time.Duration
+	CopiedParts uint32
+	TotalParts  uint32
+}
+
+// Assignment is the owner of a shard and the in-flight move if exists.
+type Assignment struct {
+	Move *Move
+	Key  ShardKey
+	Node string
+}
+
+// ShardCopier copies the data of a shard to its new owner.
+//
+// The implementation should pin a snapshot on the source node, copy its parts
+// one by one and call report after each part. The lag is the age of the newest
+// data which has not reached the target yet.
+type ShardCopier interface {
+	CopyShard(ctx context.Context, move Move, report func(copiedParts, totalParts uint32, lag time.Duration)) error
+}
+
+// RebalanceSelector is a Selector which moves shards between nodes when the membership changes.
+type RebalanceSelector interface {
+	Selector
+	run.Config
+	run.Service
+	// PickAll returns all nodes which should receive the writes of a shard.
+	// A shard in moving has two owners: the current one and the target.
+	PickAll(group, name string, shardID uint32) ([]string, error)
+	// Assignments returns current assignments sorted by the shard key.
+	Assignments() []Assignment
+	// Plan returns the moves balancing the shards without starting them, which are sorted by the shard key.
+	// The moves are started shard-rebalance-max-moves at a time, so the in-flight ones aren't included.
+	Plan() []Move
+	// ReportProgress updates the progress of the move of a shard.
+	// It returns true if the routing is flipped to the new owner.
+	ReportProgress(key ShardKey, copiedParts, totalParts uint32, lag time.Duration) bool
+	// SetCopier sets the copier to transfer data of moved shards.
+	// No move is started until the copier is set, since the historical data would be left on the old owners.
+	SetCopier(copier ShardCopier)
+}
+
+type rebalanceSelector struct {
+	copier      ShardCopier
+	ctx         context.Context
+	l           *logger.Logger
+	assignments map[ShardKey]string
+	moves       map[ShardKey]*Move
+	nodes       map[string]struct{}
+	cancel      context.CancelFunc
+	maxMoves    int
+	lagBound    time.Duration
+	// copyRetries is the number of the retries of a failed copy before the move is abandoned,
+	// and copyBackoff the wait before the first one, which doubles on each retry.
+	copyRetries int
+	copyBackoff time.Duration
+	mu          sync.RWMutex
+}
+
+// NewRebalanceSelector returns a selector which keeps shard→node assignments
+// and rebalances them when nodes join or leave.
+func NewRebalanceSelector() RebalanceSelector {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &rebalanceSelector{
+		assignments: make(map[ShardKey]string),
+		moves:       make(map[ShardKey]*Move),
+		nodes:       make(map[string]struct{}),
+		maxMoves:    1,
+		lagBound:    time.Minute,
+		copyRetries: 3,
+		copyBackoff: time.Second,
+		ctx:         ctx,
+		cancel:      cancel,
+		l:           logger.GetLogger("shard-rebalance"),
+	}
+}
+
+func (r *rebalanceSelector) Name() string {
+	return "shard-rebalance"
+}
+
+func (r *rebalanceSelector) FlagSet() *run.FlagSet {
+	fs := run.NewFlagSet("shard-rebalance")
+	fs.IntVar(&r.maxMoves, "shard-rebalance-max-moves", 1, "the maximum number of shards moving at the same time")
+	fs.DurationVar(&r.lagBound, "shard-rebalance-lag-bound", time.Minute,
+		"the maximum lag of a moved shard's new owner before the routing is flipped")
+	return fs
+}
+
+func (r *rebalanceSelector) Validate() error {
+	if r.maxMoves < 1 {
+		r.maxMoves = 1
+	}
+	return nil
+}
+
+func (r *rebalanceSelector) Serve() run.StopNotify {
+	return r.ctx.Done()
+}
+
+func (r *rebalanceSelector) GracefulStop() {
+	r.cancel()
+}
+
+func (r *rebalanceSelector) SetCopier(copier ShardCopier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.copier = copier
+	r.rebalance()
+}
+
+func (r *rebalanceSelector) AddNode(node *databasev1.Node) {
+	nodeID := node.GetMetadata().GetName()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.nodes[nodeID]; ok {
+		return
+	}
+	r.nodes[nodeID] = struct{}{}
+	r.rebalance()
+}
+
+func (r *rebalanceSelector) RemoveNode(node *databasev1.Node) {
+	nodeID := node.GetMetadata().GetName()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.nodes[nodeID]; !ok {
+		return
+	}
+	delete(r.nodes, nodeID)
+	for k, m := range r.moves {
+		switch nodeID {
+		case m.To:
+			delete(r.moves, k)
+		case m.From:
+			// The source is gone, the target owns whatever it has received.
+			delete(r.moves, k)
+			r.assignments[k] = m.To
+		}
+	}
+	for k, n := range r.assignments {
+		if n != nodeID {
+			continue
+		}
+		// There is no source to copy from, reassign the shard right away.
+		if len(r.nodes) == 0 {
+			delete(r.assignments, k)
+			continue
+		}
+		r.assignments[k] = r.leastLoaded()
+	}
+	r.rebalance()
+}
+
+func (r *rebalanceSelector) Pick(group, name string, shardID uint32) (string, error) {
+	k := ShardKey{Group: group, Name: name, ShardID: shardID}
+	r.mu.RLock()
+	n, ok := r.assignments[k]
+	r.mu.RUnlock()
+	if ok {
+		return n, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.assign(k)
+}
+
+func (r *rebalanceSelector) PickAll(group, name string, shardID uint32) ([]string, error) {
+	n, err := r.Pick(group, name, shardID)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if m, ok := r.moves[ShardKey{Group: group, Name: name, ShardID: shardID}]; ok {
+		return []string{n, m.To}, nil
+	}
+	return []string{n}, nil
+}
+
+func (r *rebalanceSelector) Assignments() []Assignment {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]Assignment, 0, len(r.assignments))
+	for k, n := range r.assignments {
+		a := Assignment{Key: k, Node: n}
+		if m, ok := r.moves[k]; ok {
+			mc := *m
+			a.Move = &mc
+		}
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key.String() < result[j].Key.String()
+	})
+	return result
+}
+
+func (r *rebalanceSelector) Plan() []Move {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	planned := r.plan(-1)
+	result := make([]Move, 0, len(planned))
+	for _, m := range planned {
+		m.State = MoveStatePlanned
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key.String() < result[j].Key.String()
+	})
+	return result
+}
+
+func (r *rebalanceSelector) ReportProgress(key ShardKey, copiedParts, totalParts uint32, lag time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.moves[key]
+	if !ok {
+		return false
+	}
+	m.CopiedParts, m.TotalParts, m.Lag = copiedParts, totalParts, lag
+	if copiedParts < totalParts {
+		m.State = MoveStateCopying
+		return false
+	}
+	m.State = MoveStateCatchingUp
+	if lag > r.lagBound {
+		return false
+	}
+	r.assignments[key] = m.To
+	delete(r.moves, key)
+	r.l.Info().Str("shard", key.String()).Str("from", m.From).Str("to", m.To).Msg("the shard is moved")
+	r.rebalance()
+	return true
+}
+
+func (r *rebalanceSelector) assign(k ShardKey) (string, error) {
+	if n, ok := r.assignments[k]; ok {
+		return n, nil
+	}
+	if len(r.nodes) == 0 {
+		return "", ErrNoAvailableNode
+	}
+	n := r.leastLoaded()
+	r.assignments[k] = n
+	return n, nil
+}
+
+// loads returns the number of shards which each node owns or is going to own.
+func (r *rebalanceSelector) loads() map[string]int {
+	loads := make(map[string]int, len(r.nodes))
+	for n := range r.nodes {
+		loads[n] = 0
+	}
+	for k, n := range r.assignments {
+		if m, ok := r.moves[k]; ok {
+			n = m.To
+		}
+		if _, ok := loads[n]; ok {
+			loads[n]++
+		}
+	}
+	return loads
+}
+
+func (r *rebalanceSelector) sortedNodes(loads map[string]int) []string {
+	nodes := make([]string, 0, len(loads))
+	for n := range loads {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if loads[nodes[i]] != loads[nodes[j]] {
+			return loads[nodes[i]] < loads[nodes[j]]
+		}
+		return nodes[i] < nodes[j]
+	})
+	return nodes
+}
+
+func (r *rebalanceSelector) leastLoaded() string {
+	nodes := r.sortedNodes(r.loads())
+	if len(nodes) == 0 {
+		return ""
+	}
+	return nodes[0]
+}
+
+// rebalance starts the planned moves until the number of in-flight moves reaches the limit.
+func (r *rebalanceSelector) rebalance() {
+	if r.copier == nil || len(r.moves) >= r.maxMoves {
+		return
+	}
+	for _, planned := range r.plan(r.maxMoves - len(r.moves)) {
+		m := planned
+		m.State = MoveStateCopying
+		m.StartedAt = time.Now()
+		r.moves[m.Key] = &m
+		r.l.Info().Str("shard", m.Key.String()).Str("from", m.From).Str("to", m.To).Msg("start to move the shard")
+		go r.copy(m)
+	}
+}
+
+// plan plans at most limit moves from the most loaded nodes to the least loaded ones
+// until the difference is less than two shards. A negative limit means no limit.
+func (r *rebalanceSelector) plan(limit int) []Move {
+	if len(r.nodes) < 2 {
+		return nil
+	}
+	loads := r.loads()
+	planned := make(map[ShardKey]struct{})
+	var moves []Move
+	for limit < 0 || len(moves) < limit {
+		nodes := r.sortedNodes(loads)
+		least, most := nodes[0], nodes[len(nodes)-1]
+		if loads[most]-loads[least] < 2 {
+			break
+		}
+		k, ok := r.candidate(most, planned)
+		if !ok {
+			break
+		}
+		planned[k] = struct{}{}
+		moves = append(moves, Move{Key: k, From: most, To: least})
+		loads[most]--
+		loads[least]++
+	}
+	return moves
+}
+
+func (r *rebalanceSelector) candidate(nodeID string, planned map[ShardKey]struct{}) (ShardKey, bool) {
+	var keys []ShardKey
+	for k, n := range r.assignments {
+		if n != nodeID {
+			continue
+		}
+		if _, moving := r.moves[k]; moving {
+			continue
+		}
+		if _, ok := planned[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return ShardKey{}, false
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys[0], true
+}
+
+// copy copies the shard to its new owner, and retries the failed copy with backoff.
+// The move is abandoned if the copy still fails: the shard stays with the old owner,
+// and the rebalance is scheduled again after the backoff.
+func (r *rebalanceSelector) copy(m Move) {
+	r.mu.RLock()
+	copier, retries, backoff := r.copier, r.copyRetries, r.copyBackoff
+	r.mu.RUnlock()
+	report := func(copiedParts, totalParts uint32, lag time.Duration) {
+		r.ReportProgress(m.Key, copiedParts, totalParts, lag)
+	}
+	for attempt := 0; ; attempt++ {
+		err := copier.CopyShard(r.ctx, m, report)
+		if err == nil || r.ctx.Err() != nil || !r.moving(m) {
+			return
+		}
+		if attempt >= retries {
+			r.l.Error().Err(err).Str("shard", m.Key.String()).Str("from", m.From).Str("to", m.To).
+				Msg("failed to copy the shard, the move is abandoned")
+			break
+		}
+		r.l.Warn().Err(err).Str("shard", m.Key.String()).Str("from", m.From).Str("to", m.To).
+			Dur("backoff", backoff).Msg("failed to copy the shard, retry it")
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.inFlight(m) {
+		// the writes are routed to the old owner only
+		delete(r.moves, m.Key)
+	}
+	time.AfterFunc(backoff, func() {
+		if r.ctx.Err() != nil {
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.rebalance()
+	})
+}
+
+func (r *rebalanceSelector) moving(m Move) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inFlight(m)
+}
+
+// inFlight returns true if the move is neither done nor replaced by another one.
+func (r *rebalanceSelector) inFlight(m Move) bool {
+	cur, ok := r.moves[m.Key]
+	return ok && cur.To == m.To && cur.StartedAt.Equal(m.StartedAt)
+}
@@ -0,0 +1,256 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+)
+
+func newRebalanceNode(i int) *databasev1.Node {
+	return &databasev1.Node{
+		Metadata: &commonv1.Metadata{
+			Name: fmt.Sprintf("data-node-%d", i),
+		},
+	}
+}
+
+// manualCopier leaves the progress of the moves to be reported by the tests.
+type manualCopier struct{}
+
+func (manualCopier) CopyShard(ctx context.Context, _ Move, _ func(copiedParts, totalParts uint32, lag time.Duration)) error {
+	<-ctx.Done()
+	return nil
+}
+
+func newTestRebalanceSelector(t *testing.T) RebalanceSelector {
+	sel := NewRebalanceSelector()
+	sel.SetCopier(manualCopier{})
+	t.Cleanup(sel.GracefulStop)
+	return sel
+}
+
+func ownerCount(sel RebalanceSelector) map[string]int {
+	counts := make(map[string]int)
+	for _, a := range sel.Assignments() {
+		counts[a.Node]++
+	}
+	return counts
+}
+
+func TestRebalanceSelector_NodeJoin(t *testing.T) {
+	sel := newTestRebalanceSelector(t)
+	sel.AddNode(newRebalanceNode(0))
+	sel.AddNode(newRebalanceNode(1))
+	for i := uint32(0); i < 4; i++ {
+		_, err := sel.Pick("sw_metrics", "service_cpm", i)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, map[string]int{"data-node-0": 2, "data-node-1": 2}, ownerCount(sel))
+
+	sel.AddNode(newRebalanceNode(2))
+	var moving *Move
+	for _, a := range sel.Assignments() {
+		if a.Move != nil {
+			require.Nil(t, moving, "only one shard moves in a round")
+			moving = a.Move
+		}
+	}
+	require.NotNil(t, moving)
+	assert.Equal(t, "data-node-2", moving.To)
+
+	nodes, err := sel.PickAll(moving.Key.Group, moving.Key.Name, moving.Key.ShardID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{moving.From, moving.To}, nodes)
+
+	assert.False(t, sel.ReportProgress(moving.Key, 1, 3, 0))
+	assert.False(t, sel.ReportProgress(moving.Key, 3, 3, 2*time.Minute))
+	assert.True(t, sel.ReportProgress(moving.Key, 3, 3, time.Second))
+
+	nodes, err = sel.PickAll(moving.Key.Group, moving.Key.Name, moving.Key.ShardID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data-node-2"}, nodes)
+	for _, a := range sel.Assignments() {
+		assert.Nil(t, a.Move, "the shards are balanced")
+	}
+}
+
+func TestRebalanceSelector_NodeLeave(t *testing.T) {
+	sel := newTestRebalanceSelector(t)
+	for i := 0; i < 3; i++ {
+		sel.AddNode(newRebalanceNode(i))
+	}
+	for i := uint32(0); i < 6; i++ {
+		_, err := sel.Pick("sw_metrics", "service_cpm", i)
+		require.NoError(t, err)
+	}
+	sel.RemoveNode(newRebalanceNode(2))
+	counts := ownerCount(sel)
+	assert.Equal(t, map[string]int{"data-node-0": 3, "data-node-1": 3}, counts)
+	for _, a := range sel.Assignments() {
+		assert.Nil(t, a.Move)
+	}
+}
+
+func TestRebalanceSelector_NoNode(t *testing.T) {
+	sel := newTestRebalanceSelector(t)
+	_, err := sel.Pick("sw_metrics", "service_cpm", 0)
+	assert.ErrorIs(t, err, ErrNoAvailableNode)
+}
+
+func TestRebalanceSelector_Plan(t *testing.T) {
+	sel := newTestRebalanceSelector(t)
+	sel.AddNode(newRebalanceNode(0))
+	assert.Empty(t, sel.Plan())
+	sel.AddNode(newRebalanceNode(1))
+	for i := uint32(0); i < 6; i++ {
+		_, err := sel.Pick("sw_metrics", "service_cpm", i)
+		require.NoError(t, err)
+	}
+	assert.Empty(t, sel.Plan())
+
+	sel.AddNode(newRebalanceNode(2))
+	plan := sel.Plan()
+	// the in-flight move isn't planned again
+	require.Len(t, plan, 1)
+	assert.Equal(t, "data-node-0", plan[0].From)
+	assert.Equal(t, "data-node-2", plan[0].To)
+	assert.Equal(t, MoveStatePlanned, plan[0].State)
+
+	var moves int
+	for _, a := range sel.Assignments() {
+		if a.Move != nil {
+			moves++
+			assert.NotEqual(t, plan[0].Key, a.Key)
+		}
+	}
+	assert.Equal(t, 1, moves, "planning doesn't start a move")
+}
+
+func TestRebalanceSelector_LastNodeLeave(t *testing.T) {
+	sel := newTestRebalanceSelector(t)
+	sel.AddNode(newRebalanceNode(0))
+	_, err := sel.Pick("sw_metrics", "service_cpm", 0)
+	require.NoError(t, err)
+	sel.RemoveNode(newRebalanceNode(0))
+	assert.Empty(t, sel.Assignments())
+	_, err = sel.Pick("sw_metrics", "service_cpm", 0)
+	assert.ErrorIs(t, err, ErrNoAvailableNode)
+}
+
+func TestRebalanceSelector_NoCopier(t *testing.T) {
+	sel := NewRebalanceSelector()
+	defer sel.GracefulStop()
+	sel.AddNode(newRebalanceNode(0))
+	for i := uint32(0); i < 4; i++ {
+		_, err := sel.Pick("sw_metrics", "service_cpm", i)
+		require.NoError(t, err)
+	}
+	sel.AddNode(newRebalanceNode(1))
+	require.Len(t, sel.Plan(), 2)
+	for _, a := range sel.Assignments() {
+		assert.Nil(t, a.Move, "no move is started without a copier")
+	}
+
+	sel.SetCopier(manualCopier{})
+	var moves int
+	for _, a := range sel.Assignments() {
+		if a.Move != nil {
+			moves++
+		}
+	}
+	assert.Equal(t, 1, moves)
+}
+
+// flakyCopier fails the first copies, and completes the others at once.
+type flakyCopier struct {
+	calls    atomic.Int32
+	failures int32
+}
+
+func (c *flakyCopier) CopyShard(_ context.Context, _ Move, report func(copiedParts, totalParts uint32, lag time.Duration)) error {
+	if c.calls.Add(1) <= c.failures {
+		return errors.New("cannot get the group")
+	}
+	report(1, 1, 0)
+	return nil
+}
+
+func newFlakyRebalanceSelector(t *testing.T, copier *flakyCopier, retries int, backoff time.Duration) RebalanceSelector {
+	sel := NewRebalanceSelector()
+	rs := sel.(*rebalanceSelector)
+	rs.copyRetries, rs.copyBackoff = retries, backoff
+	sel.SetCopier(copier)
+	t.Cleanup(sel.GracefulStop)
+	sel.AddNode(newRebalanceNode(0))
+	sel.AddNode(newRebalanceNode(1))
+	for i := uint32(0); i < 4; i++ {
+		_, err := sel.Pick("sw_metrics", "service_cpm", i)
+		require.NoError(t, err)
+	}
+	return sel
+}
+
+func movingShard(sel RebalanceSelector) *Move {
+	for _, a := range sel.Assignments() {
+		if a.Move != nil {
+			return a.Move
+		}
+	}
+	return nil
+}
+
+func TestRebalanceSelector_CopyFailsOnce(t *testing.T) {
+	copier := &flakyCopier{failures: 1}
+	sel := newFlakyRebalanceSelector(t, copier, 3, time.Millisecond)
+	sel.AddNode(newRebalanceNode(2))
+	assert.Eventually(t, func() bool {
+		return movingShard(sel) == nil && ownerCount(sel)["data-node-2"] == 1
+	}, time.Second, 10*time.Millisecond, "the retried copy moves the shard")
+	assert.Equal(t, int32(2), copier.calls.Load())
+}
+
+func TestRebalanceSelector_CopyKeepsFailing(t *testing.T) {
+	copier := &flakyCopier{failures: 1 << 30}
+	sel := newFlakyRebalanceSelector(t, copier, 1, 50*time.Millisecond)
+	sel.AddNode(newRebalanceNode(2))
+	moving := movingShard(sel)
+	require.NotNil(t, moving)
+
+	assert.Eventually(t, func() bool {
+		return copier.calls.Load() == 2 && movingShard(sel) == nil
+	}, time.Second, 5*time.Millisecond, "the move is abandoned after the retries")
+	nodes, err := sel.PickAll(moving.Key.Group, moving.Key.Name, moving.Key.ShardID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{moving.From}, nodes, "the writes are routed to the old owner only")
+
+	assert.Eventually(t, func() bool {
+		return copier.calls.Load() > 2
+	}, time.Second, 10*time.Millisecond, "the rebalance is scheduled again")
+	assert.Zero(t, ownerCount(sel)["data-node-2"])
+}
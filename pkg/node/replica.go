@@ -0,0 +1,74 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package node
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxReplicaAttempts bounds the number of keys tried to find distinct nodes for replicas.
+	maxReplicaAttempts = 16
+	replicaSeparator   = "#replica-"
+)
+
+// ErrNotEnoughNodes will be returned if there are not enough distinct nodes to place replicas.
+var ErrNotEnoughNodes = errors.New("selector: not enough nodes for replicas")
+
+// ReplicaName returns the name used to place the replica of a resource.
+// The first replica is the primary one and shares the resource's name.
+func ReplicaName(name string, replica int) string {
+	if replica == 0 {
+		return name
+	}
+	return name + replicaSeparator + strconv.Itoa(replica)
+}
+
+// ResourceName returns the name of the resource which a replica name is derived from.
+func ResourceName(replicaName string) string {
+	name, _, _ := strings.Cut(replicaName, replicaSeparator)
+	return name
+}
+
+// PickReplicas picks distinct nodes for the replicas of a shard.
+// The first node is the primary one which Pick returns.
+func PickReplicas(sel Selector, group, name string, shardID uint32, replicas int) ([]string, error) {
+	if replicas < 1 {
+		replicas = 1
+	}
+	nodes := make([]string, 0, replicas)
+	seen := make(map[string]struct{}, replicas)
+	for i := 0; len(nodes) < replicas && i < replicas+maxReplicaAttempts; i++ {
+		n, err := sel.Pick(group, ReplicaName(name, i), shardID)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[n]; ok {
+			continue
+		}
+		seen[n] = struct{}{}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) < replicas {
+		return nil, errors.Wrapf(ErrNotEnoughNodes, "%d replicas of %s", replicas, formatSearchKey(group, name, shardID))
+	}
+	return nodes, nil
+}
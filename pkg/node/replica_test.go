@@ -0,0 +1,61 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package node
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPickReplicas(t *testing.T) {
+	sel := NewRebalanceSelector()
+	for i := 0; i < 3; i++ {
+		sel.AddNode(newRebalanceNode(i))
+	}
+	nodes, err := PickReplicas(sel, "sw_record", "segment", 0, 3)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"data-node-0", "data-node-1", "data-node-2"}, nodes)
+
+	primary, err := sel.Pick("sw_record", "segment", 0)
+	require.NoError(t, err)
+	assert.Equal(t, primary, nodes[0])
+
+	again, err := PickReplicas(sel, "sw_record", "segment", 0, 3)
+	require.NoError(t, err)
+	assert.Equal(t, nodes, again)
+}
+
+func TestPickReplicas_NotEnoughNodes(t *testing.T) {
+	sel := NewRebalanceSelector()
+	sel.AddNode(newRebalanceNode(0))
+	sel.AddNode(newRebalanceNode(1))
+	_, err := PickReplicas(sel, "sw_record", "segment", 0, 3)
+	assert.True(t, errors.Is(err, ErrNotEnoughNodes))
+
+	nodes, err := PickReplicas(sel, "sw_record", "segment", 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, nodes, 1)
+}
+
+func TestResourceName(t *testing.T) {
+	assert.Equal(t, "segment", ResourceName(ReplicaName("segment", 0)))
+	assert.Equal(t, "segment", ResourceName(ReplicaName("segment", 2)))
+}
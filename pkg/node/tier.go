@@ -0,0 +1,277 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package node
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+)
+
+const (
+	// TierLabel is the label key of the tier a data node belongs to.
+	TierLabel = "tier"
+	// TierHot is the tier receiving the writes.
+	TierHot = "hot"
+	// TierCold is the tier holding the segments relocated from the hot tier.
+	TierCold = "cold"
+)
+
+var _ Selector = (*Placement)(nil)
+
+// Tier returns the tier of a node. A node without the tier label is hot.
+func Tier(node *databasev1.Node) string {
+	for _, l := range node.GetLabels() {
+		if l.GetKey() == TierLabel && l.GetValue() == TierCold {
+			return TierCold
+		}
+	}
+	return TierHot
+}
+
+// ColdThreshold returns the time before which the segments of a group are relocated to the cold tier.
+// It returns false if the group has no placement policy.
+func ColdThreshold(opts *commonv1.ResourceOpts, now time.Time) (time.Time, bool) {
+	ir := opts.GetPlacement().GetColdAfter()
+	if ir.GetNum() == 0 {
+		return time.Time{}, false
+	}
+	d := time.Duration(ir.GetNum()) * time.Hour
+	if ir.GetUnit() == commonv1.IntervalRule_UNIT_DAY {
+		d *= 24
+	}
+	return now.Add(-d), true
+}
+
+// SegmentState is the progress of relocating a segment to the cold tier.
+type SegmentState int
+
+// SegmentState constants.
+const (
+	// SegmentRelocating means the segment is being copied to the cold tier.
+	SegmentRelocating SegmentState = iota + 1
+	// SegmentCopied means the segment is on both tiers until the hot one drops it.
+	SegmentCopied
+	// SegmentCold means the segment is only on the cold tier.
+	SegmentCold
+)
+
+func (s SegmentState) String() string {
+	switch s {
+	case SegmentRelocating:
+		return "relocating"
+	case SegmentCopied:
+		return "copied"
+	case SegmentCold:
+		return "cold"
+	}
+	return "unknown"
+}
+
+// Segment is the tracked time range of a group's segment.
+type Segment struct {
+	Begin time.Time
+	End   time.Time
+	State SegmentState
+}
+
+// Placement picks the cold nodes and tracks the segments relocated to them.
+// The segments which aren't tracked are assumed on the hot tier if they're young,
+// or on either tier if they're old enough to be relocated.
+type Placement struct {
+	cold     Selector
+	nodes    map[string]struct{}
+	active   map[string]struct{}
+	segments map[string][]Segment
+	mu       sync.RWMutex
+}
+
+// NewPlacement returns a placement without any cold node.
+func NewPlacement() (*Placement, error) {
+	cold, err := NewMaglevSelector()
+	if err != nil {
+		return nil, err
+	}
+	return &Placement{
+		cold:     cold,
+		nodes:    make(map[string]struct{}),
+		active:   make(map[string]struct{}),
+		segments: make(map[string][]Segment),
+	}, nil
+}
+
+// AddNode adds or updates a cold node.
+// A draining node keeps serving the queries but isn't picked to receive the segments.
+func (p *Placement) AddNode(node *databasev1.Node) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	name := node.GetMetadata().GetName()
+	switch node.GetState() {
+	case databasev1.NodeState_NODE_STATE_UNSPECIFIED, databasev1.NodeState_NODE_STATE_ACTIVE:
+		if _, ok := p.active[name]; !ok {
+			p.active[name] = struct{}{}
+			p.cold.AddNode(node)
+		}
+	default:
+		if _, ok := p.active[name]; ok {
+			delete(p.active, name)
+			p.cold.RemoveNode(node)
+		}
+	}
+	p.nodes[name] = struct{}{}
+}
+
+// RemoveNode removes a cold node.
+func (p *Placement) RemoveNode(node *databasev1.Node) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	name := node.GetMetadata().GetName()
+	delete(p.nodes, name)
+	if _, ok := p.active[name]; ok {
+		delete(p.active, name)
+		p.cold.RemoveNode(node)
+	}
+}
+
+// Pick returns the active cold node of a shard.
+func (p *Placement) Pick(group, name string, shardID uint32) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.active) == 0 {
+		return "", ErrNoAvailableNode
+	}
+	return p.cold.Pick(group, name, shardID)
+}
+
+// ColdNodes returns the sorted names of the cold nodes including the draining ones.
+func (p *Placement) ColdNodes() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	result := make([]string, 0, len(p.nodes))
+	for n := range p.nodes {
+		result = append(result, n)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Mark sets the state of a group's segment starting at begin.
+func (p *Placement) Mark(group string, begin, end time.Time, state SegmentState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ss := p.segments[group]
+	i := sort.Search(len(ss), func(i int) bool {
+		return !ss[i].Begin.Before(begin)
+	})
+	if i < len(ss) && ss[i].Begin.Equal(begin) {
+		ss[i].End = end
+		ss[i].State = state
+		return
+	}
+	ss = append(ss, Segment{})
+	copy(ss[i+1:], ss[i:])
+	ss[i] = Segment{Begin: begin, End: end, State: state}
+	p.segments[group] = ss
+}
+
+// Forget stops tracking the segments of a group ending at or before the deadline.
+func (p *Placement) Forget(group string, deadline time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ss := p.segments[group]
+	i := 0
+	for i < len(ss) && !ss[i].End.After(deadline) {
+		i++
+	}
+	if i == len(ss) {
+		delete(p.segments, group)
+		return
+	}
+	p.segments[group] = append([]Segment(nil), ss[i:]...)
+}
+
+// Segments returns the tracked segments of a group in time order.
+func (p *Placement) Segments(group string) []Segment {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]Segment(nil), p.segments[group]...)
+}
+
+// Tracked returns true if the placement tracks any segment of the group.
+func (p *Placement) Tracked(group string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.segments[group]) > 0
+}
+
+// TimeSpan is the time range [Begin, End).
+type TimeSpan struct {
+	Begin time.Time
+	End   time.Time
+}
+
+// Route splits the time range of a query on a group into the parts read from each tier.
+// The hot tier is read after the segments only on the cold tier. The cold tier is read
+// before the threshold except for the segments being relocated, which are read from the hot tier.
+func (p *Placement) Route(group string, span TimeSpan, threshold time.Time) (hot *TimeSpan, cold []TimeSpan) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var relocated time.Time
+	prefix := true
+	coldSpan := TimeSpan{Begin: span.Begin, End: minTime(span.End, threshold)}
+	for _, s := range p.segments[group] {
+		if s.State == SegmentCold {
+			if prefix {
+				relocated = s.End
+			}
+			continue
+		}
+		prefix = false
+		if s.Begin.After(coldSpan.Begin) {
+			cold = appendSpan(cold, TimeSpan{Begin: coldSpan.Begin, End: minTime(coldSpan.End, s.Begin)})
+		}
+		if s.End.After(coldSpan.Begin) {
+			coldSpan.Begin = s.End
+		}
+	}
+	cold = appendSpan(cold, coldSpan)
+	if span.Begin.After(relocated) {
+		relocated = span.Begin
+	}
+	if relocated.Before(span.End) {
+		hot = &TimeSpan{Begin: relocated, End: span.End}
+	}
+	return hot, cold
+}
+
+func appendSpan(spans []TimeSpan, s TimeSpan) []TimeSpan {
+	if !s.Begin.Before(s.End) {
+		return spans
+	}
+	return append(spans, s)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
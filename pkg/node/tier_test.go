@@ -0,0 +1,114 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+)
+
+func TestTier(t *testing.T) {
+	assert.Equal(t, TierHot, Tier(&databasev1.Node{}))
+	assert.Equal(t, TierHot, Tier(&databasev1.Node{Labels: []*databasev1.NodeLabel{{Key: TierLabel, Value: TierHot}}}))
+	assert.Equal(t, TierCold, Tier(&databasev1.Node{Labels: []*databasev1.NodeLabel{{Key: "zone", Value: "a"}, {Key: TierLabel, Value: TierCold}}}))
+}
+
+func TestPlacementPick(t *testing.T) {
+	p, err := NewPlacement()
+	require.NoError(t, err)
+	_, err = p.Pick("sw_metric", "service_cpm", 0)
+	assert.ErrorIs(t, err, ErrNoAvailableNode)
+	for _, n := range []string{"cold-1", "cold-0"} {
+		p.AddNode(&databasev1.Node{Metadata: &commonv1.Metadata{Name: n}})
+	}
+	assert.Equal(t, []string{"cold-0", "cold-1"}, p.ColdNodes())
+	n, err := p.Pick("sw_metric", "service_cpm", 0)
+	require.NoError(t, err)
+	assert.Contains(t, p.ColdNodes(), n)
+	p.RemoveNode(&databasev1.Node{Metadata: &commonv1.Metadata{Name: "cold-1"}})
+	n, err = p.Pick("sw_metric", "service_cpm", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "cold-0", n)
+}
+
+func TestPlacementRoute(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	p, err := NewPlacement()
+	require.NoError(t, err)
+	span := TimeSpan{Begin: day(1), End: day(10)}
+	threshold := day(7)
+
+	// nothing is tracked, the old data could be on both tiers
+	hot, cold := p.Route("sw_metric", span, threshold)
+	assert.Equal(t, &TimeSpan{Begin: day(1), End: day(10)}, hot)
+	assert.Equal(t, []TimeSpan{{Begin: day(1), End: day(7)}}, cold)
+
+	p.Mark("sw_metric", day(1), day(2), SegmentCold)
+	p.Mark("sw_metric", day(3), day(4), SegmentRelocating)
+	p.Mark("sw_metric", day(2), day(3), SegmentCold)
+	hot, cold = p.Route("sw_metric", span, threshold)
+	assert.Equal(t, &TimeSpan{Begin: day(3), End: day(10)}, hot)
+	assert.Equal(t, []TimeSpan{{Begin: day(1), End: day(3)}, {Begin: day(4), End: day(7)}}, cold)
+
+	p.Mark("sw_metric", day(3), day(4), SegmentCold)
+	hot, cold = p.Route("sw_metric", TimeSpan{Begin: day(2), End: day(4)}, threshold)
+	assert.Nil(t, hot)
+	assert.Equal(t, []TimeSpan{{Begin: day(2), End: day(4)}}, cold)
+
+	hot, cold = p.Route("sw_metric", TimeSpan{Begin: day(8), End: day(9)}, threshold)
+	assert.Equal(t, &TimeSpan{Begin: day(8), End: day(9)}, hot)
+	assert.Empty(t, cold)
+
+	p.Forget("sw_metric", day(3))
+	assert.Equal(t, []Segment{{Begin: day(3), End: day(4), State: SegmentCold}}, p.Segments("sw_metric"))
+	p.Forget("sw_metric", day(4))
+	assert.False(t, p.Tracked("sw_metric"))
+}
+
+func TestPlacementDrainingNode(t *testing.T) {
+	p, err := NewPlacement()
+	require.NoError(t, err)
+	p.AddNode(&databasev1.Node{Metadata: &commonv1.Metadata{Name: "cold-0"}, State: databasev1.NodeState_NODE_STATE_DRAINING})
+	assert.Equal(t, []string{"cold-0"}, p.ColdNodes())
+	_, err = p.Pick("sw_metric", "service_cpm", 0)
+	assert.ErrorIs(t, err, ErrNoAvailableNode)
+
+	p.AddNode(&databasev1.Node{Metadata: &commonv1.Metadata{Name: "cold-0"}, State: databasev1.NodeState_NODE_STATE_ACTIVE})
+	n, err := p.Pick("sw_metric", "service_cpm", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "cold-0", n)
+}
+
+func TestColdThreshold(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	_, ok := ColdThreshold(&commonv1.ResourceOpts{}, now)
+	assert.False(t, ok)
+	threshold, ok := ColdThreshold(&commonv1.ResourceOpts{Placement: &commonv1.Placement{
+		ColdAfter: &commonv1.IntervalRule{Unit: commonv1.IntervalRule_UNIT_DAY, Num: 3},
+	}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC), threshold)
+}
@@ -75,12 +75,13 @@ func (e EntityLocator) Find(subject string, value []*modelv1.TagFamilyForWrite)
 }
 
 // Locate a shard and find the entity from a tag family, prepend a subject to the entity.
-func (e EntityLocator) Locate(subject string, value []*modelv1.TagFamilyForWrite, shardNum uint32) (tsdb.Entity, tsdb.EntityValues, common.ShardID, error) {
+func (e EntityLocator) Locate(subject string, value []*modelv1.TagFamilyForWrite, sharding Sharding, shardNum uint32,
+) (tsdb.Entity, tsdb.EntityValues, common.ShardID, error) {
 	entity, tagValues, err := e.Find(subject, value)
 	if err != nil {
 		return nil, nil, 0, err
 	}
-	id, err := ShardID(entity.Marshal(), shardNum)
+	id, err := sharding.ShardID(entity, shardNum)
 	if err != nil {
 		return nil, nil, 0, err
 	}
@@ -20,6 +20,10 @@ package partition
 import (
 	"github.com/pkg/errors"
 
+	"github.com/apache/skywalking-banyandb/api/common"
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/tsdb"
 	"github.com/apache/skywalking-banyandb/pkg/convert"
 )
 
@@ -31,3 +35,96 @@ func ShardID(key []byte, shardNum uint32) (uint, error) {
 	encodeKey := convert.Hash(key)
 	return uint(encodeKey % uint64(shardNum)), nil
 }
+
+// Sharding routes a series to a shard.
+type Sharding interface {
+	// ShardID returns the shard of the series whose entity starts with the subject.
+	ShardID(entity tsdb.Entity, shardNum uint32) (uint, error)
+	// RoutingTagNum returns how many leading tags of an entity with tagNum tags decide the shard.
+	RoutingTagNum(tagNum int) int
+}
+
+// NewSharding returns the Sharding defined by a group.
+func NewSharding(sharding *commonv1.Sharding) (Sharding, error) {
+	switch sharding.GetType() {
+	case commonv1.Sharding_TYPE_UNSPECIFIED, commonv1.Sharding_TYPE_SERIES:
+		return seriesSharding{}, nil
+	case commonv1.Sharding_TYPE_ENTITY_PREFIX:
+		if sharding.GetPrefixLength() < 1 {
+			return nil, errors.New("prefix_length should be positive for the entity prefix sharding")
+		}
+		return prefixSharding(sharding.GetPrefixLength()), nil
+	}
+	return nil, errors.Errorf("unsupported sharding type %s", sharding.GetType())
+}
+
+type seriesSharding struct{}
+
+func (seriesSharding) ShardID(entity tsdb.Entity, shardNum uint32) (uint, error) {
+	return ShardID(entity.Marshal(), shardNum)
+}
+
+func (seriesSharding) RoutingTagNum(tagNum int) int {
+	return tagNum
+}
+
+type prefixSharding uint32
+
+func (p prefixSharding) ShardID(entity tsdb.Entity, shardNum uint32) (uint, error) {
+	if len(entity) < 1 {
+		return 0, errors.New("the entity is empty")
+	}
+	return ShardID(entity[:1+p.RoutingTagNum(len(entity)-1)].Marshal(), shardNum)
+}
+
+func (p prefixSharding) RoutingTagNum(tagNum int) int {
+	if int(p) < tagNum {
+		return int(p)
+	}
+	return tagNum
+}
+
+// PinnedShard returns the only shard which the criteria can hit.
+// It's found if the criteria pin all routing tags by equality conditions joined with AND.
+func PinnedShard(subject string, entity []string, criteria *modelv1.Criteria, sharding Sharding, shardNum uint32) (common.ShardID, bool) {
+	if criteria == nil || len(entity) < 1 {
+		return 0, false
+	}
+	pinned := make(map[string]*modelv1.TagValue)
+	collectEquals(criteria, pinned)
+	tagNum := sharding.RoutingTagNum(len(entity))
+	values := make(tsdb.EntityValues, 0, tagNum+1)
+	values = append(values, tsdb.StrValue(subject))
+	for _, name := range entity[:tagNum] {
+		v, ok := pinned[name]
+		if !ok {
+			return 0, false
+		}
+		values = append(values, v)
+	}
+	e, err := values.ToEntity()
+	if err != nil {
+		return 0, false
+	}
+	id, err := sharding.ShardID(e, shardNum)
+	if err != nil {
+		return 0, false
+	}
+	return common.ShardID(id), true
+}
+
+func collectEquals(criteria *modelv1.Criteria, pinned map[string]*modelv1.TagValue) {
+	switch exp := criteria.GetExp().(type) {
+	case *modelv1.Criteria_Condition:
+		if exp.Condition.GetOp() == modelv1.Condition_BINARY_OP_EQ && exp.Condition.GetValue() != nil {
+			pinned[exp.Condition.GetName()] = exp.Condition.GetValue()
+		}
+	case *modelv1.Criteria_Le:
+		// an OR branch can't pin a tag
+		if exp.Le.GetOp() != modelv1.LogicalExpression_LOGICAL_OP_AND {
+			return
+		}
+		collectEquals(exp.Le.GetLeft(), pinned)
+		collectEquals(exp.Le.GetRight(), pinned)
+	}
+}
@@ -0,0 +1,126 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package partition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/banyand/tsdb"
+)
+
+const testShardNum = 16
+
+func entityOf(t *testing.T, values ...string) tsdb.Entity {
+	evs := make(tsdb.EntityValues, 0, len(values)+1)
+	evs = append(evs, tsdb.StrValue("service_cpm_minute"))
+	for _, v := range values {
+		evs = append(evs, tsdb.StrValue(v))
+	}
+	entity, err := evs.ToEntity()
+	require.NoError(t, err)
+	return entity
+}
+
+func TestNewSharding(t *testing.T) {
+	s, err := NewSharding(nil)
+	require.NoError(t, err)
+	assert.Equal(t, seriesSharding{}, s)
+	_, err = NewSharding(&commonv1.Sharding{Type: commonv1.Sharding_TYPE_ENTITY_PREFIX})
+	assert.Error(t, err)
+	s, err = NewSharding(&commonv1.Sharding{Type: commonv1.Sharding_TYPE_ENTITY_PREFIX, PrefixLength: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.RoutingTagNum(3))
+}
+
+func TestSeriesShardingKeepsRouting(t *testing.T) {
+	entity := entityOf(t, "svc-1", "instance-1")
+	want, err := ShardID(entity.Marshal(), testShardNum)
+	require.NoError(t, err)
+	got, err := seriesSharding{}.ShardID(entity, testShardNum)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestPrefixShardingColocates(t *testing.T) {
+	s := prefixSharding(1)
+	want, err := s.ShardID(entityOf(t, "svc-1"), testShardNum)
+	require.NoError(t, err)
+	spread := make(map[uint]struct{})
+	for _, instance := range []string{"instance-1", "instance-2", "instance-3", "instance-4", "instance-5"} {
+		got, errShard := s.ShardID(entityOf(t, "svc-1", instance), testShardNum)
+		require.NoError(t, errShard)
+		assert.Equal(t, want, got)
+		got, errShard = seriesSharding{}.ShardID(entityOf(t, "svc-1", instance), testShardNum)
+		require.NoError(t, errShard)
+		spread[got] = struct{}{}
+	}
+	assert.Greater(t, len(spread), 1)
+}
+
+func eq(name, value string) *modelv1.Criteria {
+	return &modelv1.Criteria{Exp: &modelv1.Criteria_Condition{Condition: &modelv1.Condition{
+		Name:  name,
+		Op:    modelv1.Condition_BINARY_OP_EQ,
+		Value: tsdb.StrValue(value),
+	}}}
+}
+
+func join(op modelv1.LogicalExpression_LogicalOp, left, right *modelv1.Criteria) *modelv1.Criteria {
+	return &modelv1.Criteria{Exp: &modelv1.Criteria_Le{Le: &modelv1.LogicalExpression{Op: op, Left: left, Right: right}}}
+}
+
+func TestPinnedShard(t *testing.T) {
+	entity := []string{"service_id", "instance_id"}
+	s := prefixSharding(1)
+	want, err := s.ShardID(entityOf(t, "svc-1"), testShardNum)
+	require.NoError(t, err)
+	and := modelv1.LogicalExpression_LOGICAL_OP_AND
+	or := modelv1.LogicalExpression_LOGICAL_OP_OR
+	tests := []struct {
+		criteria *modelv1.Criteria
+		sharding Sharding
+		name     string
+		pinned   bool
+	}{
+		{name: "no criteria", sharding: s},
+		{name: "equality on the prefix", sharding: s, criteria: eq("service_id", "svc-1"), pinned: true},
+		{
+			name:     "equality joined by and",
+			sharding: s,
+			criteria: join(and, eq("layer", "general"), eq("service_id", "svc-1")),
+			pinned:   true,
+		},
+		{name: "equality in an or branch", sharding: s, criteria: join(or, eq("layer", "general"), eq("service_id", "svc-1"))},
+		{name: "other tags", sharding: s, criteria: eq("instance_id", "instance-1")},
+		{name: "series sharding requires all tags", sharding: seriesSharding{}, criteria: eq("service_id", "svc-1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, ok := PinnedShard("service_cpm_minute", entity, tt.criteria, tt.sharding, testShardNum)
+			assert.Equal(t, tt.pinned, ok)
+			if tt.pinned {
+				assert.Equal(t, want, uint(id))
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package v1
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+)
+
+// StreamBatchResult is the result of a stream query pulled in column batches.
+type StreamBatchResult interface {
+	// PullBatch returns the next batch, or nil once the result is exhausted.
+	PullBatch() *streamv1.ColumnBatch
+}
+
+// NewColumnBatch encodes the timestamps and the elementIDs of the elements of the series into a column batch.
+// The tag columns are appended to its tag families by the caller.
+func NewColumnBatch(sid common.SeriesID, timestamps []int64, elementIDs []string) *streamv1.ColumnBatch {
+	cb := &streamv1.ColumnBatch{
+		SeriesId: uint64(sid),
+		Count:    uint32(len(timestamps)),
+	}
+	if len(timestamps) == 0 {
+		return cb
+	}
+	var mt encoding.EncodeType
+	cb.Timestamps, mt, cb.TimestampsFirstValue = encoding.Int64ListToBytes(nil, timestamps)
+	cb.TimestampsEncodeType = uint32(mt)
+	ids := make([][]byte, len(elementIDs))
+	for i := range elementIDs {
+		ids[i] = convert.StringToBytes(elementIDs[i])
+	}
+	cb.ElementIds = encoding.EncodeBytesBlock(nil, ids)
+	return cb
+}
+
+// EncodeTagColumn encodes the values of a tag, whose nil values are nulls.
+// The values are nulls of an unknown type if values is nil.
+func EncodeTagColumn(name string, valueType ValueType, values [][]byte) *streamv1.TagColumn {
+	tc := &streamv1.TagColumn{Name: name}
+	if values == nil {
+		return tc
+	}
+	tc.ValueType = uint32(valueType)
+	tc.Values = encoding.EncodeBytesBlock(nil, values)
+	for i := range values {
+		if values[i] != nil {
+			continue
+		}
+		if tc.NullMask == nil {
+			tc.NullMask = make([]byte, (len(values)+7)/8)
+		}
+		tc.NullMask[i/8] |= 1 << (i % 8)
+	}
+	return tc
+}
+
+// DecodeColumnBatch decodes the column batch into a StreamResult,
+// whose tag values are the same as those of the result pulled row by row.
+func DecodeColumnBatch(cb *streamv1.ColumnBatch) (*StreamResult, error) {
+	n := int(cb.GetCount())
+	r := &StreamResult{SID: common.SeriesID(cb.GetSeriesId())}
+	if n == 0 {
+		return r, nil
+	}
+	var err error
+	if r.Timestamps, err = encoding.BytesToInt64List(nil, cb.GetTimestamps(),
+		encoding.EncodeType(cb.GetTimestampsEncodeType()), cb.GetTimestampsFirstValue(), n); err != nil {
+		return nil, errors.WithMessage(err, "cannot decode timestamps")
+	}
+	var decoder encoding.BytesBlockDecoder
+	ids, err := decoder.Decode(nil, cb.GetElementIds(), uint64(n))
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot decode elementIDs")
+	}
+	r.ElementIDs = make([]string, len(ids))
+	for i := range ids {
+		r.ElementIDs[i] = string(ids[i])
+	}
+	for _, tcf := range cb.GetTagFamilies() {
+		tf := TagFamily{Name: tcf.GetName()}
+		for _, tc := range tcf.GetColumns() {
+			values, err := decodeTagColumn(&decoder, tc, n)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "cannot decode tag %s.%s", tcf.GetName(), tc.GetName())
+			}
+			tf.Tags = append(tf.Tags, Tag{Name: tc.GetName(), Values: values})
+		}
+		r.TagFamilies = append(r.TagFamilies, tf)
+	}
+	return r, nil
+}
+
+func decodeTagColumn(decoder *encoding.BytesBlockDecoder, tc *streamv1.TagColumn, n int) ([]*modelv1.TagValue, error) {
+	values := make([]*modelv1.TagValue, n)
+	valueType := ValueType(tc.GetValueType())
+	if valueType == ValueTypeUnknown {
+		for i := range values {
+			values[i] = NullTagValue
+		}
+		return values, nil
+	}
+	decoder.Reset()
+	raw, err := decoder.Decode(nil, tc.GetValues(), uint64(n))
+	if err != nil {
+		return nil, err
+	}
+	mask := tc.GetNullMask()
+	for i := range values {
+		if i/8 < len(mask) && mask[i/8]&(1<<(i%8)) != 0 {
+			if values[i], err = nullColumnValue(valueType); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if values[i], err = decodeTagValue(valueType, raw[i]); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// nullColumnValue returns the value of a null of the type, which is an empty value of the type.
+func nullColumnValue(valueType ValueType) (*modelv1.TagValue, error) {
+	switch valueType {
+	case ValueTypeStr:
+		return EmptyStrTagValue, nil
+	case ValueTypeStrArr:
+		return EmptyStrArrTagValue, nil
+	case ValueTypeInt64Arr:
+		return EmptyIntArrTagValue, nil
+	case ValueTypeBinaryData:
+		return EmptyBinaryTagValue, nil
+	case ValueTypeInt64:
+		return nil, errors.New("int64 can't be null")
+	default:
+		return NullTagValue, nil
+	}
+}
+
+func decodeTagValue(valueType ValueType, value []byte) (*modelv1.TagValue, error) {
+	switch valueType {
+	case ValueTypeInt64:
+		if len(value) != 8 {
+			return nil, errors.Errorf("invalid int64 of %d bytes", len(value))
+		}
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: convert.BytesToInt64(value)}}}, nil
+	case ValueTypeStr:
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: string(value)}}}, nil
+	case ValueTypeBinaryData:
+		return &modelv1.TagValue{Value: &modelv1.TagValue_BinaryData{BinaryData: bytes.Clone(value)}}, nil
+	case ValueTypeInt64Arr:
+		if len(value)%8 != 0 {
+			return nil, errors.Errorf("invalid int64 array of %d bytes", len(value))
+		}
+		var values []int64
+		for i := 0; i < len(value); i += 8 {
+			values = append(values, convert.BytesToInt64(value[i:i+8]))
+		}
+		return &modelv1.TagValue{Value: &modelv1.TagValue_IntArray{IntArray: &modelv1.IntArray{Value: values}}}, nil
+	case ValueTypeStrArr:
+		var values []string
+		var buf []byte
+		var err error
+		for len(value) > 0 {
+			buf, value, err = unmarshalEntityValue(buf[:0], value)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, string(buf))
+		}
+		return &modelv1.TagValue{Value: &modelv1.TagValue_StrArray{StrArray: &modelv1.StrArray{Value: values}}}, nil
+	default:
+		return nil, errors.Errorf("unsupported value type: %v", valueType)
+	}
+}
@@ -0,0 +1,71 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package v1
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+)
+
+func TestDecodeColumnBatch(t *testing.T) {
+	cb := NewColumnBatch(7, []int64{10, 20, 35}, []string{"a", "", "c"})
+	cb.TagFamilies = append(cb.TagFamilies, &streamv1.TagColumnFamily{
+		Name: "default",
+		Columns: []*streamv1.TagColumn{
+			EncodeTagColumn("str", ValueTypeStr, [][]byte{[]byte("x"), nil, {}}),
+			EncodeTagColumn("int", ValueTypeInt64, [][]byte{convert.Int64ToBytes(1), convert.Int64ToBytes(-2), convert.Int64ToBytes(3)}),
+			EncodeTagColumn("strArr", ValueTypeStrArr, [][]byte{[]byte("a|b\\||"), nil, []byte("c|")}),
+			EncodeTagColumn("missing", ValueTypeStr, nil),
+		},
+	})
+	r, err := DecodeColumnBatch(cb)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), uint64(r.SID))
+	assert.Equal(t, []int64{10, 20, 35}, r.Timestamps)
+	assert.Equal(t, []string{"a", "", "c"}, r.ElementIDs)
+	str := func(s string) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: s}}}
+	}
+	int64Value := func(v int64) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: v}}}
+	}
+	strArr := func(s ...string) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_StrArray{StrArray: &modelv1.StrArray{Value: s}}}
+	}
+	want := []TagFamily{{Name: "default", Tags: []Tag{
+		{Name: "str", Values: []*modelv1.TagValue{str("x"), EmptyStrTagValue, str("")}},
+		{Name: "int", Values: []*modelv1.TagValue{int64Value(1), int64Value(-2), int64Value(3)}},
+		{Name: "strArr", Values: []*modelv1.TagValue{strArr("a", "b|"), EmptyStrArrTagValue, strArr("c")}},
+		{Name: "missing", Values: []*modelv1.TagValue{NullTagValue, NullTagValue, NullTagValue}},
+	}}}
+	if diff := cmp.Diff(want, r.TagFamilies, protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected tag families (-want +got):\n%s", diff)
+	}
+
+	cb.TagFamilies[0].Columns = []*streamv1.TagColumn{EncodeTagColumn("int", ValueTypeInt64, [][]byte{nil, nil, convert.Int64ToBytes(1)})}
+	_, err = DecodeColumnBatch(cb)
+	assert.Error(t, err, "an int64 can't be null")
+}
@@ -0,0 +1,261 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package v1
+
+import (
+	"encoding/binary"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+const (
+	cursorVersion      = 1
+	nodeCursorsVersion = 2
+)
+
+// ErrInvalidContinuationToken indicates the continuation token of a query isn't issued by a previous page.
+var ErrInvalidContinuationToken = errors.New("invalid continuation token")
+
+// Cursor is the position a query ordered by timestamps resumes from, which the continuation tokens encode.
+// The rows are ordered by their timestamps in the order of the query, then by their series IDs in the ascending order.
+// Offset is the number of the rows of the series at the timestamp which are returned already.
+//
+// The position rather than the snapshot of the parts defines the page, so a token doesn't hold the parts
+// from being merged or removed: the rows written concurrently ahead of the position show up in the later pages,
+// and those behind it are never returned.
+type Cursor struct {
+	Timestamp int64
+	SeriesID  common.SeriesID
+	Offset    uint32
+}
+
+// Before reports whether the row at the timestamp in the series is ordered before the position.
+// The rows at the position itself are neither before nor after it.
+func (c *Cursor) Before(timestamp int64, sid common.SeriesID, desc bool) bool {
+	if timestamp != c.Timestamp {
+		return timestamp < c.Timestamp != desc
+	}
+	return sid < c.SeriesID
+}
+
+// Narrow returns the part of the time range which isn't ordered before the timestamp of the position,
+// so that the blocks of the previous pages aren't read again.
+func (c *Cursor) Narrow(tr timestamp.TimeRange, desc bool) timestamp.TimeRange {
+	t := time.Unix(0, c.Timestamp)
+	if desc {
+		if t.Before(tr.End) {
+			tr.End, tr.IncludeEnd = t, true
+		}
+		return tr
+	}
+	if t.After(tr.Start) {
+		tr.Start, tr.IncludeStart = t, true
+	}
+	return tr
+}
+
+// MarshalToken encodes the cursor into an opaque continuation token.
+func (c *Cursor) MarshalToken() []byte {
+	dst := make([]byte, 0, 1+3*binary.MaxVarintLen64)
+	dst = append(dst, cursorVersion)
+	dst = binary.AppendVarint(dst, c.Timestamp)
+	dst = binary.AppendUvarint(dst, uint64(c.SeriesID))
+	return binary.AppendUvarint(dst, uint64(c.Offset))
+}
+
+// ParseCursor decodes the cursor from a continuation token.
+func ParseCursor(token []byte) (*Cursor, error) {
+	if len(token) == 0 || token[0] != cursorVersion {
+		return nil, ErrInvalidContinuationToken
+	}
+	src := token[1:]
+	var c Cursor
+	var n int
+	if c.Timestamp, n = binary.Varint(src); n <= 0 {
+		return nil, ErrInvalidContinuationToken
+	}
+	src = src[n:]
+	sid, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, ErrInvalidContinuationToken
+	}
+	c.SeriesID = common.SeriesID(sid)
+	src = src[n:]
+	offset, n := binary.Uvarint(src)
+	if n <= 0 || n != len(src) || offset > uint64(^uint32(0)) {
+		return nil, ErrInvalidContinuationToken
+	}
+	c.Offset = uint32(offset)
+	return &c, nil
+}
+
+// NodeCursor is the position of a data node in a distributed query, which resumes the node after its rows
+// returned by the previous pages. The node is queried for the shards, or all its shards if there are none.
+// Index tells apart the responses of a node queried for the same shards more than once, e.g. for the time ranges
+// of the cold tiers, which are counted in the order the responses are opened.
+//
+// A node's token is the position of the last row of its page, while a page of the liaison may return only
+// the first rows of it. Skip counts those rows after the position the page started from, so the rows written
+// concurrently among them shift the next page: some rows are returned again, but none is missed.
+type NodeCursor struct {
+	Node     string
+	ShardIDs []uint32
+	Index    uint32
+	// Token is the continuation token of the node, or nil to start from the beginning of the time range.
+	Token []byte
+	Skip  uint32
+}
+
+// Advance returns the position of the node after a page, which returned n rows of the node's response.
+// The token of the response is taken if the page returned all the rows of the node.
+func (c NodeCursor) Advance(n uint32, all bool, token []byte) NodeCursor {
+	if all && len(token) > 0 {
+		c.Token, c.Skip = token, 0
+		return c
+	}
+	c.Skip += n
+	return c
+}
+
+// NodeCursors are the positions of the data nodes, which the continuation tokens of the distributed queries encode.
+//
+// A node queried for other shards than those of its position starts from the beginning of the time range,
+// since the position is ordered among the rows of the old shards only. It happens if the replica serving a shard
+// changes between the pages, and the rows of the shards returned by the previous pages are returned again.
+type NodeCursors []NodeCursor
+
+// Find returns the position of the index-th response of the node queried for the shards.
+func (cc NodeCursors) Find(node string, shardIDs []uint32, index uint32) (NodeCursor, bool) {
+	for _, c := range cc {
+		if c.Node == node && c.Index == index && sameShards(c.ShardIDs, shardIDs) {
+			return c, true
+		}
+	}
+	return NodeCursor{Node: node, ShardIDs: shardIDs, Index: index}, false
+}
+
+// Count returns the number of the positions of the node queried for the shards,
+// which is the index of the node's next response for the shards.
+func (cc NodeCursors) Count(node string, shardIDs []uint32) uint32 {
+	var n uint32
+	for _, c := range cc {
+		if c.Node == node && sameShards(c.ShardIDs, shardIDs) {
+			n++
+		}
+	}
+	return n
+}
+
+func sameShards(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// MarshalToken encodes the positions into an opaque continuation token.
+func (cc NodeCursors) MarshalToken() []byte {
+	dst := []byte{nodeCursorsVersion}
+	dst = binary.AppendUvarint(dst, uint64(len(cc)))
+	for _, c := range cc {
+		dst = binary.AppendUvarint(dst, uint64(len(c.Node)))
+		dst = append(dst, c.Node...)
+		dst = binary.AppendUvarint(dst, uint64(len(c.ShardIDs)))
+		for _, id := range c.ShardIDs {
+			dst = binary.AppendUvarint(dst, uint64(id))
+		}
+		dst = binary.AppendUvarint(dst, uint64(c.Index))
+		dst = binary.AppendUvarint(dst, uint64(len(c.Token)))
+		dst = append(dst, c.Token...)
+		dst = binary.AppendUvarint(dst, uint64(c.Skip))
+	}
+	return dst
+}
+
+// ParseNodeCursors decodes the positions from a continuation token of a distributed query.
+func ParseNodeCursors(token []byte) (NodeCursors, error) {
+	if len(token) == 0 || token[0] != nodeCursorsVersion {
+		return nil, ErrInvalidContinuationToken
+	}
+	src := token[1:]
+	next := func() (uint64, bool) {
+		v, n := binary.Uvarint(src)
+		if n <= 0 {
+			return 0, false
+		}
+		src = src[n:]
+		return v, true
+	}
+	bytesOf := func() ([]byte, bool) {
+		n, ok := next()
+		if !ok || n > uint64(len(src)) {
+			return nil, false
+		}
+		b := src[:n:n]
+		src = src[n:]
+		return b, true
+	}
+	count, ok := next()
+	if !ok || count > uint64(len(src)) {
+		return nil, ErrInvalidContinuationToken
+	}
+	cc := make(NodeCursors, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var c NodeCursor
+		node, okNode := bytesOf()
+		shards, okShards := next()
+		if !okNode || !okShards || shards > uint64(len(src)) {
+			return nil, ErrInvalidContinuationToken
+		}
+		c.Node = string(node)
+		for j := uint64(0); j < shards; j++ {
+			id, okID := next()
+			if !okID || id > uint64(^uint32(0)) {
+				return nil, ErrInvalidContinuationToken
+			}
+			c.ShardIDs = append(c.ShardIDs, uint32(id))
+		}
+		index, okIndex := next()
+		if !okIndex || index > uint64(^uint32(0)) {
+			return nil, ErrInvalidContinuationToken
+		}
+		c.Index = uint32(index)
+		t, okToken := bytesOf()
+		skip, okSkip := next()
+		if !okToken || !okSkip || skip > uint64(^uint32(0)) {
+			return nil, ErrInvalidContinuationToken
+		}
+		if len(t) > 0 {
+			c.Token = t
+		}
+		c.Skip = uint32(skip)
+		cc = append(cc, c)
+	}
+	if len(src) > 0 {
+		return nil, ErrInvalidContinuationToken
+	}
+	return cc, nil
+}
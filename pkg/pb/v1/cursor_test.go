@@ -0,0 +1,145 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package v1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apache/skywalking-banyandb/api/common"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+func TestCursorToken(t *testing.T) {
+	for _, c := range []Cursor{
+		{},
+		{Timestamp: 1700000000000000000, SeriesID: 42, Offset: 3},
+		{Timestamp: -1, SeriesID: ^common.SeriesID(0), Offset: ^uint32(0)},
+	} {
+		got, err := ParseCursor(c.MarshalToken())
+		require.NoError(t, err)
+		assert.Equal(t, c, *got)
+	}
+	token := (&Cursor{Timestamp: 1, SeriesID: 2, Offset: 3}).MarshalToken()
+	for _, invalid := range [][]byte{nil, {0}, token[:len(token)-1], append(token, 0)} {
+		_, err := ParseCursor(invalid)
+		assert.ErrorIs(t, err, ErrInvalidContinuationToken)
+	}
+}
+
+func TestCursorBefore(t *testing.T) {
+	c := &Cursor{Timestamp: 10, SeriesID: 5}
+	tests := []struct {
+		name      string
+		timestamp int64
+		sid       uint64
+		desc      bool
+		want      bool
+	}{
+		{name: "asc earlier", timestamp: 9, sid: 9, want: true},
+		{name: "asc later", timestamp: 11, sid: 1},
+		{name: "asc lower series", timestamp: 10, sid: 4, want: true},
+		{name: "asc same series", timestamp: 10, sid: 5},
+		{name: "asc higher series", timestamp: 10, sid: 6},
+		{name: "desc later", timestamp: 11, sid: 9, desc: true, want: true},
+		{name: "desc earlier", timestamp: 9, sid: 1, desc: true},
+		{name: "desc lower series", timestamp: 10, sid: 4, desc: true, want: true},
+		{name: "desc higher series", timestamp: 10, sid: 6, desc: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, c.Before(tt.timestamp, common.SeriesID(tt.sid), tt.desc))
+		})
+	}
+}
+
+func TestCursorNarrow(t *testing.T) {
+	start, end := time.Unix(0, 100), time.Unix(0, 200)
+	tr := timestamp.NewTimeRange(start, end, false, false)
+	c := &Cursor{Timestamp: 150}
+	assert.Equal(t, timestamp.NewTimeRange(time.Unix(0, 150), end, true, false), c.Narrow(tr, false))
+	assert.Equal(t, timestamp.NewTimeRange(start, time.Unix(0, 150), false, true), c.Narrow(tr, true))
+	c = &Cursor{Timestamp: 50}
+	assert.Equal(t, tr, c.Narrow(tr, false))
+	c = &Cursor{Timestamp: 250}
+	assert.Equal(t, tr, c.Narrow(tr, true))
+}
+
+func TestNodeCursorsToken(t *testing.T) {
+	cc := NodeCursors{
+		{Node: "data-node-0", ShardIDs: []uint32{3, 1}, Token: (&Cursor{Timestamp: 1, SeriesID: 2, Offset: 3}).MarshalToken(), Skip: 0},
+		{Node: "data-node-1", Index: 2, Skip: 7},
+	}
+	got, err := ParseNodeCursors(cc.MarshalToken())
+	require.NoError(t, err)
+	assert.Equal(t, cc, got)
+	got, err = ParseNodeCursors(NodeCursors{}.MarshalToken())
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	token := cc.MarshalToken()
+	cursorToken := (&Cursor{}).MarshalToken()
+	for _, invalid := range [][]byte{nil, cursorToken, token[:len(token)-1], append(token, 0)} {
+		_, err = ParseNodeCursors(invalid)
+		assert.ErrorIs(t, err, ErrInvalidContinuationToken)
+	}
+	_, err = ParseCursor(token)
+	assert.ErrorIs(t, err, ErrInvalidContinuationToken)
+}
+
+func TestNodeCursorsFind(t *testing.T) {
+	cc := NodeCursors{{Node: "n1", ShardIDs: []uint32{1, 3}, Skip: 2}, {Node: "n2", Skip: 5}, {Node: "n2", Index: 1, Skip: 6}}
+	c, ok := cc.Find("n1", []uint32{3, 1}, 0)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(2), c.Skip)
+	c, ok = cc.Find("n2", nil, 0)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(5), c.Skip)
+	// the responses of a node are told apart by their order
+	c, ok = cc.Find("n2", nil, 1)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(6), c.Skip)
+
+	// the node queried for other shards starts over
+	c, ok = cc.Find("n1", []uint32{1}, 0)
+	assert.False(t, ok)
+	assert.Equal(t, NodeCursor{Node: "n1", ShardIDs: []uint32{1}}, c)
+	c, ok = cc.Find("n2", nil, 2)
+	assert.False(t, ok)
+	assert.Equal(t, NodeCursor{Node: "n2", Index: 2}, c)
+	_, ok = cc.Find("n3", nil, 0)
+	assert.False(t, ok)
+
+	assert.Equal(t, uint32(2), cc.Count("n2", nil))
+	assert.Equal(t, uint32(1), cc.Count("n1", []uint32{3, 1}))
+	assert.Equal(t, uint32(0), cc.Count("n1", nil))
+}
+
+func TestNodeCursorAdvance(t *testing.T) {
+	base := NodeCursor{Node: "n1", Token: []byte{1}, Skip: 2}
+	token := []byte{2}
+	// all the rows of the node are returned, which its token resumes
+	assert.Equal(t, NodeCursor{Node: "n1", Token: token}, base.Advance(5, true, token))
+	// the rows returned are skipped after the position of the page
+	assert.Equal(t, NodeCursor{Node: "n1", Token: []byte{1}, Skip: 5}, base.Advance(3, false, token))
+	// the node without more rows has no token
+	assert.Equal(t, NodeCursor{Node: "n1", Token: []byte{1}, Skip: 6}, base.Advance(4, true, nil))
+}
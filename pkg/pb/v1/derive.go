@@ -0,0 +1,216 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package v1
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+var errInvalidDerivation = errors.New("invalid tag derivation")
+
+// TagFiller fills the tags of the elements or the data points written by the default values
+// and the derivations in their specifications, before they're located and stored.
+type TagFiller struct {
+	defaults []filledTag
+	derived  []filledTag
+}
+
+type filledTag struct {
+	defaultValue *modelv1.TagValue
+	derive       func(source *modelv1.TagValue) *modelv1.TagValue
+	family       int
+	tag          int
+	sourceFamily int
+	sourceTag    int
+}
+
+// NewTagFiller compiles the default values and the derivations of the tag families,
+// or returns nil if none of the tags has them. An error is returned if any of them is invalid.
+func NewTagFiller(families []*databasev1.TagFamilySpec) (*TagFiller, error) {
+	tf := &TagFiller{}
+	for fi, family := range families {
+		for ti, spec := range family.GetTags() {
+			ft := filledTag{family: fi, tag: ti}
+			if dv := spec.GetDefaultValue(); dv != nil {
+				if t, isNull := tagValueTypeConv(dv); isNull || t != spec.GetType() {
+					return nil, errors.WithMessagef(errInvalidDerivation, "the default value of the tag %s isn't a %s", spec.GetName(), spec.GetType())
+				}
+				ft.defaultValue = dv
+				tf.defaults = append(tf.defaults, ft)
+			}
+			derivation := spec.GetDerivation()
+			if derivation == nil {
+				continue
+			}
+			var source *databasev1.TagSpec
+			ft.sourceFamily, ft.sourceTag, source = FindTagByName(families, derivation.GetSource())
+			if source == nil {
+				return nil, errors.WithMessagef(errInvalidDerivation, "the source %s of the tag %s isn't found", derivation.GetSource(), spec.GetName())
+			}
+			if source.GetDerivation() != nil {
+				return nil, errors.WithMessagef(errInvalidDerivation, "the source %s of the tag %s is derived", source.GetName(), spec.GetName())
+			}
+			derive, err := compileDerivation(derivation, source.GetType(), spec.GetType())
+			if err != nil {
+				return nil, errors.WithMessagef(err, "the tag %s", spec.GetName())
+			}
+			ft.derive = derive
+			tf.derived = append(tf.derived, ft)
+		}
+	}
+	if len(tf.defaults) == 0 && len(tf.derived) == 0 {
+		return nil, nil
+	}
+	return tf, nil
+}
+
+func compileDerivation(derivation *databasev1.TagDerivation, sourceType, tagType databasev1.TagType) (func(*modelv1.TagValue) *modelv1.TagValue, error) {
+	checkTypes := func(expression string, expected databasev1.TagType) error {
+		if sourceType != expected || tagType != expected {
+			return errors.WithMessagef(errInvalidDerivation, "the %s derives a %s from a %s, both should be %s",
+				expression, tagType, sourceType, expected)
+		}
+		return nil
+	}
+	switch e := derivation.GetExpression().(type) {
+	case *databasev1.TagDerivation_Divisor:
+		if e.Divisor <= 0 {
+			return nil, errors.WithMessagef(errInvalidDerivation, "the divisor %d isn't positive", e.Divisor)
+		}
+		if err := checkTypes("divisor", databasev1.TagType_TAG_TYPE_INT); err != nil {
+			return nil, err
+		}
+		return func(source *modelv1.TagValue) *modelv1.TagValue {
+			if source.GetInt() == nil {
+				return nil
+			}
+			return &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: source.GetInt().GetValue() / e.Divisor}}}
+		}, nil
+	case *databasev1.TagDerivation_PrefixLength:
+		if e.PrefixLength == 0 {
+			return nil, errors.WithMessage(errInvalidDerivation, "the prefix length is zero")
+		}
+		if err := checkTypes("prefix length", databasev1.TagType_TAG_TYPE_STRING); err != nil {
+			return nil, err
+		}
+		return func(source *modelv1.TagValue) *modelv1.TagValue {
+			if source.GetStr() == nil {
+				return nil
+			}
+			runes := []rune(source.GetStr().GetValue())
+			if len(runes) > int(e.PrefixLength) {
+				runes = runes[:e.PrefixLength]
+			}
+			return strTagValue(string(runes))
+		}, nil
+	case *databasev1.TagDerivation_Regexp:
+		re, err := regexp.Compile(e.Regexp)
+		if err != nil {
+			return nil, errors.WithMessagef(errInvalidDerivation, "the regexp %q is invalid: %v", e.Regexp, err)
+		}
+		if err = checkTypes("regexp", databasev1.TagType_TAG_TYPE_STRING); err != nil {
+			return nil, err
+		}
+		return func(source *modelv1.TagValue) *modelv1.TagValue {
+			if source.GetStr() == nil {
+				return nil
+			}
+			matches := re.FindStringSubmatch(source.GetStr().GetValue())
+			switch len(matches) {
+			case 0:
+				return nil
+			case 1:
+				return strTagValue(matches[0])
+			}
+			return strTagValue(matches[1])
+		}, nil
+	}
+	return nil, errors.WithMessage(errInvalidDerivation, "no expression")
+}
+
+func strTagValue(v string) *modelv1.TagValue {
+	return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: v}}}
+}
+
+// Fill returns the tag families with the tags which are absent or null replaced by their default values,
+// and the derived tags computed from their sources. The tag families passed in aren't modified.
+// A nil TagFiller returns them as they are.
+func (tf *TagFiller) Fill(families []*modelv1.TagFamilyForWrite) []*modelv1.TagFamilyForWrite {
+	if tf == nil {
+		return families
+	}
+	result := make([]*modelv1.TagFamilyForWrite, len(families))
+	copy(result, families)
+	cloned := make([]bool, len(families))
+	set := func(fi, ti int, value *modelv1.TagValue) {
+		for len(result) <= fi {
+			result = append(result, &modelv1.TagFamilyForWrite{})
+			cloned = append(cloned, true)
+		}
+		if !cloned[fi] {
+			result[fi] = &modelv1.TagFamilyForWrite{Tags: append([]*modelv1.TagValue(nil), result[fi].GetTags()...)}
+			cloned[fi] = true
+		}
+		for len(result[fi].Tags) <= ti {
+			result[fi].Tags = append(result[fi].Tags, NullTagValue)
+		}
+		result[fi].Tags[ti] = value
+	}
+	get := func(fi, ti int) *modelv1.TagValue {
+		if fi >= len(result) || ti >= len(result[fi].GetTags()) {
+			return nil
+		}
+		return result[fi].Tags[ti]
+	}
+	for _, t := range tf.defaults {
+		if isNullTagValue(get(t.family, t.tag)) {
+			set(t.family, t.tag, t.defaultValue)
+		}
+	}
+	// the sources are never derived, so the derivations see the default values of them
+	for _, t := range tf.derived {
+		var value *modelv1.TagValue
+		if source := get(t.sourceFamily, t.sourceTag); !isNullTagValue(source) {
+			value = t.derive(source)
+		}
+		if value == nil {
+			value = t.defaultValue
+		}
+		if value == nil {
+			if get(t.family, t.tag) == nil {
+				continue
+			}
+			value = NullTagValue
+		}
+		set(t.family, t.tag, value)
+	}
+	return result
+}
+
+func isNullTagValue(v *modelv1.TagValue) bool {
+	if v == nil {
+		return true
+	}
+	_, isNull := tagValueTypeConv(v)
+	return isNull
+}
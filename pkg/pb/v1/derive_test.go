@@ -0,0 +1,182 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package v1
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+func intTagValue(v int64) *modelv1.TagValue {
+	return &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: v}}}
+}
+
+func TestTagFiller(t *testing.T) {
+	families := []*databasev1.TagFamilySpec{
+		{
+			Name: "default",
+			Tags: []*databasev1.TagSpec{
+				{Name: "http_status", Type: databasev1.TagType_TAG_TYPE_INT},
+				{Name: "endpoint", Type: databasev1.TagType_TAG_TYPE_STRING, DefaultValue: strTagValue("unknown")},
+			},
+		},
+		{
+			Name: "derived",
+			Tags: []*databasev1.TagSpec{
+				{
+					Name: "http_status_class", Type: databasev1.TagType_TAG_TYPE_INT,
+					Derivation: &databasev1.TagDerivation{Source: "http_status", Expression: &databasev1.TagDerivation_Divisor{Divisor: 100}},
+				},
+				{
+					Name: "endpoint_prefix", Type: databasev1.TagType_TAG_TYPE_STRING,
+					Derivation: &databasev1.TagDerivation{Source: "endpoint", Expression: &databasev1.TagDerivation_PrefixLength{PrefixLength: 4}},
+				},
+				{
+					Name: "api_version", Type: databasev1.TagType_TAG_TYPE_STRING, DefaultValue: strTagValue("v0"),
+					Derivation: &databasev1.TagDerivation{Source: "endpoint", Expression: &databasev1.TagDerivation_Regexp{Regexp: `^/api/(v\d+)/`}},
+				},
+			},
+		},
+	}
+	tf, err := NewTagFiller(families)
+	require.NoError(t, err)
+	require.NotNil(t, tf)
+
+	tests := []struct {
+		name    string
+		written []*modelv1.TagFamilyForWrite
+		want    []*modelv1.TagFamilyForWrite
+	}{
+		{
+			name:    "derived from the written tags",
+			written: []*modelv1.TagFamilyForWrite{{Tags: []*modelv1.TagValue{intTagValue(404), strTagValue("/api/v2/users")}}},
+			want: []*modelv1.TagFamilyForWrite{
+				{Tags: []*modelv1.TagValue{intTagValue(404), strTagValue("/api/v2/users")}},
+				{Tags: []*modelv1.TagValue{intTagValue(4), strTagValue("/api"), strTagValue("v2")}},
+			},
+		},
+		{
+			name:    "derived from the default value",
+			written: []*modelv1.TagFamilyForWrite{{Tags: []*modelv1.TagValue{intTagValue(200), NullTagValue}}},
+			want: []*modelv1.TagFamilyForWrite{
+				{Tags: []*modelv1.TagValue{intTagValue(200), strTagValue("unknown")}},
+				{Tags: []*modelv1.TagValue{intTagValue(2), strTagValue("unkn"), strTagValue("v0")}},
+			},
+		},
+		{
+			name: "override the written derived tags",
+			written: []*modelv1.TagFamilyForWrite{
+				{Tags: []*modelv1.TagValue{NullTagValue, strTagValue("/health")}},
+				{Tags: []*modelv1.TagValue{intTagValue(5)}},
+			},
+			want: []*modelv1.TagFamilyForWrite{
+				{Tags: []*modelv1.TagValue{NullTagValue, strTagValue("/health")}},
+				{Tags: []*modelv1.TagValue{NullTagValue, strTagValue("/hea"), strTagValue("v0")}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			written := make([]*modelv1.TagFamilyForWrite, len(tt.written))
+			for i := range tt.written {
+				written[i] = &modelv1.TagFamilyForWrite{Tags: append([]*modelv1.TagValue(nil), tt.written[i].Tags...)}
+			}
+			got := tf.Fill(written)
+			assert.Empty(t, cmp.Diff(tt.want, got, protocmp.Transform()))
+			// the written tag families are kept
+			assert.Empty(t, cmp.Diff(tt.written, written, protocmp.Transform()))
+		})
+	}
+}
+
+func TestNewTagFiller(t *testing.T) {
+	tag := func(name string, tagType databasev1.TagType, derivation *databasev1.TagDerivation) *databasev1.TagSpec {
+		return &databasev1.TagSpec{Name: name, Type: tagType, Derivation: derivation}
+	}
+	divisor := func(source string, d int64) *databasev1.TagDerivation {
+		return &databasev1.TagDerivation{Source: source, Expression: &databasev1.TagDerivation_Divisor{Divisor: d}}
+	}
+	tests := []struct {
+		name    string
+		tags    []*databasev1.TagSpec
+		wantErr bool
+		wantNil bool
+	}{
+		{
+			name:    "no default or derivation",
+			tags:    []*databasev1.TagSpec{tag("status", databasev1.TagType_TAG_TYPE_INT, nil)},
+			wantNil: true,
+		},
+		{
+			name: "default value of another type",
+			tags: []*databasev1.TagSpec{
+				{Name: "status", Type: databasev1.TagType_TAG_TYPE_INT, DefaultValue: strTagValue("200")},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "absent source",
+			tags:    []*databasev1.TagSpec{tag("class", databasev1.TagType_TAG_TYPE_INT, divisor("status", 100))},
+			wantErr: true,
+		},
+		{
+			name: "derived source",
+			tags: []*databasev1.TagSpec{
+				tag("status", databasev1.TagType_TAG_TYPE_INT, nil),
+				tag("class", databasev1.TagType_TAG_TYPE_INT, divisor("status", 100)),
+				tag("group", databasev1.TagType_TAG_TYPE_INT, divisor("class", 2)),
+			},
+			wantErr: true,
+		},
+		{
+			name: "source of another type",
+			tags: []*databasev1.TagSpec{
+				tag("status", databasev1.TagType_TAG_TYPE_STRING, nil),
+				tag("class", databasev1.TagType_TAG_TYPE_INT, divisor("status", 100)),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid regexp",
+			tags: []*databasev1.TagSpec{
+				tag("endpoint", databasev1.TagType_TAG_TYPE_STRING, nil),
+				tag("version", databasev1.TagType_TAG_TYPE_STRING,
+					&databasev1.TagDerivation{Source: "endpoint", Expression: &databasev1.TagDerivation_Regexp{Regexp: "(v"}}),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tf, err := NewTagFiller([]*databasev1.TagFamilySpec{{Name: "default", Tags: tt.tags}})
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errInvalidDerivation)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantNil, tf == nil)
+		})
+	}
+}
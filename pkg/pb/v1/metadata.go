@@ -130,6 +130,23 @@ type TagProjection struct {
 	Names  []string
 }
 
+// TagPredicate is a predicate on a projected array tag, which is evaluated on the blocks
+// to drop the rows not matching before they're decoded.
+type TagPredicate struct {
+	Family string
+	Name   string
+	// Value is the element or the array of the elements the tag contains all of.
+	Value *modelv1.TagValue
+	// Not negates the predicate, then the tag doesn't contain some of the elements.
+	Not bool
+	// Equal makes the predicate an equality of a string or int64 tag to Value instead,
+	// which skips the blocks by the bloom filters of the tag. It's never negated.
+	Equal bool
+	// RangeOp makes the predicate a comparison of a string or int64 tag to Value by one of LT, LE, GT and GE instead,
+	// which skips the blocks by the min and max of the tag. It's never negated.
+	RangeOp modelv1.Condition_BinaryOp
+}
+
 // StreamQueryOptions is the options of a stream query.
 type StreamQueryOptions struct {
 	Name          string
@@ -138,6 +155,14 @@ type StreamQueryOptions struct {
 	Filter        index.Filter
 	Order         *OrderBy
 	TagProjection []TagProjection
+	ShardIDs      []common.ShardID
+	// TagPredicates are the predicates the rows have to match, in addition to Filter.
+	TagPredicates []TagPredicate
+	// MaxElementSize bounds the elements ordered by the tag of Order.Index, which are the top ones by the tag.
+	// It's ignored if the elements are ordered by timestamps.
+	MaxElementSize int
+	// After drops the elements ordered before the position and those at it returned already, if they're ordered by timestamps.
+	After *Cursor
 }
 
 // StreamSortOptions is the options of a stream sort.
@@ -148,6 +173,7 @@ type StreamSortOptions struct {
 	Filter         index.Filter
 	Order          *OrderBy
 	TagProjection  []TagProjection
+	ShardIDs       []common.ShardID
 	MaxElementSize int
 }
 
@@ -159,15 +185,69 @@ type StreamFilterOptions struct {
 	Filter         index.Filter
 	Order          *OrderBy
 	TagProjection  []TagProjection
+	ShardIDs       []common.ShardID
 	MaxElementSize int
 }
 
+// BlockStats counts the blocks and their rows.
+type BlockStats struct {
+	Blocks uint64
+	Rows   uint64
+}
+
+// Add adds the blocks and the rows of o.
+func (bs *BlockStats) Add(o BlockStats) {
+	bs.Blocks += o.Blocks
+	bs.Rows += o.Rows
+}
+
+// StreamScanStats is the statistics of the blocks a stream query scans, which its plan estimates the costs by.
+// The blocks are told by their metadata, i.e. the min and max of the tags and the bloom filters, rather than their rows,
+// then a block which might match counts all its rows.
+type StreamScanStats struct {
+	// Predicates are the blocks which might match each of the TagPredicates of the query, in the same order.
+	Predicates []BlockStats
+	// Total is all the blocks of the series in the time range.
+	Total BlockStats
+	// Matched is the blocks which might match all the TagPredicates.
+	Matched BlockStats
+	Parts   int
+}
+
+// Add adds the statistics of the blocks of o, which is another series of the same query.
+func (ss *StreamScanStats) Add(o StreamScanStats) {
+	if ss.Predicates == nil {
+		ss.Predicates = make([]BlockStats, len(o.Predicates))
+	}
+	for i := range o.Predicates {
+		ss.Predicates[i].Add(o.Predicates[i])
+	}
+	ss.Total.Add(o.Total)
+	ss.Matched.Add(o.Matched)
+	ss.Parts += o.Parts
+}
+
 // StreamQueryResult is the result of a stream query.
 type StreamQueryResult interface {
 	Pull() *StreamResult
 	Release()
 }
 
+// StreamElement is an element of a stream query result, each tag of which has a value.
+type StreamElement struct {
+	ElementID   string
+	TagFamilies []TagFamily
+	Timestamp   int64
+	SID         common.SeriesID
+}
+
+// StreamIterResult is the result of a stream query iterated element by element,
+// which copies an element at a time out of the blocks instead of a StreamResult of them.
+type StreamIterResult interface {
+	// Next returns the next element in the order of the query, or false once the result is exhausted.
+	Next() (StreamElement, bool)
+}
+
 // StreamSortResult is the result of a stream sort.
 type StreamSortResult interface {
 	Pull() *StreamColumnResult
@@ -187,6 +267,9 @@ type MeasureQueryOptions struct {
 	Order           *OrderBy
 	TagProjection   []TagProjection
 	FieldProjection []string
+	ShardIDs        []common.ShardID
+	// After drops the data points at or before the position, if they're ordered by timestamps.
+	After *Cursor
 }
 
 // MeasureQueryResult is the result of a measure query.
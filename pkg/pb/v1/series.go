@@ -18,6 +18,7 @@
 package v1
 
 import (
+	"bytes"
 	"sort"
 	"sync"
 
@@ -25,6 +26,7 @@ import (
 
 	"github.com/apache/skywalking-banyandb/api/common"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
 	"github.com/apache/skywalking-banyandb/pkg/convert"
 	"github.com/apache/skywalking-banyandb/pkg/index/posting"
 	"github.com/apache/skywalking-banyandb/pkg/index/posting/roaring"
@@ -51,6 +53,25 @@ func (s *Series) Marshal() error {
 	return nil
 }
 
+// MarshalPrefix encodes the subject and the entity values to internal Buffer, followed by valuePrefix
+// as the beginning of the string value of the next entity tag. The series whose entity values start with them
+// have the marshaled entities prefixed by Buffer. ID isn't generated.
+func (s *Series) MarshalPrefix(valuePrefix string) error {
+	s.Buffer = marshalEntityValue(s.Buffer, convert.StringToBytes(s.Subject))
+	var err error
+	for _, tv := range s.EntityValues {
+		if s.Buffer, err = marshalTagValue(s.Buffer, tv); err != nil {
+			return errors.WithMessage(err, "marshal subject and entity values")
+		}
+	}
+	if valuePrefix == "" {
+		return nil
+	}
+	s.Buffer = append(s.Buffer, byte(ValueTypeStr))
+	s.Buffer = appendEscapedEntityValue(s.Buffer, convert.StringToBytes(valuePrefix))
+	return nil
+}
+
 // Unmarshal decodes series from internal Buffer.
 func (s *Series) Unmarshal(src []byte) error {
 	var err error
@@ -154,3 +175,63 @@ func (a SeriesList) ToList() posting.List {
 	}
 	return pl
 }
+
+// DefaultTopNSeries is the number of the series returned by the top-n series if the request doesn't set it.
+const DefaultTopNSeries = 10
+
+// TopNSeriesStatistics sorts the statistics of the series in descending order of orderBy, and keeps the top n of them.
+func TopNSeriesStatistics(stats []*streamv1.SeriesStatistics, orderBy streamv1.TopNSeriesRequest_OrderBy, n uint32) []*streamv1.SeriesStatistics {
+	byCount := orderBy == streamv1.TopNSeriesRequest_ORDER_BY_COUNT
+	sort.SliceStable(stats, func(i, j int) bool {
+		if byCount {
+			return stats[i].GetElements() > stats[j].GetElements()
+		}
+		return stats[i].GetCompressedBytes() > stats[j].GetCompressedBytes()
+	})
+	if n == 0 {
+		n = DefaultTopNSeries
+	}
+	if len(stats) > int(n) {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// DefaultSearchSeriesLimit is the number of the series returned by a series search if the request doesn't set it.
+const DefaultSearchSeriesLimit = 20
+
+// MergeSeriesEntities sorts the entities of the series of the subject in order of their marshaled values,
+// which is the order the series index returns them, and drops the duplicated ones. The first limit ones are kept.
+// If there are more series than the ones kept, which are either dropped by the limit or told by more,
+// the marshaled value of the last one kept is returned as the cursor of the next page.
+func MergeSeriesEntities(subject string, entities []*streamv1.SeriesEntity, limit uint32, more bool) ([]*streamv1.SeriesEntity, []byte, error) {
+	if limit == 0 {
+		limit = DefaultSearchSeriesLimit
+	}
+	keys := make(map[*streamv1.SeriesEntity][]byte, len(entities))
+	for _, e := range entities {
+		s := Series{Subject: subject, EntityValues: e.GetEntity()}
+		if err := s.Marshal(); err != nil {
+			return nil, nil, err
+		}
+		keys[e] = s.Buffer
+	}
+	sort.SliceStable(entities, func(i, j int) bool {
+		return bytes.Compare(keys[entities[i]], keys[entities[j]]) < 0
+	})
+	result := entities[:0]
+	for _, e := range entities {
+		if n := len(result); n > 0 && bytes.Equal(keys[result[n-1]], keys[e]) {
+			continue
+		}
+		result = append(result, e)
+	}
+	if len(result) > int(limit) {
+		result = result[:limit]
+		more = true
+	}
+	if !more || len(result) == 0 {
+		return result, nil, nil
+	}
+	return result, keys[result[len(result)-1]], nil
+}
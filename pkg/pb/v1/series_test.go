@@ -18,11 +18,13 @@
 package v1
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
 )
 
 func TestMarshalAndUnmarshalEntityValue(t *testing.T) {
@@ -118,3 +120,71 @@ func TestMarshalAndUnmarshalSeries(t *testing.T) {
 		})
 	}
 }
+
+func TestTopNSeriesStatistics(t *testing.T) {
+	newStats := func() []*streamv1.SeriesStatistics {
+		return []*streamv1.SeriesStatistics{
+			{Elements: 1, CompressedBytes: 300},
+			{Elements: 3, CompressedBytes: 100},
+			{Elements: 2, CompressedBytes: 200},
+		}
+	}
+	got := TopNSeriesStatistics(newStats(), streamv1.TopNSeriesRequest_ORDER_BY_UNSPECIFIED, 2)
+	assert.Len(t, got, 2)
+	assert.Equal(t, uint64(300), got[0].GetCompressedBytes())
+	assert.Equal(t, uint64(200), got[1].GetCompressedBytes())
+
+	got = TopNSeriesStatistics(newStats(), streamv1.TopNSeriesRequest_ORDER_BY_COUNT, 0)
+	assert.Len(t, got, 3)
+	assert.Equal(t, uint64(3), got[0].GetElements())
+	assert.Equal(t, uint64(1), got[2].GetElements())
+}
+
+func TestSeries_MarshalPrefix(t *testing.T) {
+	str := func(v string) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: v}}}
+	}
+	marshal := func(values ...*modelv1.TagValue) []byte {
+		s := &Series{Subject: "sw", EntityValues: values}
+		assert.NoError(t, s.Marshal())
+		return s.Buffer
+	}
+	prefix := func(valuePrefix string, values ...*modelv1.TagValue) []byte {
+		s := &Series{Subject: "sw", EntityValues: values}
+		assert.NoError(t, s.MarshalPrefix(valuePrefix))
+		return s.Buffer
+	}
+	assert.True(t, bytes.HasPrefix(marshal(str("svc|1"), str("inst")), prefix("svc|")))
+	assert.True(t, bytes.HasPrefix(marshal(str("svc|1"), str("inst")), prefix("", str("svc|1"))))
+	assert.True(t, bytes.HasPrefix(marshal(str("svc|1"), str("inst")), prefix("in", str("svc|1"))))
+	assert.False(t, bytes.HasPrefix(marshal(str("svc|1"), str("inst")), prefix("svc|2")))
+	assert.False(t, bytes.HasPrefix(marshal(str("svc"), str("inst")), prefix("", str("sv"))))
+}
+
+func TestMergeSeriesEntities(t *testing.T) {
+	entity := func(v string) *streamv1.SeriesEntity {
+		return &streamv1.SeriesEntity{Entity: []*modelv1.TagValue{{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: v}}}}}
+	}
+	values := func(entities []*streamv1.SeriesEntity) []string {
+		var result []string
+		for _, e := range entities {
+			result = append(result, e.GetEntity()[0].GetStr().GetValue())
+		}
+		return result
+	}
+	got, cursor, err := MergeSeriesEntities("sw", []*streamv1.SeriesEntity{entity("b"), entity("a"), entity("c"), entity("a")}, 2, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, values(got))
+	s := &Series{Subject: "sw", EntityValues: entity("b").GetEntity()}
+	assert.NoError(t, s.Marshal())
+	assert.Equal(t, s.Buffer, cursor)
+
+	got, cursor, err = MergeSeriesEntities("sw", []*streamv1.SeriesEntity{entity("b"), entity("a")}, 0, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, values(got))
+	assert.Nil(t, cursor)
+
+	_, cursor, err = MergeSeriesEntities("sw", []*streamv1.SeriesEntity{entity("a")}, 0, true)
+	assert.NoError(t, err)
+	assert.NotNil(t, cursor, "a data node has more series")
+}
@@ -19,6 +19,9 @@ package v1
 
 import (
 	"bytes"
+	"cmp"
+	"slices"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -42,6 +45,18 @@ const (
 	ValueTypeInt64Arr
 )
 
+// ElementType returns the type of the elements of an array type, or ValueTypeUnknown if vt isn't an array.
+func (vt ValueType) ElementType() ValueType {
+	switch vt {
+	case ValueTypeStrArr:
+		return ValueTypeStr
+	case ValueTypeInt64Arr:
+		return ValueTypeInt64
+	default:
+		return ValueTypeUnknown
+	}
+}
+
 // MustTagValueToValueType converts modelv1.TagValue to ValueType.
 func MustTagValueToValueType(tag *modelv1.TagValue) ValueType {
 	switch tag.Value.(type) {
@@ -140,14 +155,15 @@ const (
 var anyWildcard = []byte{'*'}
 
 func marshalEntityValue(dest, src []byte) []byte {
-	if src == nil {
-		dest = append(dest, entityDelimiter)
-		return dest
-	}
+	dest = appendEscapedEntityValue(dest, src)
+	dest = append(dest, entityDelimiter)
+	return dest
+}
+
+// appendEscapedEntityValue appends src without the delimiter, which is a prefix of the marshaled values starting with src.
+func appendEscapedEntityValue(dest, src []byte) []byte {
 	if bytes.IndexByte(src, entityDelimiter) < 0 && bytes.IndexByte(src, escape) < 0 {
-		dest = append(dest, src...)
-		dest = append(dest, entityDelimiter)
-		return dest
+		return append(dest, src...)
 	}
 	for _, b := range src {
 		if b == entityDelimiter || b == escape {
@@ -155,7 +171,6 @@ func marshalEntityValue(dest, src []byte) []byte {
 		}
 		dest = append(dest, b)
 	}
-	dest = append(dest, entityDelimiter)
 	return dest
 }
 
@@ -219,3 +234,31 @@ func MustCompareTagValue(tv1, tv2 *modelv1.TagValue) int {
 		return 0
 	}
 }
+
+// DefaultTagValuesLimit is the number of the values returned by a tag value enumeration if the request doesn't set it.
+const DefaultTagValuesLimit = 100
+
+// MergeTagValues sorts the string or int values of a tag in ascending order, and drops the duplicated ones.
+// The first limit ones are kept, and the result is truncated if some are dropped by the limit or truncated tells so.
+func MergeTagValues(values []*modelv1.TagValue, limit uint32, truncated bool) ([]*modelv1.TagValue, bool) {
+	if limit == 0 {
+		limit = DefaultTagValuesLimit
+	}
+	compare := func(a, b *modelv1.TagValue) int {
+		if c := cmp.Compare(MustTagValueToValueType(a), MustTagValueToValueType(b)); c != 0 {
+			return c
+		}
+		if a.GetInt() != nil {
+			return cmp.Compare(a.GetInt().GetValue(), b.GetInt().GetValue())
+		}
+		return strings.Compare(a.GetStr().GetValue(), b.GetStr().GetValue())
+	}
+	slices.SortFunc(values, compare)
+	values = slices.CompactFunc(values, func(a, b *modelv1.TagValue) bool {
+		return compare(a, b) == 0
+	})
+	if len(values) > int(limit) {
+		values, truncated = values[:limit], true
+	}
+	return values, truncated
+}
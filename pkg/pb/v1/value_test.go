@@ -74,3 +74,22 @@ func TestMarshalAndUnmarshalTagValue(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeTagValues(t *testing.T) {
+	str := func(v string) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: v}}}
+	}
+	integer := func(v int64) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Int{Int: &modelv1.Int{Value: v}}}
+	}
+	got, truncated := MergeTagValues([]*modelv1.TagValue{str("b"), str("a"), str("c"), str("a")}, 2, false)
+	assert.True(t, truncated)
+	assert.Equal(t, []*modelv1.TagValue{str("a"), str("b")}, got)
+
+	got, truncated = MergeTagValues([]*modelv1.TagValue{integer(3), integer(-1), integer(3)}, 0, false)
+	assert.False(t, truncated)
+	assert.Equal(t, []*modelv1.TagValue{integer(-1), integer(3)}, got)
+
+	_, truncated = MergeTagValues([]*modelv1.TagValue{str("a")}, 0, true)
+	assert.True(t, truncated, "a data node has more values")
+}
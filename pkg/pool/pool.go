@@ -0,0 +1,81 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package pool bounds the memory retained by the pools of reusable objects.
+package pool
+
+import (
+	"sync/atomic"
+
+	"github.com/apache/skywalking-banyandb/pkg/meter"
+)
+
+// Capacity decides whether an object released to a pool is retained.
+// An object whose backing capacity exceeds the limit is dropped, so that the memory
+// needed by a huge workload is given back to the runtime after it's done.
+// A nil Capacity or one without a limit retains all the objects, unless the limits are shrunk.
+type Capacity struct {
+	dropped meter.Counter
+	name    string
+	max     atomic.Int64
+}
+
+// shrunk overrides the limits of all the Capacities while the process is short of memory.
+var shrunk atomic.Int64
+
+// Shrink lowers the limits of all the Capacities to n bytes until Restore is called.
+func Shrink(n int64) {
+	shrunk.Store(n)
+}
+
+// Restore gives the Capacities back their own limits.
+func Restore() {
+	shrunk.Store(0)
+}
+
+// NewCapacity returns a Capacity counting the dropped objects of the named pool by dropped.
+func NewCapacity(dropped meter.Counter, name string) *Capacity {
+	return &Capacity{dropped: dropped, name: name}
+}
+
+// SetMax sets the max bytes of an object's backing capacity. Non-positive means unbounded.
+func (c *Capacity) SetMax(n int64) {
+	c.max.Store(n)
+}
+
+// Max returns the max bytes of an object's backing capacity.
+func (c *Capacity) Max() int64 {
+	return c.max.Load()
+}
+
+// Retain returns false if an object holding the bytes of capacity should be dropped.
+func (c *Capacity) Retain(capacity int) bool {
+	if c == nil {
+		return true
+	}
+	limit := c.max.Load()
+	if s := shrunk.Load(); s > 0 && (limit <= 0 || s < limit) {
+		limit = s
+	}
+	if limit <= 0 || int64(capacity) <= limit {
+		return true
+	}
+	if c.dropped != nil {
+		c.dropped.Inc(1, c.name)
+	}
+	return false
+}
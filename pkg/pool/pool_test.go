@@ -0,0 +1,47 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapacityRetain(t *testing.T) {
+	var unbounded *Capacity
+	assert.True(t, unbounded.Retain(1<<30))
+	c := NewCapacity(nil, "test")
+	assert.True(t, c.Retain(1<<30), "the limit isn't set")
+	c.SetMax(1024)
+	assert.True(t, c.Retain(1024))
+	assert.False(t, c.Retain(1025))
+}
+
+func TestShrink(t *testing.T) {
+	defer Restore()
+	c := NewCapacity(nil, "test")
+	Shrink(64)
+	assert.False(t, c.Retain(65), "an unbounded Capacity is shrunk")
+	c.SetMax(32)
+	assert.False(t, c.Retain(33), "the lower limit of the Capacity wins")
+	Restore()
+	assert.True(t, c.Retain(32))
+	c.SetMax(0)
+	assert.True(t, c.Retain(1<<30))
+}
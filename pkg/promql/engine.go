@@ -0,0 +1,279 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package promql
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MaxPoints is the max number of the evaluations of a query, which is the same as the one of Prometheus.
+const MaxPoints = 11000
+
+// ErrTooManyPoints indicates the query is evaluated at too many steps.
+var ErrTooManyPoints = errors.Errorf("exceeded the max resolution of %d points per series, try a larger step", MaxPoints)
+
+// Label is a label of a series.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Labels are the labels of a series sorted by their names.
+type Labels []Label
+
+// NewLabels returns the labels of the map except the ones of the empty values.
+func NewLabels(m map[string]string) Labels {
+	ls := make(Labels, 0, len(m))
+	for name, value := range m {
+		if value != "" {
+			ls = append(ls, Label{Name: name, Value: value})
+		}
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].Name < ls[j].Name })
+	return ls
+}
+
+// Get returns the value of the label, which is empty if the label is absent.
+func (ls Labels) Get(name string) string {
+	for _, l := range ls {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// Map returns the labels as a map.
+func (ls Labels) Map() map[string]string {
+	m := make(map[string]string, len(ls))
+	for _, l := range ls {
+		m[l.Name] = l.Value
+	}
+	return m
+}
+
+// Matches returns whether the labels match all the matchers.
+func (ls Labels) Matches(matchers []*Matcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(ls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ls Labels) String() string {
+	ss := make([]string, 0, len(ls))
+	for _, l := range ls {
+		ss = append(ss, l.Name+"="+strconv.Quote(l.Value))
+	}
+	return "{" + strings.Join(ss, ", ") + "}"
+}
+
+func (ls Labels) filter(keep func(name string) bool) Labels {
+	result := make(Labels, 0, len(ls))
+	for _, l := range ls {
+		if keep(l.Name) {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// Sample is the value of a series at the timestamp in milliseconds.
+type Sample struct {
+	T int64
+	V float64
+}
+
+// Series is the samples of the labels in the ascending order of their timestamps.
+type Series struct {
+	Labels  Labels
+	Samples []Sample
+}
+
+// Querier selects the series of the selectors.
+type Querier interface {
+	// Select returns the series of the selector with the samples in [start, end].
+	Select(ctx context.Context, selector *VectorSelector, start, end time.Time) ([]Series, error)
+}
+
+// Eval evaluates the expression at every step from start to end, and returns the series of the results
+// in the order of their labels. The value of a selector without a range at a step is the latest sample of a series
+// within the lookback before the step, and the series without such a sample are absent at the step.
+func Eval(ctx context.Context, q Querier, expr Expr, start, end time.Time, step, lookback time.Duration) ([]Series, error) {
+	if end.Before(start) {
+		return nil, errors.New("the end should not be before the start")
+	}
+	if step <= 0 {
+		return nil, errors.New("the step should be positive")
+	}
+	if end.Sub(start)/step >= MaxPoints {
+		return nil, ErrTooManyPoints
+	}
+	ev := &evaluator{selected: make(map[*VectorSelector][]Series), lookback: lookback.Milliseconds()}
+	for _, s := range Selectors(expr) {
+		window := lookback
+		if s.Range > 0 {
+			window = s.Range
+		}
+		series, err := q.Select(ctx, s, start.Add(-window), end)
+		if err != nil {
+			return nil, err
+		}
+		ev.selected[s] = series
+	}
+	results := make(map[string]*Series)
+	for t := start; !t.After(end); t = t.Add(step) {
+		ts := t.UnixMilli()
+		for _, v := range ev.eval(expr, ts) {
+			key := v.labels.String()
+			s, ok := results[key]
+			if !ok {
+				s = &Series{Labels: v.labels}
+				results[key] = s
+			}
+			s.Samples = append(s.Samples, Sample{T: ts, V: v.value})
+		}
+	}
+	keys := make([]string, 0, len(results))
+	for k := range results {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	series := make([]Series, 0, len(keys))
+	for _, k := range keys {
+		series = append(series, *results[k])
+	}
+	return series, nil
+}
+
+// element is the value of a series at a step.
+type element struct {
+	labels Labels
+	value  float64
+}
+
+type evaluator struct {
+	selected map[*VectorSelector][]Series
+	lookback int64
+}
+
+func (ev *evaluator) eval(expr Expr, ts int64) []element {
+	switch e := expr.(type) {
+	case *VectorSelector:
+		return ev.window(e, ts, ev.lookback, func(labels Labels, samples []Sample) element {
+			return element{labels: labels, value: samples[len(samples)-1].V}
+		})
+	case *Call:
+		return ev.window(e.Arg, ts, e.Arg.Range.Milliseconds(), func(labels Labels, samples []Sample) element {
+			return element{labels: dropMetricName(labels), value: overTime(e.Func, samples)}
+		})
+	case *Aggregate:
+		return aggregate(e, ev.eval(e.Expr, ts))
+	}
+	return nil
+}
+
+// window calls fn with the samples of every series in (ts-width, ts], and skips the series without such samples.
+func (ev *evaluator) window(s *VectorSelector, ts, width int64, fn func(labels Labels, samples []Sample) element) []element {
+	var result []element
+	for _, series := range ev.selected[s] {
+		end := sort.Search(len(series.Samples), func(i int) bool { return series.Samples[i].T > ts })
+		start := sort.Search(end, func(i int) bool { return series.Samples[i].T > ts-width })
+		if start < end {
+			result = append(result, fn(series.Labels, series.Samples[start:end]))
+		}
+	}
+	return result
+}
+
+func overTime(fn string, samples []Sample) float64 {
+	switch fn {
+	case "count_over_time":
+		return float64(len(samples))
+	case "last_over_time":
+		return samples[len(samples)-1].V
+	}
+	values := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		values = append(values, s.V)
+	}
+	return reduce(strings.TrimSuffix(fn, "_over_time"), values)
+}
+
+func aggregate(a *Aggregate, elements []element) []element {
+	grouping := make(map[string]bool, len(a.Grouping))
+	for _, name := range a.Grouping {
+		grouping[name] = true
+	}
+	groups := make(map[string]*element)
+	values := make(map[string][]float64)
+	var keys []string
+	for _, e := range elements {
+		labels := e.labels.filter(func(name string) bool {
+			return name != MetricNameLabel && grouping[name] != a.Without
+		})
+		key := labels.String()
+		if _, ok := groups[key]; !ok {
+			groups[key] = &element{labels: labels}
+			keys = append(keys, key)
+		}
+		values[key] = append(values[key], e.value)
+	}
+	result := make([]element, 0, len(keys))
+	for _, k := range keys {
+		g := groups[k]
+		g.value = reduce(a.Op, values[k])
+		result = append(result, *g)
+	}
+	return result
+}
+
+// reduce aggregates the values by sum, avg, min, max or count.
+func reduce(op string, values []float64) float64 {
+	if op == "count" {
+		return float64(len(values))
+	}
+	result := values[0]
+	for _, v := range values[1:] {
+		switch op {
+		case "min":
+			result = math.Min(result, v)
+		case "max":
+			result = math.Max(result, v)
+		default:
+			result += v
+		}
+	}
+	if op == "avg" {
+		result /= float64(len(values))
+	}
+	return result
+}
+
+func dropMetricName(labels Labels) Labels {
+	return labels.filter(func(name string) bool { return name != MetricNameLabel })
+}
@@ -0,0 +1,128 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package promql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeQuerier []Series
+
+func (q fakeQuerier) Select(_ context.Context, s *VectorSelector, start, end time.Time) ([]Series, error) {
+	var result []Series
+	for _, series := range q {
+		if series.Labels.Get(MetricNameLabel) != s.Name || !series.Labels.Matches(s.Matchers) {
+			continue
+		}
+		var samples []Sample
+		for _, sample := range series.Samples {
+			if sample.T >= start.UnixMilli() && sample.T <= end.UnixMilli() {
+				samples = append(samples, sample)
+			}
+		}
+		result = append(result, Series{Labels: series.Labels, Samples: samples})
+	}
+	return result, nil
+}
+
+func TestEval(t *testing.T) {
+	minute := time.Minute.Milliseconds()
+	q := fakeQuerier{
+		{
+			Labels:  NewLabels(map[string]string{MetricNameLabel: "cpm", "service": "a", "instance": "1"}),
+			Samples: []Sample{{T: 0, V: 1}, {T: minute, V: 2}, {T: 2 * minute, V: 3}},
+		},
+		{
+			Labels:  NewLabels(map[string]string{MetricNameLabel: "cpm", "service": "a", "instance": "2"}),
+			Samples: []Sample{{T: 0, V: 10}, {T: 2 * minute, V: 30}},
+		},
+		{
+			Labels:  NewLabels(map[string]string{MetricNameLabel: "cpm", "service": "b", "instance": "3"}),
+			Samples: []Sample{{T: minute, V: 100}},
+		},
+	}
+	tests := []struct {
+		query string
+		want  map[string][]Sample
+	}{
+		{
+			query: `cpm{service="a"}`,
+			want: map[string][]Sample{
+				`{__name__="cpm", instance="1", service="a"}`: {{T: 0, V: 1}, {T: minute, V: 2}, {T: 2 * minute, V: 3}},
+				`{__name__="cpm", instance="2", service="a"}`: {{T: 0, V: 10}, {T: minute, V: 10}, {T: 2 * minute, V: 30}},
+			},
+		},
+		{
+			query: `cpm{service!="a"}`,
+			want: map[string][]Sample{
+				`{__name__="cpm", instance="3", service="b"}`: {{T: minute, V: 100}, {T: 2 * minute, V: 100}},
+			},
+		},
+		{
+			query: "sum by (service) (cpm)",
+			want: map[string][]Sample{
+				`{service="a"}`: {{T: 0, V: 11}, {T: minute, V: 12}, {T: 2 * minute, V: 33}},
+				`{service="b"}`: {{T: minute, V: 100}, {T: 2 * minute, V: 100}},
+			},
+		},
+		{
+			query: "count without (instance, service) (cpm)",
+			want: map[string][]Sample{
+				`{}`: {{T: 0, V: 2}, {T: minute, V: 3}, {T: 2 * minute, V: 3}},
+			},
+		},
+		{
+			query: `max_over_time(cpm{instance=~"1|2"}[90s])`,
+			want: map[string][]Sample{
+				`{instance="1", service="a"}`: {{T: 0, V: 1}, {T: minute, V: 2}, {T: 2 * minute, V: 3}},
+				`{instance="2", service="a"}`: {{T: 0, V: 10}, {T: minute, V: 10}, {T: 2 * minute, V: 30}},
+			},
+		},
+		{
+			query: `avg(avg_over_time(cpm{service="a"}[2m]))`,
+			want: map[string][]Sample{
+				`{}`: {{T: 0, V: 5.5}, {T: minute, V: 5.75}, {T: 2 * minute, V: 16.25}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			expr, err := Parse(tt.query)
+			require.NoError(t, err)
+			got, err := Eval(context.Background(), q, expr, time.UnixMilli(0), time.UnixMilli(2*minute), time.Minute, 90*time.Second)
+			require.NoError(t, err)
+			result := make(map[string][]Sample, len(got))
+			for _, s := range got {
+				result[s.Labels.String()] = s.Samples
+			}
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
+
+func TestEvalTooManyPoints(t *testing.T) {
+	expr, err := Parse("cpm")
+	require.NoError(t, err)
+	_, err = Eval(context.Background(), fakeQuerier{}, expr, time.UnixMilli(0), time.UnixMilli(0).Add(MaxPoints*time.Second), time.Second, time.Minute)
+	assert.ErrorIs(t, err, ErrTooManyPoints)
+}
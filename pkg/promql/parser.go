@@ -0,0 +1,466 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package promql
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MetricNameLabel is the label of the metric name.
+const MetricNameLabel = "__name__"
+
+var (
+	// ErrSyntax indicates the query isn't in the subset of PromQL.
+	ErrSyntax = errors.New("invalid query")
+
+	aggregations = map[string]bool{"sum": true, "avg": true, "min": true, "max": true, "count": true}
+	// functions are the functions over time, which take a range selector as the argument.
+	functions = map[string]bool{
+		"sum_over_time": true, "avg_over_time": true, "min_over_time": true,
+		"max_over_time": true, "count_over_time": true, "last_over_time": true,
+	}
+)
+
+// MatchType is the operator of a label matcher.
+type MatchType int
+
+// The operators of the label matchers.
+const (
+	MatchEqual MatchType = iota
+	MatchNotEqual
+	MatchRegexp
+	MatchNotRegexp
+)
+
+func (t MatchType) String() string {
+	switch t {
+	case MatchNotEqual:
+		return "!="
+	case MatchRegexp:
+		return "=~"
+	case MatchNotRegexp:
+		return "!~"
+	default:
+		return "="
+	}
+}
+
+// Matcher matches the value of a label. An absent label matches as the empty value.
+type Matcher struct {
+	re    *regexp.Regexp
+	Name  string
+	Value string
+	Type  MatchType
+}
+
+// NewMatcher returns the matcher of the label, whose regular expression is anchored at both ends.
+func NewMatcher(t MatchType, name, value string) (*Matcher, error) {
+	m := &Matcher{Type: t, Name: name, Value: value}
+	if t == MatchRegexp || t == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, errors.WithMessagef(ErrSyntax, "invalid regular expression of %s: %v", name, err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+// Matches returns whether the value matches.
+func (m *Matcher) Matches(value string) bool {
+	switch m.Type {
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	default:
+		return value == m.Value
+	}
+}
+
+func (m *Matcher) String() string {
+	return m.Name + m.Type.String() + strconv.Quote(m.Value)
+}
+
+// Expr is an expression of the subset of PromQL.
+type Expr interface {
+	fmt.Stringer
+}
+
+// VectorSelector selects the series of the metric whose labels match all the matchers.
+// A selector with a range selects the samples within the range before each evaluation,
+// which is only allowed as the argument of a function over time.
+type VectorSelector struct {
+	Name     string
+	Matchers []*Matcher
+	Range    time.Duration
+}
+
+func (s *VectorSelector) String() string {
+	ss := make([]string, 0, len(s.Matchers))
+	for _, m := range s.Matchers {
+		ss = append(ss, m.String())
+	}
+	str := s.Name
+	if len(ss) > 0 {
+		str += "{" + strings.Join(ss, ", ") + "}"
+	}
+	if s.Range > 0 {
+		str += "[" + s.Range.String() + "]"
+	}
+	return str
+}
+
+// Call is a function over time, e.g. avg_over_time(metric[5m]).
+type Call struct {
+	Arg  *VectorSelector
+	Func string
+}
+
+func (c *Call) String() string {
+	return c.Func + "(" + c.Arg.String() + ")"
+}
+
+// Aggregate aggregates the series of the expression by the labels in the grouping, or by the labels
+// except the ones in the grouping if Without is set, e.g. sum by (service) (metric).
+type Aggregate struct {
+	Expr     Expr
+	Op       string
+	Grouping []string
+	Without  bool
+}
+
+func (a *Aggregate) String() string {
+	str := a.Op
+	if a.Without {
+		str += " without (" + strings.Join(a.Grouping, ", ") + ")"
+	} else if len(a.Grouping) > 0 {
+		str += " by (" + strings.Join(a.Grouping, ", ") + ")"
+	}
+	return str + " (" + a.Expr.String() + ")"
+}
+
+// Parse parses the query, which is one of the following:
+//   - a selector, e.g. metric{label="value", label=~"regexp"}, or {__name__="metric"}.
+//   - a function over time of a range selector, e.g. avg_over_time(metric[5m]),
+//     whose functions are sum, avg, min, max, count and last over time.
+//   - an aggregation of the above or of another aggregation, e.g. sum by (label) (metric),
+//     whose operators are sum, avg, min, max and count.
+func Parse(query string) (Expr, error) {
+	p := &parser{input: query}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return nil, p.errorf("unexpected %q", p.input[p.pos:])
+	}
+	if s, ok := expr.(*VectorSelector); ok && s.Range > 0 {
+		return nil, p.errorf("the range selector %s is only allowed in a function over time", s)
+	}
+	return expr, nil
+}
+
+// Selectors returns the selectors in the expression.
+func Selectors(expr Expr) []*VectorSelector {
+	switch e := expr.(type) {
+	case *VectorSelector:
+		return []*VectorSelector{e}
+	case *Call:
+		return []*VectorSelector{e.Arg}
+	case *Aggregate:
+		return Selectors(e.Expr)
+	}
+	return nil
+}
+
+var durationUnits = map[string]time.Duration{
+	"ms": time.Millisecond, "s": time.Second, "m": time.Minute, "h": time.Hour,
+	"d": 24 * time.Hour, "w": 7 * 24 * time.Hour, "y": 365 * 24 * time.Hour,
+}
+
+// ParseDuration parses a duration of PromQL, which is a sequence of the integers with the units, e.g. 1h30m.
+// The units are ms, s, m, h, d, w and y, which are from the largest to the smallest in a duration.
+func ParseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, errors.New("empty duration")
+	}
+	var d time.Duration
+	last := time.Duration(math.MaxInt64)
+	for rest := s; rest != ""; {
+		i := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+		if i <= 0 {
+			return 0, errors.Errorf("invalid duration %q", s)
+		}
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, errors.Errorf("invalid duration %q", s)
+		}
+		rest = rest[i:]
+		j := strings.IndexFunc(rest, func(r rune) bool { return r >= '0' && r <= '9' })
+		if j < 0 {
+			j = len(rest)
+		}
+		unit, ok := durationUnits[rest[:j]]
+		if !ok || unit >= last {
+			return 0, errors.Errorf("invalid unit of the duration %q", s)
+		}
+		last = unit
+		d += time.Duration(n) * unit
+		rest = rest[j:]
+	}
+	return d, nil
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	p.skipSpaces()
+	if p.peek() == '{' {
+		return p.parseSelector("")
+	}
+	ident := p.ident()
+	if ident == "" {
+		return nil, p.errorf("expected a metric name, a function or an aggregation")
+	}
+	switch {
+	case aggregations[ident]:
+		return p.parseAggregate(ident)
+	case functions[ident] && p.next('('):
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		s, ok := arg.(*VectorSelector)
+		if !ok || s.Range == 0 {
+			return nil, p.errorf("%s expects a range selector, e.g. metric[5m]", ident)
+		}
+		if !p.next(')') {
+			return nil, p.errorf("expected ) of %s", ident)
+		}
+		return &Call{Func: ident, Arg: s}, nil
+	}
+	return p.parseSelector(ident)
+}
+
+func (p *parser) parseAggregate(op string) (Expr, error) {
+	a := &Aggregate{Op: op}
+	grouping, err := p.parseGrouping(a)
+	if err != nil {
+		return nil, err
+	}
+	if !p.next('(') {
+		return nil, p.errorf("expected ( of %s", op)
+	}
+	if a.Expr, err = p.parseExpr(); err != nil {
+		return nil, err
+	}
+	if s, ok := a.Expr.(*VectorSelector); ok && s.Range > 0 {
+		return nil, p.errorf("%s expects an instant vector rather than the range selector %s", op, s)
+	}
+	if !p.next(')') {
+		return nil, p.errorf("expected ) of %s", op)
+	}
+	if grouping {
+		return a, nil
+	}
+	if _, err = p.parseGrouping(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// parseGrouping parses the optional clause "by (labels)" or "without (labels)", and returns whether it's present.
+func (p *parser) parseGrouping(a *Aggregate) (bool, error) {
+	p.skipSpaces()
+	start := p.pos
+	switch p.ident() {
+	case "by":
+	case "without":
+		a.Without = true
+	default:
+		p.pos = start
+		return false, nil
+	}
+	if !p.next('(') {
+		return false, p.errorf("expected ( of the grouping")
+	}
+	for !p.next(')') {
+		p.skipSpaces()
+		label := p.ident()
+		if label == "" {
+			return false, p.errorf("expected a label of the grouping")
+		}
+		a.Grouping = append(a.Grouping, label)
+		if !p.next(',') && p.peek() != ')' {
+			return false, p.errorf("expected , or ) of the grouping")
+		}
+	}
+	return true, nil
+}
+
+func (p *parser) parseSelector(name string) (Expr, error) {
+	s := &VectorSelector{Name: name}
+	if p.next('{') {
+		for !p.next('}') {
+			m, err := p.parseMatcher()
+			if err != nil {
+				return nil, err
+			}
+			if m.Name != MetricNameLabel {
+				s.Matchers = append(s.Matchers, m)
+			} else if m.Type != MatchEqual || s.Name != "" {
+				return nil, p.errorf("the metric name should be selected once by =")
+			} else {
+				s.Name = m.Value
+			}
+			if !p.next(',') && p.peek() != '}' {
+				return nil, p.errorf("expected , or } of the matchers")
+			}
+		}
+	}
+	if s.Name == "" {
+		return nil, p.errorf("the selector should have a metric name")
+	}
+	if p.next('[') {
+		end := strings.IndexByte(p.input[p.pos:], ']')
+		if end < 0 {
+			return nil, p.errorf("expected ] of the range")
+		}
+		d, err := ParseDuration(strings.TrimSpace(p.input[p.pos : p.pos+end]))
+		if err != nil || d <= 0 {
+			return nil, p.errorf("invalid range %q", p.input[p.pos:p.pos+end])
+		}
+		s.Range = d
+		p.pos += end + 1
+	}
+	return s, nil
+}
+
+func (p *parser) parseMatcher() (*Matcher, error) {
+	p.skipSpaces()
+	name := p.ident()
+	if name == "" {
+		return nil, p.errorf("expected a label of the matcher")
+	}
+	p.skipSpaces()
+	t := MatchEqual
+	for _, op := range []MatchType{MatchRegexp, MatchNotRegexp, MatchNotEqual, MatchEqual} {
+		if strings.HasPrefix(p.input[p.pos:], op.String()) {
+			t = op
+			break
+		}
+		if op == MatchEqual {
+			return nil, p.errorf("expected the operator of the matcher on %s", name)
+		}
+	}
+	p.pos += len(t.String())
+	value, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	return NewMatcher(t, name, value)
+}
+
+// str parses a string quoted by ", ' or `.
+func (p *parser) str() (string, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return "", p.errorf("expected a string")
+	}
+	quote := p.input[p.pos]
+	if quote != '"' && quote != '\'' && quote != '`' {
+		return "", p.errorf("expected a quoted string")
+	}
+	for i := p.pos + 1; i < len(p.input); i++ {
+		switch p.input[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			raw := p.input[p.pos : i+1]
+			p.pos = i + 1
+			if quote == '\'' {
+				// The single-quoted string is unquoted as a double-quoted one.
+				raw = `"` + strings.ReplaceAll(strings.ReplaceAll(raw[1:len(raw)-1], `\'`, `'`), `"`, `\"`) + `"`
+			}
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return "", p.errorf("invalid string %s", raw)
+			}
+			return s, nil
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// ident parses an identifier, which is empty if there is none.
+func (p *parser) ident() string {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (p.pos > start && c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.input[start:p.pos]
+}
+
+// next consumes c if it's the next character except the spaces.
+func (p *parser) next(c byte) bool {
+	if p.peek() != c {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *parser) peek() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.input) && strings.IndexByte(" \t\r\n", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return errors.WithMessagef(ErrSyntax, "%s at position %d", fmt.Sprintf(format, args...), p.pos)
+}
@@ -0,0 +1,102 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package promql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{query: "sw:cpm:value", want: "sw:cpm:value"},
+		{query: `sw:cpm:value{service="a", instance!='b',}`, want: `sw:cpm:value{service="a", instance!="b"}`},
+		{query: `{__name__="sw:cpm:value", service=~"a|b", instance!~` + "`c.*`}", want: `sw:cpm:value{service=~"a|b", instance!~"c.*"}`},
+		{query: `avg_over_time(sw:cpm:value{service="a"}[1h30m])`, want: `avg_over_time(sw:cpm:value{service="a"}[1h30m0s])`},
+		{query: "sum by (service) (sw:cpm:value)", want: "sum by (service) (sw:cpm:value)"},
+		{query: "max(count_over_time(sw:cpm:value[5m])) without (instance)", want: "max without (instance) (count_over_time(sw:cpm:value[5m0s]))"},
+		{query: "avg(sum by (service, instance) (sw:cpm:value))", want: "avg (sum by (service, instance) (sw:cpm:value))"},
+		{query: `sw:cpm:value{service="a\"b"}`, want: `sw:cpm:value{service="a\"b"}`},
+		{query: "sw:cpm:value[5m]", wantErr: true},
+		{query: "sum(sw:cpm:value[5m])", wantErr: true},
+		{query: "avg_over_time(sw:cpm:value)", wantErr: true},
+		{query: `{service="a"}`, wantErr: true},
+		{query: `{__name__=~"sw:.*"}`, wantErr: true},
+		{query: `sw:cpm:value{service=~"("}`, wantErr: true},
+		{query: `sw:cpm:value{service="a"`, wantErr: true},
+		{query: `sw:cpm:value{service}`, wantErr: true},
+		{query: "sw:cpm:value + 1", wantErr: true},
+		{query: "sum by service (sw:cpm:value)", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := Parse(tt.query)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrSyntax)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	m, err := NewMatcher(MatchRegexp, "service", "a|b")
+	require.NoError(t, err)
+	assert.True(t, m.Matches("a"))
+	assert.False(t, m.Matches("ab"), "the regular expression is anchored")
+	m, err = NewMatcher(MatchNotEqual, "service", "")
+	require.NoError(t, err)
+	assert.False(t, m.Matches(""), "an absent label matches as the empty value")
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		arg     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{arg: "5m", want: 5 * time.Minute},
+		{arg: "1h30m", want: 90 * time.Minute},
+		{arg: "1w2d", want: 9 * 24 * time.Hour},
+		{arg: "500ms", want: 500 * time.Millisecond},
+		{arg: "", wantErr: true},
+		{arg: "5", wantErr: true},
+		{arg: "1.5h", wantErr: true},
+		{arg: "30m1h", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			got, err := ParseDuration(tt.arg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package promql
+
+import (
+	"math"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ErrInvalidWriteRequest indicates the body isn't a remote-write request of Prometheus.
+var ErrInvalidWriteRequest = errors.New("invalid remote-write request")
+
+// DecodeWriteRequest decodes the series of a remote-write request of Prometheus, which is the protobuf message
+// prometheus.WriteRequest compressed by the block format of snappy. The exemplars, the histograms and the metadata are ignored.
+func DecodeWriteRequest(body []byte) ([]Series, error) {
+	b, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, errors.WithMessage(ErrInvalidWriteRequest, err.Error())
+	}
+	var result []Series
+	// message WriteRequest { repeated TimeSeries timeseries = 1; ... }
+	err = walk(b, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		s, decodeErr := decodeTimeSeries(bytesValue(value))
+		if decodeErr != nil {
+			return decodeErr
+		}
+		result = append(result, s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// decodeTimeSeries decodes message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; ... }.
+func decodeTimeSeries(b []byte) (Series, error) {
+	labels := make(map[string]string)
+	var s Series
+	err := walk(b, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			// message Label { string name = 1; string value = 2; }
+			var name, v string
+			if err := walk(bytesValue(value), func(num protowire.Number, typ protowire.Type, value []byte) error {
+				if typ == protowire.BytesType && num == 1 {
+					name = string(bytesValue(value))
+				} else if typ == protowire.BytesType && num == 2 {
+					v = string(bytesValue(value))
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			labels[name] = v
+		case 2:
+			// message Sample { double value = 1; int64 timestamp = 2; }
+			var sample Sample
+			if err := walk(bytesValue(value), func(num protowire.Number, typ protowire.Type, value []byte) error {
+				if typ == protowire.Fixed64Type && num == 1 {
+					v, _ := protowire.ConsumeFixed64(value)
+					sample.V = math.Float64frombits(v)
+				} else if typ == protowire.VarintType && num == 2 {
+					v, _ := protowire.ConsumeVarint(value)
+					sample.T = int64(v)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			s.Samples = append(s.Samples, sample)
+		}
+		return nil
+	})
+	s.Labels = NewLabels(labels)
+	return s, err
+}
+
+// walk calls fn with the number, the type and the encoded value of every field of the message.
+func walk(b []byte, fn func(num protowire.Number, typ protowire.Type, value []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errors.WithMessage(ErrInvalidWriteRequest, protowire.ParseError(n).Error())
+		}
+		b = b[n:]
+		m := protowire.ConsumeFieldValue(num, typ, b)
+		if m < 0 {
+			return errors.WithMessage(ErrInvalidWriteRequest, protowire.ParseError(m).Error())
+		}
+		if err := fn(num, typ, b[:m]); err != nil {
+			return err
+		}
+		b = b[m:]
+	}
+	return nil
+}
+
+// bytesValue returns the content of an encoded length-delimited value, which is validated by walk.
+func bytesValue(value []byte) []byte {
+	v, _ := protowire.ConsumeBytes(value)
+	return v
+}
@@ -0,0 +1,81 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package promql
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func encodeTimeSeries(labels map[string]string, samples ...Sample) []byte {
+	var ts []byte
+	for name, value := range labels {
+		var l []byte
+		l = appendMessage(l, 1, []byte(name))
+		l = appendMessage(l, 2, []byte(value))
+		ts = appendMessage(ts, 1, l)
+	}
+	for _, s := range samples {
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.V))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.T))
+		ts = appendMessage(ts, 2, sample)
+	}
+	// an exemplar is ignored
+	return appendMessage(ts, 3, []byte{})
+}
+
+func TestDecodeWriteRequest(t *testing.T) {
+	var req []byte
+	req = appendMessage(req, 1, encodeTimeSeries(map[string]string{MetricNameLabel: "up", "job": "node", "instance": "a:9100"},
+		Sample{T: 1000, V: 1}, Sample{T: 2000, V: 0}))
+	req = appendMessage(req, 1, encodeTimeSeries(map[string]string{MetricNameLabel: "temperature", "room": "b"}, Sample{T: 1000, V: -1.5}))
+	// the metadata is ignored
+	req = appendMessage(req, 3, []byte{})
+	got, err := DecodeWriteRequest(snappy.Encode(nil, req))
+	require.NoError(t, err)
+	assert.Equal(t, []Series{
+		{
+			Labels:  Labels{{Name: MetricNameLabel, Value: "up"}, {Name: "instance", Value: "a:9100"}, {Name: "job", Value: "node"}},
+			Samples: []Sample{{T: 1000, V: 1}, {T: 2000, V: 0}},
+		},
+		{
+			Labels:  Labels{{Name: MetricNameLabel, Value: "temperature"}, {Name: "room", Value: "b"}},
+			Samples: []Sample{{T: 1000, V: -1.5}},
+		},
+	}, got)
+}
+
+func TestDecodeWriteRequestInvalid(t *testing.T) {
+	_, err := DecodeWriteRequest([]byte("not snappy"))
+	assert.ErrorIs(t, err, ErrInvalidWriteRequest)
+	_, err = DecodeWriteRequest(snappy.Encode(nil, []byte{0x0a, 0x05, 0x01}))
+	assert.ErrorIs(t, err, ErrInvalidWriteRequest)
+}
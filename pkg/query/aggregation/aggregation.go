@@ -29,6 +29,7 @@ import (
 var (
 	errUnknownFunc          = errors.New("unknown aggregation function")
 	errUnSupportedFieldType = errors.New("unsupported field type")
+	errInvalidPercentile    = errors.New("invalid percentile")
 )
 
 // Func supports aggregation operations.
@@ -57,6 +58,10 @@ func NewFunc[N Number](af modelv1.AggregationFunction) (Func[N], error) {
 		result = &minFunc[N]{max: maxOf[N]()}
 	case modelv1.AggregationFunction_AGGREGATION_FUNCTION_SUM:
 		result = &sumFunc[N]{zero: zero[N]()}
+	case modelv1.AggregationFunction_AGGREGATION_FUNCTION_COUNT_DISTINCT:
+		result = &countDistinctFunc[N]{sketch: NewHyperLogLog()}
+	case modelv1.AggregationFunction_AGGREGATION_FUNCTION_PERCENTILE:
+		return nil, errors.WithMessage(errInvalidPercentile, "the percentile function is created by NewPercentileFunc")
 	default:
 		return nil, errors.WithMessagef(errUnknownFunc, "unknown function:%s", modelv1.AggregationFunction_name[int32(af)])
 	}
@@ -64,6 +69,32 @@ func NewFunc[N Number](af modelv1.AggregationFunction) (Func[N], error) {
 	return result, nil
 }
 
+// NewPercentileFunc returns a function estimating the value at the percentile in (0, 100] by a t-digest sketch.
+func NewPercentileFunc[N Number](percentile float64) (Func[N], error) {
+	if percentile <= 0 || percentile > 100 {
+		return nil, errors.WithMessagef(errInvalidPercentile, "percentile %v isn't in (0, 100]", percentile)
+	}
+	return &percentileFunc[N]{digest: NewTDigest(), quantile: percentile / 100}, nil
+}
+
+// In feeds the field value to the function. The count_distinct and the percentile functions
+// merge the sketches of the rollups, and the count_distinct one takes the values of any type.
+// The others take the numbers only.
+func In[N Number](f Func[N], fieldValue *modelv1.FieldValue) error {
+	switch sf := f.(type) {
+	case *countDistinctFunc[N]:
+		return sf.sketch.AddFieldValue(fieldValue)
+	case *percentileFunc[N]:
+		return sf.digest.AddFieldValue(fieldValue)
+	}
+	v, err := FromFieldValue[N](fieldValue)
+	if err != nil {
+		return err
+	}
+	f.In(v)
+	return nil
+}
+
 // FromFieldValue transforms modelv1.FieldValue to Number.
 func FromFieldValue[N Number](fieldValue *modelv1.FieldValue) (N, error) {
 	switch fieldValue.GetValue().(type) {
@@ -17,6 +17,12 @@
 
 package aggregation
 
+import (
+	"math"
+
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+)
+
 type meanFunc[N Number] struct {
 	sum   N
 	count N
@@ -115,3 +121,47 @@ func (m minFunc[N]) Val() N {
 func (m *minFunc[N]) Reset() {
 	m.val = m.max
 }
+
+// countDistinctFunc estimates the number of the distinct values by a HyperLogLog sketch.
+type countDistinctFunc[N Number] struct {
+	sketch *HyperLogLog
+}
+
+func (c *countDistinctFunc[N]) In(val N) {
+	switch v := any(val).(type) {
+	case int64:
+		c.sketch.Add(convert.Hash(convert.Int64ToBytes(v)))
+	case float64:
+		c.sketch.Add(convert.Hash(convert.Float64ToBytes(v)))
+	}
+}
+
+func (c countDistinctFunc[N]) Val() N {
+	return N(c.sketch.Estimate())
+}
+
+func (c *countDistinctFunc[N]) Reset() {
+	c.sketch.Reset()
+}
+
+// percentileFunc estimates the value at the quantile by a t-digest sketch.
+type percentileFunc[N Number] struct {
+	digest   *TDigest
+	quantile float64
+}
+
+func (p *percentileFunc[N]) In(val N) {
+	p.digest.Add(float64(val))
+}
+
+func (p percentileFunc[N]) Val() N {
+	v := p.digest.Quantile(p.quantile)
+	if math.IsNaN(v) {
+		return zero[N]()
+	}
+	return N(v)
+}
+
+func (p *percentileFunc[N]) Reset() {
+	p.digest.Reset()
+}
@@ -0,0 +1,144 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregation
+
+import (
+	"bytes"
+	"math"
+	"math/bits"
+
+	"github.com/pkg/errors"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+)
+
+const (
+	// hllPrecision takes 2^12 registers, whose standard error is 1.04/sqrt(4096), about 1.6%.
+	hllPrecision   = 12
+	hllRegisterNum = 1 << hllPrecision
+)
+
+var (
+	// hllMagic heads the marshaled sketches, which tells them from the other binary values.
+	hllMagic = []byte("HLL\x01")
+
+	errInvalidSketch = errors.New("invalid HyperLogLog sketch")
+)
+
+// HyperLogLog is a mergeable sketch estimating the number of the distinct values.
+type HyperLogLog struct {
+	registers [hllRegisterNum]uint8
+}
+
+// NewHyperLogLog returns an empty sketch.
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{}
+}
+
+// Add adds a value by its 64-bit hash.
+func (h *HyperLogLog) Add(hash uint64) {
+	idx := hash >> (64 - hllPrecision)
+	// the guard bit caps the rank at 64-hllPrecision+1
+	w := hash<<hllPrecision | 1<<(hllPrecision-1)
+	if rank := uint8(bits.LeadingZeros64(w)) + 1; rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// AddFieldValue adds a field value of any type, and merges the sketch if the value is a marshaled one.
+// The null values are skipped.
+func (h *HyperLogLog) AddFieldValue(v *modelv1.FieldValue) error {
+	switch v.GetValue().(type) {
+	case *modelv1.FieldValue_Int:
+		h.Add(convert.Hash(convert.Int64ToBytes(v.GetInt().GetValue())))
+	case *modelv1.FieldValue_Float:
+		h.Add(convert.Hash(convert.Float64ToBytes(v.GetFloat().GetValue())))
+	case *modelv1.FieldValue_Str:
+		h.Add(convert.HashStr(v.GetStr().GetValue()))
+	case *modelv1.FieldValue_BinaryData:
+		if !IsHyperLogLog(v.GetBinaryData()) {
+			h.Add(convert.Hash(v.GetBinaryData()))
+			return nil
+		}
+		other, err := UnmarshalHyperLogLog(v.GetBinaryData())
+		if err != nil {
+			return err
+		}
+		h.Merge(other)
+	case *modelv1.FieldValue_Null:
+	default:
+		return errUnSupportedFieldType
+	}
+	return nil
+}
+
+// Merge merges the other sketch, after which the sketch estimates the union of both.
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	for i, r := range other.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+}
+
+// Estimate returns the estimated number of the distinct values.
+// It falls back to the linear counting if the number is small.
+func (h *HyperLogLog) Estimate() uint64 {
+	var sum float64
+	var zeros int
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	m := float64(hllRegisterNum)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// Reset empties the sketch.
+func (h *HyperLogLog) Reset() {
+	h.registers = [hllRegisterNum]uint8{}
+}
+
+// Marshal returns the registers headed by the magic.
+func (h *HyperLogLog) Marshal() []byte {
+	data := make([]byte, 0, len(hllMagic)+hllRegisterNum)
+	data = append(data, hllMagic...)
+	return append(data, h.registers[:]...)
+}
+
+// IsHyperLogLog tells whether the data is a marshaled sketch.
+func IsHyperLogLog(data []byte) bool {
+	return len(data) == len(hllMagic)+hllRegisterNum && bytes.HasPrefix(data, hllMagic)
+}
+
+// UnmarshalHyperLogLog restores the sketch marshaled by Marshal.
+func UnmarshalHyperLogLog(data []byte) (*HyperLogLog, error) {
+	if !IsHyperLogLog(data) {
+		return nil, errInvalidSketch
+	}
+	h := &HyperLogLog{}
+	copy(h.registers[:], data[len(hllMagic):])
+	return h, nil
+}
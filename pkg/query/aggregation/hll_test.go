@@ -0,0 +1,79 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregation
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+func strFieldValue(s string) *modelv1.FieldValue {
+	return &modelv1.FieldValue{Value: &modelv1.FieldValue_Str{Str: &modelv1.Str{Value: s}}}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 100000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			odd, even := NewHyperLogLog(), NewHyperLogLog()
+			for i := 0; i < n; i++ {
+				h := even
+				if i%2 == 1 {
+					h = odd
+				}
+				// every value is added twice
+				for j := 0; j < 2; j++ {
+					require.NoError(t, h.AddFieldValue(strFieldValue("trace-"+strconv.Itoa(i))))
+				}
+			}
+			merged, err := UnmarshalHyperLogLog(even.Marshal())
+			require.NoError(t, err)
+			merged.Merge(odd)
+			assert.InDelta(t, n, merged.Estimate(), float64(n)*0.05)
+		})
+	}
+}
+
+func TestCountDistinct(t *testing.T) {
+	f, err := NewFunc[int64](modelv1.AggregationFunction_AGGREGATION_FUNCTION_COUNT_DISTINCT)
+	require.NoError(t, err)
+	rollup := NewHyperLogLog()
+	for _, v := range []string{"a", "b", "c"} {
+		require.NoError(t, rollup.AddFieldValue(strFieldValue(v)))
+	}
+	values := []*modelv1.FieldValue{
+		strFieldValue("a"),
+		strFieldValue("d"),
+		{Value: &modelv1.FieldValue_Null{}},
+		{Value: &modelv1.FieldValue_BinaryData{BinaryData: rollup.Marshal()}},
+	}
+	for _, v := range values {
+		require.NoError(t, In(f, v))
+	}
+	assert.Equal(t, int64(4), f.Val(), "the sketches of the rollups are merged")
+
+	f.Reset()
+	f.In(1)
+	f.In(1)
+	f.In(2)
+	assert.Equal(t, int64(2), f.Val())
+}
@@ -0,0 +1,242 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+const (
+	// tdigestCompression bounds the number of the centroids, which is about half of it.
+	tdigestCompression = 100
+	// tdigestBufferSize is how many values are buffered before they're merged into the centroids.
+	tdigestBufferSize = 5 * tdigestCompression
+)
+
+var (
+	// tdigestMagic heads the marshaled sketches, which tells them from the other binary values.
+	tdigestMagic = []byte("TDG\x01")
+
+	errInvalidTDigest = errors.New("invalid t-digest sketch")
+)
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// TDigest is a mergeable sketch estimating the quantiles of the values.
+// The centroids are small near both ends, so the extreme quantiles, e.g. p99, are accurate.
+type TDigest struct {
+	centroids []centroid
+	buffer    []centroid
+	min       float64
+	max       float64
+}
+
+// NewTDigest returns an empty sketch.
+func NewTDigest() *TDigest {
+	t := &TDigest{}
+	t.Reset()
+	return t
+}
+
+// Add adds a value.
+func (t *TDigest) Add(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	t.add(centroid{mean: v, weight: 1}, v, v)
+}
+
+func (t *TDigest) add(c centroid, minVal, maxVal float64) {
+	t.buffer = append(t.buffer, c)
+	t.min = math.Min(t.min, minVal)
+	t.max = math.Max(t.max, maxVal)
+	if len(t.buffer) >= tdigestBufferSize {
+		t.compress()
+	}
+}
+
+// AddFieldValue adds a numeric field value, and merges the sketch if the value is a marshaled one.
+// The null values are skipped.
+func (t *TDigest) AddFieldValue(v *modelv1.FieldValue) error {
+	switch v.GetValue().(type) {
+	case *modelv1.FieldValue_Int:
+		t.Add(float64(v.GetInt().GetValue()))
+	case *modelv1.FieldValue_Float:
+		t.Add(v.GetFloat().GetValue())
+	case *modelv1.FieldValue_BinaryData:
+		other, err := UnmarshalTDigest(v.GetBinaryData())
+		if err != nil {
+			return err
+		}
+		t.Merge(other)
+	case *modelv1.FieldValue_Null:
+	default:
+		return errUnSupportedFieldType
+	}
+	return nil
+}
+
+// Merge merges the other sketch, after which the sketch estimates the quantiles of both.
+func (t *TDigest) Merge(other *TDigest) {
+	for _, c := range other.centroids {
+		t.add(c, other.min, other.max)
+	}
+	for _, c := range other.buffer {
+		t.add(c, other.min, other.max)
+	}
+}
+
+// Count returns the number of the values added.
+func (t *TDigest) Count() float64 {
+	var count float64
+	for _, c := range t.centroids {
+		count += c.weight
+	}
+	for _, c := range t.buffer {
+		count += c.weight
+	}
+	return count
+}
+
+// Quantile returns the estimated value at the quantile q in [0, 1]. It returns NaN if the sketch is empty.
+func (t *TDigest) Quantile(q float64) float64 {
+	t.compress()
+	if len(t.centroids) == 0 {
+		return math.NaN()
+	}
+	if q <= 0 {
+		return t.min
+	}
+	if q >= 1 {
+		return t.max
+	}
+	total := t.Count()
+	target := q * total
+	// interpolate between the centers of the adjacent centroids, or the extremes at both ends
+	prevMean, prevCenter := t.min, 0.0
+	var cumulative float64
+	for _, c := range t.centroids {
+		center := cumulative + c.weight/2
+		if target < center {
+			return prevMean + (c.mean-prevMean)*(target-prevCenter)/(center-prevCenter)
+		}
+		prevMean, prevCenter = c.mean, center
+		cumulative += c.weight
+	}
+	if total == prevCenter {
+		return t.max
+	}
+	return prevMean + (t.max-prevMean)*(target-prevCenter)/(total-prevCenter)
+}
+
+// compress merges the buffered values into the centroids, whose sizes are bound by the scale function k1.
+func (t *TDigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+	all := append(t.centroids, t.buffer...)
+	t.buffer = t.buffer[:0]
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+	var total float64
+	for _, c := range all {
+		total += c.weight
+	}
+	merged := make([]centroid, 0, tdigestCompression)
+	merged = append(merged, all[0])
+	var weightSoFar float64
+	qLimit := tdigestQuantileLimit(0)
+	for _, c := range all[1:] {
+		cur := &merged[len(merged)-1]
+		if (weightSoFar+cur.weight+c.weight)/total <= qLimit {
+			cur.weight += c.weight
+			cur.mean += (c.mean - cur.mean) * c.weight / cur.weight
+			continue
+		}
+		weightSoFar += cur.weight
+		qLimit = tdigestQuantileLimit(weightSoFar / total)
+		merged = append(merged, c)
+	}
+	t.centroids = merged
+}
+
+// tdigestQuantileLimit returns the quantile where the centroid starting at q ends,
+// which is one unit away by the scale function k1(q) = compression/(2π) * asin(2q-1).
+func tdigestQuantileLimit(q float64) float64 {
+	k := tdigestCompression/(2*math.Pi)*math.Asin(2*q-1) + 1
+	if k >= tdigestCompression/4 {
+		return 1
+	}
+	return (math.Sin(k*2*math.Pi/tdigestCompression) + 1) / 2
+}
+
+// Reset empties the sketch.
+func (t *TDigest) Reset() {
+	t.centroids = t.centroids[:0]
+	t.buffer = t.buffer[:0]
+	t.min = math.Inf(1)
+	t.max = math.Inf(-1)
+}
+
+// Marshal returns the extremes and the centroids headed by the magic.
+func (t *TDigest) Marshal() []byte {
+	t.compress()
+	data := make([]byte, 0, len(tdigestMagic)+16+16*len(t.centroids))
+	data = append(data, tdigestMagic...)
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(t.min))
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(t.max))
+	for _, c := range t.centroids {
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(c.mean))
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(c.weight))
+	}
+	return data
+}
+
+// IsTDigest tells whether the data is a marshaled sketch.
+func IsTDigest(data []byte) bool {
+	return len(data) >= len(tdigestMagic)+16 && (len(data)-len(tdigestMagic))%16 == 0 && bytes.HasPrefix(data, tdigestMagic)
+}
+
+// UnmarshalTDigest restores the sketch marshaled by Marshal.
+func UnmarshalTDigest(data []byte) (*TDigest, error) {
+	if !IsTDigest(data) {
+		return nil, errInvalidTDigest
+	}
+	data = data[len(tdigestMagic):]
+	t := &TDigest{
+		min:       math.Float64frombits(binary.LittleEndian.Uint64(data)),
+		max:       math.Float64frombits(binary.LittleEndian.Uint64(data[8:])),
+		centroids: make([]centroid, 0, (len(data)-16)/16),
+	}
+	for data = data[16:]; len(data) > 0; data = data[16:] {
+		t.centroids = append(t.centroids, centroid{
+			mean:   math.Float64frombits(binary.LittleEndian.Uint64(data)),
+			weight: math.Float64frombits(binary.LittleEndian.Uint64(data[8:])),
+		})
+	}
+	return t, nil
+}
@@ -0,0 +1,77 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregation
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+func TestTDigest(t *testing.T) {
+	const n = 100000
+	shards := []*TDigest{NewTDigest(), NewTDigest(), NewTDigest(), NewTDigest()}
+	for i, v := range rand.New(rand.NewSource(1)).Perm(n) {
+		shards[i%len(shards)].Add(float64(v + 1))
+	}
+	merged := NewTDigest()
+	for _, s := range shards {
+		restored, err := UnmarshalTDigest(s.Marshal())
+		require.NoError(t, err)
+		merged.Merge(restored)
+	}
+	assert.Equal(t, float64(n), merged.Count())
+	assert.Equal(t, float64(1), merged.Quantile(0))
+	assert.Equal(t, float64(n), merged.Quantile(1))
+	for _, q := range []float64{0.5, 0.95, 0.99, 0.999} {
+		assert.InDelta(t, q*n, merged.Quantile(q), n*0.005, "quantile %v", q)
+	}
+	assert.True(t, math.IsNaN(NewTDigest().Quantile(0.5)))
+}
+
+func TestPercentile(t *testing.T) {
+	_, err := NewPercentileFunc[float64](0)
+	assert.ErrorIs(t, err, errInvalidPercentile)
+	_, err = NewFunc[float64](modelv1.AggregationFunction_AGGREGATION_FUNCTION_PERCENTILE)
+	assert.ErrorIs(t, err, errInvalidPercentile)
+
+	f, err := NewPercentileFunc[float64](50)
+	require.NoError(t, err)
+	rollup := NewTDigest()
+	for _, v := range []float64{1, 2, 3} {
+		rollup.Add(v)
+	}
+	values := []*modelv1.FieldValue{
+		{Value: &modelv1.FieldValue_Int{Int: &modelv1.Int{Value: 4}}},
+		{Value: &modelv1.FieldValue_Float{Float: &modelv1.Float{Value: 5}}},
+		{Value: &modelv1.FieldValue_Null{}},
+		{Value: &modelv1.FieldValue_BinaryData{BinaryData: rollup.Marshal()}},
+	}
+	for _, v := range values {
+		require.NoError(t, In(f, v))
+	}
+	assert.Equal(t, float64(3), f.Val(), "the sketches of the rollups are merged")
+
+	f.Reset()
+	assert.Equal(t, float64(0), f.Val())
+}
@@ -20,6 +20,7 @@ package executor
 
 import (
 	"context"
+	"sync"
 
 	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
@@ -33,6 +34,8 @@ type StreamExecutionContext interface {
 	Query(ctx context.Context, opts pbv1.StreamQueryOptions) (pbv1.StreamQueryResult, error)
 	Sort(ctx context.Context, opts pbv1.StreamSortOptions) (pbv1.StreamSortResult, error)
 	Filter(ctx context.Context, opts pbv1.StreamFilterOptions) (pbv1.StreamFilterResult, error)
+	// Stats returns the statistics of the blocks the query would scan, without reading their rows.
+	Stats(ctx context.Context, opts pbv1.StreamQueryOptions) (pbv1.StreamScanStats, error)
 }
 
 // StreamExecutionContextKey is the key of stream execution context in context.Context.
@@ -55,6 +58,134 @@ type StreamExecutable interface {
 	Execute(context.Context) ([]*streamv1.Element, error)
 }
 
+// StreamElementSink receives the merged elements of a distributed stream query in order,
+// instead of accumulating them in the result.
+type StreamElementSink interface {
+	Append(*streamv1.Element) error
+}
+
+// StreamElementSinkKey is the key of stream element sink in context.Context.
+type StreamElementSinkKey struct{}
+
+var streamElementSinkKeyInstance = StreamElementSinkKey{}
+
+// WithStreamElementSink returns a new context with stream element sink.
+func WithStreamElementSink(ctx context.Context, sink StreamElementSink) context.Context {
+	return context.WithValue(ctx, streamElementSinkKeyInstance, sink)
+}
+
+// FromStreamElementSink returns the stream element sink from context.Context, it's nil if there is no sink.
+func FromStreamElementSink(ctx context.Context) StreamElementSink {
+	sink, _ := ctx.Value(streamElementSinkKeyInstance).(StreamElementSink)
+	return sink
+}
+
+// Warnings collects the blocks a query skips because they fail to decode. It's safe for concurrent use.
+type Warnings struct {
+	warnings []*modelv1.BlockWarning
+	mu       sync.Mutex
+}
+
+// Add records the warnings. It does nothing if w is nil.
+func (w *Warnings) Add(warnings ...*modelv1.BlockWarning) {
+	if w == nil || len(warnings) == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warnings = append(w.warnings, warnings...)
+}
+
+// List returns the recorded warnings.
+func (w *Warnings) List() []*modelv1.BlockWarning {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.warnings
+}
+
+// WarningsKey is the key of query warnings in context.Context.
+type WarningsKey struct{}
+
+var warningsKeyInstance = WarningsKey{}
+
+// WithWarnings returns a new context with query warnings.
+func WithWarnings(ctx context.Context, w *Warnings) context.Context {
+	return context.WithValue(ctx, warningsKeyInstance, w)
+}
+
+// FromWarnings returns the query warnings from context.Context, it's nil if there are no warnings.
+func FromWarnings(ctx context.Context) *Warnings {
+	w, _ := ctx.Value(warningsKeyInstance).(*Warnings)
+	return w
+}
+
+// ColumnBatches collects the column batches of a columnar stream query, which replace its elements.
+// It's safe for concurrent use.
+type ColumnBatches struct {
+	batches []*streamv1.ColumnBatch
+	mu      sync.Mutex
+}
+
+// Add appends the batches.
+func (c *ColumnBatches) Add(batches ...*streamv1.ColumnBatch) {
+	if len(batches) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batches = append(c.batches, batches...)
+}
+
+// List returns the collected batches.
+func (c *ColumnBatches) List() []*streamv1.ColumnBatch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.batches
+}
+
+// ColumnBatchesKey is the key of the column batches in context.Context.
+type ColumnBatchesKey struct{}
+
+var columnBatchesKeyInstance = ColumnBatchesKey{}
+
+// WithColumnBatches returns a new context collecting the results of the stream query in column batches.
+func WithColumnBatches(ctx context.Context, c *ColumnBatches) context.Context {
+	return context.WithValue(ctx, columnBatchesKeyInstance, c)
+}
+
+// FromColumnBatches returns the column batches from context.Context, it's nil if the query isn't columnar.
+func FromColumnBatches(ctx context.Context) *ColumnBatches {
+	c, _ := ctx.Value(columnBatchesKeyInstance).(*ColumnBatches)
+	return c
+}
+
+// GroupSpill bounds the groups the hash aggregations of a measure query hold in memory.
+type GroupSpill struct {
+	// Dir is the directory to spill the data points of the groups exceeding MaxGroups, spilling is disabled if it's empty.
+	Dir string
+	// MaxGroups is the max number of groups held in memory.
+	MaxGroups int
+}
+
+// GroupSpillKey is the key of the group spill in context.Context.
+type GroupSpillKey struct{}
+
+var groupSpillKeyInstance = GroupSpillKey{}
+
+// WithGroupSpill returns a new context spilling the groups of the hash aggregations.
+func WithGroupSpill(ctx context.Context, gs GroupSpill) context.Context {
+	return context.WithValue(ctx, groupSpillKeyInstance, gs)
+}
+
+// FromGroupSpill returns the group spill from context.Context, it's the zero value if the groups never spill.
+func FromGroupSpill(ctx context.Context) GroupSpill {
+	gs, _ := ctx.Value(groupSpillKeyInstance).(GroupSpill)
+	return gs
+}
+
 // MeasureExecutionContext allows retrieving data through the measure module.
 type MeasureExecutionContext interface {
 	Query(ctx context.Context, opts pbv1.MeasureQueryOptions) (pbv1.MeasureQueryResult, error)
@@ -93,6 +224,22 @@ type MeasureExecutable interface {
 type DistributedExecutionContext interface {
 	bus.Broadcaster
 	TimeRange() *modelv1.TimeRange
+	// BroadcastChunked sends the message to data nodes which reply their results in chunks.
+	BroadcastChunked(topic bus.Topic, message bus.Message) ([]NodeStream, error)
+	// ReportNodeFailure records that a node failed to serve the query.
+	// It returns false if the query should fail instead of returning partial results.
+	ReportNodeFailure(node string, err error) bool
+}
+
+// NodeStream yields the chunks of a data node's response in order.
+type NodeStream interface {
+	Node() string
+	// ShardIDs returns the shards the node is queried for, or nil if it's queried for all its shards.
+	ShardIDs() []uint32
+	// Recv returns io.EOF after the last chunk.
+	Recv() (bus.Message, error)
+	// Close stops receiving the remaining chunks.
+	Close() error
 }
 
 // DistributedExecutionContextKey is the key of distributed execution context in context.Context.
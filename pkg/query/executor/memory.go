@@ -0,0 +1,144 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package executor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrMemoryExceeded is the cause a query is cancelled by once it exceeds its memory budget or the global one.
+var ErrMemoryExceeded = errors.New("the query exceeds the memory budget")
+
+// MemoryPool is the global budget of the memory held by the running queries.
+// It's safe for concurrent use.
+type MemoryPool struct {
+	used  atomic.Int64
+	limit int64
+}
+
+// NewMemoryPool returns a pool of limit bytes, 0 means unlimited.
+func NewMemoryPool(limit int64) *MemoryPool {
+	return &MemoryPool{limit: limit}
+}
+
+// Used returns the bytes held by the running queries.
+func (p *MemoryPool) Used() int64 {
+	return p.used.Load()
+}
+
+// NewAccount returns the account of a query holding up to limit bytes, 0 means it's bounded by the pool only.
+// The returned context is cancelled with ErrMemoryExceeded once the query exceeds a budget, the account has to be closed
+// after the query.
+func (p *MemoryPool) NewAccount(ctx context.Context, limit int64) (*MemoryAccount, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	a := &MemoryAccount{pool: p, limit: limit, cancel: cancel}
+	return a, context.WithValue(ctx, memoryAccountKeyInstance, a)
+}
+
+// MemoryAccount accounts the memory a query holds in its block cursors, result buffers and aggregation states.
+// A nil account accounts nothing. It's safe for concurrent use.
+type MemoryAccount struct {
+	err    error
+	pool   *MemoryPool
+	cancel context.CancelCauseFunc
+	used   atomic.Int64
+	limit  int64
+	mu     sync.Mutex
+}
+
+// Grow accounts n bytes more. If the query or all the queries exceed their budgets, the bytes aren't accounted,
+// and the query is cancelled with the error returned.
+func (a *MemoryAccount) Grow(n int64) error {
+	if a == nil || n <= 0 {
+		return nil
+	}
+	if err := a.Err(); err != nil {
+		return err
+	}
+	if used := a.used.Add(n); a.limit > 0 && used > a.limit {
+		a.used.Add(-n)
+		return a.fail(errors.Wrapf(ErrMemoryExceeded, "the query holds %d bytes, which exceeds %d", used, a.limit))
+	}
+	if used := a.pool.used.Add(n); a.pool.limit > 0 && used > a.pool.limit {
+		a.pool.used.Add(-n)
+		a.used.Add(-n)
+		return a.fail(errors.Wrapf(ErrMemoryExceeded, "the queries hold %d bytes, which exceeds %d", used, a.pool.limit))
+	}
+	return nil
+}
+
+// Shrink releases n bytes accounted before.
+func (a *MemoryAccount) Shrink(n int64) {
+	if a == nil || n <= 0 {
+		return
+	}
+	a.used.Add(-n)
+	a.pool.used.Add(-n)
+}
+
+// Used returns the bytes held by the query.
+func (a *MemoryAccount) Used() int64 {
+	if a == nil {
+		return 0
+	}
+	return a.used.Load()
+}
+
+// Err returns the error the query is cancelled by if it exceeded a budget.
+func (a *MemoryAccount) Err() error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// Close releases the bytes held by the query to the pool.
+func (a *MemoryAccount) Close() {
+	if a == nil {
+		return
+	}
+	a.Shrink(a.used.Load())
+	a.cancel(nil)
+}
+
+func (a *MemoryAccount) fail(err error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err == nil {
+		a.err = err
+		a.cancel(err)
+	}
+	return a.err
+}
+
+// MemoryAccountKey is the key of the memory account in context.Context.
+type MemoryAccountKey struct{}
+
+var memoryAccountKeyInstance = MemoryAccountKey{}
+
+// FromMemoryAccount returns the memory account from context.Context, it's nil if the query isn't accounted.
+func FromMemoryAccount(ctx context.Context) *MemoryAccount {
+	a, _ := ctx.Value(memoryAccountKeyInstance).(*MemoryAccount)
+	return a
+}
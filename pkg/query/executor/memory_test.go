@@ -0,0 +1,64 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryAccount(t *testing.T) {
+	pool := NewMemoryPool(100)
+	a, ctx := pool.NewAccount(context.Background(), 60)
+	assert.Same(t, a, FromMemoryAccount(ctx))
+	b, _ := pool.NewAccount(context.Background(), 0)
+
+	require.NoError(t, a.Grow(50))
+	require.NoError(t, b.Grow(40))
+	assert.Equal(t, int64(90), pool.Used())
+
+	// the query exceeds its own budget
+	err := a.Grow(20)
+	assert.True(t, errors.Is(err, ErrMemoryExceeded))
+	assert.Equal(t, int64(50), a.Used())
+	assert.True(t, errors.Is(context.Cause(ctx), ErrMemoryExceeded))
+	assert.Equal(t, err, a.Err())
+	assert.Equal(t, err, a.Grow(1))
+
+	// the queries exceed the pool
+	assert.True(t, errors.Is(b.Grow(20), ErrMemoryExceeded))
+	assert.Equal(t, int64(40), b.Used())
+	assert.Equal(t, int64(90), pool.Used())
+
+	a.Close()
+	b.Close()
+	assert.Equal(t, int64(0), pool.Used())
+}
+
+func TestMemoryAccountNil(t *testing.T) {
+	var a *MemoryAccount
+	assert.NoError(t, a.Grow(1))
+	a.Shrink(1)
+	a.Close()
+	assert.NoError(t, a.Err())
+	assert.Nil(t, FromMemoryAccount(context.Background()))
+}
@@ -0,0 +1,158 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+// The counters of the spans.
+const (
+	CounterBlocksScanned = "blocks_scanned"
+	CounterBlocksSkipped = "blocks_skipped"
+	CounterBytesRead     = "bytes_read"
+	CounterCacheHits     = "cache_hits"
+	CounterCacheMisses   = "cache_misses"
+	CounterRows          = "rows"
+	CounterSeries        = "series"
+)
+
+// Span traces an operator of a traced query. A nil Span does nothing, which is the one of a query not traced.
+// It's safe for concurrent use.
+type Span struct {
+	start    time.Time
+	counters map[string]int64
+	message  string
+	names    []string
+	children []*Span
+	remote   []*modelv1.Span
+	duration time.Duration
+	mu       sync.Mutex
+}
+
+// NewSpan returns the root span of a traced query, which is started.
+func NewSpan(message string) *Span {
+	return &Span{message: message, start: time.Now()}
+}
+
+// Child starts a span of an operator run by the one of s.
+func (s *Span) Child(message string) *Span {
+	if s == nil {
+		return nil
+	}
+	c := NewSpan(message)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.children = append(s.children, c)
+	return c
+}
+
+// AddRemote adds the spans returned by the data nodes as the children of s.
+func (s *Span) AddRemote(spans ...*modelv1.Span) {
+	if s == nil || len(spans) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remote = append(s.remote, spans...)
+}
+
+// Add adds delta to the counter.
+func (s *Span) Add(name string, delta int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counters == nil {
+		s.counters = make(map[string]int64)
+	}
+	if _, ok := s.counters[name]; !ok {
+		s.names = append(s.names, name)
+	}
+	s.counters[name] += delta
+}
+
+// End stops the span, whose duration is the time since it started.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.Observe(time.Since(s.start))
+}
+
+// Observe adds the time of a piece of the operator to the duration, for the operators run lazily in pieces
+// rather than at once, which are never ended.
+func (s *Span) Observe(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.duration += d
+}
+
+// Proto returns the span and its children, the counters are in the order they're added first.
+func (s *Span) Proto() *modelv1.Span {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	span := &modelv1.Span{Message: s.message, Duration: durationpb.New(s.duration)}
+	for _, name := range s.names {
+		span.Counters = append(span.Counters, &modelv1.SpanCounter{Name: name, Value: s.counters[name]})
+	}
+	for _, c := range s.children {
+		span.Children = append(span.Children, c.Proto())
+	}
+	span.Children = append(span.Children, s.remote...)
+	return span
+}
+
+// SpanKey is the key of the span of the current operator in context.Context.
+type SpanKey struct{}
+
+var spanKeyInstance = SpanKey{}
+
+// WithSpan returns a new context with the span of the current operator.
+func WithSpan(ctx context.Context, s *Span) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKeyInstance, s)
+}
+
+// FromSpan returns the span of the current operator from context.Context, it's nil if the query isn't traced.
+func FromSpan(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKeyInstance).(*Span)
+	return s
+}
+
+// StartSpan starts the span of an operator as a child of the current one, and returns the context with it.
+// It returns a nil span and ctx if the query isn't traced.
+func StartSpan(ctx context.Context, message string) (*Span, context.Context) {
+	s := FromSpan(ctx).Child(message)
+	return s, WithSpan(ctx, s)
+}
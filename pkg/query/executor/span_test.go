@@ -0,0 +1,70 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+func TestSpanNil(t *testing.T) {
+	var s *Span
+	c := s.Child("child")
+	assert.Nil(t, c)
+	c.Add(CounterRows, 1)
+	c.Observe(time.Second)
+	c.End()
+	c.AddRemote(&modelv1.Span{Message: "remote"})
+	assert.Nil(t, c.Proto())
+
+	ctx := context.Background()
+	span, spanCtx := StartSpan(ctx, "operator")
+	assert.Nil(t, span)
+	assert.Equal(t, ctx, spanCtx)
+}
+
+func TestSpanProto(t *testing.T) {
+	root := NewSpan("query")
+	child, ctx := StartSpan(WithSpan(context.Background(), root), "scan")
+	assert.Same(t, child, FromSpan(ctx))
+	child.Add(CounterRows, 2)
+	child.Add(CounterBlocksScanned, 1)
+	child.Add(CounterRows, 3)
+	child.Observe(time.Millisecond)
+	child.Observe(time.Millisecond)
+	root.AddRemote(&modelv1.Span{Message: "remote", Node: "data-0"})
+	root.End()
+
+	span := root.Proto()
+	assert.Equal(t, "query", span.GetMessage())
+	require.Len(t, span.GetChildren(), 2)
+	scan := span.GetChildren()[0]
+	assert.Equal(t, "scan", scan.GetMessage())
+	assert.Equal(t, 2*time.Millisecond, scan.GetDuration().AsDuration())
+	assert.Equal(t, []*modelv1.SpanCounter{
+		{Name: CounterRows, Value: 5},
+		{Name: CounterBlocksScanned, Value: 1},
+	}, scan.GetCounters())
+	assert.Equal(t, "data-0", span.GetChildren()[1].GetNode())
+}
@@ -20,6 +20,7 @@ package logical
 import (
 	"github.com/pkg/errors"
 
+	"github.com/apache/skywalking-banyandb/api/common"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
 	"github.com/apache/skywalking-banyandb/banyand/tsdb"
 )
@@ -89,6 +90,18 @@ func ToTags(projection *modelv1.TagProjection) [][]*Tag {
 	return projTags
 }
 
+// ToShardIDs converts the shard ids in a query request.
+func ToShardIDs(ids []uint32) []common.ShardID {
+	if len(ids) == 0 {
+		return nil
+	}
+	shardIDs := make([]common.ShardID, len(ids))
+	for i, id := range ids {
+		shardIDs[i] = common.ShardID(id)
+	}
+	return shardIDs
+}
+
 // Field identity a field in a measure.
 type Field struct {
 	Name string
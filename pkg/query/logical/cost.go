@@ -0,0 +1,134 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logical
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+// AccessPath is how a scan reads the data.
+type AccessPath int
+
+const (
+	// IndexScan looks the rows up in the inverted index by the criteria on the indexed tags.
+	IndexScan AccessPath = iota
+	// BlockScan reads the blocks in the time range, skipping those which can't match by their statistics.
+	BlockScan
+)
+
+func (p AccessPath) String() string {
+	if p == BlockScan {
+		return "BlockScan"
+	}
+	return "IndexScan"
+}
+
+// The costs are measured in the rows read sequentially out of the blocks.
+const (
+	// indexProbeCost is the cost of searching the index.
+	indexProbeCost = 1024
+	// indexFetchCost is the cost of fetching a row the index returns, which is read randomly.
+	indexFetchCost = 4
+	// indexSelectivity is the share of the rows of the blocks which might match that the index is assumed to return,
+	// since the statistics tell the blocks apart rather than their rows.
+	indexSelectivity = 0.05
+)
+
+// ScanCost is the estimated costs of the access paths of a scan.
+type ScanCost struct {
+	Index float64
+	Block float64
+}
+
+// EstimateScanCost estimates the costs by the blocks which might match the criteria. A block scan reads all their rows,
+// while an index scan probes the index, then fetches the rows it returns.
+func EstimateScanCost(matched pbv1.BlockStats) ScanCost {
+	rows := float64(matched.Rows)
+	return ScanCost{
+		Index: indexProbeCost + rows*indexSelectivity*indexFetchCost,
+		Block: rows,
+	}
+}
+
+// Cheaper returns the access path costing less, which is IndexScan on a tie.
+func (c ScanCost) Cheaper() AccessPath {
+	if c.Block < c.Index {
+		return BlockScan
+	}
+	return IndexScan
+}
+
+func (c ScanCost) String() string {
+	return fmt.Sprintf("index=%.0f,block=%.0f", c.Index, c.Block)
+}
+
+// OrderPredicates sorts the predicates by the rows of the blocks which might match them, whose statistics are in the
+// same order, so that the most selective ones are evaluated first and drop the rows not matching early.
+func OrderPredicates(predicates []pbv1.TagPredicate, stats []pbv1.BlockStats) {
+	if len(predicates) != len(stats) {
+		return
+	}
+	sort.Stable(predicatesByRows{predicates: predicates, stats: stats})
+}
+
+type predicatesByRows struct {
+	predicates []pbv1.TagPredicate
+	stats      []pbv1.BlockStats
+}
+
+func (p predicatesByRows) Len() int { return len(p.predicates) }
+
+func (p predicatesByRows) Less(i, j int) bool { return p.stats[i].Rows < p.stats[j].Rows }
+
+func (p predicatesByRows) Swap(i, j int) {
+	p.predicates[i], p.predicates[j] = p.predicates[j], p.predicates[i]
+	p.stats[i], p.stats[j] = p.stats[j], p.stats[i]
+}
+
+// Explainer is a Plan which makes its physical choices by the statistics of the data as it's executed.
+type Explainer interface {
+	Plan
+	// Explain makes the choices ahead, which the Plan shows then.
+	Explain(ctx context.Context) error
+}
+
+// Explain makes the choices of the plan and its children without executing them, and returns the plan showing them.
+func Explain(ctx context.Context, plan Plan) (string, error) {
+	if err := explain(ctx, plan); err != nil {
+		return "", err
+	}
+	return plan.String(), nil
+}
+
+func explain(ctx context.Context, plan Plan) error {
+	if e, ok := plan.(Explainer); ok {
+		if err := e.Explain(ctx); err != nil {
+			return err
+		}
+	}
+	for _, c := range plan.Children() {
+		if err := explain(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logical
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+func TestEstimateScanCost(t *testing.T) {
+	tests := []struct {
+		name    string
+		matched pbv1.BlockStats
+		want    AccessPath
+	}{
+		{name: "no block", want: BlockScan},
+		{name: "a few rows left", matched: pbv1.BlockStats{Blocks: 2, Rows: 200}, want: BlockScan},
+		{name: "many rows left", matched: pbv1.BlockStats{Blocks: 100, Rows: 100000}, want: IndexScan},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost := EstimateScanCost(tt.matched)
+			assert.Equal(t, tt.want, cost.Cheaper())
+			assert.Equal(t, float64(tt.matched.Rows), cost.Block)
+		})
+	}
+}
+
+func TestOrderPredicates(t *testing.T) {
+	predicates := []pbv1.TagPredicate{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}
+	stats := []pbv1.BlockStats{{Rows: 30}, {Rows: 10}, {Rows: 30}, {Rows: 20}}
+	OrderPredicates(predicates, stats)
+	var names []string
+	for _, tp := range predicates {
+		names = append(names, tp.Name)
+	}
+	assert.Equal(t, []string{"b", "d", "a", "c"}, names)
+	assert.Equal(t, []pbv1.BlockStats{{Rows: 10}, {Rows: 20}, {Rows: 30}, {Rows: 30}}, stats)
+
+	// the predicates are left as they are without the statistics of them all
+	OrderPredicates(predicates, []pbv1.BlockStats{{Rows: 3}, {Rows: 2}, {Rows: 1}})
+	assert.Equal(t, "b", predicates[0].Name)
+	assert.Equal(t, "c", predicates[3].Name)
+}
@@ -145,6 +145,14 @@ func parseConditionDeprecated(cond *modelv1.Condition, indexRule *databasev1.Ind
 		return newEq(indexRule, expr), []tsdb.Entity{entity}, nil
 	case modelv1.Condition_BINARY_OP_MATCH:
 		return newMatch(indexRule, expr), []tsdb.Entity{entity}, nil
+	case modelv1.Condition_BINARY_OP_MATCH_PHRASE:
+		return newMatchPhrase(indexRule, expr), []tsdb.Entity{entity}, nil
+	case modelv1.Condition_BINARY_OP_REGEX, modelv1.Condition_BINARY_OP_WILDCARD:
+		pm, err := newPatternMatch(indexRule, cond, expr)
+		if err != nil {
+			return nil, nil, err
+		}
+		return pm, []tsdb.Entity{entity}, nil
 	case modelv1.Condition_BINARY_OP_NE:
 		return newNot(indexRule, newEq(indexRule, expr)), []tsdb.Entity{entity}, nil
 	case modelv1.Condition_BINARY_OP_HAVING:
@@ -392,6 +400,14 @@ func parseCondition(cond *modelv1.Condition, indexRule *databasev1.IndexRule, ex
 		return newEq(indexRule, expr), [][]*modelv1.TagValue{entity}, nil
 	case modelv1.Condition_BINARY_OP_MATCH:
 		return newMatch(indexRule, expr), [][]*modelv1.TagValue{entity}, nil
+	case modelv1.Condition_BINARY_OP_MATCH_PHRASE:
+		return newMatchPhrase(indexRule, expr), [][]*modelv1.TagValue{entity}, nil
+	case modelv1.Condition_BINARY_OP_REGEX, modelv1.Condition_BINARY_OP_WILDCARD:
+		pm, err := newPatternMatch(indexRule, cond, expr)
+		if err != nil {
+			return nil, nil, err
+		}
+		return pm, [][]*modelv1.TagValue{entity}, nil
 	case modelv1.Condition_BINARY_OP_NE:
 		return newNot(indexRule, newEq(indexRule, expr)), [][]*modelv1.TagValue{entity}, nil
 	case modelv1.Condition_BINARY_OP_HAVING:
@@ -577,6 +593,7 @@ func (fk fieldKey) toIndex(seriesID common.SeriesID) index.FieldKey {
 		IndexRuleID: fk.Metadata.Id,
 		Analyzer:    fk.Analyzer,
 		SeriesID:    seriesID,
+		Numeric:     fk.Type == databasev1.IndexRule_TYPE_RANGE,
 	}
 }
 
@@ -833,6 +850,92 @@ func (match *match) String() string {
 	return jsonToString(match)
 }
 
+type matchPhrase struct {
+	*leaf
+}
+
+func newMatchPhrase(indexRule *databasev1.IndexRule, values LiteralExpr) *matchPhrase {
+	return &matchPhrase{
+		leaf: &leaf{
+			Key:  newFieldKey(indexRule),
+			Expr: values,
+		},
+	}
+}
+
+func (mp *matchPhrase) Execute(searcher index.GetSearcher, seriesID common.SeriesID) (posting.List, error) {
+	s, err := searcher(mp.Key.Type)
+	if err != nil {
+		return nil, err
+	}
+	bb := mp.Expr.Bytes()
+	phrases := make([]string, len(bb))
+	for i, v := range bb {
+		phrases[i] = string(v)
+	}
+	return s.MatchPhrase(
+		mp.Key.toIndex(seriesID),
+		phrases,
+	)
+}
+
+func (mp *matchPhrase) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{}, 1)
+	data["match_phrase"] = mp.leaf
+	return json.Marshal(data)
+}
+
+func (mp *matchPhrase) String() string {
+	return jsonToString(mp)
+}
+
+type patternMatch struct {
+	*leaf
+	pattern *index.Pattern
+}
+
+func newPatternMatch(indexRule *databasev1.IndexRule, cond *modelv1.Condition, values LiteralExpr) (*patternMatch, error) {
+	if cond.GetValue().GetStr() == nil {
+		return nil, errors.WithMessagef(errUnsupportedConditionValue, "%s only supports a string in condition(%v)", cond.Op, cond)
+	}
+	expr := cond.GetValue().GetStr().GetValue()
+	var pattern *index.Pattern
+	var err error
+	if cond.Op == modelv1.Condition_BINARY_OP_REGEX {
+		pattern, err = index.NewRegexPattern(expr)
+	} else {
+		pattern, err = index.NewWildcardPattern(expr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &patternMatch{
+		leaf: &leaf{
+			Key:  newFieldKey(indexRule),
+			Expr: values,
+		},
+		pattern: pattern,
+	}, nil
+}
+
+func (pm *patternMatch) Execute(searcher index.GetSearcher, seriesID common.SeriesID) (posting.List, error) {
+	s, err := searcher(pm.Key.Type)
+	if err != nil {
+		return nil, err
+	}
+	return s.MatchPattern(pm.Key.toIndex(seriesID), pm.pattern)
+}
+
+func (pm *patternMatch) MarshalJSON() ([]byte, error) {
+	data := make(map[string]interface{}, 1)
+	data["pattern"] = pm.leaf
+	return json.Marshal(data)
+}
+
+func (pm *patternMatch) String() string {
+	return jsonToString(pm)
+}
+
 type rangeOp struct {
 	*leaf
 	Opts index.RangeOpts
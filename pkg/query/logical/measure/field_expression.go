@@ -0,0 +1,290 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+var errInvalidFieldExpression = errors.New("invalid field expression")
+
+// exprPoint is a data point which the field expressions are evaluated on.
+type exprPoint struct {
+	timestamp time.Time
+	fields    map[string]*modelv1.FieldValue
+}
+
+// fieldExpr computes a value from the fields of a data point, and the previous data point of the same series.
+// The value is null if any of the operands is null, or it's divided by zero.
+type fieldExpr interface {
+	fmt.Stringer
+	eval(cur, prev *exprPoint) (float64, bool)
+	fieldNames() []string
+}
+
+type numberExpr float64
+
+func (n numberExpr) eval(_, _ *exprPoint) (float64, bool) {
+	return float64(n), true
+}
+
+func (n numberExpr) fieldNames() []string {
+	return nil
+}
+
+func (n numberExpr) String() string {
+	return strconv.FormatFloat(float64(n), 'g', -1, 64)
+}
+
+type fieldRefExpr string
+
+func (f fieldRefExpr) eval(cur, _ *exprPoint) (float64, bool) {
+	switch v := cur.fields[string(f)].GetValue().(type) {
+	case *modelv1.FieldValue_Int:
+		return float64(v.Int.GetValue()), true
+	case *modelv1.FieldValue_Float:
+		return v.Float.GetValue(), true
+	}
+	return 0, false
+}
+
+func (f fieldRefExpr) fieldNames() []string {
+	return []string{string(f)}
+}
+
+func (f fieldRefExpr) String() string {
+	return string(f)
+}
+
+type binaryExpr struct {
+	left  fieldExpr
+	right fieldExpr
+	op    byte
+}
+
+func (b *binaryExpr) eval(cur, prev *exprPoint) (float64, bool) {
+	l, ok := b.left.eval(cur, prev)
+	if !ok {
+		return 0, false
+	}
+	r, ok := b.right.eval(cur, prev)
+	if !ok {
+		return 0, false
+	}
+	switch b.op {
+	case '+':
+		return l + r, true
+	case '-':
+		return l - r, true
+	case '*':
+		return l * r, true
+	default:
+		if r == 0 {
+			return 0, false
+		}
+		return l / r, true
+	}
+}
+
+func (b *binaryExpr) fieldNames() []string {
+	return append(b.left.fieldNames(), b.right.fieldNames()...)
+}
+
+func (b *binaryExpr) String() string {
+	return fmt.Sprintf("(%s %c %s)", b.left, b.op, b.right)
+}
+
+// seriesFuncExpr computes the change of the argument since the previous data point of the series.
+// delta returns the change, and rate returns the change per second.
+type seriesFuncExpr struct {
+	arg  fieldExpr
+	name string
+}
+
+func (s *seriesFuncExpr) eval(cur, prev *exprPoint) (float64, bool) {
+	if prev == nil {
+		return 0, false
+	}
+	c, ok := s.arg.eval(cur, nil)
+	if !ok {
+		return 0, false
+	}
+	p, ok := s.arg.eval(prev, nil)
+	if !ok {
+		return 0, false
+	}
+	if s.name == "delta" {
+		return c - p, true
+	}
+	seconds := cur.timestamp.Sub(prev.timestamp).Seconds()
+	if seconds <= 0 {
+		return 0, false
+	}
+	return (c - p) / seconds, true
+}
+
+func (s *seriesFuncExpr) fieldNames() []string {
+	return s.arg.fieldNames()
+}
+
+func (s *seriesFuncExpr) String() string {
+	return fmt.Sprintf("%s(%s)", s.name, s.arg)
+}
+
+// parseFieldExpression parses the expression combining the fields by +, -, * and /,
+// and the functions rate() and delta().
+func parseFieldExpression(expression string) (fieldExpr, error) {
+	p := &exprParser{input: expression}
+	p.next()
+	expr, err := p.parseSum()
+	if err != nil {
+		return nil, errors.WithMessagef(errInvalidFieldExpression, "%q: %v", expression, err)
+	}
+	if p.token != "" {
+		return nil, errors.WithMessagef(errInvalidFieldExpression, "%q: unexpected %q", expression, p.token)
+	}
+	return expr, nil
+}
+
+// exprParser is a recursive descent parser, whose token is empty at the end of the input.
+type exprParser struct {
+	input string
+	token string
+	pos   int
+}
+
+func (p *exprParser) next() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.input) {
+		p.token = ""
+		return
+	}
+	switch c := p.input[p.pos]; {
+	case isIdentChar(c) || c == '.':
+		for p.pos < len(p.input) && (isIdentChar(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.token = p.input[start:p.pos]
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *exprParser) parseSum() (fieldExpr, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.token == "+" || p.token == "-" {
+		op := p.token[0]
+		p.next()
+		right, errRight := p.parseProduct()
+		if errRight != nil {
+			return nil, errRight
+		}
+		left = &binaryExpr{left: left, right: right, op: op}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseProduct() (fieldExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.token == "*" || p.token == "/" {
+		op := p.token[0]
+		p.next()
+		right, errRight := p.parseUnary()
+		if errRight != nil {
+			return nil, errRight
+		}
+		left = &binaryExpr{left: left, right: right, op: op}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (fieldExpr, error) {
+	if p.token != "-" {
+		return p.parsePrimary()
+	}
+	p.next()
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryExpr{left: numberExpr(0), right: operand, op: '-'}, nil
+}
+
+func (p *exprParser) parsePrimary() (fieldExpr, error) {
+	token := p.token
+	switch {
+	case token == "":
+		return nil, errors.New("unexpected end")
+	case token == "(":
+		p.next()
+		expr, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.token != ")" {
+			return nil, errors.New("missing )")
+		}
+		p.next()
+		return expr, nil
+	case token[0] >= '0' && token[0] <= '9' || token[0] == '.':
+		n, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid number %q", token)
+		}
+		p.next()
+		return numberExpr(n), nil
+	case isIdentChar(token[0]):
+		p.next()
+		if p.token != "(" {
+			return fieldRefExpr(token), nil
+		}
+		if token != "rate" && token != "delta" {
+			return nil, errors.Errorf("unknown function %q", token)
+		}
+		p.next()
+		arg, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.token != ")" {
+			return nil, errors.New("missing )")
+		}
+		p.next()
+		return &seriesFuncExpr{name: token, arg: arg}, nil
+	}
+	return nil, errors.Errorf("unexpected %q", token)
+}
@@ -0,0 +1,83 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+func TestFieldExpression(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	cur := &exprPoint{
+		timestamp: ts,
+		fields: map[string]*modelv1.FieldValue{
+			"errors": {Value: &modelv1.FieldValue_Int{Int: &modelv1.Int{Value: 5}}},
+			"total":  {Value: &modelv1.FieldValue_Int{Int: &modelv1.Int{Value: 200}}},
+			"zero":   {Value: &modelv1.FieldValue_Float{Float: &modelv1.Float{Value: 0}}},
+			"null":   {Value: &modelv1.FieldValue_Null{}},
+		},
+	}
+	prev := &exprPoint{
+		timestamp: ts.Add(-time.Minute),
+		fields: map[string]*modelv1.FieldValue{
+			"total": {Value: &modelv1.FieldValue_Int{Int: &modelv1.Int{Value: 80}}},
+		},
+	}
+	tests := []struct {
+		expression string
+		want       float64
+		null       bool
+	}{
+		{expression: "errors / total * 100", want: 2.5},
+		{expression: "100 - errors * 2", want: 90},
+		{expression: "-(errors + 1) * 2", want: -12},
+		{expression: "total / zero", null: true},
+		{expression: "total + null", null: true},
+		{expression: "delta(total)", want: 120},
+		{expression: "rate(total)", want: 2},
+		{expression: "rate(errors)", null: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			expr, err := parseFieldExpression(tt.expression)
+			require.NoError(t, err)
+			v, ok := expr.eval(cur, prev)
+			assert.Equal(t, !tt.null, ok)
+			if !tt.null {
+				assert.InDelta(t, tt.want, v, 1e-9)
+			}
+		})
+	}
+
+	expr, err := parseFieldExpression("rate(total) * 60")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"total"}, expr.fieldNames())
+	_, ok := expr.eval(cur, nil)
+	assert.False(t, ok, "the first data point of a series has no rate")
+
+	for _, invalid := range []string{"", "total +", "(total", "sum(total)", "total total", "1.2.3"} {
+		_, err = parseFieldExpression(invalid)
+		assert.ErrorIs(t, err, errInvalidFieldExpression, invalid)
+	}
+}
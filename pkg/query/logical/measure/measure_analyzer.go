@@ -21,9 +21,12 @@ import (
 	"context"
 	"math"
 
+	"github.com/pkg/errors"
+
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 	"github.com/apache/skywalking-banyandb/pkg/query/logical"
 )
 
@@ -54,6 +57,16 @@ func BuildSchema(md *databasev1.Measure, indexRules []*databasev1.IndexRule) (lo
 
 // Analyze converts logical expressions to executable operation tree represented by Plan.
 func Analyze(_ context.Context, criteria *measurev1.QueryRequest, metadata *commonv1.Metadata, s logical.Schema) (logical.Plan, error) {
+	var after *pbv1.Cursor
+	if token := criteria.GetContinuationToken(); len(token) > 0 {
+		if criteria.GetOrderBy().GetIndexRuleName() != "" || criteria.GetGroupBy() != nil || criteria.GetAgg() != nil || criteria.GetTop() != nil {
+			return nil, errors.New("a continuation token requires the data points ordered by timestamps, which are neither grouped nor aggregated")
+		}
+		var err error
+		if after, err = pbv1.ParseCursor(token); err != nil {
+			return nil, err
+		}
+	}
 	groupByEntity := false
 	var groupByTags [][]*logical.Tag
 	if criteria.GetGroupBy() != nil {
@@ -79,18 +92,20 @@ func Analyze(_ context.Context, criteria *measurev1.QueryRequest, metadata *comm
 	}
 	pushedLimit := int(limitParameter + criteria.GetOffset())
 
-	if criteria.GetGroupBy() != nil {
-		plan = newUnresolvedGroupBy(plan, groupByTags, groupByEntity)
+	if criteria.GetGroupBy() != nil && criteria.GetAgg() != nil && !groupByEntity {
+		// the groups hold their aggregation states instead of the data points
+		plan = newUnresolvedHashAggregation(plan, groupByTags, criteria.GetAgg())
 		pushedLimit = math.MaxInt
-	}
+	} else {
+		if criteria.GetGroupBy() != nil {
+			plan = newUnresolvedGroupBy(plan, groupByTags, groupByEntity)
+			pushedLimit = math.MaxInt
+		}
 
-	if criteria.GetAgg() != nil {
-		plan = newUnresolvedAggregation(plan,
-			logical.NewField(criteria.GetAgg().GetFieldName()),
-			criteria.GetAgg().GetFunction(),
-			criteria.GetGroupBy() != nil,
-		)
-		pushedLimit = math.MaxInt
+		if criteria.GetAgg() != nil {
+			plan = newUnresolvedAggregation(plan, criteria.GetAgg(), criteria.GetGroupBy() != nil)
+			pushedLimit = math.MaxInt
+		}
 	}
 
 	if criteria.GetTop() != nil {
@@ -98,6 +113,10 @@ func Analyze(_ context.Context, criteria *measurev1.QueryRequest, metadata *comm
 	}
 
 	plan = limit(plan, criteria.GetOffset(), limitParameter)
+
+	if len(criteria.GetFieldExpressions()) > 0 {
+		plan = fieldExpressions(plan, criteria.GetFieldExpressions())
+	}
 	p, err := plan.Analyze(s)
 	if err != nil {
 		return nil, err
@@ -105,6 +124,7 @@ func Analyze(_ context.Context, criteria *measurev1.QueryRequest, metadata *comm
 	rules := []logical.OptimizeRule{
 		logical.NewPushDownOrder(criteria.OrderBy),
 		logical.NewPushDownMaxSize(pushedLimit),
+		logical.NewPushDownCursor(after),
 	}
 	if err := logical.ApplyRules(p, rules...); err != nil {
 		return nil, err
@@ -112,8 +132,39 @@ func Analyze(_ context.Context, criteria *measurev1.QueryRequest, metadata *comm
 	return p, nil
 }
 
+// ContinuationToken returns the token to query the page next to the data points, which are the output of the plan.
+// It's nil if the page isn't full, or the data points aren't those of an entity ordered by timestamps.
+func ContinuationToken(plan logical.Plan, dataPoints []*measurev1.DataPoint) []byte {
+	for p := plan; ; p = p.Children()[0] {
+		switch v := p.(type) {
+		case *fieldExpressionPlan:
+		case *limitPlan:
+			if len(dataPoints) < int(v.limit) {
+				return nil
+			}
+		case *localIndexScan:
+			return v.continuationToken(dataPoints)
+		case *distributedPlan:
+			return v.continuationToken()
+		default:
+			// the data points grouped or aggregated have no position
+			return nil
+		}
+	}
+}
+
 // DistributedAnalyze converts logical expressions to executable operation tree represented by Plan.
 func DistributedAnalyze(criteria *measurev1.QueryRequest, s logical.Schema) (logical.Plan, error) {
+	var after pbv1.NodeCursors
+	if token := criteria.GetContinuationToken(); len(token) > 0 {
+		if criteria.GetOrderBy().GetIndexRuleName() != "" || criteria.GetGroupBy() != nil || criteria.GetAgg() != nil || criteria.GetTop() != nil {
+			return nil, errors.New("a continuation token requires the data points ordered by timestamps, which are neither grouped nor aggregated")
+		}
+		var err error
+		if after, err = pbv1.ParseNodeCursors(token); err != nil {
+			return nil, err
+		}
+	}
 	groupByEntity := false
 	var groupByTags [][]*logical.Tag
 	if criteria.GetGroupBy() != nil {
@@ -130,7 +181,7 @@ func DistributedAnalyze(criteria *measurev1.QueryRequest, s logical.Schema) (log
 	}
 
 	// parse fields
-	plan := newUnresolvedDistributed(criteria)
+	plan := newUnresolvedDistributed(criteria, after)
 
 	// parse limit and offset
 	limitParameter := criteria.GetLimit()
@@ -139,18 +190,20 @@ func DistributedAnalyze(criteria *measurev1.QueryRequest, s logical.Schema) (log
 	}
 	pushedLimit := int(limitParameter + criteria.GetOffset())
 
-	if criteria.GetGroupBy() != nil {
-		plan = newUnresolvedGroupBy(plan, groupByTags, groupByEntity)
+	if criteria.GetGroupBy() != nil && criteria.GetAgg() != nil && !groupByEntity {
+		// the groups hold their aggregation states instead of the data points
+		plan = newUnresolvedHashAggregation(plan, groupByTags, criteria.GetAgg())
 		pushedLimit = math.MaxInt
-	}
+	} else {
+		if criteria.GetGroupBy() != nil {
+			plan = newUnresolvedGroupBy(plan, groupByTags, groupByEntity)
+			pushedLimit = math.MaxInt
+		}
 
-	if criteria.GetAgg() != nil {
-		plan = newUnresolvedAggregation(plan,
-			logical.NewField(criteria.GetAgg().GetFieldName()),
-			criteria.GetAgg().GetFunction(),
-			criteria.GetGroupBy() != nil,
-		)
-		pushedLimit = math.MaxInt
+		if criteria.GetAgg() != nil {
+			plan = newUnresolvedAggregation(plan, criteria.GetAgg(), criteria.GetGroupBy() != nil)
+			pushedLimit = math.MaxInt
+		}
 	}
 
 	if criteria.GetTop() != nil {
@@ -158,6 +211,10 @@ func DistributedAnalyze(criteria *measurev1.QueryRequest, s logical.Schema) (log
 	}
 
 	plan = limit(plan, criteria.GetOffset(), limitParameter)
+
+	if len(criteria.GetFieldExpressions()) > 0 {
+		plan = fieldExpressions(plan, criteria.GetFieldExpressions())
+	}
 	p, err := plan.Analyze(s)
 	if err != nil {
 		return nil, err
@@ -183,5 +240,5 @@ func parseFields(criteria *measurev1.QueryRequest, metadata *commonv1.Metadata,
 	}
 	timeRange := criteria.GetTimeRange()
 	return indexScan(timeRange.GetBegin().AsTime(), timeRange.GetEnd().AsTime(), metadata,
-		logical.ToTags(criteria.GetTagProjection()), projFields, groupByEntity, criteria.GetCriteria())
+		logical.ToTags(criteria.GetTagProjection()), projFields, groupByEntity, criteria.GetCriteria(), logical.ToShardIDs(criteria.GetShardIds()))
 }
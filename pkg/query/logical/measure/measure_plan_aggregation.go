@@ -42,14 +42,16 @@ type unresolvedAggregation struct {
 	unresolvedInput  logical.UnresolvedPlan
 	aggregationField *logical.Field
 	aggrFunc         modelv1.AggregationFunction
+	percentile       float64
 	isGroup          bool
 }
 
-func newUnresolvedAggregation(input logical.UnresolvedPlan, aggrField *logical.Field, aggrFunc modelv1.AggregationFunction, isGroup bool) logical.UnresolvedPlan {
+func newUnresolvedAggregation(input logical.UnresolvedPlan, agg *measurev1.QueryRequest_Aggregation, isGroup bool) logical.UnresolvedPlan {
 	return &unresolvedAggregation{
 		unresolvedInput:  input,
-		aggrFunc:         aggrFunc,
-		aggregationField: aggrField,
+		aggrFunc:         agg.GetFunction(),
+		aggregationField: logical.NewField(agg.GetFieldName()),
+		percentile:       agg.GetPercentile(),
 		isGroup:          isGroup,
 	}
 }
@@ -69,14 +71,41 @@ func (gba *unresolvedAggregation) Analyze(measureSchema logical.Schema) (logical
 		return nil, errors.Wrap(errFieldNotDefined, "aggregation schema")
 	}
 	fieldRef := aggregationFieldRefs[0]
+	isFloat, err := isFloatAggregation(gba.aggrFunc, fieldRef)
+	if err != nil {
+		return nil, err
+	}
+	if isFloat {
+		return newAggregationPlan[float64](gba, prevPlan, schema, fieldRef)
+	}
+	return newAggregationPlan[int64](gba, prevPlan, schema, fieldRef)
+}
+
+// isFloatAggregation tells whether the aggregation of the field is computed in float64 rather than int64.
+func isFloatAggregation(aggrFunc modelv1.AggregationFunction, fieldRef *logical.FieldRef) (bool, error) {
+	switch aggrFunc {
+	case modelv1.AggregationFunction_AGGREGATION_FUNCTION_COUNT_DISTINCT:
+		// the distinct values of any type are counted, including the sketches of the rollups
+		return false, nil
+	case modelv1.AggregationFunction_AGGREGATION_FUNCTION_PERCENTILE:
+		// the percentiles of the integers are fractional, and the rollups store the sketches
+		return true, nil
+	}
 	switch fieldRef.Spec.Spec.FieldType {
 	case databasev1.FieldType_FIELD_TYPE_INT:
-		return newAggregationPlan[int64](gba, prevPlan, schema, fieldRef)
+		return false, nil
 	case databasev1.FieldType_FIELD_TYPE_FLOAT:
-		return newAggregationPlan[float64](gba, prevPlan, schema, fieldRef)
+		return true, nil
 	default:
-		return nil, errors.WithMessagef(errUnsupportedAggregationField, "field: %s", fieldRef.Spec.Spec)
+		return false, errors.WithMessagef(errUnsupportedAggregationField, "field: %s", fieldRef.Spec.Spec)
+	}
+}
+
+func newAggregationFunc[N aggregation.Number](aggrFunc modelv1.AggregationFunction, percentile float64) (aggregation.Func[N], error) {
+	if aggrFunc == modelv1.AggregationFunction_AGGREGATION_FUNCTION_PERCENTILE {
+		return aggregation.NewPercentileFunc[N](percentile)
 	}
+	return aggregation.NewFunc[N](aggrFunc)
 }
 
 type aggregationPlan[N aggregation.Number] struct {
@@ -91,7 +120,7 @@ type aggregationPlan[N aggregation.Number] struct {
 func newAggregationPlan[N aggregation.Number](gba *unresolvedAggregation, prevPlan logical.Plan,
 	measureSchema logical.Schema, fieldRef *logical.FieldRef,
 ) (*aggregationPlan[N], error) {
-	aggrFunc, err := aggregation.NewFunc[N](gba.aggrFunc)
+	aggrFunc, err := newAggregationFunc[N](gba.aggrFunc, gba.percentile)
 	if err != nil {
 		return nil, err
 	}
@@ -170,12 +199,10 @@ func (ami *aggGroupIterator[N]) Current() []*measurev1.DataPoint {
 	for _, dp := range group {
 		value := dp.GetFields()[ami.aggregationFieldRef.Spec.FieldIdx].
 			GetValue()
-		v, err := aggregation.FromFieldValue[N](value)
-		if err != nil {
+		if err := aggregation.In(ami.aggrFunc, value); err != nil {
 			ami.err = err
 			return nil
 		}
-		ami.aggrFunc.In(v)
 		if resultDp != nil {
 			continue
 		}
@@ -235,12 +262,10 @@ func (ami *aggAllIterator[N]) Next() bool {
 		for _, dp := range group {
 			value := dp.GetFields()[ami.aggregationFieldRef.Spec.FieldIdx].
 				GetValue()
-			v, err := aggregation.FromFieldValue[N](value)
-			if err != nil {
+			if err := aggregation.In(ami.aggrFunc, value); err != nil {
 				ami.err = err
 				return false
 			}
-			ami.aggrFunc.In(v)
 			if resultDp != nil {
 				continue
 			}
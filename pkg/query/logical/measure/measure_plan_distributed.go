@@ -20,7 +20,9 @@ package measure
 import (
 	"context"
 	"fmt"
+	"io"
 
+	"github.com/pkg/errors"
 	"go.uber.org/multierr"
 	"google.golang.org/protobuf/proto"
 
@@ -39,11 +41,13 @@ var _ logical.UnresolvedPlan = (*unresolvedDistributed)(nil)
 
 type unresolvedDistributed struct {
 	originalQuery *measurev1.QueryRequest
+	after         pbv1.NodeCursors
 }
 
-func newUnresolvedDistributed(query *measurev1.QueryRequest) logical.UnresolvedPlan {
+func newUnresolvedDistributed(query *measurev1.QueryRequest, after pbv1.NodeCursors) logical.UnresolvedPlan {
 	return &unresolvedDistributed{
 		originalQuery: query,
+		after:         after,
 	}
 }
 
@@ -80,12 +84,15 @@ func (ud *unresolvedDistributed) Analyze(s logical.Schema) (logical.Plan, error)
 		Criteria:        ud.originalQuery.Criteria,
 		Limit:           limit,
 		OrderBy:         ud.originalQuery.OrderBy,
+		// the token is replaced by the position of each node when the query is sent to it
+		ContinuationToken: ud.originalQuery.ContinuationToken,
 	}
 	if ud.originalQuery.OrderBy == nil {
 		return &distributedPlan{
 			queryTemplate: temp,
 			s:             s,
 			sortByTime:    true,
+			after:         ud.after,
 		}, nil
 	}
 	if ud.originalQuery.OrderBy.IndexRuleName == "" {
@@ -93,6 +100,7 @@ func (ud *unresolvedDistributed) Analyze(s logical.Schema) (logical.Plan, error)
 			queryTemplate: temp,
 			s:             s,
 			sortByTime:    true,
+			after:         ud.after,
 		}
 		if ud.originalQuery.OrderBy.Sort == modelv1.Sort_SORT_DESC {
 			result.desc = true
@@ -123,8 +131,12 @@ func (ud *unresolvedDistributed) Analyze(s logical.Schema) (logical.Plan, error)
 }
 
 type distributedPlan struct {
-	s                 logical.Schema
-	queryTemplate     *measurev1.QueryRequest
+	s             logical.Schema
+	queryTemplate *measurev1.QueryRequest
+	// after are the positions of the nodes the page starts from
+	after pbv1.NodeCursors
+	// pages are the responses of the nodes, whose data points are taken by the iterator
+	pages             []*sortableElements
 	sortTagSpec       logical.TagSpec
 	sortByTime        bool
 	desc              bool
@@ -138,30 +150,75 @@ func (t *distributedPlan) Execute(ctx context.Context) (executor.MIterator, erro
 	if t.maxDataPointsSize > 0 {
 		query.Limit = t.maxDataPointsSize
 	}
-	ff, err := dctx.Broadcast(data.TopicMeasureQuery, bus.NewMessage(bus.MessageID(dctx.TimeRange().Begin.Nanos), query))
+	streams, err := dctx.BroadcastChunked(data.TopicMeasureQuery, bus.NewMessage(bus.MessageID(dctx.TimeRange().Begin.Nanos), query))
 	if err != nil {
 		return nil, err
 	}
 	var allErr error
 	var see []sort.Iterator[*comparableDataPoint]
-	for _, f := range ff {
-		if m, getErr := f.Get(); getErr != nil {
-			allErr = multierr.Append(allErr, getErr)
-		} else {
-			d := m.Data()
-			if d == nil {
-				continue
+	span := executor.FromSpan(ctx)
+	for _, s := range streams {
+		page, recvErr := t.receive(s, span)
+		_ = s.Close()
+		t.pages = append(t.pages, page)
+		if recvErr != nil {
+			if !dctx.ReportNodeFailure(s.Node(), recvErr) {
+				allErr = multierr.Append(allErr, errors.WithMessagef(recvErr, "node %s", s.Node()))
 			}
-			see = append(see,
-				newSortableElements(d.(*measurev1.QueryResponse).DataPoints,
-					t.sortByTime, t.sortTagSpec))
+			continue
 		}
+		see = append(see, page)
 	}
 	return &sortedMIterator{
 		Iterator: sort.NewItemIter[*comparableDataPoint](see, t.desc),
 	}, allErr
 }
 
+// receive takes all the chunks of a node's response, whose continuation token comes with the last one.
+// The page of a failed node has no data points, which keeps the position of the node.
+func (t *distributedPlan) receive(s executor.NodeStream, span *executor.Span) (*sortableElements, error) {
+	var dataPoints []*measurev1.DataPoint
+	var token []byte
+	var err error
+	for {
+		var m bus.Message
+		if m, err = s.Recv(); err != nil {
+			break
+		}
+		resp, ok := m.Data().(*measurev1.QueryResponse)
+		if !ok {
+			err = errors.Errorf("invalid response type %T", m.Data())
+			break
+		}
+		if resp.Trace != nil {
+			span.AddRemote(resp.Trace)
+		}
+		dataPoints = append(dataPoints, resp.DataPoints...)
+		token = resp.ContinuationToken
+	}
+	if !errors.Is(err, io.EOF) {
+		return &sortableElements{node: s.Node(), shardIDs: s.ShardIDs()}, err
+	}
+	page := newSortableElements(dataPoints, t.sortByTime, t.sortTagSpec)
+	page.node, page.shardIDs, page.token = s.Node(), s.ShardIDs(), token
+	return page, nil
+}
+
+// continuationToken returns the token resuming every node after its data points taken by the iterator.
+// It's nil if the data points aren't ordered by timestamps.
+func (t *distributedPlan) continuationToken() []byte {
+	if !t.sortByTime {
+		return nil
+	}
+	cursors := make(pbv1.NodeCursors, 0, len(t.pages))
+	for _, p := range t.pages {
+		// the responses of a node for the same shards are opened in the same order every page
+		c, _ := t.after.Find(p.node, p.shardIDs, cursors.Count(p.node, p.shardIDs))
+		cursors = append(cursors, c.Advance(p.returned, int(p.returned) == len(p.dataPoints), p.token))
+	}
+	return cursors.MarshalToken()
+}
+
 func (t *distributedPlan) String() string {
 	return fmt.Sprintf("distributed:%s", t.queryTemplate.String())
 }
@@ -182,6 +239,7 @@ var _ sort.Comparable = (*comparableDataPoint)(nil)
 
 type comparableDataPoint struct {
 	*measurev1.DataPoint
+	from      *sortableElements
 	sortField []byte
 }
 
@@ -210,10 +268,16 @@ func (e *comparableDataPoint) SortedField() []byte {
 var _ sort.Iterator[*comparableDataPoint] = (*sortableElements)(nil)
 
 type sortableElements struct {
-	cur          *comparableDataPoint
-	dataPoints   []*measurev1.DataPoint
-	sortTagSpec  logical.TagSpec
-	index        int
+	cur        *comparableDataPoint
+	dataPoints []*measurev1.DataPoint
+	// node is the data node returning the data points, whose continuation token is token
+	node        string
+	shardIDs    []uint32
+	token       []byte
+	sortTagSpec logical.TagSpec
+	index       int
+	// returned is the number of the data points taken by the iterator
+	returned     uint32
 	isSortByTime bool
 }
 
@@ -248,6 +312,7 @@ func (s *sortableElements) iter(fn func(*measurev1.DataPoint) (*comparableDataPo
 	if err != nil {
 		return s.iter(fn)
 	}
+	cur.from = s
 	s.cur = cur
 	return s.index <= len(s.dataPoints)
 }
@@ -258,6 +323,14 @@ type sortedMIterator struct {
 	sort.Iterator[*comparableDataPoint]
 }
 
+func (s *sortedMIterator) Next() bool {
+	if !s.Iterator.Next() {
+		return false
+	}
+	s.Val().from.returned++
+	return true
+}
+
 func (s *sortedMIterator) Current() []*measurev1.DataPoint {
 	return []*measurev1.DataPoint{s.Val().DataPoint}
 }
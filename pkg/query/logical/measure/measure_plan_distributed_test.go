@@ -0,0 +1,153 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/query/executor"
+)
+
+var baseTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type fakeStream struct {
+	err      error
+	node     string
+	shardIDs []uint32
+	chunks   []*measurev1.QueryResponse
+	closed   bool
+}
+
+func (f *fakeStream) Node() string {
+	return f.node
+}
+
+func (f *fakeStream) ShardIDs() []uint32 {
+	return f.shardIDs
+}
+
+func (f *fakeStream) Recv() (bus.Message, error) {
+	if len(f.chunks) == 0 {
+		if f.err != nil {
+			return bus.Message{}, f.err
+		}
+		return bus.Message{}, io.EOF
+	}
+	c := f.chunks[0]
+	f.chunks = f.chunks[1:]
+	return bus.NewMessage(bus.MessageID(1), c), nil
+}
+
+func (f *fakeStream) Close() error {
+	f.closed = true
+	return nil
+}
+
+type fakeDistributedContext struct {
+	bus.Broadcaster
+	failures map[string]error
+	query    *measurev1.QueryRequest
+	streams  []*fakeStream
+}
+
+func (f *fakeDistributedContext) TimeRange() *modelv1.TimeRange {
+	return &modelv1.TimeRange{Begin: timestamppb.New(baseTime), End: timestamppb.New(baseTime.Add(time.Hour))}
+}
+
+func (f *fakeDistributedContext) BroadcastChunked(_ bus.Topic, m bus.Message) ([]executor.NodeStream, error) {
+	f.query = m.Data().(*measurev1.QueryRequest)
+	streams := make([]executor.NodeStream, 0, len(f.streams))
+	for _, s := range f.streams {
+		streams = append(streams, s)
+	}
+	return streams, nil
+}
+
+func (f *fakeDistributedContext) ReportNodeFailure(node string, err error) bool {
+	if f.failures == nil {
+		f.failures = make(map[string]error)
+	}
+	f.failures[node] = err
+	return true
+}
+
+// chunk returns a chunk of a response holding the data points of the seconds after the base time.
+func chunk(token string, seconds ...int) *measurev1.QueryResponse {
+	resp := &measurev1.QueryResponse{ContinuationToken: []byte(token)}
+	for _, s := range seconds {
+		resp.DataPoints = append(resp.DataPoints, &measurev1.DataPoint{Timestamp: timestamppb.New(baseTime.Add(time.Duration(s) * time.Second))})
+	}
+	return resp
+}
+
+func TestDistributedPlan_ContinuationToken(t *testing.T) {
+	after := pbv1.NodeCursors{
+		{Node: "n1", ShardIDs: []uint32{1}, Skip: 4},
+		{Node: "n3", Index: 1, Token: []byte("n3-previous"), Skip: 2},
+	}
+	errNode := errors.New("node failure")
+	dctx := &fakeDistributedContext{streams: []*fakeStream{
+		{node: "n1", shardIDs: []uint32{1}, chunks: []*measurev1.QueryResponse{chunk("", 10, 8), chunk("n1", 6)}},
+		{node: "n2", chunks: []*measurev1.QueryResponse{chunk("n2", 9)}},
+		// a cold node is queried for every time range of the cold tiers, whose responses are told apart by their order
+		{node: "n3", chunks: []*measurev1.QueryResponse{chunk("n3", 5)}},
+		{node: "n3", err: errNode},
+	}}
+	plan := &distributedPlan{
+		queryTemplate: &measurev1.QueryRequest{Limit: 3, ContinuationToken: after.MarshalToken()},
+		sortByTime:    true,
+		desc:          true,
+		after:         after,
+	}
+	iter, err := plan.Execute(executor.WithDistributedExecutionContext(context.Background(), dctx))
+	require.NoError(t, err)
+	var seconds []int64
+	for i := 0; i < 3 && iter.Next(); i++ {
+		seconds = append(seconds, iter.Current()[0].GetTimestamp().AsTime().Sub(baseTime).Milliseconds()/1000)
+	}
+	assert.Equal(t, []int64{10, 9, 8}, seconds)
+	// the nodes pick their own positions from the token
+	assert.Equal(t, after.MarshalToken(), dctx.query.GetContinuationToken())
+	assert.Equal(t, map[string]error{"n3": errNode}, dctx.failures)
+	for _, s := range dctx.streams {
+		assert.True(t, s.closed)
+	}
+	got, err := pbv1.ParseNodeCursors(plan.continuationToken())
+	require.NoError(t, err)
+	assert.Equal(t, pbv1.NodeCursors{
+		// the data points of n1 taken are skipped after its position, as some of them are left
+		{Node: "n1", ShardIDs: []uint32{1}, Skip: 6},
+		// all the data points of n2 are taken, so its token resumes it
+		{Node: "n2", Token: []byte("n2")},
+		{Node: "n3"},
+		// the failed node keeps its position
+		{Node: "n3", Index: 1, Token: []byte("n3-previous"), Skip: 2},
+	}, got)
+}
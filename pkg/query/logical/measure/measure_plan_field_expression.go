@@ -0,0 +1,197 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/query/executor"
+	"github.com/apache/skywalking-banyandb/pkg/query/logical"
+)
+
+var (
+	_ logical.UnresolvedPlan = (*unresolvedFieldExpressions)(nil)
+	_ logical.Plan           = (*fieldExpressionPlan)(nil)
+)
+
+type unresolvedFieldExpressions struct {
+	unresolvedInput logical.UnresolvedPlan
+	expressions     []*measurev1.QueryRequest_FieldExpression
+}
+
+func fieldExpressions(input logical.UnresolvedPlan, expressions []*measurev1.QueryRequest_FieldExpression) logical.UnresolvedPlan {
+	return &unresolvedFieldExpressions{
+		unresolvedInput: input,
+		expressions:     expressions,
+	}
+}
+
+func (ufe *unresolvedFieldExpressions) Analyze(measureSchema logical.Schema) (logical.Plan, error) {
+	prevPlan, err := ufe.unresolvedInput.Analyze(measureSchema)
+	if err != nil {
+		return nil, err
+	}
+	plan := &fieldExpressionPlan{
+		Parent: &logical.Parent{
+			UnresolvedInput: ufe.unresolvedInput,
+			Input:           prevPlan,
+		},
+		names: make([]string, len(ufe.expressions)),
+		exprs: make([]fieldExpr, len(ufe.expressions)),
+	}
+	for i, fe := range ufe.expressions {
+		expr, errParse := parseFieldExpression(fe.GetExpression())
+		if errParse != nil {
+			return nil, errParse
+		}
+		// the fields are either projected, or aggregated
+		for _, name := range expr.fieldNames() {
+			if _, errRef := prevPlan.Schema().CreateFieldRef(logical.NewField(name)); errRef != nil {
+				return nil, errors.WithMessagef(errRef, "field expression %s", fe.GetName())
+			}
+		}
+		plan.names[i], plan.exprs[i] = fe.GetName(), expr
+	}
+	return plan, nil
+}
+
+// fieldExpressionPlan appends the fields computed by the expressions to the data points.
+// The data points having the same tags are a series, in which rate() and delta() refer to the previous data point by time.
+type fieldExpressionPlan struct {
+	*logical.Parent
+	names []string
+	exprs []fieldExpr
+}
+
+func (f *fieldExpressionPlan) Execute(ec context.Context) (executor.MIterator, error) {
+	iter, err := f.Parent.Input.(executor.MeasureExecutable).Execute(ec)
+	if err != nil {
+		return nil, err
+	}
+	return &fieldExpressionIterator{prev: iter, plan: f}, nil
+}
+
+func (f *fieldExpressionPlan) String() string {
+	exprs := make([]string, len(f.exprs))
+	for i := range f.exprs {
+		exprs[i] = f.names[i] + "=" + f.exprs[i].String()
+	}
+	return fmt.Sprintf("%s FieldExpressions: %s", f.Input, strings.Join(exprs, ","))
+}
+
+func (f *fieldExpressionPlan) Children() []logical.Plan {
+	return []logical.Plan{f.Input}
+}
+
+func (f *fieldExpressionPlan) Schema() logical.Schema {
+	return f.Input.Schema()
+}
+
+// fieldExpressionIterator evaluates the expressions once all the data points are pulled,
+// since the previous data point of a series might come later.
+type fieldExpressionIterator struct {
+	prev   executor.MIterator
+	plan   *fieldExpressionPlan
+	groups [][]*measurev1.DataPoint
+	index  int
+	loaded bool
+}
+
+func (fei *fieldExpressionIterator) Next() bool {
+	if !fei.loaded {
+		fei.load()
+		fei.index = -1
+	}
+	fei.index++
+	return fei.index < len(fei.groups)
+}
+
+func (fei *fieldExpressionIterator) Current() []*measurev1.DataPoint {
+	if fei.index < 0 || fei.index >= len(fei.groups) {
+		return nil
+	}
+	return fei.groups[fei.index]
+}
+
+func (fei *fieldExpressionIterator) Close() error {
+	return fei.prev.Close()
+}
+
+func (fei *fieldExpressionIterator) load() {
+	fei.loaded = true
+	series := make(map[string][]*exprPoint)
+	var points []*exprPoint
+	for fei.prev.Next() {
+		group := fei.prev.Current()
+		fei.groups = append(fei.groups, group)
+		for _, dp := range group {
+			p := &exprPoint{
+				timestamp: dp.GetTimestamp().AsTime(),
+				fields:    make(map[string]*modelv1.FieldValue, len(dp.GetFields())),
+			}
+			for _, field := range dp.GetFields() {
+				p.fields[field.GetName()] = field.GetValue()
+			}
+			key := seriesKey(dp)
+			series[key] = append(series[key], p)
+			points = append(points, p)
+		}
+	}
+	previous := make(map[*exprPoint]*exprPoint, len(points))
+	for _, ss := range series {
+		sort.SliceStable(ss, func(i, j int) bool { return ss[i].timestamp.Before(ss[j].timestamp) })
+		for i := 1; i < len(ss); i++ {
+			previous[ss[i]] = ss[i-1]
+		}
+	}
+	i := 0
+	for _, group := range fei.groups {
+		for _, dp := range group {
+			p := points[i]
+			i++
+			for j, expr := range fei.plan.exprs {
+				value := pbv1.NullFieldValue
+				if v, ok := expr.eval(p, previous[p]); ok {
+					value = &modelv1.FieldValue{Value: &modelv1.FieldValue_Float{Float: &modelv1.Float{Value: v}}}
+				}
+				dp.Fields = append(dp.Fields, &measurev1.DataPoint_Field{
+					Name:  fei.plan.names[j],
+					Value: value,
+				})
+			}
+		}
+	}
+}
+
+func seriesKey(dp *measurev1.DataPoint) string {
+	var key strings.Builder
+	for _, tf := range dp.GetTagFamilies() {
+		b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(tf)
+		key.Write(b)
+	}
+	return key.String()
+}
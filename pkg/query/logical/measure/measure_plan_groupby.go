@@ -19,6 +19,7 @@ package measure
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"math"
 
@@ -126,15 +127,18 @@ func (g *groupBy) hash(ec context.Context) (mit executor.MIterator, err error) {
 		err = multierr.Append(err, iter.Close())
 	}()
 
-	groupMap := make(map[uint64][]*measurev1.DataPoint)
-	groupLst := make([]uint64, 0)
+	groupMap := make(map[string][]*measurev1.DataPoint)
+	groupLst := make([]string, 0)
+	var buf []byte
 	for iter.Next() {
 		dataPoints := iter.Current()
 		for _, dp := range dataPoints {
-			key, innerErr := formatGroupByKey(dp, g.groupByTagsRefs)
+			var innerErr error
+			buf, innerErr = appendGroupByKey(buf[:0], dp, g.groupByTagsRefs)
 			if innerErr != nil {
 				return nil, innerErr
 			}
+			key := string(buf)
 			group, ok := groupMap[key]
 			if !ok {
 				group = make([]*measurev1.DataPoint, 0)
@@ -151,42 +155,76 @@ func (g *groupBy) hash(ec context.Context) (mit executor.MIterator, err error) {
 }
 
 func formatGroupByKey(point *measurev1.DataPoint, groupByTagsRefs [][]*logical.TagRef) (uint64, error) {
-	hash := xxhash.New()
+	key, err := appendGroupByKey(nil, point, groupByTagsRefs)
+	if err != nil {
+		return 0, err
+	}
+	return xxhash.Sum64(key), nil
+}
+
+// appendGroupByKey appends the typed values of the group-by tags to dst.
+// The values are length-prefixed, so that the keys of the different groups never collide.
+func appendGroupByKey(dst []byte, point *measurev1.DataPoint, groupByTagsRefs [][]*logical.TagRef) ([]byte, error) {
 	for _, tagFamilyRef := range groupByTagsRefs {
 		for _, tagRef := range tagFamilyRef {
 			if tagRef.Spec.TagFamilyIdx >= len(point.GetTagFamilies()) {
-				return 0, errors.New("tag family index out of range")
+				return nil, errors.New("tag family index out of range")
 			}
 			if tagRef.Spec.TagIdx >= len(point.GetTagFamilies()[tagRef.Spec.TagFamilyIdx].GetTags()) {
-				return 0, errors.New("tag index out of range")
+				return nil, errors.New("tag index out of range")
 			}
 			tag := point.GetTagFamilies()[tagRef.Spec.TagFamilyIdx].GetTags()[tagRef.Spec.TagIdx]
 			switch v := tag.GetValue().GetValue().(type) {
 			case *modelv1.TagValue_Str:
-				_, innerErr := hash.Write([]byte(v.Str.GetValue()))
-				if innerErr != nil {
-					return 0, innerErr
-				}
+				dst = append(dst, groupByKeyStr)
+				dst = appendGroupByKeyBytes(dst, []byte(v.Str.GetValue()))
 			case *modelv1.TagValue_Int:
-				_, innerErr := hash.Write(convert.Int64ToBytes(v.Int.GetValue()))
-				if innerErr != nil {
-					return 0, innerErr
+				dst = append(dst, groupByKeyInt)
+				dst = append(dst, convert.Int64ToBytes(v.Int.GetValue())...)
+			case *modelv1.TagValue_StrArray:
+				dst = append(dst, groupByKeyStrArray)
+				dst = binary.AppendUvarint(dst, uint64(len(v.StrArray.GetValue())))
+				for _, str := range v.StrArray.GetValue() {
+					dst = appendGroupByKeyBytes(dst, []byte(str))
 				}
-			case *modelv1.TagValue_IntArray, *modelv1.TagValue_StrArray, *modelv1.TagValue_BinaryData:
-				return 0, errors.New("group-by on array/binary tag is not supported")
+			case *modelv1.TagValue_IntArray:
+				dst = append(dst, groupByKeyIntArray)
+				dst = binary.AppendUvarint(dst, uint64(len(v.IntArray.GetValue())))
+				for _, i := range v.IntArray.GetValue() {
+					dst = append(dst, convert.Int64ToBytes(i)...)
+				}
+			case *modelv1.TagValue_BinaryData:
+				dst = append(dst, groupByKeyBinary)
+				dst = appendGroupByKeyBytes(dst, v.BinaryData)
+			default:
+				dst = append(dst, groupByKeyNull)
 			}
 		}
 	}
-	return hash.Sum64(), nil
+	return dst, nil
+}
+
+const (
+	groupByKeyNull byte = iota
+	groupByKeyStr
+	groupByKeyInt
+	groupByKeyStrArray
+	groupByKeyIntArray
+	groupByKeyBinary
+)
+
+func appendGroupByKeyBytes(dst, b []byte) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(b)))
+	return append(dst, b...)
 }
 
 type groupIterator struct {
-	groupMap map[uint64][]*measurev1.DataPoint
-	groupLst []uint64
+	groupMap map[string][]*measurev1.DataPoint
+	groupLst []string
 	index    int
 }
 
-func newGroupIterator(groupedMap map[uint64][]*measurev1.DataPoint, groupLst []uint64) executor.MIterator {
+func newGroupIterator(groupedMap map[string][]*measurev1.DataPoint, groupLst []string) executor.MIterator {
 	return &groupIterator{
 		groupMap: groupedMap,
 		groupLst: groupLst,
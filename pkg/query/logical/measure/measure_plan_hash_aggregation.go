@@ -0,0 +1,323 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cespare/xxhash"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"google.golang.org/protobuf/proto"
+
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/query/aggregation"
+	"github.com/apache/skywalking-banyandb/pkg/query/executor"
+	"github.com/apache/skywalking-banyandb/pkg/query/logical"
+	"github.com/apache/skywalking-banyandb/pkg/query/spill"
+)
+
+// hashAggregationPartitions is the number of the partitions the data points of the groups exceeding the budget spill to.
+const hashAggregationPartitions = 16
+
+var _ logical.UnresolvedPlan = (*unresolvedHashAggregation)(nil)
+
+type unresolvedHashAggregation struct {
+	unresolvedInput logical.UnresolvedPlan
+	agg             *measurev1.QueryRequest_Aggregation
+	groupBy         [][]*logical.Tag
+}
+
+func newUnresolvedHashAggregation(input logical.UnresolvedPlan, groupBy [][]*logical.Tag,
+	agg *measurev1.QueryRequest_Aggregation,
+) logical.UnresolvedPlan {
+	return &unresolvedHashAggregation{
+		unresolvedInput: input,
+		agg:             agg,
+		groupBy:         groupBy,
+	}
+}
+
+func (ha *unresolvedHashAggregation) Analyze(measureSchema logical.Schema) (logical.Plan, error) {
+	prevPlan, err := ha.unresolvedInput.Analyze(measureSchema)
+	if err != nil {
+		return nil, err
+	}
+	schema := prevPlan.Schema()
+	groupByTagRefs, err := schema.CreateTagRef(ha.groupBy...)
+	if err != nil {
+		return nil, err
+	}
+	aggregationFieldRefs, err := schema.CreateFieldRef(logical.NewField(ha.agg.GetFieldName()))
+	if err != nil {
+		return nil, err
+	}
+	if len(aggregationFieldRefs) == 0 {
+		return nil, errors.Wrap(errFieldNotDefined, "aggregation schema")
+	}
+	isFloat, err := isFloatAggregation(ha.agg.GetFunction(), aggregationFieldRefs[0])
+	if err != nil {
+		return nil, err
+	}
+	if isFloat {
+		return newHashAggregationPlan[float64](ha, prevPlan, groupByTagRefs, aggregationFieldRefs[0])
+	}
+	return newHashAggregationPlan[int64](ha, prevPlan, groupByTagRefs, aggregationFieldRefs[0])
+}
+
+// hashAggregationPlan groups the data points by the hash of the group-by tags, and aggregates them batch by batch.
+// Unlike groupBy followed by aggregationPlan, it only holds the aggregation state of each group rather than its data points.
+// Once the number of groups reaches the budget of the query, the data points of the new groups spill to
+// the partitions picked by the hash, which are aggregated one by one after the groups in memory.
+type hashAggregationPlan[N aggregation.Number] struct {
+	*logical.Parent
+	schema              logical.Schema
+	aggregationFieldRef *logical.FieldRef
+	groupByTagsRefs     [][]*logical.TagRef
+	percentile          float64
+	aggrFunc            modelv1.AggregationFunction
+}
+
+func newHashAggregationPlan[N aggregation.Number](ha *unresolvedHashAggregation, prevPlan logical.Plan,
+	groupByTagsRefs [][]*logical.TagRef, fieldRef *logical.FieldRef,
+) (*hashAggregationPlan[N], error) {
+	// every group creates its own function, this one validates the request
+	if _, err := newAggregationFunc[N](ha.agg.GetFunction(), ha.agg.GetPercentile()); err != nil {
+		return nil, err
+	}
+	return &hashAggregationPlan[N]{
+		Parent: &logical.Parent{
+			UnresolvedInput: ha.unresolvedInput,
+			Input:           prevPlan,
+		},
+		schema:              prevPlan.Schema(),
+		aggregationFieldRef: fieldRef,
+		groupByTagsRefs:     groupByTagsRefs,
+		percentile:          ha.agg.GetPercentile(),
+		aggrFunc:            ha.agg.GetFunction(),
+	}, nil
+}
+
+func (g *hashAggregationPlan[N]) String() string {
+	return fmt.Sprintf("%s HashAggregation: groupBy=%s, aggregation{type=%s,field=%s}",
+		g.Input,
+		logical.FormatTagRefs(", ", g.groupByTagsRefs...),
+		g.aggrFunc,
+		g.aggregationFieldRef.Field.Name)
+}
+
+func (g *hashAggregationPlan[N]) Children() []logical.Plan {
+	return []logical.Plan{g.Input}
+}
+
+func (g *hashAggregationPlan[N]) Schema() logical.Schema {
+	return g.schema.ProjTags(g.groupByTagsRefs...).ProjFields(g.aggregationFieldRef)
+}
+
+func (g *hashAggregationPlan[N]) Execute(ec context.Context) (executor.MIterator, error) {
+	iter, err := g.Parent.Input.(executor.MeasureExecutable).Execute(ec)
+	if err != nil {
+		return nil, err
+	}
+	return &hashAggregationIterator[N]{
+		prev:       iter,
+		plan:       g,
+		groupSpill: executor.FromGroupSpill(ec),
+		account:    executor.FromMemoryAccount(ec),
+	}, nil
+}
+
+type hashAggregationIterator[N aggregation.Number] struct {
+	prev       executor.MIterator
+	plan       *hashAggregationPlan[N]
+	spilled    *spill.DataPoints
+	current    *measurev1.DataPoint
+	err        error
+	results    []*measurev1.DataPoint
+	account    *executor.MemoryAccount
+	groupSpill executor.GroupSpill
+	partition  int
+	aggregated bool
+}
+
+func (hai *hashAggregationIterator[N]) Next() bool {
+	if hai.err != nil {
+		return false
+	}
+	if !hai.aggregated {
+		hai.aggregated = true
+		if hai.results, hai.err = hai.aggregateInput(); hai.err != nil {
+			return false
+		}
+	}
+	for len(hai.results) == 0 {
+		if hai.spilled == nil || hai.partition >= hai.spilled.Partitions() {
+			hai.current = nil
+			return false
+		}
+		if hai.results, hai.err = hai.aggregatePartition(hai.partition); hai.err != nil {
+			return false
+		}
+		hai.partition++
+	}
+	hai.current = hai.results[0]
+	hai.results = hai.results[1:]
+	return true
+}
+
+func (hai *hashAggregationIterator[N]) Current() []*measurev1.DataPoint {
+	if hai.current == nil {
+		return nil
+	}
+	return []*measurev1.DataPoint{hai.current}
+}
+
+func (hai *hashAggregationIterator[N]) Close() error {
+	err := multierr.Combine(hai.err, hai.prev.Close())
+	if hai.spilled != nil {
+		err = multierr.Append(err, hai.spilled.Close())
+	}
+	return err
+}
+
+// aggregateInput aggregates the batches of the input. The data points of the groups
+// which can't be held in memory spill, and the results of the others are returned.
+func (hai *hashAggregationIterator[N]) aggregateInput() ([]*measurev1.DataPoint, error) {
+	groups := newHashGroups(hai.plan, hai.account)
+	full := func() bool {
+		return hai.groupSpill.Dir != "" && hai.groupSpill.MaxGroups > 0 && len(groups.keys) >= hai.groupSpill.MaxGroups
+	}
+	var key []byte
+	var err error
+	for hai.prev.Next() {
+		for _, dp := range hai.prev.Current() {
+			if key, err = appendGroupByKey(key[:0], dp, hai.plan.groupByTagsRefs); err != nil {
+				return nil, err
+			}
+			if groups.contains(key) || !full() {
+				if err = groups.add(key, dp); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if hai.spilled == nil {
+				hai.spilled = spill.NewDataPoints(hai.groupSpill.Dir, hashAggregationPartitions)
+			}
+			if err = hai.spilled.Append(int(xxhash.Sum64(key)%hashAggregationPartitions), dp); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return groups.results()
+}
+
+// aggregatePartition aggregates the data points spilled to the partition.
+// A partition holds a fraction of the groups exceeding the budget, so it's aggregated in memory regardless of the budget.
+func (hai *hashAggregationIterator[N]) aggregatePartition(partition int) ([]*measurev1.DataPoint, error) {
+	dataPoints, err := hai.spilled.Read(partition)
+	if err != nil {
+		return nil, err
+	}
+	groups := newHashGroups(hai.plan, hai.account)
+	var key []byte
+	for _, dp := range dataPoints {
+		if key, err = appendGroupByKey(key[:0], dp, hai.plan.groupByTagsRefs); err != nil {
+			return nil, err
+		}
+		if err = groups.add(key, dp); err != nil {
+			return nil, err
+		}
+	}
+	return groups.results()
+}
+
+// hashGroups holds the aggregation states of the groups keyed by their group-by tags.
+type hashGroups[N aggregation.Number] struct {
+	plan    *hashAggregationPlan[N]
+	account *executor.MemoryAccount
+	groups  map[string]*hashGroup[N]
+	// keys keeps the order the groups are created in
+	keys []string
+}
+
+type hashGroup[N aggregation.Number] struct {
+	aggrFunc    aggregation.Func[N]
+	tagFamilies []*modelv1.TagFamily
+}
+
+func newHashGroups[N aggregation.Number](plan *hashAggregationPlan[N], account *executor.MemoryAccount) *hashGroups[N] {
+	return &hashGroups[N]{
+		plan:    plan,
+		account: account,
+		groups:  make(map[string]*hashGroup[N]),
+	}
+}
+
+func (hg *hashGroups[N]) contains(key []byte) bool {
+	_, ok := hg.groups[string(key)]
+	return ok
+}
+
+func (hg *hashGroups[N]) add(key []byte, dp *measurev1.DataPoint) error {
+	group, ok := hg.groups[string(key)]
+	if !ok {
+		// the state of a group is accounted by its key and its tags, the aggregation function is small beside them
+		size := len(key)
+		for _, tf := range dp.GetTagFamilies() {
+			size += proto.Size(tf)
+		}
+		if err := hg.account.Grow(int64(size)); err != nil {
+			return err
+		}
+		aggrFunc, err := newAggregationFunc[N](hg.plan.aggrFunc, hg.plan.percentile)
+		if err != nil {
+			return err
+		}
+		group = &hashGroup[N]{
+			aggrFunc:    aggrFunc,
+			tagFamilies: dp.GetTagFamilies(),
+		}
+		k := string(key)
+		hg.groups[k] = group
+		hg.keys = append(hg.keys, k)
+	}
+	return aggregation.In(group.aggrFunc, dp.GetFields()[hg.plan.aggregationFieldRef.Spec.FieldIdx].GetValue())
+}
+
+func (hg *hashGroups[N]) results() ([]*measurev1.DataPoint, error) {
+	results := make([]*measurev1.DataPoint, 0, len(hg.keys))
+	for _, k := range hg.keys {
+		group := hg.groups[k]
+		val, err := aggregation.ToFieldValue(group.aggrFunc.Val())
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &measurev1.DataPoint{
+			TagFamilies: group.tagFamilies,
+			Fields: []*measurev1.DataPoint_Field{
+				{
+					Name:  hg.plan.aggregationFieldRef.Field.Name,
+					Value: val,
+				},
+			},
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,141 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/query/executor"
+	"github.com/apache/skywalking-banyandb/pkg/query/logical"
+)
+
+type batchIterator struct {
+	batches [][]*measurev1.DataPoint
+	index   int
+}
+
+func (bi *batchIterator) Next() bool {
+	bi.index++
+	return bi.index <= len(bi.batches)
+}
+
+func (bi *batchIterator) Current() []*measurev1.DataPoint {
+	return bi.batches[bi.index-1]
+}
+
+func (bi *batchIterator) Close() error {
+	return nil
+}
+
+type batchExecutable struct {
+	logical.Plan
+	batches [][]*measurev1.DataPoint
+}
+
+func (be *batchExecutable) Execute(context.Context) (executor.MIterator, error) {
+	return &batchIterator{batches: be.batches}, nil
+}
+
+func TestHashAggregation(t *testing.T) {
+	var batches [][]*measurev1.DataPoint
+	want := make(map[string]int64)
+	for b := 0; b < 4; b++ {
+		var batch []*measurev1.DataPoint
+		for i := 0; i < 25; i++ {
+			svc := fmt.Sprintf("svc-%d", (b*25+i)%10)
+			batch = append(batch, &measurev1.DataPoint{
+				TagFamilies: []*modelv1.TagFamily{{
+					Name: "default",
+					Tags: []*modelv1.Tag{{Key: "svc", Value: &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: svc}}}}},
+				}},
+				Fields: []*measurev1.DataPoint_Field{{
+					Name:  "value",
+					Value: &modelv1.FieldValue{Value: &modelv1.FieldValue_Int{Int: &modelv1.Int{Value: int64(i)}}},
+				}},
+			})
+			want[svc] += int64(i)
+		}
+		batches = append(batches, batch)
+	}
+	plan := &hashAggregationPlan[int64]{
+		Parent:              &logical.Parent{Input: &batchExecutable{batches: batches}},
+		aggregationFieldRef: &logical.FieldRef{Field: logical.NewField("value"), Spec: &logical.FieldSpec{}},
+		groupByTagsRefs:     [][]*logical.TagRef{{{Tag: logical.NewTag("default", "svc"), Spec: &logical.TagSpec{}}}},
+		aggrFunc:            modelv1.AggregationFunction_AGGREGATION_FUNCTION_SUM,
+	}
+	for _, maxGroups := range []int{0, 3} {
+		t.Run(fmt.Sprintf("max groups %d", maxGroups), func(t *testing.T) {
+			dir := t.TempDir()
+			iter, err := plan.Execute(executor.WithGroupSpill(context.Background(), executor.GroupSpill{Dir: dir, MaxGroups: maxGroups}))
+			require.NoError(t, err)
+			got := make(map[string]int64)
+			for iter.Next() {
+				for _, dp := range iter.Current() {
+					got[dp.GetTagFamilies()[0].GetTags()[0].GetValue().GetStr().GetValue()] = dp.GetFields()[0].GetValue().GetInt().GetValue()
+				}
+			}
+			require.NoError(t, iter.Close())
+			assert.Equal(t, want, got)
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Empty(t, entries, "the spill files are removed")
+		})
+	}
+}
+
+func TestGroupByKey(t *testing.T) {
+	refs := [][]*logical.TagRef{{
+		{Tag: logical.NewTag("default", "a"), Spec: &logical.TagSpec{TagIdx: 0}},
+		{Tag: logical.NewTag("default", "b"), Spec: &logical.TagSpec{TagIdx: 1}},
+	}}
+	point := func(a, b *modelv1.TagValue) *measurev1.DataPoint {
+		return &measurev1.DataPoint{TagFamilies: []*modelv1.TagFamily{{Tags: []*modelv1.Tag{{Value: a}, {Value: b}}}}}
+	}
+	strArray := func(values ...string) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_StrArray{StrArray: &modelv1.StrArray{Value: values}}}
+	}
+	str := func(value string) *modelv1.TagValue {
+		return &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: value}}}
+	}
+	points := []*measurev1.DataPoint{
+		point(str("ab"), str("c")),
+		point(str("a"), str("bc")),
+		point(strArray("a", "b"), str("c")),
+		point(strArray("ab"), str("c")),
+		point(&modelv1.TagValue{Value: &modelv1.TagValue_BinaryData{BinaryData: []byte("ab")}}, str("c")),
+		point(&modelv1.TagValue{Value: &modelv1.TagValue_Null{}}, str("c")),
+	}
+	keys := make(map[string]struct{})
+	for _, p := range points {
+		key, err := appendGroupByKey(nil, p, refs)
+		require.NoError(t, err)
+		keys[string(key)] = struct{}{}
+	}
+	assert.Len(t, keys, len(points))
+	again, err := appendGroupByKey(nil, point(strArray("a", "b"), str("c")), refs)
+	require.NoError(t, err)
+	assert.Contains(t, keys, string(again))
+}
@@ -22,8 +22,10 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/pkg/errors"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/apache/skywalking-banyandb/api/common"
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
 	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
@@ -44,6 +46,7 @@ type unresolvedIndexScan struct {
 	criteria         *modelv1.Criteria
 	projectionTags   [][]*logical.Tag
 	projectionFields []*logical.Field
+	shardIDs         []common.ShardID
 	groupByEntity    bool
 }
 
@@ -101,6 +104,7 @@ func (uis *unresolvedIndexScan) Analyze(s logical.Schema) (logical.Plan, error)
 		filter:               filter,
 		entities:             entities,
 		groupByEntity:        uis.groupByEntity,
+		shardIDs:             uis.shardIDs,
 		l:                    logger.GetLogger("query", "measure", uis.metadata.Group, uis.metadata.Name, "local-index"),
 	}, nil
 }
@@ -109,12 +113,15 @@ var (
 	_ logical.Plan          = (*localIndexScan)(nil)
 	_ logical.Sorter        = (*localIndexScan)(nil)
 	_ logical.VolumeLimiter = (*localIndexScan)(nil)
+	_ logical.Paginator     = (*localIndexScan)(nil)
 )
 
 type localIndexScan struct {
 	schema               logical.Schema
 	filter               index.Filter
 	order                *logical.OrderBy
+	after                *pbv1.Cursor
+	series               map[*measurev1.DataPoint]common.SeriesID // the series of the data points ordered by timestamps
 	metadata             *commonv1.Metadata
 	l                    *logger.Logger
 	timeRange            timestamp.TimeRange
@@ -123,6 +130,7 @@ type localIndexScan struct {
 	projectionTagsRefs   [][]*logical.TagRef
 	projectionFieldsRefs []*logical.FieldRef
 	entities             [][]*modelv1.TagValue
+	shardIDs             []common.ShardID
 	groupByEntity        bool
 	maxDataPointsSize    int
 }
@@ -143,17 +151,31 @@ func (i *localIndexScan) Execute(ctx context.Context) (mit executor.MIterator, e
 			Sort:  i.order.Sort,
 		}
 	}
+	orderByTS := orderBy == nil || orderBy.Index == nil
+	timeRange := i.timeRange
+	if i.after != nil {
+		// the data points of the entities are concatenated rather than merged into the order of the position
+		if !orderByTS || len(i.entities) > 1 {
+			return nil, errors.New("a continuation token requires the data points of an entity ordered by timestamps")
+		}
+		timeRange = i.after.Narrow(timeRange, orderBy != nil && orderBy.Sort == modelv1.Sort_SORT_DESC)
+	}
+	if orderByTS && len(i.entities) == 1 {
+		i.series = make(map[*measurev1.DataPoint]common.SeriesID)
+	}
 	ec := executor.FromMeasureExecutionContext(ctx)
 	var results []pbv1.MeasureQueryResult
 	for _, e := range i.entities {
 		result, err := ec.Query(ctx, pbv1.MeasureQueryOptions{
 			Name:            i.metadata.GetName(),
-			TimeRange:       &i.timeRange,
+			TimeRange:       &timeRange,
 			Entity:          e,
 			Filter:          i.filter,
 			Order:           orderBy,
 			TagProjection:   i.projectionTags,
 			FieldProjection: i.projectionFields,
+			ShardIDs:        i.shardIDs,
+			After:           i.after,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to query measure: %w", err)
@@ -166,9 +188,30 @@ func (i *localIndexScan) Execute(ctx context.Context) (mit executor.MIterator, e
 	}
 	return &resultMIterator{
 		results: results,
+		series:  i.series,
 	}, nil
 }
 
+func (i *localIndexScan) After(cursor *pbv1.Cursor) {
+	i.after = cursor
+}
+
+// continuationToken returns the position of the last data point of the page,
+// or nil if it isn't one of the data points ordered by timestamps.
+func (i *localIndexScan) continuationToken(page []*measurev1.DataPoint) []byte {
+	if len(page) == 0 {
+		return nil
+	}
+	last := page[len(page)-1]
+	sid, ok := i.series[last]
+	if !ok {
+		return nil
+	}
+	// a series has a data point at most at a timestamp
+	c := pbv1.Cursor{Timestamp: last.GetTimestamp().AsTime().UnixNano(), SeriesID: sid, Offset: 1}
+	return c.MarshalToken()
+}
+
 func (i *localIndexScan) String() string {
 	return fmt.Sprintf("IndexScan: startTime=%d,endTime=%d,Metadata{group=%s,name=%s},conditions=%s; projection=%s; order=%s; limit=%d",
 		i.timeRange.Start.Unix(), i.timeRange.End.Unix(), i.metadata.GetGroup(), i.metadata.GetName(),
@@ -187,7 +230,7 @@ func (i *localIndexScan) Schema() logical.Schema {
 }
 
 func indexScan(startTime, endTime time.Time, metadata *commonv1.Metadata, projectionTags [][]*logical.Tag,
-	projectionFields []*logical.Field, groupByEntity bool, criteria *modelv1.Criteria,
+	projectionFields []*logical.Field, groupByEntity bool, criteria *modelv1.Criteria, shardIDs []common.ShardID,
 ) logical.UnresolvedPlan {
 	return &unresolvedIndexScan{
 		startTime:        startTime,
@@ -197,10 +240,13 @@ func indexScan(startTime, endTime time.Time, metadata *commonv1.Metadata, projec
 		projectionFields: projectionFields,
 		groupByEntity:    groupByEntity,
 		criteria:         criteria,
+		shardIDs:         shardIDs,
 	}
 }
 
 type resultMIterator struct {
+	// series records the series of the data points if it isn't nil.
+	series       map[*measurev1.DataPoint]common.SeriesID
 	results      []pbv1.MeasureQueryResult
 	current      []*measurev1.DataPoint
 	index        int
@@ -246,6 +292,9 @@ func (ei *resultMIterator) Next() bool {
 				Value: f.Values[i],
 			})
 		}
+		if ei.series != nil {
+			ei.series[dp] = r.SID
+		}
 		ei.current = append(ei.current, dp)
 	}
 
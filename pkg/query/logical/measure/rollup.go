@@ -0,0 +1,85 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+// RollupGroup returns the name of the derived group storing the data points of the group rolled up at the interval.
+func RollupGroup(group, interval string) string {
+	return group + "_rollup_" + interval
+}
+
+// RollupMetadata returns the metadata of the derived measure storing the data points of the measure rolled up at the interval.
+func RollupMetadata(md *commonv1.Metadata, interval string) *commonv1.Metadata {
+	return &commonv1.Metadata{
+		Group: RollupGroup(md.GetGroup(), interval),
+		Name:  md.GetName(),
+	}
+}
+
+// RollupRequest picks the coarsest rollup of the measure which serves the step of the request,
+// and returns a copy of the request reading it. The request itself is returned if none of the rollups serves it.
+// A rollup serves the step if its interval divides the step and it has all the fields projected.
+func RollupRequest(m *databasev1.Measure, req *measurev1.QueryRequest) (*measurev1.QueryRequest, error) {
+	if req.GetStep() == "" || len(m.GetRollups()) == 0 {
+		return req, nil
+	}
+	step, err := timestamp.ParseDuration(req.GetStep())
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid step %q", req.GetStep())
+	}
+	if step <= 0 {
+		return nil, errors.Errorf("invalid step %q", req.GetStep())
+	}
+	aggregated := make(map[string]bool, len(m.GetFields()))
+	for _, f := range m.GetFields() {
+		aggregated[f.GetName()] = f.GetAggregateFunction() != modelv1.AggregationFunction_AGGREGATION_FUNCTION_UNSPECIFIED
+	}
+	for _, name := range req.GetFieldProjection().GetNames() {
+		if !aggregated[name] {
+			return req, nil
+		}
+	}
+	var coarsest *databasev1.RollupSpec
+	var coarsestInterval int64
+	for _, r := range m.GetRollups() {
+		interval, errInterval := timestamp.ParseDuration(r.GetInterval())
+		if errInterval != nil || interval <= 0 || step%interval != 0 {
+			continue
+		}
+		if int64(interval) > coarsestInterval {
+			coarsest, coarsestInterval = r, int64(interval)
+		}
+	}
+	if coarsest == nil {
+		return req, nil
+	}
+	rollupReq := proto.Clone(req).(*measurev1.QueryRequest)
+	rollupReq.Metadata = RollupMetadata(m.GetMetadata(), coarsest.GetInterval())
+	rollupReq.Step = ""
+	return rollupReq, nil
+}
@@ -0,0 +1,71 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+func TestRollupRequest(t *testing.T) {
+	m := &databasev1.Measure{
+		Metadata: &commonv1.Metadata{Group: "sw_metric", Name: "service_cpm"},
+		Fields: []*databasev1.FieldSpec{
+			{Name: "total", AggregateFunction: modelv1.AggregationFunction_AGGREGATION_FUNCTION_SUM},
+			{Name: "value"},
+		},
+		Interval: "1m",
+		Rollups:  []*databasev1.RollupSpec{{Interval: "1h"}, {Interval: "1d"}},
+	}
+	tests := []struct {
+		name  string
+		step  string
+		group string
+		field string
+	}{
+		{name: "raw", step: "", group: "sw_metric", field: "total"},
+		{name: "finer than the rollups", step: "5m", group: "sw_metric", field: "total"},
+		{name: "hourly", step: "2h", group: "sw_metric_rollup_1h", field: "total"},
+		{name: "daily", step: "7d", group: "sw_metric_rollup_1d", field: "total"},
+		{name: "not divided by a day", step: "36h", group: "sw_metric_rollup_1h", field: "total"},
+		{name: "field not rolled up", step: "1d", group: "sw_metric", field: "value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &measurev1.QueryRequest{
+				Metadata:        m.GetMetadata(),
+				FieldProjection: &measurev1.QueryRequest_FieldProjection{Names: []string{tt.field}},
+				Step:            tt.step,
+			}
+			got, err := RollupRequest(m, req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.group, got.GetMetadata().GetGroup())
+			assert.Equal(t, "service_cpm", got.GetMetadata().GetName())
+			assert.Equal(t, "sw_metric", req.GetMetadata().GetGroup(), "the request isn't modified")
+		})
+	}
+	_, err := RollupRequest(m, &measurev1.QueryRequest{Metadata: m.GetMetadata(), Step: "an hour"})
+	assert.Error(t, err)
+}
@@ -67,11 +67,10 @@ func TopNAnalyze(_ context.Context, criteria *measurev1.TopNRequest, schema *dat
 	plan := parse(criteria, schema.GetMetadata(), projectionFields, groupByTags)
 
 	if criteria.GetAgg() != 0 {
-		plan = newUnresolvedGroupBy(plan, groupByTags, false)
-		plan = newUnresolvedAggregation(plan,
-			logical.NewField(topNAggregation.GetFieldName()),
-			criteria.GetAgg(),
-			true)
+		plan = newUnresolvedHashAggregation(plan, groupByTags, &measurev1.QueryRequest_Aggregation{
+			Function:  criteria.GetAgg(),
+			FieldName: topNAggregation.GetFieldName(),
+		})
 	}
 
 	plan = top(plan, &measurev1.QueryRequest_Top{
@@ -0,0 +1,58 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package measure
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+)
+
+// TopNDimensionMetadata returns the metadata of the derived measure storing the ranks of the TopN aggregation grouped by the tags.
+func TopNDimensionMetadata(md *commonv1.Metadata, groupByTagNames []string) *commonv1.Metadata {
+	return &commonv1.Metadata{
+		Group: md.GetGroup(),
+		Name:  md.GetName() + "_by_" + strings.Join(groupByTagNames, "_"),
+	}
+}
+
+// TopNDimension picks the dimension of the TopN aggregation grouped by the tags,
+// and returns the metadata of the measure storing its ranks and its sort direction.
+// The aggregation itself is picked if the tags are empty or identical to its group_by_tag_names.
+func TopNDimension(topN *databasev1.TopNAggregation, groupByTagNames []string) (*commonv1.Metadata, modelv1.Sort, error) {
+	if len(groupByTagNames) == 0 || slices.Equal(groupByTagNames, topN.GetGroupByTagNames()) {
+		return topN.GetMetadata(), topN.GetFieldValueSort(), nil
+	}
+	for _, d := range topN.GetDimensions() {
+		if !slices.Equal(groupByTagNames, d.GetGroupByTagNames()) {
+			continue
+		}
+		sort := d.GetFieldValueSort()
+		if sort == modelv1.Sort_SORT_UNSPECIFIED {
+			sort = topN.GetFieldValueSort()
+		}
+		return TopNDimensionMetadata(topN.GetMetadata(), groupByTagNames), sort, nil
+	}
+	return nil, modelv1.Sort_SORT_UNSPECIFIED, errors.Errorf("the TopN aggregation %s has no dimension grouped by %v",
+		topN.GetMetadata().GetName(), groupByTagNames)
+}
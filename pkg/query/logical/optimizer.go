@@ -19,6 +19,7 @@ package logical
 
 import (
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 )
 
 // OptimizeRule allows optimizing a Plan based on a rule.
@@ -59,6 +60,12 @@ type Sorter interface {
 	Sort(order *OrderBy)
 }
 
+// Paginator resumes a Plan's output from the position of a continuation token.
+type Paginator interface {
+	Plan
+	After(cursor *pbv1.Cursor)
+}
+
 var _ OptimizeRule = (*PushDownOrder)(nil)
 
 // PushDownOrder pushes down the order to a Plan.
@@ -100,3 +107,21 @@ func (pde PushDownMaxSize) Optimize(plan Plan) (Plan, error) {
 	}
 	return plan, nil
 }
+
+// PushDownCursor pushes down the position of a continuation token to a Plan.
+type PushDownCursor struct {
+	cursor *pbv1.Cursor
+}
+
+// NewPushDownCursor returns a new PushDownCursor. A nil cursor leaves the Plan as it is.
+func NewPushDownCursor(cursor *pbv1.Cursor) PushDownCursor {
+	return PushDownCursor{cursor: cursor}
+}
+
+// Optimize a Plan by pushing down the position to resume from.
+func (pdc PushDownCursor) Optimize(plan Plan) (Plan, error) {
+	if v, ok := plan.(Paginator); ok && pdc.cursor != nil {
+		v.After(pdc.cursor)
+	}
+	return plan, nil
+}
@@ -0,0 +1,155 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logical
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+// ErrUnknownProjection indicates the projection refers to the tag families or the tags absent in the schema.
+var ErrUnknownProjection = errors.New("the projection refers to unknown tags")
+
+// ResolveTagProjection resolves the projection against the tag families of the schema.
+// The duplicated families and tags are merged in the order of their first occurrences.
+//
+// The unknown families and tags fail the resolution unless allowUnknown is true.
+// Then normalized keeps them while known leaves them out, and the caller projects them as nulls.
+// known is normalized itself if there is no unknown tag.
+func ResolveTagProjection(tagFamilies []*databasev1.TagFamilySpec, projection *modelv1.TagProjection, allowUnknown bool,
+) (normalized, known *modelv1.TagProjection, err error) {
+	if projection == nil {
+		return nil, nil, nil
+	}
+	specs := make(map[string]map[string]struct{}, len(tagFamilies))
+	for _, tf := range tagFamilies {
+		tags := make(map[string]struct{}, len(tf.GetTags()))
+		for _, t := range tf.GetTags() {
+			tags[t.GetName()] = struct{}{}
+		}
+		specs[tf.GetName()] = tags
+	}
+
+	normalized = &modelv1.TagProjection{}
+	known = &modelv1.TagProjection{}
+	families := make(map[string]int)
+	var unknown []string
+	for _, tf := range projection.GetTagFamilies() {
+		idx, ok := families[tf.GetName()]
+		if !ok {
+			idx = len(normalized.TagFamilies)
+			families[tf.GetName()] = idx
+			normalized.TagFamilies = append(normalized.TagFamilies, &modelv1.TagProjection_TagFamily{Name: tf.GetName()})
+		}
+		nf := normalized.TagFamilies[idx]
+		for _, name := range tf.GetTags() {
+			if slices.Contains(nf.Tags, name) {
+				continue
+			}
+			nf.Tags = append(nf.Tags, name)
+			if _, ok := specs[tf.GetName()][name]; !ok {
+				unknown = append(unknown, tf.GetName()+"."+name)
+			}
+		}
+	}
+	if len(unknown) == 0 {
+		return normalized, normalized, nil
+	}
+	if !allowUnknown {
+		return nil, nil, errors.WithMessagef(ErrUnknownProjection, "%s are absent in the tag families %s",
+			strings.Join(unknown, ", "), formatTagFamilySpecs(tagFamilies))
+	}
+	for _, nf := range normalized.TagFamilies {
+		var kf *modelv1.TagProjection_TagFamily
+		for _, name := range nf.Tags {
+			if _, ok := specs[nf.Name][name]; !ok {
+				continue
+			}
+			if kf == nil {
+				kf = &modelv1.TagProjection_TagFamily{Name: nf.Name}
+				known.TagFamilies = append(known.TagFamilies, kf)
+			}
+			kf.Tags = append(kf.Tags, name)
+		}
+	}
+	return normalized, known, nil
+}
+
+// FillUnknownTags inserts the tags of normalized absent in known into the tag families as nulls.
+// The tags of known are kept as they are, even if some of them are left out of the tag families.
+func FillUnknownTags(tagFamilies []*modelv1.TagFamily, normalized, known *modelv1.TagProjection) []*modelv1.TagFamily {
+	if normalized == known {
+		return tagFamilies
+	}
+	result := make([]*modelv1.TagFamily, 0, len(normalized.GetTagFamilies()))
+	for _, nf := range normalized.GetTagFamilies() {
+		var existing *modelv1.TagFamily
+		for _, tf := range tagFamilies {
+			if tf.GetName() == nf.GetName() {
+				existing = tf
+				break
+			}
+		}
+		var kf *modelv1.TagProjection_TagFamily
+		for _, f := range known.GetTagFamilies() {
+			if f.GetName() == nf.GetName() {
+				kf = f
+				break
+			}
+		}
+		tf := &modelv1.TagFamily{Name: nf.GetName()}
+		for _, name := range nf.GetTags() {
+			if kf == nil || !slices.Contains(kf.GetTags(), name) {
+				tf.Tags = append(tf.Tags, &modelv1.Tag{Key: name, Value: pbv1.NullTagValue})
+				continue
+			}
+			for _, t := range existing.GetTags() {
+				if t.GetKey() == name {
+					tf.Tags = append(tf.Tags, t)
+					break
+				}
+			}
+		}
+		if len(tf.Tags) > 0 {
+			result = append(result, tf)
+		}
+	}
+	return result
+}
+
+func formatTagFamilySpecs(tagFamilies []*databasev1.TagFamilySpec) string {
+	var sb strings.Builder
+	for i, tf := range tagFamilies {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		names := make([]string, 0, len(tf.GetTags()))
+		for _, t := range tf.GetTags() {
+			names = append(names, t.GetName())
+		}
+		fmt.Fprintf(&sb, "%s[%s]", tf.GetName(), strings.Join(names, " "))
+	}
+	return sb.String()
+}
@@ -0,0 +1,128 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logical
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+)
+
+var projectionTagFamilies = []*databasev1.TagFamilySpec{
+	{
+		Name: "searchable",
+		Tags: []*databasev1.TagSpec{{Name: "trace_id"}, {Name: "state"}},
+	},
+	{
+		Name: "data",
+		Tags: []*databasev1.TagSpec{{Name: "data_binary"}},
+	},
+}
+
+func projection(families ...*modelv1.TagProjection_TagFamily) *modelv1.TagProjection {
+	return &modelv1.TagProjection{TagFamilies: families}
+}
+
+func family(name string, tags ...string) *modelv1.TagProjection_TagFamily {
+	return &modelv1.TagProjection_TagFamily{Name: name, Tags: tags}
+}
+
+func TestResolveTagProjection(t *testing.T) {
+	tests := []struct {
+		projection     *modelv1.TagProjection
+		wantNormalized *modelv1.TagProjection
+		wantKnown      *modelv1.TagProjection
+		name           string
+		wantErr        []string
+		allowUnknown   bool
+	}{
+		{
+			name:           "known tags",
+			projection:     projection(family("searchable", "state", "trace_id"), family("data", "data_binary")),
+			wantNormalized: projection(family("searchable", "state", "trace_id"), family("data", "data_binary")),
+		},
+		{
+			name:           "duplicated families and tags",
+			projection:     projection(family("searchable", "state", "state"), family("data", "data_binary"), family("searchable", "trace_id", "state")),
+			wantNormalized: projection(family("searchable", "state", "trace_id"), family("data", "data_binary")),
+		},
+		{
+			name:       "unknown tag",
+			projection: projection(family("searchable", "stat", "trace_id")),
+			wantErr:    []string{"searchable.stat", "searchable[trace_id state]", "data[data_binary]"},
+		},
+		{
+			name:       "unknown family",
+			projection: projection(family("searchable", "state"), family("extended", "a", "b")),
+			wantErr:    []string{"extended.a, extended.b"},
+		},
+		{
+			name:       "tag in another family",
+			projection: projection(family("data", "state")),
+			wantErr:    []string{"data.state"},
+		},
+		{
+			name:           "allow unknown tags",
+			projection:     projection(family("searchable", "stat", "trace_id"), family("extended", "a"), family("searchable", "stat")),
+			allowUnknown:   true,
+			wantNormalized: projection(family("searchable", "stat", "trace_id"), family("extended", "a")),
+			wantKnown:      projection(family("searchable", "trace_id")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized, known, err := ResolveTagProjection(projectionTagFamilies, tt.projection, tt.allowUnknown)
+			if len(tt.wantErr) > 0 {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, ErrUnknownProjection))
+				for _, s := range tt.wantErr {
+					assert.Contains(t, err.Error(), s)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Empty(t, cmp.Diff(tt.wantNormalized, normalized, protocmp.Transform()))
+			if tt.wantKnown == nil {
+				assert.Same(t, normalized, known)
+				return
+			}
+			assert.Empty(t, cmp.Diff(tt.wantKnown, known, protocmp.Transform()))
+		})
+	}
+}
+
+func TestFillUnknownTags(t *testing.T) {
+	normalized, known, err := ResolveTagProjection(projectionTagFamilies,
+		projection(family("searchable", "stat", "trace_id"), family("extended", "a")), true)
+	require.NoError(t, err)
+	traceID := &modelv1.Tag{Key: "trace_id", Value: &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: "1"}}}}
+	got := FillUnknownTags([]*modelv1.TagFamily{{Name: "searchable", Tags: []*modelv1.Tag{traceID}}}, normalized, known)
+	want := []*modelv1.TagFamily{
+		{Name: "searchable", Tags: []*modelv1.Tag{{Key: "stat", Value: pbv1.NullTagValue}, traceID}},
+		{Name: "extended", Tags: []*modelv1.Tag{{Key: "a", Value: pbv1.NullTagValue}}},
+	}
+	assert.Empty(t, cmp.Diff(want, got, protocmp.Transform()))
+}
@@ -21,9 +21,12 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/pkg/errors"
+
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
 	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
 	"github.com/apache/skywalking-banyandb/pkg/query/executor"
 	"github.com/apache/skywalking-banyandb/pkg/query/logical"
 )
@@ -48,6 +51,16 @@ func BuildSchema(sm *databasev1.Stream, indexRules []*databasev1.IndexRule) (log
 
 // Analyze converts logical expressions to executable operation tree represented by Plan.
 func Analyze(_ context.Context, criteria *streamv1.QueryRequest, metadata *commonv1.Metadata, s logical.Schema) (logical.Plan, error) {
+	var after *pbv1.Cursor
+	if token := criteria.GetContinuationToken(); len(token) > 0 {
+		if criteria.GetOrderBy().GetIndexRuleName() != "" || criteria.GetColumnar() {
+			return nil, errors.New("a continuation token requires the elements ordered by timestamps, which aren't columnar")
+		}
+		var err error
+		if after, err = pbv1.ParseCursor(token); err != nil {
+			return nil, err
+		}
+	}
 	// parse fields
 	plan := parseTags(criteria, metadata)
 
@@ -68,6 +81,7 @@ func Analyze(_ context.Context, criteria *streamv1.QueryRequest, metadata *commo
 	rules := []logical.OptimizeRule{
 		logical.NewPushDownOrder(criteria.OrderBy),
 		logical.NewPushDownMaxSize(int(limitParameter + criteria.GetOffset())),
+		logical.NewPushDownCursor(after),
 	}
 	if err := logical.ApplyRules(p, rules...); err != nil {
 		return nil, err
@@ -75,10 +89,39 @@ func Analyze(_ context.Context, criteria *streamv1.QueryRequest, metadata *commo
 	return p, nil
 }
 
+// ContinuationToken returns the token to query the page next to the elements, which are the output of the plan.
+// It's nil if the page isn't full, or the elements aren't ordered by timestamps.
+func ContinuationToken(plan logical.Plan, elements []*streamv1.Element) []byte {
+	l, ok := plan.(*limit)
+	if !ok || len(elements) < int(l.LimitNum) {
+		return nil
+	}
+	for p := plan; len(p.Children()) > 0; {
+		p = p.Children()[0]
+		switch v := p.(type) {
+		case *localIndexScan:
+			return v.continuationToken(elements)
+		case *distributedPlan:
+			return v.token
+		}
+	}
+	return nil
+}
+
 // DistributedAnalyze converts logical expressions to executable operation tree represented by Plan.
 func DistributedAnalyze(criteria *streamv1.QueryRequest, s logical.Schema) (logical.Plan, error) {
+	var after pbv1.NodeCursors
+	if token := criteria.GetContinuationToken(); len(token) > 0 {
+		if criteria.GetOrderBy().GetIndexRuleName() != "" || criteria.GetColumnar() {
+			return nil, errors.New("a continuation token requires the elements ordered by timestamps, which aren't columnar")
+		}
+		var err error
+		if after, err = pbv1.ParseNodeCursors(token); err != nil {
+			return nil, err
+		}
+	}
 	// parse fields
-	plan := newUnresolvedDistributed(criteria)
+	plan := newUnresolvedDistributed(criteria, after)
 	// parse offset
 	plan = newOffset(plan, criteria.GetOffset())
 
@@ -213,5 +256,5 @@ func newOffset(input logical.UnresolvedPlan, num uint32) logical.UnresolvedPlan
 func parseTags(criteria *streamv1.QueryRequest, metadata *commonv1.Metadata) logical.UnresolvedPlan {
 	timeRange := criteria.GetTimeRange()
 	return tagFilter(timeRange.GetBegin().AsTime(), timeRange.GetEnd().AsTime(), metadata,
-		criteria.Criteria, logical.ToTags(criteria.GetProjection()))
+		criteria.Criteria, logical.ToTags(criteria.GetProjection()), logical.ToShardIDs(criteria.GetShardIds()))
 }
@@ -20,7 +20,9 @@ package stream
 import (
 	"context"
 	"fmt"
+	"io"
 
+	"github.com/pkg/errors"
 	"go.uber.org/multierr"
 	"google.golang.org/protobuf/proto"
 
@@ -39,11 +41,13 @@ var _ logical.UnresolvedPlan = (*unresolvedDistributed)(nil)
 
 type unresolvedDistributed struct {
 	originalQuery *streamv1.QueryRequest
+	after         pbv1.NodeCursors
 }
 
-func newUnresolvedDistributed(query *streamv1.QueryRequest) logical.UnresolvedPlan {
+func newUnresolvedDistributed(query *streamv1.QueryRequest, after pbv1.NodeCursors) logical.UnresolvedPlan {
 	return &unresolvedDistributed{
 		originalQuery: query,
+		after:         after,
 	}
 }
 
@@ -70,12 +74,15 @@ func (ud *unresolvedDistributed) Analyze(s logical.Schema) (logical.Plan, error)
 		Criteria:   ud.originalQuery.Criteria,
 		Limit:      limit,
 		OrderBy:    ud.originalQuery.OrderBy,
+		// the token is replaced by the position of each node when the query is sent to it
+		ContinuationToken: ud.originalQuery.ContinuationToken,
 	}
 	if ud.originalQuery.OrderBy == nil {
 		return &distributedPlan{
 			queryTemplate: temp,
 			s:             s,
 			sortByTime:    true,
+			after:         ud.after,
 		}, nil
 	}
 	if ud.originalQuery.OrderBy.IndexRuleName == "" {
@@ -83,6 +90,7 @@ func (ud *unresolvedDistributed) Analyze(s logical.Schema) (logical.Plan, error)
 			queryTemplate: temp,
 			s:             s,
 			sortByTime:    true,
+			after:         ud.after,
 		}
 		if ud.originalQuery.OrderBy.Sort == modelv1.Sort_SORT_DESC {
 			result.desc = true
@@ -113,8 +121,12 @@ func (ud *unresolvedDistributed) Analyze(s logical.Schema) (logical.Plan, error)
 }
 
 type distributedPlan struct {
-	s              logical.Schema
-	queryTemplate  *streamv1.QueryRequest
+	s             logical.Schema
+	queryTemplate *streamv1.QueryRequest
+	// after are the positions of the nodes the page starts from
+	after pbv1.NodeCursors
+	// token is the continuation token of the page, which is set once the page is full
+	token          []byte
 	sortTagSpec    logical.TagSpec
 	sortByTime     bool
 	desc           bool
@@ -128,37 +140,89 @@ func (t *distributedPlan) Execute(ctx context.Context) ([]*streamv1.Element, err
 	if t.maxElementSize > 0 {
 		query.Limit = t.maxElementSize
 	}
-	ff, err := dctx.Broadcast(data.TopicStreamQuery, bus.NewMessage(bus.MessageID(dctx.TimeRange().Begin.Nanos), query))
+	streams, err := dctx.BroadcastChunked(data.TopicStreamQuery, bus.NewMessage(bus.MessageID(dctx.TimeRange().Begin.Nanos), query))
 	if err != nil {
 		return nil, err
 	}
-	var allErr error
-	var see []sort.Iterator[*comparableElement]
-	for _, f := range ff {
-		if m, getErr := f.Get(); getErr != nil {
-			allErr = multierr.Append(allErr, getErr)
-		} else {
-			d := m.Data()
-			if d == nil {
-				continue
-			}
-			resp := d.(*streamv1.QueryResponse)
-			if err != nil {
-				allErr = multierr.Append(allErr, err)
-				continue
-			}
-			see = append(see,
-				newSortableElements(resp.Elements, t.sortByTime, t.sortTagSpec))
-		}
+	nodes := make([]*chunkedElements, 0, len(streams))
+	see := make([]sort.Iterator[*comparableElement], 0, len(streams))
+	for _, s := range streams {
+		c := newChunkedElements(s, t.sortByTime, t.sortTagSpec)
+		nodes = append(nodes, c)
+		see = append(see, c)
 	}
+	// the merge pulls the next chunk of a node only when its current chunk is consumed
 	iter := sort.NewItemIter[*comparableElement](see, t.desc)
+	limit := int(query.Limit)
+	// the sink takes the merged elements away to keep only the merge window in memory
+	sink := executor.FromStreamElementSink(ctx)
 	var result []*streamv1.Element
-	for iter.Next() {
-		result = append(result, iter.Val().Element)
+	var count int
+	var allErr error
+	// replicas of a shard return the same elements
+	seen := make(map[elementKey]struct{})
+	// a columnar query returns the column batches of every node as they are, which the limit doesn't apply to
+	batches := executor.FromColumnBatches(ctx)
+	if batches != nil {
+		limit = 0
+		for _, n := range nodes {
+			n.drain()
+			batches.Add(n.batches...)
+		}
+	}
+	for count < limit && iter.Next() {
+		v := iter.Val()
+		// the elements dropped as duplicates are returned by their nodes still
+		v.from.returned++
+		e := v.Element
+		k := elementKey{id: e.ElementId, ts: e.Timestamp.AsTime().UnixNano()}
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		count++
+		if sink == nil {
+			result = append(result, e)
+			continue
+		}
+		if allErr = sink.Append(e); allErr != nil {
+			break
+		}
+	}
+	// closing the streams tells the nodes to stop sending the remaining chunks
+	_ = iter.Close()
+	warnings := executor.FromWarnings(ctx)
+	span := executor.FromSpan(ctx)
+	for _, n := range nodes {
+		warnings.Add(n.warnings...)
+		if n.trace != nil {
+			span.AddRemote(n.trace)
+		}
+		if n.err != nil && !dctx.ReportNodeFailure(n.stream.Node(), n.err) {
+			allErr = multierr.Append(allErr, errors.WithMessagef(n.err, "node %s", n.stream.Node()))
+		}
+	}
+	if allErr != nil {
+		return nil, allErr
+	}
+	if t.sortByTime && sink == nil && batches == nil && count == limit {
+		t.token = continuationToken(t.after, nodes)
 	}
 	return result, nil
 }
 
+// continuationToken returns the token resuming every node after its elements returned by the page.
+func continuationToken(after pbv1.NodeCursors, nodes []*chunkedElements) []byte {
+	cursors := make(pbv1.NodeCursors, 0, len(nodes))
+	for _, n := range nodes {
+		node, shardIDs := n.stream.Node(), n.stream.ShardIDs()
+		// the streams to a node for the same shards are opened in the same order every page
+		c, _ := after.Find(node, shardIDs, cursors.Count(node, shardIDs))
+		cursors = append(cursors, c.Advance(n.returned, n.done, n.token))
+	}
+	return cursors.MarshalToken()
+}
+
 func (t *distributedPlan) String() string {
 	return fmt.Sprintf("distributed:%s", t.queryTemplate.String())
 }
@@ -175,17 +239,24 @@ func (t *distributedPlan) Limit(max int) {
 	t.maxElementSize = uint32(max)
 }
 
+type elementKey struct {
+	id string
+	ts int64
+}
+
 var _ sort.Comparable = (*comparableElement)(nil)
 
 type comparableElement struct {
 	*streamv1.Element
+	from      *chunkedElements
 	sortField []byte
 }
 
 func newComparableElement(e *streamv1.Element, sortByTime bool, sortTagSpec logical.TagSpec) (*comparableElement, error) {
 	var sortField []byte
 	if sortByTime {
-		sortField = convert.Uint64ToBytes(uint64(e.Timestamp.AsTime().UnixNano()))
+		// the element id breaks ties to keep the merged order stable across nodes
+		sortField = append(convert.Uint64ToBytes(uint64(e.Timestamp.AsTime().UnixNano())), e.ElementId...)
 	} else {
 		var err error
 		sortField, err = pbv1.MarshalTagValue(e.TagFamilies[sortTagSpec.TagFamilyIdx].Tags[sortTagSpec.TagIdx].Value)
@@ -248,3 +319,86 @@ func (s *sortableElements) iter(fn func(*streamv1.Element) (*comparableElement,
 	s.cur = cur
 	return s.index <= len(s.elements)
 }
+
+var _ sort.Iterator[*comparableElement] = (*chunkedElements)(nil)
+
+// chunkedElements iterates the elements of a node's response chunk by chunk.
+type chunkedElements struct {
+	stream   executor.NodeStream
+	err      error
+	chunk    *sortableElements
+	warnings []*modelv1.BlockWarning
+	batches  []*streamv1.ColumnBatch
+	trace    *modelv1.Span
+	// token is the continuation token of the node, which comes with the last chunk
+	token       []byte
+	sortTagSpec logical.TagSpec
+	// returned is the number of the elements the merge takes
+	returned     uint32
+	isSortByTime bool
+	// done is set once all the chunks are received
+	done bool
+}
+
+func newChunkedElements(stream executor.NodeStream, isSortByTime bool, sortTagSpec logical.TagSpec) *chunkedElements {
+	return &chunkedElements{
+		stream:       stream,
+		isSortByTime: isSortByTime,
+		sortTagSpec:  sortTagSpec,
+	}
+}
+
+func (c *chunkedElements) Next() bool {
+	for {
+		if c.chunk != nil && c.chunk.Next() {
+			c.chunk.Val().from = c
+			return true
+		}
+		if c.err != nil || c.done {
+			return false
+		}
+		m, err := c.stream.Recv()
+		if errors.Is(err, io.EOF) {
+			c.done = true
+			return false
+		}
+		if err != nil {
+			c.err = err
+			return false
+		}
+		if m.Data() == nil {
+			continue
+		}
+		resp, ok := m.Data().(*streamv1.QueryResponse)
+		if !ok {
+			c.err = errors.Errorf("invalid response type %T", m.Data())
+			return false
+		}
+		for _, w := range resp.Warnings {
+			w.Node = c.stream.Node()
+			c.warnings = append(c.warnings, w)
+		}
+		if resp.Trace != nil {
+			resp.Trace.Node = c.stream.Node()
+			c.trace = resp.Trace
+		}
+		c.batches = append(c.batches, resp.Batches...)
+		c.token = resp.ContinuationToken
+		c.chunk = newSortableElements(resp.Elements, c.isSortByTime, c.sortTagSpec)
+	}
+}
+
+// drain receives the chunks left, and drops their elements.
+func (c *chunkedElements) drain() {
+	for c.Next() {
+		c.chunk = nil
+	}
+}
+
+func (c *chunkedElements) Val() *comparableElement {
+	return c.chunk.Val()
+}
+
+func (c *chunkedElements) Close() error {
+	return c.stream.Close()
+}
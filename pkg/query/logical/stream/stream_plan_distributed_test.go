@@ -0,0 +1,286 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/pkg/bus"
+	pbv1 "github.com/apache/skywalking-banyandb/pkg/pb/v1"
+	"github.com/apache/skywalking-banyandb/pkg/query/executor"
+)
+
+var baseTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func elements(seconds ...int) []*streamv1.Element {
+	ee := make([]*streamv1.Element, 0, len(seconds))
+	for _, s := range seconds {
+		ee = append(ee, &streamv1.Element{
+			ElementId: strconv.Itoa(s),
+			Timestamp: timestamppb.New(baseTime.Add(time.Duration(s) * time.Second)),
+		})
+	}
+	return ee
+}
+
+type fakeStream struct {
+	err      error
+	closed   chan struct{}
+	node     string
+	chunks   [][]*streamv1.Element
+	token    []byte
+	shardIDs []uint32
+	received int
+	slow     bool
+	waited   bool
+}
+
+func newFakeStream(node string, chunks ...[]*streamv1.Element) *fakeStream {
+	return &fakeStream{node: node, chunks: chunks, closed: make(chan struct{})}
+}
+
+func (f *fakeStream) Node() string {
+	return f.node
+}
+
+func (f *fakeStream) ShardIDs() []uint32 {
+	return f.shardIDs
+}
+
+func (f *fakeStream) Recv() (bus.Message, error) {
+	if f.received >= len(f.chunks) {
+		if f.err != nil {
+			return bus.Message{}, f.err
+		}
+		if f.slow {
+			// a slow node sends the next chunk a long time later
+			select {
+			case <-f.closed:
+				return bus.Message{}, io.EOF
+			case <-time.After(10 * time.Second):
+				f.waited = true
+				return bus.Message{}, io.EOF
+			}
+		}
+		return bus.Message{}, io.EOF
+	}
+	resp := &streamv1.QueryResponse{Elements: f.chunks[f.received]}
+	f.received++
+	if f.received == len(f.chunks) {
+		resp.ContinuationToken = f.token
+	}
+	return bus.NewMessage(bus.MessageID(f.received), resp), nil
+}
+
+func (f *fakeStream) Close() error {
+	select {
+	case <-f.closed:
+	default:
+		close(f.closed)
+	}
+	return nil
+}
+
+func (f *fakeStream) isClosed() bool {
+	select {
+	case <-f.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+type fakeDistributedContext struct {
+	bus.Broadcaster
+	failures     map[string]error
+	query        *streamv1.QueryRequest
+	streams      []*fakeStream
+	allowPartial bool
+}
+
+func (f *fakeDistributedContext) TimeRange() *modelv1.TimeRange {
+	return &modelv1.TimeRange{Begin: timestamppb.New(baseTime), End: timestamppb.New(baseTime.Add(time.Hour))}
+}
+
+func (f *fakeDistributedContext) BroadcastChunked(_ bus.Topic, m bus.Message) ([]executor.NodeStream, error) {
+	f.query = m.Data().(*streamv1.QueryRequest)
+	streams := make([]executor.NodeStream, 0, len(f.streams))
+	for _, s := range f.streams {
+		streams = append(streams, s)
+	}
+	return streams, nil
+}
+
+func (f *fakeDistributedContext) ReportNodeFailure(node string, err error) bool {
+	if !f.allowPartial {
+		return false
+	}
+	if f.failures == nil {
+		f.failures = make(map[string]error)
+	}
+	f.failures[node] = err
+	return true
+}
+
+func ids(ee []*streamv1.Element) []string {
+	result := make([]string, 0, len(ee))
+	for _, e := range ee {
+		result = append(result, e.ElementId)
+	}
+	return result
+}
+
+func newTestPlan(limit uint32) *distributedPlan {
+	return &distributedPlan{
+		queryTemplate: &streamv1.QueryRequest{Limit: limit},
+		sortByTime:    true,
+		desc:          true,
+	}
+}
+
+func TestDistributedPlan_SlowNode(t *testing.T) {
+	fast := newFakeStream("fast", elements(9, 8), elements(7, 6))
+	// the replica of the fast node's shards returns the same elements
+	replica := newFakeStream("replica", elements(9, 8), elements(7, 6))
+	slow := newFakeStream("slow", elements(5, 1))
+	slow.slow = true
+	dctx := &fakeDistributedContext{streams: []*fakeStream{fast, replica, slow}}
+
+	start := time.Now()
+	result, err := newTestPlan(3).Execute(executor.WithDistributedExecutionContext(context.Background(), dctx))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9", "8", "7"}, ids(result))
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.False(t, slow.waited, "the merge shouldn't wait for the slow node's remaining chunks")
+	for _, s := range dctx.streams {
+		assert.True(t, s.isClosed(), "the stream of %s should be closed once the limit is reached", s.node)
+	}
+}
+
+func TestDistributedPlan_MergeInOrder(t *testing.T) {
+	n1 := newFakeStream("n1", elements(10, 7), elements(4, 1))
+	n2 := newFakeStream("n2", elements(9), elements(8, 3), nil, elements(2))
+	n1Replica := newFakeStream("n1-replica", elements(10, 7), elements(4, 1))
+	dctx := &fakeDistributedContext{streams: []*fakeStream{n1, n2, n1Replica}}
+
+	result, err := newTestPlan(100).Execute(executor.WithDistributedExecutionContext(context.Background(), dctx))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10", "9", "8", "7", "4", "3", "2", "1"}, ids(result))
+}
+
+func TestDistributedPlan_PartialFailure(t *testing.T) {
+	errNode := errors.New("node is unavailable")
+	newContext := func(allowPartial bool) *fakeDistributedContext {
+		healthy := newFakeStream("healthy", elements(3, 2))
+		failed := newFakeStream("failed", elements(4))
+		failed.err = errNode
+		return &fakeDistributedContext{streams: []*fakeStream{healthy, failed}, allowPartial: allowPartial}
+	}
+
+	t.Run("fail the query", func(t *testing.T) {
+		dctx := newContext(false)
+		_, err := newTestPlan(10).Execute(executor.WithDistributedExecutionContext(context.Background(), dctx))
+		require.ErrorIs(t, err, errNode)
+	})
+
+	t.Run("return partial results", func(t *testing.T) {
+		dctx := newContext(true)
+		result, err := newTestPlan(10).Execute(executor.WithDistributedExecutionContext(context.Background(), dctx))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"4", "3", "2"}, ids(result))
+		assert.Equal(t, map[string]error{"failed": errNode}, dctx.failures)
+	})
+}
+
+func TestDistributedPlan_ContinuationToken(t *testing.T) {
+	n1 := newFakeStream("n1", elements(10, 8), elements(6))
+	n1.token = []byte("n1")
+	n2 := newFakeStream("n2", elements(9))
+	n2.token = []byte("n2")
+	n3 := newFakeStream("n3", elements(7))
+	n1.shardIDs, n3.shardIDs = []uint32{1}, []uint32{3}
+	after := pbv1.NodeCursors{
+		{Node: "n1", ShardIDs: []uint32{1}, Skip: 4},
+		{Node: "n2", Token: []byte("n2-previous")},
+		// the node is queried for other shards than those of its position
+		{Node: "n3", ShardIDs: []uint32{2}, Token: []byte("n3-previous")},
+	}
+	dctx := &fakeDistributedContext{streams: []*fakeStream{n1, n2, n3}}
+	plan := newTestPlan(3)
+	plan.queryTemplate.ContinuationToken = after.MarshalToken()
+	plan.after = after
+
+	result, err := plan.Execute(executor.WithDistributedExecutionContext(context.Background(), dctx))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10", "9", "8"}, ids(result))
+	// the nodes pick their own positions from the token
+	assert.Equal(t, after.MarshalToken(), dctx.query.GetContinuationToken())
+	got, err := pbv1.ParseNodeCursors(plan.token)
+	require.NoError(t, err)
+	assert.Equal(t, pbv1.NodeCursors{
+		// the elements of n1 returned are skipped after its position, as its last chunk isn't received
+		{Node: "n1", ShardIDs: []uint32{1}, Skip: 6},
+		// all the elements of n2 are returned, so its token resumes it
+		{Node: "n2", Token: []byte("n2")},
+		// n3 starts over, and none of its elements is returned
+		{Node: "n3", ShardIDs: []uint32{3}},
+	}, got)
+
+	// the page which isn't full has no token
+	n1 = newFakeStream("n1", elements(2))
+	dctx = &fakeDistributedContext{streams: []*fakeStream{n1}}
+	plan = newTestPlan(3)
+	_, err = plan.Execute(executor.WithDistributedExecutionContext(context.Background(), dctx))
+	require.NoError(t, err)
+	assert.Nil(t, plan.token)
+}
+
+func TestDistributedPlan_ContinuationTokenOfNodeStreams(t *testing.T) {
+	// a cold node is queried for every time range of the cold tiers, whose streams are told apart by their order
+	first := newFakeStream("n1", elements(10, 8))
+	second := newFakeStream("n1", elements(9, 7))
+	second.token = []byte("second")
+	n2 := newFakeStream("n2", elements(6))
+	after := pbv1.NodeCursors{{Node: "n1", Skip: 1}, {Node: "n1", Index: 1, Skip: 2}}
+	dctx := &fakeDistributedContext{streams: []*fakeStream{first, second, n2}}
+	plan := newTestPlan(3)
+	plan.queryTemplate.ContinuationToken = after.MarshalToken()
+	plan.after = after
+
+	result, err := plan.Execute(executor.WithDistributedExecutionContext(context.Background(), dctx))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10", "9", "8"}, ids(result))
+	got, err := pbv1.ParseNodeCursors(plan.token)
+	require.NoError(t, err)
+	assert.Equal(t, pbv1.NodeCursors{
+		{Node: "n1", Skip: 3},
+		{Node: "n1", Index: 1, Skip: 3},
+		{Node: "n2"},
+	}, got)
+}
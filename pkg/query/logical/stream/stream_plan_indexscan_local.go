@@ -18,13 +18,21 @@
 package stream
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/apache/skywalking-banyandb/api/common"
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
 	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
 	"github.com/apache/skywalking-banyandb/pkg/index"
@@ -39,19 +47,36 @@ var (
 	_ logical.Plan          = (*localIndexScan)(nil)
 	_ logical.Sorter        = (*localIndexScan)(nil)
 	_ logical.VolumeLimiter = (*localIndexScan)(nil)
+	_ logical.Paginator     = (*localIndexScan)(nil)
+	_ logical.Explainer     = (*localIndexScan)(nil)
 )
 
+// blockScan is the alternative to the index scan, which evaluates the criteria on the indexed tags on the blocks instead.
+type blockScan struct {
+	tagFilter  logical.TagFilter // the conditions on the indexed tags, which the predicates don't fully evaluate
+	filter     index.Filter      // the filter of the index scan replaced
+	predicates []pbv1.TagPredicate
+}
+
 type localIndexScan struct {
 	schema            logical.Schema
 	filter            index.Filter
 	order             *logical.OrderBy
+	after             *pbv1.Cursor
+	blockScan         *blockScan
+	cost              *logical.ScanCost
+	series            map[*streamv1.Element]common.SeriesID // the series of the elements ordered by timestamps
 	metadata          *commonv1.Metadata
 	l                 *logger.Logger
 	timeRange         timestamp.TimeRange
 	projectionTagRefs [][]*logical.TagRef
 	projectionTags    []pbv1.TagProjection
 	entities          [][]*modelv1.TagValue
+	tagPredicates     []pbv1.TagPredicate
+	shardIDs          []common.ShardID
 	maxElementSize    int
+	path              logical.AccessPath
+	explained         bool
 }
 
 func (i *localIndexScan) Limit(max int) {
@@ -62,7 +87,63 @@ func (i *localIndexScan) Sort(order *logical.OrderBy) {
 	i.order = order
 }
 
+func (i *localIndexScan) After(cursor *pbv1.Cursor) {
+	i.after = cursor
+}
+
+// Explain orders the predicates by the statistics of the blocks, and chooses the access path by them if the criteria on the indexed
+// tags can be evaluated on the blocks. The block scan is chosen if it costs less, or a continuation token resumes the elements,
+// which only the block scan supports.
+func (i *localIndexScan) Explain(ctx context.Context) error {
+	if i.explained {
+		return nil
+	}
+	i.explained = true
+	alternative := i.blockScan != nil && (i.order == nil || i.order.Index == nil) && executor.FromColumnBatches(ctx) == nil
+	predicates := i.tagPredicates
+	if alternative {
+		predicates = i.blockScan.predicates
+	} else if len(predicates) < 2 {
+		return nil
+	}
+	span, ctx := executor.StartSpan(ctx, "statistics")
+	defer span.End()
+	ec := executor.FromStreamExecutionContext(ctx)
+	var stats pbv1.StreamScanStats
+	for _, e := range i.entities {
+		s, err := ec.Stats(ctx, pbv1.StreamQueryOptions{
+			Name:          i.metadata.GetName(),
+			TimeRange:     &i.timeRange,
+			Entity:        e,
+			TagProjection: i.projectionTags,
+			ShardIDs:      i.shardIDs,
+			TagPredicates: predicates,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get the statistics of stream: %w", err)
+		}
+		stats.Add(s)
+	}
+	logical.OrderPredicates(predicates, stats.Predicates)
+	if !alternative {
+		return nil
+	}
+	cost := logical.EstimateScanCost(stats.Matched)
+	i.cost = &cost
+	if i.after != nil || cost.Cheaper() == logical.BlockScan {
+		i.path = logical.BlockScan
+		i.blockScan.filter, i.filter = i.filter, nil
+		i.tagPredicates = predicates
+	}
+	return nil
+}
+
 func (i *localIndexScan) Execute(ctx context.Context) (elements []*streamv1.Element, err error) {
+	if err = i.Explain(ctx); err != nil {
+		return nil, err
+	}
+	span, ctx := executor.StartSpan(ctx, i.path.String())
+	defer span.End()
 	var orderBy *pbv1.OrderBy
 	if i.order != nil {
 		orderBy = &pbv1.OrderBy{
@@ -71,8 +152,18 @@ func (i *localIndexScan) Execute(ctx context.Context) (elements []*streamv1.Elem
 		}
 	}
 	ec := executor.FromStreamExecutionContext(ctx)
+	batches := executor.FromColumnBatches(ctx)
+	if batches != nil && (i.order != nil && i.order.Index != nil || i.filter != nil && i.filter != logical.Enode) {
+		return nil, errors.New("a columnar query supports neither the order by an index nor the criteria on the indexed tags")
+	}
+
+	if sortTag := i.sortTagInBlocks(); sortTag != nil {
+		return i.queryTopK(ctx, ec, orderBy, sortTag)
+	}
 
 	if i.order != nil && i.order.Index != nil {
+		lookup := span.Child("index lookup")
+		defer lookup.End()
 		ssr, err := ec.Sort(ctx, pbv1.StreamSortOptions{
 			Name:           i.metadata.GetName(),
 			TimeRange:      &i.timeRange,
@@ -80,6 +171,7 @@ func (i *localIndexScan) Execute(ctx context.Context) (elements []*streamv1.Elem
 			Filter:         i.filter,
 			Order:          orderBy,
 			TagProjection:  i.projectionTags,
+			ShardIDs:       i.shardIDs,
 			MaxElementSize: i.maxElementSize,
 		})
 		if err != nil {
@@ -89,10 +181,17 @@ func (i *localIndexScan) Execute(ctx context.Context) (elements []*streamv1.Elem
 			return elements, nil
 		}
 		r := ssr.Pull()
-		return buildElementsFromColumnResult(r), nil
+		lookup.Add(executor.CounterRows, int64(len(r.Timestamps)))
+		elements = buildElementsFromColumnResult(r)
+		return elements, accountElements(ctx, elements)
 	}
 
 	if i.filter != nil && i.filter != logical.Enode {
+		if i.after != nil {
+			return nil, errors.New("a continuation token doesn't support the criteria on the indexed tags")
+		}
+		lookup := span.Child("index lookup")
+		defer lookup.End()
 		sfr, err := ec.Filter(ctx, pbv1.StreamFilterOptions{
 			Name:           i.metadata.GetName(),
 			TimeRange:      &i.timeRange,
@@ -100,6 +199,7 @@ func (i *localIndexScan) Execute(ctx context.Context) (elements []*streamv1.Elem
 			Filter:         i.filter,
 			Order:          orderBy,
 			TagProjection:  i.projectionTags,
+			ShardIDs:       i.shardIDs,
 			MaxElementSize: i.maxElementSize,
 		})
 		if err != nil {
@@ -109,31 +209,253 @@ func (i *localIndexScan) Execute(ctx context.Context) (elements []*streamv1.Elem
 			return elements, nil
 		}
 		r := sfr.Pull()
-		return buildElementsFromColumnResult(r), nil
+		lookup.Add(executor.CounterRows, int64(len(r.Timestamps)))
+		elements = buildElementsFromColumnResult(r)
+		return elements, accountElements(ctx, elements)
 	}
 
+	desc := orderBy != nil && orderBy.Sort == modelv1.Sort_SORT_DESC
+	timeRange := i.timeRange
+	if i.after != nil {
+		timeRange = i.after.Narrow(timeRange, desc)
+	}
 	var results []pbv1.StreamQueryResult
 	for _, e := range i.entities {
 		result, err := ec.Query(ctx, pbv1.StreamQueryOptions{
 			Name:          i.metadata.GetName(),
-			TimeRange:     &i.timeRange,
+			TimeRange:     &timeRange,
 			Entity:        e,
 			Filter:        i.filter,
 			Order:         orderBy,
 			TagProjection: i.projectionTags,
+			ShardIDs:      i.shardIDs,
+			TagPredicates: i.tagPredicates,
+			After:         i.after,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to query stream: %w", err)
 		}
 		results = append(results, result)
 	}
-	return buildElementsFromQueryResults(results), nil
+	if batches != nil {
+		return nil, pullBatches(batches, results)
+	}
+	i.series = make(map[*streamv1.Element]common.SeriesID)
+	// the blocks are loaded as the merge pulls them
+	merge := span.Child("merge")
+	elements = buildElementsFromQueryResults(results, i.series)
+	merge.Add(executor.CounterRows, int64(len(elements)))
+	merge.End()
+	if err = accountElements(ctx, elements); err != nil {
+		return nil, err
+	}
+	if i.path == logical.BlockScan {
+		filter := span.Child("filter")
+		if elements, err = i.blockScan.match(elements, i.Schema()); err != nil {
+			return nil, err
+		}
+		filter.Add(executor.CounterRows, int64(len(elements)))
+		filter.End()
+	}
+	if len(results) > 1 {
+		sorting := span.Child("sort")
+		defer sorting.End()
+		// the elements of the entities are merged into the order of the position of the continuation tokens
+		slices.SortStableFunc(elements, func(a, b *streamv1.Element) int {
+			if c := a.Timestamp.AsTime().Compare(b.Timestamp.AsTime()); c != 0 {
+				if desc {
+					return -c
+				}
+				return c
+			}
+			return cmp.Compare(i.series[a], i.series[b])
+		})
+	}
+	return elements, nil
+}
+
+// continuationToken returns the position of the last element of the page, or nil if the elements aren't ordered by timestamps.
+func (i *localIndexScan) continuationToken(page []*streamv1.Element) []byte {
+	if i.series == nil || len(page) == 0 {
+		return nil
+	}
+	last := page[len(page)-1]
+	c := pbv1.Cursor{Timestamp: last.Timestamp.AsTime().UnixNano(), SeriesID: i.series[last]}
+	for j := len(page) - 1; j >= 0; j-- {
+		if page[j].Timestamp.AsTime().UnixNano() != c.Timestamp || i.series[page[j]] != c.SeriesID {
+			return c.MarshalToken()
+		}
+		c.Offset++
+	}
+	// the page is at the position of the previous one, whose elements are counted as well
+	if i.after != nil && i.after.Timestamp == c.Timestamp && i.after.SeriesID == c.SeriesID {
+		c.Offset += i.after.Offset
+	}
+	return c.MarshalToken()
+}
+
+// sortTagInBlocks returns the spec of the tag the elements are ordered by if its values are projected from the blocks,
+// whose top ones are taken from the blocks of each series rather than by scanning the index of the whole time range.
+func (i *localIndexScan) sortTagInBlocks() *logical.TagSpec {
+	if i.order == nil || len(i.order.Index.GetTags()) != 1 || i.maxElementSize <= 0 ||
+		i.filter != nil && i.filter != logical.Enode {
+		return nil
+	}
+	name := i.order.Index.GetTags()[0]
+	if !slices.ContainsFunc(i.projectionTags, func(tp pbv1.TagProjection) bool {
+		return slices.Contains(tp.Names, name)
+	}) {
+		return nil
+	}
+	tagSpec := i.Schema().FindTagSpecByName(name)
+	if tagSpec == nil || tagSpec.Spec.GetIndexedOnly() {
+		return nil
+	}
+	switch tagSpec.Spec.GetType() {
+	case databasev1.TagType_TAG_TYPE_INT, databasev1.TagType_TAG_TYPE_STRING:
+		return tagSpec
+	default:
+		return nil
+	}
+}
+
+// queryTopK takes the top elements of each series by the tag, then merges them into the top ones of all.
+// The nulls come last, and the ties are ordered by their timestamps in the same direction as the storage does.
+func (i *localIndexScan) queryTopK(ctx context.Context, ec executor.StreamExecutionContext, orderBy *pbv1.OrderBy,
+	sortTag *logical.TagSpec,
+) ([]*streamv1.Element, error) {
+	var results []pbv1.StreamQueryResult
+	defer func() {
+		for _, result := range results {
+			result.Release()
+		}
+	}()
+	for _, e := range i.entities {
+		result, err := ec.Query(ctx, pbv1.StreamQueryOptions{
+			Name:           i.metadata.GetName(),
+			TimeRange:      &i.timeRange,
+			Entity:         e,
+			Order:          orderBy,
+			TagProjection:  i.projectionTags,
+			ShardIDs:       i.shardIDs,
+			TagPredicates:  i.tagPredicates,
+			MaxElementSize: i.maxElementSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query stream: %w", err)
+		}
+		results = append(results, result)
+	}
+	merge := executor.FromSpan(ctx).Child("merge")
+	elements := buildElementsFromQueryResults(results, nil)
+	merge.Add(executor.CounterRows, int64(len(elements)))
+	merge.End()
+	if err := accountElements(ctx, elements); err != nil {
+		return nil, err
+	}
+	sorting := executor.FromSpan(ctx).Child("sort")
+	defer sorting.End()
+	ces := make([]*comparableElement, 0, len(elements))
+	for _, e := range elements {
+		ce, err := newComparableElement(e, false, *sortTag)
+		if err != nil {
+			return nil, err
+		}
+		ces = append(ces, ce)
+	}
+	desc := orderBy.Sort == modelv1.Sort_SORT_DESC
+	slices.SortStableFunc(ces, func(a, b *comparableElement) int {
+		if len(a.sortField) == 0 || len(b.sortField) == 0 {
+			return cmp.Compare(len(b.sortField), len(a.sortField))
+		}
+		c := bytes.Compare(a.sortField, b.sortField)
+		if c == 0 {
+			c = a.Timestamp.AsTime().Compare(b.Timestamp.AsTime())
+		}
+		if desc {
+			return -c
+		}
+		return c
+	})
+	if len(ces) > i.maxElementSize {
+		ces = ces[:i.maxElementSize]
+	}
+	elements = elements[:0]
+	for _, ce := range ces {
+		elements = append(elements, ce.Element)
+	}
+	return elements, nil
+}
+
+// accountElements accounts the elements held in the result buffer to the memory budget of the query.
+func accountElements(ctx context.Context, elements []*streamv1.Element) error {
+	account := executor.FromMemoryAccount(ctx)
+	if account == nil {
+		return nil
+	}
+	var size int
+	for _, e := range elements {
+		size += proto.Size(e)
+	}
+	return account.Grow(int64(size))
+}
+
+// pullBatches pulls the results in column batches into batches, and releases them.
+func pullBatches(batches *executor.ColumnBatches, results []pbv1.StreamQueryResult) error {
+	defer func() {
+		for _, result := range results {
+			result.Release()
+		}
+	}()
+	for _, result := range results {
+		br, ok := result.(pbv1.StreamBatchResult)
+		if !ok {
+			return fmt.Errorf("the result %T can't be pulled in column batches", result)
+		}
+		for cb := br.PullBatch(); cb != nil; cb = br.PullBatch() {
+			batches.Add(cb)
+		}
+	}
+	return nil
+}
+
+// match returns the elements matching the conditions on the indexed tags.
+func (bs *blockScan) match(elements []*streamv1.Element, s logical.Schema) ([]*streamv1.Element, error) {
+	if bs.tagFilter == logical.DummyFilter {
+		return elements, nil
+	}
+	matched := elements[:0]
+	for _, e := range elements {
+		ok, err := bs.tagFilter.Match(logical.TagFamilies(e.TagFamilies), s)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, e)
+		}
+	}
+	return matched, nil
 }
 
 func (i *localIndexScan) String() string {
-	return fmt.Sprintf("IndexScan: startTime=%d,endTime=%d,Metadata{group=%s,name=%s},conditions=%s; projection=%s; orderBy=%s; limit=%d",
-		i.timeRange.Start.Unix(), i.timeRange.End.Unix(), i.metadata.GetGroup(), i.metadata.GetName(),
-		i.filter, logical.FormatTagRefs(", ", i.projectionTagRefs...), i.order, i.maxElementSize)
+	var conditions fmt.Stringer = i.filter
+	if i.path == logical.BlockScan {
+		conditions = i.blockScan.tagFilter
+	}
+	str := fmt.Sprintf("%s: startTime=%d,endTime=%d,Metadata{group=%s,name=%s},conditions=%s; projection=%s; orderBy=%s; limit=%d",
+		i.path, i.timeRange.Start.Unix(), i.timeRange.End.Unix(), i.metadata.GetGroup(), i.metadata.GetName(),
+		conditions, logical.FormatTagRefs(", ", i.projectionTagRefs...), i.order, i.maxElementSize)
+	if len(i.tagPredicates) > 0 {
+		names := make([]string, 0, len(i.tagPredicates))
+		for _, tp := range i.tagPredicates {
+			names = append(names, tp.Name)
+		}
+		str += fmt.Sprintf("; predicates=[%s]", strings.Join(names, ","))
+	}
+	if i.cost != nil {
+		str += fmt.Sprintf("; cost={%s}", i.cost)
+	}
+	return str
 }
 
 func (i *localIndexScan) Children() []logical.Plan {
@@ -171,8 +493,20 @@ func buildElementsFromColumnResult(r *pbv1.StreamColumnResult) (elements []*stre
 	return
 }
 
-func buildElementsFromQueryResults(results []pbv1.StreamQueryResult) (elements []*streamv1.Element) {
+// buildElementsFromQueryResults builds the elements of the results, and records their series in series if it isn't nil.
+func buildElementsFromQueryResults(results []pbv1.StreamQueryResult, series map[*streamv1.Element]common.SeriesID) (elements []*streamv1.Element) {
 	for _, result := range results {
+		// the elements are copied one by one rather than the blocks of them if the result is iterable
+		if ir, ok := result.(pbv1.StreamIterResult); ok {
+			for se, ok := ir.Next(); ok; se, ok = ir.Next() {
+				e := buildElement(se)
+				if series != nil {
+					series[e] = se.SID
+				}
+				elements = append(elements, e)
+			}
+			continue
+		}
 		for {
 			r := result.Pull()
 			if r == nil {
@@ -196,9 +530,32 @@ func buildElementsFromQueryResults(results []pbv1.StreamQueryResult) (elements [
 						})
 					}
 				}
+				if series != nil {
+					series[e] = r.SID
+				}
 				elements = append(elements, e)
 			}
 		}
 	}
 	return
 }
+
+func buildElement(se pbv1.StreamElement) *streamv1.Element {
+	e := &streamv1.Element{
+		Timestamp: timestamppb.New(time.Unix(0, se.Timestamp)),
+		ElementId: se.ElementID,
+	}
+	for _, tf := range se.TagFamilies {
+		tagFamily := &modelv1.TagFamily{
+			Name: tf.Name,
+		}
+		e.TagFamilies = append(e.TagFamilies, tagFamily)
+		for _, t := range tf.Tags {
+			tagFamily.Tags = append(tagFamily.Tags, &modelv1.Tag{
+				Key:   t.Name,
+				Value: t.Values[0],
+			})
+		}
+	}
+	return e
+}
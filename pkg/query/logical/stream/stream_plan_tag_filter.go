@@ -20,9 +20,14 @@ package stream
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/api/common"
 	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
 	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
 	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
 	"github.com/apache/skywalking-banyandb/pkg/index"
@@ -41,6 +46,7 @@ type unresolvedTagFilter struct {
 	metadata       *commonv1.Metadata
 	criteria       *modelv1.Criteria
 	projectionTags [][]*logical.Tag
+	shardIDs       []common.ShardID
 }
 
 func (uis *unresolvedTagFilter) Analyze(s logical.Schema) (logical.Plan, error) {
@@ -74,6 +80,19 @@ func (uis *unresolvedTagFilter) Analyze(s logical.Schema) (logical.Plan, error)
 		}
 	}
 	ctx.projectionTags = projTags
+	ctx.tagPredicates = buildTagPredicates(uis.criteria, entityDict, s, projTags)
+	if ctx.filter != nil && ctx.filter != logical.Enode {
+		if predicates, ok := buildIndexedPredicates(uis.criteria, entityDict, s, projTags); ok && len(predicates) > 0 {
+			indexedFilter, errFilter := logical.BuildTagFilter(uis.criteria, entityDict, indexedConditions{s}, false)
+			if errFilter != nil {
+				return nil, errFilter
+			}
+			ctx.blockScan = &blockScan{
+				predicates: append(predicates, ctx.tagPredicates...),
+				tagFilter:  indexedFilter,
+			}
+		}
+	}
 	plan := uis.selectIndexScanner(ctx)
 	if uis.criteria != nil {
 		tagFilter, errFilter := logical.BuildTagFilter(uis.criteria, entityDict, s, len(ctx.globalConditions) > 1)
@@ -97,11 +116,15 @@ func (uis *unresolvedTagFilter) selectIndexScanner(ctx *analyzeContext) logical.
 		metadata:          uis.metadata,
 		filter:            ctx.filter,
 		entities:          ctx.entities,
+		tagPredicates:     ctx.tagPredicates,
+		blockScan:         ctx.blockScan,
+		shardIDs:          uis.shardIDs,
 		l:                 logger.GetLogger("query", "stream", "local-index"),
 	}
 }
 
 func tagFilter(startTime, endTime time.Time, metadata *commonv1.Metadata, criteria *modelv1.Criteria, projection [][]*logical.Tag,
+	shardIDs []common.ShardID,
 ) logical.UnresolvedPlan {
 	return &unresolvedTagFilter{
 		startTime:      startTime,
@@ -109,6 +132,7 @@ func tagFilter(startTime, endTime time.Time, metadata *commonv1.Metadata, criter
 		metadata:       metadata,
 		criteria:       criteria,
 		projectionTags: projection,
+		shardIDs:       shardIDs,
 	}
 }
 
@@ -117,6 +141,8 @@ type analyzeContext struct {
 	filter           index.Filter
 	entities         [][]*modelv1.TagValue
 	projectionTags   []pbv1.TagProjection
+	blockScan        *blockScan
+	tagPredicates    []pbv1.TagPredicate
 	globalConditions []interface{}
 	projTagsRefs     [][]*logical.TagRef
 }
@@ -128,6 +154,139 @@ func newAnalyzerContext(s logical.Schema) *analyzeContext {
 	}
 }
 
+// buildTagPredicates returns the HAVING and NOT_HAVING conditions on the projected tags, the LT, LE, GT and GE
+// conditions on the projected string and int64 tags, and the EQ conditions
+// on the projected tags with bloom filters, which are pushed down to the blocks. Only the conditions every
+// matching element has to meet are returned, the tag filter still evaluates the whole criteria.
+func buildTagPredicates(criteria *modelv1.Criteria, entityDict map[string]int, s logical.Schema,
+	projection []pbv1.TagProjection,
+) []pbv1.TagPredicate {
+	switch criteria.GetExp().(type) {
+	case *modelv1.Criteria_Condition:
+		cond := criteria.GetCondition()
+		equal := cond.Op == modelv1.Condition_BINARY_OP_EQ && hasBloomFilter(s, cond.Name)
+		var rangeOp modelv1.Condition_BinaryOp
+		switch cond.Op {
+		case modelv1.Condition_BINARY_OP_LT, modelv1.Condition_BINARY_OP_LE, modelv1.Condition_BINARY_OP_GT, modelv1.Condition_BINARY_OP_GE:
+			if isScalarTag(s, cond.Name) {
+				rangeOp = cond.Op
+			}
+		}
+		if !equal && rangeOp == modelv1.Condition_BINARY_OP_UNSPECIFIED &&
+			cond.Op != modelv1.Condition_BINARY_OP_HAVING && cond.Op != modelv1.Condition_BINARY_OP_NOT_HAVING {
+			return nil
+		}
+		if _, ok := entityDict[cond.Name]; ok {
+			return nil
+		}
+		if ok, _ := s.IndexDefined(cond.Name); ok {
+			return nil
+		}
+		for _, tp := range projection {
+			for _, name := range tp.Names {
+				if name == cond.Name {
+					return []pbv1.TagPredicate{{
+						Family:  tp.Family,
+						Name:    cond.Name,
+						Value:   cond.Value,
+						Not:     cond.Op == modelv1.Condition_BINARY_OP_NOT_HAVING,
+						Equal:   equal,
+						RangeOp: rangeOp,
+					}}
+				}
+			}
+		}
+	case *modelv1.Criteria_Le:
+		le := criteria.GetLe()
+		if le.Op != modelv1.LogicalExpression_LOGICAL_OP_AND {
+			return nil
+		}
+		return append(buildTagPredicates(le.Left, entityDict, s, projection),
+			buildTagPredicates(le.Right, entityDict, s, projection)...)
+	}
+	return nil
+}
+
+// buildIndexedPredicates returns the conditions on the indexed tags as the predicates on the blocks, which make a block scan
+// the alternative to the index scan. It returns false if the criteria isn't a conjunction, or some condition on an indexed tag
+// can't be evaluated on the blocks, i.e. it's neither an equality nor a comparison, or the tag isn't a projected string or int64
+// one stored in the blocks.
+func buildIndexedPredicates(criteria *modelv1.Criteria, entityDict map[string]int, s logical.Schema,
+	projection []pbv1.TagProjection,
+) ([]pbv1.TagPredicate, bool) {
+	switch criteria.GetExp().(type) {
+	case *modelv1.Criteria_Condition:
+		cond := criteria.GetCondition()
+		if _, ok := entityDict[cond.Name]; ok {
+			return nil, true
+		}
+		if ok, _ := s.IndexDefined(cond.Name); !ok {
+			return nil, true
+		}
+		tp := pbv1.TagPredicate{Name: cond.Name, Value: cond.Value}
+		switch cond.Op {
+		case modelv1.Condition_BINARY_OP_EQ:
+			tp.Equal = true
+		case modelv1.Condition_BINARY_OP_LT, modelv1.Condition_BINARY_OP_LE, modelv1.Condition_BINARY_OP_GT, modelv1.Condition_BINARY_OP_GE:
+			tp.RangeOp = cond.Op
+		default:
+			return nil, false
+		}
+		if tagSpec := s.FindTagSpecByName(cond.Name); !isScalarTag(s, cond.Name) || tagSpec.Spec.GetIndexedOnly() {
+			return nil, false
+		}
+		for _, p := range projection {
+			if slices.Contains(p.Names, cond.Name) {
+				tp.Family = p.Family
+				return []pbv1.TagPredicate{tp}, true
+			}
+		}
+		return nil, false
+	case *modelv1.Criteria_Le:
+		le := criteria.GetLe()
+		if le.Op != modelv1.LogicalExpression_LOGICAL_OP_AND {
+			return nil, false
+		}
+		left, ok := buildIndexedPredicates(le.Left, entityDict, s, projection)
+		if !ok {
+			return nil, false
+		}
+		right, ok := buildIndexedPredicates(le.Right, entityDict, s, projection)
+		if !ok {
+			return nil, false
+		}
+		return append(left, right...), true
+	}
+	return nil, true
+}
+
+// indexedConditions makes BuildTagFilter keep the conditions on the indexed tags only, which the block scan evaluates
+// in place of the index.
+type indexedConditions struct {
+	logical.IndexChecker
+}
+
+func (ic indexedConditions) IndexDefined(tagName string) (bool, *databasev1.IndexRule) {
+	ok, _ := ic.IndexChecker.IndexDefined(tagName)
+	return !ok, nil
+}
+
+// hasBloomFilter reports whether the blocks build the bloom filters of the string or int64 tag.
+func hasBloomFilter(s logical.Schema, tagName string) bool {
+	tagSpec := s.FindTagSpecByName(tagName)
+	return tagSpec != nil && tagSpec.Spec.GetBloomFilter() && isScalarTag(s, tagName)
+}
+
+// isScalarTag reports whether the tag is a string or int64 one, whose min and max are recorded by the blocks.
+func isScalarTag(s logical.Schema, tagName string) bool {
+	tagSpec := s.FindTagSpecByName(tagName)
+	if tagSpec == nil {
+		return false
+	}
+	t := tagSpec.Spec.GetType()
+	return t == databasev1.TagType_TAG_TYPE_STRING || t == databasev1.TagType_TAG_TYPE_INT
+}
+
 var (
 	_ logical.Plan              = (*tagFilterPlan)(nil)
 	_ executor.StreamExecutable = (*tagFilterPlan)(nil)
@@ -148,10 +307,15 @@ func newTagFilter(s logical.Schema, parent logical.Plan, tagFilter logical.TagFi
 }
 
 func (t *tagFilterPlan) Execute(ec context.Context) ([]*streamv1.Element, error) {
+	if executor.FromColumnBatches(ec) != nil {
+		return nil, errors.New("a columnar query doesn't support the criteria on the tags out of the entity")
+	}
 	entities, err := t.parent.(executor.StreamExecutable).Execute(ec)
 	if err != nil {
 		return nil, err
 	}
+	span := executor.FromSpan(ec).Child("tag filter")
+	defer span.End()
 	filteredElements := make([]*streamv1.Element, 0)
 	for _, e := range entities {
 		ok, err := t.tagFilter.Match(logical.TagFamilies(e.TagFamilies), t.s)
@@ -162,6 +326,7 @@ func (t *tagFilterPlan) Execute(ec context.Context) ([]*streamv1.Element, error)
 			filteredElements = append(filteredElements, e)
 		}
 	}
+	span.Add(executor.CounterRows, int64(len(filteredElements)))
 	return filteredElements, nil
 }
 
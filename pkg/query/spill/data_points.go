@@ -0,0 +1,162 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spill
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"google.golang.org/protobuf/proto"
+
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+)
+
+// partitionBlockSize is the max bytes of the data points a partition holds in memory before writing them as a block.
+const partitionBlockSize = 1 << 20
+
+// DataPoints partitions the data points of a measure query, and spills each partition to its own temp file.
+// The partitions are read back one by one, so that a consumer only holds a partition in memory at a time.
+// Close removes the files. DataPoints is not safe for concurrent use.
+type DataPoints struct {
+	dir        string
+	partitions []dataPointPartition
+	decoder    encoding.BytesBlockDecoder
+	spilled    int64
+}
+
+type dataPointPartition struct {
+	file  *os.File
+	items [][]byte
+	size  int
+}
+
+// NewDataPoints returns DataPoints spilling n partitions to the files in dir.
+func NewDataPoints(dir string, n int) *DataPoints {
+	return &DataPoints{dir: dir, partitions: make([]dataPointPartition, n)}
+}
+
+// Partitions returns the number of the partitions.
+func (d *DataPoints) Partitions() int {
+	return len(d.partitions)
+}
+
+// Append appends the data point to the partition.
+func (d *DataPoints) Append(partition int, dp *measurev1.DataPoint) error {
+	b, err := proto.Marshal(dp)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the data point")
+	}
+	p := &d.partitions[partition]
+	p.items = append(p.items, b)
+	p.size += len(b)
+	if p.size <= partitionBlockSize {
+		return nil
+	}
+	return d.flush(p)
+}
+
+func (d *DataPoints) flush(p *dataPointPartition) error {
+	if len(p.items) == 0 {
+		return nil
+	}
+	if p.file == nil {
+		f, err := os.CreateTemp(d.dir, "*"+fileSuffix)
+		if err != nil {
+			return errors.Wrap(err, "failed to create the spill file")
+		}
+		p.file = f
+	}
+	n, err := writeBlock(p.file, p.items)
+	if err != nil {
+		return err
+	}
+	d.spilled += n
+	p.items = nil
+	p.size = 0
+	return nil
+}
+
+// SpilledBytes returns the bytes written to disk.
+func (d *DataPoints) SpilledBytes() int64 {
+	return d.spilled
+}
+
+// Read returns all the data points appended to the partition, and removes its file.
+// The data points mustn't be appended to the partition after calling Read.
+func (d *DataPoints) Read(partition int) ([]*measurev1.DataPoint, error) {
+	p := &d.partitions[partition]
+	var dataPoints []*measurev1.DataPoint
+	if p.file != nil {
+		name := p.file.Name()
+		if _, err := p.file.Seek(0, io.SeekStart); err != nil {
+			return nil, errors.Wrapf(err, "failed to seek the spill file %s", name)
+		}
+		r := bufio.NewReader(p.file)
+		for {
+			// the decoder reuses its buffer, so the items are unmarshaled before reading the next block
+			items, err := readBlock(r, &d.decoder, name)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if dataPoints, err = unmarshalDataPoints(dataPoints, items); err != nil {
+				return nil, err
+			}
+		}
+		if err := removeFile(p.file); err != nil {
+			return nil, err
+		}
+		p.file = nil
+	}
+	dataPoints, err := unmarshalDataPoints(dataPoints, p.items)
+	p.items = nil
+	p.size = 0
+	return dataPoints, err
+}
+
+func unmarshalDataPoints(dst []*measurev1.DataPoint, items [][]byte) ([]*measurev1.DataPoint, error) {
+	for _, item := range items {
+		dp := &measurev1.DataPoint{}
+		if err := proto.Unmarshal(item, dp); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal a spilled data point")
+		}
+		dst = append(dst, dp)
+	}
+	return dst, nil
+}
+
+// Close removes the files of the partitions and drops the data points left in memory.
+func (d *DataPoints) Close() error {
+	var err error
+	for i := range d.partitions {
+		p := &d.partitions[i]
+		p.items = nil
+		if p.file == nil {
+			continue
+		}
+		err = multierr.Append(err, removeFile(p.file))
+		p.file = nil
+	}
+	return err
+}
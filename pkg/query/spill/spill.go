@@ -0,0 +1,244 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package spill accumulates the results of a query, and spills them to disk once they exceed a memory threshold.
+package spill
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+	"github.com/apache/skywalking-banyandb/pkg/convert"
+	"github.com/apache/skywalking-banyandb/pkg/encoding"
+)
+
+const (
+	fileSuffix = ".spill"
+	// a block is prefixed by the number of elements and the size of the encoded block
+	blockHeaderSize = 16
+)
+
+// Export is the response of exporting a stream query.
+// The receiver streams the elements back and closes them.
+type Export struct {
+	Elements     *Elements
+	NodeFailures []*modelv1.NodeFailure
+	Warnings     []*modelv1.BlockWarning
+}
+
+// Elements accumulates the elements of a stream query in order.
+// Once the accumulated elements exceed the threshold, they are encoded into a block
+// and appended to a temp file, which is removed by Close.
+// Elements is not safe for concurrent use.
+type Elements struct {
+	file      *os.File
+	reader    *bufio.Reader
+	dir       string
+	buf       []*streamv1.Element
+	decoder   encoding.BytesBlockDecoder
+	threshold int
+	size      int
+	spilled   int64
+}
+
+// NewElements returns Elements spilling to a file in dir.
+// Spilling is disabled if dir is empty.
+func NewElements(dir string, threshold int) *Elements {
+	return &Elements{dir: dir, threshold: threshold}
+}
+
+// Append appends an element after the ones appended before.
+func (e *Elements) Append(element *streamv1.Element) error {
+	e.buf = append(e.buf, element)
+	if e.dir == "" {
+		return nil
+	}
+	e.size += proto.Size(element)
+	if e.size <= e.threshold {
+		return nil
+	}
+	return e.spill()
+}
+
+func (e *Elements) spill() error {
+	if e.file == nil {
+		f, err := os.CreateTemp(e.dir, "*"+fileSuffix)
+		if err != nil {
+			return errors.Wrap(err, "failed to create the spill file")
+		}
+		e.file = f
+	}
+	items := make([][]byte, 0, len(e.buf))
+	for _, element := range e.buf {
+		b, err := proto.Marshal(element)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal the element")
+		}
+		items = append(items, b)
+	}
+	n, err := writeBlock(e.file, items)
+	if err != nil {
+		return err
+	}
+	e.spilled += n
+	for i := range e.buf {
+		e.buf[i] = nil
+	}
+	e.buf = e.buf[:0]
+	e.size = 0
+	return nil
+}
+
+// SpilledBytes returns the bytes written to disk.
+func (e *Elements) SpilledBytes() int64 {
+	return e.spilled
+}
+
+// Next returns the next batch of elements in the appended order. It returns io.EOF once all of them are returned.
+// The spilled blocks are read back one by one, then the elements left in memory.
+// Elements mustn't be appended after calling Next.
+func (e *Elements) Next() ([]*streamv1.Element, error) {
+	if e.file != nil {
+		if e.reader == nil {
+			if _, err := e.file.Seek(0, io.SeekStart); err != nil {
+				return nil, errors.Wrapf(err, "failed to seek the spill file %s", e.file.Name())
+			}
+			e.reader = bufio.NewReader(e.file)
+		}
+		elements, err := e.readBlock()
+		if err == nil {
+			return elements, nil
+		}
+		if !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if err = e.removeFile(); err != nil {
+			return nil, err
+		}
+	}
+	if len(e.buf) == 0 {
+		return nil, io.EOF
+	}
+	elements := e.buf
+	e.buf = nil
+	return elements, nil
+}
+
+func (e *Elements) readBlock() ([]*streamv1.Element, error) {
+	items, err := readBlock(e.reader, &e.decoder, e.file.Name())
+	if err != nil {
+		return nil, err
+	}
+	elements := make([]*streamv1.Element, 0, len(items))
+	for _, item := range items {
+		element := &streamv1.Element{}
+		if err = proto.Unmarshal(item, element); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal an element of the spill file %s", e.file.Name())
+		}
+		elements = append(elements, element)
+	}
+	return elements, nil
+}
+
+// Close removes the spill file and drops the elements left in memory.
+func (e *Elements) Close() error {
+	e.buf = nil
+	return e.removeFile()
+}
+
+func (e *Elements) removeFile() error {
+	if e.file == nil {
+		return nil
+	}
+	f := e.file
+	e.file = nil
+	e.reader = nil
+	return removeFile(f)
+}
+
+func removeFile(f *os.File) error {
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close the spill file %s", f.Name())
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		return errors.Wrapf(err, "failed to remove the spill file %s", f.Name())
+	}
+	return nil
+}
+
+// Clean creates dir if it doesn't exist, and removes the spill files left in it by a previous process.
+func Clean(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.Wrapf(err, "failed to create the spill root %s", dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the spill root %s", dir)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileSuffix) {
+			continue
+		}
+		if err = os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return errors.Wrapf(err, "failed to remove the spill file %s", entry.Name())
+		}
+	}
+	return nil
+}
+
+// writeBlock encodes the items into a block, and appends it to the file. It returns the bytes written.
+func writeBlock(f *os.File, items [][]byte) (int64, error) {
+	block := encoding.EncodeBytesBlock(nil, items)
+	dst := make([]byte, 0, blockHeaderSize+len(block))
+	dst = append(dst, convert.Uint64ToBytes(uint64(len(items)))...)
+	dst = append(dst, convert.Uint64ToBytes(uint64(len(block)))...)
+	dst = append(dst, block...)
+	if _, err := f.Write(dst); err != nil {
+		return 0, errors.Wrapf(err, "failed to write the spill file %s", f.Name())
+	}
+	return int64(len(dst)), nil
+}
+
+// readBlock reads the next block of the file named name. It returns io.EOF if there are no more blocks.
+func readBlock(r *bufio.Reader, decoder *encoding.BytesBlockDecoder, name string) ([][]byte, error) {
+	header := make([]byte, blockHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, errors.Wrapf(err, "failed to read the block header of the spill file %s", name)
+	}
+	count := convert.BytesToUint64(header[:8])
+	block := make([]byte, convert.BytesToUint64(header[8:]))
+	if _, err := io.ReadFull(r, block); err != nil {
+		return nil, errors.Wrapf(err, "failed to read a block of the spill file %s", name)
+	}
+	decoder.Reset()
+	items, err := decoder.Decode(nil, block, count)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode a block of the spill file %s", name)
+	}
+	return items, nil
+}
@@ -0,0 +1,124 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spill
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	streamv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/stream/v1"
+)
+
+func TestElements(t *testing.T) {
+	dir := t.TempDir()
+	e := NewElements(dir, 256)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, e.Append(&streamv1.Element{
+			ElementId: fmt.Sprintf("element-%d", i),
+			Timestamp: timestamppb.Now(),
+		}))
+	}
+	assert.Positive(t, e.SpilledBytes())
+	assert.Len(t, spillFiles(t, dir), 1)
+
+	var got []string
+	for {
+		elements, err := e.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		for _, element := range elements {
+			got = append(got, element.ElementId)
+		}
+	}
+	require.Len(t, got, 100)
+	for i, id := range got {
+		assert.Equal(t, fmt.Sprintf("element-%d", i), id)
+	}
+	assert.Empty(t, spillFiles(t, dir), "the drained spill file is removed")
+	require.NoError(t, e.Close())
+}
+
+func TestElementsClose(t *testing.T) {
+	dir := t.TempDir()
+	e := NewElements(dir, 0)
+	require.NoError(t, e.Append(&streamv1.Element{ElementId: "element"}))
+	assert.Len(t, spillFiles(t, dir), 1)
+	require.NoError(t, e.Close())
+	assert.Empty(t, spillFiles(t, dir))
+	_, err := e.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestClean(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spill")
+	e := NewElements(dir, 0)
+	require.NoError(t, Clean(dir))
+	require.NoError(t, e.Append(&streamv1.Element{ElementId: "element"}))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other"), nil, 0o600))
+	require.NoError(t, Clean(dir))
+	assert.Empty(t, spillFiles(t, dir))
+	_, err := os.Stat(filepath.Join(dir, "other"))
+	assert.NoError(t, err, "only the spill files are removed")
+}
+
+func TestDataPoints(t *testing.T) {
+	dir := t.TempDir()
+	d := NewDataPoints(dir, 2)
+	payload := make([]byte, 64<<10)
+	for i := 0; i < 40; i++ {
+		require.NoError(t, d.Append(0, &measurev1.DataPoint{
+			Fields: []*measurev1.DataPoint_Field{{
+				Name:  fmt.Sprintf("field-%d", i),
+				Value: &modelv1.FieldValue{Value: &modelv1.FieldValue_BinaryData{BinaryData: payload}},
+			}},
+		}))
+	}
+	require.NoError(t, d.Append(1, &measurev1.DataPoint{Fields: []*measurev1.DataPoint_Field{{Name: "field"}}}))
+	assert.Positive(t, d.SpilledBytes())
+	assert.Len(t, spillFiles(t, dir), 1, "the partition held in memory has no file")
+
+	dataPoints, err := d.Read(0)
+	require.NoError(t, err)
+	require.Len(t, dataPoints, 40)
+	for i, dp := range dataPoints {
+		assert.Equal(t, fmt.Sprintf("field-%d", i), dp.GetFields()[0].GetName())
+	}
+	assert.Empty(t, spillFiles(t, dir), "the read partition is removed")
+	dataPoints, err = d.Read(1)
+	require.NoError(t, err)
+	require.Len(t, dataPoints, 1)
+	assert.Equal(t, "field", dataPoints[0].GetFields()[0].GetName())
+	require.NoError(t, d.Close())
+}
+
+func spillFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+fileSuffix))
+	require.NoError(t, err)
+	return files
+}
@@ -293,6 +293,18 @@ func (sr *schemaRepo) LoadGroup(name string) (Group, bool) {
 	return g, g.isInit()
 }
 
+func (sr *schemaRepo) LoadAllGroups() []Group {
+	sr.RLock()
+	defer sr.RUnlock()
+	groups := make([]Group, 0, len(sr.data))
+	for _, g := range sr.data {
+		if g.isInit() {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
 func (sr *schemaRepo) LoadResource(metadata *commonv1.Metadata) (Resource, bool) {
 	g, ok := sr.LoadGroup(metadata.Group)
 	if !ok {
@@ -461,7 +473,7 @@ func (g *group) storeResource(ctx context.Context, resourceSchema ResourceSchema
 	if !g.isPortable() {
 		dbSupplier = g
 	}
-	sm, errTS := g.resourceSchemaSupplier.OpenResource(g.GetSchema().GetResourceOpts().ShardNum, dbSupplier, resource)
+	sm, errTS := g.resourceSchemaSupplier.OpenResource(g.GetSchema().GetResourceOpts(), dbSupplier, resource)
 	if errTS != nil {
 		return nil, errTS
 	}
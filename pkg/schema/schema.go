@@ -80,7 +80,7 @@ type Supplier interface {
 // ResourceSchemaSupplier allows get a ResourceSchema from the metadata.
 type ResourceSchemaSupplier interface {
 	ResourceSchema(metadata *commonv1.Metadata) (ResourceSchema, error)
-	OpenResource(shardNum uint32, supplier Supplier, spec Resource) (io.Closer, error)
+	OpenResource(groupOpts *commonv1.ResourceOpts, supplier Supplier, spec Resource) (io.Closer, error)
 }
 
 // ResourceSupplier allows open a resource and its embedded tsdb.
@@ -95,6 +95,7 @@ type Repository interface {
 	SendMetadataEvent(MetadataEvent)
 	StoreGroup(groupMeta *commonv1.Metadata) (*group, error)
 	LoadGroup(name string) (Group, bool)
+	LoadAllGroups() []Group
 	LoadResource(metadata *commonv1.Metadata) (Resource, bool)
 	Close()
 	StopCh() <-chan struct{}
@@ -38,6 +38,7 @@ var (
 // Handler implements a unix signal handler as run.GroupService.
 type Handler struct {
 	signal chan os.Signal
+	hangup chan os.Signal
 	cancel chan struct{}
 }
 
@@ -51,7 +52,12 @@ func (h *Handler) PreRun(_ context.Context) error {
 	h.cancel = make(chan struct{})
 	h.signal = make(chan os.Signal, 1)
 	signal.Notify(h.signal,
-		syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+		syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	// SIGHUP doesn't stop the server, which reloads the TLS files instead if they're enabled.
+	// It's subscribed here as well, otherwise it kills the server running without the TLS reloaders.
+	// The channel is never drained, the signals are dropped once it's full.
+	h.hangup = make(chan os.Signal, 1)
+	signal.Notify(h.hangup, syscall.SIGHUP)
 	return nil
 }
 
@@ -69,4 +75,6 @@ func (h *Handler) Serve() run.StopNotify {
 func (h *Handler) GracefulStop() {
 	signal.Stop(h.signal)
 	close(h.signal)
+	signal.Stop(h.hangup)
+	close(h.hangup)
 }
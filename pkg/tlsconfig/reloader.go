@@ -0,0 +1,252 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package tlsconfig provides the TLS configs of the servers and the clients whose certificates are reloaded
+// once their files change or SIGHUP is received, so that a long-running node rotates them without restarting.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/apache/skywalking-banyandb/pkg/logger"
+	"github.com/apache/skywalking-banyandb/pkg/run"
+)
+
+// checkInterval is the interval of checking the changes of the files.
+const checkInterval = 10 * time.Second
+
+var (
+	errNoPeerCert = errors.New("the peer presents no certificate")
+	errNoCA       = errors.New("no certificate is found in the CA file")
+)
+
+// Reloader holds a certificate with its key and a CA bundle loaded from the files, and reloads them
+// once the files change or SIGHUP is received. The files which fail to be reloaded leave the loaded ones in use.
+type Reloader struct {
+	log      *logger.Logger
+	cert     atomic.Pointer[tls.Certificate]
+	pool     atomic.Pointer[x509.CertPool]
+	closer   *run.Closer
+	sig      chan os.Signal
+	stamps   map[string]stamp
+	certFile string
+	keyFile  string
+	caFile   string
+	mu       sync.Mutex
+}
+
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader loads the certificate, the key and the CA bundle. Either the certificate and the key or the CA bundle
+// might be empty, e.g. a server doesn't verify the client certificates without the CA bundle, and a client
+// verifies the server by the system roots without it.
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("the cert file and the key file should be set together")
+	}
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the files again.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stamps := make(map[string]stamp, 3)
+	for _, f := range r.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return errors.Wrapf(err, "failed to stat %s", f)
+		}
+		stamps[f] = stamp{modTime: fi.ModTime(), size: fi.Size()}
+	}
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		c, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to load cert and key")
+		}
+		cert = &c
+	}
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to read the CA file")
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.WithMessage(errNoCA, r.caFile)
+		}
+	}
+	if cert != nil {
+		r.cert.Store(cert)
+	}
+	if pool != nil {
+		r.pool.Store(pool)
+	}
+	r.stamps = stamps
+	return nil
+}
+
+// Start reloads the files once they change or SIGHUP is received until Stop is called.
+func (r *Reloader) Start(l *logger.Logger) {
+	r.log = l
+	r.closer = run.NewCloser(1)
+	r.sig = make(chan os.Signal, 1)
+	signal.Notify(r.sig, syscall.SIGHUP)
+	go func() {
+		defer r.closer.Done()
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.closer.CloseNotify():
+				return
+			case <-r.sig:
+				r.reload("SIGHUP is received")
+			case <-ticker.C:
+				if r.changed() {
+					r.reload("the files change")
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops reloading the files.
+func (r *Reloader) Stop() {
+	if r.closer == nil {
+		return
+	}
+	signal.Stop(r.sig)
+	r.closer.CloseThenWait()
+}
+
+func (r *Reloader) reload(reason string) {
+	if err := r.Reload(); err != nil {
+		r.log.Error().Err(err).Str("reason", reason).Msg("failed to reload the TLS files, the loaded ones are kept")
+		return
+	}
+	r.log.Info().Str("reason", reason).Strs("files", r.files()).Msg("reloaded the TLS files")
+}
+
+func (r *Reloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			// the files might be replaced at the moment, they're checked again later
+			continue
+		}
+		if s := r.stamps[f]; !s.modTime.Equal(fi.ModTime()) || s.size != fi.Size() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) files() []string {
+	var files []string
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// ServerConfig returns the config of a server presenting the certificate. The clients are required to present
+// the certificates signed by the CA bundle if it's set, which is the mutual TLS.
+func (r *Reloader) ServerConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.cert.Load(), nil
+		},
+	}
+	if r.caFile == "" {
+		return cfg
+	}
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	cfg.ClientCAs = r.pool.Load()
+	// the client CAs are picked by every handshake, since the config can't be changed once it's in use
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := cfg.Clone()
+		c.GetConfigForClient = nil
+		c.ClientCAs = r.pool.Load()
+		return c, nil
+	}
+	return cfg
+}
+
+// ClientConfig returns the config of a client presenting the certificate if it's set. The server certificate is
+// verified by the CA bundle if it's set, or by the system roots.
+func (r *Reloader) ClientConfig() *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if r.certFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.cert.Load(), nil
+		}
+	}
+	if r.caFile == "" {
+		return cfg
+	}
+	// the roots can't be changed once the config is in use, so the server certificate is verified
+	// by VerifyConnection against the roots loaded last instead of the default verification
+	cfg.InsecureSkipVerify = true //nolint:gosec
+	cfg.VerifyConnection = r.verifyServer
+	return cfg
+}
+
+func (r *Reloader) verifyServer(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errNoPeerCert
+	}
+	opts := x509.VerifyOptions{
+		Roots:         r.pool.Load(),
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+		return errors.Wrap(err, "failed to verify the server certificate")
+	}
+	return nil
+}
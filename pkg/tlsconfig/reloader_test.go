@@ -0,0 +1,185 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM encoded certificate and key signed by the CA.
+func (ca *testCA) issue(t *testing.T, name string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+type testFiles struct {
+	cert, key, ca string
+}
+
+func writeFiles(t *testing.T, dir string, ca *testCA, name string) testFiles {
+	certPEM, keyPEM := ca.issue(t, name)
+	f := testFiles{
+		cert: filepath.Join(dir, name+".crt"),
+		key:  filepath.Join(dir, name+".key"),
+		ca:   filepath.Join(dir, name+"-ca.crt"),
+	}
+	require.NoError(t, os.WriteFile(f.cert, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(f.key, keyPEM, 0o600))
+	require.NoError(t, os.WriteFile(f.ca, ca.pem, 0o600))
+	return f
+}
+
+func handshake(t *testing.T, server, client *tls.Config) error {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	errCh := make(chan error, 1)
+	go func() {
+		conn, errAccept := lis.Accept()
+		if errAccept != nil {
+			errCh <- errAccept
+			return
+		}
+		defer conn.Close()
+		errCh <- tls.Server(conn, server).Handshake()
+	}()
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	cfg := client.Clone()
+	cfg.ServerName = "server"
+	errClient := tls.Client(conn, cfg).Handshake()
+	// the server might reject the client certificate after the client completes the handshake
+	conn.Close()
+	errServer := <-errCh
+	if errClient != nil {
+		return errClient
+	}
+	return errServer
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "ca")
+	sf := writeFiles(t, dir, ca, "server")
+	cf := writeFiles(t, dir, ca, "client")
+	server, err := NewReloader(sf.cert, sf.key, cf.ca)
+	require.NoError(t, err)
+	client, err := NewReloader(cf.cert, cf.key, sf.ca)
+	require.NoError(t, err)
+	require.NoError(t, handshake(t, server.ServerConfig(), client.ClientConfig()))
+
+	// the client without a certificate is rejected
+	anonymous, err := NewReloader("", "", sf.ca)
+	require.NoError(t, err)
+	assert.Error(t, handshake(t, server.ServerConfig(), anonymous.ClientConfig()))
+
+	// the server certificate signed by an unknown CA is rejected
+	other := newTestCA(t, "other")
+	of := writeFiles(t, t.TempDir(), other, "server")
+	rogue, err := NewReloader(of.cert, of.key, cf.ca)
+	require.NoError(t, err)
+	assert.Error(t, handshake(t, rogue.ServerConfig(), client.ClientConfig()))
+
+	// the configs in use pick the rotated certificates and CAs once they're reloaded
+	serverCfg, clientCfg := server.ServerConfig(), client.ClientConfig()
+	rotated := newTestCA(t, "rotated")
+	writeFiles(t, dir, rotated, "server")
+	writeFiles(t, dir, rotated, "client")
+	require.NoError(t, server.Reload())
+	assert.Error(t, handshake(t, serverCfg, clientCfg))
+	require.NoError(t, client.Reload())
+	assert.NoError(t, handshake(t, serverCfg, clientCfg))
+}
+
+func TestReloader_KeepsLoadedOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	f := writeFiles(t, dir, newTestCA(t, "ca"), "server")
+	r, err := NewReloader(f.cert, f.key, f.ca)
+	require.NoError(t, err)
+	loaded := r.cert.Load()
+	assert.False(t, r.changed())
+
+	require.NoError(t, os.WriteFile(f.cert, []byte("broken"), 0o600))
+	assert.True(t, r.changed())
+	assert.Error(t, r.Reload())
+	assert.Same(t, loaded, r.cert.Load())
+
+	writeFiles(t, dir, newTestCA(t, "ca"), "server")
+	require.NoError(t, r.Reload())
+	assert.NotSame(t, loaded, r.cert.Load())
+	assert.False(t, r.changed())
+}
+
+func TestNewReloader_CertWithoutKey(t *testing.T) {
+	_, err := NewReloader("server.crt", "", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,112 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package integration_other_test
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	g "github.com/onsi/ginkgo/v2"
+	gm "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gleak"
+
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	"github.com/apache/skywalking-banyandb/pkg/client"
+	"github.com/apache/skywalking-banyandb/pkg/test/flags"
+	"github.com/apache/skywalking-banyandb/pkg/test/setup"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+)
+
+var _ = g.Describe("Client", func() {
+	var deferFn func()
+	var baseTime time.Time
+	var c *client.Client
+	var goods []gleak.Goroutine
+
+	g.BeforeEach(func() {
+		var addr string
+		addr, _, deferFn = setup.Standalone()
+		var err error
+		c, err = client.Connect(addr)
+		gm.Expect(err).NotTo(gm.HaveOccurred())
+		ns := timestamp.NowMilli().UnixNano()
+		baseTime = time.Unix(0, ns-ns%int64(time.Minute))
+		goods = gleak.Goroutines()
+	})
+	g.AfterEach(func() {
+		gm.Expect(c.Close()).To(gm.Succeed())
+		deferFn()
+		gm.Eventually(gleak.Goroutines, flags.EventuallyTimeout).ShouldNot(gleak.HaveLeaked(goods))
+	})
+
+	g.It("writes and queries a stream", func() {
+		ctx := context.Background()
+		var elements []*client.Element
+		for i := 0; i < 10; i++ {
+			elements = append(elements, client.NewElement(strconv.Itoa(i), baseTime.Add(time.Duration(i)*time.Second)).
+				Tag("trace_id", "trace_"+strconv.Itoa(i)).
+				Tag("state", i%2).
+				Tag("service_id", "webapp").
+				Tag("service_instance_id", "10.0.0.1_id"))
+		}
+		gm.Expect(c.WriteStream(ctx, "default", "sw", elements...)).To(gm.Succeed())
+		gm.Expect(c.WriteStream(ctx, "default", "sw", client.NewElement("x", baseTime).Tag("unknown", "x"))).
+			To(gm.MatchError(gm.ContainSubstring("unknown")))
+		gm.Eventually(func(innerGm gm.Gomega) {
+			result, err := c.QueryStream(ctx, client.NewStreamQuery("default", "sw").
+				TimeRange(baseTime.Add(-time.Minute), baseTime.Add(time.Minute)).
+				Where(client.And(client.Eq("service_id", "webapp"), client.Eq("state", 1))).
+				Project("searchable", "trace_id", "state").
+				OrderBy("", modelv1.Sort_SORT_ASC).
+				PageSize(2))
+			innerGm.Expect(err).NotTo(gm.HaveOccurred())
+			innerGm.Expect(result).To(gm.HaveLen(5))
+			for i, e := range result {
+				innerGm.Expect(e.GetElementId()).To(gm.Equal(strconv.Itoa(2*i + 1)))
+			}
+		}, flags.EventuallyTimeout).Should(gm.Succeed())
+	})
+
+	g.It("writes and aggregates a measure", func() {
+		ctx := context.Background()
+		var dataPoints []*client.DataPoint
+		for i := 0; i < 6; i++ {
+			dataPoints = append(dataPoints, client.NewDataPoint(baseTime.Add(time.Duration(i)*time.Second)).
+				Tag("id", strconv.Itoa(i)).
+				Tag("entity_id", "entity_"+strconv.Itoa(i%2)).
+				Field("total", 100).
+				Field("value", i))
+		}
+		gm.Expect(c.WriteMeasure(ctx, "sw_metric", "service_cpm_minute", dataPoints...)).To(gm.Succeed())
+		gm.Eventually(func(innerGm gm.Gomega) {
+			result, err := c.QueryMeasure(ctx, client.NewMeasureQuery("sw_metric", "service_cpm_minute").
+				TimeRange(baseTime.Add(-time.Minute), baseTime.Add(time.Minute)).
+				Project("default", "entity_id").
+				Fields("value").
+				GroupBy("value", "default", "entity_id").
+				Aggregate(modelv1.AggregationFunction_AGGREGATION_FUNCTION_SUM, "value"))
+			innerGm.Expect(err).NotTo(gm.HaveOccurred())
+			sums := make(map[string]int64)
+			for _, dp := range result {
+				sums[dp.GetTagFamilies()[0].GetTags()[0].GetValue().GetStr().GetValue()] = dp.GetFields()[0].GetValue().GetInt().GetValue()
+			}
+			innerGm.Expect(sums).To(gm.Equal(map[string]int64{"entity_0": 6, "entity_1": 9}))
+		}, flags.EventuallyTimeout).Should(gm.Succeed())
+	})
+})
@@ -0,0 +1,165 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package integration_other_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	g "github.com/onsi/ginkgo/v2"
+	gm "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	commonv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/common/v1"
+	databasev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/database/v1"
+	measurev1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/measure/v1"
+	modelv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/model/v1"
+	propertyv1 "github.com/apache/skywalking-banyandb/api/proto/banyandb/property/v1"
+	"github.com/apache/skywalking-banyandb/pkg/grpchelper"
+	"github.com/apache/skywalking-banyandb/pkg/test/flags"
+	"github.com/apache/skywalking-banyandb/pkg/test/setup"
+	"github.com/apache/skywalking-banyandb/pkg/timestamp"
+	casesMeasureData "github.com/apache/skywalking-banyandb/test/cases/measure/data"
+	casesStreamData "github.com/apache/skywalking-banyandb/test/cases/stream/data"
+)
+
+// exportLine is a line of the export, whose int64 tag values are kept as they're encoded.
+type exportLine struct {
+	Result struct {
+		Elements []struct {
+			TagFamilies []struct {
+				Tags []struct {
+					Value map[string]map[string]any `json:"value"`
+					Key   string                    `json:"key"`
+				} `json:"tags"`
+			} `json:"tagFamilies"`
+			ElementID string `json:"elementId"`
+		} `json:"elements"`
+	} `json:"result"`
+}
+
+var _ = g.Describe("HTTP API", func() {
+	var deferFn func()
+	var conn *grpc.ClientConn
+	var httpAddr string
+	var baseTime time.Time
+
+	post := func(path, body string) (int, []byte) {
+		resp, err := http.Post("http://"+httpAddr+path, "application/json", bytes.NewBufferString(body))
+		gm.Expect(err).NotTo(gm.HaveOccurred())
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		gm.Expect(err).NotTo(gm.HaveOccurred())
+		return resp.StatusCode, data
+	}
+	timeRange := func() string {
+		return fmt.Sprintf(`{"begin": %q, "end": %q}`,
+			baseTime.Add(-20*time.Minute).Format(time.RFC3339Nano), baseTime.Add(5*time.Minute).Format(time.RFC3339Nano))
+	}
+
+	g.BeforeEach(func() {
+		var addr string
+		addr, httpAddr, deferFn = setup.Standalone()
+		var err error
+		conn, err = grpchelper.Conn(addr, 10*time.Second, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		gm.Expect(err).NotTo(gm.HaveOccurred())
+		baseTime = timestamp.NowMilli()
+	})
+	g.AfterEach(func() {
+		gm.Expect(conn.Close()).To(gm.Succeed())
+		deferFn()
+	})
+
+	g.It("queries the top-n entities", func() {
+		casesMeasureData.Write(conn, "service_instance_cpm_minute", "sw_metric", "service_instance_cpm_minute_data.json", baseTime, time.Minute)
+		body := fmt.Sprintf(`{"metadata": {"group": "sw_metric", "name": "service_instance_cpm_minute_top_bottom_100"},
+			"timeRange": %s, "topN": 3, "agg": "AGGREGATION_FUNCTION_MAX", "fieldValueSort": "SORT_DESC"}`, timeRange())
+		gm.Eventually(func(innerGm gm.Gomega) {
+			code, data := post("/api/v1/measure/topn", body)
+			innerGm.Expect(code).To(gm.Equal(http.StatusOK), string(data))
+			resp := new(measurev1.TopNResponse)
+			innerGm.Expect(protojson.Unmarshal(data, resp)).To(gm.Succeed())
+			innerGm.Expect(resp.Lists).To(gm.HaveLen(1))
+			innerGm.Expect(resp.Lists[0].Items).NotTo(gm.BeEmpty())
+		}, flags.EventuallyTimeout).Should(gm.Succeed())
+	})
+
+	g.It("exports the stream elements as newline-delimited JSON", func() {
+		casesStreamData.Write(conn, "data.json", baseTime, 500*time.Millisecond)
+		body := fmt.Sprintf(`{"metadata": {"group": "default", "name": "sw"}, "timeRange": %s,
+			"projection": {"tagFamilies": [{"name": "searchable", "tags": ["trace_id", "state", "start_time"]}]}}`, timeRange())
+		gm.Eventually(func(innerGm gm.Gomega) {
+			code, data := post("/api/v1/stream/export", body)
+			innerGm.Expect(code).To(gm.Equal(http.StatusOK), string(data))
+			tags := make(map[string]map[string]any)
+			scanner := bufio.NewScanner(bytes.NewReader(data))
+			for scanner.Scan() {
+				var line exportLine
+				innerGm.Expect(json.Unmarshal(scanner.Bytes(), &line)).To(gm.Succeed(), scanner.Text())
+				for _, e := range line.Result.Elements {
+					for _, tf := range e.TagFamilies {
+						for _, t := range tf.Tags {
+							if t.Key == "trace_id" {
+								continue
+							}
+							tags[fmt.Sprintf("%s.%s", e.ElementID, t.Key)] = t.Value["int"]
+						}
+					}
+				}
+			}
+			innerGm.Expect(scanner.Err()).NotTo(gm.HaveOccurred())
+			innerGm.Expect(tags).To(gm.HaveLen(10))
+			// the int64 values are encoded as strings, and the zero values are emitted
+			innerGm.Expect(tags).To(gm.HaveKeyWithValue("1.start_time", gm.HaveKeyWithValue("value", "1622933202000000000")))
+			innerGm.Expect(tags).To(gm.HaveKeyWithValue("3.state", gm.HaveKeyWithValue("value", "0")))
+		}, flags.EventuallyTimeout).Should(gm.Succeed())
+	})
+
+	g.It("lists the properties by a posted request", func() {
+		_, err := databasev1.NewGroupRegistryServiceClient(conn).Create(context.Background(),
+			&databasev1.GroupRegistryServiceCreateRequest{Group: &commonv1.Group{Metadata: &commonv1.Metadata{Name: "g"}}})
+		gm.Expect(err).NotTo(gm.HaveOccurred())
+		client := propertyv1.NewPropertyServiceClient(conn)
+		for _, id := range []string{"1", "2"} {
+			_, err = client.Apply(context.Background(), &propertyv1.ApplyRequest{Property: &propertyv1.Property{
+				Metadata: &propertyv1.Metadata{Container: &commonv1.Metadata{Name: "p", Group: "g"}, Id: id},
+				Tags: []*modelv1.Tag{
+					{Key: "t1", Value: &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: "v1"}}}},
+					{Key: "t2", Value: &modelv1.TagValue{Value: &modelv1.TagValue_Str{Str: &modelv1.Str{Value: "v2"}}}},
+				},
+			}})
+			gm.Expect(err).NotTo(gm.HaveOccurred())
+		}
+		code, data := post("/api/v1/property/lists", `{"container": {"group": "g", "name": "p"}, "ids": ["2"], "tags": ["t2"]}`)
+		gm.Expect(code).To(gm.Equal(http.StatusOK), string(data))
+		resp := new(propertyv1.ListResponse)
+		gm.Expect(protojson.Unmarshal(data, resp)).To(gm.Succeed())
+		gm.Expect(resp.Property).To(gm.HaveLen(1))
+		gm.Expect(resp.Property[0].Metadata.Id).To(gm.Equal("2"))
+		gm.Expect(resp.Property[0].Tags).To(gm.HaveLen(1))
+		gm.Expect(resp.Property[0].Tags[0].Key).To(gm.Equal("t2"))
+	})
+})